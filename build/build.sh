@@ -10,11 +10,12 @@ COMPILE_THREAD_NUM=-j4
 BUILD_GAMMA=ON
 BUILD_GAMMA_TEST=OFF
 BUILD_GAMMA_TYPE=Release
+BUILD_CGO=ON
 
 # version value
 BUILD_VERSION="latest"
 
-while getopts ":n:g:tdh" opt; do
+while getopts ":n:g:tdch" opt; do
   case $opt in
   n)
     COMPILE_THREAD_NUM="-j"$OPTARG
@@ -28,6 +29,11 @@ while getopts ":n:g:tdh" opt; do
     BUILD_GAMMA_TYPE=Debug
     echo "BUILD_GAMMA_TYPE="$BUILD_GAMMA_TYPE
     ;;
+  c)
+    BUILD_CGO=OFF
+    BUILD_GAMMA=OFF
+    echo "BUILD_CGO=OFF, only the go-hnsw engine"
+    ;;
   g)
     BUILD_GAMMA=$OPTARG
     echo "BUILD_GAMMA="$BUILD_GAMMA
@@ -39,6 +45,7 @@ while getopts ":n:g:tdh" opt; do
     echo -e "\t-g\t\tbuild gamma or not: [ON|OFF]"
     echo -e "\t-t\t\tbuild gamma test"
     echo -e "\t-d\t\tbuild gamma type=Debug"
+    echo -e "\t-c\t\tbuild without cgo and gamma, spaces use index type go-hnsw"
     exit 0
     ;;
   ?)
@@ -99,8 +106,15 @@ function build_vearch() {
   export LD_LIBRARY_PATH=$LD_LIBRARY_PATH:$GAMMAOUT
   export LIBRARY_PATH=$LIBRARY_PATH:$GAMMAOUT
 
+  if [ $BUILD_CGO == "OFF" ]; then
+    export CGO_ENABLED=0
+  fi
+
   echo "build vearch"
   go build -a -tags="vector" -ldflags "$flags" -o $BUILDOUT/vearch $ROOT/cmd/vearch/startup.go
+
+  echo "build vearchctl"
+  go build -o $BUILDOUT/vearchctl $ROOT/cmd/vearchctl
 }
 
 get_version
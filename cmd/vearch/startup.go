@@ -15,26 +15,32 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/client"
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/master"
+	"github.com/vearch/vearch/v3/internal/pkg/diagnose"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/metrics/mserver"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/pkg/signals"
 	"github.com/vearch/vearch/v3/internal/pkg/tracer"
 	"github.com/vearch/vearch/v3/internal/pkg/vearchlog"
 	"github.com/vearch/vearch/v3/internal/ps"
+	"github.com/vearch/vearch/v3/internal/ps/psutil"
 	"github.com/vearch/vearch/v3/internal/router"
 )
 
@@ -44,11 +50,13 @@ var (
 	CommitID     = "xxxxx"
 	confPath     string
 	masterName   string
+	checkData    bool
 )
 
 func init() {
 	flag.StringVar(&confPath, "conf", getDefaultConfigFile(), "vearch config path")
 	flag.StringVar(&masterName, "master", "", "vearch config for master name, is on local start two master must use it")
+	flag.BoolVar(&checkData, "check", false, "check the local data of ps without joining the cluster, print a json report and exit")
 }
 
 const (
@@ -59,7 +67,42 @@ const (
 	DefaultResourceName = "default"
 )
 
+var (
+	diagnoseOnce   sync.Once
+	diagnoseClient *client.Client
+	diagnoseErr    error
+)
+
+// authorizeAdmin only passes users whose role can access all resources,
+// the client is created on first use since master may not be ready at startup
+func authorizeAdmin(ctx context.Context, user, password string) error {
+	diagnoseOnce.Do(func() {
+		diagnoseClient, diagnoseErr = client.NewClient(config.Conf())
+	})
+	if diagnoseErr != nil {
+		return diagnoseErr
+	}
+	u, err := diagnoseClient.Master().QueryUserByPassword(ctx, user, password)
+	if err != nil {
+		return fmt.Errorf("auth header user %s is invalid", user)
+	}
+	if u.RoleName == nil {
+		return fmt.Errorf("user %s has no role", user)
+	}
+	role := &entity.Role{}
+	if value, exists := entity.RoleMap[*u.RoleName]; exists {
+		*role = value
+	} else if role, err = diagnoseClient.Master().QueryRole(ctx, *u.RoleName); err != nil {
+		return err
+	}
+	return role.HasPermissionForResources(diagnose.PathPrefix, http.MethodPut)
+}
+
 func newProfileHttpServer(port uint16) {
+	var handler http.Handler = diagnose.NewHandler(config.Conf().GetLogDir())
+	if !config.Conf().Global.SkipAuth {
+		handler = diagnose.BasicAuth(handler, authorizeAdmin)
+	}
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -68,7 +111,7 @@ func newProfileHttpServer(port uint16) {
 		}()
 
 		for i := 0; i < 3; i++ {
-			err := http.ListenAndServe("0.0.0.0:"+cast.ToString(port), nil)
+			err := http.ListenAndServe("0.0.0.0:"+cast.ToString(port), handler)
 			if err != nil {
 				log.Error(err.Error())
 				time.Sleep(10 * time.Second)
@@ -80,9 +123,51 @@ func newProfileHttpServer(port uint16) {
 	}()
 }
 
-func main() {
-	runtime.GOMAXPROCS(runtime.NumCPU())
+// applyRuntimeLimits limits the go runtime by the config of the only
+// component the process runs, or the global one, and by its cgroup
+func applyRuntimeLimits(tags map[string]bool) {
+	var models []config.Model
+	if tags[masterTag] || tags[allTag] {
+		models = append(models, config.Master)
+	}
+	if tags[psTag] || tags[allTag] {
+		models = append(models, config.PS)
+	}
+	if tags[routerTag] || tags[allTag] {
+		models = append(models, config.Router)
+	}
+	limits := &diagnose.Limits{}
+	if cfg := config.Conf().RuntimeConfig(models...); cfg != nil {
+		limits = &diagnose.Limits{
+			MaxProcs:         cfg.MaxProcs,
+			MemoryLimit:      cfg.MemoryLimit,
+			MemoryLimitRatio: cfg.MemoryLimitRatio,
+			GCPercent:        cfg.GCPercent,
+		}
+	}
+	if err := diagnose.ApplyLimits(limits, diagnose.CgroupRoot); err != nil {
+		log.Error("apply runtime limits error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// checkLocalData prints the report of checking the local data of ps, the
+// exit code is 1 if it found errors
+func checkLocalData() int {
+	report := psutil.CheckLocalData()
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Error("encode check report error: %v", err)
+		return 2
+	}
+	fmt.Println(string(b))
+	if !report.Ok {
+		return 1
+	}
+	return 0
+}
 
+func main() {
 	config.SetConfigVersion(BuildVersion, BuildTime, CommitID)
 
 	flag.Parse()
@@ -119,7 +204,27 @@ func main() {
 
 	logName := strings.ToUpper(strings.Join(args, "-"))
 	vearchlog.SetConfig(config.Conf().GetLogFileNum(), 1024*1024*config.Conf().GetLogFileSize())
-	log.Regist(vearchlog.NewVearchLog(config.Conf().GetLogDir(), logName, config.Conf().GetLevel(), false))
+	redact.SetLogPayload(config.Conf().Global.LogPayload)
+	if base := config.Conf().Global.Base; base.LogFormat != "" {
+		structuredLog, err := vearchlog.NewStructuredLog(&vearchlog.StructuredConfig{
+			Dir:              base.Log,
+			Module:           logName,
+			Level:            base.Level,
+			Format:           base.LogFormat,
+			Sink:             base.LogSink,
+			ModuleLevels:     base.LogModuleLevels,
+			SampleInitial:    base.LogSampleInitial,
+			SampleThereafter: base.LogSampleThereafter,
+		})
+		if err != nil {
+			log.Error("init structured log error: %v", err)
+			os.Exit(1)
+		}
+		defer structuredLog.Close()
+		log.Regist(structuredLog)
+	} else {
+		log.Regist(vearchlog.NewVearchLog(config.Conf().GetLogDir(), logName, config.Conf().GetLevel(), false))
+	}
 
 	log.Info("start server by version:[%s] commitID:[%s]", BuildVersion, CommitID)
 	log.Info("config file: %v", confPath)
@@ -127,6 +232,10 @@ func main() {
 	entity.SetPrefixAndSequence(config.Conf().Global.Name)
 	log.Info("The cluster prefix is: %v", entity.PrefixEtcdClusterID)
 
+	if checkData {
+		os.Exit(checkLocalData())
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -191,6 +300,9 @@ func main() {
 		}
 	}
 
+	// after master which knows its own config
+	applyRuntimeLimits(tags)
+
 	// start ps
 	if tags[psTag] || tags[allTag] {
 		if err := config.Conf().Validate(config.PS); err != nil {
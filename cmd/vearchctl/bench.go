@@ -0,0 +1,412 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/algorithm"
+)
+
+type benchConfig struct {
+	db          string
+	space       string
+	field       string
+	dim         int
+	metric      string
+	num         int
+	queries     int
+	k           int
+	input       string
+	searchRatio float64
+	batch       int
+	concurrency string
+	duration    time.Duration
+	indexParams string
+	skipLoad    bool
+	recall      bool
+	seed        int64
+}
+
+type benchRunner struct {
+	cfg     *benchConfig
+	client  *apiClient
+	base    [][]float32
+	queries [][]float32
+	// labels of the base vectors written to the label field, for quickstart
+	labels []string
+}
+
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	cfg := &benchConfig{}
+	c := addClientFlags(fs)
+	fs.StringVar(&cfg.db, "db", "", "db name")
+	fs.StringVar(&cfg.space, "space", "", "space name")
+	fs.StringVar(&cfg.field, "field", "", "vector field name")
+	fs.IntVar(&cfg.dim, "dim", 128, "dimension of synthetic vectors")
+	fs.StringVar(&cfg.metric, "metric", algorithm.MetricL2, "metric of the space, L2 or InnerProduct, for the brute-force baseline")
+	fs.IntVar(&cfg.num, "num", 10000, "num of base vectors to upsert")
+	fs.IntVar(&cfg.queries, "queries", 100, "num of query vectors")
+	fs.IntVar(&cfg.k, "k", 10, "limit of search and k of recall")
+	fs.StringVar(&cfg.input, "input", "", "file of vectors, one vector per line as json array or separated by space or comma, synthetic vectors are used if empty")
+	fs.Float64Var(&cfg.searchRatio, "search-ratio", 0.9, "ratio of searches in the load, the rest are upserts")
+	fs.IntVar(&cfg.batch, "batch", 100, "documents of each upsert request")
+	fs.StringVar(&cfg.concurrency, "concurrency", "1,4,16", "concurrency ramp, each level runs for duration")
+	fs.DurationVar(&cfg.duration, "duration", 30*time.Second, "duration of each concurrency level")
+	fs.StringVar(&cfg.indexParams, "index-params", "", "index_params of search as json, such as {\"efSearch\":64}")
+	fs.BoolVar(&cfg.skipLoad, "skip-load", false, "skip upserting base vectors, they should be loaded by a former run with the same flags")
+	fs.BoolVar(&cfg.recall, "recall", true, "report recall against the brute-force baseline")
+	fs.Int64Var(&cfg.seed, "seed", 1, "seed of synthetic vectors and load mix")
+	fs.Parse(args)
+
+	if cfg.db == "" || cfg.space == "" || cfg.field == "" {
+		return fmt.Errorf("db, space and field should not be empty")
+	}
+	if cfg.k <= 0 || cfg.batch <= 0 || cfg.num <= 0 || cfg.queries <= 0 {
+		return fmt.Errorf("k, batch, num and queries should be positive")
+	}
+	if cfg.searchRatio < 0 || cfg.searchRatio > 1 {
+		return fmt.Errorf("search-ratio should be in [0, 1]")
+	}
+	if cfg.indexParams != "" && !json.Valid([]byte(cfg.indexParams)) {
+		return fmt.Errorf("index-params is not valid json: %s", cfg.indexParams)
+	}
+	levels, err := parseLevels(cfg.concurrency)
+	if err != nil {
+		return err
+	}
+
+	b := &benchRunner{cfg: cfg, client: c}
+	if err := b.loadVectors(); err != nil {
+		return err
+	}
+	if !cfg.skipLoad {
+		start := time.Now()
+		if err := b.load(levels[len(levels)-1]); err != nil {
+			return err
+		}
+		fmt.Printf("loaded %d vectors in %v\n", len(b.base), time.Since(start).Round(time.Millisecond))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "concurrency\top\tcount\terrors\tqps\tp50\tp90\tp99\tmax")
+	for _, level := range levels {
+		fmt.Fprintf(os.Stderr, "running concurrency %d for %v\n", level, cfg.duration)
+		stats := b.runLevel(level)
+		for _, op := range []string{"search", "upsert"} {
+			if s := stats[op]; s != nil {
+				s.print(w, level, op, cfg.duration)
+			}
+		}
+	}
+	w.Flush()
+
+	if cfg.recall {
+		recall, err := b.measureRecall()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("recall@%d: %.4f over %d queries\n", cfg.k, recall, len(b.queries))
+	}
+	return nil
+}
+
+func parseLevels(s string) ([]int, error) {
+	levels := make([]int, 0)
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid concurrency: %s", s)
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+// loadVectors reads the base and query vectors from input, or generates
+// them, queries are taken from the tail of input
+func (b *benchRunner) loadVectors() error {
+	cfg := b.cfg
+	if cfg.input == "" {
+		r := rand.New(rand.NewSource(cfg.seed))
+		b.base = randomVectors(r, cfg.num, cfg.dim)
+		b.queries = randomVectors(r, cfg.queries, cfg.dim)
+		return nil
+	}
+
+	vectors, err := readVectors(cfg.input, cfg.num+cfg.queries)
+	if err != nil {
+		return err
+	}
+	if len(vectors) <= cfg.queries {
+		return fmt.Errorf("input has %d vectors, should be more than queries %d", len(vectors), cfg.queries)
+	}
+	b.base = vectors[:len(vectors)-cfg.queries]
+	b.queries = vectors[len(vectors)-cfg.queries:]
+	return nil
+}
+
+func randomVectors(r *rand.Rand, n, dim int) [][]float32 {
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = r.Float32()
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+// readVectors reads at most limit vectors of the same dimension from file
+func readVectors(file string, limit int) ([][]float32, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vectors := make([][]float32, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for line := 1; scanner.Scan() && len(vectors) < limit; line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		v, err := parseVector(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", file, line, err)
+		}
+		if len(vectors) > 0 && len(v) != len(vectors[0]) {
+			return nil, fmt.Errorf("%s line %d: dimension %d, expect %d", file, line, len(v), len(vectors[0]))
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, scanner.Err()
+}
+
+func parseVector(text string) ([]float32, error) {
+	v := make([]float32, 0)
+	if strings.HasPrefix(text, "[") {
+		err := json.Unmarshal([]byte(text), &v)
+		return v, err
+	}
+	for _, s := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, float32(f))
+	}
+	return v, nil
+}
+
+func benchDocID(i int) string {
+	return "bench-" + strconv.Itoa(i)
+}
+
+func (b *benchRunner) upsert(from, to int) error {
+	docs := make([]map[string]interface{}, 0, to-from)
+	for i := from; i < to; i++ {
+		doc := map[string]interface{}{"_id": benchDocID(i), b.cfg.field: b.base[i]}
+		if b.labels != nil {
+			doc[quickstartLabelField] = b.labels[i]
+		}
+		docs = append(docs, doc)
+	}
+	return b.client.post("/document/upsert", map[string]interface{}{
+		"db_name":    b.cfg.db,
+		"space_name": b.cfg.space,
+		"documents":  docs,
+	}, nil)
+}
+
+type searchResult struct {
+	Documents [][]struct {
+		ID    string  `json:"_id"`
+		Score float64 `json:"_score"`
+	} `json:"documents"`
+}
+
+func (b *benchRunner) search(query []float32) ([]string, error) {
+	body := map[string]interface{}{
+		"db_name":    b.cfg.db,
+		"space_name": b.cfg.space,
+		"vectors":    []map[string]interface{}{{"field": b.cfg.field, "feature": query}},
+		"limit":      b.cfg.k,
+		"fields":     []string{"_id"},
+	}
+	if b.cfg.indexParams != "" {
+		body["index_params"] = json.RawMessage(b.cfg.indexParams)
+	}
+	result := &searchResult{}
+	if err := b.client.post("/document/search", body, result); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, b.cfg.k)
+	if len(result.Documents) > 0 {
+		for _, doc := range result.Documents[0] {
+			ids = append(ids, doc.ID)
+		}
+	}
+	return ids, nil
+}
+
+// load upserts all base vectors in batches with concurrency workers
+func (b *benchRunner) load(concurrency int) error {
+	batches := make(chan int)
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for from := range batches {
+				to := from + b.cfg.batch
+				if to > len(b.base) {
+					to = len(b.base)
+				}
+				if err := b.upsert(from, to); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+	for from := 0; from < len(b.base) && err == nil; from += b.cfg.batch {
+		select {
+		case batches <- from:
+		case err = <-errs:
+		}
+	}
+	close(batches)
+	wg.Wait()
+	if err == nil && len(errs) > 0 {
+		err = <-errs
+	}
+	return err
+}
+
+type opStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func (s *opStats) print(w *tabwriter.Writer, concurrency int, op string, duration time.Duration) {
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	qps := 0.0
+	if duration > 0 {
+		qps = float64(len(s.latencies)) / duration.Seconds()
+	}
+	fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%.1f\t%v\t%v\t%v\t%v\n", concurrency, op, len(s.latencies), s.errors, qps,
+		percentile(s.latencies, 0.5), percentile(s.latencies, 0.9), percentile(s.latencies, 0.99), percentile(s.latencies, 1))
+}
+
+// percentile returns the p percentile of sorted latencies to the microsecond
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return algorithm.Percentile(sorted, p).Round(time.Microsecond)
+}
+
+// runLevel runs the mix of searches and upserts with concurrency workers for
+// the configured duration. Upserts rewrite base vectors with the same values,
+// so the brute-force baseline stays valid.
+func (b *benchRunner) runLevel(concurrency int) map[string]*opStats {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		stats = map[string]*opStats{}
+	)
+	deadline := time.Now().Add(b.cfg.duration)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(b.cfg.seed + int64(concurrency*1000+worker)))
+			local := map[string]*opStats{}
+			for time.Now().Before(deadline) {
+				op := "upsert"
+				start := time.Now()
+				var err error
+				if r.Float64() < b.cfg.searchRatio {
+					op = "search"
+					_, err = b.search(b.queries[r.Intn(len(b.queries))])
+				} else {
+					from := r.Intn(len(b.base))
+					to := from + b.cfg.batch
+					if to > len(b.base) {
+						to = len(b.base)
+					}
+					err = b.upsert(from, to)
+				}
+				s := local[op]
+				if s == nil {
+					s = &opStats{}
+					local[op] = s
+				}
+				if err != nil {
+					s.errors++
+					continue
+				}
+				s.latencies = append(s.latencies, time.Since(start))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for op, s := range local {
+				if stats[op] == nil {
+					stats[op] = &opStats{}
+				}
+				stats[op].latencies = append(stats[op].latencies, s.latencies...)
+				stats[op].errors += s.errors
+			}
+		}(i)
+	}
+	wg.Wait()
+	return stats
+}
+
+// measureRecall searches every query and compares the result with the
+// brute-force nearest neighbors over the base vectors
+func (b *benchRunner) measureRecall() (float64, error) {
+	truth := algorithm.BruteForceKNN(b.cfg.metric, b.base, b.queries, b.cfg.k)
+	sum := 0.0
+	for i, q := range b.queries {
+		ids, err := b.search(q)
+		if err != nil {
+			return 0, err
+		}
+		expect := make([]string, len(truth[i]))
+		for j, index := range truth[i] {
+			expect[j] = benchDocID(index)
+		}
+		sum += algorithm.Recall(ids, expect, b.cfg.k)
+	}
+	return sum / float64(len(b.queries)), nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiClient calls the http api of router or master
+type apiClient struct {
+	url      string
+	user     string
+	password string
+	http     *http.Client
+}
+
+// addClientFlags registers the flags of the connection to a cluster
+func addClientFlags(fs *flag.FlagSet) *apiClient {
+	c := &apiClient{http: &http.Client{Timeout: 60 * time.Second}}
+	fs.StringVar(&c.url, "url", "http://127.0.0.1:9001", "vearch router url")
+	fs.StringVar(&c.user, "user", "root", "user name")
+	fs.StringVar(&c.password, "password", "", "user password")
+	return c
+}
+
+type apiResponse struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// do sends body as json and decodes the data of response into out if it is
+// not nil, a response with non zero code is returned as error
+func (c *apiClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(c.url, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.user, c.password)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	r := &apiResponse{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return fmt.Errorf("%s %s status %d: %s", method, path, resp.StatusCode, string(b))
+	}
+	if r.Code != 0 {
+		return fmt.Errorf("%s %s code %d: %s", method, path, r.Code, r.Msg)
+	}
+	if out != nil && len(r.Data) > 0 {
+		return json.Unmarshal(r.Data, out)
+	}
+	return nil
+}
+
+func (c *apiClient) post(path string, body, out interface{}) error {
+	return c.do(http.MethodPost, path, body, out)
+}
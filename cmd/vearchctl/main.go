@@ -0,0 +1,65 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// vearchctl is the command line tool to operate a vearch cluster by its
+// router and master http api
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]*command{
+	"bench":       {usage: "run a load of upserts and searches against a space and report latency and recall", run: runBench},
+	"meta-schema": {usage: "dual write, validate and finalize the metadata of a kind in a new schema version on master", run: runMetaSchema},
+	"quickstart":  {usage: "create a demo space, load the bundled or a downloaded dataset and run example searches with recall", run: runQuickstart},
+	"replay":      {usage: "re-issue the searches of a router query log at the captured or a scaled rate", run: runReplay},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: vearchctl <command> [flags]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun 'vearchctl <command> -h' for the flags of a command\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
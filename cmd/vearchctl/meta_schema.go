@@ -0,0 +1,58 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// runMetaSchema migrates the metadata of a kind to a new schema version, the
+// steps are run one by one so routers can be upgraded in between:
+// dual writes the target version beside the current one, status validates
+// it, finalize cuts over once routers read the target and abort rolls back
+func runMetaSchema(args []string) error {
+	fs := flag.NewFlagSet("meta-schema", flag.ExitOnError)
+	client := addClientFlags(fs)
+	kind := fs.String("kind", "role", "metadata kind")
+	action := fs.String("action", "status", "status, dual, finalize or abort")
+	target := fs.Int("target", 2, "schema version to dual write, for dual")
+	fs.Parse(args)
+
+	path := "/meta_schema/" + *kind
+	var (
+		status json.RawMessage
+		err    error
+	)
+	switch *action {
+	case "status":
+		err = client.do(http.MethodGet, path, nil, &status)
+	case "dual":
+		err = client.post(path+"/dual", map[string]int{"target": *target}, &status)
+	case "finalize", "abort":
+		err = client.post(path+"/"+*action, nil, &status)
+	default:
+		return fmt.Errorf("unknown action %s", *action)
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(status)
+}
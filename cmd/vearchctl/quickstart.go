@@ -0,0 +1,381 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/algorithm"
+)
+
+const (
+	quickstartVectorField = "vector"
+	quickstartLabelField  = "label"
+
+	// the demo dataset is generated the same on every run
+	demoTopics    = 20
+	demoDimension = 32
+	demoNoise     = 0.05
+)
+
+// sampleDataset is the base vectors loaded by quickstart and the queries
+// searched, labels name the vectors in the example searches
+type sampleDataset struct {
+	metric      string
+	base        [][]float32
+	queries     [][]float32
+	labels      []string
+	queryLabels []string
+}
+
+type sampleLoader func(data string, num, queries int) (*sampleDataset, error)
+
+// bundledDatasets are built in vearchctl, data is not used
+var bundledDatasets = map[string]sampleLoader{
+	"demo": demoDataset,
+}
+
+// fileDatasets read the files of a dataset downloaded to data by the user
+var fileDatasets = map[string]sampleLoader{
+	"siftsmall": siftSmallDataset,
+	"glove":     gloveDataset,
+}
+
+// runQuickstart creates a demo space, loads the bundled dataset or a
+// downloaded one into it, prints a few example searches and the recall
+// against the brute-force baseline
+func runQuickstart(args []string) error {
+	fs := flag.NewFlagSet("quickstart", flag.ExitOnError)
+	c := addClientFlags(fs)
+	db := fs.String("db", "quickstart", "db name, created if not found")
+	space := fs.String("space", "", "space name, the dataset name if empty")
+	name := fs.String("dataset", "demo", "bundled dataset, demo is the only one and labels vectors by topic")
+	data := fs.String("data", "", "load a downloaded dataset of -format instead of the bundled one")
+	format := fs.String("format", "", "format of -data, siftsmall is the dir of siftsmall.tar.gz of the ANN_SIFT10K corpus extracted, glove is a GloVe text file such as glove.6B.50d.txt")
+	num := fs.Int("num", 5000, "max num of base vectors")
+	queries := fs.Int("queries", 100, "num of query vectors")
+	k := fs.Int("k", 10, "limit of search and k of recall")
+	examples := fs.Int("examples", 3, "num of example searches printed")
+	recreate := fs.Bool("recreate", false, "delete the space first if it exists")
+	fs.Parse(args)
+
+	loader := bundledDatasets[*name]
+	if *data != "" || *format != "" {
+		if loader = fileDatasets[*format]; loader == nil {
+			return fmt.Errorf("unknown format %s of -data, should be siftsmall or glove", *format)
+		}
+		*name = *format
+	} else if loader == nil {
+		return fmt.Errorf("unknown dataset %s, only demo is bundled, use -data and -format to load a downloaded one", *name)
+	}
+	if *num <= 0 || *queries <= 0 || *k <= 0 {
+		return fmt.Errorf("num, queries and k should be positive")
+	}
+	if *space == "" {
+		*space = *name
+	}
+	ds, err := loader(*data, *num, *queries)
+	if err != nil {
+		return err
+	}
+	dim := len(ds.base[0])
+	fmt.Printf("dataset %s: %d base and %d query vectors of dimension %d\n", *name, len(ds.base), len(ds.queries), dim)
+
+	if err := createQuickstartSpace(c, *db, *space, dim, ds.metric, *recreate); err != nil {
+		return err
+	}
+	fmt.Printf("created space %s/%s\n", *db, *space)
+
+	b := &benchRunner{
+		cfg: &benchConfig{
+			db:          *db,
+			space:       *space,
+			field:       quickstartVectorField,
+			metric:      ds.metric,
+			k:           *k,
+			batch:       100,
+			indexParams: `{"efSearch":64}`,
+		},
+		client:  c,
+		base:    ds.base,
+		queries: ds.queries,
+		labels:  ds.labels,
+	}
+	start := time.Now()
+	if err := waitQuickstartSpace(b); err != nil {
+		return err
+	}
+	if err := b.load(4); err != nil {
+		return err
+	}
+	fmt.Printf("loaded %d vectors in %v\n", len(ds.base), time.Since(start).Round(time.Millisecond))
+
+	for i := 0; i < *examples && i < len(ds.queries); i++ {
+		if err := printExampleSearch(b, ds, i); err != nil {
+			return err
+		}
+	}
+
+	recall, err := b.measureRecall()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("recall@%d: %.4f over %d queries\n", *k, recall, len(ds.queries))
+	fmt.Printf("\nsearch it yourself:\n  curl -u %s:<password> -H 'Content-Type: application/json' %s/document/search -d '{\"db_name\":\"%s\",\"space_name\":\"%s\",\"vectors\":[{\"field\":\"%s\",\"feature\":[...%d floats]}],\"limit\":%d}'\n",
+		c.user, strings.TrimRight(c.url, "/"), *db, *space, quickstartVectorField, dim, *k)
+	return nil
+}
+
+func createQuickstartSpace(c *apiClient, db, space string, dim int, metric string, recreate bool) error {
+	if err := c.do(http.MethodGet, "/dbs/"+db, nil, nil); err != nil {
+		if err := c.post("/dbs/"+db, nil, nil); err != nil {
+			return err
+		}
+	}
+	if c.do(http.MethodGet, "/dbs/"+db+"/spaces/"+space, nil, nil) == nil {
+		if !recreate {
+			return fmt.Errorf("space %s/%s exists, use -recreate to delete it first or -space to name another", db, space)
+		}
+		if err := c.do(http.MethodDelete, "/dbs/"+db+"/spaces/"+space, nil, nil); err != nil {
+			return err
+		}
+	}
+	return c.post("/dbs/"+db+"/spaces", map[string]interface{}{
+		"name":          space,
+		"partition_num": 1,
+		"replica_num":   1,
+		"fields": []map[string]interface{}{
+			{
+				"name":  quickstartLabelField,
+				"type":  "string",
+				"index": map[string]interface{}{"name": quickstartLabelField, "type": "SCALAR"},
+			},
+			{
+				"name":      quickstartVectorField,
+				"type":      "vector",
+				"dimension": dim,
+				"index": map[string]interface{}{
+					"name": "gamma",
+					"type": "HNSW",
+					"params": map[string]interface{}{
+						"metric_type":    metric,
+						"nlinks":         32,
+						"efConstruction": 100,
+					},
+				},
+			},
+		},
+	}, nil)
+}
+
+// waitQuickstartSpace retries the first write until the partitions of the
+// new space have leaders
+func waitQuickstartSpace(b *benchRunner) error {
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		err := b.upsert(0, 1)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func printExampleSearch(b *benchRunner, ds *sampleDataset, i int) error {
+	result := &struct {
+		Documents [][]map[string]interface{} `json:"documents"`
+	}{}
+	err := b.client.post("/document/search", map[string]interface{}{
+		"db_name":      b.cfg.db,
+		"space_name":   b.cfg.space,
+		"vectors":      []map[string]interface{}{{"field": quickstartVectorField, "feature": ds.queries[i]}},
+		"limit":        5,
+		"fields":       []string{quickstartLabelField},
+		"index_params": map[string]int{"efSearch": 64},
+	}, result)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nquery %d", i)
+	if ds.queryLabels != nil {
+		fmt.Printf(" (%s)", ds.queryLabels[i])
+	}
+	fmt.Println(":")
+	if len(result.Documents) == 0 {
+		return nil
+	}
+	for _, doc := range result.Documents[0] {
+		score, _ := doc["_score"].(float64)
+		fmt.Printf("  %-14v %-20v score %.4f\n", doc["_id"], doc[quickstartLabelField], score)
+	}
+	return nil
+}
+
+// demoDataset generates vectors around the centers of topics, a query
+// should find the vectors of its own topic
+func demoDataset(data string, num, queries int) (*sampleDataset, error) {
+	r := rand.New(rand.NewSource(1))
+	centers := randomVectors(r, demoTopics, demoDimension)
+	ds := &sampleDataset{metric: algorithm.MetricL2}
+	around := func(topic int) []float32 {
+		v := make([]float32, demoDimension)
+		for j := range v {
+			v[j] = centers[topic][j] + float32(r.NormFloat64()*demoNoise)
+		}
+		return v
+	}
+	for i := 0; i < num; i++ {
+		ds.base = append(ds.base, around(i%demoTopics))
+		ds.labels = append(ds.labels, fmt.Sprintf("topic-%02d", i%demoTopics))
+	}
+	for i := 0; i < queries; i++ {
+		topic := r.Intn(demoTopics)
+		ds.queries = append(ds.queries, around(topic))
+		ds.queryLabels = append(ds.queryLabels, fmt.Sprintf("topic-%02d", topic))
+	}
+	return ds, nil
+}
+
+// siftSmallDataset reads the ANN_SIFT10K corpus, siftsmall.tar.gz of
+// ftp://ftp.irisa.fr/local/texmex/corpus extracted to data
+func siftSmallDataset(data string, num, queries int) (*sampleDataset, error) {
+	if data == "" {
+		return nil, fmt.Errorf("siftsmall needs -data, the dir of siftsmall.tar.gz of ftp://ftp.irisa.fr/local/texmex/corpus extracted")
+	}
+	base, err := readFvecs(filepath.Join(data, "siftsmall_base.fvecs"), num)
+	if err != nil {
+		return nil, err
+	}
+	query, err := readFvecs(filepath.Join(data, "siftsmall_query.fvecs"), queries)
+	if err != nil {
+		return nil, err
+	}
+	return &sampleDataset{metric: algorithm.MetricL2, base: base, queries: query}, nil
+}
+
+// readFvecs reads at most limit vectors of a .fvecs file, each one is its
+// dimension as int32 then its values as float32, little endian
+func readFvecs(file string, limit int) ([][]float32, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	vectors := make([][]float32, 0)
+	for len(vectors) < limit {
+		var dim int32
+		if err := binary.Read(r, binary.LittleEndian, &dim); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("%s vector %d: %v", file, len(vectors), err)
+		}
+		if dim <= 0 || (len(vectors) > 0 && int(dim) != len(vectors[0])) {
+			return nil, fmt.Errorf("%s vector %d: invalid dimension %d", file, len(vectors), dim)
+		}
+		v := make([]float32, dim)
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("%s vector %d: %v", file, len(vectors), err)
+		}
+		vectors = append(vectors, v)
+	}
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("%s has no vectors", file)
+	}
+	return vectors, nil
+}
+
+// gloveDataset reads the words of a GloVe text file, such as glove.6B.50d.txt
+// of https://nlp.stanford.edu/projects/glove, the words after the base ones
+// are the queries. Vectors are normalized and compared by inner product, so
+// by cosine
+func gloveDataset(data string, num, queries int) (*sampleDataset, error) {
+	if data == "" {
+		return nil, fmt.Errorf("glove needs -data, a GloVe text file such as glove.6B.50d.txt")
+	}
+	f, err := os.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		words   []string
+		vectors [][]float32
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for line := 1; scanner.Scan() && len(vectors) < num+queries; line++ {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 2 {
+			continue
+		}
+		v := make([]float32, len(parts)-1)
+		for j, s := range parts[1:] {
+			f, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", data, line, err)
+			}
+			v[j] = float32(f)
+		}
+		if len(vectors) > 0 && len(v) != len(vectors[0]) {
+			return nil, fmt.Errorf("%s line %d: dimension %d, expect %d", data, line, len(v), len(vectors[0]))
+		}
+		normalize(v)
+		words = append(words, parts[0])
+		vectors = append(vectors, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(vectors) <= queries {
+		return nil, fmt.Errorf("%s has %d words, should be more than queries %d", data, len(vectors), queries)
+	}
+	n := len(vectors) - queries
+	return &sampleDataset{
+		metric:      algorithm.MetricInnerProduct,
+		base:        vectors[:n],
+		queries:     vectors[n:],
+		labels:      words[:n],
+		queryLabels: words[n:],
+	}, nil
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/pkg/algorithm"
+)
+
+// fvecs encodes vectors as a .fvecs file, each one with its own dimension
+func fvecs(vectors ...[]float32) []byte {
+	var buf bytes.Buffer
+	for _, v := range vectors {
+		binary.Write(&buf, binary.LittleEndian, int32(len(v)))
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadFvecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		limit   int
+		want    [][]float32
+		wantErr bool
+	}{
+		{
+			name:  "All vectors",
+			data:  fvecs([]float32{1, 2}, []float32{3, 4}, []float32{5, 6}),
+			limit: 10,
+			want:  [][]float32{{1, 2}, {3, 4}, {5, 6}},
+		},
+		{
+			name:  "Limit",
+			data:  fvecs([]float32{1, 2}, []float32{3, 4}, []float32{5, 6}),
+			limit: 2,
+			want:  [][]float32{{1, 2}, {3, 4}},
+		},
+		{
+			name:    "Empty file",
+			data:    nil,
+			limit:   10,
+			wantErr: true,
+		},
+		{
+			name:    "Dimension mismatch",
+			data:    fvecs([]float32{1, 2}, []float32{3, 4, 5}),
+			limit:   10,
+			wantErr: true,
+		},
+		{
+			name:    "Zero dimension",
+			data:    fvecs([]float32{}),
+			limit:   10,
+			wantErr: true,
+		},
+		{
+			name:    "Truncated vector",
+			data:    fvecs([]float32{1, 2})[:10],
+			limit:   10,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFvecs(writeFile(t, "base.fvecs", tt.data), tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readFvecs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readFvecs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readFvecs(filepath.Join(t.TempDir(), "missing.fvecs"), 10); err == nil {
+		t.Error("readFvecs() of a missing file should fail")
+	}
+}
+
+func TestGloveDataset(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		num         int
+		queries     int
+		wantLabels  []string
+		wantQueries []string
+		wantErr     bool
+	}{
+		{
+			name:        "Base and queries",
+			text:        "the 3 4\nof 0 2\nand 1 0\nto 0 0\n",
+			num:         2,
+			queries:     1,
+			wantLabels:  []string{"the", "of"},
+			wantQueries: []string{"and"},
+		},
+		{
+			name:        "Fewer words than num",
+			text:        "the 3 4\nof 0 2\nand 1 0\n",
+			num:         10,
+			queries:     1,
+			wantLabels:  []string{"the", "of"},
+			wantQueries: []string{"and"},
+		},
+		{
+			name:        "Blank lines skipped",
+			text:        "the 3 4\n\nof 0 2\n",
+			num:         1,
+			queries:     1,
+			wantLabels:  []string{"the"},
+			wantQueries: []string{"of"},
+		},
+		{
+			name:    "Not more words than queries",
+			text:    "the 3 4\nof 0 2\n",
+			num:     10,
+			queries: 2,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid value",
+			text:    "the 3 4\nof x 2\n",
+			num:     1,
+			queries: 1,
+			wantErr: true,
+		},
+		{
+			name:    "Dimension mismatch",
+			text:    "the 3 4\nof 0 2 1\n",
+			num:     1,
+			queries: 1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, err := gloveDataset(writeFile(t, "glove.txt", []byte(tt.text)), tt.num, tt.queries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gloveDataset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if ds.metric != algorithm.MetricInnerProduct {
+				t.Errorf("gloveDataset() metric = %s, want %s", ds.metric, algorithm.MetricInnerProduct)
+			}
+			if !reflect.DeepEqual(ds.labels, tt.wantLabels) || !reflect.DeepEqual(ds.queryLabels, tt.wantQueries) {
+				t.Errorf("gloveDataset() labels = %v %v, want %v %v", ds.labels, ds.queryLabels, tt.wantLabels, tt.wantQueries)
+			}
+			if len(ds.base) != len(tt.wantLabels) || len(ds.queries) != len(tt.wantQueries) {
+				t.Errorf("gloveDataset() = %d base and %d queries, want %d and %d", len(ds.base), len(ds.queries), len(tt.wantLabels), len(tt.wantQueries))
+			}
+			// the first word is "the 3 4", normalized
+			if want := []float32{0.6, 0.8}; !reflect.DeepEqual(ds.base[0], want) {
+				t.Errorf("gloveDataset() first vector = %v, want %v", ds.base[0], want)
+			}
+		})
+	}
+
+	if _, err := gloveDataset("", 1, 1); err == nil {
+		t.Error("gloveDataset() without data should fail")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		v    []float32
+		want []float32
+	}{
+		{name: "Unit vector", v: []float32{0, 1}, want: []float32{0, 1}},
+		{name: "Scaled", v: []float32{3, 4}, want: []float32{0.6, 0.8}},
+		{name: "Negative", v: []float32{-3, 0, 4}, want: []float32{-0.6, 0, 0.8}},
+		{name: "Zero vector", v: []float32{0, 0}, want: []float32{0, 0}},
+		{name: "Empty", v: []float32{}, want: []float32{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalize(tt.v)
+			for i := range tt.want {
+				if math.Abs(float64(tt.v[i]-tt.want[i])) > 1e-6 {
+					t.Fatalf("normalize() = %v, want %v", tt.v, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/querylog"
+)
+
+type replayConfig struct {
+	input       string
+	speed       float64
+	concurrency int
+	db          string
+	space       string
+	limit       int
+}
+
+type replayJob struct {
+	record *querylog.Record
+	body   json.RawMessage
+	due    time.Time
+}
+
+func runReplay(args []string) error {
+	cfg := &replayConfig{}
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	client := addClientFlags(fs)
+	fs.StringVar(&cfg.input, "input", "", "query log captured by router query_log")
+	fs.Float64Var(&cfg.speed, "speed", 1, "rate relative to the capture, 2 replays twice as fast, 0 replays as fast as possible")
+	fs.IntVar(&cfg.concurrency, "concurrency", 32, "max requests in flight")
+	fs.StringVar(&cfg.db, "db", "", "replay to this db instead of the captured one")
+	fs.StringVar(&cfg.space, "space", "", "replay to this space instead of the captured one")
+	fs.IntVar(&cfg.limit, "limit", 0, "max records to replay, 0 is all")
+	fs.Parse(args)
+	if cfg.input == "" {
+		return fmt.Errorf("input should not be empty")
+	}
+	if cfg.speed < 0 || cfg.concurrency <= 0 {
+		return fmt.Errorf("speed should not be negative and concurrency should be positive")
+	}
+
+	f, err := os.Open(cfg.input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		replayed = &opStats{}
+		captured = &opStats{}
+		lags     []time.Duration
+		jobs     = make(chan *replayJob, cfg.concurrency)
+	)
+	for i := 0; i < cfg.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				lag := time.Since(job.due)
+				start := time.Now()
+				err := client.post(job.record.Path, job.body, nil)
+				cost := time.Since(start)
+
+				mu.Lock()
+				lags = append(lags, lag)
+				captured.latencies = append(captured.latencies, time.Duration(job.record.Cost*float64(time.Millisecond)))
+				if err != nil {
+					replayed.errors++
+				} else {
+					replayed.latencies = append(replayed.latencies, cost)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var first, last int64
+	count := 0
+	start := time.Now()
+	err = querylog.Read(f, func(r *querylog.Record) error {
+		if cfg.limit > 0 && count >= cfg.limit {
+			return errStopReplay
+		}
+		body, err := replayBody(r.Body, cfg.db, cfg.space)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			first = r.Time
+		}
+		count++
+		last = r.Time
+		due := time.Now()
+		if cfg.speed > 0 {
+			due = start.Add(time.Duration(float64(r.Time-first) / cfg.speed))
+			if d := time.Until(due); d > 0 {
+				time.Sleep(d)
+			}
+		}
+		if r.Path == "" {
+			r.Path = "/document/search"
+		}
+		jobs <- &replayJob{record: r, body: body, due: due}
+		if count%1000 == 0 {
+			fmt.Fprintf(os.Stderr, "replayed %d records\n", count)
+		}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	if err != nil && err != errStopReplay {
+		return err
+	}
+	duration := time.Since(start)
+
+	sort.Slice(lags, func(i, j int) bool { return lags[i] < lags[j] })
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "concurrency\top\tcount\terrors\tqps\tp50\tp90\tp99\tmax\n")
+	captured.print(w, cfg.concurrency, "captured", time.Duration(last-first))
+	replayed.print(w, cfg.concurrency, "replayed", duration)
+	w.Flush()
+	fmt.Printf("\nreplayed %d records in %v at speed %v, dispatch lag p99 %v\n", count, duration.Round(time.Millisecond), cfg.speed, percentile(lags, 0.99))
+	return nil
+}
+
+var errStopReplay = fmt.Errorf("stop replay")
+
+// replayBody points the captured body to db and space if they are set
+func replayBody(body json.RawMessage, db, space string) (json.RawMessage, error) {
+	if db == "" && space == "" {
+		return body, nil
+	}
+	m := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, err
+	}
+	if db != "" {
+		m["db_name"], _ = json.Marshal(db)
+	}
+	if space != "" {
+		m["space_name"], _ = json.Marshal(space)
+	}
+	return json.Marshal(m)
+}
@@ -28,7 +28,6 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/patrickmn/go-cache"
 	"github.com/smallnest/rpcx/share"
 	"github.com/spaolacci/murmur3"
 	"github.com/spf13/cast"
@@ -67,6 +66,17 @@ func NewClient(conf *config.Config) (client *Client, err error) {
 	return client, err
 }
 
+// NewClientWithStore create a client on the given meta store, it is used to
+// run the master on an in-memory store in simulation
+func NewClientWithStore(conf *config.Config, s store.Store) (client *Client, err error) {
+	client = &Client{}
+	if err = client.initPsClient(); err != nil {
+		return nil, err
+	}
+	client.master = &masterClient{client: client, Store: s, cfg: conf}
+	return client, nil
+}
+
 func (client *Client) initPsClient() error {
 	client.ps = &psClient{client: client}
 	client.ps.initFaultylist()
@@ -157,6 +167,10 @@ func (r *routerRequest) SetMethod(method string) *routerRequest {
 // SetHead Set head
 func (r *routerRequest) SetHead(head *vearchpb.RequestHead) *routerRequest {
 	r.head = head
+	// gets carry no head to ps
+	if asOf := head.GetParams()[entity.AsOfParam]; asOf != "" {
+		r.md[entity.AsOfParam] = asOf
+	}
 	return r
 }
 
@@ -166,6 +180,12 @@ func (r *routerRequest) SetSpace() *routerRequest {
 		return r
 	}
 	r.space, r.Err = r.client.Space(r.ctx, r.head.DbName, r.head.SpaceName)
+	if r.Err == nil {
+		switch r.md[HandlerType] {
+		case BatchHandler, DeleteDocsHandler, DeleteByQueryHandler:
+			r.Err = r.space.CheckWritable()
+		}
+	}
 	return r
 }
 
@@ -370,7 +390,6 @@ func (r *routerRequest) Execute() []*vearchpb.Item {
 		c := context.WithValue(r.ctx, share.ReqMetaDataKey, vmap.CopyMap(r.md))
 		go func(ctx context.Context, pid entity.PartitionID, d *vearchpb.PartitionData) {
 			defer wg.Done()
-			replyPartition := new(vearchpb.PartitionData)
 			defer func() {
 				if r := recover(); r != nil {
 					d.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_RECOVER, Msg: fmt.Sprintf("[Recover] partitionID: [%v], err: [%s]", pid, cast.ToString(r))}
@@ -407,8 +426,7 @@ func (r *routerRequest) Execute() []*vearchpb.Item {
 					}
 				}
 			}
-			nodeID := partition.LeaderID
-			err := r.client.PS().GetOrCreateRPCClient(ctx, nodeID).Execute(ctx, UnaryHandler, d, replyPartition)
+			replyPartition, err := r.executeOnLeader(ctx, partition, d)
 			if err != nil {
 				d.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
 				respChain <- d
@@ -454,6 +472,105 @@ func setPartitionErr(d *vearchpb.PartitionData) {
 	}
 }
 
+// docExistence returns whether the item is found, ok is false when the get
+// failed for other reasons than the document not existing
+func docExistence(item *vearchpb.Item) (exist bool, ok bool) {
+	if item == nil || item.Doc == nil {
+		return false, false
+	}
+	if item.Err == nil || item.Err.Code == vearchpb.ErrorEnum_SUCCESS {
+		return true, true
+	}
+	if item.Err.Code == vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST {
+		return false, true
+	}
+	return false, false
+}
+
+// compareReplica returns the docs of leader missing or extra in the items of
+// a follower, nil if they agree. Docs unknown to either side are skipped
+func compareReplica(leaderExist map[string]bool, items []*vearchpb.Item) *entity.ReplicaDivergence {
+	divergence := &entity.ReplicaDivergence{}
+	for _, item := range items {
+		exist, ok := docExistence(item)
+		if !ok {
+			continue
+		}
+		leader, ok := leaderExist[item.Doc.PKey]
+		if !ok || leader == exist {
+			continue
+		}
+		if leader {
+			divergence.Missing = append(divergence.Missing, item.Doc.PKey)
+		} else {
+			divergence.Extra = append(divergence.Extra, item.Doc.PKey)
+		}
+	}
+	if len(divergence.Missing) == 0 && len(divergence.Extra) == 0 {
+		return nil
+	}
+	return divergence
+}
+
+// CompareReplicas gets the docs of the request from the followers of their
+// partitions, and returns the followers disagreeing with items, the result of
+// Execute from the leaders. Followers failing to answer are skipped
+func (r *routerRequest) CompareReplicas(items []*vearchpb.Item) []*entity.ReplicaDivergence {
+	leaderExist := make(map[string]bool, len(items))
+	for _, item := range items {
+		if exist, ok := docExistence(item); ok {
+			leaderExist[item.Doc.PKey] = exist
+		}
+	}
+
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		divergences []*entity.ReplicaDivergence
+	)
+	for partitionID, pData := range r.sendMap {
+		partition, err := r.client.Master().Cache().PartitionByCache(r.ctx, r.space.Name, partitionID)
+		if err != nil {
+			log.Error("compare replicas of partition [%d] err: %v", partitionID, err)
+			continue
+		}
+		for _, nodeID := range partition.Replicas {
+			if nodeID == partition.LeaderID {
+				continue
+			}
+			wg.Add(1)
+			go func(pid entity.PartitionID, nodeID entity.NodeID, d *vearchpb.PartitionData) {
+				defer wg.Done()
+				md := vmap.CopyMap(r.md)
+				md[ReadReplica] = "true"
+				ctx := context.WithValue(r.ctx, share.ReqMetaDataKey, md)
+				req := &vearchpb.PartitionData{PartitionID: pid, MessageID: d.MessageID}
+				for _, item := range d.Items {
+					req.Items = append(req.Items, &vearchpb.Item{Doc: &vearchpb.Document{PKey: item.Doc.PKey}})
+				}
+				reply := new(vearchpb.PartitionData)
+				if err := r.client.PS().GetOrCreateRPCClient(ctx, nodeID).Execute(ctx, UnaryHandler, req, reply); err != nil {
+					log.Warn("get docs of partition [%d] from replica [%d] err: %v", pid, nodeID, err)
+					return
+				}
+				if reply.Err != nil && reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+					log.Warn("get docs of partition [%d] from replica [%d] err: %s", pid, nodeID, reply.Err.Msg)
+					return
+				}
+				divergence := compareReplica(leaderExist, reply.Items)
+				if divergence != nil {
+					divergence.PartitionID, divergence.NodeID = pid, nodeID
+					mu.Lock()
+					divergences = append(divergences, divergence)
+					mu.Unlock()
+				}
+			}(partitionID, nodeID, pData)
+		}
+	}
+	wg.Wait()
+	return divergences
+}
+
 func (r *routerRequest) replicasFaultyNum(replicas []uint64) int {
 	faultyNodeNum := 0
 	for _, nodeID := range replicas {
@@ -535,6 +652,7 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 
 	faultyNodeNum := r.replicasFaultyNum(partition.Replicas)
 	retryTime := 0
+	reresolved := false
 	var retry_err error
 	if len(partition.Replicas) <= faultyNodeNum || nodeID == 0 {
 		msg := fmt.Sprintf("nodeID %v partitionID: %d is faulty, replica_num=%d, faultyNodeNum=%d", nodeID, partitionID, len(partition.Replicas), faultyNodeNum)
@@ -632,7 +750,24 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 		retry_err = rpcClient.Execute(ctx, UnaryHandler, pd, replyPartition)
 		rpcEnd = time.Now()
 		if retry_err == nil {
-			break
+			if !partitionMoved(replyPartition) || reresolved {
+				break
+			}
+			// the ps no longer has the partition, send it once more to
+			// where the master has it now
+			reresolved = true
+			p, err := r.reresolvePartition(ctx, partitionID)
+			if err != nil {
+				break
+			}
+			nodeID = GetNodeIdsByClientType(clientType, p, serverCache, r.client)
+			faultyNodeNum = r.replicasFaultyNum(p.Replicas)
+			if len(p.Replicas) <= faultyNodeNum || nodeID == 0 {
+				break
+			}
+			partition, replyPartition.Err = p, nil
+			retryTime = 0
+			continue
 		}
 
 		log.Error("nodeID %v partitionID: %d rpc err [%v], retryTime: %d, len(partition.Replicas)=%d, faultyNodeNum: %d", nodeID, partitionID, retry_err, retryTime, len(partition.Replicas), faultyNodeNum)
@@ -646,15 +781,15 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 		faultyNodeNum = r.replicasFaultyNum(partition.Replicas)
 		retryTime++
 	}
+	if reresolved {
+		r.countReresolve(replyPartition, retry_err)
+	}
+	if retry_err == nil && partitionMoved(replyPartition) {
+		retry_err = vearchpb.NewErrorInfo(replyPartition.Err.Code, replyPartition.Err.Msg)
+	}
 
 	if retry_err != nil {
-		var err *vearchpb.Error
-		if vErr, ok := retry_err.(*vearchpb.VearchErr); ok {
-			err = &vearchpb.Error{Code: vErr.GetError().Code, Msg: retry_err.Error()}
-		} else {
-			err = &vearchpb.Error{Code: vearchpb.ErrorEnum_INTERNAL_ERROR, Msg: retry_err.Error()}
-		}
-		head := &vearchpb.ResponseHead{Err: err}
+		head := &vearchpb.ResponseHead{Err: &vearchpb.Error{Code: vearchpb.Code(retry_err), Msg: retry_err.Error()}}
 		searchResponse := &vearchpb.SearchResponse{Head: head}
 		pd.SearchResponse = searchResponse
 		responseDoc.PartitionData = pd
@@ -704,6 +839,16 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 					sortValueMap[item.PKey+"_"+index] = sortValues
 				}
 			}
+			if pd.SearchRequest.Head.Params[entity.ProvenanceParam] == "true" {
+				// the applied index is of this partition only, it is not merged
+				// into the head of the search
+				appliedIndex, _ := strconv.ParseUint(searchResponse.Head.Params[entity.AppliedIndexParam], 10, 64)
+				delete(searchResponse.Head.Params, entity.AppliedIndexParam)
+				provenance := &entity.Provenance{PartitionID: partitionID, NodeID: nodeID, Leader: nodeID == partition.LeaderID, AppliedIndex: appliedIndex}
+				if err := entity.AnnotateProvenance(searchResponse.Results, provenance); err != nil {
+					log.Error("annotate provenance of partition %d err: %v", partitionID, err)
+				}
+			}
 
 			if trace {
 				fieldParsingTime := time.Since(deSerializeEndTime).Seconds() * 1000
@@ -782,7 +927,7 @@ func (r *routerRequest) SearchFieldSortExecute(sortOrder sortorder.SortOrder) *v
 
 	mergeStartTime := time.Now()
 	var finalErr *vearchpb.Error
-	for r := range respChain {
+	for _, r := range byPartition(respChain) {
 		if r != nil && r.PartitionData.Err != nil {
 			finalErr = r.PartitionData.Err
 			continue
@@ -894,6 +1039,7 @@ func (r *routerRequest) queryFromPartition(ctx context.Context, partitionID enti
 
 	faultyNodeNum := r.replicasFaultyNum(partition.Replicas)
 	retryTime := 0
+	reresolved := false
 	var retry_err error
 	if len(partition.Replicas) <= faultyNodeNum || nodeID == 0 {
 		msg := fmt.Sprintf("nodeID %v partitionID: %d is faulty, replica_num=%d, faultyNodeNum=%d", nodeID, partitionID, len(partition.Replicas), faultyNodeNum)
@@ -914,7 +1060,22 @@ func (r *routerRequest) queryFromPartition(ctx context.Context, partitionID enti
 
 		retry_err = rpcClient.Execute(ctx, UnaryHandler, pd, replyPartition)
 		if retry_err == nil {
-			break
+			if !partitionMoved(replyPartition) || reresolved {
+				break
+			}
+			reresolved = true
+			p, err := r.reresolvePartition(ctx, partitionID)
+			if err != nil {
+				break
+			}
+			nodeID = GetNodeIdsByClientType(clientType, p, servers, r.client)
+			faultyNodeNum = r.replicasFaultyNum(p.Replicas)
+			if len(p.Replicas) <= faultyNodeNum || nodeID == 0 {
+				break
+			}
+			partition, replyPartition.Err = p, nil
+			retryTime = 0
+			continue
 		}
 
 		log.Error("nodeID %v partitionID: %d rpc err [%v], retryTime: %d, len(partition.Replicas)=%d, faultyNodeNum: %d", nodeID, partitionID, retry_err, retryTime, len(partition.Replicas), faultyNodeNum)
@@ -928,15 +1089,15 @@ func (r *routerRequest) queryFromPartition(ctx context.Context, partitionID enti
 		faultyNodeNum = r.replicasFaultyNum(partition.Replicas)
 		retryTime++
 	}
+	if reresolved {
+		r.countReresolve(replyPartition, retry_err)
+	}
+	if retry_err == nil && partitionMoved(replyPartition) {
+		retry_err = vearchpb.NewErrorInfo(replyPartition.Err.Code, replyPartition.Err.Msg)
+	}
 
 	if retry_err != nil {
-		var err *vearchpb.Error
-		if vErr, ok := retry_err.(*vearchpb.VearchErr); ok {
-			err = &vearchpb.Error{Code: vErr.GetError().Code, Msg: retry_err.Error()}
-		} else {
-			err = &vearchpb.Error{Code: vearchpb.ErrorEnum_INTERNAL_ERROR, Msg: retry_err.Error()}
-		}
-		head := &vearchpb.ResponseHead{Err: err}
+		head := &vearchpb.ResponseHead{Err: &vearchpb.Error{Code: vearchpb.Code(retry_err), Msg: retry_err.Error()}}
 		searchResponse := &vearchpb.SearchResponse{Head: head}
 		pd.SearchResponse = searchResponse
 		responseDoc.PartitionData = pd
@@ -994,7 +1155,7 @@ func (r *routerRequest) QueryFieldSortExecute(sortOrder sortorder.SortOrder) *ve
 	var head vearchpb.ResponseHead
 
 	var final_err *vearchpb.Error
-	for r := range respChain {
+	for _, r := range byPartition(respChain) {
 		if r != nil && r.PartitionData.Err != nil {
 			final_err = r.PartitionData.Err
 			continue
@@ -1079,6 +1240,31 @@ func (r *routerRequest) QueryFieldSortExecute(sortOrder sortorder.SortOrder) *ve
 	return searchResponse
 }
 
+// byPartition drains the responses of partitions ordered by partition id, so
+// the merge does not depend on which partition replies first
+func byPartition(respChain chan *response.SearchDocResult) []*response.SearchDocResult {
+	resps := make([]*response.SearchDocResult, 0, len(respChain))
+	for r := range respChain {
+		resps = append(resps, r)
+	}
+	sort.SliceStable(resps, func(i, j int) bool {
+		if resps[i] == nil || resps[j] == nil {
+			return resps[j] == nil && resps[i] != nil
+		}
+		return resps[i].PartitionData.PartitionID < resps[j].PartitionData.PartitionID
+	})
+	return resps
+}
+
+// compareItems compares two items by the sort values and then by document
+// id, so equal sort values keep the same order across identical requests
+func compareItems(so sortorder.SortOrder, sortValueMap map[string][]sortorder.SortValue, a, b *vearchpb.ResultItem, index string) int {
+	if c := so.Compare(sortValueMap[a.PKey+"_"+index], sortValueMap[b.PKey+"_"+index]); c != 0 {
+		return c
+	}
+	return strings.Compare(a.PKey, b.PKey)
+}
+
 func quickSort(items []*vearchpb.ResultItem, sortValueMap map[string][]sortorder.SortValue, low, high int, so sortorder.SortOrder, index string) {
 	if low < high {
 		var pivot = partition(items, sortValueMap, low, high, so, index)
@@ -1089,15 +1275,14 @@ func quickSort(items []*vearchpb.ResultItem, sortValueMap map[string][]sortorder
 
 func partition(items []*vearchpb.ResultItem, sortValueMap map[string][]sortorder.SortValue, low, high int, so sortorder.SortOrder, index string) int {
 	var pivot = items[low]
-	var pivotSort = sortValueMap[pivot.PKey+"_"+index]
 	var i = low
 	var j = high
 	for i < j {
-		for so.Compare(sortValueMap[items[j].PKey+"_"+index], pivotSort) >= 0 && j > low {
+		for compareItems(so, sortValueMap, items[j], pivot, index) >= 0 && j > low {
 			j--
 		}
 
-		for so.Compare(sortValueMap[items[i].PKey+"_"+index], pivotSort) <= 0 && i < high {
+		for compareItems(so, sortValueMap, items[i], pivot, index) <= 0 && i < high {
 			i++
 		}
 		if i < j {
@@ -1195,7 +1380,7 @@ func (r *routerRequest) SearchByPartitions(searchReq *vearchpb.SearchRequest) *r
 
 var replicaRoundRobin = algorithm.NewRoundRobin[entity.PartitionID, entity.NodeID]()
 
-func GetNodeIdsByClientType(clientType string, partition *entity.Partition, servers *cache.Cache, client *Client) entity.NodeID {
+func GetNodeIdsByClientType(clientType string, partition *entity.Partition, servers *Cache[*entity.Server], client *Client) entity.NodeID {
 	nodeId := uint64(0)
 	switch clientType {
 	case request.Leader:
@@ -1492,7 +1677,7 @@ func mergeSortedArrays(arr1, arr2 []*vearchpb.ResultItem, topN int, desc bool) [
 	i, j := 0, 0
 	if desc {
 		for i < m && j < n {
-			if arr1[i].Score > arr2[j].Score {
+			if arr1[i].Score > arr2[j].Score || (arr1[i].Score == arr2[j].Score && arr1[i].PKey < arr2[j].PKey) {
 				merged = append(merged, arr1[i])
 				i++
 			} else {
@@ -1502,7 +1687,7 @@ func mergeSortedArrays(arr1, arr2 []*vearchpb.ResultItem, topN int, desc bool) [
 		}
 	} else {
 		for i < m && j < n {
-			if arr1[i].Score < arr2[j].Score {
+			if arr1[i].Score < arr2[j].Score || (arr1[i].Score == arr2[j].Score && arr1[i].PKey < arr2[j].PKey) {
 				merged = append(merged, arr1[i])
 				i++
 			} else {
@@ -0,0 +1,215 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine/sortorder"
+)
+
+func TestCompareReplica(t *testing.T) {
+	found := func(key string) *vearchpb.Item {
+		return &vearchpb.Item{Doc: &vearchpb.Document{PKey: key}}
+	}
+	notFound := func(key string) *vearchpb.Item {
+		return &vearchpb.Item{Doc: &vearchpb.Document{PKey: key}, Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST}}
+	}
+	failed := func(key string) *vearchpb.Item {
+		return &vearchpb.Item{Doc: &vearchpb.Document{PKey: key}, Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_TIMEOUT}}
+	}
+	tests := []struct {
+		name        string
+		leaderExist map[string]bool
+		items       []*vearchpb.Item
+		wantMissing []string
+		wantExtra   []string
+	}{
+		{
+			name:        "Replicas agree",
+			leaderExist: map[string]bool{"1": true, "2": false},
+			items:       []*vearchpb.Item{found("1"), notFound("2")},
+		},
+		{
+			name:        "Follower missing and extra docs",
+			leaderExist: map[string]bool{"1": true, "2": false},
+			items:       []*vearchpb.Item{notFound("1"), found("2")},
+			wantMissing: []string{"1"},
+			wantExtra:   []string{"2"},
+		},
+		{
+			name:        "Follower error is skipped",
+			leaderExist: map[string]bool{"1": true},
+			items:       []*vearchpb.Item{failed("1")},
+		},
+		{
+			name:        "Doc unknown to leader is skipped",
+			leaderExist: map[string]bool{},
+			items:       []*vearchpb.Item{found("1"), nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareReplica(tt.leaderExist, tt.items)
+			if tt.wantMissing == nil && tt.wantExtra == nil {
+				if got != nil {
+					t.Fatalf("compareReplica() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("compareReplica() = nil, want divergence")
+			}
+			if !reflect.DeepEqual(got.Missing, tt.wantMissing) || !reflect.DeepEqual(got.Extra, tt.wantExtra) {
+				t.Errorf("compareReplica() = %v, %v, want %v, %v", got.Missing, got.Extra, tt.wantMissing, tt.wantExtra)
+			}
+		})
+	}
+}
+
+func itemKeys(items []*vearchpb.ResultItem) []string {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item.PKey)
+	}
+	return keys
+}
+
+func TestMergeSortedArrays(t *testing.T) {
+	item := func(key string, score float64) *vearchpb.ResultItem {
+		return &vearchpb.ResultItem{PKey: key, Score: score}
+	}
+	tests := []struct {
+		name       string
+		arr1, arr2 []*vearchpb.ResultItem
+		topN       int
+		desc       bool
+		want       []string
+	}{
+		{
+			name: "Desc scores",
+			arr1: []*vearchpb.ResultItem{item("a", 0.9), item("b", 0.5)},
+			arr2: []*vearchpb.ResultItem{item("c", 0.7)},
+			desc: true,
+			want: []string{"a", "c", "b"},
+		},
+		{
+			name: "Desc ties across partitions by id",
+			arr1: []*vearchpb.ResultItem{item("d", 0.5)},
+			arr2: []*vearchpb.ResultItem{item("a", 0.5), item("b", 0.1)},
+			desc: true,
+			want: []string{"a", "d", "b"},
+		},
+		{
+			name: "Asc ties across partitions by id",
+			arr1: []*vearchpb.ResultItem{item("c", 1), item("d", 2)},
+			arr2: []*vearchpb.ResultItem{item("b", 1)},
+			want: []string{"b", "c", "d"},
+		},
+		{
+			name: "Tie cut at topN keeps lower id",
+			arr1: []*vearchpb.ResultItem{item("z", 0.5)},
+			arr2: []*vearchpb.ResultItem{item("y", 0.5)},
+			topN: 1,
+			desc: true,
+			want: []string{"y"},
+		},
+		{
+			name: "Empty side cut at topN",
+			arr2: []*vearchpb.ResultItem{item("a", 0.9), item("b", 0.5)},
+			topN: 1,
+			desc: true,
+			want: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := itemKeys(mergeSortedArrays(tt.arr1, tt.arr2, tt.topN, tt.desc))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeSortedArrays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuickSortTies(t *testing.T) {
+	so := sortorder.SortOrder{sortorder.NewSortField("_score")}
+	value := func(v float64) []sortorder.SortValue {
+		return []sortorder.SortValue{&sortorder.FloatSortValue{Val: v}}
+	}
+	tests := []struct {
+		name   string
+		scores map[string]float64
+		order  []string
+		want   []string
+	}{
+		{
+			name:   "Distinct values",
+			scores: map[string]float64{"a": 3, "b": 1, "c": 2},
+			order:  []string{"a", "b", "c"},
+			want:   []string{"b", "c", "a"},
+		},
+		{
+			name:   "Equal values by id whatever the arrival order",
+			scores: map[string]float64{"a": 1, "b": 1, "c": 1, "d": 0},
+			order:  []string{"c", "a", "d", "b"},
+			want:   []string{"d", "a", "b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortValueMap := make(map[string][]sortorder.SortValue)
+			items := make([]*vearchpb.ResultItem, 0, len(tt.order))
+			for _, key := range tt.order {
+				sortValueMap[key+"_0"] = value(tt.scores[key])
+				items = append(items, &vearchpb.ResultItem{PKey: key})
+			}
+			quickSort(items, sortValueMap, 0, len(items)-1, so, "0")
+			if got := itemKeys(items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("quickSort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestByPartition(t *testing.T) {
+	tests := []struct {
+		name string
+		pids []uint32
+		want []uint32
+	}{
+		{name: "Out of order replies", pids: []uint32{3, 1, 2}, want: []uint32{1, 2, 3}},
+		{name: "Single reply", pids: []uint32{7}, want: []uint32{7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			respChain := make(chan *response.SearchDocResult, len(tt.pids))
+			for _, pid := range tt.pids {
+				respChain <- &response.SearchDocResult{PartitionData: &vearchpb.PartitionData{PartitionID: pid}}
+			}
+			close(respChain)
+			got := make([]uint32, 0, len(tt.pids))
+			for _, r := range byPartition(respChain) {
+				got = append(got, r.PartitionData.PartitionID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("byPartition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// result of a request redirected to the leader of its partition
+const (
+	RedirectOK     = "ok"
+	RedirectFailed = "failed"
+)
+
+const maxLeaderRedirects = 3
+
+var noLeaderBackoff = 100 * time.Millisecond
+
+// LeaderRedirects counts the requests a replica answered it is not the
+// leader of the partition, registered by the monitor of the router
+var LeaderRedirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "vearch_router_leader_redirects_total",
+	Help: "requests sent again to the leader after a not leader reply by result",
+}, []string{"space", "result"})
+
+// redirectLeader returns whether reply is a not leader reply and the leader
+// it names, the leader is 0 when the partition has no leader
+func redirectLeader(reply *vearchpb.PartitionData) (entity.NodeID, bool) {
+	if reply == nil || reply.Err == nil {
+		return 0, false
+	}
+	switch reply.Err.Code {
+	case vearchpb.ErrorEnum_PARTITION_NO_LEADER:
+		return 0, true
+	case vearchpb.ErrorEnum_PARTITION_NOT_LEADER:
+		replica := &entity.Replica{}
+		if err := vjson.Unmarshal([]byte(reply.Err.Msg), replica); err != nil {
+			return 0, true
+		}
+		return replica.NodeID, true
+	}
+	return 0, false
+}
+
+// executeOnLeader sends d to the leader of partition, when the replica is
+// not the leader the partition cache is refreshed and d is sent again to the
+// leader it names. When the ps no longer has the partition, it is loaded
+// again from the master and d is sent once to its current leader
+func (r *routerRequest) executeOnLeader(ctx context.Context, partition *entity.Partition, d *vearchpb.PartitionData) (*vearchpb.PartitionData, error) {
+	nodeID := partition.LeaderID
+	redirected, reresolved := false, false
+	for i := 0; ; i++ {
+		reply := new(vearchpb.PartitionData)
+		if err := r.client.PS().GetOrCreateRPCClient(ctx, nodeID).Execute(ctx, UnaryHandler, d, reply); err != nil {
+			if reresolved {
+				r.countReresolve(nil, err)
+			}
+			return nil, err
+		}
+		if partitionMoved(reply) && !reresolved && ctx.Err() == nil {
+			reresolved = true
+			p, err := r.reresolvePartition(ctx, d.PartitionID)
+			if err != nil || p.LeaderID == 0 {
+				log.Warn("partition [%d] moved from node [%d] and has no leader, err: %v", d.PartitionID, nodeID, err)
+				r.countReresolve(reply, err)
+				return reply, nil
+			}
+			log.Warn("partition [%d] moved from node [%d] to [%d]", d.PartitionID, nodeID, p.LeaderID)
+			nodeID = p.LeaderID
+			continue
+		}
+		leader, redirect := redirectLeader(reply)
+		if !redirect {
+			if redirected {
+				LeaderRedirects.WithLabelValues(r.space.Name, RedirectOK).Inc()
+			}
+			if reresolved {
+				r.countReresolve(reply, nil)
+			}
+			return reply, nil
+		}
+		if i >= maxLeaderRedirects || ctx.Err() != nil {
+			LeaderRedirects.WithLabelValues(r.space.Name, RedirectFailed).Inc()
+			if reresolved {
+				r.countReresolve(reply, nil)
+			}
+			return reply, nil
+		}
+		redirected = true
+		log.Warn("partition [%d] on node [%d] is not leader, redirect to [%d]", d.PartitionID, nodeID, leader)
+		cache := r.client.Master().Cache()
+		if leader != 0 && leader != nodeID {
+			_ = cache.reloadPartitionCache(ctx, false, r.space.Name, d.PartitionID)
+			nodeID = leader
+			continue
+		}
+		// no leader yet, wait for the election and take the leader the
+		// master knows
+		select {
+		case <-ctx.Done():
+		case <-time.After(noLeaderBackoff << i):
+		}
+		if err := cache.reloadPartitionCache(ctx, true, r.space.Name, d.PartitionID); err != nil {
+			log.Warn("reload partition [%d] err: %v", d.PartitionID, err)
+			continue
+		}
+		if p, err := cache.PartitionByCache(ctx, r.space.Name, d.PartitionID); err == nil && p.LeaderID != 0 {
+			nodeID = p.LeaderID
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestRedirectLeader(t *testing.T) {
+	tests := []struct {
+		name         string
+		reply        *vearchpb.PartitionData
+		wantLeader   entity.NodeID
+		wantRedirect bool
+	}{
+		{
+			name:  "Nil reply",
+			reply: nil,
+		},
+		{
+			name:  "Success",
+			reply: &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}},
+		},
+		{
+			name:  "Other error",
+			reply: &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_TIMEOUT}},
+		},
+		{
+			name:         "Not leader names the leader",
+			reply:        &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NOT_LEADER, Msg: `{"nodeID":3,"rpc_addr":"127.0.0.1:8081"}`}},
+			wantLeader:   3,
+			wantRedirect: true,
+		},
+		{
+			name:         "Not leader without leader",
+			reply:        &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NOT_LEADER, Msg: "partition_not_leader"}},
+			wantRedirect: true,
+		},
+		{
+			name:         "No leader",
+			reply:        &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NO_LEADER}},
+			wantRedirect: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leader, redirect := redirectLeader(tt.reply)
+			if leader != tt.wantLeader || redirect != tt.wantRedirect {
+				t.Errorf("redirectLeader() = %d, %v, want %d, %v", leader, redirect, tt.wantLeader, tt.wantRedirect)
+			}
+		})
+	}
+}
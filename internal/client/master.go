@@ -18,9 +18,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/cast"
@@ -31,6 +33,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/netutil"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -46,7 +49,7 @@ type masterClient struct {
 	client *Client
 	store.Store
 	cfg      *config.Config
-	cliCache *clientCache
+	cliCache atomic.Pointer[clientCache]
 }
 
 // Client return the masterClient.client not masterClient
@@ -56,7 +59,7 @@ func (m *masterClient) Client() *Client {
 
 // Cache return the clientCache of client
 func (m *masterClient) Cache() *clientCache {
-	return m.cliCache
+	return m.cliCache.Load()
 }
 
 // Config return the config of client
@@ -71,19 +74,22 @@ func (m *masterClient) FlushCacheJob(ctx context.Context) error {
 		return err
 	}
 
-	old := m.cliCache
-	m.cliCache = cliCache
-	if old != nil {
+	m.swapCache(cliCache)
+	return nil
+}
+
+// swapCache replaces the cache by cc and stops the jobs of the old one, the
+// requests holding the old cache finish with it
+func (m *masterClient) swapCache(cc *clientCache) {
+	if old := m.cliCache.Swap(cc); old != nil {
 		old.stopCacheJob()
 	}
-
-	return nil
 }
 
 // Stop stop the cache job
 func (m *masterClient) Stop() {
-	if m.cliCache != nil {
-		m.cliCache.stopCacheJob()
+	if cc := m.cliCache.Load(); cc != nil {
+		cc.stopCacheJob()
 	}
 }
 
@@ -139,7 +145,7 @@ func (m *masterClient) QueryServer(ctx context.Context, id entity.NodeID) (*enti
 
 	p := new(entity.Server)
 	if err = vjson.Unmarshal(bytes, p); err != nil {
-		log.Error("server find on master, but json.Unmarshal(bytes, p) error, nodeId:[%d], bytes:[%s], err:[%v]", id, string(bytes), err)
+		log.Error("server find on master, but json.Unmarshal(bytes, p) error, nodeId:[%d], bytes:[%s], err:[%v]", id, redact.Payload(bytes), err)
 		return nil, err
 	}
 
@@ -179,7 +185,7 @@ func (m *masterClient) QueryRole(ctx context.Context, rolename string) (*entity.
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_ROLE_NOT_EXIST, err)
 	}
 	role := new(entity.Role)
-	if err = vjson.Unmarshal(bytes, role); err != nil {
+	if _, err = entity.DecodeMeta(bytes, role); err != nil {
 		return nil, err
 	}
 	return role, nil
@@ -224,6 +230,25 @@ func (m *masterClient) QueryRouter(ctx context.Context, key string) ([]string, e
 	return routerIPs, nil
 }
 
+// QueryRouterInfos returns the healthy routers of cluster registered for
+// discovery
+func (m *masterClient) QueryRouterInfos(ctx context.Context, cluster string) ([]*entity.RouterInfo, error) {
+	_, values, err := m.PrefixScan(ctx, fmt.Sprintf("%s%s/", entity.PrefixRouters, cluster))
+	if err != nil {
+		return nil, err
+	}
+	routers := make([]*entity.RouterInfo, 0, len(values))
+	for _, bs := range values {
+		router := &entity.RouterInfo{}
+		if err := vjson.Unmarshal(bs, router); err != nil {
+			log.Error("unmarshal router info err: %s", err.Error())
+			continue
+		}
+		routers = append(routers, router)
+	}
+	return routers, nil
+}
+
 // QuerySpacesByKey scan space by space prefix
 func (m *masterClient) QuerySpacesByKey(ctx context.Context, prefix string) ([]*entity.Space, error) {
 	_, bytesSpaces, err := m.PrefixScan(ctx, prefix)
@@ -334,7 +359,7 @@ func (m *masterClient) QueryDBs(ctx context.Context) ([]*entity.DB, error) {
 	for _, bs := range bytesDBs {
 		db := &entity.DB{}
 		if err := vjson.Unmarshal(bs, db); err != nil {
-			log.Error("decode db err: %s,and the bs is:%s", err.Error(), string(bs))
+			log.Error("decode db err: %s,and the bs is:%s", err.Error(), redact.Payload(bs))
 			continue
 		}
 		dbs = append(dbs, db)
@@ -342,6 +367,128 @@ func (m *masterClient) QueryDBs(ctx context.Context) ([]*entity.DB, error) {
 	return dbs, err
 }
 
+// QueryWebhooks scan webhooks
+func (m *masterClient) QueryWebhooks(ctx context.Context) ([]*entity.Webhook, error) {
+	_, bytesHooks, err := m.PrefixScan(ctx, entity.PrefixWebhook)
+	if err != nil {
+		return nil, err
+	}
+	hooks := make([]*entity.Webhook, 0, len(bytesHooks))
+	for _, bs := range bytesHooks {
+		hook := &entity.Webhook{}
+		if err := vjson.Unmarshal(bs, hook); err != nil {
+			log.Error("decode webhook err: %s,and the bs is:%s", err.Error(), redact.Payload(bs))
+			continue
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, err
+}
+
+// QueryResourceGroups scan resource groups
+func (m *masterClient) QueryResourceGroups(ctx context.Context) ([]*entity.ResourceGroup, error) {
+	_, bytesGroups, err := m.PrefixScan(ctx, entity.PrefixResourceGroup)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*entity.ResourceGroup, 0, len(bytesGroups))
+	for _, bs := range bytesGroups {
+		group := &entity.ResourceGroup{}
+		if err := vjson.Unmarshal(bs, group); err != nil {
+			log.Error("decode resource group err: %s,and the bs is:%s", err.Error(), redact.Payload(bs))
+			continue
+		}
+		groups = append(groups, group)
+	}
+	return groups, err
+}
+
+// QuerySpaceTemplates scan space templates
+func (m *masterClient) QuerySpaceTemplates(ctx context.Context) ([]*entity.SpaceTemplate, error) {
+	_, bytesTemplates, err := m.PrefixScan(ctx, entity.PrefixSpaceTemplate)
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]*entity.SpaceTemplate, 0, len(bytesTemplates))
+	for _, bs := range bytesTemplates {
+		template := &entity.SpaceTemplate{}
+		if err := vjson.Unmarshal(bs, template); err != nil {
+			log.Error("decode space template err: %s,and the bs is:%s", err.Error(), redact.Payload(bs))
+			continue
+		}
+		templates = append(templates, template)
+	}
+	return templates, err
+}
+
+// QueryFeatureFlags scan feature flags
+func (m *masterClient) QueryFeatureFlags(ctx context.Context) ([]*entity.FeatureFlag, error) {
+	_, bytesFlags, err := m.PrefixScan(ctx, entity.PrefixFeatureFlag)
+	if err != nil {
+		return nil, err
+	}
+	flags := make([]*entity.FeatureFlag, 0, len(bytesFlags))
+	for _, bs := range bytesFlags {
+		flag := &entity.FeatureFlag{}
+		if err := vjson.Unmarshal(bs, flag); err != nil {
+			log.Error("decode feature flag err: %s,and the bs is:%s", err.Error(), redact.Payload(bs))
+			continue
+		}
+		flags = append(flags, flag)
+	}
+	return flags, err
+}
+
+// QueryGlobalAliases scan global aliases
+func (m *masterClient) QueryGlobalAliases(ctx context.Context) ([]*entity.GlobalAlias, error) {
+	_, bytesAliases, err := m.PrefixScan(ctx, entity.PrefixGlobalAlias)
+	if err != nil {
+		return nil, err
+	}
+	aliases := make([]*entity.GlobalAlias, 0, len(bytesAliases))
+	for _, bs := range bytesAliases {
+		alias := &entity.GlobalAlias{}
+		if err := vjson.Unmarshal(bs, alias); err != nil {
+			log.Error("decode global alias err: %s,and the bs is:%s", err.Error(), redact.Payload(bs))
+			continue
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, err
+}
+
+// QueryQuarantines scan the quarantined partition replicas
+func (m *masterClient) QueryQuarantines(ctx context.Context) ([]*entity.QuarantinedPartition, error) {
+	_, bytesQuarantines, err := m.PrefixScan(ctx, entity.PrefixQuarantine)
+	if err != nil {
+		return nil, err
+	}
+	quarantines := make([]*entity.QuarantinedPartition, 0, len(bytesQuarantines))
+	for _, bs := range bytesQuarantines {
+		q := &entity.QuarantinedPartition{}
+		if err := vjson.Unmarshal(bs, q); err != nil {
+			log.Error("decode quarantined partition err: %s", err.Error())
+			continue
+		}
+		quarantines = append(quarantines, q)
+	}
+	return quarantines, err
+}
+
+// PutQuarantine records a quarantined partition replica
+func (m *masterClient) PutQuarantine(ctx context.Context, q *entity.QuarantinedPartition) error {
+	value, err := vjson.Marshal(q)
+	if err != nil {
+		return err
+	}
+	return m.Put(ctx, entity.QuarantineKey(q.NodeID, q.PartitionID), value)
+}
+
+// DeleteQuarantine removes the record of a quarantined partition replica
+func (m *masterClient) DeleteQuarantine(ctx context.Context, nodeID entity.NodeID, pid entity.PartitionID) error {
+	return m.Delete(ctx, entity.QuarantineKey(nodeID, pid))
+}
+
 // QueryPartitions get all partitions from the etcd
 func (m *masterClient) QueryPartitions(ctx context.Context) ([]*entity.Partition, error) {
 	_, bytesPartitions, err := m.PrefixScan(ctx, entity.PrefixPartition)
@@ -404,7 +551,7 @@ func (m *masterClient) QueryAliasByName(ctx context.Context, alias_name string)
 
 	err = vjson.Unmarshal(bs, alias)
 	if err != nil {
-		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get alias:%s value:%s, err:%s", alias.Name, string(bs), err.Error()))
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get alias:%s value:%s, err:%s", alias.Name, redact.Payload(bs), err.Error()))
 	}
 	return alias, nil
 }
@@ -418,11 +565,13 @@ func (m *masterClient) KeepAlive(ctx context.Context, server *entity.Server) (<-
 	if err != nil {
 		return nil, err
 	}
-	timeout := m.cfg.PS.PsHeartbeatTimeout
-	if timeout <= 0 {
-		timeout = DefaultPsTimeOut
+	ps := m.cfg.PS
+	if ps.KeepaliveInterval() <= 0 {
+		return m.Store.KeepAlive(ctx, entity.ServerKey(server.ID), bytes, ps.HeartbeatTTL())
 	}
-	return m.Store.KeepAlive(ctx, entity.ServerKey(server.ID), bytes, time.Second*time.Duration(timeout))
+	return m.Store.KeepAliveEvery(ctx, entity.ServerKey(server.ID), bytes, ps.HeartbeatTTL(), func() time.Duration {
+		return ps.Jitter(ps.KeepaliveInterval())
+	})
 }
 
 // PutServerWithLeaseID PutServerWithLeaseID
@@ -596,6 +745,31 @@ func (m *masterClient) RegisterPartition(ctx context.Context, partition *entity.
 	return nil
 }
 
+// FencingTokens renews the fencing tokens of the partitions of req led by
+// req.NodeID, or releases them, the partitions not led by the node are left out
+func (m *masterClient) FencingTokens(ctx context.Context, req *entity.FencingRequest) ([]*entity.FencingToken, error) {
+	reqBody, err := vjson.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	response, err := m.HTTPRequest(ctx, http.MethodPost, "/partitions/fencing_token", string(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	js := &struct {
+		Code int                    `json:"code"`
+		Msg  string                 `json:"msg"`
+		Data []*entity.FencingToken `json:"data"`
+	}{}
+	if err := vjson.Unmarshal(response, js); err != nil {
+		return nil, err
+	}
+	if js.Code != int(vearchpb.ErrorEnum_SUCCESS) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("fencing tokens of partitions %v error, code: %d, msg: %s", req.PartitionIDs, js.Code, js.Msg))
+	}
+	return js.Data, nil
+}
+
 // send HTTP request
 func (m *masterClient) HTTPRequest(ctx context.Context, method string, url string, reqBody string) (response []byte, e error) {
 	// process panic
@@ -621,9 +795,9 @@ func (m *masterClient) HTTPRequest(ctx context.Context, method string, url strin
 			panic(err)
 		}
 		query.SetAddress(ms[keyNumber].ApiUrl())
-		log.Debug("remote server url: %s, req body: %s", query.GetUrl(), string(reqBody))
+		log.Debug("remote server url: %s, req body: %s", query.GetUrl(), redact.Payload([]byte(reqBody)))
 		response, err = query.Do()
-		log.Debug("remote server response: %v", string(response))
+		log.Debug("remote server response: %s", redact.Payload(response))
 		if err == nil {
 			break
 		}
@@ -662,7 +836,7 @@ func (m *masterClient) ProxyHTTPRequest(method string, url string, reqBody strin
 		query.SetAddress(ms[keyNumber].ApiUrl())
 		statusCode := 0
 		response, statusCode, err = query.ProxyDo()
-		log.Debug("remote server url:%s, req body:%s, response: %v, statusCode %d", query.GetUrl(), string(reqBody), string(response), statusCode)
+		log.Debug("remote server url:%s, req body:%s, response: %v, statusCode %d", query.GetUrl(), redact.Payload([]byte(reqBody)), redact.Payload(response), statusCode)
 
 		if statusCode != -1 {
 			e = err
@@ -674,6 +848,43 @@ func (m *masterClient) ProxyHTTPRequest(method string, url string, reqBody strin
 	return response, e
 }
 
+// ProxyHTTPRequestHeader is ProxyHTTPRequest sending header and returning
+// the status and headers of the master reply
+func (m *masterClient) ProxyHTTPRequestHeader(method string, url string, reqBody string, header map[string]string) (response []byte, respHeader http.Header, statusCode int, e error) {
+	query := netutil.NewQuery()
+	for key, value := range header {
+		query.SetHeader(key, value)
+	}
+	query.SetMethod(method)
+	query.SetUrlPath(url)
+	query.SetReqBody(reqBody)
+	query.SetContentTypeJson()
+	query.SetTimeout(60)
+
+	var masterServer = &MasterServer{}
+	ms := m.Config().GetMasters()
+	masterServer.init(len(ms))
+
+	for {
+		keyNumber, err := masterServer.getKey()
+		if err != nil {
+			return nil, nil, -1, err
+		}
+		query.SetAddress(ms[keyNumber].ApiUrl())
+		resp, err := query.DoResponse()
+		if err == nil {
+			response, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, nil, -1, err
+			}
+			log.Debug("remote server url:%s, req body:%s, response: %v, statusCode %d", query.GetUrl(), redact.Payload([]byte(reqBody)), redact.Payload(response), resp.StatusCode)
+			return response, resp.Header, resp.StatusCode, nil
+		}
+		masterServer.next()
+	}
+}
+
 // remove metadata of the node and delete from raftServer
 func (m *masterClient) RemoveNodeMeta(ctx context.Context, nodeID entity.NodeID) error {
 	if nodeID == 0 {
@@ -26,17 +26,19 @@ import (
 	"time"
 
 	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
-	"github.com/patrickmn/go-cache"
 	"github.com/spf13/cast"
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
 	httpResonse "github.com/vearch/vearch/v3/internal/entity/response"
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
+	"github.com/vearch/vearch/v3/internal/pkg/fault"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/pkg/vearchlog"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 const retryNum = 3
@@ -54,10 +56,19 @@ var (
 
 type clientCache struct {
 	sync.Map
-	mc                                                                                                    *masterClient
-	cancel                                                                                                context.CancelFunc
-	lock                                                                                                  sync.Mutex
-	userCache, spaceCache, spaceIDCache, partitionCache, serverCache, aliasCache, roleCache, mastersCache *cache.Cache
+	mc             *masterClient
+	cancel         context.CancelFunc
+	lock           sync.Mutex
+	snapshotTime   time.Time // loaded from snapshot if not zero
+	userCache      *Cache[*entity.User]
+	spaceCache     *Cache[*entity.Space]
+	spaceIDCache   *Cache[*entity.Space]
+	partitionCache *Cache[*entity.Partition]
+	serverCache    *Cache[*entity.Server]
+	aliasCache     *Cache[*entity.Alias]
+	roleCache      *Cache[*entity.Role]
+	mastersCache   *Cache[config.MasterCfg]
+	dbNames        *Cache[string] // by db id, the name of a db never changes
 }
 
 func newClientCache(serverCtx context.Context, masterClient *masterClient) (*clientCache, error) {
@@ -66,17 +77,19 @@ func newClientCache(serverCtx context.Context, masterClient *masterClient) (*cli
 	cc := &clientCache{
 		mc:             masterClient,
 		cancel:         cancel,
-		userCache:      cache.New(cache.NoExpiration, cache.NoExpiration),
-		spaceCache:     cache.New(cache.NoExpiration, cache.NoExpiration),
-		spaceIDCache:   cache.New(cache.NoExpiration, cache.NoExpiration),
-		partitionCache: cache.New(cache.NoExpiration, cache.NoExpiration),
-		serverCache:    cache.New(cache.NoExpiration, cache.NoExpiration),
-		aliasCache:     cache.New(cache.NoExpiration, cache.NoExpiration),
-		roleCache:      cache.New(cache.NoExpiration, cache.NoExpiration),
-		mastersCache:   cache.New(cache.NoExpiration, cache.NoExpiration),
-	}
-
-	if err := cc.startCacheJob(ctx); err != nil {
+		userCache:      newTypedCache[*entity.User]("user"),
+		spaceCache:     newTypedCache[*entity.Space]("space"),
+		spaceIDCache:   newTypedCache[*entity.Space]("space_id"),
+		partitionCache: newTypedCache[*entity.Partition]("partition"),
+		serverCache:    newTypedCache[*entity.Server]("server"),
+		aliasCache:     newTypedCache[*entity.Alias]("alias"),
+		roleCache:      newTypedCache[*entity.Role]("role"),
+		mastersCache:   newTypedCache[config.MasterCfg]("master"),
+		dbNames:        newTypedCache[string]("db_name"),
+	}
+
+	if err := cc.startCacheJob(ctx, true); err != nil {
+		cancel()
 		return nil, err
 	}
 
@@ -90,7 +103,7 @@ func NewWatchServerCache(serverCtx context.Context, cli *Client) error {
 	cc := &clientCache{
 		mc:          cli.Master(),
 		cancel:      cancel,
-		serverCache: cache.New(cache.NoExpiration, cache.NoExpiration),
+		serverCache: newTypedCache[*entity.Server]("server"),
 	}
 
 	err := cc.startWSJob(ctx)
@@ -110,12 +123,40 @@ func cacheServerKey(nodeID entity.NodeID) string {
 	return cast.ToString(nodeID)
 }
 
+// dbName returns the name of db id, memoized so the events of many spaces of
+// a db do not each query etcd
+func (cliCache *clientCache) dbName(ctx context.Context, id entity.DBID) (string, error) {
+	key := cast.ToString(id)
+	if name, ok := cliCache.dbNames.Get(key); ok {
+		return name, nil
+	}
+	name, err := cliCache.mc.QueryDBId2Name(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	cliCache.dbNames.Set(key, name)
+	return name, nil
+}
+
+// spaceName returns the name of space id of db id, from the cached spaces if
+// it is there
+func (cliCache *clientCache) spaceName(ctx context.Context, dbID entity.DBID, spaceID entity.SpaceID) (string, error) {
+	if space, ok := cliCache.spaceIDCache.Get(cast.ToString(spaceID)); ok && space.DBId == dbID {
+		return space.Name, nil
+	}
+	space, err := cliCache.mc.QuerySpaceByID(ctx, dbID, spaceID)
+	if err != nil {
+		return "", err
+	}
+	return space.Name, nil
+}
+
 // find a user by cache
 func (cliCache *clientCache) UserByCache(ctx context.Context, userName string) (*entity.User, error) {
 
 	get, found := cliCache.userCache.Get(userName)
 	if found {
-		return get.(*entity.User), nil
+		return get, nil
 	}
 
 	_ = cliCache.reloadUserCache(ctx, true, userName)
@@ -124,7 +165,7 @@ func (cliCache *clientCache) UserByCache(ctx context.Context, userName string) (
 		time.Sleep(retrySleepTime)
 		log.Debug("to find user by key:[%s] ", userName)
 		if get, found = cliCache.userCache.Get(userName); found {
-			return get.(*entity.User), nil
+			return get, nil
 		}
 	}
 
@@ -134,11 +175,12 @@ func (cliCache *clientCache) UserByCache(ctx context.Context, userName string) (
 func (cliCache *clientCache) reloadUserCache(ctx context.Context, sync bool, userName string) error {
 	fun := func() error {
 		log.Info("to reload user:[%s]", userName)
+		rev := cliCache.readRevision(ctx)
 		user, err := cliCache.mc.QueryUser(ctx, userName)
 		if err != nil {
 			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("can not found user by name:[%s] err:[%s]", userName, err.Error()))
 		}
-		cliCache.userCache.Set(userName, user, cache.NoExpiration)
+		cliCache.userCache.casSet(userName, user, rev)
 		return nil
 	}
 
@@ -159,7 +201,7 @@ func (cliCache *clientCache) RoleByCache(ctx context.Context, roleName string) (
 
 	get, found := cliCache.roleCache.Get(roleName)
 	if found {
-		return get.(*entity.Role), nil
+		return get, nil
 	}
 
 	_ = cliCache.reloadRoleCache(ctx, true, roleName)
@@ -168,7 +210,7 @@ func (cliCache *clientCache) RoleByCache(ctx context.Context, roleName string) (
 		time.Sleep(retrySleepTime)
 		log.Debug("to find role by key:[%s] ", roleName)
 		if get, found = cliCache.roleCache.Get(roleName); found {
-			return get.(*entity.Role), nil
+			return get, nil
 		}
 	}
 
@@ -178,11 +220,12 @@ func (cliCache *clientCache) RoleByCache(ctx context.Context, roleName string) (
 func (cliCache *clientCache) reloadRoleCache(ctx context.Context, sync bool, roleName string) error {
 	fun := func() error {
 		log.Info("to reload role:[%s]", roleName)
+		rev := cliCache.readRevision(ctx)
 		role, err := cliCache.mc.QueryRole(ctx, roleName)
 		if err != nil {
 			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("can not found role by name:[%s] err:[%s]", roleName, err.Error()))
 		}
-		cliCache.roleCache.Set(roleName, role, cache.NoExpiration)
+		cliCache.roleCache.casSet(roleName, role, rev)
 		return nil
 	}
 
@@ -204,7 +247,7 @@ func (cliCache *clientCache) SpaceByCache(ctx context.Context, db, space string)
 
 	get, found := cliCache.spaceCache.Get(key)
 	if found {
-		return get.(*entity.Space), nil
+		return get, nil
 	}
 
 	err := cliCache.reloadSpaceCache(ctx, false, db, space)
@@ -219,7 +262,7 @@ func (cliCache *clientCache) SpaceByCache(ctx context.Context, db, space string)
 		time.Sleep(retrySleepTime)
 		log.Debug("to find space by key:[%s] ", key)
 		if get, found = cliCache.spaceCache.Get(key); found {
-			return get.(*entity.Space), nil
+			return get, nil
 		}
 	}
 
@@ -231,6 +274,7 @@ func (cliCache *clientCache) reloadSpaceCache(ctx context.Context, sync bool, db
 
 	fun := func() error {
 		log.Info("to reload db:[%s] space:[%s]", db, spaceName)
+		rev := cliCache.readRevision(ctx)
 
 		dbID, err := cliCache.mc.QueryDBName2Id(ctx, db)
 		if err != nil {
@@ -248,8 +292,9 @@ func (cliCache *clientCache) reloadSpaceCache(ctx context.Context, sync bool, db
 		}
 		spaceCacheLock.Lock()
 		defer spaceCacheLock.Unlock()
-		cliCache.spaceCache.Set(key, space, cache.NoExpiration)
-		cliCache.spaceIDCache.Set(cast.ToString(space.Id), space, cache.NoExpiration)
+		if cliCache.spaceCache.casSet(key, space, rev) {
+			cliCache.spaceIDCache.Set(cast.ToString(space.Id), space)
+		}
 		return nil
 	}
 
@@ -279,7 +324,7 @@ func (cliCache *clientCache) PartitionByCache(ctx context.Context, spaceName str
 	key := cachePartitionKey(spaceName, pid)
 	get, found := cliCache.partitionCache.Get(key)
 	if found {
-		return get.(*entity.Partition), nil
+		return get, nil
 	}
 
 	_ = cliCache.reloadPartitionCache(ctx, false, spaceName, pid)
@@ -288,7 +333,7 @@ func (cliCache *clientCache) PartitionByCache(ctx context.Context, spaceName str
 		time.Sleep(retrySleepTime)
 		log.Debug("to find partition by key:[%s] ", key)
 		if get, found = cliCache.partitionCache.Get(key); found {
-			return get.(*entity.Partition), nil
+			return get, nil
 		}
 	}
 
@@ -304,12 +349,13 @@ func (cliCache *clientCache) reloadPartitionCache(ctx context.Context, sync bool
 		c, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 
+		rev := cliCache.readRevision(c)
 		partition, err := cliCache.mc.QueryPartition(c, pid)
 		if err != nil {
 			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("can not found db by space:[%s] partition_id:[%d] err:[%s]", spaceName, pid, err.Error()))
 		}
 
-		cliCache.partitionCache.Set(key, partition, cache.NoExpiration)
+		cliCache.partitionCache.casSet(key, partition, rev)
 
 		return nil
 	}
@@ -333,7 +379,7 @@ func (cliCache *clientCache) ServerByCache(ctx context.Context, id entity.NodeID
 	key := cast.ToString(id)
 	get, found := cliCache.serverCache.Get(key)
 	if found {
-		return get.(*entity.Server), nil
+		return get, nil
 	}
 
 	_ = cliCache.reloadServerCache(ctx, false, id)
@@ -342,7 +388,7 @@ func (cliCache *clientCache) ServerByCache(ctx context.Context, id entity.NodeID
 		time.Sleep(retrySleepTime)
 		log.Debug("to find server by key:[%s] ", key)
 		if get, found = cliCache.serverCache.Get(key); found {
-			return get.(*entity.Server), nil
+			return get, nil
 		}
 	}
 
@@ -358,12 +404,13 @@ func (cliCache *clientCache) reloadServerCache(ctx context.Context, sync bool, i
 		c, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 
+		rev := cliCache.readRevision(c)
 		server, err := cliCache.mc.QueryServer(c, id)
 		if err != nil {
 			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("can not found server node_id:[%d] err:[%s]", id, err.Error()))
 		}
 
-		cliCache.serverCache.Set(key, server, cache.NoExpiration)
+		cliCache.serverCache.casSet(key, server, rev)
 
 		return nil
 	}
@@ -392,7 +439,7 @@ func (cliCache *clientCache) startWSJob(ctx context.Context) error {
 	if err := cliCache.initServer(ctx); err != nil {
 		return err
 	}
-	log.Debug("server info is %v", *cliCache.serverCache)
+	log.Debug("server info is %v", cliCache.serverCache.Items())
 	serverJob := watcherJob{ctx: ctx, prefix: entity.PrefixServer, masterClient: cliCache.mc, cache: cliCache.serverCache}
 	serverJob.put = serverJob.serverPut
 	serverJob.delete = serverJob.serverDelete
@@ -401,41 +448,36 @@ func (cliCache *clientCache) startWSJob(ctx context.Context) error {
 	return nil
 }
 
-// start cache job
-func (cliCache *clientCache) startCacheJob(ctx context.Context) error {
+// start cache job, the cache is filled by scanning etcd if scan is set
+func (cliCache *clientCache) startCacheJob(ctx context.Context, scan bool) error {
 	log.Info("start cache job")
 	start := time.Now()
+	mux := newWatchMux(ctx, cliCache.mc)
 
-	// init user
-	if err := cliCache.initUser(ctx); err != nil {
-		return err
-	}
-	userJob := watcherJob{ctx: ctx, prefix: entity.PrefixUser, masterClient: cliCache.mc, cache: cliCache.userCache,
-		put: func(value []byte) (err error) {
+	// watch user
+	userJob := watcherJob{ctx: ctx, prefix: entity.PrefixUser, masterClient: cliCache.mc,
+		put: func(value []byte, rev int64) (err error) {
 			user := &entity.User{}
 			if err := vjson.Unmarshal(value, user); err != nil {
-				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("put event user cache err, can't unmarshal event value: %s, error: %s", string(value), err.Error()))
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("put event user cache err, can't unmarshal event value: %s, error: %s", redact.Payload(value), err.Error()))
 			}
-			log.Debug("[%v] add to user cache.", *user)
-			cliCache.userCache.Set(entity.UserKey(user.Name), user, cache.NoExpiration)
+			log.Debug("[%s] add to user cache.", user.Name)
+			cliCache.userCache.casSet(user.Name, user, rev)
 			return nil
 		},
-		delete: func(key string) (err error) {
+		delete: func(key string, rev int64) (err error) {
 			userSplit := strings.Split(key, "/")
 			username := userSplit[len(userSplit)-1]
 			log.Debug("[%s] delete from user cache.", username)
-			cliCache.userCache.Delete(username)
+			cliCache.userCache.casDelete(username, rev)
 			return nil
 		},
 	}
-	userJob.start()
+	mux.add(&userJob)
 
-	// init space
-	if err := cliCache.initSpace(ctx); err != nil {
-		return err
-	}
-	spaceJob := watcherJob{ctx: ctx, prefix: entity.PrefixSpace, masterClient: cliCache.mc, cache: cliCache.spaceCache,
-		put: func(value []byte) (err error) {
+	// watch space
+	spaceJob := watcherJob{ctx: ctx, prefix: entity.PrefixSpace, masterClient: cliCache.mc,
+		put: func(value []byte, rev int64) (err error) {
 			space := &entity.Space{}
 			if err := vjson.Unmarshal(value, space); err != nil {
 				return err
@@ -445,33 +487,35 @@ func (cliCache *clientCache) startCacheJob(ctx context.Context) error {
 					space.Name, space.ResourceName, config.Conf().Global.ResourceName)
 				return nil
 			}
-			dbName, err := cliCache.mc.QueryDBId2Name(ctx, space.DBId)
+			dbName, err := cliCache.dbName(ctx, space.DBId)
 			if err != nil {
-				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("find db by id err: %s, data: %s", err.Error(), string(value)))
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("find db by id err: %s, data: %s", err.Error(), redact.Payload(value)))
 			}
 			key := cacheSpaceKey(dbName, space.Name)
-			if oldValue, b := cliCache.spaceCache.Get(key); !b || space.Version > oldValue.(*entity.Space).Version {
+			if oldValue, b := cliCache.spaceCache.Get(key); !b || space.Newer(oldValue) {
 				spaceCacheLock.Lock()
-				cliCache.spaceCache.Set(key, space, cache.NoExpiration)
-				cliCache.spaceIDCache.Set(cast.ToString(space.Id), space, cache.NoExpiration)
+				if cliCache.spaceCache.casSet(key, space, rev) {
+					cliCache.spaceIDCache.Set(cast.ToString(space.Id), space)
+				}
 				log.Debug("space name [%s] , [%s], [%s] add to cache.",
 					space.Name, space.ResourceName, config.Conf().Global.ResourceName)
 				spaceCacheLock.Unlock()
 			}
 			return nil
 		},
-		delete: func(key string) (err error) {
+		delete: func(key string, rev int64) (err error) {
 			spaceSplit := strings.Split(key, "/")
 			dbIDStr := spaceSplit[len(spaceSplit)-2]
 			dbID := cast.ToInt64(dbIDStr)
 			spaceIDStr := spaceSplit[len(spaceSplit)-1]
 			spaceID := cast.ToInt64(spaceIDStr)
 			for k, v := range cliCache.spaceCache.Items() {
-				if v.Object.(*entity.Space).DBId == dbID && v.Object.(*entity.Space).Id == spaceID {
+				if v.DBId == dbID && v.Id == spaceID {
 					log.Info("remove space cache dbID:[%d] space:[%d] ", dbID, spaceID)
 					spaceCacheLock.Lock()
-					cliCache.spaceCache.Delete(k)
-					cliCache.spaceIDCache.Delete(cast.ToString(spaceID))
+					if cliCache.spaceCache.casDelete(k, rev) {
+						cliCache.spaceIDCache.Delete(cast.ToString(spaceID))
+					}
 					spaceCacheLock.Unlock()
 					break
 				}
@@ -479,48 +523,42 @@ func (cliCache *clientCache) startCacheJob(ctx context.Context) error {
 			return nil
 		},
 	}
-	spaceJob.start()
+	mux.add(&spaceJob)
 
-	// init partition
-	if err := cliCache.initPartition(ctx); err != nil {
-		return err
-	}
-	partitionJob := watcherJob{ctx: ctx, prefix: entity.PrefixPartition, masterClient: cliCache.mc, cache: cliCache.partitionCache,
-		put: func(value []byte) (err error) {
+	// watch partition
+	partitionJob := watcherJob{ctx: ctx, prefix: entity.PrefixPartition, masterClient: cliCache.mc,
+		put: func(value []byte, rev int64) (err error) {
 			partition := &entity.Partition{}
 			if err = vjson.Unmarshal(value, partition); err != nil {
 				return
 			}
-			space, err := cliCache.mc.QuerySpaceByID(ctx, partition.DBId, partition.SpaceId)
+			spaceName, err := cliCache.spaceName(ctx, partition.DBId, partition.SpaceId)
 			if err != nil {
 				return
 			}
-			cacheKey := cachePartitionKey(space.Name, partition.Id)
-			if old, b := cliCache.partitionCache.Get(cacheKey); !b || partition.UpdateTime > old.(*entity.Partition).UpdateTime {
-				cliCache.partitionCache.Set(cacheKey, partition, cache.NoExpiration)
+			cacheKey := cachePartitionKey(spaceName, partition.Id)
+			if old, b := cliCache.partitionCache.Get(cacheKey); !b || partition.UpdateTime > old.UpdateTime {
+				cliCache.partitionCache.casSet(cacheKey, partition, rev)
 			}
 			return nil
 		},
-		delete: func(key string) (err error) {
+		delete: func(key string, rev int64) (err error) {
 			partitionIdSplit := strings.Split(key, "/")
 			partitionIdStr := partitionIdSplit[len(partitionIdSplit)-1]
 			for k := range cliCache.partitionCache.Items() {
 				if strings.HasSuffix(k, "/"+partitionIdStr) {
-					cliCache.partitionCache.Delete(k)
+					cliCache.partitionCache.casDelete(k, rev)
 					break
 				}
 			}
 			return nil
 		},
 	}
-	partitionJob.start()
+	mux.add(&partitionJob)
 
-	// init server
-	if err := cliCache.initServer(ctx); err != nil {
-		return err
-	}
+	// watch server
 	serverJob := watcherJob{ctx: ctx, prefix: entity.PrefixServer, masterClient: cliCache.mc, cache: cliCache.serverCache,
-		put: func(value []byte) (err error) {
+		put: func(value []byte, rev int64) (err error) {
 			defer errutil.CatchError(&err)
 			server := &entity.Server{}
 			if err := vjson.Unmarshal(value, server); err != nil {
@@ -537,10 +575,10 @@ func (cliCache *clientCache) startCacheJob(ctx context.Context) error {
 					cliCache.Delete(server.ID)
 				}
 			}
-			cliCache.serverCache.Set(cacheServerKey(server.ID), server, cache.NoExpiration)
+			cliCache.serverCache.casSet(cacheServerKey(server.ID), server, rev)
 			return nil
 		},
-		delete: func(key string) (err error) {
+		delete: func(key string, rev int64) (err error) {
 			defer errutil.CatchError(&err)
 			serverSplit := strings.Split(key, "/")
 			nodeIdStr := serverSplit[len(serverSplit)-1]
@@ -549,88 +587,96 @@ func (cliCache *clientCache) startCacheJob(ctx context.Context) error {
 				value.(*rpcClient).close()
 				cliCache.Delete(nodeId)
 			}
-			cliCache.serverCache.Delete(nodeIdStr)
+			cliCache.serverCache.casDelete(nodeIdStr, rev)
 			return nil
 		},
 	}
-	serverJob.start()
+	mux.add(&serverJob)
+	// fail servers are recovered once by the cache, not by every job
+	go serverJob.recoverFailServers()
 
-	// init alias
-	if err := cliCache.initAlias(ctx); err != nil {
-		return err
-	}
-	aliasJob := watcherJob{ctx: ctx, prefix: entity.PrefixAlias, masterClient: cliCache.mc, cache: cliCache.aliasCache,
-		put: func(value []byte) (err error) {
+	// watch alias
+	aliasJob := watcherJob{ctx: ctx, prefix: entity.PrefixAlias, masterClient: cliCache.mc,
+		put: func(value []byte, rev int64) (err error) {
 			defer errutil.CatchError(&err)
 			alias := &entity.Alias{}
 			if err := vjson.Unmarshal(value, alias); err != nil {
 				return err
 			}
 			log.Debug("[%v] add to alias cache.", *alias)
-			cliCache.aliasCache.Set(alias.Name, alias, cache.NoExpiration)
+			cliCache.aliasCache.casSet(alias.Name, alias, rev)
 			return nil
 		},
-		delete: func(key string) (err error) {
+		delete: func(key string, rev int64) (err error) {
 			defer errutil.CatchError(&err)
 			aliasSplit := strings.Split(key, "/")
 			alias_name := aliasSplit[len(aliasSplit)-1]
 			log.Debug("[%s] delete from alias cache.", alias_name)
-			cliCache.aliasCache.Delete(alias_name)
+			cliCache.aliasCache.casDelete(alias_name, rev)
 			return nil
 		},
 	}
-	aliasJob.start()
+	mux.add(&aliasJob)
 
-	// init role
-	if err := cliCache.initRole(ctx); err != nil {
-		return err
-	}
-	roleJob := watcherJob{ctx: ctx, prefix: entity.PrefixRole, masterClient: cliCache.mc, cache: cliCache.roleCache,
-		put: func(value []byte) (err error) {
+	// watch role
+	roleJob := watcherJob{ctx: ctx, prefix: entity.PrefixRole, masterClient: cliCache.mc,
+		put: func(value []byte, rev int64) (err error) {
 			role := &entity.Role{}
-			if err := vjson.Unmarshal(value, role); err != nil {
-				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("put event role cache err, can't unmarshal event value: %s, error: %s", string(value), err.Error()))
+			if _, err := entity.DecodeMeta(value, role); err != nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("put event role cache err, can't unmarshal event value: %s, error: %s", redact.Payload(value), err.Error()))
 			}
 			log.Debug("[%v] add to role cache.", *role)
-			cliCache.roleCache.Set(entity.RoleKey(role.Name), role, cache.NoExpiration)
+			cliCache.roleCache.casSet(role.Name, role, rev)
 			return nil
 		},
-		delete: func(key string) (err error) {
+		delete: func(key string, rev int64) (err error) {
 			roleSplit := strings.Split(key, "/")
 			rolename := roleSplit[len(roleSplit)-1]
 			log.Debug("[%s] delete from role cache.", rolename)
-			cliCache.roleCache.Delete(rolename)
+			cliCache.roleCache.casDelete(rolename, rev)
 			return nil
 		},
 	}
-	roleJob.start()
+	mux.add(&roleJob)
 
 	// init masters
 	if err := cliCache.initMasters(); err != nil {
 		return err
 	}
-	mastersJob := watcherJob{ctx: ctx, prefix: entity.PrefixMasterMember, masterClient: cliCache.mc, cache: cliCache.mastersCache,
-		put: func(value []byte) (err error) {
+	mastersJob := watcherJob{ctx: ctx, prefix: entity.PrefixMasterMember, masterClient: cliCache.mc,
+		put: func(value []byte, rev int64) (err error) {
 			var master config.MasterCfg
 			if err := vjson.Unmarshal(value, &master); err != nil {
-				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("put event masters cache err, can't unmarshal event value: %s, error: %s", string(value), err.Error()))
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("put event masters cache err, can't unmarshal event value: %s, error: %s", redact.Payload(value), err.Error()))
 			}
 			log.Debug("[%v] add to master cache.", master)
-			cliCache.mastersCache.Set(master.Address, master, cache.NoExpiration)
+			cliCache.mastersCache.casSet(master.Address, master, rev)
 			if err := cliCache.mc.CheckMasterConfig(ctx); err != nil {
 				log.Error("router update master config err: %s", err.Error())
 			}
 			return nil
 		},
-		delete: func(key string) (err error) {
+		delete: func(key string, rev int64) (err error) {
 			masterSplit := strings.Split(key, "/")
 			masterAddress := masterSplit[len(masterSplit)-1]
 			log.Debug("[%s] delete from masters cache.", key)
-			cliCache.mastersCache.Delete(masterAddress)
+			cliCache.mastersCache.casDelete(masterAddress, rev)
 			return nil
 		},
 	}
-	mastersJob.start()
+	mux.add(&mastersJob)
+
+	// the watches are opened before the scans so no change after a scan is
+	// missed, the cache orders the scans and the events by revision
+	mux.start()
+	if scan {
+		for _, init := range []func(context.Context) error{cliCache.initUser, cliCache.initSpace,
+			cliCache.initPartition, cliCache.initServer, cliCache.initAlias, cliCache.initRole} {
+			if err := init(ctx); err != nil {
+				return err
+			}
+		}
+	}
 
 	log.Info("cache inited ok use time %v", time.Since(start))
 
@@ -645,6 +691,7 @@ func (cliCache *clientCache) stopCacheJob() {
 }
 
 func (cliCache *clientCache) initUser(ctx context.Context) error {
+	rev := cliCache.readRevision(ctx)
 	_, users, err := cliCache.mc.PrefixScan(ctx, entity.PrefixUser)
 	if err != nil {
 		log.Error("init user cache err: %s", err.Error())
@@ -657,22 +704,20 @@ func (cliCache *clientCache) initUser(ctx context.Context) error {
 			log.Error("init user cache err: %s", err.Error())
 			return err
 		}
-		if err := cliCache.userCache.Add(user.Name, user, cache.NoExpiration); err != nil {
-			log.Error(err.Error())
-			return err
-		}
+		cliCache.userCache.casSet(user.Name, user, rev)
 	}
 
 	return nil
 }
 
 func (cliCache *clientCache) initSpace(ctx context.Context) error {
+	rev := cliCache.readRevision(ctx)
 	spaces, err := cliCache.mc.QuerySpacesByKey(ctx, entity.PrefixSpace)
 	if err != nil {
 		return err
 	}
 	for _, s := range spaces {
-		db, err := cliCache.mc.QueryDBId2Name(ctx, s.DBId)
+		db, err := cliCache.dbName(ctx, s.DBId)
 		if err != nil {
 			log.Error("init spaces cache dbid to id err , err:[%s]", err.Error())
 			continue
@@ -685,10 +730,8 @@ func (cliCache *clientCache) initSpace(ctx context.Context) error {
 		}
 
 		spaceCacheLock.Lock()
-		if err := cliCache.spaceCache.Add(cacheSpaceKey(db, s.Name), s, cache.NoExpiration); err != nil {
-			log.Error(err.Error())
-		} else {
-			cliCache.spaceIDCache.Set(cast.ToString(s.Id), s, cache.NoExpiration)
+		if cliCache.spaceCache.casSet(cacheSpaceKey(db, s.Name), s, rev) {
+			cliCache.spaceIDCache.Set(cast.ToString(s.Id), s)
 		}
 		spaceCacheLock.Unlock()
 	}
@@ -696,6 +739,7 @@ func (cliCache *clientCache) initSpace(ctx context.Context) error {
 }
 
 func (cliCache *clientCache) initPartition(ctx context.Context) error {
+	rev := cliCache.readRevision(ctx)
 	_, values, err := cliCache.mc.PrefixScan(ctx, entity.PrefixPartition)
 	if err != nil {
 		log.Error("init partition cache err , err:[%s]", err.Error())
@@ -712,23 +756,22 @@ func (cliCache *clientCache) initPartition(ctx context.Context) error {
 		}
 		spaceName := spaceNameMap[pt.SpaceId]
 		if spaceName == "" {
-			space, err := cliCache.mc.QuerySpaceByID(ctx, pt.DBId, pt.SpaceId)
+			name, err := cliCache.spaceName(ctx, pt.DBId, pt.SpaceId)
 			if err != nil {
 				log.Error("partition can not find by DBID:[%d] spaceID:[%d] partitionID:[%d] err:[%s]", pt.DBId, pt.SpaceId, pt.Id, err.Error())
 				continue
 			}
-			spaceName, spaceNameMap[pt.SpaceId] = space.Name, space.Name
+			spaceName, spaceNameMap[pt.SpaceId] = name, name
 		}
 		key := cachePartitionKey(spaceName, pt.Id)
-		if err := cliCache.partitionCache.Add(key, pt, cache.NoExpiration); err != nil {
-			log.Error(err.Error())
-		}
+		cliCache.partitionCache.casSet(key, pt, rev)
 	}
 
 	return nil
 }
 
 func (cliCache *clientCache) initServer(ctx context.Context) error {
+	rev := cliCache.readRevision(ctx)
 	_, values, err := cliCache.mc.PrefixScan(ctx, entity.PrefixServer)
 	if err != nil {
 		log.Error("init server cache err , err:[%s]", err.Error())
@@ -741,9 +784,7 @@ func (cliCache *clientCache) initServer(ctx context.Context) error {
 			log.Error("unmarshal server cache err [%s]", err.Error())
 			continue
 		}
-		if err := cliCache.serverCache.Add(cast.ToString(server.ID), server, cache.NoExpiration); err != nil {
-			log.Error(err.Error())
-		}
+		cliCache.serverCache.casSet(cast.ToString(server.ID), server, rev)
 	}
 	return nil
 }
@@ -759,13 +800,13 @@ type watcherJob struct {
 	prefix       string
 	masterClient *masterClient
 	wg           sync.WaitGroup
-	cache        *cache.Cache
-	put          func(value []byte) (err error)
-	delete       func(key string) (err error)
+	cache        *Cache[*entity.Server]
+	put          func(value []byte, rev int64) (err error)
+	delete       func(key string, rev int64) (err error)
 }
 
 // watch /server/ put
-func (w *watcherJob) serverPut(value []byte) (e error) {
+func (w *watcherJob) serverPut(value []byte, _ int64) (e error) {
 	// process panic
 	defer errutil.CatchError(&e)
 	// parse server info
@@ -802,13 +843,13 @@ func (w *watcherJob) serverPut(value []byte) (e error) {
 		}
 	}
 	// update the cache
-	w.cache.Set(cacheServerKey(server.ID), server, cache.NoExpiration)
+	w.cache.Set(cacheServerKey(server.ID), server)
 	log.Debug("update cache %s: %+v ", cacheServerKey(server.ID), server)
 	return err
 }
 
 // watch /server/ delete
-func (w *watcherJob) serverDelete(cacheKey string) (err error) {
+func (w *watcherJob) serverDelete(cacheKey string, _ int64) (err error) {
 	// process panic
 	defer errutil.CatchError(&err)
 	parts := strings.Split(cacheKey, "/")
@@ -819,6 +860,41 @@ func (w *watcherJob) serverDelete(cacheKey string) (err error) {
 	if nodeID == 0 {
 		return fmt.Errorf("nodeID is invalid: %d", nodeID)
 	}
+	if grace := config.Conf().PS.PsFailureGrace; grace > 0 {
+		// a ps missing a few keepalives registers again with a new lease,
+		// only record it as failed if it does not within the grace
+		get, found := w.cache.Get(cacheServerKey(nodeID))
+		if !found || get == nil {
+			return nil
+		}
+		go func(server *entity.Server) {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(time.Duration(grace) * time.Second):
+			}
+			if value, err := w.masterClient.Get(w.ctx, entity.ServerKey(nodeID)); err == nil && value != nil {
+				log.Info("server %d registered again within %ds, not failed", nodeID, grace)
+				return
+			}
+			if err := w.recordFailServer(nodeID, server); err != nil {
+				log.Error("record fail server %d err: %v", nodeID, err)
+			}
+		}(get)
+		return nil
+	}
+	get, found := w.cache.Get(cacheServerKey(nodeID))
+	if !found || get == nil {
+		log.Debug("node meta not found: %v, %v", found, get)
+		return w.recordFailServer(nodeID, nil)
+	}
+	return w.recordFailServer(nodeID, get)
+}
+
+// recordFailServer puts the ps failed unless it answers, then drops it from
+// the cache
+func (w *watcherJob) recordFailServer(nodeID uint64, failServer *entity.Server) (err error) {
+	defer errutil.CatchError(&err)
 	// mutex ensure only one master update the meta, the other just update local cache
 	mutex := w.masterClient.Client().Master().NewLock(w.ctx, entity.ClusterWatchServerKeyDelete, time.Second*188)
 	if getLock, err := mutex.TryLock(); getLock && err == nil {
@@ -828,12 +904,9 @@ func (w *watcherJob) serverDelete(cacheKey string) (err error) {
 			}
 		}()
 		log.Debug("get LOCK success, record fail server %d", nodeID)
-		get, found := w.cache.Get(cacheServerKey(nodeID))
-		if !found || get == nil {
-			log.Debug("node meta not found: %v, %v", found, get)
+		if failServer == nil {
 			return nil
 		}
-		failServer := get.(*entity.Server)
 		// attach alive, timeout is 5s
 		if IsLive(failServer.RpcAddr()) || len(failServer.PartitionIds) == 0 {
 			log.Info("%v is alive or server partition num is 0.", *failServer)
@@ -855,7 +928,7 @@ func (w *watcherJob) serverDelete(cacheKey string) (err error) {
 func (cliCache *clientCache) AliasByCache(ctx context.Context, alias_name string) (*entity.Alias, error) {
 	get, found := cliCache.aliasCache.Get(alias_name)
 	if found {
-		return get.(*entity.Alias), nil
+		return get, nil
 	}
 
 	err := cliCache.reloadAliasCache(ctx, false, alias_name)
@@ -866,7 +939,7 @@ func (cliCache *clientCache) AliasByCache(ctx context.Context, alias_name string
 
 	for i := 0; i < retryNum; i++ {
 		if get, found = cliCache.aliasCache.Get(alias_name); found {
-			return get.(*entity.Alias), nil
+			return get, nil
 		}
 		time.Sleep(retrySleepTime)
 	}
@@ -878,12 +951,13 @@ func (cliCache *clientCache) reloadAliasCache(ctx context.Context, sync bool, al
 	fun := func() error {
 		log.Info("to reload alias_name:[%s]", alias_name)
 
+		rev := cliCache.readRevision(ctx)
 		alias, err := cliCache.mc.QueryAliasByName(ctx, alias_name)
 
 		if err != nil {
 			return fmt.Errorf("can not found alias by name:[%s] err:[%s]", alias_name, err.Error())
 		}
-		cliCache.aliasCache.Set(alias_name, alias, cache.NoExpiration)
+		cliCache.aliasCache.casSet(alias_name, alias, rev)
 		return nil
 	}
 
@@ -894,6 +968,7 @@ func (cliCache *clientCache) reloadAliasCache(ctx context.Context, sync bool, al
 }
 
 func (cliCache *clientCache) initAlias(ctx context.Context) error {
+	rev := cliCache.readRevision(ctx)
 	_, values, err := cliCache.mc.PrefixScan(ctx, entity.PrefixAlias)
 	if err != nil {
 		log.Error("init alias cache err , err:[%s]", err.Error())
@@ -906,14 +981,13 @@ func (cliCache *clientCache) initAlias(ctx context.Context) error {
 			log.Error("unmarshal alias cache err [%s]", err.Error())
 			continue
 		}
-		if err := cliCache.aliasCache.Add(alias.Name, alias, cache.NoExpiration); err != nil {
-			log.Error(err.Error())
-		}
+		cliCache.aliasCache.casSet(alias.Name, alias, rev)
 	}
 	return nil
 }
 
 func (cliCache *clientCache) initRole(ctx context.Context) error {
+	rev := cliCache.readRevision(ctx)
 	_, values, err := cliCache.mc.PrefixScan(ctx, entity.PrefixRole)
 	if err != nil {
 		log.Error("init role cache err , err:[%s]", err.Error())
@@ -921,14 +995,12 @@ func (cliCache *clientCache) initRole(ctx context.Context) error {
 	}
 	for _, value := range values {
 		role := &entity.Role{}
-		err := vjson.Unmarshal(value, role)
+		_, err := entity.DecodeMeta(value, role)
 		if err != nil {
 			log.Error("unmarshal role cache err [%s]", err.Error())
 			continue
 		}
-		if err := cliCache.roleCache.Add(role.Name, role, cache.NoExpiration); err != nil {
-			log.Error(err.Error())
-		}
+		cliCache.roleCache.casSet(role.Name, role, rev)
 	}
 	return nil
 }
@@ -964,275 +1036,292 @@ func (wj *watcherJob) start() {
 				log.Debug("start watcher routine %s", wj.prefix)
 			}
 
-			wj.wg.Add(1)
+			wj.wg.Add(2)
 			go func() {
-				defer func() {
-					if rErr := recover(); rErr != nil {
-						log.Error("recover() err:[%v]", rErr)
-						log.Error("stack:[%s]", debug.Stack())
-					}
-				}()
 				defer wj.wg.Done()
+				wj.watch()
+			}()
+			go func() {
+				defer wj.wg.Done()
+				wj.recoverFailServers()
+			}()
+			wj.wg.Wait()
+		}
+	}()
+}
 
-				select {
-				case <-wj.ctx.Done():
-					log.Debug("watchjob job to stop %s", wj.prefix)
-					return
-				default:
-				}
+// watch applies the events under the prefix of job until the watch closes
+func (wj *watcherJob) watch() {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			log.Error("recover() err:[%v]", rErr)
+			log.Error("stack:[%s]", debug.Stack())
+		}
+	}()
 
-				watcher, err := wj.masterClient.WatchPrefix(wj.ctx, wj.prefix)
+	select {
+	case <-wj.ctx.Done():
+		log.Debug("watchjob job to stop %s", wj.prefix)
+		return
+	default:
+	}
 
-				if err != nil {
-					log.Error("watch prefix:[%s] err", wj.prefix)
-					time.Sleep(1 * time.Second)
-					return
-				}
+	watcher, err := wj.masterClient.WatchPrefix(wj.ctx, wj.prefix)
 
-				for reps := range watcher {
-					if reps.Canceled {
-						log.Error("chan is closed by server watcher job")
-						return
-					}
+	if err != nil {
+		log.Error("watch prefix:[%s] err", wj.prefix)
+		time.Sleep(1 * time.Second)
+		return
+	}
 
-					for _, event := range reps.Events {
-						switch event.Type {
-						case mvccpb.PUT:
-							err := wj.put(event.Kv.Value)
-							if err != nil {
-								log.Error("change cache %s, err: %s , content: %s", wj.prefix, err.Error(), string(event.Kv.Value))
-							}
+	for reps := range watcher {
+		if reps.Canceled {
+			log.Error("chan is closed by server watcher job")
+			return
+		}
 
-						case mvccpb.DELETE:
-							err := wj.delete(string(event.Kv.Key))
-							if err != nil {
-								log.Error("delete cache %s, err: %s , content: %s", wj.prefix, err.Error(), string(event.Kv.Value))
-							}
-						}
-					}
-				}
-			}()
+		for _, event := range reps.Events {
+			wj.apply(event)
+		}
+	}
+}
+
+// apply changes the cache of job by the event
+func (wj *watcherJob) apply(event *clientv3.Event) {
+	if err := fault.Eval(fault.Watcher, wj.prefix); err != nil {
+		log.Error("skip watcher event of %s, err: %v", wj.prefix, err)
+		return
+	}
+	switch event.Type {
+	case mvccpb.PUT:
+		err := wj.put(event.Kv.Value, event.Kv.ModRevision)
+		if err != nil {
+			log.Error("change cache %s, err: %s , content: %s", wj.prefix, err.Error(), redact.Payload(event.Kv.Value))
+		}
+
+	case mvccpb.DELETE:
+		err := wj.delete(string(event.Kv.Key), event.Kv.ModRevision)
+		if err != nil {
+			log.Error("delete cache %s, err: %s , key: %s", wj.prefix, err.Error(), string(event.Kv.Key))
+		}
+	}
+}
 
-			recoverTime := int64(1800)
-			if config.Conf().PS.ReplicaAutoRecoverTime > 0 {
-				recoverTime = config.Conf().PS.ReplicaAutoRecoverTime
+// recoverFailServers moves the replicas of the servers failed longer than the
+// recover time to empty servers if ps auto recover is on
+func (wj *watcherJob) recoverFailServers() {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			log.Error("recover() err:[%v]", rErr)
+			log.Error("stack:[%s]", debug.Stack())
+		}
+	}()
+	if !config.Conf().Global.AutoRecoverPs {
+		return
+	}
+
+	recoverTime := int64(1800)
+	if config.Conf().PS.ReplicaAutoRecoverTime > 0 {
+		recoverTime = config.Conf().PS.ReplicaAutoRecoverTime
+	}
+	antiAffinity := config.Conf().PS.ReplicaAntiAffinityStrategy
+
+	for {
+		select {
+		case <-wj.ctx.Done():
+			log.Debug("watchjob job to stop %s", wj.prefix)
+			return
+		default:
+		}
+
+		time.Sleep(60 * time.Second)
+		mutex := wj.masterClient.Client().Master().NewLock(wj.ctx, entity.ClusterWatchServerKeyScan, time.Second*188)
+		if getLock, err := mutex.TryLock(); getLock && err == nil {
+			unlock := func() {
+				if err := mutex.Unlock(); err != nil {
+					log.Error("failed to unlock space, the Error is:%v ", err)
+				}
 			}
 
-			antiAffinity := config.Conf().PS.ReplicaAntiAffinityStrategy
-			wj.wg.Add(1)
-			go func() {
-				defer func() {
-					if rErr := recover(); rErr != nil {
-						log.Error("recover() err:[%v]", rErr)
-						log.Error("stack:[%s]", debug.Stack())
-					}
-				}()
-				defer wj.wg.Done()
+			fs, err := wj.masterClient.QueryAllFailServer(wj.ctx)
+			if err != nil {
+				log.Error("query all fail server err: %v", err)
+				time.Sleep(1 * time.Second)
+				unlock()
+				continue
+			}
 
-				if !config.Conf().Global.AutoRecoverPs {
-					return
+			for _, failServer := range fs {
+				if len(failServer.Node.PartitionIds) == 0 {
+					continue
 				}
 
-				for {
-					select {
-					case <-wj.ctx.Done():
-						log.Debug("watchjob job to stop %s", wj.prefix)
-						return
+				recoveredPid := make([]entity.PartitionID, 0)
+				if time.Now().Unix()-failServer.TimeStamp > recoverTime {
+					log.Debug("failServer %v is dead, try to recover replicas", *failServer)
+
+					var zone string
+
+					switch antiAffinity {
+					case 1:
+						zone = failServer.Node.HostIp
+					case 2:
+						zone = failServer.Node.HostRack
+					case 3:
+						zone = failServer.Node.HostZone
 					default:
+						zone = ""
 					}
-
-					time.Sleep(60 * time.Second)
-					mutex := wj.masterClient.Client().Master().NewLock(wj.ctx, entity.ClusterWatchServerKeyScan, time.Second*188)
-					if getLock, err := mutex.TryLock(); getLock && err == nil {
-						unlock := func() {
-							if err := mutex.Unlock(); err != nil {
-								log.Error("failed to unlock space, the Error is:%v ", err)
+					for _, failPid := range failServer.Node.PartitionIds {
+						// get partition
+						partition, err := wj.masterClient.QueryPartition(wj.ctx, failPid)
+						errutil.ThrowError(err)
+						space, err := wj.masterClient.QuerySpaceByID(wj.ctx, partition.DBId, partition.SpaceId)
+						if err != nil {
+							log.Error("query space by id %d err: %v", partition.SpaceId, err)
+							continue
+						}
+						replicas := make([]entity.NodeID, 0)
+						for _, r := range partition.Replicas {
+							server, err := wj.masterClient.QueryServer(wj.ctx, r)
+							if err != nil {
+								log.Error("query server by id %d err: %v", r, err)
+								continue
+							}
+							if IsLive(server.RpcAddr()) {
+								replicas = append(replicas, r)
 							}
 						}
-
-						fs, err := wj.masterClient.QueryAllFailServer(wj.ctx)
-						if err != nil {
-							log.Error("query all fail server err: %v", err)
-							time.Sleep(1 * time.Second)
-							unlock()
+						if len(replicas) < int((space.ReplicaNum+1)/2) {
+							log.Error("partition %d replica num %d less than half of space replica num %d", failPid, len(replicas), space.ReplicaNum)
 							continue
 						}
-
-						for _, failServer := range fs {
-							if len(failServer.Node.PartitionIds) == 0 {
+						// get all server
+						servers, err := wj.masterClient.QueryServers(wj.ctx)
+						errutil.ThrowError(err)
+						availableServers := make([]*entity.Server, 0)
+						for _, server := range servers {
+							if server.ID == failServer.ID {
 								continue
 							}
 
-							recoveredPid := make([]entity.PartitionID, 0)
-							if time.Now().Unix()-failServer.TimeStamp > recoverTime {
-								log.Debug("failServer %v is dead, try to recover replicas", *failServer)
-
-								var zone string
+							if zone != "" {
+								var destZone string
 
 								switch antiAffinity {
 								case 1:
-									zone = failServer.Node.HostIp
+									destZone = server.HostIp
 								case 2:
-									zone = failServer.Node.HostRack
+									destZone = server.HostRack
 								case 3:
-									zone = failServer.Node.HostZone
+									destZone = server.HostZone
 								default:
-									zone = ""
-								}
-								for _, failPid := range failServer.Node.PartitionIds {
-									// get partition
-									partition, err := wj.masterClient.QueryPartition(wj.ctx, failPid)
-									errutil.ThrowError(err)
-									space, err := wj.masterClient.QuerySpaceByID(wj.ctx, partition.DBId, partition.SpaceId)
-									if err != nil {
-										log.Error("query space by id %d err: %v", partition.SpaceId, err)
-										continue
-									}
-									replicas := make([]entity.NodeID, 0)
-									for _, r := range partition.Replicas {
-										server, err := wj.masterClient.QueryServer(wj.ctx, r)
-										if err != nil {
-											log.Error("query server by id %d err: %v", r, err)
-											continue
-										}
-										if IsLive(server.RpcAddr()) {
-											replicas = append(replicas, r)
-										}
-									}
-									if len(replicas) < int((space.ReplicaNum+1)/2) {
-										log.Error("partition %d replica num %d less than half of space replica num %d", failPid, len(replicas), space.ReplicaNum)
-										continue
-									}
-									// get all server
-									servers, err := wj.masterClient.QueryServers(wj.ctx)
-									errutil.ThrowError(err)
-									availableServers := make([]*entity.Server, 0)
-									for _, server := range servers {
-										if server.ID == failServer.ID {
-											continue
-										}
-
-										if zone != "" {
-											var destZone string
-
-											switch antiAffinity {
-											case 1:
-												destZone = server.HostIp
-											case 2:
-												destZone = server.HostRack
-											case 3:
-												destZone = server.HostZone
-											default:
-												destZone = ""
-											}
-
-											if destZone == zone {
-												continue
-											}
-										}
-
-										bFound := false
-										for _, id := range replicas {
-											if id == server.ID {
-												bFound = true
-											}
-										}
-										if bFound {
-											continue
-										}
-										availableServers = append(availableServers, server)
-									}
-
-									if len(availableServers) == 0 {
-										log.Error("no available server to recover partition %d", failPid)
-										continue
-									}
-
-									sort.Slice(availableServers, func(i, j int) bool {
-										return len(availableServers[i].PartitionIds) < len(availableServers[j].PartitionIds)
-									})
-
-									if len(availableServers[0].PartitionIds) > 0 {
-										// only use the server which has 0 partition
-										log.Warn("server %d has %d partitions, can not recover partition %d", availableServers[0].ID, len(availableServers[0].PartitionIds), failPid)
-										continue
-									}
-									cm := &entity.ChangeMembers{
-										PartitionIDs: []entity.PartitionID{failPid},
-										NodeID:       availableServers[0].ID,
-										Method:       proto.ConfAddNode,
-									}
-									reqBody, err := vjson.Marshal(cm)
-									if err != nil {
-										log.Error("%v", err)
-										continue
-									}
-									response, err := wj.masterClient.HTTPRequest(wj.ctx, http.MethodPost, "/partitions/change_member?timeout=60000", string(reqBody))
-									if err != nil {
-										log.Error("%s: %v", string(reqBody), err)
-										continue
-									}
-									js := &httpResonse.HttpReply{}
-									err = vjson.Unmarshal(response, js)
-									if err != nil {
-										log.Error("%v", err)
-										continue
-									}
-									if js.Code != int(vearchpb.ErrorEnum_SUCCESS) {
-										log.Error("client master api recover server error, code: %d, msg: %s", js.Code, js.Msg)
-										continue
-									}
-
-									cm = &entity.ChangeMembers{
-										PartitionIDs: []entity.PartitionID{failPid},
-										NodeID:       failServer.ID,
-										Method:       proto.ConfRemoveNode,
-									}
-									reqBody, err = vjson.Marshal(cm)
-									if err != nil {
-										log.Error("%v", err)
-										continue
-									}
-									response, err = wj.masterClient.HTTPRequest(wj.ctx, http.MethodPost, "/partitions/change_member?timeout=60000", string(reqBody))
-									if err != nil {
-										log.Error("%v", err)
-										continue
-									}
-									js = &httpResonse.HttpReply{}
-									err = vjson.Unmarshal(response, js)
-									if err != nil {
-										log.Error("%v", err)
-										continue
-									}
-									if js.Code != int(vearchpb.ErrorEnum_SUCCESS) {
-										log.Error("client master api recover server error, code: %d, msg: %s", js.Code, js.Msg)
-										continue
-									}
-
-									recoveredPid = append(recoveredPid, failPid)
+									destZone = ""
 								}
 
-								for _, pid := range recoveredPid {
-									failServer.Node.PartitionIds = removePartitionID(failServer.Node.PartitionIds, pid)
-								}
-
-								err = wj.masterClient.DeleteFailServerByNodeID(wj.ctx, failServer.ID)
-								if err != nil {
-									log.Error("remove failServer %v err: %v", *failServer, err)
+								if destZone == zone {
+									continue
 								}
+							}
 
-								if len(failServer.Node.PartitionIds) > 0 {
-									err = wj.masterClient.PutFailServerByID(wj.ctx, failServer.ID, failServer.Node)
-									errutil.ThrowError(err)
-									log.Info("put failServer %d: %v", failServer.ID, *failServer)
+							bFound := false
+							for _, id := range replicas {
+								if id == server.ID {
+									bFound = true
 								}
 							}
+							if bFound {
+								continue
+							}
+							availableServers = append(availableServers, server)
 						}
-						unlock()
+
+						if len(availableServers) == 0 {
+							log.Error("no available server to recover partition %d", failPid)
+							continue
+						}
+
+						sort.Slice(availableServers, func(i, j int) bool {
+							return len(availableServers[i].PartitionIds) < len(availableServers[j].PartitionIds)
+						})
+
+						if len(availableServers[0].PartitionIds) > 0 {
+							// only use the server which has 0 partition
+							log.Warn("server %d has %d partitions, can not recover partition %d", availableServers[0].ID, len(availableServers[0].PartitionIds), failPid)
+							continue
+						}
+						cm := &entity.ChangeMembers{
+							PartitionIDs: []entity.PartitionID{failPid},
+							NodeID:       availableServers[0].ID,
+							Method:       proto.ConfAddNode,
+						}
+						reqBody, err := vjson.Marshal(cm)
+						if err != nil {
+							log.Error("%v", err)
+							continue
+						}
+						response, err := wj.masterClient.HTTPRequest(wj.ctx, http.MethodPost, "/partitions/change_member?timeout=60000", string(reqBody))
+						if err != nil {
+							log.Error("%s: %v", redact.Payload(reqBody), err)
+							continue
+						}
+						js := &httpResonse.HttpReply{}
+						err = vjson.Unmarshal(response, js)
+						if err != nil {
+							log.Error("%v", err)
+							continue
+						}
+						if js.Code != int(vearchpb.ErrorEnum_SUCCESS) {
+							log.Error("client master api recover server error, code: %d, msg: %s", js.Code, js.Msg)
+							continue
+						}
+
+						cm = &entity.ChangeMembers{
+							PartitionIDs: []entity.PartitionID{failPid},
+							NodeID:       failServer.ID,
+							Method:       proto.ConfRemoveNode,
+						}
+						reqBody, err = vjson.Marshal(cm)
+						if err != nil {
+							log.Error("%v", err)
+							continue
+						}
+						response, err = wj.masterClient.HTTPRequest(wj.ctx, http.MethodPost, "/partitions/change_member?timeout=60000", string(reqBody))
+						if err != nil {
+							log.Error("%v", err)
+							continue
+						}
+						js = &httpResonse.HttpReply{}
+						err = vjson.Unmarshal(response, js)
+						if err != nil {
+							log.Error("%v", err)
+							continue
+						}
+						if js.Code != int(vearchpb.ErrorEnum_SUCCESS) {
+							log.Error("client master api recover server error, code: %d, msg: %s", js.Code, js.Msg)
+							continue
+						}
+
+						recoveredPid = append(recoveredPid, failPid)
+					}
+
+					for _, pid := range recoveredPid {
+						failServer.Node.PartitionIds = removePartitionID(failServer.Node.PartitionIds, pid)
+					}
+
+					err = wj.masterClient.DeleteFailServerByNodeID(wj.ctx, failServer.ID)
+					if err != nil {
+						log.Error("remove failServer %v err: %v", *failServer, err)
+					}
+
+					if len(failServer.Node.PartitionIds) > 0 {
+						err = wj.masterClient.PutFailServerByID(wj.ctx, failServer.ID, failServer.Node)
+						errutil.ThrowError(err)
+						log.Info("put failServer %d: %v", failServer.ID, *failServer)
 					}
 				}
-			}()
-			wj.wg.Wait()
+			}
+			unlock()
 		}
-	}()
+	}
 }
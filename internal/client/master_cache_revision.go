@@ -0,0 +1,65 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+// readRevision returns the store revision before a read, the objects read
+// are at least as new as it, zero if it is unknown
+func (cliCache *clientCache) readRevision(ctx context.Context) int64 {
+	rev, err := cliCache.mc.Revision(ctx)
+	if err != nil {
+		log.Warn("get store revision err: %v", err)
+		return 0
+	}
+	return rev
+}
+
+// casSet sets value to key unless the cache holds or has deleted the key at
+// a newer revision, a zero rev is unknown and only sets a key never seen. The
+// revisions are kept so an out of order watch event or a slow reload never
+// puts an older object back in the cache. A nil value is refused
+func (c *Cache[T]) casSet(key string, value T, rev int64) bool {
+	if c.corrupted(key, value) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cur, ok := c.revs[key]; ok && (rev == 0 || rev < cur) {
+		log.Debug("skip %s cache key [%s] of revision %d older than %d", c.kind, key, rev, cur)
+		return false
+	}
+	c.revs[key] = rev
+	c.items[key] = value
+	return true
+}
+
+// casDelete deletes key unless the cache holds it at a newer revision, the
+// revision is kept so older reloads do not bring the key back
+func (c *Cache[T]) casDelete(key string, rev int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cur, ok := c.revs[key]; ok && rev < cur {
+		log.Debug("skip delete %s cache key [%s] of revision %d older than %d", c.kind, key, rev, cur)
+		return false
+	}
+	c.revs[key] = rev
+	delete(c.items, key)
+	return true
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import "testing"
+
+func TestCache_casSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int64
+		deleted bool
+		rev     int64
+		want    bool
+		value   string
+	}{
+		{name: "Set key never seen", current: -1, rev: 5, want: true, value: "new"},
+		{name: "Set key never seen of unknown revision", current: -1, rev: 0, want: true, value: "new"},
+		{name: "Set key of newer revision", current: 5, rev: 6, want: true, value: "new"},
+		{name: "Set key of equal revision", current: 5, rev: 5, want: true, value: "new"},
+		{name: "Skip stale put", current: 5, rev: 4, want: false, value: "old"},
+		{name: "Skip put of unknown revision", current: 5, rev: 0, want: false, value: "old"},
+		{name: "Skip put older than delete", current: 5, deleted: true, rev: 4, want: false},
+		{name: "Set key newer than delete", current: 5, deleted: true, rev: 6, want: true, value: "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTypedCache[string]("test")
+			if tt.current >= 0 {
+				c.casSet("k", "old", tt.current)
+				if tt.deleted {
+					c.casDelete("k", tt.current)
+				}
+			}
+			if got := c.casSet("k", "new", tt.rev); got != tt.want {
+				t.Errorf("casSet() = %v, want %v", got, tt.want)
+			}
+			value, ok := c.Get("k")
+			if tt.value == "" {
+				if ok {
+					t.Errorf("key should stay deleted, got %v", value)
+				}
+			} else if !ok || value != tt.value {
+				t.Errorf("cached value = %v, want %v", value, tt.value)
+			}
+		})
+	}
+}
+
+func TestCache_casDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int64
+		rev     int64
+		want    bool
+	}{
+		{name: "Delete key of newer revision", current: 5, rev: 6, want: true},
+		{name: "Delete key of equal revision", current: 5, rev: 5, want: true},
+		{name: "Skip stale delete", current: 5, rev: 4, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTypedCache[string]("test")
+			c.casSet("k", "v", tt.current)
+			if got := c.casDelete("k", tt.rev); got != tt.want {
+				t.Errorf("casDelete() = %v, want %v", got, tt.want)
+			}
+			if _, ok := c.Get("k"); ok == tt.want {
+				t.Errorf("key cached = %v after delete %v", ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+// cacheSnapshot is the meta cache of a router persisted to local disk, keyed
+// as in the cache. Users are left out so no password is written to disk or
+// served to peers, they are loaded from etcd on the first request
+type cacheSnapshot struct {
+	Time       time.Time                    `json:"time"`
+	Roles      map[string]*entity.Role      `json:"roles"`
+	Spaces     map[string]*entity.Space     `json:"spaces"`
+	Partitions map[string]*entity.Partition `json:"partitions"`
+	Servers    map[string]*entity.Server    `json:"servers"`
+	Aliases    map[string]*entity.Alias     `json:"aliases"`
+}
+
+// snapshot copies the items of the cache
+func (cliCache *clientCache) snapshot() *cacheSnapshot {
+	return &cacheSnapshot{
+		Time:       time.Now(),
+		Roles:      cliCache.roleCache.Items(),
+		Spaces:     cliCache.spaceCache.Items(),
+		Partitions: cliCache.partitionCache.Items(),
+		Servers:    cliCache.serverCache.Items(),
+		Aliases:    cliCache.aliasCache.Items(),
+	}
+}
+
+// fill adds the items of snapshot the cache has never seen, the items set or
+// deleted by the watch jobs are newer than the snapshot and a key deleted
+// after the snapshot is not brought back
+func (cliCache *clientCache) fill(snapshot *cacheSnapshot) {
+	for k, v := range snapshot.Roles {
+		cliCache.roleCache.casSet(k, v, 0)
+	}
+	spaceCacheLock.Lock()
+	for k, v := range snapshot.Spaces {
+		if cliCache.spaceCache.casSet(k, v, 0) {
+			cliCache.spaceIDCache.Set(cast.ToString(v.Id), v)
+		}
+	}
+	spaceCacheLock.Unlock()
+	for k, v := range snapshot.Partitions {
+		cliCache.partitionCache.casSet(k, v, 0)
+	}
+	for k, v := range snapshot.Servers {
+		cliCache.serverCache.casSet(k, v, 0)
+	}
+	for k, v := range snapshot.Aliases {
+		cliCache.aliasCache.casSet(k, v, 0)
+	}
+}
+
+// SaveSnapshot writes the cache to path, a cache loaded from a snapshot is not
+// saved again
+func (cliCache *clientCache) SaveSnapshot(path string) error {
+	if !cliCache.snapshotTime.IsZero() {
+		return nil
+	}
+	bs, err := vjson.Marshal(cliCache.snapshot())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// rename so a crash never leaves a partial snapshot
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// MarshalSnapshot returns the cache as a snapshot to warm a starting router,
+// a cache loaded from a snapshot file is not served as it may be stale
+func (cliCache *clientCache) MarshalSnapshot() ([]byte, error) {
+	if !cliCache.snapshotTime.IsZero() {
+		return nil, fmt.Errorf("cache is loaded from snapshot of %s", cliCache.snapshotTime.Format(time.RFC3339))
+	}
+	return vjson.Marshal(cliCache.snapshot())
+}
+
+// SnapshotTime returns when the snapshot the cache is loaded from was saved,
+// zero if the cache is watching etcd
+func (cliCache *clientCache) SnapshotTime() time.Time {
+	return cliCache.snapshotTime
+}
+
+// LoadCacheSnapshot replaces the cache by the snapshot at path without
+// watching etcd, FlushCacheJob replaces it once etcd is reachable
+func (m *masterClient) LoadCacheSnapshot(ctx context.Context, path string) (time.Time, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	snapshot := &cacheSnapshot{}
+	if err := vjson.Unmarshal(bs, snapshot); err != nil {
+		return time.Time{}, err
+	}
+
+	_, cancel := context.WithCancel(ctx)
+	cc := &clientCache{
+		mc:             m,
+		cancel:         cancel,
+		snapshotTime:   snapshot.Time,
+		userCache:      newTypedCache[*entity.User]("user"),
+		spaceCache:     newTypedCache[*entity.Space]("space"),
+		spaceIDCache:   newTypedCache[*entity.Space]("space_id"),
+		partitionCache: newTypedCache[*entity.Partition]("partition"),
+		serverCache:    newTypedCache[*entity.Server]("server"),
+		aliasCache:     newTypedCache[*entity.Alias]("alias"),
+		roleCache:      newTypedCache[*entity.Role]("role"),
+		mastersCache:   newTypedCache[config.MasterCfg]("master"),
+		dbNames:        newTypedCache[string]("db_name"),
+	}
+	cc.fill(snapshot)
+	m.swapCache(cc)
+	log.Warn("load meta cache snapshot of %s from %s, spaces: %d, partitions: %d, servers: %d",
+		snapshot.Time.Format(time.RFC3339), path, len(snapshot.Spaces), len(snapshot.Partitions), len(snapshot.Servers))
+	return snapshot.Time, nil
+}
+
+// FlushCacheJobFromPeer resets the cache as FlushCacheJob, but fills it with
+// the snapshot got by fetch from a warmed router instead of scanning etcd.
+// fetch is called after the watch jobs start, so the changes after the peer
+// snapshot are got by watch
+func (m *masterClient) FlushCacheJobFromPeer(ctx context.Context, fetch func() ([]byte, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	cc := &clientCache{
+		mc:             m,
+		cancel:         cancel,
+		userCache:      newTypedCache[*entity.User]("user"),
+		spaceCache:     newTypedCache[*entity.Space]("space"),
+		spaceIDCache:   newTypedCache[*entity.Space]("space_id"),
+		partitionCache: newTypedCache[*entity.Partition]("partition"),
+		serverCache:    newTypedCache[*entity.Server]("server"),
+		aliasCache:     newTypedCache[*entity.Alias]("alias"),
+		roleCache:      newTypedCache[*entity.Role]("role"),
+		mastersCache:   newTypedCache[config.MasterCfg]("master"),
+		dbNames:        newTypedCache[string]("db_name"),
+	}
+	if err := cc.startCacheJob(ctx, false); err != nil {
+		cancel()
+		return err
+	}
+	bs, err := fetch()
+	if err != nil {
+		cc.stopCacheJob()
+		return err
+	}
+	snapshot := &cacheSnapshot{}
+	if err := vjson.Unmarshal(bs, snapshot); err != nil {
+		cc.stopCacheJob()
+		return err
+	}
+	cc.fill(snapshot)
+	m.swapCache(cc)
+	log.Info("warm meta cache from peer snapshot of %s, spaces: %d, partitions: %d, servers: %d",
+		snapshot.Time.Format(time.RFC3339), len(snapshot.Spaces), len(snapshot.Partitions), len(snapshot.Servers))
+	return nil
+}
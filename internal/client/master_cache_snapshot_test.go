@@ -0,0 +1,120 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+func TestClientCache_SaveSnapshot(t *testing.T) {
+	newCache := func() *clientCache {
+		return &clientCache{
+			userCache:      newTypedCache[*entity.User]("user"),
+			spaceCache:     newTypedCache[*entity.Space]("space"),
+			spaceIDCache:   newTypedCache[*entity.Space]("space_id"),
+			partitionCache: newTypedCache[*entity.Partition]("partition"),
+			serverCache:    newTypedCache[*entity.Server]("server"),
+			aliasCache:     newTypedCache[*entity.Alias]("alias"),
+			roleCache:      newTypedCache[*entity.Role]("role"),
+		}
+	}
+	password, roleName := "secret", "reader"
+	cc := newCache()
+	cc.userCache.Set("u", &entity.User{Name: "u", Password: &password, RoleName: &roleName})
+	cc.spaceCache.Set("db/s", &entity.Space{Id: 1, Name: "s"})
+
+	path := filepath.Join(t.TempDir(), "snapshot", "cache.json")
+	if err := cc.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), password) {
+		t.Errorf("snapshot has the password of users: %s", bs)
+	}
+	for _, p := range []struct {
+		path string
+		perm os.FileMode
+	}{{path, 0600}, {filepath.Dir(path), 0700}} {
+		info, err := os.Stat(p.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm&^p.perm != 0 {
+			t.Errorf("%s permission = %o, want at most %o", p.path, perm, p.perm)
+		}
+	}
+
+	snapshot := &cacheSnapshot{}
+	if err := vjson.Unmarshal(bs, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	loaded := newCache()
+	loaded.fill(snapshot)
+	if _, ok := loaded.spaceCache.Get("db/s"); !ok {
+		t.Error("space not filled from snapshot")
+	}
+	if _, ok := loaded.userCache.Get("u"); ok {
+		t.Error("user filled from snapshot")
+	}
+}
+
+func TestClientCache_FillAfterWatch(t *testing.T) {
+	cc := &clientCache{
+		spaceCache:     newTypedCache[*entity.Space]("space"),
+		spaceIDCache:   newTypedCache[*entity.Space]("space_id"),
+		partitionCache: newTypedCache[*entity.Partition]("partition"),
+		serverCache:    newTypedCache[*entity.Server]("server"),
+		aliasCache:     newTypedCache[*entity.Alias]("alias"),
+		roleCache:      newTypedCache[*entity.Role]("role"),
+	}
+	// the watch jobs delete a space and update another before the peer
+	// snapshot arrives
+	cc.spaceCache.casSet("db/gone", &entity.Space{Id: 1, Name: "gone"}, 3)
+	cc.spaceCache.casDelete("db/gone", 5)
+	cc.spaceCache.casSet("db/new", &entity.Space{Id: 2, Name: "new", PartitionNum: 2}, 6)
+
+	cc.fill(&cacheSnapshot{Spaces: map[string]*entity.Space{
+		"db/gone":  {Id: 1, Name: "gone"},
+		"db/new":   {Id: 2, Name: "new", PartitionNum: 1},
+		"db/other": {Id: 3, Name: "other"},
+		// a null of a corrupted snapshot file
+		"db/null": nil,
+	}})
+
+	if _, ok := cc.spaceCache.Get("db/gone"); ok {
+		t.Error("space deleted by watch brought back by snapshot")
+	}
+	if _, ok := cc.spaceIDCache.Get("1"); ok {
+		t.Error("id of space deleted by watch brought back by snapshot")
+	}
+	if v, ok := cc.spaceCache.Get("db/new"); !ok || v.PartitionNum != 2 {
+		t.Errorf("space updated by watch replaced by snapshot: %v", v)
+	}
+	if _, ok := cc.spaceCache.Get("db/other"); !ok {
+		t.Error("space not filled from snapshot")
+	}
+	if _, ok := cc.spaceCache.Get("db/null"); ok {
+		t.Error("nil space filled from snapshot")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// PartitionReresolves counts the requests a ps answered it has no longer
+// the partition, sent again after the partition is loaded from the master,
+// registered by the monitor of the router
+var PartitionReresolves = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "vearch_router_partition_reresolves_total",
+	Help: "requests sent again after the partition moved by result",
+}, []string{"space", "result"})
+
+// partitionMoved is whether reply says the ps no longer has the partition,
+// it was moved or deleted and the watch did not update the cache yet
+func partitionMoved(reply *vearchpb.PartitionData) bool {
+	return reply != nil && reply.Err != nil && reply.Err.Code == vearchpb.ErrorEnum_PARTITION_NOT_EXIST
+}
+
+// reresolvePartition drops the cached space and partition of r for the ones
+// of the master, a partition or space the master no longer has is deleted
+// from the cache so the next requests are routed by the current space
+func (r *routerRequest) reresolvePartition(ctx context.Context, pid entity.PartitionID) (*entity.Partition, error) {
+	cache := r.client.Master().Cache()
+	rev := cache.readRevision(ctx)
+	if err := cache.reloadSpaceCache(ctx, true, r.head.DbName, r.space.Name); err != nil {
+		log.Warn("reload space [%s] of moved partition [%d] err: %v", r.space.Name, pid, err)
+		spaceCacheLock.Lock()
+		cache.spaceCache.casDelete(cacheSpaceKey(r.head.DbName, r.space.Name), rev)
+		spaceCacheLock.Unlock()
+	}
+	key := cachePartitionKey(r.space.Name, pid)
+	if err := cache.reloadPartitionCache(ctx, true, r.space.Name, pid); err != nil {
+		cache.partitionCache.casDelete(key, rev)
+		return nil, err
+	}
+	partition, ok := cache.partitionCache.Get(key)
+	if !ok {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_EXIST, fmt.Errorf("space:[%s] partition_id:[%d]", r.space.Name, pid))
+	}
+	return partition, nil
+}
+
+// countReresolve counts a request sent again after its partition moved by
+// whether the ps answered it
+func (r *routerRequest) countReresolve(reply *vearchpb.PartitionData, err error) {
+	result := RedirectOK
+	if err != nil || partitionMoved(reply) {
+		result = RedirectFailed
+	}
+	PartitionReresolves.WithLabelValues(r.space.Name, result).Inc()
+}
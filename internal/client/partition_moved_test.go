@@ -0,0 +1,58 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestPartitionMoved(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply *vearchpb.PartitionData
+		want  bool
+	}{
+		{
+			name:  "Nil reply",
+			reply: nil,
+		},
+		{
+			name:  "No error",
+			reply: &vearchpb.PartitionData{},
+		},
+		{
+			name:  "Success",
+			reply: &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}},
+		},
+		{
+			name:  "Not leader",
+			reply: &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NOT_LEADER}},
+		},
+		{
+			name:  "Partition not exist",
+			reply: &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NOT_EXIST, Msg: "partition not found"}},
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partitionMoved(tt.reply); got != tt.want {
+				t.Errorf("partitionMoved() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
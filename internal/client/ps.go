@@ -23,6 +23,7 @@ import (
 	"github.com/patrickmn/go-cache"
 	"github.com/spf13/cast"
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fault"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	server "github.com/vearch/vearch/v3/internal/pkg/server/rpc"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
@@ -48,6 +49,8 @@ const (
 const (
 	HandlerType  = "type"
 	UnaryHandler = "UnaryHandler"
+	// ReadReplica lets a follower answer the gets of the request
+	ReadReplica = "read_replica"
 
 	SearchHandler        = "SearchHandler"
 	QueryHandler         = "QueryHandler"
@@ -63,6 +66,9 @@ const (
 	RebuildIndexHandler           = "RebuildIndexHandler"
 	FlushHandler                  = "FlushHandler"
 	BackupHandler                 = "BackupHandler"
+	IndexImportHandler            = "IndexImportHandler"
+	SnapshotHandler               = "SnapshotHandler"
+	SpaceCopyHandler              = "SpaceCopyHandler"
 	ResourceLimitHandler          = "ResourceLimitHandler"
 
 	CreatePartitionHandler = "CreatePartitionHandler"
@@ -72,8 +78,14 @@ const (
 	StatsHandler           = "StatsHandler"
 	IsLiveHandler          = "IsLiveHandler"
 	PartitionInfoHandler   = "PartitionInfoHandler"
+	PartitionStatsHandler  = "PartitionStatsHandler"
+	TryToLeaderHandler     = "TryToLeaderHandler"
+	SimilarityHandler      = "SimilarityHandler"
+	ChecksumHandler        = "ChecksumHandler"
 	ChangeMemberHandler    = "ChangeMemberHandler"
+	RebuildReplicaHandler  = "RebuildReplicaHandler"
 	EngineCfgHandler       = "EngineCfgHandler"
+	IndexStatsHandler      = "IndexStatsHandler"
 )
 
 type psClient struct {
@@ -87,29 +99,29 @@ func (ps *psClient) Client() *Client {
 
 // when psclient stop, it will remove all client
 func (ps *psClient) Stop() {
-	ps.Client().Master().cliCache.Range(func(key, value interface{}) bool {
+	ps.Client().Master().Cache().Range(func(key, value interface{}) bool {
 		value.(*rpcClient).close()
-		ps.Client().Master().cliCache.Delete(key)
+		ps.Client().Master().Cache().Delete(key)
 		return true
 	})
 }
 
 func (ps *psClient) GetOrCreateRPCClient(ctx context.Context, nodeID entity.NodeID) *rpcClient {
-	value, ok := ps.Client().Master().cliCache.Load(nodeID)
+	value, ok := ps.Client().Master().Cache().Load(nodeID)
 	if ok {
 		return value.(*rpcClient).lastUse()
 	}
 
-	ps.Client().Master().cliCache.lock.Lock()
-	defer ps.Client().Master().cliCache.lock.Unlock()
+	ps.Client().Master().Cache().lock.Lock()
+	defer ps.Client().Master().Cache().lock.Unlock()
 
-	value, ok = ps.Client().Master().cliCache.Load(nodeID)
+	value, ok = ps.Client().Master().Cache().Load(nodeID)
 	if ok {
 		return value.(*rpcClient).lastUse()
 	}
 
 	log.Info("psClient not in psClientCache, make new psClient, nodeID:[%d]", nodeID)
-	psServer, err := ps.Client().Master().cliCache.ServerByCache(ctx, nodeID)
+	psServer, err := ps.Client().Master().Cache().ServerByCache(ctx, nodeID)
 	if err != nil {
 		log.Error("Master().ServerByCache() err, can not get ps server from master, err: %s", err.Error())
 		return nilClient
@@ -123,7 +135,7 @@ func (ps *psClient) GetOrCreateRPCClient(ctx context.Context, nodeID entity.Node
 
 	if client != nil {
 		c := &rpcClient{client: client, useTime: time.Now().UnixNano()}
-		ps.Client().Master().cliCache.Store(nodeID, c)
+		ps.Client().Master().Cache().Store(nodeID, c)
 		return c.lastUse()
 	}
 
@@ -169,6 +181,9 @@ func (r *rpcClient) Execute(ctx context.Context, servicePath string, args interf
 	if r == nilClient {
 		return vearchpb.NewError(vearchpb.ErrorEnum_CREATE_RPCCLIENT_FAILED, nil)
 	}
+	if err := fault.Eval(fault.PSRPC, servicePath); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CALL_RPCCLIENT_FAILED, err)
+	}
 	return r.client.Execute(ctx, servicePath, args, reply)
 }
 
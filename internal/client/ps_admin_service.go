@@ -15,6 +15,7 @@
 package client
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/vearch/vearch/v3/internal/entity"
@@ -106,6 +107,91 @@ func BackupSpace(addr string, backup *entity.BackupSpace, pid entity.PartitionID
 	return nil
 }
 
+// ImportIndex starts the import of an index built offline into the replica
+// of partition on addr, or returns the status of the import
+func ImportIndex(addr string, indexImport *entity.IndexImport, pid entity.PartitionID) (*entity.IndexImportStatus, error) {
+	value, err := vjson.Marshal(indexImport)
+	if err != nil {
+		return nil, err
+	}
+
+	args := &vearchpb.PartitionData{PartitionID: pid, Data: value, Type: vearchpb.OpType_CREATE}
+	reply := new(vearchpb.PartitionData)
+	err = Execute(addr, IndexImportHandler, args, reply)
+	if err != nil {
+		return nil, err
+	} else if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewErrorInfo(reply.Err.Code, reply.Err.Msg)
+	}
+	status := &entity.IndexImportStatus{}
+	if err := vjson.Unmarshal(reply.Data, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// CopyPartition starts pulling the snapshot of a partition of another
+// cluster into the replica of partition on addr, or returns the status of
+// the copy
+func CopyPartition(addr string, spaceCopy *entity.SpaceCopy, pid entity.PartitionID) (*entity.SpaceCopyStatus, error) {
+	value, err := vjson.Marshal(spaceCopy)
+	if err != nil {
+		return nil, err
+	}
+	args := &vearchpb.PartitionData{PartitionID: pid, Data: value}
+	reply := new(vearchpb.PartitionData)
+	if err := Execute(addr, SpaceCopyHandler, args, reply); err != nil {
+		return nil, err
+	} else if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewErrorInfo(reply.Err.Code, reply.Err.Msg)
+	}
+	status := &entity.SpaceCopyStatus{}
+	if err := vjson.Unmarshal(reply.Data, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// OpenSnapshot keeps a snapshot of the engine files of partition on addr
+// for copies and returns its files
+func OpenSnapshot(addr string, pid entity.PartitionID) (*entity.SnapshotManifest, error) {
+	data, err := snapshot(addr, pid, &entity.SnapshotRequest{Command: entity.SnapshotCommandOpen})
+	if err != nil {
+		return nil, err
+	}
+	manifest := &entity.SnapshotManifest{}
+	if err := vjson.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadSnapshot reads at most size bytes of a file of a snapshot from offset,
+// the bytes are compressed by compression if it is set
+func ReadSnapshot(addr string, pid entity.PartitionID, session, file string, offset int64, size int, compression string) ([]byte, error) {
+	return snapshot(addr, pid, &entity.SnapshotRequest{Command: entity.SnapshotCommandRead, Session: session, File: file, Offset: offset, Size: size, Compression: compression})
+}
+
+func ReleaseSnapshot(addr string, pid entity.PartitionID, session string) error {
+	_, err := snapshot(addr, pid, &entity.SnapshotRequest{Command: entity.SnapshotCommandRelease, Session: session})
+	return err
+}
+
+func snapshot(addr string, pid entity.PartitionID, req *entity.SnapshotRequest) ([]byte, error) {
+	value, err := vjson.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	args := &vearchpb.PartitionData{PartitionID: pid, Data: value}
+	reply := new(vearchpb.PartitionData)
+	if err := Execute(addr, SnapshotHandler, args, reply); err != nil {
+		return nil, err
+	} else if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewErrorInfo(reply.Err.Code, reply.Err.Msg)
+	}
+	return reply.Data, nil
+}
+
 func ResourceLimit(addr string, resource *entity.ResourceLimit, pid entity.PartitionID) error {
 	value, err := vjson.Marshal(resource)
 	if err != nil {
@@ -214,6 +300,118 @@ func _partitionsInfo(addr string, pid entity.PartitionID, detail_info bool) (val
 	return value, nil
 }
 
+// IndexStats gets the sampled statistics of the scalar fields of a partition
+// from server, nil if it has not sampled them yet
+func IndexStats(addr string, pid entity.PartitionID) (*entity.IndexStats, error) {
+	args := &vearchpb.PartitionData{PartitionID: pid}
+	reply := new(vearchpb.PartitionData)
+	if err := Execute(addr, IndexStatsHandler, args, reply); err != nil {
+		return nil, err
+	}
+	if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(reply.Err.Code, errors.New(reply.Err.Msg))
+	}
+	if len(reply.Data) == 0 {
+		return nil, nil
+	}
+	stats := &entity.IndexStats{}
+	if err := vjson.Unmarshal(reply.Data, stats); err != nil {
+		log.Error("Unmarshal index stats failed, err: [%v]", err)
+		return nil, err
+	}
+	return stats, nil
+}
+
+// PartitionStats get the request statistics of a partition from server
+func PartitionStats(addr string, pid entity.PartitionID) (*entity.PartitionStats, error) {
+	args := &vearchpb.PartitionData{PartitionID: pid}
+	reply := new(vearchpb.PartitionData)
+	if err := Execute(addr, PartitionStatsHandler, args, reply); err != nil {
+		return nil, err
+	}
+	if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(reply.Err.Code, errors.New(reply.Err.Msg))
+	}
+	stats := &entity.PartitionStats{}
+	if err := vjson.Unmarshal(reply.Data, stats); err != nil {
+		log.Error("Unmarshal partition stats failed, err: [%v]", err)
+		return nil, err
+	}
+	return stats, nil
+}
+
+// TryToLeader makes the replica on server the leader of partition
+func TryToLeader(addr string, pid entity.PartitionID) error {
+	args := &vearchpb.PartitionData{PartitionID: pid}
+	reply := new(vearchpb.PartitionData)
+	if err := Execute(addr, TryToLeaderHandler, args, reply); err != nil {
+		return err
+	}
+	if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return vearchpb.NewError(reply.Err.Code, errors.New(reply.Err.Msg))
+	}
+	return nil
+}
+
+// RebuildReplica recovers the quarantined replica of partition on server
+// from the other replicas
+func RebuildReplica(addr string, pid entity.PartitionID) error {
+	args := &vearchpb.PartitionData{PartitionID: pid}
+	reply := new(vearchpb.PartitionData)
+	if err := Execute(addr, RebuildReplicaHandler, args, reply); err != nil {
+		return err
+	}
+	if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return vearchpb.NewError(reply.Err.Code, errors.New(reply.Err.Msg))
+	}
+	return nil
+}
+
+// Similarity computes the pairwise similarity of the vectors of req on server
+func Similarity(addr string, req *entity.SimilarityRequest) (*entity.SimilarityResult, error) {
+	data, err := vjson.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	args := &vearchpb.PartitionData{Data: data}
+	reply := new(vearchpb.PartitionData)
+	if err := Execute(addr, SimilarityHandler, args, reply); err != nil {
+		return nil, err
+	}
+	if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(reply.Err.Code, errors.New(reply.Err.Msg))
+	}
+	result := &entity.SimilarityResult{}
+	if err := vjson.Unmarshal(reply.Data, result); err != nil {
+		log.Error("Unmarshal similarity result failed, err: [%v]", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// Checksum returns the checksum of the replica on server at the raft index,
+// the leader proposes a checksum entry if index is 0
+func Checksum(addr string, pid entity.PartitionID, index uint64) (*entity.ReplicaChecksum, error) {
+	data, err := vjson.Marshal(&entity.ReplicaChecksum{PartitionID: pid, Index: index})
+	if err != nil {
+		return nil, err
+	}
+	args := &vearchpb.PartitionData{PartitionID: pid, Data: data}
+	reply := new(vearchpb.PartitionData)
+	if err := Execute(addr, ChecksumHandler, args, reply); err != nil {
+		return nil, err
+	}
+	if reply.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(reply.Err.Code, errors.New(reply.Err.Msg))
+	}
+	checksum := &entity.ReplicaChecksum{}
+	if err := vjson.Unmarshal(reply.Data, checksum); err != nil {
+		log.Error("Unmarshal checksum failed, err: [%v]", err)
+		return nil, err
+	}
+	return checksum, nil
+}
+
 func ChangeMember(addr string, changeMember *entity.ChangeMember) error {
 	value, err := vjson.Marshal(changeMember)
 	if err != nil {
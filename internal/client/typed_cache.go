@@ -0,0 +1,103 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+// CacheCorruptions counts the nil values refused by the meta caches by kind of
+// meta, registered by the monitor of the router
+var CacheCorruptions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "vearch_router_cache_corruptions_total",
+	Help: "nil meta values refused by the cache by kind",
+}, []string{"kind"})
+
+// Cache is a concurrent safe meta cache of values of type T. A nil value, as
+// decoded from a null of a snapshot, is never cached: it is counted as a
+// corruption and refused, so the key is still not found and the caller
+// reloads it from the master instead of dereferencing nil
+type Cache[T any] struct {
+	kind string
+	// mu orders the sets and the deletes, with or without revision
+	mu    sync.RWMutex
+	items map[string]T
+	// revs is the etcd revision of every cached key and of the deleted ones
+	revs map[string]int64
+}
+
+func newTypedCache[T any](kind string) *Cache[T] {
+	return &Cache[T]{
+		kind:  kind,
+		items: make(map[string]T),
+		revs:  make(map[string]int64),
+	}
+}
+
+// Get returns the value of key, false if it is not cached
+func (c *Cache[T]) Get(key string) (T, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, found := c.items[key]
+	return v, found
+}
+
+// Set sets value to key whatever its revision, a nil value is refused
+func (c *Cache[T]) Set(key string, value T) {
+	if c.corrupted(key, value) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = value
+}
+
+// Delete deletes key whatever its revision
+func (c *Cache[T]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+// Items returns a copy of the cached values by key
+func (c *Cache[T]) Items() map[string]T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	items := make(map[string]T, len(c.items))
+	for k, v := range c.items {
+		items[k] = v
+	}
+	return items
+}
+
+// corrupted reports if value is nil, counting and logging it
+func (c *Cache[T]) corrupted(key string, value T) bool {
+	v := reflect.ValueOf(&value).Elem()
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		if !v.IsNil() {
+			return false
+		}
+	default:
+		return false
+	}
+	CacheCorruptions.WithLabelValues(c.kind).Inc()
+	log.Error("refuse nil %s cache key [%s]", c.kind, key)
+	return true
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func corruptions(t *testing.T, kind string) float64 {
+	m := &dto.Metric{}
+	if err := CacheCorruptions.WithLabelValues(kind).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestCache_Set(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     *entity.Space
+		rev       int64
+		want      bool
+		corrupted bool
+	}{
+		{name: "Set value", value: &entity.Space{Id: 1}, want: true},
+		{name: "Set value by revision", value: &entity.Space{Id: 1}, rev: 3, want: true},
+		{name: "Refuse nil value", corrupted: true},
+		{name: "Refuse nil value by revision", rev: 3, corrupted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind := "set_" + tt.name
+			c := newTypedCache[*entity.Space](kind)
+			if tt.rev > 0 {
+				if got := c.casSet("k", tt.value, tt.rev); got != tt.want {
+					t.Errorf("casSet() = %v, want %v", got, tt.want)
+				}
+			} else {
+				c.Set("k", tt.value)
+			}
+			got, ok := c.Get("k")
+			if ok != tt.want {
+				t.Fatalf("Get() found = %v, want %v", ok, tt.want)
+			}
+			if ok && got.Id != 1 {
+				t.Errorf("Get() = %v", got)
+			}
+			if n := corruptions(t, kind); (n == 1) != tt.corrupted {
+				t.Errorf("corruptions = %v, corrupted %v", n, tt.corrupted)
+			}
+		})
+	}
+}
+
+func TestCache_NilValueKeepsCached(t *testing.T) {
+	c := newTypedCache[*entity.Alias]("nil_keeps")
+	c.casSet("a", &entity.Alias{Name: "a"}, 3)
+	if c.casSet("a", nil, 4) {
+		t.Error("casSet() of a nil value = true")
+	}
+	if got, ok := c.Get("a"); !ok || got.Name != "a" {
+		t.Errorf("Get() = %v, %v, want the cached value", got, ok)
+	}
+	// the revision of the refused value is not kept
+	if !c.casSet("a", &entity.Alias{Name: "b"}, 3) {
+		t.Error("casSet() of the cached revision = false")
+	}
+}
+
+func TestCache_Items(t *testing.T) {
+	c := newTypedCache[*entity.Server]("items")
+	c.Set("1", &entity.Server{ID: 1})
+	c.Set("2", &entity.Server{ID: 2})
+	c.Set("3", nil)
+
+	items := c.Items()
+	if len(items) != 2 || items["1"].ID != 1 || items["2"].ID != 2 {
+		t.Errorf("Items() = %v", items)
+	}
+	// the copy is not the cache
+	delete(items, "1")
+	if _, ok := c.Get("1"); !ok {
+		t.Error("Items() returned the cache itself")
+	}
+	if n := corruptions(t, "items"); n != 1 {
+		t.Errorf("corruptions = %v, want 1", n)
+	}
+}
+
+// TestCache_WatchReloadRace races the in order events of a watch with slow
+// reloads of older revisions and readers, the cache ends at the last event
+func TestCache_WatchReloadRace(t *testing.T) {
+	const keys, events, reloads = 8, 200, 4
+	c := newTypedCache[*entity.Partition]("race")
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for k := 0; k < keys; k++ {
+		key := fmt.Sprint(k)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rev := int64(1); rev <= events; rev++ {
+				if rev%10 == 0 {
+					c.casDelete(key, rev)
+				} else {
+					c.casSet(key, &entity.Partition{UpdateTime: rev}, rev)
+				}
+			}
+			c.casSet(key, &entity.Partition{UpdateTime: events + 1}, events+1)
+		}()
+		for r := 0; r < reloads; r++ {
+			wg.Add(1)
+			go func(r int) {
+				defer wg.Done()
+				for rev := int64(r); rev <= events; rev += reloads {
+					c.casSet(key, &entity.Partition{UpdateTime: rev}, rev)
+				}
+			}(r)
+		}
+	}
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for k := 0; k < keys; k++ {
+				if p, ok := c.Get(fmt.Sprint(k)); ok && p == nil {
+					t.Error("Get() found a nil partition")
+					return
+				}
+			}
+			c.Items()
+		}
+	}()
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			c.Set("plain", &entity.Partition{})
+			c.Delete("plain")
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	for k := 0; k < keys; k++ {
+		p, ok := c.Get(fmt.Sprint(k))
+		if !ok || p.UpdateTime != events+1 {
+			t.Errorf("key %d = %v, %v, want the last event", k, p, ok)
+		}
+	}
+	if n := corruptions(t, "race"); n != 0 {
+		t.Errorf("corruptions = %v, want 0", n)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// watchMuxQueue is the number of events a job may fall behind its watch
+// before the watch waits for it
+const watchMuxQueue = 1024
+
+// watchMuxBatch caps the events a job takes off its queue at once, the
+// events of a batch are coalesced by key
+const watchMuxBatch = 256
+
+// watchMux watches the key ranges of the watcher jobs of a cache, one etcd
+// watch for each prefix not under another job prefix, and dispatches the
+// events to the job of the longest matching prefix. Each job applies its
+// events in order on its own goroutine, so a slow job does not hold back the
+// others. The watchers outside the cache, as the feature flags of router,
+// watch on their own
+type watchMux struct {
+	ctx          context.Context
+	masterClient *masterClient
+	mu           sync.RWMutex
+	jobs         []*muxJob
+}
+
+type muxJob struct {
+	*watcherJob
+	events chan *clientv3.Event
+}
+
+func newWatchMux(ctx context.Context, masterClient *masterClient) *watchMux {
+	return &watchMux{ctx: ctx, masterClient: masterClient}
+}
+
+// add registers the job, all jobs should be added before start as the events
+// of a prefix before its job is added are not dispatched
+func (m *watchMux) add(job *watcherJob) {
+	m.mu.Lock()
+	m.jobs = append(m.jobs, &muxJob{watcherJob: job, events: make(chan *clientv3.Event, watchMuxQueue)})
+	m.mu.Unlock()
+}
+
+// ranges returns the prefixes to watch, a prefix under another one is
+// watched by it
+func (m *watchMux) ranges() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	prefixes := make([]string, 0, len(m.jobs))
+	seen := make(map[string]bool, len(m.jobs))
+	for _, job := range m.jobs {
+		if seen[job.prefix] {
+			continue
+		}
+		seen[job.prefix] = true
+		covered := false
+		for _, other := range m.jobs {
+			if other.prefix != job.prefix && strings.HasPrefix(job.prefix, other.prefix) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			prefixes = append(prefixes, job.prefix)
+		}
+	}
+	return prefixes
+}
+
+// start opens the watches of the jobs added and returns once they are
+// opened, so the changes after start are all dispatched. The watches are
+// opened again until the ctx of mux is done
+func (m *watchMux) start() {
+	m.mu.RLock()
+	for _, job := range m.jobs {
+		go m.run(job)
+	}
+	m.mu.RUnlock()
+	for _, prefix := range m.ranges() {
+		watcher, err := m.masterClient.WatchPrefix(m.ctx, prefix)
+		if err != nil {
+			log.Error("watch prefix:[%s] err: %v", prefix, err)
+		}
+		go m.watchRange(prefix, watcher)
+	}
+}
+
+// run applies the events of job in order until the ctx of mux is done. The
+// events queued while the job was busy are applied as a batch, so a burst of
+// changes of the same keys, as a mass rebalance, is applied once per key
+func (m *watchMux) run(job *muxJob) {
+	batch := make([]*clientv3.Event, 0, watchMuxBatch)
+	for {
+		select {
+		case <-m.ctx.Done():
+			log.Debug("watch mux job to stop %s", job.prefix)
+			return
+		case event := <-job.events:
+			batch = append(batch[:0], event)
+		}
+	drain:
+		for len(batch) < watchMuxBatch {
+			select {
+			case event := <-job.events:
+				batch = append(batch, event)
+			default:
+				break drain
+			}
+		}
+		events := coalesceEvents(batch)
+		if len(events) < len(batch) {
+			log.Debug("watch mux job %s coalesced %d events to %d", job.prefix, len(batch), len(events))
+		}
+		for _, event := range events {
+			m.apply(job, event)
+		}
+	}
+}
+
+// coalesceEvents keeps the last event of each key of events in place, in the
+// order of the events kept. Only the newest state of a key is applied, the
+// older ones would be overwritten by it anyway
+func coalesceEvents(events []*clientv3.Event) []*clientv3.Event {
+	if len(events) < 2 {
+		return events
+	}
+	last := make(map[string]int, len(events))
+	for i, event := range events {
+		last[string(event.Kv.Key)] = i
+	}
+	if len(last) == len(events) {
+		return events
+	}
+	kept := events[:0]
+	for i, event := range events {
+		if last[string(event.Kv.Key)] == i {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+func (m *watchMux) apply(job *muxJob, event *clientv3.Event) {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			log.Error("recover() err:[%v]", rErr)
+			log.Error("stack:[%s]", debug.Stack())
+		}
+	}()
+	job.apply(event)
+}
+
+// watchRange dispatches the events of prefix, watcher is the watch opened by
+// start and nil if it failed
+func (m *watchMux) watchRange(prefix string, watcher clientv3.WatchChan) {
+	for {
+		if watcher != nil {
+			m.dispatchAll(watcher)
+		}
+		select {
+		case <-m.ctx.Done():
+			log.Debug("watch mux to stop %s", prefix)
+			return
+		case <-time.After(time.Second):
+		}
+		var err error
+		if watcher, err = m.masterClient.WatchPrefix(m.ctx, prefix); err != nil {
+			log.Error("watch prefix:[%s] err: %v", prefix, err)
+			watcher = nil
+		}
+	}
+}
+
+func (m *watchMux) dispatchAll(watcher clientv3.WatchChan) {
+	for reps := range watcher {
+		if reps.Canceled {
+			log.Error("chan is closed by watch mux, err: %v", reps.Err())
+			return
+		}
+		for _, event := range reps.Events {
+			m.dispatch(event)
+		}
+	}
+}
+
+// dispatch queues the event to the job of the longest prefix of its key, it
+// waits while the queue of the job is full
+func (m *watchMux) dispatch(event *clientv3.Event) {
+	job := m.route(string(event.Kv.Key))
+	if job == nil {
+		return
+	}
+	select {
+	case job.events <- event:
+	case <-m.ctx.Done():
+	}
+}
+
+func (m *watchMux) route(key string) *muxJob {
+	var job *muxJob
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, j := range m.jobs {
+		if strings.HasPrefix(key, j.prefix) && (job == nil || len(j.prefix) > len(job.prefix)) {
+			job = j
+		}
+	}
+	return job
+}
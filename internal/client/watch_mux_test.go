@@ -0,0 +1,218 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// recordJob returns a watcher job of prefix sending the keys of its put
+// events to keys, after wait is closed if it is not nil
+func recordJob(ctx context.Context, prefix string, keys chan<- string, wait <-chan struct{}) *watcherJob {
+	return &watcherJob{ctx: ctx, prefix: prefix,
+		put: func(value []byte, rev int64) error {
+			if wait != nil {
+				<-wait
+			}
+			keys <- string(value)
+			return nil
+		},
+		delete: func(key string, rev int64) error { return nil },
+	}
+}
+
+func receive(t *testing.T, keys <-chan string, n int) []string {
+	got := make([]string, 0, n)
+	for len(got) < n {
+		select {
+		case key := <-keys:
+			got = append(got, key)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %v, want %d keys", got, n)
+		}
+	}
+	select {
+	case key := <-keys:
+		t.Errorf("unexpected key %s after %v", key, got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return got
+}
+
+func TestWatchMuxRoute(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	memStore := store.NewMemStore()
+	cli, err := NewClientWithStore(nil, memStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := newWatchMux(ctx, cli.Master())
+	keys := map[string]chan string{"/t/a/": make(chan string, 8), "/t/a/b/": make(chan string, 8), "/t/c/": make(chan string, 8)}
+	for prefix, ch := range keys {
+		mux.add(recordJob(ctx, prefix, ch, nil))
+	}
+
+	ranges := mux.ranges()
+	sort.Strings(ranges)
+	if want := []string{"/t/a/", "/t/c/"}; !reflect.DeepEqual(ranges, want) {
+		t.Errorf("ranges() = %v, want %v", ranges, want)
+	}
+
+	mux.start()
+	for _, key := range []string{"/t/a/x", "/t/a/b/y", "/t/c/z", "/t/d/w", "/t/a/b"} {
+		if err := memStore.Put(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "/t/a/", want: []string{"/t/a/x", "/t/a/b"}},
+		{prefix: "/t/a/b/", want: []string{"/t/a/b/y"}},
+		{prefix: "/t/c/", want: []string{"/t/c/z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			if got := receive(t, keys[tt.prefix], len(tt.want)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("job %s got %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchMuxStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	memStore := store.NewMemStore()
+	cli, err := NewClientWithStore(nil, memStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := newWatchMux(ctx, cli.Master())
+	slowKeys, fastKeys := make(chan string, 8), make(chan string, 8)
+	release := make(chan struct{})
+	mux.add(recordJob(ctx, "/t/slow/", slowKeys, release))
+	mux.add(recordJob(ctx, "/t/fast/", fastKeys, nil))
+
+	// the changes right after start are not lost, as the ones after the
+	// scans of a cache
+	mux.start()
+	for _, key := range []string{"/t/slow/1", "/t/slow/2", "/t/fast/1", "/t/slow/3"} {
+		if err := memStore.Put(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a slow job does not hold back the others
+	if got := receive(t, fastKeys, 1); got[0] != "/t/fast/1" {
+		t.Errorf("fast job got %v", got)
+	}
+	close(release)
+	if got, want := receive(t, slowKeys, 3), []string{"/t/slow/1", "/t/slow/2", "/t/slow/3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("slow job got %v, want %v in order", got, want)
+	}
+}
+
+func TestCoalesceEvents(t *testing.T) {
+	event := func(typ mvccpb.Event_EventType, key string, rev int64) *clientv3.Event {
+		return &clientv3.Event{Type: typ, Kv: &mvccpb.KeyValue{Key: []byte(key), ModRevision: rev}}
+	}
+	tests := []struct {
+		name   string
+		events []*clientv3.Event
+		want   []int64
+	}{
+		{
+			name: "No events",
+		},
+		{
+			name:   "Distinct keys",
+			events: []*clientv3.Event{event(mvccpb.PUT, "a", 1), event(mvccpb.PUT, "b", 2), event(mvccpb.DELETE, "c", 3)},
+			want:   []int64{1, 2, 3},
+		},
+		{
+			name:   "Last put of a key",
+			events: []*clientv3.Event{event(mvccpb.PUT, "a", 1), event(mvccpb.PUT, "b", 2), event(mvccpb.PUT, "a", 3), event(mvccpb.PUT, "a", 4)},
+			want:   []int64{2, 4},
+		},
+		{
+			name:   "Delete after put",
+			events: []*clientv3.Event{event(mvccpb.PUT, "a", 1), event(mvccpb.DELETE, "a", 2), event(mvccpb.PUT, "b", 3)},
+			want:   []int64{2, 3},
+		},
+		{
+			name:   "Put after delete",
+			events: []*clientv3.Event{event(mvccpb.DELETE, "a", 1), event(mvccpb.PUT, "b", 2), event(mvccpb.PUT, "a", 3)},
+			want:   []int64{2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			for _, e := range coalesceEvents(tt.events) {
+				got = append(got, e.Kv.ModRevision)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("coalesceEvents() revisions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCache_names(t *testing.T) {
+	ctx := context.Background()
+	memStore := store.NewMemStore()
+	cli, err := NewClientWithStore(nil, memStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &clientCache{mc: cli.Master(), dbNames: newTypedCache[string]("db_name"), spaceIDCache: newTypedCache[*entity.Space]("space_id")}
+	if err := memStore.Put(ctx, entity.DBKeyId(1), []byte("db")); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := cc.dbName(ctx, 1); err != nil || name != "db" {
+		t.Fatalf("dbName() = %s, %v, want db", name, err)
+	}
+	// the name is not queried again
+	if err := memStore.Delete(ctx, entity.DBKeyId(1)); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := cc.dbName(ctx, 1); err != nil || name != "db" {
+		t.Errorf("memoized dbName() = %s, %v, want db", name, err)
+	}
+	if _, err := cc.dbName(ctx, 2); err == nil {
+		t.Error("dbName() of a missing db should fail")
+	}
+
+	cc.spaceIDCache.Set("5", &entity.Space{Id: 5, DBId: 1, Name: "cached"})
+	if name, err := cc.spaceName(ctx, 1, 5); err != nil || name != "cached" {
+		t.Errorf("spaceName() = %s, %v, want cached", name, err)
+	}
+	// a cached space of another db is not used
+	if _, err := cc.spaceName(ctx, 2, 5); err == nil {
+		t.Error("spaceName() of a missing space should fail")
+	}
+}
@@ -17,12 +17,14 @@ package config
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/url"
 	"os"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
@@ -190,6 +192,16 @@ type Base struct {
 	LogFileNum  int      `toml:"log_file_num,omitempty" json:"log_file_num"`
 	LogFileSize int      `toml:"log_file_size,omitempty" json:"log_file_size"`
 	Data        []string `toml:"data,omitempty" json:"data"`
+
+	// structured log, it is used when LogFormat is json or text
+	LogFormat           string            `toml:"log_format,omitempty" json:"log_format"`
+	LogSink             string            `toml:"log_sink,omitempty" json:"log_sink"`                   // file, stdout or syslog
+	LogModuleLevels     map[string]string `toml:"log_module_levels,omitempty" json:"log_module_levels"` // keyed by package path under internal
+	LogSampleInitial    int               `toml:"log_sample_initial,omitempty" json:"log_sample_initial"`
+	LogSampleThereafter int               `toml:"log_sample_thereafter,omitempty" json:"log_sample_thereafter"`
+
+	// LogPayload keeps request bodies, etcd values and documents in logs and errors, for debug only
+	LogPayload bool `toml:"log_payload,omitempty" json:"log_payload"`
 }
 
 type GlobalCfg struct {
@@ -206,6 +218,55 @@ type GlobalCfg struct {
 	LimitedReplicaNum bool    `toml:"limited_replica_num,omitempty" json:"limited_replica_num"`
 	ResourceLimitRate float64 `toml:"resource_limit_rate,omitempty" json:"resource_limit_rate"`
 	Path              string  `toml:"path,omitempty" json:"path"`
+	// seconds clients may cache metadata reads, 0 revalidates every time
+	MetaCacheMaxAge int `toml:"meta_cache_max_age,omitempty" json:"meta_cache_max_age"`
+	// Runtime limits the go runtime of processes whose component has none
+	Runtime *RuntimeCfg `toml:"runtime,omitempty" json:"runtime"`
+}
+
+// RuntimeCfg limits the go runtime of a process, zero fields are derived
+// from the cgroup of the process so containers are not oom killed
+type RuntimeCfg struct {
+	MaxProcs         int     `toml:"max_procs" json:"max_procs,omitempty"`                   // GOMAXPROCS, 0 is the cpu quota of the cgroup
+	MemoryLimit      int64   `toml:"memory_limit" json:"memory_limit,omitempty"`             // bytes of GOMEMLIMIT, 0 is memory_limit_ratio of the cgroup memory limit
+	MemoryLimitRatio float64 `toml:"memory_limit_ratio" json:"memory_limit_ratio,omitempty"` // 0 is 0.9
+	GCPercent        *int    `toml:"gogc" json:"gogc,omitempty"`
+}
+
+func (cfg *RuntimeCfg) validate() error {
+	if cfg.MaxProcs < 0 || cfg.MemoryLimit < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CONFIG_ERROR, fmt.Errorf("runtime max_procs and memory_limit should not be negative"))
+	}
+	if cfg.MemoryLimitRatio < 0 || cfg.MemoryLimitRatio > 1 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CONFIG_ERROR, fmt.Errorf("runtime memory_limit_ratio should be in [0, 1]"))
+	}
+	return nil
+}
+
+// RuntimeConfig returns the runtime config of the component of a process
+// running only model, or the global one, nil if there is none
+func (config *Config) RuntimeConfig(models ...Model) *RuntimeCfg {
+	if len(models) == 1 {
+		var cfg *RuntimeCfg
+		switch models[0] {
+		case Master:
+			if self := config.Masters.Self(); self != nil {
+				cfg = self.Runtime
+			}
+		case PS:
+			if config.PS != nil {
+				cfg = config.PS.Runtime
+			}
+		case Router:
+			if config.Router != nil {
+				cfg = config.Router.Runtime
+			}
+		}
+		if cfg != nil {
+			return cfg
+		}
+	}
+	return config.Global.Runtime
 }
 
 type EtcdCfg struct {
@@ -242,17 +303,19 @@ func (ms Masters) Self() *MasterCfg {
 }
 
 type MasterCfg struct {
-	Name           string `toml:"name,omitempty" json:"name"`
-	Address        string `toml:"address,omitempty" json:"address"`
-	ApiPort        uint16 `toml:"api_port,omitempty" json:"api_port"`
-	EtcdPort       uint16 `toml:"etcd_port,omitempty" json:"etcd_port"`
-	EtcdPeerPort   uint16 `toml:"etcd_peer_port,omitempty" json:"etcd_peer_port"`
-	EtcdClientPort uint16 `toml:"etcd_client_port,omitempty" json:"etcd_client_port"`
-	Self           bool   `json:"-"`
-	SkipAuth       bool   `toml:"skip_auth,omitempty" json:"skip_auth"`
-	PprofPort      uint16 `toml:"pprof_port,omitempty" json:"pprof_port"`
-	MonitorPort    uint16 `toml:"monitor_port" json:"monitor_port"`
-	ClusterState   string `toml:"cluster_state,omitempty" json:"cluster_state"`
+	Name           string      `toml:"name,omitempty" json:"name"`
+	Address        string      `toml:"address,omitempty" json:"address"`
+	ApiPort        uint16      `toml:"api_port,omitempty" json:"api_port"`
+	EtcdPort       uint16      `toml:"etcd_port,omitempty" json:"etcd_port"`
+	EtcdPeerPort   uint16      `toml:"etcd_peer_port,omitempty" json:"etcd_peer_port"`
+	EtcdClientPort uint16      `toml:"etcd_client_port,omitempty" json:"etcd_client_port"`
+	Self           bool        `json:"-"`
+	SkipAuth       bool        `toml:"skip_auth,omitempty" json:"skip_auth"`
+	PprofPort      uint16      `toml:"pprof_port,omitempty" json:"pprof_port"`
+	MonitorPort    uint16      `toml:"monitor_port" json:"monitor_port"`
+	ClusterState   string      `toml:"cluster_state,omitempty" json:"cluster_state"`
+	Runtime        *RuntimeCfg `toml:"runtime,omitempty" json:"runtime"`
+	WebUI          bool        `toml:"web_ui,omitempty" json:"web_ui"` // serve the web ui under /ui/ for admin
 }
 
 func (m *MasterCfg) ApiUrl() string {
@@ -351,16 +414,226 @@ func (config *Config) GetEmbed() (*embed.Config, error) {
 }
 
 type RouterCfg struct {
-	Port          uint16   `toml:"port,omitempty" json:"port"`
-	PprofPort     uint16   `toml:"pprof_port,omitempty" json:"pprof_port"`
-	RpcPort       uint16   `toml:"rpc_port,omitempty" json:"rpc_port"`
-	MonitorPort   uint16   `toml:"monitor_port" json:"monitor_port"`
-	ConnLimit     int      `toml:"conn_limit" json:"conn_limit"`
-	CloseTimeout  int64    `toml:"close_timeout" json:"close_timeout"`
-	RouterIPS     []string `toml:"router_ips" json:"router_ips"`
-	ConcurrentNum int      `toml:"concurrent_num" json:"concurrent_num"`
-	RpcTimeOut    int      `toml:"rpc_timeout" json:"rpc_timeout"` // ms
-	AllowOrigins  []string `toml:"allow_origins" json:"allow_origins"`
+	Port          uint16              `toml:"port,omitempty" json:"port"`
+	PprofPort     uint16              `toml:"pprof_port,omitempty" json:"pprof_port"`
+	RpcPort       uint16              `toml:"rpc_port,omitempty" json:"rpc_port"`
+	MonitorPort   uint16              `toml:"monitor_port" json:"monitor_port"`
+	ConnLimit     int                 `toml:"conn_limit" json:"conn_limit"`
+	CloseTimeout  int64               `toml:"close_timeout" json:"close_timeout"`
+	RouterIPS     []string            `toml:"router_ips" json:"router_ips"`
+	ConcurrentNum int                 `toml:"concurrent_num" json:"concurrent_num"`
+	RpcTimeOut    int                 `toml:"rpc_timeout" json:"rpc_timeout"` // ms
+	AllowOrigins  []string            `toml:"allow_origins" json:"allow_origins"`
+	Shadow        []*ShadowCfg        `toml:"shadow" json:"shadow"`
+	Experiment    []*ExperimentCfg    `toml:"experiment" json:"experiment"`
+	QueryLog      *QueryLogCfg        `toml:"query_log" json:"query_log"`
+	Hydration     []*HydrationCfg     `toml:"hydration" json:"hydration"`
+	Usage         *UsageCfg           `toml:"usage" json:"usage"`
+	CacheSnapshot *CacheSnapshotCfg   `toml:"cache_snapshot" json:"cache_snapshot"`
+	ReadRepair    *ReadRepairCfg      `toml:"read_repair" json:"read_repair"`
+	Embedders     []*EmbedderCfg      `toml:"embedder" json:"embedder"`
+	Rerankers     []*RerankerCfg      `toml:"reranker" json:"reranker"`
+	FairQueue     *FairQueueCfg       `toml:"fair_queue" json:"fair_queue"`
+	WarmStart     *WarmStartCfg       `toml:"warm_start" json:"warm_start"`
+	MetricPlugins []string            `toml:"metric_plugins" json:"metric_plugins"` // go plugins registering distance metrics
+	Remotes       []*RemoteClusterCfg `toml:"remote" json:"remote"`
+	Runtime       *RuntimeCfg         `toml:"runtime" json:"runtime"`
+	AdvertiseAddr string              `toml:"advertise_addr" json:"advertise_addr"` // host:port clients reach the router by, local ip and port if not set
+	ResponseLimit *ResponseLimitCfg   `toml:"response_limit" json:"response_limit"`
+	Breaker       *BreakerCfg         `toml:"circuit_breaker" json:"circuit_breaker"`
+	Planner       *PlannerCfg         `toml:"filter_planner" json:"filter_planner"`
+}
+
+// PlannerCfg plans the filters of searches and queries by the index stats
+// of partitions. Searches expected to match at most brute_force_rows
+// documents filter first and scan the matches exactly instead of searching
+// the index, queries expected to return more than max_query_rows documents
+// are rejected
+type PlannerCfg struct {
+	BruteForceRows int `toml:"brute_force_rows" json:"brute_force_rows,omitempty"` // 0 never filters first
+	MaxQueryRows   int `toml:"max_query_rows" json:"max_query_rows,omitempty"`     // 0 admits every query
+	RefreshTime    int `toml:"refresh_time" json:"refresh_time,omitempty"`         // seconds the stats of a space are kept, 60 if 0
+}
+
+// ResponseLimitCfg caps the bytes of the documents of a search, query or get
+// response, the documents beyond the budget keep only their id and score.
+// The budget of the role of a request wins over the one of its space, which
+// wins over the default
+type ResponseLimitCfg struct {
+	DefaultBytes int64            `toml:"default_bytes" json:"default_bytes,omitempty"` // 0 is unlimited
+	Spaces       map[string]int64 `toml:"spaces" json:"spaces,omitempty"`               // db/space to bytes
+	Roles        map[string]int64 `toml:"roles" json:"roles,omitempty"`                 // role name to bytes
+}
+
+// Budget returns the bytes budget of a request of role to db/space, 0 if
+// it is unlimited
+func (cfg *ResponseLimitCfg) Budget(role, dbName, spaceName string) int64 {
+	if cfg == nil {
+		return 0
+	}
+	if budget, ok := cfg.Roles[role]; ok && role != "" {
+		return budget
+	}
+	if budget, ok := cfg.Spaces[dbName+"/"+spaceName]; ok {
+		return budget
+	}
+	return cfg.DefaultBytes
+}
+
+// FairQueueCfg shares the slots of searches and queries among tenants by
+// weighted fair queuing, a tenant is the user of a request, or db/space if
+// the request has no user
+type FairQueueCfg struct {
+	Slots         int            `toml:"slots" json:"slots,omitempty"` // requests running at the same time, ps uses concurrent_num if 0
+	DefaultWeight int            `toml:"default_weight" json:"default_weight,omitempty"`
+	Weights       map[string]int `toml:"weights" json:"weights,omitempty"` // tenant to weight
+}
+
+// Weight returns the weight of tenant, 1 if not configured
+func (cfg *FairQueueCfg) Weight(tenant string) int {
+	if w, ok := cfg.Weights[tenant]; ok && w > 0 {
+		return w
+	}
+	if cfg.DefaultWeight > 0 {
+		return cfg.DefaultWeight
+	}
+	return 1
+}
+
+// BreakerCfg trips the circuit breaker of the searches or of the writes of a
+// space when their error or timeout rate over the window passes its
+// threshold, the router then fails them fast until probes succeed
+type BreakerCfg struct {
+	Window      int     `toml:"window" json:"window,omitempty"`             // seconds, 10 if 0
+	MinRequests int     `toml:"min_requests" json:"min_requests,omitempty"` // requests of the window before it may trip, 20 if 0
+	ErrorRate   float64 `toml:"error_rate" json:"error_rate,omitempty"`     // 0.5 if 0, timeouts are errors too
+	TimeoutRate float64 `toml:"timeout_rate" json:"timeout_rate,omitempty"` // 0.2 if 0
+	OpenTime    int     `toml:"open_time" json:"open_time,omitempty"`       // seconds failing fast before probing, 30 if 0
+	Probes      int     `toml:"probes" json:"probes,omitempty"`             // successes closing it half open, 3 if 0
+}
+
+// RerankerCfg is a reranker model the cross_encoder stages of space
+// pipelines name
+type RerankerCfg struct {
+	Name    string            `toml:"name" json:"name"`
+	Plugin  string            `toml:"plugin" json:"plugin,omitempty"`   // registered reranker, http if empty
+	Url     string            `toml:"url" json:"url,omitempty"`         // for http, a cohere compatible rerank api
+	Model   string            `toml:"model" json:"model,omitempty"`     // for http
+	Headers map[string]string `toml:"headers" json:"headers,omitempty"` // for http
+	Timeout int               `toml:"timeout" json:"timeout,omitempty"` // ms for a query
+}
+
+// EmbedderCfg is an embedding model the router runs embedding migrations
+// of master with, a migration names its embedder
+type EmbedderCfg struct {
+	Name    string            `toml:"name" json:"name"`
+	Plugin  string            `toml:"plugin" json:"plugin,omitempty"`   // registered embedder, http if empty
+	Url     string            `toml:"url" json:"url,omitempty"`         // for http, an openai compatible embeddings api
+	Model   string            `toml:"model" json:"model,omitempty"`     // for http
+	Headers map[string]string `toml:"headers" json:"headers,omitempty"` // for http
+	Timeout int               `toml:"timeout" json:"timeout,omitempty"` // ms for a batch
+}
+
+// ReadRepairCfg asks master to check and repair the partitions whose
+// replicas disagree on a get checking consistency, a partition is repaired
+// at most once in Interval
+type ReadRepairCfg struct {
+	Enabled  bool `toml:"enabled" json:"enabled"`
+	Interval int  `toml:"interval" json:"interval,omitempty"` // seconds
+}
+
+// CacheSnapshotCfg persists the meta cache of router to local disk, a router
+// which can not reach etcd at startup serves reads from the snapshot
+type CacheSnapshotCfg struct {
+	Path     string `toml:"path" json:"path"`
+	Interval int    `toml:"interval" json:"interval,omitempty"` // seconds
+}
+
+// WarmStartCfg fills the meta cache of a starting router from the cache of a
+// running one instead of scanning etcd, peers are the http addresses of the
+// routers, the registered routers on the router port are tried if empty
+type WarmStartCfg struct {
+	Enabled bool     `toml:"enabled" json:"enabled"`
+	Peers   []string `toml:"peers" json:"peers,omitempty"`
+	Timeout int      `toml:"timeout" json:"timeout,omitempty"` // seconds
+}
+
+// UsageCfg accounts the cost of the searches and queries of this router by
+// user and space, in daily rollups reported by master
+type UsageCfg struct {
+	Enabled       bool `toml:"enabled" json:"enabled"`
+	FlushInterval int  `toml:"flush_interval" json:"flush_interval,omitempty"` // seconds
+}
+
+// HydrationCfg fetches the payloads of the results of a space from an
+// external store by the reference kept in RefField, for searches and
+// queries asking for hydrate
+type HydrationCfg struct {
+	DbName      string            `toml:"db_name" json:"db_name"`
+	SpaceName   string            `toml:"space_name" json:"space_name"`
+	RefField    string            `toml:"ref_field" json:"ref_field"`
+	Plugin      string            `toml:"plugin" json:"plugin,omitempty"`   // registered hydrator, http if empty
+	Url         string            `toml:"url" json:"url,omitempty"`         // for http, {ref} is replaced by the reference
+	Headers     map[string]string `toml:"headers" json:"headers,omitempty"` // for http
+	Timeout     int               `toml:"timeout" json:"timeout,omitempty"` // ms for all the fetches of a request
+	Concurrency int               `toml:"concurrency" json:"concurrency,omitempty"`
+	CacheSize   int               `toml:"cache_size" json:"cache_size,omitempty"` // payloads cached, 0 disables
+	CacheTTL    int               `toml:"cache_ttl" json:"cache_ttl,omitempty"`   // seconds
+}
+
+// QueryLogCfg captures sampled search requests to a json lines file, which
+// vearchctl replay re-issues against a cluster
+type QueryLogCfg struct {
+	Path          string  `toml:"path" json:"path"`
+	SamplePercent float64 `toml:"sample_percent" json:"sample_percent"`
+	MaxSizeMB     int     `toml:"max_size_mb" json:"max_size_mb"` // rotated to path.1 beyond it
+}
+
+// ShadowCfg mirrors part of the searches of a space to another space of the
+// same cluster, or of the cluster behind TargetUrl
+type ShadowCfg struct {
+	DbName      string  `toml:"db_name" json:"db_name"`
+	SpaceName   string  `toml:"space_name" json:"space_name"`
+	TargetDb    string  `toml:"target_db" json:"target_db"`
+	TargetSpace string  `toml:"target_space" json:"target_space"`
+	TargetUrl   string  `toml:"target_url" json:"target_url,omitempty"` // router url of another cluster
+	User        string  `toml:"user" json:"user,omitempty"`
+	Password    string  `toml:"password" json:"password,omitempty"`
+	Percent     float64 `toml:"percent" json:"percent"`           // percent of searches mirrored
+	DiffPercent float64 `toml:"diff_percent" json:"diff_percent"` // percent of mirrored searches diffed
+	Timeout     int     `toml:"timeout" json:"timeout,omitempty"` // ms
+}
+
+// RemoteClusterCfg is another cluster the searches of this router reach by
+// the targets cluster:db/space, its routers are tried in order on errors
+type RemoteClusterCfg struct {
+	Name      string   `toml:"name" json:"name"`
+	Endpoints []string `toml:"endpoints" json:"endpoints"` // router urls
+	User      string   `toml:"user" json:"user,omitempty"` // the auth of the request is forwarded if empty
+	Password  string   `toml:"password" json:"password,omitempty"`
+	Timeout   int      `toml:"timeout" json:"timeout,omitempty"` // ms
+}
+
+// ExperimentCfg routes part of the searches of a space to variants, a
+// search is bucketed by the hash of the user, of the HashHeader value, or
+// randomly when hash_by is empty
+type ExperimentCfg struct {
+	Name       string               `toml:"name" json:"name"`
+	DbName     string               `toml:"db_name" json:"db_name"`
+	SpaceName  string               `toml:"space_name" json:"space_name"`
+	HashBy     string               `toml:"hash_by" json:"hash_by,omitempty"` // user, header or empty
+	HashHeader string               `toml:"hash_header" json:"hash_header,omitempty"`
+	Variants   []*ExperimentVariant `toml:"variants" json:"variants"`
+}
+
+// ExperimentVariant is a space or index params searched instead of the
+// original, the searches not in any variant are the control
+type ExperimentVariant struct {
+	Name        string                 `toml:"name" json:"name"`
+	TargetDb    string                 `toml:"target_db" json:"target_db,omitempty"`
+	TargetSpace string                 `toml:"target_space" json:"target_space,omitempty"`
+	IndexParams map[string]interface{} `toml:"index_params" json:"index_params,omitempty"`
+	Percent     float64                `toml:"percent" json:"percent"`
 }
 
 func (routerCfg *RouterCfg) ApiUrl(keyNumber int) string {
@@ -375,24 +648,119 @@ func (routerCfg *RouterCfg) ApiUrl(keyNumber int) string {
 }
 
 type PSCfg struct {
-	RpcPort                     uint16 `toml:"rpc_port,omitempty" json:"rpc_port"`
-	PsHeartbeatTimeout          int    `toml:"ps_heartbeat_timeout" json:"ps_heartbeat_timeout"`
-	RaftHeartbeatPort           uint16 `toml:"raft_heartbeat_port,omitempty" json:"raft_heartbeat_port"`
-	RaftReplicatePort           uint16 `toml:"raft_replicate_port,omitempty" json:"raft_replicate_port"`
-	RaftHeartbeatInterval       int    `toml:"heartbeat_interval" json:"heartbeat-interval"`
-	RaftRetainLogs              uint64 `toml:"raft_retain_logs" json:"raft-retain-logs"`
-	RaftReplicaConcurrency      int    `toml:"raft_replica_concurrency" json:"raft-replica-concurrency"`
-	RaftSnapConcurrency         int    `toml:"raft_snap_concurrency" json:"raft-snap-concurrency"`
-	RaftTruncateCount           int64  `toml:"raft_truncate_count" json:"raft_truncate_count"`
-	RaftDiffCount               uint64 `toml:"raft_diff_count" json:"raft_diff_count"`
-	ReplicaAutoRecoverTime      int64  `toml:"replica_auto_recover_time" json:"replica_auto_recover_time"`
-	ReplicaAntiAffinityStrategy int    `toml:"replica_anti_affinity_strategy" json:"replica_anti_affinity_strategy"` // 0: no anti-affinity, 1: by HostIp, 2: by HostRack, 3: by HostZone
-	PprofPort                   uint16 `toml:"pprof_port" json:"pprof_port"`
-	Private                     bool   `toml:"private" json:"private"`                         //this ps is private if true you must set machine by dbConfig
-	FlushTimeInterval           uint32 `toml:"flush_time_interval" json:"flush_time_interval"` // seconds
-	FlushCountThreshold         uint32 `toml:"flush_count_threshold" json:"flush_count_threshold"`
-	ConcurrentNum               int    `toml:"concurrent_num" json:"concurrent_num"`
-	RpcTimeOut                  int    `toml:"rpc_timeout" json:"rpc_timeout"`
+	RpcPort                     uint16        `toml:"rpc_port,omitempty" json:"rpc_port"`
+	PsHeartbeatTimeout          int           `toml:"ps_heartbeat_timeout" json:"ps_heartbeat_timeout"`   // seconds, ttl of the registration lease
+	PsKeepaliveInterval         int           `toml:"ps_keepalive_interval" json:"ps_keepalive_interval"` // ms, 0 lets etcd refresh at a third of the ttl
+	PsKeepaliveJitter           float64       `toml:"ps_keepalive_jitter" json:"ps_keepalive_jitter"`     // fraction of the interval randomized
+	PsFailureGrace              int           `toml:"ps_failure_grace" json:"ps_failure_grace"`           // seconds master waits for an expired ps to register again
+	RaftHeartbeatPort           uint16        `toml:"raft_heartbeat_port,omitempty" json:"raft_heartbeat_port"`
+	RaftReplicatePort           uint16        `toml:"raft_replicate_port,omitempty" json:"raft_replicate_port"`
+	RaftHeartbeatInterval       int           `toml:"heartbeat_interval" json:"heartbeat-interval"`
+	RaftRetainLogs              uint64        `toml:"raft_retain_logs" json:"raft-retain-logs"`
+	RaftReplicaConcurrency      int           `toml:"raft_replica_concurrency" json:"raft-replica-concurrency"`
+	RaftSnapConcurrency         int           `toml:"raft_snap_concurrency" json:"raft-snap-concurrency"`
+	RaftTruncateCount           int64         `toml:"raft_truncate_count" json:"raft_truncate_count"`
+	RaftDiffCount               uint64        `toml:"raft_diff_count" json:"raft_diff_count"`
+	RaftNoPreVote               bool          `toml:"raft_no_pre_vote" json:"raft_no_pre_vote"` // followers hearing from their leader vote in the elections of other replicas
+	ReplicaAutoRecoverTime      int64         `toml:"replica_auto_recover_time" json:"replica_auto_recover_time"`
+	ReplicaAntiAffinityStrategy int           `toml:"replica_anti_affinity_strategy" json:"replica_anti_affinity_strategy"` // 0: no anti-affinity, 1: by HostIp, 2: by HostRack, 3: by HostZone
+	PprofPort                   uint16        `toml:"pprof_port" json:"pprof_port"`
+	Private                     bool          `toml:"private" json:"private"`                         //this ps is private if true you must set machine by dbConfig
+	FlushTimeInterval           uint32        `toml:"flush_time_interval" json:"flush_time_interval"` // seconds
+	FlushCountThreshold         uint32        `toml:"flush_count_threshold" json:"flush_count_threshold"`
+	ConcurrentNum               int           `toml:"concurrent_num" json:"concurrent_num"`
+	RpcTimeOut                  int           `toml:"rpc_timeout" json:"rpc_timeout"`
+	WriteFencing                bool          `toml:"write_fencing" json:"write_fencing"` // leaders write only with a valid fencing token of master
+	FairQueue                   *FairQueueCfg `toml:"fair_queue" json:"fair_queue"`
+	MetricPlugins               []string      `toml:"metric_plugins" json:"metric_plugins"` // go plugins registering distance metrics
+	UDF                         *UDFCfg       `toml:"udf" json:"udf"`
+	Transfer                    *TransferCfg  `toml:"transfer" json:"transfer"`
+	Runtime                     *RuntimeCfg   `toml:"runtime" json:"runtime"`
+	Watchdog                    *WatchdogCfg  `toml:"watchdog" json:"watchdog"`
+	Tombstone                   *TombstoneCfg `toml:"tombstone" json:"tombstone"`
+	IndexStats                  *StatsCfg     `toml:"index_stats" json:"index_stats"`
+}
+
+const (
+	DefaultStatsInterval = 300 // s
+	DefaultStatsSamples  = 1000
+)
+
+// StatsCfg samples the scalar fields of every partition periodically for
+// the filter planner of routers, it runs with the defaults without the
+// config
+type StatsCfg struct {
+	Disabled bool `toml:"disabled" json:"disabled,omitempty"`
+	Interval int  `toml:"interval" json:"interval,omitempty"` // seconds
+	Samples  int  `toml:"samples" json:"samples,omitempty"`   // documents sampled per partition
+}
+
+const (
+	DefaultStuckMultiple    = 3
+	DefaultWatchdogInterval = 1000 // ms
+)
+
+// WatchdogCfg finds the requests of ps running stuck_multiple times their
+// timeout, the watchdog runs with the defaults without the config
+type WatchdogCfg struct {
+	Disabled      bool    `toml:"disabled" json:"disabled,omitempty"`
+	StuckMultiple float64 `toml:"stuck_multiple" json:"stuck_multiple,omitempty"`
+	Interval      int     `toml:"interval" json:"interval,omitempty"` // ms
+	// Cancel cancels stuck requests and frees their slots, the calls into
+	// the engine they are stuck in go on
+	Cancel bool `toml:"cancel" json:"cancel,omitempty"`
+}
+
+const DefaultTombstoneMaxDocs = 10000
+
+// TombstoneCfg keeps the documents deleted in the last retention seconds in
+// memory, so reads and searches as of a time before the deletes still see
+// them. Tombstones are lost when ps restarts
+type TombstoneCfg struct {
+	Retention int `toml:"retention" json:"retention,omitempty"` // seconds, 0 keeps no tombstones
+	MaxDocs   int `toml:"max_docs" json:"max_docs,omitempty"`   // per partition, the oldest are dropped beyond it
+}
+
+const CompressionZstd = "zstd"
+
+// TransferCfg compresses and limits the raft snapshots ps send and the
+// partition snapshots other clusters copy from ps. Receivers decompress
+// whatever they get, so compression is turned on after every ps runs a
+// version knowing it
+type TransferCfg struct {
+	Compression      string `toml:"compression" json:"compression,omitempty"`             // zstd or empty for none
+	CompressionLevel int    `toml:"compression_level" json:"compression_level,omitempty"` // zstd level from 1 to 22, 0 is 3
+	RateLimit        int64  `toml:"rate_limit" json:"rate_limit,omitempty"`               // bytes per second all transfers of ps send, 0 is unlimited
+}
+
+// TransferConfig never returns nil, transfers are not compressed nor
+// limited without the config
+func (ps *PSCfg) TransferConfig() *TransferCfg {
+	if ps.Transfer == nil {
+		return &TransferCfg{}
+	}
+	return ps.Transfer
+}
+
+func (cfg *TransferCfg) validate() error {
+	if cfg.Compression != "" && cfg.Compression != CompressionZstd {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CONFIG_ERROR, fmt.Errorf("transfer compression should be %s or empty", CompressionZstd))
+	}
+	if cfg.CompressionLevel < 0 || cfg.CompressionLevel > 22 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CONFIG_ERROR, fmt.Errorf("transfer compression_level should be in [0, 22]"))
+	}
+	if cfg.RateLimit < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CONFIG_ERROR, fmt.Errorf("transfer rate_limit should not be negative"))
+	}
+	return nil
+}
+
+// UDFCfg enables the experimental wasm user defined functions of spaces on
+// ps and caps the limits the udfs declare
+type UDFCfg struct {
+	Enabled        bool   `toml:"enabled" json:"enabled"`
+	MaxFuel        int64  `toml:"max_fuel" json:"max_fuel,omitempty"`                 // instructions per candidate
+	MaxMemoryPages uint32 `toml:"max_memory_pages" json:"max_memory_pages,omitempty"` // pages of 64KB
 }
 
 func InitConfig(path string) {
@@ -520,9 +888,61 @@ func (config *Config) Validate(model Model) error {
 		}
 	}
 
+	if config.PS != nil {
+		if err := config.PS.validateKeepalive(); err != nil {
+			return err
+		}
+		if err := config.PS.TransferConfig().validate(); err != nil {
+			return err
+		}
+	}
+	if cfg := config.RuntimeConfig(model); cfg != nil {
+		if err := cfg.validate(); err != nil {
+			return err
+		}
+	}
+
 	return config.validatePath()
 }
 
+const DefaultPsHeartbeatTimeout = 5 // seconds
+
+// HeartbeatTTL is the ttl of the ps registration lease
+func (ps *PSCfg) HeartbeatTTL() time.Duration {
+	if ps.PsHeartbeatTimeout <= 0 {
+		return DefaultPsHeartbeatTimeout * time.Second
+	}
+	return time.Duration(ps.PsHeartbeatTimeout) * time.Second
+}
+
+// KeepaliveInterval is 0 when etcd chooses the interval
+func (ps *PSCfg) KeepaliveInterval() time.Duration {
+	return time.Duration(ps.PsKeepaliveInterval) * time.Millisecond
+}
+
+// Jitter spreads d by the keepalive jitter, so a restarted cluster does not
+// refresh or register all at once
+func (ps *PSCfg) Jitter(d time.Duration) time.Duration {
+	if ps.PsKeepaliveJitter <= 0 || d <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*ps.PsKeepaliveJitter*float64(d))
+}
+
+func (ps *PSCfg) validateKeepalive() error {
+	if ps.PsKeepaliveInterval < 0 || ps.PsFailureGrace < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CONFIG_ERROR, fmt.Errorf("ps_keepalive_interval and ps_failure_grace should not be negative"))
+	}
+	if ps.PsKeepaliveJitter < 0 || ps.PsKeepaliveJitter >= 0.5 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CONFIG_ERROR, fmt.Errorf("ps_keepalive_jitter should be in [0, 0.5)"))
+	}
+	// the slowest jittered refresh should still leave room for one retry
+	if interval := ps.KeepaliveInterval(); interval > 0 && float64(interval)*(1+ps.PsKeepaliveJitter) > float64(ps.HeartbeatTTL())/2 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_CONFIG_ERROR, fmt.Errorf("ps_keepalive_interval with jitter should not exceed half of ps_heartbeat_timeout"))
+	}
+	return nil
+}
+
 func (config *Config) validatePath() error {
 	if err := os.MkdirAll(config.GetLogDir(), os.ModePerm); err != nil {
 		return err
@@ -15,10 +15,12 @@
 #include <iostream>
 #include <sstream>
 #include <string>
+#include <vector>
 
 #include "api_data/doc.h"
 #include "api_data/response.h"
 #include "api_data/table.h"
+#include "faiss/utils/distances.h"
 #include "search/engine.h"
 #include "third_party/nlohmann/json.hpp"
 #include "util/log.h"
@@ -283,3 +285,31 @@ struct CStatus Backup(void *engine, int command) {
   Status2CStatus(status, cstatus);
   return cstatus;
 }
+
+int Similarity(int metric, const float *a, int na, const float *b, int nb,
+               int d, float *result) {
+  if (metric < 0 || metric > 2 || na <= 0 || nb <= 0 || d <= 0) {
+    return 1;
+  }
+  if (metric == 1) {
+    faiss::pairwise_L2sqr(d, na, a, nb, b, result);
+    return 0;
+  }
+  for (int i = 0; i < na; ++i) {
+    faiss::fvec_inner_products_ny(result + (size_t)i * nb, a + (size_t)i * d,
+                                  b, d, nb);
+  }
+  if (metric == 2) {
+    std::vector<float> norm_a(na), norm_b(nb);
+    faiss::fvec_norms_L2(norm_a.data(), a, d, na);
+    faiss::fvec_norms_L2(norm_b.data(), b, d, nb);
+    for (int i = 0; i < na; ++i) {
+      for (int j = 0; j < nb; ++j) {
+        float norm = norm_a[i] * norm_b[j];
+        result[(size_t)i * nb + j] =
+            norm > 0 ? result[(size_t)i * nb + j] / norm : 0;
+      }
+    }
+  }
+  return 0;
+}
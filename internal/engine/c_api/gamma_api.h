@@ -150,6 +150,18 @@ int SetConfig(void *engine, const char *config_str, int len);
 int GetConfig(void *engine, char **config_str, int *len);
 
 struct CStatus Backup(void *engine, int command);
+
+/**
+ * @brief pairwise similarity of two sets of vectors by the simd kernels
+ *
+ * @param metric  0: inner product, 1: squared L2, 2: cosine
+ * @param a       na vectors of dimension d
+ * @param b       nb vectors of dimension d
+ * @param result  na * nb scores in row major
+ * @return 0 successed, 1 failed
+ */
+int Similarity(int metric, const float *a, int na, const float *b, int nb,
+               int d, float *result);
 #ifdef __cplusplus
 }
 #endif
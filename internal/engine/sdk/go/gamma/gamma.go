@@ -226,3 +226,18 @@ func BackupSpace(engine unsafe.Pointer, command string) *Status {
 	}
 	return status
 }
+
+// Similarity returns the na * nb scores of the vectors of a and b in row
+// major, metric is 0 for inner product, 1 for squared L2 and 2 for cosine
+func Similarity(metric int, a, b []float32, dimension int) ([]float32, error) {
+	if dimension <= 0 || len(a) == 0 || len(b) == 0 || len(a)%dimension != 0 || len(b)%dimension != 0 {
+		return nil, fmt.Errorf("vectors length [%d] and [%d] not divided by dimension [%d]", len(a), len(b), dimension)
+	}
+	na, nb := len(a)/dimension, len(b)/dimension
+	result := make([]float32, na*nb)
+	ret := C.Similarity(C.int(metric), (*C.float)(unsafe.Pointer(&a[0])), C.int(na), (*C.float)(unsafe.Pointer(&b[0])), C.int(nb), C.int(dimension), (*C.float)(unsafe.Pointer(&result[0])))
+	if ret != 0 {
+		return nil, fmt.Errorf("similarity of metric [%d] failed", metric)
+	}
+	return result, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+type AlertRuleType string
+
+const (
+	// AlertRuleHealthRed fires when cluster health is red longer than Duration
+	AlertRuleHealthRed AlertRuleType = "health_red"
+	// AlertRuleDiskWatermark fires when disk used percent of any ps is above Threshold
+	AlertRuleDiskWatermark AlertRuleType = "disk_watermark"
+	// AlertRuleRaftLag fires when any follower is behind the leader commit more than Threshold entries
+	AlertRuleRaftLag AlertRuleType = "raft_lag"
+)
+
+type AlertChannelType string
+
+const (
+	AlertChannelSMTP  AlertChannelType = "smtp"
+	AlertChannelSlack AlertChannelType = "slack"
+)
+
+const DefaultAlertInterval = 60 // seconds
+
+type AlertRule struct {
+	Name      string        `json:"name"`
+	Type      AlertRuleType `json:"type"`
+	Threshold float64       `json:"threshold,omitempty"`
+	Duration  int64         `json:"duration,omitempty"` // seconds the condition must hold before firing
+	Channels  []string      `json:"channels,omitempty"` // empty means all channels
+}
+
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+type AlertChannel struct {
+	Name     string           `json:"name"`
+	Type     AlertChannelType `json:"type"`
+	SlackURL string           `json:"slack_url,omitempty"`
+	SMTP     *SMTPConfig      `json:"smtp,omitempty"`
+}
+
+// AlertState is the condition of a rule between two evaluations, stored in
+// etcd so the master evaluating next goes on from it
+type AlertState struct {
+	Since  int64 `json:"since"` // unix seconds the condition holds from
+	Firing bool  `json:"firing"`
+}
+
+// AlertConfig is the alerting settings of cluster, stored in etcd and
+// changed by the master api at runtime
+type AlertConfig struct {
+	Enabled  bool            `json:"enabled"`
+	Interval int64           `json:"interval,omitempty"` // seconds
+	Rules    []*AlertRule    `json:"rules,omitempty"`
+	Channels []*AlertChannel `json:"channels,omitempty"`
+}
+
+func (ac *AlertConfig) Validate() error {
+	if ac.Interval < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert interval should not be negative"))
+	}
+	if ac.Interval == 0 {
+		ac.Interval = DefaultAlertInterval
+	}
+
+	channels := make(map[string]bool, len(ac.Channels))
+	for _, c := range ac.Channels {
+		if c.Name == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert channel name can not be empty"))
+		}
+		if channels[c.Name] {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert channel %s is duplicated", c.Name))
+		}
+		channels[c.Name] = true
+		switch c.Type {
+		case AlertChannelSlack:
+			if u, err := url.Parse(c.SlackURL); err != nil || u.Host == "" {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert channel %s slack_url %s is invalid", c.Name, c.SlackURL))
+			}
+		case AlertChannelSMTP:
+			if c.SMTP == nil || c.SMTP.Host == "" || c.SMTP.Port <= 0 || c.SMTP.From == "" || len(c.SMTP.To) == 0 {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert channel %s smtp should set host, port, from and to", c.Name))
+			}
+		default:
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert channel type: %s, should be %s or %s", c.Type, AlertChannelSMTP, AlertChannelSlack))
+		}
+	}
+
+	rules := make(map[string]bool, len(ac.Rules))
+	for _, r := range ac.Rules {
+		if r.Name == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert rule name can not be empty"))
+		}
+		if rules[r.Name] {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert rule %s is duplicated", r.Name))
+		}
+		rules[r.Name] = true
+		switch r.Type {
+		case AlertRuleHealthRed:
+		case AlertRuleDiskWatermark:
+			if r.Threshold <= 0 || r.Threshold > 100 {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert rule %s threshold should be in (0, 100]", r.Name))
+			}
+		case AlertRuleRaftLag:
+			if r.Threshold <= 0 {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert rule %s threshold should be positive", r.Name))
+			}
+		default:
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert rule type: %s, should be %s, %s or %s", r.Type, AlertRuleHealthRed, AlertRuleDiskWatermark, AlertRuleRaftLag))
+		}
+		if r.Duration < 0 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert rule %s duration should not be negative", r.Name))
+		}
+		for _, name := range r.Channels {
+			if !channels[name] {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("alert rule %s channel %s not exists", r.Name, name))
+			}
+		}
+	}
+	return nil
+}
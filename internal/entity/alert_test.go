@@ -0,0 +1,80 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestAlertConfig_Validate(t *testing.T) {
+	slack := &AlertChannel{Name: "ops", Type: AlertChannelSlack, SlackURL: "https://hooks.slack.com/services/x"}
+	mail := &AlertChannel{Name: "mail", Type: AlertChannelSMTP, SMTP: &SMTPConfig{Host: "smtp.example.com", Port: 25, From: "vearch@example.com", To: []string{"ops@example.com"}}}
+	tests := []struct {
+		name    string
+		cfg     AlertConfig
+		wantErr bool
+	}{
+		{
+			name:    "Valid config with rules and channels",
+			cfg:     AlertConfig{Channels: []*AlertChannel{slack, mail}, Rules: []*AlertRule{{Name: "red", Type: AlertRuleHealthRed, Duration: 60}, {Name: "disk", Type: AlertRuleDiskWatermark, Threshold: 85, Channels: []string{"mail"}}}},
+			wantErr: false,
+		},
+		{
+			name:    "Invalid config with negative interval",
+			cfg:     AlertConfig{Interval: -1},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid config with duplicated channel",
+			cfg:     AlertConfig{Channels: []*AlertChannel{slack, slack}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid config with slack channel without url",
+			cfg:     AlertConfig{Channels: []*AlertChannel{{Name: "ops", Type: AlertChannelSlack}}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid config with smtp channel without recipient",
+			cfg:     AlertConfig{Channels: []*AlertChannel{{Name: "mail", Type: AlertChannelSMTP, SMTP: &SMTPConfig{Host: "smtp.example.com", Port: 25, From: "vearch@example.com"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid config with disk watermark beyond 100",
+			cfg:     AlertConfig{Rules: []*AlertRule{{Name: "disk", Type: AlertRuleDiskWatermark, Threshold: 120}}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid config with raft lag without threshold",
+			cfg:     AlertConfig{Rules: []*AlertRule{{Name: "lag", Type: AlertRuleRaftLag}}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid config with rule of unknown channel",
+			cfg:     AlertConfig{Channels: []*AlertChannel{slack}, Rules: []*AlertRule{{Name: "red", Type: AlertRuleHealthRed, Channels: []string{"pager"}}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("AlertConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg := &AlertConfig{}
+	if err := cfg.Validate(); err != nil || cfg.Interval != DefaultAlertInterval {
+		t.Errorf("interval = %d, err %v, want default %d", cfg.Interval, err, DefaultAlertInterval)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	MaxAnnotations           = 32
+	MaxAnnotationKeyLength   = 64
+	MaxAnnotationValueLength = 256
+)
+
+// ValidateAnnotations checks the static response annotations of a space,
+// they are returned by every search so they are kept small
+func ValidateAnnotations(annotations map[string]string) error {
+	if len(annotations) > MaxAnnotations {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space should have at most %d annotations, got %d", MaxAnnotations, len(annotations)))
+	}
+	for key, value := range annotations {
+		if key == "" || len(key) > MaxAnnotationKeyLength {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("annotation key %q should have 1 to %d bytes", key, MaxAnnotationKeyLength))
+		}
+		if len(value) > MaxAnnotationValueLength {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("value of annotation %s should have at most %d bytes", key, MaxAnnotationValueLength))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestValidateAnnotations(t *testing.T) {
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxAnnotations; i++ {
+		tooMany["k"+strconv.Itoa(i)] = "v"
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{name: "none", annotations: nil},
+		{name: "model version", annotations: map[string]string{"model_version": "bge-m3-2024"}},
+		{name: "empty key", annotations: map[string]string{"": "v"}, wantErr: true},
+		{name: "long key", annotations: map[string]string{strings.Repeat("k", MaxAnnotationKeyLength+1): "v"}, wantErr: true},
+		{name: "long value", annotations: map[string]string{"k": strings.Repeat("v", MaxAnnotationValueLength+1)}, wantErr: true},
+		{name: "too many", annotations: tooMany, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateAnnotations(tt.annotations); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
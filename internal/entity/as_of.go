@@ -0,0 +1,40 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// AsOfParam is the param of gets, queries by document ids and searches as of
+// a unix ms time. They see the documents deleted after it too, while ps keep
+// the tombstones of the deletes
+const AsOfParam = "as_of"
+
+// ParseAsOf returns the as_of time of params, 0 if it is not set
+func ParseAsOf(params map[string]string) (int64, error) {
+	v, ok := params[AsOfParam]
+	if !ok || v == "" {
+		return 0, nil
+	}
+	asOf, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || asOf <= 0 {
+		return 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s should be a positive unix ms time, got %s", AsOfParam, v))
+	}
+	return asOf, nil
+}
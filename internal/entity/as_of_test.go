@@ -0,0 +1,60 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestParseAsOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "Not set",
+		},
+		{
+			name:   "Empty",
+			params: map[string]string{AsOfParam: ""},
+		},
+		{
+			name:   "Unix ms",
+			params: map[string]string{AsOfParam: "1760000000000"},
+			want:   1760000000000,
+		},
+		{
+			name:    "Not a number",
+			params:  map[string]string{AsOfParam: "yesterday"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative",
+			params:  map[string]string{AsOfParam: "-1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAsOf(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAsOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAsOf() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
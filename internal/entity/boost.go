@@ -0,0 +1,176 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	BoostLinear = "linear"
+	BoostLog    = "log"
+	BoostDecay  = "decay"
+
+	BoostModeMultiply = "multiply"
+	BoostModeSum      = "sum"
+
+	// BoostParam is the head param carrying the boost from router to ps
+	BoostParam = "boost"
+
+	DefaultBoostOversample = 4
+	MaxBoostOversample     = 16
+)
+
+// BoostFunction maps the value of a numeric scalar field to a boost:
+// linear is factor * value, log is ln(1 + factor * value), and decay is
+// decay ^ (|value - origin| / scale). Date fields are in unix seconds.
+type BoostFunction struct {
+	Field   string   `json:"field"`
+	Type    string   `json:"type"`
+	Factor  float64  `json:"factor,omitempty"`
+	Origin  *float64 `json:"origin,omitempty"` // now if not set
+	Scale   float64  `json:"scale,omitempty"`
+	Decay   float64  `json:"decay,omitempty"`
+	Weight  float64  `json:"weight,omitempty"`  // 1 if not set
+	Missing float64  `json:"missing,omitempty"` // function value of documents without the field
+}
+
+// Boost combines the vector score with the sum of the weighted functions,
+// each partition scores oversample * topN candidates before its topN cut.
+// For L2 a larger boost lowers the distance, multiply divides and sum
+// subtracts.
+type Boost struct {
+	Functions  []*BoostFunction `json:"functions"`
+	Mode       string           `json:"mode,omitempty"`
+	Oversample int32            `json:"oversample,omitempty"`
+	MetricType string           `json:"metric_type,omitempty"` // set by router
+	Now        int64            `json:"now,omitempty"`         // set by router, unix seconds
+}
+
+// Validate checks the functions against the space fields and fills defaults
+func (b *Boost) Validate(proMap map[string]*SpaceProperties) error {
+	if len(b.Functions) == 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("boost functions should not be empty"))
+	}
+	if b.Mode == "" {
+		b.Mode = BoostModeMultiply
+	}
+	if b.Mode != BoostModeMultiply && b.Mode != BoostModeSum {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("boost mode should be %s or %s", BoostModeMultiply, BoostModeSum))
+	}
+	if b.Oversample <= 0 {
+		b.Oversample = DefaultBoostOversample
+	}
+	if b.Oversample > MaxBoostOversample {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("boost oversample should not exceed %d", MaxBoostOversample))
+	}
+	for _, f := range b.Functions {
+		pro := proMap[f.Field]
+		if pro == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("boost field [%s] not space field", f.Field))
+		}
+		switch pro.FieldType {
+		case vearchpb.FieldType_INT, vearchpb.FieldType_LONG, vearchpb.FieldType_FLOAT, vearchpb.FieldType_DOUBLE, vearchpb.FieldType_DATE:
+		default:
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("boost field [%s] should be numeric or date", f.Field))
+		}
+		switch f.Type {
+		case BoostLinear, BoostLog:
+			if f.Factor == 0 {
+				f.Factor = 1
+			}
+		case BoostDecay:
+			if f.Scale <= 0 || f.Decay <= 0 || f.Decay >= 1 {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("decay boost of [%s] should have scale > 0 and decay in (0, 1)", f.Field))
+			}
+		default:
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("boost type should be %s, %s or %s", BoostLinear, BoostLog, BoostDecay))
+		}
+		if f.Weight == 0 {
+			f.Weight = 1
+		}
+	}
+	return nil
+}
+
+// boostValue decodes a numeric field, dates are converted to seconds
+func boostValue(fieldType vearchpb.FieldType, value []byte) (float64, bool) {
+	switch fieldType {
+	case vearchpb.FieldType_INT:
+		return float64(cbbytes.Bytes2Int32(value)), true
+	case vearchpb.FieldType_LONG:
+		return float64(cbbytes.Bytes2Int(value)), true
+	case vearchpb.FieldType_FLOAT:
+		return float64(cbbytes.ByteToFloat32(value)), true
+	case vearchpb.FieldType_DOUBLE:
+		return cbbytes.ByteToFloat64New(value), true
+	case vearchpb.FieldType_DATE:
+		return float64(cbbytes.Bytes2Int(value)) / 1e9, true
+	}
+	return 0, false
+}
+
+// Score returns the score of a candidate with fields
+func (b *Boost) Score(score float64, fields []*vearchpb.Field, proMap map[string]*SpaceProperties) float64 {
+	values := make(map[string]float64, len(b.Functions))
+	for _, fv := range fields {
+		if pro := proMap[fv.Name]; pro != nil && len(fv.Value) > 0 {
+			if v, ok := boostValue(pro.FieldType, fv.Value); ok {
+				values[fv.Name] = v
+			}
+		}
+	}
+
+	boost := 0.0
+	for _, f := range b.Functions {
+		v, ok := values[f.Field]
+		if !ok {
+			boost += f.Weight * f.Missing
+			continue
+		}
+		var fn float64
+		switch f.Type {
+		case BoostLinear:
+			fn = f.Factor * v
+		case BoostLog:
+			fn = math.Log1p(math.Max(0, f.Factor*v))
+		case BoostDecay:
+			origin := float64(b.Now)
+			if f.Origin != nil {
+				origin = *f.Origin
+			}
+			fn = math.Pow(f.Decay, math.Abs(v-origin)/f.Scale)
+		}
+		boost += f.Weight * fn
+	}
+
+	if b.MetricType == "L2" {
+		if b.Mode == BoostModeSum {
+			return score - boost
+		}
+		if boost <= 0 {
+			return math.MaxFloat32
+		}
+		return score / boost
+	}
+	if b.Mode == BoostModeSum {
+		return score + boost
+	}
+	return score * boost
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestBoost_Score(t *testing.T) {
+	proMap := map[string]*SpaceProperties{
+		"popularity": {FieldType: vearchpb.FieldType_INT},
+		"title":      {FieldType: vearchpb.FieldType_STRING},
+	}
+	if err := (&Boost{Functions: []*BoostFunction{{Field: "title", Type: BoostLinear}}}).Validate(proMap); err == nil {
+		t.Fatalf("string field should not be boosted")
+	}
+
+	boost := &Boost{Functions: []*BoostFunction{{Field: "popularity", Type: BoostLinear, Factor: 0.5, Missing: 1}}}
+	if err := boost.Validate(proMap); err != nil {
+		t.Fatal(err)
+	}
+	if boost.Mode != BoostModeMultiply || boost.Oversample != DefaultBoostOversample {
+		t.Fatalf("defaults not filled: %+v", boost)
+	}
+	fields := []*vearchpb.Field{{Name: "popularity", Value: cbbytes.Int32ToByte(4)}}
+	if got := boost.Score(0.5, fields, proMap); math.Abs(got-1) > 1e-9 {
+		t.Fatalf("multiply score got %v, want 1", got)
+	}
+	if got := boost.Score(0.5, nil, proMap); math.Abs(got-0.5) > 1e-9 {
+		t.Fatalf("missing score got %v, want 0.5", got)
+	}
+
+	boost.MetricType, boost.Mode = "L2", BoostModeSum
+	if got := boost.Score(3, fields, proMap); math.Abs(got-1) > 1e-9 {
+		t.Fatalf("L2 sum score got %v, want 1", got)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	CronTaskBackup           = "backup"
+	CronTaskConsistencyCheck = "consistency_check"
+	CronTaskEtcdMaintenance  = "etcd_maintenance"
+	CronTaskOrphanCheck      = "orphan_check"
+	CronTaskUsageClean       = "usage_clean"
+
+	CronRunRunning   = "running"
+	CronRunSucceeded = "succeeded"
+	CronRunFailed    = "failed"
+	CronRunSkipped   = "skipped"
+
+	CronTriggerSchedule = "schedule"
+	CronTriggerManual   = "manual"
+
+	// MaxCronRuns is the number of runs kept in the history of a job
+	MaxCronRuns = 20
+	// MinCronEvery is the shortest interval of an @every schedule
+	MinCronEvery = time.Minute
+)
+
+var CronTasks = map[string]bool{
+	CronTaskBackup:           true,
+	CronTaskConsistencyCheck: true,
+	CronTaskEtcdMaintenance:  true,
+	CronTaskOrphanCheck:      true,
+	CronTaskUsageClean:       true,
+}
+
+var cronJobNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,127}$`)
+
+// CronJob runs Task with Params on Schedule, a cron expression of minute,
+// hour, day of month, month and day of week in UTC or one of @hourly,
+// @daily, @weekly, @monthly and @every <duration>. NextRun is the unix time
+// of the next scheduled run, the master claiming it sets the one after
+type CronJob struct {
+	Name       string          `json:"name"`
+	Task       string          `json:"task"`
+	Schedule   string          `json:"schedule"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Disabled   bool            `json:"disabled,omitempty"`
+	NextRun    int64           `json:"next_run"`
+	CreateTime int64           `json:"create_time"`
+	UpdateTime int64           `json:"update_time,omitempty"`
+}
+
+// CronRun is a run of a job, Id is the start time in nanoseconds
+type CronRun struct {
+	Id         int64  `json:"id"`
+	Job        string `json:"job"`
+	Trigger    string `json:"trigger"`
+	Status     string `json:"status"`
+	Master     string `json:"master,omitempty"`
+	Message    string `json:"message,omitempty"`
+	StartTime  int64  `json:"start_time"`
+	FinishTime int64  `json:"finish_time,omitempty"`
+}
+
+// CronBackupParams backs up a space, or all spaces of the db if space_name
+// is empty, to s3
+type CronBackupParams struct {
+	DbName    string  `json:"db_name"`
+	SpaceName string  `json:"space_name,omitempty"`
+	S3Param   S3Param `json:"s3_param"`
+}
+
+// Validate checks the job to put, the params of backup and consistency check
+// are checked as well
+func (j *CronJob) Validate() error {
+	if !cronJobNameRegexp.MatchString(j.Name) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job name %q should be letters, digits, _ or -", j.Name))
+	}
+	if !CronTasks[j.Task] {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job task %q not supported", j.Task))
+	}
+	schedule, err := ParseCronSchedule(j.Schedule)
+	if err != nil {
+		return err
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron schedule %q never runs", j.Schedule))
+	}
+	switch j.Task {
+	case CronTaskBackup:
+		params := &CronBackupParams{}
+		if err := json.Unmarshal(j.Params, params); err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job %s params err: %v", j.Name, err))
+		}
+		if params.DbName == "" || params.S3Param.EndPoint == "" || params.S3Param.BucketName == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job %s params should have db_name, s3_param endpoint and bucket_name", j.Name))
+		}
+	case CronTaskConsistencyCheck:
+		params := &ConsistencyCheckRequest{}
+		if err := json.Unmarshal(j.Params, params); err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job %s params err: %v", j.Name, err))
+		}
+		if err := params.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CronSchedule is a parsed schedule, the fields are bit sets of the allowed
+// values, or every is set for an @every schedule
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// a day matches either of day of month and day of week if both are restricted
+	domAny, dowAny bool
+	every          time.Duration
+}
+
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+func ParseCronSchedule(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := strings.CutPrefix(expr, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron schedule %q err: %v", expr, err))
+		}
+		if every < MinCronEvery {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron schedule %q should not be less than %v", expr, MinCronEvery))
+		}
+		return &CronSchedule{every: every}, nil
+	}
+	if s, ok := cronDescriptors[expr]; ok {
+		expr = s
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron schedule %q should have 5 fields: minute hour day month weekday", expr))
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	bits := [5]uint64{}
+	for i, field := range fields {
+		b, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron schedule %q err: %v", expr, err))
+		}
+		bits[i] = b
+	}
+	// 7 is sunday as 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &CronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma separated list of *, n, n-m with an
+// optional /step into the bit set of values
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range [%d, %d]", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time of the schedule after t, zero if none in five
+// years, e.g. 0 0 30 2 *
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every).Truncate(time.Second)
+	}
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
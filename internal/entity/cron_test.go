@@ -0,0 +1,103 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	// 2024-03-15 is a friday
+	from := time.Date(2024, 3, 15, 10, 30, 20, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule string
+		want     time.Time
+	}{
+		{name: "every minute", schedule: "* * * * *", want: time.Date(2024, 3, 15, 10, 31, 0, 0, time.UTC)},
+		{name: "step minutes", schedule: "*/15 * * * *", want: time.Date(2024, 3, 15, 10, 45, 0, 0, time.UTC)},
+		{name: "daily at 2", schedule: "0 2 * * *", want: time.Date(2024, 3, 16, 2, 0, 0, 0, time.UTC)},
+		{name: "hourly", schedule: "@hourly", want: time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{name: "monthly", schedule: "@monthly", want: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "sunday as 7", schedule: "0 3 * * 7", want: time.Date(2024, 3, 17, 3, 0, 0, 0, time.UTC)},
+		{name: "weekdays range", schedule: "0 9 * * 1-5", want: time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)},
+		{name: "list of hours", schedule: "30 6,18 * * *", want: time.Date(2024, 3, 15, 18, 30, 0, 0, time.UTC)},
+		{name: "day of month or week", schedule: "0 0 1 * 6", want: time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{name: "leap day", schedule: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "every duration", schedule: "@every 90m", want: time.Date(2024, 3, 15, 12, 0, 20, 0, time.UTC)},
+		{name: "never", schedule: "0 0 30 2 *", want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCronSchedule(tt.schedule)
+			if err != nil {
+				t.Fatalf("ParseCronSchedule() error = %v", err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronSchedule_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule string
+	}{
+		{name: "empty", schedule: ""},
+		{name: "four fields", schedule: "* * * *"},
+		{name: "minute out of range", schedule: "60 * * * *"},
+		{name: "reversed range", schedule: "0 5-2 * * *"},
+		{name: "zero step", schedule: "*/0 * * * *"},
+		{name: "not a number", schedule: "a * * * *"},
+		{name: "every too short", schedule: "@every 10s"},
+		{name: "every invalid", schedule: "@every soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCronSchedule(tt.schedule); err == nil {
+				t.Errorf("ParseCronSchedule(%q) should fail", tt.schedule)
+			}
+		})
+	}
+}
+
+func TestCronJob_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     *CronJob
+		wantErr bool
+	}{
+		{name: "usage clean", job: &CronJob{Name: "usage", Task: CronTaskUsageClean, Schedule: "@daily"}},
+		{name: "consistency check", job: &CronJob{Name: "check", Task: CronTaskConsistencyCheck, Schedule: "0 3 * * 0", Params: json.RawMessage(`{"db_name":"db","space_name":"s"}`)}},
+		{name: "backup", job: &CronJob{Name: "backup", Task: CronTaskBackup, Schedule: "0 1 * * *", Params: json.RawMessage(`{"db_name":"db","s3_param":{"endpoint":"s3:9000","bucket_name":"b"}}`)}},
+		{name: "invalid name", job: &CronJob{Name: "a b", Task: CronTaskUsageClean, Schedule: "@daily"}, wantErr: true},
+		{name: "unknown task", job: &CronJob{Name: "x", Task: "reboot", Schedule: "@daily"}, wantErr: true},
+		{name: "invalid schedule", job: &CronJob{Name: "x", Task: CronTaskUsageClean, Schedule: "daily"}, wantErr: true},
+		{name: "never runs", job: &CronJob{Name: "x", Task: CronTaskUsageClean, Schedule: "0 0 31 4 *"}, wantErr: true},
+		{name: "consistency check without space", job: &CronJob{Name: "x", Task: CronTaskConsistencyCheck, Schedule: "@daily", Params: json.RawMessage(`{"db_name":"db"}`)}, wantErr: true},
+		{name: "backup without bucket", job: &CronJob{Name: "x", Task: CronTaskBackup, Schedule: "@daily", Params: json.RawMessage(`{"db_name":"db","s3_param":{"endpoint":"s3:9000"}}`)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.job.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
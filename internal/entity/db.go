@@ -25,9 +25,66 @@ import (
 // db/name/[dbName]:[dbId]
 // db/body/[dbId]:[dbBody]
 type DB struct {
-	Id   DBID     `json:"id,omitempty"`
-	Name string   `json:"name,omitempty"`
-	Ps   []string `json:"ps,omitempty"` //if set this , your db only use you config ps
+	Id       DBID        `json:"id,omitempty"`
+	Name     string      `json:"name,omitempty"`
+	Ps       []string    `json:"ps,omitempty"`       //if set this , your db only use you config ps
+	Defaults *DBDefaults `json:"defaults,omitempty"` // inherited by new spaces which do not set them
+	Quota    *DBQuota    `json:"quota,omitempty"`
+}
+
+// DBDefaults are the settings of new spaces of a db which do not set them
+type DBDefaults struct {
+	ReplicaNum   uint8         `json:"replica_num,omitempty"`
+	ResourceName string        `json:"resource_name,omitempty"`
+	EngineConfig *EngineConfig `json:"engine_config,omitempty"`
+}
+
+// DBQuota caps the spaces of a db, zero is unlimited
+type DBQuota struct {
+	MaxSpaces     int   `json:"max_spaces,omitempty"`
+	MaxPartitions int   `json:"max_partitions,omitempty"` // of all spaces
+	MaxReplicaNum uint8 `json:"max_replica_num,omitempty"`
+}
+
+// DBSettings is the body to change the defaults and quota of a db, a nil
+// part is kept and an empty one clears it
+type DBSettings struct {
+	Defaults *DBDefaults `json:"defaults,omitempty"`
+	Quota    *DBQuota    `json:"quota,omitempty"`
+}
+
+func (d *DBDefaults) Validate() error {
+	if d.EngineConfig != nil {
+		if err := d.EngineConfig.Validate(); err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+		}
+	}
+	return nil
+}
+
+func (q *DBQuota) Validate() error {
+	if q.MaxSpaces < 0 || q.MaxPartitions < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("db quota should not be negative"))
+	}
+	return nil
+}
+
+// CheckQuota checks a db with spaces and partitions can add spaces with
+// partitions of replicaNum
+func (q *DBQuota) CheckQuota(spaces, partitions, addSpaces, addPartitions int, replicaNum uint8) error {
+	if q == nil {
+		return nil
+	}
+	if q.MaxSpaces > 0 && spaces+addSpaces > q.MaxSpaces {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("db quota exceeded: %d spaces, max_spaces %d", spaces+addSpaces, q.MaxSpaces))
+	}
+	if q.MaxPartitions > 0 && partitions+addPartitions > q.MaxPartitions {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("db quota exceeded: %d partitions, max_partitions %d", partitions+addPartitions, q.MaxPartitions))
+	}
+	if q.MaxReplicaNum > 0 && replicaNum > q.MaxReplicaNum {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("db quota exceeded: replica_num %d, max_replica_num %d", replicaNum, q.MaxReplicaNum))
+	}
+	return nil
 }
 
 func (db *DB) Validate() error {
@@ -49,6 +106,17 @@ func (db *DB) Validate() error {
 		}
 	}
 
+	if db.Defaults != nil {
+		if err := db.Defaults.Validate(); err != nil {
+			return err
+		}
+	}
+	if db.Quota != nil {
+		if err := db.Quota.Validate(); err != nil {
+			return err
+		}
+	}
+
 	// validate db id
 	if db.Id > 0 {
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("can not set db id when creating db"))
@@ -78,3 +78,31 @@ func TestDB_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestDBQuota_CheckQuota(t *testing.T) {
+	quota := &DBQuota{MaxSpaces: 2, MaxPartitions: 10, MaxReplicaNum: 3}
+	tests := []struct {
+		name                                         string
+		spaces, partitions, addSpaces, addPartitions int
+		replicaNum                                   uint8
+		wantErr                                      bool
+	}{
+		{"within quota", 1, 4, 1, 6, 3, false},
+		{"too many spaces", 2, 4, 1, 1, 3, true},
+		{"too many partitions", 1, 8, 1, 3, 3, true},
+		{"too many replicas", 0, 0, 1, 1, 5, true},
+		{"more partitions of space", 2, 8, 0, 2, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := quota.CheckQuota(tt.spaces, tt.partitions, tt.addSpaces, tt.addPartitions, tt.replicaNum); (err != nil) != tt.wantErr {
+				t.Errorf("DBQuota.CheckQuota() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var unlimited *DBQuota
+	if err := unlimited.CheckQuota(100, 1000, 1, 1, 9); err != nil {
+		t.Errorf("nil DBQuota.CheckQuota() error = %v", err)
+	}
+}
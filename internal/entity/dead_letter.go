@@ -0,0 +1,104 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	DeadLetterStageIngest = "ingest"
+	DeadLetterStageParse  = "parse"
+	DeadLetterStagePS     = "ps"
+
+	// fields of the documents of a dead letter space
+	DeadLetterFieldDocument = "document"
+	DeadLetterFieldError    = "error"
+	DeadLetterFieldSource   = "source"
+	DeadLetterFieldStage    = "stage"
+	DeadLetterFieldTime     = "time"
+
+	DefaultDeadLetterTimeout = 5000 // ms
+)
+
+// DeadLetter keeps the documents of the writes to a space the ingest
+// pipeline, the parsing of routers or ps reject, so a stream of writes does
+// not lose them. The rejected documents are written with their errors to a
+// space of the same db or posted as json to an http sink, and the other
+// documents of the write go on.
+type DeadLetter struct {
+	Space   string `json:"space,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Timeout int    `json:"timeout,omitempty"` // ms, of the http sink
+}
+
+// DeadLetterRecord is a rejected document, the body posted to sinks is an
+// array of records
+type DeadLetterRecord struct {
+	DbName    string `json:"db_name"`
+	SpaceName string `json:"space_name"`
+	Stage     string `json:"stage"`
+	Error     string `json:"error"`
+	Document  string `json:"document"` // the json written
+	Time      int64  `json:"time"`     // unix seconds
+}
+
+// Validate checks the dead letter has a space or an http url
+func (d *DeadLetter) Validate() error {
+	if (d.Space == "") == (d.URL == "") {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dead letter should have one of space and url"))
+	}
+	if d.URL != "" {
+		u, err := url.Parse(d.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dead letter url %s should be http or https", d.URL))
+		}
+	}
+	if d.Timeout <= 0 {
+		d.Timeout = DefaultDeadLetterTimeout
+	}
+	return nil
+}
+
+// ValidateDeadLetterSpace checks space can store dead letters: it needs the
+// string fields document and error, and may have the string fields source
+// and stage and the long or date field time. Its vector fields are written
+// as zero vectors.
+func ValidateDeadLetterSpace(space *Space) error {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	for _, field := range []string{DeadLetterFieldDocument, DeadLetterFieldError} {
+		if pro := proMap[field]; pro == nil || pro.FieldType != vearchpb.FieldType_STRING {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dead letter space %s should have string field [%s]", space.Name, field))
+		}
+	}
+	for _, field := range []string{DeadLetterFieldSource, DeadLetterFieldStage} {
+		if pro := proMap[field]; pro != nil && pro.FieldType != vearchpb.FieldType_STRING {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] of dead letter space %s should be string", field, space.Name))
+		}
+	}
+	if pro := proMap[DeadLetterFieldTime]; pro != nil && pro.FieldType != vearchpb.FieldType_LONG && pro.FieldType != vearchpb.FieldType_DATE {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] of dead letter space %s should be long or date", DeadLetterFieldTime, space.Name))
+	}
+	return nil
+}
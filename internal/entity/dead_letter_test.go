@@ -0,0 +1,75 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestDeadLetterValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		deadLetter DeadLetter
+		wantErr    bool
+	}{
+		{name: "space", deadLetter: DeadLetter{Space: "rejected"}},
+		{name: "url", deadLetter: DeadLetter{URL: "https://sink.example.com/dlq"}},
+		{name: "none", deadLetter: DeadLetter{}, wantErr: true},
+		{name: "space and url", deadLetter: DeadLetter{Space: "rejected", URL: "http://sink"}, wantErr: true},
+		{name: "not http", deadLetter: DeadLetter{URL: "kafka://broker/topic"}, wantErr: true},
+		{name: "no host", deadLetter: DeadLetter{URL: "http:///dlq"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.deadLetter
+			err := d.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && d.Timeout != DefaultDeadLetterTimeout {
+				t.Fatalf("Validate() timeout = %d, want %d", d.Timeout, DefaultDeadLetterTimeout)
+			}
+		})
+	}
+}
+
+func TestValidateDeadLetterSpace(t *testing.T) {
+	str := &SpaceProperties{FieldType: vearchpb.FieldType_STRING}
+	tests := []struct {
+		name    string
+		fields  map[string]*SpaceProperties
+		wantErr bool
+	}{
+		{name: "document and error", fields: map[string]*SpaceProperties{"document": str, "error": str}},
+		{name: "all fields", fields: map[string]*SpaceProperties{
+			"document": str, "error": str, "source": str, "stage": str,
+			"time":   {FieldType: vearchpb.FieldType_DATE},
+			"vector": {FieldType: vearchpb.FieldType_VECTOR, Dimension: 8},
+		}},
+		{name: "no error", fields: map[string]*SpaceProperties{"document": str}, wantErr: true},
+		{name: "int document", fields: map[string]*SpaceProperties{"document": {FieldType: vearchpb.FieldType_INT}, "error": str}, wantErr: true},
+		{name: "float time", fields: map[string]*SpaceProperties{"document": str, "error": str, "time": {FieldType: vearchpb.FieldType_FLOAT}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			space := &Space{Name: "rejected", SpaceProperties: tt.fields}
+			if err := ValidateDeadLetterSpace(space); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateDeadLetterSpace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
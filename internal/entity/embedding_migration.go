@@ -0,0 +1,96 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	MigrationRunning  = "running"
+	MigrationDone     = "done"
+	MigrationFailed   = "failed"
+	MigrationCanceled = "canceled"
+
+	DefaultMigrationRate      = 100 // documents per second
+	DefaultMigrationBatchSize = 32
+	MaxMigrationBatchSize     = 1000
+)
+
+var migrationNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,127}$`)
+
+// EmbeddingMigration re-embeds SourceField of the documents of a space into
+// the new vector field Target by an embedder of routers, and switches the
+// default vector field of the space to Target when all documents are done.
+// Partition and Cursor are the progress, the index of the partition in the
+// space and the last docid embedded in it
+type EmbeddingMigration struct {
+	Name        string `json:"name"`
+	DbName      string `json:"db_name"`
+	SpaceName   string `json:"space_name"`
+	SourceField string `json:"source_field"`
+	Target      *Field `json:"target"`
+	Embedder    string `json:"embedder"`
+	Rate        int    `json:"rate,omitempty"` // documents per second
+	BatchSize   int    `json:"batch_size,omitempty"`
+
+	Status     string `json:"status"`
+	Partition  int    `json:"partition"`
+	Cursor     int32  `json:"cursor"`
+	Embedded   int64  `json:"embedded"`
+	Skipped    int64  `json:"skipped"` // documents without source text
+	Error      string `json:"error,omitempty"`
+	Runner     string `json:"runner,omitempty"` // router running the migration
+	StartTime  int64  `json:"start_time"`
+	UpdateTime int64  `json:"update_time,omitempty"`
+	FinishTime int64  `json:"finish_time,omitempty"`
+}
+
+// Validate checks the migration to create and sets the defaults
+func (m *EmbeddingMigration) Validate() error {
+	if !migrationNameRegexp.MatchString(m.Name) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("migration name %q should be letters, digits, _ or -", m.Name))
+	}
+	if m.DbName == "" || m.SpaceName == "" || m.SourceField == "" || m.Embedder == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("db_name, space_name, source_field and embedder should not be empty"))
+	}
+	if m.Target == nil || m.Target.Name == "" || m.Target.Type != "vector" || m.Target.Dimension <= 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("target should be a vector field with name and dimension"))
+	}
+	if m.Target.Name == m.SourceField {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("target should not be the source field %s", m.SourceField))
+	}
+	if m.Rate < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rate should not be negative"))
+	}
+	if m.Rate == 0 {
+		m.Rate = DefaultMigrationRate
+	}
+	if m.BatchSize < 0 || m.BatchSize > MaxMigrationBatchSize {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("batch_size should be in [1, %d]", MaxMigrationBatchSize))
+	}
+	if m.BatchSize == 0 {
+		m.BatchSize = DefaultMigrationBatchSize
+	}
+	return nil
+}
+
+// Finished reports whether the migration stopped
+func (m *EmbeddingMigration) Finished() bool {
+	return m.Status != MigrationRunning
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestEmbeddingMigration_Validate(t *testing.T) {
+	valid := func() EmbeddingMigration {
+		return EmbeddingMigration{
+			Name:        "m1",
+			DbName:      "db",
+			SpaceName:   "space",
+			SourceField: "text",
+			Target:      &Field{Name: "vec2", Type: "vector", Dimension: 128},
+			Embedder:    "e5",
+		}
+	}
+	tests := []struct {
+		name      string
+		update    func(m *EmbeddingMigration)
+		wantErr   bool
+		wantRate  int
+		wantBatch int
+	}{
+		{
+			name:      "Defaults",
+			update:    func(m *EmbeddingMigration) {},
+			wantRate:  DefaultMigrationRate,
+			wantBatch: DefaultMigrationBatchSize,
+		},
+		{
+			name:      "Rate and batch kept",
+			update:    func(m *EmbeddingMigration) { m.Rate, m.BatchSize = 10, MaxMigrationBatchSize },
+			wantRate:  10,
+			wantBatch: MaxMigrationBatchSize,
+		},
+		{
+			name:    "Bad name",
+			update:  func(m *EmbeddingMigration) { m.Name = "-m" },
+			wantErr: true,
+		},
+		{
+			name:    "No embedder",
+			update:  func(m *EmbeddingMigration) { m.Embedder = "" },
+			wantErr: true,
+		},
+		{
+			name:    "Target not vector",
+			update:  func(m *EmbeddingMigration) { m.Target.Type = "string" },
+			wantErr: true,
+		},
+		{
+			name:    "Target without dimension",
+			update:  func(m *EmbeddingMigration) { m.Target.Dimension = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "Target is source",
+			update:  func(m *EmbeddingMigration) { m.Target.Name = m.SourceField },
+			wantErr: true,
+		},
+		{
+			name:    "Negative rate",
+			update:  func(m *EmbeddingMigration) { m.Rate = -1 },
+			wantErr: true,
+		},
+		{
+			name:    "Batch too large",
+			update:  func(m *EmbeddingMigration) { m.BatchSize = MaxMigrationBatchSize + 1 },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := valid()
+			tt.update(&m)
+			err := m.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (m.Rate != tt.wantRate || m.BatchSize != tt.wantBatch) {
+				t.Errorf("Validate() rate %d batch %d, want %d %d", m.Rate, m.BatchSize, tt.wantRate, tt.wantBatch)
+			}
+		})
+	}
+}
+
+func TestEmbeddingMigration_Finished(t *testing.T) {
+	for status, want := range map[string]bool{
+		MigrationRunning:  false,
+		MigrationDone:     true,
+		MigrationFailed:   true,
+		MigrationCanceled: true,
+	} {
+		m := &EmbeddingMigration{Status: status}
+		if got := m.Finished(); got != want {
+			t.Errorf("Finished() of %s = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -14,12 +14,49 @@
 
 package entity
 
+import "fmt"
+
 type EngineConfig struct {
 	EngineCacheSize *int64  `json:"engine_cache_size,omitempty"`
 	Path            *string `json:"path,omitempty"`
 	LongSearchTime  *int64  `json:"long_search_time,omitempty"`
 }
 
+// Validate checks the config before it is distributed to ps
+func (cfg *EngineConfig) Validate() error {
+	if cfg.EngineCacheSize == nil && cfg.Path == nil && cfg.LongSearchTime == nil {
+		return fmt.Errorf("engine config is empty")
+	}
+	if cfg.EngineCacheSize != nil && *cfg.EngineCacheSize < 0 {
+		return fmt.Errorf("engine_cache_size should not be negative")
+	}
+	if cfg.LongSearchTime != nil && *cfg.LongSearchTime < 0 {
+		return fmt.Errorf("long_search_time should not be negative")
+	}
+	if cfg.Path != nil && *cfg.Path == "" {
+		return fmt.Errorf("path should not be empty")
+	}
+	return nil
+}
+
+// Merge returns cfg with the fields set in other, cfg may be nil
+func (cfg *EngineConfig) Merge(other *EngineConfig) *EngineConfig {
+	merged := &EngineConfig{}
+	if cfg != nil {
+		*merged = *cfg
+	}
+	if other.EngineCacheSize != nil {
+		merged.EngineCacheSize = other.EngineCacheSize
+	}
+	if other.Path != nil {
+		merged.Path = other.Path
+	}
+	if other.LongSearchTime != nil {
+		merged.LongSearchTime = other.LongSearchTime
+	}
+	return merged
+}
+
 type EngineStatus struct {
 	IndexStatus   int32 `json:"index_status,omitempty"`
 	BackupStatus  int32 `json:"backup_status,omitempty"`
@@ -0,0 +1,41 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestEngineConfig_Validate(t *testing.T) {
+	size, negative := int64(1024), int64(-1)
+	path, empty := "/data", ""
+	tests := []struct {
+		name    string
+		cfg     EngineConfig
+		wantErr bool
+	}{
+		{name: "Valid cache size", cfg: EngineConfig{EngineCacheSize: &size}, wantErr: false},
+		{name: "Valid path and long search time", cfg: EngineConfig{Path: &path, LongSearchTime: &size}, wantErr: false},
+		{name: "Invalid empty config", cfg: EngineConfig{}, wantErr: true},
+		{name: "Invalid negative cache size", cfg: EngineConfig{EngineCacheSize: &negative}, wantErr: true},
+		{name: "Invalid negative long search time", cfg: EngineConfig{LongSearchTime: &negative}, wantErr: true},
+		{name: "Invalid empty path", cfg: EngineConfig{Path: &empty}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("EngineConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
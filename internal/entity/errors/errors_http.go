@@ -15,7 +15,7 @@
 package errors
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
@@ -40,87 +40,82 @@ func (e ErrRequest) HttpCode() int {
 	return e.httpCode
 }
 
-func NewErrBadRequest(err error) *ErrRequest {
-	if vErr, ok := err.(*vearchpb.VearchErr); ok {
-		return &ErrRequest{
-			err:      fmt.Errorf(vErr.Error()),
-			msg:      vErr.Error(),
-			code:     int(vErr.GetError().Code),
-			httpCode: http.StatusBadRequest,
+// the http status of the conditions of sentinel errors, they override the
+// status a handler picks for other errors
+var conditionStatus = []struct {
+	err    error
+	status int
+}{
+	{vearchpb.ErrSpaceNotFound, http.StatusNotFound},
+	{vearchpb.ErrDBNotFound, http.StatusNotFound},
+	{vearchpb.ErrPartitionNotFound, http.StatusNotFound},
+	{vearchpb.ErrAuthFailed, http.StatusUnauthorized},
+	{vearchpb.ErrOverloaded, http.StatusTooManyRequests},
+	{vearchpb.ErrPartitionNotLeader, http.StatusServiceUnavailable},
+	{vearchpb.ErrPartitionNoLeader, http.StatusServiceUnavailable},
+	{vearchpb.ErrUnavailable, http.StatusServiceUnavailable},
+	{vearchpb.ErrTimeout, http.StatusGatewayTimeout},
+}
+
+// HttpStatus is the http status of the condition of err, ok is false if err
+// is of no condition with a status of its own
+func HttpStatus(err error) (status int, ok bool) {
+	for _, c := range conditionStatus {
+		if errors.Is(err, c.err) {
+			return c.status, true
 		}
 	}
-	return &ErrRequest{
-		err:      err,
-		msg:      err.Error(),
-		code:     int(vearchpb.ErrorEnum_INTERNAL_ERROR),
-		httpCode: http.StatusBadRequest,
-	}
+	return 0, false
 }
 
-func NewErrUnprocessable(err error) *ErrRequest {
-	if vErr, ok := err.(*vearchpb.VearchErr); ok {
-		return &ErrRequest{
-			err:      fmt.Errorf(vErr.Error()),
-			msg:      vErr.Error(),
-			code:     int(vErr.GetError().Code),
-			httpCode: http.StatusUnprocessableEntity,
-		}
+// newErrRequest replies err by its code, or code if it is no VearchErr, and
+// by the status of its condition, or httpCode if it has none
+func newErrRequest(err error, code vearchpb.ErrorEnum, httpCode int) *ErrRequest {
+	var vErr *vearchpb.VearchErr
+	if errors.As(err, &vErr) {
+		code = vErr.GetError().Code
+	}
+	if status, ok := HttpStatus(err); ok {
+		httpCode = status
 	}
 	return &ErrRequest{
 		err:      err,
 		msg:      err.Error(),
-		code:     int(vearchpb.ErrorEnum_INTERNAL_ERROR),
-		httpCode: http.StatusUnprocessableEntity,
+		code:     int(code),
+		httpCode: httpCode,
 	}
 }
 
+// NewErr replies err by the status of its condition, others are internal
+// errors
+func NewErr(err error) *ErrRequest {
+	return newErrRequest(err, vearchpb.ErrorEnum_INTERNAL_ERROR, http.StatusInternalServerError)
+}
+
+func NewErrBadRequest(err error) *ErrRequest {
+	return newErrRequest(err, vearchpb.ErrorEnum_INTERNAL_ERROR, http.StatusBadRequest)
+}
+
+func NewErrUnprocessable(err error) *ErrRequest {
+	return newErrRequest(err, vearchpb.ErrorEnum_INTERNAL_ERROR, http.StatusUnprocessableEntity)
+}
+
 func NewErrNotFound(err error) *ErrRequest {
-	if vErr, ok := err.(*vearchpb.VearchErr); ok {
-		return &ErrRequest{
-			err:      fmt.Errorf(vErr.Error()),
-			msg:      vErr.Error(),
-			code:     int(vErr.GetError().Code),
-			httpCode: http.StatusNotFound,
-		}
-	}
-	return &ErrRequest{
-		err:      err,
-		msg:      err.Error(),
-		code:     int(vearchpb.ErrorEnum_INTERNAL_ERROR),
-		httpCode: http.StatusNotFound,
-	}
+	return newErrRequest(err, vearchpb.ErrorEnum_INTERNAL_ERROR, http.StatusNotFound)
 }
 
 func NewErrInternal(err error) *ErrRequest {
-	if vErr, ok := err.(*vearchpb.VearchErr); ok {
-		return &ErrRequest{
-			err:      fmt.Errorf(vErr.Error()),
-			msg:      vErr.Error(),
-			code:     int(vErr.GetError().Code),
-			httpCode: http.StatusInternalServerError,
-		}
-	}
-	return &ErrRequest{
-		err:      err,
-		msg:      err.Error(),
-		code:     int(vearchpb.ErrorEnum_INTERNAL_ERROR),
-		httpCode: http.StatusInternalServerError,
-	}
+	return newErrRequest(err, vearchpb.ErrorEnum_INTERNAL_ERROR, http.StatusInternalServerError)
 }
 
 func NewErrUnauthorized(err error) *ErrRequest {
-	if vErr, ok := err.(*vearchpb.VearchErr); ok {
-		return &ErrRequest{
-			err:      fmt.Errorf(vErr.Error()),
-			msg:      vErr.Error(),
-			code:     int(vErr.GetError().Code),
-			httpCode: http.StatusUnauthorized,
-		}
-	}
-	return &ErrRequest{
-		err:      err,
-		msg:      err.Error(),
-		code:     int(vearchpb.ErrorEnum_AUTHENTICATION_FAILED),
-		httpCode: http.StatusUnauthorized,
-	}
+	return newErrRequest(err, vearchpb.ErrorEnum_AUTHENTICATION_FAILED, http.StatusUnauthorized)
+}
+
+func NewErrUnavailable(err error) *ErrRequest {
+	return newErrRequest(err, vearchpb.ErrorEnum_SERVICE_UNAVAILABLE, http.StatusServiceUnavailable)
+}
+
+func NewErrPreconditionFailed(err error) *ErrRequest {
+	return newErrRequest(err, vearchpb.ErrorEnum_PARAM_ERROR, http.StatusPreconditionFailed)
 }
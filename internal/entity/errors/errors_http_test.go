@@ -0,0 +1,48 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestNewErrRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *ErrRequest
+		code     vearchpb.ErrorEnum
+		httpCode int
+	}{
+		{name: "bad request", err: NewErrBadRequest(errors.New("bad")), code: vearchpb.ErrorEnum_INTERNAL_ERROR, httpCode: http.StatusBadRequest},
+		{name: "vearch code", err: NewErrBadRequest(vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, nil)), code: vearchpb.ErrorEnum_PARAM_ERROR, httpCode: http.StatusBadRequest},
+		{name: "space not found", err: NewErrInternal(vearchpb.NewError(vearchpb.ErrorEnum_SPACE_NOT_EXIST, fmt.Errorf("space ts"))), code: vearchpb.ErrorEnum_SPACE_NOT_EXIST, httpCode: http.StatusNotFound},
+		{name: "wrapped not leader", err: NewErrBadRequest(fmt.Errorf("search: %w", vearchpb.ErrPartitionNotLeader)), code: vearchpb.ErrorEnum_PARTITION_NOT_LEADER, httpCode: http.StatusServiceUnavailable},
+		{name: "overloaded", err: NewErr(vearchpb.ErrOverloaded), code: vearchpb.ErrorEnum_PARTITION_RESOURCE_EXHAUSTED, httpCode: http.StatusTooManyRequests},
+		{name: "unauthorized", err: NewErrUnauthorized(errors.New("no user")), code: vearchpb.ErrorEnum_AUTHENTICATION_FAILED, httpCode: http.StatusUnauthorized},
+		{name: "internal", err: NewErr(errors.New("broken")), code: vearchpb.ErrorEnum_INTERNAL_ERROR, httpCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.Code() != int(tt.code) || tt.err.HttpCode() != tt.httpCode {
+				t.Errorf("code %d http code %d, want %d and %d", tt.err.Code(), tt.err.HttpCode(), tt.code, tt.httpCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	DefaultEtcdMaintenanceInterval        = 3600 // seconds
+	DefaultEtcdMaintenanceRetainRevisions = 10000
+	DefaultEtcdMaintenanceDefragRatio     = 1.5
+	DefaultEtcdMaintenanceDefragMinSize   = 64 // MB
+	DefaultEtcdMaintenanceDefragPause     = 30 // seconds
+)
+
+// EtcdMaintenanceConfig is the etcd compaction and defragmentation settings
+// of cluster, stored in etcd and changed by the master api at runtime. The
+// revisions older than the latest RetainRevisions are compacted, then the
+// members whose db size is at least DefragMinSize MB and DefragRatio times the
+// size in use are defragmented one by one, the leader last
+type EtcdMaintenanceConfig struct {
+	Enabled         bool    `json:"enabled"`
+	Interval        int64   `json:"interval,omitempty"` // seconds
+	RetainRevisions int64   `json:"retain_revisions,omitempty"`
+	DefragRatio     float64 `json:"defrag_ratio,omitempty"`
+	DefragMinSize   int64   `json:"defrag_min_size,omitempty"` // MB
+	DefragPause     int64   `json:"defrag_pause,omitempty"`    // seconds between members
+}
+
+func NewEtcdMaintenanceConfig() *EtcdMaintenanceConfig {
+	return &EtcdMaintenanceConfig{
+		Interval:        DefaultEtcdMaintenanceInterval,
+		RetainRevisions: DefaultEtcdMaintenanceRetainRevisions,
+		DefragRatio:     DefaultEtcdMaintenanceDefragRatio,
+		DefragMinSize:   DefaultEtcdMaintenanceDefragMinSize,
+		DefragPause:     DefaultEtcdMaintenanceDefragPause,
+	}
+}
+
+func (ec *EtcdMaintenanceConfig) Validate() error {
+	if ec.Interval < 0 || ec.RetainRevisions < 0 || ec.DefragRatio < 0 || ec.DefragMinSize < 0 || ec.DefragPause < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("etcd maintenance interval, retain_revisions, defrag_ratio, defrag_min_size and defrag_pause should not be negative"))
+	}
+	if ec.DefragRatio != 0 && ec.DefragRatio < 1 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("etcd maintenance defrag_ratio should not be less than 1"))
+	}
+	if ec.Interval == 0 {
+		ec.Interval = DefaultEtcdMaintenanceInterval
+	}
+	if ec.RetainRevisions == 0 {
+		ec.RetainRevisions = DefaultEtcdMaintenanceRetainRevisions
+	}
+	if ec.DefragRatio == 0 {
+		ec.DefragRatio = DefaultEtcdMaintenanceDefragRatio
+	}
+	if ec.DefragMinSize == 0 {
+		ec.DefragMinSize = DefaultEtcdMaintenanceDefragMinSize
+	}
+	if ec.DefragPause == 0 {
+		ec.DefragPause = DefaultEtcdMaintenanceDefragPause
+	}
+	return nil
+}
+
+// NeedDefrag returns whether a member with the db size and the size in use
+// in bytes is fragmented enough to defragment
+func (ec *EtcdMaintenanceConfig) NeedDefrag(dbSize, dbSizeInUse int64) bool {
+	if dbSize < ec.DefragMinSize*1024*1024 {
+		return false
+	}
+	return float64(dbSize) >= ec.DefragRatio*float64(dbSizeInUse)
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// the router behaviors can be gated by feature flags
+const (
+	FeatureShadow       = "shadow"
+	FeatureReadRepair   = "read_repair"
+	FeatureFairQueue    = "fair_queue"
+	FeatureSearchBudget = "search_budget"
+)
+
+var featureNames = map[string]bool{
+	FeatureShadow:       true,
+	FeatureReadRepair:   true,
+	FeatureFairQueue:    true,
+	FeatureSearchBudget: true,
+}
+
+// FeatureFlag gates a router behavior, routers watch the flags and a behavior
+// without flag runs as configured. A disabled flag turns the behavior off,
+// an enabled one turns it on for the spaces in Spaces as db/space, all if
+// empty, and for Percent of the tenants, all if not set. A percent of zero
+// reaches no tenant
+type FeatureFlag struct {
+	Name    string   `json:"name"`
+	Enabled bool     `json:"enabled"`
+	Percent *float64 `json:"percent,omitempty"`
+	Spaces  []string `json:"spaces,omitempty"`
+}
+
+func (f *FeatureFlag) Validate() error {
+	if !featureNames[f.Name] {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unknown feature flag [%s]", f.Name))
+	}
+	if f.Percent != nil && (*f.Percent < 0 || *f.Percent > 100) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("feature flag percent should be in [0, 100]"))
+	}
+	for _, space := range f.Spaces {
+		if parts := strings.Split(space, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("feature flag space [%s] should be db/space", space))
+		}
+	}
+	return nil
+}
+
+// On tells whether the behavior is on for the space, bucket is the stable
+// position of the tenant in [0, 100)
+func (f *FeatureFlag) On(dbName, spaceName string, bucket float64) bool {
+	if !f.Enabled {
+		return false
+	}
+	if len(f.Spaces) > 0 {
+		found := false
+		for _, space := range f.Spaces {
+			if space == dbName+"/"+spaceName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return bucket < f.Share()
+}
+
+// Share is the percent of the tenants the flag reaches, 100 if not set
+func (f *FeatureFlag) Share() float64 {
+	if f.Percent == nil {
+		return 100
+	}
+	return *f.Percent
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func percent(p float64) *float64 { return &p }
+
+func TestFeatureFlag_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    FeatureFlag
+		wantErr bool
+	}{
+		{
+			name:    "Valid flag for all",
+			flag:    FeatureFlag{Name: FeatureShadow, Enabled: true},
+			wantErr: false,
+		},
+		{
+			name:    "Valid flag by percent and space",
+			flag:    FeatureFlag{Name: FeatureFairQueue, Enabled: true, Percent: percent(5), Spaces: []string{"db/ts_space"}},
+			wantErr: false,
+		},
+		{
+			name:    "Invalid flag with unknown name",
+			flag:    FeatureFlag{Name: "hedging", Enabled: true},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid flag with percent beyond 100",
+			flag:    FeatureFlag{Name: FeatureReadRepair, Percent: percent(101)},
+			wantErr: true,
+		},
+		{
+			name:    "Valid flag with percent zero",
+			flag:    FeatureFlag{Name: FeatureReadRepair, Enabled: true, Percent: percent(0)},
+			wantErr: false,
+		},
+		{
+			name:    "Invalid flag with space without db",
+			flag:    FeatureFlag{Name: FeatureSearchBudget, Spaces: []string{"ts_space"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.flag.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("FeatureFlag.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFeatureFlag_On(t *testing.T) {
+	off := &FeatureFlag{Name: FeatureShadow, Percent: percent(100)}
+	if off.On("db", "space", 0) {
+		t.Errorf("disabled flag should be off")
+	}
+	all := &FeatureFlag{Name: FeatureShadow, Enabled: true}
+	if !all.On("db", "space", 99.9) {
+		t.Errorf("enabled flag without percent should be on for all tenants")
+	}
+	none := &FeatureFlag{Name: FeatureShadow, Enabled: true, Percent: percent(0)}
+	if none.On("db", "space", 0) {
+		t.Errorf("enabled flag at zero percent should be off for all tenants")
+	}
+	rollout := &FeatureFlag{Name: FeatureShadow, Enabled: true, Percent: percent(10), Spaces: []string{"db/space"}}
+	if !rollout.On("db", "space", 5) {
+		t.Errorf("flag should be on for tenant in percent")
+	}
+	if rollout.On("db", "space", 50) {
+		t.Errorf("flag should be off for tenant beyond percent")
+	}
+	if rollout.On("db", "other", 5) {
+		t.Errorf("flag should be off for space not listed")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/hlc"
+)
+
+// FencingTTL is how long a fencing token permits writes, leaders renew it at
+// a third of the ttl. A leader change waits for the token of the old leader
+// to expire only if the old leader did not release it, it crashed or was cut
+// off from master, so the ttl bounds the write outage of that case
+const FencingTTL = 10 * time.Second
+
+// FencingToken permits LeaderID to acknowledge the writes of the partition
+// from NotBefore until Expire. Master issues the token of the next Epoch to a
+// new leader starting when the token of the old leader expires or is
+// released, so a deposed leader cut off from master stops writing before the
+// new one starts. Times are hybrid logical timestamps of master, a node
+// observes Issued before checking so its clock is never behind master
+type FencingToken struct {
+	PartitionID PartitionID `json:"partition_id"`
+	LeaderID    NodeID      `json:"leader_id"`
+	Epoch       uint64      `json:"epoch"`
+	Issued      int64       `json:"issued"`
+	NotBefore   int64       `json:"not_before"`
+	Expire      int64       `json:"expire"`
+}
+
+// FencingRequest renews the fencing tokens of the partitions led by NodeID
+// in one transaction, or releases them if Release so the next leaders write
+// without waiting for them to expire
+type FencingRequest struct {
+	NodeID       NodeID        `json:"node_id"`
+	PartitionIDs []PartitionID `json:"partition_ids"`
+	Release      bool          `json:"release,omitempty"`
+}
+
+// NextFencingToken renews old if it is held by leader, else issues the next
+// epoch to leader starting no earlier than old expires, now is a hybrid
+// logical timestamp
+func NextFencingToken(old *FencingToken, pid PartitionID, leader NodeID, now int64, ttl time.Duration) *FencingToken {
+	if old == nil {
+		return &FencingToken{PartitionID: pid, LeaderID: leader, Epoch: 1, Issued: now, NotBefore: now, Expire: now + int64(ttl)}
+	}
+	if old.LeaderID == leader {
+		token := *old
+		token.Issued = now
+		if expire := now + int64(ttl); expire > token.Expire {
+			token.Expire = expire
+		}
+		return &token
+	}
+	notBefore := now
+	if old.Expire > notBefore {
+		notBefore = old.Expire
+	}
+	return &FencingToken{PartitionID: pid, LeaderID: leader, Epoch: old.Epoch + 1, Issued: now, NotBefore: notBefore, Expire: notBefore + int64(ttl)}
+}
+
+// Release ends the token at now if it is not expired
+func (t *FencingToken) Release(now int64) {
+	if t.Expire > now {
+		t.Expire = now
+	}
+}
+
+// Check returns an error if the token does not permit node to write at now,
+// a hybrid logical timestamp
+func (t *FencingToken) Check(nodeID NodeID, now int64) error {
+	if t == nil {
+		return fmt.Errorf("no fencing token")
+	}
+	if t.LeaderID != nodeID {
+		return fmt.Errorf("fencing token of epoch [%d] is held by node [%d]", t.Epoch, t.LeaderID)
+	}
+	if now < t.NotBefore {
+		return fmt.Errorf("fencing token of epoch [%d] is valid after %s", t.Epoch, hlc.Physical(t.NotBefore).Format(time.RFC3339Nano))
+	}
+	if now >= t.Expire {
+		return fmt.Errorf("fencing token of epoch [%d] expired at %s", t.Epoch, hlc.Physical(t.Expire).Format(time.RFC3339Nano))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextFencingToken(t *testing.T) {
+	now := time.Unix(1000, 0).UnixNano()
+	ttl := 10 * time.Second
+	at := func(d time.Duration) int64 { return now + int64(d) }
+
+	first := NextFencingToken(nil, 1, 1, now, ttl)
+	if first.Epoch != 1 || first.Check(1, now) != nil {
+		t.Fatalf("first token %+v should permit node 1", first)
+	}
+
+	renewed := NextFencingToken(first, 1, 1, at(5*time.Second), ttl)
+	if renewed.Epoch != 1 || renewed.Expire != at(15*time.Second) || renewed.Issued != at(5*time.Second) {
+		t.Fatalf("renewed token %+v should keep the epoch and extend the expire", renewed)
+	}
+
+	// the new leader waits for the token of the old one to expire
+	next := NextFencingToken(renewed, 1, 2, at(6*time.Second), ttl)
+	if next.Epoch != 2 || next.NotBefore != renewed.Expire {
+		t.Fatalf("next token %+v should start when epoch 1 expires", next)
+	}
+	if next.Check(2, at(6*time.Second)) == nil {
+		t.Fatal("new leader should not write before the old token expires")
+	}
+	if renewed.Check(1, at(15*time.Second)) == nil {
+		t.Fatal("old leader should not write after its token expires")
+	}
+	if next.Check(2, at(15*time.Second)) != nil {
+		t.Fatal("new leader should write after the old token expires")
+	}
+	if next.Check(1, at(15*time.Second)) == nil {
+		t.Fatal("old leader should not write with the token of the new leader")
+	}
+}
+
+func TestFencingToken_Release(t *testing.T) {
+	now := time.Unix(1000, 0).UnixNano()
+	ttl := 10 * time.Second
+	tests := []struct {
+		name          string
+		releaseAt     int64
+		nextAt        int64
+		wantNotBefore int64
+	}{
+		{
+			name:          "Released token lets next leader write at once",
+			releaseAt:     now + int64(time.Second),
+			nextAt:        now + int64(2*time.Second),
+			wantNotBefore: now + int64(2*time.Second),
+		},
+		{
+			name:          "Release after expire keeps expire",
+			releaseAt:     now + int64(20*time.Second),
+			nextAt:        now + int64(21*time.Second),
+			wantNotBefore: now + int64(21*time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := NextFencingToken(nil, 1, 1, now, ttl)
+			old.Release(tt.releaseAt)
+			if old.Check(1, tt.releaseAt) == nil {
+				t.Fatal("released token should not permit writes")
+			}
+			next := NextFencingToken(old, 1, 2, tt.nextAt, ttl)
+			if next.NotBefore != tt.wantNotBefore || next.Check(2, tt.nextAt) != nil {
+				t.Errorf("next token %+v, want not before %d", next, tt.wantNotBefore)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// GlobalAlias names a space of this cluster, or of the remote cluster of
+// routers named Cluster. Searches targeting the name follow the alias, so
+// applications switch between local and remote spaces without changes
+type GlobalAlias struct {
+	Name      string `json:"name"`
+	Cluster   string `json:"cluster,omitempty"`
+	DbName    string `json:"db_name"`
+	SpaceName string `json:"space_name"`
+}
+
+func (a *GlobalAlias) Validate() error {
+	if err := (&Alias{Name: a.Name}).Validate(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(a.Cluster, ":/") {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("global alias cluster [%s] should not have : or /", a.Cluster))
+	}
+	if a.DbName == "" || a.SpaceName == "" || strings.Contains(a.DbName, "/") || strings.Contains(a.SpaceName, "/") {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("global alias %s should have db_name and space_name without /", a.Name))
+	}
+	return nil
+}
+
+// Target is the [cluster:]db/space the alias names
+func (a *GlobalAlias) Target() string {
+	if a.Cluster == "" {
+		return a.DbName + "/" + a.SpaceName
+	}
+	return a.Cluster + ":" + a.DbName + "/" + a.SpaceName
+}
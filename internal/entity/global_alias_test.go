@@ -0,0 +1,43 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestGlobalAliasValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		alias      GlobalAlias
+		wantTarget string
+		wantErr    bool
+	}{
+		{name: "local", alias: GlobalAlias{Name: "products", DbName: "db", SpaceName: "space"}, wantTarget: "db/space"},
+		{name: "remote", alias: GlobalAlias{Name: "products", Cluster: "eu", DbName: "db", SpaceName: "space"}, wantTarget: "eu:db/space"},
+		{name: "name with slash", alias: GlobalAlias{Name: "a/b", DbName: "db", SpaceName: "space"}, wantErr: true},
+		{name: "cluster with colon", alias: GlobalAlias{Name: "products", Cluster: "e:u", DbName: "db", SpaceName: "space"}, wantErr: true},
+		{name: "no space", alias: GlobalAlias{Name: "products", DbName: "db"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.alias.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.alias.Target() != tt.wantTarget {
+				t.Errorf("Target() = %s, want %s", tt.alias.Target(), tt.wantTarget)
+			}
+		})
+	}
+}
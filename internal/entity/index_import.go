@@ -0,0 +1,140 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	IndexImportCommandStart  = "start"
+	IndexImportCommandStatus = "status"
+
+	IndexImportRunning = "running"
+	IndexImportDone    = "done"
+	IndexImportFailed  = "failed"
+)
+
+// IndexManifestFile is the name of the manifest next to the index files of
+// a partition, which are under <prefix>/<partition_id>/ of the bucket
+const IndexManifestFile = "manifest.json"
+
+// ImportableIndexFiles are the files of the index types whose dumps can be
+// built offline, as the engine names them in the dump of a vector field
+var ImportableIndexFiles = map[string][]string{
+	"HNSW": {"hnswlib.index"},
+}
+
+// IndexImport loads the index of a vector field built offline into every
+// replica of the partitions of a space
+type IndexImport struct {
+	Command string  `json:"command,omitempty"`
+	Field   string  `json:"field"`
+	Prefix  string  `json:"prefix"`
+	S3Param S3Param `json:"s3_param"`
+}
+
+// IndexManifest describes the index files of a partition, the index covers
+// the first VectorNum documents of the partition in docid order
+type IndexManifest struct {
+	Field          string `json:"field"`
+	IndexType      string `json:"index_type"`
+	Dimension      int    `json:"dimension"`
+	MetricType     string `json:"metric_type"`
+	Nlinks         int    `json:"nlinks,omitempty"`
+	EfConstruction int    `json:"efConstruction,omitempty"`
+	VectorNum      int64  `json:"vector_num"`
+	// file name to its hex sha256
+	Files map[string]string `json:"files"`
+}
+
+type IndexImportStatus struct {
+	PartitionID PartitionID `json:"partition_id"`
+	NodeID      NodeID      `json:"node_id,omitempty"`
+	Field       string      `json:"field,omitempty"`
+	State       string      `json:"state"`
+	Error       string      `json:"error,omitempty"`
+	StartTime   int64       `json:"start_time,omitempty"`
+	EndTime     int64       `json:"end_time,omitempty"`
+}
+
+// Validate checks the manifest was built for the field of space with the
+// same index type and parameters
+func (m *IndexManifest) Validate(space *Space, field string) error {
+	if m.Field != field {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("manifest is for field %s but import field is %s", m.Field, field))
+	}
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	pro, ok := proMap[field]
+	if !ok || pro.FieldType != vearchpb.FieldType_VECTOR {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s is not a vector field of space %s", field, space.Name))
+	}
+	if pro.Index == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s has no index", field))
+	}
+	indexFiles, ok := ImportableIndexFiles[pro.Index.Type]
+	if !ok {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index type %s of field %s can not be imported", pro.Index.Type, field))
+	}
+	if m.IndexType != pro.Index.Type {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("manifest index type %s but field %s has %s", m.IndexType, field, pro.Index.Type))
+	}
+	if m.Dimension != pro.Dimension {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("manifest dimension %d but field %s has %d", m.Dimension, field, pro.Dimension))
+	}
+
+	params := &IndexParams{}
+	if len(pro.Index.Params) > 0 {
+		if err := json.Unmarshal(pro.Index.Params, params); err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index params of field %s: %v", field, err))
+		}
+	}
+	if params.MetricType == "" {
+		params.MetricType = DefaultMetricType
+	}
+	if m.MetricType != params.MetricType {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("manifest metric type %s but field %s has %s", m.MetricType, field, params.MetricType))
+	}
+	if m.Nlinks != params.Nlinks || m.EfConstruction != params.EfConstruction {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("manifest nlinks %d efConstruction %d but field %s has %d %d", m.Nlinks, m.EfConstruction, field, params.Nlinks, params.EfConstruction))
+	}
+
+	if m.VectorNum <= 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("manifest vector_num should be greater than 0"))
+	}
+	if len(m.Files) != len(indexFiles) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("manifest files of %s index should be %v", pro.Index.Type, indexFiles))
+	}
+	for _, name := range indexFiles {
+		sum, ok := m.Files[name]
+		if !ok {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("manifest files of %s index should be %v", pro.Index.Type, indexFiles))
+		}
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != 32 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("checksum of %s should be a hex sha256", name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// buckets of the equi depth histogram of a numeric field
+	statsBuckets = 16
+	// most frequent values kept of a string field
+	statsTopValues = 32
+)
+
+// IndexStats are the statistics of the scalar fields of a partition the ps
+// samples periodically, the router plans the filters of searches and
+// queries with them
+type IndexStats struct {
+	PartitionID PartitionID            `json:"partition_id"`
+	DocNum      uint64                 `json:"doc_num"`
+	Sampled     int                    `json:"sampled"`    // documents sampled
+	UpdatedAt   int64                  `json:"updated_at"` // unix seconds
+	Fields      map[string]*FieldStats `json:"fields,omitempty"`
+}
+
+// FieldStats are the statistics of a scalar field of the sampled documents
+type FieldStats struct {
+	Count       int   `json:"count"`       // sampled documents with a value
+	Cardinality int64 `json:"cardinality"` // distinct values of the partition estimated
+	// Bounds of the equi depth histogram of a numeric field, each bucket
+	// between two bounds holds the same share of the values
+	Bounds []float64 `json:"bounds,omitempty"`
+	// Top are the counts of the most frequent values of a string field
+	Top map[string]int `json:"top,omitempty"`
+}
+
+// StatsSampler builds the IndexStats of the documents sampled
+type StatsSampler struct {
+	sampled int
+	numbers map[string][]float64
+	terms   map[string]map[string]int
+}
+
+func NewStatsSampler() *StatsSampler {
+	return &StatsSampler{numbers: make(map[string][]float64), terms: make(map[string]map[string]int)}
+}
+
+// AddDoc counts a sampled document, its values are added one by one
+func (s *StatsSampler) AddDoc() {
+	s.sampled++
+}
+
+func (s *StatsSampler) AddNumber(field string, value float64) {
+	s.numbers[field] = append(s.numbers[field], value)
+}
+
+func (s *StatsSampler) AddTerm(field, value string) {
+	terms := s.terms[field]
+	if terms == nil {
+		terms = make(map[string]int)
+		s.terms[field] = terms
+	}
+	terms[value]++
+}
+
+// Stats returns the statistics of a partition of docNum documents
+func (s *StatsSampler) Stats(pid PartitionID, docNum uint64, updatedAt int64) *IndexStats {
+	stats := &IndexStats{PartitionID: pid, DocNum: docNum, Sampled: s.sampled, UpdatedAt: updatedAt, Fields: make(map[string]*FieldStats)}
+	for field, values := range s.numbers {
+		sort.Float64s(values)
+		counts := make(map[float64]int)
+		for _, v := range values {
+			counts[v]++
+		}
+		fs := &FieldStats{Count: len(values), Cardinality: cardinality(docNum, s.sampled, len(values), counts)}
+		buckets := statsBuckets
+		if len(values)-1 < buckets {
+			buckets = len(values) - 1
+		}
+		if buckets == 0 {
+			fs.Bounds = []float64{values[0], values[0]}
+		} else {
+			fs.Bounds = make([]float64, buckets+1)
+			for i := range fs.Bounds {
+				fs.Bounds[i] = values[i*(len(values)-1)/buckets]
+			}
+		}
+		stats.Fields[field] = fs
+	}
+	for field, terms := range s.terms {
+		fs := &FieldStats{}
+		for _, n := range terms {
+			fs.Count += n
+		}
+		fs.Cardinality = cardinality(docNum, s.sampled, fs.Count, terms)
+		values := make([]string, 0, len(terms))
+		for v := range terms {
+			values = append(values, v)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if terms[values[i]] != terms[values[j]] {
+				return terms[values[i]] > terms[values[j]]
+			}
+			return values[i] < values[j]
+		})
+		if len(values) > statsTopValues {
+			values = values[:statsTopValues]
+		}
+		fs.Top = make(map[string]int, len(values))
+		for _, v := range values {
+			fs.Top[v] = terms[v]
+		}
+		stats.Fields[field] = fs
+	}
+	return stats
+}
+
+// cardinality estimates the distinct values of docNum documents from the
+// counts of the n values sampled by the GEE estimator, the values seen once
+// stand for the ones not sampled
+func cardinality[K comparable](docNum uint64, sampled, n int, counts map[K]int) int64 {
+	distinct, once := len(counts), 0
+	for _, k := range counts {
+		if k == 1 {
+			once++
+		}
+	}
+	if n == 0 || sampled == 0 {
+		return int64(distinct)
+	}
+	total := float64(docNum) * float64(n) / float64(sampled)
+	estimate := math.Sqrt(math.Max(total/float64(n), 1))*float64(once) + float64(distinct-once)
+	return int64(math.Round(math.Min(estimate, math.Max(total, float64(distinct)))))
+}
+
+// presence is the share of the documents with a value of the field
+func (s *IndexStats) presence(fs *FieldStats) float64 {
+	if s.Sampled == 0 {
+		return 1
+	}
+	return float64(fs.Count) / float64(s.Sampled)
+}
+
+// RangeSelectivity estimates the share of the documents with a value of the
+// numeric field between lower and upper, 1 if the field is not sampled
+func (s *IndexStats) RangeSelectivity(field string, lower, upper float64) float64 {
+	fs := s.Fields[field]
+	if fs == nil || len(fs.Bounds) < 2 {
+		return 1
+	}
+	if lower > upper {
+		return 0
+	}
+	share := quantile(fs.Bounds, upper, true) - quantile(fs.Bounds, lower, false)
+	return clampShare(share * s.presence(fs))
+}
+
+// quantile interpolates the share of the values less than x, or not
+// greater than x if inclusive, in the histogram of bounds
+func quantile(bounds []float64, x float64, inclusive bool) float64 {
+	buckets := len(bounds) - 1
+	i := sort.Search(len(bounds), func(i int) bool {
+		if inclusive {
+			return bounds[i] > x
+		}
+		return bounds[i] >= x
+	})
+	if i == 0 {
+		return 0
+	}
+	if i == len(bounds) {
+		return 1
+	}
+	lo, hi := bounds[i-1], bounds[i]
+	return (float64(i-1) + (x-lo)/(hi-lo)) / float64(buckets)
+}
+
+// TermSelectivity estimates the share of the documents with one of values
+// in the string field, the values not in the top share what the top leaves
+// evenly. 1 if the field is not sampled
+func (s *IndexStats) TermSelectivity(field string, values []string) float64 {
+	fs := s.Fields[field]
+	if fs == nil || fs.Count == 0 {
+		return 1
+	}
+	rest, topCount := fs.Count, 0
+	for _, n := range fs.Top {
+		topCount += n
+	}
+	rest -= topCount
+	unseen := fs.Cardinality - int64(len(fs.Top))
+	share := 0.0
+	for _, v := range values {
+		if n, ok := fs.Top[v]; ok {
+			share += float64(n) / float64(fs.Count)
+		} else if unseen > 0 {
+			share += float64(rest) / float64(fs.Count) / float64(unseen)
+		}
+	}
+	return clampShare(share * s.presence(fs))
+}
+
+func clampShare(share float64) float64 {
+	return math.Max(0, math.Min(1, share))
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func sampleStats() *IndexStats {
+	s := NewStatsSampler()
+	// 1000 documents sampled of 10000, age uniform in [0, 100), city
+	// skewed and set on half of the documents
+	for i := 0; i < 1000; i++ {
+		s.AddDoc()
+		s.AddNumber("age", float64(i%100))
+		if i%2 == 0 {
+			city := "beijing"
+			if i%10 != 0 {
+				city = fmt.Sprintf("city%d", i)
+			}
+			s.AddTerm("city", city)
+		}
+	}
+	return s.Stats(1, 10000, 0)
+}
+
+func TestIndexStatsRangeSelectivity(t *testing.T) {
+	stats := sampleStats()
+	tests := []struct {
+		name         string
+		field        string
+		lower, upper float64
+		want         float64
+	}{
+		{name: "Whole range", field: "age", lower: math.Inf(-1), upper: math.Inf(1), want: 1},
+		{name: "Half", field: "age", lower: 0, upper: 49.5, want: 0.5},
+		{name: "Tenth", field: "age", lower: 90, upper: 99, want: 0.1},
+		{name: "Out of range", field: "age", lower: 200, upper: 300, want: 0},
+		{name: "Empty range", field: "age", lower: 50, upper: 40, want: 0},
+		{name: "Field not sampled", field: "height", lower: 0, upper: 1, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stats.RangeSelectivity(tt.field, tt.lower, tt.upper); math.Abs(got-tt.want) > 0.05 {
+				t.Errorf("RangeSelectivity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexStatsTermSelectivity(t *testing.T) {
+	stats := sampleStats()
+	city := stats.Fields["city"]
+	if city.Count != 500 || len(city.Top) != statsTopValues || city.Top["beijing"] != 100 {
+		t.Fatalf("city stats = count %d top %d beijing %d", city.Count, len(city.Top), city.Top["beijing"])
+	}
+	// 400 values seen once of 5000 documents with a city
+	if city.Cardinality < 1000 || city.Cardinality > 5000 {
+		t.Errorf("city cardinality = %d", city.Cardinality)
+	}
+	tests := []struct {
+		name     string
+		values   []string
+		min, max float64
+	}{
+		{name: "Frequent value", values: []string{"beijing"}, min: 0.09, max: 0.11},
+		{name: "Rare value", values: []string{"nowhere"}, min: 0, max: 0.001},
+		{name: "No value", min: 0, max: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stats.TermSelectivity("city", tt.values); got < tt.min || got > tt.max {
+				t.Errorf("TermSelectivity() = %v, want in [%v, %v]", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestStatsSamplerSingleValue(t *testing.T) {
+	s := NewStatsSampler()
+	for i := 0; i < 10; i++ {
+		s.AddDoc()
+		s.AddNumber("status", 1)
+	}
+	stats := s.Stats(1, 10, 0)
+	if got := stats.RangeSelectivity("status", 1, 1); got != 1 {
+		t.Errorf("RangeSelectivity() of the only value = %v, want 1", got)
+	}
+	if got := stats.RangeSelectivity("status", 2, 3); got != 0 {
+		t.Errorf("RangeSelectivity() beyond the only value = %v, want 0", got)
+	}
+	if stats.Fields["status"].Cardinality != 1 {
+		t.Errorf("cardinality = %d, want 1", stats.Fields["status"].Cardinality)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	IngestSet    = "set"
+	IngestRename = "rename"
+	IngestTrim   = "trim"
+	IngestHash   = "hash"
+	IngestEmbed  = "embed"
+	IngestDrop   = "drop"
+
+	IngestHashMD5    = "md5"
+	IngestHashSHA1   = "sha1"
+	IngestHashSHA256 = "sha256"
+
+	MaxIngestProcessors = 32
+)
+
+// IngestProcessor is a processor of the ingest pipeline of a space, routers
+// run the processors in order on each document to write before routing it to
+// a partition:
+//
+//	set     sets field to value
+//	rename  moves field to target_field
+//	trim    trims the spaces around the string of field
+//	hash    sets target_field to the hex digest of fields
+//	embed   sets the vector target_field to the embedding of the text of
+//	        field by an embedder of routers
+//	drop    drops the documents matching condition
+type IngestProcessor struct {
+	Type          string           `json:"type"`
+	Field         string           `json:"field,omitempty"`
+	TargetField   string           `json:"target_field,omitempty"`
+	Value         json.RawMessage  `json:"value,omitempty"`
+	Fields        []string         `json:"fields,omitempty"`
+	Algorithm     string           `json:"algorithm,omitempty"` // of hash, sha256 if not set
+	Embedder      string           `json:"embedder,omitempty"`
+	Condition     *IngestCondition `json:"condition,omitempty"`
+	IgnoreMissing bool             `json:"ignore_missing,omitempty"` // skip documents without field instead of failing
+}
+
+// IngestCondition matches the documents whose field compares to value by
+// operator, exists and missing do not have value. Numbers compare as
+// numbers, strings as strings and other values only by equality.
+type IngestCondition struct {
+	Field    string          `json:"field"`
+	Operator string          `json:"operator"`
+	Value    json.RawMessage `json:"value,omitempty"`
+}
+
+var ingestOperators = map[string]bool{"exists": true, "missing": true, "=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// ValidateIngestPipeline checks the processors against the fields of space
+// and fills defaults, the embedders are checked by routers
+func (space *Space) ValidateIngestPipeline(processors []*IngestProcessor) error {
+	if len(processors) > MaxIngestProcessors {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("ingest pipeline should not exceed %d processors", MaxIngestProcessors))
+	}
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	// fieldOf checks a field written by a processor is of one of types, _id
+	// is a string field
+	fieldOf := func(i int, field string, types ...vearchpb.FieldType) error {
+		fieldType := vearchpb.FieldType_STRING
+		if field != IdField {
+			pro := proMap[field]
+			if pro == nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] of ingest processor %d not space field", field, i))
+			}
+			fieldType = pro.FieldType
+		}
+		for _, t := range types {
+			if t == fieldType {
+				return nil
+			}
+		}
+		if len(types) == 0 {
+			return nil
+		}
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] of ingest processor %d should be %v", field, i, types))
+	}
+	for i, p := range processors {
+		if p == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("ingest processor %d is null", i))
+		}
+		var err error
+		switch p.Type {
+		case IngestSet:
+			if len(p.Value) == 0 || !json.Valid(p.Value) {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("set ingest processor %d should have a json value", i))
+			}
+			err = fieldOf(i, p.Field)
+		case IngestRename:
+			if p.Field == "" || p.Field == p.TargetField {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rename ingest processor %d should have field other than target_field", i))
+			}
+			err = fieldOf(i, p.TargetField)
+		case IngestTrim:
+			err = fieldOf(i, p.Field, vearchpb.FieldType_STRING)
+		case IngestHash:
+			if len(p.Fields) == 0 {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("hash ingest processor %d should have fields", i))
+			}
+			if p.Algorithm == "" {
+				p.Algorithm = IngestHashSHA256
+			}
+			if newIngestHash(p.Algorithm) == nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("hash ingest processor %d algorithm should be %s, %s or %s", i, IngestHashMD5, IngestHashSHA1, IngestHashSHA256))
+			}
+			err = fieldOf(i, p.TargetField, vearchpb.FieldType_STRING)
+		case IngestEmbed:
+			if p.Field == "" || p.Embedder == "" {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embed ingest processor %d should have field and embedder", i))
+			}
+			err = fieldOf(i, p.TargetField, vearchpb.FieldType_VECTOR)
+		case IngestDrop:
+			c := p.Condition
+			if c == nil || c.Field == "" || !ingestOperators[c.Operator] {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("drop ingest processor %d should have a condition of field and operator", i))
+			}
+			if (c.Operator == "exists" || c.Operator == "missing") != (len(c.Value) == 0) || (len(c.Value) > 0 && !json.Valid(c.Value)) {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("drop ingest processor %d should have a json value except for exists and missing", i))
+			}
+		default:
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unknown type %s of ingest processor %d", p.Type, i))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newIngestHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case IngestHashMD5:
+		return md5.New()
+	case IngestHashSHA1:
+		return sha1.New()
+	case IngestHashSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// Apply runs the processor on doc, it returns whether to drop the document.
+// Embed processors are run by routers in batches and skipped here.
+func (p *IngestProcessor) Apply(doc map[string]json.RawMessage) (bool, error) {
+	switch p.Type {
+	case IngestSet:
+		doc[p.Field] = p.Value
+	case IngestRename:
+		value, ok := doc[p.Field]
+		if !ok {
+			return false, p.missing(p.Field)
+		}
+		delete(doc, p.Field)
+		doc[p.TargetField] = value
+	case IngestTrim:
+		value, ok := doc[p.Field]
+		if !ok {
+			return false, p.missing(p.Field)
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return false, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("trim field [%s] should be string", p.Field))
+		}
+		trimmed, _ := json.Marshal(strings.TrimSpace(s))
+		doc[p.Field] = trimmed
+	case IngestHash:
+		h := newIngestHash(p.Algorithm)
+		if h == nil {
+			h = sha256.New()
+		}
+		for i, field := range p.Fields {
+			if i > 0 {
+				h.Write([]byte{0})
+			}
+			value, ok := doc[field]
+			if !ok {
+				if err := p.missing(field); err != nil {
+					return false, err
+				}
+				continue
+			}
+			var s string
+			if json.Unmarshal(value, &s) == nil {
+				h.Write([]byte(s))
+			} else {
+				h.Write(bytes.TrimSpace(value))
+			}
+		}
+		digest, _ := json.Marshal(hex.EncodeToString(h.Sum(nil)))
+		doc[p.TargetField] = digest
+	case IngestDrop:
+		return p.Condition.Match(doc), nil
+	}
+	return false, nil
+}
+
+func (p *IngestProcessor) missing(field string) error {
+	if p.IgnoreMissing {
+		return nil
+	}
+	return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s ingest processor: document has no field [%s]", p.Type, field))
+}
+
+// Match tells whether doc matches the condition
+func (c *IngestCondition) Match(doc map[string]json.RawMessage) bool {
+	value, ok := doc[c.Field]
+	if ok && bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+		ok = false
+	}
+	switch c.Operator {
+	case "exists":
+		return ok
+	case "missing":
+		return !ok
+	}
+	if !ok {
+		return false
+	}
+	var cmp int
+	var a, b float64
+	var sa, sb string
+	if json.Unmarshal(value, &a) == nil && json.Unmarshal(c.Value, &b) == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	} else if json.Unmarshal(value, &sa) == nil && json.Unmarshal(c.Value, &sb) == nil {
+		cmp = strings.Compare(sa, sb)
+	} else {
+		var va, vb interface{}
+		if json.Unmarshal(value, &va) != nil || json.Unmarshal(c.Value, &vb) != nil {
+			return false
+		}
+		equal := fmt.Sprint(va) == fmt.Sprint(vb)
+		switch c.Operator {
+		case "=":
+			return equal
+		case "!=":
+			return !equal
+		}
+		return false
+	}
+	switch c.Operator {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
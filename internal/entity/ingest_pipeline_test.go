@@ -0,0 +1,157 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestValidateIngestPipeline(t *testing.T) {
+	space := &Space{SpaceProperties: map[string]*SpaceProperties{
+		"title":  {FieldType: vearchpb.FieldType_STRING},
+		"price":  {FieldType: vearchpb.FieldType_FLOAT},
+		"vector": {FieldType: vearchpb.FieldType_VECTOR},
+	}}
+	tests := []struct {
+		name      string
+		processor IngestProcessor
+		wantErr   bool
+	}{
+		{name: "set", processor: IngestProcessor{Type: IngestSet, Field: "price", Value: json.RawMessage(`1`)}},
+		{name: "set without value", processor: IngestProcessor{Type: IngestSet, Field: "price"}, wantErr: true},
+		{name: "rename", processor: IngestProcessor{Type: IngestRename, Field: "name", TargetField: "title"}},
+		{name: "rename to unknown field", processor: IngestProcessor{Type: IngestRename, Field: "title", TargetField: "name"}, wantErr: true},
+		{name: "trim number", processor: IngestProcessor{Type: IngestTrim, Field: "price"}, wantErr: true},
+		{name: "hash to id", processor: IngestProcessor{Type: IngestHash, Fields: []string{"title"}, TargetField: IdField}},
+		{name: "hash algorithm", processor: IngestProcessor{Type: IngestHash, Fields: []string{"title"}, TargetField: IdField, Algorithm: "crc"}, wantErr: true},
+		{name: "embed", processor: IngestProcessor{Type: IngestEmbed, Field: "title", TargetField: "vector", Embedder: "e"}},
+		{name: "embed to string", processor: IngestProcessor{Type: IngestEmbed, Field: "title", TargetField: "title", Embedder: "e"}, wantErr: true},
+		{name: "drop", processor: IngestProcessor{Type: IngestDrop, Condition: &IngestCondition{Field: "price", Operator: "<", Value: json.RawMessage(`0`)}}},
+		{name: "drop without value", processor: IngestProcessor{Type: IngestDrop, Condition: &IngestCondition{Field: "price", Operator: "<"}}, wantErr: true},
+		{name: "unknown type", processor: IngestProcessor{Type: "script"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.processor
+			if err := space.ValidateIngestPipeline([]*IngestProcessor{&p}); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateIngestPipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIngestProcessorApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor IngestProcessor
+		doc       string
+		want      string
+		wantDrop  bool
+		wantErr   bool
+	}{
+		{
+			name:      "set",
+			processor: IngestProcessor{Type: IngestSet, Field: "price", Value: json.RawMessage(`2.5`)},
+			doc:       `{"title":"a"}`,
+			want:      `{"price":2.5,"title":"a"}`,
+		},
+		{
+			name:      "rename",
+			processor: IngestProcessor{Type: IngestRename, Field: "name", TargetField: "title"},
+			doc:       `{"name":"a"}`,
+			want:      `{"title":"a"}`,
+		},
+		{
+			name:      "rename missing",
+			processor: IngestProcessor{Type: IngestRename, Field: "name", TargetField: "title"},
+			doc:       `{"title":"a"}`,
+			wantErr:   true,
+		},
+		{
+			name:      "rename ignore missing",
+			processor: IngestProcessor{Type: IngestRename, Field: "name", TargetField: "title", IgnoreMissing: true},
+			doc:       `{"title":"a"}`,
+			want:      `{"title":"a"}`,
+		},
+		{
+			name:      "trim",
+			processor: IngestProcessor{Type: IngestTrim, Field: "title"},
+			doc:       `{"title":"  a b \n"}`,
+			want:      `{"title":"a b"}`,
+		},
+		{
+			name:      "hash",
+			processor: IngestProcessor{Type: IngestHash, Fields: []string{"title"}, TargetField: IdField, Algorithm: IngestHashMD5},
+			doc:       `{"title":"a"}`,
+			want:      `{"_id":"0cc175b9c0f1b6a831c399e269772661","title":"a"}`,
+		},
+		{
+			name:      "drop less",
+			processor: IngestProcessor{Type: IngestDrop, Condition: &IngestCondition{Field: "price", Operator: "<", Value: json.RawMessage(`0`)}},
+			doc:       `{"price":-1}`,
+			wantDrop:  true,
+		},
+		{
+			name:      "keep less",
+			processor: IngestProcessor{Type: IngestDrop, Condition: &IngestCondition{Field: "price", Operator: "<", Value: json.RawMessage(`0`)}},
+			doc:       `{"price":1}`,
+			want:      `{"price":1}`,
+		},
+		{
+			name:      "drop missing",
+			processor: IngestProcessor{Type: IngestDrop, Condition: &IngestCondition{Field: "title", Operator: "missing"}},
+			doc:       `{"title":null}`,
+			wantDrop:  true,
+		},
+		{
+			name:      "drop equal string",
+			processor: IngestProcessor{Type: IngestDrop, Condition: &IngestCondition{Field: "title", Operator: "=", Value: json.RawMessage(`"spam"`)}},
+			doc:       `{"title":"spam"}`,
+			wantDrop:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := make(map[string]json.RawMessage)
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			drop, err := tt.processor.Apply(doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if drop != tt.wantDrop {
+				t.Fatalf("Apply() drop = %v, want %v", drop, tt.wantDrop)
+			}
+			if drop {
+				return
+			}
+			var got, want interface{}
+			b, _ := json.Marshal(doc)
+			_ = json.Unmarshal(b, &got)
+			_ = json.Unmarshal([]byte(tt.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Apply() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// kinds of the jobs whose events are streamed, the id of a job is its kind
+// and name joined by a colon, like similarity_join:dedup
+const (
+	JobEmbeddingMigration = "embedding_migration"
+	JobSimilarityJoin     = "similarity_join"
+)
+
+// events of the stream of a job
+const (
+	JobEventState    = "state"    // the status of the job changed
+	JobEventProgress = "progress" // the job went on in the same status
+	JobEventDeleted  = "deleted"  // the job was deleted
+)
+
+// JobEvent is the state of a job pushed to the clients of its event stream,
+// Progress is the percentage of the partitions done and Done the documents
+// embedded or joined
+type JobEvent struct {
+	ID         string  `json:"id"`
+	Status     string  `json:"status"`
+	Progress   float64 `json:"progress"`
+	Done       int64   `json:"done"`
+	Error      string  `json:"error,omitempty"`
+	UpdateTime int64   `json:"update_time,omitempty"`
+}
+
+// ParseJobID splits id into the kind and name of the job
+func ParseJobID(id string) (kind, name string, err error) {
+	kind, name, found := strings.Cut(id, ":")
+	if !found || !migrationNameRegexp.MatchString(name) {
+		return "", "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("job id %q should be kind:name", id))
+	}
+	switch kind {
+	case JobEmbeddingMigration, JobSimilarityJoin:
+		return kind, name, nil
+	}
+	return "", "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("job kind %q should be %s or %s", kind, JobEmbeddingMigration, JobSimilarityJoin))
+}
+
+// JobKey returns the key in etcd of the job of kind
+func JobKey(kind, name string) string {
+	if kind == JobSimilarityJoin {
+		return SimilarityJoinKey(name)
+	}
+	return EmbeddingMigrationKey(name)
+}
+
+// Event returns the state of the migration of a space of partitions
+func (m *EmbeddingMigration) Event(partitions int) *JobEvent {
+	return &JobEvent{
+		ID:         JobEmbeddingMigration + ":" + m.Name,
+		Status:     m.Status,
+		Progress:   jobProgress(m.Status, m.Partition, partitions),
+		Done:       m.Embedded,
+		Error:      m.Error,
+		UpdateTime: m.UpdateTime,
+	}
+}
+
+// Event returns the state of the join of a space of partitions
+func (j *SimilarityJoin) Event(partitions int) *JobEvent {
+	return &JobEvent{
+		ID:         JobSimilarityJoin + ":" + j.Name,
+		Status:     j.Status,
+		Progress:   jobProgress(j.Status, j.Partition, partitions),
+		Done:       j.Joined,
+		Error:      j.Error,
+		UpdateTime: j.UpdateTime,
+	}
+}
+
+// Next returns the event sent after prev for e, empty if e is no news
+func (e *JobEvent) Next(prev *JobEvent) string {
+	switch {
+	case prev == nil || prev.Status != e.Status:
+		return JobEventState
+	case prev.Progress != e.Progress || prev.Done != e.Done:
+		return JobEventProgress
+	}
+	return ""
+}
+
+// jobProgress is the percentage of partitions done, a done job is complete
+// whatever the partitions it went through
+func jobProgress(status string, partition, partitions int) float64 {
+	if status == MigrationDone {
+		return 100
+	}
+	if partitions <= 0 || partition <= 0 {
+		return 0
+	}
+	if partition >= partitions {
+		return 100
+	}
+	return float64(partition*10000/partitions) / 100
+}
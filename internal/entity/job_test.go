@@ -0,0 +1,92 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestParseJobID(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		wantKind string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "migration", id: "embedding_migration:m-1", wantKind: JobEmbeddingMigration, wantName: "m-1"},
+		{name: "join", id: "similarity_join:dedup", wantKind: JobSimilarityJoin, wantName: "dedup"},
+		{name: "no kind", id: "dedup", wantErr: true},
+		{name: "unknown kind", id: "backup:dedup", wantErr: true},
+		{name: "bad name", id: "similarity_join:a/b", wantErr: true},
+		{name: "empty name", id: "similarity_join:", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, name, err := ParseJobID(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJobID(%q) err = %v, wantErr %v", tt.id, err, tt.wantErr)
+			}
+			if kind != tt.wantKind || name != tt.wantName {
+				t.Errorf("ParseJobID(%q) = %q, %q, want %q, %q", tt.id, kind, name, tt.wantKind, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestJobEvent_Next(t *testing.T) {
+	running := (&SimilarityJoin{Name: "j", Status: MigrationRunning, Partition: 1, Joined: 10}).Event(4)
+	tests := []struct {
+		name string
+		prev *JobEvent
+		cur  *JobEvent
+		want string
+	}{
+		{name: "first", cur: running, want: JobEventState},
+		{name: "same", prev: running, cur: running, want: ""},
+		{name: "more docs", prev: running, cur: (&SimilarityJoin{Name: "j", Status: MigrationRunning, Partition: 1, Joined: 20}).Event(4), want: JobEventProgress},
+		{name: "next partition", prev: running, cur: (&SimilarityJoin{Name: "j", Status: MigrationRunning, Partition: 2, Joined: 10}).Event(4), want: JobEventProgress},
+		{name: "failed", prev: running, cur: (&SimilarityJoin{Name: "j", Status: MigrationFailed, Partition: 1, Joined: 10}).Event(4), want: JobEventState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cur.Next(tt.prev); got != tt.want {
+				t.Errorf("Next() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobProgress(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		partition  int
+		partitions int
+		want       float64
+	}{
+		{name: "not started", status: MigrationRunning, partition: 0, partitions: 3, want: 0},
+		{name: "one of three", status: MigrationRunning, partition: 1, partitions: 3, want: 33.33},
+		{name: "all partitions", status: MigrationRunning, partition: 3, partitions: 3, want: 100},
+		{name: "unknown partitions", status: MigrationRunning, partition: 2, partitions: 0, want: 0},
+		{name: "done", status: MigrationDone, partition: 0, partitions: 3, want: 100},
+		{name: "canceled", status: MigrationCanceled, partition: 2, partitions: 4, want: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jobProgress(tt.status, tt.partition, tt.partitions); got != tt.want {
+				t.Errorf("jobProgress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	DefaultLeaderBalanceInterval = 300 // seconds
+	DefaultLeaderBalanceHotRatio = 2.0
+	DefaultLeaderBalanceMinQps   = 10.0
+	DefaultLeaderBalanceMaxMoves = 1
+)
+
+// LeaderBalanceConfig is the hot partition leader spreading settings of
+// cluster, stored in etcd and changed by the master api at runtime. A
+// partition is hot when the qps of its leader is at least MinQps and HotRatio
+// times the mean qps of all partitions
+type LeaderBalanceConfig struct {
+	Enabled  bool    `json:"enabled"`
+	Interval int64   `json:"interval,omitempty"` // seconds
+	HotRatio float64 `json:"hot_ratio,omitempty"`
+	MinQps   float64 `json:"min_qps,omitempty"`
+	MaxMoves int     `json:"max_moves,omitempty"` // leader transfers by interval
+}
+
+func NewLeaderBalanceConfig() *LeaderBalanceConfig {
+	return &LeaderBalanceConfig{
+		Interval: DefaultLeaderBalanceInterval,
+		HotRatio: DefaultLeaderBalanceHotRatio,
+		MinQps:   DefaultLeaderBalanceMinQps,
+		MaxMoves: DefaultLeaderBalanceMaxMoves,
+	}
+}
+
+func (lc *LeaderBalanceConfig) Validate() error {
+	if lc.Interval < 0 || lc.HotRatio < 0 || lc.MinQps < 0 || lc.MaxMoves < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("leader balance interval, hot_ratio, min_qps and max_moves should not be negative"))
+	}
+	if lc.HotRatio != 0 && lc.HotRatio < 1 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("leader balance hot_ratio should not be less than 1"))
+	}
+	if lc.Interval == 0 {
+		lc.Interval = DefaultLeaderBalanceInterval
+	}
+	if lc.HotRatio == 0 {
+		lc.HotRatio = DefaultLeaderBalanceHotRatio
+	}
+	if lc.MinQps == 0 {
+		lc.MinQps = DefaultLeaderBalanceMinQps
+	}
+	if lc.MaxMoves == 0 {
+		lc.MaxMoves = DefaultLeaderBalanceMaxMoves
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// Leadership places the leaders of the partitions of a space by the
+// priorities of their replicas. A replica takes the highest priority of the
+// rules its server matches, 0 without any. Elections between replicas with
+// logs as up to date are won by the higher priority, and masters move the
+// leaders back to the replicas of the highest priority which are alive.
+type Leadership struct {
+	Priorities []*LeaderPriority `json:"priorities,omitempty"`
+}
+
+// LeaderPriority matches the servers by zone, rack or node id
+type LeaderPriority struct {
+	Zone     string `json:"zone,omitempty"`
+	Rack     string `json:"rack,omitempty"`
+	NodeID   NodeID `json:"node_id,omitempty"`
+	Priority int    `json:"priority"`
+}
+
+// Validate checks every rule matches servers with a priority in (0, 65535]
+func (l *Leadership) Validate() error {
+	for _, p := range l.Priorities {
+		if p == nil || (p.Zone == "" && p.Rack == "" && p.NodeID == 0) {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("leader priority should have zone, rack or node_id"))
+		}
+		if p.Priority <= 0 || p.Priority > math.MaxUint16 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("leader priority [%d] should be in (0, %d]", p.Priority, math.MaxUint16))
+		}
+	}
+	return nil
+}
+
+func (p *LeaderPriority) match(server *Server) bool {
+	return (p.Zone == "" || p.Zone == server.HostZone) &&
+		(p.Rack == "" || p.Rack == server.HostRack) &&
+		(p.NodeID == 0 || p.NodeID == server.ID)
+}
+
+// Priority is the priority of the replica on server, 0 for servers unknown
+func (l *Leadership) Priority(server *Server) uint16 {
+	if l == nil || server == nil {
+		return 0
+	}
+	var priority uint16
+	for _, p := range l.Priorities {
+		if p.match(server) && uint16(p.Priority) > priority {
+			priority = uint16(p.Priority)
+		}
+	}
+	return priority
+}
+
+// PreferredLeaders are the replicas of the highest priority on the servers,
+// replicas on no server are not alive and never preferred. It is empty when
+// no replica has a priority.
+func (l *Leadership) PreferredLeaders(replicas []NodeID, servers map[NodeID]*Server) []NodeID {
+	var (
+		top       uint16
+		preferred []NodeID
+	)
+	for _, id := range replicas {
+		server := servers[id]
+		if server == nil {
+			continue
+		}
+		priority := l.Priority(server)
+		switch {
+		case priority == 0 || priority < top:
+		case priority > top:
+			top, preferred = priority, []NodeID{id}
+		default:
+			preferred = append(preferred, id)
+		}
+	}
+	return preferred
+}
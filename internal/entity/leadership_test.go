@@ -0,0 +1,104 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLeadershipValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		leadership *Leadership
+		wantErr    bool
+	}{
+		{
+			name:       "Zone rule",
+			leadership: &Leadership{Priorities: []*LeaderPriority{{Zone: "a", Priority: 1}}},
+		},
+		{
+			name:       "No rule",
+			leadership: &Leadership{},
+		},
+		{
+			name:       "Rule matching every server",
+			leadership: &Leadership{Priorities: []*LeaderPriority{{Priority: 1}}},
+			wantErr:    true,
+		},
+		{
+			name:       "Zero priority",
+			leadership: &Leadership{Priorities: []*LeaderPriority{{Rack: "r1"}}},
+			wantErr:    true,
+		},
+		{
+			name:       "Priority too large",
+			leadership: &Leadership{Priorities: []*LeaderPriority{{NodeID: 1, Priority: 1 << 16}}},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.leadership.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLeadershipPreferredLeaders(t *testing.T) {
+	servers := map[NodeID]*Server{
+		1: {ID: 1, HostZone: "a", HostRack: "r1"},
+		2: {ID: 2, HostZone: "a", HostRack: "r2"},
+		3: {ID: 3, HostZone: "b", HostRack: "r1"},
+	}
+	tests := []struct {
+		name       string
+		leadership *Leadership
+		replicas   []NodeID
+		want       []NodeID
+	}{
+		{
+			name:       "Replicas of the zone",
+			leadership: &Leadership{Priorities: []*LeaderPriority{{Zone: "a", Priority: 1}}},
+			replicas:   []NodeID{1, 2, 3},
+			want:       []NodeID{1, 2},
+		},
+		{
+			name:       "Highest priority of the rules matched",
+			leadership: &Leadership{Priorities: []*LeaderPriority{{Zone: "a", Priority: 1}, {Zone: "a", Rack: "r2", Priority: 2}}},
+			replicas:   []NodeID{1, 2, 3},
+			want:       []NodeID{2},
+		},
+		{
+			name:       "Replica without server",
+			leadership: &Leadership{Priorities: []*LeaderPriority{{NodeID: 4, Priority: 5}, {Zone: "b", Priority: 1}}},
+			replicas:   []NodeID{3, 4},
+			want:       []NodeID{3},
+		},
+		{
+			name:       "No replica with priority",
+			leadership: &Leadership{Priorities: []*LeaderPriority{{Zone: "c", Priority: 1}}},
+			replicas:   []NodeID{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.leadership.PreferredLeaders(tt.replicas, servers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PreferredLeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -43,6 +43,28 @@ func PartitionKey(partitionID uint32) string {
 	return fmt.Sprintf("%s%d", PrefixPartition, partitionID)
 }
 
+func FencingTokenKey(partitionID uint32) string {
+	return fmt.Sprintf("%s%d", PrefixFencingToken, partitionID)
+}
+
+func EmbeddingMigrationKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixEmbeddingMigration, name)
+}
+
+// EmbeddingMigrationLockKey is locked by the router running the migration
+func EmbeddingMigrationLockKey(name string) string {
+	return fmt.Sprintf("embedding_migration/%s", name)
+}
+
+func SimilarityJoinKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixSimilarityJoin, name)
+}
+
+// SimilarityJoinLockKey is locked by the router running the join
+func SimilarityJoinLockKey(name string) string {
+	return fmt.Sprintf("similarity_join/%s", name)
+}
+
 func DBKeyId(id int64) string {
 	return fmt.Sprintf("%sid/%d", PrefixDataBase, id)
 }
@@ -81,6 +103,12 @@ func RouterKey(key, value string) string {
 	return fmt.Sprintf("%s%s/%s", PrefixRouter, key, value)
 }
 
+// RouterRegistrationKey is the key a healthy router of cluster registers
+// itself under by its address
+func RouterRegistrationKey(cluster, addr string) string {
+	return fmt.Sprintf("%s%s/%s", PrefixRouters, cluster, addr)
+}
+
 func AliasKey(aliasName string) string {
 	return fmt.Sprintf("%s%s", PrefixAlias, aliasName)
 }
@@ -93,6 +121,60 @@ func LockAliasKey(aliasName string) string {
 	return fmt.Sprintf("%s%s", PrefixLock, aliasName)
 }
 
+// SettingsKey generate dynamic settings key
+func SettingsKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixSettings, name)
+}
+
+func WebhookKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixWebhook, name)
+}
+
+func LockWebhookKey(name string) string {
+	return fmt.Sprintf("%swebhook/%s", PrefixLock, name)
+}
+
+func ResourceGroupKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixResourceGroup, name)
+}
+
+func LockResourceGroupKey(name string) string {
+	return fmt.Sprintf("%sresource_group/%s", PrefixLock, name)
+}
+
+func SpaceTemplateKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixSpaceTemplate, name)
+}
+
+func LockSpaceTemplateKey(name string) string {
+	return fmt.Sprintf("%sspace_template/%s", PrefixLock, name)
+}
+
+func CronJobKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixCronJob, name)
+}
+
+func LockCronJobKey(name string) string {
+	return fmt.Sprintf("%scron_job/%s", PrefixLock, name)
+}
+
+// CronRunKey orders the runs of a job by the id, the start time in nanoseconds
+func CronRunKey(name string, id int64) string {
+	return fmt.Sprintf("%s%s/%020d", PrefixCronRun, name, id)
+}
+
+func CronRunPrefix(name string) string {
+	return fmt.Sprintf("%s%s/", PrefixCronRun, name)
+}
+
+func FeatureFlagKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixFeatureFlag, name)
+}
+
+func GlobalAliasKey(name string) string {
+	return fmt.Sprintf("%s%s", PrefixGlobalAlias, name)
+}
+
 func SetPrefixAndSequence(cluster_id string) {
 	if strings.HasPrefix(cluster_id, Prefix) {
 		PrefixEtcdClusterID = cluster_id
@@ -115,9 +197,25 @@ func SetPrefixAndSequence(cluster_id string) {
 	PrefixDataBaseBody = PrefixEtcdClusterID + PrefixDataBaseBody
 	PrefixFailServer = PrefixEtcdClusterID + PrefixFailServer
 	PrefixRouter = PrefixEtcdClusterID + PrefixRouter
+	PrefixRouters = PrefixEtcdClusterID + PrefixRouters
 	PrefixAlias = PrefixEtcdClusterID + PrefixAlias
 	PrefixRole = PrefixEtcdClusterID + PrefixRole
 	PrefixMasterMember = PrefixEtcdClusterID + PrefixMasterMember
+	PrefixWebhook = PrefixEtcdClusterID + PrefixWebhook
+	PrefixSettings = PrefixEtcdClusterID + PrefixSettings
+	PrefixResourceGroup = PrefixEtcdClusterID + PrefixResourceGroup
+	PrefixSpaceTemplate = PrefixEtcdClusterID + PrefixSpaceTemplate
+	PrefixCronJob = PrefixEtcdClusterID + PrefixCronJob
+	PrefixCronRun = PrefixEtcdClusterID + PrefixCronRun
+	PrefixUsage = PrefixEtcdClusterID + PrefixUsage
+	PrefixFencingToken = PrefixEtcdClusterID + PrefixFencingToken
+	PrefixEmbeddingMigration = PrefixEtcdClusterID + PrefixEmbeddingMigration
+	PrefixSimilarityJoin = PrefixEtcdClusterID + PrefixSimilarityJoin
+	PrefixFeatureFlag = PrefixEtcdClusterID + PrefixFeatureFlag
+	PrefixGlobalAlias = PrefixEtcdClusterID + PrefixGlobalAlias
+	PrefixQuarantine = PrefixEtcdClusterID + PrefixQuarantine
+	PrefixMetaSchema = PrefixEtcdClusterID + PrefixMetaSchema
+	PrefixMetaShadow = PrefixEtcdClusterID + PrefixMetaShadow
 }
 
 // sids sequence key for etcd
@@ -141,6 +239,7 @@ var (
 	PrefixDataBaseBody = "/db/body/"
 	PrefixFailServer   = "/fail/server/"
 	PrefixRouter       = "/router/"
+	PrefixRouters      = "/routers/"
 	PrefixNodeId       = "/id/node"
 	PrefixSpaceId      = "/id/space"
 	PrefixDBId         = "/id/db"
@@ -148,6 +247,27 @@ var (
 	PrefixAlias        = "/alias/"
 	PrefixRole         = "/role/"
 	PrefixMasterMember = "/member/"
+	PrefixWebhook      = "/webhook/"
+	PrefixSettings     = "/settings/"
+
+	PrefixResourceGroup = "/resource_group/"
+	PrefixSpaceTemplate = "/space_template/"
+	PrefixCronJob       = "/cron_job/"
+	PrefixCronRun       = "/cron_run/"
+	PrefixUsage         = "/usage/"
+	PrefixFencingToken  = "/fencing_token/"
+
+	PrefixEmbeddingMigration = "/embedding_migration/"
+	PrefixSimilarityJoin     = "/similarity_join/"
+
+	PrefixFeatureFlag = "/feature_flag/"
+	PrefixGlobalAlias = "/global_alias/"
+	PrefixQuarantine  = "/quarantine/"
+
+	PrefixMetaSchema = "/meta_schema/"
+	// the keys of the target schema of kinds dual written, under their key
+	// without the cluster prefix
+	PrefixMetaShadow = "/meta_shadow"
 )
 
 var PrefixEtcdClusterID = "/vearch/default/"
@@ -161,11 +281,44 @@ const ClusterWatchServerKeyPut = "watch/server/put"
 const ClusterWatchServerKeyDelete = "watch/server/delete"
 const ClusterWatchServerKeyScan = "watch/server/scan"
 
+// ClusterWatchServerKeyWebhook for server event webhook lock
+const ClusterWatchServerKeyWebhook = "watch/server/webhook"
+
+// ClusterAlertKey for alert evaluation lock
+const ClusterAlertKey = "cluster/alert"
+
+// ClusterLeaderBalanceKey for leader balance lock
+const ClusterLeaderBalanceKey = "cluster/leader_balance"
+
+// ClusterLeaderPlacementKey for leader placement lock
+const ClusterLeaderPlacementKey = "cluster/leader_placement"
+
+// ClusterEtcdMaintenanceKey for etcd compaction and defragmentation lock
+const ClusterEtcdMaintenanceKey = "cluster/etcd_maintenance"
+
+// ClusterEtcdMaintenanceNextKey holds the unix time of the next etcd
+// maintenance, masters skip the maintenance before it
+const ClusterEtcdMaintenanceNextKey = "/cluster/etcd_maintenance_next"
+
+// ClusterMetadataImportKey for metadata import lock
+const ClusterMetadataImportKey = "cluster/metadata_import"
+
+// ClusterOrphanCheckKey for orphan partition check lock
+const ClusterOrphanCheckKey = "cluster/orphan_check"
+
+// ClusterUsageCleanKey for usage clean lock
+const ClusterUsageCleanKey = "cluster/usage_clean"
+
+// ClusterMetaSchemaKey for metadata schema migration lock
+const ClusterMetaSchemaKey = "cluster/meta_schema"
+
 // rpc time out, default 10 * 1000 ms
 type CTX_KEY string
 
 var (
 	RPC_TIME_OUT CTX_KEY = "rpc_timeout"
+	// tenant of a search or query, ps queues the requests of tenants fairly
+	TENANT CTX_KEY = "tenant"
 )
 
 type (
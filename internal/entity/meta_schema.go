@@ -0,0 +1,178 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// the metadata kinds whose schema is versioned
+const (
+	MetaKindRole = "role"
+)
+
+const (
+	// MetaSchemaV1 stores the json of the entity
+	MetaSchemaV1 = 1
+	// MetaSchemaV2 stores the json of the entity in an envelope naming its
+	// kind and schema version
+	MetaSchemaV2 = 2
+	// MetaSchemaLatest is the newest version this build reads and writes
+	MetaSchemaLatest = MetaSchemaV2
+)
+
+// the modes of a metadata schema
+const (
+	MetaSchemaSingle = "single"
+	MetaSchemaDual   = "dual"
+)
+
+// MetaSchema is the version the master writes the metadata of a kind in. In
+// dual mode the master also writes each entity in Target under the shadow
+// key, so the new representation can be validated against the old one before
+// finalize rewrites the keys in Target. Readers decode either version
+type MetaSchema struct {
+	Kind       string `json:"kind"`
+	Version    int    `json:"version"`
+	Target     int    `json:"target,omitempty"`
+	Mode       string `json:"mode"`
+	UpdateTime int64  `json:"update_time,omitempty"`
+}
+
+// MetaSchemaStatus is a metadata schema with the validation of the keys of
+// its kind, Missing are keys without shadow and Mismatched keys whose shadow
+// does not decode to the same entity
+type MetaSchemaStatus struct {
+	*MetaSchema
+	Keys       int         `json:"keys"`
+	Versions   map[int]int `json:"versions"` // number of keys in each version
+	Shadows    int         `json:"shadows"`
+	Missing    []string    `json:"missing,omitempty"`
+	Mismatched []string    `json:"mismatched,omitempty"`
+}
+
+// MetaSchemaRequest starts the dual write of a kind in Target
+type MetaSchemaRequest struct {
+	Target int `json:"target"`
+}
+
+type metaEnvelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Kind          string          `json:"kind"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// IsMetaKind tells whether the schema of kind is versioned
+func IsMetaKind(kind string) bool {
+	return kind == MetaKindRole
+}
+
+// DefaultMetaSchema is the schema of a kind never migrated
+func DefaultMetaSchema(kind string) *MetaSchema {
+	return &MetaSchema{Kind: kind, Version: MetaSchemaV1, Mode: MetaSchemaSingle}
+}
+
+// DecodeMetaSchema decodes the schema of kind stored in value, the default
+// schema if value is empty
+func DecodeMetaSchema(kind, value string) (*MetaSchema, error) {
+	if value == "" {
+		return DefaultMetaSchema(kind), nil
+	}
+	schema := &MetaSchema{}
+	if err := vjson.Unmarshal([]byte(value), schema); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("decode meta schema of %s err: %v", kind, err))
+	}
+	return schema, nil
+}
+
+// ValidateTarget checks the dual write of schema can start in target
+func (schema *MetaSchema) ValidateTarget(target int) error {
+	if schema.Mode == MetaSchemaDual {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s is already dual written in version %d", schema.Kind, schema.Target))
+	}
+	if target <= schema.Version || target > MetaSchemaLatest {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("target version of %s should be in (%d, %d]", schema.Kind, schema.Version, MetaSchemaLatest))
+	}
+	return nil
+}
+
+// EncodeMeta encodes v of kind in the schema version
+func EncodeMeta(kind string, version int, v interface{}) ([]byte, error) {
+	data, err := vjson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	switch version {
+	case MetaSchemaV1:
+		return data, nil
+	case MetaSchemaV2:
+		return vjson.Marshal(&metaEnvelope{SchemaVersion: version, Kind: kind, Data: data})
+	}
+	return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("meta schema version %d of %s not support", version, kind))
+}
+
+// DecodeMeta decodes value of any schema version into v and returns the
+// version it is stored in
+func DecodeMeta(value []byte, v interface{}) (int, error) {
+	if bytes.Contains(value, []byte(`"schema_version"`)) {
+		envelope := &metaEnvelope{}
+		if err := vjson.Unmarshal(value, envelope); err == nil && envelope.SchemaVersion > MetaSchemaV1 && len(envelope.Data) > 0 {
+			if envelope.SchemaVersion > MetaSchemaLatest {
+				return 0, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("meta schema version %d of %s is newer than this build", envelope.SchemaVersion, envelope.Kind))
+			}
+			return envelope.SchemaVersion, vjson.Unmarshal(envelope.Data, v)
+		}
+	}
+	return MetaSchemaV1, vjson.Unmarshal(value, v)
+}
+
+// SameMeta tells whether two stored values of any version decode to the same
+// entity
+func SameMeta(a, b []byte) bool {
+	var x, y interface{}
+	if _, err := DecodeMeta(a, &x); err != nil {
+		return false
+	}
+	if _, err := DecodeMeta(b, &y); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func MetaSchemaKey(kind string) string {
+	return fmt.Sprintf("%s%s", PrefixMetaSchema, kind)
+}
+
+// MetaShadowKey is the key of the target version of the entity at key while
+// its kind is dual written
+func MetaShadowKey(key string) string {
+	return PrefixMetaShadow + strings.TrimPrefix(key, PrefixEtcdClusterID)
+}
+
+// MetaKindPrefix is the prefix of the keys of kind
+func MetaKindPrefix(kind string) string {
+	switch kind {
+	case MetaKindRole:
+		return PrefixRole
+	}
+	return ""
+}
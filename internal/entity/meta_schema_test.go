@@ -0,0 +1,93 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"testing"
+)
+
+func TestEncodeDecodeMeta(t *testing.T) {
+	role := &Role{Name: "reader", Privileges: map[Resource]Privilege{ResourceCluster: ReadOnly}}
+	tests := []struct {
+		name    string
+		version int
+		wantErr bool
+	}{
+		{name: "Version 1 is the plain json", version: MetaSchemaV1},
+		{name: "Version 2 is the envelope", version: MetaSchemaV2},
+		{name: "Unknown version fails", version: MetaSchemaLatest + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := EncodeMeta(MetaKindRole, tt.version, role)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("EncodeMeta() = %s, want error", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &Role{}
+			version, err := DecodeMeta(value, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.version {
+				t.Errorf("DecodeMeta() version = %d, want %d", version, tt.version)
+			}
+			if got.Name != role.Name || got.Privileges[ResourceCluster] != ReadOnly {
+				t.Errorf("DecodeMeta() = %+v, want %+v", got, role)
+			}
+		})
+	}
+}
+
+func TestSameMeta(t *testing.T) {
+	v1, _ := EncodeMeta(MetaKindRole, MetaSchemaV1, &Role{Name: "a"})
+	v2, _ := EncodeMeta(MetaKindRole, MetaSchemaV2, &Role{Name: "a"})
+	other, _ := EncodeMeta(MetaKindRole, MetaSchemaV2, &Role{Name: "b"})
+	if !SameMeta(v1, v2) {
+		t.Errorf("SameMeta(%s, %s) = false", v1, v2)
+	}
+	if SameMeta(v1, other) {
+		t.Errorf("SameMeta(%s, %s) = true", v1, other)
+	}
+	if SameMeta(v1, []byte("{")) {
+		t.Error("SameMeta() of invalid json = true")
+	}
+}
+
+func TestMetaSchemaValidateTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  *MetaSchema
+		target  int
+		wantErr bool
+	}{
+		{name: "Newer version", schema: DefaultMetaSchema(MetaKindRole), target: MetaSchemaV2},
+		{name: "Same version", schema: DefaultMetaSchema(MetaKindRole), target: MetaSchemaV1, wantErr: true},
+		{name: "Version unknown to this build", schema: DefaultMetaSchema(MetaKindRole), target: MetaSchemaLatest + 1, wantErr: true},
+		{name: "Already dual written", schema: &MetaSchema{Kind: MetaKindRole, Version: MetaSchemaV1, Target: MetaSchemaV2, Mode: MetaSchemaDual}, target: MetaSchemaV2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schema.ValidateTarget(tt.target); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// MetadataBundleVersion is the version of the bundles exported by this build,
+// bundles of other versions are not imported
+const MetadataBundleVersion = 1
+
+// MetadataBundle is the cluster metadata exported to rebuild the control plane
+// of a cluster in a fresh etcd, the data of ps is not in it. Users keep their
+// stored passwords so the bundle should be kept as a secret
+type MetadataBundle struct {
+	Version    int          `json:"version"`
+	Cluster    string       `json:"cluster"`
+	ExportTime int64        `json:"export_time"` // unix seconds
+	DBs        []*DB        `json:"dbs"`
+	Spaces     []*Space     `json:"spaces"`
+	Partitions []*Partition `json:"partitions"`
+	Users      []*User      `json:"users"`
+	Roles      []*Role      `json:"roles"`
+	Aliases    []*Alias     `json:"aliases"`
+}
+
+func metadataError(format string, a ...interface{}) error {
+	return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metadata bundle: "+format, a...))
+}
+
+// Validate checks the bundle is consistent: ids and names are unique, spaces
+// belong to dbs, the partitions of spaces and the partitions are the same,
+// aliases point to spaces and users to roles
+func (b *MetadataBundle) Validate() error {
+	if b.Version != MetadataBundleVersion {
+		return metadataError("version %d not support, should be %d", b.Version, MetadataBundleVersion)
+	}
+
+	dbs := make(map[DBID]*DB, len(b.DBs))
+	dbNames := make(map[string]bool, len(b.DBs))
+	for _, db := range b.DBs {
+		if db == nil || db.Id <= 0 || db.Name == "" {
+			return metadataError("db should have id and name")
+		}
+		if dbs[db.Id] != nil || dbNames[db.Name] {
+			return metadataError("db id %d or name %s is duplicated", db.Id, db.Name)
+		}
+		dbs[db.Id] = db
+		dbNames[db.Name] = true
+	}
+
+	partitions := make(map[PartitionID]*Partition, len(b.Partitions))
+	for _, p := range b.Partitions {
+		if p == nil {
+			return metadataError("partition should not be null")
+		}
+		if partitions[p.Id] != nil {
+			return metadataError("partition id %d is duplicated", p.Id)
+		}
+		partitions[p.Id] = p
+	}
+
+	spaceIds := make(map[SpaceID]bool, len(b.Spaces))
+	spaceNames := make(map[string]bool, len(b.Spaces))
+	owned := make(map[PartitionID]bool, len(b.Partitions))
+	for _, space := range b.Spaces {
+		if space == nil || space.Id <= 0 || space.Name == "" {
+			return metadataError("space should have id and name")
+		}
+		db := dbs[space.DBId]
+		if db == nil {
+			return metadataError("db %d of space %s not found", space.DBId, space.Name)
+		}
+		key := db.Name + "/" + space.Name
+		if spaceIds[space.Id] || spaceNames[key] {
+			return metadataError("space id %d or name %s is duplicated", space.Id, key)
+		}
+		spaceIds[space.Id] = true
+		spaceNames[key] = true
+		for _, sp := range space.Partitions {
+			p := partitions[sp.Id]
+			if p == nil {
+				return metadataError("partition %d of space %s not found", sp.Id, key)
+			}
+			if p.SpaceId != space.Id || p.DBId != space.DBId {
+				return metadataError("partition %d belongs to space %d of db %d, not space %s", p.Id, p.SpaceId, p.DBId, key)
+			}
+			if owned[p.Id] {
+				return metadataError("partition %d is in more than one space", p.Id)
+			}
+			owned[p.Id] = true
+		}
+	}
+	for id := range partitions {
+		if !owned[id] {
+			return metadataError("partition %d is not in any space", id)
+		}
+	}
+
+	roles := make(map[string]bool, len(b.Roles))
+	for _, role := range b.Roles {
+		if role == nil || role.Name == "" {
+			return metadataError("role should have name")
+		}
+		if roles[role.Name] {
+			return metadataError("role %s is duplicated", role.Name)
+		}
+		roles[role.Name] = true
+	}
+	users := make(map[string]bool, len(b.Users))
+	for _, user := range b.Users {
+		if user == nil || user.Name == "" {
+			return metadataError("user should have name")
+		}
+		if users[user.Name] {
+			return metadataError("user %s is duplicated", user.Name)
+		}
+		users[user.Name] = true
+		if user.RoleName == nil {
+			continue
+		}
+		if _, ok := RoleMap[*user.RoleName]; !ok && !roles[*user.RoleName] {
+			return metadataError("role %s of user %s not found", *user.RoleName, user.Name)
+		}
+	}
+
+	aliases := make(map[string]bool, len(b.Aliases))
+	for _, alias := range b.Aliases {
+		if alias == nil || alias.Name == "" {
+			return metadataError("alias should have name")
+		}
+		if aliases[alias.Name] {
+			return metadataError("alias %s is duplicated", alias.Name)
+		}
+		aliases[alias.Name] = true
+		if !spaceNames[alias.DbName+"/"+alias.SpaceName] {
+			return metadataError("space %s/%s of alias %s not found", alias.DbName, alias.SpaceName, alias.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestMetadataBundle_Validate(t *testing.T) {
+	bundle := func() *MetadataBundle {
+		role := "reader"
+		p := &Partition{Id: 1, SpaceId: 1, DBId: 1}
+		return &MetadataBundle{
+			Version:    MetadataBundleVersion,
+			DBs:        []*DB{{Id: 1, Name: "db"}},
+			Spaces:     []*Space{{Id: 1, Name: "space", DBId: 1, Partitions: []*Partition{p}}},
+			Partitions: []*Partition{p},
+			Roles:      []*Role{{Name: role}},
+			Users:      []*User{{Name: "user", RoleName: &role}},
+			Aliases:    []*Alias{{Name: "alias", DbName: "db", SpaceName: "space"}},
+		}
+	}
+	tests := []struct {
+		name    string
+		modify  func(b *MetadataBundle)
+		wantErr bool
+	}{
+		{"valid", func(b *MetadataBundle) {}, false},
+		{"other version", func(b *MetadataBundle) { b.Version = 0 }, true},
+		{"space without db", func(b *MetadataBundle) { b.Spaces[0].DBId = 2 }, true},
+		{"partition not in space", func(b *MetadataBundle) { b.Partitions = append(b.Partitions, &Partition{Id: 2, SpaceId: 1, DBId: 1}) }, true},
+		{"partition of other space", func(b *MetadataBundle) { b.Partitions[0].SpaceId = 2 }, true},
+		{"alias without space", func(b *MetadataBundle) { b.Aliases[0].SpaceName = "other" }, true},
+		{"user without role", func(b *MetadataBundle) { b.Roles = nil }, true},
+		{"duplicated role", func(b *MetadataBundle) { b.Roles = append(b.Roles, &Role{Name: "reader"}) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bundle()
+			tt.modify(b)
+			if err := b.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("MetadataBundle.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	// OrphanDirectory is partition data on a ps which is not a replica of it
+	// in any space
+	OrphanDirectory = "orphan_directory"
+	// OrphanMissing is a replica of a space partition on a live ps without
+	// the partition data
+	OrphanMissing = "missing_partition"
+
+	// OrphanActionAdopt makes the ps a replica of the space partition again,
+	// for missing partitions the partition is created on the ps
+	OrphanActionAdopt = "adopt"
+	// OrphanActionGC deletes the orphan data, for missing partitions the ps
+	// is removed from the replicas
+	OrphanActionGC = "gc"
+
+	DefaultOrphanCheckInterval = 600 // seconds
+)
+
+type OrphanPartition struct {
+	Kind        string      `json:"kind"`
+	PartitionID PartitionID `json:"partition_id"`
+	NodeID      NodeID      `json:"node_id"`
+	DbName      string      `json:"db_name,omitempty"` // space of the partition, empty if no space has it
+	SpaceName   string      `json:"space_name,omitempty"`
+}
+
+func (o *OrphanPartition) Key() string {
+	return fmt.Sprintf("%s/%d/%d", o.Kind, o.PartitionID, o.NodeID)
+}
+
+type OrphanReport struct {
+	Time    int64              `json:"time"` // unix seconds
+	Orphans []*OrphanPartition `json:"orphans"`
+}
+
+// OrphanResolve adopts or deletes an orphan, without Confirm it only returns
+// what would be done
+type OrphanResolve struct {
+	Kind        string      `json:"kind"`
+	PartitionID PartitionID `json:"partition_id"`
+	NodeID      NodeID      `json:"node_id"`
+	Action      string      `json:"action"`
+	Confirm     bool        `json:"confirm"`
+}
+
+func (r *OrphanResolve) Validate() error {
+	if r.Kind != OrphanDirectory && r.Kind != OrphanMissing {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("orphan kind should be %s or %s", OrphanDirectory, OrphanMissing))
+	}
+	if r.Action != OrphanActionAdopt && r.Action != OrphanActionGC {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("orphan action should be %s or %s", OrphanActionAdopt, OrphanActionGC))
+	}
+	return nil
+}
+
+func (r *OrphanResolve) Key() string {
+	return fmt.Sprintf("%s/%d/%d", r.Kind, r.PartitionID, r.NodeID)
+}
+
+// DetectOrphanPartitions compares the partition data reported by the live
+// servers with the replicas of the space partitions, replicas on servers not
+// alive are left to the fail server recovery
+func DetectOrphanPartitions(spaces []*Space, servers []*Server, dbNames map[DBID]string) []*OrphanPartition {
+	type ref struct {
+		space    *Space
+		replicas map[NodeID]bool
+	}
+	refs := make(map[PartitionID]*ref)
+	for _, space := range spaces {
+		for _, p := range space.Partitions {
+			r := &ref{space: space, replicas: make(map[NodeID]bool, len(p.Replicas))}
+			for _, id := range p.Replicas {
+				r.replicas[id] = true
+			}
+			refs[p.Id] = r
+		}
+	}
+
+	orphans := make([]*OrphanPartition, 0)
+	alive := make(map[NodeID]map[PartitionID]bool, len(servers))
+	for _, server := range servers {
+		pids := make(map[PartitionID]bool, len(server.PartitionIds))
+		for _, pid := range server.PartitionIds {
+			pids[pid] = true
+			r := refs[pid]
+			if r != nil && r.replicas[server.ID] {
+				continue
+			}
+			orphan := &OrphanPartition{Kind: OrphanDirectory, PartitionID: pid, NodeID: server.ID}
+			if r != nil {
+				orphan.DbName, orphan.SpaceName = dbNames[r.space.DBId], r.space.Name
+			}
+			orphans = append(orphans, orphan)
+		}
+		alive[server.ID] = pids
+	}
+	for pid, r := range refs {
+		for id := range r.replicas {
+			if pids, ok := alive[id]; ok && !pids[pid] {
+				orphans = append(orphans, &OrphanPartition{Kind: OrphanMissing, PartitionID: pid, NodeID: id, DbName: dbNames[r.space.DBId], SpaceName: r.space.Name})
+			}
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].PartitionID != orphans[j].PartitionID {
+			return orphans[i].PartitionID < orphans[j].PartitionID
+		}
+		if orphans[i].NodeID != orphans[j].NodeID {
+			return orphans[i].NodeID < orphans[j].NodeID
+		}
+		return orphans[i].Kind < orphans[j].Kind
+	})
+	return orphans
+}
@@ -15,6 +15,7 @@
 package entity
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 
@@ -98,6 +99,38 @@ type PartitionInfo struct {
 	Error        string            `json:"error,omitempty"`
 }
 
+// PartitionStats is the request statistics of a partition replica on a ps
+type PartitionStats struct {
+	PartitionID  PartitionID              `json:"pid"`
+	NodeID       NodeID                   `json:"node_id"`
+	Ip           string                   `json:"ip,omitempty"`
+	Leader       bool                     `json:"leader"`
+	Window       int64                    `json:"window_seconds"`
+	Requests     map[string]*RequestStats `json:"requests"`
+	Queued       int64                    `json:"queued"`
+	Running      int64                    `json:"running"`
+	Stuck        int64                    `json:"stuck"`       // running past several times their timeout
+	StuckTotal   int64                    `json:"stuck_total"` // since ps started
+	MemoryBytes  int64                    `json:"memory_bytes"`
+	DocNum       uint64                   `json:"doc_num"`
+	Indexes      map[string]*Index        `json:"indexes,omitempty"`
+	SearchParams json.RawMessage          `json:"default_search_params,omitempty"`
+	EngineConfig *EngineConfig            `json:"engine_config,omitempty"`
+	Error        string                   `json:"error,omitempty"`
+}
+
+// RequestStats is the statistics of one request type in the stats window,
+// latencies are in milliseconds
+type RequestStats struct {
+	Count  int64   `json:"count"`
+	Errors int64   `json:"errors"`
+	Qps    float64 `json:"qps"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P99    float64 `json:"p99"`
+	Max    float64 `json:"max"`
+}
+
 type ResourceLimit struct {
 	Rate              *float64 `json:"rate,omitempty"`
 	ResourceExhausted *bool    `json:"resource_exhausted,omitempty"`
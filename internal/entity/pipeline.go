@@ -0,0 +1,102 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	// PipelineRetrieve searches the vector field for the candidates
+	PipelineRetrieve = "retrieve"
+	// PipelineRerank rescores the candidates by the exact distance of
+	// another vector field to its query vector
+	PipelineRerank = "rerank"
+	// PipelineCrossEncoder rescores the candidates by a reranker model of
+	// the router scoring the query text with the text field
+	PipelineCrossEncoder = "cross_encoder"
+
+	MaxPipelineCandidates = 10000
+)
+
+// PipelineStage is a stage of the retrieval pipeline of a space, it keeps
+// the best Limit candidates of the stage before
+type PipelineStage struct {
+	Name      string `json:"name,omitempty"`
+	Type      string `json:"type"`
+	Field     string `json:"field,omitempty"`      // vector field of retrieve and rerank
+	TextField string `json:"text_field,omitempty"` // string field of cross_encoder
+	Reranker  string `json:"reranker,omitempty"`   // reranker of routers of cross_encoder
+	Metric    string `json:"metric,omitempty"`     // space metric of rerank, the search metric if not set
+	Limit     int32  `json:"limit"`
+}
+
+// ValidatePipeline checks the pipeline starts by a retrieve stage followed by
+// rerank or cross_encoder stages of non-increasing limits
+func (space *Space) ValidatePipeline(stages []*PipelineStage) error {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool, len(stages))
+	for i, stage := range stages {
+		if stage == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("pipeline stage %d is null", i))
+		}
+		if stage.Name == "" {
+			stage.Name = fmt.Sprintf("%s_%d", stage.Type, i)
+		}
+		if names[stage.Name] {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("pipeline stage name %s is duplicated", stage.Name))
+		}
+		names[stage.Name] = true
+		if stage.Limit <= 0 || stage.Limit > MaxPipelineCandidates {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("limit of pipeline stage %s should be in (0, %d]", stage.Name, MaxPipelineCandidates))
+		}
+		if i > 0 && stage.Limit > stages[i-1].Limit {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("limit of pipeline stage %s is larger than the stage before", stage.Name))
+		}
+		if (i == 0) != (stage.Type == PipelineRetrieve) {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("pipeline should have only the first stage of type %s", PipelineRetrieve))
+		}
+
+		switch stage.Type {
+		case PipelineRetrieve, PipelineRerank:
+			if pro := proMap[stage.Field]; pro == nil || pro.FieldType != vearchpb.FieldType_VECTOR {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] of pipeline stage %s is not vector field", stage.Field, stage.Name))
+			}
+			if stage.Metric != "" {
+				if m := space.Metric(stage.Metric); stage.Type != PipelineRerank || m == nil || m.Field != stage.Field {
+					return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metric %s of pipeline stage %s should be a space metric of field [%s] for rerank", stage.Metric, stage.Name, stage.Field))
+				}
+			}
+		case PipelineCrossEncoder:
+			if pro := proMap[stage.TextField]; pro == nil || pro.FieldType != vearchpb.FieldType_STRING {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("text_field [%s] of pipeline stage %s is not string field", stage.TextField, stage.Name))
+			}
+			if stage.Reranker == "" {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("reranker of pipeline stage %s is empty", stage.Name))
+			}
+		default:
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unknown type %s of pipeline stage %s", stage.Type, stage.Name))
+		}
+	}
+	return nil
+}
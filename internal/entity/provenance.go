@@ -0,0 +1,54 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	// ProvenanceParam asks the ps of a search for the raft state it searched
+	// at, the router then annotates each hit with its provenance
+	ProvenanceParam = "provenance"
+	// AppliedIndexParam is the response head param of the raft applied index
+	// of the replica a partition was searched on
+	AppliedIndexParam = "applied_index"
+	// ProvenanceField is the field of a hit carrying its provenance
+	ProvenanceField = "_provenance"
+)
+
+// Provenance is the partition and replica a hit comes from, applied index is
+// the raft index the replica had applied when it searched, 0 if unknown
+type Provenance struct {
+	PartitionID  PartitionID `json:"partition_id"`
+	NodeID       NodeID      `json:"node_id"`
+	Leader       bool        `json:"leader"`
+	AppliedIndex uint64      `json:"applied_index"`
+}
+
+// AnnotateProvenance adds p as the provenance field of all items of results
+func AnnotateProvenance(results []*vearchpb.SearchResult, p *Provenance) error {
+	value, err := vjson.Marshal(p)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		for _, item := range result.ResultItems {
+			item.Fields = append(item.Fields, &vearchpb.Field{Name: ProvenanceField, Type: vearchpb.FieldType_STRING, Value: value})
+		}
+	}
+	return nil
+}
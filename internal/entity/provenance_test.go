@@ -0,0 +1,74 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestAnnotateProvenance(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []*vearchpb.SearchResult
+		want    int
+	}{
+		{
+			name: "No results",
+		},
+		{
+			name:    "No items",
+			results: []*vearchpb.SearchResult{{}},
+		},
+		{
+			name: "Items of every query",
+			results: []*vearchpb.SearchResult{
+				{ResultItems: []*vearchpb.ResultItem{{Fields: []*vearchpb.Field{{Name: IdField, Value: []byte("1")}}}, {}}},
+				{ResultItems: []*vearchpb.ResultItem{{}}},
+			},
+			want: 3,
+		},
+	}
+	p := &Provenance{PartitionID: 3, NodeID: 2, Leader: true, AppliedIndex: 42}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AnnotateProvenance(tt.results, p); err != nil {
+				t.Fatalf("AnnotateProvenance() error = %v", err)
+			}
+			got := 0
+			for _, result := range tt.results {
+				for _, item := range result.ResultItems {
+					fv := item.Fields[len(item.Fields)-1]
+					if fv.Name != ProvenanceField {
+						t.Fatalf("last field = %s, want %s", fv.Name, ProvenanceField)
+					}
+					annotated := &Provenance{}
+					if err := vjson.Unmarshal(fv.Value, annotated); err != nil {
+						t.Fatalf("unmarshal provenance error = %v", err)
+					}
+					if *annotated != *p {
+						t.Errorf("provenance = %+v, want %+v", annotated, p)
+					}
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("annotated %d items, want %d", got, tt.want)
+			}
+		})
+	}
+}
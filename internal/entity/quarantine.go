@@ -0,0 +1,50 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// QuarantinedPartition is a replica a ps failed to load, the ps skips it
+// and serves its other partitions until it is rebuilt from other replicas
+type QuarantinedPartition struct {
+	PartitionID PartitionID `json:"partition_id"`
+	NodeID      NodeID      `json:"node_id"`
+	Ip          string      `json:"ip,omitempty"`
+	Error       string      `json:"error"`
+	Time        int64       `json:"time"`
+}
+
+// PartitionRebuild asks the ps of NodeID to drop its quarantined replica of
+// PartitionID and recover it from the raft leader
+type PartitionRebuild struct {
+	PartitionID PartitionID `json:"partition_id"`
+	NodeID      NodeID      `json:"node_id"`
+}
+
+func (r *PartitionRebuild) Validate() error {
+	if r.PartitionID == 0 || r.NodeID == 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition_id and node_id of partition rebuild are required"))
+	}
+	return nil
+}
+
+// QuarantineKey is the etcd key of the quarantined replica of pid on node
+func QuarantineKey(nodeID NodeID, pid PartitionID) string {
+	return fmt.Sprintf("%s%d/%d", PrefixQuarantine, nodeID, pid)
+}
@@ -0,0 +1,42 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestPartitionRebuild_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rebuild PartitionRebuild
+		wantErr bool
+	}{
+		{name: "valid", rebuild: PartitionRebuild{PartitionID: 1, NodeID: 2}},
+		{name: "no partition", rebuild: PartitionRebuild{NodeID: 2}, wantErr: true},
+		{name: "no node", rebuild: PartitionRebuild{PartitionID: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rebuild.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuarantineKey(t *testing.T) {
+	if key, want := QuarantineKey(2, 1), PrefixQuarantine+"2/1"; key != want {
+		t.Errorf("QuarantineKey() = %s, want %s", key, want)
+	}
+}
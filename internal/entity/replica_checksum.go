@@ -0,0 +1,101 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// ReplicaChecksum is the document count and the order independent checksum
+// of the documents of a replica, computed when the replica applies the
+// checksum raft entry at Index so all replicas are compared at the same index
+type ReplicaChecksum struct {
+	PartitionID PartitionID `json:"partition_id"`
+	NodeID      NodeID      `json:"node_id"`
+	Index       uint64      `json:"index"`
+	DocNum      uint64      `json:"doc_num"`
+	Checksum    uint64      `json:"checksum"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// ConsistencyCheckRequest checks the partitions of a space, all if
+// PartitionIDs is empty. Repair re-replicates the minority replicas from the
+// leader when the leader is in the majority
+type ConsistencyCheckRequest struct {
+	DbName       string        `json:"db_name"`
+	SpaceName    string        `json:"space_name"`
+	PartitionIDs []PartitionID `json:"partition_ids,omitempty"`
+	Repair       bool          `json:"repair,omitempty"`
+}
+
+func (r *ConsistencyCheckRequest) Validate() error {
+	if r.DbName == "" || r.SpaceName == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("db_name and space_name can not be empty"))
+	}
+	return nil
+}
+
+type PartitionConsistency struct {
+	PartitionID PartitionID        `json:"partition_id"`
+	Index       uint64             `json:"index"`
+	Consistent  bool               `json:"consistent"`
+	Replicas    []*ReplicaChecksum `json:"replicas"`
+	Minority    []NodeID           `json:"minority,omitempty"`
+	Repaired    []NodeID           `json:"repaired,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// Compare marks the replicas which differ from the most replicas as
+// minority, without a strict majority all replicas are minority
+func (pc *PartitionConsistency) Compare() {
+	type digest struct {
+		docNum   uint64
+		checksum uint64
+	}
+	groups := make(map[digest][]NodeID)
+	for _, r := range pc.Replicas {
+		if r.Error != "" {
+			pc.Minority = append(pc.Minority, r.NodeID)
+			continue
+		}
+		d := digest{r.DocNum, r.Checksum}
+		groups[d] = append(groups[d], r.NodeID)
+	}
+	var majority []NodeID
+	for _, nodes := range groups {
+		if len(nodes)*2 > len(pc.Replicas) {
+			majority = nodes
+		}
+	}
+	for _, nodes := range groups {
+		if majority == nil || nodes[0] != majority[0] {
+			pc.Minority = append(pc.Minority, nodes...)
+		}
+	}
+	sort.Slice(pc.Minority, func(i, j int) bool { return pc.Minority[i] < pc.Minority[j] })
+	pc.Consistent = len(pc.Minority) == 0
+}
+
+// ReplicaDivergence is found by a get checking consistency, a follower of the
+// partition has a different set of the documents than the leader
+type ReplicaDivergence struct {
+	PartitionID PartitionID `json:"partition_id"`
+	NodeID      NodeID      `json:"node_id"`
+	Missing     []string    `json:"missing,omitempty"` // on leader but not on the follower
+	Extra       []string    `json:"extra,omitempty"`   // on the follower but not on leader
+}
@@ -16,6 +16,8 @@ package request
 
 import (
 	"encoding/json"
+	"fmt"
+	"path"
 
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/ps/engine/sortorder"
@@ -66,6 +68,68 @@ type Ranker struct {
 	Params json.RawMessage `json:"params,omitempty"`
 }
 
+// MMR re-selects the merged candidates by maximal marginal relevance,
+// lambda 1 is pure relevance and 0 pure diversity
+type MMR struct {
+	Lambda     *float64 `json:"lambda,omitempty"`     // 0.5 if not set
+	Candidates int32    `json:"candidates,omitempty"` // 4 * limit if not set
+	Field      string   `json:"field,omitempty"`      // the searched vector field if not set
+	MetricType string   `json:"-"`
+	Strip      bool     `json:"-"` // field is only fetched for mmr
+}
+
+// Sample returns size documents picked uniformly from the matches of a
+// query, the same seed picks the same documents for the same matches
+type Sample struct {
+	Size int32  `json:"size"`
+	Seed *int64 `json:"seed,omitempty"` // random if not set
+}
+
+// Dither perturbs the query vectors of a search by gaussian noise, a debug
+// option for benchmarks to defeat result caches. The noise of each dimension
+// has the stddev Noise * |vector| / sqrt(dimension), so a vector moves by
+// about Noise of its norm
+type Dither struct {
+	Noise float64 `json:"noise"`
+	Seed  *int64  `json:"seed,omitempty"` // random if not set
+}
+
+// SourceFilter selects the returned fields by path.Match patterns, excludes
+// win over includes and no includes means all fields
+type SourceFilter struct {
+	Includes []string `json:"includes,omitempty"`
+	Excludes []string `json:"excludes,omitempty"`
+}
+
+func (f *SourceFilter) Validate() error {
+	for _, patterns := range [][]string{f.Includes, f.Excludes} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("_source pattern [%s] err: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Match reports whether field is returned
+func (f *SourceFilter) Match(field string) bool {
+	for _, pattern := range f.Excludes {
+		if ok, _ := path.Match(pattern, field); ok {
+			return false
+		}
+	}
+	if len(f.Includes) == 0 {
+		return true
+	}
+	for _, pattern := range f.Includes {
+		if ok, _ := path.Match(pattern, field); ok {
+			return true
+		}
+	}
+	return false
+}
+
 type SearchDocumentRequest struct {
 	Limit         int32             `json:"limit,omitempty"`
 	Fields        []string          `json:"fields,omitempty"`
@@ -81,10 +145,38 @@ type SearchDocumentRequest struct {
 	LoadBalance   string            `json:"load_balance"`
 	DocumentIds   *[]string         `json:"document_ids,omitempty"`
 	PartitionId   *uint32           `json:"partition_id,omitempty"`
-	Next          *bool             `json:"next,omitempty"`
-	Ranker        json.RawMessage   `json:"ranker,omitempty"`
-	GetByHash     bool              `json:"get_by_hash,omitempty"`
-	sortOrder     sortorder.SortOrder
+	// ConsistencyCheck compares the documents got by DocumentIds on all replicas
+	ConsistencyCheck bool                `json:"consistency_check,omitempty"`
+	Next             *bool               `json:"next,omitempty"`
+	Ranker           json.RawMessage     `json:"ranker,omitempty"`
+	GetByHash        bool                `json:"get_by_hash,omitempty"`
+	Boost            *entity.Boost       `json:"boost,omitempty"`
+	ScoreScript      *entity.ScoreScript `json:"score_script,omitempty"`
+	UDF              *entity.UDFCall     `json:"udf,omitempty"`
+	MMR              *MMR                `json:"mmr,omitempty"`
+	Source           *SourceFilter       `json:"_source,omitempty"`
+	Hydrate          bool                `json:"hydrate,omitempty"`
+	Sample           *Sample             `json:"sample,omitempty"`
+	Dither           *Dither             `json:"dither,omitempty"`
+	// HydrateTimeout caps the ms hydration waits for the payloads of the
+	// request, the hydration timeout of the router if 0
+	HydrateTimeout int32 `json:"hydrate_timeout,omitempty"`
+	// QueryText is scored by the cross_encoder stages of the space pipeline
+	QueryText    string `json:"query_text,omitempty"`
+	SkipPipeline bool   `json:"skip_pipeline,omitempty"`
+	// Explain returns the candidates and time of each pipeline stage and
+	// the plan of the filters
+	Explain bool `json:"explain,omitempty"`
+	// Targets are the [cluster:]db/space or global aliases searched instead
+	// of db_name and space_name, a cluster is a remote cluster of the router
+	// and the results of all targets are merged by score
+	Targets []string `json:"targets,omitempty"`
+	// Echo is returned untouched in the response of the search
+	Echo json.RawMessage `json:"echo,omitempty"`
+	// Provenance adds to each hit the partition, replica and raft applied
+	// index it comes from, for debugging
+	Provenance bool `json:"provenance,omitempty"`
+	sortOrder  sortorder.SortOrder
 }
 
 func (s *SearchDocumentRequest) SortOrder() (sortorder.SortOrder, error) {
@@ -95,3 +187,71 @@ func (s *SearchDocumentRequest) SortOrder() (sortorder.SortOrder, error) {
 	s.sortOrder, err = sortorder.ParseSort(s.Sort)
 	return s.sortOrder, err
 }
+
+// EvaluateRequest is the body of /document/evaluate, ground truth is computed
+// by brute force search if it is not supplied
+type EvaluateRequest struct {
+	DbName      string          `json:"db_name,omitempty"`
+	SpaceName   string          `json:"space_name,omitempty"`
+	Field       string          `json:"field"`
+	Vectors     [][]float32     `json:"vectors"`
+	GroundTruth [][]string      `json:"ground_truth,omitempty"`
+	Limit       int32           `json:"limit,omitempty"`
+	IndexParams json.RawMessage `json:"index_params,omitempty"`
+	Filters     *Filter         `json:"filters,omitempty"`
+}
+
+// AdviseRequest is the body of /document/advise, it evaluates every
+// combination of candidates, such as {"efSearch": [32, 64]}, candidates are
+// chosen by the index type if empty
+type AdviseRequest struct {
+	EvaluateRequest
+	LatencyBudget float64          `json:"latency_budget_ms,omitempty"` // p99 latency limit, 0 is no limit
+	Candidates    map[string][]int `json:"candidates,omitempty"`
+	Apply         bool             `json:"apply,omitempty"` // persist the recommended params as space default
+}
+
+// DedupRequest is the body of /document/dedup, documents whose vectors are
+// within epsilon are grouped and all but the first scanned are reported,
+// deleted, or tagged with the id of the first in TagField
+type DedupRequest struct {
+	DbName      string          `json:"db_name"`
+	SpaceName   string          `json:"space_name"`
+	Field       string          `json:"field"`
+	Epsilon     float64         `json:"epsilon"`
+	Neighbors   int32           `json:"neighbors,omitempty"`
+	MaxDocs     int             `json:"max_docs,omitempty"`
+	Action      string          `json:"action,omitempty"`
+	TagField    string          `json:"tag_field,omitempty"`
+	IndexParams json.RawMessage `json:"index_params,omitempty"`
+}
+
+// ClusterRequest is the body of /document/cluster, it samples the vectors of
+// a space for kmeans labels and a 2d projection
+type ClusterRequest struct {
+	DbName     string `json:"db_name"`
+	SpaceName  string `json:"space_name"`
+	Field      string `json:"field"`
+	Sample     int    `json:"sample,omitempty"`
+	ScanLimit  int    `json:"scan_limit,omitempty"` // documents scanned to sample from
+	K          int    `json:"k,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	Projection string `json:"projection,omitempty"` // pca or empty
+	Seed       int64  `json:"seed,omitempty"`
+}
+
+// ExportRequest is the body of /document/export, the documents are written
+// to the bucket as parquet files under prefix instead of returned. Without
+// filters every document is scanned, with filters MaxDocs is the matches
+// queried of each partition
+type ExportRequest struct {
+	DbName      string         `json:"db_name"`
+	SpaceName   string         `json:"space_name"`
+	Filters     *Filter        `json:"filters,omitempty"`
+	Fields      []string       `json:"fields,omitempty"` // all stored fields if empty
+	VectorValue bool           `json:"vector_value,omitempty"`
+	MaxDocs     int            `json:"max_docs,omitempty"`
+	RowsPerFile int            `json:"rows_per_file,omitempty"`
+	Prefix      string         `json:"prefix"`
+	S3Param     entity.S3Param `json:"s3_param"`
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// ResourceGroupNone as the resource group of space update removes the space
+// from its group
+const ResourceGroupNone = "none"
+
+// ResourceGroup caps the searches and queries of the spaces in it on each ps,
+// CpuShare is the percent of the ps execution slots the group can hold and
+// MaxConcurrency the number of its requests running at the same time, zero
+// means no limit
+type ResourceGroup struct {
+	Name           string `json:"name"`
+	CpuShare       int    `json:"cpu_share,omitempty"`
+	MaxConcurrency int    `json:"max_concurrency,omitempty"`
+}
+
+func (rg *ResourceGroup) Validate() error {
+	if err := ValidateName(rg.Name, ResourceGroupNameType, false); err != nil {
+		return err
+	}
+	if rg.Name == ResourceGroupNone {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("resource group name can not be %s", ResourceGroupNone))
+	}
+	if rg.CpuShare < 0 || rg.CpuShare > 100 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("resource group cpu_share should be in [0, 100]"))
+	}
+	if rg.MaxConcurrency < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("resource group max_concurrency should not be negative"))
+	}
+	return nil
+}
+
+// Limit returns the requests of the group can run at the same time on a ps
+// with slots execution slots, zero means no limit
+func (rg *ResourceGroup) Limit(slots int) int {
+	limit := rg.MaxConcurrency
+	if rg.CpuShare > 0 {
+		share := (slots*rg.CpuShare + 99) / 100
+		if limit == 0 || share < limit {
+			limit = share
+		}
+	}
+	return limit
+}
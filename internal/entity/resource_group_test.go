@@ -0,0 +1,59 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestResourceGroup_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		group   ResourceGroup
+		wantErr bool
+	}{
+		{name: "Valid group by cpu share", group: ResourceGroup{Name: "batch", CpuShare: 20}, wantErr: false},
+		{name: "Valid group by concurrency", group: ResourceGroup{Name: "online", MaxConcurrency: 8}, wantErr: false},
+		{name: "Invalid group named none", group: ResourceGroup{Name: ResourceGroupNone}, wantErr: true},
+		{name: "Invalid cpu share beyond 100", group: ResourceGroup{Name: "batch", CpuShare: 101}, wantErr: true},
+		{name: "Invalid negative concurrency", group: ResourceGroup{Name: "batch", MaxConcurrency: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.group.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ResourceGroup.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResourceGroup_Limit(t *testing.T) {
+	tests := []struct {
+		name  string
+		group ResourceGroup
+		slots int
+		want  int
+	}{
+		{name: "No limit", group: ResourceGroup{}, slots: 100, want: 0},
+		{name: "Cpu share rounded up", group: ResourceGroup{CpuShare: 15}, slots: 10, want: 2},
+		{name: "Concurrency lower than share", group: ResourceGroup{CpuShare: 50, MaxConcurrency: 3}, slots: 10, want: 3},
+		{name: "Share lower than concurrency", group: ResourceGroup{CpuShare: 10, MaxConcurrency: 30}, slots: 100, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.group.Limit(tt.slots); got != tt.want {
+				t.Errorf("ResourceGroup.Limit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
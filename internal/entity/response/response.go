@@ -17,10 +17,12 @@ package response
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vearch/vearch/v3/internal/entity/errors"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 )
 
@@ -60,7 +62,7 @@ func (r *Response) SendJsonBytes(bytes []byte) {
 	r.ginContext.Writer.WriteHeader(int(r.httpStatus))
 
 	if _, err := r.ginContext.Writer.Write(bytes); err != nil {
-		log.Errorf("fail to write http reply, err:[%v], body:[%s] len[%d]", err, string(bytes), len(bytes))
+		log.Errorf("fail to write http reply, err:[%v], body:[%s] len[%d]", err, redact.Payload(bytes), len(bytes))
 	}
 }
 
@@ -75,6 +77,30 @@ func (r *Response) JsonSuccess(data interface{}) {
 	r.SendJson(httpReply)
 }
 
+// JsonSuccessETag replies data tagged by etag, or 304 without body if the
+// If-None-Match of the request has etag
+func (r *Response) JsonSuccessETag(data interface{}, etag string) {
+	r.ginContext.Header("ETag", etag)
+	if ETagMatch(r.ginContext.GetHeader("If-None-Match"), etag) {
+		r.ginContext.Status(http.StatusNotModified)
+		return
+	}
+	r.JsonSuccess(data)
+}
+
+// ETagMatch reports whether the If-None-Match header value has etag, weak
+// tags match by their opaque value
+func ETagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Response) SuccessDelete() {
 	httpReply := &HttpReply{
 		Code:      int(vearchpb.ErrorEnum_SUCCESS),
@@ -0,0 +1,81 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestETagMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{name: "No header", ifNoneMatch: "", etag: `"a"`, want: false},
+		{name: "Same tag", ifNoneMatch: `"a"`, etag: `"a"`, want: true},
+		{name: "Other tag", ifNoneMatch: `"b"`, etag: `"a"`, want: false},
+		{name: "Tag in list", ifNoneMatch: `"b", "a"`, etag: `"a"`, want: true},
+		{name: "Weak tag", ifNoneMatch: `W/"a"`, etag: `"a"`, want: true},
+		{name: "Any tag", ifNoneMatch: "*", etag: `"a"`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ETagMatch(tt.ifNoneMatch, tt.etag); got != tt.want {
+				t.Errorf("ETagMatch(%q, %q) = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJsonSuccessETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+		wantBody    bool
+	}{
+		{name: "Full reply", wantStatus: http.StatusOK, wantBody: true},
+		{name: "Not modified", ifNoneMatch: `"a"`, wantStatus: http.StatusNotModified},
+		{name: "Modified", ifNoneMatch: `"b"`, wantStatus: http.StatusOK, wantBody: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/document", nil)
+			if tt.ifNoneMatch != "" {
+				c.Request.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			New(c).JsonSuccessETag(map[string]string{"_id": "1"}, `"a"`)
+			c.Writer.WriteHeaderNow()
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Header().Get("ETag") != `"a"` {
+				t.Errorf("ETag = %s, want \"a\"", w.Header().Get("ETag"))
+			}
+			if (w.Body.Len() > 0) != tt.wantBody {
+				t.Errorf("body = %q, want body %v", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+// RouterInfo is the registration of a healthy router under the routers
+// prefix. Routers remove it when they turn unhealthy or shut down, and it
+// expires with the lease of a router gone
+type RouterInfo struct {
+	Address    string `json:"address"` // host:port of the http api
+	RpcAddress string `json:"rpc_address,omitempty"`
+	Version    string `json:"version,omitempty"`
+	StartTime  int64  `json:"start_time"` // unix seconds
+}
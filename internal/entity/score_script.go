@@ -0,0 +1,142 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vearch/vearch/v3/internal/pkg/scoreexpr"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	// ScoreScriptParam is the head param carrying the script from router to ps
+	ScoreScriptParam = "score_script"
+
+	ScoreScriptScore = "_score"
+	ScoreScriptNow   = "now"
+
+	// MaxScoreScriptCost bounds the nodes evaluated by one partition search
+	MaxScoreScriptCost = 1 << 24
+)
+
+// ScoreScript replaces the score of each candidate by a scoreexpr expression
+// over _score, now and numeric or date fields. Like Boost each partition
+// scores oversample * topN candidates before its topN cut, and for L2 a
+// smaller result ranks first.
+type ScoreScript struct {
+	Source     string  `json:"source"`
+	Oversample int32   `json:"oversample,omitempty"`
+	Missing    float64 `json:"missing,omitempty"`     // value of fields a document does not have
+	MetricType string  `json:"metric_type,omitempty"` // set by router
+	Now        int64   `json:"now,omitempty"`         // set by router, unix seconds
+
+	program *scoreexpr.Program
+}
+
+// Compile checks the script against the space fields and fills defaults, it
+// is called once per request on router and on each partition
+func (s *ScoreScript) Compile(proMap map[string]*SpaceProperties) error {
+	if s.Oversample <= 0 {
+		s.Oversample = DefaultBoostOversample
+	}
+	if s.Oversample > MaxBoostOversample {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("score_script oversample should not exceed %d", MaxBoostOversample))
+	}
+	var fieldErr error
+	program, err := scoreexpr.Compile(s.Source, func(name string) bool {
+		if name == ScoreScriptScore || name == ScoreScriptNow {
+			return true
+		}
+		pro := proMap[name]
+		if pro == nil {
+			return false
+		}
+		switch pro.FieldType {
+		case vearchpb.FieldType_INT, vearchpb.FieldType_LONG, vearchpb.FieldType_FLOAT, vearchpb.FieldType_DOUBLE, vearchpb.FieldType_DATE:
+		default:
+			if fieldErr == nil {
+				fieldErr = fmt.Errorf("score_script field [%s] should be numeric or date", name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("compile score_script err: %v", err))
+	}
+	if fieldErr != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fieldErr)
+	}
+	s.program = program
+	return nil
+}
+
+// Fields returns the space fields read by the script
+func (s *ScoreScript) Fields() []string {
+	fields := make([]string, 0, len(s.program.Vars()))
+	for _, name := range s.program.Vars() {
+		if name != ScoreScriptScore && name != ScoreScriptNow {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// CheckCost rejects running the script on more candidates than the budget
+func (s *ScoreScript) CheckCost(candidates int) error {
+	if cost := int64(s.program.Cost()) * int64(candidates); cost > MaxScoreScriptCost {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("score_script cost %d of %d candidates exceeds %d", cost, candidates, MaxScoreScriptCost))
+	}
+	return nil
+}
+
+// Score returns the script result of a candidate with fields, a NaN result
+// ranks last
+func (s *ScoreScript) Score(score float64, fields []*vearchpb.Field, proMap map[string]*SpaceProperties) float64 {
+	vars := s.program.Vars()
+	values := make([]float64, len(vars))
+	for i, name := range vars {
+		switch name {
+		case ScoreScriptScore:
+			values[i] = score
+		case ScoreScriptNow:
+			values[i] = float64(s.Now)
+		default:
+			values[i] = s.Missing
+		}
+	}
+	for _, fv := range fields {
+		if len(fv.Value) == 0 {
+			continue
+		}
+		for i, name := range vars {
+			if pro := proMap[name]; pro != nil && name == fv.Name {
+				if v, ok := boostValue(pro.FieldType, fv.Value); ok {
+					values[i] = v
+				}
+			}
+		}
+	}
+
+	result := s.program.Eval(values)
+	if math.IsNaN(result) {
+		if s.MetricType == "L2" {
+			return math.MaxFloat64
+		}
+		return -math.MaxFloat64
+	}
+	return result
+}
@@ -42,6 +42,49 @@ type Server struct {
 	Size              uint64        `json:"size,omitempty"`
 	Private           bool          `json:"private"`
 	Version           *BuildVersion `json:"version"`
+	Capabilities      *Capabilities `json:"capabilities,omitempty"`
+}
+
+const (
+	FeatureBoost       = "boost"
+	FeatureScoreScript = "score_script"
+)
+
+// PsFeatures are the optional features this ps build serves
+var PsFeatures = []string{FeatureBoost, FeatureScoreScript}
+
+// Capabilities is what a ps build supports, registered with the server
+type Capabilities struct {
+	IndexTypes []string `json:"index_types"`
+	Features   []string `json:"features"`
+}
+
+// SupportsIndex reports whether the server can build indexType, servers
+// registered before capabilities support the legacy index types only
+func (s *Server) SupportsIndex(indexType string) bool {
+	types := LegacyIndexTypes
+	if s.Capabilities != nil {
+		types = s.Capabilities.IndexTypes
+	}
+	for _, t := range types {
+		if t == indexType {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsFeature reports whether the server serves feature
+func (s *Server) SupportsFeature(feature string) bool {
+	if s.Capabilities == nil {
+		return false
+	}
+	for _, f := range s.Capabilities.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
 }
 
 // FailServer /fail/server/id:[body] ttl 3m 3s
@@ -0,0 +1,45 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestServer_Supports(t *testing.T) {
+	legacy := &Server{}
+	modern := &Server{Capabilities: &Capabilities{IndexTypes: []string{"FLAT", "DISKANN"}, Features: []string{FeatureBoost}}}
+	tests := []struct {
+		name      string
+		server    *Server
+		indexType string
+		feature   string
+		wantIndex bool
+		wantFeat  bool
+	}{
+		{name: "Server without capabilities supports legacy index types", server: legacy, indexType: "HNSW", feature: FeatureBoost, wantIndex: true, wantFeat: false},
+		{name: "Server without capabilities lacks new index types", server: legacy, indexType: "DISKANN", feature: FeatureScoreScript, wantIndex: false, wantFeat: false},
+		{name: "Server supports registered index type and feature", server: modern, indexType: "DISKANN", feature: FeatureBoost, wantIndex: true, wantFeat: true},
+		{name: "Server lacks index type and feature not registered", server: modern, indexType: "HNSW", feature: FeatureScoreScript, wantIndex: false, wantFeat: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.server.SupportsIndex(tt.indexType); got != tt.wantIndex {
+				t.Errorf("SupportsIndex(%s) = %v, want %v", tt.indexType, got, tt.wantIndex)
+			}
+			if got := tt.server.SupportsFeature(tt.feature); got != tt.wantFeat {
+				t.Errorf("SupportsFeature(%s) = %v, want %v", tt.feature, got, tt.wantFeat)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	MetricInnerProduct = "InnerProduct"
+	MetricL2           = "L2"
+	MetricCosine       = "Cosine"
+
+	// MaxSimilarityVectors is the most vectors of each set of a similarity request
+	MaxSimilarityVectors = 1024
+)
+
+// similarityMetrics are the metrics of the engine similarity kernels
+var similarityMetrics = map[string]int{MetricInnerProduct: 0, MetricL2: 1, MetricCosine: 2}
+
+// SimilarityRequest is the pairwise similarity of two small sets of vectors,
+// computed by the engine kernels of a ps without any index. The space only
+// locates the ps to compute on
+type SimilarityRequest struct {
+	DbName     string      `json:"db_name,omitempty"`
+	SpaceName  string      `json:"space_name,omitempty"`
+	MetricType string      `json:"metric_type,omitempty"` // InnerProduct if not set, or a space metric
+	VectorsA   [][]float32 `json:"vectors_a"`
+	VectorsB   [][]float32 `json:"vectors_b"`
+	// SpaceMetric is set by router if MetricType names a metric of the
+	// space, ps computes it in go instead of by the engine kernels
+	SpaceMetric *SpaceMetric `json:"space_metric,omitempty"`
+}
+
+// SimilarityResult holds the score of VectorsA[i] and VectorsB[j] at
+// Scores[i][j], L2 is the squared distance
+type SimilarityResult struct {
+	MetricType string      `json:"metric_type"`
+	Scores     [][]float32 `json:"scores"`
+}
+
+func (r *SimilarityRequest) Validate() error {
+	if r.MetricType == "" {
+		r.MetricType = MetricInnerProduct
+	}
+	if _, ok := similarityMetrics[r.MetricType]; !ok && r.SpaceMetric == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metric_type not support: %s, should be InnerProduct, L2, Cosine or a metric of the space", r.MetricType))
+	}
+	if len(r.VectorsA) == 0 || len(r.VectorsB) == 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vectors_a and vectors_b can not be empty"))
+	}
+	if len(r.VectorsA) > MaxSimilarityVectors || len(r.VectorsB) > MaxSimilarityVectors {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vectors_a and vectors_b should not exceed %d vectors", MaxSimilarityVectors))
+	}
+	d := len(r.VectorsA[0])
+	for _, vectors := range [][][]float32{r.VectorsA, r.VectorsB} {
+		for _, v := range vectors {
+			if len(v) == 0 || len(v) != d {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vectors should have the same dimension %d, got %d", d, len(v)))
+			}
+		}
+	}
+	return nil
+}
+
+// Metric returns the metric of the engine similarity kernels
+func (r *SimilarityRequest) Metric() int {
+	return similarityMetrics[r.MetricType]
+}
+
+// Dimension returns the dimension of the vectors of a validated request
+func (r *SimilarityRequest) Dimension() int {
+	return len(r.VectorsA[0])
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	DefaultSimilarityJoinTopK      = 10
+	MaxSimilarityJoinTopK          = 1000
+	DefaultSimilarityJoinQueryDocs = 10000
+	MaxSimilarityJoinQueryDocs     = 1000000
+
+	// fields of the documents written to the result space
+	SimilarityJoinSourceField = "source_id"
+	SimilarityJoinTargetField = "target_id"
+	SimilarityJoinScoreField  = "score"
+)
+
+// SimilarityJoinOutput is where the matches of a join are written, either
+// the documents of a result space or parquet objects under Prefix
+type SimilarityJoinOutput struct {
+	DbName    string   `json:"db_name,omitempty"`
+	SpaceName string   `json:"space_name,omitempty"`
+	S3Param   *S3Param `json:"s3_param,omitempty"`
+	Prefix    string   `json:"prefix,omitempty"`
+}
+
+// SimilarityJoin searches the TopK neighbors in the target space of the
+// vector Field of every document of the source space, or of the documents
+// matching Filters, and writes the matches to Output. Partition and Cursor
+// are the progress, the index of the partition in the source space and the
+// last docid joined in it, or the number of matches of the filters joined
+type SimilarityJoin struct {
+	Name            string                `json:"name"`
+	DbName          string                `json:"db_name"`
+	SpaceName       string                `json:"space_name"`
+	Field           string                `json:"field"`
+	Filters         json.RawMessage       `json:"filters,omitempty"`
+	MaxDocs         int                   `json:"max_docs,omitempty"` // matches of filters of a partition
+	TargetDbName    string                `json:"target_db_name"`
+	TargetSpaceName string                `json:"target_space_name"`
+	TargetField     string                `json:"target_field,omitempty"`
+	TopK            int                   `json:"top_k,omitempty"`
+	IndexParams     json.RawMessage       `json:"index_params,omitempty"`
+	Output          *SimilarityJoinOutput `json:"output"`
+	Rate            int                   `json:"rate,omitempty"` // documents per second
+	BatchSize       int                   `json:"batch_size,omitempty"`
+
+	Status     string `json:"status"`
+	Partition  int    `json:"partition"`
+	Cursor     int32  `json:"cursor"`
+	Joined     int64  `json:"joined"`
+	Matches    int64  `json:"matches"`
+	Files      int    `json:"files,omitempty"` // objects written to s3
+	Error      string `json:"error,omitempty"`
+	Runner     string `json:"runner,omitempty"` // router running the join
+	StartTime  int64  `json:"start_time"`
+	UpdateTime int64  `json:"update_time,omitempty"`
+	FinishTime int64  `json:"finish_time,omitempty"`
+}
+
+// Validate checks the join to create and sets the defaults
+func (j *SimilarityJoin) Validate() error {
+	if !migrationNameRegexp.MatchString(j.Name) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("join name %q should be letters, digits, _ or -", j.Name))
+	}
+	if j.DbName == "" || j.SpaceName == "" || j.Field == "" || j.TargetDbName == "" || j.TargetSpaceName == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("db_name, space_name, field, target_db_name and target_space_name should not be empty"))
+	}
+	if j.TargetField == "" {
+		j.TargetField = j.Field
+	}
+	if j.TopK < 0 || j.TopK > MaxSimilarityJoinTopK {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("top_k should be in [1, %d]", MaxSimilarityJoinTopK))
+	}
+	if j.TopK == 0 {
+		j.TopK = DefaultSimilarityJoinTopK
+	}
+	if j.MaxDocs < 0 || j.MaxDocs > MaxSimilarityJoinQueryDocs {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("max_docs should be in [1, %d]", MaxSimilarityJoinQueryDocs))
+	}
+	if len(j.Filters) > 0 && j.MaxDocs == 0 {
+		j.MaxDocs = DefaultSimilarityJoinQueryDocs
+	}
+
+	o := j.Output
+	if o == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("output should be set"))
+	}
+	toSpace := o.DbName != "" || o.SpaceName != ""
+	if toSpace == (o.S3Param != nil) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("output should be either a space or s3_param"))
+	}
+	if toSpace && (o.DbName == "" || o.SpaceName == "") {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("output db_name and space_name should not be empty"))
+	}
+	if toSpace && o.DbName == j.DbName && o.SpaceName == j.SpaceName {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("output should not be the source space"))
+	}
+	if o.S3Param != nil && (o.S3Param.BucketName == "" || o.Prefix == "") {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("output s3_param.bucket_name and prefix should be set"))
+	}
+
+	if j.Rate < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rate should not be negative"))
+	}
+	if j.Rate == 0 {
+		j.Rate = DefaultMigrationRate
+	}
+	if j.BatchSize < 0 || j.BatchSize > MaxMigrationBatchSize {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("batch_size should be in [1, %d]", MaxMigrationBatchSize))
+	}
+	if j.BatchSize == 0 {
+		j.BatchSize = DefaultMigrationBatchSize
+	}
+	return nil
+}
+
+// SelfJoin reports whether the source and target are the same space, the
+// document itself is not taken as its neighbor then
+func (j *SimilarityJoin) SelfJoin() bool {
+	return j.DbName == j.TargetDbName && j.SpaceName == j.TargetSpaceName
+}
+
+// Finished reports whether the join stopped
+func (j *SimilarityJoin) Finished() bool {
+	return j.Status != MigrationRunning
+}
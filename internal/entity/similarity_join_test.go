@@ -0,0 +1,61 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSimilarityJoin_Validate(t *testing.T) {
+	newJoin := func(output *SimilarityJoinOutput) SimilarityJoin {
+		return SimilarityJoin{Name: "items-to-users", DbName: "db", SpaceName: "items", Field: "vec",
+			TargetDbName: "db", TargetSpaceName: "users", Output: output}
+	}
+	toSpace := &SimilarityJoinOutput{DbName: "db", SpaceName: "matches"}
+	toS3 := &SimilarityJoinOutput{S3Param: &S3Param{BucketName: "bucket"}, Prefix: "joins/items"}
+
+	tests := []struct {
+		name    string
+		join    SimilarityJoin
+		wantErr bool
+	}{
+		{name: "Valid join to space", join: newJoin(toSpace)},
+		{name: "Valid join to s3", join: newJoin(toS3)},
+		{name: "Invalid join without output", join: newJoin(nil), wantErr: true},
+		{name: "Invalid join to both space and s3", join: newJoin(&SimilarityJoinOutput{DbName: "db", SpaceName: "matches", S3Param: toS3.S3Param, Prefix: "p"}), wantErr: true},
+		{name: "Invalid join to s3 without prefix", join: newJoin(&SimilarityJoinOutput{S3Param: &S3Param{BucketName: "bucket"}}), wantErr: true},
+		{name: "Invalid join to source space", join: newJoin(&SimilarityJoinOutput{DbName: "db", SpaceName: "items"}), wantErr: true},
+		{name: "Invalid join with bad name", join: func() SimilarityJoin { j := newJoin(toSpace); j.Name = "a b"; return j }(), wantErr: true},
+		{name: "Invalid join with top_k too large", join: func() SimilarityJoin { j := newJoin(toSpace); j.TopK = MaxSimilarityJoinTopK + 1; return j }(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.join.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("SimilarityJoin.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	j := newJoin(toSpace)
+	j.Filters = json.RawMessage(`{"operator":"AND","conditions":[]}`)
+	if err := j.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if j.TargetField != "vec" || j.TopK != DefaultSimilarityJoinTopK || j.MaxDocs != DefaultSimilarityJoinQueryDocs ||
+		j.Rate != DefaultMigrationRate || j.BatchSize != DefaultMigrationBatchSize {
+		t.Errorf("unexpected defaults %+v", j)
+	}
+}
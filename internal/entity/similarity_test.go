@@ -0,0 +1,73 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestSimilarityRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        SimilarityRequest
+		wantErr    bool
+		wantMetric int
+	}{
+		{
+			name:       "Default metric",
+			req:        SimilarityRequest{VectorsA: [][]float32{{1, 0}}, VectorsB: [][]float32{{0, 1}, {1, 1}}},
+			wantMetric: 0,
+		},
+		{
+			name:       "Cosine metric",
+			req:        SimilarityRequest{MetricType: MetricCosine, VectorsA: [][]float32{{1}}, VectorsB: [][]float32{{1}}},
+			wantMetric: 2,
+		},
+		{
+			name:    "Unknown metric",
+			req:     SimilarityRequest{MetricType: "Hamming", VectorsA: [][]float32{{1}}, VectorsB: [][]float32{{1}}},
+			wantErr: true,
+		},
+		{
+			name:    "Empty set",
+			req:     SimilarityRequest{VectorsA: [][]float32{{1}}},
+			wantErr: true,
+		},
+		{
+			name:    "Dimension mismatch across sets",
+			req:     SimilarityRequest{VectorsA: [][]float32{{1, 0}}, VectorsB: [][]float32{{1}}},
+			wantErr: true,
+		},
+		{
+			name:    "Empty vector",
+			req:     SimilarityRequest{VectorsA: [][]float32{{}}, VectorsB: [][]float32{{}}},
+			wantErr: true,
+		},
+		{
+			name:    "Too many vectors",
+			req:     SimilarityRequest{VectorsA: make([][]float32, MaxSimilarityVectors+1), VectorsB: [][]float32{{1}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.req.Metric() != tt.wantMetric {
+				t.Errorf("Metric() = %d, want %d", tt.req.Metric(), tt.wantMetric)
+			}
+		})
+	}
+}
@@ -60,6 +60,34 @@ var (
 	DefaultMinPointsPerCentroid = 39
 )
 
+// GammaIndexTypes are the index types of the gamma engine
+var GammaIndexTypes = []string{"IVFPQ", "IVFFLAT", "BINARYIVF", "FLAT", "HNSW", "GPU", "SSG", "IVFPQ_RELAYOUT", "SCANN", "SCALAR"}
+
+// IndexTypes are the index types of this build, the ones of gamma and of the
+// engines registered
+var IndexTypes = append([]string{}, GammaIndexTypes...)
+
+// RegisterIndexTypes adds the index types of an engine to IndexTypes, it is
+// called from the init of the engine package before IndexTypes is read
+func RegisterIndexTypes(types ...string) {
+	for _, t := range types {
+		have := false
+		for _, it := range IndexTypes {
+			if it == t {
+				have = true
+				break
+			}
+		}
+		if !have {
+			IndexTypes = append(IndexTypes, t)
+		}
+	}
+}
+
+// LegacyIndexTypes are supported by every ps, including the ones registered
+// without capabilities, new index types must not be added here
+var LegacyIndexTypes = []string{"IVFPQ", "IVFFLAT", "BINARYIVF", "FLAT", "HNSW", "GPU", "SSG", "IVFPQ_RELAYOUT", "SCANN", "SCALAR"}
+
 type IndexParams struct {
 	Nlinks            int    `json:"nlinks,omitempty"`
 	EfSearch          int    `json:"efSearch,omitempty"`
@@ -87,6 +115,47 @@ type Space struct {
 	Index           *Index                      `json:"index,omitempty"`
 	PartitionRule   *PartitionRule              `json:"partition_rule,omitempty"`
 	SpaceProperties map[string]*SpaceProperties `json:"space_properties"`
+	// DefaultSearchParams is the index_params of searches without index_params
+	DefaultSearchParams json.RawMessage `json:"default_search_params,omitempty"`
+	// ResourceGroup caps the searches and queries of space on ps
+	ResourceGroup string `json:"resource_group,omitempty"`
+	// FieldAliases maps alias names to scalar fields, routers resolve them so
+	// a field can be renamed without reindexing
+	FieldAliases map[string]string `json:"field_aliases,omitempty"`
+	// DefaultVectorField is searched by the vectors of searches without field
+	DefaultVectorField string `json:"default_vector_field,omitempty"`
+	// Pipeline is run by routers for the searches of space
+	Pipeline []*PipelineStage `json:"pipeline,omitempty"`
+	// Metrics are the custom metrics of rerank stages and similarity
+	Metrics []*SpaceMetric `json:"metrics,omitempty"`
+	// UDFs are the user defined functions searches of space may call
+	UDFs []*SpaceUDF `json:"udfs,omitempty"`
+	// IngestPipeline is run by routers on the documents written to space
+	IngestPipeline []*IngestProcessor `json:"ingest_pipeline,omitempty"`
+	// DeadLetter keeps the documents the writes to space reject
+	DeadLetter *DeadLetter `json:"dead_letter,omitempty"`
+	// WriteGuard rejects or flags the documents written which duplicate others
+	WriteGuard *WriteGuard `json:"write_guard,omitempty"`
+	// Leadership prefers the replicas of some servers as leaders
+	Leadership *Leadership `json:"leadership,omitempty"`
+	// WritePause rejects the writes to space while it is set
+	WritePause *WritePause `json:"write_pause,omitempty"`
+	// Annotations are returned as they are in the responses of the searches
+	// of space, such as the version of the model of the vectors
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Template is the space template the space was created from
+	Template string `json:"template,omitempty"`
+	// UpdateTime is the hybrid logical timestamp of master writing the space
+	UpdateTime int64 `json:"update_time,omitempty"`
+}
+
+// Newer reports whether s is a later write of the space than old, by
+// UpdateTime or by Version for spaces written without it
+func (s *Space) Newer(old *Space) bool {
+	if s.UpdateTime != 0 && old.UpdateTime != 0 {
+		return s.UpdateTime > old.UpdateTime
+	}
+	return s.Version > old.Version
 }
 
 type SpaceSchema struct {
@@ -95,17 +164,32 @@ type SpaceSchema struct {
 }
 
 type SpaceInfo struct {
-	SpaceName     string           `json:"space_name,omitempty"`
-	Name          string           `json:"name,omitempty"` // for compitable with old version before v3.5.5, cluster health api use it
-	DbName        string           `json:"db_name"`
-	DocNum        uint64           `json:"doc_num"`
-	PartitionNum  int              `json:"partition_num"`
-	ReplicaNum    uint8            `json:"replica_num"`
-	Schema        *SpaceSchema     `json:"schema"`
-	PartitionRule *PartitionRule   `json:"partition_rule,omitempty"`
-	Status        string           `json:"status,omitempty"`
-	Partitions    []*PartitionInfo `json:"partitions"`
-	Errors        []string         `json:"errors,omitempty"`
+	SpaceName          string             `json:"space_name,omitempty"`
+	Version            Version            `json:"version,omitempty"`
+	Name               string             `json:"name,omitempty"` // for compitable with old version before v3.5.5, cluster health api use it
+	DbName             string             `json:"db_name"`
+	DocNum             uint64             `json:"doc_num"`
+	PartitionNum       int                `json:"partition_num"`
+	ReplicaNum         uint8              `json:"replica_num"`
+	Schema             *SpaceSchema       `json:"schema"`
+	PartitionRule      *PartitionRule     `json:"partition_rule,omitempty"`
+	SearchParams       json.RawMessage    `json:"default_search_params,omitempty"`
+	ResourceGroup      string             `json:"resource_group,omitempty"`
+	FieldAliases       map[string]string  `json:"field_aliases,omitempty"`
+	DefaultVectorField string             `json:"default_vector_field,omitempty"`
+	Pipeline           []*PipelineStage   `json:"pipeline,omitempty"`
+	Metrics            []*SpaceMetric     `json:"metrics,omitempty"`
+	UDFs               []*SpaceUDF        `json:"udfs,omitempty"`
+	IngestPipeline     []*IngestProcessor `json:"ingest_pipeline,omitempty"`
+	DeadLetter         *DeadLetter        `json:"dead_letter,omitempty"`
+	WriteGuard         *WriteGuard        `json:"write_guard,omitempty"`
+	Leadership         *Leadership        `json:"leadership,omitempty"`
+	WritePause         *WritePause        `json:"write_pause,omitempty"`
+	Annotations        map[string]string  `json:"annotations,omitempty"`
+	Template           string             `json:"template,omitempty"`
+	Status             string             `json:"status,omitempty"`
+	Partitions         []*PartitionInfo   `json:"partitions"`
+	Errors             []string           `json:"errors,omitempty"`
 }
 
 type SpacePartitionResource struct {
@@ -124,16 +208,18 @@ type SpaceDescribeRequest struct {
 	Detail    *bool  `json:"detail"`
 }
 
+type S3Param struct {
+	BucketName string `json:"bucket_name"`
+	EndPoint   string `json:"endpoint"`
+	AccessKey  string `json:"access_key"`
+	SecretKey  string `json:"secret_key"`
+	UseSSL     bool   `json:"use_ssl"`
+}
+
 type BackupSpace struct {
-	Command string `json:"command,omitempty"`
-	Part    int    `json:"part"`
-	S3Param struct {
-		BucketName string `json:"bucket_name"`
-		EndPoint   string `json:"endpoint"`
-		AccessKey  string `json:"access_key"`
-		SecretKey  string `json:"secret_key"`
-		UseSSL     bool   `json:"use_ssl"`
-	} `json:"s3_param,omitempty"`
+	Command string  `json:"command,omitempty"`
+	Part    int     `json:"part"`
+	S3Param S3Param `json:"s3_param,omitempty"`
 }
 
 type SpaceProperties struct {
@@ -145,6 +231,12 @@ type SpaceProperties struct {
 	StoreType  *string              `json:"store_type,omitempty"`
 	StoreParam json.RawMessage      `json:"store_param,omitempty"`
 	Option     vearchpb.FieldOption `json:"option,omitempty"`
+	Store      *bool                `json:"store,omitempty"`
+}
+
+// Stored reports whether the field is returned by reads
+func (sp *SpaceProperties) Stored() bool {
+	return sp.Store == nil || *sp.Store
 }
 
 func (s *Space) String() string {
@@ -234,12 +326,16 @@ func (index *Index) UnmarshalJSON(bs []byte) error {
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space Index json.Unmarshal err:%v", err))
 	}
 
-	indexTypeMap := map[string]string{"IVFPQ": "IVFPQ", "IVFFLAT": "IVFFLAT", "BINARYIVF": "BINARYIVF", "FLAT": "FLAT",
-		"HNSW": "HNSW", "GPU": "GPU", "SSG": "SSG", "IVFPQ_RELAYOUT": "IVFPQ_RELAYOUT", "SCANN": "SCANN", "SCALAR": "SCALAR"}
 	if tempIndex.Type == "" {
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index type is null"))
 	}
-	_, have := indexTypeMap[tempIndex.Type]
+	have := false
+	for _, t := range IndexTypes {
+		if t == tempIndex.Type {
+			have = true
+			break
+		}
+	}
 	if !have {
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index type not support: %s", tempIndex.Type))
 	}
@@ -254,7 +350,7 @@ func (index *Index) UnmarshalJSON(bs []byte) error {
 			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index params metric_type not support: %s, should be L2 or InnerProduct", indexParams.MetricType))
 		}
 
-		if tempIndex.Type == "HNSW" {
+		if tempIndex.Type == "HNSW" || tempIndex.Type == "go-hnsw" {
 			if indexParams.Nlinks != 0 {
 				if indexParams.Nlinks < MinNlinks || indexParams.Nlinks > MaxNlinks {
 					return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index params nlinks:%d should in [%d, %d]", indexParams.Nlinks, MinNlinks, MaxNlinks))
@@ -302,6 +398,42 @@ func (index *Index) UnmarshalJSON(bs []byte) error {
 }
 
 // check params is ok
+// ValidateFieldAliases checks every alias names a scalar field of space and
+// does not shadow a field or another alias
+func (space *Space) ValidateFieldAliases(aliases map[string]string) error {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	for alias, field := range aliases {
+		if alias == "" || alias == IdField || alias == ScoreField {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field alias %q is not allowed", alias))
+		}
+		if proMap[alias] != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field alias %s is a field of space %s", alias, space.Name))
+		}
+		pro := proMap[field]
+		if pro == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s of alias %s not found in space %s", field, alias, space.Name))
+		}
+		if pro.FieldType == vearchpb.FieldType_VECTOR {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s of alias %s is not a scalar field", field, alias))
+		}
+	}
+	return nil
+}
+
+// ResolveField returns the field an alias names, or name if it is no alias
+func (space *Space) ResolveField(name string) string {
+	if field, ok := space.FieldAliases[name]; ok {
+		return field
+	}
+	return name
+}
+
 func (space *Space) Validate() error {
 	rs := []rune(space.Name)
 
@@ -334,6 +466,7 @@ type Field struct {
 	StoreType  *string `json:"store_type,omitempty"`
 	Format     *string `json:"format,omitempty"`
 	Index      *Index  `json:"index,omitempty"`
+	Store      *bool   `json:"store,omitempty"` // false keeps the field only for its index
 	StoreParam *struct {
 		CacheSize int `json:"cache_size,omitempty"`
 	} `json:"store_param,omitempty"`
@@ -422,6 +555,13 @@ func UnmarshalPropertyJSON(propertity []byte) (map[string]*SpaceProperties, erro
 			sp.Index = data.Index
 		}
 
+		if data.Store != nil && !*data.Store {
+			if isVector || sp.Index == nil {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] not stored should be an indexed scalar field", data.Name))
+			}
+			sp.Store = data.Store
+		}
+
 		// set date format
 		if sp.Format != nil {
 			if !(sp.FieldType == vearchpb.FieldType_DATE || sp.FieldType == vearchpb.FieldType_VECTOR) {
@@ -0,0 +1,170 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	SpaceCopyCommandStart  = "start"
+	SpaceCopyCommandStatus = "status"
+
+	SpaceCopyRunning = "running"
+	SpaceCopyDone    = "done"
+	SpaceCopyFailed  = "failed"
+
+	SnapshotCommandOpen    = "open"
+	SnapshotCommandRead    = "read"
+	SnapshotCommandRelease = "release"
+
+	// MaxSnapshotChunk is the most bytes a snapshot read replies
+	MaxSnapshotChunk = 4 << 20
+)
+
+// SpaceCopy copies the documents of a space of another cluster into a space
+// of this cluster, the ps of the destination pull partition snapshots from
+// the ps of the source
+type SpaceCopy struct {
+	Command string `json:"command,omitempty"`
+	// SourceMaster is the http address of a master of the source cluster
+	SourceMaster string `json:"source_master,omitempty"`
+	User         string `json:"user,omitempty"`
+	Password     string `json:"password,omitempty"`
+	SourceDb     string `json:"source_db,omitempty"`
+	SourceSpace  string `json:"source_space,omitempty"`
+	// RateLimit caps the bytes per second each replica pulls, 0 is unlimited
+	RateLimit int64 `json:"rate_limit,omitempty"`
+	// Source is set by master for the replica of a partition
+	Source *PartitionCopySource `json:"source,omitempty"`
+}
+
+// SpaceCopySource is what the master of the source cluster replies for a
+// copy, a snapshot of every partition is kept open by the ps having it
+type SpaceCopySource struct {
+	Space      *Space                 `json:"space"`
+	Partitions []*PartitionCopySource `json:"partitions"`
+}
+
+type PartitionCopySource struct {
+	PartitionID PartitionID     `json:"partition_id"`
+	Slot        SlotID          `json:"slot"`
+	Addr        string          `json:"addr"`
+	Session     string          `json:"session"`
+	Files       []*SnapshotFile `json:"files"`
+}
+
+type SpaceCopyStatus struct {
+	PartitionID       PartitionID `json:"partition_id"`
+	NodeID            NodeID      `json:"node_id,omitempty"`
+	SourcePartitionID PartitionID `json:"source_partition_id,omitempty"`
+	State             string      `json:"state"`
+	Bytes             int64       `json:"bytes,omitempty"`
+	TotalBytes        int64       `json:"total_bytes,omitempty"`
+	Error             string      `json:"error,omitempty"`
+	StartTime         int64       `json:"start_time,omitempty"`
+	EndTime           int64       `json:"end_time,omitempty"`
+}
+
+// SnapshotRequest opens, reads or releases a snapshot of the engine files
+// of a partition kept for copies
+type SnapshotRequest struct {
+	Command string `json:"command"`
+	Session string `json:"session,omitempty"`
+	File    string `json:"file,omitempty"`
+	Offset  int64  `json:"offset,omitempty"`
+	Size    int    `json:"size,omitempty"`
+	// Compression of the read reply, the reply is not compressed if empty
+	Compression string `json:"compression,omitempty"`
+}
+
+type SnapshotManifest struct {
+	Session string          `json:"session"`
+	Files   []*SnapshotFile `json:"files"`
+}
+
+// SnapshotFile is a file of a snapshot, Name is relative to the engine path
+type SnapshotFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Validate checks the source of a partition copy before its files are
+// written under the engine path of a replica
+func (s *PartitionCopySource) Validate() error {
+	if s.Addr == "" || s.Session == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("copy source of partition %d has no addr or session", s.PartitionID))
+	}
+	for _, f := range s.Files {
+		if !filepath.IsLocal(f.Name) {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("snapshot file %s is not under the engine path", f.Name))
+		}
+		if b, err := hex.DecodeString(f.Sha256); err != nil || len(b) != 32 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("checksum of snapshot file %s should be a hex sha256", f.Name))
+		}
+	}
+	return nil
+}
+
+// CheckCopySource checks the engine files of src can be loaded by space,
+// they need the same partitions and the same fields in the same order
+func (space *Space) CheckCopySource(src *Space) error {
+	if space.PartitionNum != src.PartitionNum {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space %s has %d partitions but source space %s has %d", space.Name, space.PartitionNum, src.Name, src.PartitionNum))
+	}
+	srcSlots := make(map[SlotID]bool, len(src.Partitions))
+	for _, p := range src.Partitions {
+		srcSlots[p.Slot] = true
+	}
+	for _, p := range space.Partitions {
+		if !srcSlots[p.Slot] {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("source space %s has no partition of slot %d", src.Name, p.Slot))
+		}
+	}
+
+	fields, srcFields := make([]Field, 0), make([]Field, 0)
+	if err := json.Unmarshal(space.Fields, &fields); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
+	if err := json.Unmarshal(src.Fields, &srcFields); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
+	if len(fields) != len(srcFields) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space %s has %d fields but source space %s has %d", space.Name, len(fields), src.Name, len(srcFields)))
+	}
+	for i, f := range fields {
+		sf := srcFields[i]
+		if f.Name != sf.Name || f.Type != sf.Type || f.Dimension != sf.Dimension {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %d of space %s is %s %s %d but source has %s %s %d", i, space.Name, f.Name, f.Type, f.Dimension, sf.Name, sf.Type, sf.Dimension))
+		}
+		if indexType(f.Index) != indexType(sf.Index) {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s of space %s has index %q but source has %q", f.Name, space.Name, indexType(f.Index), indexType(sf.Index)))
+		}
+	}
+	return nil
+}
+
+func indexType(index *Index) string {
+	if index == nil {
+		return ""
+	}
+	return index.Type
+}
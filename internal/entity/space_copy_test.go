@@ -0,0 +1,72 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckCopySource(t *testing.T) {
+	fields := `[{"name":"title","type":"string"},{"name":"vec","type":"vector","dimension":8,"index":{"name":"idx","type":"HNSW"}}]`
+	space := func(partitionNum int, fields string) *Space {
+		s := &Space{Name: "space", PartitionNum: partitionNum, Fields: []byte(fields)}
+		for i := 0; i < partitionNum; i++ {
+			s.Partitions = append(s.Partitions, &Partition{Id: PartitionID(i + 1), Slot: SlotID(i * 100)})
+		}
+		return s
+	}
+	tests := []struct {
+		name    string
+		src     *Space
+		wantErr bool
+	}{
+		{name: "same", src: space(2, fields)},
+		{name: "partition number", src: space(3, fields), wantErr: true},
+		{name: "field order", src: space(2, `[{"name":"vec","type":"vector","dimension":8,"index":{"name":"idx","type":"HNSW"}},{"name":"title","type":"string"}]`), wantErr: true},
+		{name: "dimension", src: space(2, strings.Replace(fields, `"dimension":8`, `"dimension":16`, 1)), wantErr: true},
+		{name: "index type", src: space(2, strings.Replace(fields, `"HNSW"`, `"FLAT"`, 1)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := space(2, fields).CheckCopySource(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckCopySource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPartitionCopySourceValidate(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	tests := []struct {
+		name    string
+		source  PartitionCopySource
+		wantErr bool
+	}{
+		{name: "valid", source: PartitionCopySource{Addr: "ps:8081", Session: "s", Files: []*SnapshotFile{{Name: "table/data", Sha256: sum}}}},
+		{name: "no session", source: PartitionCopySource{Addr: "ps:8081"}, wantErr: true},
+		{name: "file outside", source: PartitionCopySource{Addr: "ps:8081", Session: "s", Files: []*SnapshotFile{{Name: "../meta/meta.txt", Sha256: sum}}}, wantErr: true},
+		{name: "absolute file", source: PartitionCopySource{Addr: "ps:8081", Session: "s", Files: []*SnapshotFile{{Name: "/etc/passwd", Sha256: sum}}}, wantErr: true},
+		{name: "bad checksum", source: PartitionCopySource{Addr: "ps:8081", Session: "s", Files: []*SnapshotFile{{Name: "sn", Sha256: "xyz"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.source.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/pkg/distance"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// SpaceMetric declares a metric of the distance registry with its params for
+// a vector field, rerank stages and similarity requests refer to it by name
+type SpaceMetric struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"` // registered name of the metric
+	Field  string          `json:"field"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// ValidateMetrics checks the metrics against the fields of space. A metric
+// registered in this process is built to check its params, the others may be
+// registered only by the plugins of ps and routers and are checked there
+func (space *Space) ValidateMetrics(metrics []*SpaceMetric) error {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool, len(metrics))
+	for i, m := range metrics {
+		if m == nil || m.Name == "" || m.Type == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metric %d should have name and type", i))
+		}
+		if names[m.Name] {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metric name %s is duplicated", m.Name))
+		}
+		names[m.Name] = true
+		pro := proMap[m.Field]
+		if pro == nil || pro.FieldType != vearchpb.FieldType_VECTOR {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] of metric %s is not vector field", m.Field, m.Name))
+		}
+		if distance.Registered(m.Type) {
+			if _, err := distance.New(m.Type, pro.Dimension, m.Params); err != nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metric %s err: %v", m.Name, err))
+			}
+		}
+	}
+	return nil
+}
+
+// Metric returns the metric of space by name, nil if it is not declared
+func (space *Space) Metric(name string) *SpaceMetric {
+	for _, m := range space.Metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// New builds the metric from the registry of this process
+func (m *SpaceMetric) New(dimension int) (distance.Metric, error) {
+	metric, err := distance.New(m.Type, dimension, m.Params)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metric %s err: %v", m.Name, err))
+	}
+	return metric, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// SpaceTemplateParam names the template of a space create
+const SpaceTemplateParam = "template"
+
+// names such as rag-768-cosine
+var spaceTemplateName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{0,127}$`)
+
+// SpaceTemplate is a space configuration platform admins keep for teams to
+// create spaces with. A space created from a template takes its fields and
+// index, its partition and replica counts are bounded by the template and
+// the other settings of the template are defaults of the space
+type SpaceTemplate struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Fields      json.RawMessage `json:"fields"`
+	Index       *Index          `json:"index,omitempty"`
+
+	PartitionNum    int   `json:"partition_num,omitempty"`
+	ReplicaNum      uint8 `json:"replica_num,omitempty"`
+	MaxPartitionNum int   `json:"max_partition_num,omitempty"` // 0 is unbounded
+	MaxReplicaNum   uint8 `json:"max_replica_num,omitempty"`   // 0 is unbounded
+
+	DefaultSearchParams json.RawMessage   `json:"default_search_params,omitempty"`
+	ResourceGroup       string            `json:"resource_group,omitempty"`
+	Annotations         map[string]string `json:"annotations,omitempty"`
+}
+
+func (t *SpaceTemplate) Validate() error {
+	if !spaceTemplateName.MatchString(t.Name) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space template name [%s] should start with a letter and have letters, digits, '_', '.' or '-'", t.Name))
+	}
+	if len(t.Fields) == 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space template %s should have fields", t.Name))
+	}
+	if _, err := UnmarshalPropertyJSON(t.Fields); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("fields of space template %s: %v", t.Name, err))
+	}
+	if t.PartitionNum < 0 || t.MaxPartitionNum < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition_num and max_partition_num of space template %s should not be negative", t.Name))
+	}
+	if t.MaxPartitionNum > 0 && t.PartitionNum > t.MaxPartitionNum {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition_num %d of space template %s is above max_partition_num %d", t.PartitionNum, t.Name, t.MaxPartitionNum))
+	}
+	if t.MaxReplicaNum > 0 && t.ReplicaNum > t.MaxReplicaNum {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("replica_num %d of space template %s is above max_replica_num %d", t.ReplicaNum, t.Name, t.MaxReplicaNum))
+	}
+	if len(t.DefaultSearchParams) > 0 && !json.Valid(t.DefaultSearchParams) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("default_search_params of space template %s is not valid json", t.Name))
+	}
+	return ValidateAnnotations(t.Annotations)
+}
+
+// Apply configures space by the template, the settings space sets win over
+// the defaults of the template
+func (t *SpaceTemplate) Apply(space *Space) error {
+	if len(space.Fields) > 0 || space.Index != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space %s of template %s should not set fields or index", space.Name, t.Name))
+	}
+	if space.PartitionNum <= 0 {
+		space.PartitionNum = t.PartitionNum
+	}
+	if space.ReplicaNum == 0 {
+		space.ReplicaNum = t.ReplicaNum
+	}
+	if t.MaxPartitionNum > 0 && space.PartitionNum > t.MaxPartitionNum {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition_num %d of space %s is above %d of template %s", space.PartitionNum, space.Name, t.MaxPartitionNum, t.Name))
+	}
+	if t.MaxReplicaNum > 0 && space.ReplicaNum > t.MaxReplicaNum {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("replica_num %d of space %s is above %d of template %s", space.ReplicaNum, space.Name, t.MaxReplicaNum, t.Name))
+	}
+	space.Template = t.Name
+	space.Fields = t.Fields
+	space.Index = t.Index
+	if len(space.DefaultSearchParams) == 0 {
+		space.DefaultSearchParams = t.DefaultSearchParams
+	}
+	if space.ResourceGroup == "" {
+		space.ResourceGroup = t.ResourceGroup
+	}
+	if t.Annotations != nil {
+		annotations := make(map[string]string, len(t.Annotations)+len(space.Annotations))
+		for k, v := range t.Annotations {
+			annotations[k] = v
+		}
+		for k, v := range space.Annotations {
+			annotations[k] = v
+		}
+		space.Annotations = annotations
+	}
+	return nil
+}
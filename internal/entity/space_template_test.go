@@ -0,0 +1,92 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var templateFields = json.RawMessage(`[{"name":"text","type":"string"},{"name":"embedding","type":"vector","dimension":768,"index":{"name":"hnsw","type":"HNSW","params":{"metric_type":"InnerProduct"}}}]`)
+
+func TestSpaceTemplate_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template *SpaceTemplate
+		wantErr  bool
+	}{
+		{name: "valid", template: &SpaceTemplate{Name: "rag-768-cosine", Fields: templateFields, PartitionNum: 2, MaxPartitionNum: 8}},
+		{name: "bad name", template: &SpaceTemplate{Name: "-rag", Fields: templateFields}, wantErr: true},
+		{name: "no fields", template: &SpaceTemplate{Name: "rag"}, wantErr: true},
+		{name: "partitions above max", template: &SpaceTemplate{Name: "rag", Fields: templateFields, PartitionNum: 16, MaxPartitionNum: 8}, wantErr: true},
+		{name: "replicas above max", template: &SpaceTemplate{Name: "rag", Fields: templateFields, ReplicaNum: 5, MaxReplicaNum: 3}, wantErr: true},
+		{name: "bad search params", template: &SpaceTemplate{Name: "rag", Fields: templateFields, DefaultSearchParams: json.RawMessage(`{`)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.template.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpaceTemplate_Apply(t *testing.T) {
+	template := &SpaceTemplate{
+		Name:            "rag-768-cosine",
+		Fields:          templateFields,
+		PartitionNum:    2,
+		ReplicaNum:      3,
+		MaxPartitionNum: 8,
+		Annotations:     map[string]string{"model": "bge-m3", "team": "platform"},
+	}
+	tests := []struct {
+		name          string
+		space         *Space
+		wantPartition int
+		wantReplica   uint8
+		wantErr       bool
+	}{
+		{name: "defaults", space: &Space{Name: "docs"}, wantPartition: 2, wantReplica: 3},
+		{name: "own counts", space: &Space{Name: "docs", PartitionNum: 8, ReplicaNum: 1}, wantPartition: 8, wantReplica: 1},
+		{name: "partitions above max", space: &Space{Name: "docs", PartitionNum: 9}, wantErr: true},
+		{name: "own fields", space: &Space{Name: "docs", Fields: templateFields}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := template.Apply(tt.space)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.space.PartitionNum != tt.wantPartition || tt.space.ReplicaNum != tt.wantReplica {
+				t.Errorf("Apply() partitions %d replicas %d, want %d %d", tt.space.PartitionNum, tt.space.ReplicaNum, tt.wantPartition, tt.wantReplica)
+			}
+			if string(tt.space.Fields) != string(templateFields) || tt.space.Annotations["model"] != "bge-m3" {
+				t.Errorf("Apply() space = %+v, want the fields and annotations of template", tt.space)
+			}
+		})
+	}
+
+	space := &Space{Name: "docs", Annotations: map[string]string{"team": "search"}}
+	if err := template.Apply(space); err != nil {
+		t.Fatal(err)
+	}
+	if space.Annotations["team"] != "search" || template.Annotations["team"] != "platform" {
+		t.Errorf("Apply() annotations = %v, want the ones of space to win without changing the template", space.Annotations)
+	}
+}
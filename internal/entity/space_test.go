@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 )
 
 func TestEngineSpaceString(t *testing.T) {
@@ -173,3 +174,223 @@ func TestSpace_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestSpace_ValidateFieldAliases(t *testing.T) {
+	space := &entity.Space{
+		Name: "ts_space",
+		SpaceProperties: map[string]*entity.SpaceProperties{
+			"title":  {FieldType: vearchpb.FieldType_STRING},
+			"vector": {FieldType: vearchpb.FieldType_VECTOR},
+		},
+	}
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		wantErr bool
+	}{
+		{"alias of scalar field", map[string]string{"name": "title"}, false},
+		{"no aliases", nil, false},
+		{"alias shadows field", map[string]string{"vector": "title"}, true},
+		{"alias of vector field", map[string]string{"embedding": "vector"}, true},
+		{"alias of missing field", map[string]string{"name": "missing"}, true},
+		{"alias of alias", map[string]string{"name": "title", "label": "name"}, true},
+		{"alias of id", map[string]string{"_id": "title"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := space.ValidateFieldAliases(tt.aliases); (err != nil) != tt.wantErr {
+				t.Errorf("Space.ValidateFieldAliases() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	space.FieldAliases = map[string]string{"name": "title"}
+	if got := space.ResolveField("name"); got != "title" {
+		t.Errorf("Space.ResolveField(name) = %s, want title", got)
+	}
+	if got := space.ResolveField("title"); got != "title" {
+		t.Errorf("Space.ResolveField(title) = %s, want title", got)
+	}
+}
+
+func TestDetectOrphanPartitions(t *testing.T) {
+	spaces := []*entity.Space{{Id: 1, Name: "space", DBId: 1, Partitions: []*entity.Partition{
+		{Id: 1, Replicas: []entity.NodeID{1, 2}},
+		{Id: 2, Replicas: []entity.NodeID{1, 3}},
+	}}}
+	servers := []*entity.Server{
+		{ID: 1, PartitionIds: []entity.PartitionID{1}},    // partition 2 missing
+		{ID: 2, PartitionIds: []entity.PartitionID{1, 5}}, // partition 5 in no space
+		{ID: 4, PartitionIds: []entity.PartitionID{2}},    // not a replica of partition 2
+	}
+	orphans := entity.DetectOrphanPartitions(spaces, servers, map[entity.DBID]string{1: "db"})
+	want := []string{
+		entity.OrphanMissing + "/2/1",
+		entity.OrphanDirectory + "/2/4",
+		entity.OrphanDirectory + "/5/2",
+	}
+	if len(orphans) != len(want) {
+		t.Fatalf("DetectOrphanPartitions() = %d orphans, want %d", len(orphans), len(want))
+	}
+	for i, o := range orphans {
+		if o.Key() != want[i] {
+			t.Errorf("DetectOrphanPartitions()[%d] = %s, want %s", i, o.Key(), want[i])
+		}
+	}
+	if orphans[1].SpaceName != "space" || orphans[2].SpaceName != "" {
+		t.Errorf("DetectOrphanPartitions() spaces = %s, %s", orphans[1].SpaceName, orphans[2].SpaceName)
+	}
+}
+
+func TestSpaceNewer(t *testing.T) {
+	old := &entity.Space{Version: 3}
+	if !(&entity.Space{Version: 4}).Newer(old) {
+		t.Fatal("space without update time should compare by version")
+	}
+	old.UpdateTime = 200
+	if !(&entity.Space{Version: 4, UpdateTime: 100}).Newer(&entity.Space{Version: 3}) {
+		t.Fatal("space written before update time should compare by version")
+	}
+	// a rollback decreases the version but is written later
+	if !(&entity.Space{Version: 2, UpdateTime: 300}).Newer(old) {
+		t.Fatal("space with a later update time should be newer")
+	}
+	if (&entity.Space{Version: 5, UpdateTime: 100}).Newer(old) {
+		t.Fatal("space with an earlier update time should not be newer")
+	}
+}
+
+func TestSpace_ValidatePipeline(t *testing.T) {
+	space := &entity.Space{
+		Name: "ts_space",
+		SpaceProperties: map[string]*entity.SpaceProperties{
+			"title":  {FieldType: vearchpb.FieldType_STRING},
+			"sparse": {FieldType: vearchpb.FieldType_VECTOR},
+			"dense":  {FieldType: vearchpb.FieldType_VECTOR},
+		},
+		Metrics: []*entity.SpaceMetric{{Name: "weighted", Type: "WeightedL2", Field: "dense"}},
+	}
+	retrieve := func(limit int32) *entity.PipelineStage {
+		return &entity.PipelineStage{Type: entity.PipelineRetrieve, Field: "sparse", Limit: limit}
+	}
+	rerank := func(field string, limit int32) *entity.PipelineStage {
+		return &entity.PipelineStage{Type: entity.PipelineRerank, Field: field, Limit: limit}
+	}
+	crossEncoder := &entity.PipelineStage{Type: entity.PipelineCrossEncoder, TextField: "title", Reranker: "bge", Limit: 10}
+	tests := []struct {
+		name    string
+		stages  []*entity.PipelineStage
+		wantErr bool
+	}{
+		{"three stages", []*entity.PipelineStage{retrieve(1000), rerank("dense", 100), crossEncoder}, false},
+		{"no stages", nil, false},
+		{"retrieve only", []*entity.PipelineStage{retrieve(10)}, false},
+		{"no retrieve", []*entity.PipelineStage{rerank("dense", 100)}, true},
+		{"two retrieves", []*entity.PipelineStage{retrieve(100), retrieve(10)}, true},
+		{"increasing limit", []*entity.PipelineStage{retrieve(100), rerank("dense", 1000)}, true},
+		{"rerank scalar field", []*entity.PipelineStage{retrieve(100), rerank("title", 10)}, true},
+		{"zero limit", []*entity.PipelineStage{retrieve(0)}, true},
+		{"unknown type", []*entity.PipelineStage{retrieve(100), {Type: "bm25", Limit: 10}}, true},
+		{"rerank by space metric", []*entity.PipelineStage{retrieve(100), {Type: entity.PipelineRerank, Field: "dense", Metric: "weighted", Limit: 10}}, false},
+		{"metric of other field", []*entity.PipelineStage{retrieve(100), {Type: entity.PipelineRerank, Field: "sparse", Metric: "weighted", Limit: 10}}, true},
+		{"metric not declared", []*entity.PipelineStage{retrieve(100), {Type: entity.PipelineRerank, Field: "dense", Metric: "L2", Limit: 10}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := space.ValidatePipeline(tt.stages); (err != nil) != tt.wantErr {
+				t.Errorf("Space.ValidatePipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpace_ValidateMetrics(t *testing.T) {
+	space := &entity.Space{
+		Name: "ts_space",
+		SpaceProperties: map[string]*entity.SpaceProperties{
+			"title": {FieldType: vearchpb.FieldType_STRING},
+			"dense": {FieldType: vearchpb.FieldType_VECTOR, Dimension: 2},
+		},
+	}
+	metric := func(name, typ, params string) *entity.SpaceMetric {
+		return &entity.SpaceMetric{Name: name, Type: typ, Field: "dense", Params: json.RawMessage(params)}
+	}
+	tests := []struct {
+		name    string
+		metrics []*entity.SpaceMetric
+		wantErr bool
+	}{
+		{"builtin metric", []*entity.SpaceMetric{metric("weighted", "WeightedL2", `{"weights":[1,2]}`)}, false},
+		{"metric of plugins", []*entity.SpaceMetric{metric("custom", "PluginMetric", "")}, false},
+		{"bad params", []*entity.SpaceMetric{metric("weighted", "WeightedL2", `{"weights":[1]}`)}, true},
+		{"duplicated name", []*entity.SpaceMetric{metric("m", "L2", ""), metric("m", "Cosine", "")}, true},
+		{"no type", []*entity.SpaceMetric{metric("m", "", "")}, true},
+		{"scalar field", []*entity.SpaceMetric{{Name: "m", Type: "L2", Field: "title"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := space.ValidateMetrics(tt.metrics); (err != nil) != tt.wantErr {
+				t.Errorf("Space.ValidateMetrics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpace_CheckVersion(t *testing.T) {
+	space := &entity.Space{Name: "ts_space", Version: 3}
+	for ifMatch, conflict := range map[string]bool{"": false, "*": false, "3": false, `"3"`: false, `W/"3"`: false, `W/"2"`: true} {
+		version, err := entity.ParseIfMatchVersion(ifMatch)
+		if err != nil {
+			t.Fatalf("parse %q: %v", ifMatch, err)
+		}
+		err = space.CheckVersion(version)
+		if _, ok := err.(*entity.SpaceVersionConflict); ok != conflict {
+			t.Errorf("If-Match %q: conflict %v, want %v", ifMatch, ok, conflict)
+		}
+	}
+	if _, err := entity.ParseIfMatchVersion(`"abc"`); err == nil {
+		t.Error("expect error for a tag which is not a version")
+	}
+}
+
+func TestIndexManifest_Validate(t *testing.T) {
+	space := &entity.Space{
+		Name: "ts_space",
+		SpaceProperties: map[string]*entity.SpaceProperties{
+			"title": {FieldType: vearchpb.FieldType_STRING},
+			"vec": {FieldType: vearchpb.FieldType_VECTOR, Dimension: 128, Index: &entity.Index{
+				Type: "HNSW", Params: json.RawMessage(`{"metric_type":"InnerProduct","nlinks":32,"efConstruction":100}`),
+			}},
+		},
+	}
+	sum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	manifest := func(edit func(m *entity.IndexManifest)) *entity.IndexManifest {
+		m := &entity.IndexManifest{Field: "vec", IndexType: "HNSW", Dimension: 128, MetricType: "InnerProduct",
+			Nlinks: 32, EfConstruction: 100, VectorNum: 1000, Files: map[string]string{"hnswlib.index": sum}}
+		edit(m)
+		return m
+	}
+	tests := []struct {
+		name     string
+		field    string
+		manifest *entity.IndexManifest
+		wantErr  bool
+	}{
+		{"valid", "vec", manifest(func(m *entity.IndexManifest) {}), false},
+		{"other field", "title", manifest(func(m *entity.IndexManifest) { m.Field = "title" }), true},
+		{"field mismatch", "vec", manifest(func(m *entity.IndexManifest) { m.Field = "vec2" }), true},
+		{"dimension", "vec", manifest(func(m *entity.IndexManifest) { m.Dimension = 64 }), true},
+		{"metric", "vec", manifest(func(m *entity.IndexManifest) { m.MetricType = "L2" }), true},
+		{"nlinks", "vec", manifest(func(m *entity.IndexManifest) { m.Nlinks = 16 }), true},
+		{"no vectors", "vec", manifest(func(m *entity.IndexManifest) { m.VectorNum = 0 }), true},
+		{"missing file", "vec", manifest(func(m *entity.IndexManifest) { m.Files = map[string]string{"other": sum} }), true},
+		{"bad checksum", "vec", manifest(func(m *entity.IndexManifest) { m.Files["hnswlib.index"] = "abc" }), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.manifest.Validate(space, tt.field); (err != nil) != tt.wantErr {
+				t.Errorf("IndexManifest.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SpaceVersionHeader carries the schema version of the space in replies
+const SpaceVersionHeader = "X-Space-Version"
+
+// SpaceETag is the weak etag of a space schema version, the status and doc
+// counts of a described space are not covered by it
+func SpaceETag(version Version) string {
+	return fmt.Sprintf(`W/"%d"`, version)
+}
+
+// ParseIfMatchVersion returns the space version required by an If-Match
+// header, 0 if the header is empty or "*"
+func ParseIfMatchVersion(ifMatch string) (Version, error) {
+	tag := strings.TrimSpace(ifMatch)
+	if tag == "" || tag == "*" {
+		return 0, nil
+	}
+	tag = strings.Trim(strings.TrimPrefix(tag, "W/"), `"`)
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("If-Match should be a space version, but is %s", ifMatch)
+	}
+	return version, nil
+}
+
+// SpaceVersionConflict is returned by an update which requires a version
+// the space no longer has
+type SpaceVersionConflict struct {
+	Space   string
+	Expect  Version
+	Current Version
+}
+
+func (e *SpaceVersionConflict) Error() string {
+	return fmt.Sprintf("space %s version is %d but update requires %d", e.Space, e.Current, e.Expect)
+}
+
+// CheckVersion returns a *SpaceVersionConflict if expect is not 0 and not
+// the version of space
+func (space *Space) CheckVersion(expect Version) error {
+	if expect == 0 || expect == space.Version {
+		return nil
+	}
+	return &SpaceVersionConflict{Space: space.Name, Expect: expect, Current: space.Version}
+}
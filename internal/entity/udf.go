@@ -0,0 +1,233 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/pkg/wasm"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	UDFKindFilter = "filter"
+	UDFKindScore  = "score"
+
+	// UDFParam is the head param carrying the udf call from router to ps
+	UDFParam = "udf"
+
+	MaxUDFs          = 16
+	MaxUDFModuleSize = 256 << 10
+	// MaxUDFModulesSize bounds the modules of a space, they are stored with
+	// the space in etcd
+	MaxUDFModulesSize = 512 << 10
+	MaxUDFFields      = 16
+
+	// DefaultUDFFuel is the instructions a udf runs per candidate
+	DefaultUDFFuel = 10000
+	MaxUDFFuel     = 1 << 20
+
+	DefaultUDFMemoryPages = 1
+	MaxUDFMemoryPages     = 16
+)
+
+// SpaceUDF is a user defined function of space, a sandboxed wasm module
+// exporting function. It is called for each candidate of a search with the
+// f64 score followed by the f64 values of fields, a filter returns an i32 and
+// drops the candidate if it is 0, a score returns the new f64 score. Only the
+// udfs of a space can be called by its searches.
+type SpaceUDF struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	Function    string   `json:"function,omitempty"` // exported function, kind if not set
+	Fields      []string `json:"fields,omitempty"`   // numeric or date fields
+	Missing     float64  `json:"missing,omitempty"`  // value of fields a document does not have
+	Module      []byte   `json:"module"`             // wasm binary, base64 in json
+	Fuel        int64    `json:"fuel,omitempty"`     // instructions per candidate
+	MemoryPages uint32   `json:"memory_pages,omitempty"`
+}
+
+// ValidateUDFs compiles the modules of udfs and checks their functions
+// against the fields of space, filling defaults
+func (space *Space) ValidateUDFs(udfs []*SpaceUDF) error {
+	if len(udfs) > MaxUDFs {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udfs should not exceed %d", MaxUDFs))
+	}
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool, len(udfs))
+	size := 0
+	for i, u := range udfs {
+		if u == nil || u.Name == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %d should have name", i))
+		}
+		if names[u.Name] {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf name %s is duplicated", u.Name))
+		}
+		names[u.Name] = true
+		if u.Kind != UDFKindFilter && u.Kind != UDFKindScore {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s kind should be %s or %s", u.Name, UDFKindFilter, UDFKindScore))
+		}
+		if len(u.Fields) > MaxUDFFields {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s fields should not exceed %d", u.Name, MaxUDFFields))
+		}
+		for _, field := range u.Fields {
+			pro := proMap[field]
+			if pro == nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s field [%s] not space field", u.Name, field))
+			}
+			switch pro.FieldType {
+			case vearchpb.FieldType_INT, vearchpb.FieldType_LONG, vearchpb.FieldType_FLOAT, vearchpb.FieldType_DOUBLE, vearchpb.FieldType_DATE:
+			default:
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s field [%s] should be numeric or date", u.Name, field))
+			}
+		}
+		if size += len(u.Module); size > MaxUDFModulesSize {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf modules of a space should not exceed %d bytes", MaxUDFModulesSize))
+		}
+		if u.Fuel <= 0 {
+			u.Fuel = DefaultUDFFuel
+		}
+		if u.Fuel > MaxUDFFuel {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s fuel should not exceed %d", u.Name, MaxUDFFuel))
+		}
+		if u.MemoryPages == 0 {
+			u.MemoryPages = DefaultUDFMemoryPages
+		}
+		if u.MemoryPages > MaxUDFMemoryPages {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s memory_pages should not exceed %d", u.Name, MaxUDFMemoryPages))
+		}
+		if _, err := u.Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UDF returns the udf of space by name, nil if it is not declared
+func (space *Space) UDF(name string) *SpaceUDF {
+	for _, u := range space.UDFs {
+		if u.Name == name {
+			return u
+		}
+	}
+	return nil
+}
+
+// Export returns the exported function of the udf
+func (u *SpaceUDF) Export() string {
+	if u.Function != "" {
+		return u.Function
+	}
+	return u.Kind
+}
+
+// Signature returns the type the exported function must have
+func (u *SpaceUDF) Signature() wasm.FuncType {
+	params := make([]wasm.ValueType, len(u.Fields)+1)
+	for i := range params {
+		params[i] = wasm.F64
+	}
+	result := wasm.F64
+	if u.Kind == UDFKindFilter {
+		result = wasm.I32
+	}
+	return wasm.FuncType{Params: params, Results: []wasm.ValueType{result}}
+}
+
+// Compile decodes the module and checks the signature of its function
+func (u *SpaceUDF) Compile() (*wasm.Module, error) {
+	if len(u.Module) == 0 || len(u.Module) > MaxUDFModuleSize {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s module should have 1 to %d bytes", u.Name, MaxUDFModuleSize))
+	}
+	m, err := wasm.Compile(u.Module, u.MemoryPages)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s err: %v", u.Name, err))
+	}
+	typ, ok := m.Export(u.Export())
+	if !ok {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s module does not export function %s", u.Name, u.Export()))
+	}
+	if want := u.Signature(); !typ.Equal(want) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s function %s is %v, should be %v", u.Name, u.Export(), typ, want))
+	}
+	return m, nil
+}
+
+// UDFCall calls udfs of the space for the candidates of a search: each
+// partition filters and then scores oversample * topN candidates before its
+// topN cut, like Boost
+type UDFCall struct {
+	Filter     string `json:"filter,omitempty"`
+	Score      string `json:"score,omitempty"`
+	Oversample int32  `json:"oversample,omitempty"`
+	MetricType string `json:"metric_type,omitempty"` // set by router
+}
+
+// Validate checks the call against the udfs of space and fills defaults, it
+// returns the fields the udfs read
+func (c *UDFCall) Validate(space *Space) ([]string, error) {
+	if c.Filter == "" && c.Score == "" {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf should have filter or score"))
+	}
+	if c.Oversample <= 0 {
+		c.Oversample = DefaultBoostOversample
+	}
+	if c.Oversample > MaxBoostOversample {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf oversample should not exceed %d", MaxBoostOversample))
+	}
+	var fields []string
+	for _, call := range []struct{ name, kind string }{{c.Filter, UDFKindFilter}, {c.Score, UDFKindScore}} {
+		if call.name == "" {
+			continue
+		}
+		u := space.UDF(call.name)
+		if u == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s is not a udf of space %s", call.name, space.Name))
+		}
+		if u.Kind != call.kind {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf %s is not a %s udf", call.name, call.kind))
+		}
+		fields = append(fields, u.Fields...)
+	}
+	return fields, nil
+}
+
+// Args returns the arguments of the function of u for a candidate
+func (u *SpaceUDF) Args(score float64, fields []*vearchpb.Field, proMap map[string]*SpaceProperties) []float64 {
+	args := make([]float64, len(u.Fields)+1)
+	args[0] = score
+	for i := range u.Fields {
+		args[i+1] = u.Missing
+	}
+	for _, fv := range fields {
+		if len(fv.Value) == 0 {
+			continue
+		}
+		for i, name := range u.Fields {
+			if pro := proMap[name]; pro != nil && name == fv.Name {
+				if v, ok := boostValue(pro.FieldType, fv.Value); ok {
+					args[i+1] = v
+				}
+			}
+		}
+	}
+	return args
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// priceFilter exports filter(score, price f64) i32 returning price > 10
+var priceFilter = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x07, 0x01, 0x60, 0x02, 0x7c, 0x7c, 0x01, 0x7f,
+	0x03, 0x02, 0x01, 0x00,
+	0x07, 0x0a, 0x01, 0x06, 'f', 'i', 'l', 't', 'e', 'r', 0x00, 0x00,
+	0x0a, 0x10, 0x01, 0x0e, 0x00, 0x20, 0x01, 0x44, 0, 0, 0, 0, 0, 0, 0x24, 0x40, 0x64, 0x0b,
+}
+
+func TestValidateUDFs(t *testing.T) {
+	space := &Space{Name: "s", SpaceProperties: map[string]*SpaceProperties{
+		"price": {FieldType: vearchpb.FieldType_FLOAT},
+		"title": {FieldType: vearchpb.FieldType_STRING},
+	}}
+	tests := []struct {
+		name    string
+		udf     SpaceUDF
+		wantErr bool
+	}{
+		{name: "filter", udf: SpaceUDF{Name: "cheap", Kind: UDFKindFilter, Fields: []string{"price"}, Module: priceFilter}},
+		{name: "renamed function", udf: SpaceUDF{Name: "cheap", Kind: UDFKindFilter, Function: "keep", Fields: []string{"price"}, Module: priceFilter}, wantErr: true},
+		{name: "score signature", udf: SpaceUDF{Name: "cheap", Kind: UDFKindScore, Function: "filter", Fields: []string{"price"}, Module: priceFilter}, wantErr: true},
+		{name: "missing argument", udf: SpaceUDF{Name: "cheap", Kind: UDFKindFilter, Module: priceFilter}, wantErr: true},
+		{name: "string field", udf: SpaceUDF{Name: "cheap", Kind: UDFKindFilter, Fields: []string{"title"}, Module: priceFilter}, wantErr: true},
+		{name: "unknown kind", udf: SpaceUDF{Name: "cheap", Kind: "map", Fields: []string{"price"}, Module: priceFilter}, wantErr: true},
+		{name: "not wasm", udf: SpaceUDF{Name: "cheap", Kind: UDFKindFilter, Fields: []string{"price"}, Module: []byte("wasm")}, wantErr: true},
+		{name: "fuel over the limit", udf: SpaceUDF{Name: "cheap", Kind: UDFKindFilter, Fields: []string{"price"}, Module: priceFilter, Fuel: MaxUDFFuel + 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			udf := tt.udf
+			err := space.ValidateUDFs([]*SpaceUDF{&udf})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateUDFs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (udf.Fuel != DefaultUDFFuel || udf.MemoryPages != DefaultUDFMemoryPages) {
+				t.Fatalf("defaults not filled: %+v", udf)
+			}
+		})
+	}
+}
+
+func TestUDFCall(t *testing.T) {
+	space := &Space{Name: "s", UDFs: []*SpaceUDF{{Name: "cheap", Kind: UDFKindFilter, Fields: []string{"price"}, Module: priceFilter}}}
+	tests := []struct {
+		name    string
+		call    UDFCall
+		wantErr bool
+	}{
+		{name: "filter", call: UDFCall{Filter: "cheap"}},
+		{name: "empty", call: UDFCall{}, wantErr: true},
+		{name: "not allowed", call: UDFCall{Filter: "other"}, wantErr: true},
+		{name: "wrong kind", call: UDFCall{Score: "cheap"}, wantErr: true},
+		{name: "oversample", call: UDFCall{Filter: "cheap", Oversample: MaxBoostOversample + 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := tt.call.Validate(space)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (len(fields) != 1 || fields[0] != "price" || tt.call.Oversample != DefaultBoostOversample) {
+				t.Fatalf("Validate() = %v, %+v", fields, tt.call)
+			}
+		})
+	}
+	m, err := space.UDFs[0].Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := space.UDFs[0].Args(0.5, []*vearchpb.Field{{Name: "price", Value: []byte{0, 0, 0x40, 0x41}}}, map[string]*SpaceProperties{"price": {FieldType: vearchpb.FieldType_FLOAT}})
+	if len(args) != 2 || args[1] != 12 {
+		t.Fatalf("Args() = %v", args)
+	}
+	keep, err := m.Instantiate().Call("filter", 100, math.Float64bits(args[0]), math.Float64bits(args[1]))
+	if err != nil || keep[0] != 1 {
+		t.Fatalf("filter() = %v, %v", keep, err)
+	}
+}
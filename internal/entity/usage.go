@@ -0,0 +1,78 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// UsageDateLayout is the layout of the day of usage rollups, in utc
+	UsageDateLayout = "2006-01-02"
+	// UsageRetentionDays is the days usage rollups are kept
+	UsageRetentionDays = 90
+	// UsageAnonymous is the user of requests to routers skipping auth
+	UsageAnonymous = "_anonymous"
+
+	// cost units of a request are its documents and vector searches plus one
+	// unit by UsageBytesPerUnit bytes returned and one for the request itself
+	UsageBytesPerUnit = 1024
+)
+
+// Usage is the cost consumed by a user on a space in a day, Documents are
+// the documents read for the requests, Vectors the query vectors searched
+// in each partition and Bytes the response bytes
+type Usage struct {
+	Date      string `json:"date"`
+	User      string `json:"user"`
+	DbName    string `json:"db_name"`
+	SpaceName string `json:"space_name"`
+	Requests  int64  `json:"requests"`
+	Documents int64  `json:"documents"`
+	Vectors   int64  `json:"vectors"`
+	Bytes     int64  `json:"bytes"`
+	Units     int64  `json:"units"`
+}
+
+func (u *Usage) Add(o *Usage) {
+	u.Requests += o.Requests
+	u.Documents += o.Documents
+	u.Vectors += o.Vectors
+	u.Bytes += o.Bytes
+	u.Units = u.Requests + u.Documents + u.Vectors + u.Bytes/UsageBytesPerUnit
+}
+
+func UsageDate(t time.Time) string {
+	return t.UTC().Format(UsageDateLayout)
+}
+
+func UsageKey(date, user, dbName, spaceName string) string {
+	return fmt.Sprintf("%s%s/%s/%s/%s", PrefixUsage, date, user, dbName, spaceName)
+}
+
+func UsageDateKey(date string) string {
+	return fmt.Sprintf("%s%s/", PrefixUsage, date)
+}
+
+// UsageReport is the usage of the days from Start to End, with the totals
+// by user for chargeback
+type UsageReport struct {
+	Start string            `json:"start"`
+	End   string            `json:"end"`
+	Usage []*Usage          `json:"usage"`
+	Users map[string]*Usage `json:"users"`
+	Total *Usage            `json:"total"`
+}
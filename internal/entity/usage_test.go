@@ -0,0 +1,95 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsage_Add(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  Usage
+		add   []Usage
+		units int64
+	}{
+		{
+			name:  "Single request",
+			add:   []Usage{{Requests: 1, Documents: 10, Vectors: 2, Bytes: 100}},
+			units: 13,
+		},
+		{
+			name:  "Bytes by unit",
+			add:   []Usage{{Requests: 1, Bytes: 2 * UsageBytesPerUnit}},
+			units: 3,
+		},
+		{
+			name: "Bytes accumulated before division",
+			add: []Usage{
+				{Requests: 1, Bytes: UsageBytesPerUnit / 2},
+				{Requests: 1, Bytes: UsageBytesPerUnit / 2},
+			},
+			units: 3,
+		},
+		{
+			name:  "Added to rollup",
+			base:  Usage{Requests: 5, Documents: 5, Units: 10},
+			add:   []Usage{{Requests: 1, Vectors: 4}},
+			units: 15,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := tt.base
+			for i := range tt.add {
+				u.Add(&tt.add[i])
+			}
+			if u.Units != tt.units {
+				t.Errorf("Units = %d, want %d", u.Units, tt.units)
+			}
+		})
+	}
+}
+
+func TestUsageKey(t *testing.T) {
+	tests := []struct {
+		name string
+		time time.Time
+		want string
+	}{
+		{
+			name: "Utc day",
+			time: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			want: PrefixUsage + "2024-03-01/u/db/s",
+		},
+		{
+			name: "Local time before utc midnight",
+			time: time.Date(2024, 3, 1, 23, 30, 0, 0, time.FixedZone("west", -2*3600)),
+			want: PrefixUsage + "2024-03-02/u/db/s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date := UsageDate(tt.time)
+			if got := UsageKey(date, "u", "db", "s"); got != tt.want {
+				t.Errorf("UsageKey() = %s, want %s", got, tt.want)
+			}
+			if prefix := UsageDateKey(date); len(prefix) >= len(tt.want) || tt.want[:len(prefix)] != prefix {
+				t.Errorf("UsageDateKey() = %s is not prefix of %s", prefix, tt.want)
+			}
+		})
+	}
+}
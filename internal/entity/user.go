@@ -157,8 +157,11 @@ var RoleMap = map[string]Role{
 type NameType string
 
 const (
-	RoleNameType NameType = "Role"
-	UserNameType NameType = "User"
+	RoleNameType    NameType = "Role"
+	UserNameType    NameType = "User"
+	WebhookNameType NameType = "Webhook"
+
+	ResourceGroupNameType NameType = "ResourceGroup"
 )
 
 func ValidateName(name string, name_type NameType, check_root bool) error {
@@ -188,6 +191,16 @@ func ValidateName(name string, name_type NameType, check_root bool) error {
 	return nil
 }
 
+// readOnlyDocumentEndpoints are the document POST endpoints that only read,
+// advise applies its params through the master, which checks the caller's
+// write privilege on the space then
+var readOnlyDocumentEndpoints = map[string]bool{
+	"/document/evaluate":   true,
+	"/document/advise":     true,
+	"/document/cluster":    true,
+	"/document/similarity": true,
+}
+
 func ParseResources(endpoint string, method string) (resource Resource, privilege Privilege) {
 	switch method {
 	case "GET":
@@ -197,7 +210,8 @@ func ParseResources(endpoint string, method string) (resource Resource, privileg
 	}
 
 	resource = ResourceAll
-	if strings.HasPrefix(endpoint, "/cluster") {
+	// the jobs streamed by /jobs are the ones of /cluster
+	if strings.HasPrefix(endpoint, "/cluster") || strings.HasPrefix(endpoint, "/jobs") {
 		resource = ResourceCluster
 		return resource, privilege
 	}
@@ -220,6 +234,15 @@ func ParseResources(endpoint string, method string) (resource Resource, privileg
 		return resource, privilege
 	}
 
+	// teams read the templates to create spaces, platform admins write them
+	if strings.HasPrefix(endpoint, "/space_templates") {
+		resource = ResourceSpace
+		if privilege != ReadOnly {
+			resource = ResourceCluster
+		}
+		return resource, privilege
+	}
+
 	if strings.HasPrefix(endpoint, "/backup") {
 		resource = ResourceSpace
 		return resource, privilege
@@ -227,7 +250,7 @@ func ParseResources(endpoint string, method string) (resource Resource, privileg
 
 	if strings.HasPrefix(endpoint, "/document") {
 		resource = ResourceDocument
-		if strings.Contains(endpoint, "query") || strings.Contains(endpoint, "search") {
+		if method == "GET" || strings.Contains(endpoint, "query") || strings.Contains(endpoint, "search") || readOnlyDocumentEndpoints[endpoint] {
 			privilege = ReadOnly
 		} else {
 			privilege = WriteOnly
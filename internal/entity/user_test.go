@@ -0,0 +1,106 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestParseResources(t *testing.T) {
+	tests := []struct {
+		name          string
+		endpoint      string
+		method        string
+		wantResource  Resource
+		wantPrivilege Privilege
+	}{
+		{
+			name:          "Document upsert writes",
+			endpoint:      "/document/upsert",
+			method:        "POST",
+			wantResource:  ResourceDocument,
+			wantPrivilege: WriteOnly,
+		},
+		{
+			name:          "Document delete writes",
+			endpoint:      "/document/delete",
+			method:        "POST",
+			wantResource:  ResourceDocument,
+			wantPrivilege: WriteOnly,
+		},
+		{
+			name:          "Document search reads",
+			endpoint:      "/document/search",
+			method:        "POST",
+			wantResource:  ResourceDocument,
+			wantPrivilege: ReadOnly,
+		},
+		{
+			name:          "Document query reads",
+			endpoint:      "/document/query",
+			method:        "POST",
+			wantResource:  ResourceDocument,
+			wantPrivilege: ReadOnly,
+		},
+		{
+			name:          "Document evaluate reads",
+			endpoint:      "/document/evaluate",
+			method:        "POST",
+			wantResource:  ResourceDocument,
+			wantPrivilege: ReadOnly,
+		},
+		{
+			name:          "Document advise reads",
+			endpoint:      "/document/advise",
+			method:        "POST",
+			wantResource:  ResourceDocument,
+			wantPrivilege: ReadOnly,
+		},
+		{
+			name:          "Document cluster reads",
+			endpoint:      "/document/cluster",
+			method:        "POST",
+			wantResource:  ResourceDocument,
+			wantPrivilege: ReadOnly,
+		},
+		{
+			name:          "Document similarity reads",
+			endpoint:      "/document/similarity",
+			method:        "POST",
+			wantResource:  ResourceDocument,
+			wantPrivilege: ReadOnly,
+		},
+		{
+			name:          "Space create writes",
+			endpoint:      "/dbs/:db_name/spaces",
+			method:        "POST",
+			wantResource:  ResourceSpace,
+			wantPrivilege: WriteOnly,
+		},
+		{
+			name:          "Cluster get reads",
+			endpoint:      "/cluster/stats",
+			method:        "GET",
+			wantResource:  ResourceCluster,
+			wantPrivilege: ReadOnly,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, privilege := ParseResources(tt.endpoint, tt.method)
+			if resource != tt.wantResource || privilege != tt.wantPrivilege {
+				t.Errorf("ParseResources(%s, %s) = %s, %s, want %s, %s", tt.endpoint, tt.method, resource, privilege, tt.wantResource, tt.wantPrivilege)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+type WebhookEventType string
+
+const (
+	EventSpaceCreated      WebhookEventType = "space_created"
+	EventSpaceDeleted      WebhookEventType = "space_deleted"
+	EventPartitionFailover WebhookEventType = "partition_failover"
+	EventNodeJoined        WebhookEventType = "node_joined"
+	EventNodeLeft          WebhookEventType = "node_left"
+	EventBackupCompleted   WebhookEventType = "backup_completed"
+	EventOrphanPartition   WebhookEventType = "orphan_partition"
+)
+
+var WebhookEventMap = map[WebhookEventType]string{
+	EventSpaceCreated:      "space_created",
+	EventSpaceDeleted:      "space_deleted",
+	EventPartitionFailover: "partition_failover",
+	EventNodeJoined:        "node_joined",
+	EventNodeLeft:          "node_left",
+	EventBackupCompleted:   "backup_completed",
+	EventOrphanPartition:   "orphan_partition",
+}
+
+const (
+	DefaultWebhookMaxRetries = 3
+	DefaultWebhookTimeout    = 5000 // ms
+)
+
+// Webhook is a user supplied endpoint which receives cluster events,
+// if Events is empty all events will be posted to it
+type Webhook struct {
+	Name       string             `json:"name"`
+	URL        string             `json:"url"`
+	Secret     string             `json:"secret,omitempty"`
+	Events     []WebhookEventType `json:"events,omitempty"`
+	MaxRetries int                `json:"max_retries,omitempty"`
+	Timeout    int                `json:"timeout,omitempty"` // ms
+}
+
+func (hook *Webhook) Validate() error {
+	if err := ValidateName(hook.Name, WebhookNameType, false); err != nil {
+		return err
+	}
+	u, err := url.Parse(hook.URL)
+	if err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("webhook url %s is invalid, err: %s", hook.URL, err.Error()))
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("webhook url %s should be http or https", hook.URL))
+	}
+	for _, e := range hook.Events {
+		if _, ok := WebhookEventMap[e]; !ok {
+			keys := make([]WebhookEventType, 0, len(WebhookEventMap))
+			for k := range WebhookEventMap {
+				keys = append(keys, k)
+			}
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("webhook event: %s, should be %v", e, keys))
+		}
+	}
+	if hook.MaxRetries < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("webhook max_retries should not be negative"))
+	}
+	if hook.MaxRetries == 0 {
+		hook.MaxRetries = DefaultWebhookMaxRetries
+	}
+	if hook.Timeout <= 0 {
+		hook.Timeout = DefaultWebhookTimeout
+	}
+	return nil
+}
+
+// Accept return true if the webhook subscribes the event type
+func (hook *Webhook) Accept(eventType WebhookEventType) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, e := range hook.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is the json body posted to webhooks
+type WebhookEvent struct {
+	ID        string           `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	Cluster   string           `json:"cluster"`
+	Timestamp int64            `json:"timestamp"`
+	Data      interface{}      `json:"data,omitempty"`
+}
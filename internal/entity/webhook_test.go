@@ -0,0 +1,77 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestWebhook_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    Webhook
+		wantErr bool
+	}{
+		{
+			name:    "Valid webhook with all events",
+			hook:    Webhook{Name: "pager", URL: "https://example.com/hook"},
+			wantErr: false,
+		},
+		{
+			name:    "Valid webhook with subscribed events",
+			hook:    Webhook{Name: "pager", URL: "http://127.0.0.1:8080", Events: []WebhookEventType{EventNodeLeft, EventPartitionFailover}},
+			wantErr: false,
+		},
+		{
+			name:    "Invalid webhook with empty name",
+			hook:    Webhook{URL: "https://example.com/hook"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid webhook with unsupported scheme",
+			hook:    Webhook{Name: "pager", URL: "ftp://example.com/hook"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid webhook with unknown event",
+			hook:    Webhook{Name: "pager", URL: "https://example.com/hook", Events: []WebhookEventType{"space_renamed"}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid webhook with negative retries",
+			hook:    Webhook{Name: "pager", URL: "https://example.com/hook", MaxRetries: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hook.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Webhook.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhook_Accept(t *testing.T) {
+	all := &Webhook{Name: "all"}
+	if !all.Accept(EventSpaceCreated) || !all.Accept(EventBackupCompleted) {
+		t.Errorf("webhook without events should accept all events")
+	}
+	hook := &Webhook{Name: "node", Events: []WebhookEventType{EventNodeJoined, EventNodeLeft}}
+	if !hook.Accept(EventNodeLeft) {
+		t.Errorf("webhook should accept subscribed event %s", EventNodeLeft)
+	}
+	if hook.Accept(EventSpaceDeleted) {
+		t.Errorf("webhook should not accept unsubscribed event %s", EventSpaceDeleted)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	WriteGuardReject = "reject"
+	WriteGuardFlag   = "flag"
+
+	DeadLetterStageWriteGuard = "write_guard"
+)
+
+// WriteGuard keeps a space deduplicated: routers search the space by the
+// vector Field of each document written, and a document within Epsilon of
+// another document, in the space or earlier in the same write, is a
+// duplicate. Epsilon is the max distance for L2 and the max 1 - score for
+// InnerProduct, as of /document/dedup. Duplicates are rejected, kept by the
+// dead letter of the space if any, or written with the id of the document
+// they duplicate in the string field FlagField.
+type WriteGuard struct {
+	Field       string          `json:"field"`
+	Epsilon     float64         `json:"epsilon"`
+	Action      string          `json:"action,omitempty"`
+	FlagField   string          `json:"flag_field,omitempty"`
+	IndexParams json.RawMessage `json:"index_params,omitempty"`
+}
+
+// Validate checks the guard against the fields of space and sets the default
+// action
+func (g *WriteGuard) Validate(space *Space) error {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	if pro := proMap[g.Field]; pro == nil || pro.FieldType != vearchpb.FieldType_VECTOR {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("write guard field [%s] should be vector field of space %s", g.Field, space.Name))
+	}
+	if space.Index != nil && space.Index.Type == "BINARYIVF" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("write guard not support binary vector"))
+	}
+	if g.Epsilon < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("write guard epsilon should not be negative"))
+	}
+	if g.Action == "" {
+		g.Action = WriteGuardReject
+	}
+	switch g.Action {
+	case WriteGuardReject:
+		if g.FlagField != "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("write guard flag_field is only for action %s", WriteGuardFlag))
+		}
+	case WriteGuardFlag:
+		if pro := proMap[g.FlagField]; pro == nil || pro.FieldType != vearchpb.FieldType_STRING {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("write guard flag_field [%s] should be string field of space %s", g.FlagField, space.Name))
+		}
+	default:
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("write guard action should be %s or %s", WriteGuardReject, WriteGuardFlag))
+	}
+	return nil
+}
+
+// Near returns whether a score of the metric is within epsilon
+func (g *WriteGuard) Near(metric string, score float64) bool {
+	if metric == "L2" {
+		return score <= g.Epsilon
+	}
+	return 1-score <= g.Epsilon
+}
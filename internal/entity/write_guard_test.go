@@ -0,0 +1,73 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteGuard_Validate(t *testing.T) {
+	space := &Space{Name: "s", Fields: json.RawMessage(`[{"name":"title","type":"string"},{"name":"dup_of","type":"string"},{"name":"price","type":"float"},{"name":"vec","type":"vector","dimension":4}]`)}
+	tests := []struct {
+		name       string
+		guard      *WriteGuard
+		wantErr    bool
+		wantAction string
+	}{
+		{name: "default reject", guard: &WriteGuard{Field: "vec", Epsilon: 0.01}, wantAction: WriteGuardReject},
+		{name: "flag", guard: &WriteGuard{Field: "vec", Epsilon: 0.01, Action: WriteGuardFlag, FlagField: "dup_of"}, wantAction: WriteGuardFlag},
+		{name: "not vector field", guard: &WriteGuard{Field: "title"}, wantErr: true},
+		{name: "unknown field", guard: &WriteGuard{Field: "missing"}, wantErr: true},
+		{name: "negative epsilon", guard: &WriteGuard{Field: "vec", Epsilon: -1}, wantErr: true},
+		{name: "flag without flag field", guard: &WriteGuard{Field: "vec", Action: WriteGuardFlag}, wantErr: true},
+		{name: "flag field not string", guard: &WriteGuard{Field: "vec", Action: WriteGuardFlag, FlagField: "price"}, wantErr: true},
+		{name: "reject with flag field", guard: &WriteGuard{Field: "vec", FlagField: "dup_of"}, wantErr: true},
+		{name: "unknown action", guard: &WriteGuard{Field: "vec", Action: "drop"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.guard.Validate(space)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.guard.Action != tt.wantAction {
+				t.Errorf("Action = %s, want %s", tt.guard.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestWriteGuard_Near(t *testing.T) {
+	guard := &WriteGuard{Epsilon: 0.1}
+	tests := []struct {
+		name   string
+		metric string
+		score  float64
+		want   bool
+	}{
+		{name: "l2 within", metric: "L2", score: 0.05, want: true},
+		{name: "l2 beyond", metric: "L2", score: 0.2},
+		{name: "inner product within", metric: "InnerProduct", score: 0.95, want: true},
+		{name: "inner product beyond", metric: "InnerProduct", score: 0.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := guard.Near(tt.metric, tt.score); got != tt.want {
+				t.Errorf("Near() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
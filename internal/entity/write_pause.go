@@ -0,0 +1,54 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	// owners of write pauses
+	WritePauseOperator  = "operator"
+	WritePauseSpaceCopy = "space_copy"
+
+	// WritePauseRetryAfter is the seconds routers ask clients to wait
+	// before retrying the writes to a paused space
+	WritePauseRetryAfter = 5
+)
+
+// WritePause makes every router reject the writes to a space with a
+// retriable error until it is resumed, for maintenance needing the
+// documents of the space to stay as they are. Jobs resume the pauses they
+// own when they finish, the pauses of operators stay until resumed.
+type WritePause struct {
+	Reason string `json:"reason,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+	Time   int64  `json:"time,omitempty"` // unix seconds
+}
+
+// CheckWritable returns an unavailable error while the writes to space are
+// paused
+func (space *Space) CheckWritable() error {
+	if space.WritePause == nil {
+		return nil
+	}
+	msg := fmt.Sprintf("writes to space %s are paused by %s", space.Name, space.WritePause.Owner)
+	if space.WritePause.Reason != "" {
+		msg += ": " + space.WritePause.Reason
+	}
+	return vearchpb.NewError(vearchpb.ErrorEnum_SERVICE_UNAVAILABLE, fmt.Errorf("%s, retry later", msg))
+}
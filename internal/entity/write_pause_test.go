@@ -0,0 +1,56 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestSpaceCheckWritable(t *testing.T) {
+	tests := []struct {
+		name    string
+		pause   *WritePause
+		wantErr bool
+	}{
+		{
+			name: "Not paused",
+		},
+		{
+			name:    "Paused by operator",
+			pause:   &WritePause{Owner: WritePauseOperator, Reason: "schema maintenance"},
+			wantErr: true,
+		},
+		{
+			name:    "Paused by copy",
+			pause:   &WritePause{Owner: WritePauseSpaceCopy},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			space := &Space{Name: "ts_space", WritePause: tt.pause}
+			err := space.CheckWritable()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckWritable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, vearchpb.ErrUnavailable) {
+				t.Errorf("CheckWritable() error = %v, want unavailable", err)
+			}
+		})
+	}
+}
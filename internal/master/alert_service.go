@@ -0,0 +1,395 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+const (
+	alertSettingsName      = "alert"
+	alertStateSettingsName = "alert_state"
+)
+
+// alertManager evaluates alert rules over cluster health and server stats
+// periodically, and notifies the channels when a rule fires or resolves.
+// The master evaluating may change every interval, so the states of the
+// rules are kept in etcd
+type alertManager struct {
+	ms *masterService
+}
+
+func newAlertManager(ms *masterService) *alertManager {
+	return &alertManager{ms: ms}
+}
+
+// alertResult is the condition of a rule found by an evaluation, a skipped
+// rule could not be evaluated and keeps its state
+type alertResult struct {
+	rule     *entity.AlertRule
+	breached bool
+	skipped  bool
+	detail   string
+}
+
+// alertNotice is a message of a rule that fired or resolved
+type alertNotice struct {
+	rule *entity.AlertRule
+	msg  string
+}
+
+func (am *alertManager) start(ctx context.Context) {
+	go func() {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				log.Error("recover() err:[%v]", rErr)
+				log.Error("stack:[%s]", debug.Stack())
+			}
+		}()
+		interval := int64(entity.DefaultAlertInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("alert manager stopped")
+				return
+			case <-time.After(time.Duration(interval) * time.Second):
+			}
+
+			cfg, err := am.ms.getAlertConfigService(ctx)
+			if err != nil {
+				log.Error("get alert config err: %v", err)
+				continue
+			}
+			interval = cfg.Interval
+			if !cfg.Enabled || len(cfg.Rules) == 0 {
+				continue
+			}
+
+			// the lock is not released, it expires by ttl so only one master evaluates in an interval
+			mutex := am.ms.Master().NewLock(ctx, entity.ClusterAlertKey, time.Duration(interval)*time.Second)
+			if getLock, err := mutex.TryLock(); !getLock || err != nil {
+				continue
+			}
+			am.evaluate(ctx, cfg)
+		}
+	}()
+}
+
+func (am *alertManager) evaluate(ctx context.Context, cfg *entity.AlertConfig) {
+	var (
+		health      []map[string]interface{}
+		healthErr   error
+		needHealth  bool
+		needDetail  bool
+		needStats   bool
+		diskPercent = make(map[string]float64)
+	)
+	for _, r := range cfg.Rules {
+		switch r.Type {
+		case entity.AlertRuleHealthRed:
+			needHealth = true
+		case entity.AlertRuleRaftLag:
+			needHealth, needDetail = true, true
+		case entity.AlertRuleDiskWatermark:
+			needStats = true
+		}
+	}
+	if needHealth {
+		health, healthErr = am.ms.partitionInfo(ctx, "", "", cast.ToString(needDetail))
+		if healthErr != nil {
+			log.Error("alert query cluster health err: %v", healthErr)
+		}
+	}
+	if needStats {
+		stats, err := am.ms.statsService(ctx)
+		if err != nil {
+			log.Error("alert query server stats err: %v", err)
+		}
+		for _, s := range stats {
+			if s.Fs != nil {
+				diskPercent[s.Ip] = s.Fs.UsedPercent
+			}
+		}
+	}
+
+	results := make([]*alertResult, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		res := &alertResult{rule: r}
+		switch r.Type {
+		case entity.AlertRuleHealthRed:
+			if healthErr != nil {
+				res.skipped = true
+				break
+			}
+			res.breached, res.detail = redHealth(health)
+		case entity.AlertRuleRaftLag:
+			if healthErr != nil {
+				res.skipped = true
+				break
+			}
+			res.breached, res.detail = raftLag(health, uint64(r.Threshold))
+		case entity.AlertRuleDiskWatermark:
+			var msgs []string
+			for ip, percent := range diskPercent {
+				if percent > r.Threshold {
+					msgs = append(msgs, fmt.Sprintf("%s disk used %.2f%%", ip, percent))
+				}
+			}
+			res.breached, res.detail = len(msgs) > 0, strings.Join(msgs, "; ")
+		}
+		results = append(results, res)
+	}
+
+	notices, err := am.transition(ctx, results, time.Now())
+	if err != nil {
+		log.Error("update alert states err: %v", err)
+		return
+	}
+	for _, n := range notices {
+		am.notify(cfg, n.rule, n.msg)
+	}
+}
+
+// transition fires the rules whose condition holds longer than their
+// duration and resolves the ones whose condition is gone. The states are
+// updated in one transaction and the notices returned once it commits, so
+// they are sent once whichever master evaluates
+func (am *alertManager) transition(ctx context.Context, results []*alertResult, now time.Time) ([]*alertNotice, error) {
+	var notices []*alertNotice
+	key := entity.SettingsKey(alertStateSettingsName)
+	err := am.ms.Master().STM(ctx, func(stm store.STM) error {
+		// the transaction may run again, only its last run counts
+		notices = notices[:0]
+		states := make(map[string]*entity.AlertState)
+		if value := stm.Get(key); value != "" {
+			if err := vjson.Unmarshal([]byte(value), &states); err != nil {
+				return err
+			}
+		}
+
+		// states of the rules no longer configured are dropped
+		next := make(map[string]*entity.AlertState, len(results))
+		for _, res := range results {
+			r, state := res.rule, states[res.rule.Name]
+			switch {
+			case res.skipped:
+				if state != nil {
+					next[r.Name] = state
+				}
+			case !res.breached:
+				if state != nil && state.Firing {
+					notices = append(notices, &alertNotice{rule: r, msg: fmt.Sprintf("[RESOLVED] vearch cluster %s alert %s(%s)",
+						config.Conf().Global.Name, r.Name, r.Type)})
+				}
+			default:
+				if state == nil {
+					state = &entity.AlertState{Since: now.Unix()}
+				}
+				since := time.Unix(state.Since, 0)
+				if !state.Firing && now.Sub(since) >= time.Duration(r.Duration)*time.Second {
+					state.Firing = true
+					notices = append(notices, &alertNotice{rule: r, msg: fmt.Sprintf("[FIRING] vearch cluster %s alert %s(%s) since %s: %s",
+						config.Conf().Global.Name, r.Name, r.Type, since.Format(time.RFC3339), res.detail)})
+				}
+				next[r.Name] = state
+			}
+		}
+
+		bs, err := vjson.Marshal(next)
+		if err != nil {
+			return err
+		}
+		stm.Put(key, string(bs))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
+
+func (am *alertManager) notify(cfg *entity.AlertConfig, r *entity.AlertRule, msg string) {
+	log.Warn(msg)
+	for _, c := range cfg.Channels {
+		if len(r.Channels) > 0 {
+			found := false
+			for _, name := range r.Channels {
+				if name == c.Name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		var err error
+		switch c.Type {
+		case entity.AlertChannelSlack:
+			err = sendSlack(c.SlackURL, msg)
+		case entity.AlertChannelSMTP:
+			err = sendMail(c.SMTP, msg)
+		}
+		if err != nil {
+			log.Error("send alert %s to channel %s err: %v", r.Name, c.Name, err)
+		}
+	}
+}
+
+func redHealth(health []map[string]interface{}) (bool, string) {
+	var reds []string
+	for _, db := range health {
+		if cast.ToString(db["status"]) != "red" {
+			continue
+		}
+		spaces, _ := db["spaces"].([]*entity.SpaceInfo)
+		for _, s := range spaces {
+			if s.Status == "red" {
+				reds = append(reds, fmt.Sprintf("%s/%s", s.DbName, s.Name))
+			}
+		}
+		if len(spaces) == 0 {
+			reds = append(reds, cast.ToString(db["db_name"]))
+		}
+	}
+	if len(reds) == 0 {
+		return false, ""
+	}
+	return true, "red spaces: " + strings.Join(reds, ", ")
+}
+
+func raftLag(health []map[string]interface{}, threshold uint64) (bool, string) {
+	var lags []string
+	for _, db := range health {
+		spaces, _ := db["spaces"].([]*entity.SpaceInfo)
+		for _, s := range spaces {
+			for _, p := range s.Partitions {
+				if p.RaftStatus == nil {
+					continue
+				}
+				for nodeID, replica := range p.RaftStatus.Replicas {
+					if p.RaftStatus.Commit > replica.Match && p.RaftStatus.Commit-replica.Match > threshold {
+						lags = append(lags, fmt.Sprintf("%s/%s partition %d node %d lag %d",
+							s.DbName, s.Name, p.PartitionID, nodeID, p.RaftStatus.Commit-replica.Match))
+					}
+				}
+			}
+		}
+	}
+	if len(lags) == 0 {
+		return false, ""
+	}
+	return true, strings.Join(lags, "; ")
+}
+
+func sendSlack(url string, msg string) error {
+	body, err := vjson.Marshal(map[string]string{"text": msg})
+	if err != nil {
+		return err
+	}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func sendMail(cfg *entity.SMTPConfig, msg string) error {
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	subject := msg
+	if i := strings.Index(subject, ":"); i > 0 {
+		subject = subject[:i]
+	}
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", cfg.From, strings.Join(cfg.To, ","), subject, msg)
+	return smtp.SendMail(fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), auth, cfg.From, cfg.To, []byte(body))
+}
+
+// getAlertConfigService return the alerting settings, it is disabled if not set
+func (ms *masterService) getAlertConfigService(ctx context.Context) (*entity.AlertConfig, error) {
+	cfg := &entity.AlertConfig{Interval: entity.DefaultAlertInterval}
+	bs, err := ms.Master().Get(ctx, entity.SettingsKey(alertSettingsName))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return cfg, nil
+	}
+	if err := vjson.Unmarshal(bs, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// updateAlertConfigService replaces the alerting settings, an smtp channel
+// without password keeps the stored one, as the settings read have none
+func (ms *masterService) updateAlertConfigService(ctx context.Context, cfg *entity.AlertConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	key := entity.SettingsKey(alertSettingsName)
+	return ms.Master().STM(ctx, func(stm store.STM) error {
+		if value := stm.Get(key); value != "" {
+			stored := &entity.AlertConfig{}
+			if err := vjson.Unmarshal([]byte(value), stored); err != nil {
+				return err
+			}
+			keepSMTPPasswords(cfg, stored)
+		}
+		marshal, err := vjson.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		stm.Put(key, string(marshal))
+		return nil
+	})
+}
+
+// keepSMTPPasswords sets the empty smtp passwords of cfg to the ones of the
+// stored channels of the same name
+func keepSMTPPasswords(cfg, stored *entity.AlertConfig) {
+	passwords := make(map[string]string)
+	for _, c := range stored.Channels {
+		if c.SMTP != nil && c.SMTP.Password != "" {
+			passwords[c.Name] = c.SMTP.Password
+		}
+	}
+	for _, c := range cfg.Channels {
+		if c.SMTP != nil && c.SMTP.Password == "" {
+			c.SMTP.Password = passwords[c.Name]
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+)
+
+func TestRedHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		health []map[string]interface{}
+		want   bool
+		detail string
+	}{
+		{
+			name:   "Green cluster",
+			health: []map[string]interface{}{{"db_name": "db", "status": "green"}},
+			want:   false,
+		},
+		{
+			name: "Red space",
+			health: []map[string]interface{}{{"db_name": "db", "status": "red", "spaces": []*entity.SpaceInfo{
+				{DbName: "db", Name: "ok", Status: "green"},
+				{DbName: "db", Name: "bad", Status: "red"},
+			}}},
+			want:   true,
+			detail: "red spaces: db/bad",
+		},
+		{
+			name:   "Red db without space detail",
+			health: []map[string]interface{}{{"db_name": "db", "status": "red"}},
+			want:   true,
+			detail: "red spaces: db",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, detail := redHealth(tt.health)
+			if got != tt.want || detail != tt.detail {
+				t.Errorf("redHealth() = %v, %q, want %v, %q", got, detail, tt.want, tt.detail)
+			}
+		})
+	}
+}
+
+func TestRaftLag(t *testing.T) {
+	health := []map[string]interface{}{{"db_name": "db", "spaces": []*entity.SpaceInfo{{DbName: "db", Name: "space", Partitions: []*entity.PartitionInfo{
+		{PartitionID: 1, RaftStatus: &raft.Status{Commit: 100, Replicas: map[uint64]*raft.ReplicaStatus{1: {Match: 100}, 2: {Match: 40}}}},
+		{PartitionID: 2},
+	}}}}}
+	tests := []struct {
+		name      string
+		threshold uint64
+		want      bool
+	}{
+		{name: "Follower behind threshold", threshold: 50, want: true},
+		{name: "Follower within threshold", threshold: 60, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, detail := raftLag(health, tt.threshold)
+			if got != tt.want {
+				t.Errorf("raftLag() = %v, %q, want %v", got, detail, tt.want)
+			}
+			if got && detail != "db/space partition 1 node 2 lag 60" {
+				t.Errorf("raftLag() detail = %q", detail)
+			}
+		})
+	}
+}
+
+func TestAlertTransition(t *testing.T) {
+	if config.Conf() == nil {
+		path := filepath.Join(t.TempDir(), "alert.toml")
+		if err := os.WriteFile(path, []byte("[global]\nname = \"alert\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config.InitConfig(path)
+	}
+	ctx := context.Background()
+	memStore := store.NewMemStore()
+	// two masters sharing the store, either may win the evaluation lock
+	managers := make([]*alertManager, 2)
+	for i := range managers {
+		cli, err := client.NewClientWithStore(nil, memStore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms, err := newMasterService(cli)
+		if err != nil {
+			t.Fatal(err)
+		}
+		managers[i] = newAlertManager(ms)
+	}
+
+	rule := &entity.AlertRule{Name: "red", Type: entity.AlertRuleHealthRed, Duration: 60}
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		master  int
+		after   time.Duration
+		result  *alertResult
+		wantMsg string
+	}{
+		{name: "Breached", master: 0, after: 0, result: &alertResult{rule: rule, breached: true}},
+		{name: "Held on another master", master: 1, after: 30 * time.Second, result: &alertResult{rule: rule, breached: true}},
+		{name: "Fires after duration", master: 1, after: 60 * time.Second, result: &alertResult{rule: rule, breached: true, detail: "db/s"}, wantMsg: "[FIRING]"},
+		{name: "Fired once", master: 0, after: 120 * time.Second, result: &alertResult{rule: rule, breached: true}},
+		{name: "Skipped keeps state", master: 0, after: 150 * time.Second, result: &alertResult{rule: rule, skipped: true}},
+		{name: "Resolves on another master", master: 1, after: 180 * time.Second, result: &alertResult{rule: rule}, wantMsg: "[RESOLVED]"},
+		{name: "Resolved once", master: 0, after: 240 * time.Second, result: &alertResult{rule: rule}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notices, err := managers[tt.master].transition(ctx, []*alertResult{tt.result}, start.Add(tt.after))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantMsg == "" {
+				if len(notices) != 0 {
+					t.Fatalf("want no notice, got %q", notices[0].msg)
+				}
+				return
+			}
+			if len(notices) != 1 || !strings.HasPrefix(notices[0].msg, tt.wantMsg) {
+				t.Fatalf("want a %s notice, got %d notices", tt.wantMsg, len(notices))
+			}
+		})
+	}
+}
+
+func TestKeepSMTPPasswords(t *testing.T) {
+	stored := &entity.AlertConfig{Channels: []*entity.AlertChannel{
+		{Name: "mail", Type: entity.AlertChannelSMTP, SMTP: &entity.SMTPConfig{Host: "smtp", Password: "secret"}},
+		{Name: "slack", Type: entity.AlertChannelSlack, SlackURL: "http://slack"},
+	}}
+	tests := []struct {
+		name    string
+		channel *entity.AlertChannel
+		want    string
+	}{
+		{name: "Empty password kept", channel: &entity.AlertChannel{Name: "mail", SMTP: &entity.SMTPConfig{Host: "smtp"}}, want: "secret"},
+		{name: "New password set", channel: &entity.AlertChannel{Name: "mail", SMTP: &entity.SMTPConfig{Host: "smtp", Password: "new"}}, want: "new"},
+		{name: "Unknown channel", channel: &entity.AlertChannel{Name: "other", SMTP: &entity.SMTPConfig{Host: "smtp"}}, want: ""},
+		{name: "Renamed from slack", channel: &entity.AlertChannel{Name: "slack", SMTP: &entity.SMTPConfig{Host: "smtp"}}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &entity.AlertConfig{Channels: []*entity.AlertChannel{tt.channel}}
+			keepSMTPPasswords(cfg, stored)
+			if got := tt.channel.SMTP.Password; got != tt.want {
+				t.Errorf("password = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
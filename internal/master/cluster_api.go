@@ -16,6 +16,7 @@ package master
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -33,9 +34,11 @@ import (
 	"github.com/vearch/vearch/v3/internal/entity/errors"
 	"github.com/vearch/vearch/v3/internal/entity/response"
 	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/diagnose"
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/netutil"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 )
 
@@ -46,10 +49,17 @@ const (
 	aliasName           = "alias_name"
 	userName            = "user_name"
 	roleName            = "role_name"
+	webhookName         = "webhook_name"
+	resourceGroupName   = "resource_group_name"
+	spaceTemplateName   = "space_template_name"
+	featureFlagName     = "feature_flag_name"
+	globalAliasName     = "global_alias_name"
+	metaKind            = "meta_kind"
 	memberId            = "member_id"
 	peerAddrs           = "peer_addrs"
 	headerAuthKey       = "Authorization"
 	NodeID              = "node_id"
+	partitionID         = "partition_id"
 	DefaultResourceName = "default"
 )
 
@@ -64,68 +74,92 @@ func BasicAuthMiddleware(masterService *masterService) gin.HandlerFunc {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			err := fmt.Errorf("auth header is empty")
-			response.New(c).JsonError(errors.NewErrUnauthorized(err))
-			c.Abort()
+			unauthorized(c, err)
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Basic" {
 			err := fmt.Errorf("auth header type is invalid")
-			response.New(c).JsonError(errors.NewErrUnauthorized(err))
-			c.Abort()
+			unauthorized(c, err)
 			return
 		}
 
 		decoded, err := base64.StdEncoding.DecodeString(parts[1])
 		if err != nil {
-			response.New(c).JsonError(errors.NewErrUnauthorized(err))
-			c.Abort()
+			unauthorized(c, err)
 			return
 		}
 
 		credentials := strings.SplitN(string(decoded), ":", 2)
 		if len(credentials) != 2 {
 			err := fmt.Errorf("auth header credentials is invalid")
-			response.New(c).JsonError(errors.NewErrUnauthorized(err))
-			c.Abort()
+			unauthorized(c, err)
 			return
 		}
 
 		user, err := masterService.queryUserWithPasswordService(c, credentials[0], true)
 		if err != nil {
 			ferr := fmt.Errorf("auth header user %s is invalid", credentials[0])
-			response.New(c).JsonError(errors.NewErrUnauthorized(ferr))
-			c.Abort()
+			unauthorized(c, ferr)
 			return
 		}
 		if *user.Password != credentials[1] {
 			err := fmt.Errorf("auth header password is invalid")
-			response.New(c).JsonError(errors.NewErrUnauthorized(err))
-			c.Abort()
+			unauthorized(c, err)
 			return
 		}
 
 		role, err := masterService.queryRoleService(c, user.Role.Name)
 		if err != nil {
-			response.New(c).JsonError(errors.NewErrUnauthorized(err))
-			c.Abort()
+			unauthorized(c, err)
 			return
 		}
 		endpoint := c.FullPath()
 		method := c.Request.Method
 		if err := role.HasPermissionForResources(endpoint, method); err != nil {
+			unauthorized(c, err)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// unauthorized replies 401, with the basic challenge for the pages of the web
+// ui only: browsers prompt for the credentials there and send them again to
+// the api of the master, while api clients get the error alone
+func unauthorized(c *gin.Context, err error) {
+	if strings.HasPrefix(c.FullPath(), "/ui/") {
+		c.Header("WWW-Authenticate", `Basic realm="vearch"`)
+	}
+	response.New(c).JsonError(errors.NewErrUnauthorized(err))
+	c.Abort()
+}
+
+// SignkeyAuthMiddleware permits only the root user with the signkey of the
+// cluster, the credentials of the internal requests of ps and routers, even
+// if auth of users is skipped
+func SignkeyAuthMiddleware(signkey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, password, ok := c.Request.BasicAuth()
+		if !ok || user != entity.RootName || subtle.ConstantTimeCompare([]byte(password), []byte(signkey)) != 1 {
+			err := fmt.Errorf("internal api should be called with the signkey of root")
 			response.New(c).JsonError(errors.NewErrUnauthorized(err))
 			c.Abort()
 			return
 		}
-
 		c.Next()
 	}
 }
 
 func TimeoutMiddleware(defaultTimeout time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// event streams last as long as their clients
+		if c.FullPath() == jobEventsPath {
+			c.Next()
+			return
+		}
 		timeoutStr := c.Query("timeout")
 		var timeout time.Duration
 		if timeoutStr != "" {
@@ -185,6 +219,19 @@ func RecoveryMiddleware() gin.HandlerFunc {
 	}
 }
 
+// CacheControlMiddleware hints http caches to reuse the reply for maxAge
+// seconds, or to revalidate it every time if maxAge is 0
+func CacheControlMiddleware(maxAge int) gin.HandlerFunc {
+	value := "private, no-cache"
+	if maxAge > 0 {
+		value = fmt.Sprintf("private, max-age=%d", maxAge)
+	}
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", value)
+		c.Next()
+	}
+}
+
 func ExportToClusterHandler(router *gin.Engine, masterService *masterService, server *Server) {
 	c := &clusterAPI{router: router, masterService: masterService, server: server}
 	router.Use(RecoveryMiddleware())
@@ -198,6 +245,9 @@ func ExportToClusterHandler(router *gin.Engine, masterService *masterService, se
 		groupAuth = router.Group("")
 	}
 
+	// metadata reads are hinted cacheable by clients
+	metaCache := CacheControlMiddleware(config.Conf().Global.MetaCacheMaxAge)
+
 	group.GET("/", c.handleClusterInfo)
 
 	// cluster handler
@@ -208,37 +258,63 @@ func ExportToClusterHandler(router *gin.Engine, masterService *masterService, se
 
 	// router  handler
 	groupAuth.GET("/routers", c.routerList)
+	groupAuth.GET("/discovery/routers", c.discoverRouters)
 
 	// partition register, use internal so no need to auth
 	group.POST("/register", c.register)
 	group.POST("/register_partition", c.registerPartition)
 	group.POST("/register_router", c.registerRouter)
 
+	// internal handler of ps, only with the signkey
+	groupSignkey := router.Group("", SignkeyAuthMiddleware(config.Conf().Global.Signkey))
+	groupSignkey.POST("/partitions/fencing_token", c.fencingToken)
+
 	// db handler
 	groupAuth.POST(fmt.Sprintf("/dbs/:%s", dbName), c.createDB)
-	groupAuth.GET(fmt.Sprintf("/dbs/:%s", dbName), c.getDB)
-	groupAuth.GET("/dbs", c.getDB)
+	groupAuth.GET(fmt.Sprintf("/dbs/:%s", dbName), metaCache, c.getDB)
+	groupAuth.GET("/dbs", metaCache, c.getDB)
 	groupAuth.DELETE(fmt.Sprintf("/dbs/:%s", dbName), c.deleteDB)
 	groupAuth.PUT(fmt.Sprintf("/dbs/:%s", dbName), c.modifyDB)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/settings", dbName), c.updateDBSettings)
 
 	// space handler
 	groupAuth.POST(fmt.Sprintf("/dbs/:%s/spaces", dbName), c.createSpace)
-	groupAuth.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), c.getSpace)
-	groupAuth.GET(fmt.Sprintf("/dbs/:%s/spaces", dbName), c.getSpace)
+	groupAuth.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), metaCache, c.getSpace)
+	groupAuth.GET(fmt.Sprintf("/dbs/:%s/spaces", dbName), metaCache, c.getSpace)
 	groupAuth.DELETE(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), c.deleteSpace)
 	// group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), c.updateSpace)
 	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), c.updateSpaceResource)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/search_params", dbName, spaceName), c.updateSpaceSearchParams)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/field_aliases", dbName, spaceName), c.updateSpaceFieldAliases)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/pipeline", dbName, spaceName), c.updateSpacePipeline)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/metrics", dbName, spaceName), c.updateSpaceMetrics)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/udfs", dbName, spaceName), c.updateSpaceUDFs)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/ingest_pipeline", dbName, spaceName), c.updateSpaceIngestPipeline)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/dead_letter", dbName, spaceName), c.updateSpaceDeadLetter)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/leadership", dbName, spaceName), c.updateSpaceLeadership)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/annotations", dbName, spaceName), c.updateSpaceAnnotations)
+	groupAuth.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/write_guard", dbName, spaceName), c.updateSpaceWriteGuard)
+	groupAuth.POST(fmt.Sprintf("/dbs/:%s/spaces/:%s/writes/pause", dbName, spaceName), c.pauseSpaceWrites)
+	groupAuth.POST(fmt.Sprintf("/dbs/:%s/spaces/:%s/writes/resume", dbName, spaceName), c.resumeSpaceWrites)
 	groupAuth.POST(fmt.Sprintf("/backup/dbs/:%s/spaces/:%s", dbName, spaceName), c.backupSpace)
 	groupAuth.POST(fmt.Sprintf("/backup/dbs/:%s", dbName), c.backupDb)
+	groupAuth.POST(fmt.Sprintf("/dbs/:%s/spaces/:%s/index/import", dbName, spaceName), c.importIndex)
+	groupAuth.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s/index/import", dbName, spaceName), c.importIndexStatus)
+	groupAuth.POST(fmt.Sprintf("/dbs/:%s/spaces/:%s/copy", dbName, spaceName), c.copySpace)
+	groupAuth.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s/copy", dbName, spaceName), c.copySpaceStatus)
+	groupAuth.POST(fmt.Sprintf("/dbs/:%s/spaces/:%s/copy_source", dbName, spaceName), c.copySource)
 
 	// modify engine config handler
 	groupAuth.POST("/config/:"+dbName+"/:"+spaceName, c.modifyEngineCfg)
-	groupAuth.GET("/config/:"+dbName+"/:"+spaceName, c.getEngineCfg)
+	groupAuth.GET("/config/:"+dbName+"/:"+spaceName, metaCache, c.getEngineCfg)
 
 	// partition handler
 	groupAuth.GET("/partitions", c.partitionList)
+	groupAuth.GET("/partitions/:"+partitionID+"/_stats", c.partitionStats)
 	groupAuth.POST("/partitions/change_member", c.changeMember)
 	groupAuth.POST("/partitions/resource_limit", c.ResourceLimit)
+	groupAuth.GET("/partitions/quarantined", c.quarantinedPartitions)
+	groupAuth.POST("/partitions/rebuild", c.rebuildPartition)
 
 	// schedule
 	groupAuth.POST("/schedule/recover_server", c.RecoverFailServer)
@@ -252,28 +328,114 @@ func ExportToClusterHandler(router *gin.Engine, masterService *masterService, se
 
 	// alias handler
 	groupAuth.POST(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", aliasName, dbName, spaceName), c.createAlias)
-	groupAuth.GET(fmt.Sprintf("/alias/:%s", aliasName), c.getAlias)
-	groupAuth.GET("/alias", c.getAlias)
+	groupAuth.GET(fmt.Sprintf("/alias/:%s", aliasName), metaCache, c.getAlias)
+	groupAuth.GET("/alias", metaCache, c.getAlias)
 	groupAuth.DELETE(fmt.Sprintf("/alias/:%s", aliasName), c.deleteAlias)
 	groupAuth.PUT(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", aliasName, dbName, spaceName), c.modifyAlias)
 
+	// webhook handler
+	groupAuth.POST("/webhooks", c.createWebhook)
+	groupAuth.GET(fmt.Sprintf("/webhooks/:%s", webhookName), metaCache, c.getWebhook)
+	groupAuth.GET("/webhooks", metaCache, c.getWebhook)
+	groupAuth.DELETE(fmt.Sprintf("/webhooks/:%s", webhookName), c.deleteWebhook)
+
+	// resource group handler
+	groupAuth.POST("/resource_groups", c.createResourceGroup)
+	groupAuth.PUT(fmt.Sprintf("/resource_groups/:%s", resourceGroupName), c.updateResourceGroup)
+	groupAuth.GET(fmt.Sprintf("/resource_groups/:%s", resourceGroupName), metaCache, c.getResourceGroup)
+	groupAuth.GET("/resource_groups", metaCache, c.getResourceGroup)
+	groupAuth.DELETE(fmt.Sprintf("/resource_groups/:%s", resourceGroupName), c.deleteResourceGroup)
+
+	// space template handler
+	groupAuth.POST("/space_templates", c.createSpaceTemplate)
+	groupAuth.PUT(fmt.Sprintf("/space_templates/:%s", spaceTemplateName), c.updateSpaceTemplate)
+	groupAuth.GET(fmt.Sprintf("/space_templates/:%s", spaceTemplateName), metaCache, c.getSpaceTemplate)
+	groupAuth.GET("/space_templates", metaCache, c.getSpaceTemplate)
+	groupAuth.DELETE(fmt.Sprintf("/space_templates/:%s", spaceTemplateName), c.deleteSpaceTemplate)
+
+	// feature flag handler, watched by routers
+	groupAuth.PUT(fmt.Sprintf("/feature_flags/:%s", featureFlagName), c.putFeatureFlag)
+	groupAuth.GET(fmt.Sprintf("/feature_flags/:%s", featureFlagName), metaCache, c.getFeatureFlag)
+	groupAuth.GET("/feature_flags", metaCache, c.getFeatureFlag)
+	groupAuth.DELETE(fmt.Sprintf("/feature_flags/:%s", featureFlagName), c.deleteFeatureFlag)
+
+	// global alias handler, watched by routers
+	groupAuth.PUT(fmt.Sprintf("/global_aliases/:%s", globalAliasName), c.putGlobalAlias)
+	groupAuth.GET(fmt.Sprintf("/global_aliases/:%s", globalAliasName), metaCache, c.getGlobalAlias)
+	groupAuth.GET("/global_aliases", metaCache, c.getGlobalAlias)
+	groupAuth.DELETE(fmt.Sprintf("/global_aliases/:%s", globalAliasName), c.deleteGlobalAlias)
+
+	// metadata schema migration
+	groupAuth.GET(fmt.Sprintf("/meta_schema/:%s", metaKind), c.getMetaSchema)
+	groupAuth.POST(fmt.Sprintf("/meta_schema/:%s/dual", metaKind), c.dualMetaSchema)
+	groupAuth.POST(fmt.Sprintf("/meta_schema/:%s/finalize", metaKind), c.finalizeMetaSchema)
+	groupAuth.POST(fmt.Sprintf("/meta_schema/:%s/abort", metaKind), c.abortMetaSchema)
+
 	// user handler
 	groupAuth.POST("/users", c.createUser)
-	groupAuth.GET(fmt.Sprintf("/users/:%s", userName), c.getUser)
-	groupAuth.GET("/users", c.getUser)
+	groupAuth.GET(fmt.Sprintf("/users/:%s", userName), metaCache, c.getUser)
+	groupAuth.GET("/users", metaCache, c.getUser)
 	groupAuth.DELETE(fmt.Sprintf("/users/:%s", userName), c.deleteUser)
 	groupAuth.PUT("/users", c.updateUser)
 
 	// role handler
 	groupAuth.POST("/roles", c.createRole)
-	groupAuth.GET(fmt.Sprintf("/roles/:%s", roleName), c.getRole)
-	groupAuth.GET("/roles", c.getRole)
+	groupAuth.GET(fmt.Sprintf("/roles/:%s", roleName), metaCache, c.getRole)
+	groupAuth.GET("/roles", metaCache, c.getRole)
 	groupAuth.DELETE(fmt.Sprintf("/roles/:%s", roleName), c.deleteRole)
 	groupAuth.PUT("/roles", c.changeRolePrivilege)
 
 	// cluster handler
 	groupAuth.GET("/cluster/stats", c.stats)
 	groupAuth.GET("/cluster/health", c.health)
+	groupAuth.GET("/cluster/alert", c.getAlertConfig)
+	groupAuth.PUT("/cluster/alert", c.updateAlertConfig)
+	groupAuth.GET("/cluster/usage", c.usageReport)
+	groupAuth.GET("/cluster/leader_balance", c.getLeaderBalanceConfig)
+	groupAuth.PUT("/cluster/leader_balance", c.updateLeaderBalanceConfig)
+	groupAuth.GET("/cluster/etcd_maintenance", c.getEtcdMaintenanceConfig)
+	groupAuth.PUT("/cluster/etcd_maintenance", c.updateEtcdMaintenanceConfig)
+	groupAuth.GET("/cluster/metadata/export", c.exportMetadata)
+	groupAuth.POST("/cluster/metadata/import", c.importMetadata)
+	groupAuth.GET("/cluster/orphan_partitions", c.orphanPartitions)
+	groupAuth.POST("/cluster/orphan_partitions/resolve", c.resolveOrphanPartition)
+	groupAuth.POST("/cluster/consistency_check", c.checkConsistency)
+
+	// embedding migration handler
+	groupAuth.POST("/cluster/embedding_migrations", c.createEmbeddingMigration)
+	groupAuth.GET("/cluster/embedding_migrations", c.listEmbeddingMigrations)
+	groupAuth.GET("/cluster/embedding_migrations/:name", c.getEmbeddingMigration)
+	groupAuth.POST("/cluster/embedding_migrations/:name/cancel", c.cancelEmbeddingMigration)
+	groupAuth.POST("/cluster/embedding_migrations/:name/complete", c.completeEmbeddingMigration)
+
+	// cron job handler
+	groupAuth.POST("/cluster/cron_jobs", c.createCronJob)
+	groupAuth.GET("/cluster/cron_jobs", c.listCronJobs)
+	groupAuth.GET("/cluster/cron_jobs/:name", c.getCronJob)
+	groupAuth.PUT("/cluster/cron_jobs/:name", c.updateCronJob)
+	groupAuth.DELETE("/cluster/cron_jobs/:name", c.deleteCronJob)
+	groupAuth.GET("/cluster/cron_jobs/:name/runs", c.listCronRuns)
+	groupAuth.POST("/cluster/cron_jobs/:name/run", c.triggerCronJob)
+	groupAuth.POST("/cluster/cron_jobs/:name/skip", c.skipCronJob)
+
+	// similarity join handler
+	groupAuth.POST("/cluster/similarity_joins", c.createSimilarityJoin)
+	groupAuth.GET("/cluster/similarity_joins", c.listSimilarityJoins)
+	groupAuth.GET("/cluster/similarity_joins/:name", c.getSimilarityJoin)
+	groupAuth.POST("/cluster/similarity_joins/:name/cancel", c.cancelSimilarityJoin)
+
+	// job events, the id of a job is kind:name like similarity_join:dedup
+	groupAuth.GET(jobEventsPath, c.jobEvents)
+
+	// web ui, /ui maps to ResourceAll so only admin can sign in
+	if self := config.Conf().Masters.Self(); self != nil && self.WebUI {
+		groupAuth.GET("/ui/*path", c.webUI)
+		groupAuth.POST("/ui/*path", c.webUIConsole)
+	}
+
+	// runtime diagnostics, /debug maps to ResourceAll so only admin can access,
+	// pass timeout param for long cpu profile
+	groupAuth.Any("/debug/*path", gin.WrapH(diagnose.NewHandler(config.Conf().GetLogDir())))
 
 	// members handler
 	groupAuth.GET("/members", c.getMembers)
@@ -307,6 +469,372 @@ func (ca *clusterAPI) health(c *gin.Context) {
 	response.New(c).JsonSuccess(result)
 }
 
+// getAlertConfig never returns the smtp password
+func (ca *clusterAPI) getAlertConfig(c *gin.Context) {
+	cfg, err := ca.masterService.getAlertConfigService(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	for _, channel := range cfg.Channels {
+		if channel.SMTP != nil {
+			channel.SMTP.Password = ""
+		}
+	}
+	response.New(c).JsonSuccess(cfg)
+}
+
+func (ca *clusterAPI) updateAlertConfig(c *gin.Context) {
+	cfg := &entity.AlertConfig{}
+	if err := c.ShouldBindJSON(cfg); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := ca.masterService.updateAlertConfigService(c, cfg); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(nil)
+}
+
+// usageReport reports the daily usage of users on spaces, dates are in utc
+func (ca *clusterAPI) usageReport(c *gin.Context) {
+	report, err := ca.masterService.usageReportService(c, c.Query("start"), c.Query("end"), c.Query("user"), c.Query("db"), c.Query("space"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(report)
+}
+
+func (ca *clusterAPI) getLeaderBalanceConfig(c *gin.Context) {
+	cfg, err := ca.masterService.getLeaderBalanceConfigService(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(cfg)
+}
+
+func (ca *clusterAPI) updateLeaderBalanceConfig(c *gin.Context) {
+	cfg := &entity.LeaderBalanceConfig{}
+	if err := c.ShouldBindJSON(cfg); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := ca.masterService.updateLeaderBalanceConfigService(c, cfg); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(nil)
+}
+
+func (ca *clusterAPI) getEtcdMaintenanceConfig(c *gin.Context) {
+	cfg, err := ca.masterService.getEtcdMaintenanceConfigService(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(cfg)
+}
+
+func (ca *clusterAPI) updateEtcdMaintenanceConfig(c *gin.Context) {
+	cfg := &entity.EtcdMaintenanceConfig{}
+	if err := c.ShouldBindJSON(cfg); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := ca.masterService.updateEtcdMaintenanceConfigService(c, cfg); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(nil)
+}
+
+func (ca *clusterAPI) exportMetadata(c *gin.Context) {
+	bundle, err := ca.masterService.exportMetadataService(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(bundle)
+}
+
+func (ca *clusterAPI) importMetadata(c *gin.Context) {
+	bundle := &entity.MetadataBundle{}
+	if err := c.ShouldBindJSON(bundle); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	result, err := ca.masterService.importMetadataService(c, bundle)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(result)
+}
+
+func (ca *clusterAPI) orphanPartitions(c *gin.Context) {
+	report, err := ca.masterService.orphanPartitionsService(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(report)
+}
+
+func (ca *clusterAPI) quarantinedPartitions(c *gin.Context) {
+	quarantines, err := ca.masterService.quarantinedPartitionsService(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(quarantines)
+}
+
+func (ca *clusterAPI) rebuildPartition(c *gin.Context) {
+	rebuild := &entity.PartitionRebuild{}
+	if err := c.ShouldBindJSON(rebuild); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := ca.masterService.rebuildPartitionService(c, rebuild); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(rebuild)
+}
+
+func (ca *clusterAPI) resolveOrphanPartition(c *gin.Context) {
+	resolve := &entity.OrphanResolve{}
+	if err := c.ShouldBindJSON(resolve); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	plan, err := ca.masterService.resolveOrphanPartitionService(c, resolve)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(map[string]interface{}{"plan": plan, "done": resolve.Confirm})
+}
+
+func (ca *clusterAPI) checkConsistency(c *gin.Context) {
+	req := &entity.ConsistencyCheckRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	results, err := ca.masterService.checkConsistencyService(c, req)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(results)
+}
+
+func (ca *clusterAPI) createEmbeddingMigration(c *gin.Context) {
+	m := &entity.EmbeddingMigration{}
+	if err := c.ShouldBindJSON(m); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if m, err := ca.masterService.createEmbeddingMigrationService(c, m); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		response.New(c).JsonSuccess(m)
+	}
+}
+
+func (ca *clusterAPI) listEmbeddingMigrations(c *gin.Context) {
+	if migrations, err := ca.masterService.listEmbeddingMigrationsService(c); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+	} else {
+		response.New(c).JsonSuccess(migrations)
+	}
+}
+
+func (ca *clusterAPI) getEmbeddingMigration(c *gin.Context) {
+	if m, err := ca.masterService.getEmbeddingMigrationService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		response.New(c).JsonSuccess(m)
+	}
+}
+
+func (ca *clusterAPI) cancelEmbeddingMigration(c *gin.Context) {
+	if m, err := ca.masterService.cancelEmbeddingMigrationService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		response.New(c).JsonSuccess(m)
+	}
+}
+
+// completeEmbeddingMigration is called by the router running the migration
+// when all documents are embedded
+func (ca *clusterAPI) completeEmbeddingMigration(c *gin.Context) {
+	if m, err := ca.masterService.completeEmbeddingMigrationService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		response.New(c).JsonSuccess(m)
+	}
+}
+
+func (ca *clusterAPI) createCronJob(c *gin.Context) {
+	job := &entity.CronJob{}
+	if err := c.ShouldBindJSON(job); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := ca.masterService.putCronJobService(c, job, false); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		response.New(c).JsonSuccess(job)
+	}
+}
+
+func (ca *clusterAPI) updateCronJob(c *gin.Context) {
+	job := &entity.CronJob{}
+	if err := c.ShouldBindJSON(job); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	job.Name = c.Param("name")
+	if err := ca.masterService.putCronJobService(c, job, true); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		response.New(c).JsonSuccess(job)
+	}
+}
+
+func (ca *clusterAPI) listCronJobs(c *gin.Context) {
+	if jobs, err := ca.masterService.listCronJobsService(c); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+	} else {
+		response.New(c).JsonSuccess(jobs)
+	}
+}
+
+func (ca *clusterAPI) getCronJob(c *gin.Context) {
+	if job, err := ca.masterService.getCronJobService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		response.New(c).JsonSuccess(job)
+	}
+}
+
+func (ca *clusterAPI) deleteCronJob(c *gin.Context) {
+	if err := ca.masterService.deleteCronJobService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		response.New(c).SuccessDelete()
+	}
+}
+
+func (ca *clusterAPI) listCronRuns(c *gin.Context) {
+	if runs, err := ca.masterService.listCronRunsService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		response.New(c).JsonSuccess(runs)
+	}
+}
+
+// triggerCronJob starts a run of the job at once, the run is returned
+// running or skipped if the former run is not finished
+func (ca *clusterAPI) triggerCronJob(c *gin.Context) {
+	if run, err := ca.masterService.triggerCronJobService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		response.New(c).JsonSuccess(run)
+	}
+}
+
+func (ca *clusterAPI) skipCronJob(c *gin.Context) {
+	if job, err := ca.masterService.skipCronJobService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		response.New(c).JsonSuccess(job)
+	}
+}
+
+func (ca *clusterAPI) createSimilarityJoin(c *gin.Context) {
+	j := &entity.SimilarityJoin{}
+	if err := c.ShouldBindJSON(j); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if j, err := ca.masterService.createSimilarityJoinService(c, j); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		response.New(c).JsonSuccess(j)
+	}
+}
+
+func (ca *clusterAPI) listSimilarityJoins(c *gin.Context) {
+	if joins, err := ca.masterService.listSimilarityJoinsService(c); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+	} else {
+		response.New(c).JsonSuccess(joins)
+	}
+}
+
+func (ca *clusterAPI) getSimilarityJoin(c *gin.Context) {
+	if j, err := ca.masterService.getSimilarityJoinService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		response.New(c).JsonSuccess(j)
+	}
+}
+
+func (ca *clusterAPI) cancelSimilarityJoin(c *gin.Context) {
+	if j, err := ca.masterService.cancelSimilarityJoinService(c, c.Param("name")); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		response.New(c).JsonSuccess(j)
+	}
+}
+
+// jobEvents streams the state of a job as server-sent events, a state event
+// on every status change and progress events while it runs, until the job
+// finishes, is deleted or the client goes away
+func (ca *clusterAPI) jobEvents(c *gin.Context) {
+	kind, name, err := entity.ParseJobID(c.Param("id"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	updates, err := ca.masterService.watchJobService(ctx, kind, name)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	heartbeat := time.NewTicker(jobEventsHeartbeat)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				return false
+			}
+			c.SSEvent(u.name, u.event)
+			return true
+		case <-heartbeat.C:
+			// a comment keeps proxies from closing an idle stream
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
 func (ca *clusterAPI) handleClusterInfo(c *gin.Context) {
 	layer := map[string]interface{}{
 		"name": config.Conf().Global.Name,
@@ -394,8 +922,6 @@ func (ca *clusterAPI) registerPartition(c *gin.Context) {
 		return
 	}
 
-	partition.UpdateTime = time.Now().UnixNano()
-
 	if err := ca.masterService.registerPartitionService(c, partition); err != nil {
 		response.New(c).JsonError(errors.NewErrInternal(err))
 	} else {
@@ -403,11 +929,33 @@ func (ca *clusterAPI) registerPartition(c *gin.Context) {
 	}
 }
 
+func (ca *clusterAPI) fencingToken(c *gin.Context) {
+	req := &entity.FencingRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	tokens, err := ca.masterService.fencingTokenService(c, req)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(tokens)
+}
+
 func (ca *clusterAPI) createDB(c *gin.Context) {
 	startTime := time.Now()
 	defer monitor.Profiler("createDB", startTime)
 	dbName := c.Param(dbName)
-	db := &entity.DB{Name: dbName}
+	db := &entity.DB{}
+	// the body is optional, it may set the defaults and quota of db
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(db); err != nil {
+			response.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+	}
+	db.Name = dbName
 
 	log.Debug("create db: %s", db.Name)
 
@@ -459,6 +1007,20 @@ func (ca *clusterAPI) modifyDB(c *gin.Context) {
 	}
 }
 
+// updateDBSettings changes the defaults and quota of db
+func (ca *clusterAPI) updateDBSettings(c *gin.Context) {
+	settings := &entity.DBSettings{}
+	if err := c.ShouldBindJSON(settings); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if db, err := ca.masterService.updateDBSettingsService(c, c.Param(dbName), settings); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+	} else {
+		response.New(c).JsonSuccess(db)
+	}
+}
+
 func (ca *clusterAPI) createSpace(c *gin.Context) {
 	log.Debug("create space, db: %s", c.Param(dbName))
 
@@ -467,10 +1029,36 @@ func (ca *clusterAPI) createSpace(c *gin.Context) {
 	space := &entity.Space{}
 	if err := c.ShouldBindJSON(space); err != nil {
 		body, _ := netutil.GetReqBody(c.Request)
-		log.Error("create space request: %s, err: %s", body, err.Error())
+		log.Error("create space request: %s, err: %s", redact.Payload(body), err.Error())
 		response.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
+	if name := c.Query(entity.SpaceTemplateParam); name != "" {
+		template, err := ca.masterService.querySpaceTemplateService(c, name)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		if err := template.Apply(space); err != nil {
+			response.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+	}
+
+	db, err := ca.masterService.queryDBService(c, dbName)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+		return
+	}
+	// the defaults of db come before the ones of cluster
+	if defaults := db.Defaults; defaults != nil {
+		if space.ResourceName == "" {
+			space.ResourceName = defaults.ResourceName
+		}
+		if space.ReplicaNum <= 0 {
+			space.ReplicaNum = defaults.ReplicaNum
+		}
+	}
 
 	// set default resource name
 	if space.ResourceName == "" {
@@ -511,6 +1099,9 @@ func (ca *clusterAPI) createSpace(c *gin.Context) {
 		response.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if db.Defaults != nil && db.Defaults.EngineConfig != nil {
+		cfg = cfg.Merge(db.Defaults.EngineConfig)
+	}
 
 	err = ca.masterService.updateEngineConfig(c, space, cfg)
 	if err != nil {
@@ -554,17 +1145,33 @@ func (ca *clusterAPI) getSpace(c *gin.Context) {
 			spaceInfo := &entity.SpaceInfo{}
 			spaceInfo.DbName = dbName
 			spaceInfo.SpaceName = spaceName
+			spaceInfo.Version = space.Version
 			spaceInfo.Schema = &entity.SpaceSchema{
 				Fields: space.Fields,
 			}
 			spaceInfo.PartitionNum = space.PartitionNum
 			spaceInfo.ReplicaNum = space.ReplicaNum
 			spaceInfo.PartitionRule = space.PartitionRule
+			spaceInfo.SearchParams = space.DefaultSearchParams
+			spaceInfo.ResourceGroup = space.ResourceGroup
+			spaceInfo.FieldAliases = space.FieldAliases
+			spaceInfo.DefaultVectorField = space.DefaultVectorField
+			spaceInfo.Pipeline = space.Pipeline
+			spaceInfo.Metrics = space.Metrics
+			spaceInfo.UDFs = space.UDFs
+			spaceInfo.IngestPipeline = space.IngestPipeline
+			spaceInfo.DeadLetter = space.DeadLetter
+			spaceInfo.Leadership = space.Leadership
+			spaceInfo.WritePause = space.WritePause
+			spaceInfo.Annotations = space.Annotations
+			spaceInfo.WriteGuard = space.WriteGuard
+			spaceInfo.Template = space.Template
 			if _, err := ca.masterService.describeSpaceService(c, space, spaceInfo, detail_info); err != nil {
 				response.New(c).JsonError(errors.NewErrInternal(err))
 				return
 			} else {
-				response.New(c).JsonSuccess(spaceInfo)
+				c.Header(entity.SpaceVersionHeader, strconv.FormatUint(space.Version, 10))
+				response.New(c).JsonSuccessETag(spaceInfo, entity.SpaceETag(space.Version))
 			}
 		}
 	} else {
@@ -576,12 +1183,27 @@ func (ca *clusterAPI) getSpace(c *gin.Context) {
 				var spaceInfo = &entity.SpaceInfo{}
 				spaceInfo.DbName = dbName
 				spaceInfo.SpaceName = space.Name
+				spaceInfo.Version = space.Version
 				spaceInfo.Schema = &entity.SpaceSchema{
 					Fields: space.Fields,
 				}
 				spaceInfo.PartitionNum = space.PartitionNum
 				spaceInfo.ReplicaNum = space.ReplicaNum
 				spaceInfo.PartitionRule = space.PartitionRule
+				spaceInfo.SearchParams = space.DefaultSearchParams
+				spaceInfo.ResourceGroup = space.ResourceGroup
+				spaceInfo.FieldAliases = space.FieldAliases
+				spaceInfo.DefaultVectorField = space.DefaultVectorField
+				spaceInfo.Pipeline = space.Pipeline
+				spaceInfo.Metrics = space.Metrics
+				spaceInfo.UDFs = space.UDFs
+				spaceInfo.IngestPipeline = space.IngestPipeline
+				spaceInfo.DeadLetter = space.DeadLetter
+				spaceInfo.Leadership = space.Leadership
+				spaceInfo.WritePause = space.WritePause
+				spaceInfo.Annotations = space.Annotations
+				spaceInfo.WriteGuard = space.WriteGuard
+				spaceInfo.Template = space.Template
 				if _, err := ca.masterService.describeSpaceService(c, space, spaceInfo, detail_info); err != nil {
 					response.New(c).JsonError(errors.NewErrInternal(err))
 					return
@@ -605,10 +1227,16 @@ func (ca *clusterAPI) updateSpace(c *gin.Context) {
 		return
 	}
 
-	if spaceResult, err := ca.masterService.updateSpaceService(c, dbName, spaceName, space); err != nil {
-		response.New(c).JsonError(errors.NewErrInternal(err))
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if spaceResult, err := ca.masterService.updateSpaceService(c, dbName, spaceName, space, version); err != nil {
+		spaceUpdateError(c, err)
 	} else {
-		response.New(c).JsonSuccess(spaceResult)
+		spaceUpdateSuccess(c, spaceResult)
 	}
 }
 
@@ -621,18 +1249,334 @@ func (ca *clusterAPI) updateSpaceResource(c *gin.Context) {
 		DbName:    dbName,
 	}
 
-	if err := c.ShouldBindJSON(space); err != nil {
-		response.New(c).JsonError(errors.NewErrBadRequest(err))
+	if err := c.ShouldBindJSON(space); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	log.Debug("updateSpaceResource %v", space)
+
+	if spaceResult, err := ca.masterService.updateSpaceResourceService(c, space, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, spaceResult)
+	}
+}
+
+// updateSpaceSearchParams sets the default index_params of searches, an empty
+// body or null clears it
+func (ca *clusterAPI) updateSpaceSearchParams(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	params, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceSearchParamsService(c, dbName, spaceName, params, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpaceFieldAliases replaces the field aliases of space by a body of
+// {"alias": "field"}, an empty object clears them
+func (ca *clusterAPI) updateSpaceFieldAliases(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	aliases := make(map[string]string)
+	if err := c.ShouldBindJSON(&aliases); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceFieldAliasesService(c, dbName, spaceName, aliases, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpacePipeline replaces the retrieval pipeline of space by a body of
+// stages, an empty array clears it
+func (ca *clusterAPI) updateSpacePipeline(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	stages := make([]*entity.PipelineStage, 0)
+	if err := c.ShouldBindJSON(&stages); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpacePipelineService(c, dbName, spaceName, stages, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpaceMetrics replaces the custom metrics of space by a body of
+// metrics, an empty array clears them
+func (ca *clusterAPI) updateSpaceMetrics(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	metrics := make([]*entity.SpaceMetric, 0)
+	if err := c.ShouldBindJSON(&metrics); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceMetricsService(c, dbName, spaceName, metrics, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpaceUDFs replaces the user defined functions of space by a body of
+// udfs, an empty array clears them
+func (ca *clusterAPI) updateSpaceUDFs(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	udfs := make([]*entity.SpaceUDF, 0)
+	if err := c.ShouldBindJSON(&udfs); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceUDFsService(c, dbName, spaceName, udfs, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpaceIngestPipeline replaces the ingest pipeline of space by a body
+// of processors, an empty array clears it
+func (ca *clusterAPI) updateSpaceIngestPipeline(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	processors := make([]*entity.IngestProcessor, 0)
+	if err := c.ShouldBindJSON(&processors); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceIngestPipelineService(c, dbName, spaceName, processors, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpaceDeadLetter sets the dead letter of space by a body of a space or
+// an url, an empty object clears it
+func (ca *clusterAPI) updateSpaceDeadLetter(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	deadLetter := &entity.DeadLetter{}
+	if err := c.ShouldBindJSON(deadLetter); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceDeadLetterService(c, dbName, spaceName, deadLetter, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpaceLeadership sets the leader priorities of space, an empty object
+// clears them
+func (ca *clusterAPI) updateSpaceLeadership(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	leadership := &entity.Leadership{}
+	if err := c.ShouldBindJSON(leadership); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := leadership.Validate(); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceLeadershipService(c, dbName, spaceName, leadership, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpaceAnnotations replaces the response annotations of space by a
+// body of {"key": "value"}, an empty object clears them
+func (ca *clusterAPI) updateSpaceAnnotations(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	annotations := make(map[string]string)
+	if err := c.ShouldBindJSON(&annotations); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := entity.ValidateAnnotations(annotations); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceAnnotationsService(c, dbName, spaceName, annotations, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// updateSpaceWriteGuard sets the write guard of space, an empty object clears
+// it
+func (ca *clusterAPI) updateSpaceWriteGuard(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	guard := &entity.WriteGuard{}
+	if err := c.ShouldBindJSON(guard); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	version, err := entity.ParseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	if space, err := ca.masterService.updateSpaceWriteGuardService(c, dbName, spaceName, guard, version); err != nil {
+		spaceUpdateError(c, err)
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// pauseSpaceWrites makes routers reject the writes to space until they are
+// resumed, the body may give the reason
+func (ca *clusterAPI) pauseSpaceWrites(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	pause := &entity.WritePause{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(pause); err != nil {
+			response.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+	}
+	pause.Owner = entity.WritePauseOperator
+
+	if space, err := ca.masterService.pauseSpaceWritesService(c, dbName, spaceName, pause); err != nil {
+		response.New(c).JsonError(errors.NewErr(err))
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// resumeSpaceWrites lets routers write space again, whoever paused it
+func (ca *clusterAPI) resumeSpaceWrites(c *gin.Context) {
+	dbName := c.Param(dbName)
+	spaceName := c.Param(spaceName)
+
+	if space, err := ca.masterService.resumeSpaceWritesService(c, dbName, spaceName, ""); err != nil {
+		response.New(c).JsonError(errors.NewErr(err))
+	} else {
+		spaceUpdateSuccess(c, space)
+	}
+}
+
+// spaceUpdateError replies 412 if the space no longer has the version
+// required by If-Match
+func spaceUpdateError(c *gin.Context, err error) {
+	if _, ok := err.(*entity.SpaceVersionConflict); ok {
+		response.New(c).JsonError(errors.NewErrPreconditionFailed(err))
 		return
 	}
+	response.New(c).JsonError(errors.NewErrInternal(err))
+}
 
-	log.Debug("updateSpaceResource %v", space)
-
-	if spaceResult, err := ca.masterService.updateSpaceResourceService(c, space); err != nil {
-		response.New(c).JsonError(errors.NewErrInternal(err))
-	} else {
-		response.New(c).JsonSuccess(spaceResult)
-	}
+// spaceUpdateSuccess replies the updated space with its new version, for the
+// If-Match of the next update
+func spaceUpdateSuccess(c *gin.Context, space *entity.Space) {
+	c.Header(entity.SpaceVersionHeader, strconv.FormatUint(space.Version, 10))
+	c.Header("ETag", entity.SpaceETag(space.Version))
+	response.New(c).JsonSuccess(space)
 }
 
 func (ca *clusterAPI) backupDb(c *gin.Context) {
@@ -701,6 +1645,70 @@ func (ca *clusterAPI) backupSpace(c *gin.Context) {
 	}
 }
 
+// importIndex starts loading an index built offline into every replica
+func (ca *clusterAPI) importIndex(c *gin.Context) {
+	indexImport := &entity.IndexImport{}
+	if err := c.ShouldBindJSON(indexImport); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	indexImport.Command = entity.IndexImportCommandStart
+	statuses, err := ca.masterService.ImportIndexService(c, c.Param(dbName), c.Param(spaceName), indexImport)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(statuses)
+}
+
+// importIndexStatus replies the last index import of every replica
+func (ca *clusterAPI) importIndexStatus(c *gin.Context) {
+	indexImport := &entity.IndexImport{Command: entity.IndexImportCommandStatus}
+	statuses, err := ca.masterService.ImportIndexService(c, c.Param(dbName), c.Param(spaceName), indexImport)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(statuses)
+}
+
+// copySpace starts copying a space of another cluster into every replica
+func (ca *clusterAPI) copySpace(c *gin.Context) {
+	spaceCopy := &entity.SpaceCopy{}
+	if err := c.ShouldBindJSON(spaceCopy); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	spaceCopy.Command = entity.SpaceCopyCommandStart
+	statuses, err := ca.masterService.CopySpaceService(c, c.Param(dbName), c.Param(spaceName), spaceCopy)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(statuses)
+}
+
+// copySpaceStatus replies the last copy of every replica
+func (ca *clusterAPI) copySpaceStatus(c *gin.Context) {
+	spaceCopy := &entity.SpaceCopy{Command: entity.SpaceCopyCommandStatus}
+	statuses, err := ca.masterService.CopySpaceService(c, c.Param(dbName), c.Param(spaceName), spaceCopy)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(statuses)
+}
+
+// copySource opens the snapshots another cluster copies the space from
+func (ca *clusterAPI) copySource(c *gin.Context) {
+	source, err := ca.masterService.copySourceService(c, c.Param(dbName), c.Param(spaceName))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(source)
+}
+
 func (ca *clusterAPI) ResourceLimit(c *gin.Context) {
 	resourceLimit := &entity.ResourceLimit{}
 	if err := c.ShouldBindJSON(resourceLimit); err != nil {
@@ -799,11 +1807,320 @@ func (ca *clusterAPI) modifyAlias(c *gin.Context) {
 	}
 }
 
+func (ca *clusterAPI) createWebhook(c *gin.Context) {
+	hook := &entity.Webhook{}
+	if err := c.ShouldBindJSON(hook); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	log.Debug("create webhook: %s, url: %s", hook.Name, hook.URL)
+
+	if err := ca.masterService.createWebhookService(c, hook); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	hook.Secret = ""
+	response.New(c).JsonSuccess(hook)
+}
+
+func (ca *clusterAPI) deleteWebhook(c *gin.Context) {
+	name := c.Param(webhookName)
+	log.Debug("delete webhook: %s", name)
+
+	if err := ca.masterService.deleteWebhookService(c, name); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).SuccessDelete()
+}
+
+// getWebhook never returns the secret of webhook
+func (ca *clusterAPI) getWebhook(c *gin.Context) {
+	name := c.Param(webhookName)
+	if name == "" {
+		hooks, err := ca.masterService.Master().QueryWebhooks(c)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		for _, hook := range hooks {
+			hook.Secret = ""
+		}
+		response.New(c).JsonSuccess(hooks)
+	} else {
+		hook, err := ca.masterService.queryWebhookService(c, name)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		hook.Secret = ""
+		response.New(c).JsonSuccess(hook)
+	}
+}
+
+func (ca *clusterAPI) createResourceGroup(c *gin.Context) {
+	group := &entity.ResourceGroup{}
+	if err := c.ShouldBindJSON(group); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	log.Debug("create resource group: %+v", group)
+
+	if err := ca.masterService.putResourceGroupService(c, group, false); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(group)
+}
+
+func (ca *clusterAPI) updateResourceGroup(c *gin.Context) {
+	group := &entity.ResourceGroup{}
+	if err := c.ShouldBindJSON(group); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	group.Name = c.Param(resourceGroupName)
+
+	log.Debug("update resource group: %+v", group)
+
+	if err := ca.masterService.putResourceGroupService(c, group, true); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(group)
+}
+
+func (ca *clusterAPI) deleteResourceGroup(c *gin.Context) {
+	name := c.Param(resourceGroupName)
+	log.Debug("delete resource group: %s", name)
+
+	if err := ca.masterService.deleteResourceGroupService(c, name); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).SuccessDelete()
+}
+
+func (ca *clusterAPI) getResourceGroup(c *gin.Context) {
+	name := c.Param(resourceGroupName)
+	if name == "" {
+		groups, err := ca.masterService.Master().QueryResourceGroups(c)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		response.New(c).JsonSuccess(groups)
+	} else {
+		group, err := ca.masterService.queryResourceGroupService(c, name)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		response.New(c).JsonSuccess(group)
+	}
+}
+
+func (ca *clusterAPI) createSpaceTemplate(c *gin.Context) {
+	template := &entity.SpaceTemplate{}
+	if err := c.ShouldBindJSON(template); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	log.Debug("create space template: %s", template.Name)
+
+	if err := ca.masterService.putSpaceTemplateService(c, template, false); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(template)
+}
+
+func (ca *clusterAPI) updateSpaceTemplate(c *gin.Context) {
+	template := &entity.SpaceTemplate{}
+	if err := c.ShouldBindJSON(template); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	template.Name = c.Param(spaceTemplateName)
+
+	log.Debug("update space template: %s", template.Name)
+
+	if err := ca.masterService.putSpaceTemplateService(c, template, true); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(template)
+}
+
+func (ca *clusterAPI) deleteSpaceTemplate(c *gin.Context) {
+	name := c.Param(spaceTemplateName)
+	log.Debug("delete space template: %s", name)
+
+	if err := ca.masterService.deleteSpaceTemplateService(c, name); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).SuccessDelete()
+}
+
+func (ca *clusterAPI) getSpaceTemplate(c *gin.Context) {
+	name := c.Param(spaceTemplateName)
+	if name == "" {
+		templates, err := ca.masterService.Master().QuerySpaceTemplates(c)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		response.New(c).JsonSuccess(templates)
+	} else {
+		template, err := ca.masterService.querySpaceTemplateService(c, name)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		response.New(c).JsonSuccess(template)
+	}
+}
+
+func (ca *clusterAPI) putFeatureFlag(c *gin.Context) {
+	flag := &entity.FeatureFlag{}
+	if err := c.ShouldBindJSON(flag); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	flag.Name = c.Param(featureFlagName)
+
+	if err := ca.masterService.putFeatureFlagService(c, flag); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(flag)
+}
+
+func (ca *clusterAPI) deleteFeatureFlag(c *gin.Context) {
+	name := c.Param(featureFlagName)
+	log.Debug("delete feature flag: %s", name)
+
+	if err := ca.masterService.deleteFeatureFlagService(c, name); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).SuccessDelete()
+}
+
+func (ca *clusterAPI) getFeatureFlag(c *gin.Context) {
+	name := c.Param(featureFlagName)
+	if name == "" {
+		flags, err := ca.masterService.Master().QueryFeatureFlags(c)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		response.New(c).JsonSuccess(flags)
+	} else {
+		flag, err := ca.masterService.queryFeatureFlagService(c, name)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		response.New(c).JsonSuccess(flag)
+	}
+}
+
+func (ca *clusterAPI) putGlobalAlias(c *gin.Context) {
+	alias := &entity.GlobalAlias{}
+	if err := c.ShouldBindJSON(alias); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	alias.Name = c.Param(globalAliasName)
+
+	if err := ca.masterService.putGlobalAliasService(c, alias); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(alias)
+}
+
+func (ca *clusterAPI) deleteGlobalAlias(c *gin.Context) {
+	name := c.Param(globalAliasName)
+	log.Debug("delete global alias: %s", name)
+
+	if err := ca.masterService.deleteGlobalAliasService(c, name); err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).SuccessDelete()
+}
+
+func (ca *clusterAPI) getGlobalAlias(c *gin.Context) {
+	name := c.Param(globalAliasName)
+	if name == "" {
+		aliases, err := ca.masterService.Master().QueryGlobalAliases(c)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		response.New(c).JsonSuccess(aliases)
+	} else {
+		alias, err := ca.masterService.queryGlobalAliasService(c, name)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		response.New(c).JsonSuccess(alias)
+	}
+}
+
+func (ca *clusterAPI) getMetaSchema(c *gin.Context) {
+	status, err := ca.masterService.metaSchemaService(c, c.Param(metaKind))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(status)
+}
+
+func (ca *clusterAPI) dualMetaSchema(c *gin.Context) {
+	req := &entity.MetaSchemaRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	status, err := ca.masterService.dualMetaSchemaService(c, c.Param(metaKind), req.Target)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(status)
+}
+
+func (ca *clusterAPI) finalizeMetaSchema(c *gin.Context) {
+	status, err := ca.masterService.finalizeMetaSchemaService(c, c.Param(metaKind))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(status)
+}
+
+func (ca *clusterAPI) abortMetaSchema(c *gin.Context) {
+	status, err := ca.masterService.abortMetaSchemaService(c, c.Param(metaKind))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(status)
+}
+
 func (ca *clusterAPI) createUser(c *gin.Context) {
 	user := &entity.User{}
 	if err := c.ShouldBindJSON(user); err != nil {
 		body, _ := netutil.GetReqBody(c.Request)
-		log.Error("create space request: %s, err: %s", body, err.Error())
+		log.Error("create space request: %s, err: %s", redact.Payload(body), err.Error())
 		response.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
@@ -855,7 +2172,7 @@ func (ca *clusterAPI) updateUser(c *gin.Context) {
 	user := &entity.User{}
 	if err := c.ShouldBindJSON(user); err != nil {
 		body, _ := netutil.GetReqBody(c.Request)
-		log.Error("create space request: %s, err: %s", body, err.Error())
+		log.Error("create space request: %s, err: %s", redact.Payload(body), err.Error())
 		response.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
@@ -905,7 +2222,7 @@ func (ca *clusterAPI) createRole(c *gin.Context) {
 	role := &entity.Role{}
 	if err := c.ShouldBindJSON(role); err != nil {
 		body, _ := netutil.GetReqBody(c.Request)
-		log.Error("create space request: %s, err: %s", body, err.Error())
+		log.Error("create space request: %s, err: %s", redact.Payload(body), err.Error())
 		response.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
@@ -951,7 +2268,7 @@ func (ca *clusterAPI) changeRolePrivilege(c *gin.Context) {
 	role := &entity.Role{}
 	if err := c.ShouldBindJSON(role); err != nil {
 		body, _ := netutil.GetReqBody(c.Request)
-		log.Error("create space request: %s, err: %s", body, err.Error())
+		log.Error("create space request: %s, err: %s", redact.Payload(body), err.Error())
 		response.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
@@ -1078,10 +2395,17 @@ func (ca *clusterAPI) serverList(c *gin.Context) {
 	}
 
 	serverInfos := make([]map[string]interface{}, 0, len(servers))
+	// servers by build version, more than one means a mixed cluster
+	versions := make(map[string]int)
 
 	for _, server := range servers {
 		serverInfo := make(map[string]interface{})
 		serverInfo["server"] = server
+		if server.Version != nil {
+			versions[server.Version.BuildVersion+"-"+server.Version.CommitID]++
+		} else {
+			versions["unknown"]++
+		}
 
 		partitionInfos, err := client.PartitionInfos(server.RpcAddr())
 		if err != nil {
@@ -1092,7 +2416,7 @@ func (ca *clusterAPI) serverList(c *gin.Context) {
 		serverInfos = append(serverInfos, serverInfo)
 	}
 
-	response.New(c).JsonSuccess(map[string]interface{}{"servers": serverInfos, "count": len(servers)})
+	response.New(c).JsonSuccess(map[string]interface{}{"servers": serverInfos, "count": len(servers), "versions": versions})
 }
 
 // routerList list router
@@ -1104,6 +2428,16 @@ func (ca *clusterAPI) routerList(c *gin.Context) {
 	}
 }
 
+// discoverRouters lists the healthy routers clients can send requests to
+func (ca *clusterAPI) discoverRouters(c *gin.Context) {
+	routers, err := ca.masterService.Master().QueryRouterInfos(c, config.Conf().Global.Name)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(map[string]interface{}{"routers": routers, "count": len(routers)})
+}
+
 // partitionList list partition
 func (ca *clusterAPI) partitionList(c *gin.Context) {
 	partitions, err := ca.masterService.Master().QueryPartitions(c)
@@ -1114,6 +2448,21 @@ func (ca *clusterAPI) partitionList(c *gin.Context) {
 	}
 }
 
+// request statistics of a partition on each replica
+func (ca *clusterAPI) partitionStats(c *gin.Context) {
+	pid, err := strconv.ParseUint(c.Param(partitionID), 10, 32)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(fmt.Errorf("partition id [%s] is invalid", c.Param(partitionID))))
+		return
+	}
+	stats, err := ca.masterService.partitionStats(c, entity.PartitionID(pid))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+		return
+	}
+	response.New(c).JsonSuccess(stats)
+}
+
 // list fail servers
 func (cluster *clusterAPI) FailServerList(c *gin.Context) {
 	failServers, err := cluster.masterService.Master().QueryAllFailServer(c.Request.Context())
@@ -32,24 +32,40 @@ import (
 	"github.com/vearch/vearch/v3/internal/client"
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
+	"github.com/vearch/vearch/v3/internal/pkg/hlc"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/metrics/mserver"
 	"github.com/vearch/vearch/v3/internal/pkg/number"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
 	clientv3 "go.etcd.io/etcd/client/v3"
-	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
 // masterService is used for master administrator purpose. It should not used by router or partition server program
 type masterService struct {
 	*client.Client
+	webhooks *webhookDispatcher
+	alerts   *alertManager
+	leaders  *leaderBalancer
+	placer   *leaderPlacer
+	etcd     *etcdMaintainer
+	orphans  *orphanChecker
+	cron     *cronScheduler
 }
 
 func newMasterService(client *client.Client) (*masterService, error) {
-	return &masterService{client}, nil
+	ms := &masterService{Client: client, webhooks: newWebhookDispatcher(client)}
+	ms.alerts = newAlertManager(ms)
+	ms.leaders = newLeaderBalancer(ms)
+	ms.placer = newLeaderPlacer(ms)
+	ms.etcd = newEtcdMaintainer(ms)
+	ms.orphans = newOrphanChecker(ms)
+	ms.cron = newCronScheduler(ms)
+	return ms, nil
 }
 
 // registerServerService find nodeId partitions
@@ -79,11 +95,40 @@ func (ms *masterService) registerServerService(ctx context.Context, ip string, n
 // registerPartitionService partition/[id]:[body]
 func (ms *masterService) registerPartitionService(ctx context.Context, partition *entity.Partition) error {
 	log.Info("register partition:[%d] ", partition.Id)
-	marshal, err := vjson.Marshal(partition)
+	// read and write in one transaction, so a register racing with another
+	// retries on the partition it wrote and its version is always newer
+	var old *entity.Partition
+	err := ms.Master().STM(ctx, func(stm store.STM) error {
+		old = nil
+		if value := stm.Get(entity.PartitionKey(partition.Id)); value != "" {
+			old = &entity.Partition{}
+			if err := vjson.Unmarshal([]byte(value), old); err != nil {
+				return err
+			}
+			// the write must win over the stored one even if this clock is behind
+			hlc.Update(old.UpdateTime)
+		}
+		partition.UpdateTime = hlc.Now()
+		marshal, err := vjson.Marshal(partition)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.PartitionKey(partition.Id), string(marshal))
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	return ms.Master().Put(ctx, entity.PartitionKey(partition.Id), marshal)
+	if old != nil && old.LeaderID != 0 && old.LeaderID != partition.LeaderID {
+		ms.webhooks.publish(entity.EventPartitionFailover, map[string]interface{}{
+			"partition_id": partition.Id,
+			"db_id":        partition.DBId,
+			"space_id":     partition.SpaceId,
+			"old_leader":   old.LeaderID,
+			"new_leader":   partition.LeaderID,
+		})
+	}
+	return nil
 }
 
 // createDBService three keys "db/id/[dbId]:[dbName]" ,"db/name/[dbName]:[dbId]" ,"db/body/[dbId]:[dbBody]"
@@ -123,7 +168,7 @@ func (ms *masterService) createDBService(ctx context.Context, db *entity.DB) (er
 			log.Error("unlock db err:[%s]", err.Error())
 		}
 	}()
-	err = ms.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
 		idKey, nameKey, bodyKey := ms.Master().DBKeys(db.Id, db.Name)
 
 		if stm.Get(nameKey) != "" {
@@ -158,7 +203,7 @@ func (ms *masterService) validatePS(ctx context.Context, psList []string) error
 		for _, server := range servers {
 			if server.Ip == ps {
 				flag = true
-				if !client.IsLive(server.RpcAddr()) {
+				if !psClient.IsLive(server.RpcAddr()) {
 					return fmt.Errorf("server:[%s] can not connection", ps)
 				}
 				break
@@ -197,7 +242,7 @@ func (ms *masterService) deleteDBService(ctx context.Context, dbstr string) (err
 	}
 
 	err = ms.Master().STM(context.Background(),
-		func(stm concurrency.STM) error {
+		func(stm store.STM) error {
 			idKey, nameKey, bodyKey := ms.Master().DBKeys(db.Id, db.Name)
 			stm.Del(idKey)
 			stm.Del(nameKey)
@@ -261,6 +306,86 @@ func (ms *masterService) updateDBIpList(ctx context.Context, dbModify *entity.DB
 	return db, err
 }
 
+// updateDBSettingsService changes the defaults and quota of db, the spaces
+// created before keep their settings
+func (ms *masterService) updateDBSettingsService(ctx context.Context, dbName string, settings *entity.DBSettings) (*entity.DB, error) {
+	if settings.Defaults != nil {
+		if err := settings.Defaults.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if settings.Quota != nil {
+		if err := settings.Quota.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	mutex := ms.Master().NewLock(ctx, entity.LockDBKey(dbName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock db err:[%s]", err.Error())
+		}
+	}()
+
+	db, err := ms.queryDBService(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Defaults != nil {
+		db.Defaults = settings.Defaults
+		if *db.Defaults == (entity.DBDefaults{}) {
+			db.Defaults = nil
+		}
+	}
+	if settings.Quota != nil {
+		db.Quota = settings.Quota
+		if *db.Quota == (entity.DBQuota{}) {
+			db.Quota = nil
+		}
+	}
+
+	_, _, bodyKey := ms.Master().DBKeys(db.Id, db.Name)
+	value, err := vjson.Marshal(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := ms.Master().Put(ctx, bodyKey, value); err != nil {
+		return nil, err
+	}
+	log.Info("update settings of db %s to %s", dbName, string(value))
+	return db, nil
+}
+
+// checkDBQuota checks db can add spaces with partitions of replicaNum
+func (ms *masterService) checkDBQuota(ctx context.Context, dbId entity.DBID, addSpaces, addPartitions int, replicaNum uint8) error {
+	bs, err := ms.Master().Get(ctx, entity.DBKeyBody(dbId))
+	if err != nil {
+		return err
+	}
+	if bs == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_DB_NOT_EXIST, nil)
+	}
+	db := &entity.DB{}
+	if err := vjson.Unmarshal(bs, db); err != nil {
+		return err
+	}
+	if db.Quota == nil {
+		return nil
+	}
+	spaces, err := ms.Master().QuerySpaces(ctx, dbId)
+	if err != nil {
+		return err
+	}
+	partitions := 0
+	for _, space := range spaces {
+		partitions += len(space.Partitions)
+	}
+	return db.Quota.CheckQuota(len(spaces), partitions, addSpaces, addPartitions, replicaNum)
+}
+
 func (ms *masterService) queryDBService(ctx context.Context, dbstr string) (db *entity.DB, err error) {
 	var id int64
 	db = &entity.DB{}
@@ -302,6 +427,28 @@ func (ms *masterService) createSpaceService(ctx context.Context, dbName string,
 		return err
 	}
 
+	if space.ResourceGroup != "" {
+		if _, err = ms.queryResourceGroupService(ctx, space.ResourceGroup); err != nil {
+			return err
+		}
+	}
+
+	if space.Leadership != nil {
+		if err = space.Leadership.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err = entity.ValidateAnnotations(space.Annotations); err != nil {
+		return err
+	}
+
+	if space.WriteGuard != nil {
+		if err = space.WriteGuard.Validate(space); err != nil {
+			return err
+		}
+	}
+
 	// it will lock cluster to create space
 	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*300)
 	if err = mutex.Lock(); err != nil {
@@ -313,11 +460,14 @@ func (ms *masterService) createSpaceService(ctx context.Context, dbName string,
 		}
 	}()
 
+	if err = ms.checkDBQuota(ctx, space.DBId, 1, space.PartitionNum, space.ReplicaNum); err != nil {
+		return err
+	}
+
 	// spaces is existed
 	if _, err := ms.Master().QuerySpaceByName(ctx, space.DBId, space.Name); err != nil {
-		vErr := vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err)
-		if vErr.GetError().Code != vearchpb.ErrorEnum_SPACE_NOT_EXIST {
-			return vErr
+		if !errors.Is(err, vearchpb.ErrSpaceNotFound) {
+			return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err)
 		}
 	} else {
 		return vearchpb.NewError(vearchpb.ErrorEnum_SPACE_EXIST, nil)
@@ -409,6 +559,7 @@ func (ms *masterService) createSpaceService(ctx context.Context, dbName string,
 		}
 	}()
 
+	space.UpdateTime = hlc.Now()
 	marshal, err := vjson.Marshal(space)
 	if err != nil {
 		return err
@@ -443,7 +594,7 @@ func (ms *masterService) createSpaceService(ctx context.Context, dbName string,
 				}
 			}()
 			for _, addr := range addrs {
-				if err := client.CreatePartition(addr, space, partition.Id); err != nil {
+				if err := psClient.CreatePartition(addr, space, partition.Id); err != nil {
 					err := vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("create partition err: %s", err.Error()))
 					errChain <- err
 					log.Error(err.Error())
@@ -469,7 +620,7 @@ func (ms *masterService) createSpaceService(ctx context.Context, dbName string,
 			if v%5 == 0 {
 				log.Debug("check the partition:%d status", space.Partitions[i].Id)
 			}
-			if err != nil && vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError().Code != vearchpb.ErrorEnum_PARTITION_NOT_EXIST {
+			if err != nil && !errors.Is(err, vearchpb.ErrPartitionNotFound) {
 				return err
 			}
 			if partition != nil {
@@ -490,6 +641,14 @@ func (ms *masterService) createSpaceService(ctx context.Context, dbName string,
 		return err
 	}
 
+	ms.webhooks.publish(entity.EventSpaceCreated, map[string]interface{}{
+		"db_name":       dbName,
+		"space_name":    space.Name,
+		"space_id":      space.Id,
+		"partition_num": len(space.Partitions),
+		"replica_num":   space.ReplicaNum,
+	})
+
 	return nil
 }
 
@@ -526,8 +685,13 @@ func (ms *masterService) selectServersForPartition(servers []*entity.Server, ser
 		i++
 	}
 
+	// servers with the same partition num are ordered by index, so the same
+	// cluster state always gets the same placement
 	sort.Slice(kvList, func(i, j int) bool {
-		return kvList[i].length < kvList[j].length
+		if kvList[i].length != kvList[j].length {
+			return kvList[i].length < kvList[j].length
+		}
+		return kvList[i].index < kvList[j].index
 	})
 
 	zoneCount := make(map[string]int)
@@ -550,7 +714,7 @@ func (ms *masterService) selectServersForPartition(servers []*entity.Server, ser
 			zone = ""
 		}
 
-		if !client.IsLive(addr) {
+		if !psClient.IsLive(addr) {
 			serverPartitions[kv.index] = kv.length
 			continue
 		}
@@ -602,10 +766,15 @@ func (ms *masterService) filterAndSortServer(ctx context.Context, space *entity.
 
 	serverIndex := make(map[entity.NodeID]int)
 
+	// old servers can not build index types added after them
+	supported := func(s *entity.Server) bool {
+		return space.Index == nil || s.SupportsIndex(space.Index.Type)
+	}
+
 	if psMap == nil { // If psMap is nil, only use public servers
 		for i, s := range servers {
 			// Only use servers with the same resource name
-			if s.ResourceName != space.ResourceName {
+			if s.ResourceName != space.ResourceName || !supported(s) {
 				continue
 			}
 			if !s.Private {
@@ -620,7 +789,7 @@ func (ms *masterService) filterAndSortServer(ctx context.Context, space *entity.
 				psMap[s.Ip] = false
 				continue
 			}
-			if psMap[s.Ip] {
+			if psMap[s.Ip] && supported(s) {
 				serverPartitions[i] = 0
 				serverIndex[s.ID] = i
 			}
@@ -675,7 +844,7 @@ func (ms *masterService) deleteSpaceService(ctx context.Context, dbName string,
 			if server, err := ms.Master().QueryServer(ctx, replica); err != nil {
 				log.Error("query partition:[%d] for replica:[%s] has err:[%s]", p.Id, replica, err.Error())
 			} else {
-				if err := client.DeletePartition(server.RpcAddr(), p.Id); err != nil {
+				if err := psClient.DeletePartition(server.RpcAddr(), p.Id); err != nil {
 					log.Error("delete partition:[%d] for server:[%s] has err:[%s]", p.Id, server.RpcAddr(), err.Error())
 				}
 			}
@@ -684,6 +853,9 @@ func (ms *masterService) deleteSpaceService(ctx context.Context, dbName string,
 		if err != nil {
 			return err
 		}
+		if err := ms.Master().Delete(ctx, entity.FencingTokenKey(p.Id)); err != nil {
+			log.Warn("delete fencing token of partition [%d] err: %v", p.Id, err)
+		}
 	}
 
 	// delete alias
@@ -703,6 +875,12 @@ func (ms *masterService) deleteSpaceService(ctx context.Context, dbName string,
 		return err
 	}
 
+	ms.webhooks.publish(entity.EventSpaceDeleted, map[string]interface{}{
+		"db_name":    dbName,
+		"space_name": spaceName,
+		"space_id":   space.Id,
+	})
+
 	return nil
 }
 
@@ -715,7 +893,7 @@ func (ms *masterService) queryDBs(ctx context.Context) ([]*entity.DB, error) {
 	for _, bs := range bytesArr {
 		db := &entity.DB{}
 		if err := vjson.Unmarshal(bs, db); err != nil {
-			log.Error("decode db err: %s,and the bs is:%s", err.Error(), string(bs))
+			log.Error("decode db err: %s,and the bs is:%s", err.Error(), redact.Payload(bs))
 			continue
 		}
 		dbs = append(dbs, db)
@@ -891,7 +1069,7 @@ func (ms *masterService) createAliasService(ctx context.Context, alias *entity.A
 			log.Error("unlock lock for create alias err %s", err)
 		}
 	}()
-	err = ms.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
 		aliasKey := entity.AliasKey(alias.Name)
 
 		value := stm.Get(aliasKey)
@@ -925,7 +1103,7 @@ func (ms *masterService) deleteAliasService(ctx context.Context, alias_name stri
 	}()
 
 	err = ms.Master().STM(context.Background(),
-		func(stm concurrency.STM) error {
+		func(stm store.STM) error {
 			stm.Del(entity.AliasKey(alias.Name))
 			return nil
 		})
@@ -956,7 +1134,7 @@ func (ms *masterService) updateAliasService(ctx context.Context, alias *entity.A
 			log.Error("unlock lock for update alias err %s", err)
 		}
 	}()
-	err = ms.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
 		marshal, err := vjson.Marshal(alias)
 		if err != nil {
 			return err
@@ -980,7 +1158,7 @@ func (ms *masterService) queryAllAlias(ctx context.Context) ([]*entity.Alias, er
 		alias := &entity.Alias{}
 		err = vjson.Unmarshal(value, alias)
 		if err != nil {
-			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get alias:%s value:%s, err:%s", alias.Name, string(value), err.Error()))
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get alias:%s value:%s, err:%s", alias.Name, redact.Payload(value), err.Error()))
 		}
 		allAlias = append(allAlias, alias)
 	}
@@ -1003,7 +1181,7 @@ func (ms *masterService) queryAliasService(ctx context.Context, alias_name strin
 
 	err = vjson.Unmarshal(bs, alias)
 	if err != nil {
-		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get alias:%s value:%s, err:%s", alias.Name, string(bs), err.Error()))
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get alias:%s value:%s, err:%s", alias.Name, redact.Payload(bs), err.Error()))
 	}
 	return alias, nil
 }
@@ -1035,7 +1213,7 @@ func (ms *masterService) createUserService(ctx context.Context, user *entity.Use
 			log.Error("unlock lock for create user err %s", err)
 		}
 	}()
-	err = ms.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
 		userKey := entity.UserKey(user.Name)
 
 		value := stm.Get(userKey)
@@ -1072,7 +1250,7 @@ func (ms *masterService) deleteUserService(ctx context.Context, user_name string
 	}()
 
 	err = ms.Master().STM(context.Background(),
-		func(stm concurrency.STM) error {
+		func(stm store.STM) error {
 			stm.Del(entity.UserKey(user.Name))
 			return nil
 		})
@@ -1144,7 +1322,7 @@ func (ms *masterService) updateUserService(ctx context.Context, user *entity.Use
 			log.Error("unlock lock for update alias err %s", err)
 		}
 	}()
-	err = ms.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
 		marshal, err := vjson.Marshal(user)
 		if err != nil {
 			return err
@@ -1258,19 +1436,14 @@ func (ms *masterService) createRoleService(ctx context.Context, role *entity.Rol
 			log.Error("unlock lock for create role err %s", err)
 		}
 	}()
-	err = ms.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
 		roleKey := entity.RoleKey(role.Name)
 
 		value := stm.Get(roleKey)
 		if value != "" {
 			return vearchpb.NewError(vearchpb.ErrorEnum_ROLE_EXIST, nil)
 		}
-		marshal, err := vjson.Marshal(role)
-		if err != nil {
-			return err
-		}
-		stm.Put(roleKey, string(marshal))
-		return nil
+		return putMeta(stm, entity.MetaKindRole, roleKey, role)
 	})
 	return err
 }
@@ -1292,8 +1465,8 @@ func (ms *masterService) deleteRoleService(ctx context.Context, role_name string
 	}()
 
 	err = ms.Master().STM(context.Background(),
-		func(stm concurrency.STM) error {
-			stm.Del(entity.RoleKey(role.Name))
+		func(stm store.STM) error {
+			delMeta(stm, entity.RoleKey(role.Name))
 			return nil
 		})
 
@@ -1318,7 +1491,7 @@ func (ms *masterService) changeRolePrivilegeService(ctx context.Context, role *e
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_ROLE_NOT_EXIST, nil)
 	}
 	old_role := &entity.Role{}
-	err = vjson.Unmarshal(bs, old_role)
+	_, err = entity.DecodeMeta(bs, old_role)
 	if err != nil {
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get role privilege:%s err:%s", old_role.Name, err.Error()))
 	}
@@ -1333,7 +1506,7 @@ func (ms *masterService) changeRolePrivilegeService(ctx context.Context, role *e
 			log.Error("unlock lock for update role privilege err %s", err)
 		}
 	}()
-	err = ms.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
 		if len(old_role.Privileges) == 0 {
 			old_role.Privileges = make(map[entity.Resource]entity.Privilege)
 		}
@@ -1345,13 +1518,11 @@ func (ms *masterService) changeRolePrivilegeService(ctx context.Context, role *e
 				delete(old_role.Privileges, resource)
 			}
 		}
-		marshal, err := vjson.Marshal(old_role)
-		if err != nil {
-			return err
-		}
-		stm.Put(entity.RoleKey(old_role.Name), string(marshal))
-		return nil
+		return putMeta(stm, entity.MetaKindRole, entity.RoleKey(old_role.Name), old_role)
 	})
+	if err != nil {
+		return nil, err
+	}
 	return old_role, nil
 }
 
@@ -1366,7 +1537,7 @@ func (ms *masterService) queryAllRole(ctx context.Context) ([]*entity.Role, erro
 			return nil, vearchpb.NewError(vearchpb.ErrorEnum_ROLE_NOT_EXIST, nil)
 		}
 		role := &entity.Role{}
-		err = vjson.Unmarshal(value, role)
+		_, err = entity.DecodeMeta(value, role)
 		if err != nil {
 			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get role:%s, err:%s", role.Name, err.Error()))
 		}
@@ -1394,7 +1565,7 @@ func (ms *masterService) queryRoleService(ctx context.Context, role_name string)
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_ROLE_NOT_EXIST, nil)
 	}
 
-	err = vjson.Unmarshal(bs, role)
+	_, err = entity.DecodeMeta(bs, role)
 	if err != nil {
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get role:%s, err:%s", role.Name, err.Error()))
 	}
@@ -1445,6 +1616,9 @@ func (ms *masterService) GetEngineCfg(ctx context.Context, dbName, spaceName str
 
 func (ms *masterService) ModifyEngineCfg(ctx context.Context, dbName, spaceName string, cfg *entity.EngineConfig) (err error) {
 	defer errutil.CatchError(&err)
+	if err = cfg.Validate(); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
 	// get space info
 	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
 	if err != nil {
@@ -1455,6 +1629,15 @@ func (ms *masterService) ModifyEngineCfg(ctx context.Context, dbName, spaceName
 	if err != nil {
 		errutil.ThrowError(err)
 	}
+
+	// the stored config is watched by ps, so nodes unreachable now apply it
+	// when they come back or load the partitions of the space
+	err = ms.updateEngineConfig(ctx, space, cfg)
+	if err != nil {
+		log.Error("update engine config err: %s", err.Error())
+		return err
+	}
+
 	// invoke all space nodeID
 	if space != nil && space.Partitions != nil {
 		for _, partition := range space.Partitions {
@@ -1462,25 +1645,25 @@ func (ms *masterService) ModifyEngineCfg(ctx context.Context, dbName, spaceName
 			if partition.Replicas != nil {
 				for _, nodeID := range partition.Replicas {
 					server, err := ms.Master().QueryServer(ctx, nodeID)
-					errutil.ThrowError(err)
+					if err != nil {
+						log.Warn("query server [%d] to update engine config err: %v", nodeID, err)
+						continue
+					}
 					// send rpc query
 					log.Debug("invoke nodeID [%+v],address [%+v]", partition.Id, server.RpcAddr())
-					err = client.UpdateEngineCfg(server.RpcAddr(), cfg, partition.Id)
-					errutil.ThrowError(err)
+					if err = client.UpdateEngineCfg(server.RpcAddr(), cfg, partition.Id); err != nil {
+						log.Warn("update engine config of partition [%d] on [%s] err: %v", partition.Id, server.RpcAddr(), err)
+					}
 				}
 			}
 		}
 	}
-
-	err = ms.updateEngineConfig(ctx, space, cfg)
-	if err != nil {
-		log.Error("update engine config err: %s", err.Error())
-		return err
-	}
 	return nil
 }
 
-func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceName string, temp *entity.Space) (*entity.Space, error) {
+// updateSpaceService updates space by temp, version if not 0 is the version
+// space should still have
+func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceName string, temp *entity.Space, version entity.Version) (*entity.Space, error) {
 	// it will lock cluster to create space
 	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*300)
 	if err := mutex.Lock(); err != nil {
@@ -1505,6 +1688,9 @@ func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceNa
 	if space == nil {
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("can not found space by name : %s", spaceName))
 	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
 
 	buff := bytes.Buffer{}
 	if temp.DBId != 0 && temp.DBId != space.DBId {
@@ -1529,6 +1715,15 @@ func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceNa
 		space.Enabled = temp.Enabled
 	}
 
+	if temp.ResourceGroup == entity.ResourceGroupNone {
+		space.ResourceGroup = ""
+	} else if temp.ResourceGroup != "" {
+		if _, err := ms.queryResourceGroupService(ctx, temp.ResourceGroup); err != nil {
+			return nil, err
+		}
+		space.ResourceGroup = temp.ResourceGroup
+	}
+
 	if err := space.Validate(); err != nil {
 		return nil, err
 	}
@@ -1582,7 +1777,7 @@ func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceNa
 			return nil, err
 		}
 
-		if !client.IsLive(server.RpcAddr()) {
+		if !psClient.IsLive(server.RpcAddr()) {
 			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_CLOSED, fmt.Errorf("partition %s is shutdown", server.RpcAddr()))
 		}
 	}
@@ -1601,7 +1796,7 @@ func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceNa
 		log.Debug("update partition server is [%+v], space is [%+v], pid is [%+v]",
 			server, space, p.Id)
 
-		if err := client.UpdatePartition(server.RpcAddr(), space, p.Id); err != nil {
+		if err := psClient.UpdatePartition(server.RpcAddr(), space, p.Id); err != nil {
 			log.Error("UpdatePartition err is [%v]", err)
 			return nil, err
 		}
@@ -1616,8 +1811,472 @@ func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceNa
 	return space, nil
 }
 
+// updateSpaceSearchParamsService persists the default index_params of searches
+// in space, routers apply it when they get the new space version
+func (ms *masterService) updateSpaceSearchParamsService(ctx context.Context, dbName, spaceName string, params []byte, version entity.Version) (*entity.Space, error) {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || string(params) == "null" {
+		params = nil
+	} else {
+		indexParams := &entity.IndexParams{}
+		if err := vjson.Unmarshal(params, indexParams); err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("search params should be index_params object, err: %v", err))
+		}
+	}
+
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	space.DefaultSearchParams = params
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update default search params of space %s/%s to [%s]", dbName, spaceName, string(params))
+	return space, nil
+}
+
+// updateSpaceFieldAliasesService replaces the field aliases of space, empty
+// aliases clear them. Only routers use the aliases so partitions are not
+// notified
+func (ms *masterService) updateSpaceFieldAliasesService(ctx context.Context, dbName, spaceName string, aliases map[string]string, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+	if err := space.ValidateFieldAliases(aliases); err != nil {
+		return nil, err
+	}
+
+	if len(aliases) == 0 {
+		aliases = nil
+	}
+	space.FieldAliases = aliases
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update field aliases of space %s/%s to %v", dbName, spaceName, aliases)
+	return space, nil
+}
+
+// updateSpacePipelineService replaces the retrieval pipeline of space, empty stages
+// clear it. Only routers run the pipeline so partitions are not notified
+func (ms *masterService) updateSpacePipelineService(ctx context.Context, dbName, spaceName string, stages []*entity.PipelineStage, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+	if err := space.ValidatePipeline(stages); err != nil {
+		return nil, err
+	}
+
+	if len(stages) == 0 {
+		stages = nil
+	}
+	space.Pipeline = stages
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update pipeline of space %s/%s to %d stages", dbName, spaceName, len(stages))
+	return space, nil
+}
+
+// updateSpaceMetricsService replaces the custom metrics of space, empty
+// metrics clear them. The pipeline of space must not refer to a dropped metric
+func (ms *masterService) updateSpaceMetricsService(ctx context.Context, dbName, spaceName string, metrics []*entity.SpaceMetric, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+	if err := space.ValidateMetrics(metrics); err != nil {
+		return nil, err
+	}
+
+	if len(metrics) == 0 {
+		metrics = nil
+	}
+	space.Metrics = metrics
+	if err := space.ValidatePipeline(space.Pipeline); err != nil {
+		return nil, err
+	}
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update metrics of space %s/%s to %d metrics", dbName, spaceName, len(metrics))
+	return space, nil
+}
+
+// updateSpaceUDFsService replaces the user defined functions of space, empty
+// udfs clear them
+func (ms *masterService) updateSpaceUDFsService(ctx context.Context, dbName, spaceName string, udfs []*entity.SpaceUDF, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+	if err := space.ValidateUDFs(udfs); err != nil {
+		return nil, err
+	}
+
+	if len(udfs) == 0 {
+		udfs = nil
+	}
+	space.UDFs = udfs
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update udfs of space %s/%s to %d udfs", dbName, spaceName, len(udfs))
+	return space, nil
+}
+
+// updateSpaceIngestPipelineService replaces the ingest pipeline of space,
+// empty processors clear it. Routers pick it up by watching the space.
+func (ms *masterService) updateSpaceIngestPipelineService(ctx context.Context, dbName, spaceName string, processors []*entity.IngestProcessor, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+	if err := space.ValidateIngestPipeline(processors); err != nil {
+		return nil, err
+	}
+
+	if len(processors) == 0 {
+		processors = nil
+	}
+	space.IngestPipeline = processors
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update ingest pipeline of space %s/%s to %d processors", dbName, spaceName, len(processors))
+	return space, nil
+}
+
+// updateSpaceDeadLetterService sets the dead letter of space, a dead letter
+// without space and url clears it. A dead letter space must be another space
+// of the same db with the fields of dead letters.
+func (ms *masterService) updateSpaceDeadLetterService(ctx context.Context, dbName, spaceName string, deadLetter *entity.DeadLetter, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	if deadLetter.Space == "" && deadLetter.URL == "" {
+		deadLetter = nil
+	} else {
+		if err := deadLetter.Validate(); err != nil {
+			return nil, err
+		}
+		if deadLetter.Space != "" {
+			if deadLetter.Space == spaceName {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dead letter space should not be space %s itself", spaceName))
+			}
+			target, err := ms.Master().QuerySpaceByName(ctx, dbId, deadLetter.Space)
+			if err != nil {
+				return nil, err
+			}
+			if err := entity.ValidateDeadLetterSpace(target); err != nil {
+				return nil, err
+			}
+		}
+	}
+	space.DeadLetter = deadLetter
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update dead letter of space %s/%s to %v", dbName, spaceName, deadLetter)
+	return space, nil
+}
+
+// updateSpaceLeadershipService sets the leader priorities of space, a
+// leadership without priorities clears them. The priorities of the raft
+// replicas running are set when they start, masters move the leaders by the
+// new ones until then.
+func (ms *masterService) updateSpaceLeadershipService(ctx context.Context, dbName, spaceName string, leadership *entity.Leadership, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	if len(leadership.Priorities) == 0 {
+		leadership = nil
+	}
+	space.Leadership = leadership
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update leadership of space %s/%s to %v", dbName, spaceName, leadership)
+	return space, nil
+}
+
+// updateSpaceAnnotationsService replaces the response annotations of space,
+// empty annotations clear them. Only routers return them so partitions are
+// not notified
+func (ms *masterService) updateSpaceAnnotationsService(ctx context.Context, dbName, spaceName string, annotations map[string]string, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	space.Annotations = annotations
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update annotations of space %s/%s to %v", dbName, spaceName, annotations)
+	return space, nil
+}
+
+// updateSpaceWriteGuardService sets the write guard of space, a guard without
+// field clears it. Only routers run it so partitions are not notified
+func (ms *masterService) updateSpaceWriteGuardService(ctx context.Context, dbName, spaceName string, guard *entity.WriteGuard, version entity.Version) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	if guard.Field == "" {
+		guard = nil
+	} else if err := guard.Validate(space); err != nil {
+		return nil, err
+	}
+	space.WriteGuard = guard
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update write guard of space %s/%s to %+v", dbName, spaceName, guard)
+	return space, nil
+}
+
+// pauseSpaceWritesService makes every router reject the writes to space,
+// a space already paused keeps the pause it has
+func (ms *masterService) pauseSpaceWritesService(ctx context.Context, dbName, spaceName string, pause *entity.WritePause) (*entity.Space, error) {
+	return ms.updateSpaceWritePause(ctx, dbName, spaceName, func(space *entity.Space) bool {
+		if space.WritePause != nil {
+			return false
+		}
+		pause.Time = time.Now().Unix()
+		space.WritePause = pause
+		return true
+	})
+}
+
+// resumeSpaceWritesService lets routers write space again, an owner resumes
+// only the pauses it owns and an empty owner resumes any
+func (ms *masterService) resumeSpaceWritesService(ctx context.Context, dbName, spaceName, owner string) (*entity.Space, error) {
+	return ms.updateSpaceWritePause(ctx, dbName, spaceName, func(space *entity.Space) bool {
+		if space.WritePause == nil || (owner != "" && space.WritePause.Owner != owner) {
+			return false
+		}
+		space.WritePause = nil
+		return true
+	})
+}
+
+func (ms *masterService) updateSpaceWritePause(ctx context.Context, dbName, spaceName string, fn func(space *entity.Space) bool) (*entity.Space, error) {
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to find database id according database name:%v,the Error is:%v ", dbName, err))
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if !fn(space) {
+		return space, nil
+	}
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	log.Info("update write pause of space %s/%s to %v", dbName, spaceName, space.WritePause)
+	return space, nil
+}
+
 func (ms *masterService) updateSpace(ctx context.Context, space *entity.Space) error {
 	space.Version++
+	hlc.Update(space.UpdateTime)
+	space.UpdateTime = hlc.Now()
 	if space.PartitionRule == nil {
 		space.PartitionNum = len(space.Partitions)
 	}
@@ -1858,9 +2517,54 @@ func (ms *masterService) BackupSpace(ctx context.Context, dbName, spaceName stri
 			}
 		}
 	}
+	ms.webhooks.publish(entity.EventBackupCompleted, map[string]interface{}{
+		"db_name":    dbName,
+		"space_name": spaceName,
+		"command":    backup.Command,
+		"parts":      part,
+	})
 	return nil
 }
 
+// ImportIndexService sends the index import command to every replica of the
+// partitions of space, so each replica loads the files of its partition
+func (ms *masterService) ImportIndexService(ctx context.Context, dbName, spaceName string, indexImport *entity.IndexImport) ([]*entity.IndexImportStatus, error) {
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	if indexImport.Command == entity.IndexImportCommandStart {
+		if indexImport.Field == "" || indexImport.S3Param.BucketName == "" {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field and s3_param.bucket_name should be set"))
+		}
+	}
+
+	statuses := make([]*entity.IndexImportStatus, 0, len(space.Partitions))
+	for _, p := range space.Partitions {
+		partition, err := ms.Master().QueryPartition(ctx, p.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, nodeID := range partition.Replicas {
+			server, err := ms.Master().QueryServer(ctx, nodeID)
+			if err != nil {
+				return nil, err
+			}
+			status, err := client.ImportIndex(server.RpcAddr(), indexImport, partition.Id)
+			if err != nil {
+				log.Error("import index of partition %d on node %d err: %v", partition.Id, nodeID, err)
+				status = &entity.IndexImportStatus{PartitionID: partition.Id, NodeID: nodeID, Field: indexImport.Field, State: entity.IndexImportFailed, Error: err.Error()}
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
 func (ms *masterService) ResourceLimitService(ctx context.Context, resourceLimit *entity.ResourceLimit) (err error) {
 	spaces := make([]*entity.Space, 0)
 	dbNames := make([]string, 0)
@@ -1924,7 +2628,7 @@ func (ms *masterService) ResourceLimitService(ctx context.Context, resourceLimit
 	return nil
 }
 
-func (ms *masterService) updateSpaceResourceService(ctx context.Context, spaceResource *entity.SpacePartitionResource) (*entity.Space, error) {
+func (ms *masterService) updateSpaceResourceService(ctx context.Context, spaceResource *entity.SpacePartitionResource, version entity.Version) (*entity.Space, error) {
 	// it will lock cluster, to update space
 	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(spaceResource.DbName, spaceResource.SpaceName), time.Second*300)
 	if err := mutex.Lock(); err != nil {
@@ -1949,6 +2653,9 @@ func (ms *masterService) updateSpaceResourceService(ctx context.Context, spaceRe
 	if space == nil {
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("can not found space by name : %s", spaceResource.SpaceName))
 	}
+	if err := space.CheckVersion(version); err != nil {
+		return nil, err
+	}
 
 	if spaceResource.PartitionOperatorType != "" {
 		if spaceResource.PartitionOperatorType != entity.Add && spaceResource.PartitionOperatorType != entity.Drop {
@@ -1962,6 +2669,10 @@ func (ms *masterService) updateSpaceResourceService(ctx context.Context, spaceRe
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("paritition_num: %d now should greater than origin space partition_num: %d", spaceResource.PartitionNum, space.PartitionNum))
 	}
 
+	if err := ms.checkDBQuota(ctx, space.DBId, 0, spaceResource.PartitionNum-space.PartitionNum, space.ReplicaNum); err != nil {
+		return nil, err
+	}
+
 	partitions := make([]*entity.Partition, 0)
 	for i := space.PartitionNum; i < spaceResource.PartitionNum; i++ {
 		partitionID, err := ms.Master().NewIDGenerate(ctx, entity.PartitionIdSequence, 1, 5*time.Second)
@@ -2024,7 +2735,7 @@ func (ms *masterService) updateSpaceResourceService(ctx context.Context, spaceRe
 				}
 			}()
 			for _, addr := range addrs {
-				if err := client.CreatePartition(addr, space, partition.Id); err != nil {
+				if err := psClient.CreatePartition(addr, space, partition.Id); err != nil {
 					err := vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("create partition err: %s ", err.Error()))
 					errChain <- err
 					log.Error(err.Error())
@@ -2050,7 +2761,7 @@ func (ms *masterService) updateSpaceResourceService(ctx context.Context, spaceRe
 			if times%5 == 0 {
 				log.Debug("updateSpacePartitionNum check the partition:%d status", partitions[i].Id)
 			}
-			if err != nil && vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError().Code != vearchpb.ErrorEnum_PARTITION_NOT_EXIST {
+			if err != nil && !errors.Is(err, vearchpb.ErrPartitionNotFound) {
 				return nil, err
 			}
 			if partition != nil {
@@ -2099,7 +2810,7 @@ func (ms *masterService) updateSpacePartitonRuleService(ctx context.Context, spa
 					if server, err := ms.Master().QueryServer(ctx, replica); err != nil {
 						log.Error("query partition:[%d] for replica:[%s] has err:[%s]", partition.Id, replica, err.Error())
 					} else {
-						if err := client.DeletePartition(server.RpcAddr(), partition.Id); err != nil {
+						if err := psClient.DeletePartition(server.RpcAddr(), partition.Id); err != nil {
 							log.Error("delete partition:[%d] for server:[%s] has err:[%s]", partition.Id, server.RpcAddr(), err.Error())
 						}
 					}
@@ -2108,6 +2819,9 @@ func (ms *masterService) updateSpacePartitonRuleService(ctx context.Context, spa
 				if err != nil {
 					return nil, err
 				}
+				if err := ms.Master().Delete(ctx, entity.FencingTokenKey(partition.Id)); err != nil {
+					log.Warn("delete fencing token of partition [%d] err: %v", partition.Id, err)
+				}
 			}
 		}
 		space.Partitions = new_partitions
@@ -2198,7 +2912,7 @@ func (ms *masterService) updateSpacePartitonRuleService(ctx context.Context, spa
 					}
 				}()
 				for _, addr := range addrs {
-					if err := client.CreatePartition(addr, space, partition.Id); err != nil {
+					if err := psClient.CreatePartition(addr, space, partition.Id); err != nil {
 						err := vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("create partition err: %s ", err.Error()))
 						errChain <- err
 						log.Error(err.Error())
@@ -2223,7 +2937,7 @@ func (ms *masterService) updateSpacePartitonRuleService(ctx context.Context, spa
 				if times%5 == 0 {
 					log.Debug("updateSpacePartitionNum check the partition:%d status", partitions[i].Id)
 				}
-				if err != nil && vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError().Code != vearchpb.ErrorEnum_PARTITION_NOT_EXIST {
+				if err != nil && !errors.Is(err, vearchpb.ErrPartitionNotFound) {
 					return nil, err
 				}
 				if partition != nil {
@@ -2317,12 +3031,12 @@ func (ms *masterService) ChangeMember(ctx context.Context, cm *entity.ChangeMemb
 	}
 	log.Info("targetNode is [%+v], cm is [%+v] ", targetNode, cm)
 
-	if !client.IsLive(masterNode.RpcAddr()) {
+	if !psClient.IsLive(masterNode.RpcAddr()) {
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_SERVER_ERROR, fmt.Errorf("server:[%d] addr:[%s] can not connect ", cm.NodeID, masterNode.RpcAddr()))
 	}
 
 	if cm.Method == proto.ConfAddNode && targetNode != nil {
-		if err := client.CreatePartition(targetNode.RpcAddr(), space, cm.PartitionID); err != nil {
+		if err := psClient.CreatePartition(targetNode.RpcAddr(), space, cm.PartitionID); err != nil {
 			return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("create partiiton has err:[%s] addr:[%s]", err.Error(), targetNode.RpcAddr()))
 		}
 	} else if cm.Method == proto.ConfRemoveNode {
@@ -2331,16 +3045,16 @@ func (ms *masterService) ChangeMember(ctx context.Context, cm *entity.ChangeMemb
 		return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("change member only support add:[%d] remove:[%d] not support:[%d]", proto.ConfAddNode, proto.ConfRemoveNode, cm.Method))
 	}
 
-	if err := client.ChangeMember(masterNode.RpcAddr(), cm); err != nil {
+	if err := psClient.ChangeMember(masterNode.RpcAddr(), cm); err != nil {
 		return err
 	}
-	if cm.Method == proto.ConfRemoveNode && targetNode != nil && client.IsLive(targetNode.RpcAddr()) {
-		if err := client.DeleteReplica(targetNode.RpcAddr(), cm.PartitionID); err != nil {
+	if cm.Method == proto.ConfRemoveNode && targetNode != nil && psClient.IsLive(targetNode.RpcAddr()) {
+		if err := psClient.DeleteReplica(targetNode.RpcAddr(), cm.PartitionID); err != nil {
 			return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("delete partiiton has err:[%s] addr:[%s]", err.Error(), targetNode.RpcAddr()))
 		}
 	}
 
-	if _, err := ms.updateSpaceService(ctx, dbName, space.Name, space); err != nil {
+	if _, err := ms.updateSpaceService(ctx, dbName, space.Name, space, 0); err != nil {
 		return err
 	}
 	log.Info("update space: %v", space)
@@ -2545,6 +3259,30 @@ func (ms *masterService) IsExistNode(ctx context.Context, id entity.NodeID, ip s
 	return nil
 }
 
+// partitionStats gets the request statistics of every replica of a partition,
+// replicas unreachable are returned with the error
+func (ms *masterService) partitionStats(ctx context.Context, pid entity.PartitionID) ([]*entity.PartitionStats, error) {
+	partition, err := ms.Master().QueryPartition(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.PartitionStats, 0, len(partition.Replicas))
+	for _, nodeID := range partition.Replicas {
+		server, err := ms.Master().QueryServer(ctx, nodeID)
+		if err != nil {
+			result = append(result, &entity.PartitionStats{PartitionID: pid, NodeID: nodeID, Error: err.Error()})
+			continue
+		}
+		stats, err := client.PartitionStats(server.RpcAddr(), pid)
+		if err != nil {
+			result = append(result, &entity.PartitionStats{PartitionID: pid, NodeID: nodeID, Ip: server.Ip, Error: err.Error()})
+			continue
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
+
 func (ms *masterService) partitionInfo(ctx context.Context, dbName string, spaceName string, detail string) ([]map[string]interface{}, error) {
 	dbNames := make([]string, 0)
 	if dbName != "" {
@@ -2748,7 +3486,7 @@ func (ms *masterService) removeMemberService(ctx context.Context, master *entity
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf(msg))
 	}
 
-	err = ms.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
 		stm.Del(entity.MasterMemberKey(master.ID))
 		return nil
 	})
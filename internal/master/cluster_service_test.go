@@ -0,0 +1,107 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+func TestRegisterPartitionService(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		stored    *entity.Partition
+		registers int
+	}{
+		{name: "New partition", registers: 1},
+		{name: "Stored by a clock ahead", stored: &entity.Partition{Id: 1, LeaderID: 1, UpdateTime: time.Now().Add(time.Hour).UnixNano()}, registers: 1},
+		{name: "Concurrent registers", stored: &entity.Partition{Id: 1, LeaderID: 1}, registers: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memStore := store.NewMemStore()
+			cli, err := client.NewClientWithStore(nil, memStore)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ms, err := newMasterService(cli)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var storedTime int64
+			if tt.stored != nil {
+				storedTime = tt.stored.UpdateTime
+				bs, err := vjson.Marshal(tt.stored)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := memStore.Put(ctx, entity.PartitionKey(1), bs); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var (
+				wg    sync.WaitGroup
+				mu    sync.Mutex
+				times []int64
+			)
+			for i := 0; i < tt.registers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					p := &entity.Partition{Id: 1, LeaderID: 1}
+					if err := ms.registerPartitionService(ctx, p); err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					times = append(times, p.UpdateTime)
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			latest := int64(0)
+			seen := make(map[int64]bool)
+			for _, ts := range times {
+				if ts <= storedTime {
+					t.Errorf("version %d is not newer than stored %d", ts, storedTime)
+				}
+				if seen[ts] {
+					t.Errorf("version %d registered twice", ts)
+				}
+				seen[ts] = true
+				if ts > latest {
+					latest = ts
+				}
+			}
+			got, err := ms.Master().QueryPartition(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.UpdateTime != latest {
+				t.Errorf("stored version %d, want the latest %d", got.UpdateTime, latest)
+			}
+		})
+	}
+}
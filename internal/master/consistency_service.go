@@ -0,0 +1,131 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// checkConsistencyService compares the replicas of the partitions one by one,
+// each replica blocks its writes while reading its documents for the checksum
+func (ms *masterService) checkConsistencyService(ctx context.Context, req *entity.ConsistencyCheckRequest) ([]*entity.PartitionConsistency, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	dbID, err := ms.Master().QueryDBName2Id(ctx, req.DbName)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbID, req.SpaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := space.Partitions
+	if len(req.PartitionIDs) > 0 {
+		partitions = make([]*entity.Partition, 0, len(req.PartitionIDs))
+		for _, pid := range req.PartitionIDs {
+			p := space.GetPartition(pid)
+			if p == nil {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_EXIST, fmt.Errorf("partition [%d] not in space [%s/%s]", pid, req.DbName, req.SpaceName))
+			}
+			partitions = append(partitions, p)
+		}
+	}
+
+	results := make([]*entity.PartitionConsistency, 0, len(partitions))
+	for _, p := range partitions {
+		pc := ms.checkPartitionConsistency(ctx, p)
+		if req.Repair && !pc.Consistent && pc.Error == "" {
+			ms.repairPartition(ctx, pc)
+		}
+		results = append(results, pc)
+	}
+	return results, nil
+}
+
+func (ms *masterService) checkPartitionConsistency(ctx context.Context, p *entity.Partition) *entity.PartitionConsistency {
+	pc := &entity.PartitionConsistency{PartitionID: p.Id}
+	partition, err := ms.Master().QueryPartition(ctx, p.Id)
+	if err != nil {
+		pc.Error = err.Error()
+		return pc
+	}
+	leader, err := ms.Master().QueryServer(ctx, partition.LeaderID)
+	if err != nil {
+		pc.Error = fmt.Sprintf("query leader [%d] err: %v", partition.LeaderID, err)
+		return pc
+	}
+	leaderChecksum, err := psClient.Checksum(leader.RpcAddr(), p.Id, 0)
+	if err != nil {
+		pc.Error = fmt.Sprintf("checksum on leader [%d] err: %v", partition.LeaderID, err)
+		return pc
+	}
+	pc.Index = leaderChecksum.Index
+	pc.Replicas = append(pc.Replicas, leaderChecksum)
+
+	for _, nodeID := range p.Replicas {
+		if nodeID == leaderChecksum.NodeID {
+			continue
+		}
+		checksum := &entity.ReplicaChecksum{PartitionID: p.Id, NodeID: nodeID, Index: pc.Index}
+		server, err := ms.Master().QueryServer(ctx, nodeID)
+		if err != nil {
+			checksum.Error = err.Error()
+		} else if c, err := psClient.Checksum(server.RpcAddr(), p.Id, pc.Index); err != nil {
+			checksum.Error = err.Error()
+		} else {
+			checksum = c
+		}
+		pc.Replicas = append(pc.Replicas, checksum)
+	}
+	pc.Compare()
+	if !pc.Consistent {
+		log.Warn("partition [%d] replicas diverge at index [%d], minority: %v", p.Id, pc.Index, pc.Minority)
+	}
+	return pc
+}
+
+// repairPartition removes the minority replicas and adds them back so they
+// copy the data of the leader, only when the leader is in the majority
+func (ms *masterService) repairPartition(ctx context.Context, pc *entity.PartitionConsistency) {
+	leader := pc.Replicas[0].NodeID
+	if len(pc.Minority)*2 >= len(pc.Replicas) {
+		pc.Error = "no majority of replicas agree, repair it manually"
+		return
+	}
+	for _, nodeID := range pc.Minority {
+		if nodeID == leader {
+			pc.Error = fmt.Sprintf("leader [%d] is in the minority, transfer the leader before repair", leader)
+			return
+		}
+	}
+	for _, nodeID := range pc.Minority {
+		for _, method := range []proto.ConfChangeType{proto.ConfRemoveNode, proto.ConfAddNode} {
+			if err := ms.ChangeMember(ctx, &entity.ChangeMember{PartitionID: pc.PartitionID, NodeID: nodeID, Method: method}); err != nil {
+				pc.Error = fmt.Sprintf("re-replicate replica [%d] err: %v", nodeID, err)
+				return
+			}
+		}
+		log.Info("partition [%d] replica [%d] re-replicated from leader [%d]", pc.PartitionID, nodeID, leader)
+		pc.Repaired = append(pc.Repaired, nodeID)
+	}
+}
@@ -0,0 +1,491 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	cronTickInterval = 30 * time.Second
+	// cronRunLockTTL frees the lock of a master crashed in a run, a run
+	// longer than it may overlap with the next one
+	cronRunLockTTL = 6 * time.Hour
+)
+
+// cronTask runs a job and returns the message kept in the history
+type cronTask func(ctx context.Context, job *entity.CronJob) (string, error)
+
+// cronScheduler runs the due jobs stored in etcd. A master runs a job only
+// after moving its next run in a transaction, so a scheduled run is taken
+// by one master, and a run is skipped while the former one holds the lock
+// of the job
+type cronScheduler struct {
+	ms     *masterService
+	ctx    context.Context
+	master string
+	tasks  map[string]cronTask
+}
+
+func newCronScheduler(ms *masterService) *cronScheduler {
+	cs := &cronScheduler{ms: ms, ctx: context.Background()}
+	cs.tasks = map[string]cronTask{
+		entity.CronTaskBackup:           cs.backup,
+		entity.CronTaskConsistencyCheck: cs.consistencyCheck,
+		entity.CronTaskEtcdMaintenance:  cs.etcdMaintenance,
+		entity.CronTaskOrphanCheck:      cs.orphanCheck,
+		entity.CronTaskUsageClean:       cs.usageClean,
+	}
+	return cs
+}
+
+func (cs *cronScheduler) start(ctx context.Context) {
+	cs.ctx = ctx
+	if self := config.Conf().Masters.Self(); self != nil {
+		cs.master = self.Name
+	}
+	go func() {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				log.Error("recover() err:[%v]", rErr)
+				log.Error("stack:[%s]", debug.Stack())
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("cron scheduler stopped")
+				return
+			case <-time.After(cronTickInterval):
+			}
+			cs.tick(ctx, time.Now())
+		}
+	}()
+}
+
+// tick starts the jobs due at now and claimed by this master
+func (cs *cronScheduler) tick(ctx context.Context, now time.Time) {
+	jobs, err := cs.ms.listCronJobsService(ctx)
+	if err != nil {
+		log.Error("list cron jobs err: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.Disabled || job.NextRun == 0 || job.NextRun > now.Unix() {
+			continue
+		}
+		claimed, err := cs.claim(ctx, job, now)
+		if err != nil {
+			log.Error("claim cron job [%s] err: %v", job.Name, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if run, mutex := cs.begin(ctx, job, entity.CronTriggerSchedule); mutex != nil {
+			go cs.execute(job, run, mutex)
+		}
+	}
+}
+
+// claim moves the next run of the job after now, false if another master
+// moved it since the job was read
+func (cs *cronScheduler) claim(ctx context.Context, job *entity.CronJob, now time.Time) (bool, error) {
+	claimed := false
+	err := cs.ms.Master().STM(ctx, func(stm store.STM) error {
+		claimed = false
+		value := stm.Get(entity.CronJobKey(job.Name))
+		if value == "" {
+			return nil
+		}
+		current := &entity.CronJob{}
+		if err := vjson.Unmarshal([]byte(value), current); err != nil {
+			return err
+		}
+		if current.NextRun != job.NextRun || current.Disabled {
+			return nil
+		}
+		current.NextRun = nextCronRun(current.Schedule, now)
+		bs, err := vjson.Marshal(current)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.CronJobKey(job.Name), string(bs))
+		claimed = true
+		return nil
+	})
+	return claimed, err
+}
+
+// begin records a run of the job, the run is skipped and no lock returned
+// if a former run is not finished
+func (cs *cronScheduler) begin(ctx context.Context, job *entity.CronJob, trigger string) (*entity.CronRun, store.Locker) {
+	now := time.Now()
+	run := &entity.CronRun{
+		Id:        now.UnixNano(),
+		Job:       job.Name,
+		Trigger:   trigger,
+		Status:    entity.CronRunRunning,
+		Master:    cs.master,
+		StartTime: now.Unix(),
+	}
+	mutex := cs.ms.Master().NewLock(ctx, entity.LockCronJobKey(job.Name), cronRunLockTTL)
+	if getLock, err := mutex.TryLock(); !getLock || err != nil {
+		run.Status = entity.CronRunSkipped
+		run.Message = "the former run is not finished"
+		run.FinishTime = now.Unix()
+		mutex = nil
+	}
+	cs.record(ctx, run, true)
+	return run, mutex
+}
+
+// execute runs the task of the job and releases the lock of the job
+func (cs *cronScheduler) execute(job *entity.CronJob, run *entity.CronRun, mutex store.Locker) {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			log.Error("recover() err:[%v]", rErr)
+			log.Error("stack:[%s]", debug.Stack())
+			run.Status, run.Message = entity.CronRunFailed, fmt.Sprintf("panic: %v", rErr)
+			run.FinishTime = time.Now().Unix()
+			cs.record(cs.ctx, run, false)
+		}
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock cron job [%s] err: %v", job.Name, err)
+		}
+	}()
+	log.Info("cron job [%s] run [%d] of task [%s] started by %s", job.Name, run.Id, job.Task, run.Trigger)
+	message, err := cs.tasks[job.Task](cs.ctx, job)
+	run.Status, run.Message = entity.CronRunSucceeded, message
+	if err != nil {
+		run.Status = entity.CronRunFailed
+		if message != "" {
+			run.Message = fmt.Sprintf("%s, err: %v", message, err)
+		} else {
+			run.Message = err.Error()
+		}
+		log.Error("cron job [%s] run [%d] failed: %s", job.Name, run.Id, run.Message)
+	} else {
+		log.Info("cron job [%s] run [%d] succeeded: %s", job.Name, run.Id, run.Message)
+	}
+	run.FinishTime = time.Now().Unix()
+	cs.record(cs.ctx, run, false)
+}
+
+// record saves the run, the oldest runs beyond entity.MaxCronRuns are
+// deleted when a run is added
+func (cs *cronScheduler) record(ctx context.Context, run *entity.CronRun, added bool) {
+	bs, err := vjson.Marshal(run)
+	if err != nil {
+		log.Error("marshal cron run err: %v", err)
+		return
+	}
+	if err := cs.ms.Master().Put(ctx, entity.CronRunKey(run.Job, run.Id), bs); err != nil {
+		log.Error("save cron job [%s] run [%d] err: %v", run.Job, run.Id, err)
+		return
+	}
+	if !added {
+		return
+	}
+	keys, _, err := cs.ms.Master().PrefixScan(ctx, entity.CronRunPrefix(run.Job))
+	if err != nil {
+		log.Error("scan cron job [%s] runs err: %v", run.Job, err)
+		return
+	}
+	for i := 0; i < len(keys)-entity.MaxCronRuns; i++ {
+		if err := cs.ms.Master().Delete(ctx, string(keys[i])); err != nil {
+			log.Error("delete cron run [%s] err: %v", string(keys[i]), err)
+		}
+	}
+}
+
+// nextCronRun returns the unix time of the run after now, 0 if none
+func nextCronRun(schedule string, now time.Time) int64 {
+	s, err := entity.ParseCronSchedule(schedule)
+	if err != nil {
+		return 0
+	}
+	next := s.Next(now)
+	if next.IsZero() {
+		return 0
+	}
+	return next.Unix()
+}
+
+func (cs *cronScheduler) backup(ctx context.Context, job *entity.CronJob) (string, error) {
+	params := &entity.CronBackupParams{}
+	if err := vjson.Unmarshal(job.Params, params); err != nil {
+		return "", err
+	}
+	names := []string{params.SpaceName}
+	if params.SpaceName == "" {
+		dbID, err := cs.ms.Master().QueryDBName2Id(ctx, params.DbName)
+		if err != nil {
+			return "", err
+		}
+		spaces, err := cs.ms.Master().QuerySpaces(ctx, dbID)
+		if err != nil {
+			return "", err
+		}
+		names = names[:0]
+		for _, space := range spaces {
+			names = append(names, space.Name)
+		}
+	}
+	for i, name := range names {
+		backup := &entity.BackupSpace{Command: "create", S3Param: params.S3Param}
+		if err := cs.ms.BackupSpace(ctx, params.DbName, name, backup); err != nil {
+			return fmt.Sprintf("backed up %d of %d spaces of db %s", i, len(names), params.DbName), fmt.Errorf("backup space %s err: %v", name, err)
+		}
+	}
+	return fmt.Sprintf("backed up %d spaces of db %s", len(names), params.DbName), nil
+}
+
+func (cs *cronScheduler) consistencyCheck(ctx context.Context, job *entity.CronJob) (string, error) {
+	req := &entity.ConsistencyCheckRequest{}
+	if err := vjson.Unmarshal(job.Params, req); err != nil {
+		return "", err
+	}
+	results, err := cs.ms.checkConsistencyService(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	inconsistent, failed := 0, 0
+	for _, pc := range results {
+		if pc.Error != "" {
+			failed++
+		} else if !pc.Consistent && len(pc.Repaired) == 0 {
+			inconsistent++
+		}
+	}
+	message := fmt.Sprintf("checked %d partitions of space %s/%s", len(results), req.DbName, req.SpaceName)
+	if inconsistent > 0 || failed > 0 {
+		return message, fmt.Errorf("%d partitions inconsistent, %d partitions failed to check", inconsistent, failed)
+	}
+	return message, nil
+}
+
+// etcdMaintenance compacts and defragments etcd by the maintenance config
+// whether the periodic maintenance is enabled or not
+func (cs *cronScheduler) etcdMaintenance(ctx context.Context, job *entity.CronJob) (string, error) {
+	cfg, err := cs.ms.getEtcdMaintenanceConfigService(ctx)
+	if err != nil {
+		return "", err
+	}
+	mutex := cs.ms.Master().NewLock(ctx, entity.ClusterEtcdMaintenanceKey, etcdMaintenanceLockTTL)
+	if getLock, err := mutex.TryLock(); !getLock || err != nil {
+		return "", fmt.Errorf("etcd is being maintained")
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock etcd maintenance err: %v", err)
+		}
+	}()
+	if err := cs.ms.etcd.maintain(ctx, cfg); err != nil {
+		return "", err
+	}
+	return "etcd maintained", nil
+}
+
+func (cs *cronScheduler) orphanCheck(ctx context.Context, job *entity.CronJob) (string, error) {
+	report, err := cs.ms.orphanPartitionsService(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("found %d orphan partitions", len(report.Orphans)), nil
+}
+
+func (cs *cronScheduler) usageClean(ctx context.Context, job *entity.CronJob) (string, error) {
+	cs.ms.cleanUsage(ctx)
+	return "expired usage rollups deleted", nil
+}
+
+// putCronJobService creates the cron job, or updates it when update is set,
+// the next run is computed from now either way
+func (ms *masterService) putCronJobService(ctx context.Context, job *entity.CronJob, update bool) (err error) {
+	if err = job.Validate(); err != nil {
+		return err
+	}
+	now := time.Now()
+	job.NextRun = nextCronRun(job.Schedule, now)
+	err = ms.Master().STM(ctx, func(stm store.STM) error {
+		key := entity.CronJobKey(job.Name)
+		value := stm.Get(key)
+		if value != "" && !update {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job %s is exists", job.Name))
+		}
+		if value == "" && update {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job %s not exists", job.Name))
+		}
+		job.CreateTime, job.UpdateTime = now.Unix(), 0
+		if update {
+			old := &entity.CronJob{}
+			if err := vjson.Unmarshal([]byte(value), old); err != nil {
+				return err
+			}
+			job.CreateTime, job.UpdateTime = old.CreateTime, now.Unix()
+		}
+		bs, err := vjson.Marshal(job)
+		if err != nil {
+			return err
+		}
+		stm.Put(key, string(bs))
+		return nil
+	})
+	if err == nil {
+		log.Info("put cron job [%s] of task [%s] scheduled %q, next run at %d", job.Name, job.Task, job.Schedule, job.NextRun)
+	}
+	return err
+}
+
+// deleteCronJobService deletes the job and its history, a run in progress
+// goes on
+func (ms *masterService) deleteCronJobService(ctx context.Context, name string) error {
+	if _, err := ms.getCronJobService(ctx, name); err != nil {
+		return err
+	}
+	if err := ms.Master().Delete(ctx, entity.CronJobKey(name)); err != nil {
+		return err
+	}
+	keys, _, err := ms.Master().PrefixScan(ctx, entity.CronRunPrefix(name))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ms.Master().Delete(ctx, string(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *masterService) getCronJobService(ctx context.Context, name string) (*entity.CronJob, error) {
+	bs, err := ms.Master().Get(ctx, entity.CronJobKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job %s not exists", name))
+	}
+	job := &entity.CronJob{}
+	if err := vjson.Unmarshal(bs, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (ms *masterService) listCronJobsService(ctx context.Context) ([]*entity.CronJob, error) {
+	_, values, err := ms.Master().PrefixScan(ctx, entity.PrefixCronJob)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*entity.CronJob, 0, len(values))
+	for _, value := range values {
+		job := &entity.CronJob{}
+		if err := vjson.Unmarshal(value, job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// listCronRunsService returns the history of the job, the latest run first
+func (ms *masterService) listCronRunsService(ctx context.Context, name string) ([]*entity.CronRun, error) {
+	if _, err := ms.getCronJobService(ctx, name); err != nil {
+		return nil, err
+	}
+	_, values, err := ms.Master().PrefixScan(ctx, entity.CronRunPrefix(name))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*entity.CronRun, 0, len(values))
+	for _, value := range values {
+		run := &entity.CronRun{}
+		if err := vjson.Unmarshal(value, run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Id > runs[j].Id })
+	return runs, nil
+}
+
+// triggerCronJobService runs the job now in background whether it is
+// disabled or not, the scheduled runs are not changed
+func (ms *masterService) triggerCronJobService(ctx context.Context, name string) (*entity.CronRun, error) {
+	job, err := ms.getCronJobService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	run, mutex := ms.cron.begin(ctx, job, entity.CronTriggerManual)
+	if mutex != nil {
+		go ms.cron.execute(job, run, mutex)
+	}
+	return run, nil
+}
+
+// skipCronJobService skips the next scheduled run of the job and records it
+// in the history
+func (ms *masterService) skipCronJobService(ctx context.Context, name string) (*entity.CronJob, error) {
+	job := &entity.CronJob{}
+	skipped := int64(0)
+	err := ms.Master().STM(ctx, func(stm store.STM) error {
+		value := stm.Get(entity.CronJobKey(name))
+		if value == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job %s not exists", name))
+		}
+		if err := vjson.Unmarshal([]byte(value), job); err != nil {
+			return err
+		}
+		if job.NextRun == 0 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cron job %s has no next run", name))
+		}
+		skipped = job.NextRun
+		job.NextRun = nextCronRun(job.Schedule, time.Unix(job.NextRun, 0))
+		bs, err := vjson.Marshal(job)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.CronJobKey(name), string(bs))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ms.cron.record(ctx, &entity.CronRun{
+		Id:         now.UnixNano(),
+		Job:        name,
+		Trigger:    entity.CronTriggerManual,
+		Status:     entity.CronRunSkipped,
+		Message:    fmt.Sprintf("run at %s skipped", time.Unix(skipped, 0).UTC().Format(time.RFC3339)),
+		StartTime:  now.Unix(),
+		FinishTime: now.Unix(),
+	}, true)
+	log.Info("cron job [%s] run at %d skipped, next run at %d", name, skipped, job.NextRun)
+	return job, nil
+}
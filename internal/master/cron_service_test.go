@@ -0,0 +1,125 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+)
+
+func TestCronScheduler(t *testing.T) {
+	ctx := context.Background()
+	cli, err := client.NewClientWithStore(nil, store.NewMemStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newMasterService(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := make(chan string, 10)
+	ms.cron.tasks[entity.CronTaskUsageClean] = func(ctx context.Context, job *entity.CronJob) (string, error) {
+		ran <- job.Name
+		return "done", nil
+	}
+	wait := func() {
+		select {
+		case <-ran:
+		case <-time.After(5 * time.Second):
+			t.Fatal("cron job not run")
+		}
+		// the run is recorded after the task returns
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	job := &entity.CronJob{Name: "usage", Task: entity.CronTaskUsageClean, Schedule: "@hourly"}
+	if err := ms.putCronJobService(ctx, job, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.putCronJobService(ctx, job, false); err == nil {
+		t.Fatal("put an existing job should fail")
+	}
+	first := job.NextRun
+
+	ms.cron.tick(ctx, time.Unix(first-1, 0))
+	if runs, _ := ms.listCronRunsService(ctx, "usage"); len(runs) != 0 {
+		t.Fatalf("job not due should not run, got %d runs", len(runs))
+	}
+
+	due := time.Unix(first, 0)
+	ms.cron.tick(ctx, due)
+	wait()
+	ms.cron.tick(ctx, due)
+	runs, err := ms.listCronRunsService(ctx, "usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != entity.CronRunSucceeded || runs[0].Trigger != entity.CronTriggerSchedule || runs[0].Message != "done" {
+		t.Fatalf("want a succeeded scheduled run, got %+v", runs)
+	}
+	if job, _ = ms.getCronJobService(ctx, "usage"); job.NextRun != first+3600 {
+		t.Fatalf("next run = %d, want %d", job.NextRun, first+3600)
+	}
+
+	// a run is skipped while the former one holds the lock
+	mutex := ms.Master().NewLock(ctx, entity.LockCronJobKey("usage"), time.Minute)
+	if ok, err := mutex.TryLock(); !ok || err != nil {
+		t.Fatal("lock cron job failed")
+	}
+	run, err := ms.triggerCronJobService(ctx, "usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != entity.CronRunSkipped || run.Trigger != entity.CronTriggerManual {
+		t.Fatalf("want a skipped manual run, got %+v", run)
+	}
+	if err := mutex.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if run, err = ms.triggerCronJobService(ctx, "usage"); err != nil || run.Status != entity.CronRunRunning {
+		t.Fatalf("want a running manual run, got %+v, err %v", run, err)
+	}
+	wait()
+
+	if job, err = ms.skipCronJobService(ctx, "usage"); err != nil {
+		t.Fatal(err)
+	}
+	if job.NextRun != first+2*3600 {
+		t.Fatalf("next run after skip = %d, want %d", job.NextRun, first+2*3600)
+	}
+	runs, _ = ms.listCronRunsService(ctx, "usage")
+	if len(runs) != 4 || runs[0].Status != entity.CronRunSkipped || runs[1].Status != entity.CronRunSucceeded {
+		t.Fatalf("want 4 runs with the skip first, got %+v", runs)
+	}
+
+	for i := 0; i < entity.MaxCronRuns; i++ {
+		ms.cron.record(ctx, &entity.CronRun{Id: time.Now().UnixNano() + int64(i), Job: "usage", Status: entity.CronRunSkipped}, true)
+	}
+	if runs, _ = ms.listCronRunsService(ctx, "usage"); len(runs) != entity.MaxCronRuns {
+		t.Fatalf("history should keep %d runs, got %d", entity.MaxCronRuns, len(runs))
+	}
+
+	if err := ms.deleteCronJobService(ctx, "usage"); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _, _ := ms.Master().PrefixScan(ctx, entity.CronRunPrefix("usage")); len(keys) != 0 {
+		t.Fatalf("runs of deleted job should be deleted, got %d", len(keys))
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// createEmbeddingMigrationService adds the target vector field to the space
+// and saves the migration for routers to run
+func (ms *masterService) createEmbeddingMigrationService(ctx context.Context, m *entity.EmbeddingMigration) (*entity.EmbeddingMigration, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if old, err := ms.Master().Get(ctx, entity.EmbeddingMigrationKey(m.Name)); err != nil {
+		return nil, err
+	} else if old != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedding migration %s exists", m.Name))
+	}
+
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(m.DbName, m.SpaceName), time.Second*300)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+
+	dbId, err := ms.Master().QueryDBName2Id(ctx, m.DbName)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, m.SpaceName)
+	if err != nil {
+		return nil, err
+	}
+	proMap, err := entity.UnmarshalPropertyJSON(space.Fields)
+	if err != nil {
+		return nil, err
+	}
+	if source := proMap[m.SourceField]; source == nil || source.FieldType != vearchpb.FieldType_STRING {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("source_field %s should be a string field of space %s", m.SourceField, m.SpaceName))
+	}
+	if proMap[m.Target.Name] != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("target field %s exists in space %s", m.Target.Name, m.SpaceName))
+	}
+
+	fields := make([]json.RawMessage, 0, len(proMap)+1)
+	if err := vjson.Unmarshal(space.Fields, &fields); err != nil {
+		return nil, err
+	}
+	target, err := vjson.Marshal(m.Target)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := vjson.Marshal(append(fields, target))
+	if err != nil {
+		return nil, err
+	}
+	if space.SpaceProperties, err = entity.UnmarshalPropertyJSON(schema); err != nil {
+		return nil, err
+	}
+	space.Fields = schema
+
+	for _, p := range space.Partitions {
+		partition, err := ms.Master().QueryPartition(ctx, p.Id)
+		if err != nil {
+			return nil, err
+		}
+		server, err := ms.Master().QueryServer(ctx, partition.LeaderID)
+		if err != nil {
+			return nil, err
+		}
+		if err := psClient.UpdatePartition(server.RpcAddr(), space, p.Id); err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("add field %s to partition %d err: %v", m.Target.Name, p.Id, err))
+		}
+	}
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+
+	m.Status = entity.MigrationRunning
+	m.Partition, m.Cursor = 0, -1
+	m.Embedded, m.Skipped, m.Error, m.Runner = 0, 0, "", ""
+	m.StartTime, m.UpdateTime, m.FinishTime = time.Now().Unix(), 0, 0
+	bs, err := vjson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := ms.Master().Put(ctx, entity.EmbeddingMigrationKey(m.Name), bs); err != nil {
+		return nil, err
+	}
+	log.Info("create embedding migration %s of space %s/%s, %s -> %s by %s", m.Name, m.DbName, m.SpaceName, m.SourceField, m.Target.Name, m.Embedder)
+	return m, nil
+}
+
+func (ms *masterService) getEmbeddingMigrationService(ctx context.Context, name string) (*entity.EmbeddingMigration, error) {
+	bs, err := ms.Master().Get(ctx, entity.EmbeddingMigrationKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedding migration %s not found", name))
+	}
+	m := &entity.EmbeddingMigration{}
+	if err := vjson.Unmarshal(bs, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (ms *masterService) listEmbeddingMigrationsService(ctx context.Context) ([]*entity.EmbeddingMigration, error) {
+	_, values, err := ms.Master().PrefixScan(ctx, entity.PrefixEmbeddingMigration)
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]*entity.EmbeddingMigration, 0, len(values))
+	for _, value := range values {
+		m := &entity.EmbeddingMigration{}
+		if err := vjson.Unmarshal(value, m); err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].StartTime > migrations[j].StartTime })
+	return migrations, nil
+}
+
+// updateEmbeddingMigration applies fn to the running migration
+func (ms *masterService) updateEmbeddingMigration(ctx context.Context, name string, fn func(m *entity.EmbeddingMigration) error) (*entity.EmbeddingMigration, error) {
+	m := &entity.EmbeddingMigration{}
+	err := ms.Master().STM(ctx, func(stm store.STM) error {
+		value := stm.Get(entity.EmbeddingMigrationKey(name))
+		if value == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedding migration %s not found", name))
+		}
+		if err := vjson.Unmarshal([]byte(value), m); err != nil {
+			return err
+		}
+		if m.Finished() {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedding migration %s is %s", name, m.Status))
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+		bs, err := vjson.Marshal(m)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.EmbeddingMigrationKey(name), string(bs))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// cancelEmbeddingMigrationService stops the migration, the target field is
+// kept with the documents embedded
+func (ms *masterService) cancelEmbeddingMigrationService(ctx context.Context, name string) (*entity.EmbeddingMigration, error) {
+	return ms.updateEmbeddingMigration(ctx, name, func(m *entity.EmbeddingMigration) error {
+		m.Status = entity.MigrationCanceled
+		m.FinishTime = time.Now().Unix()
+		return nil
+	})
+}
+
+// completeEmbeddingMigrationService switches the default vector field of the
+// space to the target once the runner embedded all partitions
+func (ms *masterService) completeEmbeddingMigrationService(ctx context.Context, name string) (*entity.EmbeddingMigration, error) {
+	m, err := ms.getEmbeddingMigrationService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if m.Finished() {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedding migration %s is %s", name, m.Status))
+	}
+
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(m.DbName, m.SpaceName), time.Second*30)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("failed to unlock space,the Error is:%v ", err)
+		}
+	}()
+	dbId, err := ms.Master().QueryDBName2Id(ctx, m.DbName)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, m.SpaceName)
+	if err != nil {
+		return nil, err
+	}
+	if m.Partition < len(space.Partitions) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedding migration %s is at partition %d of %d", name, m.Partition, len(space.Partitions)))
+	}
+	space.DefaultVectorField = m.Target.Name
+	if err := ms.updateSpace(ctx, space); err != nil {
+		return nil, err
+	}
+
+	m, err = ms.updateEmbeddingMigration(ctx, name, func(m *entity.EmbeddingMigration) error {
+		m.Status = entity.MigrationDone
+		m.FinishTime = time.Now().Unix()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("embedding migration %s done, default vector field of space %s/%s is %s", name, m.DbName, m.SpaceName, m.Target.Name)
+	return m, nil
+}
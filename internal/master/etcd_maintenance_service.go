@@ -0,0 +1,248 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	etcdMaintenanceSettingsName = "etcd_maintenance"
+	// etcdMaintenanceLockTTL frees the lock of a master crashed in maintenance,
+	// it is longer than a maintenance of all members
+	etcdMaintenanceLockTTL = 30 * time.Minute
+)
+
+// etcdMaintainer compacts the etcd revisions accumulated by heartbeats and
+// defragments the members periodically, a member at a time
+type etcdMaintainer struct {
+	ms *masterService
+}
+
+type etcdMember struct {
+	endpoint string
+	status   *clientv3.StatusResponse
+}
+
+func newEtcdMaintainer(ms *masterService) *etcdMaintainer {
+	return &etcdMaintainer{ms: ms}
+}
+
+func (em *etcdMaintainer) start(ctx context.Context) {
+	go func() {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				log.Error("recover() err:[%v]", rErr)
+				log.Error("stack:[%s]", debug.Stack())
+			}
+		}()
+		interval := int64(entity.DefaultEtcdMaintenanceInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("etcd maintainer stopped")
+				return
+			case <-time.After(time.Duration(interval) * time.Second):
+			}
+
+			cfg, err := em.ms.getEtcdMaintenanceConfigService(ctx)
+			if err != nil {
+				log.Error("get etcd maintenance config err: %v", err)
+				continue
+			}
+			interval = cfg.Interval
+			if !cfg.Enabled {
+				continue
+			}
+			em.round(ctx, cfg, time.Now())
+		}
+	}()
+}
+
+// round maintains etcd if no other master is maintaining or has maintained
+// it in the interval before now
+func (em *etcdMaintainer) round(ctx context.Context, cfg *entity.EtcdMaintenanceConfig, now time.Time) {
+	mutex := em.ms.Master().NewLock(ctx, entity.ClusterEtcdMaintenanceKey, etcdMaintenanceLockTTL)
+	if getLock, err := mutex.TryLock(); !getLock || err != nil {
+		return
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock etcd maintenance err: %v", err)
+		}
+	}()
+
+	due, err := em.claim(ctx, cfg.Interval, now)
+	if err != nil {
+		log.Error("claim etcd maintenance err: %v", err)
+		return
+	}
+	if !due {
+		return
+	}
+	if err := em.maintain(ctx, cfg); err != nil {
+		log.Error("etcd maintenance err: %v", err)
+	}
+}
+
+// claim sets the next maintenance an interval after now, false if the next
+// maintenance set by a master is after now
+func (em *etcdMaintainer) claim(ctx context.Context, interval int64, now time.Time) (bool, error) {
+	due := false
+	err := em.ms.Master().STM(ctx, func(stm store.STM) error {
+		due = false
+		if next := stm.Get(entity.ClusterEtcdMaintenanceNextKey); next != "" && now.Unix() < cast.ToInt64(next) {
+			return nil
+		}
+		stm.Put(entity.ClusterEtcdMaintenanceNextKey, strconv.FormatInt(now.Unix()+interval, 10))
+		due = true
+		return nil
+	})
+	return due, err
+}
+
+// maintain compacts and defragments only when all members are healthy,
+// followers before the leader and stops at the first member not healthy after
+// its defragmentation
+func (em *etcdMaintainer) maintain(ctx context.Context, cfg *entity.EtcdMaintenanceConfig) error {
+	members, err := em.members(ctx)
+	if err != nil {
+		return err
+	}
+
+	var leader *etcdMember
+	rev := int64(0)
+	for _, m := range members {
+		if m.status.Header.Revision > rev {
+			rev = m.status.Header.Revision
+		}
+		if m.status.Leader == m.status.Header.MemberId {
+			leader = m
+		}
+	}
+	if leader == nil {
+		return fmt.Errorf("etcd has no leader, skip maintenance")
+	}
+
+	if compactRev := rev - cfg.RetainRevisions; compactRev > 0 {
+		if err := em.ms.Master().Compact(ctx, compactRev); err != nil {
+			// compacted by another round or etcd auto compaction
+			log.Warn("etcd compact to revision [%d] err: %v", compactRev, err)
+		} else {
+			log.Info("etcd compacted to revision [%d], current revision [%d]", compactRev, rev)
+		}
+	}
+
+	ordered := make([]*etcdMember, 0, len(members))
+	for _, m := range members {
+		if m != leader {
+			ordered = append(ordered, m)
+		}
+	}
+	ordered = append(ordered, leader)
+
+	defragmented := 0
+	for _, m := range ordered {
+		// compaction changes the size in use, so read it again
+		status, err := em.ms.Master().EndpointStatus(ctx, m.endpoint)
+		if err != nil {
+			return fmt.Errorf("etcd member [%s] status err: %v, stop defragmentation", m.endpoint, err)
+		}
+		if !cfg.NeedDefrag(status.DbSize, status.DbSizeInUse) {
+			continue
+		}
+		if defragmented > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Duration(cfg.DefragPause) * time.Second):
+			}
+		}
+		start := time.Now()
+		if err := em.ms.Master().Defragment(ctx, m.endpoint); err != nil {
+			return fmt.Errorf("etcd member [%s] defragment err: %v, stop defragmentation", m.endpoint, err)
+		}
+		defragmented++
+		after, err := em.ms.Master().EndpointStatus(ctx, m.endpoint)
+		if err != nil || len(after.Errors) > 0 {
+			return fmt.Errorf("etcd member [%s] not healthy after defragment, err: %v, stop defragmentation", m.endpoint, err)
+		}
+		log.Info("etcd member [%s] defragmented in %v, db size [%d] to [%d]", m.endpoint, time.Since(start), status.DbSize, after.DbSize)
+	}
+	return nil
+}
+
+// members returns the status of all members, an error if any is not healthy
+func (em *etcdMaintainer) members(ctx context.Context) ([]*etcdMember, error) {
+	list, err := em.ms.Master().MemberList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*etcdMember, 0, len(list.Members))
+	for _, member := range list.Members {
+		if member.IsLearner || len(member.ClientURLs) == 0 {
+			return nil, fmt.Errorf("etcd member [%s] is not started or a learner, skip maintenance", member.Name)
+		}
+		endpoint := member.ClientURLs[0]
+		requestCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		status, err := em.ms.Master().EndpointStatus(requestCtx, endpoint)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("etcd member [%s] status err: %v, skip maintenance", endpoint, err)
+		}
+		if len(status.Errors) > 0 {
+			return nil, fmt.Errorf("etcd member [%s] has errors %v, skip maintenance", endpoint, status.Errors)
+		}
+		members = append(members, &etcdMember{endpoint: endpoint, status: status})
+	}
+	return members, nil
+}
+
+func (ms *masterService) getEtcdMaintenanceConfigService(ctx context.Context) (*entity.EtcdMaintenanceConfig, error) {
+	cfg := entity.NewEtcdMaintenanceConfig()
+	bs, err := ms.Master().Get(ctx, entity.SettingsKey(etcdMaintenanceSettingsName))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return cfg, nil
+	}
+	if err := vjson.Unmarshal(bs, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (ms *masterService) updateEtcdMaintenanceConfigService(ctx context.Context, cfg *entity.EtcdMaintenanceConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	marshal, err := vjson.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return ms.Master().Put(ctx, entity.SettingsKey(etcdMaintenanceSettingsName), marshal)
+}
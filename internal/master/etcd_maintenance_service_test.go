@@ -0,0 +1,194 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// maintenanceStore is a memory store with scripted etcd members
+type maintenanceStore struct {
+	*store.MemStore
+	leader    uint64
+	rev       int64
+	sizes     map[string][2]int64 // endpoint -> db size, size in use
+	unhealthy map[string]bool
+	compacted int64
+	defrags   []string
+}
+
+func (s *maintenanceStore) MemberList(ctx context.Context) (*clientv3.MemberListResponse, error) {
+	resp := &clientv3.MemberListResponse{}
+	for i := 1; i <= len(s.sizes); i++ {
+		resp.Members = append(resp.Members, &etcdserverpb.Member{ID: uint64(i), Name: fmt.Sprintf("m%d", i), ClientURLs: []string{fmt.Sprintf("m%d", i)}})
+	}
+	return resp, nil
+}
+
+func (s *maintenanceStore) EndpointStatus(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error) {
+	var id uint64
+	fmt.Sscanf(endpoint, "m%d", &id)
+	status := &clientv3.StatusResponse{
+		Header:      &etcdserverpb.ResponseHeader{MemberId: id, Revision: s.rev},
+		Leader:      s.leader,
+		DbSize:      s.sizes[endpoint][0],
+		DbSizeInUse: s.sizes[endpoint][1],
+	}
+	if s.unhealthy[endpoint] {
+		status.Errors = []string{"alarm"}
+	}
+	return status, nil
+}
+
+func (s *maintenanceStore) Compact(ctx context.Context, rev int64) error {
+	s.compacted = rev
+	return nil
+}
+
+func (s *maintenanceStore) Defragment(ctx context.Context, endpoint string) error {
+	s.defrags = append(s.defrags, endpoint)
+	s.sizes[endpoint] = [2]int64{s.sizes[endpoint][1], s.sizes[endpoint][1]}
+	return nil
+}
+
+func newMaintenanceStore() *maintenanceStore {
+	const mb = 1024 * 1024
+	return &maintenanceStore{
+		MemStore: store.NewMemStore(),
+		leader:   1,
+		rev:      50000,
+		sizes: map[string][2]int64{
+			"m1": {200 * mb, 100 * mb},
+			"m2": {200 * mb, 100 * mb},
+			"m3": {100 * mb, 100 * mb},
+		},
+		unhealthy: make(map[string]bool),
+	}
+}
+
+func newMaintainer(t *testing.T, s store.Store) *etcdMaintainer {
+	cli, err := client.NewClientWithStore(nil, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newMasterService(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ms.etcd
+}
+
+func TestEtcdMaintainer_maintain(t *testing.T) {
+	cfg := &entity.EtcdMaintenanceConfig{Enabled: true, Interval: 60, RetainRevisions: 10000, DefragRatio: 1.5, DefragMinSize: 64}
+	tests := []struct {
+		name          string
+		update        func(s *maintenanceStore)
+		wantErr       bool
+		wantCompacted int64
+		wantDefrags   []string
+	}{
+		{
+			name:          "Compact and defragment fragmented members, leader last",
+			update:        func(s *maintenanceStore) {},
+			wantCompacted: 40000,
+			wantDefrags:   []string{"m2", "m1"},
+		},
+		{
+			name:          "Few revisions are not compacted",
+			update:        func(s *maintenanceStore) { s.rev = 5000 },
+			wantCompacted: 0,
+			wantDefrags:   []string{"m2", "m1"},
+		},
+		{
+			name:    "Unhealthy member skips maintenance",
+			update:  func(s *maintenanceStore) { s.unhealthy["m3"] = true },
+			wantErr: true,
+		},
+		{
+			name:    "No leader skips maintenance",
+			update:  func(s *maintenanceStore) { s.leader = 9 },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMaintenanceStore()
+			tt.update(s)
+			err := newMaintainer(t, s).maintain(context.Background(), cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("maintain() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if s.compacted != tt.wantCompacted {
+				t.Errorf("compacted to %d, want %d", s.compacted, tt.wantCompacted)
+			}
+			if !reflect.DeepEqual(s.defrags, tt.wantDefrags) {
+				t.Errorf("defragmented %v, want %v", s.defrags, tt.wantDefrags)
+			}
+		})
+	}
+}
+
+func TestEtcdMaintainer_round(t *testing.T) {
+	ctx := context.Background()
+	cfg := &entity.EtcdMaintenanceConfig{Enabled: true, Interval: 60, RetainRevisions: 10000, DefragRatio: 1.5, DefragMinSize: 64}
+	s := newMaintenanceStore()
+	em := newMaintainer(t, s)
+	start := time.Now()
+
+	steps := []struct {
+		name          string
+		now           time.Time
+		held          bool
+		wantCompacted bool
+	}{
+		{name: "First round maintains", now: start, wantCompacted: true},
+		{name: "Round in interval is skipped", now: start.Add(30 * time.Second)},
+		{name: "Round held by another master is skipped", now: start.Add(2 * time.Minute), held: true},
+		{name: "Round after interval maintains", now: start.Add(2 * time.Minute), wantCompacted: true},
+	}
+	for _, step := range steps {
+		s.compacted = 0
+		var other store.Locker
+		if step.held {
+			other = s.NewLock(ctx, entity.ClusterEtcdMaintenanceKey, time.Minute)
+			if ok, err := other.TryLock(); !ok || err != nil {
+				t.Fatalf("%s: lock err: %v", step.name, err)
+			}
+		}
+		em.round(ctx, cfg, step.now)
+		if (s.compacted != 0) != step.wantCompacted {
+			t.Errorf("%s: compacted %d, want compacted %v", step.name, s.compacted, step.wantCompacted)
+		}
+		if other != nil {
+			other.Unlock()
+		}
+		// the lock is released by the round
+		lock := s.NewLock(ctx, entity.ClusterEtcdMaintenanceKey, time.Minute)
+		if ok, err := lock.TryLock(); !ok || err != nil {
+			t.Fatalf("%s: lock not released: %v", step.name, err)
+		}
+		lock.Unlock()
+	}
+}
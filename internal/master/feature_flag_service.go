@@ -0,0 +1,64 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// putFeatureFlagService creates or replaces the feature flag, routers watch
+// the flags so a rollout or rollback applies without redeploying them
+func (ms *masterService) putFeatureFlagService(ctx context.Context, flag *entity.FeatureFlag) error {
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+	marshal, err := vjson.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	log.Info("put feature flag %s: enabled %v, percent %.2f, spaces %v", flag.Name, flag.Enabled, flag.Share(), flag.Spaces)
+	return ms.Master().Put(ctx, entity.FeatureFlagKey(flag.Name), marshal)
+}
+
+// deleteFeatureFlagService deletes the feature flag, the behavior then runs
+// as configured on routers
+func (ms *masterService) deleteFeatureFlagService(ctx context.Context, name string) error {
+	if _, err := ms.queryFeatureFlagService(ctx, name); err != nil {
+		return err
+	}
+	return ms.Master().Delete(ctx, entity.FeatureFlagKey(name))
+}
+
+func (ms *masterService) queryFeatureFlagService(ctx context.Context, name string) (*entity.FeatureFlag, error) {
+	bs, err := ms.Master().Get(ctx, entity.FeatureFlagKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("feature flag %s not exists", name))
+	}
+	flag := &entity.FeatureFlag{}
+	if err = vjson.Unmarshal(bs, flag); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get feature flag:%s value:%s, err:%s", name, redact.Payload(bs), err.Error()))
+	}
+	return flag, nil
+}
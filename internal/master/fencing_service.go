@@ -0,0 +1,84 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/hlc"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+// fencingTokenService renews or issues the fencing tokens of the partitions
+// of req led by req.NodeID in etcd, or releases them, in one transaction for
+// all the partitions of a node. Partitions led by other nodes are skipped, so
+// their leaders stop writing when the tokens they hold expire
+func (ms *masterService) fencingTokenService(ctx context.Context, req *entity.FencingRequest) ([]*entity.FencingToken, error) {
+	var tokens []*entity.FencingToken
+	err := ms.Master().STM(ctx, func(stm store.STM) error {
+		tokens = make([]*entity.FencingToken, 0, len(req.PartitionIDs))
+		now := hlc.Now()
+		for _, pid := range req.PartitionIDs {
+			var old *entity.FencingToken
+			if value := stm.Get(entity.FencingTokenKey(pid)); value != "" {
+				old = &entity.FencingToken{}
+				if err := vjson.Unmarshal([]byte(value), old); err != nil {
+					return err
+				}
+			}
+			var token *entity.FencingToken
+			if req.Release {
+				if old == nil || old.LeaderID != req.NodeID {
+					continue
+				}
+				token = old
+				token.Release(now)
+			} else {
+				value := stm.Get(entity.PartitionKey(pid))
+				if value == "" {
+					continue
+				}
+				partition := &entity.Partition{}
+				if err := vjson.Unmarshal([]byte(value), partition); err != nil {
+					return err
+				}
+				if partition.LeaderID != req.NodeID {
+					continue
+				}
+				token = entity.NextFencingToken(old, pid, req.NodeID, now, entity.FencingTTL)
+			}
+			bs, err := vjson.Marshal(token)
+			if err != nil {
+				return err
+			}
+			stm.Put(entity.FencingTokenKey(pid), string(bs))
+			tokens = append(tokens, token)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if !req.Release && token.NotBefore > token.Issued {
+			log.Info("issue fencing token of epoch [%d] of partition [%d] to node [%d], valid after %s", token.Epoch, token.PartitionID, req.NodeID, hlc.Physical(token.NotBefore).Format(time.RFC3339Nano))
+		}
+	}
+	return tokens, nil
+}
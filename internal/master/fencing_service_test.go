@@ -0,0 +1,117 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/hlc"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+func TestFencingTokenService(t *testing.T) {
+	ctx := context.Background()
+	memStore := store.NewMemStore()
+	cli, err := client.NewClientWithStore(nil, memStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newMasterService(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setLeader := func(pid entity.PartitionID, leader entity.NodeID) {
+		bs, err := vjson.Marshal(&entity.Partition{Id: pid, LeaderID: leader})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := memStore.Put(ctx, entity.PartitionKey(pid), bs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setLeader(1, 1)
+	setLeader(2, 2)
+
+	steps := []struct {
+		name      string
+		leader    func()
+		req       *entity.FencingRequest
+		wantPids  []entity.PartitionID
+		wantEpoch uint64
+		writable  bool
+	}{
+		{
+			name:      "Partitions of other leaders are left out",
+			req:       &entity.FencingRequest{NodeID: 1, PartitionIDs: []entity.PartitionID{1, 2}},
+			wantPids:  []entity.PartitionID{1},
+			wantEpoch: 1,
+			writable:  true,
+		},
+		{
+			name:      "New leader waits for the token of the old one",
+			leader:    func() { setLeader(1, 3) },
+			req:       &entity.FencingRequest{NodeID: 3, PartitionIDs: []entity.PartitionID{1}},
+			wantPids:  []entity.PartitionID{1},
+			wantEpoch: 2,
+			writable:  false,
+		},
+		{
+			name:     "Deposed leader releases nothing it does not hold",
+			req:      &entity.FencingRequest{NodeID: 1, PartitionIDs: []entity.PartitionID{1}, Release: true},
+			wantPids: []entity.PartitionID{},
+		},
+		{
+			name:      "Leader moved back after release writes at once",
+			leader:    func() { setLeader(1, 1) },
+			req:       &entity.FencingRequest{NodeID: 3, PartitionIDs: []entity.PartitionID{1}, Release: true},
+			wantPids:  []entity.PartitionID{1},
+			wantEpoch: 2,
+		},
+		{
+			name:      "Next epoch after release",
+			req:       &entity.FencingRequest{NodeID: 1, PartitionIDs: []entity.PartitionID{1}},
+			wantPids:  []entity.PartitionID{1},
+			wantEpoch: 3,
+			writable:  true,
+		},
+	}
+	for _, step := range steps {
+		if step.leader != nil {
+			step.leader()
+		}
+		tokens, err := ms.fencingTokenService(ctx, step.req)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if len(tokens) != len(step.wantPids) {
+			t.Fatalf("%s: got %d tokens, want %v", step.name, len(tokens), step.wantPids)
+		}
+		for i, token := range tokens {
+			if token.PartitionID != step.wantPids[i] || token.Epoch != step.wantEpoch {
+				t.Errorf("%s: token %+v, want partition %d epoch %d", step.name, token, step.wantPids[i], step.wantEpoch)
+			}
+			if step.req.Release {
+				continue
+			}
+			if writable := token.Check(step.req.NodeID, hlc.Now()) == nil; writable != step.writable {
+				t.Errorf("%s: token %+v writable %v, want %v", step.name, token, writable, step.writable)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// putGlobalAliasService creates or replaces the global alias, routers watch
+// the aliases so a region migration switches searches without redeploying.
+// The space of a local alias must exist, remote clusters are only known by
+// routers
+func (ms *masterService) putGlobalAliasService(ctx context.Context, alias *entity.GlobalAlias) error {
+	if err := alias.Validate(); err != nil {
+		return err
+	}
+	if alias.Cluster == "" {
+		dbId, err := ms.Master().QueryDBName2Id(ctx, alias.DbName)
+		if err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_DB_NOT_EXIST, fmt.Errorf("global alias %s db %s not exists", alias.Name, alias.DbName))
+		}
+		if _, err := ms.Master().QuerySpaceByName(ctx, dbId, alias.SpaceName); err != nil {
+			return err
+		}
+	}
+	marshal, err := vjson.Marshal(alias)
+	if err != nil {
+		return err
+	}
+	log.Info("put global alias %s to %s", alias.Name, alias.Target())
+	return ms.Master().Put(ctx, entity.GlobalAliasKey(alias.Name), marshal)
+}
+
+func (ms *masterService) deleteGlobalAliasService(ctx context.Context, name string) error {
+	if _, err := ms.queryGlobalAliasService(ctx, name); err != nil {
+		return err
+	}
+	return ms.Master().Delete(ctx, entity.GlobalAliasKey(name))
+}
+
+func (ms *masterService) queryGlobalAliasService(ctx context.Context, name string) (*entity.GlobalAlias, error) {
+	bs, err := ms.Master().Get(ctx, entity.GlobalAliasKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("global alias %s not exists", name))
+	}
+	alias := &entity.GlobalAlias{}
+	if err = vjson.Unmarshal(bs, alias); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get global alias:%s value:%s, err:%s", name, redact.Payload(bs), err.Error()))
+	}
+	return alias, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+const (
+	// jobEventsPath streams the events of a job, it is not timed out
+	jobEventsPath = "/jobs/:id/events"
+	// jobEventsHeartbeat is the interval of the comments sent on an idle
+	// job event stream
+	jobEventsHeartbeat = 15 * time.Second
+)
+
+// jobUpdate is an event of the stream of a job
+type jobUpdate struct {
+	name  string
+	event *entity.JobEvent
+}
+
+// watchJobService returns the events of the job of kind, the first is its
+// current state. The channel is closed when the job finishes, is deleted or
+// ctx is done
+func (ms *masterService) watchJobService(ctx context.Context, kind, name string) (<-chan *jobUpdate, error) {
+	key := entity.JobKey(kind, name)
+	// watch before the first read so no update between them is lost, the
+	// updates already read are no news and not sent again
+	watcher, err := ms.Master().WatchPrefix(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := ms.Master().Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("job %s:%s not found", kind, name))
+	}
+
+	updates := make(chan *jobUpdate)
+	go func() {
+		defer close(updates)
+		partitions := 0
+		var prev *entity.JobEvent
+		push := func(u *jobUpdate) bool {
+			select {
+			case updates <- u:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		// send pushes the job in value if it changed, false when the stream
+		// is over
+		send := func(value []byte) bool {
+			event, finished, err := ms.decodeJob(ctx, kind, value, &partitions)
+			if err != nil {
+				log.Error("decode job %s:%s err: %v", kind, name, err)
+				return true
+			}
+			if next := event.Next(prev); next != "" {
+				prev = event
+				if !push(&jobUpdate{name: next, event: event}) {
+					return false
+				}
+			}
+			return !finished
+		}
+
+		if !send(value) {
+			return
+		}
+		for resp := range watcher {
+			if resp.Canceled {
+				log.Error("watch job %s:%s canceled: %v", kind, name, resp.Err())
+				return
+			}
+			for _, event := range resp.Events {
+				if string(event.Kv.Key) != key {
+					continue
+				}
+				if event.Type == mvccpb.DELETE {
+					deleted := &entity.JobEvent{ID: kind + ":" + name}
+					if prev != nil {
+						*deleted = *prev
+					}
+					push(&jobUpdate{name: entity.JobEventDeleted, event: deleted})
+					return
+				}
+				if !send(event.Kv.Value) {
+					return
+				}
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// decodeJob returns the state of the job of kind stored in value and whether
+// it finished, partitions is the number of partitions of its space, loaded
+// once when zero
+func (ms *masterService) decodeJob(ctx context.Context, kind string, value []byte, partitions *int) (*entity.JobEvent, bool, error) {
+	if kind == entity.JobSimilarityJoin {
+		j := &entity.SimilarityJoin{}
+		if err := vjson.Unmarshal(value, j); err != nil {
+			return nil, false, err
+		}
+		ms.loadJobPartitions(ctx, j.DbName, j.SpaceName, partitions)
+		return j.Event(*partitions), j.Finished(), nil
+	}
+	m := &entity.EmbeddingMigration{}
+	if err := vjson.Unmarshal(value, m); err != nil {
+		return nil, false, err
+	}
+	ms.loadJobPartitions(ctx, m.DbName, m.SpaceName, partitions)
+	return m.Event(*partitions), m.Finished(), nil
+}
+
+// loadJobPartitions sets partitions to the number of partitions of the space
+// a job goes through unless it is known, it is left zero on errors and the
+// progress unknown until the next update
+func (ms *masterService) loadJobPartitions(ctx context.Context, dbName, spaceName string, partitions *int) {
+	if *partitions > 0 {
+		return
+	}
+	dbID, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		log.Warn("get db %s of job err: %v", dbName, err)
+		return
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbID, spaceName)
+	if err != nil {
+		log.Warn("get space %s/%s of job err: %v", dbName, spaceName, err)
+		return
+	}
+	*partitions = len(space.Partitions)
+}
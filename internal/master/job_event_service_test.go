@@ -0,0 +1,103 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+func TestWatchJobService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cli, err := client.NewClientWithStore(nil, store.NewMemStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newMasterService(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(j *entity.SimilarityJoin) {
+		bs, err := vjson.Marshal(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ms.Master().Put(ctx, entity.SimilarityJoinKey(j.Name), bs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := func(updates <-chan *jobUpdate) *jobUpdate {
+		select {
+		case u := <-updates:
+			return u
+		case <-time.After(5 * time.Second):
+			t.Fatal("no job event")
+		}
+		return nil
+	}
+
+	if _, err := ms.watchJobService(ctx, entity.JobSimilarityJoin, "dedup"); err == nil {
+		t.Fatal("watch a missing job should fail")
+	}
+
+	put(&entity.SimilarityJoin{Name: "dedup", Status: entity.MigrationRunning})
+	updates, err := ms.watchJobService(ctx, entity.JobSimilarityJoin, "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := next(updates); u.name != entity.JobEventState || u.event.ID != "similarity_join:dedup" || u.event.Status != entity.MigrationRunning {
+		t.Fatalf("want the running state first, got %s %+v", u.name, u.event)
+	}
+
+	// a job of a name sharing the prefix and an update with no news are
+	// not sent
+	put(&entity.SimilarityJoin{Name: "dedup2", Status: entity.MigrationDone})
+	put(&entity.SimilarityJoin{Name: "dedup", Status: entity.MigrationRunning, UpdateTime: 1})
+	put(&entity.SimilarityJoin{Name: "dedup", Status: entity.MigrationRunning, Joined: 10, UpdateTime: 2})
+	if u := next(updates); u.name != entity.JobEventProgress || u.event.Done != 10 {
+		t.Fatalf("want progress of 10 docs, got %s %+v", u.name, u.event)
+	}
+
+	put(&entity.SimilarityJoin{Name: "dedup", Status: entity.MigrationDone, Joined: 20, UpdateTime: 3})
+	if u := next(updates); u.name != entity.JobEventState || u.event.Status != entity.MigrationDone || u.event.Progress != 100 {
+		t.Fatalf("want the done state, got %s %+v", u.name, u.event)
+	}
+	if _, ok := <-updates; ok {
+		t.Fatal("stream of a finished job should be closed")
+	}
+
+	put(&entity.SimilarityJoin{Name: "gone", Status: entity.MigrationRunning})
+	updates, err = ms.watchJobService(ctx, entity.JobSimilarityJoin, "gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next(updates)
+	if err := ms.Master().Delete(ctx, entity.SimilarityJoinKey("gone")); err != nil {
+		t.Fatal(err)
+	}
+	if u := next(updates); u.name != entity.JobEventDeleted || u.event.Status != entity.MigrationRunning {
+		t.Fatalf("want the deleted event, got %s %+v", u.name, u.event)
+	}
+	if _, ok := <-updates; ok {
+		t.Fatal("stream of a deleted job should be closed")
+	}
+}
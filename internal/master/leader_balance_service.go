@@ -0,0 +1,240 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+const leaderBalanceSettingsName = "leader_balance"
+
+// leaderBalancer spreads the leaders of hot partitions across ps periodically,
+// the qps of partitions comes from the partition stats of their leaders
+type leaderBalancer struct {
+	ms *masterService
+}
+
+type hotPartition struct {
+	partition *entity.Partition
+	qps       float64
+	// preferred are the replicas the leader may move to by the leadership
+	// of the space, any replica without it
+	preferred []entity.NodeID
+}
+
+func newLeaderBalancer(ms *masterService) *leaderBalancer {
+	return &leaderBalancer{ms: ms}
+}
+
+func (lb *leaderBalancer) start(ctx context.Context) {
+	go func() {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				log.Error("recover() err:[%v]", rErr)
+				log.Error("stack:[%s]", debug.Stack())
+			}
+		}()
+		interval := int64(entity.DefaultLeaderBalanceInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("leader balancer stopped")
+				return
+			case <-time.After(time.Duration(interval) * time.Second):
+			}
+
+			cfg, err := lb.ms.getLeaderBalanceConfigService(ctx)
+			if err != nil {
+				log.Error("get leader balance config err: %v", err)
+				continue
+			}
+			interval = cfg.Interval
+			if !cfg.Enabled {
+				continue
+			}
+
+			// the lock is not released, it expires by ttl so only one master balances in an interval
+			mutex := lb.ms.Master().NewLock(ctx, entity.ClusterLeaderBalanceKey, time.Duration(interval)*time.Second)
+			if getLock, err := mutex.TryLock(); !getLock || err != nil {
+				continue
+			}
+			lb.balance(ctx, cfg)
+		}
+	}()
+}
+
+// balance moves the leaders of the hottest partitions from the nodes leading
+// most hot partitions to the replicas on nodes leading fewer, at most
+// MaxMoves transfers
+func (lb *leaderBalancer) balance(ctx context.Context, cfg *entity.LeaderBalanceConfig) {
+	partitions, err := lb.ms.Master().QueryPartitions(ctx)
+	if err != nil {
+		log.Error("leader balance query partitions err: %v", err)
+		return
+	}
+	servers, err := lb.ms.Master().QueryServers(ctx)
+	if err != nil {
+		log.Error("leader balance query servers err: %v", err)
+		return
+	}
+	serverMap := make(map[entity.NodeID]*entity.Server, len(servers))
+	for _, s := range servers {
+		serverMap[s.ID] = s
+	}
+	spaces, err := lb.ms.Master().QuerySpacesByKey(ctx, entity.PrefixSpace)
+	if err != nil {
+		log.Error("leader balance query spaces err: %v", err)
+		return
+	}
+	leaderships := make(map[entity.SpaceID]*entity.Leadership)
+	for _, s := range spaces {
+		if s.Leadership != nil {
+			leaderships[s.Id] = s.Leadership
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		load = make([]*hotPartition, 0, len(partitions))
+	)
+	for _, p := range partitions {
+		server := serverMap[p.LeaderID]
+		if server == nil || len(p.Replicas) < 2 {
+			continue
+		}
+		wg.Add(1)
+		go func(p *entity.Partition, addr string) {
+			defer wg.Done()
+			stats, err := client.PartitionStats(addr, p.Id)
+			if err != nil {
+				log.Warn("leader balance get stats of partition [%d] err: %v", p.Id, err)
+				return
+			}
+			hp := &hotPartition{partition: p}
+			if leadership := leaderships[p.SpaceId]; leadership != nil {
+				hp.preferred = leadership.PreferredLeaders(p.Replicas, serverMap)
+			}
+			for _, r := range stats.Requests {
+				hp.qps += r.Qps
+			}
+			mu.Lock()
+			load = append(load, hp)
+			mu.Unlock()
+		}(p, server.RpcAddr())
+	}
+	wg.Wait()
+	moveHotLeaders(load, serverMap, cfg, func(hp *hotPartition, to entity.NodeID) error {
+		from := hp.partition.LeaderID
+		if err := client.TryToLeader(serverMap[to].RpcAddr(), hp.partition.Id); err != nil {
+			log.Error("leader balance move leader of partition [%d] from [%d] to [%d] err: %v", hp.partition.Id, from, to, err)
+			return err
+		}
+		log.Info("leader balance moved leader of hot partition [%d] qps [%.2f] from [%d] to [%d]", hp.partition.Id, hp.qps, from, to)
+		return nil
+	})
+}
+
+// moveHotLeaders moves the leaders of the hot partitions by move to the
+// preferred replica leading the fewest hot partitions, it returns the
+// leaders moved
+func moveHotLeaders(load []*hotPartition, serverMap map[entity.NodeID]*entity.Server, cfg *entity.LeaderBalanceConfig, move func(hp *hotPartition, to entity.NodeID) error) int {
+	if len(load) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, hp := range load {
+		total += hp.qps
+	}
+	mean := total / float64(len(load))
+
+	hotLeaders := make(map[entity.NodeID]int)
+	hot := make([]*hotPartition, 0)
+	for _, hp := range load {
+		if hp.qps >= cfg.MinQps && hp.qps >= cfg.HotRatio*mean {
+			hot = append(hot, hp)
+			hotLeaders[hp.partition.LeaderID]++
+		}
+	}
+	sort.Slice(hot, func(i, j int) bool { return hot[i].qps > hot[j].qps })
+
+	moves := 0
+	for _, hp := range hot {
+		if moves >= cfg.MaxMoves {
+			break
+		}
+		from := hp.partition.LeaderID
+		// moving only when it lowers the max, so the leaders do not flap
+		var to entity.NodeID
+		fewest := hotLeaders[from] - 1
+		for _, nodeID := range hp.partition.Replicas {
+			if nodeID == from || serverMap[nodeID] == nil {
+				continue
+			}
+			if len(hp.preferred) > 0 && !slices.Contains(hp.preferred, nodeID) {
+				continue
+			}
+			if hotLeaders[nodeID] < fewest {
+				to, fewest = nodeID, hotLeaders[nodeID]
+			}
+		}
+		if to == 0 {
+			continue
+		}
+		if err := move(hp, to); err != nil {
+			continue
+		}
+		hotLeaders[from]--
+		hotLeaders[to]++
+		moves++
+	}
+	return moves
+}
+
+func (ms *masterService) getLeaderBalanceConfigService(ctx context.Context) (*entity.LeaderBalanceConfig, error) {
+	cfg := entity.NewLeaderBalanceConfig()
+	bs, err := ms.Master().Get(ctx, entity.SettingsKey(leaderBalanceSettingsName))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return cfg, nil
+	}
+	if err := vjson.Unmarshal(bs, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (ms *masterService) updateLeaderBalanceConfigService(ctx context.Context, cfg *entity.LeaderBalanceConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	marshal, err := vjson.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return ms.Master().Put(ctx, entity.SettingsKey(leaderBalanceSettingsName), marshal)
+}
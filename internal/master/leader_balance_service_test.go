@@ -0,0 +1,101 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func TestMoveHotLeaders(t *testing.T) {
+	load := func() []*hotPartition {
+		hp := func(id entity.PartitionID, leader entity.NodeID, qps float64, replicas ...entity.NodeID) *hotPartition {
+			return &hotPartition{partition: &entity.Partition{Id: id, LeaderID: leader, Replicas: replicas}, qps: qps}
+		}
+		return []*hotPartition{
+			hp(1, 1, 100, 1, 2, 3),
+			hp(2, 1, 90, 1, 2),
+			hp(3, 2, 1, 2, 3),
+			hp(4, 2, 1, 2, 3),
+			hp(5, 3, 1, 3, 1),
+			hp(6, 3, 1, 3, 1),
+		}
+	}
+	servers := map[entity.NodeID]*entity.Server{1: {ID: 1}, 2: {ID: 2}, 3: {ID: 3}}
+	withoutTwo := map[entity.NodeID]*entity.Server{1: {ID: 1}, 3: {ID: 3}}
+	tests := []struct {
+		name      string
+		servers   map[entity.NodeID]*entity.Server
+		cfg       *entity.LeaderBalanceConfig
+		fail      entity.PartitionID
+		preferred []entity.NodeID
+		want      []string
+	}{
+		{
+			name:    "Hottest leader moved to the replica without hot leader",
+			servers: servers,
+			cfg:     &entity.LeaderBalanceConfig{HotRatio: 2, MinQps: 10, MaxMoves: 2},
+			want:    []string{"1->2"},
+		},
+		{
+			name:    "Replica on a server down is skipped",
+			servers: withoutTwo,
+			cfg:     &entity.LeaderBalanceConfig{HotRatio: 2, MinQps: 10, MaxMoves: 2},
+			want:    []string{"1->3"},
+		},
+		{
+			name:    "Failed move is not counted",
+			servers: servers,
+			cfg:     &entity.LeaderBalanceConfig{HotRatio: 2, MinQps: 10, MaxMoves: 1},
+			fail:    1,
+			want:    []string{"2->2"},
+		},
+		{
+			name:      "Leader moved only to a preferred replica",
+			servers:   servers,
+			cfg:       &entity.LeaderBalanceConfig{HotRatio: 2, MinQps: 10, MaxMoves: 2},
+			preferred: []entity.NodeID{1, 3},
+			want:      []string{"1->3"},
+		},
+		{
+			name:    "No partition above min qps",
+			servers: servers,
+			cfg:     &entity.LeaderBalanceConfig{HotRatio: 2, MinQps: 200, MaxMoves: 2},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var moved []string
+			hps := load()
+			for _, hp := range hps {
+				hp.preferred = tt.preferred
+			}
+			n := moveHotLeaders(hps, tt.servers, tt.cfg, func(hp *hotPartition, to entity.NodeID) error {
+				if hp.partition.Id == tt.fail {
+					return fmt.Errorf("transfer failed")
+				}
+				moved = append(moved, fmt.Sprintf("%d->%d", hp.partition.Id, to))
+				return nil
+			})
+			if n != len(moved) || !reflect.DeepEqual(moved, tt.want) {
+				t.Errorf("moveHotLeaders() = %d %v, want %v", n, moved, tt.want)
+			}
+		})
+	}
+}
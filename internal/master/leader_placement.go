@@ -0,0 +1,169 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+const (
+	leaderPlacementInterval = 60 * time.Second
+	// a partition whose leader was moved is left alone for a while, so a
+	// preferred replica failing to keep the leadership does not churn it
+	leaderPlacementBackoff = 10 * time.Minute
+)
+
+// leaderPlacer moves the leaders of the partitions of spaces with leader
+// priorities to their preferred replicas
+type leaderPlacer struct {
+	ms    *masterService
+	mu    sync.Mutex
+	moved map[entity.PartitionID]time.Time
+}
+
+type leaderMove struct {
+	partition *entity.Partition
+	to        entity.NodeID
+}
+
+func newLeaderPlacer(ms *masterService) *leaderPlacer {
+	return &leaderPlacer{ms: ms, moved: make(map[entity.PartitionID]time.Time)}
+}
+
+func (lp *leaderPlacer) start(ctx context.Context) {
+	go func() {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				log.Error("recover() err:[%v]", rErr)
+				log.Error("stack:[%s]", debug.Stack())
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("leader placer stopped")
+				return
+			case <-time.After(leaderPlacementInterval):
+			}
+
+			// the lock is not released, it expires by ttl so only one master places in an interval
+			mutex := lp.ms.Master().NewLock(ctx, entity.ClusterLeaderPlacementKey, leaderPlacementInterval)
+			if getLock, err := mutex.TryLock(); !getLock || err != nil {
+				continue
+			}
+			lp.place(ctx)
+		}
+	}()
+}
+
+func (lp *leaderPlacer) place(ctx context.Context) {
+	spaces, err := lp.ms.Master().QuerySpacesByKey(ctx, entity.PrefixSpace)
+	if err != nil {
+		log.Error("leader placement query spaces err: %v", err)
+		return
+	}
+	servers, err := lp.ms.Master().QueryServers(ctx)
+	if err != nil {
+		log.Error("leader placement query servers err: %v", err)
+		return
+	}
+	serverMap := make(map[entity.NodeID]*entity.Server, len(servers))
+	for _, s := range servers {
+		serverMap[s.ID] = s
+	}
+
+	now := time.Now()
+	lp.mu.Lock()
+	for pid, t := range lp.moved {
+		if now.Sub(t) >= leaderPlacementBackoff {
+			delete(lp.moved, pid)
+		}
+	}
+	lp.mu.Unlock()
+
+	// the partitions of spaces keep the replicas and leaders they had when
+	// the spaces were written, the current ones are the partitions registered
+	partitions, err := lp.ms.Master().QueryPartitions(ctx)
+	if err != nil {
+		log.Error("leader placement query partitions err: %v", err)
+		return
+	}
+	bySpace := make(map[entity.SpaceID][]*entity.Partition)
+	for _, p := range partitions {
+		bySpace[p.SpaceId] = append(bySpace[p.SpaceId], p)
+	}
+
+	for _, space := range spaces {
+		if space.Leadership == nil {
+			continue
+		}
+		for _, move := range misplacedLeaders(space.Leadership, bySpace[space.Id], serverMap) {
+			pid := move.partition.Id
+			lp.mu.Lock()
+			_, recent := lp.moved[pid]
+			if !recent {
+				lp.moved[pid] = now
+			}
+			lp.mu.Unlock()
+			if recent {
+				continue
+			}
+			from := move.partition.LeaderID
+			if err := client.TryToLeader(serverMap[move.to].RpcAddr(), pid); err != nil {
+				log.Error("leader placement move leader of partition [%d] from [%d] to [%d] err: %v", pid, from, move.to, err)
+				continue
+			}
+			log.Info("leader placement moved leader of partition [%d] of space [%s] from [%d] to [%d]", pid, space.Name, from, move.to)
+		}
+	}
+}
+
+// misplacedLeaders are the moves of the leaders of partitions which are not
+// on a preferred replica, to the preferred replica leading the fewest of
+// them. Partitions without a leader are electing one and left alone.
+func misplacedLeaders(leadership *entity.Leadership, partitions []*entity.Partition, servers map[entity.NodeID]*entity.Server) []*leaderMove {
+	leading := make(map[entity.NodeID]int)
+	for _, p := range partitions {
+		leading[p.LeaderID]++
+	}
+	var moves []*leaderMove
+	for _, p := range partitions {
+		if p.LeaderID == 0 {
+			continue
+		}
+		preferred := leadership.PreferredLeaders(p.Replicas, servers)
+		if len(preferred) == 0 || slices.Contains(preferred, p.LeaderID) {
+			continue
+		}
+		to := preferred[0]
+		for _, id := range preferred[1:] {
+			if leading[id] < leading[to] {
+				to = id
+			}
+		}
+		leading[p.LeaderID]--
+		leading[to]++
+		moves = append(moves, &leaderMove{partition: p, to: to})
+	}
+	return moves
+}
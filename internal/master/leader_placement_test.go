@@ -0,0 +1,86 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func TestMisplacedLeaders(t *testing.T) {
+	servers := map[entity.NodeID]*entity.Server{
+		1: {ID: 1, HostZone: "a"},
+		2: {ID: 2, HostZone: "a"},
+		3: {ID: 3, HostZone: "b"},
+	}
+	primaryA := &entity.Leadership{Priorities: []*entity.LeaderPriority{{Zone: "a", Priority: 10}}}
+	p := func(id entity.PartitionID, leader entity.NodeID, replicas ...entity.NodeID) *entity.Partition {
+		return &entity.Partition{Id: id, LeaderID: leader, Replicas: replicas}
+	}
+	tests := []struct {
+		name       string
+		leadership *entity.Leadership
+		partitions []*entity.Partition
+		servers    map[entity.NodeID]*entity.Server
+		want       []string
+	}{
+		{
+			name:       "Leaders in the primary zone stay",
+			leadership: primaryA,
+			partitions: []*entity.Partition{p(1, 1, 1, 3), p(2, 2, 2, 3)},
+			servers:    servers,
+		},
+		{
+			name:       "Leaders spread over the preferred replicas",
+			leadership: primaryA,
+			partitions: []*entity.Partition{p(1, 3, 1, 2, 3), p(2, 3, 1, 2, 3), p(3, 1, 1, 3)},
+			servers:    servers,
+			want:       []string{"1->2", "2->1"},
+		},
+		{
+			name:       "Preferred replica down is not a target",
+			leadership: primaryA,
+			partitions: []*entity.Partition{p(1, 3, 1, 3)},
+			servers:    map[entity.NodeID]*entity.Server{3: servers[3]},
+		},
+		{
+			name:       "Partition electing a leader is left alone",
+			leadership: primaryA,
+			partitions: []*entity.Partition{p(1, 0, 1, 3)},
+			servers:    servers,
+		},
+		{
+			name:       "Node priority over zone",
+			leadership: &entity.Leadership{Priorities: []*entity.LeaderPriority{{Zone: "a", Priority: 10}, {NodeID: 2, Priority: 20}}},
+			partitions: []*entity.Partition{p(1, 1, 1, 2, 3)},
+			servers:    servers,
+			want:       []string{"1->2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range misplacedLeaders(tt.leadership, tt.partitions, tt.servers) {
+				got = append(got, fmt.Sprintf("%d->%d", m.partition.Id, m.to))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("misplacedLeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// metaSchemaReportKeys limits the missing and mismatched keys reported
+const metaSchemaReportKeys = 100
+
+// putMeta writes v of kind at key in the schema version of kind, and in the
+// target version at the shadow key while kind is dual written. The schema is
+// read in the transaction, so a write racing a mode change is retried
+func putMeta(stm store.STM, kind, key string, v interface{}) error {
+	schema, err := entity.DecodeMetaSchema(kind, stm.Get(entity.MetaSchemaKey(kind)))
+	if err != nil {
+		return err
+	}
+	value, err := entity.EncodeMeta(kind, schema.Version, v)
+	if err != nil {
+		return err
+	}
+	stm.Put(key, string(value))
+	if schema.Mode != entity.MetaSchemaDual {
+		return nil
+	}
+	shadow, err := entity.EncodeMeta(kind, schema.Target, v)
+	if err != nil {
+		return err
+	}
+	stm.Put(entity.MetaShadowKey(key), string(shadow))
+	return nil
+}
+
+// delMeta deletes the entity at key and its shadow
+func delMeta(stm store.STM, key string) {
+	stm.Del(key)
+	stm.Del(entity.MetaShadowKey(key))
+}
+
+// createMetaIfAbsent is createIfAbsent for a kind with versioned schema
+func (ms *masterService) createMetaIfAbsent(ctx context.Context, kind, key string, v interface{}) (bool, error) {
+	created := false
+	err := ms.Master().STM(ctx, func(stm store.STM) error {
+		if stm.Get(key) != "" {
+			return nil
+		}
+		created = true
+		return putMeta(stm, kind, key, v)
+	})
+	return created, err
+}
+
+func checkMetaKind(kind string) error {
+	if !entity.IsMetaKind(kind) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metadata kind %s has no versioned schema", kind))
+	}
+	return nil
+}
+
+func (ms *masterService) lockMetaSchema(ctx context.Context) (func(), error) {
+	mutex := ms.Master().NewLock(ctx, entity.ClusterMetaSchemaKey, time.Second*300)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock meta schema err:[%s]", err.Error())
+		}
+	}, nil
+}
+
+// updateMetaSchema changes the schema of kind in a transaction
+func (ms *masterService) updateMetaSchema(ctx context.Context, kind string, fn func(schema *entity.MetaSchema) error) error {
+	return ms.Master().STM(ctx, func(stm store.STM) error {
+		schema, err := entity.DecodeMetaSchema(kind, stm.Get(entity.MetaSchemaKey(kind)))
+		if err != nil {
+			return err
+		}
+		if err = fn(schema); err != nil {
+			return err
+		}
+		schema.UpdateTime = time.Now().Unix()
+		marshal, err := vjson.Marshal(schema)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.MetaSchemaKey(kind), string(marshal))
+		return nil
+	})
+}
+
+// rewriteMeta re-encodes the entity at key with fn in a transaction, the
+// entity is left alone if it is deleted meanwhile
+func (ms *masterService) rewriteMeta(ctx context.Context, key string, fn func(stm store.STM, data json.RawMessage) error) error {
+	return ms.Master().STM(ctx, func(stm store.STM) error {
+		value := stm.Get(key)
+		if value == "" {
+			stm.Del(entity.MetaShadowKey(key))
+			return nil
+		}
+		var data json.RawMessage
+		if _, err := entity.DecodeMeta([]byte(value), &data); err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("decode meta %s err: %v", key, err))
+		}
+		return fn(stm, data)
+	})
+}
+
+// deleteMetaShadows deletes the shadows of kind
+func (ms *masterService) deleteMetaShadows(ctx context.Context, kind string) error {
+	keys, _, err := ms.Master().PrefixScan(ctx, entity.MetaShadowKey(entity.MetaKindPrefix(kind)))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err = ms.Master().Delete(ctx, string(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metaSchemaService returns the schema of kind and validates each key of
+// kind against its shadow while kind is dual written
+func (ms *masterService) metaSchemaService(ctx context.Context, kind string) (*entity.MetaSchemaStatus, error) {
+	if err := checkMetaKind(kind); err != nil {
+		return nil, err
+	}
+	bs, err := ms.Master().Get(ctx, entity.MetaSchemaKey(kind))
+	if err != nil {
+		return nil, err
+	}
+	schema, err := entity.DecodeMetaSchema(kind, string(bs))
+	if err != nil {
+		return nil, err
+	}
+	keys, values, err := ms.Master().PrefixScan(ctx, entity.MetaKindPrefix(kind))
+	if err != nil {
+		return nil, err
+	}
+	shadowKeys, shadowValues, err := ms.Master().PrefixScan(ctx, entity.MetaShadowKey(entity.MetaKindPrefix(kind)))
+	if err != nil {
+		return nil, err
+	}
+	shadows := make(map[string][]byte, len(shadowKeys))
+	for i, key := range shadowKeys {
+		shadows[string(key)] = shadowValues[i]
+	}
+
+	status := &entity.MetaSchemaStatus{MetaSchema: schema, Keys: len(keys), Versions: make(map[int]int), Shadows: len(shadowKeys)}
+	for i, key := range keys {
+		var data interface{}
+		version, err := entity.DecodeMeta(values[i], &data)
+		if err != nil {
+			status.Mismatched = appendReportKey(status.Mismatched, string(key))
+			continue
+		}
+		status.Versions[version]++
+		if schema.Mode != entity.MetaSchemaDual {
+			continue
+		}
+		shadow, ok := shadows[entity.MetaShadowKey(string(key))]
+		if !ok {
+			status.Missing = appendReportKey(status.Missing, string(key))
+			continue
+		}
+		if shadowVersion, err := entity.DecodeMeta(shadow, &data); err != nil || shadowVersion != schema.Target || !entity.SameMeta(values[i], shadow) {
+			status.Mismatched = appendReportKey(status.Mismatched, string(key))
+		}
+	}
+	return status, nil
+}
+
+func appendReportKey(keys []string, key string) []string {
+	if len(keys) >= metaSchemaReportKeys {
+		return keys
+	}
+	return append(keys, key)
+}
+
+// dualMetaSchemaService starts writing each entity of kind in the target
+// version too, then backfills the shadows of the existing keys. Readers keep
+// reading the current version, so routers of any build are unaffected
+func (ms *masterService) dualMetaSchemaService(ctx context.Context, kind string, target int) (*entity.MetaSchemaStatus, error) {
+	if err := checkMetaKind(kind); err != nil {
+		return nil, err
+	}
+	unlock, err := ms.lockMetaSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = ms.updateMetaSchema(ctx, kind, func(schema *entity.MetaSchema) error {
+		if err := schema.ValidateTarget(target); err != nil {
+			return err
+		}
+		schema.Target, schema.Mode = target, entity.MetaSchemaDual
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys, _, err := ms.Master().PrefixScan(ctx, entity.MetaKindPrefix(kind))
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		err = ms.rewriteMeta(ctx, string(key), func(stm store.STM, data json.RawMessage) error {
+			shadow, err := entity.EncodeMeta(kind, target, data)
+			if err != nil {
+				return err
+			}
+			stm.Put(entity.MetaShadowKey(string(key)), string(shadow))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Info("dual write %s in meta schema version %d, backfill %d keys", kind, target, len(keys))
+	return ms.metaSchemaService(ctx, kind)
+}
+
+// finalizeMetaSchemaService cuts kind over to the target version once every
+// key matches its shadow: the master writes in the target version from then
+// on and rewrites the existing keys in it. Every router must run a build
+// reading the target version before the cutover
+func (ms *masterService) finalizeMetaSchemaService(ctx context.Context, kind string) (*entity.MetaSchemaStatus, error) {
+	if err := checkMetaKind(kind); err != nil {
+		return nil, err
+	}
+	unlock, err := ms.lockMetaSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	status, err := ms.metaSchemaService(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+	if status.Mode != entity.MetaSchemaDual {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s is not dual written, nothing to finalize", kind))
+	}
+	if len(status.Missing) > 0 || len(status.Mismatched) > 0 {
+		return status, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s has %d missing and %d mismatched shadows, abort or dual write again", kind, len(status.Missing), len(status.Mismatched)))
+	}
+	target := status.Target
+
+	err = ms.updateMetaSchema(ctx, kind, func(schema *entity.MetaSchema) error {
+		if schema.Mode != entity.MetaSchemaDual || schema.Target != target {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("meta schema of %s changed while finalizing", kind))
+		}
+		schema.Version, schema.Target, schema.Mode = target, 0, entity.MetaSchemaSingle
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys, _, err := ms.Master().PrefixScan(ctx, entity.MetaKindPrefix(kind))
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		// encode from the key not the shadow, so a write made between the
+		// validation and the cutover is kept
+		err = ms.rewriteMeta(ctx, string(key), func(stm store.STM, data json.RawMessage) error {
+			value, err := entity.EncodeMeta(kind, target, data)
+			if err != nil {
+				return err
+			}
+			stm.Put(string(key), string(value))
+			stm.Del(entity.MetaShadowKey(string(key)))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = ms.deleteMetaShadows(ctx, kind); err != nil {
+		return nil, err
+	}
+	log.Info("finalize %s in meta schema version %d, rewrite %d keys", kind, target, len(keys))
+	return ms.metaSchemaService(ctx, kind)
+}
+
+// abortMetaSchemaService stops the dual write of kind and drops the shadows,
+// the keys were never changed so nothing else is rolled back
+func (ms *masterService) abortMetaSchemaService(ctx context.Context, kind string) (*entity.MetaSchemaStatus, error) {
+	if err := checkMetaKind(kind); err != nil {
+		return nil, err
+	}
+	unlock, err := ms.lockMetaSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = ms.updateMetaSchema(ctx, kind, func(schema *entity.MetaSchema) error {
+		if schema.Mode != entity.MetaSchemaDual {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s is not dual written, nothing to abort", kind))
+		}
+		schema.Target, schema.Mode = 0, entity.MetaSchemaSingle
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = ms.deleteMetaShadows(ctx, kind); err != nil {
+		return nil, err
+	}
+	log.Info("abort dual write of %s", kind)
+	return ms.metaSchemaService(ctx, kind)
+}
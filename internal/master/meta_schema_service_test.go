@@ -0,0 +1,104 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+)
+
+func TestMetaSchemaService(t *testing.T) {
+	ctx := context.Background()
+	memStore := store.NewMemStore()
+	cli, err := client.NewClientWithStore(nil, memStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newMasterService(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := func(name string) int {
+		bs, err := memStore.Get(ctx, entity.RoleKey(name))
+		if err != nil || bs == nil {
+			t.Fatalf("get role %s: %v", name, err)
+		}
+		v, err := entity.DecodeMeta(bs, &entity.Role{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	if err := ms.createRoleService(ctx, &entity.Role{Name: "reader", Privileges: map[entity.Resource]entity.Privilege{entity.ResourceCluster: entity.ReadOnly}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.finalizeMetaSchemaService(ctx, entity.MetaKindRole); err == nil {
+		t.Fatal("finalize without dual write should fail")
+	}
+
+	status, err := ms.dualMetaSchemaService(ctx, entity.MetaKindRole, entity.MetaSchemaV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Shadows != 1 || len(status.Missing) != 0 || len(status.Mismatched) != 0 {
+		t.Fatalf("dual write status = %+v, want the role backfilled", status)
+	}
+
+	// writes while dual written keep the key in version 1 and its shadow in sync
+	if err := ms.createRoleService(ctx, &entity.Role{Name: "writer"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.changeRolePrivilegeService(ctx, &entity.Role{Name: "reader", Operator: entity.Grant, Privileges: map[entity.Resource]entity.Privilege{entity.ResourceServer: entity.WriteRead}}); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = ms.metaSchemaService(ctx, entity.MetaKindRole); err != nil {
+		t.Fatal(err)
+	}
+	if status.Versions[entity.MetaSchemaV1] != 2 || status.Shadows != 2 || len(status.Missing) != 0 || len(status.Mismatched) != 0 {
+		t.Fatalf("status = %+v, want 2 keys in version 1 with matching shadows", status)
+	}
+
+	if status, err = ms.finalizeMetaSchemaService(ctx, entity.MetaKindRole); err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != entity.MetaSchemaV2 || status.Mode != entity.MetaSchemaSingle || status.Shadows != 0 || status.Versions[entity.MetaSchemaV2] != 2 {
+		t.Fatalf("finalize status = %+v, want 2 keys in version 2 without shadows", status)
+	}
+	if v := version("reader"); v != entity.MetaSchemaV2 {
+		t.Errorf("reader in version %d, want %d", v, entity.MetaSchemaV2)
+	}
+	role, err := ms.queryRoleService(ctx, "reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.Privileges[entity.ResourceServer] != entity.WriteRead || role.Privileges[entity.ResourceCluster] != entity.ReadOnly {
+		t.Errorf("reader privileges = %v", role.Privileges)
+	}
+
+	if err := ms.deleteRoleService(ctx, "writer"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.dualMetaSchemaService(ctx, entity.MetaKindRole, entity.MetaSchemaV2); err == nil {
+		t.Error("dual write in the current version should fail")
+	}
+	if _, err := ms.abortMetaSchemaService(ctx, entity.MetaKindRole); err == nil {
+		t.Error("abort without dual write should fail")
+	}
+}
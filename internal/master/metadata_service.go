@@ -0,0 +1,223 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// MetadataImportResult counts what an import wrote, builtin roles and users
+// and roles already in etcd such as root are kept and skipped
+type MetadataImportResult struct {
+	DBs          int      `json:"dbs"`
+	Spaces       int      `json:"spaces"`
+	Partitions   int      `json:"partitions"`
+	Users        int      `json:"users"`
+	Roles        int      `json:"roles"`
+	Aliases      int      `json:"aliases"`
+	SkippedUsers []string `json:"skipped_users,omitempty"`
+	SkippedRoles []string `json:"skipped_roles,omitempty"`
+}
+
+func (ms *masterService) exportMetadataService(ctx context.Context) (*entity.MetadataBundle, error) {
+	bundle := &entity.MetadataBundle{
+		Version:    entity.MetadataBundleVersion,
+		Cluster:    config.Conf().Global.Name,
+		ExportTime: time.Now().Unix(),
+	}
+	var err error
+	if bundle.DBs, err = ms.Master().QueryDBs(ctx); err != nil {
+		return nil, err
+	}
+	if bundle.Spaces, err = ms.Master().QuerySpacesByKey(ctx, entity.PrefixSpace); err != nil {
+		return nil, err
+	}
+	if bundle.Partitions, err = ms.Master().QueryPartitions(ctx); err != nil {
+		return nil, err
+	}
+	if bundle.Roles, err = ms.queryAllRole(ctx); err != nil {
+		return nil, err
+	}
+	if bundle.Aliases, err = ms.queryAllAlias(ctx); err != nil {
+		return nil, err
+	}
+	// users as stored, queryAllUser resolves roles and drops passwords
+	_, values, err := ms.Master().PrefixScan(ctx, entity.PrefixUser)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Users = make([]*entity.User, 0, len(values))
+	for _, value := range values {
+		user := &entity.User{}
+		if err := vjson.Unmarshal(value, user); err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("decode user err: %v", err))
+		}
+		bundle.Users = append(bundle.Users, user)
+	}
+	// the exported meta should import as is
+	if err := bundle.Validate(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// importMetadataService writes a validated bundle into an etcd without dbs,
+// spaces and partitions, then raises the id sequences over the imported ids
+func (ms *masterService) importMetadataService(ctx context.Context, bundle *entity.MetadataBundle) (*MetadataImportResult, error) {
+	if err := bundle.Validate(); err != nil {
+		return nil, err
+	}
+
+	mutex := ms.Master().NewLock(ctx, entity.ClusterMetadataImportKey, time.Second*300)
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock metadata import err:[%s]", err.Error())
+		}
+	}()
+
+	for _, prefix := range []string{entity.PrefixDataBaseBody, entity.PrefixSpace, entity.PrefixPartition} {
+		keys, _, err := ms.Master().PrefixScan(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metadata import needs a fresh etcd, but %s has %d keys", prefix, len(keys)))
+		}
+	}
+
+	result := &MetadataImportResult{}
+	for _, role := range bundle.Roles {
+		if _, ok := entity.RoleMap[role.Name]; ok {
+			result.SkippedRoles = append(result.SkippedRoles, role.Name)
+			continue
+		}
+		created, err := ms.createMetaIfAbsent(ctx, entity.MetaKindRole, entity.RoleKey(role.Name), role)
+		if err != nil {
+			return result, err
+		}
+		if created {
+			result.Roles++
+		} else {
+			result.SkippedRoles = append(result.SkippedRoles, role.Name)
+		}
+	}
+	for _, user := range bundle.Users {
+		created, err := ms.createIfAbsent(ctx, entity.UserKey(user.Name), user)
+		if err != nil {
+			return result, err
+		}
+		if created {
+			result.Users++
+		} else {
+			result.SkippedUsers = append(result.SkippedUsers, user.Name)
+		}
+	}
+
+	var maxDB, maxSpace, maxPartition, maxNode int64
+	for _, db := range bundle.DBs {
+		err := ms.Master().STM(ctx, func(stm store.STM) error {
+			idKey, nameKey, bodyKey := ms.Master().DBKeys(db.Id, db.Name)
+			value, err := vjson.Marshal(db)
+			if err != nil {
+				return err
+			}
+			stm.Put(nameKey, cast.ToString(db.Id))
+			stm.Put(idKey, db.Name)
+			stm.Put(bodyKey, string(value))
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+		maxDB = max(maxDB, db.Id)
+		result.DBs++
+	}
+	for _, space := range bundle.Spaces {
+		if _, err := ms.createIfAbsent(ctx, entity.SpaceKey(space.DBId, space.Id), space); err != nil {
+			return result, err
+		}
+		maxSpace = max(maxSpace, space.Id)
+		result.Spaces++
+	}
+	for _, p := range bundle.Partitions {
+		if _, err := ms.createIfAbsent(ctx, entity.PartitionKey(p.Id), p); err != nil {
+			return result, err
+		}
+		maxPartition = max(maxPartition, int64(p.Id))
+		for _, nodeID := range p.Replicas {
+			maxNode = max(maxNode, int64(nodeID))
+		}
+		result.Partitions++
+	}
+	for _, alias := range bundle.Aliases {
+		if _, err := ms.createIfAbsent(ctx, entity.AliasKey(alias.Name), alias); err != nil {
+			return result, err
+		}
+		result.Aliases++
+	}
+
+	// ps keep their node ids, new nodes and meta should not reuse the imported ids
+	sequences := map[string]int64{
+		entity.DBIdSequence:        maxDB,
+		entity.SpaceIdSequence:     maxSpace,
+		entity.PartitionIdSequence: maxPartition,
+		entity.NodeIdSequence:      maxNode,
+	}
+	for key, id := range sequences {
+		err := ms.Master().STM(ctx, func(stm store.STM) error {
+			if v := stm.Get(key); v != "" && cast.ToInt64(v) >= id {
+				return nil
+			}
+			stm.Put(key, cast.ToString(id))
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+	log.Info("import metadata of cluster [%s] exported at [%d]: %+v", bundle.Cluster, bundle.ExportTime, result)
+	return result, nil
+}
+
+// createIfAbsent returns false without error if the key exists
+func (ms *masterService) createIfAbsent(ctx context.Context, key string, value interface{}) (bool, error) {
+	marshal, err := vjson.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	created := false
+	err = ms.Master().STM(ctx, func(stm store.STM) error {
+		if stm.Get(key) != "" {
+			return nil
+		}
+		stm.Put(key, string(marshal))
+		created = true
+		return nil
+	})
+	return created, err
+}
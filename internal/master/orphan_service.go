@@ -0,0 +1,192 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// orphanChecker reports the orphan partitions periodically, an orphan is
+// reported once it is found in two checks in a row since partitions being
+// created or moved look orphan for a moment
+type orphanChecker struct {
+	ms       *masterService
+	previous map[string]bool
+	reported map[string]bool
+}
+
+func newOrphanChecker(ms *masterService) *orphanChecker {
+	return &orphanChecker{ms: ms, previous: make(map[string]bool), reported: make(map[string]bool)}
+}
+
+func (oc *orphanChecker) start(ctx context.Context) {
+	go func() {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				log.Error("recover() err:[%v]", rErr)
+				log.Error("stack:[%s]", debug.Stack())
+			}
+		}()
+		interval := time.Duration(entity.DefaultOrphanCheckInterval) * time.Second
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("orphan checker stopped")
+				return
+			case <-time.After(interval):
+			}
+
+			// the lock is not released, it expires by ttl so only one master checks in an interval
+			mutex := oc.ms.Master().NewLock(ctx, entity.ClusterOrphanCheckKey, interval)
+			if getLock, err := mutex.TryLock(); !getLock || err != nil {
+				continue
+			}
+			report, err := oc.ms.orphanPartitionsService(ctx)
+			if err != nil {
+				log.Error("check orphan partitions err: %v", err)
+				continue
+			}
+			oc.check(report)
+		}
+	}()
+}
+
+func (oc *orphanChecker) check(report *entity.OrphanReport) {
+	current := make(map[string]bool, len(report.Orphans))
+	reported := make(map[string]bool, len(report.Orphans))
+	for _, orphan := range report.Orphans {
+		key := orphan.Key()
+		current[key] = true
+		if !oc.previous[key] {
+			continue
+		}
+		reported[key] = true
+		if oc.reported[key] {
+			continue
+		}
+		log.Warn("found orphan partition: %+v", orphan)
+		oc.ms.webhooks.publish(entity.EventOrphanPartition, orphan)
+	}
+	oc.previous = current
+	oc.reported = reported
+}
+
+func (ms *masterService) orphanPartitionsService(ctx context.Context) (*entity.OrphanReport, error) {
+	spaces, err := ms.Master().QuerySpacesByKey(ctx, entity.PrefixSpace)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := ms.Master().QueryServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dbs, err := ms.Master().QueryDBs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dbNames := make(map[entity.DBID]string, len(dbs))
+	for _, db := range dbs {
+		dbNames[db.Id] = db.Name
+	}
+	return &entity.OrphanReport{
+		Time:    time.Now().Unix(),
+		Orphans: entity.DetectOrphanPartitions(spaces, servers, dbNames),
+	}, nil
+}
+
+// resolveOrphanPartitionService adopts or deletes an orphan which is still in
+// the report, the plan is returned without doing it if not confirmed
+func (ms *masterService) resolveOrphanPartitionService(ctx context.Context, resolve *entity.OrphanResolve) (string, error) {
+	if err := resolve.Validate(); err != nil {
+		return "", err
+	}
+	report, err := ms.orphanPartitionsService(ctx)
+	if err != nil {
+		return "", err
+	}
+	var orphan *entity.OrphanPartition
+	for _, o := range report.Orphans {
+		if o.Key() == resolve.Key() {
+			orphan = o
+			break
+		}
+	}
+	if orphan == nil {
+		return "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition [%d] on server [%d] is not a %s now", resolve.PartitionID, resolve.NodeID, resolve.Kind))
+	}
+	if orphan.SpaceName == "" && resolve.Action == entity.OrphanActionAdopt {
+		return "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition [%d] is in no space, it can only be deleted", orphan.PartitionID))
+	}
+
+	var plan string
+	switch {
+	case orphan.Kind == entity.OrphanDirectory && resolve.Action == entity.OrphanActionAdopt:
+		plan = fmt.Sprintf("add server [%d] to the replicas of partition [%d] of space [%s/%s]", orphan.NodeID, orphan.PartitionID, orphan.DbName, orphan.SpaceName)
+	case orphan.Kind == entity.OrphanDirectory:
+		plan = fmt.Sprintf("delete the data of partition [%d] on server [%d]", orphan.PartitionID, orphan.NodeID)
+	case resolve.Action == entity.OrphanActionAdopt:
+		plan = fmt.Sprintf("create partition [%d] of space [%s/%s] on server [%d]", orphan.PartitionID, orphan.DbName, orphan.SpaceName, orphan.NodeID)
+	default:
+		plan = fmt.Sprintf("remove server [%d] from the replicas of partition [%d] of space [%s/%s]", orphan.NodeID, orphan.PartitionID, orphan.DbName, orphan.SpaceName)
+	}
+	if !resolve.Confirm {
+		return plan, nil
+	}
+
+	server, err := ms.Master().QueryServer(ctx, orphan.NodeID)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case orphan.Kind == entity.OrphanDirectory && resolve.Action == entity.OrphanActionAdopt:
+		err = ms.ChangeMember(ctx, &entity.ChangeMember{PartitionID: orphan.PartitionID, NodeID: orphan.NodeID, Method: proto.ConfAddNode})
+	case orphan.Kind == entity.OrphanDirectory:
+		err = psClient.DeletePartition(server.RpcAddr(), orphan.PartitionID)
+	case resolve.Action == entity.OrphanActionAdopt:
+		var space *entity.Space
+		if space, err = ms.orphanSpace(ctx, orphan); err == nil {
+			err = psClient.CreatePartition(server.RpcAddr(), space, orphan.PartitionID)
+		}
+	default:
+		var space *entity.Space
+		if space, err = ms.orphanSpace(ctx, orphan); err == nil {
+			if p := space.GetPartition(orphan.PartitionID); p != nil && len(p.Replicas) <= 1 {
+				return "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("server [%d] is the last replica of partition [%d], adopt it instead", orphan.NodeID, orphan.PartitionID))
+			}
+			err = ms.ChangeMember(ctx, &entity.ChangeMember{PartitionID: orphan.PartitionID, NodeID: orphan.NodeID, Method: proto.ConfRemoveNode})
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	log.Info("resolved orphan partition: %s", plan)
+	return plan, nil
+}
+
+func (ms *masterService) orphanSpace(ctx context.Context, orphan *entity.OrphanPartition) (*entity.Space, error) {
+	dbID, err := ms.Master().QueryDBName2Id(ctx, orphan.DbName)
+	if err != nil {
+		return nil, err
+	}
+	return ms.Master().QuerySpaceByName(ctx, dbID, orphan.SpaceName)
+}
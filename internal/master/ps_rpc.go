@@ -0,0 +1,64 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+// psRPC is the partition server calls used by placement, failover and
+// balancing, the simulation harness replaces it with fake partition servers
+type psRPC interface {
+	IsLive(addr string) bool
+	CreatePartition(addr string, space *entity.Space, pid entity.PartitionID) error
+	UpdatePartition(addr string, space *entity.Space, pid entity.PartitionID) error
+	DeletePartition(addr string, pid entity.PartitionID) error
+	DeleteReplica(addr string, pid entity.PartitionID) error
+	ChangeMember(addr string, cm *entity.ChangeMember) error
+	Checksum(addr string, pid entity.PartitionID, index uint64) (*entity.ReplicaChecksum, error)
+}
+
+var psClient psRPC = rpcPSClient{}
+
+type rpcPSClient struct{}
+
+func (rpcPSClient) IsLive(addr string) bool {
+	return client.IsLive(addr)
+}
+
+func (rpcPSClient) CreatePartition(addr string, space *entity.Space, pid entity.PartitionID) error {
+	return client.CreatePartition(addr, space, pid)
+}
+
+func (rpcPSClient) UpdatePartition(addr string, space *entity.Space, pid entity.PartitionID) error {
+	return client.UpdatePartition(addr, space, pid)
+}
+
+func (rpcPSClient) DeletePartition(addr string, pid entity.PartitionID) error {
+	return client.DeletePartition(addr, pid)
+}
+
+func (rpcPSClient) DeleteReplica(addr string, pid entity.PartitionID) error {
+	return client.DeleteReplica(addr, pid)
+}
+
+func (rpcPSClient) ChangeMember(addr string, cm *entity.ChangeMember) error {
+	return client.ChangeMember(addr, cm)
+}
+
+func (rpcPSClient) Checksum(addr string, pid entity.PartitionID, index uint64) (*entity.ReplicaChecksum, error) {
+	return client.Checksum(addr, pid, index)
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func (ms *masterService) quarantinedPartitionsService(ctx context.Context) ([]*entity.QuarantinedPartition, error) {
+	return ms.Master().QueryQuarantines(ctx)
+}
+
+// rebuildPartitionService asks the ps of a quarantined replica to recover
+// it from the other replicas
+func (ms *masterService) rebuildPartitionService(ctx context.Context, rebuild *entity.PartitionRebuild) error {
+	if err := rebuild.Validate(); err != nil {
+		return err
+	}
+	quarantines, err := ms.Master().QueryQuarantines(ctx)
+	if err != nil {
+		return err
+	}
+	quarantined := false
+	for _, q := range quarantines {
+		if q.PartitionID == rebuild.PartitionID && q.NodeID == rebuild.NodeID {
+			quarantined = true
+			break
+		}
+	}
+	if !quarantined {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition [%d] is not quarantined on server [%d]", rebuild.PartitionID, rebuild.NodeID))
+	}
+	server, err := ms.Master().QueryServer(ctx, rebuild.NodeID)
+	if err != nil {
+		return err
+	}
+	log.Info("rebuild quarantined partition [%d] on server [%d]", rebuild.PartitionID, rebuild.NodeID)
+	return client.RebuildReplica(server.RpcAddr(), rebuild.PartitionID)
+}
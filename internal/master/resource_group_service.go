@@ -0,0 +1,103 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// putResourceGroupService creates the resource group, or updates it when
+// update is set, ps watch the groups and apply the caps at once
+func (ms *masterService) putResourceGroupService(ctx context.Context, group *entity.ResourceGroup, update bool) (err error) {
+	if err = group.Validate(); err != nil {
+		return err
+	}
+	mutex := ms.Master().NewLock(ctx, entity.LockResourceGroupKey(group.Name), time.Second*30)
+	if err = mutex.Lock(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock lock for put resource group err %s", err)
+		}
+	}()
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
+		groupKey := entity.ResourceGroupKey(group.Name)
+		exists := stm.Get(groupKey) != ""
+		if exists && !update {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("resource group %s is exists", group.Name))
+		}
+		if !exists && update {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("resource group %s not exists", group.Name))
+		}
+		marshal, err := vjson.Marshal(group)
+		if err != nil {
+			return err
+		}
+		stm.Put(groupKey, string(marshal))
+		return nil
+	})
+	return err
+}
+
+// deleteResourceGroupService deletes the resource group not used by any space
+func (ms *masterService) deleteResourceGroupService(ctx context.Context, name string) (err error) {
+	if _, err = ms.queryResourceGroupService(ctx, name); err != nil {
+		return err
+	}
+	mutex := ms.Master().NewLock(ctx, entity.LockResourceGroupKey(name), time.Second*30)
+	if err = mutex.Lock(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock lock for delete resource group err %s", err)
+		}
+	}()
+	spaces, err := ms.Master().QuerySpacesByKey(ctx, entity.PrefixSpace)
+	if err != nil {
+		return err
+	}
+	for _, space := range spaces {
+		if space.ResourceGroup == name {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("resource group %s is used by space %s", name, space.Name))
+		}
+	}
+	return ms.Master().Delete(ctx, entity.ResourceGroupKey(name))
+}
+
+func (ms *masterService) queryResourceGroupService(ctx context.Context, name string) (*entity.ResourceGroup, error) {
+	bs, err := ms.Master().Get(ctx, entity.ResourceGroupKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("resource group %s not exists", name))
+	}
+	group := &entity.ResourceGroup{}
+	if err = vjson.Unmarshal(bs, group); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get resource group:%s value:%s, err:%s", name, redact.Payload(bs), err.Error()))
+	}
+	return group, nil
+}
@@ -22,9 +22,9 @@ import (
 
 	"github.com/vearch/vearch/v3/internal/client"
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
-	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
 const CronInterval = 60
@@ -34,7 +34,7 @@ func walkPartitions(masterServer *Server, partitions []*entity.Partition) {
 	log.Debug("Start Walking Partitions!")
 	for _, partition := range partitions {
 		if space, err := masterServer.client.Master().QuerySpaceByID(ctx, partition.DBId, partition.SpaceId); err != nil {
-			if vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError().Code == vearchpb.ErrorEnum_SPACE_NOT_EXIST {
+			if errors.Is(err, vearchpb.ErrSpaceNotFound) {
 				log.Warnf("Could not find Space contains partition,PartitionID:[%d] so remove it from etcd!", partition.Id)
 				partitionKey := entity.PartitionKey(partition.Id)
 				if err := masterServer.client.Master().Delete(ctx, partitionKey); err != nil {
@@ -75,7 +75,7 @@ func walkSpaces(masterServer *Server, spaces []*entity.Space) {
 
 func removePartition(partitionServerRpcAddr string, pid entity.PartitionID) error {
 	log.Debugf("Removing partition:[%s] from ps:[%s]", pid, partitionServerRpcAddr)
-	return client.DeletePartition(partitionServerRpcAddr, pid)
+	return psClient.DeletePartition(partitionServerRpcAddr, pid)
 }
 
 func walkServers(masterServer *Server, servers []*entity.Server) {
@@ -84,7 +84,7 @@ func walkServers(masterServer *Server, servers []*entity.Server) {
 	for _, server := range servers {
 		for _, pid := range server.PartitionIds {
 			if _, err := masterServer.client.Master().QueryPartition(ctx, pid); err != nil {
-				if vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError().Code == vearchpb.ErrorEnum_PARTITION_NOT_EXIST {
+				if errors.Is(err, vearchpb.ErrPartitionNotFound) {
 					log.Warnf("to remove partition:%d", pid)
 					if err := removePartition(server.RpcAddr(), pid); err != nil {
 						log.Warnf("Failed to remove partition: %v allocated on server: %v, and err is:%v", pid, server.ID, err)
@@ -101,7 +101,7 @@ func walkServers(masterServer *Server, servers []*entity.Server) {
 var errSkipJob = errors.New("skip job")
 
 func CleanTask(masterServer *Server) {
-	var err = masterServer.client.Master().STM(masterServer.ctx, func(stm concurrency.STM) error {
+	var err = masterServer.client.Master().STM(masterServer.ctx, func(stm store.STM) error {
 		timeBytes := stm.Get(entity.ClusterCleanJobKey)
 		if len(timeBytes) == 0 {
 			return nil
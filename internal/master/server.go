@@ -27,11 +27,11 @@ import (
 	"github.com/vearch/vearch/v3/internal/client"
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
-	"go.etcd.io/etcd/client/v3/concurrency"
 	"go.etcd.io/etcd/server/v3/embed"
 	"go.etcd.io/etcd/server/v3/etcdserver"
 )
@@ -112,6 +112,14 @@ func (s *Server) Start() (err error) {
 	if err != nil {
 		return err
 	}
+	service.webhooks.start(s.ctx)
+	service.alerts.start(s.ctx)
+	service.leaders.start(s.ctx)
+	service.placer.start(s.ctx)
+	service.etcd.start(s.ctx)
+	service.orphans.start(s.ctx)
+	service.cron.start(s.ctx)
+	service.startUsageCleaner(s.ctx)
 
 	monitorService := &monitorService{}
 	if config.Conf().Global.SelfManageEtcd {
@@ -207,7 +215,7 @@ func (s *Server) Start() (err error) {
 		log.Error(msg)
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf(msg))
 	}
-	err = s.client.Master().STM(context.Background(), func(stm concurrency.STM) error {
+	err = s.client.Master().STM(context.Background(), func(stm store.STM) error {
 		marshal, err := vjson.Marshal(master)
 		if err != nil {
 			return err
@@ -0,0 +1,163 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// spaceProperties returns the fields of the space dbName/spaceName
+func (ms *masterService) spaceProperties(ctx context.Context, dbName, spaceName string) (map[string]*entity.SpaceProperties, error) {
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	return entity.UnmarshalPropertyJSON(space.Fields)
+}
+
+// createSimilarityJoinService checks the spaces of the join and saves it for
+// routers to run
+func (ms *masterService) createSimilarityJoinService(ctx context.Context, j *entity.SimilarityJoin) (*entity.SimilarityJoin, error) {
+	if err := j.Validate(); err != nil {
+		return nil, err
+	}
+	if old, err := ms.Master().Get(ctx, entity.SimilarityJoinKey(j.Name)); err != nil {
+		return nil, err
+	} else if old != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("similarity join %s exists", j.Name))
+	}
+
+	source, err := ms.spaceProperties(ctx, j.DbName, j.SpaceName)
+	if err != nil {
+		return nil, err
+	}
+	target, err := ms.spaceProperties(ctx, j.TargetDbName, j.TargetSpaceName)
+	if err != nil {
+		return nil, err
+	}
+	field, targetField := source[j.Field], target[j.TargetField]
+	if field == nil || field.FieldType != vearchpb.FieldType_VECTOR {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s should be a vector field of space %s", j.Field, j.SpaceName))
+	}
+	if targetField == nil || targetField.FieldType != vearchpb.FieldType_VECTOR {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("target_field %s should be a vector field of space %s", j.TargetField, j.TargetSpaceName))
+	}
+	if field.Dimension != targetField.Dimension {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dimension of field %s is %d, target_field %s is %d", j.Field, field.Dimension, j.TargetField, targetField.Dimension))
+	}
+	if j.Output.SpaceName != "" {
+		output, err := ms.spaceProperties(ctx, j.Output.DbName, j.Output.SpaceName)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range []string{entity.SimilarityJoinSourceField, entity.SimilarityJoinTargetField} {
+			if p := output[name]; p == nil || p.FieldType != vearchpb.FieldType_STRING {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("output space %s should have string field %s", j.Output.SpaceName, name))
+			}
+		}
+		if p := output[entity.SimilarityJoinScoreField]; p == nil || (p.FieldType != vearchpb.FieldType_FLOAT && p.FieldType != vearchpb.FieldType_DOUBLE) {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("output space %s should have float field %s", j.Output.SpaceName, entity.SimilarityJoinScoreField))
+		}
+	}
+
+	j.Status = entity.MigrationRunning
+	j.Partition, j.Cursor = 0, -1
+	j.Joined, j.Matches, j.Files, j.Error, j.Runner = 0, 0, 0, "", ""
+	j.StartTime, j.UpdateTime, j.FinishTime = time.Now().Unix(), 0, 0
+	bs, err := vjson.Marshal(j)
+	if err != nil {
+		return nil, err
+	}
+	if err := ms.Master().Put(ctx, entity.SimilarityJoinKey(j.Name), bs); err != nil {
+		return nil, err
+	}
+	log.Info("create similarity join %s of space %s/%s.%s to %s/%s.%s top %d", j.Name, j.DbName, j.SpaceName, j.Field,
+		j.TargetDbName, j.TargetSpaceName, j.TargetField, j.TopK)
+	return j, nil
+}
+
+func (ms *masterService) getSimilarityJoinService(ctx context.Context, name string) (*entity.SimilarityJoin, error) {
+	bs, err := ms.Master().Get(ctx, entity.SimilarityJoinKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("similarity join %s not found", name))
+	}
+	j := &entity.SimilarityJoin{}
+	if err := vjson.Unmarshal(bs, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (ms *masterService) listSimilarityJoinsService(ctx context.Context) ([]*entity.SimilarityJoin, error) {
+	_, values, err := ms.Master().PrefixScan(ctx, entity.PrefixSimilarityJoin)
+	if err != nil {
+		return nil, err
+	}
+	joins := make([]*entity.SimilarityJoin, 0, len(values))
+	for _, value := range values {
+		j := &entity.SimilarityJoin{}
+		if err := vjson.Unmarshal(value, j); err != nil {
+			return nil, err
+		}
+		joins = append(joins, j)
+	}
+	sort.Slice(joins, func(i, k int) bool { return joins[i].StartTime > joins[k].StartTime })
+	return joins, nil
+}
+
+// cancelSimilarityJoinService stops the join, the matches written are kept
+func (ms *masterService) cancelSimilarityJoinService(ctx context.Context, name string) (*entity.SimilarityJoin, error) {
+	j := &entity.SimilarityJoin{}
+	err := ms.Master().STM(ctx, func(stm store.STM) error {
+		value := stm.Get(entity.SimilarityJoinKey(name))
+		if value == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("similarity join %s not found", name))
+		}
+		if err := vjson.Unmarshal([]byte(value), j); err != nil {
+			return err
+		}
+		if j.Finished() {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("similarity join %s is %s", name, j.Status))
+		}
+		j.Status = entity.MigrationCanceled
+		j.FinishTime = time.Now().Unix()
+		bs, err := vjson.Marshal(j)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.SimilarityJoinKey(name), string(bs))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
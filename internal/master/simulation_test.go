@@ -0,0 +1,510 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+// The simulation harness runs the master scheduling logic on an in-memory
+// meta store and fake partition servers. Fake servers register partitions
+// and elect leaders like the real ones, and fail by script, so placement,
+// failover and balancing can be checked without a real cluster.
+
+const simFields = `[{"name":"vec","type":"vector","dimension":8,"index":{"name":"vec_idx","type":"FLAT","params":{"metric_type":"L2"}}}]`
+
+// simPS is a fake partition server
+type simPS struct {
+	server *entity.Server
+	down   bool
+}
+
+type simCluster struct {
+	t     *testing.T
+	ctx   context.Context
+	store *store.MemStore
+	ms    *masterService
+
+	mu      sync.Mutex
+	nodes   map[entity.NodeID]*simPS
+	created map[entity.PartitionID]map[entity.NodeID]bool
+	// crashes are scripted failures, the node crashes before the n-th ps call
+	crashes map[int][]entity.NodeID
+	calls   int
+}
+
+var simConfigOnce sync.Once
+
+func newSimCluster(t *testing.T, antiAffinity int) *simCluster {
+	simConfigOnce.Do(func() {
+		path := filepath.Join(t.TempDir(), "sim.toml")
+		if err := os.WriteFile(path, []byte("[global]\nname = \"sim\"\n[ps]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config.InitConfig(path)
+	})
+	config.Conf().PS.ReplicaAntiAffinityStrategy = antiAffinity
+
+	memStore := store.NewMemStore()
+	cli, err := client.NewClientWithStore(config.Conf(), memStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newMasterService(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc := &simCluster{
+		t:       t,
+		ctx:     context.Background(),
+		store:   memStore,
+		ms:      ms,
+		nodes:   make(map[entity.NodeID]*simPS),
+		created: make(map[entity.PartitionID]map[entity.NodeID]bool),
+		crashes: make(map[int][]entity.NodeID),
+	}
+	old := psClient
+	psClient = sc
+	t.Cleanup(func() { psClient = old })
+	return sc
+}
+
+// addNode starts a fake partition server and registers it to the meta store
+func (sc *simCluster) addNode(id entity.NodeID, zone string) {
+	server := &entity.Server{
+		ID:       id,
+		Ip:       fmt.Sprintf("10.0.0.%d", id),
+		RpcPort:  8081,
+		HostIp:   fmt.Sprintf("10.0.0.%d", id),
+		HostZone: zone,
+	}
+	sc.mu.Lock()
+	sc.nodes[id] = &simPS{server: server}
+	sc.mu.Unlock()
+	sc.putServer(server)
+}
+
+func (sc *simCluster) putServer(server *entity.Server) {
+	value, err := vjson.Marshal(server)
+	if err != nil {
+		sc.t.Fatal(err)
+	}
+	if err := sc.store.Put(sc.ctx, entity.ServerKey(server.ID), value); err != nil {
+		sc.t.Fatal(err)
+	}
+}
+
+// crashAt scripts node to crash before the n-th ps call, counted from 1
+func (sc *simCluster) crashAt(n int, id entity.NodeID) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.crashes[n] = append(sc.crashes[n], id)
+}
+
+// crash stops a node as its lease expires: the server key is removed, a fail
+// server record is kept and the partitions led by it elect new leaders
+func (sc *simCluster) crash(id entity.NodeID) {
+	sc.mu.Lock()
+	node := sc.nodes[id]
+	node.down = true
+	server := node.server
+	sc.mu.Unlock()
+
+	if err := sc.store.Delete(sc.ctx, entity.ServerKey(id)); err != nil {
+		sc.t.Fatal(err)
+	}
+	value, err := vjson.Marshal(&entity.FailServer{ID: id, TimeStamp: time.Now().Unix(), Node: server})
+	if err != nil {
+		sc.t.Fatal(err)
+	}
+	if err := sc.store.Put(sc.ctx, entity.FailServerKey(id), value); err != nil {
+		sc.t.Fatal(err)
+	}
+
+	partitions, err := sc.ms.Master().QueryPartitions(sc.ctx)
+	if err != nil {
+		sc.t.Fatal(err)
+	}
+	for _, p := range partitions {
+		if p.LeaderID != id {
+			continue
+		}
+		live := make([]entity.NodeID, 0, len(p.Replicas))
+		for _, r := range p.Replicas {
+			if sc.isUp(r) {
+				live = append(live, r)
+			}
+		}
+		// raft needs a quorum to elect a new leader
+		if len(live)*2 <= len(p.Replicas) {
+			continue
+		}
+		sort.Slice(live, func(i, j int) bool { return live[i] < live[j] })
+		p.LeaderID = live[0]
+		if err := sc.ms.registerPartitionService(sc.ctx, p); err != nil {
+			sc.t.Fatal(err)
+		}
+	}
+}
+
+func (sc *simCluster) isUp(id entity.NodeID) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	node, ok := sc.nodes[id]
+	return ok && !node.down
+}
+
+// call counts a ps call, applies the scripted crashes and returns the node
+// of addr if it is up
+func (sc *simCluster) call(addr string) (*simPS, error) {
+	sc.mu.Lock()
+	sc.calls++
+	crashes := sc.crashes[sc.calls]
+	sc.mu.Unlock()
+	for _, id := range crashes {
+		sc.crash(id)
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for _, node := range sc.nodes {
+		if node.server.RpcAddr() == addr {
+			if node.down {
+				return nil, fmt.Errorf("server:[%s] is down", addr)
+			}
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("server:[%s] not exist", addr)
+}
+
+func (sc *simCluster) IsLive(addr string) bool {
+	_, err := sc.call(addr)
+	return err == nil
+}
+
+// CreatePartition registers the partition once all of its replicas are
+// created, as the raft group can only serve after that
+func (sc *simCluster) CreatePartition(addr string, space *entity.Space, pid entity.PartitionID) error {
+	node, err := sc.call(addr)
+	if err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	node.server.PartitionIds = append(node.server.PartitionIds, pid)
+	server := *node.server
+	if sc.created[pid] == nil {
+		sc.created[pid] = make(map[entity.NodeID]bool)
+	}
+	sc.created[pid][server.ID] = true
+	created := len(sc.created[pid])
+	sc.mu.Unlock()
+	sc.putServer(&server)
+
+	p := space.GetPartition(pid)
+	if p == nil || created != len(p.Replicas) {
+		return nil
+	}
+	if old, _ := sc.ms.Master().QueryPartition(sc.ctx, pid); old != nil {
+		return nil
+	}
+	return sc.ms.registerPartitionService(sc.ctx, &entity.Partition{
+		Id:       pid,
+		SpaceId:  space.Id,
+		DBId:     space.DBId,
+		Slot:     p.Slot,
+		LeaderID: p.Replicas[0],
+		Replicas: p.Replicas,
+	})
+}
+
+func (sc *simCluster) UpdatePartition(addr string, space *entity.Space, pid entity.PartitionID) error {
+	_, err := sc.call(addr)
+	return err
+}
+
+func (sc *simCluster) DeletePartition(addr string, pid entity.PartitionID) error {
+	return sc.DeleteReplica(addr, pid)
+}
+
+func (sc *simCluster) DeleteReplica(addr string, pid entity.PartitionID) error {
+	node, err := sc.call(addr)
+	if err != nil {
+		return err
+	}
+	sc.mu.Lock()
+	ids := make([]entity.PartitionID, 0, len(node.server.PartitionIds))
+	for _, id := range node.server.PartitionIds {
+		if id != pid {
+			ids = append(ids, id)
+		}
+	}
+	node.server.PartitionIds = ids
+	server := *node.server
+	sc.mu.Unlock()
+	sc.putServer(&server)
+	return nil
+}
+
+// ChangeMember is applied by the leader and registers the new replicas
+func (sc *simCluster) ChangeMember(addr string, cm *entity.ChangeMember) error {
+	if _, err := sc.call(addr); err != nil {
+		return err
+	}
+	p, err := sc.ms.Master().QueryPartition(sc.ctx, cm.PartitionID)
+	if err != nil {
+		return err
+	}
+	replicas := make([]entity.NodeID, 0, len(p.Replicas)+1)
+	for _, r := range p.Replicas {
+		if r != cm.NodeID {
+			replicas = append(replicas, r)
+		}
+	}
+	if cm.Method == proto.ConfAddNode {
+		replicas = append(replicas, cm.NodeID)
+	}
+	p.Replicas = replicas
+	return sc.ms.registerPartitionService(sc.ctx, p)
+}
+
+// Checksum of fake replicas without documents, all replicas agree
+func (sc *simCluster) Checksum(addr string, pid entity.PartitionID, index uint64) (*entity.ReplicaChecksum, error) {
+	node, err := sc.call(addr)
+	if err != nil {
+		return nil, err
+	}
+	if index == 0 {
+		index = 1
+	}
+	return &entity.ReplicaChecksum{PartitionID: pid, NodeID: node.server.ID, Index: index}, nil
+}
+
+func (sc *simCluster) createDB(name string) {
+	if err := sc.ms.createDBService(sc.ctx, &entity.DB{Name: name}); err != nil {
+		sc.t.Fatal(err)
+	}
+}
+
+func (sc *simCluster) createSpace(dbName, name string, partitionNum int, replicaNum uint8) (*entity.Space, error) {
+	space := &entity.Space{
+		Name:         name,
+		PartitionNum: partitionNum,
+		ReplicaNum:   replicaNum,
+		Fields:       []byte(simFields),
+	}
+	ctx, cancel := context.WithTimeout(sc.ctx, 10*time.Second)
+	defer cancel()
+	if err := sc.ms.createSpaceService(ctx, dbName, space); err != nil {
+		return nil, err
+	}
+	return space, nil
+}
+
+// placement returns the replicas of each partition of space in the meta store
+func (sc *simCluster) placement(space *entity.Space) [][]entity.NodeID {
+	stored, err := sc.ms.Master().QuerySpaceByID(sc.ctx, space.DBId, space.Id)
+	if err != nil {
+		sc.t.Fatal(err)
+	}
+	result := make([][]entity.NodeID, 0, len(stored.Partitions))
+	for _, p := range stored.Partitions {
+		result = append(result, p.Replicas)
+	}
+	return result
+}
+
+// replicaCount returns the replica num on each node of all spaces
+func replicaCount(placements ...[][]entity.NodeID) map[entity.NodeID]int {
+	count := make(map[entity.NodeID]int)
+	for _, placement := range placements {
+		for _, replicas := range placement {
+			for _, id := range replicas {
+				count[id]++
+			}
+		}
+	}
+	return count
+}
+
+func TestSimPlacementIsBalancedAndDeterministic(t *testing.T) {
+	run := func() [][]entity.NodeID {
+		sc := newSimCluster(t, 0)
+		for id := entity.NodeID(1); id <= 4; id++ {
+			sc.addNode(id, "")
+		}
+		sc.createDB("db")
+		space, err := sc.createSpace("db", "space", 8, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sc.placement(space)
+	}
+
+	first := run()
+	for id, n := range replicaCount(first) {
+		if n != 4 {
+			t.Fatalf("node %d has %d replicas, want 4, placement %v", id, n, first)
+		}
+	}
+	for _, replicas := range first {
+		if replicas[0] == replicas[1] {
+			t.Fatalf("replicas of one partition on the same node: %v", first)
+		}
+	}
+	if second := run(); !reflect.DeepEqual(first, second) {
+		t.Fatalf("placement is not deterministic: %v and %v", first, second)
+	}
+}
+
+func TestSimPlacementAntiAffinityByZone(t *testing.T) {
+	sc := newSimCluster(t, 3)
+	zones := map[entity.NodeID]string{1: "a", 2: "a", 3: "b", 4: "b"}
+	for id := entity.NodeID(1); id <= 4; id++ {
+		sc.addNode(id, zones[id])
+	}
+	sc.createDB("db")
+	space, err := sc.createSpace("db", "space", 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, replicas := range sc.placement(space) {
+		if zones[replicas[0]] == zones[replicas[1]] {
+			t.Fatalf("replicas %v in the same zone", replicas)
+		}
+	}
+
+	// only two zones, three replicas can not be placed
+	if _, err := sc.createSpace("db", "space3", 1, 3); err == nil {
+		t.Fatal("expect error for three replicas in two zones")
+	}
+}
+
+func TestSimPlacementSkipsFailedNode(t *testing.T) {
+	sc := newSimCluster(t, 0)
+	for id := entity.NodeID(1); id <= 3; id++ {
+		sc.addNode(id, "")
+	}
+	sc.createDB("db")
+	sc.crash(2)
+
+	space, err := sc.createSpace("db", "space", 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := replicaCount(sc.placement(space))[2]; n != 0 {
+		t.Fatalf("%d replicas placed on crashed node 2", n)
+	}
+}
+
+func TestSimCreateSpaceFailsWhenReplicaCrashes(t *testing.T) {
+	sc := newSimCluster(t, 0)
+	for id := entity.NodeID(1); id <= 2; id++ {
+		sc.addNode(id, "")
+	}
+	sc.createDB("db")
+	// calls: two liveness checks in placement, then the partition creates
+	sc.crashAt(4, 2)
+
+	if _, err := sc.createSpace("db", "space", 1, 2); err == nil {
+		t.Fatal("expect create space error when a replica crashes")
+	}
+	spaces, err := sc.ms.Master().QuerySpacesByKey(sc.ctx, entity.PrefixSpace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spaces) != 0 {
+		t.Fatalf("space is not removed after failure: %v", spaces)
+	}
+}
+
+func TestSimFailoverAndRecover(t *testing.T) {
+	sc := newSimCluster(t, 0)
+	for id := entity.NodeID(1); id <= 3; id++ {
+		sc.addNode(id, "")
+	}
+	sc.createDB("db")
+	space, err := sc.createSpace("db", "space", 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc.crash(1)
+	partitions, err := sc.ms.Master().QueryPartitions(sc.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range partitions {
+		if p.LeaderID == 1 {
+			t.Fatalf("partition %d still led by crashed node 1", p.Id)
+		}
+	}
+
+	sc.addNode(4, "")
+	if err := sc.ms.RecoverFailServer(sc.ctx, &entity.RecoverFailServer{FailNodeAddr: "10.0.0.1", NewNodeAddr: "10.0.0.4"}); err != nil {
+		t.Fatal(err)
+	}
+	count := replicaCount(sc.placement(space))
+	if count[1] != 0 || count[4] != 3 {
+		t.Fatalf("replicas not moved from node 1 to node 4: %v", count)
+	}
+	failServers, err := sc.ms.Master().QueryAllFailServer(sc.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failServers) != 0 {
+		t.Fatalf("fail server record not removed: %v", failServers)
+	}
+}
+
+func TestSimNewNodeIsPreferred(t *testing.T) {
+	sc := newSimCluster(t, 0)
+	for id := entity.NodeID(1); id <= 2; id++ {
+		sc.addNode(id, "")
+	}
+	sc.createDB("db")
+	first, err := sc.createSpace("db", "first", 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc.addNode(3, "")
+	second, err := sc.createSpace("db", "second", 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := replicaCount(sc.placement(first), sc.placement(second))
+	want := map[entity.NodeID]int{1: 3, 2: 3, 3: 2}
+	if !reflect.DeepEqual(count, want) {
+		t.Fatalf("replica count %v, want %v", count, want)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// the source opens a snapshot of every partition before it replies, which
+// copies the engine files of each of them
+const spaceCopySourceTimeout = 30 * time.Minute
+
+// copySourceService opens a snapshot of every partition of space on its
+// leader for the ps of another cluster to copy them
+func (ms *masterService) copySourceService(ctx context.Context, dbName, spaceName string) (*entity.SpaceCopySource, error) {
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+	source := &entity.SpaceCopySource{Space: space, Partitions: make([]*entity.PartitionCopySource, 0, len(space.Partitions))}
+	for _, p := range space.Partitions {
+		partition, err := ms.Master().QueryPartition(ctx, p.Id)
+		if err != nil {
+			return nil, err
+		}
+		leader := partition.LeaderID
+		if leader == 0 && len(partition.Replicas) > 0 {
+			leader = partition.Replicas[0]
+		}
+		server, err := ms.Master().QueryServer(ctx, leader)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := client.OpenSnapshot(server.RpcAddr(), partition.Id)
+		if err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("open snapshot of partition %d on node %d: %v", partition.Id, leader, err))
+		}
+		source.Partitions = append(source.Partitions, &entity.PartitionCopySource{
+			PartitionID: partition.Id,
+			Slot:        partition.Slot,
+			Addr:        server.RpcAddr(),
+			Session:     manifest.Session,
+			Files:       manifest.Files,
+		})
+	}
+	return source, nil
+}
+
+// CopySpaceService starts copying the space of another cluster into every
+// replica of the partitions of space, partitions are matched by slot, or
+// returns the status of the copy of every replica. The replicas of a
+// partition pull the same snapshot so they stay the same
+func (ms *masterService) CopySpaceService(ctx context.Context, dbName, spaceName string, spaceCopy *entity.SpaceCopy) ([]*entity.SpaceCopyStatus, error) {
+	dbId, err := ms.Master().QueryDBName2Id(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ms.Master().QuerySpaceByName(ctx, dbId, spaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[entity.SlotID]*entity.PartitionCopySource)
+	if spaceCopy.Command == entity.SpaceCopyCommandStart {
+		if spaceCopy.SourceMaster == "" || spaceCopy.SourceDb == "" || spaceCopy.SourceSpace == "" {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("source_master, source_db and source_space should be set"))
+		}
+		if spaceCopy.RateLimit < 0 {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rate_limit should not be negative"))
+		}
+		source, err := fetchCopySource(ctx, spaceCopy)
+		if err != nil {
+			return nil, err
+		}
+		if err := space.CheckCopySource(source.Space); err != nil {
+			return nil, err
+		}
+		for _, p := range source.Partitions {
+			sources[p.Slot] = p
+		}
+		log.Info("copy space %s/%s of %s into %s/%s", spaceCopy.SourceDb, spaceCopy.SourceSpace, spaceCopy.SourceMaster, dbName, spaceName)
+		// the documents written while copying would be lost
+		reason := fmt.Sprintf("copying %s/%s of %s", spaceCopy.SourceDb, spaceCopy.SourceSpace, spaceCopy.SourceMaster)
+		if _, err := ms.pauseSpaceWritesService(ctx, dbName, spaceName, &entity.WritePause{Owner: entity.WritePauseSpaceCopy, Reason: reason}); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]*entity.SpaceCopyStatus, 0, len(space.Partitions))
+	for _, p := range space.Partitions {
+		partition, err := ms.Master().QueryPartition(ctx, p.Id)
+		if err != nil {
+			return nil, err
+		}
+		cmd := &entity.SpaceCopy{Command: spaceCopy.Command, RateLimit: spaceCopy.RateLimit}
+		if spaceCopy.Command == entity.SpaceCopyCommandStart {
+			if cmd.Source = sources[partition.Slot]; cmd.Source == nil {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("source has no partition of slot %d", partition.Slot))
+			}
+		}
+		for _, nodeID := range partition.Replicas {
+			server, err := ms.Master().QueryServer(ctx, nodeID)
+			if err != nil {
+				return nil, err
+			}
+			status, err := client.CopyPartition(server.RpcAddr(), cmd, partition.Id)
+			if err != nil {
+				log.Error("copy partition %d on node %d err: %v", partition.Id, nodeID, err)
+				status = &entity.SpaceCopyStatus{PartitionID: partition.Id, NodeID: nodeID, State: entity.SpaceCopyFailed, Error: err.Error()}
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	if spaceCopy.Command != entity.SpaceCopyCommandStart && space.WritePause != nil && copyDone(statuses) {
+		if _, err := ms.resumeSpaceWritesService(ctx, dbName, spaceName, entity.WritePauseSpaceCopy); err != nil {
+			log.Error("resume writes of space %s/%s after copy err: %v", dbName, spaceName, err)
+		}
+	}
+	return statuses, nil
+}
+
+// copyDone is whether every replica finished the copy, a failed copy keeps
+// the writes paused for operators to resume them
+func copyDone(statuses []*entity.SpaceCopyStatus) bool {
+	for _, status := range statuses {
+		if status.State != entity.SpaceCopyDone {
+			return false
+		}
+	}
+	return len(statuses) > 0
+}
+
+// fetchCopySource asks the master of the source cluster to open the
+// snapshots of the source space
+func fetchCopySource(ctx context.Context, spaceCopy *entity.SpaceCopy) (*entity.SpaceCopySource, error) {
+	endpoint := strings.TrimSuffix(spaceCopy.SourceMaster, "/")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	endpoint = fmt.Sprintf("%s/dbs/%s/spaces/%s/copy_source", endpoint, url.PathEscape(spaceCopy.SourceDb), url.PathEscape(spaceCopy.SourceSpace))
+
+	ctx, cancel := context.WithTimeout(ctx, spaceCopySourceTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(nil))
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
+	if spaceCopy.User != "" {
+		req.SetBasicAuth(spaceCopy.User, spaceCopy.Password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("request copy source %s: %v", endpoint, err))
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	reply := &struct {
+		Code int                     `json:"code"`
+		Msg  string                  `json:"msg"`
+		Data *entity.SpaceCopySource `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, reply); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("copy source %s status %d: %s", endpoint, resp.StatusCode, string(body)))
+	}
+	if reply.Code != int(vearchpb.ErrorEnum_SUCCESS) || reply.Data == nil || reply.Data.Space == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("copy source %s code %d: %s", endpoint, reply.Code, reply.Msg))
+	}
+	return reply.Data, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// putSpaceTemplateService creates the space template, or updates it when
+// update is set. The spaces created from the template keep the former
+// configuration
+func (ms *masterService) putSpaceTemplateService(ctx context.Context, template *entity.SpaceTemplate, update bool) (err error) {
+	if err = template.Validate(); err != nil {
+		return err
+	}
+	if template.ResourceGroup != "" {
+		if _, err = ms.queryResourceGroupService(ctx, template.ResourceGroup); err != nil {
+			return err
+		}
+	}
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceTemplateKey(template.Name), time.Second*30)
+	if err = mutex.Lock(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock lock for put space template err %s", err)
+		}
+	}()
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
+		templateKey := entity.SpaceTemplateKey(template.Name)
+		exists := stm.Get(templateKey) != ""
+		if exists && !update {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space template %s is exists", template.Name))
+		}
+		if !exists && update {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space template %s not exists", template.Name))
+		}
+		marshal, err := vjson.Marshal(template)
+		if err != nil {
+			return err
+		}
+		stm.Put(templateKey, string(marshal))
+		return nil
+	})
+	return err
+}
+
+// deleteSpaceTemplateService deletes the space template, the spaces created
+// from it are kept
+func (ms *masterService) deleteSpaceTemplateService(ctx context.Context, name string) (err error) {
+	if _, err = ms.querySpaceTemplateService(ctx, name); err != nil {
+		return err
+	}
+	mutex := ms.Master().NewLock(ctx, entity.LockSpaceTemplateKey(name), time.Second*30)
+	if err = mutex.Lock(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock lock for delete space template err %s", err)
+		}
+	}()
+	return ms.Master().Delete(ctx, entity.SpaceTemplateKey(name))
+}
+
+func (ms *masterService) querySpaceTemplateService(ctx context.Context, name string) (*entity.SpaceTemplate, error) {
+	bs, err := ms.Master().Get(ctx, entity.SpaceTemplateKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space template %s not exists", name))
+	}
+	template := &entity.SpaceTemplate{}
+	if err = vjson.Unmarshal(bs, template); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get space template:%s value:%s, err:%s", name, redact.Payload(bs), err.Error()))
+	}
+	return template, nil
+}
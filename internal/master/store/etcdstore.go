@@ -16,12 +16,16 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/pkg/fault"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
 )
@@ -41,7 +45,7 @@ func (store *EtcdStore) NewIDGenerate(ctx context.Context, key string, base int6
 		nextID = int64(0)
 		err    error
 	)
-	err = store.STM(ctx, func(stm concurrency.STM) error {
+	err = store.STM(ctx, func(stm STM) error {
 		v := stm.Get(key)
 		if len(v) == 0 {
 			stm.Put(key, fmt.Sprintf("%v", base))
@@ -65,7 +69,7 @@ func (store *EtcdStore) NewIDGenerate(ctx context.Context, key string, base int6
 	return nextID, nil
 }
 
-func (store *EtcdStore) NewLock(ctx context.Context, key string, timeout time.Duration) *DistLock {
+func (store *EtcdStore) NewLock(ctx context.Context, key string, timeout time.Duration) Locker {
 	return NewDistLock(ctx, store.cli, key, timeout)
 }
 
@@ -94,6 +98,9 @@ func NewEtcdStore(serverAddrs []string) (Store, error) {
 
 // put kv if already exits it will overwrite
 func (store *EtcdStore) Put(ctx context.Context, key string, value []byte) error {
+	if err := fault.Eval(fault.EtcdOp, key); err != nil {
+		return err
+	}
 	_, err := store.cli.Put(ctx, key, string(value))
 	return err
 }
@@ -101,6 +108,9 @@ func (store *EtcdStore) Put(ctx context.Context, key string, value []byte) error
 // if key already in , it will check version  if same insert else ?????
 // if key is not in , it will put
 func (store *EtcdStore) Create(ctx context.Context, key string, value []byte) error {
+	if err := fault.Eval(fault.EtcdOp, key); err != nil {
+		return err
+	}
 	resp, err := store.cli.Txn(ctx).
 		If(clientv3.Compare(clientv3.Version(key), "=", 0)).
 		Then(clientv3.OpPut(key, string(value))).
@@ -153,6 +163,70 @@ func (store *EtcdStore) KeepAlive(ctx context.Context, key string, value []byte,
 	return keepaliveC, err
 }
 
+// KeepAliveEvery refreshes the lease after each interval instead of the third
+// of the ttl used by etcd, the channel is closed when a refresh fails
+func (store *EtcdStore) KeepAliveEvery(ctx context.Context, key string, value []byte, ttl time.Duration, interval func() time.Duration) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	if int64(ttl.Seconds()) == 0 {
+		return nil, fmt.Errorf("ttl time must gather 1 sencod")
+	}
+
+	grant, err := store.cli.Grant(ctx, int64(ttl.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	_, err = store.cli.Put(ctx, key, string(value), clientv3.WithLease(grant.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	keepaliveC := make(chan *clientv3.LeaseKeepAliveResponse, 1)
+	go func() {
+		defer close(keepaliveC)
+		keepAliveLoop(ctx, ttl, interval, func(ctx context.Context) (*clientv3.LeaseKeepAliveResponse, error) {
+			resp, err := store.cli.KeepAliveOnce(ctx, grant.ID)
+			if err != nil {
+				log.Warn("keepalive lease %d of %s err: %v", grant.ID, key, err)
+			}
+			return resp, err
+		}, keepaliveC)
+	}()
+	return keepaliveC, nil
+}
+
+const keepAliveRetryBackoff = 100 * time.Millisecond
+
+// keepAliveLoop refreshes the lease every interval until ctx is done. A
+// failed refresh is retried with backoff while the lease may still be
+// alive, it stops when the lease is not found or ttl passed since the last
+// refresh
+func keepAliveLoop(ctx context.Context, ttl time.Duration, interval func() time.Duration,
+	once func(ctx context.Context) (*clientv3.LeaseKeepAliveResponse, error), keepaliveC chan<- *clientv3.LeaseKeepAliveResponse) {
+	last := time.Now()
+	wait := interval()
+	backoff := keepAliveRetryBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		resp, err := once(ctx)
+		if err != nil {
+			if errors.Is(err, rpctypes.ErrLeaseNotFound) || time.Since(last)+backoff >= ttl {
+				return
+			}
+			wait = backoff
+			backoff *= 2
+			continue
+		}
+		last, wait, backoff = time.Now(), interval(), keepAliveRetryBackoff
+		select {
+		case keepaliveC <- resp:
+		default:
+		}
+	}
+}
+
 func (store *EtcdStore) PutWithLeaseId(ctx context.Context, key string, value []byte, ttl time.Duration, leaseId clientv3.LeaseID) error {
 	if ttl != 0 && int64(ttl.Seconds()) == 0 {
 		return fmt.Errorf("ttl time must gather 1 sencod")
@@ -172,6 +246,9 @@ func (store *EtcdStore) Update(ctx context.Context, key string, value []byte) er
 }
 
 func (store *EtcdStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := fault.Eval(fault.EtcdOp, key); err != nil {
+		return nil, err
+	}
 	resp, err := store.cli.Get(ctx, key)
 	if err != nil {
 		return nil, err
@@ -184,6 +261,9 @@ func (store *EtcdStore) Get(ctx context.Context, key string) ([]byte, error) {
 }
 
 func (store *EtcdStore) PrefixScan(ctx context.Context, prefix string) ([][]byte, [][]byte, error) {
+	if err := fault.Eval(fault.EtcdOp, prefix); err != nil {
+		return nil, nil, err
+	}
 	resp, err := store.cli.Get(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return nil, nil, err
@@ -198,7 +278,18 @@ func (store *EtcdStore) PrefixScan(ctx context.Context, prefix string) ([][]byte
 	return keys, vale, nil
 }
 
+func (store *EtcdStore) Revision(ctx context.Context) (int64, error) {
+	resp, err := store.cli.Get(ctx, "/", clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	return resp.Header.Revision, nil
+}
+
 func (store *EtcdStore) Delete(ctx context.Context, key string) error {
+	if err := fault.Eval(fault.EtcdOp, key); err != nil {
+		return err
+	}
 	resp, err := store.cli.Delete(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to delete %s from etcd store, the error is :%s", key, err.Error())
@@ -209,8 +300,13 @@ func (store *EtcdStore) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-func (store *EtcdStore) STM(ctx context.Context, apply func(stm concurrency.STM) error) error {
-	resp, err := concurrency.NewSTM(store.cli, apply)
+func (store *EtcdStore) STM(ctx context.Context, apply func(stm STM) error) error {
+	if err := fault.Eval(fault.EtcdOp, "stm"); err != nil {
+		return err
+	}
+	resp, err := concurrency.NewSTM(store.cli, func(stm concurrency.STM) error {
+		return apply(stm)
+	})
 	if err != nil {
 		return err
 	}
@@ -316,3 +412,17 @@ func (store *EtcdStore) Endpoints() []string {
 func (store *EtcdStore) MemberSync(ctx context.Context) error {
 	return store.cli.Sync(ctx)
 }
+
+func (store *EtcdStore) EndpointStatus(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error) {
+	return store.cli.Status(ctx, endpoint)
+}
+
+func (store *EtcdStore) Compact(ctx context.Context, rev int64) error {
+	_, err := store.cli.Compact(ctx, rev, clientv3.WithCompactPhysical())
+	return err
+}
+
+func (store *EtcdStore) Defragment(ctx context.Context, endpoint string) error {
+	_, err := store.cli.Defragment(ctx, endpoint)
+	return err
+}
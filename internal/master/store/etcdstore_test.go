@@ -0,0 +1,77 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestKeepAliveLoop(t *testing.T) {
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		errs  []error // results of the refreshes in order, nil is success
+		calls int     // refreshes before the loop stops
+		oks   int
+	}{
+		{
+			name:  "Transient errors are retried",
+			ttl:   time.Second,
+			errs:  []error{fmt.Errorf("unavailable"), fmt.Errorf("unavailable"), nil, rpctypes.ErrLeaseNotFound},
+			calls: 4,
+			oks:   1,
+		},
+		{
+			name:  "Lease not found stops at once",
+			ttl:   time.Second,
+			errs:  []error{nil, rpctypes.ErrLeaseNotFound, nil},
+			calls: 2,
+			oks:   1,
+		},
+		{
+			name:  "Errors longer than ttl stop",
+			ttl:   300 * time.Millisecond,
+			errs:  []error{fmt.Errorf("unavailable"), fmt.Errorf("unavailable"), fmt.Errorf("unavailable"), fmt.Errorf("unavailable"), nil},
+			calls: 2,
+			oks:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			once := func(ctx context.Context) (*clientv3.LeaseKeepAliveResponse, error) {
+				err := tt.errs[calls]
+				calls++
+				if err != nil {
+					return nil, err
+				}
+				return &clientv3.LeaseKeepAliveResponse{}, nil
+			}
+			keepaliveC := make(chan *clientv3.LeaseKeepAliveResponse, len(tt.errs))
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			keepAliveLoop(ctx, tt.ttl, func() time.Duration { return time.Millisecond }, once, keepaliveC)
+			if calls != tt.calls || len(keepaliveC) != tt.oks {
+				t.Errorf("keepAliveLoop() refreshed %d times with %d responses, want %d and %d", calls, len(keepaliveC), tt.calls, tt.oks)
+			}
+		})
+	}
+}
@@ -0,0 +1,486 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fault"
+	mvccpb "go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func init() {
+	Register("memory", func(serverAddrs []string) (Store, error) {
+		return NewMemStore(), nil
+	})
+}
+
+type memKV struct {
+	value  []byte
+	modRev int64
+}
+
+type memWatcher struct {
+	mu     sync.Mutex
+	closed bool
+	ctx    context.Context
+	prefix string
+	ch     chan clientv3.WatchResponse
+}
+
+func (w *memWatcher) send(events []*clientv3.Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.ch <- clientv3.WatchResponse{Events: events}:
+	case <-w.ctx.Done():
+	}
+}
+
+func (w *memWatcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	close(w.ch)
+}
+
+// MemStore is an in-memory Store for simulation and tests, every operation is
+// applied in order under one lock so runs are deterministic. ttl and leases
+// are accepted but keys never expire, members are not supported.
+type MemStore struct {
+	mu       sync.Mutex
+	rev      int64
+	kvs      map[string]*memKV
+	locks    map[string]bool
+	watchers []*memWatcher
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		kvs:   make(map[string]*memKV),
+		locks: make(map[string]bool),
+	}
+}
+
+// put must be called with mu held, it returns the event for watchers
+func (store *MemStore) put(key string, value []byte) *clientv3.Event {
+	store.rev++
+	v := make([]byte, len(value))
+	copy(v, value)
+	store.kvs[key] = &memKV{value: v, modRev: store.rev}
+	return &clientv3.Event{Type: mvccpb.PUT, Kv: &mvccpb.KeyValue{Key: []byte(key), Value: v, ModRevision: store.rev}}
+}
+
+// del must be called with mu held, it returns nil if key not exist
+func (store *MemStore) del(key string) *clientv3.Event {
+	if _, ok := store.kvs[key]; !ok {
+		return nil
+	}
+	store.rev++
+	delete(store.kvs, key)
+	return &clientv3.Event{Type: mvccpb.DELETE, Kv: &mvccpb.KeyValue{Key: []byte(key), ModRevision: store.rev}}
+}
+
+// notify sends events to watchers, it must be called without mu held
+func (store *MemStore) notify(events ...*clientv3.Event) {
+	store.mu.Lock()
+	watchers := make([]*memWatcher, len(store.watchers))
+	copy(watchers, store.watchers)
+	store.mu.Unlock()
+
+	for _, w := range watchers {
+		var matched []*clientv3.Event
+		for _, e := range events {
+			if e != nil && strings.HasPrefix(string(e.Kv.Key), w.prefix) {
+				matched = append(matched, e)
+			}
+		}
+		if len(matched) > 0 {
+			w.send(matched)
+		}
+	}
+}
+
+func (store *MemStore) Put(ctx context.Context, key string, value []byte) error {
+	if err := fault.Eval(fault.EtcdOp, key); err != nil {
+		return err
+	}
+	store.mu.Lock()
+	e := store.put(key, value)
+	store.mu.Unlock()
+	store.notify(e)
+	return nil
+}
+
+func (store *MemStore) Create(ctx context.Context, key string, value []byte) error {
+	if err := fault.Eval(fault.EtcdOp, key); err != nil {
+		return err
+	}
+	store.mu.Lock()
+	if _, ok := store.kvs[key]; ok {
+		store.mu.Unlock()
+		return fmt.Errorf("memory store key :%v error", key)
+	}
+	e := store.put(key, value)
+	store.mu.Unlock()
+	store.notify(e)
+	return nil
+}
+
+func (store *MemStore) CreateWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl != 0 && int64(ttl.Seconds()) == 0 {
+		return fmt.Errorf("ttl time must gather 1 sencod")
+	}
+	return store.Put(ctx, key, value)
+}
+
+// KeepAlive puts the key, the returned channel is closed when ctx is done
+func (store *MemStore) KeepAlive(ctx context.Context, key string, value []byte, ttl time.Duration) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	if err := store.CreateWithTTL(ctx, key, value, ttl); err != nil {
+		return nil, err
+	}
+	keepaliveC := make(chan *clientv3.LeaseKeepAliveResponse)
+	go func() {
+		<-ctx.Done()
+		close(keepaliveC)
+	}()
+	return keepaliveC, nil
+}
+
+// KeepAliveEvery is KeepAlive, there is no lease to refresh
+func (store *MemStore) KeepAliveEvery(ctx context.Context, key string, value []byte, ttl time.Duration, interval func() time.Duration) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	return store.KeepAlive(ctx, key, value, ttl)
+}
+
+func (store *MemStore) PutWithLeaseId(ctx context.Context, key string, value []byte, ttl time.Duration, leaseId clientv3.LeaseID) error {
+	return store.CreateWithTTL(ctx, key, value, ttl)
+}
+
+func (store *MemStore) Update(ctx context.Context, key string, value []byte) error {
+	return store.Put(ctx, key, value)
+}
+
+func (store *MemStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := fault.Eval(fault.EtcdOp, key); err != nil {
+		return nil, err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if kv, ok := store.kvs[key]; ok {
+		return kv.value, nil
+	}
+	return nil, nil
+}
+
+// PrefixScan returns keys in ascending order as etcd does
+func (store *MemStore) PrefixScan(ctx context.Context, prefix string) ([][]byte, [][]byte, error) {
+	if err := fault.Eval(fault.EtcdOp, prefix); err != nil {
+		return nil, nil, err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	names := make([]string, 0)
+	for k := range store.kvs {
+		if strings.HasPrefix(k, prefix) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	keys := make([][]byte, len(names))
+	vale := make([][]byte, len(names))
+	for i, k := range names {
+		keys[i] = []byte(k)
+		vale[i] = store.kvs[k].value
+	}
+	return keys, vale, nil
+}
+
+func (store *MemStore) Revision(ctx context.Context) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.rev, nil
+}
+
+func (store *MemStore) Delete(ctx context.Context, key string) error {
+	if err := fault.Eval(fault.EtcdOp, key); err != nil {
+		return err
+	}
+	store.mu.Lock()
+	e := store.del(key)
+	store.mu.Unlock()
+	if e == nil {
+		return fmt.Errorf("key not exist error, key:%v", key)
+	}
+	store.notify(e)
+	return nil
+}
+
+// memSTM buffers the writes of a transaction and keeps the revisions of the
+// keys read, it is committed only if none of them is changed as the etcd stm
+type memSTM struct {
+	store  *MemStore
+	reads  map[string]int64
+	writes map[string]*string
+	order  []string
+}
+
+// read returns the value of key in the store and keeps its revision
+func (s *memSTM) read(key string) (string, bool) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	kv, ok := s.store.kvs[key]
+	if _, read := s.reads[key]; !read {
+		s.reads[key] = 0
+		if ok {
+			s.reads[key] = kv.modRev
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	return string(kv.value), true
+}
+
+func (s *memSTM) Get(keys ...string) string {
+	for _, key := range keys {
+		if v, ok := s.writes[key]; ok {
+			if v == nil {
+				continue
+			}
+			return *v
+		}
+		if v, ok := s.read(key); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func (s *memSTM) Put(key, val string, opts ...clientv3.OpOption) {
+	if _, ok := s.writes[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.writes[key] = &val
+}
+
+func (s *memSTM) Rev(key string) int64 {
+	s.read(key)
+	return s.reads[key]
+}
+
+func (s *memSTM) Del(key string) {
+	if _, ok := s.writes[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.writes[key] = nil
+}
+
+// commit must be called with mu held, it returns false if a key read is
+// changed after it is read
+func (s *memSTM) commit() ([]*clientv3.Event, bool) {
+	for key, rev := range s.reads {
+		cur := int64(0)
+		if kv, ok := s.store.kvs[key]; ok {
+			cur = kv.modRev
+		}
+		if cur != rev {
+			return nil, false
+		}
+	}
+	events := make([]*clientv3.Event, 0, len(s.order))
+	for _, key := range s.order {
+		if v := s.writes[key]; v != nil {
+			events = append(events, s.store.put(key, []byte(*v)))
+		} else {
+			events = append(events, s.store.del(key))
+		}
+	}
+	return events, true
+}
+
+// STM runs apply without the store lock, so apply can use the store, and
+// retries it if a key read is changed before the writes are committed
+func (store *MemStore) STM(ctx context.Context, apply func(stm STM) error) error {
+	if err := fault.Eval(fault.EtcdOp, "stm"); err != nil {
+		return err
+	}
+	for {
+		stm := &memSTM{store: store, reads: make(map[string]int64), writes: make(map[string]*string)}
+		if err := apply(stm); err != nil {
+			return err
+		}
+		store.mu.Lock()
+		events, ok := stm.commit()
+		store.mu.Unlock()
+		if ok {
+			store.notify(events...)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// memLock is the Locker of MemStore, it is held until unlocked
+type memLock struct {
+	ctx   context.Context
+	store *MemStore
+	path  string
+}
+
+func (store *MemStore) NewLock(ctx context.Context, key string, timeout time.Duration) Locker {
+	return &memLock{ctx: ctx, store: store, path: entity.PrefixLock + key}
+}
+
+func (l *memLock) Lock() error {
+	for !l.store.tryLock(l.path) {
+		select {
+		case <-l.ctx.Done():
+			return fmt.Errorf("wait on lock %s ctx timeout", l.path)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+func (l *memLock) TryLock() (bool, error) {
+	if !l.store.tryLock(l.path) {
+		return false, fmt.Errorf("lock hold by ohter process")
+	}
+	return true, nil
+}
+
+func (l *memLock) Unlock() error {
+	l.store.unlock(l.path)
+	return nil
+}
+
+// KeepAliveOnce does nothing, a memory lock has no lease to renew
+func (l *memLock) KeepAliveOnce() {}
+
+func (store *MemStore) tryLock(path string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.locks[path] {
+		return false
+	}
+	store.locks[path] = true
+	return true
+}
+
+func (store *MemStore) unlock(path string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.locks, path)
+}
+
+func (store *MemStore) NewIDGenerate(ctx context.Context, key string, base int64, timeout time.Duration) (int64, error) {
+	nextID := int64(0)
+	err := store.STM(ctx, func(stm STM) error {
+		v := stm.Get(key)
+		if len(v) == 0 {
+			stm.Put(key, fmt.Sprintf("%v", base))
+			nextID = base
+			return nil
+		}
+
+		intv, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("increment id error in storage :%v", v)
+		}
+
+		nextID = intv + 1
+		stm.Put(key, strconv.FormatInt(nextID, 10))
+		return nil
+	})
+	if err != nil {
+		return int64(0), err
+	}
+	return nextID, nil
+}
+
+// WatchPrefix only sends the changes after it is called, the channel is
+// closed when ctx is done
+func (store *MemStore) WatchPrefix(ctx context.Context, key string) (clientv3.WatchChan, error) {
+	w := &memWatcher{ctx: ctx, prefix: key, ch: make(chan clientv3.WatchResponse, 1024)}
+	store.mu.Lock()
+	store.watchers = append(store.watchers, w)
+	store.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		store.mu.Lock()
+		for i, v := range store.watchers {
+			if v == w {
+				store.watchers = append(store.watchers[:i], store.watchers[i+1:]...)
+				break
+			}
+		}
+		store.mu.Unlock()
+		w.close()
+	}()
+	return w.ch, nil
+}
+
+func (store *MemStore) MemberList(ctx context.Context) (*clientv3.MemberListResponse, error) {
+	return nil, fmt.Errorf("memory store not support member list")
+}
+
+func (store *MemStore) MemberStatus(ctx context.Context) ([]*clientv3.StatusResponse, error) {
+	return nil, fmt.Errorf("memory store not support member status")
+}
+
+func (store *MemStore) EndpointStatus(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error) {
+	return nil, fmt.Errorf("memory store not support endpoint status")
+}
+
+func (store *MemStore) Compact(ctx context.Context, rev int64) error {
+	return fmt.Errorf("memory store not support compact")
+}
+
+func (store *MemStore) Defragment(ctx context.Context, endpoint string) error {
+	return fmt.Errorf("memory store not support defragment")
+}
+
+func (store *MemStore) MemberAdd(ctx context.Context, peerAddrs []string) (*clientv3.MemberAddResponse, error) {
+	return nil, fmt.Errorf("memory store not support member add")
+}
+
+func (store *MemStore) MemberRemove(ctx context.Context, id uint64) (*clientv3.MemberRemoveResponse, error) {
+	return nil, fmt.Errorf("memory store not support member remove")
+}
+
+func (store *MemStore) Endpoints() []string {
+	return nil
+}
+
+func (store *MemStore) MemberSync(ctx context.Context) error {
+	return nil
+}
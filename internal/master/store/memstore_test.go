@@ -0,0 +1,99 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package store
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemStore_STM(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStore()
+
+	// apply can use the store, it is not run with the store lock held
+	err := s.STM(ctx, func(stm STM) error {
+		if err := s.Put(ctx, "/a", []byte("1")); err != nil {
+			return err
+		}
+		stm.Put("/b", stm.Get("/a"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.Get(ctx, "/b"); string(v) != "1" {
+		t.Errorf("get /b = %s, want 1", v)
+	}
+
+	// concurrent increments are retried on conflict and none is lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.STM(ctx, func(stm STM) error {
+				n, _ := strconv.Atoi(stm.Get("/counter"))
+				stm.Put("/counter", strconv.Itoa(n+1))
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := s.Get(ctx, "/counter"); string(v) != "20" {
+		t.Errorf("get /counter = %s, want 20", v)
+	}
+
+	rev := int64(0)
+	_ = s.STM(ctx, func(stm STM) error {
+		rev = stm.Rev("/counter")
+		stm.Del("/b")
+		return nil
+	})
+	if rev == 0 {
+		t.Errorf("revision of /counter should not be zero")
+	}
+	if v, _ := s.Get(ctx, "/b"); v != nil {
+		t.Errorf("/b should be deleted, got %s", v)
+	}
+}
+
+func TestMemStore_NewLock(t *testing.T) {
+	s := NewMemStore()
+	l := s.NewLock(context.Background(), "job", time.Second)
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.NewLock(context.Background(), "job", time.Second).TryLock(); ok {
+		t.Errorf("lock should be held")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.NewLock(ctx, "job", time.Second).Lock(); err == nil {
+		t.Errorf("lock should time out")
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.NewLock(context.Background(), "job", time.Second).TryLock(); !ok {
+		t.Errorf("lock should be free after unlock")
+	}
+}
@@ -20,7 +20,6 @@ import (
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
-	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
 var storeFactories = make(map[string]InitFunc)
@@ -42,21 +41,43 @@ func OpenStore(implementation string, serverAddress []string) (Store, error) {
 	return s(serverAddress)
 }
 
+// STM is the software transactional memory of a store, the same as the STM
+// of etcd concurrency, so master logic does not depend on etcd and other
+// stores can run transactions
+type STM interface {
+	// Get returns the value of the first key found, empty if none is found
+	Get(key ...string) string
+	Put(key, val string, opts ...clientv3.OpOption)
+	// Rev returns the revision of key, zero if it is not found
+	Rev(key string) int64
+	Del(key string)
+}
+
+// Locker is the distributed lock of a store
+type Locker interface {
+	Lock() error
+	TryLock() (bool, error)
+	Unlock() error
+	// KeepAliveOnce renews the lease of the lock held by a long job
+	KeepAliveOnce()
+}
+
 type Store interface {
 	Put(ctx context.Context, key string, value []byte) error
 	Create(ctx context.Context, key string, value []byte) error
 	CreateWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error
 	KeepAlive(ctx context.Context, key string, value []byte, ttl time.Duration) (<-chan *clientv3.LeaseKeepAliveResponse, error)
+	KeepAliveEvery(ctx context.Context, key string, value []byte, ttl time.Duration, interval func() time.Duration) (<-chan *clientv3.LeaseKeepAliveResponse, error)
 	PutWithLeaseId(ctx context.Context, key string, value []byte, ttl time.Duration, leaseId clientv3.LeaseID) error
 	Update(ctx context.Context, key string, value []byte) error
 	Get(ctx context.Context, key string) ([]byte, error)
 	PrefixScan(ctx context.Context, prefix string) ([][]byte, [][]byte, error)
+	//Revision returns the current revision of the store
+	Revision(ctx context.Context) (int64, error)
 	Delete(ctx context.Context, key string) error
-	//Here we should not use the STM structure of etcd, but should define a data structure
-	//equivalent to STM in the store, and then convert it in etcdstorage.go, on the one hand, the
-	//decoupling of etcd and master logic, on the other hand Easy to extend other types of storage
-	STM(ctx context.Context, apply func(stm concurrency.STM) error) error
-	NewLock(ctx context.Context, key string, timeout time.Duration) *DistLock
+	//STM runs apply in a transaction, it is retried if the keys read are changed
+	STM(ctx context.Context, apply func(stm STM) error) error
+	NewLock(ctx context.Context, key string, timeout time.Duration) Locker
 	//it to generate increment unique id
 	NewIDGenerate(ctx context.Context, key string, base int64, timeout time.Duration) (int64, error)
 	WatchPrefix(ctx context.Context, key string) (clientv3.WatchChan, error)
@@ -66,6 +87,11 @@ type Store interface {
 	MemberRemove(ctx context.Context, id uint64) (*clientv3.MemberRemoveResponse, error)
 	Endpoints() []string
 	MemberSync(ctx context.Context) error
+	EndpointStatus(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error)
+	//Compact removes the revisions before rev
+	Compact(ctx context.Context, rev int64) error
+	//Defragment releases the space of compacted revisions of one member
+	Defragment(ctx context.Context, endpoint string) error
 }
 
 type WatcherJob interface {
@@ -0,0 +1,190 @@
+// web ui of a vearch cluster, it reads the api of the master with the
+// credentials the browser signed in with
+"use strict";
+
+const views = {health: loadHealth, spaces: loadSpaces, placement: loadPlacement, nodes: loadNodes, jobs: loadJobs};
+const streams = [];
+
+async function api(path, options) {
+  const resp = await fetch(path, options);
+  const reply = await resp.json().catch(() => ({msg: resp.statusText}));
+  if (!resp.ok || (reply.code !== undefined && reply.code !== 0)) {
+    throw new Error(reply.msg || resp.statusText);
+  }
+  return reply.data;
+}
+
+function esc(v) {
+  return String(v === undefined || v === null ? "" : v).replace(/[&<>"']/g, c => "&#" + c.charCodeAt(0) + ";");
+}
+
+function status(s) {
+  return `<span class="status ${esc(s)}">${esc(s || "unknown")}</span>`;
+}
+
+function bytes(n) {
+  if (!n) {
+    return "-";
+  }
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return n.toFixed(1) + " " + units[i];
+}
+
+function percent(p) {
+  return p === undefined ? "-" : p.toFixed(1) + "%";
+}
+
+function bar(p) {
+  return `<div class="bar"><span style="width:${Math.min(p || 0, 100)}%"></span></div>`;
+}
+
+function table(head, rows) {
+  if (rows.length === 0) {
+    return "<p>none</p>";
+  }
+  return `<table><tr>${head.map(h => `<th>${h}</th>`).join("")}</tr>` +
+    rows.map(r => `<tr>${r.map(c => `<td>${c}</td>`).join("")}</tr>`).join("") + "</table>";
+}
+
+async function loadHealth(el) {
+  const dbs = await api("/cluster/health");
+  el.innerHTML = "<h2>Databases</h2>" + table(
+    ["db", "status", "spaces", "documents", "errors"],
+    (dbs || []).map(db => [esc(db.db_name), status(db.status), esc(db.space_num), esc(db.doc_num),
+      `<span class="error">${esc((db.errors || []).join("; "))}</span>`]));
+}
+
+async function loadSpaces(el) {
+  const dbs = await api("/cluster/health");
+  const rows = [];
+  for (const db of dbs || []) {
+    for (const s of db.spaces || []) {
+      rows.push([esc(db.db_name), esc(s.space_name || s.name), status(s.status), esc(s.doc_num),
+        esc(s.partition_num), esc(s.replica_num), esc(s.resource_group)]);
+    }
+  }
+  el.innerHTML = "<h2>Spaces</h2>" + table(["db", "space", "status", "documents", "partitions", "replicas", "resource group"], rows);
+}
+
+async function loadPlacement(el) {
+  const data = await api("/servers");
+  const rows = (data.servers || []).map(s => {
+    const pids = (s.partitions || []).map(p =>
+      `<span class="pid" title="${esc(p.name)} ${esc(p.doc_num)} docs">${esc(p.pid)}</span>`).join("");
+    return [esc(s.server.name), esc(s.server.ip), esc(s.server.resource_name), esc(s.server.host_zone),
+      s.error ? `<span class="error">${esc(s.error)}</span>` : pids];
+  });
+  el.innerHTML = "<h2>Partitions by node</h2>" + table(["node", "ip", "resource", "zone", "partitions"], rows);
+}
+
+async function loadNodes(el) {
+  const stats = await api("/cluster/stats");
+  const rows = (stats || []).map(s => [
+    esc(s.ip),
+    s.err ? `<span class="error">${esc(s.err)}</span>` : status(s.status === 200 ? "green" : "red"),
+    s.cpu ? percent(100 - s.cpu.idle_percent) : "-",
+    s.mem ? `${percent(s.mem.used_percent)} of ${bytes(s.mem.total_in_bytes)}` : "-",
+    s.fs ? `${percent(s.fs.used_percent)} of ${bytes(s.fs.total_in_bytes)}` : "-",
+    esc(s.active_conn),
+    esc((s.partition_infos || []).length),
+  ]);
+  el.innerHTML = "<h2>Nodes</h2>" + table(["ip", "status", "cpu", "memory", "disk", "connections", "partitions"], rows);
+}
+
+// loadJobs lists the jobs and follows the running ones by their event stream
+async function loadJobs(el) {
+  const [migrations, joins] = await Promise.all([
+    api("/cluster/embedding_migrations"),
+    api("/cluster/similarity_joins"),
+  ]);
+  const jobs = (migrations || []).map(m => ({kind: "embedding_migration", job: m, done: m.embedded}))
+    .concat((joins || []).map(j => ({kind: "similarity_join", job: j, done: j.joined})));
+  const rows = jobs.map(({kind, job, done}) => {
+    const id = esc(kind + ":" + job.name);
+    return [id, esc(job.db_name + "/" + job.space_name), `<span data-status="${id}">${status(job.status)}</span>`,
+      `<span data-progress="${id}">${job.status === "done" ? bar(100) : "-"}</span>`,
+      `<span data-done="${id}">${esc(done)}</span>`, `<span class="error">${esc(job.error)}</span>`];
+  });
+  el.innerHTML = "<h2>Jobs</h2>" + table(["id", "space", "status", "progress", "documents", "error"], rows);
+  for (const {kind, job} of jobs) {
+    if (job.status === "running") {
+      follow(el, kind + ":" + job.name);
+    }
+  }
+}
+
+function follow(el, id) {
+  const source = new EventSource(`/jobs/${encodeURIComponent(id)}/events`);
+  const update = e => {
+    const ev = JSON.parse(e.data);
+    const cell = name => el.querySelector(`[data-${name}="${CSS.escape(id)}"]`);
+    if (cell("status")) {
+      cell("status").innerHTML = status(ev.status);
+      cell("progress").innerHTML = bar(ev.progress) + percent(ev.progress);
+      cell("done").textContent = ev.done;
+    }
+  };
+  source.addEventListener("state", update);
+  source.addEventListener("progress", update);
+  source.addEventListener("deleted", () => source.close());
+  // the stream ends with the job, do not reconnect
+  source.onerror = () => source.close();
+  streams.push(source);
+}
+
+async function show() {
+  const name = (location.hash || "#health").slice(1);
+  while (streams.length) {
+    streams.pop().close();
+  }
+  for (const section of document.querySelectorAll("main section")) {
+    section.hidden = section.id !== name;
+  }
+  for (const a of document.querySelectorAll("nav a")) {
+    a.classList.toggle("active", a.getAttribute("href") === "#" + name);
+  }
+  const el = document.getElementById(name);
+  if (!el || !views[name]) {
+    return;
+  }
+  el.innerHTML = "<p>loading...</p>";
+  try {
+    await views[name](el);
+  } catch (e) {
+    el.innerHTML = `<p class="error">${esc(e.message)}</p>`;
+  }
+}
+
+document.getElementById("console-form").addEventListener("submit", async e => {
+  e.preventDefault();
+  const form = e.target;
+  const result = document.getElementById("console-result");
+  let body;
+  try {
+    body = JSON.parse(form.body.value);
+  } catch (err) {
+    result.textContent = "invalid json: " + err.message;
+    return;
+  }
+  result.textContent = "sending...";
+  const resp = await fetch("query", {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({kind: form.kind.value, body: body}),
+  });
+  const text = await resp.text();
+  try {
+    result.textContent = JSON.stringify(JSON.parse(text), null, 2);
+  } catch (err) {
+    result.textContent = text;
+  }
+});
+
+window.addEventListener("hashchange", show);
+document.getElementById("refresh").addEventListener("click", show);
+show();
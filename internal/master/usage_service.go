@@ -0,0 +1,134 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const usageCleanInterval = 24 * time.Hour
+
+// usageReportService reports the daily usage routers rolled up from start to
+// end, empty user, db or space matches all
+func (ms *masterService) usageReportService(ctx context.Context, start, end, user, dbName, spaceName string) (*entity.UsageReport, error) {
+	today := entity.UsageDate(time.Now())
+	if end == "" {
+		end = today
+	}
+	if start == "" {
+		start = end
+	}
+	startDay, err := time.Parse(entity.UsageDateLayout, start)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("usage start %s should be %s", start, entity.UsageDateLayout))
+	}
+	endDay, err := time.Parse(entity.UsageDateLayout, end)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("usage end %s should be %s", end, entity.UsageDateLayout))
+	}
+	if endDay.Before(startDay) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("usage end %s is before start %s", end, start))
+	}
+	if endDay.Sub(startDay) >= entity.UsageRetentionDays*24*time.Hour {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("usage report should be less than %d days", entity.UsageRetentionDays))
+	}
+
+	report := &entity.UsageReport{
+		Start: start,
+		End:   end,
+		Usage: make([]*entity.Usage, 0),
+		Users: make(map[string]*entity.Usage),
+		Total: &entity.Usage{},
+	}
+	for day := startDay; !day.After(endDay); day = day.AddDate(0, 0, 1) {
+		_, values, err := ms.Master().PrefixScan(ctx, entity.UsageDateKey(entity.UsageDate(day)))
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			u := &entity.Usage{}
+			if err := vjson.Unmarshal(value, u); err != nil {
+				log.Error("decode usage err: %v", err)
+				continue
+			}
+			if (user != "" && u.User != user) || (dbName != "" && u.DbName != dbName) || (spaceName != "" && u.SpaceName != spaceName) {
+				continue
+			}
+			report.Usage = append(report.Usage, u)
+			total, ok := report.Users[u.User]
+			if !ok {
+				total = &entity.Usage{User: u.User}
+				report.Users[u.User] = total
+			}
+			total.Add(u)
+			report.Total.Add(u)
+		}
+	}
+	return report, nil
+}
+
+// startUsageCleaner deletes the expired usage rollups daily
+func (ms *masterService) startUsageCleaner(ctx context.Context) {
+	go func() {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				log.Error("recover() err:[%v]", rErr)
+				log.Error("stack:[%s]", debug.Stack())
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("usage cleaner stopped")
+				return
+			case <-time.After(usageCleanInterval):
+			}
+			// the lock is not released, it expires by ttl so only one master cleans in an interval
+			mutex := ms.Master().NewLock(ctx, entity.ClusterUsageCleanKey, usageCleanInterval)
+			if getLock, err := mutex.TryLock(); !getLock || err != nil {
+				continue
+			}
+			ms.cleanUsage(ctx)
+		}
+	}()
+}
+
+// cleanUsage deletes the usage rollups older than the retention
+func (ms *masterService) cleanUsage(ctx context.Context) {
+	expire := entity.UsageDate(time.Now().AddDate(0, 0, -entity.UsageRetentionDays))
+	keys, _, err := ms.Master().PrefixScan(ctx, entity.PrefixUsage)
+	if err != nil {
+		log.Error("scan usage err: %v", err)
+		return
+	}
+	for _, key := range keys {
+		date, _, _ := strings.Cut(strings.TrimPrefix(string(key), entity.PrefixUsage), "/")
+		if date >= expire {
+			continue
+		}
+		if err := ms.Master().Delete(ctx, string(key)); err != nil {
+			log.Error("delete usage [%s] err: %v", string(key), err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+)
+
+// webUIFiles are the pages of the web ui, served under /ui/ when web_ui of
+// the master is set. They read the api of the master with the credentials
+// of the signed in user
+//
+//go:embed ui
+var webUIFiles embed.FS
+
+// webUIQueryPath is the path under /ui/ of the query console, forwarded to a
+// router
+const webUIQueryPath = "/query"
+
+// webUIQuery is a document query or search of the console of the web ui,
+// Body is the request of the router api
+type webUIQuery struct {
+	Kind string          `json:"kind"` // query or search
+	Body json.RawMessage `json:"body"`
+}
+
+var webUIClient = &http.Client{Timeout: 10 * time.Second}
+
+// webUI serves the pages of the web ui
+func (ca *clusterAPI) webUI(c *gin.Context) {
+	files, err := fs.Sub(webUIFiles, "ui")
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	c.FileFromFS(c.Param("path"), http.FS(files))
+}
+
+// webUIConsole forwards a query or search of the console to a healthy router
+// with the credentials of the user and replies the answer of the router
+func (ca *clusterAPI) webUIConsole(c *gin.Context) {
+	if c.Param("path") != webUIQueryPath {
+		response.New(c).JsonError(errors.NewErrNotFound(fmt.Errorf("%s not found", c.Request.URL.Path)))
+		return
+	}
+	q := &webUIQuery{}
+	if err := c.ShouldBindJSON(q); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if q.Kind != "query" && q.Kind != "search" {
+		response.New(c).JsonError(errors.NewErrBadRequest(fmt.Errorf("kind should be query or search")))
+		return
+	}
+	if !json.Valid(q.Body) {
+		response.New(c).JsonError(errors.NewErrBadRequest(fmt.Errorf("body should be the json request of the router")))
+		return
+	}
+
+	routers, err := ca.masterService.Master().QueryRouterInfos(c, config.Conf().Global.Name)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	if len(routers) == 0 {
+		response.New(c).JsonError(errors.NewErrUnavailable(fmt.Errorf("no healthy router")))
+		return
+	}
+	router := routers[rand.Intn(len(routers))]
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, "http://"+router.Address+"/document/"+q.Kind, bytes.NewReader(q.Body))
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := webUIClient.Do(req)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrUnavailable(fmt.Errorf("router %s: %v", router.Address, err)))
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	if !json.Valid(body) {
+		body, _ = json.Marshal(map[string]string{"msg": string(body)})
+	}
+	c.Header("X-Vearch-Router", router.Address)
+	c.Data(resp.StatusCode, "application/json", body)
+}
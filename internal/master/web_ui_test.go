@@ -0,0 +1,91 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWebUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ca := &clusterAPI{router: router}
+	router.GET("/ui/*path", ca.webUI)
+	router.POST("/ui/*path", ca.webUIConsole)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "Index", method: http.MethodGet, path: "/ui/", wantStatus: http.StatusOK, wantBody: "<title>Vearch</title>"},
+		{name: "Script", method: http.MethodGet, path: "/ui/app.js", wantStatus: http.StatusOK, wantBody: "EventSource"},
+		{name: "Missing file", method: http.MethodGet, path: "/ui/missing.js", wantStatus: http.StatusNotFound},
+		{name: "Unknown console path", method: http.MethodPost, path: "/ui/delete", body: `{}`, wantStatus: http.StatusNotFound},
+		{name: "Console bad kind", method: http.MethodPost, path: "/ui/query", body: `{"kind": "delete", "body": {}}`, wantStatus: http.StatusBadRequest},
+		{name: "Console without body", method: http.MethodPost, path: "/ui/query", body: `{"kind": "search"}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s %s status = %d, want %d, body %s", tt.method, tt.path, w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body does not have %q", tt.method, tt.path, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUnauthorizedChallenge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	deny := func(c *gin.Context) { unauthorized(c, fmt.Errorf("auth header is empty")) }
+	router.GET("/ui/*path", deny)
+	router.GET("/cluster/stats", deny)
+
+	tests := []struct {
+		name          string
+		path          string
+		wantChallenge bool
+	}{
+		{name: "Web ui page", path: "/ui/", wantChallenge: true},
+		{name: "Web ui file", path: "/ui/app.js", wantChallenge: true},
+		{name: "Api", path: "/cluster/stats", wantChallenge: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("GET %s status = %d, want %d", tt.path, w.Code, http.StatusUnauthorized)
+			}
+			if got := w.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("GET %s challenge = %v, want %v", tt.path, got, tt.wantChallenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+const (
+	webhookQueueSize      = 1024
+	webhookSignatureKey   = "X-Vearch-Signature"
+	webhookEventHeaderKey = "X-Vearch-Event"
+)
+
+// webhookDispatcher posts cluster events to the webhooks stored in etcd
+type webhookDispatcher struct {
+	client *client.Client
+	queue  chan *entity.WebhookEvent
+}
+
+func newWebhookDispatcher(client *client.Client) *webhookDispatcher {
+	return &webhookDispatcher{
+		client: client,
+		queue:  make(chan *entity.WebhookEvent, webhookQueueSize),
+	}
+}
+
+// publish puts the event into the queue, it never blocks the caller
+func (wd *webhookDispatcher) publish(eventType entity.WebhookEventType, data interface{}) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		log.Error("generate webhook event id failed, %v", err)
+		return
+	}
+	event := &entity.WebhookEvent{
+		ID:        id.String(),
+		Type:      eventType,
+		Cluster:   config.Conf().Global.Name,
+		Timestamp: time.Now().UnixNano(),
+		Data:      data,
+	}
+	select {
+	case wd.queue <- event:
+	default:
+		log.Warn("webhook queue is full, drop event %s: %s", event.Type, event.ID)
+	}
+}
+
+func (wd *webhookDispatcher) start(ctx context.Context) {
+	go func() {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				log.Error("recover() err:[%v]", rErr)
+				log.Error("stack:[%s]", debug.Stack())
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("webhook dispatcher stopped")
+				return
+			case event := <-wd.queue:
+				hooks, err := wd.client.Master().QueryWebhooks(ctx)
+				if err != nil {
+					log.Error("query webhooks for event %s err: %v", event.Type, err)
+					continue
+				}
+				for _, hook := range hooks {
+					if hook.Accept(event.Type) {
+						go wd.deliver(ctx, hook, event)
+					}
+				}
+			}
+		}
+	}()
+
+	go wd.watchServers(ctx)
+}
+
+// deliver posts the event to the webhook, it retries with exponential backoff
+func (wd *webhookDispatcher) deliver(ctx context.Context, hook *entity.Webhook, event *entity.WebhookEvent) {
+	body, err := vjson.Marshal(event)
+	if err != nil {
+		log.Error("marshal webhook event %s err: %v", event.ID, err)
+		return
+	}
+
+	maxRetries := hook.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = entity.DefaultWebhookMaxRetries
+	}
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = entity.DefaultWebhookTimeout
+	}
+	httpClient := &http.Client{Timeout: time.Duration(timeout) * time.Millisecond}
+
+	backoff := time.Second
+	for i := 0; i <= maxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		if err = wd.post(httpClient, hook, event, body); err == nil {
+			log.Debug("post webhook [%s] event %s: %s success", hook.Name, event.Type, event.ID)
+			return
+		}
+		log.Warn("post webhook [%s] event %s: %s attempt %d err: %v", hook.Name, event.Type, event.ID, i+1, err)
+	}
+	log.Error("post webhook [%s] event %s: %s failed after %d attempts", hook.Name, event.Type, event.ID, maxRetries+1)
+}
+
+func (wd *webhookDispatcher) post(httpClient *http.Client, hook *entity.Webhook, event *entity.WebhookEvent, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeaderKey, string(event.Type))
+	if hook.Secret != "" {
+		req.Header.Set(webhookSignatureKey, "sha256="+signWebhookBody(hook.Secret, body))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhookBody return hex encoded HMAC-SHA256 of body, receivers can verify
+// the X-Vearch-Signature header with the shared secret
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// watchServers publish node joined and left events, every master watches the
+// server prefix, the lock keyed by revision makes sure only one of them publish
+func (wd *webhookDispatcher) watchServers(ctx context.Context) {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			log.Error("recover() err:[%v]", rErr)
+			log.Error("stack:[%s]", debug.Stack())
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		watcher, err := wd.client.Master().WatchPrefix(ctx, entity.PrefixServer)
+		if err != nil {
+			log.Error("watch prefix:[%s] err: %v", entity.PrefixServer, err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		for reps := range watcher {
+			if reps.Canceled {
+				log.Error("chan is closed by webhook server watcher")
+				break
+			}
+			for _, event := range reps.Events {
+				if event.Type == mvccpb.PUT && !event.IsCreate() {
+					continue
+				}
+				lockKey := fmt.Sprintf("%s/%d", entity.ClusterWatchServerKeyWebhook, event.Kv.ModRevision)
+				// the lock is not released, it expires by ttl so other masters skip the same revision
+				mutex := wd.client.Master().NewLock(ctx, lockKey, time.Second*60)
+				if getLock, err := mutex.TryLock(); !getLock || err != nil {
+					continue
+				}
+				keySplit := strings.Split(string(event.Kv.Key), "/")
+				nodeID := cast.ToUint64(keySplit[len(keySplit)-1])
+				data := map[string]interface{}{"node_id": nodeID}
+				if event.Type == mvccpb.PUT {
+					server := &entity.Server{}
+					if err := vjson.Unmarshal(event.Kv.Value, server); err == nil {
+						data["ip"] = server.Ip
+						data["resource_name"] = server.ResourceName
+					}
+					wd.publish(entity.EventNodeJoined, data)
+				} else {
+					wd.publish(entity.EventNodeLeft, data)
+				}
+			}
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func (ms *masterService) createWebhookService(ctx context.Context, hook *entity.Webhook) (err error) {
+	if err = hook.Validate(); err != nil {
+		return err
+	}
+	mutex := ms.Master().NewLock(ctx, entity.LockWebhookKey(hook.Name), time.Second*30)
+	if err = mutex.Lock(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock lock for create webhook err %s", err)
+		}
+	}()
+	err = ms.Master().STM(context.Background(), func(stm store.STM) error {
+		hookKey := entity.WebhookKey(hook.Name)
+		if stm.Get(hookKey) != "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("webhook %s is exists", hook.Name))
+		}
+		marshal, err := vjson.Marshal(hook)
+		if err != nil {
+			return err
+		}
+		stm.Put(hookKey, string(marshal))
+		return nil
+	})
+	return err
+}
+
+func (ms *masterService) deleteWebhookService(ctx context.Context, name string) (err error) {
+	if _, err = ms.queryWebhookService(ctx, name); err != nil {
+		return err
+	}
+	mutex := ms.Master().NewLock(ctx, entity.LockWebhookKey(name), time.Second*30)
+	if err = mutex.Lock(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mutex.Unlock(); err != nil {
+			log.Error("unlock lock for delete webhook err %s", err)
+		}
+	}()
+	return ms.Master().Delete(ctx, entity.WebhookKey(name))
+}
+
+func (ms *masterService) queryWebhookService(ctx context.Context, name string) (*entity.Webhook, error) {
+	bs, err := ms.Master().Get(ctx, entity.WebhookKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("webhook %s not exists", name))
+	}
+	hook := &entity.Webhook{}
+	if err = vjson.Unmarshal(bs, hook); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get webhook:%s value:%s, err:%s", name, redact.Payload(bs), err.Error()))
+	}
+	return hook, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package monitor
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var breakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "vearch_router_circuit_breaker_state",
+	Help: "state of the circuit breaker of a space, 0 closed, 1 half open and 2 open",
+}, []string{"space", "kind"})
+
+var breakerTrips = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "vearch_router_circuit_breaker_trips_total",
+	Help: "times the circuit breaker of a space opened",
+}, []string{"space", "kind"})
+
+var breakerRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "vearch_router_circuit_breaker_rejected_total",
+	Help: "requests failed fast by the circuit breaker of a space",
+}, []string{"space", "kind"})
+
+// BreakerState records the state of the breaker of kind of space, a trip
+// if it opens
+func BreakerState(space, kind string, state int, open bool) {
+	breakerState.WithLabelValues(space, kind).Set(float64(state))
+	if open {
+		breakerTrips.WithLabelValues(space, kind).Inc()
+	}
+}
+
+// BreakerRejected records a request failed fast by the breaker of kind of
+// space
+func BreakerRejected(space, kind string) {
+	breakerRejected.WithLabelValues(space, kind).Inc()
+}
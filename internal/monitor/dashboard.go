@@ -0,0 +1,158 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const dashboardSchemaVersion = 39
+
+// Dashboard is a Grafana dashboard definition, it can be imported directly
+// by the Grafana dashboard import api or ui
+type Dashboard struct {
+	UID           string                 `json:"uid"`
+	Title         string                 `json:"title"`
+	Tags          []string               `json:"tags"`
+	Timezone      string                 `json:"timezone"`
+	SchemaVersion int                    `json:"schemaVersion"`
+	Refresh       string                 `json:"refresh"`
+	Time          map[string]string      `json:"time"`
+	Templating    map[string]interface{} `json:"templating"`
+	Panels        []*Panel               `json:"panels"`
+}
+
+type Panel struct {
+	ID         int                    `json:"id"`
+	Title      string                 `json:"title"`
+	Type       string                 `json:"type"`
+	Datasource map[string]interface{} `json:"datasource"`
+	GridPos    map[string]int         `json:"gridPos"`
+	Targets    []*Target              `json:"targets"`
+	FieldCfg   map[string]interface{} `json:"fieldConfig,omitempty"`
+}
+
+type Target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Exemplar     bool   `json:"exemplar,omitempty"`
+}
+
+var promDatasource = map[string]interface{}{"type": "prometheus", "uid": "${datasource}"}
+
+// dashboardBuilder lays out panels two per row
+type dashboardBuilder struct {
+	d *Dashboard
+}
+
+func newDashboard(uid, title string) *dashboardBuilder {
+	return &dashboardBuilder{d: &Dashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"vearch"},
+		Timezone:      "browser",
+		SchemaVersion: dashboardSchemaVersion,
+		Refresh:       "30s",
+		Time:          map[string]string{"from": "now-1h", "to": "now"},
+		Templating: map[string]interface{}{"list": []map[string]interface{}{{
+			"name":  "datasource",
+			"type":  "datasource",
+			"query": "prometheus",
+			"label": "Datasource",
+		}}},
+	}}
+}
+
+func (b *dashboardBuilder) panel(title, unit string, targets ...*Target) *dashboardBuilder {
+	n := len(b.d.Panels)
+	for i, t := range targets {
+		t.RefID = string(rune('A' + i))
+	}
+	b.d.Panels = append(b.d.Panels, &Panel{
+		ID:         n + 1,
+		Title:      title,
+		Type:       "timeseries",
+		Datasource: promDatasource,
+		GridPos:    map[string]int{"h": 8, "w": 12, "x": (n % 2) * 12, "y": (n / 2) * 8},
+		Targets:    targets,
+		FieldCfg:   map[string]interface{}{"defaults": map[string]interface{}{"unit": unit}},
+	})
+	return b
+}
+
+func latencyQuantile(q float64) *Target {
+	return &Target{
+		Expr:         fmt.Sprintf(`histogram_quantile(%g, sum by (le, key) (rate(vearch_request_latency_milliseconds_bucket[5m])))`, q),
+		LegendFormat: fmt.Sprintf("{{key}} p%g", q*100),
+		Exemplar:     true,
+	}
+}
+
+// Dashboards generates the dashboards of the metrics exported by vearch
+func Dashboards() []*Dashboard {
+	requests := newDashboard("vearch-requests", "Vearch Requests").
+		panel("Request rate", "reqps", &Target{
+			Expr:         `sum by (key) (rate(vearch_request_latency_milliseconds_count[5m]))`,
+			LegendFormat: "{{key}}",
+		}).
+		panel("Latency p50", "ms", latencyQuantile(tp50)).
+		panel("Latency p99", "ms", latencyQuantile(tp99)).
+		panel("Latency p999", "ms", latencyQuantile(tp999)).
+		panel("Summary latency by api", "ms", &Target{
+			Expr:         `vearch_request_duration_milliseconds{quantile="0.99"}`,
+			LegendFormat: "{{key}} {{method}}",
+		})
+
+	cluster := newDashboard("vearch-cluster", "Vearch Cluster").
+		panel("Servers", "none", &Target{Expr: `vearch_db_info{metric="server_num"}`, LegendFormat: "servers"}).
+		panel("Databases and spaces", "none",
+			&Target{Expr: `vearch_db_info{metric="db_num"}`, LegendFormat: "dbs"},
+			&Target{Expr: `max(vearch_db_info{metric="space_num", tag1="*"})`, LegendFormat: "spaces"},
+			&Target{Expr: `vearch_db_info{metric="partition_num"}`, LegendFormat: "partitions"}).
+		panel("Documents by space", "short", &Target{Expr: `vearch_db_info{metric="doc_num"}`, LegendFormat: "{{tag1}}/{{tag2}}"}).
+		panel("Size by space", "bytes", &Target{Expr: `vearch_db_info{metric="size_map"}`, LegendFormat: "{{tag1}}/{{tag2}}"}).
+		panel("Leaders by server", "none", &Target{Expr: `vearch_db_info{metric="leader_num"}`, LegendFormat: "{{tag1}}"}).
+		panel("Disk used percent", "percent", &Target{Expr: `vearch_disk_stat{metric="disk_used_percent"}`, LegendFormat: "{{ip}}"}).
+		panel("Disk free", "bytes", &Target{Expr: `vearch_disk_stat{metric="disk_free"}`, LegendFormat: "{{ip}}"})
+
+	return []*Dashboard{requests.d, cluster.d}
+}
+
+// handleDashboards returns all dashboards on /debug/dashboards, and one
+// dashboard on /debug/dashboards/{uid}
+func handleDashboards(w http.ResponseWriter, r *http.Request) {
+	var body interface{} = Dashboards()
+	if uid := strings.Trim(strings.TrimPrefix(r.URL.Path, "/debug/dashboards"), "/"); uid != "" {
+		body = nil
+		for _, d := range Dashboards() {
+			if d.UID == uid {
+				body = d
+				break
+			}
+		}
+		if body == nil {
+			http.Error(w, fmt.Sprintf("dashboard %s not found", uid), http.StatusNotFound)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
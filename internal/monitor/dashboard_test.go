@@ -0,0 +1,52 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleDashboards(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleDashboards(w, httptest.NewRequest(http.MethodGet, "/debug/dashboards", nil))
+	var all []*Dashboard
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil {
+		t.Fatalf("unmarshal dashboards err: %v", err)
+	}
+	if len(all) == 0 {
+		t.Fatalf("no dashboards generated")
+	}
+	for _, d := range all {
+		if len(d.Panels) == 0 {
+			t.Errorf("dashboard %s has no panels", d.UID)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	handleDashboards(w, httptest.NewRequest(http.MethodGet, "/debug/dashboards/vearch-requests", nil))
+	var one Dashboard
+	if err := json.Unmarshal(w.Body.Bytes(), &one); err != nil || one.UID != "vearch-requests" {
+		t.Errorf("get dashboard vearch-requests got %s, err: %v", w.Body.String(), err)
+	}
+
+	w = httptest.NewRecorder()
+	handleDashboards(w, httptest.NewRequest(http.MethodGet, "/debug/dashboards/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("get unknown dashboard status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
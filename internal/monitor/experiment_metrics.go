@@ -0,0 +1,36 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package monitor
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var experimentLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "vearch_experiment_latency_milliseconds",
+	Help:    "latency of searches routed by experiments per variant",
+	Buckets: latencyBuckets,
+}, []string{"experiment", "variant", "result"})
+
+// ExperimentObserve records one search of the variant of an experiment
+func ExperimentObserve(experiment, variant string, success bool, cost time.Duration) {
+	result := "ok"
+	if !success {
+		result = "error"
+	}
+	experimentLatency.WithLabelValues(experiment, variant, result).Observe(float64(cost.Milliseconds()))
+}
@@ -59,12 +59,18 @@ func Register(masterClient *client.Client, etcdServer *etcdserver.EtcdServer, mo
 	var err error
 	defer errutil.CatchError(&err)
 	once.Do(func() {
-		prometheus.MustRegister(NewMetricCollector(masterClient, etcdServer))
-		http.Handle("/metrics", promhttp.Handler())
+		prometheus.MustRegister(NewMetricCollector(masterClient, etcdServer), newRuntimeCollector(), requestLatency, shadowRequests, shadowLatency, shadowOverlap, experimentLatency, breakerState, breakerTrips, breakerRejected, client.LeaderRedirects, client.PartitionReresolves, client.CacheCorruptions)
+		// own mux so the pprof handlers on the default mux are not exposed without auth
+		mux := http.NewServeMux()
+		// exemplars are only exposed in the OpenMetrics format
+		mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+		mux.HandleFunc("/debug/dashboards", handleDashboards)
+		mux.HandleFunc("/debug/dashboards/", handleDashboards)
 		go func() {
 			if monitorPort > 0 {
 				log.Info("monitoring start in Port: %v", monitorPort)
-				if err := http.ListenAndServe(":"+cast.ToString(monitorPort), nil); err != nil {
+				if err := http.ListenAndServe(":"+cast.ToString(monitorPort), mux); err != nil {
 					log.Error("Error occur when start server %v", err)
 				}
 			} else {
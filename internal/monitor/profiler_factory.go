@@ -15,17 +15,50 @@
 package monitor
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"github.com/caio/go-tdigest"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uber/jaeger-client-go"
 )
 
 var mutex sync.Mutex
 
 var metricMap = map[string]*Digest{}
 
+// latencyBuckets covers 1ms to about 16s in milliseconds
+var latencyBuckets = prometheus.ExponentialBuckets(1, 2, 15)
+
+// requestLatency is the histogram of request latency, observations made by
+// ProfilerContext carry the trace id as exemplar so a slow bucket links to its trace
+var requestLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "vearch_request_latency_milliseconds",
+	Help:    "histogram of request api latency",
+	Buckets: latencyBuckets,
+}, []string{"key"})
+
 func Profiler(key string, startTime time.Time) {
+	ProfilerContext(context.Background(), key, startTime)
+}
+
+// ProfilerContext is Profiler with the trace of ctx, if ctx has a sampled jaeger
+// span the trace id is recorded as exemplar of the latency histogram
+func ProfilerContext(ctx context.Context, key string, startTime time.Time) {
+	costTime := time.Since(startTime).Milliseconds()
+	observer := requestLatency.WithLabelValues(key)
+	if traceID := traceIDFromContext(ctx); traceID != "" {
+		if eo, ok := observer.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(float64(costTime), prometheus.Labels{"trace_id": traceID})
+		} else {
+			observer.Observe(float64(costTime))
+		}
+	} else {
+		observer.Observe(float64(costTime))
+	}
+
 	mutex.Lock()
 	digest, ok := metricMap[key]
 	if !ok {
@@ -35,12 +68,23 @@ func Profiler(key string, startTime time.Time) {
 	mutex.Unlock()
 
 	digest.Lock()
-	costTime := time.Since(startTime).Milliseconds()
 	digest.Digest.Add(float64(costTime))
 	digest.Sum += float64(costTime)
 	digest.Unlock()
 }
 
+func traceIDFromContext(ctx context.Context) string {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	sc, ok := span.Context().(jaeger.SpanContext)
+	if !ok || !sc.IsSampled() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
 func SliceMetric() map[string]*Digest {
 	mutex.Lock()
 	newMap := metricMap
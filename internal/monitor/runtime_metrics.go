@@ -0,0 +1,65 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package monitor
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vearch/vearch/v3/internal/pkg/diagnose"
+)
+
+// runtimeCollector reports the runtime limits of the process and the gc
+// pressure under them, memory stats are read once a scrape
+type runtimeCollector struct {
+	maxProcs      *prometheus.Desc
+	memoryLimit   *prometheus.Desc
+	gcCPUFraction *prometheus.Desc
+	heapRatio     *prometheus.Desc
+	cgroupCPU     *prometheus.Desc
+	cgroupMemory  *prometheus.Desc
+}
+
+func newRuntimeCollector() prometheus.Collector {
+	return &runtimeCollector{
+		maxProcs:      prometheus.NewDesc("vearch_runtime_gomaxprocs", "GOMAXPROCS of the process", nil, nil),
+		memoryLimit:   prometheus.NewDesc("vearch_runtime_memory_limit_bytes", "GOMEMLIMIT of the process", nil, nil),
+		gcCPUFraction: prometheus.NewDesc("vearch_runtime_gc_cpu_fraction", "share of cpu spent by gc since the process started", nil, nil),
+		heapRatio:     prometheus.NewDesc("vearch_runtime_heap_limit_ratio", "heap in use over GOMEMLIMIT, 0 without a limit", nil, nil),
+		cgroupCPU:     prometheus.NewDesc("vearch_runtime_cgroup_cpu", "cpu quota of the cgroup in cpus, 0 without a quota", nil, nil),
+		cgroupMemory:  prometheus.NewDesc("vearch_runtime_cgroup_memory_bytes", "memory limit of the cgroup, 0 without a limit", nil, nil),
+	}
+}
+
+func (c *runtimeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxProcs
+	ch <- c.memoryLimit
+	ch <- c.gcCPUFraction
+	ch <- c.heapRatio
+	ch <- c.cgroupCPU
+	ch <- c.cgroupMemory
+}
+
+func (c *runtimeCollector) Collect(ch chan<- prometheus.Metric) {
+	gcCPUFraction, heapRatio := diagnose.GCPressure()
+	cgroup := diagnose.Cgroup()
+	ch <- prometheus.MustNewConstMetric(c.maxProcs, prometheus.GaugeValue, float64(runtime.GOMAXPROCS(0)))
+	ch <- prometheus.MustNewConstMetric(c.memoryLimit, prometheus.GaugeValue, float64(debug.SetMemoryLimit(-1)))
+	ch <- prometheus.MustNewConstMetric(c.gcCPUFraction, prometheus.GaugeValue, gcCPUFraction)
+	ch <- prometheus.MustNewConstMetric(c.heapRatio, prometheus.GaugeValue, heapRatio)
+	ch <- prometheus.MustNewConstMetric(c.cgroupCPU, prometheus.GaugeValue, cgroup.CPU)
+	ch <- prometheus.MustNewConstMetric(c.cgroupMemory, prometheus.GaugeValue, float64(cgroup.Memory))
+}
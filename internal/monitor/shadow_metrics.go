@@ -0,0 +1,58 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package monitor
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// shadow result of a mirrored search
+const (
+	ShadowOK      = "ok"
+	ShadowError   = "error"
+	ShadowDropped = "dropped"
+)
+
+var shadowRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "vearch_shadow_requests_total",
+	Help: "searches mirrored to the shadow target by result",
+}, []string{"space", "target", "result"})
+
+var shadowLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "vearch_shadow_latency_milliseconds",
+	Help:    "latency of searches on the shadow target",
+	Buckets: latencyBuckets,
+}, []string{"space", "target"})
+
+var shadowOverlap = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "vearch_shadow_result_overlap_ratio",
+	Help:    "ratio of primary result ids also returned by the shadow target",
+	Buckets: prometheus.LinearBuckets(0, 0.1, 11),
+}, []string{"space", "target"})
+
+// ShadowObserve records one mirrored search
+func ShadowObserve(space, target, result string, cost time.Duration) {
+	shadowRequests.WithLabelValues(space, target, result).Inc()
+	if result == ShadowOK {
+		shadowLatency.WithLabelValues(space, target).Observe(float64(cost.Milliseconds()))
+	}
+}
+
+// ShadowDiff records the overlap of the ids of a mirrored search
+func ShadowDiff(space, target string, overlap float64) {
+	shadowOverlap.WithLabelValues(space, target).Observe(overlap)
+}
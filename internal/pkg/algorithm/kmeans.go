@@ -0,0 +1,211 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package algorithm
+
+import (
+	"math"
+	"math/rand"
+)
+
+// KMeans clusters vectors into k groups by L2 with k-means++ seeding, it
+// stops after iterations or when no label changes. It returns the centroids,
+// the label of each vector and the sum of squared distances to the centroids.
+func KMeans(vectors [][]float32, k, iterations int, seed int64) ([][]float32, []int, float64) {
+	if len(vectors) == 0 || k <= 0 {
+		return nil, nil, 0
+	}
+	if k > len(vectors) {
+		k = len(vectors)
+	}
+	r := rand.New(rand.NewSource(seed))
+	dim := len(vectors[0])
+
+	// k-means++: each next centroid is picked with probability proportional
+	// to its squared distance to the nearest picked one
+	centroids := make([][]float32, 0, k)
+	centroids = append(centroids, copyVector(vectors[r.Intn(len(vectors))]))
+	nearest := make([]float64, len(vectors))
+	for i, v := range vectors {
+		nearest[i] = float64(Distance(MetricL2, v, centroids[0]))
+	}
+	for len(centroids) < k {
+		sum := 0.0
+		for _, d := range nearest {
+			sum += d
+		}
+		pick := 0
+		if sum > 0 {
+			target := r.Float64() * sum
+			for i, d := range nearest {
+				target -= d
+				if target <= 0 {
+					pick = i
+					break
+				}
+			}
+		} else {
+			pick = r.Intn(len(vectors))
+		}
+		c := copyVector(vectors[pick])
+		centroids = append(centroids, c)
+		for i, v := range vectors {
+			if d := float64(Distance(MetricL2, v, c)); d < nearest[i] {
+				nearest[i] = d
+			}
+		}
+	}
+
+	labels := make([]int, len(vectors))
+	for i := range labels {
+		labels[i] = -1
+	}
+	var inertia float64
+	for iter := 0; ; iter++ {
+		changed := false
+		inertia = 0
+		for i, v := range vectors {
+			best, bestDistance := 0, float32(math.MaxFloat32)
+			for j, c := range centroids {
+				if d := Distance(MetricL2, v, c); d < bestDistance {
+					best, bestDistance = j, d
+				}
+			}
+			if labels[i] != best {
+				labels[i] = best
+				changed = true
+			}
+			inertia += float64(bestDistance)
+		}
+		// labels stay consistent with the centroids returned
+		if !changed || iter >= iterations-1 {
+			break
+		}
+
+		sums := make([][]float64, k)
+		counts := make([]int, k)
+		for j := range sums {
+			sums[j] = make([]float64, dim)
+		}
+		for i, v := range vectors {
+			counts[labels[i]]++
+			for d, x := range v {
+				sums[labels[i]][d] += float64(x)
+			}
+		}
+		for j := range centroids {
+			// an empty cluster keeps its centroid
+			if counts[j] == 0 {
+				continue
+			}
+			for d := range centroids[j] {
+				centroids[j][d] = float32(sums[j][d] / float64(counts[j]))
+			}
+		}
+	}
+	return centroids, labels, inertia
+}
+
+// PCA2D projects vectors onto their first two principal components found by
+// power iteration, it is good enough for plotting a sample
+func PCA2D(vectors [][]float32, seed int64) [][2]float32 {
+	if len(vectors) == 0 {
+		return nil
+	}
+	dim := len(vectors[0])
+	mean := make([]float64, dim)
+	for _, v := range vectors {
+		for d, x := range v {
+			mean[d] += float64(x)
+		}
+	}
+	for d := range mean {
+		mean[d] /= float64(len(vectors))
+	}
+	centered := make([][]float64, len(vectors))
+	for i, v := range vectors {
+		centered[i] = make([]float64, dim)
+		for d, x := range v {
+			centered[i][d] = float64(x) - mean[d]
+		}
+	}
+
+	variance := 0.0
+	for _, x := range centered {
+		variance += dot(x, x)
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	components := make([][]float64, 0, 2)
+	// orthonormalize v against the components found, false if nothing is left
+	orthonormalize := func(v []float64, min float64) bool {
+		for _, prev := range components {
+			p := dot(v, prev)
+			for d := range v {
+				v[d] -= p * prev[d]
+			}
+		}
+		norm := math.Sqrt(dot(v, v))
+		if norm <= min {
+			return false
+		}
+		for d := range v {
+			v[d] /= norm
+		}
+		return true
+	}
+	for len(components) < 2 {
+		c := make([]float64, dim)
+		for d := range c {
+			c[d] = r.Float64() - 0.5
+		}
+		orthonormalize(c, 0)
+		for iter := 0; iter < 100; iter++ {
+			// c = X^T X c, a negligible result means the data has no variance
+			// left in other directions and c is kept
+			next := make([]float64, dim)
+			for _, x := range centered {
+				p := dot(x, c)
+				for d := range next {
+					next[d] += p * x[d]
+				}
+			}
+			if !orthonormalize(next, 1e-9*variance) {
+				break
+			}
+			c = next
+		}
+		components = append(components, c)
+	}
+
+	points := make([][2]float32, len(centered))
+	for i, x := range centered {
+		points[i] = [2]float32{float32(dot(x, components[0])), float32(dot(x, components[1]))}
+	}
+	return points
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func copyVector(v []float32) []float32 {
+	c := make([]float32, len(v))
+	copy(c, v)
+	return c
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package algorithm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// blobs returns n points around each center
+func blobs(centers [][]float32, n int, seed int64) [][]float32 {
+	r := rand.New(rand.NewSource(seed))
+	var vectors [][]float32
+	for _, c := range centers {
+		for i := 0; i < n; i++ {
+			v := make([]float32, len(c))
+			for d := range c {
+				v[d] = c[d] + float32(r.NormFloat64()*0.1)
+			}
+			vectors = append(vectors, v)
+		}
+	}
+	return vectors
+}
+
+func TestKMeans(t *testing.T) {
+	centers := [][]float32{{0, 0, 0}, {10, 10, 10}, {-10, 10, 0}}
+	vectors := blobs(centers, 50, 1)
+
+	centroids, labels, inertia := KMeans(vectors, 3, 20, 1)
+	if len(centroids) != 3 || len(labels) != len(vectors) {
+		t.Fatalf("expect 3 centroids and %d labels, got %d and %d", len(vectors), len(centroids), len(labels))
+	}
+	// every blob is one cluster
+	for b := range centers {
+		label := labels[b*50]
+		for i := b * 50; i < (b+1)*50; i++ {
+			if labels[i] != label {
+				t.Fatalf("blob %d split into clusters %d and %d", b, label, labels[i])
+			}
+		}
+		if d := Distance(MetricL2, centroids[label], centers[b]); d > 0.1 {
+			t.Fatalf("centroid of blob %d is %v away", b, d)
+		}
+	}
+	if inertia <= 0 || inertia > float64(len(vectors)) {
+		t.Fatalf("unexpected inertia %v", inertia)
+	}
+
+	again, _, _ := KMeans(vectors, 3, 20, 1)
+	for j := range centroids {
+		if Distance(MetricL2, centroids[j], again[j]) != 0 {
+			t.Fatalf("kmeans with the same seed is not deterministic")
+		}
+	}
+}
+
+func TestKMeansMoreClustersThanVectors(t *testing.T) {
+	centroids, labels, _ := KMeans([][]float32{{1, 1}, {2, 2}}, 5, 10, 1)
+	if len(centroids) != 2 || len(labels) != 2 || labels[0] == labels[1] {
+		t.Fatalf("expect 2 clusters, got %v %v", centroids, labels)
+	}
+}
+
+func TestPCA2D(t *testing.T) {
+	// points on a line in 3d keep their order on the first component
+	vectors := make([][]float32, 10)
+	for i := range vectors {
+		x := float32(i)
+		vectors[i] = []float32{x, 2 * x, -x}
+	}
+	points := PCA2D(vectors, 1)
+	if len(points) != len(vectors) {
+		t.Fatalf("expect %d points, got %d", len(vectors), len(points))
+	}
+	step := points[1][0] - points[0][0]
+	for i := 1; i < len(points); i++ {
+		if math.Abs(float64(points[i][0]-points[i-1][0]-step)) > 1e-3 {
+			t.Fatalf("first component is not linear: %v", points)
+		}
+		if math.Abs(float64(points[i][1])) > 1e-3 {
+			t.Fatalf("second component of a line should be 0: %v", points)
+		}
+	}
+	if math.Abs(math.Abs(float64(step))-math.Sqrt(6)) > 1e-3 {
+		t.Fatalf("expect step sqrt(6), got %v", step)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package algorithm
+
+import (
+	"container/heap"
+	"sort"
+)
+
+const (
+	MetricL2           = "L2"
+	MetricInnerProduct = "InnerProduct"
+)
+
+// Distance returns the distance of a and b by metric, smaller is closer.
+// L2 is the squared distance, inner product is negated.
+func Distance(metric string, a, b []float32) float32 {
+	var sum float32
+	if metric == MetricInnerProduct {
+		for i := range a {
+			sum += a[i] * b[i]
+		}
+		return -sum
+	}
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+type neighbor struct {
+	index    int
+	distance float32
+}
+
+// maxHeap keeps the k nearest neighbors, the farthest on top
+type maxHeap []neighbor
+
+func (h maxHeap) Len() int { return len(h) }
+func (h maxHeap) Less(i, j int) bool {
+	if h[i].distance != h[j].distance {
+		return h[i].distance > h[j].distance
+	}
+	return h[i].index > h[j].index
+}
+func (h maxHeap) Swap(i, j int)         { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{})   { *h = append(*h, x.(neighbor)) }
+func (h *maxHeap) Pop() (v interface{}) { *h, v = (*h)[:len(*h)-1], (*h)[len(*h)-1]; return }
+
+// BruteForceKNN returns the indexes in base of the k nearest neighbors of
+// each query, nearest first, ties are ordered by index
+func BruteForceKNN(metric string, base, queries [][]float32, k int) [][]int {
+	result := make([][]int, len(queries))
+	for qi, q := range queries {
+		h := make(maxHeap, 0, k+1)
+		for i, v := range base {
+			d := Distance(metric, q, v)
+			if len(h) < k {
+				heap.Push(&h, neighbor{index: i, distance: d})
+			} else if len(h) > 0 && (d < h[0].distance || (d == h[0].distance && i < h[0].index)) {
+				h[0] = neighbor{index: i, distance: d}
+				heap.Fix(&h, 0)
+			}
+		}
+		sort.Slice(h, func(i, j int) bool { return h.Less(j, i) })
+		ids := make([]int, len(h))
+		for i, n := range h {
+			ids[i] = n.index
+		}
+		result[qi] = ids
+	}
+	return result
+}
+
+// Recall returns the fraction of truth[:k] found in result[:k]
+func Recall[T comparable](result, truth []T, k int) float64 {
+	if k > len(truth) {
+		k = len(truth)
+	}
+	if k == 0 {
+		return 1
+	}
+	expect := make(map[T]bool, k)
+	for _, id := range truth[:k] {
+		expect[id] = true
+	}
+	hit := 0
+	for i, id := range result {
+		if i >= k {
+			break
+		}
+		if expect[id] {
+			hit++
+			delete(expect, id)
+		}
+	}
+	return float64(hit) / float64(k)
+}
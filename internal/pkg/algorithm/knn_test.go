@@ -0,0 +1,61 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package algorithm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBruteForceKNN(t *testing.T) {
+	base := [][]float32{{0, 0}, {1, 0}, {0, 2}, {3, 3}, {1, 0}}
+	queries := [][]float32{{0.9, 0}, {3, 2}}
+
+	got := BruteForceKNN(MetricL2, base, queries, 3)
+	want := [][]int{{1, 4, 0}, {3, 1, 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("l2 knn %v, want %v", got, want)
+	}
+
+	got = BruteForceKNN(MetricInnerProduct, base, queries[1:], 2)
+	want = [][]int{{3, 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("inner product knn %v, want %v", got, want)
+	}
+
+	if got := BruteForceKNN(MetricL2, base[:1], queries[:1], 3); !reflect.DeepEqual(got, [][]int{{0}}) {
+		t.Fatalf("knn with k larger than base %v", got)
+	}
+}
+
+func TestRecall(t *testing.T) {
+	truth := []string{"a", "b", "c", "d"}
+	cases := []struct {
+		result []string
+		k      int
+		want   float64
+	}{
+		{[]string{"a", "b", "c"}, 3, 1},
+		{[]string{"c", "x", "a"}, 3, 2.0 / 3},
+		{[]string{"d", "a", "a"}, 2, 0.5},
+		{nil, 2, 0},
+		{[]string{"a"}, 0, 1},
+	}
+	for _, c := range cases {
+		if got := Recall(c.result, truth, c.k); got != c.want {
+			t.Fatalf("recall of %v at %d = %v, want %v", c.result, c.k, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package algorithm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	ten := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{name: "Min", sorted: ten, p: 0, want: 1},
+		{name: "Median", sorted: ten, p: 0.5, want: 5},
+		{name: "P90", sorted: ten, p: 0.9, want: 9},
+		{name: "P99 rounds up", sorted: ten, p: 0.99, want: 10},
+		{name: "Max", sorted: ten, p: 1, want: 10},
+		{name: "Over max", sorted: ten, p: 1.5, want: 10},
+		{name: "Rank between values", sorted: []float64{15, 20, 35, 40, 50}, p: 0.3, want: 20},
+		{name: "One value", sorted: []float64{7}, p: 0.5, want: 7},
+		{name: "Empty", p: 0.5, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("Percentile(%v, %.2f) = %v, want %v", tt.sorted, tt.p, got, tt.want)
+			}
+		})
+	}
+
+	durations := []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}
+	if got := Percentile(durations, 0.5); got != 2*time.Millisecond {
+		t.Errorf("Percentile of durations = %v, want 2ms", got)
+	}
+}
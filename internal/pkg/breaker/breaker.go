@@ -0,0 +1,257 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package breaker is a circuit breaker tripping on the error and timeout
+// rates of a rolling window of requests. An open breaker fails the requests
+// fast for a cool down, then lets a few probes through half open: they close
+// it if they all succeed and the first failing one opens it again
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	}
+	return "unknown"
+}
+
+// Outcome of a request let through, an ignored one is counted neither as a
+// success nor as a failure, as the requests failed by the caller itself
+type Outcome int
+
+const (
+	Success Outcome = iota
+	Failure
+	Timeout
+	Ignored
+)
+
+// buckets of the rolling window, the oldest one is dropped at a time
+const buckets = 10
+
+// Config of a breaker, the rates are of the failures and timeouts over the
+// requests of the window and a rate of 0 never trips it
+type Config struct {
+	Window      time.Duration
+	MinRequests int // requests of the window before a rate may trip it
+	ErrorRate   float64
+	TimeoutRate float64
+	OpenTime    time.Duration // cool down before probing
+	Probes      int           // probes let through half open
+}
+
+type counts struct {
+	epoch    int64
+	requests int
+	failures int
+	timeouts int
+}
+
+// Stats of a breaker, the counts are of the window
+type Stats struct {
+	State    State
+	Requests int
+	Failures int // timeouts included
+	Timeouts int
+	OpenedAt time.Time // zero if closed
+}
+
+// Breaker is a concurrent safe circuit breaker
+type Breaker struct {
+	mu       sync.Mutex
+	cfg      Config
+	state    State
+	openedAt time.Time
+	window   [buckets]counts
+	// generation changes with the state, the outcomes of the requests let
+	// through in another generation are dropped
+	generation int
+	probes     int // probes let through in this half open generation
+	successes  int
+	onChange   func(from, to State)
+	now        func() time.Time
+}
+
+// New returns a closed breaker, onChange is called under the lock of the
+// breaker on every change of state if it is not nil
+func New(cfg Config, onChange func(from, to State)) *Breaker {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.Probes < 1 {
+		cfg.Probes = 1
+	}
+	return &Breaker{cfg: cfg, onChange: onChange, now: time.Now}
+}
+
+// Allow returns whether a request may go, done records its outcome and
+// should be called once if it may
+func (b *Breaker) Allow() (done func(Outcome), ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if b.state == Open {
+		if now.Sub(b.openedAt) < b.cfg.OpenTime {
+			return nil, false
+		}
+		b.setState(HalfOpen, now)
+	}
+	if b.state == HalfOpen {
+		if b.probes >= b.cfg.Probes {
+			return nil, false
+		}
+		b.probes++
+	}
+	generation := b.generation
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() { b.record(generation, outcome) })
+	}, true
+}
+
+// RetryAfter returns how long an open breaker fails the requests before
+// probing, 0 if it is not open
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != Open {
+		return 0
+	}
+	if left := b.cfg.OpenTime - b.now().Sub(b.openedAt); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Stats returns the state and the counts of the window
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := Stats{State: b.state, OpenedAt: b.openedAt}
+	epoch := b.epoch(b.now())
+	for _, c := range b.window {
+		if c.epoch > epoch-buckets {
+			stats.Requests += c.requests
+			stats.Failures += c.failures
+			stats.Timeouts += c.timeouts
+		}
+	}
+	return stats
+}
+
+// Reset closes the breaker and clears its window
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setState(Closed, b.now())
+}
+
+// epoch is the number of the bucket of now since unix time
+func (b *Breaker) epoch(now time.Time) int64 {
+	width := int64(b.cfg.Window / buckets)
+	if width < 1 {
+		width = 1
+	}
+	return now.UnixNano() / width
+}
+
+func (b *Breaker) record(generation int, outcome Outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	now := b.now()
+	if b.state == HalfOpen {
+		switch outcome {
+		case Success:
+			if b.successes++; b.successes >= b.cfg.Probes {
+				b.setState(Closed, now)
+			}
+		case Failure, Timeout:
+			b.setState(Open, now)
+		case Ignored:
+			b.probes--
+		}
+		return
+	}
+	if outcome == Ignored {
+		return
+	}
+
+	epoch := b.epoch(now)
+	c := &b.window[epoch%buckets]
+	if c.epoch != epoch {
+		*c = counts{epoch: epoch}
+	}
+	c.requests++
+	switch outcome {
+	case Failure:
+		c.failures++
+	case Timeout:
+		c.failures++
+		c.timeouts++
+	}
+
+	requests, failures, timeouts := 0, 0, 0
+	for _, c := range b.window {
+		if c.epoch > epoch-buckets {
+			requests += c.requests
+			failures += c.failures
+			timeouts += c.timeouts
+		}
+	}
+	if requests < b.cfg.MinRequests || requests == 0 {
+		return
+	}
+	if (b.cfg.ErrorRate > 0 && float64(failures) >= b.cfg.ErrorRate*float64(requests)) ||
+		(b.cfg.TimeoutRate > 0 && float64(timeouts) >= b.cfg.TimeoutRate*float64(requests)) {
+		b.setState(Open, now)
+	}
+}
+
+// setState starts a new generation of state, the window is cleared as the
+// requests of the old state say nothing of the new one
+func (b *Breaker) setState(state State, now time.Time) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.probes, b.successes = 0, 0
+	b.window = [buckets]counts{}
+	if state == Open {
+		b.openedAt = now
+	} else if state == Closed {
+		b.openedAt = time.Time{}
+	}
+	if b.onChange != nil && from != state {
+		b.onChange(from, state)
+	}
+}
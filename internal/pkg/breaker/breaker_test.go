@@ -0,0 +1,211 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestBreaker(cfg Config) (*Breaker, *clock, *[]State) {
+	changes := &[]State{}
+	b := New(cfg, func(from, to State) { *changes = append(*changes, to) })
+	c := &clock{t: time.Unix(1760000000, 0)}
+	b.now = c.now
+	return b, c, changes
+}
+
+func run(t *testing.T, b *Breaker, outcomes ...Outcome) {
+	t.Helper()
+	for _, outcome := range outcomes {
+		done, ok := b.Allow()
+		if !ok {
+			t.Fatalf("request rejected in state %s", b.Stats().State)
+		}
+		done(outcome)
+	}
+}
+
+func repeat(outcome Outcome, n int) []Outcome {
+	outcomes := make([]Outcome, n)
+	for i := range outcomes {
+		outcomes[i] = outcome
+	}
+	return outcomes
+}
+
+func TestBreakerTrip(t *testing.T) {
+	cfg := Config{Window: 10 * time.Second, MinRequests: 10, ErrorRate: 0.5, TimeoutRate: 0.2, OpenTime: time.Second, Probes: 2}
+	tests := []struct {
+		name     string
+		outcomes []Outcome
+		want     State
+	}{
+		{
+			name:     "Successes",
+			outcomes: repeat(Success, 20),
+			want:     Closed,
+		},
+		{
+			name:     "Failures under min requests",
+			outcomes: repeat(Failure, 9),
+			want:     Closed,
+		},
+		{
+			name:     "Error rate",
+			outcomes: append(repeat(Success, 5), repeat(Failure, 5)...),
+			want:     Open,
+		},
+		{
+			name:     "Error rate under threshold",
+			outcomes: append(repeat(Success, 6), repeat(Failure, 4)...),
+			want:     Closed,
+		},
+		{
+			name:     "Timeout rate",
+			outcomes: append(repeat(Success, 8), repeat(Timeout, 2)...),
+			want:     Open,
+		},
+		{
+			name:     "Ignored not counted",
+			outcomes: append(repeat(Ignored, 20), repeat(Failure, 9)...),
+			want:     Closed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _, _ := newTestBreaker(cfg)
+			for _, outcome := range tt.outcomes {
+				done, ok := b.Allow()
+				if !ok {
+					break
+				}
+				done(outcome)
+			}
+			if got := b.Stats().State; got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	b, c, _ := newTestBreaker(Config{Window: 10 * time.Second, MinRequests: 10, ErrorRate: 0.5, OpenTime: time.Second})
+	run(t, b, repeat(Failure, 9)...)
+	// the failures leave the window before the request reaching min requests
+	c.t = c.t.Add(11 * time.Second)
+	run(t, b, Failure)
+	if stats := b.Stats(); stats.State != Closed || stats.Requests != 1 {
+		t.Errorf("stats = %+v, want closed with 1 request", stats)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	cfg := Config{Window: 10 * time.Second, MinRequests: 2, ErrorRate: 0.5, OpenTime: time.Second, Probes: 2}
+	tests := []struct {
+		name    string
+		probes  []Outcome
+		want    State
+		changes []State
+	}{
+		{name: "Probes succeed", probes: []Outcome{Success, Success}, want: Closed, changes: []State{Open, HalfOpen, Closed}},
+		{name: "Probe fails", probes: []Outcome{Success, Failure}, want: Open, changes: []State{Open, HalfOpen, Open}},
+		{name: "Probe times out", probes: []Outcome{Timeout}, want: Open, changes: []State{Open, HalfOpen, Open}},
+		{name: "Ignored probe let again", probes: []Outcome{Ignored, Success, Success}, want: Closed, changes: []State{Open, HalfOpen, Closed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, c, changes := newTestBreaker(cfg)
+			run(t, b, Failure, Failure)
+			if _, ok := b.Allow(); ok {
+				t.Fatal("open breaker let a request through")
+			}
+			if got := b.RetryAfter(); got != time.Second {
+				t.Errorf("RetryAfter() = %v, want 1s", got)
+			}
+			c.t = c.t.Add(time.Second)
+			for _, outcome := range tt.probes {
+				done, ok := b.Allow()
+				if !ok {
+					t.Fatalf("probe rejected in state %s", b.Stats().State)
+				}
+				done(outcome)
+			}
+			if got := b.Stats().State; got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+			if len(*changes) != len(tt.changes) {
+				t.Fatalf("changes = %v, want %v", *changes, tt.changes)
+			}
+			for i := range tt.changes {
+				if (*changes)[i] != tt.changes[i] {
+					t.Errorf("changes = %v, want %v", *changes, tt.changes)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerProbes(t *testing.T) {
+	b, c, _ := newTestBreaker(Config{Window: 10 * time.Second, MinRequests: 2, ErrorRate: 0.5, OpenTime: time.Second, Probes: 2})
+	run(t, b, Failure, Failure)
+	c.t = c.t.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		if _, ok := b.Allow(); !ok {
+			t.Fatalf("probe %d rejected", i)
+		}
+	}
+	if _, ok := b.Allow(); ok {
+		t.Error("half open breaker let more probes than configured through")
+	}
+}
+
+func TestBreakerStaleOutcome(t *testing.T) {
+	b, c, _ := newTestBreaker(Config{Window: 10 * time.Second, MinRequests: 2, ErrorRate: 0.5, OpenTime: time.Second, Probes: 1})
+	slow, _ := b.Allow()
+	run(t, b, Failure, Failure)
+	c.t = c.t.Add(time.Second)
+	probe, ok := b.Allow()
+	if !ok {
+		t.Fatal("probe rejected")
+	}
+	// the outcome of a request let through before the breaker opened does
+	// not count for the probe
+	slow(Failure)
+	probe(Success)
+	probe(Failure)
+	if got := b.Stats().State; got != Closed {
+		t.Errorf("state = %s, want closed", got)
+	}
+}
+
+func TestBreakerReset(t *testing.T) {
+	b, _, changes := newTestBreaker(Config{Window: 10 * time.Second, MinRequests: 2, ErrorRate: 0.5, OpenTime: time.Minute})
+	run(t, b, Failure, Failure)
+	b.Reset()
+	if stats := b.Stats(); stats.State != Closed || stats.Requests != 0 || !stats.OpenedAt.IsZero() {
+		t.Errorf("stats = %+v after reset", stats)
+	}
+	if _, ok := b.Allow(); !ok {
+		t.Error("reset breaker rejected a request")
+	}
+	if len(*changes) != 2 {
+		t.Errorf("changes = %v, want open and closed", *changes)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package compress compresses the data ps send to each other by zstd
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Zstd             = "zstd"
+	DefaultZstdLevel = 3
+
+	// a frame decompresses to at most this, snapshot chunks are far smaller
+	maxDecodedSize = 256 << 20
+)
+
+// zstdMagic starts every zstd frame, protobuf messages never start with it
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+var (
+	encoders sync.Map // level -> *zstd.Encoder
+
+	decoderOnce sync.Once
+	decoder     *zstd.Decoder
+	decoderErr  error
+)
+
+// Compress compresses data by the named compression at level, data is
+// returned as is if name is empty
+func Compress(name string, level int, data []byte) ([]byte, error) {
+	switch name {
+	case "":
+		return data, nil
+	case Zstd:
+		encoder, err := zstdEncoder(level)
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %s", name)
+	}
+}
+
+// Decompress decompresses a zstd frame
+func Decompress(data []byte) ([]byte, error) {
+	decoderOnce.Do(func() {
+		decoder, decoderErr = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(maxDecodedSize))
+	})
+	if decoderErr != nil {
+		return nil, decoderErr
+	}
+	return decoder.DecodeAll(data, nil)
+}
+
+// Compressed reports whether data is a zstd frame
+func Compressed(data []byte) bool {
+	return bytes.HasPrefix(data, zstdMagic)
+}
+
+func zstdEncoder(level int) (*zstd.Encoder, error) {
+	if level <= 0 {
+		level = DefaultZstdLevel
+	}
+	if e, ok := encoders.Load(level); ok {
+		return e.(*zstd.Encoder), nil
+	}
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	if err != nil {
+		return nil, err
+	}
+	e, _ := encoders.LoadOrStore(level, encoder)
+	return e.(*zstd.Encoder), nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte("vearch snapshot chunk "), 1000)
+	tests := []struct {
+		name           string
+		compression    string
+		level          int
+		wantCompressed bool
+		wantErr        bool
+	}{
+		{name: "none", compression: ""},
+		{name: "zstd default level", compression: Zstd, wantCompressed: true},
+		{name: "zstd best", compression: Zstd, level: 19, wantCompressed: true},
+		{name: "unknown", compression: "lz4", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Compress(tt.compression, tt.level, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if Compressed(out) != tt.wantCompressed {
+				t.Fatalf("Compressed() = %v, want %v", Compressed(out), tt.wantCompressed)
+			}
+			if !tt.wantCompressed {
+				return
+			}
+			if len(out) >= len(data) {
+				t.Errorf("compressed %d bytes into %d", len(data), len(out))
+			}
+			got, err := Decompress(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("Decompress() does not return the data")
+			}
+		})
+	}
+}
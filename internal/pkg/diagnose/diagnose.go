@@ -0,0 +1,261 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package diagnose serves the runtime diagnostics of a vearch process: pprof,
+// expvar, goroutine and heap snapshots, GOGC/GOMEMLIMIT/GOMAXPROCS adjustment
+// and log levels.
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	rpprof "runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/fault"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+const PathPrefix = "/debug/"
+
+var (
+	gcMu      sync.Mutex
+	gcPercent = initGCPercent()
+)
+
+// initGCPercent returns the GOGC of process start, it can not be read
+// back from runtime without changing it
+func initGCPercent() int {
+	v := os.Getenv("GOGC")
+	if v == "off" {
+		return -1
+	}
+	if p, err := strconv.Atoi(v); err == nil {
+		return p
+	}
+	return 100
+}
+
+// RuntimeSettings is the body of /debug/runtime, nil fields are not changed
+type RuntimeSettings struct {
+	GCPercent   *int   `json:"gogc,omitempty"`
+	MemoryLimit *int64 `json:"memory_limit,omitempty"`
+	MaxProcs    *int   `json:"gomaxprocs,omitempty"`
+}
+
+type runtimeInfo struct {
+	GCPercent   int    `json:"gogc"`
+	MemoryLimit int64  `json:"memory_limit"`
+	Goroutines  int    `json:"goroutines"`
+	NumGC       uint32 `json:"num_gc"`
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapSys     uint64 `json:"heap_sys"`
+	GOMAXPROCS  int    `json:"gomaxprocs"`
+	// gc pressure
+	GCCPUFraction float64      `json:"gc_cpu_fraction"`
+	PauseTotalNs  uint64       `json:"pause_total_ns"`
+	NextGC        uint64       `json:"next_gc"`
+	Cgroup        CgroupLimits `json:"cgroup"`
+}
+
+func init() {
+	expvar.Publish("runtime", expvar.Func(func() interface{} { return currentRuntime() }))
+}
+
+// NewHandler returns the handler of all diagnostics endpoints under /debug/,
+// snapshots are written to snapshotDir
+func NewHandler(snapshotDir string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathPrefix+"pprof/", pprof.Index)
+	mux.HandleFunc(PathPrefix+"pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc(PathPrefix+"pprof/profile", pprof.Profile)
+	mux.HandleFunc(PathPrefix+"pprof/symbol", pprof.Symbol)
+	mux.HandleFunc(PathPrefix+"pprof/trace", pprof.Trace)
+	mux.Handle(PathPrefix+"vars", expvar.Handler())
+	mux.HandleFunc(PathPrefix+"runtime", handleRuntime)
+	mux.HandleFunc(PathPrefix+"log", handleLogLevel)
+	mux.HandleFunc(PathPrefix+"faults", fault.Handler)
+	mux.HandleFunc(PathPrefix+"snapshot", func(w http.ResponseWriter, r *http.Request) {
+		handleSnapshot(w, r, snapshotDir)
+	})
+	return mux
+}
+
+// BasicAuth checks the basic auth credentials of request by authorize before
+// serving h, authorize should only pass the admin users
+func BasicAuth(h http.Handler, authorize func(ctx context.Context, user, password string) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="vearch"`)
+			http.Error(w, "auth header is empty or invalid", http.StatusUnauthorized)
+			return
+		}
+		if err := authorize(r.Context(), user, password); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func handleRuntime(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		settings := &RuntimeSettings{}
+		if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := Apply(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, currentRuntime())
+}
+
+// LogLevel is the body of /debug/log, empty module is the default level
+type LogLevel struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}
+
+func handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	lc, ok := log.Get().(log.LevelController)
+	if !ok {
+		http.Error(w, "log levels can not be changed at runtime, set log_format to use structured log", http.StatusNotImplemented)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		level := &LogLevel{}
+		if err := json.NewDecoder(r.Body).Decode(level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := lc.SetLevel(level.Module, level.Level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Info("set log level of module [%s] to [%s]", level.Module, level.Level)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, lc.Levels())
+}
+
+// Apply changes GOGC, GOMEMLIMIT and GOMAXPROCS of the running process
+func Apply(settings *RuntimeSettings) error {
+	if settings.MemoryLimit != nil && *settings.MemoryLimit <= 0 {
+		return fmt.Errorf("memory_limit should be positive, use math.MaxInt64 for no limit")
+	}
+	if settings.MaxProcs != nil && *settings.MaxProcs <= 0 {
+		return fmt.Errorf("gomaxprocs should be positive")
+	}
+	gcMu.Lock()
+	defer gcMu.Unlock()
+	if settings.GCPercent != nil {
+		old := debug.SetGCPercent(*settings.GCPercent)
+		gcPercent = *settings.GCPercent
+		log.Info("set GOGC from %d to %d", old, gcPercent)
+	}
+	if settings.MemoryLimit != nil {
+		old := debug.SetMemoryLimit(*settings.MemoryLimit)
+		log.Info("set GOMEMLIMIT from %d to %d", old, *settings.MemoryLimit)
+	}
+	if settings.MaxProcs != nil {
+		old := runtime.GOMAXPROCS(*settings.MaxProcs)
+		log.Info("set GOMAXPROCS from %d to %d", old, *settings.MaxProcs)
+	}
+	return nil
+}
+
+func currentRuntime() *runtimeInfo {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	gcMu.Lock()
+	defer gcMu.Unlock()
+	return &runtimeInfo{
+		GCPercent:   gcPercent,
+		MemoryLimit: debug.SetMemoryLimit(-1), // negative input only reads the limit
+		Goroutines:  runtime.NumGoroutine(),
+		NumGC:       mem.NumGC,
+		HeapAlloc:   mem.HeapAlloc,
+		HeapSys:     mem.HeapSys,
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+
+		GCCPUFraction: mem.GCCPUFraction,
+		PauseTotalNs:  mem.PauseTotalNs,
+		NextGC:        mem.NextGC,
+		Cgroup:        Cgroup(),
+	}
+}
+
+// handleSnapshot writes a goroutine or heap profile to dir, so it can be
+// collected later even if the process is restarted
+func handleSnapshot(w http.ResponseWriter, r *http.Request, dir string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("type")
+	if name != "goroutine" && name != "heap" {
+		http.Error(w, fmt.Sprintf("snapshot type: %s, should be goroutine or heap", name), http.StatusBadRequest)
+		return
+	}
+	if name == "heap" {
+		runtime.GC()
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	file := filepath.Join(dir, fmt.Sprintf("%s-%d-%s.pprof", name, os.Getpid(), time.Now().Format("20060102150405")))
+	f, err := os.Create(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	if err := rpprof.Lookup(name).WriteTo(f, 0); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Info("write %s snapshot to %s", name, file)
+	writeJSON(w, map[string]string{"file": file})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeSettings(t *testing.T) {
+	oldGC := debug.SetGCPercent(100)
+	oldLimit := debug.SetMemoryLimit(-1)
+	defer func() {
+		debug.SetGCPercent(oldGC)
+		debug.SetMemoryLimit(oldLimit)
+	}()
+
+	h := NewHandler(t.TempDir())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/debug/runtime", strings.NewReader(`{"gogc":50,"memory_limit":1073741824}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("put runtime status %d: %s", w.Code, w.Body.String())
+	}
+	info := &runtimeInfo{}
+	if err := json.Unmarshal(w.Body.Bytes(), info); err != nil {
+		t.Fatal(err)
+	}
+	if info.GCPercent != 50 || info.MemoryLimit != 1<<30 {
+		t.Errorf("got gogc %d memory_limit %d, want 50 and %d", info.GCPercent, info.MemoryLimit, 1<<30)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/debug/runtime", strings.NewReader(`{"memory_limit":-1}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("negative memory_limit status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	h := NewHandler(dir)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/snapshot?type=goroutine", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("snapshot status %d: %s", w.Code, w.Body.String())
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("snapshot files %d, want 1", len(files))
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/snapshot?type=block", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown snapshot status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	h := BasicAuth(NewHandler(t.TempDir()), func(ctx context.Context, user, password string) error {
+		if user != "root" || password != "secret" {
+			return fmt.Errorf("user %s is not admin", user)
+		}
+		return nil
+	})
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		want     int
+	}{
+		{name: "No credentials", want: http.StatusUnauthorized},
+		{name: "Not admin", user: "reader", password: "secret", want: http.StatusUnauthorized},
+		{name: "Admin", user: "root", password: "secret", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
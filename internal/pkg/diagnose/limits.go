@@ -0,0 +1,161 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package diagnose
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+const (
+	CgroupRoot = "/sys/fs/cgroup"
+
+	DefaultMemoryLimitRatio = 0.9
+
+	// cgroup v1 reports no memory limit as a page aligned max int64
+	cgroupNoMemoryLimit = int64(1) << 62
+)
+
+// Limits are the runtime limits of a process, zero fields are derived from
+// the cgroup of the process, GOMAXPROCS and GOMEMLIMIT of the environment
+// win over derived values
+type Limits struct {
+	MaxProcs         int
+	MemoryLimit      int64
+	MemoryLimitRatio float64
+	GCPercent        *int
+}
+
+// CgroupLimits are the cpu quota in cpus and the memory limit in bytes of
+// a cgroup, 0 if there is none
+type CgroupLimits struct {
+	CPU    float64 `json:"cpu,omitempty"`
+	Memory int64   `json:"memory,omitempty"`
+}
+
+var (
+	cgroupMu sync.Mutex
+	cgroup   CgroupLimits
+)
+
+// ReadCgroupLimits reads the limits of the cgroup mounted at root, by the
+// files of cgroup v2 or else of cgroup v1
+func ReadCgroupLimits(root string) CgroupLimits {
+	limits := CgroupLimits{}
+	if b, err := os.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(b))
+		if len(fields) == 2 && fields[0] != "max" {
+			limits.CPU = cpuQuota(fields[0], fields[1])
+		}
+	} else {
+		quota, err1 := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+		period, err2 := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+		if err1 == nil && err2 == nil {
+			limits.CPU = cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(root, "memory.max"))
+	if err != nil {
+		b, err = os.ReadFile(filepath.Join(root, "memory", "memory.limit_in_bytes"))
+	}
+	if err == nil {
+		if m, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64); err == nil && m > 0 && m < cgroupNoMemoryLimit {
+			limits.Memory = m
+		}
+	}
+	return limits
+}
+
+func cpuQuota(quota, period string) float64 {
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	if err1 != nil || err2 != nil || q <= 0 || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// ApplyLimits sets GOMAXPROCS, GOMEMLIMIT and GOGC of the process by limits
+// and the cgroup mounted at root
+func ApplyLimits(limits *Limits, root string) error {
+	cg := ReadCgroupLimits(root)
+	cgroupMu.Lock()
+	cgroup = cg
+	cgroupMu.Unlock()
+
+	settings := &RuntimeSettings{GCPercent: limits.GCPercent}
+	if procs := maxProcs(limits.MaxProcs, cg.CPU, os.Getenv("GOMAXPROCS") != ""); procs > 0 {
+		settings.MaxProcs = &procs
+	}
+	if memory := memoryLimit(limits.MemoryLimit, limits.MemoryLimitRatio, cg.Memory, os.Getenv("GOMEMLIMIT") != ""); memory > 0 {
+		settings.MemoryLimit = &memory
+	}
+	log.Info("cgroup limits cpu %.2f memory %d", cg.CPU, cg.Memory)
+	return Apply(settings)
+}
+
+// maxProcs is 0 to keep GOMAXPROCS, a cpu quota is rounded up so a
+// fraction of a cpu still gets a proc
+func maxProcs(configured int, cpu float64, env bool) int {
+	if configured > 0 {
+		return configured
+	}
+	if env || cpu <= 0 {
+		return 0
+	}
+	return min(int(math.Ceil(cpu)), runtime.NumCPU())
+}
+
+// memoryLimit is 0 to keep GOMEMLIMIT, the ratio leaves room for the
+// memory of cgo the go runtime does not count
+func memoryLimit(configured int64, ratio float64, cgroupMemory int64, env bool) int64 {
+	if configured > 0 {
+		return configured
+	}
+	if env || cgroupMemory <= 0 {
+		return 0
+	}
+	if ratio <= 0 {
+		ratio = DefaultMemoryLimitRatio
+	}
+	return int64(float64(cgroupMemory) * ratio)
+}
+
+// Cgroup returns the cgroup limits read when the limits were applied
+func Cgroup() CgroupLimits {
+	cgroupMu.Lock()
+	defer cgroupMu.Unlock()
+	return cgroup
+}
+
+// GCPressure is the share of cpu spent by gc since the process started and
+// the heap over GOMEMLIMIT, the heap ratio is 0 without a limit
+func GCPressure() (cpuFraction, heapRatio float64) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	if limit := debug.SetMemoryLimit(-1); limit > 0 && limit != math.MaxInt64 {
+		heapRatio = float64(mem.HeapAlloc) / float64(limit)
+	}
+	return mem.GCCPUFraction, heapRatio
+}
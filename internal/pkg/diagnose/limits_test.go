@@ -0,0 +1,81 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package diagnose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCgroupLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  CgroupLimits
+	}{
+		{name: "v2", files: map[string]string{"cpu.max": "250000 100000\n", "memory.max": "4294967296\n"}, want: CgroupLimits{CPU: 2.5, Memory: 4 << 30}},
+		{name: "v2 unlimited", files: map[string]string{"cpu.max": "max 100000\n", "memory.max": "max\n"}},
+		{name: "v1", files: map[string]string{"cpu/cpu.cfs_quota_us": "50000\n", "cpu/cpu.cfs_period_us": "100000\n", "memory/memory.limit_in_bytes": "1073741824\n"}, want: CgroupLimits{CPU: 0.5, Memory: 1 << 30}},
+		{name: "v1 unlimited", files: map[string]string{"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n", "memory/memory.limit_in_bytes": "9223372036854771712\n"}},
+		{name: "no cgroup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := ReadCgroupLimits(root); got != tt.want {
+				t.Errorf("ReadCgroupLimits() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDerivedLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		procs      int
+		memory     int64
+		ratio      float64
+		cgroup     CgroupLimits
+		env        bool
+		wantProcs  int
+		wantMemory int64
+	}{
+		{name: "configured", procs: 3, memory: 1 << 30, cgroup: CgroupLimits{CPU: 1, Memory: 4 << 30}, wantProcs: 3, wantMemory: 1 << 30},
+		{name: "from cgroup", cgroup: CgroupLimits{CPU: 0.5, Memory: 10 << 30}, wantProcs: 1, wantMemory: 9 << 30},
+		{name: "ratio", ratio: 0.5, cgroup: CgroupLimits{Memory: 4 << 30}, wantMemory: 2 << 30},
+		{name: "environment wins over cgroup", env: true, cgroup: CgroupLimits{CPU: 1, Memory: 4 << 30}},
+		{name: "no cgroup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maxProcs(tt.procs, tt.cgroup.CPU, tt.env); got != tt.wantProcs {
+				t.Errorf("maxProcs() = %d, want %d", got, tt.wantProcs)
+			}
+			if got := memoryLimit(tt.memory, tt.ratio, tt.cgroup.Memory, tt.env); got != tt.wantMemory {
+				t.Errorf("memoryLimit() = %d, want %d", got, tt.wantMemory)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package distance
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// the builtin metrics, the first three match the metric types of the engine
+const (
+	InnerProduct = "InnerProduct"
+	L2           = "L2"
+	Cosine       = "Cosine"
+	// WeightedL2 is the squared L2 distance with a weight per dimension
+	WeightedL2 = "WeightedL2"
+	// Mahalanobis is the squared mahalanobis distance by the inverse of the
+	// covariance matrix of the vectors
+	Mahalanobis = "Mahalanobis"
+)
+
+func init() {
+	Register(InnerProduct, func(int, json.RawMessage) (Metric, error) { return innerProduct{}, nil })
+	Register(L2, func(int, json.RawMessage) (Metric, error) { return l2{}, nil })
+	Register(Cosine, func(int, json.RawMessage) (Metric, error) { return cosine{}, nil })
+	Register(WeightedL2, newWeightedL2)
+	Register(Mahalanobis, newMahalanobis)
+}
+
+type innerProduct struct{}
+
+func (innerProduct) Score(query, vector []float32) float64 {
+	score := 0.0
+	for i, x := range vector {
+		score += float64(x) * float64(query[i])
+	}
+	return score
+}
+
+func (innerProduct) Ascending() bool { return false }
+
+type l2 struct{}
+
+func (l2) Score(query, vector []float32) float64 {
+	score := 0.0
+	for i, x := range vector {
+		d := float64(x) - float64(query[i])
+		score += d * d
+	}
+	return score
+}
+
+func (l2) Ascending() bool { return true }
+
+type cosine struct{}
+
+func (cosine) Score(query, vector []float32) float64 {
+	var dot, nq, nv float64
+	for i, x := range vector {
+		dot += float64(x) * float64(query[i])
+		nq += float64(query[i]) * float64(query[i])
+		nv += float64(x) * float64(x)
+	}
+	if nq == 0 || nv == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(nq*nv)
+}
+
+func (cosine) Ascending() bool { return false }
+
+type weightedL2 struct {
+	Weights []float64 `json:"weights"`
+}
+
+func newWeightedL2(dimension int, params json.RawMessage) (Metric, error) {
+	m := &weightedL2{}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, m); err != nil {
+			return nil, fmt.Errorf("params of %s err: %v", WeightedL2, err)
+		}
+	}
+	if len(m.Weights) != dimension {
+		return nil, fmt.Errorf("%s should have %d weights, got %d", WeightedL2, dimension, len(m.Weights))
+	}
+	for _, w := range m.Weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("weights of %s should be finite and not negative", WeightedL2)
+		}
+	}
+	return m, nil
+}
+
+func (m *weightedL2) Score(query, vector []float32) float64 {
+	score := 0.0
+	for i, x := range vector {
+		d := float64(x) - float64(query[i])
+		score += m.Weights[i] * d * d
+	}
+	return score
+}
+
+func (m *weightedL2) Ascending() bool { return true }
+
+type mahalanobis struct {
+	// InverseCovariance is the dimension x dimension matrix by rows
+	InverseCovariance []float64 `json:"inverse_covariance"`
+	dimension         int
+}
+
+func newMahalanobis(dimension int, params json.RawMessage) (Metric, error) {
+	m := &mahalanobis{dimension: dimension}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, m); err != nil {
+			return nil, fmt.Errorf("params of %s err: %v", Mahalanobis, err)
+		}
+	}
+	if len(m.InverseCovariance) != dimension*dimension {
+		return nil, fmt.Errorf("inverse_covariance of %s should have %d x %d values, got %d", Mahalanobis, dimension, dimension, len(m.InverseCovariance))
+	}
+	for i := 0; i < dimension; i++ {
+		for j := 0; j < i; j++ {
+			if m.InverseCovariance[i*dimension+j] != m.InverseCovariance[j*dimension+i] {
+				return nil, fmt.Errorf("inverse_covariance of %s should be symmetric", Mahalanobis)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *mahalanobis) Score(query, vector []float32) float64 {
+	d := make([]float64, m.dimension)
+	for i, x := range vector {
+		d[i] = float64(x) - float64(query[i])
+	}
+	score := 0.0
+	for i := 0; i < m.dimension; i++ {
+		row := m.InverseCovariance[i*m.dimension : (i+1)*m.dimension]
+		s := 0.0
+		for j, v := range row {
+			s += v * d[j]
+		}
+		score += d[i] * s
+	}
+	return score
+}
+
+func (m *mahalanobis) Ascending() bool { return true }
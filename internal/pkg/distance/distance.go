@@ -0,0 +1,111 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package distance is the registry of the vector metrics computed in go by
+// the flat and rerank paths. Metrics are registered by name, from init of
+// this package for the builtin ones or of a plugin loaded at startup, and
+// declared by name in the metrics of a space.
+package distance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Metric scores a vector against a query vector of the same dimension
+type Metric interface {
+	Score(query, vector []float32) float64
+	// Ascending tells whether a smaller score is closer, like L2
+	Ascending() bool
+}
+
+// BatchMetric is a metric with a kernel scoring many vectors at once, cgo or
+// assembly kernels implement it to amortize the call overhead
+type BatchMetric interface {
+	Metric
+	// ScoreBatch scores the vectors laid out one after another into scores
+	ScoreBatch(query, vectors []float32, scores []float64)
+}
+
+// Factory builds a metric for vectors of dimension from the params declared
+// with it in the space
+type Factory func(dimension int, params json.RawMessage) (Metric, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a metric available by name, it panics if the name is
+// registered twice so a plugin can not silently replace a metric
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if factory == nil {
+		panic("distance: register nil factory of " + name)
+	}
+	if _, ok := factories[name]; ok {
+		panic("distance: register metric twice " + name)
+	}
+	factories[name] = factory
+}
+
+// Registered tells whether the metric name is registered
+func Registered(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := factories[name]
+	return ok
+}
+
+// Names returns the registered metrics sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the metric name for vectors of dimension
+func New(name string, dimension int, params json.RawMessage) (Metric, error) {
+	mu.RLock()
+	factory := factories[name]
+	mu.RUnlock()
+	if factory == nil {
+		return nil, fmt.Errorf("metric %s is not registered, registered: %v", name, Names())
+	}
+	if dimension <= 0 {
+		return nil, fmt.Errorf("dimension of metric %s should be positive", name)
+	}
+	return factory(dimension, params)
+}
+
+// ScoreBatch scores the vectors laid out one after another into scores, by
+// the kernel of m if it has one
+func ScoreBatch(m Metric, query, vectors []float32, scores []float64) {
+	if bm, ok := m.(BatchMetric); ok {
+		bm.ScoreBatch(query, vectors, scores)
+		return
+	}
+	d := len(query)
+	for i := range scores {
+		scores[i] = m.Score(query, vectors[i*d:(i+1)*d])
+	}
+}
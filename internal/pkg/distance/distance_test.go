@@ -0,0 +1,107 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package distance
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestBuiltinMetrics(t *testing.T) {
+	query := []float32{1, 2}
+	vector := []float32{3, 1}
+	tests := []struct {
+		name      string
+		metric    string
+		params    string
+		want      float64
+		ascending bool
+		wantErr   bool
+	}{
+		{name: "InnerProduct", metric: InnerProduct, want: 5},
+		{name: "L2 is squared", metric: L2, want: 5, ascending: true},
+		{name: "Cosine", metric: Cosine, want: 5 / math.Sqrt(50)},
+		{name: "WeightedL2", metric: WeightedL2, params: `{"weights":[2,0.5]}`, want: 8.5, ascending: true},
+		{name: "WeightedL2 of other dimension", metric: WeightedL2, params: `{"weights":[1]}`, wantErr: true},
+		{name: "Mahalanobis by identity is L2", metric: Mahalanobis, params: `{"inverse_covariance":[1,0,0,1]}`, want: 5, ascending: true},
+		{name: "Mahalanobis", metric: Mahalanobis, params: `{"inverse_covariance":[2,1,1,2]}`, want: 6, ascending: true},
+		{name: "Mahalanobis not symmetric", metric: Mahalanobis, params: `{"inverse_covariance":[1,1,0,1]}`, wantErr: true},
+		{name: "Unknown metric", metric: "Hamming", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.metric, len(query), json.RawMessage(tt.params))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := m.Score(query, vector); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+			if m.Ascending() != tt.ascending {
+				t.Errorf("Ascending() = %v, want %v", m.Ascending(), tt.ascending)
+			}
+		})
+	}
+}
+
+type batchL2 struct {
+	l2
+	batches int
+}
+
+func (m *batchL2) ScoreBatch(query, vectors []float32, scores []float64) {
+	m.batches++
+	for i := range scores {
+		scores[i] = m.Score(query, vectors[i*len(query):(i+1)*len(query)])
+	}
+}
+
+func TestScoreBatch(t *testing.T) {
+	query := []float32{0, 0}
+	vectors := []float32{1, 0, 0, 2, 3, 4}
+	want := []float64{1, 4, 25}
+	for _, m := range []Metric{l2{}, &batchL2{}} {
+		scores := make([]float64, 3)
+		ScoreBatch(m, query, vectors, scores)
+		for i := range want {
+			if scores[i] != want[i] {
+				t.Errorf("ScoreBatch(%T) = %v, want %v", m, scores, want)
+				break
+			}
+		}
+	}
+	m := &batchL2{}
+	ScoreBatch(m, query, vectors, make([]float64, 3))
+	if m.batches != 1 {
+		t.Errorf("ScoreBatch() called the kernel %d times, want 1", m.batches)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("TestMetric", func(int, json.RawMessage) (Metric, error) { return l2{}, nil })
+	if !Registered("TestMetric") {
+		t.Fatal("Registered() = false after Register()")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() twice should panic")
+		}
+	}()
+	Register("TestMetric", func(int, json.RawMessage) (Metric, error) { return l2{}, nil })
+}
@@ -0,0 +1,32 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package distance
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// LoadPlugins opens the go plugins of paths, each registers its metrics from
+// its init. A plugin must be built by the same go toolchain and version of
+// this module as the binary loading it
+func LoadPlugins(paths []string) error {
+	for _, path := range paths {
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("load metric plugin %s err: %v", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package fairqueue shares a fixed number of execution slots among tenants
+// by weighted fair queuing, a tenant with weight 2 gets twice the slots of a
+// tenant with weight 1 while both have requests waiting, and a tenant alone
+// can use all the slots
+package fairqueue
+
+import (
+	"context"
+	"sync"
+)
+
+// Queue is a weighted fair queue of requests waiting for slots
+type Queue struct {
+	mu      sync.Mutex
+	slots   int
+	running int
+	waiting int
+	weight  func(tenant string) int
+	// virtual time, the finish tag of the last request dispatched
+	vtime   float64
+	tenants map[string]*tenant
+}
+
+type tenant struct {
+	// finish tag of the last request of the tenant
+	finish  float64
+	waiters []*waiter
+}
+
+type waiter struct {
+	tag float64
+	// the virtual time the request adds to the finish tag of its tenant
+	cost  float64
+	ready chan struct{}
+	// set under the lock of queue when the waiter gets its slot
+	granted bool
+}
+
+// New returns a queue of slots, weight returns the weight of a tenant and
+// values below 1 are taken as 1
+func New(slots int, weight func(tenant string) int) *Queue {
+	if slots < 1 {
+		slots = 1
+	}
+	return &Queue{slots: slots, weight: weight, tenants: make(map[string]*tenant)}
+}
+
+// Acquire waits for a slot for a request of tenant, the returned func
+// releases the slot and does nothing if called again. It returns the error
+// of ctx if ctx is done first, the request then takes no share of the tenant
+func (q *Queue) Acquire(ctx context.Context, name string) (func(), error) {
+	q.mu.Lock()
+	t := q.tenants[name]
+	if t == nil {
+		t = &tenant{finish: q.vtime}
+		q.tenants[name] = t
+	}
+	start := t.finish
+	if start < q.vtime {
+		start = q.vtime
+	}
+	cost := 1 / float64(q.weightOf(name))
+	t.finish = start + cost
+	if q.running < q.slots && q.waiting == 0 {
+		q.running++
+		q.mu.Unlock()
+		return q.releaseOnce(), nil
+	}
+	w := &waiter{tag: t.finish, cost: cost, ready: make(chan struct{})}
+	t.waiters = append(t.waiters, w)
+	q.waiting++
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return q.releaseOnce(), nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.granted {
+			// the slot came with the cancel, give it to the next one
+			q.mu.Unlock()
+			q.release()
+		} else {
+			q.remove(name, w)
+			q.mu.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Stats returns the requests running and waiting
+func (q *Queue) Stats() (running, waiting int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.running, q.waiting
+}
+
+func (q *Queue) weightOf(name string) int {
+	if q.weight == nil {
+		return 1
+	}
+	if w := q.weight(name); w > 1 {
+		return w
+	}
+	return 1
+}
+
+// releaseOnce returns a func releasing one slot, calling it again does not
+// free the slot of another request
+func (q *Queue) releaseOnce() func() {
+	var once sync.Once
+	return func() { once.Do(q.release) }
+}
+
+// release gives the slot to the waiter with the smallest finish tag
+func (q *Queue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var next *tenant
+	for name, t := range q.tenants {
+		if len(t.waiters) == 0 {
+			// idle tenants keep no credit once the virtual time passes them
+			if t.finish <= q.vtime {
+				delete(q.tenants, name)
+			}
+			continue
+		}
+		if next == nil || t.waiters[0].tag < next.waiters[0].tag {
+			next = t
+		}
+	}
+	if next == nil {
+		q.running--
+		return
+	}
+	w := next.waiters[0]
+	next.waiters = next.waiters[1:]
+	q.waiting--
+	q.vtime = w.tag
+	w.granted = true
+	close(w.ready)
+}
+
+// remove takes a canceled waiter out of the queue and rolls back the finish
+// tags it pushed, so the tenant is not charged for a request never run
+func (q *Queue) remove(name string, w *waiter) {
+	t := q.tenants[name]
+	if t == nil {
+		return
+	}
+	for i, v := range t.waiters {
+		if v == w {
+			t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+			q.waiting--
+			for _, later := range t.waiters[i:] {
+				later.tag -= w.cost
+			}
+			t.finish -= w.cost
+			if t.finish < q.vtime {
+				t.finish = q.vtime
+			}
+			return
+		}
+	}
+}
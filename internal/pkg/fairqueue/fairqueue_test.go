@@ -0,0 +1,171 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fairqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type grant struct {
+	tenant  string
+	release func()
+}
+
+func waitQueued(t *testing.T, q *Queue, n int) {
+	for i := 0; i < 1000; i++ {
+		if _, waiting := q.Stats(); waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expect %d waiting requests", n)
+}
+
+func TestQueueWeightedShare(t *testing.T) {
+	q := New(1, func(tenant string) int {
+		if tenant == "b" {
+			return 3
+		}
+		return 1
+	})
+	hold, err := q.Acquire(context.Background(), "holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grants := make(chan grant)
+	// a bursts first, b arrives after and still gets its share
+	queued := 0
+	for _, tenant := range []string{"a", "a", "a", "a", "b", "b", "b", "b"} {
+		go func(tenant string) {
+			release, err := q.Acquire(context.Background(), tenant)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			grants <- grant{tenant, release}
+		}(tenant)
+		queued++
+		waitQueued(t, q, queued)
+	}
+
+	hold()
+	counts := map[string]int{}
+	for i := 0; i < 4; i++ {
+		g := <-grants
+		counts[g.tenant]++
+		g.release()
+	}
+	if counts["b"] != 3 || counts["a"] != 1 {
+		t.Errorf("expect b to get 3 of the first 4 slots, got %v", counts)
+	}
+	for i := 0; i < 4; i++ {
+		(<-grants).release()
+	}
+	if running, waiting := q.Stats(); running != 0 || waiting != 0 {
+		t.Errorf("expect an idle queue, running %d waiting %d", running, waiting)
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q := New(1, nil)
+	hold, err := q.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Acquire(ctx, "b"); err == nil {
+		t.Fatal("expect the error of the context")
+	}
+	if _, waiting := q.Stats(); waiting != 0 {
+		t.Errorf("expect the canceled request removed, waiting %d", waiting)
+	}
+	hold()
+	release, err := q.Acquire(context.Background(), "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestQueueCancelRollsBackTag(t *testing.T) {
+	q := New(1, nil)
+	hold, err := q.Acquire(context.Background(), "holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// canceled requests of a must not push a behind b
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		if _, err := q.Acquire(ctx, "a"); err == nil {
+			t.Fatal("expect the error of the context")
+		}
+		cancel()
+	}
+
+	grants := make(chan grant)
+	queued := 0
+	for _, tenant := range []string{"a", "b", "b"} {
+		go func(tenant string) {
+			release, err := q.Acquire(context.Background(), tenant)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			grants <- grant{tenant, release}
+		}(tenant)
+		queued++
+		waitQueued(t, q, queued)
+	}
+
+	hold()
+	order := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		g := <-grants
+		order = append(order, g.tenant)
+		g.release()
+	}
+	if order[2] == "a" {
+		t.Errorf("expect a in the first 2 slots, got %v", order)
+	}
+}
+
+func TestQueueReleaseTwice(t *testing.T) {
+	q := New(1, nil)
+	release, err := q.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	release()
+	if running, _ := q.Stats(); running != 0 {
+		t.Fatalf("expect no running request, got %d", running)
+	}
+
+	hold, err := q.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Acquire(ctx, "b"); err == nil {
+		t.Error("expect the slot still held after a stale release")
+	}
+	hold()
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package fault injects faults at named points for resilience tests. The
+// injection only works in binaries built with the chaos tag, otherwise Eval
+// is a no-op and faults can not be set.
+package fault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// injection points, the key passed to Eval is matched by Fault.Match
+const (
+	EtcdOp    = "etcd"       // before master store operations, key is the etcd key
+	PSRPC     = "ps_rpc"     // before rpc to ps, key is the service path
+	RaftApply = "raft_apply" // before raft applies a command, key is the partition id
+	Watcher   = "watcher"    // on watcher events of the client cache, key is the watched prefix
+)
+
+var points = map[string]bool{EtcdOp: true, PSRPC: true, RaftApply: true, Watcher: true}
+
+type Action string
+
+const (
+	ActionDelay Action = "delay"
+	ActionError Action = "error"
+	ActionPanic Action = "panic"
+)
+
+// ErrInjected is returned by Eval for the error action
+var ErrInjected = errors.New("fault injected")
+
+type Fault struct {
+	Point       string  `json:"point"`
+	Action      Action  `json:"action"`
+	Delay       int64   `json:"delay,omitempty"`       // milliseconds of delay action
+	Probability float64 `json:"probability,omitempty"` // in (0, 1], 0 means always
+	Count       int64   `json:"count,omitempty"`       // remaining triggers, 0 means unlimited
+	Match       string  `json:"match,omitempty"`       // substring of key, empty matches all
+}
+
+func (f *Fault) Validate() error {
+	if !points[f.Point] {
+		return fmt.Errorf("fault point: %s, should be %s, %s, %s or %s", f.Point, EtcdOp, PSRPC, RaftApply, Watcher)
+	}
+	switch f.Action {
+	case ActionDelay:
+		if f.Delay <= 0 {
+			return fmt.Errorf("fault delay should be positive")
+		}
+	case ActionError, ActionPanic:
+	default:
+		return fmt.Errorf("fault action: %s, should be %s, %s or %s", f.Action, ActionDelay, ActionError, ActionPanic)
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("fault probability should be in [0, 1]")
+	}
+	if f.Count < 0 {
+		return fmt.Errorf("fault count should not be negative")
+	}
+	return nil
+}
+
+// Handler lists faults by GET, sets a fault by PUT/POST and clears the
+// faults of point param, or all faults without it, by DELETE
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if !Enabled {
+		http.Error(w, "fault injection is disabled, build with the chaos tag", http.StatusNotImplemented)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		f := &Fault{}
+		if err := json.NewDecoder(r.Body).Decode(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := Set(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		Clear(r.URL.Query().Get("point"))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(List()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build chaos
+
+package fault
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+const Enabled = true
+
+var (
+	mu     sync.Mutex
+	faults = make(map[string][]*Fault)
+)
+
+// Set adds a fault, it replaces the fault of the same point and match
+func Set(f *Fault) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	list := faults[f.Point][:0:0]
+	for _, old := range faults[f.Point] {
+		if old.Match != f.Match {
+			list = append(list, old)
+		}
+	}
+	faults[f.Point] = append(list, f)
+	log.Warn("set fault point [%s] action [%s] match [%s]", f.Point, f.Action, f.Match)
+	return nil
+}
+
+// Clear removes the faults of point, or all faults if point is empty
+func Clear(point string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if point == "" {
+		faults = make(map[string][]*Fault)
+		return
+	}
+	delete(faults, point)
+}
+
+func List() []*Fault {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]*Fault, 0, len(faults))
+	for _, fs := range faults {
+		for _, f := range fs {
+			c := *f
+			list = append(list, &c)
+		}
+	}
+	return list
+}
+
+// take returns the fault triggered at point for key
+func take(point, key string) *Fault {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, f := range faults[point] {
+		if f.Match != "" && !strings.Contains(key, f.Match) {
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() >= f.Probability {
+			continue
+		}
+		if f.Count > 0 {
+			f.Count--
+			if f.Count == 0 {
+				faults[point] = append(faults[point][:i:i], faults[point][i+1:]...)
+			}
+		}
+		c := *f
+		return &c
+	}
+	return nil
+}
+
+// Eval triggers the fault of point for key, it sleeps for delay action,
+// returns ErrInjected for error action and panics for panic action
+func Eval(point, key string) error {
+	f := take(point, key)
+	if f == nil {
+		return nil
+	}
+	switch f.Action {
+	case ActionDelay:
+		time.Sleep(time.Duration(f.Delay) * time.Millisecond)
+	case ActionError:
+		return fmt.Errorf("%w at %s [%s]", ErrInjected, point, key)
+	case ActionPanic:
+		panic(fmt.Sprintf("fault injected at %s [%s]", point, key))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build chaos
+
+package fault
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	defer Clear("")
+
+	if err := Set(&Fault{Point: PSRPC, Action: ActionError, Match: "Search", Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Eval(PSRPC, "UpsertHandler"); err != nil {
+		t.Errorf("unmatched key should not fail, err: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := Eval(PSRPC, "SearchHandler"); !errors.Is(err, ErrInjected) {
+			t.Errorf("eval %d err %v, want ErrInjected", i, err)
+		}
+	}
+	if err := Eval(PSRPC, "SearchHandler"); err != nil {
+		t.Errorf("fault should be removed after count, err: %v", err)
+	}
+
+	if err := Set(&Fault{Point: EtcdOp, Action: ActionDelay, Delay: 20}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := Eval(EtcdOp, "/space/1"); err != nil || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("delay fault not applied, err: %v, cost: %v", err, time.Since(start))
+	}
+
+	if err := Set(&Fault{Point: Watcher, Action: ActionPanic}); err != nil {
+		t.Fatal(err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panic fault should panic")
+			}
+		}()
+		_ = Eval(Watcher, "/space/")
+	}()
+
+	if err := Set(&Fault{Point: "disk", Action: ActionError}); err == nil {
+		t.Errorf("unknown point should fail")
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build !chaos
+
+package fault
+
+import "fmt"
+
+const Enabled = false
+
+func Set(f *Fault) error {
+	return fmt.Errorf("fault injection is disabled, build with the chaos tag")
+}
+
+func Clear(point string) {}
+
+func List() []*Fault { return nil }
+
+// Eval does nothing without the chaos tag
+func Eval(point, key string) error { return nil }
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -40,3 +41,51 @@ func TestWrite(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	data := []byte("hello world")
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "dst")
+	// an existing dst is truncated
+	if err := os.WriteFile(dst, []byte("a much longer old content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := CopyFile(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("copied %d bytes, want %d", n, len(data))
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, data) {
+		t.Errorf("dst = %q, want %q", got, data)
+	}
+
+	if _, err := CopyFile(filepath.Join(dir, "missing"), dst); err == nil {
+		t.Error("expected an error of a missing src")
+	}
+	if _, err := CopyFile(src, filepath.Join(dir, "missing", "dst")); err == nil {
+		t.Error("expected an error of a dst in a missing dir")
+	}
+}
+
+func TestFileSha256(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	size, sum, err := FileSha256(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"; size != 11 || sum != want {
+		t.Errorf("FileSha256() = %d, %s, want 11, %s", size, sum, want)
+	}
+	if _, _, err := FileSha256(file + ".missing"); err == nil {
+		t.Error("expected an error of a missing file")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package hlc is a hybrid logical clock for metadata versions. A timestamp
+// is unix nano with the low bits holding a logical counter, so it compares
+// with the wall clock timestamps written before, and a clock which observed a
+// timestamp never issues a smaller one even if its wall clock is behind.
+package hlc
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	logicalBits = 16
+	logicalMask = 1<<logicalBits - 1
+)
+
+// Clock issues increasing timestamps
+type Clock struct {
+	mu   sync.Mutex
+	last int64
+	wall func() int64
+}
+
+func NewClock() *Clock {
+	return &Clock{wall: func() int64 { return time.Now().UnixNano() }}
+}
+
+// Now returns a timestamp greater than all issued or observed by the clock
+func (c *Clock) Now() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if physical := c.wall() &^ logicalMask; physical > c.last {
+		c.last = physical
+	} else {
+		c.last++
+	}
+	return c.last
+}
+
+// Update observes a timestamp issued by another node
+func (c *Clock) Update(ts int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ts > c.last {
+		c.last = ts
+	}
+}
+
+// Physical returns the wall time of the timestamp
+func Physical(ts int64) time.Time {
+	return time.Unix(0, ts&^logicalMask)
+}
+
+var defaultClock = NewClock()
+
+// Now returns a timestamp of the clock of this process
+func Now() int64 {
+	return defaultClock.Now()
+}
+
+// Update lets the clock of this process observe ts
+func Update(ts int64) {
+	defaultClock.Update(ts)
+}
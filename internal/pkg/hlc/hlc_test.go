@@ -0,0 +1,47 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hlc
+
+import "testing"
+
+func TestClock(t *testing.T) {
+	wall := int64(1 << 20)
+	c := &Clock{wall: func() int64 { return wall }}
+
+	first := c.Now()
+	if first != wall {
+		t.Fatalf("first timestamp %d should be the wall time %d", first, wall)
+	}
+	if second := c.Now(); second != first+1 {
+		t.Fatalf("timestamp %d in the same tick should advance the logical counter", second)
+	}
+
+	// a timestamp of a node with a clock ahead
+	remote := wall + 10<<logicalBits
+	c.Update(remote)
+	if ts := c.Now(); ts <= remote {
+		t.Fatalf("timestamp %d should be greater than the observed %d", ts, remote)
+	}
+
+	// the wall clock goes backwards
+	wall -= 1 << 18
+	last := c.last
+	if ts := c.Now(); ts != last+1 {
+		t.Fatalf("timestamp %d should not go backwards with the wall clock", ts)
+	}
+	if Physical(remote).UnixNano() != remote {
+		t.Fatalf("physical of %d is %d", remote, Physical(remote).UnixNano())
+	}
+}
@@ -39,6 +39,13 @@ type Log interface {
 	Flush()
 }
 
+// LevelController is implemented by the logs whose levels can be changed at
+// runtime, the empty module is the default level
+type LevelController interface {
+	Levels() map[string]string
+	SetLevel(module, level string) error
+}
+
 var logs [math.MaxUint8]Log
 
 func RemoveLogI(i uint8) {
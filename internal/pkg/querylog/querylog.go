@@ -0,0 +1,167 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package querylog captures search requests as json lines so they can be
+// replayed against another cluster. Records keep the request body only, the
+// user, auth header, request id and client address are never written.
+package querylog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultMaxSizeMB = 512
+	bufferedRecords  = 4096
+	maxRecordSize    = 64 << 20
+)
+
+// Record is one captured request
+type Record struct {
+	Time int64           `json:"time"` // unix nano of the arrival
+	Path string          `json:"path"`
+	Cost float64         `json:"cost_ms"`
+	Body json.RawMessage `json:"body"`
+}
+
+// Writer appends records to a file in the background, the file is rotated to
+// path.1 when it exceeds the max size
+type Writer struct {
+	path    string
+	maxSize int64
+
+	records chan *Record
+	dropped uint64
+	wg      sync.WaitGroup
+
+	file *os.File
+	buf  *bufio.Writer
+	size int64
+}
+
+func NewWriter(path string, maxSizeMB int) (*Writer, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMaxSizeMB
+	}
+	w := &Writer{path: path, maxSize: int64(maxSizeMB) << 20, records: make(chan *Record, bufferedRecords)}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open query log %s err: %v", w.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.buf, w.size = f, bufio.NewWriter(f), info.Size()
+	return nil
+}
+
+func (w *Writer) rotate() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Write queues r without blocking, it is dropped if the writer falls behind
+func (w *Writer) Write(r *Record) bool {
+	select {
+	case w.records <- r:
+		return true
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+		return false
+	}
+}
+
+// Dropped returns the number of records dropped because the queue was full
+func (w *Writer) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+func (w *Writer) run() {
+	defer w.wg.Done()
+	for r := range w.records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			continue
+		}
+		if w.size+int64(len(b))+1 > w.maxSize && w.size > 0 {
+			if err := w.rotate(); err != nil {
+				atomic.AddUint64(&w.dropped, 1)
+				continue
+			}
+		}
+		n, _ := w.buf.Write(append(b, '\n'))
+		w.size += int64(n)
+		// flush when idle so the file is readable while capturing
+		if len(w.records) == 0 {
+			w.buf.Flush()
+		}
+	}
+}
+
+// Close writes the queued records and closes the file
+func (w *Writer) Close() error {
+	close(w.records)
+	w.wg.Wait()
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Read calls fn with every record of r in order
+func Read(r io.Reader, fn func(*Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return fmt.Errorf("query log line %d: %v", line, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package querylog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, path string) []*Record {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []*Record
+	if err := Read(f, func(r *Record) error {
+		records = append(records, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log")
+	w, err := NewWriter(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		w.Write(&Record{Time: int64(i), Path: "/document/search", Cost: 1.5, Body: json.RawMessage(`{"limit":10}`)})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readAll(t, path)
+	if len(records) != 10 {
+		t.Fatalf("expect 10 records, got %d", len(records))
+	}
+	for i, r := range records {
+		if r.Time != int64(i) || string(r.Body) != `{"limit":10}` {
+			t.Fatalf("record %d mismatch: %+v", i, r)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log")
+	w, err := NewWriter(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 15 records of 100KB exceed the 1MB limit once
+	body := json.RawMessage(`"` + strings.Repeat("a", 100<<10) + `"`)
+	for i := 0; i < 15; i++ {
+		w.Write(&Record{Time: int64(i), Body: body})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	old, cur := readAll(t, path+".1"), readAll(t, path)
+	if len(old)+len(cur) != 15 || len(cur) == 0 || len(old) == 0 {
+		t.Fatalf("expect 15 records in both files, got %d and %d", len(old), len(cur))
+	}
+	if cur[len(cur)-1].Time != 14 {
+		t.Fatalf("expect latest record in current file, got %d", cur[len(cur)-1].Time)
+	}
+}
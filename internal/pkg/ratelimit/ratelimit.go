@@ -0,0 +1,66 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ratelimit keeps transfers under a number of bytes per second, so
+// copies between nodes do not saturate their network
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is shared by the transfers it limits together, a nil limiter does
+// not limit
+type Limiter struct {
+	mu   sync.Mutex
+	rate int64     // bytes per second
+	paid time.Time // when the bytes taken so far fit in the rate
+}
+
+// New returns a limiter of rate bytes per second, nil if rate is not positive
+func New(rate int64) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &Limiter{rate: rate}
+}
+
+// Wait takes n bytes and blocks until the bytes taken before fit in the
+// rate, or ctx is done
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.paid.Before(now) {
+		l.paid = now
+	}
+	delay := l.paid.Sub(now)
+	l.paid = l.paid.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    int64
+		chunks  int
+		size    int
+		minTime time.Duration
+		maxTime time.Duration
+	}{
+		{name: "unlimited", rate: 0, chunks: 10, size: 1 << 20, maxTime: 50 * time.Millisecond},
+		{name: "first chunk is free", rate: 1 << 20, chunks: 1, size: 1 << 20, maxTime: 50 * time.Millisecond},
+		{name: "limited", rate: 10 << 20, chunks: 4, size: 1 << 20, minTime: 250 * time.Millisecond, maxTime: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.rate)
+			start := time.Now()
+			for i := 0; i < tt.chunks; i++ {
+				if err := l.Wait(context.Background(), tt.size); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if d := time.Since(start); d < tt.minTime || d > tt.maxTime {
+				t.Errorf("%d chunks took %v, want in [%v, %v]", tt.chunks, d, tt.minTime, tt.maxTime)
+			}
+		})
+	}
+}
+
+func TestLimiterWaitCanceled(t *testing.T) {
+	l := New(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, 1); err != nil {
+		t.Fatalf("first Wait() error = %v, want nil", err)
+	}
+	if err := l.Wait(ctx, 1); err != context.Canceled {
+		t.Fatalf("Wait() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package redact keeps credentials and document payloads out of logs and
+// error messages. Credentials are always masked, payloads are only kept
+// when the log_payload debug flag is enabled.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+const mask = "******"
+
+var logPayload atomic.Bool
+
+// SetLogPayload enables payloads in logs, it is for debug only
+func SetLogPayload(enable bool) {
+	logPayload.Store(enable)
+}
+
+func LogPayload() bool {
+	return logPayload.Load()
+}
+
+var (
+	// json fields of credentials, such as "password":"xx" and "old_password": "xx"
+	secretFields = regexp.MustCompile(`(?i)("[a-z_]*(password|secret|token|signkey|authorization)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// go struct prints, such as Password:0xc000 or Secret:xx
+	secretStructFields = regexp.MustCompile(`(?i)\b((?:old)?password|secret|token|signkey):\s*[^\s}]+`)
+	// credentials in headers and urls
+	basicAuth   = regexp.MustCompile(`(?i)\b(basic|bearer)\s+[a-z0-9+/=._-]+`)
+	urlUserInfo = regexp.MustCompile(`(://[^/:@\s]+):[^/@\s]+@`)
+)
+
+// Secrets masks the credentials in s
+func Secrets(s string) string {
+	s = secretFields.ReplaceAllString(s, `${1}"`+mask+`"`)
+	s = secretStructFields.ReplaceAllString(s, `${1}:`+mask)
+	s = basicAuth.ReplaceAllString(s, `${1} `+mask)
+	return urlUserInfo.ReplaceAllString(s, `${1}:`+mask+`@`)
+}
+
+// Payload returns a request body, etcd value or document for logging, only
+// the size is kept unless log payload is enabled
+func Payload(b []byte) string {
+	if !LogPayload() {
+		return fmt.Sprintf("<redacted %d bytes>", len(b))
+	}
+	return Secrets(string(b))
+}
+
+// Value is Payload for values printed by %v, such as documents and vectors
+func Value(v interface{}) string {
+	if !LogPayload() {
+		return fmt.Sprintf("<redacted %T>", v)
+	}
+	return Secrets(fmt.Sprintf("%v", v))
+}
@@ -0,0 +1,442 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package scoreexpr is a small arithmetic expression language for custom
+// score functions. Expressions only see numbers: variables, literals and the
+// builtin functions, booleans are 1 and 0. There are no loops, assignments
+// or calls out of the package, so the cost of an evaluation is bounded by
+// the number of nodes of the program.
+//
+//	_score * log1p(popularity) + (in_stock ? 0.1 : 0)
+package scoreexpr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	MaxLength = 4096
+	MaxNodes  = 512
+	MaxDepth  = 64
+)
+
+type evalFunc func(vars []float64) float64
+
+// Program is a compiled expression, it is safe for concurrent use
+type Program struct {
+	eval  evalFunc
+	vars  []string
+	nodes int
+}
+
+// Vars returns the variables referenced by the program, Eval takes their
+// values in this order
+func (p *Program) Vars() []string {
+	return p.vars
+}
+
+// Cost is the number of nodes evaluated by Eval
+func (p *Program) Cost() int {
+	return p.nodes
+}
+
+// Eval runs the program with the values of Vars
+func (p *Program) Eval(values []float64) float64 {
+	return p.eval(values)
+}
+
+type builtin struct {
+	minArgs, maxArgs int
+	fn               func(args []float64) float64
+}
+
+var builtins = map[string]*builtin{
+	"abs":   {1, 1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, 1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"log":   {1, 1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log1p": {1, 1, func(a []float64) float64 { return math.Log1p(a[0]) }},
+	"exp":   {1, 1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"floor": {1, 1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, 1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"pow":   {2, 2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min": {1, 8, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {1, 8, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+}
+
+// Compile parses src, isVar reports whether an identifier is a known variable
+func Compile(src string, isVar func(name string) bool) (*Program, error) {
+	if len(src) > MaxLength {
+		return nil, fmt.Errorf("expression length %d exceeds %d", len(src), MaxLength)
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, isVar: isVar, slots: make(map[string]int)}
+	eval, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Program{eval: eval, vars: p.vars, nodes: p.nodes}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	num  float64
+}
+
+var operators = []string{"<=", ">=", "==", "!=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", ","}
+
+func tokenize(src string) ([]token, error) {
+	tokens := make([]token, 0, len(src)/2)
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (isDigit(src[j]) || src[j] == '.') {
+				j++
+			}
+			// exponent
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				j++
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				for j < len(src) && isDigit(src[j]) {
+					j++
+				}
+			}
+			num, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q at %d", src[i:j], i)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[i:j], pos: i, num: num})
+			i = j
+		case isLetter(c):
+			j := i
+			for j < len(src) && (isLetter(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			matched := ""
+			for _, op := range operators {
+				if len(src)-i >= len(op) && src[i:i+len(op)] == op {
+					matched = op
+					break
+				}
+			}
+			if matched == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: matched, pos: i})
+			i += len(matched)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, text: "end", pos: len(src)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+	nodes  int
+	isVar  func(name string) bool
+	vars   []string
+	slots  map[string]int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.next(); t.kind != tokenOp || t.text != op {
+		return fmt.Errorf("expect %q but got %q at %d", op, t.text, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) node() error {
+	p.nodes++
+	if p.nodes > MaxNodes {
+		return fmt.Errorf("expression exceeds %d nodes", MaxNodes)
+	}
+	return nil
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *parser) parseTernary() (evalFunc, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxDepth {
+		return nil, fmt.Errorf("expression exceeds depth %d", MaxDepth)
+	}
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("?") {
+		return cond, nil
+	}
+	p.next()
+	then, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.node(); err != nil {
+		return nil, err
+	}
+	return func(v []float64) float64 {
+		if cond(v) != 0 {
+			return then(v)
+		}
+		return otherwise(v)
+	}, nil
+}
+
+// binary operators from the lowest precedence
+var precedences = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (evalFunc, error) {
+	if level == len(precedences) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(precedences[level]...) {
+		op := p.next().text
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.node(); err != nil {
+			return nil, err
+		}
+		left = binary(op, left, right)
+	}
+	return left, nil
+}
+
+func binary(op string, l, r evalFunc) evalFunc {
+	switch op {
+	case "||":
+		return func(v []float64) float64 { return boolean(l(v) != 0 || r(v) != 0) }
+	case "&&":
+		return func(v []float64) float64 { return boolean(l(v) != 0 && r(v) != 0) }
+	case "==":
+		return func(v []float64) float64 { return boolean(l(v) == r(v)) }
+	case "!=":
+		return func(v []float64) float64 { return boolean(l(v) != r(v)) }
+	case "<":
+		return func(v []float64) float64 { return boolean(l(v) < r(v)) }
+	case "<=":
+		return func(v []float64) float64 { return boolean(l(v) <= r(v)) }
+	case ">":
+		return func(v []float64) float64 { return boolean(l(v) > r(v)) }
+	case ">=":
+		return func(v []float64) float64 { return boolean(l(v) >= r(v)) }
+	case "+":
+		return func(v []float64) float64 { return l(v) + r(v) }
+	case "-":
+		return func(v []float64) float64 { return l(v) - r(v) }
+	case "*":
+		return func(v []float64) float64 { return l(v) * r(v) }
+	case "/":
+		return func(v []float64) float64 { return l(v) / r(v) }
+	default:
+		return func(v []float64) float64 { return math.Mod(l(v), r(v)) }
+	}
+}
+
+func (p *parser) parseUnary() (evalFunc, error) {
+	if !p.isOp("-", "!") {
+		return p.parsePrimary()
+	}
+	op := p.next().text
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxDepth {
+		return nil, fmt.Errorf("expression exceeds depth %d", MaxDepth)
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.node(); err != nil {
+		return nil, err
+	}
+	if op == "-" {
+		return func(v []float64) float64 { return -operand(v) }, nil
+	}
+	return func(v []float64) float64 { return boolean(operand(v) == 0) }, nil
+}
+
+func (p *parser) parsePrimary() (evalFunc, error) {
+	t := p.next()
+	if err := p.node(); err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case tokenNumber:
+		num := t.num
+		return func([]float64) float64 { return num }, nil
+	case tokenIdent:
+		if p.isOp("(") {
+			return p.parseCall(t)
+		}
+		switch t.text {
+		case "true":
+			return func([]float64) float64 { return 1 }, nil
+		case "false":
+			return func([]float64) float64 { return 0 }, nil
+		}
+		if p.isVar == nil || !p.isVar(t.text) {
+			return nil, fmt.Errorf("unknown variable %q at %d", t.text, t.pos)
+		}
+		slot, ok := p.slots[t.text]
+		if !ok {
+			slot = len(p.vars)
+			p.slots[t.text] = slot
+			p.vars = append(p.vars, t.text)
+		}
+		return func(v []float64) float64 { return v[slot] }, nil
+	case tokenOp:
+		if t.text == "(" {
+			inner, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) parseCall(name token) (evalFunc, error) {
+	b := builtins[name.text]
+	if b == nil {
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+	p.next()
+	var args []evalFunc
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+	if len(args) < b.minArgs || len(args) > b.maxArgs {
+		return nil, fmt.Errorf("function %q takes %d to %d arguments but got %d", name.text, b.minArgs, b.maxArgs, len(args))
+	}
+	return func(v []float64) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(v)
+		}
+		return b.fn(values)
+	}, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package scoreexpr
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	values := map[string]float64{"_score": 0.5, "popularity": 3, "in_stock": 1}
+	isVar := func(name string) bool { _, ok := values[name]; return ok }
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"_score", 0.5},
+		{"1 + 2 * 3 - 4 / 2", 5},
+		{"(1 + 2) * 3", 9},
+		{"-popularity % 2", -1},
+		{"_score * log1p(popularity)", 0.5 * math.Log1p(3)},
+		{"in_stock ? _score + 0.1 : _score", 0.6},
+		{"!in_stock || popularity >= 3 && popularity != 4", 1},
+		{"max(_score, popularity, 1e1) + min(2, pow(2, 3))", 12},
+		{"popularity > 5 ? 1 : popularity > 2 ? 2 : 3", 2},
+		{"true && !false", 1},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.src, isVar)
+		if err != nil {
+			t.Fatalf("compile %q: %v", tt.src, err)
+		}
+		args := make([]float64, len(p.Vars()))
+		for i, name := range p.Vars() {
+			args[i] = values[name]
+		}
+		if got := p.Eval(args); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	isVar := func(name string) bool { return name == "_score" }
+	for _, src := range []string{
+		"",
+		"_score +",
+		"unknown * 2",
+		"system(1)",
+		"pow(1)",
+		"(_score",
+		"_score ? 1",
+		"_score $ 1",
+		strings.Repeat("(", MaxDepth+1) + "1" + strings.Repeat(")", MaxDepth+1),
+		strings.Repeat("1+", MaxNodes) + "1",
+		strings.Repeat(" ", MaxLength+1),
+	} {
+		if _, err := Compile(src, isVar); err == nil {
+			t.Errorf("%q should not compile", src)
+		}
+	}
+}
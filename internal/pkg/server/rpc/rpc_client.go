@@ -120,6 +120,9 @@ func (r *RpcClient) Execute(ctx context.Context, servicePath string, args interf
 			}
 			md[string(entity.RPC_TIME_OUT)] = strconv.FormatInt(int64(timeout), 10)
 		}
+		if tenant, ok := ctx.Value(entity.TENANT).(string); ok && tenant != "" {
+			md[string(entity.TENANT)] = tenant
+		}
 		if span := opentracing.SpanFromContext(ctx); span != nil {
 			span.Tracer().Inject(span.Context(), opentracing.TextMap, opentracing.TextMapCarrier(md))
 		}
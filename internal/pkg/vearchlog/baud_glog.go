@@ -83,6 +83,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 )
 
 // severity identifies the sort of log: info, warning etc. It also implements
@@ -663,11 +665,15 @@ func (l *loggingT) printDepth(s severity, depth int, format string, args ...inte
 	}
 
 	//buf.Write(log_type[s])
-	if len(args) == 0 {
-		buf.WriteString(format)
-	} else {
-		_, _ = buf.WriteString(fmt.Sprintf(format, args...))
+	msg := format
+	if len(args) != 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	// error paths often carry raw values, mask the credentials in them
+	if s >= warningLog {
+		msg = redact.Secrets(msg)
 	}
+	_, _ = buf.WriteString(msg)
 	if buf.Bytes()[buf.Len()-1] != '\n' {
 		_ = buf.WriteByte('\n')
 	}
@@ -0,0 +1,443 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package vearchlog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	SinkFile   = "file"
+	SinkStdout = "stdout"
+	SinkSyslog = "syslog"
+)
+
+// slog has no trace, panic and fatal levels, they are placed between the
+// levels of slog so the order is the same as severity
+const (
+	levelTrace = slog.Level(-2)
+	levelPanic = slog.Level(10)
+	levelFatal = slog.Level(12)
+)
+
+var structuredLevels = map[string]slog.Level{
+	"DEBUG": slog.LevelDebug,
+	"TRACE": levelTrace,
+	"INFO":  slog.LevelInfo,
+	"WARN":  slog.LevelWarn,
+	"ERROR": slog.LevelError,
+	"PANIC": levelPanic,
+	"FATAL": levelFatal,
+}
+
+func parseLevel(level string) (slog.Level, error) {
+	l, ok := structuredLevels[strings.ToUpper(level)]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %s, should be DEBUG, TRACE, INFO, WARN, ERROR, PANIC or FATAL", level)
+	}
+	return l, nil
+}
+
+func levelName(l slog.Level) string {
+	for name, v := range structuredLevels {
+		if v == l {
+			return name
+		}
+	}
+	return l.String()
+}
+
+// StructuredConfig configs the structured log, ModuleLevels is keyed by the
+// package path under internal, such as "client" or "ps/engine", a module
+// matches the longest configured prefix of its path
+type StructuredConfig struct {
+	Dir              string
+	Module           string
+	Level            string
+	Format           string
+	Sink             string
+	ModuleLevels     map[string]string
+	SampleInitial    int // debug and trace lines logged per call site in a second
+	SampleThereafter int // then log every nth line in the second, 0 drops them
+}
+
+type structuredLog struct {
+	handler slog.Handler
+	writer  io.Writer
+	closer  io.Closer
+	sampler *sampler
+
+	mu           sync.RWMutex
+	level        slog.Level
+	moduleLevels map[string]slog.Level
+	minLevel     slog.Level
+}
+
+func NewStructuredLog(cfg *StructuredConfig) (*structuredLog, error) {
+	l := &structuredLog{moduleLevels: make(map[string]slog.Level)}
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	l.level = level
+	for module, name := range cfg.ModuleLevels {
+		if l.moduleLevels[module], err = parseLevel(name); err != nil {
+			return nil, err
+		}
+	}
+	l.resetMinLevel()
+	if cfg.SampleInitial > 0 {
+		l.sampler = newSampler(cfg.SampleInitial, cfg.SampleThereafter)
+	}
+
+	switch cfg.Sink {
+	case "", SinkFile:
+		w, err := newRotateWriter(cfg.Dir, cfg.Module)
+		if err != nil {
+			return nil, err
+		}
+		l.writer, l.closer = w, w
+	case SinkStdout:
+		l.writer = os.Stdout
+	case SinkSyslog:
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "vearch-"+strings.ToLower(cfg.Module))
+		if err != nil {
+			return nil, err
+		}
+		l.writer, l.closer = w, w
+	default:
+		return nil, fmt.Errorf("unknown log sink %s, should be %s, %s or %s", cfg.Sink, SinkFile, SinkStdout, SinkSyslog)
+	}
+
+	opts := &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.Level(-8), // levels are checked before building the record
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && len(groups) == 0 {
+				if lv, ok := a.Value.Any().(slog.Level); ok {
+					a.Value = slog.StringValue(levelName(lv))
+				}
+			}
+			return a
+		},
+	}
+	switch cfg.Format {
+	case "", FormatJSON:
+		l.handler = slog.NewJSONHandler(l.writer, opts)
+	case FormatText:
+		l.handler = slog.NewTextHandler(l.writer, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %s, should be %s or %s", cfg.Format, FormatJSON, FormatText)
+	}
+	l.handler = l.handler.WithAttrs([]slog.Attr{slog.String("component", cfg.Module)})
+	return l, nil
+}
+
+// resetMinLevel should be called with mu held
+func (l *structuredLog) resetMinLevel() {
+	l.minLevel = l.level
+	for _, v := range l.moduleLevels {
+		if v < l.minLevel {
+			l.minLevel = v
+		}
+	}
+}
+
+// moduleOf returns the package path under internal of the source file
+func moduleOf(file string) string {
+	dir := path.Dir(file)
+	if i := strings.LastIndex(dir, "/internal/"); i >= 0 {
+		return dir[i+len("/internal/"):]
+	}
+	return path.Base(dir)
+}
+
+func (l *structuredLog) enabledFor(module string, level slog.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level < l.minLevel {
+		return false
+	}
+	threshold, matched := l.level, -1
+	for prefix, v := range l.moduleLevels {
+		if (module == prefix || strings.HasPrefix(module, prefix+"/")) && len(prefix) > matched {
+			threshold, matched = v, len(prefix)
+		}
+	}
+	return level >= threshold
+}
+
+// Levels returns the default level by key "" and the module levels
+func (l *structuredLog) Levels() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	levels := map[string]string{"": levelName(l.level)}
+	for module, v := range l.moduleLevels {
+		levels[module] = levelName(v)
+	}
+	return levels
+}
+
+// SetLevel changes the default level when module is empty, an empty level
+// removes the module level so it follows the default level again
+func (l *structuredLog) SetLevel(module, level string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if module != "" && level == "" {
+		delete(l.moduleLevels, module)
+		l.resetMinLevel()
+		return nil
+	}
+	v, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	if module == "" {
+		l.level = v
+	} else {
+		l.moduleLevels[module] = v
+	}
+	l.resetMinLevel()
+	return nil
+}
+
+// log skips depth more frames than runtime.Callers, log, the method of
+// structuredLog and the function of log package
+func (l *structuredLog) log(depth int, level slog.Level, format string, args ...interface{}) {
+	if !l.enabledMin(level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(4+depth, pcs[:])
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	module := moduleOf(frame.File)
+	if !l.enabledFor(module, level) {
+		return
+	}
+	if level < slog.LevelInfo && l.sampler != nil && !l.sampler.allow(pcs[0]) {
+		return
+	}
+
+	msg := format
+	if len(args) > 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	// error paths often carry raw values, mask the credentials in them
+	if level >= slog.LevelWarn {
+		msg = redact.Secrets(msg)
+	}
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.AddAttrs(slog.String("module", module))
+	_ = l.handler.Handle(context.Background(), r)
+}
+
+func (l *structuredLog) enabledMin(level slog.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return level >= l.minLevel
+}
+
+// logArgs keeps the behavior of vearchLog, more than one arg means the first is format
+func (l *structuredLog) logArgs(level slog.Level, v ...interface{}) {
+	if len(v) <= 1 {
+		l.log(1, level, fmt.Sprint(v...))
+		return
+	}
+	format, ok := v[0].(string)
+	if !ok {
+		l.log(1, level, fmt.Sprint(v...))
+		return
+	}
+	l.log(1, level, format, v[1:]...)
+}
+
+func (l *structuredLog) IsDebugEnabled() bool { return l.enabledMin(slog.LevelDebug) }
+func (l *structuredLog) IsTraceEnabled() bool { return l.enabledMin(levelTrace) }
+func (l *structuredLog) IsInfoEnabled() bool  { return l.enabledMin(slog.LevelInfo) }
+func (l *structuredLog) IsWarnEnabled() bool  { return l.enabledMin(slog.LevelWarn) }
+
+func (l *structuredLog) Debug(v ...interface{}) { l.logArgs(slog.LevelDebug, v...) }
+func (l *structuredLog) Trace(v ...interface{}) { l.logArgs(levelTrace, v...) }
+func (l *structuredLog) Info(v ...interface{})  { l.logArgs(slog.LevelInfo, v...) }
+func (l *structuredLog) Warn(v ...interface{})  { l.logArgs(slog.LevelWarn, v...) }
+func (l *structuredLog) Error(v ...interface{}) { l.logArgs(slog.LevelError, v...) }
+
+func (l *structuredLog) Debugf(format string, v ...interface{}) {
+	l.log(0, slog.LevelDebug, format, v...)
+}
+func (l *structuredLog) Tracef(format string, v ...interface{}) { l.log(0, levelTrace, format, v...) }
+func (l *structuredLog) Infof(format string, v ...interface{}) {
+	l.log(0, slog.LevelInfo, format, v...)
+}
+func (l *structuredLog) Warnf(format string, v ...interface{}) {
+	l.log(0, slog.LevelWarn, format, v...)
+}
+func (l *structuredLog) Errorf(format string, v ...interface{}) {
+	l.log(0, slog.LevelError, format, v...)
+}
+
+func (l *structuredLog) Panic(v ...interface{}) {
+	l.logArgs(levelPanic, v...)
+	l.Flush()
+	panic(fmt.Sprint(v...))
+}
+
+func (l *structuredLog) Panicf(format string, v ...interface{}) {
+	l.log(0, levelPanic, format, v...)
+	l.Flush()
+	panic(fmt.Sprintf(format, v...))
+}
+
+func (l *structuredLog) Fatal(v ...interface{}) {
+	l.logArgs(levelFatal, v...)
+	l.Flush()
+	os.Exit(-1)
+}
+
+func (l *structuredLog) Fatalf(format string, v ...interface{}) {
+	l.log(0, levelFatal, format, v...)
+	l.Flush()
+	os.Exit(-1)
+}
+
+func (l *structuredLog) Flush() {
+	if f, ok := l.writer.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+}
+
+func (l *structuredLog) Close() error {
+	l.Flush()
+	if l.closer != nil {
+		return l.closer.Close()
+	}
+	return nil
+}
+
+// sampler limits the lines of each call site in a second
+type sampler struct {
+	initial    int
+	thereafter int
+
+	mu     sync.Mutex
+	second int64
+	counts map[uintptr]int
+}
+
+func newSampler(initial, thereafter int) *sampler {
+	return &sampler{initial: initial, thereafter: thereafter, counts: make(map[uintptr]int)}
+}
+
+func (s *sampler) allow(pc uintptr) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now().Unix(); now != s.second {
+		s.second = now
+		s.counts = make(map[uintptr]int)
+	}
+	s.counts[pc]++
+	n := s.counts[pc]
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+// rotateWriter writes {dir}/{module}.LOG.log and rotates it like the glog files
+type rotateWriter struct {
+	mu     sync.Mutex
+	dir    string
+	module string
+	file   *os.File
+	buf    *bufio.Writer
+	nbytes uint64
+	ticker *time.Ticker
+	done   chan struct{}
+	once   sync.Once
+}
+
+const structuredTag = "LOG"
+
+func newRotateWriter(dir, module string) (*rotateWriter, error) {
+	f, n, err := create(dir, module, structuredTag)
+	if err != nil {
+		return nil, err
+	}
+	w := &rotateWriter{dir: dir, module: module, file: f, buf: bufio.NewWriterSize(f, bufferSize), nbytes: uint64(n),
+		ticker: time.NewTicker(flushInterval), done: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case <-w.ticker.C:
+				_ = w.Flush()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return w, nil
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.nbytes+uint64(len(p)) >= uint64(RotateSize) {
+		_ = w.buf.Flush()
+		_ = w.file.Close()
+		f, err := renameAndCreate(w.dir, w.module, structuredTag, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		w.file, w.nbytes = f, 0
+		w.buf.Reset(f)
+	}
+	n, err := w.buf.Write(p)
+	w.nbytes += uint64(n)
+	return n, err
+}
+
+func (w *rotateWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Flush()
+}
+
+// Close stops the flush of writer and closes the file, it can be called twice
+func (w *rotateWriter) Close() error {
+	w.once.Do(func() {
+		w.ticker.Stop()
+		close(w.done)
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_ = w.buf.Flush()
+	return w.file.Close()
+}
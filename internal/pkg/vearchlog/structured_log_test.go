@@ -0,0 +1,133 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package vearchlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+func readLines(t *testing.T, file string) []map[string]interface{} {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line %s is not json: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestStructuredLog(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewStructuredLog(&StructuredConfig{
+		Dir:              dir,
+		Module:           "Test",
+		Level:            "info",
+		ModuleLevels:     map[string]string{"pkg/vearchlog": "debug"},
+		SampleInitial:    2,
+		SampleThereafter: 0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.RemoveLogI(0)
+	log.Regist(l)
+	defer log.RemoveLogI(0)
+
+	log.Info("hello %s", "info")
+	for i := 0; i < 5; i++ {
+		log.Debug("sampled %d", i)
+	}
+	if err := l.SetLevel("pkg/vearchlog", "warn"); err != nil {
+		t.Fatal(err)
+	}
+	log.Info("dropped by module level")
+	log.Error("hello %s", "error")
+	l.Close()
+
+	lines := readLines(t, filepath.Join(dir, "Test.LOG.log"))
+	want := []string{"hello info", "sampled 0", "sampled 1", "hello error"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines %v, want %d", len(lines), lines, len(want))
+	}
+	for i, line := range lines {
+		if line["msg"] != want[i] {
+			t.Errorf("line %d msg %v, want %s", i, line["msg"], want[i])
+		}
+		if line["module"] != "pkg/vearchlog" || line["component"] != "Test" {
+			t.Errorf("line %d module %v component %v", i, line["module"], line["component"])
+		}
+	}
+	if lines[1]["level"] != "DEBUG" {
+		t.Errorf("level %v, want DEBUG", lines[1]["level"])
+	}
+
+	if _, err := NewStructuredLog(&StructuredConfig{Level: "verbose"}); err == nil {
+		t.Errorf("unknown level should fail")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		wantErr bool
+	}{
+		{level: "debug"},
+		{level: "TRACE"},
+		{level: "panic"},
+		{level: "Fatal"},
+		{level: "verbose", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			if _, err := parseLevel(tt.level); (err != nil) != tt.wantErr {
+				t.Errorf("parseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRotateWriterClose(t *testing.T) {
+	w, err := newRotateWriter(t.TempDir(), "Test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.done:
+	default:
+		t.Errorf("flush goroutine should be stopped by close")
+	}
+	// a second close does not panic on the closed channel
+	_ = w.Close()
+}
@@ -0,0 +1,640 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wasm
+
+import (
+	"fmt"
+)
+
+// internal opcodes of the saturating truncations 0xfc 0..7
+const opTruncSat = 0x100
+
+// unknown is the type of a value popped in unreachable code
+const unknown ValueType = 0
+
+// target is where a branch goes: the stack keeps the top keep values at
+// height, relative to the locals of the frame
+type target struct {
+	pc     int
+	keep   int
+	height int
+}
+
+type instr struct {
+	op  uint16
+	imm uint64
+	tgt target
+}
+
+const (
+	ctrlBlock = iota
+	ctrlLoop
+	ctrlIf
+	ctrlElse
+)
+
+type ctrl struct {
+	kind        int
+	params      []ValueType
+	results     []ValueType
+	height      int
+	unreachable bool
+	// start is the first instruction of a loop
+	start int
+	// patches are the branches to the end of a block
+	patches []int
+	// tables are the br_table targets to the end of a block
+	tables [][2]int
+	// elseJump is the jump of an if over its then branch
+	elseJump int
+}
+
+func (c *ctrl) labelTypes() []ValueType {
+	if c.kind == ctrlLoop {
+		return c.params
+	}
+	return c.results
+}
+
+type compiler struct {
+	m      *Module
+	fn     *function
+	locals []ValueType
+	stack  []ValueType
+	ctrls  []*ctrl
+}
+
+func (m *Module) compileFunction(fn *function, r *reader) error {
+	locals := append([]ValueType{}, fn.typ.Params...)
+	groups := r.u32()
+	for i := uint32(0); i < groups && r.err == nil; i++ {
+		count := r.u32()
+		t := ValueType(r.byte())
+		if r.err != nil {
+			break
+		}
+		if uint64(len(locals))+uint64(count) > MaxLocals {
+			return fmt.Errorf("more than %d locals", MaxLocals)
+		}
+		if !t.valid() {
+			return fmt.Errorf("local of unknown type 0x%x", byte(t))
+		}
+		for j := uint32(0); j < count; j++ {
+			locals = append(locals, t)
+		}
+	}
+	fn.numLocals = len(locals)
+	c := &compiler{m: m, fn: fn, locals: locals}
+	c.ctrls = append(c.ctrls, &ctrl{kind: ctrlBlock, results: fn.typ.Results})
+	for len(c.ctrls) > 0 {
+		op := r.byte()
+		if r.err != nil {
+			return r.err
+		}
+		if err := c.compile(op, r); err != nil {
+			return err
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+	if r.off != len(r.b) {
+		return fmt.Errorf("code after the end of the body")
+	}
+	return nil
+}
+
+func (c *compiler) emit(op uint16, imm uint64) int {
+	c.fn.code = append(c.fn.code, instr{op: op, imm: imm})
+	return len(c.fn.code) - 1
+}
+
+func (c *compiler) push(types ...ValueType) {
+	c.stack = append(c.stack, types...)
+	if h := len(c.stack); h > c.fn.maxStack {
+		c.fn.maxStack = h
+	}
+}
+
+func (c *compiler) pop(want ValueType) (ValueType, error) {
+	frame := c.ctrls[len(c.ctrls)-1]
+	if len(c.stack) == frame.height {
+		if frame.unreachable {
+			return want, nil
+		}
+		return 0, fmt.Errorf("pop of an empty stack")
+	}
+	t := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	if want != unknown && t != unknown && t != want {
+		return 0, fmt.Errorf("expected %v but got %v", want, t)
+	}
+	if t == unknown {
+		t = want
+	}
+	return t, nil
+}
+
+func (c *compiler) popAll(types []ValueType) error {
+	for i := len(types) - 1; i >= 0; i-- {
+		if _, err := c.pop(types[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unop pops the operands of a numeric instruction and pushes its result
+func (c *compiler) unop(in, out ValueType) error {
+	if _, err := c.pop(in); err != nil {
+		return err
+	}
+	c.push(out)
+	return nil
+}
+
+func (c *compiler) binop(in, out ValueType) error {
+	if _, err := c.pop(in); err != nil {
+		return err
+	}
+	return c.unop(in, out)
+}
+
+func (c *compiler) setUnreachable() {
+	frame := c.ctrls[len(c.ctrls)-1]
+	c.stack = c.stack[:frame.height]
+	frame.unreachable = true
+}
+
+func (c *compiler) blockType(r *reader) ([]ValueType, []ValueType, error) {
+	if r.off >= len(r.b) {
+		return nil, nil, fmt.Errorf("unexpected end of block type")
+	}
+	b := r.b[r.off]
+	if b == 0x40 {
+		r.off++
+		return nil, nil, nil
+	}
+	if t := ValueType(b); t.valid() {
+		r.off++
+		return nil, []ValueType{t}, nil
+	}
+	idx := r.sleb(33)
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	if idx < 0 || idx >= int64(len(c.m.types)) {
+		return nil, nil, fmt.Errorf("block of unknown type %d", idx)
+	}
+	t := c.m.types[idx]
+	return t.Params, t.Results, nil
+}
+
+func (c *compiler) pushCtrl(kind int, params, results []ValueType) error {
+	if err := c.popAll(params); err != nil {
+		return err
+	}
+	frame := &ctrl{kind: kind, params: params, results: results, height: len(c.stack), start: len(c.fn.code)}
+	c.ctrls = append(c.ctrls, frame)
+	c.push(params...)
+	return nil
+}
+
+// label returns the target of a branch to depth and records it to be
+// patched when the block ends
+func (c *compiler) label(depth uint32) (*ctrl, target, error) {
+	if int(depth) >= len(c.ctrls) {
+		return nil, target{}, fmt.Errorf("branch to unknown depth %d", depth)
+	}
+	frame := c.ctrls[len(c.ctrls)-1-int(depth)]
+	tgt := target{keep: len(frame.labelTypes()), height: frame.height}
+	if frame.kind == ctrlLoop {
+		tgt.pc = frame.start
+	}
+	return frame, tgt, nil
+}
+
+func (c *compiler) branch(op uint16, depth uint32) error {
+	frame, tgt, err := c.label(depth)
+	if err != nil {
+		return err
+	}
+	types := frame.labelTypes()
+	if err := c.popAll(types); err != nil {
+		return err
+	}
+	idx := c.emit(op, 0)
+	c.fn.code[idx].tgt = tgt
+	if frame.kind != ctrlLoop {
+		frame.patches = append(frame.patches, idx)
+	}
+	if op == 0x0c {
+		c.setUnreachable()
+	} else {
+		c.push(types...)
+	}
+	return nil
+}
+
+func (c *compiler) end() error {
+	frame := c.ctrls[len(c.ctrls)-1]
+	if err := c.popAll(frame.results); err != nil {
+		return err
+	}
+	if len(c.stack) != frame.height {
+		return fmt.Errorf("%d values left at the end of a block", len(c.stack)-frame.height)
+	}
+	if frame.kind == ctrlIf {
+		// an if without else passes its params through
+		if !sameTypes(frame.params, frame.results) {
+			return fmt.Errorf("if without else must not change the stack")
+		}
+		c.fn.code[frame.elseJump].tgt.pc = len(c.fn.code)
+	}
+	c.ctrls = c.ctrls[:len(c.ctrls)-1]
+	pc := len(c.fn.code)
+	if len(c.ctrls) == 0 {
+		c.emit(0x0f, 0)
+	}
+	for _, idx := range frame.patches {
+		c.fn.code[idx].tgt.pc = pc
+	}
+	for _, t := range frame.tables {
+		c.fn.brTables[t[0]][t[1]].pc = pc
+	}
+	c.push(frame.results...)
+	return nil
+}
+
+func (c *compiler) memarg(r *reader, size uint32) (uint64, error) {
+	if !c.m.hasMemory {
+		return 0, fmt.Errorf("memory access without memory")
+	}
+	align := r.u32()
+	offset := r.u32()
+	if r.err != nil {
+		return 0, r.err
+	}
+	if align > 3 || 1<<align > size {
+		return 0, fmt.Errorf("alignment 2^%d over %d bytes", align, size)
+	}
+	return uint64(offset), nil
+}
+
+// memory loads and stores by opcode: the size, the type of the value and
+// whether it is a store
+var memoryOps = map[byte]struct {
+	size  uint32
+	typ   ValueType
+	store bool
+}{
+	0x28: {4, I32, false}, 0x29: {8, I64, false}, 0x2a: {4, F32, false}, 0x2b: {8, F64, false},
+	0x2c: {1, I32, false}, 0x2d: {1, I32, false}, 0x2e: {2, I32, false}, 0x2f: {2, I32, false},
+	0x30: {1, I64, false}, 0x31: {1, I64, false}, 0x32: {2, I64, false}, 0x33: {2, I64, false},
+	0x34: {4, I64, false}, 0x35: {4, I64, false},
+	0x36: {4, I32, true}, 0x37: {8, I64, true}, 0x38: {4, F32, true}, 0x39: {8, F64, true},
+	0x3a: {1, I32, true}, 0x3b: {2, I32, true}, 0x3c: {1, I64, true}, 0x3d: {2, I64, true},
+	0x3e: {4, I64, true},
+}
+
+// conversions by opcode: the operand and the result types
+var convertOps = map[byte][2]ValueType{
+	0xa7: {I64, I32},
+	0xa8: {F32, I32}, 0xa9: {F32, I32}, 0xaa: {F64, I32}, 0xab: {F64, I32},
+	0xac: {I32, I64}, 0xad: {I32, I64},
+	0xae: {F32, I64}, 0xaf: {F32, I64}, 0xb0: {F64, I64}, 0xb1: {F64, I64},
+	0xb2: {I32, F32}, 0xb3: {I32, F32}, 0xb4: {I64, F32}, 0xb5: {I64, F32},
+	0xb6: {F64, F32},
+	0xb7: {I32, F64}, 0xb8: {I32, F64}, 0xb9: {I64, F64}, 0xba: {I64, F64},
+	0xbb: {F32, F64},
+	0xbc: {F32, I32}, 0xbd: {F64, I64}, 0xbe: {I32, F32}, 0xbf: {I64, F64},
+	0xc0: {I32, I32}, 0xc1: {I32, I32},
+	0xc2: {I64, I64}, 0xc3: {I64, I64}, 0xc4: {I64, I64},
+}
+
+// truncSatOps are the operand and result types of the 0xfc truncations
+var truncSatOps = [8][2]ValueType{
+	{F32, I32}, {F32, I32}, {F64, I32}, {F64, I32},
+	{F32, I64}, {F32, I64}, {F64, I64}, {F64, I64},
+}
+
+func (c *compiler) compile(op byte, r *reader) error {
+	switch {
+	case op == 0x00:
+		c.emit(uint16(op), 0)
+		c.setUnreachable()
+	case op == 0x01:
+	case op == 0x02 || op == 0x03:
+		params, results, err := c.blockType(r)
+		if err != nil {
+			return err
+		}
+		kind := ctrlBlock
+		if op == 0x03 {
+			kind = ctrlLoop
+		}
+		return c.pushCtrl(kind, params, results)
+	case op == 0x04:
+		params, results, err := c.blockType(r)
+		if err != nil {
+			return err
+		}
+		if _, err := c.pop(I32); err != nil {
+			return err
+		}
+		if err := c.pushCtrl(ctrlIf, params, results); err != nil {
+			return err
+		}
+		frame := c.ctrls[len(c.ctrls)-1]
+		frame.elseJump = c.emit(0x04, 0)
+		c.fn.code[frame.elseJump].tgt = target{keep: len(params), height: frame.height}
+	case op == 0x05:
+		frame := c.ctrls[len(c.ctrls)-1]
+		if frame.kind != ctrlIf {
+			return fmt.Errorf("else without if")
+		}
+		if err := c.popAll(frame.results); err != nil {
+			return err
+		}
+		if len(c.stack) != frame.height {
+			return fmt.Errorf("%d values left at the end of a block", len(c.stack)-frame.height)
+		}
+		jump := c.emit(0x0c, 0)
+		c.fn.code[jump].tgt = target{keep: len(frame.results), height: frame.height}
+		frame.patches = append(frame.patches, jump)
+		c.fn.code[frame.elseJump].tgt.pc = len(c.fn.code)
+		frame.kind = ctrlElse
+		frame.unreachable = false
+		c.push(frame.params...)
+	case op == 0x0b:
+		return c.end()
+	case op == 0x0c || op == 0x0d:
+		depth := r.u32()
+		if op == 0x0d {
+			if _, err := c.pop(I32); err != nil {
+				return err
+			}
+		}
+		return c.branch(uint16(op), depth)
+	case op == 0x0e:
+		n := r.u32()
+		if n > MaxFunctions {
+			return fmt.Errorf("br_table of %d labels", n)
+		}
+		if _, err := c.pop(I32); err != nil {
+			return err
+		}
+		table := len(c.fn.brTables)
+		targets := make([]target, n+1)
+		c.fn.brTables = append(c.fn.brTables, targets)
+		var arity = -1
+		for i := uint32(0); i <= n; i++ {
+			frame, tgt, err := c.label(r.u32())
+			if err != nil {
+				return err
+			}
+			if r.err != nil {
+				return r.err
+			}
+			if arity >= 0 && arity != tgt.keep {
+				return fmt.Errorf("br_table labels of different arity")
+			}
+			arity = tgt.keep
+			targets[i] = tgt
+			if frame.kind != ctrlLoop {
+				frame.tables = append(frame.tables, [2]int{table, int(i)})
+			}
+			types := frame.labelTypes()
+			for j := len(types) - 1; j >= 0; j-- {
+				k := len(c.stack) - len(types) + j
+				if k >= c.ctrls[len(c.ctrls)-1].height && c.stack[k] != unknown && c.stack[k] != types[j] {
+					return fmt.Errorf("br_table expected %v but got %v", types[j], c.stack[k])
+				}
+			}
+		}
+		if top := c.ctrls[len(c.ctrls)-1]; !top.unreachable && len(c.stack)-top.height < arity {
+			return fmt.Errorf("br_table pops an empty stack")
+		}
+		c.emit(0x0e, uint64(table))
+		c.setUnreachable()
+	case op == 0x0f:
+		if err := c.popAll(c.fn.typ.Results); err != nil {
+			return err
+		}
+		c.emit(0x0f, 0)
+		c.setUnreachable()
+	case op == 0x10:
+		idx := r.u32()
+		if int(idx) >= len(c.m.funcs) {
+			return fmt.Errorf("call of unknown function %d", idx)
+		}
+		t := c.m.funcs[idx].typ
+		if err := c.popAll(t.Params); err != nil {
+			return err
+		}
+		c.emit(0x10, uint64(idx))
+		c.push(t.Results...)
+	case op == 0x11:
+		return fmt.Errorf("call_indirect is not supported")
+	case op == 0x1a:
+		if _, err := c.pop(unknown); err != nil {
+			return err
+		}
+		c.emit(0x1a, 0)
+	case op == 0x1b || op == 0x1c:
+		var want ValueType = unknown
+		if op == 0x1c {
+			types, err := r.valueTypes()
+			if err != nil {
+				return err
+			}
+			if len(types) != 1 {
+				return fmt.Errorf("select of %d types", len(types))
+			}
+			want = types[0]
+		}
+		if _, err := c.pop(I32); err != nil {
+			return err
+		}
+		t, err := c.pop(want)
+		if err != nil {
+			return err
+		}
+		if _, err := c.pop(t); err != nil {
+			return err
+		}
+		c.push(t)
+		c.emit(0x1b, 0)
+	case op >= 0x20 && op <= 0x22:
+		idx := r.u32()
+		if int(idx) >= len(c.locals) {
+			return fmt.Errorf("unknown local %d", idx)
+		}
+		t := c.locals[idx]
+		switch op {
+		case 0x20:
+			c.push(t)
+		case 0x21:
+			if _, err := c.pop(t); err != nil {
+				return err
+			}
+		case 0x22:
+			if err := c.unop(t, t); err != nil {
+				return err
+			}
+		}
+		c.emit(uint16(op), uint64(idx))
+	case op == 0x23 || op == 0x24:
+		idx := r.u32()
+		if int(idx) >= len(c.m.globals) {
+			return fmt.Errorf("unknown global %d", idx)
+		}
+		g := c.m.globals[idx]
+		t := g.typ
+		if op == 0x23 {
+			c.push(t)
+		} else {
+			if !g.mutable {
+				return fmt.Errorf("set of immutable global %d", idx)
+			}
+			if _, err := c.pop(t); err != nil {
+				return err
+			}
+		}
+		c.emit(uint16(op), uint64(idx))
+	case op >= 0x28 && op <= 0x3e:
+		mem := memoryOps[op]
+		offset, err := c.memarg(r, mem.size)
+		if err != nil {
+			return err
+		}
+		if mem.store {
+			if _, err := c.pop(mem.typ); err != nil {
+				return err
+			}
+			if _, err := c.pop(I32); err != nil {
+				return err
+			}
+		} else if err := c.unop(I32, mem.typ); err != nil {
+			return err
+		}
+		c.emit(uint16(op), offset)
+	case op == 0x3f || op == 0x40:
+		if !c.m.hasMemory {
+			return fmt.Errorf("memory instruction without memory")
+		}
+		if r.byte() != 0 {
+			return fmt.Errorf("memory index must be 0")
+		}
+		if op == 0x3f {
+			c.push(I32)
+		} else if err := c.unop(I32, I32); err != nil {
+			return err
+		}
+		c.emit(uint16(op), 0)
+	case op == 0x41:
+		c.push(I32)
+		c.emit(uint16(op), uint64(uint32(int32(r.sleb(32)))))
+	case op == 0x42:
+		c.push(I64)
+		c.emit(uint16(op), uint64(r.sleb(64)))
+	case op == 0x43:
+		b := r.bytes(4)
+		if r.err != nil {
+			return r.err
+		}
+		c.push(F32)
+		c.emit(uint16(op), uint64(uint32(b[0])|uint32(b[1])<<8|uint32(b[2])<<16|uint32(b[3])<<24))
+	case op == 0x44:
+		b := r.bytes(8)
+		if r.err != nil {
+			return r.err
+		}
+		var v uint64
+		for i := 7; i >= 0; i-- {
+			v = v<<8 | uint64(b[i])
+		}
+		c.push(F64)
+		c.emit(uint16(op), v)
+	case op == 0xfc:
+		sub := r.u32()
+		if sub >= uint32(len(truncSatOps)) {
+			return fmt.Errorf("instruction 0xfc %d is not supported", sub)
+		}
+		if err := c.unop(truncSatOps[sub][0], truncSatOps[sub][1]); err != nil {
+			return err
+		}
+		c.emit(opTruncSat+uint16(sub), 0)
+	default:
+		in, out, arity, ok := numericType(op)
+		if !ok {
+			return fmt.Errorf("instruction 0x%x is not supported", op)
+		}
+		var err error
+		if arity == 1 {
+			err = c.unop(in, out)
+		} else {
+			err = c.binop(in, out)
+		}
+		if err != nil {
+			return err
+		}
+		c.emit(uint16(op), 0)
+	}
+	return nil
+}
+
+func sameTypes(a, b []ValueType) bool {
+	return FuncType{Params: a}.Equal(FuncType{Params: b})
+}
+
+// numericType returns the operand and result types and the arity of the
+// numeric instructions from 0x45
+func numericType(op byte) (in, out ValueType, arity int, ok bool) {
+	switch {
+	case op == 0x45:
+		return I32, I32, 1, true
+	case op >= 0x46 && op <= 0x4f:
+		return I32, I32, 2, true
+	case op == 0x50:
+		return I64, I32, 1, true
+	case op >= 0x51 && op <= 0x5a:
+		return I64, I32, 2, true
+	case op >= 0x5b && op <= 0x60:
+		return F32, I32, 2, true
+	case op >= 0x61 && op <= 0x66:
+		return F64, I32, 2, true
+	case op >= 0x67 && op <= 0x69:
+		return I32, I32, 1, true
+	case op >= 0x6a && op <= 0x78:
+		return I32, I32, 2, true
+	case op >= 0x79 && op <= 0x7b:
+		return I64, I64, 1, true
+	case op >= 0x7c && op <= 0x8a:
+		return I64, I64, 2, true
+	case op >= 0x8b && op <= 0x91:
+		return F32, F32, 1, true
+	case op >= 0x92 && op <= 0x98:
+		return F32, F32, 2, true
+	case op >= 0x99 && op <= 0x9f:
+		return F64, F64, 1, true
+	case op >= 0xa0 && op <= 0xa6:
+		return F64, F64, 2, true
+	}
+	if t, found := convertOps[op]; found {
+		return t[0], t[1], 1, true
+	}
+	return 0, 0, 0, false
+}
@@ -0,0 +1,709 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	// MaxCallDepth bounds the nested calls of a run
+	MaxCallDepth = 256
+	// MaxStack bounds the values of the stack of a run, locals included
+	MaxStack = 1 << 16
+)
+
+var (
+	// ErrOutOfFuel is returned when a call runs more instructions than its fuel
+	ErrOutOfFuel = errors.New("wasm: out of fuel")
+	// ErrStackOverflow is returned when calls nest too deep
+	ErrStackOverflow = errors.New("wasm: stack overflow")
+)
+
+// Trap is a runtime error of a module
+type Trap struct {
+	Reason string
+}
+
+func (t *Trap) Error() string {
+	return "wasm: trap: " + t.Reason
+}
+
+func trap(reason string) error {
+	return &Trap{Reason: reason}
+}
+
+type frame struct {
+	fn *function
+	pc int
+	fb int
+}
+
+// Instance is the memory and globals of a module, it is not safe for
+// concurrent use
+type Instance struct {
+	m       *Module
+	memory  []byte
+	globals []uint64
+	stack   []uint64
+	frames  []frame
+}
+
+// Instantiate makes a fresh instance of m
+func (m *Module) Instantiate() *Instance {
+	in := &Instance{m: m, globals: make([]uint64, len(m.globals))}
+	for i, g := range m.globals {
+		in.globals[i] = g.value
+	}
+	if m.hasMemory {
+		in.memory = make([]byte, int(m.memMin)*PageSize)
+		for _, d := range m.data {
+			copy(in.memory[d.offset:], d.data)
+		}
+	}
+	return in
+}
+
+// Memory returns the linear memory of the instance
+func (in *Instance) Memory() []byte {
+	return in.memory
+}
+
+// Call runs the exported function name with at most fuel instructions, the
+// arguments and results are the bits of their values
+func (in *Instance) Call(name string, fuel int64, args ...uint64) ([]uint64, error) {
+	idx, ok := in.m.exports[name]
+	if !ok {
+		return nil, fmt.Errorf("wasm: no exported function %s", name)
+	}
+	fn := in.m.funcs[idx]
+	if len(args) != len(fn.typ.Params) {
+		return nil, fmt.Errorf("wasm: %s takes %d arguments, not %d", name, len(fn.typ.Params), len(args))
+	}
+	if in.stack == nil {
+		in.stack = make([]uint64, 256)
+	}
+	in.frames = in.frames[:0]
+	copy(in.stack, args)
+	return in.run(fn, len(args), fuel)
+}
+
+// enter makes room for the locals and the operands of fn at fb
+func (in *Instance) enter(fn *function, fb, sp int) error {
+	need := fb + fn.numLocals + fn.maxStack
+	if need > MaxStack {
+		return ErrStackOverflow
+	}
+	if need > len(in.stack) {
+		size := 2 * len(in.stack)
+		for size < need {
+			size *= 2
+		}
+		if size > MaxStack {
+			size = MaxStack
+		}
+		stack := make([]uint64, size)
+		copy(stack, in.stack[:sp])
+		in.stack = stack
+	}
+	for i := sp; i < fb+fn.numLocals; i++ {
+		in.stack[i] = 0
+	}
+	return nil
+}
+
+func (in *Instance) run(fn *function, sp int, fuel int64) ([]uint64, error) {
+	fb, pc := 0, 0
+	if err := in.enter(fn, fb, sp); err != nil {
+		return nil, err
+	}
+	st := in.stack
+	base := fb + fn.numLocals
+	sp = base
+	for {
+		if fuel--; fuel < 0 {
+			return nil, ErrOutOfFuel
+		}
+		ins := &fn.code[pc]
+		pc++
+		switch op := ins.op; {
+		case op == 0x00:
+			return nil, trap("unreachable")
+		case op == 0x04:
+			sp--
+			if uint32(st[sp]) == 0 {
+				pc = ins.tgt.pc
+			}
+		case op == 0x0c:
+			sp = branch(st, base, sp, ins.tgt)
+			pc = ins.tgt.pc
+		case op == 0x0d:
+			sp--
+			if uint32(st[sp]) != 0 {
+				sp = branch(st, base, sp, ins.tgt)
+				pc = ins.tgt.pc
+			}
+		case op == 0x0e:
+			sp--
+			table := fn.brTables[ins.imm]
+			i := uint64(uint32(st[sp]))
+			if i >= uint64(len(table)) {
+				i = uint64(len(table) - 1)
+			}
+			sp = branch(st, base, sp, table[i])
+			pc = table[i].pc
+		case op == 0x0f:
+			n := len(fn.typ.Results)
+			copy(st[fb:], st[sp-n:sp])
+			sp = fb + n
+			if len(in.frames) == 0 {
+				return append([]uint64(nil), st[fb:sp]...), nil
+			}
+			top := in.frames[len(in.frames)-1]
+			in.frames = in.frames[:len(in.frames)-1]
+			fn, pc, fb = top.fn, top.pc, top.fb
+			base = fb + fn.numLocals
+		case op == 0x10:
+			if len(in.frames) >= MaxCallDepth {
+				return nil, ErrStackOverflow
+			}
+			callee := in.m.funcs[ins.imm]
+			in.frames = append(in.frames, frame{fn: fn, pc: pc, fb: fb})
+			fb = sp - len(callee.typ.Params)
+			if err := in.enter(callee, fb, sp); err != nil {
+				return nil, err
+			}
+			st = in.stack
+			fn, pc = callee, 0
+			base = fb + fn.numLocals
+			sp = base
+		case op == 0x1a:
+			sp--
+		case op == 0x1b:
+			sp -= 2
+			if uint32(st[sp+1]) == 0 {
+				st[sp-1] = st[sp]
+			}
+		case op == 0x20:
+			st[sp] = st[fb+int(ins.imm)]
+			sp++
+		case op == 0x21:
+			sp--
+			st[fb+int(ins.imm)] = st[sp]
+		case op == 0x22:
+			st[fb+int(ins.imm)] = st[sp-1]
+		case op == 0x23:
+			st[sp] = in.globals[ins.imm]
+			sp++
+		case op == 0x24:
+			sp--
+			in.globals[ins.imm] = st[sp]
+		case op >= 0x28 && op <= 0x35:
+			v, err := in.load(byte(op), st[sp-1], ins.imm)
+			if err != nil {
+				return nil, err
+			}
+			st[sp-1] = v
+		case op >= 0x36 && op <= 0x3e:
+			sp -= 2
+			if err := in.store(byte(op), st[sp], ins.imm, st[sp+1]); err != nil {
+				return nil, err
+			}
+		case op == 0x3f:
+			st[sp] = uint64(len(in.memory) / PageSize)
+			sp++
+		case op == 0x40:
+			st[sp-1] = uint64(in.grow(uint32(st[sp-1])))
+		case op >= 0x41 && op <= 0x44:
+			st[sp] = ins.imm
+			sp++
+		case op >= opTruncSat:
+			st[sp-1] = truncSat(op-opTruncSat, st[sp-1])
+		default:
+			_, _, arity, _ := numericType(byte(op))
+			var err error
+			if arity == 1 {
+				st[sp-1], err = unary(byte(op), st[sp-1])
+			} else {
+				sp--
+				st[sp-1], err = binary2(byte(op), st[sp-1], st[sp])
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// branch keeps the top values of the stack at the height of a label
+func branch(st []uint64, base, sp int, t target) int {
+	dst := base + t.height
+	if t.keep > 0 && dst != sp-t.keep {
+		copy(st[dst:], st[sp-t.keep:sp])
+	}
+	return dst + t.keep
+}
+
+var loadSizes = [...]uint64{4, 8, 4, 8, 1, 1, 2, 2, 1, 1, 2, 2, 4, 4, 4, 8, 4, 8, 1, 2, 1, 2, 4}
+
+func (in *Instance) address(op byte, addr, offset uint64) (uint64, error) {
+	ea := uint64(uint32(addr)) + offset
+	if ea+loadSizes[op-0x28] > uint64(len(in.memory)) {
+		return 0, trap("out of bounds memory access")
+	}
+	return ea, nil
+}
+
+func (in *Instance) load(op byte, addr, offset uint64) (uint64, error) {
+	ea, err := in.address(op, addr, offset)
+	if err != nil {
+		return 0, err
+	}
+	mem := in.memory[ea:]
+	switch op {
+	case 0x28, 0x2a:
+		return uint64(binary.LittleEndian.Uint32(mem)), nil
+	case 0x29, 0x2b:
+		return binary.LittleEndian.Uint64(mem), nil
+	case 0x2c:
+		return uint64(uint32(int32(int8(mem[0])))), nil
+	case 0x2d, 0x31:
+		return uint64(mem[0]), nil
+	case 0x2e:
+		return uint64(uint32(int32(int16(binary.LittleEndian.Uint16(mem))))), nil
+	case 0x2f, 0x33:
+		return uint64(binary.LittleEndian.Uint16(mem)), nil
+	case 0x30:
+		return uint64(int64(int8(mem[0]))), nil
+	case 0x32:
+		return uint64(int64(int16(binary.LittleEndian.Uint16(mem)))), nil
+	case 0x34:
+		return uint64(int64(int32(binary.LittleEndian.Uint32(mem)))), nil
+	default:
+		return uint64(binary.LittleEndian.Uint32(mem)), nil
+	}
+}
+
+func (in *Instance) store(op byte, addr, offset, v uint64) error {
+	ea, err := in.address(op, addr, offset)
+	if err != nil {
+		return err
+	}
+	mem := in.memory[ea:]
+	switch loadSizes[op-0x28] {
+	case 1:
+		mem[0] = byte(v)
+	case 2:
+		binary.LittleEndian.PutUint16(mem, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(mem, uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(mem, v)
+	}
+	return nil
+}
+
+func (in *Instance) grow(delta uint32) uint32 {
+	pages := uint32(len(in.memory) / PageSize)
+	if uint64(pages)+uint64(delta) > uint64(in.m.memMax) {
+		return math.MaxUint32
+	}
+	if delta > 0 {
+		in.memory = append(in.memory, make([]byte, int(delta)*PageSize)...)
+	}
+	return pages
+}
+
+func f32(v uint64) float32 {
+	return math.Float32frombits(uint32(v))
+}
+
+func f64(v uint64) float64 {
+	return math.Float64frombits(v)
+}
+
+func fromF32(f float32) uint64 {
+	return uint64(math.Float32bits(f))
+}
+
+func fromF64(f float64) uint64 {
+	return math.Float64bits(f)
+}
+
+func fromBool(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func unary(op byte, v uint64) (uint64, error) {
+	a32, a64 := uint32(v), v
+	switch op {
+	case 0x45:
+		return fromBool(a32 == 0), nil
+	case 0x50:
+		return fromBool(a64 == 0), nil
+	case 0x67:
+		return uint64(bits.LeadingZeros32(a32)), nil
+	case 0x68:
+		return uint64(bits.TrailingZeros32(a32)), nil
+	case 0x69:
+		return uint64(bits.OnesCount32(a32)), nil
+	case 0x79:
+		return uint64(bits.LeadingZeros64(a64)), nil
+	case 0x7a:
+		return uint64(bits.TrailingZeros64(a64)), nil
+	case 0x7b:
+		return uint64(bits.OnesCount64(a64)), nil
+	case 0x8b:
+		return uint64(a32 &^ (1 << 31)), nil
+	case 0x8c:
+		return uint64(a32 ^ (1 << 31)), nil
+	case 0x8d, 0x8e, 0x8f, 0x90, 0x91:
+		return fromF32(float32(round(op-0x8d, float64(f32(v))))), nil
+	case 0x99:
+		return a64 &^ (1 << 63), nil
+	case 0x9a:
+		return a64 ^ (1 << 63), nil
+	case 0x9b, 0x9c, 0x9d, 0x9e, 0x9f:
+		return fromF64(round(op-0x9b, f64(v))), nil
+	case 0xa7:
+		return uint64(a32), nil
+	case 0xa8, 0xa9, 0xaa, 0xab, 0xae, 0xaf, 0xb0, 0xb1:
+		return trunc(op, v)
+	case 0xac:
+		return uint64(int64(int32(a32))), nil
+	case 0xad:
+		return uint64(a32), nil
+	case 0xb2:
+		return fromF32(float32(int32(a32))), nil
+	case 0xb3:
+		return fromF32(float32(a32)), nil
+	case 0xb4:
+		return fromF32(float32(int64(a64))), nil
+	case 0xb5:
+		return fromF32(float32(a64)), nil
+	case 0xb6:
+		return fromF32(float32(f64(v))), nil
+	case 0xb7:
+		return fromF64(float64(int32(a32))), nil
+	case 0xb8:
+		return fromF64(float64(a32)), nil
+	case 0xb9:
+		return fromF64(float64(int64(a64))), nil
+	case 0xba:
+		return fromF64(float64(a64)), nil
+	case 0xbb:
+		return fromF64(float64(f32(v))), nil
+	case 0xbc, 0xbe:
+		return uint64(a32), nil
+	case 0xbd, 0xbf:
+		return a64, nil
+	case 0xc0:
+		return uint64(uint32(int32(int8(a32)))), nil
+	case 0xc1:
+		return uint64(uint32(int32(int16(a32)))), nil
+	case 0xc2:
+		return uint64(int64(int8(a64))), nil
+	case 0xc3:
+		return uint64(int64(int16(a64))), nil
+	case 0xc4:
+		return uint64(int64(int32(a64))), nil
+	}
+	return 0, trap(fmt.Sprintf("unknown instruction 0x%x", op))
+}
+
+// round runs ceil, floor, trunc, nearest or sqrt
+func round(kind byte, f float64) float64 {
+	switch kind {
+	case 0:
+		return math.Ceil(f)
+	case 1:
+		return math.Floor(f)
+	case 2:
+		return math.Trunc(f)
+	case 3:
+		return math.RoundToEven(f)
+	}
+	return math.Sqrt(f)
+}
+
+// truncation ranges of the destination integers, the bounds are exclusive
+var truncRanges = map[byte][2]float64{
+	0xa8: {-2147483649, 2147483648}, 0xa9: {-1, 4294967296},
+	0xaa: {-2147483649, 2147483648}, 0xab: {-1, 4294967296},
+	0xae: {-9223372036854777856, 9223372036854775808}, 0xaf: {-1, 18446744073709551616},
+	0xb0: {-9223372036854777856, 9223372036854775808}, 0xb1: {-1, 18446744073709551616},
+}
+
+func trunc(op byte, v uint64) (uint64, error) {
+	var f float64
+	if op == 0xa8 || op == 0xa9 || op == 0xae || op == 0xaf {
+		f = float64(f32(v))
+	} else {
+		f = f64(v)
+	}
+	if math.IsNaN(f) {
+		return 0, trap("invalid conversion to integer")
+	}
+	f = math.Trunc(f)
+	r := truncRanges[op]
+	if f <= r[0] || f >= r[1] {
+		return 0, trap("integer overflow")
+	}
+	switch op {
+	case 0xa8, 0xaa:
+		return uint64(uint32(int32(f))), nil
+	case 0xa9, 0xab:
+		return uint64(uint32(f)), nil
+	case 0xae, 0xb0:
+		return uint64(int64(f)), nil
+	}
+	return uint64(f), nil
+}
+
+func truncSat(sub uint16, v uint64) uint64 {
+	var f float64
+	if sub == 0 || sub == 1 || sub == 4 || sub == 5 {
+		f = float64(f32(v))
+	} else {
+		f = f64(v)
+	}
+	if math.IsNaN(f) {
+		return 0
+	}
+	switch sub {
+	case 0, 2:
+		if f <= math.MinInt32 {
+			return 1 << 31
+		} else if f >= math.MaxInt32 {
+			return math.MaxInt32
+		}
+		return uint64(uint32(int32(f)))
+	case 1, 3:
+		if f <= 0 {
+			return 0
+		} else if f >= math.MaxUint32 {
+			return math.MaxUint32
+		}
+		return uint64(uint32(f))
+	case 4, 6:
+		if f <= math.MinInt64 {
+			return 1 << 63
+		} else if f >= math.MaxInt64 {
+			return math.MaxInt64
+		}
+		return uint64(int64(f))
+	}
+	if f <= 0 {
+		return 0
+	} else if f >= math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return uint64(f)
+}
+
+func binary2(op byte, x, y uint64) (uint64, error) {
+	switch {
+	case op >= 0x46 && op <= 0x4f:
+		return fromBool(compareInt(op-0x46, int64(int32(x)), int64(int32(y)), uint64(uint32(x)), uint64(uint32(y)))), nil
+	case op >= 0x51 && op <= 0x5a:
+		return fromBool(compareInt(op-0x51, int64(x), int64(y), x, y)), nil
+	case op >= 0x5b && op <= 0x60:
+		return fromBool(compareFloat(op-0x5b, float64(f32(x)), float64(f32(y)))), nil
+	case op >= 0x61 && op <= 0x66:
+		return fromBool(compareFloat(op-0x61, f64(x), f64(y))), nil
+	case op >= 0x6a && op <= 0x78:
+		v, err := binaryI32(op, uint32(x), uint32(y))
+		return uint64(v), err
+	case op >= 0x7c && op <= 0x8a:
+		return binaryI64(op, x, y)
+	case op >= 0x92 && op <= 0x98:
+		a, b := f32(x), f32(y)
+		switch op {
+		case 0x92:
+			return fromF32(a + b), nil
+		case 0x93:
+			return fromF32(a - b), nil
+		case 0x94:
+			return fromF32(a * b), nil
+		case 0x95:
+			return fromF32(a / b), nil
+		case 0x98:
+			return uint64(uint32(x)&^(1<<31) | uint32(y)&(1<<31)), nil
+		}
+		return fromF32(float32(minMax(op == 0x96, float64(a), float64(b)))), nil
+	case op >= 0xa0 && op <= 0xa6:
+		a, b := f64(x), f64(y)
+		switch op {
+		case 0xa0:
+			return fromF64(a + b), nil
+		case 0xa1:
+			return fromF64(a - b), nil
+		case 0xa2:
+			return fromF64(a * b), nil
+		case 0xa3:
+			return fromF64(a / b), nil
+		case 0xa6:
+			return x&^(1<<63) | y&(1<<63), nil
+		}
+		return fromF64(minMax(op == 0xa4, a, b)), nil
+	}
+	return 0, trap(fmt.Sprintf("unknown instruction 0x%x", op))
+}
+
+// compareInt runs eq, ne, lt_s, lt_u, gt_s, gt_u, le_s, le_u, ge_s or ge_u
+func compareInt(kind byte, sa, sb int64, ua, ub uint64) bool {
+	switch kind {
+	case 0:
+		return ua == ub
+	case 1:
+		return ua != ub
+	case 2:
+		return sa < sb
+	case 3:
+		return ua < ub
+	case 4:
+		return sa > sb
+	case 5:
+		return ua > ub
+	case 6:
+		return sa <= sb
+	case 7:
+		return ua <= ub
+	case 8:
+		return sa >= sb
+	}
+	return ua >= ub
+}
+
+// compareFloat runs eq, ne, lt, gt, le or ge
+func compareFloat(kind byte, a, b float64) bool {
+	switch kind {
+	case 0:
+		return a == b
+	case 1:
+		return a != b
+	case 2:
+		return a < b
+	case 3:
+		return a > b
+	case 4:
+		return a <= b
+	}
+	return a >= b
+}
+
+func minMax(min bool, a, b float64) float64 {
+	if min {
+		return math.Min(a, b)
+	}
+	return math.Max(a, b)
+}
+
+func binaryI32(op byte, a, b uint32) (uint32, error) {
+	switch op {
+	case 0x6a:
+		return a + b, nil
+	case 0x6b:
+		return a - b, nil
+	case 0x6c:
+		return a * b, nil
+	case 0x6d, 0x6e, 0x6f, 0x70:
+		if b == 0 {
+			return 0, trap("integer divide by zero")
+		}
+		switch op {
+		case 0x6d:
+			if int32(a) == math.MinInt32 && int32(b) == -1 {
+				return 0, trap("integer overflow")
+			}
+			return uint32(int32(a) / int32(b)), nil
+		case 0x6e:
+			return a / b, nil
+		case 0x6f:
+			if int32(b) == -1 {
+				return 0, nil
+			}
+			return uint32(int32(a) % int32(b)), nil
+		}
+		return a % b, nil
+	case 0x71:
+		return a & b, nil
+	case 0x72:
+		return a | b, nil
+	case 0x73:
+		return a ^ b, nil
+	case 0x74:
+		return a << (b & 31), nil
+	case 0x75:
+		return uint32(int32(a) >> (b & 31)), nil
+	case 0x76:
+		return a >> (b & 31), nil
+	case 0x77:
+		return bits.RotateLeft32(a, int(b&31)), nil
+	}
+	return bits.RotateLeft32(a, -int(b&31)), nil
+}
+
+func binaryI64(op byte, a, b uint64) (uint64, error) {
+	switch op {
+	case 0x7c:
+		return a + b, nil
+	case 0x7d:
+		return a - b, nil
+	case 0x7e:
+		return a * b, nil
+	case 0x7f, 0x80, 0x81, 0x82:
+		if b == 0 {
+			return 0, trap("integer divide by zero")
+		}
+		switch op {
+		case 0x7f:
+			if int64(a) == math.MinInt64 && int64(b) == -1 {
+				return 0, trap("integer overflow")
+			}
+			return uint64(int64(a) / int64(b)), nil
+		case 0x80:
+			return a / b, nil
+		case 0x81:
+			if int64(b) == -1 {
+				return 0, nil
+			}
+			return uint64(int64(a) % int64(b)), nil
+		}
+		return a % b, nil
+	case 0x83:
+		return a & b, nil
+	case 0x84:
+		return a | b, nil
+	case 0x85:
+		return a ^ b, nil
+	case 0x86:
+		return a << (b & 63), nil
+	case 0x87:
+		return uint64(int64(a) >> (b & 63)), nil
+	case 0x88:
+		return a >> (b & 63), nil
+	case 0x89:
+		return bits.RotateLeft64(a, int(b&63)), nil
+	}
+	return bits.RotateLeft64(a, -int(b&63)), nil
+}
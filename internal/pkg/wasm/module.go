@@ -0,0 +1,545 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package wasm runs small sandboxed WebAssembly modules in pure go, the user
+// defined functions of spaces. It interprets the numeric subset of the MVP
+// with the sign extension and saturating truncation instructions: modules
+// can not import anything, so a call sees only its arguments, its globals and
+// its linear memory. Every executed instruction burns a unit of fuel and the
+// memory is capped, so a call is bounded in time and space.
+package wasm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	// PageSize is the bytes of a page of linear memory
+	PageSize = 65536
+	// MaxModuleSize bounds the binary of a module
+	MaxModuleSize = 1 << 20
+	// MaxFunctions bounds the functions of a module
+	MaxFunctions = 1024
+	// MaxLocals bounds the locals of a function
+	MaxLocals = 1024
+)
+
+// ValueType is a number type of wasm
+type ValueType byte
+
+const (
+	I32 ValueType = 0x7f
+	I64 ValueType = 0x7e
+	F32 ValueType = 0x7d
+	F64 ValueType = 0x7c
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	}
+	return fmt.Sprintf("type(0x%x)", byte(t))
+}
+
+// FuncType is the signature of a function
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+// Equal tells whether t has the params and results of o
+func (t FuncType) Equal(o FuncType) bool {
+	if len(t.Params) != len(o.Params) || len(t.Results) != len(o.Results) {
+		return false
+	}
+	for i := range t.Params {
+		if t.Params[i] != o.Params[i] {
+			return false
+		}
+	}
+	for i := range t.Results {
+		if t.Results[i] != o.Results[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (t FuncType) String() string {
+	return fmt.Sprintf("%v -> %v", t.Params, t.Results)
+}
+
+type function struct {
+	typ       FuncType
+	numLocals int
+	code      []instr
+	// brTables holds the targets of the br_table instructions
+	brTables [][]target
+	maxStack int
+}
+
+type global struct {
+	typ     ValueType
+	mutable bool
+	value   uint64
+}
+
+type dataSegment struct {
+	offset uint32
+	data   []byte
+}
+
+// Module is a compiled module, it is safe for concurrent use and instantiated
+// for every run
+type Module struct {
+	types      []FuncType
+	funcs      []*function
+	hasMemory  bool
+	memMin     uint32
+	memMax     uint32
+	globals    []global
+	exports    map[string]int
+	data       []dataSegment
+	maxPages   uint32
+	codeLength int
+}
+
+// Compile decodes and checks a binary module, its memory may not start with
+// more than maxPages pages and can not grow over them
+func Compile(bin []byte, maxPages uint32) (*Module, error) {
+	if len(bin) > MaxModuleSize {
+		return nil, fmt.Errorf("wasm: module of %d bytes exceeds %d", len(bin), MaxModuleSize)
+	}
+	if len(bin) < 8 || string(bin[:4]) != "\x00asm" || binary.LittleEndian.Uint32(bin[4:8]) != 1 {
+		return nil, fmt.Errorf("wasm: not a version 1 binary module")
+	}
+	m := &Module{exports: make(map[string]int), maxPages: maxPages}
+	r := &reader{b: bin, off: 8}
+	var funcTypes []uint32
+	lastID := byte(0)
+	for r.err == nil && r.off < len(r.b) {
+		id := r.byte()
+		size := r.u32()
+		if r.err != nil {
+			break
+		}
+		if int(size) > len(r.b)-r.off {
+			return nil, fmt.Errorf("wasm: section %d of %d bytes is truncated", id, size)
+		}
+		// the data count section 12 goes before the code section 10
+		order := id
+		if id == 12 {
+			order = 9
+		}
+		if id != 0 {
+			if order <= lastID {
+				return nil, fmt.Errorf("wasm: section %d out of order", id)
+			}
+			lastID = order
+		}
+		sr := &reader{b: r.b[:r.off+int(size)], off: r.off}
+		var err error
+		switch id {
+		case 0, 12:
+			// custom and data count sections
+		case 1:
+			err = m.readTypes(sr)
+		case 2:
+			if n := sr.u32(); n > 0 {
+				err = fmt.Errorf("wasm: modules can not import")
+			}
+		case 3:
+			funcTypes, err = m.readFunctions(sr)
+		case 4:
+			if n := sr.u32(); n > 0 {
+				err = fmt.Errorf("wasm: tables are not supported")
+			}
+		case 5:
+			err = m.readMemory(sr)
+		case 6:
+			err = m.readGlobals(sr)
+		case 7:
+			err = m.readExports(sr)
+		case 8:
+			err = fmt.Errorf("wasm: start functions are not supported")
+		case 9:
+			if n := sr.u32(); n > 0 {
+				err = fmt.Errorf("wasm: element segments are not supported")
+			}
+		case 10:
+			err = m.readCode(sr, funcTypes)
+		case 11:
+			err = m.readData(sr)
+		default:
+			err = fmt.Errorf("wasm: unknown section %d", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sr.err != nil {
+			return nil, sr.err
+		}
+		if id != 0 && sr.off != len(sr.b) {
+			return nil, fmt.Errorf("wasm: section %d has trailing bytes", id)
+		}
+		r.off += int(size)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(funcTypes) != len(m.funcs) {
+		return nil, fmt.Errorf("wasm: %d functions declared but %d bodies", len(funcTypes), len(m.funcs))
+	}
+	for name, idx := range m.exports {
+		if idx >= len(m.funcs) {
+			return nil, fmt.Errorf("wasm: export %s of unknown function %d", name, idx)
+		}
+	}
+	return m, nil
+}
+
+// Export returns the signature of an exported function
+func (m *Module) Export(name string) (FuncType, bool) {
+	idx, ok := m.exports[name]
+	if !ok {
+		return FuncType{}, false
+	}
+	return m.funcs[idx].typ, true
+}
+
+// CodeLength returns the instructions of all functions
+func (m *Module) CodeLength() int {
+	return m.codeLength
+}
+
+func (m *Module) readTypes(r *reader) error {
+	n := r.u32()
+	if n > MaxFunctions {
+		return fmt.Errorf("wasm: %d types exceed %d", n, MaxFunctions)
+	}
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		if form := r.byte(); form != 0x60 {
+			return fmt.Errorf("wasm: type form 0x%x is not a function", form)
+		}
+		params, err := r.valueTypes()
+		if err != nil {
+			return err
+		}
+		results, err := r.valueTypes()
+		if err != nil {
+			return err
+		}
+		m.types = append(m.types, FuncType{Params: params, Results: results})
+	}
+	return nil
+}
+
+func (m *Module) readFunctions(r *reader) ([]uint32, error) {
+	n := r.u32()
+	if n > MaxFunctions {
+		return nil, fmt.Errorf("wasm: %d functions exceed %d", n, MaxFunctions)
+	}
+	types := make([]uint32, 0, n)
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		t := r.u32()
+		if int(t) >= len(m.types) {
+			return nil, fmt.Errorf("wasm: function of unknown type %d", t)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+func (m *Module) readMemory(r *reader) error {
+	n := r.u32()
+	if n > 1 {
+		return fmt.Errorf("wasm: at most one memory is supported")
+	}
+	if n == 0 {
+		return nil
+	}
+	flags := r.byte()
+	m.hasMemory = true
+	m.memMin = r.u32()
+	m.memMax = m.maxPages
+	switch flags {
+	case 0:
+	case 1:
+		if max := r.u32(); max < m.memMax {
+			m.memMax = max
+		}
+	default:
+		return fmt.Errorf("wasm: memory flags 0x%x are not supported", flags)
+	}
+	if m.memMin > m.memMax {
+		return fmt.Errorf("wasm: memory of %d pages exceeds the limit of %d", m.memMin, m.memMax)
+	}
+	return nil
+}
+
+func (m *Module) readGlobals(r *reader) error {
+	n := r.u32()
+	if n > MaxFunctions {
+		return fmt.Errorf("wasm: %d globals exceed %d", n, MaxFunctions)
+	}
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		t := ValueType(r.byte())
+		if !t.valid() {
+			return fmt.Errorf("wasm: global of unknown type 0x%x", byte(t))
+		}
+		mut := r.byte()
+		if mut > 1 {
+			return fmt.Errorf("wasm: global mutability 0x%x", mut)
+		}
+		v, err := r.constExpr(t)
+		if err != nil {
+			return err
+		}
+		m.globals = append(m.globals, global{typ: t, mutable: mut == 1, value: v})
+	}
+	return nil
+}
+
+func (m *Module) readExports(r *reader) error {
+	n := r.u32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		name := r.name()
+		kind := r.byte()
+		idx := r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		if kind == 0 {
+			if _, ok := m.exports[name]; ok {
+				return fmt.Errorf("wasm: export %s twice", name)
+			}
+			m.exports[name] = int(idx)
+		}
+	}
+	return nil
+}
+
+func (m *Module) readCode(r *reader, funcTypes []uint32) error {
+	n := r.u32()
+	if int(n) != len(funcTypes) {
+		return fmt.Errorf("wasm: %d functions declared but %d bodies", len(funcTypes), n)
+	}
+	// the bodies call each other, so every signature is known before
+	for _, t := range funcTypes {
+		m.funcs = append(m.funcs, &function{typ: m.types[t]})
+	}
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		size := r.u32()
+		if r.err != nil || int(size) > len(r.b)-r.off {
+			return fmt.Errorf("wasm: body %d is truncated", i)
+		}
+		body := &reader{b: r.b[:r.off+int(size)], off: r.off}
+		if err := m.compileFunction(m.funcs[i], body); err != nil {
+			return fmt.Errorf("wasm: function %d: %v", i, err)
+		}
+		m.codeLength += len(m.funcs[i].code)
+		r.off += int(size)
+	}
+	return nil
+}
+
+func (m *Module) readData(r *reader) error {
+	n := r.u32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		mode := r.u32()
+		switch mode {
+		case 0:
+		case 2:
+			if mem := r.u32(); mem != 0 {
+				return fmt.Errorf("wasm: data of unknown memory %d", mem)
+			}
+		default:
+			return fmt.Errorf("wasm: passive data segments are not supported")
+		}
+		offset, err := r.constExpr(I32)
+		if err != nil {
+			return err
+		}
+		size := r.u32()
+		data := r.bytes(int(size))
+		if r.err != nil {
+			return r.err
+		}
+		if !m.hasMemory || uint64(uint32(offset))+uint64(size) > uint64(m.memMin)*PageSize {
+			return fmt.Errorf("wasm: data segment %d out of memory", i)
+		}
+		m.data = append(m.data, dataSegment{offset: uint32(offset), data: data})
+	}
+	return nil
+}
+
+func (t ValueType) valid() bool {
+	return t == I32 || t == I64 || t == F32 || t == F64
+}
+
+// reader decodes a binary module, the first error sticks and makes the
+// following reads return zero values
+type reader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *reader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("wasm: "+format, args...)
+	}
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.off >= len(r.b) {
+		r.fail("unexpected end at %d", r.off)
+		return 0
+	}
+	b := r.b[r.off]
+	r.off++
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b)-r.off {
+		r.fail("unexpected end at %d", r.off)
+		return nil
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+// uleb reads an unsigned LEB128 of at most bits
+func (r *reader) uleb(bits uint) uint64 {
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		b := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if shift >= bits || (shift+7 > bits && b&0x7f>>(bits-shift) != 0) {
+			r.fail("integer too large at %d", r.off)
+			return 0
+		}
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+}
+
+// sleb reads a signed LEB128 of at most bits
+func (r *reader) sleb(bits uint) int64 {
+	var v int64
+	shift := uint(0)
+	for {
+		b := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if shift >= bits {
+			r.fail("integer too large at %d", r.off)
+			return 0
+		}
+		v |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				v |= -1 << shift
+			}
+			if shift > bits && bits < 64 {
+				// the unused bits must extend the sign
+				if v < -(1<<(bits-1)) || v >= 1<<(bits-1) {
+					r.fail("integer too large at %d", r.off)
+					return 0
+				}
+			}
+			return v
+		}
+	}
+}
+
+func (r *reader) u32() uint32 {
+	return uint32(r.uleb(32))
+}
+
+func (r *reader) name() string {
+	n := r.u32()
+	b := r.bytes(int(n))
+	if r.err == nil && !utf8.Valid(b) {
+		r.fail("name is not utf8")
+	}
+	return string(b)
+}
+
+func (r *reader) valueTypes() ([]ValueType, error) {
+	n := r.u32()
+	if n > MaxLocals {
+		return nil, fmt.Errorf("wasm: %d values exceed %d", n, MaxLocals)
+	}
+	types := make([]ValueType, 0, n)
+	for i := uint32(0); i < n; i++ {
+		t := ValueType(r.byte())
+		if r.err != nil {
+			return nil, r.err
+		}
+		if !t.valid() {
+			return nil, fmt.Errorf("wasm: unknown value type 0x%x", byte(t))
+		}
+		types = append(types, t)
+	}
+	return types, r.err
+}
+
+// constExpr reads the constant of an initializer ended by end
+func (r *reader) constExpr(t ValueType) (uint64, error) {
+	op := r.byte()
+	var v uint64
+	switch {
+	case op == 0x41 && t == I32:
+		v = uint64(uint32(int32(r.sleb(32))))
+	case op == 0x42 && t == I64:
+		v = uint64(r.sleb(64))
+	case op == 0x43 && t == F32:
+		v = uint64(binary.LittleEndian.Uint32(r.bytes(4)))
+	case op == 0x44 && t == F64:
+		v = binary.LittleEndian.Uint64(r.bytes(8))
+	default:
+		if r.err != nil {
+			return 0, r.err
+		}
+		return 0, fmt.Errorf("wasm: initializer 0x%x of %v is not a constant", op, t)
+	}
+	if end := r.byte(); r.err == nil && end != 0x0b {
+		return 0, fmt.Errorf("wasm: initializer is not a single constant")
+	}
+	return v, r.err
+}
@@ -0,0 +1,731 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// The cases below are assertions of the WebAssembly spec test suite
+// (i32.wast, i64.wast, f32.wast, f64.wast, f32_cmp.wast, f64_cmp.wast,
+// conversions.wast, int_exprs.wast, memory.wast, address.wast and
+// sign-extension/nontrapping-float-to-int of the proposals), at least one
+// for every instruction Compile accepts, TestSpecCoverage keeps it so.
+
+func s32(v int32) uint64 { return uint64(uint32(v)) }
+
+func s64(v int64) uint64 { return uint64(v) }
+
+var (
+	inf32     = fromF32(float32(math.Inf(1)))
+	negInf32  = fromF32(float32(math.Inf(-1)))
+	nan32     = uint64(0x7fc00000)
+	negNaN32  = uint64(0xffc00000)
+	negZero32 = uint64(0x80000000)
+	inf64     = fromF64(math.Inf(1))
+	negInf64  = fromF64(math.Inf(-1))
+	nan64     = uint64(0x7ff8000000000000)
+	negNaN64  = uint64(0xfff8000000000000)
+	negZero64 = uint64(0x8000000000000000)
+)
+
+// specCase asserts the result of op on args, or a trap
+type specCase struct {
+	op   []byte
+	args []uint64
+	want uint64
+	trap bool
+}
+
+func ret(op []byte, want uint64, args ...uint64) specCase {
+	return specCase{op: op, args: args, want: want}
+}
+
+func trapped(op []byte, args ...uint64) specCase {
+	return specCase{op: op, args: args, trap: true}
+}
+
+func op1(op byte) []byte { return []byte{op} }
+
+func sat(sub byte) []byte { return []byte{0xfc, sub} }
+
+var numericSpec = []specCase{
+	// i32.wast
+	ret(op1(0x45), 1, 0), ret(op1(0x45), 0, 1), ret(op1(0x45), 0, 0x80000000), ret(op1(0x45), 0, 0x7fffffff),
+	ret(op1(0x46), 1, 0, 0), ret(op1(0x46), 0, s32(-1), 1), ret(op1(0x46), 1, 0x80000000, 0x80000000), ret(op1(0x46), 0, 0x80000000, 0x7fffffff),
+	ret(op1(0x47), 0, 0, 0), ret(op1(0x47), 1, s32(-1), 1), ret(op1(0x47), 1, 0x80000000, 0x7fffffff),
+	ret(op1(0x48), 1, s32(-1), 1), ret(op1(0x48), 1, 0x80000000, 0), ret(op1(0x48), 0, 1, s32(-1)), ret(op1(0x48), 0, 0, 0),
+	ret(op1(0x49), 0, s32(-1), 1), ret(op1(0x49), 1, 1, s32(-1)), ret(op1(0x49), 1, 0, 0x80000000), ret(op1(0x49), 0, 0, 0),
+	ret(op1(0x4a), 1, 1, s32(-1)), ret(op1(0x4a), 0, 0x80000000, 0x7fffffff), ret(op1(0x4a), 0, 0, 0),
+	ret(op1(0x4b), 1, s32(-1), 1), ret(op1(0x4b), 1, 0x80000000, 0x7fffffff), ret(op1(0x4b), 0, 0, 0),
+	ret(op1(0x4c), 1, 1, 1), ret(op1(0x4c), 1, s32(-1), 0), ret(op1(0x4c), 0, 0, s32(-1)),
+	ret(op1(0x4d), 1, 1, 1), ret(op1(0x4d), 1, 0, s32(-1)), ret(op1(0x4d), 0, s32(-1), 0),
+	ret(op1(0x4e), 1, 1, 1), ret(op1(0x4e), 1, 0, s32(-1)), ret(op1(0x4e), 0, s32(-1), 0),
+	ret(op1(0x4f), 1, 1, 1), ret(op1(0x4f), 1, s32(-1), 0), ret(op1(0x4f), 0, 0, s32(-1)),
+	ret(op1(0x67), 0, 0xffffffff), ret(op1(0x67), 32, 0), ret(op1(0x67), 16, 0x00008000), ret(op1(0x67), 24, 0xff),
+	ret(op1(0x67), 0, 0x80000000), ret(op1(0x67), 31, 1), ret(op1(0x67), 30, 2), ret(op1(0x67), 1, 0x7fffffff),
+	ret(op1(0x68), 0, s32(-1)), ret(op1(0x68), 32, 0), ret(op1(0x68), 15, 0x00008000), ret(op1(0x68), 16, 0x00010000),
+	ret(op1(0x68), 31, 0x80000000), ret(op1(0x68), 0, 0x7fffffff),
+	ret(op1(0x69), 32, s32(-1)), ret(op1(0x69), 0, 0), ret(op1(0x69), 1, 0x00008000), ret(op1(0x69), 2, 0x80008000),
+	ret(op1(0x69), 31, 0x7fffffff), ret(op1(0x69), 16, 0xaaaaaaaa), ret(op1(0x69), 16, 0x55555555), ret(op1(0x69), 24, 0xdeadbeef),
+	ret(op1(0x6a), 2, 1, 1), ret(op1(0x6a), 1, 1, 0), ret(op1(0x6a), s32(-2), s32(-1), s32(-1)), ret(op1(0x6a), 0, s32(-1), 1),
+	ret(op1(0x6a), 0x80000000, 0x7fffffff, 1), ret(op1(0x6a), 0x7fffffff, 0x80000000, s32(-1)),
+	ret(op1(0x6a), 0, 0x80000000, 0x80000000), ret(op1(0x6a), 0x40000000, 0x3fffffff, 1),
+	ret(op1(0x6b), 0, 1, 1), ret(op1(0x6b), 1, 1, 0), ret(op1(0x6b), 0, s32(-1), s32(-1)),
+	ret(op1(0x6b), 0x80000000, 0x7fffffff, s32(-1)), ret(op1(0x6b), 0x7fffffff, 0x80000000, 1),
+	ret(op1(0x6b), 0, 0x80000000, 0x80000000), ret(op1(0x6b), 0x40000000, 0x3fffffff, s32(-1)),
+	ret(op1(0x6c), 1, 1, 1), ret(op1(0x6c), 0, 1, 0), ret(op1(0x6c), 1, s32(-1), s32(-1)), ret(op1(0x6c), 0, 0x10000000, 4096),
+	ret(op1(0x6c), 0, 0x80000000, 0), ret(op1(0x6c), 0x80000000, 0x80000000, s32(-1)),
+	ret(op1(0x6c), 0x80000001, 0x7fffffff, s32(-1)), ret(op1(0x6c), 0x358e7470, 0x01234567, 0x76543210), ret(op1(0x6c), 1, 0x7fffffff, 0x7fffffff),
+	trapped(op1(0x6d), 1, 0), trapped(op1(0x6d), 0, 0), trapped(op1(0x6d), 0x80000000, s32(-1)),
+	ret(op1(0x6d), 0, 0, 1), ret(op1(0x6d), 0xc0000000, 0x80000000, 2), ret(op1(0x6d), 0xffdf3b65, 0x80000001, 1000),
+	ret(op1(0x6d), 2, 5, 2), ret(op1(0x6d), s32(-2), s32(-5), 2), ret(op1(0x6d), s32(-2), 5, s32(-2)), ret(op1(0x6d), 2, s32(-5), s32(-2)),
+	ret(op1(0x6d), 3, 7, 2), ret(op1(0x6d), s32(-3), s32(-7), 2), ret(op1(0x6d), s32(-3), 7, s32(-2)), ret(op1(0x6d), 3, s32(-7), s32(-2)),
+	ret(op1(0x6d), 2, 11, 5), ret(op1(0x6d), 2, 17, 7),
+	trapped(op1(0x6e), 1, 0), trapped(op1(0x6e), 0, 0),
+	ret(op1(0x6e), 1, 1, 1), ret(op1(0x6e), 1, s32(-1), s32(-1)), ret(op1(0x6e), 0, 0x80000000, s32(-1)),
+	ret(op1(0x6e), 0x40000000, 0x80000000, 2), ret(op1(0x6e), 0x8fef, 0x8ff00ff0, 0x10001), ret(op1(0x6e), 0x20c49b, 0x80000001, 1000),
+	ret(op1(0x6e), 2, 5, 2), ret(op1(0x6e), 0x7ffffffd, s32(-5), 2), ret(op1(0x6e), 0, 5, s32(-2)), ret(op1(0x6e), 0, s32(-5), s32(-2)),
+	ret(op1(0x6e), 3, 7, 2), ret(op1(0x6e), 2, 11, 5), ret(op1(0x6e), 2, 17, 7),
+	trapped(op1(0x6f), 1, 0), trapped(op1(0x6f), 0, 0),
+	ret(op1(0x6f), 0, 0x7fffffff, s32(-1)), ret(op1(0x6f), 0, 1, 1), ret(op1(0x6f), 0, 0x80000000, s32(-1)),
+	ret(op1(0x6f), 0, 0x80000000, 2), ret(op1(0x6f), s32(-647), 0x80000001, 1000),
+	ret(op1(0x6f), 1, 5, 2), ret(op1(0x6f), s32(-1), s32(-5), 2), ret(op1(0x6f), 1, 5, s32(-2)), ret(op1(0x6f), s32(-1), s32(-5), s32(-2)),
+	ret(op1(0x6f), 1, 7, 3), ret(op1(0x6f), s32(-1), s32(-7), 3), ret(op1(0x6f), 1, 7, s32(-3)), ret(op1(0x6f), s32(-1), s32(-7), s32(-3)),
+	ret(op1(0x6f), 1, 11, 5), ret(op1(0x6f), 3, 17, 7),
+	trapped(op1(0x70), 1, 0), trapped(op1(0x70), 0, 0),
+	ret(op1(0x70), 0, 1, 1), ret(op1(0x70), 0, s32(-1), s32(-1)), ret(op1(0x70), 0x80000000, 0x80000000, s32(-1)),
+	ret(op1(0x70), 0, 0x80000000, 2), ret(op1(0x70), 0x8001, 0x8ff00ff0, 0x10001), ret(op1(0x70), 649, 0x80000001, 1000),
+	ret(op1(0x70), 1, 5, 2), ret(op1(0x70), 1, s32(-5), 2), ret(op1(0x70), 5, 5, s32(-2)), ret(op1(0x70), s32(-5), s32(-5), s32(-2)),
+	ret(op1(0x70), 1, 7, 2), ret(op1(0x70), 1, 11, 5), ret(op1(0x70), 3, 17, 7),
+	ret(op1(0x71), 0, 1, 0), ret(op1(0x71), 0, 0, 1), ret(op1(0x71), 1, 1, 1), ret(op1(0x71), 0, 0x7fffffff, 0x80000000),
+	ret(op1(0x71), 0x7fffffff, 0x7fffffff, s32(-1)), ret(op1(0x71), 0xf0f0f0f0, 0xf0f0ffff, 0xfffff0f0), ret(op1(0x71), 0xffffffff, 0xffffffff, 0xffffffff),
+	ret(op1(0x72), 1, 1, 0), ret(op1(0x72), 1, 1, 1), ret(op1(0x72), s32(-1), 0x7fffffff, 0x80000000),
+	ret(op1(0x72), 0x80000000, 0x80000000, 0), ret(op1(0x72), 0xffffffff, 0xf0f0ffff, 0xfffff0f0),
+	ret(op1(0x73), 1, 1, 0), ret(op1(0x73), 0, 1, 1), ret(op1(0x73), s32(-1), 0x7fffffff, 0x80000000),
+	ret(op1(0x73), 0x7fffffff, s32(-1), 0x80000000), ret(op1(0x73), 0x80000000, s32(-1), 0x7fffffff), ret(op1(0x73), 0x0f0f0f0f, 0xf0f0ffff, 0xfffff0f0),
+	ret(op1(0x74), 2, 1, 1), ret(op1(0x74), 1, 1, 0), ret(op1(0x74), 0xfffffffe, 0x7fffffff, 1), ret(op1(0x74), 0xfffffffe, 0xffffffff, 1),
+	ret(op1(0x74), 0, 0x80000000, 1), ret(op1(0x74), 0x80000000, 0x40000000, 1), ret(op1(0x74), 0x80000000, 1, 31),
+	ret(op1(0x74), 1, 1, 32), ret(op1(0x74), 2, 1, 33), ret(op1(0x74), 0x80000000, 1, s32(-1)), ret(op1(0x74), 0x80000000, 1, 0x7fffffff),
+	ret(op1(0x75), 0, 1, 1), ret(op1(0x75), 1, 1, 0), ret(op1(0x75), s32(-1), s32(-1), 1), ret(op1(0x75), 0x3fffffff, 0x7fffffff, 1),
+	ret(op1(0x75), 0xc0000000, 0x80000000, 1), ret(op1(0x75), 0x20000000, 0x40000000, 1), ret(op1(0x75), 1, 1, 32),
+	ret(op1(0x75), 0, 1, 33), ret(op1(0x75), 0, 1, s32(-1)), ret(op1(0x75), s32(-1), 0x80000000, 31), ret(op1(0x75), s32(-1), s32(-1), 32),
+	ret(op1(0x76), 0, 1, 1), ret(op1(0x76), 1, 1, 0), ret(op1(0x76), 0x7fffffff, s32(-1), 1), ret(op1(0x76), 0x3fffffff, 0x7fffffff, 1),
+	ret(op1(0x76), 0x40000000, 0x80000000, 1), ret(op1(0x76), 1, 1, 32), ret(op1(0x76), 0, 1, 33), ret(op1(0x76), 1, 0x80000000, 31),
+	ret(op1(0x76), 1, s32(-1), s32(-1)), ret(op1(0x76), s32(-1), s32(-1), 32),
+	ret(op1(0x77), 2, 1, 1), ret(op1(0x77), 1, 1, 0), ret(op1(0x77), s32(-1), s32(-1), 1), ret(op1(0x77), 1, 1, 32),
+	ret(op1(0x77), 0x579b30ed, 0xabcd9876, 1), ret(op1(0x77), 0xe00dc00f, 0xfe00dc00, 4), ret(op1(0x77), 0x183a5c76, 0xb0c1d2e3, 5),
+	ret(op1(0x77), 0x00100000, 0x00008000, 37), ret(op1(0x77), 0x183a5c76, 0xb0c1d2e3, 0xff05), ret(op1(0x77), 0x80000000, 1, 31),
+	ret(op1(0x78), 0x80000000, 1, 1), ret(op1(0x78), 1, 1, 0), ret(op1(0x78), s32(-1), s32(-1), 1), ret(op1(0x78), 1, 1, 32),
+	ret(op1(0x78), 0x7f806600, 0xff00cc00, 1), ret(op1(0x78), 0x00008000, 0x00080000, 4), ret(op1(0x78), 0x1d860e97, 0xb0c1d2e3, 5),
+	ret(op1(0x78), 0x00000400, 0x00008000, 37), ret(op1(0x78), 0x1d860e97, 0xb0c1d2e3, 0xff05), ret(op1(0x78), 2, 1, 31),
+
+	// i64.wast
+	ret(op1(0x50), 1, 0), ret(op1(0x50), 0, 1), ret(op1(0x50), 0, 0x8000000000000000), ret(op1(0x50), 0, 0x7fffffffffffffff),
+	ret(op1(0x51), 1, 0, 0), ret(op1(0x51), 0, s64(-1), 1), ret(op1(0x51), 1, 0x8000000000000000, 0x8000000000000000),
+	ret(op1(0x51), 0, 0x8000000000000000, 0x7fffffffffffffff), ret(op1(0x51), 0, 0x100000000, 0),
+	ret(op1(0x52), 0, 0, 0), ret(op1(0x52), 1, s64(-1), 1), ret(op1(0x52), 1, 0x8000000000000000, 0x7fffffffffffffff),
+	ret(op1(0x53), 1, s64(-1), 1), ret(op1(0x53), 1, 0x8000000000000000, 0), ret(op1(0x53), 0, 1, s64(-1)), ret(op1(0x53), 0, 0, 0),
+	ret(op1(0x54), 0, s64(-1), 1), ret(op1(0x54), 1, 1, s64(-1)), ret(op1(0x54), 1, 0, 0x8000000000000000), ret(op1(0x54), 0, 0, 0),
+	ret(op1(0x55), 1, 1, s64(-1)), ret(op1(0x55), 0, 0x8000000000000000, 0x7fffffffffffffff), ret(op1(0x55), 0, 0, 0),
+	ret(op1(0x56), 1, s64(-1), 1), ret(op1(0x56), 1, 0x8000000000000000, 0x7fffffffffffffff), ret(op1(0x56), 0, 0, s64(-1)),
+	ret(op1(0x57), 1, 1, 1), ret(op1(0x57), 1, s64(-1), 0), ret(op1(0x57), 0, 0, s64(-1)),
+	ret(op1(0x58), 1, 1, 1), ret(op1(0x58), 1, 0, s64(-1)), ret(op1(0x58), 0, s64(-1), 0),
+	ret(op1(0x59), 1, 1, 1), ret(op1(0x59), 1, 0, s64(-1)), ret(op1(0x59), 0, s64(-1), 0),
+	ret(op1(0x5a), 1, 1, 1), ret(op1(0x5a), 1, s64(-1), 0), ret(op1(0x5a), 0, 0, s64(-1)),
+	ret(op1(0x79), 0, 0xffffffffffffffff), ret(op1(0x79), 64, 0), ret(op1(0x79), 48, 0x00008000), ret(op1(0x79), 56, 0xff),
+	ret(op1(0x79), 0, 0x8000000000000000), ret(op1(0x79), 63, 1), ret(op1(0x79), 62, 2), ret(op1(0x79), 1, 0x7fffffffffffffff),
+	ret(op1(0x7a), 0, s64(-1)), ret(op1(0x7a), 64, 0), ret(op1(0x7a), 15, 0x00008000), ret(op1(0x7a), 16, 0x00010000),
+	ret(op1(0x7a), 63, 0x8000000000000000), ret(op1(0x7a), 0, 0x7fffffffffffffff),
+	ret(op1(0x7b), 64, s64(-1)), ret(op1(0x7b), 0, 0), ret(op1(0x7b), 1, 0x00008000), ret(op1(0x7b), 4, 0x8000800080008000),
+	ret(op1(0x7b), 63, 0x7fffffffffffffff), ret(op1(0x7b), 32, 0xaaaaaaaa55555555), ret(op1(0x7b), 32, 0x99999999aaaaaaaa),
+	ret(op1(0x7b), 48, 0xdeadbeefdeadbeef),
+	ret(op1(0x7c), 2, 1, 1), ret(op1(0x7c), s64(-2), s64(-1), s64(-1)), ret(op1(0x7c), 0x8000000000000000, 0x7fffffffffffffff, 1),
+	ret(op1(0x7c), 0x7fffffffffffffff, 0x8000000000000000, s64(-1)), ret(op1(0x7c), 0, 0x8000000000000000, 0x8000000000000000),
+	ret(op1(0x7c), 0x40000000, 0x3fffffff, 1),
+	ret(op1(0x7d), 0, 1, 1), ret(op1(0x7d), 0x8000000000000000, 0x7fffffffffffffff, s64(-1)),
+	ret(op1(0x7d), 0x7fffffffffffffff, 0x8000000000000000, 1), ret(op1(0x7d), 0, 0x8000000000000000, 0x8000000000000000),
+	ret(op1(0x7e), 1, 1, 1), ret(op1(0x7e), 0, 0x1000000000000000, 4096), ret(op1(0x7e), 0x8000000000000000, 0x8000000000000000, s64(-1)),
+	ret(op1(0x7e), 0x8000000000000001, 0x7fffffffffffffff, s64(-1)), ret(op1(0x7e), 0x2236d88fe5618cf0, 0x0123456789abcdef, 0xfedcba9876543210),
+	ret(op1(0x7e), 1, 0x7fffffffffffffff, 0x7fffffffffffffff),
+	trapped(op1(0x7f), 1, 0), trapped(op1(0x7f), 0, 0), trapped(op1(0x7f), 0x8000000000000000, s64(-1)),
+	ret(op1(0x7f), 0xc000000000000000, 0x8000000000000000, 2), ret(op1(0x7f), 0xffdf3b645a1cac09, 0x8000000000000001, 1000),
+	ret(op1(0x7f), 2, 5, 2), ret(op1(0x7f), s64(-2), s64(-5), 2), ret(op1(0x7f), s64(-2), 5, s64(-2)), ret(op1(0x7f), 2, s64(-5), s64(-2)),
+	ret(op1(0x7f), 3, 7, 2), ret(op1(0x7f), s64(-3), s64(-7), 2), ret(op1(0x7f), s64(-3), 7, s64(-2)), ret(op1(0x7f), 3, s64(-7), s64(-2)),
+	trapped(op1(0x80), 1, 0), trapped(op1(0x80), 0, 0),
+	ret(op1(0x80), 0, 0x8000000000000000, s64(-1)), ret(op1(0x80), 0x4000000000000000, 0x8000000000000000, 2),
+	ret(op1(0x80), 0x7fffffffffffffff, s64(-1), 2), ret(op1(0x80), 0x8ff00fef, 0x8ff00ff00ff00ff0, 0x100000001),
+	ret(op1(0x80), 0x20c49ba5e353f7, 0x8000000000000001, 1000), ret(op1(0x80), 0x7ffffffffffffffd, s64(-5), 2), ret(op1(0x80), 0, 5, s64(-2)),
+	trapped(op1(0x81), 1, 0), trapped(op1(0x81), 0, 0),
+	ret(op1(0x81), 0, 0x7fffffffffffffff, s64(-1)), ret(op1(0x81), 0, 0x8000000000000000, s64(-1)), ret(op1(0x81), 0, 0x8000000000000000, 2),
+	ret(op1(0x81), s64(-807), 0x8000000000000001, 1000), ret(op1(0x81), 1, 5, 2), ret(op1(0x81), s64(-1), s64(-5), 2),
+	ret(op1(0x81), 1, 5, s64(-2)), ret(op1(0x81), s64(-1), s64(-5), s64(-2)), ret(op1(0x81), s64(-1), s64(-7), 3), ret(op1(0x81), 1, 7, s64(-3)),
+	trapped(op1(0x82), 1, 0), trapped(op1(0x82), 0, 0),
+	ret(op1(0x82), 0x8000000000000000, 0x8000000000000000, s64(-1)), ret(op1(0x82), 0, 0x8000000000000000, 2),
+	ret(op1(0x82), 0x80000001, 0x8ff00ff00ff00ff0, 0x100000001), ret(op1(0x82), 809, 0x8000000000000001, 1000),
+	ret(op1(0x82), 1, s64(-5), 2), ret(op1(0x82), s64(-5), s64(-5), s64(-2)), ret(op1(0x82), 3, 17, 7),
+	ret(op1(0x83), 0, 1, 0), ret(op1(0x83), 1, 1, 1), ret(op1(0x83), 0, 0x7fffffffffffffff, 0x8000000000000000),
+	ret(op1(0x83), 0xf0f0f0f0, 0xf0f0ffff, 0xfffff0f0), ret(op1(0x83), 0xffffffffffffffff, 0xffffffffffffffff, 0xffffffffffffffff),
+	ret(op1(0x84), 1, 1, 0), ret(op1(0x84), s64(-1), 0x7fffffffffffffff, 0x8000000000000000), ret(op1(0x84), 0xffffffff, 0xf0f0ffff, 0xfffff0f0),
+	ret(op1(0x85), 0, 1, 1), ret(op1(0x85), s64(-1), 0x7fffffffffffffff, 0x8000000000000000),
+	ret(op1(0x85), 0x7fffffffffffffff, s64(-1), 0x8000000000000000), ret(op1(0x85), 0x0f0f0f0f, 0xf0f0ffff, 0xfffff0f0),
+	ret(op1(0x86), 2, 1, 1), ret(op1(0x86), 1, 1, 0), ret(op1(0x86), 0xfffffffffffffffe, 0x7fffffffffffffff, 1),
+	ret(op1(0x86), 0, 0x8000000000000000, 1), ret(op1(0x86), 0x8000000000000000, 1, 63), ret(op1(0x86), 1, 1, 64),
+	ret(op1(0x86), 2, 1, 65), ret(op1(0x86), 0x8000000000000000, 1, s64(-1)), ret(op1(0x86), 0x8000000000000000, 1, 0x7fffffffffffffff),
+	ret(op1(0x87), 0, 1, 1), ret(op1(0x87), s64(-1), s64(-1), 1), ret(op1(0x87), 0x3fffffffffffffff, 0x7fffffffffffffff, 1),
+	ret(op1(0x87), 0xc000000000000000, 0x8000000000000000, 1), ret(op1(0x87), 1, 1, 64), ret(op1(0x87), 0, 1, 65),
+	ret(op1(0x87), s64(-1), 0x8000000000000000, 63), ret(op1(0x87), s64(-1), s64(-1), 64),
+	ret(op1(0x88), 0, 1, 1), ret(op1(0x88), 0x7fffffffffffffff, s64(-1), 1), ret(op1(0x88), 0x4000000000000000, 0x8000000000000000, 1),
+	ret(op1(0x88), 1, 1, 64), ret(op1(0x88), 0, 1, 65), ret(op1(0x88), 1, 0x8000000000000000, 63), ret(op1(0x88), 1, s64(-1), s64(-1)),
+	ret(op1(0x89), 2, 1, 1), ret(op1(0x89), 1, 1, 0), ret(op1(0x89), s64(-1), s64(-1), 1), ret(op1(0x89), 1, 1, 64),
+	ret(op1(0x89), 0x579b30ec048d159d, 0xabcd987602468ace, 1), ret(op1(0x89), 0xe000000dc000000f, 0xfe000000dc000000, 4),
+	ret(op1(0x89), 0x013579a2469deacf, 0xabcd1234ef567809, 53), ret(op1(0x89), 0x55e891a77ab3c04e, 0xabd1234ef567809c, 63),
+	ret(op1(0x89), 0x8000000000000000, 1, 63),
+	ret(op1(0x8a), 0x8000000000000000, 1, 1), ret(op1(0x8a), 1, 1, 0), ret(op1(0x8a), s64(-1), s64(-1), 1), ret(op1(0x8a), 1, 1, 64),
+	ret(op1(0x8a), 0x55e6cc3b01234567, 0xabcd987602468ace, 1), ret(op1(0x8a), 0x0fe000000dc00000, 0xfe000000dc000000, 4),
+	ret(op1(0x8a), 0x6891a77ab3c04d5e, 0xabcd1234ef567809, 53), ret(op1(0x8a), 0x57a2469deacf0139, 0xabd1234ef567809c, 63),
+	ret(op1(0x8a), 2, 1, 63),
+
+	// f32_cmp.wast and f64_cmp.wast
+	ret(op1(0x5b), 1, negZero32, 0), ret(op1(0x5b), 1, fromF32(1), fromF32(1)), ret(op1(0x5b), 0, fromF32(1), fromF32(-1)),
+	ret(op1(0x5b), 0, nan32, nan32), ret(op1(0x5b), 0, fromF32(1), nan32), ret(op1(0x5b), 1, inf32, inf32),
+	ret(op1(0x5c), 0, negZero32, 0), ret(op1(0x5c), 1, nan32, nan32), ret(op1(0x5c), 1, fromF32(1), fromF32(2)),
+	ret(op1(0x5d), 0, negZero32, 0), ret(op1(0x5d), 1, fromF32(-1), fromF32(1)), ret(op1(0x5d), 0, fromF32(1), nan32),
+	ret(op1(0x5d), 0, nan32, fromF32(1)), ret(op1(0x5d), 1, negInf32, inf32),
+	ret(op1(0x5e), 0, 0, negZero32), ret(op1(0x5e), 1, fromF32(1), fromF32(-1)), ret(op1(0x5e), 0, nan32, fromF32(1)),
+	ret(op1(0x5f), 1, negZero32, 0), ret(op1(0x5f), 1, fromF32(-1), fromF32(1)), ret(op1(0x5f), 0, nan32, nan32),
+	ret(op1(0x60), 1, 0, negZero32), ret(op1(0x60), 0, fromF32(-1), fromF32(1)), ret(op1(0x60), 0, nan32, fromF32(1)),
+	ret(op1(0x61), 1, negZero64, 0), ret(op1(0x61), 1, fromF64(1), fromF64(1)), ret(op1(0x61), 0, nan64, nan64),
+	ret(op1(0x61), 0, fromF64(1), nan64), ret(op1(0x61), 1, inf64, inf64),
+	ret(op1(0x62), 0, negZero64, 0), ret(op1(0x62), 1, nan64, nan64), ret(op1(0x62), 1, fromF64(1), fromF64(2)),
+	ret(op1(0x63), 0, negZero64, 0), ret(op1(0x63), 1, fromF64(-1), fromF64(1)), ret(op1(0x63), 0, fromF64(1), nan64),
+	ret(op1(0x63), 1, negInf64, inf64),
+	ret(op1(0x64), 0, 0, negZero64), ret(op1(0x64), 1, fromF64(1), fromF64(-1)), ret(op1(0x64), 0, nan64, fromF64(1)),
+	ret(op1(0x65), 1, negZero64, 0), ret(op1(0x65), 1, fromF64(-1), fromF64(1)), ret(op1(0x65), 0, nan64, nan64),
+	ret(op1(0x66), 1, 0, negZero64), ret(op1(0x66), 0, fromF64(-1), fromF64(1)), ret(op1(0x66), 0, nan64, fromF64(1)),
+
+	// f32.wast
+	ret(op1(0x8b), fromF32(1.5), fromF32(-1.5)), ret(op1(0x8b), 0, negZero32), ret(op1(0x8b), inf32, negInf32),
+	ret(op1(0x8b), nan32, negNaN32), ret(op1(0x8b), 0x7fa00000, 0xffa00000),
+	ret(op1(0x8c), fromF32(-1.5), fromF32(1.5)), ret(op1(0x8c), negZero32, 0), ret(op1(0x8c), 0, negZero32),
+	ret(op1(0x8c), negNaN32, nan32), ret(op1(0x8c), 0x7fa00000, 0xffa00000),
+	ret(op1(0x8d), negZero32, fromF32(-0.5)), ret(op1(0x8d), fromF32(2), fromF32(1.5)), ret(op1(0x8d), fromF32(-1), fromF32(-1.5)),
+	ret(op1(0x8d), fromF32(1), 0x00000001), ret(op1(0x8d), inf32, inf32), ret(op1(0x8d), nan32, nan32), ret(op1(0x8d), negZero32, negZero32),
+	ret(op1(0x8e), fromF32(-1), fromF32(-0.5)), ret(op1(0x8e), fromF32(1), fromF32(1.5)), ret(op1(0x8e), 0, fromF32(0.5)),
+	ret(op1(0x8e), negZero32, negZero32), ret(op1(0x8e), fromF32(-1), 0x80000001), ret(op1(0x8e), negInf32, negInf32),
+	ret(op1(0x8f), fromF32(-1), fromF32(-1.5)), ret(op1(0x8f), fromF32(1), fromF32(1.5)), ret(op1(0x8f), negZero32, fromF32(-0.5)),
+	ret(op1(0x8f), 0, fromF32(0.5)), ret(op1(0x8f), nan32, nan32),
+	ret(op1(0x90), 0, fromF32(0.5)), ret(op1(0x90), negZero32, fromF32(-0.5)), ret(op1(0x90), fromF32(2), fromF32(1.5)),
+	ret(op1(0x90), fromF32(2), fromF32(2.5)), ret(op1(0x90), fromF32(-4), fromF32(-3.5)), ret(op1(0x90), fromF32(4), fromF32(4.5)),
+	ret(op1(0x90), 0x4b000001, 0x4b000001), ret(op1(0x90), 0xcb000001, 0xcb000001), ret(op1(0x90), 0x4b800000, 0x4b800000),
+	ret(op1(0x91), fromF32(2), fromF32(4)), ret(op1(0x91), nan32, fromF32(-1)), ret(op1(0x91), negZero32, negZero32),
+	ret(op1(0x91), inf32, inf32), ret(op1(0x91), 0x3fb504f3, fromF32(2)), ret(op1(0x91), nan32, negInf32),
+	ret(op1(0x92), fromF32(3), fromF32(1), fromF32(2)), ret(op1(0x92), nan32, inf32, negInf32), ret(op1(0x92), 2, 1, 1),
+	ret(op1(0x92), 0x4b800000, 0x4b800000, fromF32(1)), ret(op1(0x92), 0x4b800002, 0x4b800001, fromF32(1)),
+	ret(op1(0x92), negZero32, negZero32, negZero32), ret(op1(0x92), 0, negZero32, 0), ret(op1(0x92), inf32, 0x7f7fffff, 0x7f7fffff),
+	ret(op1(0x92), nan32, nan32, fromF32(1)),
+	ret(op1(0x93), nan32, inf32, inf32), ret(op1(0x93), fromF32(-1), fromF32(1), fromF32(2)), ret(op1(0x93), 0, 0, 0),
+	ret(op1(0x93), negZero32, negZero32, 0), ret(op1(0x93), 0, negZero32, negZero32), ret(op1(0x93), negInf32, negInf32, inf32),
+	ret(op1(0x94), nan32, inf32, 0), ret(op1(0x94), fromF32(-3), fromF32(-1.5), fromF32(2)), ret(op1(0x94), 0x00400000, 0x00800000, fromF32(0.5)),
+	ret(op1(0x94), negZero32, negZero32, fromF32(1)), ret(op1(0x94), inf32, 0x7f7fffff, fromF32(2)), ret(op1(0x94), 0, 1, 0x33ffffff),
+	ret(op1(0x95), inf32, fromF32(1), 0), ret(op1(0x95), negInf32, fromF32(-1), 0), ret(op1(0x95), nan32, 0, 0),
+	ret(op1(0x95), 0x3eaaaaab, fromF32(1), fromF32(3)), ret(op1(0x95), negZero32, 0, fromF32(-1)), ret(op1(0x95), nan32, inf32, negInf32),
+	ret(op1(0x95), 1, 0x00000002, fromF32(2)),
+	ret(op1(0x96), negZero32, negZero32, 0), ret(op1(0x96), negZero32, 0, negZero32), ret(op1(0x96), nan32, fromF32(1), nan32),
+	ret(op1(0x96), nan32, nan32, fromF32(1)), ret(op1(0x96), negInf32, negInf32, fromF32(1)), ret(op1(0x96), fromF32(-1), fromF32(-1), fromF32(1)),
+	ret(op1(0x97), 0, negZero32, 0), ret(op1(0x97), 0, 0, negZero32), ret(op1(0x97), nan32, nan32, fromF32(1)),
+	ret(op1(0x97), nan32, fromF32(1), nan32), ret(op1(0x97), inf32, inf32, fromF32(1)), ret(op1(0x97), fromF32(1), fromF32(-1), fromF32(1)),
+	ret(op1(0x98), fromF32(-1.5), fromF32(1.5), negZero32), ret(op1(0x98), fromF32(1.5), fromF32(-1.5), 0),
+	ret(op1(0x98), negNaN32, nan32, fromF32(-1)), ret(op1(0x98), nan32, negNaN32, fromF32(1)), ret(op1(0x98), negZero32, 0, negNaN32),
+
+	// f64.wast
+	ret(op1(0x99), fromF64(1.5), fromF64(-1.5)), ret(op1(0x99), 0, negZero64), ret(op1(0x99), nan64, negNaN64),
+	ret(op1(0x99), 0x7ff4000000000000, 0xfff4000000000000),
+	ret(op1(0x9a), fromF64(-1.5), fromF64(1.5)), ret(op1(0x9a), negZero64, 0), ret(op1(0x9a), negNaN64, nan64),
+	ret(op1(0x9b), negZero64, fromF64(-0.5)), ret(op1(0x9b), fromF64(2), fromF64(1.5)), ret(op1(0x9b), fromF64(1), 1),
+	ret(op1(0x9b), inf64, inf64), ret(op1(0x9b), nan64, nan64),
+	ret(op1(0x9c), fromF64(-1), fromF64(-0.5)), ret(op1(0x9c), fromF64(1), fromF64(1.5)), ret(op1(0x9c), fromF64(-1), 0x8000000000000001),
+	ret(op1(0x9c), negZero64, negZero64),
+	ret(op1(0x9d), fromF64(-1), fromF64(-1.5)), ret(op1(0x9d), negZero64, fromF64(-0.5)), ret(op1(0x9d), fromF64(1), fromF64(1.5)),
+	ret(op1(0x9e), 0, fromF64(0.5)), ret(op1(0x9e), negZero64, fromF64(-0.5)), ret(op1(0x9e), fromF64(2), fromF64(2.5)),
+	ret(op1(0x9e), fromF64(-4), fromF64(-3.5)), ret(op1(0x9e), fromF64(4503599627370497), fromF64(4503599627370497)),
+	ret(op1(0x9e), fromF64(-4503599627370497), fromF64(-4503599627370497)),
+	ret(op1(0x9f), fromF64(2), fromF64(4)), ret(op1(0x9f), nan64, fromF64(-1)), ret(op1(0x9f), negZero64, negZero64),
+	ret(op1(0x9f), 0x3ff6a09e667f3bcd, fromF64(2)), ret(op1(0x9f), inf64, inf64),
+	ret(op1(0xa0), fromF64(3), fromF64(1), fromF64(2)), ret(op1(0xa0), 0x3fd3333333333334, fromF64(0.1), fromF64(0.2)),
+	ret(op1(0xa0), nan64, inf64, negInf64), ret(op1(0xa0), 2, 1, 1), ret(op1(0xa0), fromF64(9007199254740992), fromF64(9007199254740992), fromF64(1)),
+	ret(op1(0xa0), negZero64, negZero64, negZero64), ret(op1(0xa0), inf64, 0x7fefffffffffffff, 0x7fefffffffffffff),
+	ret(op1(0xa1), nan64, inf64, inf64), ret(op1(0xa1), fromF64(-1), fromF64(1), fromF64(2)), ret(op1(0xa1), negZero64, negZero64, 0),
+	ret(op1(0xa1), 0, negZero64, negZero64),
+	ret(op1(0xa2), nan64, inf64, 0), ret(op1(0xa2), fromF64(-3), fromF64(-1.5), fromF64(2)), ret(op1(0xa2), 0x0008000000000000, 0x0010000000000000, fromF64(0.5)),
+	ret(op1(0xa2), negZero64, negZero64, fromF64(1)), ret(op1(0xa2), inf64, 0x7fefffffffffffff, fromF64(2)),
+	ret(op1(0xa3), inf64, fromF64(1), 0), ret(op1(0xa3), negInf64, fromF64(-1), 0), ret(op1(0xa3), nan64, 0, 0),
+	ret(op1(0xa3), 0x3fd5555555555555, fromF64(1), fromF64(3)), ret(op1(0xa3), negZero64, 0, fromF64(-1)),
+	ret(op1(0xa4), negZero64, negZero64, 0), ret(op1(0xa4), negZero64, 0, negZero64), ret(op1(0xa4), nan64, fromF64(1), nan64),
+	ret(op1(0xa4), nan64, nan64, fromF64(1)), ret(op1(0xa4), negInf64, negInf64, fromF64(1)),
+	ret(op1(0xa5), 0, negZero64, 0), ret(op1(0xa5), 0, 0, negZero64), ret(op1(0xa5), nan64, nan64, fromF64(1)),
+	ret(op1(0xa5), nan64, fromF64(1), nan64), ret(op1(0xa5), inf64, inf64, fromF64(1)),
+	ret(op1(0xa6), fromF64(-1.5), fromF64(1.5), negZero64), ret(op1(0xa6), fromF64(1.5), fromF64(-1.5), 0),
+	ret(op1(0xa6), negNaN64, nan64, fromF64(-1)), ret(op1(0xa6), nan64, negNaN64, fromF64(1)),
+
+	// conversions.wast
+	ret(op1(0xa7), s32(-1), s64(-1)), ret(op1(0xa7), s32(-100000), s64(-100000)), ret(op1(0xa7), 0x80000000, 0x80000000),
+	ret(op1(0xa7), 0x7fffffff, 0xffffffff7fffffff), ret(op1(0xa7), 0, 0xffffffff00000000), ret(op1(0xa7), 1, 0x0000000100000001),
+	ret(op1(0xa7), 0x9abcdef0, 0x123456789abcdef0), ret(op1(0xa7), 0, 0x8000000000000000),
+	ret(op1(0xa8), 0, 0), ret(op1(0xa8), 0, negZero32), ret(op1(0xa8), 0, 1), ret(op1(0xa8), 1, fromF32(1.5)),
+	ret(op1(0xa8), s32(-1), fromF32(-1.5)), ret(op1(0xa8), s32(-1), 0xbf8ccccd), ret(op1(0xa8), 2147483520, 0x4effffff),
+	ret(op1(0xa8), 0x80000000, 0xcf000000), trapped(op1(0xa8), 0x4f000000), trapped(op1(0xa8), 0xcf000001),
+	trapped(op1(0xa8), inf32), trapped(op1(0xa8), negInf32), trapped(op1(0xa8), nan32), trapped(op1(0xa8), 0x7fa00000), trapped(op1(0xa8), negNaN32),
+	ret(op1(0xa9), 0, 0), ret(op1(0xa9), 1, fromF32(1)), ret(op1(0xa9), 1, fromF32(1.5)), ret(op1(0xa9), 2, fromF32(2)),
+	ret(op1(0xa9), 0x80000000, 0x4f000000), ret(op1(0xa9), 0xffffff00, 0x4f7fffff), ret(op1(0xa9), 0, 0xbf733333), ret(op1(0xa9), 0, 0xbf7fffff),
+	trapped(op1(0xa9), 0x4f800000), trapped(op1(0xa9), fromF32(-1)), trapped(op1(0xa9), inf32), trapped(op1(0xa9), nan32),
+	ret(op1(0xaa), 0, negZero64), ret(op1(0xaa), 1, fromF64(1.5)), ret(op1(0xaa), s32(-1), fromF64(-1.5)),
+	ret(op1(0xaa), 2147483647, fromF64(2147483647)), ret(op1(0xaa), 0x80000000, fromF64(-2147483648)),
+	ret(op1(0xaa), 0x80000000, fromF64(-2147483648.9)), ret(op1(0xaa), 2147483647, fromF64(2147483647.9)),
+	trapped(op1(0xaa), fromF64(2147483648)), trapped(op1(0xaa), fromF64(-2147483649)), trapped(op1(0xaa), inf64), trapped(op1(0xaa), nan64),
+	ret(op1(0xab), 0, 0), ret(op1(0xab), 1, fromF64(1.5)), ret(op1(0xab), 0x80000000, fromF64(2147483648)),
+	ret(op1(0xab), 0xffffffff, fromF64(4294967295)), ret(op1(0xab), 0xffffffff, fromF64(4294967295.9)), ret(op1(0xab), 0, fromF64(-0.9)),
+	ret(op1(0xab), 100000000, fromF64(1e8)),
+	trapped(op1(0xab), fromF64(4294967296)), trapped(op1(0xab), fromF64(-1)), trapped(op1(0xab), fromF64(1e16)), trapped(op1(0xab), nan64),
+	ret(op1(0xac), 0, 0), ret(op1(0xac), 10000, 10000), ret(op1(0xac), s64(-10000), s32(-10000)), ret(op1(0xac), s64(-1), s32(-1)),
+	ret(op1(0xac), 0x7fffffff, 0x7fffffff), ret(op1(0xac), 0xffffffff80000000, 0x80000000),
+	ret(op1(0xad), 0, 0), ret(op1(0xad), 0x00000000ffffd8f0, s32(-10000)), ret(op1(0xad), 0xffffffff, s32(-1)),
+	ret(op1(0xad), 0x80000000, 0x80000000),
+	ret(op1(0xae), 0, negZero32), ret(op1(0xae), 1, fromF32(1.5)), ret(op1(0xae), s64(-1), fromF32(-1.5)), ret(op1(0xae), 4294967296, 0x4f800000),
+	ret(op1(0xae), s64(-4294967296), 0xcf800000), ret(op1(0xae), 9223371487098961920, 0x5effffff),
+	ret(op1(0xae), 0x8000000000000000, 0xdf000000),
+	trapped(op1(0xae), 0x5f000000), trapped(op1(0xae), 0xdf000001), trapped(op1(0xae), inf32), trapped(op1(0xae), nan32),
+	ret(op1(0xaf), 0, 0), ret(op1(0xaf), 1, fromF32(1.5)), ret(op1(0xaf), 4294967296, 0x4f800000),
+	ret(op1(0xaf), 18446742974197923840, 0x5f7fffff), ret(op1(0xaf), 0, 0xbf7fffff),
+	trapped(op1(0xaf), 0x5f800000), trapped(op1(0xaf), fromF32(-1)), trapped(op1(0xaf), inf32), trapped(op1(0xaf), nan32),
+	ret(op1(0xb0), 0, negZero64), ret(op1(0xb0), 1, fromF64(1.5)), ret(op1(0xb0), s64(-1), fromF64(-1.5)),
+	ret(op1(0xb0), 4294967296, fromF64(4294967296)), ret(op1(0xb0), 9223372036854774784, 0x43dfffffffffffff),
+	ret(op1(0xb0), 0x8000000000000000, 0xc3e0000000000000),
+	trapped(op1(0xb0), 0x43e0000000000000), trapped(op1(0xb0), 0xc3e0000000000001), trapped(op1(0xb0), negInf64), trapped(op1(0xb0), nan64),
+	ret(op1(0xb1), 0, 0), ret(op1(0xb1), 1, fromF64(1.5)), ret(op1(0xb1), 0xffffffff, fromF64(4294967295)),
+	ret(op1(0xb1), 18446744073709549568, 0x43efffffffffffff), ret(op1(0xb1), 0, fromF64(-0.9)), ret(op1(0xb1), 10000000000000000, fromF64(1e16)),
+	trapped(op1(0xb1), 0x43f0000000000000), trapped(op1(0xb1), fromF64(-1)), trapped(op1(0xb1), inf64), trapped(op1(0xb1), nan64),
+	ret(op1(0xb2), fromF32(1), 1), ret(op1(0xb2), fromF32(-1), s32(-1)), ret(op1(0xb2), 0, 0), ret(op1(0xb2), 0x4f000000, 0x7fffffff),
+	ret(op1(0xb2), 0xcf000000, 0x80000000), ret(op1(0xb2), 0x4e932c06, 1234567890),
+	ret(op1(0xb2), 0x4b800000, 0x01000001), ret(op1(0xb2), 0xcb800000, s32(-0x01000001)), ret(op1(0xb2), 0x4b800002, 0x01000003),
+	ret(op1(0xb3), fromF32(1), 1), ret(op1(0xb3), 0, 0), ret(op1(0xb3), 0x4f000000, 0x7fffffff), ret(op1(0xb3), 0x4f000000, 0x80000000),
+	ret(op1(0xb3), 0x4f7ffffd, 0xfffffd00), ret(op1(0xb3), 0x4f800000, 0xffffffff), ret(op1(0xb3), 0x4b800000, 0x01000001),
+	ret(op1(0xb3), 0x4f000001, 0x80000081), ret(op1(0xb3), 0x4f000000, 0x80000080),
+	ret(op1(0xb4), fromF32(1), 1), ret(op1(0xb4), fromF32(-1), s64(-1)), ret(op1(0xb4), 0, 0),
+	ret(op1(0xb4), 0x5f000000, 0x7fffffffffffffff), ret(op1(0xb4), 0xdf000000, 0x8000000000000000),
+	ret(op1(0xb4), 0x4b800000, 0x01000001), ret(op1(0xb4), 0x5a000001, 0x20000020000001), ret(op1(0xb4), 0xda000001, s64(-0x20000020000001)),
+	ret(op1(0xb5), fromF32(1), 1), ret(op1(0xb5), 0, 0), ret(op1(0xb5), 0x5f000000, 0x7fffffffffffffff),
+	ret(op1(0xb5), 0x5f000000, 0x8000000000000000), ret(op1(0xb5), 0x5f800000, 0xffffffffffffffff),
+	ret(op1(0xb5), 0x5a000001, 0x20000020000001), ret(op1(0xb5), 0x5f000001, 0x8000008000000001), ret(op1(0xb5), 0x5f7fffff, 0xfffffe8000000001),
+	ret(op1(0xb6), 0, 0), ret(op1(0xb6), negZero32, negZero64), ret(op1(0xb6), fromF32(1), fromF64(1)),
+	ret(op1(0xb6), fromF32(1), 0x3ff0000010000000), ret(op1(0xb6), 0x3f800001, 0x3ff0000010000001),
+	ret(op1(0xb6), 0x7f7fffff, 0x47efffffe0000000), ret(op1(0xb6), 0x7f7fffff, 0x47efffffefffffff), ret(op1(0xb6), inf32, 0x47effffff0000000),
+	ret(op1(0xb6), 0, 0x3690000000000000), ret(op1(0xb6), 1, 0x3690000000000001), ret(op1(0xb6), 1, 0x36a0000000000000),
+	ret(op1(0xb6), inf32, inf64), ret(op1(0xb6), negInf32, negInf64), ret(op1(0xb6), nan32, nan64), ret(op1(0xb6), fromF32(0.1), fromF64(0.1)),
+	ret(op1(0xb7), fromF64(1), 1), ret(op1(0xb7), fromF64(-1), s32(-1)), ret(op1(0xb7), 0, 0),
+	ret(op1(0xb7), fromF64(2147483647), 0x7fffffff), ret(op1(0xb7), fromF64(-2147483648), 0x80000000), ret(op1(0xb7), fromF64(987654321), 987654321),
+	ret(op1(0xb8), fromF64(1), 1), ret(op1(0xb8), 0, 0), ret(op1(0xb8), fromF64(2147483647), 0x7fffffff),
+	ret(op1(0xb8), fromF64(2147483648), 0x80000000), ret(op1(0xb8), fromF64(4294967295), 0xffffffff),
+	ret(op1(0xb9), fromF64(1), 1), ret(op1(0xb9), fromF64(-1), s64(-1)), ret(op1(0xb9), 0, 0),
+	ret(op1(0xb9), 0x43e0000000000000, 0x7fffffffffffffff), ret(op1(0xb9), 0xc3e0000000000000, 0x8000000000000000),
+	ret(op1(0xb9), fromF64(4669201609102990), 4669201609102990), ret(op1(0xb9), fromF64(9007199254740992), 9007199254740993),
+	ret(op1(0xb9), fromF64(-9007199254740992), s64(-9007199254740993)), ret(op1(0xb9), fromF64(9007199254740996), 9007199254740995),
+	ret(op1(0xba), fromF64(1), 1), ret(op1(0xba), 0, 0), ret(op1(0xba), 0x43e0000000000000, 0x7fffffffffffffff),
+	ret(op1(0xba), 0x43e0000000000000, 0x8000000000000000), ret(op1(0xba), 0x43f0000000000000, 0xffffffffffffffff),
+	ret(op1(0xba), 0x43e0000000000000, 0x8000000000000400), ret(op1(0xba), 0x43e0000000000001, 0x8000000000000401),
+	ret(op1(0xba), 0x43e0000000000001, 0x8000000000000402), ret(op1(0xba), 0x43effffffffffffe, 0xfffffffffffff400),
+	ret(op1(0xba), 0x43f0000000000000, 0xfffffffffffffc00),
+	ret(op1(0xbb), 0, 0), ret(op1(0xbb), negZero64, negZero32), ret(op1(0xbb), 0x36a0000000000000, 1),
+	ret(op1(0xbb), 0xb6a0000000000000, 0x80000001), ret(op1(0xbb), fromF64(1), fromF32(1)), ret(op1(0xbb), 0x47efffffe0000000, 0x7f7fffff),
+	ret(op1(0xbb), inf64, inf32), ret(op1(0xbb), negInf64, negInf32), ret(op1(0xbb), nan64, nan32),
+	ret(op1(0xbc), 0, 0), ret(op1(0xbc), 0x80000000, negZero32), ret(op1(0xbc), 1, 1), ret(op1(0xbc), s32(-1), 0xffffffff),
+	ret(op1(0xbc), 0x7fc00000, nan32), ret(op1(0xbc), 0x7fa00000, 0x7fa00000), ret(op1(0xbc), 0xffa00000, 0xffa00000),
+	ret(op1(0xbd), 0, 0), ret(op1(0xbd), 0x8000000000000000, negZero64), ret(op1(0xbd), 1, 1),
+	ret(op1(0xbd), 0x7ff4000000000000, 0x7ff4000000000000), ret(op1(0xbd), 0xfff4000000000000, 0xfff4000000000000),
+	ret(op1(0xbe), 0, 0), ret(op1(0xbe), negZero32, 0x80000000), ret(op1(0xbe), 1, 1), ret(op1(0xbe), 0x7fa00000, 0x7fa00000),
+	ret(op1(0xbe), 0xffa00000, 0xffa00000), ret(op1(0xbe), 0x7f800000, 0x7f800000),
+	ret(op1(0xbf), 0, 0), ret(op1(0xbf), negZero64, 0x8000000000000000), ret(op1(0xbf), 1, 1),
+	ret(op1(0xbf), 0x7ff4000000000000, 0x7ff4000000000000), ret(op1(0xbf), 0xfff4000000000000, 0xfff4000000000000),
+
+	// i32.wast and i64.wast of the sign extension proposal
+	ret(op1(0xc0), 0, 0), ret(op1(0xc0), 0x7f, 0x7f), ret(op1(0xc0), s32(-128), 0x80), ret(op1(0xc0), s32(-1), 0xff),
+	ret(op1(0xc0), 0, 0x01234500), ret(op1(0xc0), s32(-128), 0xfedcba80), ret(op1(0xc0), s32(-1), s32(-1)),
+	ret(op1(0xc1), 0, 0), ret(op1(0xc1), 0x7fff, 0x7fff), ret(op1(0xc1), s32(-32768), 0x8000), ret(op1(0xc1), s32(-1), 0xffff),
+	ret(op1(0xc1), 0, 0x01230000), ret(op1(0xc1), s32(-32768), 0xfedc8000), ret(op1(0xc1), s32(-1), s32(-1)),
+	ret(op1(0xc2), 0, 0), ret(op1(0xc2), 0x7f, 0x7f), ret(op1(0xc2), s64(-128), 0x80), ret(op1(0xc2), s64(-1), 0xff),
+	ret(op1(0xc2), 0, 0x0123456789abcd00), ret(op1(0xc2), s64(-128), 0xfedcba9876543280), ret(op1(0xc2), s64(-1), s64(-1)),
+	ret(op1(0xc3), 0, 0), ret(op1(0xc3), 0x7fff, 0x7fff), ret(op1(0xc3), s64(-32768), 0x8000), ret(op1(0xc3), s64(-1), 0xffff),
+	ret(op1(0xc3), 0, 0x123456789abc0000), ret(op1(0xc3), s64(-32768), 0xfedcba9876548000), ret(op1(0xc3), s64(-1), s64(-1)),
+	ret(op1(0xc4), 0, 0), ret(op1(0xc4), 0x7fff, 0x7fff), ret(op1(0xc4), 0x8000, 0x8000), ret(op1(0xc4), 0xffff, 0xffff),
+	ret(op1(0xc4), 0x7fffffff, 0x7fffffff), ret(op1(0xc4), s64(-0x80000000), 0x80000000), ret(op1(0xc4), s64(-1), 0xffffffff),
+	ret(op1(0xc4), 0, 0x0123456700000000), ret(op1(0xc4), s64(-0x80000000), 0xfedcba9880000000), ret(op1(0xc4), s64(-1), s64(-1)),
+
+	// conversions.wast of the non-trapping float to int proposal
+	ret(sat(0), 0, 0), ret(sat(0), 0, negZero32), ret(sat(0), 1, fromF32(1.5)), ret(sat(0), s32(-1), fromF32(-1.5)),
+	ret(sat(0), 2147483520, 0x4effffff), ret(sat(0), 0x80000000, 0xcf000000), ret(sat(0), 0x7fffffff, 0x4f000000),
+	ret(sat(0), 0x80000000, 0xcf000001), ret(sat(0), 0x7fffffff, inf32), ret(sat(0), 0x80000000, negInf32),
+	ret(sat(0), 0, nan32), ret(sat(0), 0, 0x7fa00000), ret(sat(0), 0, negNaN32),
+	ret(sat(1), 0, 0), ret(sat(1), 1, fromF32(1.5)), ret(sat(1), 0x80000000, 0x4f000000), ret(sat(1), 0xffffff00, 0x4f7fffff),
+	ret(sat(1), 0, 0xbf7fffff), ret(sat(1), 0xffffffff, 0x4f800000), ret(sat(1), 0, fromF32(-1)),
+	ret(sat(1), 0xffffffff, inf32), ret(sat(1), 0, negInf32), ret(sat(1), 0, nan32),
+	ret(sat(2), 0, negZero64), ret(sat(2), 1, fromF64(1.5)), ret(sat(2), s32(-1), fromF64(-1.9)), ret(sat(2), 2147483647, fromF64(2147483647)),
+	ret(sat(2), 0x80000000, fromF64(-2147483648)), ret(sat(2), 0x7fffffff, fromF64(2147483648)), ret(sat(2), 0x80000000, fromF64(-2147483649)),
+	ret(sat(2), 0x7fffffff, inf64), ret(sat(2), 0x80000000, negInf64), ret(sat(2), 0, nan64), ret(sat(2), 0, negNaN64),
+	ret(sat(3), 0, 0), ret(sat(3), 1, fromF64(1.5)), ret(sat(3), 0xffffffff, fromF64(4294967295)), ret(sat(3), 0xffffffff, fromF64(4294967295.9)),
+	ret(sat(3), 100000000, fromF64(1e8)), ret(sat(3), 0xffffffff, fromF64(4294967296)), ret(sat(3), 0, fromF64(-1)),
+	ret(sat(3), 0, fromF64(-0.9)), ret(sat(3), 0xffffffff, fromF64(1e16)), ret(sat(3), 0xffffffff, inf64), ret(sat(3), 0, nan64),
+	ret(sat(4), 0, negZero32), ret(sat(4), 1, fromF32(1.5)), ret(sat(4), s64(-1), fromF32(-1.5)), ret(sat(4), 4294967296, 0x4f800000),
+	ret(sat(4), 9223371487098961920, 0x5effffff), ret(sat(4), 0x8000000000000000, 0xdf000000), ret(sat(4), 0x7fffffffffffffff, 0x5f000000),
+	ret(sat(4), 0x8000000000000000, 0xdf000001), ret(sat(4), 0x7fffffffffffffff, inf32), ret(sat(4), 0x8000000000000000, negInf32),
+	ret(sat(4), 0, nan32),
+	ret(sat(5), 0, 0), ret(sat(5), 1, fromF32(1.5)), ret(sat(5), 4294967296, 0x4f800000), ret(sat(5), 18446742974197923840, 0x5f7fffff),
+	ret(sat(5), 0, 0xbf7fffff), ret(sat(5), 0xffffffffffffffff, 0x5f800000), ret(sat(5), 0, fromF32(-1)),
+	ret(sat(5), 0xffffffffffffffff, inf32), ret(sat(5), 0, negInf32), ret(sat(5), 0, nan32),
+	ret(sat(6), 0, negZero64), ret(sat(6), 1, fromF64(1.5)), ret(sat(6), s64(-1), fromF64(-1.5)),
+	ret(sat(6), 9223372036854774784, 0x43dfffffffffffff), ret(sat(6), 0x8000000000000000, 0xc3e0000000000000),
+	ret(sat(6), 0x7fffffffffffffff, 0x43e0000000000000), ret(sat(6), 0x8000000000000000, 0xc3e0000000000001),
+	ret(sat(6), 0x7fffffffffffffff, inf64), ret(sat(6), 0x8000000000000000, negInf64), ret(sat(6), 0, nan64),
+	ret(sat(7), 0, 0), ret(sat(7), 1, fromF64(1.5)), ret(sat(7), 0xffffffff, fromF64(4294967295)),
+	ret(sat(7), 18446744073709549568, 0x43efffffffffffff), ret(sat(7), 0, fromF64(-0.9)), ret(sat(7), 10000000000000000, fromF64(1e16)),
+	ret(sat(7), 0xffffffffffffffff, 0x43f0000000000000), ret(sat(7), 0, fromF64(-1)), ret(sat(7), 0xffffffffffffffff, inf64),
+	ret(sat(7), 0, negInf64), ret(sat(7), 0, nan64),
+}
+
+// specTypes returns the operand and result types and the arity of op
+func specTypes(op []byte) (in, out ValueType, arity int, ok bool) {
+	if op[0] == 0xfc {
+		if int(op[1]) >= len(truncSatOps) {
+			return 0, 0, 0, false
+		}
+		return truncSatOps[op[1]][0], truncSatOps[op[1]][1], 1, true
+	}
+	return numericType(op[0])
+}
+
+// bitwiseOps keep the payload of a NaN, the others may return any NaN
+var bitwiseOps = map[byte]bool{
+	0x8b: true, 0x8c: true, 0x98: true, 0x99: true, 0x9a: true, 0xa6: true,
+	0xbc: true, 0xbd: true, 0xbe: true, 0xbf: true,
+}
+
+func isNaN(t ValueType, v uint64) bool {
+	switch t {
+	case F32:
+		return math.IsNaN(float64(f32(v)))
+	case F64:
+		return math.IsNaN(f64(v))
+	}
+	return false
+}
+
+func TestSpecNumeric(t *testing.T) {
+	for i, c := range numericSpec {
+		in, out, arity, ok := specTypes(c.op)
+		if !ok {
+			t.Fatalf("case %d: instruction %x is not supported", i, c.op)
+		}
+		t.Run(fmt.Sprintf("%x/%d", c.op, i), func(t *testing.T) {
+			if len(c.args) != arity {
+				t.Fatalf("%d arguments for %d operands", len(c.args), arity)
+			}
+			params := make([]byte, arity)
+			code := make([]byte, 0, 2*arity+len(c.op))
+			for j := range params {
+				params[j] = byte(in)
+				code = append(code, 0x20, byte(j))
+			}
+			code = append(code, c.op...)
+			m, err := Compile(build([]testFunc{{name: "f", params: params, result: []byte{byte(out)}, code: code}}, nil), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.Instantiate().Call("f", 100, c.args...)
+			if c.trap {
+				var trap *Trap
+				if !errors.As(err, &trap) {
+					t.Fatalf("got %v, %v, want a trap", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results", len(got))
+			}
+			if got[0] == c.want {
+				return
+			}
+			if isNaN(out, c.want) && isNaN(out, got[0]) && !bitwiseOps[c.op[0]] {
+				return
+			}
+			t.Fatalf("%x %x = 0x%x, want 0x%x", c.op, c.args, got[0], c.want)
+		})
+	}
+}
+
+// memorySpec stores pattern at 0 with i64.store and runs op at addr, a
+// load returns the value loaded, a store of value returns the i64 at 0
+// after filling it with ones
+type memorySpec struct {
+	op      byte
+	addr    uint64
+	pattern uint64
+	value   uint64
+	want    uint64
+	trap    bool
+}
+
+var memorySpecs = []memorySpec{
+	// memory.wast and address.wast
+	{op: 0x28, pattern: 0x8182838485868788, want: 0x85868788},
+	{op: 0x28, addr: 4, pattern: 0x8182838485868788, want: 0x81828384},
+	{op: 0x28, addr: 1, pattern: 0x0102030405060708, want: 0x04050607},
+	{op: 0x28, addr: PageSize - 4},
+	{op: 0x28, addr: PageSize - 3, trap: true},
+	{op: 0x28, addr: 0xffffffff, trap: true},
+	{op: 0x29, pattern: 0x8182838485868788, want: 0x8182838485868788},
+	{op: 0x29, addr: PageSize - 7, trap: true},
+	{op: 0x2a, pattern: 0x3fc00000, want: 0x3fc00000},
+	{op: 0x2a, pattern: 0x7fa00000, want: 0x7fa00000},
+	{op: 0x2a, addr: PageSize - 3, trap: true},
+	{op: 0x2b, pattern: 0x7ff4000000000000, want: 0x7ff4000000000000},
+	{op: 0x2b, addr: PageSize - 7, trap: true},
+	{op: 0x2c, pattern: 0x88, want: 0xffffff88},
+	{op: 0x2c, pattern: 0x7f, want: 0x7f},
+	{op: 0x2c, addr: PageSize, trap: true},
+	{op: 0x2d, pattern: 0x88, want: 0x88},
+	{op: 0x2d, addr: PageSize - 1},
+	{op: 0x2d, addr: PageSize, trap: true},
+	{op: 0x2e, pattern: 0x8788, want: 0xffff8788},
+	{op: 0x2e, pattern: 0x7788, want: 0x7788},
+	{op: 0x2e, addr: PageSize - 1, trap: true},
+	{op: 0x2f, pattern: 0x8788, want: 0x8788},
+	{op: 0x2f, addr: PageSize - 1, trap: true},
+	{op: 0x30, pattern: 0x88, want: 0xffffffffffffff88},
+	{op: 0x30, pattern: 0x7f, want: 0x7f},
+	{op: 0x30, addr: PageSize, trap: true},
+	{op: 0x31, pattern: 0x88, want: 0x88},
+	{op: 0x31, addr: PageSize, trap: true},
+	{op: 0x32, pattern: 0x8788, want: 0xffffffffffff8788},
+	{op: 0x32, addr: PageSize - 1, trap: true},
+	{op: 0x33, pattern: 0x8788, want: 0x8788},
+	{op: 0x33, addr: PageSize - 1, trap: true},
+	{op: 0x34, pattern: 0x8182838485868788, want: 0xffffffff85868788},
+	{op: 0x34, pattern: 0x75868788, want: 0x75868788},
+	{op: 0x34, addr: PageSize - 3, trap: true},
+	{op: 0x35, pattern: 0x8182838485868788, want: 0x85868788},
+	{op: 0x35, addr: PageSize - 3, trap: true},
+	{op: 0x36, value: 0x01020304, want: 0xffffffff01020304},
+	{op: 0x36, addr: PageSize - 4, want: 0xffffffffffffffff},
+	{op: 0x36, addr: PageSize - 3, trap: true},
+	{op: 0x36, addr: 0xffffffff, trap: true},
+	{op: 0x37, value: 0x0102030405060708, want: 0x0102030405060708},
+	{op: 0x37, addr: PageSize - 7, trap: true},
+	{op: 0x38, value: 0x7fa00000, want: 0xffffffff7fa00000},
+	{op: 0x38, addr: PageSize - 3, trap: true},
+	{op: 0x39, value: 0x7ff4000000000000, want: 0x7ff4000000000000},
+	{op: 0x39, addr: PageSize - 7, trap: true},
+	{op: 0x3a, value: 0x12345678, want: 0xffffffffffffff78},
+	{op: 0x3a, addr: PageSize, trap: true},
+	{op: 0x3b, value: 0x12345678, want: 0xffffffffffff5678},
+	{op: 0x3b, addr: PageSize - 1, trap: true},
+	{op: 0x3c, value: 0x0102030405060708, want: 0xffffffffffffff08},
+	{op: 0x3c, addr: PageSize, trap: true},
+	{op: 0x3d, value: 0x0102030405060708, want: 0xffffffffffff0708},
+	{op: 0x3d, addr: PageSize - 1, trap: true},
+	{op: 0x3e, value: 0x0102030405060708, want: 0xffffffff05060708},
+	{op: 0x3e, addr: PageSize - 3, trap: true},
+}
+
+func TestSpecMemory(t *testing.T) {
+	for i, c := range memorySpecs {
+		mem := memoryOps[c.op]
+		t.Run(fmt.Sprintf("%x/%d", c.op, i), func(t *testing.T) {
+			f := testFunc{name: "f", params: []byte{0x7f, 0x7e}}
+			args := []uint64{c.addr, c.pattern}
+			if mem.store {
+				// the i64 at 0 is all ones before the store
+				f.params = []byte{0x7f, byte(mem.typ)}
+				f.result = []byte{0x7e}
+				f.code = []byte{0x41, 0, 0x42, 0x7f, 0x37, 3, 0, 0x20, 0, 0x20, 1, c.op, 0, 0, 0x41, 0, 0x29, 3, 0}
+				args[1] = c.value
+			} else {
+				f.result = []byte{byte(mem.typ)}
+				f.code = []byte{0x41, 0, 0x20, 1, 0x37, 3, 0, 0x20, 0, c.op, 0, 0}
+			}
+			m, err := Compile(build([]testFunc{f}, []byte{0, 1}), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.Instantiate().Call("f", 100, args...)
+			if c.trap {
+				var trap *Trap
+				if !errors.As(err, &trap) {
+					t.Fatalf("got %v, %v, want a trap", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0] != c.want {
+				t.Fatalf("got 0x%x, want 0x%x", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSpecControl(t *testing.T) {
+	funcs := []testFunc{
+		{name: "nop", result: []byte{0x7f}, code: []byte{0x01, 0x41, 7, 0x01}},
+		{name: "drop", result: []byte{0x7f}, code: []byte{0x41, 1, 0x41, 2, 0x1a}},
+		{name: "tee", params: []byte{0x7f}, result: []byte{0x7f}, locals: []byte{1, 1, 0x7f},
+			code: []byte{0x20, 0, 0x22, 1, 0x20, 1, 0x6a}},
+		{name: "size", result: []byte{0x7f}, code: []byte{0x3f, 0}},
+		{name: "grow", params: []byte{0x7f}, result: []byte{0x7f}, code: []byte{0x20, 0, 0x40, 0, 0x1a, 0x3f, 0}},
+		{name: "select_t", params: []byte{0x7f}, result: []byte{0x7e},
+			code: []byte{0x42, 1, 0x42, 2, 0x20, 0, 0x1c, 1, 0x7e}},
+		{name: "br_value", result: []byte{0x7f}, code: []byte{0x02, 0x7f, 0x41, 5, 0x0c, 0, 0x0b}},
+		{name: "br_if_value", params: []byte{0x7f}, result: []byte{0x7f},
+			code: []byte{0x02, 0x7f, 0x41, 5, 0x20, 0, 0x0d, 0, 0x1a, 0x41, 6, 0x0b}},
+		{name: "if", params: []byte{0x7f}, result: []byte{0x7f}, locals: []byte{1, 1, 0x7f},
+			code: []byte{0x20, 0, 0x04, 0x40, 0x41, 9, 0x21, 1, 0x0b, 0x20, 1}},
+		{name: "if_else", params: []byte{0x7f}, result: []byte{0x7f},
+			code: []byte{0x20, 0, 0x04, 0x7f, 0x41, 1, 0x05, 0x41, 2, 0x0b}},
+		{name: "loop_value", result: []byte{0x7f}, code: []byte{0x03, 0x7f, 0x41, 3, 0x0b}},
+		{name: "return", result: []byte{0x7f}, code: []byte{0x02, 0x40, 0x41, 4, 0x0f, 0x0b, 0x41, 5}},
+		{name: "br_outer", result: []byte{0x7f},
+			code: []byte{0x02, 0x7f, 0x02, 0x40, 0x41, 8, 0x0c, 1, 0x0b, 0x41, 9, 0x0b}},
+		{name: "br_table_value", params: []byte{0x7f}, result: []byte{0x7f},
+			code: []byte{0x02, 0x7f, 0x02, 0x7f, 0x41, 3, 0x20, 0, 0x0e, 1, 0, 1, 0x0b, 0x41, 1, 0x6a, 0x0b}},
+	}
+	m, err := Compile(build(funcs, []byte{0, 1}), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		fn   string
+		args []uint64
+		want uint64
+	}{
+		{"nop", "nop", nil, 7},
+		{"drop", "drop", nil, 1},
+		{"local.tee", "tee", []uint64{21}, 42},
+		{"memory.size", "size", nil, 1},
+		{"memory.size after grow", "grow", []uint64{1}, 2},
+		{"typed select true", "select_t", []uint64{1}, 1},
+		{"typed select false", "select_t", []uint64{0}, 2},
+		{"br with value", "br_value", nil, 5},
+		{"br_if taken", "br_if_value", []uint64{1}, 5},
+		{"br_if not taken", "br_if_value", []uint64{0}, 6},
+		{"if taken", "if", []uint64{1}, 9},
+		{"if not taken", "if", []uint64{0}, 0},
+		{"if else then", "if_else", []uint64{1}, 1},
+		{"if else else", "if_else", []uint64{0}, 2},
+		{"loop with value", "loop_value", nil, 3},
+		{"return from a block", "return", nil, 4},
+		{"br to an outer block", "br_outer", nil, 8},
+		{"br_table inner", "br_table_value", []uint64{0}, 4},
+		{"br_table default", "br_table_value", []uint64{5}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.Instantiate().Call(tt.fn, 1000, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// globalModule assembles a module of a mutable i32 global of 10 and an
+// immutable i64 global of 7, exporting inc of the i32 result and get of the
+// i64 result
+func globalModule(inc, get []byte) []byte {
+	body := func(code []byte) []byte {
+		code = concat([]byte{0}, code, []byte{0x0b})
+		return concat(leb(uint32(len(code))), code)
+	}
+	bin := []byte("\x00asm\x01\x00\x00\x00")
+	bin = append(bin, section(1, vec(concat([]byte{0x60}, vec(), vec([]byte{0x7f})), concat([]byte{0x60}, vec(), vec([]byte{0x7e}))))...)
+	bin = append(bin, section(3, vec([]byte{0}, []byte{1}))...)
+	bin = append(bin, section(6, vec([]byte{0x7f, 1, 0x41, 10, 0x0b}, []byte{0x7e, 0, 0x42, 7, 0x0b}))...)
+	bin = append(bin, section(7, vec(concat([]byte{3}, []byte("inc"), []byte{0, 0}), concat([]byte{3}, []byte("get"), []byte{0, 1})))...)
+	return append(bin, section(10, vec(body(inc), body(get)))...)
+}
+
+func TestSpecGlobals(t *testing.T) {
+	// inc adds one to the i32 global and returns it, get returns the i64 one
+	m, err := Compile(globalModule([]byte{0x23, 0, 0x41, 1, 0x6a, 0x24, 0, 0x23, 0}, []byte{0x23, 1}), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := m.Instantiate()
+	for _, want := range []uint64{11, 12} {
+		if got, err := in.Call("inc", 100); err != nil || got[0] != want {
+			t.Fatalf("inc = %v, %v, want %d", got, err, want)
+		}
+	}
+	if got, err := m.Instantiate().Call("inc", 100); err != nil || got[0] != 11 {
+		t.Fatalf("inc of a new instance = %v, %v, want 11", got, err)
+	}
+	if got, err := in.Call("get", 100); err != nil || got[0] != 7 {
+		t.Fatalf("get = %v, %v, want 7", got, err)
+	}
+
+	// global.set of the immutable global
+	if _, err := Compile(globalModule([]byte{0x23, 0}, []byte{0x42, 1, 0x24, 1, 0x42, 1}), 0); err == nil {
+		t.Fatal("expected an error of setting an immutable global")
+	}
+}
+
+// TestSpecCoverage keeps a spec case for every instruction Compile accepts
+func TestSpecCoverage(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, c := range numericSpec {
+		covered[fmt.Sprintf("%x", c.op)] = true
+	}
+	for op := 0x45; op <= 0xff; op++ {
+		if _, _, _, ok := numericType(byte(op)); ok && !covered[fmt.Sprintf("%x", []byte{byte(op)})] {
+			t.Errorf("no spec case of instruction 0x%x", op)
+		}
+	}
+	for sub := range truncSatOps {
+		if !covered[fmt.Sprintf("%x", sat(byte(sub)))] {
+			t.Errorf("no spec case of instruction 0xfc %d", sub)
+		}
+	}
+	loads, traps := make(map[byte]bool), make(map[byte]bool)
+	for _, c := range memorySpecs {
+		if c.trap {
+			traps[c.op] = true
+		} else {
+			loads[c.op] = true
+		}
+	}
+	for op := range memoryOps {
+		if !loads[op] || !traps[op] {
+			t.Errorf("no spec case of the result and the trap of instruction 0x%x", op)
+		}
+	}
+}
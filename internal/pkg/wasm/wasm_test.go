@@ -0,0 +1,251 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func leb(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	b := leb(uint32(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func section(id byte, payload []byte) []byte {
+	return append(append([]byte{id}, leb(uint32(len(payload)))...), payload...)
+}
+
+func f64Const(f float64) []byte {
+	b := []byte{0x44, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint64(b[1:], math.Float64bits(f))
+	return b
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+type testFunc struct {
+	name   string
+	params []byte
+	result []byte
+	locals []byte
+	code   []byte
+}
+
+// build assembles a module of funcs, each exported by its name and of its
+// own type, memory holds the limits of the memory if any
+func build(funcs []testFunc, memory []byte) []byte {
+	var types, decls, exports, bodies [][]byte
+	for i, f := range funcs {
+		types = append(types, concat([]byte{0x60}, vec(bytesOf(f.params)...), vec(bytesOf(f.result)...)))
+		decls = append(decls, leb(uint32(i)))
+		if f.name != "" {
+			exports = append(exports, concat(leb(uint32(len(f.name))), []byte(f.name), []byte{0}, leb(uint32(i))))
+		}
+		locals := f.locals
+		if locals == nil {
+			locals = []byte{0}
+		}
+		body := concat(locals, f.code, []byte{0x0b})
+		bodies = append(bodies, concat(leb(uint32(len(body))), body))
+	}
+	bin := []byte("\x00asm\x01\x00\x00\x00")
+	bin = append(bin, section(1, vec(types...))...)
+	bin = append(bin, section(3, vec(decls...))...)
+	if memory != nil {
+		bin = append(bin, section(5, vec(memory))...)
+	}
+	bin = append(bin, section(7, vec(exports...))...)
+	return append(bin, section(10, vec(bodies...))...)
+}
+
+func bytesOf(b []byte) [][]byte {
+	items := make([][]byte, len(b))
+	for i := range b {
+		items[i] = b[i : i+1]
+	}
+	return items
+}
+
+func TestCall(t *testing.T) {
+	funcs := []testFunc{
+		{name: "add", params: []byte{0x7c, 0x7c}, result: []byte{0x7c},
+			code: []byte{0x20, 0, 0x20, 1, 0xa0}},
+		// fact(n) = n <= 1 ? 1 : n * fact(n - 1)
+		{name: "fact", params: []byte{0x7e}, result: []byte{0x7e},
+			code: []byte{0x20, 0, 0x42, 1, 0x57, 0x04, 0x7e, 0x42, 1, 0x05,
+				0x20, 0, 0x20, 0, 0x42, 1, 0x7d, 0x10, 1, 0x7e, 0x0b}},
+		// sum(n) adds 1..n in a loop
+		{name: "sum", params: []byte{0x7f}, result: []byte{0x7f}, locals: []byte{1, 1, 0x7f},
+			code: []byte{0x02, 0x40, 0x03, 0x40,
+				0x20, 0, 0x45, 0x0d, 1,
+				0x20, 1, 0x20, 0, 0x6a, 0x21, 1,
+				0x20, 0, 0x41, 1, 0x6b, 0x21, 0,
+				0x0c, 0, 0x0b, 0x0b, 0x20, 1}},
+		// pick(i) returns 10, 20 or 30 by a br_table
+		{name: "pick", params: []byte{0x7f}, result: []byte{0x7f},
+			code: []byte{0x02, 0x40, 0x02, 0x40, 0x02, 0x40,
+				0x20, 0, 0x0e, 2, 0, 1, 2, 0x0b,
+				0x41, 10, 0x0f, 0x0b,
+				0x41, 20, 0x0f, 0x0b,
+				0x41, 30}},
+		// clamp(x) truncates a f64 to an i32 and saturates
+		{name: "clamp", params: []byte{0x7c}, result: []byte{0x7f},
+			code: []byte{0x20, 0, 0xfc, 2}},
+		{name: "select", params: []byte{0x7f}, result: []byte{0x7c},
+			code: concat(f64Const(1.5), f64Const(-2), []byte{0x20, 0, 0x1b})},
+	}
+	m, err := Compile(build(funcs, nil), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, ok := m.Export("add"); !ok || !typ.Equal(FuncType{Params: []ValueType{F64, F64}, Results: []ValueType{F64}}) {
+		t.Fatalf("export add %v %v", typ, ok)
+	}
+	in := m.Instantiate()
+	tests := []struct {
+		name string
+		fn   string
+		args []uint64
+		want uint64
+	}{
+		{"add", "add", []uint64{math.Float64bits(1.25), math.Float64bits(2)}, math.Float64bits(3.25)},
+		{"fact", "fact", []uint64{10}, 3628800},
+		{"sum", "sum", []uint64{100}, 5050},
+		{"br_table first", "pick", []uint64{0}, 10},
+		{"br_table second", "pick", []uint64{1}, 20},
+		{"br_table default", "pick", []uint64{7}, 30},
+		{"trunc_sat", "clamp", []uint64{math.Float64bits(1e20)}, math.MaxInt32},
+		{"trunc_sat nan", "clamp", []uint64{math.Float64bits(math.NaN())}, 0},
+		{"select true", "select", []uint64{1}, math.Float64bits(1.5)},
+		{"select false", "select", []uint64{0}, math.Float64bits(-2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := in.Call(tt.fn, 100000, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	funcs := []testFunc{
+		{name: "spin", code: []byte{0x03, 0x40, 0x0c, 0, 0x0b}},
+		{name: "deep", code: []byte{0x10, 1}},
+		{name: "div", params: []byte{0x7f}, result: []byte{0x7f},
+			code: []byte{0x41, 1, 0x20, 0, 0x6d}},
+		// poke(addr) stores 7 at addr and loads it back
+		{name: "poke", params: []byte{0x7f}, result: []byte{0x7f},
+			code: []byte{0x20, 0, 0x41, 7, 0x36, 2, 0, 0x20, 0, 0x28, 2, 0}},
+		{name: "grow", params: []byte{0x7f}, result: []byte{0x7f},
+			code: []byte{0x20, 0, 0x40, 0}},
+		{name: "trap", code: []byte{0x00}},
+	}
+	m, err := Compile(build(funcs, []byte{0, 1}), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := m.Instantiate()
+	var trap *Trap
+	tests := []struct {
+		name string
+		fn   string
+		args []uint64
+		want []uint64
+		err  func(error) bool
+	}{
+		{name: "out of fuel", fn: "spin", err: func(err error) bool { return errors.Is(err, ErrOutOfFuel) }},
+		{name: "recursion", fn: "deep", err: func(err error) bool { return errors.Is(err, ErrStackOverflow) }},
+		{name: "divide by zero", fn: "div", args: []uint64{0}, err: func(err error) bool { return errors.As(err, &trap) }},
+		{name: "divide", fn: "div", args: []uint64{1}, want: []uint64{1}},
+		{name: "store and load", fn: "poke", args: []uint64{PageSize - 4}, want: []uint64{7}},
+		{name: "out of bounds", fn: "poke", args: []uint64{PageSize - 2}, err: func(err error) bool { return errors.As(err, &trap) }},
+		{name: "grow", fn: "grow", args: []uint64{1}, want: []uint64{1}},
+		{name: "grow over the limit", fn: "grow", args: []uint64{1}, want: []uint64{math.MaxUint32}},
+		{name: "unreachable", fn: "trap", err: func(err error) bool { return errors.As(err, &trap) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := in.Call(tt.fn, 1000000, tt.args...)
+			if tt.err != nil {
+				if err == nil || !tt.err(err) {
+					t.Fatalf("got %v, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) || got[0] != tt.want[0] {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	i32 := []byte{0x7f}
+	tests := []struct {
+		name string
+		bin  []byte
+	}{
+		{"bad magic", []byte("\x00wasm\x01\x00\x00\x00")},
+		{"import", concat([]byte("\x00asm\x01\x00\x00\x00"), section(2, vec(concat([]byte{1, 'a', 1, 'b', 3, 0x7f, 0}))))},
+		{"type mismatch", build([]testFunc{{name: "f", result: i32, code: concat(f64Const(1), []byte{0x41, 1, 0x6a})}}, nil)},
+		{"empty stack", build([]testFunc{{name: "f", result: i32, code: []byte{0x41, 1, 0x6a}}}, nil)},
+		{"values left", build([]testFunc{{name: "f", code: []byte{0x41, 1}}}, nil)},
+		{"unknown local", build([]testFunc{{name: "f", result: i32, code: []byte{0x20, 3}}}, nil)},
+		{"unknown function", build([]testFunc{{name: "f", code: []byte{0x10, 5}}}, nil)},
+		{"branch too deep", build([]testFunc{{name: "f", code: []byte{0x0c, 2}}}, nil)},
+		{"memory without memory", build([]testFunc{{name: "f", result: i32, code: []byte{0x41, 0, 0x28, 2, 0}}}, nil)},
+		{"memory over the limit", build([]testFunc{{name: "f"}}, []byte{0, 3})},
+		{"call_indirect", build([]testFunc{{name: "f", code: []byte{0x41, 0, 0x11, 0, 0}}}, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile(tt.bin, 2); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -82,6 +82,7 @@ const (
 	CmdType_UPDATESPACE CmdType = 1
 	CmdType_FLUSH       CmdType = 2
 	CmdType_SEARCHDEL   CmdType = 3
+	CmdType_CHECKSUM    CmdType = 4
 )
 
 // Enum value maps for CmdType.
@@ -91,12 +92,14 @@ var (
 		1: "UPDATESPACE",
 		2: "FLUSH",
 		3: "SEARCHDEL",
+		4: "CHECKSUM",
 	}
 	CmdType_value = map[string]int32{
 		"WRITE":       0,
 		"UPDATESPACE": 1,
 		"FLUSH":       2,
 		"SEARCHDEL":   3,
+		"CHECKSUM":    4,
 	}
 )
 
@@ -591,12 +594,13 @@ var file_raftcmd_proto_rawDesc = []byte{
 	0x45, 0x41, 0x54, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45,
 	0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x42, 0x55, 0x4c, 0x4b, 0x10, 0x02, 0x12, 0x07, 0x0a, 0x03,
 	0x47, 0x45, 0x54, 0x10, 0x03, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x45, 0x41, 0x52, 0x43, 0x48, 0x10,
-	0x04, 0x2a, 0x3f, 0x0a, 0x07, 0x43, 0x6d, 0x64, 0x54, 0x79, 0x70, 0x65, 0x12, 0x09, 0x0a, 0x05,
+	0x04, 0x2a, 0x4d, 0x0a, 0x07, 0x43, 0x6d, 0x64, 0x54, 0x79, 0x70, 0x65, 0x12, 0x09, 0x0a, 0x05,
 	0x57, 0x52, 0x49, 0x54, 0x45, 0x10, 0x00, 0x12, 0x0f, 0x0a, 0x0b, 0x55, 0x50, 0x44, 0x41, 0x54,
 	0x45, 0x53, 0x50, 0x41, 0x43, 0x45, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05, 0x46, 0x4c, 0x55, 0x53,
 	0x48, 0x10, 0x02, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x45, 0x41, 0x52, 0x43, 0x48, 0x44, 0x45, 0x4c,
-	0x10, 0x03, 0x42, 0x0e, 0x48, 0x01, 0x5a, 0x0a, 0x2e, 0x2f, 0x76, 0x65, 0x61, 0x72, 0x63, 0x68,
-	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x10, 0x03, 0x12, 0x0c, 0x0a, 0x08, 0x43, 0x48, 0x45, 0x43, 0x4b, 0x53, 0x55, 0x4d, 0x10, 0x04,
+	0x42, 0x0e, 0x48, 0x01, 0x5a, 0x0a, 0x2e, 0x2f, 0x76, 0x65, 0x61, 0x72, 0x63, 0x68, 0x70, 0x62,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -15,16 +15,34 @@
 package vearchpb
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
-
-	"github.com/pkg/errors"
 )
 
+// VearchErr is an error of a code, errors of the same code are the same
+// condition as only the code and message cross rpc, so errors.Is matches
+// them by code. The cause it wraps is kept for errors.Is and errors.As
 type VearchErr struct {
 	error *Error
+	cause error
 }
 
+// sentinel errors of common conditions, match them by errors.Is and wrap
+// them by NewError to add a message
+var (
+	ErrSpaceNotFound      = NewError(ErrorEnum_SPACE_NOT_EXIST, nil)
+	ErrDBNotFound         = NewError(ErrorEnum_DB_NOT_EXIST, nil)
+	ErrPartitionNotFound  = NewError(ErrorEnum_PARTITION_NOT_EXIST, nil)
+	ErrPartitionNotLeader = NewError(ErrorEnum_PARTITION_NOT_LEADER, nil)
+	ErrPartitionNoLeader  = NewError(ErrorEnum_PARTITION_NO_LEADER, nil)
+	ErrOverloaded         = NewError(ErrorEnum_PARTITION_RESOURCE_EXHAUSTED, nil)
+	ErrUnavailable        = NewError(ErrorEnum_SERVICE_UNAVAILABLE, nil)
+	ErrTimeout            = NewError(ErrorEnum_TIMEOUT, nil)
+	ErrAuthFailed         = NewError(ErrorEnum_AUTHENTICATION_FAILED, nil)
+)
+
 func (v *VearchErr) Error() string {
 	if v.error == nil {
 		return ""
@@ -39,21 +57,30 @@ func (v *VearchErr) GetError() *Error {
 	return v.error
 }
 
+func (v *VearchErr) Unwrap() error {
+	return v.cause
+}
+
+// Is matches a VearchErr of the same code
+func (v *VearchErr) Is(target error) bool {
+	t, ok := target.(*VearchErr)
+	return ok && v.error != nil && t.error != nil && v.error.Code == t.error.Code
+}
+
+// NewError returns an error of code caused by err, a VearchErr err keeps its
+// own code
 func NewError(code ErrorEnum, err error) (vErr *VearchErr) {
 	if err == nil {
-		vErr = &VearchErr{error: &Error{Code: code, Msg: ErrMsg(code)}}
-		return
+		return &VearchErr{error: &Error{Code: code, Msg: ErrMsg(code)}}
 	}
 	if vErr, ok := err.(*VearchErr); ok {
-		return vErr
+		// a copy, so callers changing it never change a sentinel
+		return &VearchErr{error: &Error{Code: vErr.GetError().GetCode(), Msg: vErr.Error()}, cause: vErr.cause}
 	}
 	if strings.HasPrefix(err.Error(), ErrMsg(code)) {
-		vErr = &VearchErr{error: &Error{Code: code, Msg: err.Error()}}
-		return
-	} else {
-		vErr = &VearchErr{error: &Error{Code: code, Msg: ErrMsg(code) + ":" + err.Error()}}
-		return
+		return &VearchErr{error: &Error{Code: code, Msg: err.Error()}, cause: err}
 	}
+	return &VearchErr{error: &Error{Code: code, Msg: ErrMsg(code) + ":" + err.Error()}, cause: err}
 }
 
 func ErrMsg(code ErrorEnum) (s string) {
@@ -65,13 +92,40 @@ func Wrap(err error, s string) error {
 		return nil
 	}
 	if vErr, ok := err.(*VearchErr); ok {
-		vErr.error.Msg = fmt.Sprintf("%s:%s", s, vErr.error.Msg)
-		return vErr
+		return &VearchErr{error: &Error{Code: vErr.GetError().GetCode(), Msg: fmt.Sprintf("%s:%s", s, vErr.Error())}, cause: vErr}
 	}
-	return NewError(0, errors.Wrap(err, s))
+	return NewError(0, fmt.Errorf("%s: %w", s, err))
 }
 
 func NewErrorInfo(code ErrorEnum, msg string) (vErr *VearchErr) {
 	vErr = &VearchErr{error: &Error{Code: code, Msg: msg}}
 	return
 }
+
+// Code is the code of err, errors of context deadline are timeouts and
+// others are internal errors
+func Code(err error) ErrorEnum {
+	var vErr *VearchErr
+	switch {
+	case err == nil:
+		return ErrorEnum_SUCCESS
+	case errors.As(err, &vErr) && vErr.GetError() != nil:
+		return vErr.GetError().Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorEnum_TIMEOUT
+	default:
+		return ErrorEnum_INTERNAL_ERROR
+	}
+}
+
+// ToError is the rpc error of err, it keeps the message of err
+func ToError(err error) *Error {
+	if err == nil {
+		return &Error{Code: ErrorEnum_SUCCESS}
+	}
+	var vErr *VearchErr
+	if errors.As(err, &vErr) && vErr == err {
+		return vErr.GetError()
+	}
+	return &Error{Code: Code(err), Msg: err.Error()}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package vearchpb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorsIs(t *testing.T) {
+	cause := errors.New("disk is full")
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{name: "sentinel", err: ErrSpaceNotFound, target: ErrSpaceNotFound, want: true},
+		{name: "same code", err: NewError(ErrorEnum_SPACE_NOT_EXIST, fmt.Errorf("space ts")), target: ErrSpaceNotFound, want: true},
+		{name: "other code", err: NewError(ErrorEnum_DB_NOT_EXIST, nil), target: ErrSpaceNotFound},
+		{name: "wrapped by fmt", err: fmt.Errorf("search: %w", ErrPartitionNotLeader), target: ErrPartitionNotLeader, want: true},
+		{name: "wrapped by Wrap", err: Wrap(ErrOverloaded, "write"), target: ErrOverloaded, want: true},
+		{name: "cause", err: NewError(ErrorEnum_PARTITION_RESOURCE_EXHAUSTED, cause), target: cause, want: true},
+		{name: "rpc error", err: NewErrorInfo(ErrorEnum_PARTITION_NOT_LEADER, "not leader"), target: ErrPartitionNotLeader, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrorKeepsSentinel(t *testing.T) {
+	err := NewError(ErrorEnum_INTERNAL_ERROR, ErrTimeout)
+	err.GetError().Msg = "changed"
+	if ErrTimeout.Error() != ErrMsg(ErrorEnum_TIMEOUT) {
+		t.Errorf("sentinel is changed to %s", ErrTimeout.Error())
+	}
+	if err.GetError().Code != ErrorEnum_TIMEOUT {
+		t.Errorf("code %v, want the code of the sentinel", err.GetError().Code)
+	}
+	if Wrap(ErrTimeout, "search"); ErrTimeout.Error() != ErrMsg(ErrorEnum_TIMEOUT) {
+		t.Errorf("sentinel is changed to %s by Wrap", ErrTimeout.Error())
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorEnum
+	}{
+		{name: "nil", want: ErrorEnum_SUCCESS},
+		{name: "vearch", err: fmt.Errorf("get: %w", ErrDBNotFound), want: ErrorEnum_DB_NOT_EXIST},
+		{name: "deadline", err: fmt.Errorf("search: %w", context.DeadlineExceeded), want: ErrorEnum_TIMEOUT},
+		{name: "other", err: errors.New("broken"), want: ErrorEnum_INTERNAL_ERROR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Code(tt.err); got != tt.want {
+				t.Errorf("Code() = %v, want %v", got, tt.want)
+			}
+			if tt.err != nil && ToError(tt.err).Code != tt.want {
+				t.Errorf("ToError() code = %v, want %v", ToError(tt.err).Code, tt.want)
+			}
+		})
+	}
+}
@@ -54,6 +54,10 @@ type Writer interface {
 }
 
 // Engine is the interface that wraps the core operations of a document store.
+// An engine is opened by the Builder it is registered with and writes
+// documents by its Writer, searches and queries by its Reader filling
+// FlatBytes of the response with the marshaled SearchResponse, replicates by
+// NewSnapshot and ApplySnapshot and reports its stats by GetEngineStatus.
 type Engine interface {
 	Reader() Reader
 	Writer() Writer
@@ -42,15 +42,10 @@ var _ engine.Engine = &gammaEngine{}
 
 var indexLocker sync.Mutex
 
-type EngineConfig struct {
-	// Path is the data directory.
-	Path string
-	// ExtraOptions contains extension options using a json format ("{key1:value1,key2:value2}").
-	ExtraOptions map[string]interface{}
-	// Schema
-	Space *entity.Space
-	// partitionID
-	PartitionID entity.PartitionID
+type EngineConfig = engine.Config
+
+func init() {
+	engine.Register(engine.DefaultEngine, entity.GammaIndexTypes, Build)
 }
 
 func Build(cfg EngineConfig) (e engine.Engine, err error) {
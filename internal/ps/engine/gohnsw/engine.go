@@ -0,0 +1,287 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package gohnsw is an engine in pure go serving the spaces of index type
+// go-hnsw. It keeps documents in memory, indexes vectors with an hnsw graph
+// per vector field and dumps the documents to a file on flush, trading the
+// performance of gamma for a ps which runs without the gamma library, like in
+// tests and on the platforms gamma is not built for. A ps binary without cgo
+// imports this engine and leaves out gammacb.
+package gohnsw
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/distance"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
+	"github.com/vearch/vearch/v3/internal/ps/engine/gohnsw/hnsw"
+	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
+)
+
+// IndexType is the index type of the spaces served by this engine
+const IndexType = "go-hnsw"
+
+// index status of gamma, the graph is always built
+const indexStatusIndexed = 2
+
+var _ engine.Engine = &goEngine{}
+
+func init() {
+	engine.Register("gohnsw", []string{IndexType}, Build)
+}
+
+// vectorIndex is the graph of a vector field and its vectors by docid
+type vectorIndex struct {
+	dimension int
+	metric    distance.Metric
+	graph     *hnsw.Graph
+	vectors   [][]float32
+}
+
+// cost orders the vectors of the graph, the score for ascending metrics
+func (vi *vectorIndex) cost(query, vector []float32) float64 {
+	if vi.metric.Ascending() {
+		return vi.metric.Score(query, vector)
+	}
+	return -vi.metric.Score(query, vector)
+}
+
+type goEngine struct {
+	path         string
+	indexMapping *mapping.IndexMapping
+	space        *entity.Space
+	partitionID  entity.PartitionID
+	proMap       map[string]*entity.SpaceProperties
+	params       entity.IndexParams
+	// ascending tells whether a smaller score of the metric is nearer
+	ascending bool
+
+	reader *readerImpl
+	writer *writerImpl
+
+	// lock guards the documents and graphs, dumps hold it for reading
+	lock    sync.RWMutex
+	docs    []*vearchpb.Document
+	ids     map[string]int
+	live    int
+	indexes map[string]*vectorIndex
+	// changed tells the documents changed since the last dump
+	changed bool
+
+	// dumpLock serializes the dumps of flush and commit
+	dumpLock  sync.Mutex
+	hasClosed bool
+}
+
+func Build(cfg engine.Config) (engine.Engine, error) {
+	return New(cfg)
+}
+
+func New(cfg engine.Config) (engine.Engine, error) {
+	indexMapping, err := mapping.Space2Mapping(cfg.Space)
+	if err != nil {
+		return nil, err
+	}
+	proMap := cfg.Space.SpaceProperties
+	if proMap == nil {
+		if proMap, err = entity.UnmarshalPropertyJSON(cfg.Space.Fields); err != nil {
+			return nil, err
+		}
+	}
+
+	ge := &goEngine{
+		path:         cfg.Path,
+		indexMapping: indexMapping,
+		space:        cfg.Space,
+		partitionID:  cfg.PartitionID,
+		proMap:       proMap,
+	}
+	if cfg.Space.Index != nil && len(cfg.Space.Index.Params) > 0 {
+		if err := json.Unmarshal(cfg.Space.Index.Params, &ge.params); err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index params:%s json.Unmarshal err :[%s]", cfg.Space.Index.Params, err.Error()))
+		}
+	}
+	if ge.params.MetricType == "" {
+		ge.params.MetricType = entity.DefaultMetricType
+	}
+	ge.ascending = ge.params.MetricType == distance.L2
+	ge.reader = &readerImpl{engine: ge}
+	ge.writer = &writerImpl{engine: ge}
+
+	if err := ge.reset(); err != nil {
+		return nil, err
+	}
+	if err := ge.load(); err != nil {
+		return nil, err
+	}
+	log.Info("open go-hnsw engine by path:[%s], space: %s, docs: [%d]", cfg.Path, cfg.Space.Name, ge.live)
+	return ge, nil
+}
+
+// reset drops the documents and makes an empty graph for every vector field
+func (ge *goEngine) reset() error {
+	ge.docs, ge.ids, ge.live = nil, make(map[string]int), 0
+	ge.indexes = make(map[string]*vectorIndex)
+	for name, pro := range ge.proMap {
+		if pro.FieldType != vearchpb.FieldType_VECTOR {
+			continue
+		}
+		metric, err := distance.New(ge.params.MetricType, pro.Dimension, nil)
+		if err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+		}
+		index := &vectorIndex{dimension: pro.Dimension, metric: metric}
+		index.graph = hnsw.New(index.cost, hnsw.Config{M: ge.params.Nlinks, EfConstruction: ge.params.EfConstruction})
+		ge.indexes[name] = index
+	}
+	return nil
+}
+
+func (ge *goEngine) GetSpace() *entity.Space {
+	return ge.space
+}
+
+func (ge *goEngine) GetPartitionID() entity.PartitionID {
+	return ge.partitionID
+}
+
+func (ge *goEngine) Reader() engine.Reader {
+	return ge.reader
+}
+
+func (ge *goEngine) Writer() engine.Writer {
+	return ge.writer
+}
+
+func (ge *goEngine) UpdateMapping(space *entity.Space) error {
+	var oldProperties, newProperties interface{}
+
+	if err := json.Unmarshal([]byte(ge.space.Fields), &oldProperties); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unmarshal old space properties:[%s] has err:[%s] ", ge.space.Fields, err.Error()))
+	}
+
+	if err := json.Unmarshal([]byte(space.Fields), &newProperties); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unmarshal new space properties:[%s] has err :[%s]", space.Fields, err.Error()))
+	}
+
+	if !reflect.DeepEqual(oldProperties, newProperties) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("go-hnsw engine not support "))
+	}
+
+	ge.space = space
+
+	return nil
+}
+
+func (ge *goEngine) GetMapping() *mapping.IndexMapping {
+	return ge.indexMapping
+}
+
+// Optimize does nothing, the graphs are built by the writes
+func (ge *goEngine) Optimize() error {
+	return nil
+}
+
+// RebuildIndex builds the graphs again from the live documents, dropping the
+// deleted ones they route through
+func (ge *goEngine) RebuildIndex(drop_before_rebuild int, limit_cpu int, describe int) error {
+	ge.lock.Lock()
+	defer ge.lock.Unlock()
+	docs := make([]*vearchpb.Document, 0, ge.live)
+	for _, doc := range ge.docs {
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	if err := ge.reset(); err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := ge.put(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ge *goEngine) Rebuild(drop_before_rebuild int, limit_cpu int, describe int) error {
+	go func() {
+		log.Info("RebuildIndex index:[%d] begin", ge.partitionID)
+		if e := ge.RebuildIndex(drop_before_rebuild, limit_cpu, describe); e != nil {
+			log.Error("RebuildIndex index:[%d] has err %v", ge.partitionID, e.Error())
+			return
+		}
+		log.Info("RebuildIndex index:[%d] end", ge.partitionID)
+	}()
+	return nil
+}
+
+func (ge *goEngine) IndexInfo() (int, int, int) {
+	status := &entity.EngineStatus{}
+	if err := ge.GetEngineStatus(status); err != nil {
+		return 0, 0, 0
+	}
+	return int(status.IndexStatus), int(status.MinIndexedNum), int(status.MaxDocid)
+}
+
+func (ge *goEngine) GetEngineStatus(status *entity.EngineStatus) error {
+	ge.lock.RLock()
+	defer ge.lock.RUnlock()
+	status.IndexStatus = indexStatusIndexed
+	status.DocNum = int32(ge.live)
+	status.MinIndexedNum = int32(len(ge.docs))
+	status.MaxDocid = int32(len(ge.docs) - 1)
+	return nil
+}
+
+func (ge *goEngine) HasClosed() bool {
+	return ge.hasClosed
+}
+
+func (ge *goEngine) Close() {
+	ge.lock.Lock()
+	defer ge.lock.Unlock()
+	ge.docs, ge.ids, ge.indexes = nil, nil, nil
+	ge.hasClosed = true
+	log.Info("close go-hnsw engine pid:[%d]", ge.partitionID)
+}
+
+// closed tells the engine is closed, callers hold lock
+func (ge *goEngine) closed() error {
+	if ge.hasClosed {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_CLOSED, nil)
+	}
+	return nil
+}
+
+// SetEngineCfg takes no config, the cache and path of gamma do not apply
+func (ge *goEngine) SetEngineCfg(configJson []byte) error {
+	return nil
+}
+
+func (ge *goEngine) GetEngineCfg(config *entity.EngineConfig) error {
+	path := ge.path
+	config.Path = &path
+	return nil
+}
+
+func (ge *goEngine) BackupSpace(command string) error {
+	return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("go-hnsw engine not support backup"))
+}
@@ -0,0 +1,252 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gohnsw
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/vearch/vearch/v3/internal/engine/idl/fbs-gen/go/gamma_api"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
+	"google.golang.org/protobuf/proto"
+)
+
+const testFields = `[
+	{"name": "vec", "type": "vector", "dimension": 2, "index": {"name": "gi", "type": "go-hnsw", "params": {"metric_type": "L2"}}},
+	{"name": "age", "type": "integer", "index": {"name": "ai", "type": "SCALAR"}},
+	{"name": "tag", "type": "string", "index": {"name": "ti", "type": "SCALAR"}}
+]`
+
+func openTestEngine(t *testing.T, path string) engine.Engine {
+	t.Helper()
+	space := &entity.Space{
+		Name:   "s",
+		Fields: []byte(testFields),
+		Index:  &entity.Index{Name: "gi", Type: IndexType, Params: []byte(`{"metric_type": "L2"}`)},
+	}
+	e, err := engine.Build(engine.Config{Path: path, Space: space, PartitionID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// serialize lays out fields like the gamma sdk does
+func serialize(fields []*vearchpb.Field) []byte {
+	builder := flatbuffers.NewBuilder(0)
+	offsets := make([]flatbuffers.UOffsetT, len(fields))
+	for i, f := range fields {
+		name := builder.CreateString(f.Name)
+		value := builder.CreateByteVector(f.Value)
+		gamma_api.FieldStart(builder)
+		gamma_api.FieldAddName(builder, name)
+		gamma_api.FieldAddValue(builder, value)
+		gamma_api.FieldAddDataType(builder, gamma_api.DataType(f.Type))
+		offsets[i] = gamma_api.FieldEnd(builder)
+	}
+	gamma_api.DocStartFieldsVector(builder, len(fields))
+	for i := len(offsets) - 1; i >= 0; i-- {
+		builder.PrependUOffsetT(offsets[i])
+	}
+	vector := builder.EndVector(len(fields))
+	gamma_api.DocStart(builder)
+	gamma_api.DocAddFields(builder, vector)
+	builder.Finish(gamma_api.DocEnd(builder))
+	return builder.FinishedBytes()
+}
+
+func testDoc(id string, x, y float32, age int32, tag string) []byte {
+	vec, _ := cbbytes.FloatArrayByte([]float32{x, y})
+	return serialize([]*vearchpb.Field{
+		{Name: entity.IdField, Type: vearchpb.FieldType_STRING, Value: []byte(id)},
+		{Name: "vec", Type: vearchpb.FieldType_VECTOR, Value: vec},
+		{Name: "age", Type: vearchpb.FieldType_INT, Value: cbbytes.Int32ToByte(age)},
+		{Name: "tag", Type: vearchpb.FieldType_STRING, Value: []byte(tag)},
+	})
+}
+
+func write(t *testing.T, e engine.Engine, cmd *vearchpb.DocCmd) string {
+	t.Helper()
+	err := e.Writer().Write(context.Background(), cmd)
+	if err == nil {
+		return ""
+	}
+	vErr := vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err)
+	if vErr.GetError().Code != vearchpb.ErrorEnum_SUCCESS {
+		t.Fatalf("write err: %v", err)
+	}
+	// the codes follow the name of the error code
+	msg := vErr.GetError().Msg
+	return msg[strings.LastIndex(msg, ":")+1:]
+}
+
+func search(t *testing.T, e engine.Engine, request *vearchpb.SearchRequest) []string {
+	t.Helper()
+	request.Head = &vearchpb.RequestHead{}
+	response := &vearchpb.SearchResponse{}
+	if err := e.Reader().Search(context.Background(), request, response); err != nil {
+		t.Fatal(err)
+	}
+	results := &vearchpb.SearchResponse{}
+	if err := proto.Unmarshal(response.FlatBytes, results); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0)
+	for _, item := range results.Results[0].ResultItems {
+		ids = append(ids, item.PKey)
+	}
+	return ids
+}
+
+func TestEngine(t *testing.T) {
+	path := t.TempDir()
+	e := openTestEngine(t, path)
+	codes := write(t, e, &vearchpb.DocCmd{Type: vearchpb.OpType_BULK, Docs: [][]byte{
+		testDoc("a", 0, 0, 10, "red"),
+		testDoc("b", 1, 0, 20, "blue"),
+		testDoc("c", 5, 5, 30, "red"),
+		serialize([]*vearchpb.Field{{Name: "tag", Type: vearchpb.FieldType_STRING, Value: []byte("no id")}}),
+	}})
+	if codes != "0,0,0,1," {
+		t.Fatalf("bulk codes %q, want 0,0,0,1,", codes)
+	}
+
+	query, _ := cbbytes.FloatArrayByte([]float32{0.9, 0})
+	lower, upper := cbbytes.Int32ToByte(15), cbbytes.Int32ToByte(40)
+	tests := []struct {
+		name    string
+		request *vearchpb.SearchRequest
+		want    string
+	}{
+		{
+			name:    "nearest",
+			request: &vearchpb.SearchRequest{TopN: 2},
+			want:    "b,a",
+		},
+		{
+			name:    "range filter",
+			request: &vearchpb.SearchRequest{TopN: 2, RangeFilters: []*vearchpb.RangeFilter{{Field: "age", LowerValue: lower, UpperValue: upper, IncludeLower: true}}},
+			want:    "b,c",
+		},
+		{
+			name:    "term filter",
+			request: &vearchpb.SearchRequest{TopN: 3, TermFilters: []*vearchpb.TermFilter{{Field: "tag", Value: []byte("red"), IsUnion: 1}}},
+			want:    "a,c",
+		},
+		{
+			name:    "not in filter",
+			request: &vearchpb.SearchRequest{TopN: 3, TermFilters: []*vearchpb.TermFilter{{Field: "tag", Value: []byte("red\001green"), IsUnion: termOperatorNotIn}}},
+			want:    "b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.request.VecFields = []*vearchpb.VectorQuery{{Name: "vec", Value: query}}
+			if got := strings.Join(search(t, e, tt.request), ","); got != tt.want {
+				t.Errorf("search got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// an update merges the fields it does not write
+	vec, _ := cbbytes.FloatArrayByte([]float32{9, 9})
+	write(t, e, &vearchpb.DocCmd{Type: vearchpb.OpType_BULK, Docs: [][]byte{serialize([]*vearchpb.Field{
+		{Name: entity.IdField, Type: vearchpb.FieldType_STRING, Value: []byte("b")},
+		{Name: "vec", Type: vearchpb.FieldType_VECTOR, Value: vec},
+	})}})
+	doc := &vearchpb.Document{PKey: "b"}
+	if err := e.Reader().GetDoc(context.Background(), doc, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Fields) != 4 {
+		t.Errorf("updated doc has fields %v, want 4", doc.Fields)
+	}
+	write(t, e, &vearchpb.DocCmd{Type: vearchpb.OpType_DELETE, Doc: []byte("a")})
+	if err := e.Writer().Write(context.Background(), &vearchpb.DocCmd{Type: vearchpb.OpType_DELETE, Doc: []byte("a")}); err == nil {
+		t.Error("delete of a deleted doc should fail")
+	}
+	want := "c,b"
+	if got := strings.Join(search(t, e, &vearchpb.SearchRequest{TopN: 3, VecFields: []*vearchpb.VectorQuery{{Name: "vec", Value: query}}}), ","); got != want {
+		t.Errorf("search after update got %s, want %s", got, want)
+	}
+
+	// documents are read back by docid in order
+	ids := make([]string, 0)
+	for docID := -1; ; {
+		doc := &vearchpb.Document{PKey: strconv.Itoa(docID)}
+		if err := e.Reader().GetDoc(context.Background(), doc, true, true); err != nil {
+			break
+		}
+		for _, f := range doc.Fields {
+			switch f.Name {
+			case docIdField:
+				docID = int(cbbytes.Bytes2Int32(f.Value))
+			case entity.IdField:
+				ids = append(ids, string(f.Value))
+			}
+		}
+	}
+	if strings.Join(ids, ",") != "c,b" {
+		t.Errorf("docs by docid %v, want [c b]", ids)
+	}
+
+	if err := e.Writer().Flush(context.Background(), 42); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := e.NewSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Close()
+
+	// a reopened engine and a follower of the snapshot have the documents
+	for _, reopened := range []engine.Engine{openTestEngine(t, path), applySnapshot(t, snapshot)} {
+		if sn, _ := reopened.Reader().ReadSN(context.Background()); sn != 42 {
+			t.Errorf("sn %d, want 42", sn)
+		}
+		if n, _ := reopened.Reader().DocCount(context.Background()); n != 2 {
+			t.Errorf("doc count %d, want 2", n)
+		}
+		if got := strings.Join(search(t, reopened, &vearchpb.SearchRequest{TopN: 3, VecFields: []*vearchpb.VectorQuery{{Name: "vec", Value: query}}}), ","); got != want {
+			t.Errorf("search after reopen got %s, want %s", got, want)
+		}
+		reopened.Close()
+	}
+}
+
+type snapshotIterator struct {
+	snapshot interface{ Next() ([]byte, error) }
+}
+
+func (it *snapshotIterator) Next() ([]byte, error) {
+	return it.snapshot.Next()
+}
+
+func applySnapshot(t *testing.T, snapshot interface{ Next() ([]byte, error) }) engine.Engine {
+	t.Helper()
+	path := t.TempDir()
+	follower := openTestEngine(t, path)
+	follower.Close()
+	if err := follower.ApplySnapshot(nil, &snapshotIterator{snapshot: snapshot}); err != nil {
+		t.Fatal(err)
+	}
+	return openTestEngine(t, path)
+}
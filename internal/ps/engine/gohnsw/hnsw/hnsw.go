@@ -0,0 +1,309 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package hnsw is a hierarchical navigable small world graph in pure go, the
+// vector index of the go-hnsw engine.
+package hnsw
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+const (
+	// DefaultM is the links of a node on the upper levels, twice of them on
+	// level 0
+	DefaultM              = 32
+	DefaultEfConstruction = 40
+	DefaultEfSearch       = 64
+)
+
+// Cost tells how far a vector is from a query, smaller is nearer
+type Cost func(query, vector []float32) float64
+
+// Config tunes a graph, zero values take the defaults
+type Config struct {
+	M              int
+	EfConstruction int
+	Seed           int64
+}
+
+// Result is a node found by a search
+type Result struct {
+	ID   int
+	Cost float64
+}
+
+// Graph indexes vectors by the ids they are added with. Deleted ids stay in
+// the graph to route searches but are never returned, the graph is rebuilt
+// from the live vectors when the engine loads.
+type Graph struct {
+	mu             sync.RWMutex
+	cost           Cost
+	m              int
+	m0             int
+	efConstruction int
+	levelMult      float64
+	rng            *rand.Rand
+
+	vectors  [][]float32
+	links    [][][]int32
+	deleted  []bool
+	entry    int32
+	maxLevel int
+	live     int
+}
+
+// New returns an empty graph scoring vectors by cost
+func New(cost Cost, cfg Config) *Graph {
+	if cfg.M <= 1 {
+		cfg.M = DefaultM
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = DefaultEfConstruction
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = 1
+	}
+	return &Graph{
+		cost:           cost,
+		m:              cfg.M,
+		m0:             2 * cfg.M,
+		efConstruction: cfg.EfConstruction,
+		levelMult:      1 / math.Log(float64(cfg.M)),
+		rng:            rand.New(rand.NewSource(cfg.Seed)),
+		entry:          -1,
+	}
+}
+
+// Len returns the live vectors of the graph
+func (g *Graph) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.live
+}
+
+// Add inserts vector by id, ids are expected to grow so the graph is laid out
+// in slices, a vector added twice by id replaces nothing and is ignored
+func (g *Graph) Add(id int, vector []float32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for len(g.vectors) <= id {
+		g.vectors = append(g.vectors, nil)
+		g.links = append(g.links, nil)
+		g.deleted = append(g.deleted, false)
+	}
+	if g.vectors[id] != nil {
+		return
+	}
+	level := int(math.Floor(-math.Log(1-g.rng.Float64()) * g.levelMult))
+	g.vectors[id] = vector
+	g.links[id] = make([][]int32, level+1)
+	g.live++
+
+	if g.entry < 0 {
+		g.entry, g.maxLevel = int32(id), level
+		return
+	}
+
+	ep := []Result{{ID: int(g.entry), Cost: g.cost(vector, g.vectors[g.entry])}}
+	for lc := g.maxLevel; lc > level; lc-- {
+		ep = g.searchLayer(vector, ep, 1, lc, nil)
+	}
+	for lc := min(level, g.maxLevel); lc >= 0; lc-- {
+		found := g.searchLayer(vector, ep, g.efConstruction, lc, nil)
+		limit := g.m
+		if lc == 0 {
+			limit = g.m0
+		}
+		neighbors := g.selectNeighbors(found, limit)
+		g.links[id][lc] = make([]int32, 0, len(neighbors))
+		for _, n := range neighbors {
+			g.links[id][lc] = append(g.links[id][lc], int32(n.ID))
+			g.link(n.ID, id, lc, limit)
+		}
+		ep = found
+	}
+	if level > g.maxLevel {
+		g.entry, g.maxLevel = int32(id), level
+	}
+}
+
+// Delete hides id from the searches
+func (g *Graph) Delete(id int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if id < 0 || id >= len(g.vectors) || g.vectors[id] == nil || g.deleted[id] {
+		return
+	}
+	g.deleted[id] = true
+	g.live--
+}
+
+// Search returns the k nearest live ids to query sorted by cost, looking at ef
+// candidates on level 0. accept filters the ids returned, nil accepts all
+func (g *Graph) Search(query []float32, k, ef int, accept func(id int) bool) []Result {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.entry < 0 || k <= 0 {
+		return nil
+	}
+	if ef < k {
+		ef = k
+	}
+	ep := []Result{{ID: int(g.entry), Cost: g.cost(query, g.vectors[g.entry])}}
+	for lc := g.maxLevel; lc > 0; lc-- {
+		ep = g.searchLayer(query, ep, 1, lc, nil)
+	}
+	live := func(id int) bool {
+		return !g.deleted[id] && (accept == nil || accept(id))
+	}
+	results := g.searchLayer(query, ep, ef, 0, live)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// link adds to the links of from on level lc, pruning them to the nearest
+// limit ones
+func (g *Graph) link(from, to, lc, limit int) {
+	links := append(g.links[from][lc], int32(to))
+	if len(links) > limit {
+		candidates := make([]Result, len(links))
+		for i, n := range links {
+			candidates[i] = Result{ID: int(n), Cost: g.cost(g.vectors[from], g.vectors[n])}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Cost < candidates[j].Cost })
+		selected := g.selectNeighbors(candidates, limit)
+		links = links[:0]
+		for _, n := range selected {
+			links = append(links, int32(n.ID))
+		}
+	}
+	g.links[from][lc] = links
+}
+
+// selectNeighbors picks up to limit of the sorted candidates by the heuristic
+// of the paper, a candidate nearer to a picked one than to the query is
+// skipped so the links spread out, the skipped fill the rest
+func (g *Graph) selectNeighbors(candidates []Result, limit int) []Result {
+	if len(candidates) <= limit {
+		return candidates
+	}
+	selected := make([]Result, 0, limit)
+	skipped := make([]Result, 0, len(candidates))
+	for _, c := range candidates {
+		if len(selected) >= limit {
+			break
+		}
+		good := true
+		for _, s := range selected {
+			if g.cost(g.vectors[c.ID], g.vectors[s.ID]) < c.Cost {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	for _, c := range skipped {
+		if len(selected) >= limit {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// searchLayer returns the ef nearest nodes to query on level lc found from
+// the entry points sorted by cost. With accept the traversal goes through all
+// nodes but only the accepted ones are returned
+func (g *Graph) searchLayer(query []float32, entries []Result, ef, lc int, accept func(id int) bool) []Result {
+	visited := make(map[int]struct{}, ef*4)
+	candidates := &costHeap{}
+	results := &costHeap{max: true}
+	for _, e := range entries {
+		visited[e.ID] = struct{}{}
+		heap.Push(candidates, e)
+		if accept == nil || accept(e.ID) {
+			heap.Push(results, e)
+		}
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(Result)
+		if results.Len() >= ef && c.Cost > results.items[0].Cost {
+			break
+		}
+		if lc >= len(g.links[c.ID]) {
+			continue
+		}
+		for _, n := range g.links[c.ID][lc] {
+			id := int(n)
+			if _, ok := visited[id]; ok {
+				continue
+			}
+			visited[id] = struct{}{}
+			cost := g.cost(query, g.vectors[id])
+			if results.Len() < ef || cost < results.items[0].Cost {
+				heap.Push(candidates, Result{ID: id, Cost: cost})
+				if accept == nil || accept(id) {
+					heap.Push(results, Result{ID: id, Cost: cost})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+	sorted := make([]Result, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(Result)
+	}
+	return sorted
+}
+
+// costHeap is a min heap of results by cost, a max heap with max
+type costHeap struct {
+	items []Result
+	max   bool
+}
+
+func (h *costHeap) Len() int { return len(h.items) }
+
+func (h *costHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].Cost > h.items[j].Cost
+	}
+	return h.items[i].Cost < h.items[j].Cost
+}
+
+func (h *costHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *costHeap) Push(x any) { h.items = append(h.items, x.(Result)) }
+
+func (h *costHeap) Pop() any {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hnsw
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func l2(query, vector []float32) float64 {
+	cost := 0.0
+	for i, x := range vector {
+		d := float64(x) - float64(query[i])
+		cost += d * d
+	}
+	return cost
+}
+
+func randomVectors(rng *rand.Rand, n, dimension int) [][]float32 {
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dimension)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.Float32()
+		}
+	}
+	return vectors
+}
+
+func exact(vectors [][]float32, query []float32, k int, accept func(id int) bool) []int {
+	results := make([]Result, 0, len(vectors))
+	for id, v := range vectors {
+		if v != nil && (accept == nil || accept(id)) {
+			results = append(results, Result{ID: id, Cost: l2(query, v)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Cost < results[j].Cost })
+	ids := make([]int, 0, k)
+	for i := 0; i < k && i < len(results); i++ {
+		ids = append(ids, results[i].ID)
+	}
+	return ids
+}
+
+func recall(got []Result, want []int) float64 {
+	if len(want) == 0 {
+		return 1
+	}
+	hit := make(map[int]bool, len(want))
+	for _, id := range want {
+		hit[id] = true
+	}
+	n := 0
+	for _, r := range got {
+		if hit[r.ID] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(want))
+}
+
+func TestGraph_Search(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	vectors := randomVectors(rng, 2000, 16)
+	g := New(l2, Config{M: 16, EfConstruction: 100})
+	for id, v := range vectors {
+		g.Add(id, v)
+	}
+	if g.Len() != len(vectors) {
+		t.Fatalf("len %d, want %d", g.Len(), len(vectors))
+	}
+
+	for id := 0; id < len(vectors); id += 3 {
+		g.Delete(id)
+		vectors[id] = nil
+	}
+	even := func(id int) bool { return id%2 == 0 }
+
+	tests := []struct {
+		name   string
+		accept func(id int) bool
+	}{
+		{name: "all"},
+		{name: "filtered", accept: even},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total := 0.0
+			queries := randomVectors(rng, 50, 16)
+			for _, q := range queries {
+				got := g.Search(q, 10, 100, tt.accept)
+				for i, r := range got {
+					if vectors[r.ID] == nil {
+						t.Fatalf("deleted id %d returned", r.ID)
+					}
+					if tt.accept != nil && !tt.accept(r.ID) {
+						t.Fatalf("rejected id %d returned", r.ID)
+					}
+					if i > 0 && got[i-1].Cost > r.Cost {
+						t.Fatalf("results not sorted: %v", got)
+					}
+				}
+				total += recall(got, exact(vectors, q, 10, tt.accept))
+			}
+			if r := total / float64(len(queries)); r < 0.9 {
+				t.Errorf("recall %.3f, want >= 0.9", r)
+			}
+		})
+	}
+}
+
+func TestGraph_Empty(t *testing.T) {
+	g := New(l2, Config{})
+	if got := g.Search([]float32{1, 2}, 5, 10, nil); len(got) != 0 {
+		t.Errorf("search of empty graph returned %v", got)
+	}
+	g.Add(0, []float32{1, 2})
+	g.Delete(0)
+	g.Delete(0)
+	if g.Len() != 0 {
+		t.Errorf("len %d, want 0", g.Len())
+	}
+	if got := g.Search([]float32{1, 2}, 5, 10, nil); len(got) != 0 {
+		t.Errorf("deleted node returned %v", got)
+	}
+}
@@ -0,0 +1,471 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gohnsw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
+	"github.com/vearch/vearch/v3/internal/ps/engine/gohnsw/hnsw"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultTopN  = 10
+	defaultLimit = 50
+	// searches of spaces with fewer live documents scan them all, the graph
+	// does not pay off on them
+	bruteSearchDocs = 1000
+	// docIdField returns the docid of documents read by it
+	docIdField = "_docid"
+	// termOperatorNotIn is the is_union of NOT IN term filters
+	termOperatorNotIn = 2
+)
+
+var _ engine.Reader = &readerImpl{}
+
+type readerImpl struct {
+	engine *goEngine
+}
+
+func (ri *readerImpl) GetDoc(ctx context.Context, doc *vearchpb.Document, getByDocId bool, next bool) error {
+	ge := ri.engine
+	ge.lock.RLock()
+	defer ge.lock.RUnlock()
+	if err := ge.closed(); err != nil {
+		return err
+	}
+
+	if getByDocId {
+		docId, err := strconv.ParseInt(doc.PKey, 10, 32)
+		if err != nil {
+			msg := fmt.Sprintf("key: [%s] convert to int32 failed, err: [%s]", doc.PKey, err.Error())
+			return vearchpb.NewError(vearchpb.ErrorEnum_PRIMARY_KEY_IS_INVALID, errors.New(msg))
+		}
+		if next && docId < -1 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PRIMARY_KEY_IS_INVALID, fmt.Errorf("docid: [%s] less than -1 with next as true", doc.PKey))
+		}
+		if !next && docId < 0 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PRIMARY_KEY_IS_INVALID, fmt.Errorf("docid: [%s] less than 0", doc.PKey))
+		}
+		docID := int(docId)
+		if next {
+			for docID++; docID < len(ge.docs) && ge.docs[docID] == nil; docID++ {
+			}
+		}
+		if docID >= len(ge.docs) || ge.docs[docID] == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST, nil)
+		}
+		// the docid is returned like gamma does, readers go on from it
+		stored := ge.docs[docID].Fields
+		doc.Fields = make([]*vearchpb.Field, 0, len(stored)+1)
+		doc.Fields = append(doc.Fields, stored...)
+		doc.Fields = append(doc.Fields, &vearchpb.Field{Name: docIdField, Type: vearchpb.FieldType_INT, Value: cbbytes.Int32ToByte(int32(docID))})
+		return nil
+	}
+	docID, ok := ge.ids[doc.PKey]
+	if !ok {
+		return vearchpb.NewError(vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST, nil)
+	}
+	doc.Fields = ge.docs[docID].Fields
+	return nil
+}
+
+func (ri *readerImpl) ReadSN(ctx context.Context) (int64, error) {
+	b, err := os.ReadFile(filepath.Join(ri.engine.path, indexSn))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+func (ri *readerImpl) DocCount(ctx context.Context) (uint64, error) {
+	ri.engine.lock.RLock()
+	defer ri.engine.lock.RUnlock()
+	if err := ri.engine.closed(); err != nil {
+		return 0, err
+	}
+	return uint64(ri.engine.live), nil
+}
+
+// Capacity returns the bytes of the live documents, the graphs are left out
+func (ri *readerImpl) Capacity(ctx context.Context) (int64, error) {
+	ri.engine.lock.RLock()
+	defer ri.engine.lock.RUnlock()
+	if err := ri.engine.closed(); err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, doc := range ri.engine.docs {
+		if doc == nil {
+			continue
+		}
+		for _, f := range doc.Fields {
+			size += int64(len(f.Name) + len(f.Value))
+		}
+	}
+	return size, nil
+}
+
+// searchParams are the index params of a search this engine reads
+type searchParams struct {
+	EfSearch int `json:"efSearch,omitempty"`
+}
+
+func (ri *readerImpl) Search(ctx context.Context, request *vearchpb.SearchRequest, response *vearchpb.SearchResponse) error {
+	ge := ri.engine
+	ge.lock.RLock()
+	defer ge.lock.RUnlock()
+	if err := ge.closed(); err != nil {
+		return err
+	}
+	if response.Head == nil {
+		response.Head = &vearchpb.ResponseHead{}
+	}
+
+	params := searchParams{EfSearch: ge.params.EfSearch}
+	if request.IndexParams != "" {
+		if err := json.Unmarshal([]byte(request.IndexParams), &params); err != nil {
+			return vearchpb.NewErrorInfo(vearchpb.ErrorEnum_SEARCH_ENGINE_ERR, err.Error())
+		}
+	}
+	if params.EfSearch <= 0 {
+		params.EfSearch = hnsw.DefaultEfSearch
+	}
+	topN := int(request.TopN)
+	if topN <= 0 {
+		topN = defaultTopN
+	}
+	accept, err := ge.filter(request.RangeFilters, request.TermFilters)
+	if err != nil {
+		return err
+	}
+
+	queries, reqNum, err := ge.queryVectors(request)
+	if err != nil {
+		return err
+	}
+	brute := request.IsBruteSearch == 1 || len(queries) > 1 || ge.live <= bruteSearchDocs
+
+	results := make([]*vearchpb.SearchResult, reqNum)
+	for i := 0; i < reqNum; i++ {
+		var hits []hnsw.Result
+		if brute {
+			hits = ge.scan(queries, i, topN, accept)
+		} else {
+			q := queries[0]
+			hits = ge.indexes[q.field].graph.Search(q.vectors[i], topN, params.EfSearch, accept)
+		}
+		items := make([]*vearchpb.ResultItem, 0, len(hits))
+		for _, hit := range hits {
+			score := hit.Cost
+			if !ge.ascending {
+				score = -score
+			} else if request.L2Sqrt {
+				score = math.Sqrt(score)
+			}
+			if !queries.accept(score) {
+				continue
+			}
+			items = append(items, ge.resultItem(hit.ID, score, request.Fields, request.IsVectorValue))
+		}
+		result := &vearchpb.SearchResult{TotalHits: int32(len(items)), ResultItems: items, TopN: int32(topN)}
+		if len(items) > 0 {
+			result.MaxScore = items[0].Score
+		}
+		results[i] = result
+	}
+	return ge.marshalResults(results, response)
+}
+
+func (ri *readerImpl) Query(ctx context.Context, request *vearchpb.QueryRequest, response *vearchpb.SearchResponse) error {
+	ge := ri.engine
+	ge.lock.RLock()
+	defer ge.lock.RUnlock()
+	if err := ge.closed(); err != nil {
+		return err
+	}
+	if response.Head == nil {
+		response.Head = &vearchpb.ResponseHead{}
+	}
+
+	items := make([]*vearchpb.ResultItem, 0)
+	if len(request.DocumentIds) > 0 {
+		for _, id := range request.DocumentIds {
+			if docID, ok := ge.ids[id]; ok {
+				items = append(items, ge.resultItem(docID, 0, request.Fields, request.IsVectorValue))
+			}
+		}
+	} else {
+		accept, err := ge.filter(request.RangeFilters, request.TermFilters)
+		if err != nil {
+			return err
+		}
+		limit := int(request.Limit)
+		if limit <= 0 {
+			limit = defaultLimit
+		}
+		for docID, doc := range ge.docs {
+			if len(items) >= limit {
+				break
+			}
+			if doc != nil && (accept == nil || accept(docID)) {
+				items = append(items, ge.resultItem(docID, 0, request.Fields, request.IsVectorValue))
+			}
+		}
+	}
+	result := &vearchpb.SearchResult{TotalHits: int32(len(items)), ResultItems: items}
+	return ge.marshalResults([]*vearchpb.SearchResult{result}, response)
+}
+
+func (ge *goEngine) marshalResults(results []*vearchpb.SearchResult, response *vearchpb.SearchResponse) error {
+	bs, err := proto.Marshal(&vearchpb.SearchResponse{Results: results})
+	if err != nil {
+		return vearchpb.NewErrorInfo(vearchpb.ErrorEnum_SEARCH_ENGINE_ERR, err.Error())
+	}
+	response.FlatBytes = bs
+	return nil
+}
+
+// vectorQuery is the vectors of a request searching a field
+type vectorQuery struct {
+	field              string
+	vectors            [][]float32
+	minScore, maxScore float64
+}
+
+type vectorQueries []*vectorQuery
+
+// accept tells whether score is in the bounds of every query, unset bounds
+// are zero
+func (qs vectorQueries) accept(score float64) bool {
+	for _, q := range qs {
+		if q.minScore == 0 && q.maxScore == 0 {
+			continue
+		}
+		if score < q.minScore || score > q.maxScore {
+			return false
+		}
+	}
+	return true
+}
+
+// queryVectors splits the vectors of every vector field of request, each one
+// has req_num vectors
+func (ge *goEngine) queryVectors(request *vearchpb.SearchRequest) (vectorQueries, int, error) {
+	if len(request.VecFields) == 0 {
+		return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("search has no vector"))
+	}
+	queries := make(vectorQueries, 0, len(request.VecFields))
+	reqNum := int(request.ReqNum)
+	for _, vf := range request.VecFields {
+		index := ge.indexes[vf.Name]
+		if index == nil {
+			return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s is not a vector field", vf.Name))
+		}
+		values, err := cbbytes.ByteToFloat32Array(vf.Value)
+		if err != nil || index.dimension == 0 || len(values)%index.dimension != 0 {
+			return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector of field %s should have dimension %d", vf.Name, index.dimension))
+		}
+		n := len(values) / index.dimension
+		if reqNum <= 0 {
+			reqNum = n
+		}
+		if n != reqNum {
+			return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s has %d vectors, should be %d", vf.Name, n, reqNum))
+		}
+		q := &vectorQuery{field: vf.Name, minScore: vf.MinScore, maxScore: vf.MaxScore}
+		for i := 0; i < n; i++ {
+			q.vectors = append(q.vectors, values[i*index.dimension:(i+1)*index.dimension])
+		}
+		queries = append(queries, q)
+	}
+	return queries, reqNum, nil
+}
+
+// scan scores all accepted documents by the i-th vector of every query,
+// summing the costs of the fields, and returns the topN nearest
+func (ge *goEngine) scan(queries vectorQueries, i, topN int, accept func(docID int) bool) []hnsw.Result {
+	hits := make([]hnsw.Result, 0)
+	for docID, doc := range ge.docs {
+		if doc == nil || (accept != nil && !accept(docID)) {
+			continue
+		}
+		cost := 0.0
+		for _, q := range queries {
+			index := ge.indexes[q.field]
+			cost += index.cost(q.vectors[i], index.vectors[docID])
+		}
+		hits = append(hits, hnsw.Result{ID: docID, Cost: cost})
+	}
+	sort.SliceStable(hits, func(a, b int) bool { return hits[a].Cost < hits[b].Cost })
+	if len(hits) > topN {
+		hits = hits[:topN]
+	}
+	return hits
+}
+
+// resultItem returns the fields of a document a search asks for, all but the
+// vectors if it asks for none, with the vectors only if it asks for their
+// values. The _id is always returned
+func (ge *goEngine) resultItem(docID int, score float64, names []string, vectorValue bool) *vearchpb.ResultItem {
+	doc := ge.docs[docID]
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	item := &vearchpb.ResultItem{Score: score, PKey: doc.PKey}
+	for _, f := range doc.Fields {
+		if f.Name != entity.IdField {
+			if len(wanted) > 0 && !wanted[f.Name] {
+				continue
+			}
+			if f.Type == vearchpb.FieldType_VECTOR && !vectorValue {
+				continue
+			}
+		}
+		item.Fields = append(item.Fields, f)
+	}
+	return item
+}
+
+// filter returns the check of the filters on a docid, nil without filters
+func (ge *goEngine) filter(ranges []*vearchpb.RangeFilter, terms []*vearchpb.TermFilter) (func(docID int) bool, error) {
+	check, err := DocFilter(ge.proMap, ranges, terms)
+	if check == nil || err != nil {
+		return nil, err
+	}
+	return func(docID int) bool { return check(ge.docs[docID]) }, nil
+}
+
+// DocFilter returns the check of the filters on a document, nil without
+// filters. Filters are joined by AND like the ones routers build
+func DocFilter(proMap map[string]*entity.SpaceProperties, ranges []*vearchpb.RangeFilter, terms []*vearchpb.TermFilter) (func(doc *vearchpb.Document) bool, error) {
+	if len(ranges) == 0 && len(terms) == 0 {
+		return nil, nil
+	}
+	checks := make([]func(doc *vearchpb.Document) bool, 0, len(ranges)+len(terms))
+	for _, rf := range ranges {
+		pro := proMap[rf.Field]
+		if pro == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] not found in space fields", rf.Field))
+		}
+		check, err := rangeCheck(rf, pro.FieldType)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	for _, tf := range terms {
+		checks = append(checks, termCheck(tf))
+	}
+	return func(doc *vearchpb.Document) bool {
+		for _, check := range checks {
+			if !check(doc) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func fieldOf(doc *vearchpb.Document, name string) *vearchpb.Field {
+	for _, f := range doc.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// rangeCheck compares the values of a numeric field to the bounds of rf,
+// encoded in little endian by their type
+func rangeCheck(rf *vearchpb.RangeFilter, fieldType vearchpb.FieldType) (func(doc *vearchpb.Document) bool, error) {
+	var compare func(a, b []byte) int
+	switch fieldType {
+	case vearchpb.FieldType_INT:
+		compare = func(a, b []byte) int { return cmpOrdered(cbbytes.Bytes2Int32(a), cbbytes.Bytes2Int32(b)) }
+	case vearchpb.FieldType_LONG, vearchpb.FieldType_DATE:
+		compare = func(a, b []byte) int { return cmpOrdered(cbbytes.Bytes2Long(a), cbbytes.Bytes2Long(b)) }
+	case vearchpb.FieldType_FLOAT:
+		compare = func(a, b []byte) int { return cmpOrdered(cbbytes.ByteToFloat32(a), cbbytes.ByteToFloat32(b)) }
+	case vearchpb.FieldType_DOUBLE:
+		compare = func(a, b []byte) int { return cmpOrdered(cbbytes.ByteToFloat64New(a), cbbytes.ByteToFloat64New(b)) }
+	default:
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("range filter should be numberic type, field:[%s] is %s", rf.Field, fieldType.String()))
+	}
+	size := len(rf.LowerValue)
+	if size == 0 || len(rf.UpperValue) != size {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("range filter of field:[%s] has invalid bounds", rf.Field))
+	}
+	return func(doc *vearchpb.Document) bool {
+		f := fieldOf(doc, rf.Field)
+		if f == nil || len(f.Value) != size {
+			return false
+		}
+		lower, upper := compare(f.Value, rf.LowerValue), compare(f.Value, rf.UpperValue)
+		if lower < 0 || (lower == 0 && !rf.IncludeLower) {
+			return false
+		}
+		return upper < 0 || (upper == 0 && rf.IncludeUpper)
+	}, nil
+}
+
+func cmpOrdered[T int32 | int64 | float32 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// termCheck matches the values of a string or string array field, separated
+// by \001, to the ones of tf
+func termCheck(tf *vearchpb.TermFilter) func(doc *vearchpb.Document) bool {
+	terms := bytes.Split(tf.Value, []byte{'\001'})
+	return func(doc *vearchpb.Document) bool {
+		matched := false
+		if f := fieldOf(doc, tf.Field); f != nil {
+			for _, v := range bytes.Split(f.Value, []byte{'\001'}) {
+				for _, t := range terms {
+					if bytes.Equal(v, t) {
+						matched = true
+					}
+				}
+			}
+		}
+		if tf.IsUnion == termOperatorNotIn {
+			return !matched
+		}
+		return matched
+	}
+}
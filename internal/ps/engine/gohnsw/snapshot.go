@@ -0,0 +1,157 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gohnsw
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// snapshotChunk is the bytes sent in a message
+const snapshotChunk = 1024000 * 10
+
+var _ proto.Snapshot = &goSnapshot{}
+
+// goSnapshot sends the dumped files of the engine by their names, it reads
+// them when created so later dumps do not change it
+type goSnapshot struct {
+	sn    int64
+	names []string
+	files [][]byte
+	index int
+	off   int
+}
+
+func (s *goSnapshot) Next() ([]byte, error) {
+	for s.index < len(s.files) && s.off >= len(s.files[s.index]) {
+		s.index, s.off = s.index+1, 0
+	}
+	if s.index >= len(s.files) {
+		data, err := protobuf.Marshal(&vearchpb.SnapshotMsg{Status: vearchpb.SnapshotStatus_Finish})
+		if err != nil {
+			return data, err
+		}
+		return data, io.EOF
+	}
+	data := s.files[s.index]
+	end := min(s.off+snapshotChunk, len(data))
+	msg := &vearchpb.SnapshotMsg{
+		FileName: s.names[s.index],
+		Data:     data[s.off:end],
+		Status:   vearchpb.SnapshotStatus_Running,
+	}
+	s.off = end
+	return protobuf.Marshal(msg)
+}
+
+func (s *goSnapshot) ApplyIndex() uint64 {
+	return uint64(s.sn)
+}
+
+func (s *goSnapshot) Close() {}
+
+func (ge *goEngine) NewSnapshot() (proto.Snapshot, error) {
+	ge.dumpLock.Lock()
+	defer ge.dumpLock.Unlock()
+	b, err := os.ReadFile(filepath.Join(ge.path, indexSn))
+	if err != nil {
+		return nil, err
+	}
+	sn, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if sn < 0 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("read sn:[%d] less than zero", sn))
+	}
+	s := &goSnapshot{sn: sn}
+	docs, err := os.ReadFile(filepath.Join(ge.path, docsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		s.names, s.files = append(s.names, docsFile), append(s.files, docs)
+	}
+	// sn goes last, a follower stopped in the middle has no sn to start from
+	s.names, s.files = append(s.names, indexSn), append(s.files, b)
+	return s, nil
+}
+
+// ApplySnapshot writes the files of a snapshot under the path of the engine,
+// it is called on the closed engine which is built again after it
+func (ge *goEngine) ApplySnapshot(peers []proto.Peer, iter proto.SnapIterator) error {
+	if err := os.MkdirAll(ge.path, os.ModePerm); err != nil {
+		return err
+	}
+	var (
+		out  *os.File
+		name string
+	)
+	defer func() {
+		if out != nil {
+			out.Close()
+		}
+	}()
+	for {
+		bs, err := iter.Next()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if bs == nil {
+			if err == io.EOF {
+				return nil
+			}
+			continue
+		}
+		msg := &vearchpb.SnapshotMsg{}
+		if err := protobuf.Unmarshal(bs, msg); err != nil {
+			return err
+		}
+		if msg.Status == vearchpb.SnapshotStatus_Finish {
+			if out != nil {
+				err := out.Close()
+				out = nil
+				return err
+			}
+			return nil
+		}
+		if len(msg.Data) == 0 {
+			continue
+		}
+		if out == nil || msg.FileName != name {
+			if out != nil {
+				if err := out.Close(); err != nil {
+					out = nil
+					return err
+				}
+			}
+			name = msg.FileName
+			if out, err = os.OpenFile(filepath.Join(ge.path, filepath.Base(name)), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0660); err != nil {
+				out = nil
+				return err
+			}
+		}
+		if _, err := out.Write(msg.Data); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gohnsw
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/fileutil"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	indexSn  = "sn"
+	docsFile = "docs"
+)
+
+// upsert writes the fields of a document by the _id among them, the fields
+// of a stored document are merged into the new ones like gamma updates. The
+// document gets a new docid and the old one is deleted. Callers hold lock
+func (ge *goEngine) upsert(fields []*vearchpb.Field) error {
+	var key string
+	found := false
+	for _, f := range fields {
+		if f.Name == entity.IdField {
+			key, found = string(f.Value), true
+			break
+		}
+	}
+	if !found {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document has no %s", entity.IdField))
+	}
+	doc := &vearchpb.Document{PKey: key, Fields: fields}
+	old, exists := ge.ids[key]
+	if exists {
+		written := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			written[f.Name] = true
+		}
+		merged := make([]*vearchpb.Field, 0, len(ge.docs[old].Fields))
+		for _, f := range ge.docs[old].Fields {
+			if !written[f.Name] {
+				merged = append(merged, f)
+			}
+		}
+		doc.Fields = append(merged, fields...)
+	}
+	vectors, err := ge.vectorsOf(doc)
+	if err != nil {
+		return err
+	}
+	if exists {
+		ge.remove(old)
+	}
+	ge.add(doc, vectors)
+	return nil
+}
+
+// put adds a document which is not stored, callers hold lock
+func (ge *goEngine) put(doc *vearchpb.Document) error {
+	if _, ok := ge.ids[doc.PKey]; ok {
+		return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("document %s stored twice", doc.PKey))
+	}
+	vectors, err := ge.vectorsOf(doc)
+	if err != nil {
+		return err
+	}
+	ge.add(doc, vectors)
+	return nil
+}
+
+// vectorsOf decodes the vector of every vector field of doc
+func (ge *goEngine) vectorsOf(doc *vearchpb.Document) (map[string][]float32, error) {
+	vectors := make(map[string][]float32, len(ge.indexes))
+	for _, f := range doc.Fields {
+		index := ge.indexes[f.Name]
+		if index == nil {
+			continue
+		}
+		vector, err := cbbytes.ByteToFloat32Array(f.Value)
+		if err != nil || len(vector) != index.dimension {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field %s of document %s should have dimension %d", f.Name, doc.PKey, index.dimension))
+		}
+		vectors[f.Name] = vector
+	}
+	for name := range ge.indexes {
+		if vectors[name] == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document %s has no vector field %s", doc.PKey, name))
+		}
+	}
+	return vectors, nil
+}
+
+func (ge *goEngine) add(doc *vearchpb.Document, vectors map[string][]float32) {
+	docID := len(ge.docs)
+	ge.docs = append(ge.docs, doc)
+	ge.ids[doc.PKey] = docID
+	ge.live++
+	for name, index := range ge.indexes {
+		for len(index.vectors) <= docID {
+			index.vectors = append(index.vectors, nil)
+		}
+		index.vectors[docID] = vectors[name]
+		index.graph.Add(docID, vectors[name])
+	}
+	ge.changed = true
+}
+
+func (ge *goEngine) remove(docID int) {
+	delete(ge.ids, ge.docs[docID].PKey)
+	ge.docs[docID] = nil
+	ge.live--
+	for _, index := range ge.indexes {
+		index.vectors[docID] = nil
+		index.graph.Delete(docID)
+	}
+	ge.changed = true
+}
+
+// dump writes the live documents and then sn, so a reopened engine does not
+// skip the raft log after sn
+func (ge *goEngine) dump(sn int64) error {
+	ge.dumpLock.Lock()
+	defer ge.dumpLock.Unlock()
+
+	ge.lock.Lock()
+	if err := ge.closed(); err != nil {
+		ge.lock.Unlock()
+		return err
+	}
+	var (
+		data []byte
+		err  error
+	)
+	changed := ge.changed
+	if changed {
+		data, err = ge.encodeDocs()
+		ge.changed = false
+	}
+	ge.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		if err := fileutil.WriteFileAtomic(filepath.Join(ge.path, docsFile), data, os.ModePerm); err != nil {
+			ge.lock.Lock()
+			ge.changed = true
+			ge.lock.Unlock()
+			return err
+		}
+	}
+	return fileutil.WriteFileAtomic(filepath.Join(ge.path, indexSn), []byte(strconv.FormatInt(sn, 10)), os.ModePerm)
+}
+
+// encodeDocs lays out the live documents as the length and the marshaled
+// bytes of each one
+func (ge *goEngine) encodeDocs() ([]byte, error) {
+	data := make([]byte, 0)
+	for _, doc := range ge.docs {
+		if doc == nil {
+			continue
+		}
+		bs, err := proto.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		data = binary.AppendUvarint(data, uint64(len(bs)))
+		data = append(data, bs...)
+	}
+	return data, nil
+}
+
+// load reads the documents dumped to the path of the engine and builds the
+// graphs of them
+func (ge *goEngine) load() error {
+	data, err := os.ReadFile(filepath.Join(ge.path, docsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("documents file of partition %d is truncated", ge.partitionID))
+		}
+		doc := &vearchpb.Document{}
+		if err := proto.Unmarshal(data[n:n+int(size)], doc); err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err)
+		}
+		if err := ge.put(doc); err != nil {
+			return err
+		}
+		data = data[n+int(size):]
+	}
+	ge.changed = false
+	return nil
+}
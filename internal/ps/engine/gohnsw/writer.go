@@ -0,0 +1,112 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gohnsw
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/vearch/vearch/v3/internal/engine/idl/fbs-gen/go/gamma_api"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
+)
+
+var _ engine.Writer = &writerImpl{}
+
+type writerImpl struct {
+	engine *goEngine
+}
+
+// Write applies a command the way gamma does, a bulk returns the code of
+// every document joined by commas in a SUCCESS error
+func (wi *writerImpl) Write(ctx context.Context, doc *vearchpb.DocCmd) (err error) {
+	if doc == nil {
+		return errors.New("doc is nil")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = vearchpb.NewError(vearchpb.ErrorEnum_RECOVER, fmt.Errorf(" %v", r))
+		}
+	}()
+
+	wi.engine.lock.Lock()
+	defer wi.engine.lock.Unlock()
+	if err := wi.engine.closed(); err != nil {
+		return err
+	}
+
+	switch doc.Type {
+	case vearchpb.OpType_BULK:
+		var buffer bytes.Buffer
+		for _, bs := range doc.Docs {
+			code := 0
+			if err := wi.engine.upsert(decodeDoc(bs)); err != nil {
+				log.Error("go-hnsw add doc err: [%s]", err.Error())
+				code = 1
+			}
+			buffer.WriteString(strconv.Itoa(code) + ",")
+		}
+		return vearchpb.NewError(vearchpb.ErrorEnum_SUCCESS, errors.New(buffer.String()))
+	case vearchpb.OpType_DELETE:
+		docID, ok := wi.engine.ids[string(doc.Doc)]
+		if !ok {
+			return vearchpb.NewError(vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST, nil)
+		}
+		wi.engine.remove(docID)
+	default:
+		msg := fmt.Sprintf("type: [%v] not found", doc.Type)
+		err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, errors.New(msg))
+	}
+	return
+}
+
+func (wi *writerImpl) Flush(ctx context.Context, sn int64) error {
+	return wi.engine.dump(sn)
+}
+
+func (wi *writerImpl) Commit(ctx context.Context, sn int64) (chan error, error) {
+	wi.engine.lock.RLock()
+	err := wi.engine.closed()
+	wi.engine.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	flushC := make(chan error, 1)
+	go func() {
+		flushC <- wi.engine.dump(sn)
+	}()
+	return flushC, nil
+}
+
+// decodeDoc reads the fields of a document serialized by the gamma sdk
+func decodeDoc(bs []byte) []*vearchpb.Field {
+	doc := gamma_api.GetRootAsDoc(bs, 0)
+	fields := make([]*vearchpb.Field, doc.FieldsLength())
+	for i := range fields {
+		var field gamma_api.Field
+		doc.Fields(&field, i)
+		fields[i] = &vearchpb.Field{
+			Name:  string(field.Name()),
+			Value: append([]byte(nil), field.ValueBytes()...),
+			Type:  vearchpb.FieldType(field.DataType()),
+		}
+	}
+	return fields
+}
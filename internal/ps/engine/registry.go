@@ -0,0 +1,138 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// DefaultEngine serves the spaces without vector index
+const DefaultEngine = "gamma"
+
+// Config opens the engine of a partition
+type Config struct {
+	// Path is the data directory.
+	Path string
+	// ExtraOptions contains extension options using a json format ("{key1:value1,key2:value2}").
+	ExtraOptions map[string]interface{}
+	// Schema
+	Space *entity.Space
+	// partitionID
+	PartitionID entity.PartitionID
+}
+
+// Builder opens the engine of a partition, loading the data it stored under
+// the path of cfg before
+type Builder func(cfg Config) (Engine, error)
+
+type registration struct {
+	name       string
+	indexTypes []string
+	build      Builder
+}
+
+var (
+	registryMu sync.RWMutex
+	engines    = make(map[string]*registration)
+	byIndex    = make(map[string]*registration)
+)
+
+// Register makes an engine available for the spaces of its index types, an
+// engine is integrated by registering it from the init of its package and
+// importing the package in the binary. Its index types are registered in
+// entity too, so master accepts spaces of them and ps reports them in its
+// capabilities. It panics if the name or an index type is registered twice
+func Register(name string, indexTypes []string, build Builder) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if build == nil {
+		panic("engine: register nil builder of " + name)
+	}
+	if _, ok := engines[name]; ok {
+		panic("engine: register engine twice " + name)
+	}
+	r := &registration{name: name, indexTypes: indexTypes, build: build}
+	for _, t := range indexTypes {
+		if other, ok := byIndex[t]; ok {
+			panic(fmt.Sprintf("engine: index type %s of %s is served by %s", t, name, other.name))
+		}
+	}
+	for _, t := range indexTypes {
+		byIndex[t] = r
+	}
+	engines[name] = r
+	entity.RegisterIndexTypes(indexTypes...)
+}
+
+// Names returns the registered engines sorted
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IndexTypes returns the index types of the registered engines sorted, ps
+// reports them in its capabilities so master places no space on a ps built
+// without its engine
+func IndexTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(byIndex))
+	for t := range byIndex {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NameOf returns the engine serving space, the default engine if space has
+// no vector index
+func NameOf(space *entity.Space) (string, error) {
+	if space == nil || space.Index == nil || space.Index.Type == "" {
+		return DefaultEngine, nil
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := byIndex[space.Index.Type]
+	if !ok {
+		return "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("no engine registered for index type %s", space.Index.Type))
+	}
+	return r.name, nil
+}
+
+// Build opens the engine serving the space of cfg
+func Build(cfg Config) (Engine, error) {
+	name, err := NameOf(cfg.Space)
+	if err != nil {
+		return nil, err
+	}
+	registryMu.RLock()
+	r := engines[name]
+	registryMu.RUnlock()
+	if r == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("engine %s is not registered", name))
+	}
+	return r.build(cfg)
+}
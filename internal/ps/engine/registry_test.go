@@ -0,0 +1,75 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func TestRegistry(t *testing.T) {
+	var built []entity.PartitionID
+	Register("test", []string{"TEST_FLAT", "TEST_HNSW"}, func(cfg Config) (Engine, error) {
+		built = append(built, cfg.PartitionID)
+		return nil, nil
+	})
+
+	tests := []struct {
+		name    string
+		space   *entity.Space
+		want    string
+		wantErr bool
+	}{
+		{name: "Space without index", space: &entity.Space{}, want: DefaultEngine},
+		{name: "Index type of the engine", space: &entity.Space{Index: &entity.Index{Type: "TEST_HNSW"}}, want: "test"},
+		{name: "Index type of no engine", space: &entity.Space{Index: &entity.Index{Type: "NONE"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NameOf(tt.space)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NameOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NameOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Build(Config{Space: &entity.Space{Index: &entity.Index{Type: "TEST_FLAT"}}, PartitionID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if len(built) != 1 || built[0] != 7 {
+		t.Errorf("Build() built partitions %v, want [7]", built)
+	}
+	if got := IndexTypes(); len(got) != 2 || got[0] != "TEST_FLAT" || got[1] != "TEST_HNSW" {
+		t.Errorf("IndexTypes() = %v, want [TEST_FLAT TEST_HNSW]", got)
+	}
+	registered := false
+	for _, it := range entity.IndexTypes {
+		registered = registered || it == "TEST_FLAT"
+	}
+	if !registered {
+		t.Errorf("entity.IndexTypes = %v, want the index types of the engine", entity.IndexTypes)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() of an index type served by another engine should panic")
+		}
+	}()
+	Register("other", []string{"TEST_FLAT"}, func(cfg Config) (Engine, error) { return nil, nil })
+}
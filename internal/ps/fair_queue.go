@@ -0,0 +1,71 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/smallnest/rpcx/share"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fairqueue"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// newFairQueue queues the searches and queries of tenants fairly for the
+// execution slots of the server, nil if not configured
+func (s *Server) newFairQueue(cfg *config.FairQueueCfg) *fairqueue.Queue {
+	if cfg == nil {
+		return nil
+	}
+	slots := cfg.Slots
+	if slots <= 0 {
+		slots = s.concurrentNum
+	}
+	log.Info("queue searches and queries fairly by tenant in %d slots", slots)
+	return fairqueue.New(slots, cfg.Weight)
+}
+
+// acquireFairQueue waits for a slot of the tenant of the request if it is a
+// search or query, the tenant is set by router and is the db id and name of
+// the space for requests without it. The returned func releases the slot and
+// is nil if no slot is taken
+func (s *Server) acquireFairQueue(ctx context.Context, pid entity.PartitionID) (func(), *vearchpb.VearchErr) {
+	if s.fairQueue == nil {
+		return nil, nil
+	}
+	reqMap, _ := ctx.Value(share.ReqMetaDataKey).(map[string]string)
+	if method := reqMap[client.HandlerType]; method != client.SearchHandler && method != client.QueryHandler {
+		return nil, nil
+	}
+	tenant := reqMap[string(entity.TENANT)]
+	if tenant == "" {
+		store := s.GetPartition(pid)
+		if store == nil {
+			return nil, nil
+		}
+		space := store.GetSpace()
+		tenant = fmt.Sprintf("%d/%s", space.DBId, space.Name)
+	}
+	release, err := s.fairQueue.Acquire(ctx, tenant)
+	if err != nil {
+		err = fmt.Errorf("request for partition: %d time out, tenant [%s] waits in the fair queue", pid, tenant)
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_RESOURCE_EXHAUSTED, err)
+	}
+	return release, nil
+}
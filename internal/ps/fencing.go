@@ -0,0 +1,81 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/hlc"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+// StartFencingJob renews the fencing tokens of the partitions led by this
+// node at a third of their ttl, in one request for all of them, a leader
+// failing to renew stops writing when its token expires
+func (s *Server) StartFencingJob() {
+	if !config.Conf().PS.WriteFencing {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(entity.FencingTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if s.stopping {
+				return
+			}
+			var pids []entity.PartitionID
+			s.RangePartition(func(pid entity.PartitionID, store PartitionStore) {
+				if store.IsLeader() {
+					pids = append(pids, pid)
+				}
+			})
+			s.renewFencingTokens(pids, false)
+		}
+	}()
+}
+
+// renewFencingTokens renews the fencing tokens of the partitions led by this
+// node, or releases them if release
+func (s *Server) renewFencingTokens(pids []entity.PartitionID, release bool) {
+	if !config.Conf().PS.WriteFencing || len(pids) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, entity.FencingTTL/3)
+	defer cancel()
+	req := &entity.FencingRequest{NodeID: s.nodeID, PartitionIDs: pids, Release: release}
+	tokens, err := s.client.Master().FencingTokens(ctx, req)
+	if err != nil {
+		log.Error("fencing tokens of partitions %v, release: %v, err: %v", pids, release, err)
+		return
+	}
+	if release {
+		return
+	}
+	for _, token := range tokens {
+		// the clock of this node is not behind master when checking the token
+		hlc.Update(token.Issued)
+		if store := s.GetPartition(token.PartitionID); store != nil {
+			store.SetFencingToken(token)
+		}
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/cubefs/cubefs/depends/tiglabs/raft"
 	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
@@ -28,6 +30,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
 
+	"github.com/vearch/vearch/v3/internal/pkg/distance"
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/metrics/mserver"
@@ -70,6 +73,21 @@ func ExportToRpcAdminHandler(server *Server) {
 	if err := server.rpcServer.RegisterName(handler.NewChain(client.StatsHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &StatsHandler{server: server}), ""); err != nil {
 		panic(err)
 	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.PartitionStatsHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &PartitionStatsHandler{server: server}), ""); err != nil {
+		panic(err)
+	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.IndexStatsHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &IndexStatsHandler{server: server}), ""); err != nil {
+		panic(err)
+	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.TryToLeaderHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &TryToLeaderHandler{server: server}), ""); err != nil {
+		panic(err)
+	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.SimilarityHandler, handler.DefaultPanicHandler, nil, initAdminHandler, new(SimilarityHandler)), ""); err != nil {
+		panic(err)
+	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.ChecksumHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &ChecksumHandler{server: server}), ""); err != nil {
+		panic(err)
+	}
 	if err := server.rpcServer.RegisterName(handler.NewChain(client.ChangeMemberHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &ChangeMemberHandler{server: server}), ""); err != nil {
 		panic(err)
 	}
@@ -82,6 +100,18 @@ func ExportToRpcAdminHandler(server *Server) {
 	if err := server.rpcServer.RegisterName(handler.NewChain(client.ResourceLimitHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &ResourceLimitHandler{server: server}), ""); err != nil {
 		panic(err)
 	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.IndexImportHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &IndexImportHandler{server: server}), ""); err != nil {
+		panic(err)
+	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.SnapshotHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &SnapshotHandler{server: server}), ""); err != nil {
+		panic(err)
+	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.SpaceCopyHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &SpaceCopyHandler{server: server}), ""); err != nil {
+		panic(err)
+	}
+	if err := server.rpcServer.RegisterName(handler.NewChain(client.RebuildReplicaHandler, handler.DefaultPanicHandler, nil, initAdminHandler, &RebuildReplicaHandler{server: server}), ""); err != nil {
+		panic(err)
+	}
 }
 
 type InitAdminHandler struct {
@@ -289,6 +319,197 @@ func (sh *StatsHandler) Execute(ctx context.Context, req *vearchpb.PartitionData
 	return nil
 }
 
+type PartitionStatsHandler struct {
+	server *Server
+}
+
+func (psh *PartitionStatsHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) (err error) {
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+	store := psh.server.GetPartition(req.PartitionID)
+	if store == nil {
+		msg := fmt.Sprintf("partition not found, partitionId:[%d], nodeID:[%d], node ip:[%s]", req.PartitionID, psh.server.nodeID, psh.server.ip)
+		reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_EXIST, errors.New(msg)).GetError()
+		return nil
+	}
+
+	recorder := psh.server.partitionStats(req.PartitionID)
+	stats := &entity.PartitionStats{
+		PartitionID: req.PartitionID,
+		NodeID:      psh.server.nodeID,
+		Ip:          psh.server.ip,
+		Leader:      store.IsLeader(),
+		Window:      statsWindow,
+		Requests:    recorder.snapshot(),
+	}
+	if recorder != nil {
+		stats.Queued = recorder.queued.Load()
+		stats.Running = recorder.running.Load()
+		stats.Stuck = recorder.stuckNow.Load()
+		stats.StuckTotal = recorder.stuckTotal.Load()
+	}
+
+	space := store.GetSpace()
+	stats.SearchParams = space.DefaultSearchParams
+	for name, pro := range space.SpaceProperties {
+		if pro.Index != nil {
+			if stats.Indexes == nil {
+				stats.Indexes = make(map[string]*entity.Index)
+			}
+			stats.Indexes[name] = pro.Index
+		}
+	}
+
+	if engine := store.GetEngine(); engine != nil {
+		errs := make([]string, 0)
+		if stats.MemoryBytes, err = engine.Reader().Capacity(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("got capacity from engine err:[%s]", err.Error()))
+		}
+		if stats.DocNum, err = engine.Reader().DocCount(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("got docCount from engine err:[%s]", err.Error()))
+		}
+		cfg := &entity.EngineConfig{}
+		if err = engine.GetEngineCfg(cfg); err != nil {
+			errs = append(errs, fmt.Sprintf("got engine config err:[%s]", err.Error()))
+		} else {
+			stats.EngineConfig = cfg
+		}
+		stats.Error = strings.Join(errs, "; ")
+	}
+
+	if reply.Data, err = vjson.Marshal(stats); err != nil {
+		log.Error("marshal partition stats failed, err: [%v]", err)
+		return err
+	}
+	return nil
+}
+
+// TryToLeaderHandler makes the replica of this server the leader of partition
+type TryToLeaderHandler struct {
+	server *Server
+}
+
+func (th *TryToLeaderHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) error {
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+	store := th.server.GetPartition(req.PartitionID)
+	if store == nil {
+		msg := fmt.Sprintf("partition not found, partitionId:[%d], nodeID:[%d], node ip:[%s]", req.PartitionID, th.server.nodeID, th.server.ip)
+		reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_EXIST, errors.New(msg)).GetError()
+		return nil
+	}
+	if store.IsLeader() {
+		return nil
+	}
+	log.Info("try to be leader of partition [%d] on node [%d]", req.PartitionID, th.server.nodeID)
+	return store.TryToLeader()
+}
+
+// SimilarityHandler computes the pairwise similarity of two sets of vectors
+// by the engine kernels
+type SimilarityHandler struct{}
+
+func (sh *SimilarityHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) (err error) {
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+	simReq := &entity.SimilarityRequest{}
+	if err := vjson.Unmarshal(req.Data, simReq); err != nil {
+		reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err).GetError()
+		return nil
+	}
+	if err := simReq.Validate(); err != nil {
+		reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err).GetError()
+		return nil
+	}
+
+	d := simReq.Dimension()
+	flatten := func(vectors [][]float32) []float32 {
+		flat := make([]float32, 0, len(vectors)*d)
+		for _, v := range vectors {
+			flat = append(flat, v...)
+		}
+		return flat
+	}
+	var scores []float32
+	if simReq.SpaceMetric != nil {
+		scores, err = metricSimilarity(simReq.SpaceMetric, flatten(simReq.VectorsA), flatten(simReq.VectorsB), d)
+	} else {
+		scores, err = engineSimilarity(simReq, flatten(simReq.VectorsA), flatten(simReq.VectorsB), d)
+	}
+	if err != nil {
+		reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
+		return nil
+	}
+	result := &entity.SimilarityResult{MetricType: simReq.MetricType, Scores: make([][]float32, len(simReq.VectorsA))}
+	nb := len(simReq.VectorsB)
+	for i := range result.Scores {
+		result.Scores[i] = scores[i*nb : (i+1)*nb]
+	}
+	if reply.Data, err = vjson.Marshal(result); err != nil {
+		log.Error("marshal similarity result failed, err: [%v]", err)
+		return err
+	}
+	return nil
+}
+
+// metricSimilarity computes the similarity by a space metric of the distance
+// registry, the scores of a row of a are laid out like the engine kernels do
+func metricSimilarity(m *entity.SpaceMetric, a, b []float32, d int) ([]float32, error) {
+	metric, err := m.New(d)
+	if err != nil {
+		return nil, err
+	}
+	nb := len(b) / d
+	scores := make([]float32, 0, len(a)/d*nb)
+	row := make([]float64, nb)
+	for i := 0; i+d <= len(a); i += d {
+		distance.ScoreBatch(metric, a[i:i+d], b, row)
+		for _, score := range row {
+			scores = append(scores, float32(score))
+		}
+	}
+	return scores, nil
+}
+
+// checksumWaitTimeout bounds the wait of a follower behind the checksum entry
+const checksumWaitTimeout = 60 * time.Second
+
+// ChecksumHandler proposes a checksum entry on the leader if the index is 0,
+// else waits for the checksum of this replica at the index
+type ChecksumHandler struct {
+	server *Server
+}
+
+func (ch *ChecksumHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) (err error) {
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+	store := ch.server.GetPartition(req.PartitionID)
+	if store == nil {
+		msg := fmt.Sprintf("partition not found, partitionId:[%d], nodeID:[%d], node ip:[%s]", req.PartitionID, ch.server.nodeID, ch.server.ip)
+		reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_EXIST, errors.New(msg)).GetError()
+		return nil
+	}
+	checksumReq := &entity.ReplicaChecksum{}
+	if err := vjson.Unmarshal(req.Data, checksumReq); err != nil {
+		reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err).GetError()
+		return nil
+	}
+
+	var checksum *entity.ReplicaChecksum
+	if checksumReq.Index == 0 {
+		checksum, err = store.Checksum(ctx)
+	} else {
+		waitCtx, cancel := context.WithTimeout(ctx, checksumWaitTimeout)
+		checksum, err = store.GetChecksum(waitCtx, checksumReq.Index)
+		cancel()
+	}
+	if err != nil {
+		reply.Err = vearchpb.ToError(err)
+		return nil
+	}
+	if reply.Data, err = vjson.Marshal(checksum); err != nil {
+		log.Error("marshal checksum failed, err: [%v]", err)
+		return err
+	}
+	return nil
+}
+
 type ChangeMemberHandler struct {
 	server *Server
 }
@@ -343,31 +564,39 @@ func (ch *ChangeMemberHandler) Execute(ctx context.Context, req *vearchpb.Partit
 // redirect some other to response and send err to status when happen
 func psErrorChange(server *Server) handler.ErrorChangeFun {
 	return func(ctx context.Context, err error, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) error {
-		if vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError().Code == vearchpb.ErrorEnum_PARTITION_NOT_LEADER || err == raft.ErrNotLeader {
+		if errors.Is(err, vearchpb.ErrPartitionNotLeader) || errors.Is(err, raft.ErrNotLeader) {
 			store := server.GetPartition(req.PartitionID)
 			if store == nil {
 				msg := fmt.Sprintf("partition not found, partitionId:[%d], nodeID:[%d], node ip:[%s]", req.PartitionID, server.nodeID, server.ip)
 				log.Error("%s", msg)
 				return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_EXIST, errors.New(msg))
 			}
-			id, _ := store.GetLeader()
-			if id == 0 {
-				reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NO_LEADER}
-			} else {
-				bytes, err := vjson.Marshal(server.raftResolver.ToReplica(id))
-				if err != nil {
-					log.Error("find raft resolver err[%s]", err.Error())
-					return err
-				}
-				reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NOT_LEADER, Msg: string(bytes)}
+			vErr, err := server.notLeaderError(store)
+			if err != nil {
+				return err
 			}
-
+			reply.Err = vErr
 			return nil
 		}
 		return err
 	}
 }
 
+// notLeaderError is the reply error of a replica which is not the leader of
+// store, it names the leader so the router can send the request there
+func (s *Server) notLeaderError(store PartitionStore) (*vearchpb.Error, error) {
+	id, _ := store.GetLeader()
+	if id == 0 {
+		return &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NO_LEADER}, nil
+	}
+	bytes, err := vjson.Marshal(s.raftResolver.ToReplica(id))
+	if err != nil {
+		log.Error("find raft resolver err[%s]", err.Error())
+		return nil, err
+	}
+	return &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NOT_LEADER, Msg: string(bytes)}, nil
+}
+
 type EngineCfgHandler struct {
 	server *Server
 }
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,8 +36,10 @@ import (
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/server/rpc/handler"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
 )
 
 type limitPlugin struct {
@@ -164,9 +167,50 @@ func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.Partitio
 		}
 	}()
 
+	begin := time.Now()
+	stats := handler.server.partitionStats(req.PartitionID)
+	stats.enqueue()
+	// requests wait for the slot of their resource group first, so they do
+	// not hold the server slots the spaces of other groups need
+	release, vErr := handler.server.acquireResourceGroup(ctx, req.PartitionID)
+	if vErr != nil {
+		stats.dequeue()
+		log.Error(vErr.Error())
+		req.Err = vErr.GetError()
+		return
+	}
+	if release != nil {
+		defer release()
+	}
+	// then for the slot of their tenant, a burst of one tenant waits behind
+	// the requests of the others
+	releaseFair, vErr := handler.server.acquireFairQueue(ctx, req.PartitionID)
+	if vErr != nil {
+		stats.dequeue()
+		log.Error(vErr.Error())
+		req.Err = vErr.GetError()
+		return
+	}
+	if releaseFair != nil {
+		defer releaseFair()
+	}
 	handler.server.concurrent <- true
+	stats.start()
+	// the watchdog may cancel a stuck request and free its slot before the
+	// engine call it is stuck in returns
+	ctx, cancel := context.WithCancel(ctx)
+	finish := cancelOnce(cancel, func() { <-handler.server.concurrent })
+	timeout := time.Duration(handler.server.rpcTimeOut) * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = deadline.Sub(begin)
+	}
+	metaData, _ := ctx.Value(share.ReqMetaDataKey).(map[string]string)
+	untrack := handler.server.watchdog.track(req.PartitionID, metaData[client.HandlerType], timeout, stats, finish)
+	var method string
 	defer func() {
-		<-handler.server.concurrent
+		untrack()
+		finish()
+		stats.done(method, begin, req.Err != nil && req.Err.Code != vearchpb.ErrorEnum_SUCCESS)
 	}()
 	select {
 	case <-ctx.Done():
@@ -186,21 +230,39 @@ func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.Partitio
 			req.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_EXIST, errors.New(msg)).GetError()
 			return
 		}
-		var method string
 		reqMap := ctx.Value(share.ReqMetaDataKey).(map[string]string)
-		method, ok := reqMap[client.HandlerType]
+		var ok bool
+		method, ok = reqMap[client.HandlerType]
 		if !ok {
 			err := fmt.Errorf("client type not support, key [%s]", client.HandlerType)
 			req.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
 			return
 		}
+		// requests for the leader are answered with the leader when this
+		// replica is not, the router retries them there
+		if needLeader(method, reqMap) && !store.IsLeader() {
+			vErr, err := handler.server.notLeaderError(store)
+			if err != nil {
+				vErr = vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_LEADER, err).GetError()
+			}
+			req.Err = vErr
+			return
+		}
 		switch method {
 		case client.GetDocsHandler:
-			getDocuments(ctx, store, req.Items, false, false)
+			asOf, err := entity.ParseAsOf(reqMap)
+			if err != nil {
+				req.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err).GetError()
+				return
+			}
+			getDocuments(ctx, store, req.Items, reqMap[client.ReadReplica] != "true", false, false)
+			if asOf > 0 {
+				getTombstones(store, req.Items, asOf)
+			}
 		case client.GetDocsByPartitionHandler:
-			getDocuments(ctx, store, req.Items, true, false)
+			getDocuments(ctx, store, req.Items, true, true, false)
 		case client.GetNextDocsByPartitionHandler:
-			getDocuments(ctx, store, req.Items, true, true)
+			getDocuments(ctx, store, req.Items, true, true, true)
 		case client.DeleteDocsHandler:
 			deleteDocs(ctx, store, req.Items)
 		case client.BatchHandler:
@@ -234,12 +296,24 @@ func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.Partitio
 	}
 }
 
-func getDocuments(ctx context.Context, store PartitionStore, items []*vearchpb.Item, getByDocId bool, next bool) {
+// needLeader is whether method must be executed by the leader of the partition
+func needLeader(method string, reqMap map[string]string) bool {
+	switch method {
+	case client.BatchHandler, client.DeleteDocsHandler:
+		return true
+	case client.GetDocsHandler:
+		return reqMap[client.ReadReplica] != "true"
+	}
+	return false
+}
+
+func getDocuments(ctx context.Context, store PartitionStore, items []*vearchpb.Item, readLeader bool, getByDocId bool, next bool) {
 	for _, item := range items {
-		if e := store.GetDocument(ctx, true, item.Doc, getByDocId, next); e != nil {
+		if e := store.GetDocument(ctx, readLeader, item.Doc, getByDocId, next); e != nil {
 			msg := fmt.Sprintf("GetDocument failed, key: [%s], err: [%s]", item.Doc.PKey, e.Error())
 			log.Error("%s", msg)
-			if vearchErr, ok := e.(*vearchpb.VearchErr); ok {
+			var vearchErr *vearchpb.VearchErr
+			if errors.As(e, &vearchErr) {
 				item.Err = vearchErr.GetError()
 			} else {
 				item.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_INTERNAL_ERROR, Msg: msg}
@@ -349,12 +423,58 @@ func search(ctx context.Context, store PartitionStore, request *vearchpb.SearchR
 		}
 	}()
 
+	r, err := newRescorer(store, request.Head.Params)
+	if err != nil {
+		response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err).GetError()
+		return
+	}
+	asOf, err := entity.ParseAsOf(request.Head.Params)
+	if err != nil {
+		response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err).GetError()
+		return
+	}
+	topN := request.TopN
+	if r != nil {
+		request.TopN = topN * r.oversample
+	}
+
+	// the applied index is read before the search, the documents applied
+	// while it runs may be seen too
+	var appliedIndex string
+	if request.Head.Params[entity.ProvenanceParam] == "true" {
+		if status := store.Status(); status != nil {
+			appliedIndex = strconv.FormatUint(status.Applied, 10)
+		}
+	}
+
 	startTime := time.Now()
 	if err := store.Search(ctx, request, response); err != nil {
 		log.Error("search doc failed, err: [%s]", err.Error())
 		response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
 		return
 	}
+	// the deleted documents are rescored with the others
+	if asOf > 0 {
+		if err := searchTombstones(store, asOf, request, response); err != nil {
+			log.Error("search tombstones failed, err: [%s]", err.Error())
+			response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err).GetError()
+			return
+		}
+	}
+	if r != nil {
+		if err := r.apply(topN, response); err != nil {
+			log.Error("rescore search result failed, err: [%s]", err.Error())
+			response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
+			return
+		}
+	}
+
+	if appliedIndex != "" && response.Head != nil {
+		if response.Head.Params == nil {
+			response.Head.Params = make(map[string]string)
+		}
+		response.Head.Params[entity.AppliedIndexParam] = appliedIndex
+	}
 
 	partitionIDstr := strconv.FormatUint(uint64(store.GetEngine().GetPartitionID()), 10)
 	storeSearch := (time.Since(startTime).Seconds()) * 1000
@@ -368,6 +488,145 @@ func search(ctx context.Context, store PartitionStore, request *vearchpb.SearchR
 	}
 }
 
+// rescorer replaces the score of the oversampled candidates of each query by
+// a boost, a score script or udfs and cuts them to topN, so it can lift
+// documents the vector score alone would cut
+type rescorer struct {
+	score      func(score float64, fields []*vearchpb.Field, proMap map[string]*entity.SpaceProperties) float64
+	check      func(candidates int) error
+	udf        *udfRun
+	oversample int32
+	metricType string
+	proMap     map[string]*entity.SpaceProperties
+}
+
+// newRescorer returns nil if the request carries no boost, score script or
+// udf
+func newRescorer(store PartitionStore, params map[string]string) (*rescorer, error) {
+	b, s, u := params[entity.BoostParam], params[entity.ScoreScriptParam], params[entity.UDFParam]
+	if b == "" && s == "" && u == "" {
+		return nil, nil
+	}
+	space := store.GetSpace()
+	r := &rescorer{proMap: space.SpaceProperties}
+	if r.proMap == nil {
+		r.proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	if u != "" {
+		call := &entity.UDFCall{}
+		if err := vjson.Unmarshal([]byte(u), call); err != nil {
+			return nil, err
+		}
+		run, err := newUDFRun(&space, call, r.proMap)
+		if err != nil {
+			return nil, err
+		}
+		r.udf, r.oversample, r.metricType = run, call.Oversample, call.MetricType
+		return r, nil
+	}
+	if b != "" {
+		boost := &entity.Boost{}
+		if err := vjson.Unmarshal([]byte(b), boost); err != nil {
+			return nil, err
+		}
+		r.score, r.oversample, r.metricType = boost.Score, boost.Oversample, boost.MetricType
+		return r, nil
+	}
+	script := &entity.ScoreScript{}
+	if err := vjson.Unmarshal([]byte(s), script); err != nil {
+		return nil, err
+	}
+	// compiled once for all the candidates of the request
+	if err := script.Compile(r.proMap); err != nil {
+		return nil, err
+	}
+	r.score, r.check, r.oversample, r.metricType = script.Score, script.CheckCost, script.Oversample, script.MetricType
+	return r, nil
+}
+
+func (r *rescorer) apply(topN int32, response *vearchpb.SearchResponse) error {
+	if response.FlatBytes == nil {
+		return nil
+	}
+	searchResponse := &vearchpb.SearchResponse{}
+	if err := proto.Unmarshal(response.FlatBytes, searchResponse); err != nil {
+		return err
+	}
+	if r.check != nil {
+		candidates := 0
+		for _, result := range searchResponse.Results {
+			if result != nil {
+				candidates += len(result.ResultItems)
+			}
+		}
+		if err := r.check(candidates); err != nil {
+			return err
+		}
+	}
+	for _, result := range searchResponse.Results {
+		if result == nil {
+			continue
+		}
+		if r.udf != nil {
+			items, err := r.udf.apply(result.ResultItems)
+			if err != nil {
+				return err
+			}
+			result.ResultItems = items
+		} else {
+			for _, item := range result.ResultItems {
+				item.Score = r.score(item.Score, item.Fields, r.proMap)
+			}
+		}
+		items := result.ResultItems
+		sortResultItems(items, r.metricType == "L2")
+		if topN > 0 && int32(len(items)) > topN {
+			result.ResultItems = items[:topN]
+		}
+		if len(result.ResultItems) > 0 {
+			result.MaxScore = result.ResultItems[0].Score
+		}
+	}
+	flatBytes, err := proto.Marshal(searchResponse)
+	if err != nil {
+		return err
+	}
+	response.FlatBytes = flatBytes
+	return nil
+}
+
+// sortResultItems sorts items by score and then by document id, so replicas
+// and routers cut equal scores at topN the same way
+func sortResultItems(items []*vearchpb.ResultItem, asc bool) {
+	keys := make(map[*vearchpb.ResultItem]string, len(items))
+	for _, item := range items {
+		keys[item] = resultItemKey(item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score {
+			if asc {
+				return items[i].Score < items[j].Score
+			}
+			return items[i].Score > items[j].Score
+		}
+		return keys[items[i]] < keys[items[j]]
+	})
+}
+
+// resultItemKey returns the document id of an item, engine results carry it
+// in the _id field
+func resultItemKey(item *vearchpb.ResultItem) string {
+	if item.PKey != "" {
+		return item.PKey
+	}
+	for _, f := range item.Fields {
+		if f.Name == entity.IdField {
+			return string(f.Value)
+		}
+	}
+	return ""
+}
+
 func forceMerge(store PartitionStore) *vearchpb.Error {
 	err := store.GetEngine().Optimize()
 	if err != nil {
@@ -0,0 +1,64 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestSortResultItems(t *testing.T) {
+	item := func(id string, score float64) *vearchpb.ResultItem {
+		return &vearchpb.ResultItem{Score: score, Fields: []*vearchpb.Field{{Name: entity.IdField, Value: []byte(id)}}}
+	}
+	tests := []struct {
+		name  string
+		items []*vearchpb.ResultItem
+		asc   bool
+		want  []string
+	}{
+		{
+			name:  "Desc score",
+			items: []*vearchpb.ResultItem{item("a", 0.1), item("b", 0.9), item("c", 0.5)},
+			want:  []string{"b", "c", "a"},
+		},
+		{
+			name:  "Desc ties by id",
+			items: []*vearchpb.ResultItem{item("c", 0.5), item("a", 0.5), item("b", 0.9)},
+			want:  []string{"b", "a", "c"},
+		},
+		{
+			name:  "Asc ties by id",
+			items: []*vearchpb.ResultItem{item("b", 1), item("a", 1), item("c", 0)},
+			asc:   true,
+			want:  []string{"c", "a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortResultItems(tt.items, tt.asc)
+			got := make([]string, 0, len(tt.items))
+			for _, item := range tt.items {
+				got = append(got, resultItemKey(item))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortResultItems() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
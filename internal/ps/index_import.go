@@ -0,0 +1,171 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/errutil"
+	"github.com/vearch/vearch/v3/internal/pkg/fileutil"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// IndexImportHandler validates the manifest of an index built offline and
+// imports it in background, or replies the status of the last import
+type IndexImportHandler struct {
+	server *Server
+}
+
+func (ih *IndexImportHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) (err error) {
+	defer errutil.CatchError(&err)
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+
+	store := ih.server.GetPartition(req.PartitionID)
+	if store == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_INVALID, fmt.Errorf("partition (%v), partitonStore is nil ", req.PartitionID))
+	}
+	indexImport := &entity.IndexImport{}
+	if err := vjson.Unmarshal(req.Data, indexImport); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
+
+	var status *entity.IndexImportStatus
+	switch indexImport.Command {
+	case entity.IndexImportCommandStatus:
+		status = &entity.IndexImportStatus{PartitionID: req.PartitionID, NodeID: ih.server.nodeID}
+		if s, ok := ih.server.indexImports.Load(req.PartitionID); ok {
+			status = s.(*entity.IndexImportStatus)
+		}
+	case entity.IndexImportCommandStart:
+		if status, err = ih.start(store, req.PartitionID, indexImport); err != nil {
+			return err
+		}
+	default:
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unknow command %s", indexImport.Command))
+	}
+	reply.Data, err = vjson.Marshal(status)
+	return err
+}
+
+// start checks the manifest against the space and the documents of the
+// partition before the files are downloaded
+func (ih *IndexImportHandler) start(store PartitionStore, pid entity.PartitionID, indexImport *entity.IndexImport) (*entity.IndexImportStatus, error) {
+	minioClient, err := minio.New(indexImport.S3Param.EndPoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(indexImport.S3Param.AccessKey, indexImport.S3Param.SecretKey, ""),
+		Secure: indexImport.S3Param.UseSSL,
+	})
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to create minio client: %v", err))
+	}
+	objectDir := path.Join(indexImport.Prefix, fmt.Sprintf("%d", pid))
+
+	object, err := minioClient.GetObject(context.Background(), indexImport.S3Param.BucketName, path.Join(objectDir, entity.IndexManifestFile), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get index manifest: %v", err))
+	}
+	data, err := io.ReadAll(object)
+	object.Close()
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("read index manifest: %v", err))
+	}
+	manifest := &entity.IndexManifest{}
+	if err := vjson.Unmarshal(data, manifest); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("parse index manifest: %v", err))
+	}
+	space := store.GetSpace()
+	if err := manifest.Validate(&space, indexImport.Field); err != nil {
+		return nil, err
+	}
+	docNum, err := store.IndexedDocNum()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.VectorNum > docNum {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index has %d vectors but partition %d has %d documents", manifest.VectorNum, pid, docNum))
+	}
+
+	engineConfig := entity.EngineConfig{}
+	if err := store.GetEngine().GetEngineCfg(&engineConfig); err != nil {
+		return nil, err
+	}
+	importDir := filepath.Join(*engineConfig.Path, "import")
+
+	status := &entity.IndexImportStatus{
+		PartitionID: pid,
+		NodeID:      ih.server.nodeID,
+		Field:       indexImport.Field,
+		State:       entity.IndexImportRunning,
+		StartTime:   time.Now().Unix(),
+	}
+	if last, loaded := ih.server.indexImports.LoadOrStore(pid, status); loaded {
+		if last.(*entity.IndexImportStatus).State == entity.IndexImportRunning {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index import of partition %d is running", pid))
+		}
+		ih.server.indexImports.Store(pid, status)
+	}
+
+	go func() {
+		result := *status
+		result.State = entity.IndexImportDone
+		if err := importIndex(store, minioClient, indexImport, objectDir, importDir, manifest); err != nil {
+			log.Error("import index of partition %d field %s err: %v", pid, indexImport.Field, err)
+			result.State = entity.IndexImportFailed
+			result.Error = err.Error()
+		} else {
+			log.Info("import index of partition %d field %s with %d vectors done", pid, indexImport.Field, manifest.VectorNum)
+		}
+		result.EndTime = time.Now().Unix()
+		ih.server.indexImports.Store(pid, &result)
+	}()
+	return status, nil
+}
+
+// importIndex downloads the files of manifest, verifies their checksums and
+// installs them into the engine
+func importIndex(store PartitionStore, minioClient *minio.Client, indexImport *entity.IndexImport, objectDir, importDir string, manifest *entity.IndexManifest) error {
+	if err := os.MkdirAll(importDir, os.ModePerm); err != nil {
+		return err
+	}
+	defer os.RemoveAll(importDir)
+
+	files := make(map[string]string, len(manifest.Files))
+	for name, sum := range manifest.Files {
+		local := filepath.Join(importDir, name)
+		err := minioClient.FGetObject(context.Background(), indexImport.S3Param.BucketName, path.Join(objectDir, name), local, minio.GetObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("download index file %s: %v", name, err)
+		}
+		_, actual, err := fileutil.FileSha256(local)
+		if err != nil {
+			return err
+		}
+		if actual != sum {
+			return fmt.Errorf("checksum of index file %s is %s but manifest has %s", name, actual, sum)
+		}
+		files[name] = local
+	}
+	return store.ImportIndex(context.Background(), indexImport.Field, files)
+}
@@ -0,0 +1,194 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// statsFields are the scalar fields of space filters can use, by name
+func statsFields(space *entity.Space) map[string]vearchpb.FieldType {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = entity.UnmarshalPropertyJSON(space.Fields); err != nil {
+			return nil
+		}
+	}
+	fields := make(map[string]vearchpb.FieldType)
+	for name, pro := range proMap {
+		if pro.FieldType == vearchpb.FieldType_VECTOR || pro.Option&entity.FieldOption_Index != entity.FieldOption_Index {
+			continue
+		}
+		fields[name] = pro.FieldType
+	}
+	return fields
+}
+
+// addStatsValue adds the value of a sampled field to the sampler
+func addStatsValue(sampler *entity.StatsSampler, name string, fieldType vearchpb.FieldType, value []byte) {
+	if len(value) == 0 {
+		return
+	}
+	switch fieldType {
+	case vearchpb.FieldType_INT:
+		sampler.AddNumber(name, float64(cbbytes.Bytes2Int32(value)))
+	case vearchpb.FieldType_LONG, vearchpb.FieldType_DATE:
+		sampler.AddNumber(name, float64(cbbytes.Bytes2Int(value)))
+	case vearchpb.FieldType_FLOAT:
+		sampler.AddNumber(name, float64(cbbytes.ByteToFloat32(value)))
+	case vearchpb.FieldType_DOUBLE:
+		sampler.AddNumber(name, cbbytes.ByteToFloat64New(value))
+	case vearchpb.FieldType_STRING:
+		sampler.AddTerm(name, string(value))
+	case vearchpb.FieldType_STRINGARRAY:
+		for _, v := range bytes.Split(value, []byte{'\001'}) {
+			sampler.AddTerm(name, string(v))
+		}
+	}
+}
+
+// sampleIndexStats reads up to samples documents of the partition at random
+// docids, all of them if the partition has fewer, and returns the stats of
+// their scalar fields. It returns nil if the space has no such field
+func sampleIndexStats(ctx context.Context, store PartitionStore, samples int) (*entity.IndexStats, error) {
+	engine := store.GetEngine()
+	if engine == nil {
+		return nil, nil
+	}
+	space := store.GetSpace()
+	fields := statsFields(&space)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	status := &entity.EngineStatus{}
+	if err := engine.GetEngineStatus(status); err != nil {
+		return nil, err
+	}
+
+	sampler := entity.NewStatsSampler()
+	reader := engine.Reader()
+	maxDocid := int(status.MaxDocid)
+	scan := maxDocid <= samples
+	sampled := 0
+	// deleted documents are missed, so twice the samples are tried
+	for try := 0; sampled < samples && try < 2*samples; try++ {
+		docID := try
+		if scan {
+			if docID >= maxDocid {
+				break
+			}
+		} else {
+			docID = rand.Intn(maxDocid)
+		}
+		doc := &vearchpb.Document{PKey: strconv.Itoa(docID)}
+		if err := reader.GetDoc(ctx, doc, true, false); err != nil {
+			continue
+		}
+		sampled++
+		sampler.AddDoc()
+		for _, f := range doc.Fields {
+			if fieldType, ok := fields[f.Name]; ok {
+				addStatsValue(sampler, f.Name, fieldType, f.Value)
+			}
+		}
+	}
+	return sampler.Stats(store.GetPartition().Id, uint64(status.DocNum), time.Now().Unix()), nil
+}
+
+// StartIndexStatsJob samples the scalar fields of every partition of this
+// server periodically for the filter planner of routers
+func (s *Server) StartIndexStatsJob() {
+	cfg := config.Conf().PS.IndexStats
+	if cfg == nil {
+		cfg = &config.StatsCfg{}
+	}
+	if cfg.Disabled {
+		return
+	}
+	interval, samples := time.Duration(config.DefaultStatsInterval)*time.Second, config.DefaultStatsSamples
+	if cfg.Interval > 0 {
+		interval = time.Duration(cfg.Interval) * time.Second
+	}
+	if cfg.Samples > 0 {
+		samples = cfg.Samples
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		s.sampleIndexStats(samples)
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.sampleIndexStats(samples)
+			}
+		}
+	}()
+}
+
+func (s *Server) sampleIndexStats(samples int) {
+	live := make(map[entity.PartitionID]bool)
+	s.RangePartition(func(pid entity.PartitionID, store PartitionStore) {
+		live[pid] = true
+		stats, err := sampleIndexStats(s.ctx, store, samples)
+		if err != nil {
+			log.Warn("sample index stats of partition [%d] failed, err: [%v]", pid, err)
+			return
+		}
+		if stats == nil {
+			s.indexStats.Delete(pid)
+			return
+		}
+		s.indexStats.Store(pid, stats)
+	})
+	s.indexStats.Range(func(key, value interface{}) bool {
+		if !live[key.(entity.PartitionID)] {
+			s.indexStats.Delete(key)
+		}
+		return true
+	})
+}
+
+// IndexStatsHandler returns the latest index stats sampled of a partition,
+// no data if they are not sampled yet
+type IndexStatsHandler struct {
+	server *Server
+}
+
+func (ish *IndexStatsHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) (err error) {
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+	v, ok := ish.server.indexStats.Load(req.PartitionID)
+	if !ok {
+		return nil
+	}
+	if reply.Data, err = vjson.Marshal(v.(*entity.IndexStats)); err != nil {
+		log.Error("marshal index stats failed, err: [%v]", err)
+		return err
+	}
+	return nil
+}
@@ -76,13 +76,33 @@ type PartitionStore interface {
 
 	GetDocument(ctx context.Context, readLeader bool, doc *vearchpb.Document, getByDocId bool, next bool) (err error)
 
+	Tombstone(key string, asOf int64) (*vearchpb.Document, error)
+
+	Tombstones(asOf int64) ([]*vearchpb.Document, error)
+
 	Write(ctx context.Context, request *vearchpb.DocCmd) (err error)
 
 	Flush(ctx context.Context) error
 
+	Checksum(ctx context.Context) (*entity.ReplicaChecksum, error)
+
+	GetChecksum(ctx context.Context, index uint64) (*entity.ReplicaChecksum, error)
+
+	SetFencingToken(token *entity.FencingToken)
+
 	Search(ctx context.Context, query *vearchpb.SearchRequest, response *vearchpb.SearchResponse) error
 
 	Query(ctx context.Context, query *vearchpb.QueryRequest, response *vearchpb.SearchResponse) error
+
+	IndexedDocNum() (int64, error)
+
+	ImportIndex(ctx context.Context, field string, files map[string]string) error
+
+	CopyDir(name string) string
+
+	SnapshotFiles(ctx context.Context, dir string) ([]*entity.SnapshotFile, error)
+
+	ReplaceData(ctx context.Context, dir string) error
 }
 
 func (s *Server) GetPartition(id entity.PartitionID) (partition PartitionStore) {
@@ -151,6 +171,7 @@ func (s *Server) LoadPartition(ctx context.Context, pid entity.PartitionID, spac
 	}
 
 	s.partitions.Store(pid, store)
+	s.loadEngineConfig(ctx, pid, store)
 
 	return store, nil
 }
@@ -195,6 +216,7 @@ func (s *Server) CreatePartition(ctx context.Context, space *entity.Space, pid e
 		if err = store.Start(); err != nil {
 			return err
 		}
+		s.loadEngineConfig(ctx, pid, store)
 	}
 
 	s.partitions.Store(pid, store)
@@ -207,6 +229,7 @@ func (s *Server) DeleteReplica(id entity.PartitionID) {
 
 	if p, ok := s.partitions.Load(id); ok {
 		s.partitions.Delete(id)
+		s.stats.Delete(id)
 		if partition, is := p.(PartitionStore); is {
 			s.raftResolver.DeleteNode(s.nodeID)
 			if err := partition.Destroy(); err != nil {
@@ -215,6 +238,9 @@ func (s *Server) DeleteReplica(id entity.PartitionID) {
 		}
 	}
 
+	if s.dropQuarantine(context.Background(), id) {
+		log.Info("delete quarantined partition[%d]", id)
+	}
 	log.Info("delete partition[%d] success", id)
 }
 
@@ -237,12 +263,16 @@ func (s *Server) DeletePartition(id entity.PartitionID) {
 		}
 	}
 
+	if s.dropQuarantine(context.Background(), id) {
+		log.Info("delete quarantined partition[%d]", id)
+	}
 	log.Info("delete partition:[%d] success", id)
 
 	// delete partition cache
 	if _, ok := s.partitions.Load(id); ok {
 		s.partitions.Delete(id)
 	}
+	s.stats.Delete(id)
 }
 
 func (s *Server) PartitionNum() int {
@@ -277,14 +307,7 @@ func (s *Server) recoverPartitions(pids []entity.PartitionID, spaces []*entity.S
 		idx := i
 		go func(pid entity.PartitionID) {
 			defer wg.Done()
-
-			log.Debug("starting recover partition[%d]...", pid)
-			_, err := s.LoadPartition(ctx, pid, spaces)
-			if err != nil {
-				log.Error("init partition err :[%s]", err.Error())
-			} else {
-				log.Debug("partition[%d] recovered complete", pid)
-			}
+			s.recoverPartition(ctx, pid, spaces)
 		}(pids[idx])
 	}
 
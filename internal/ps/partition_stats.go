@@ -0,0 +1,175 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/algorithm"
+)
+
+const (
+	// statsWindow is the seconds the request statistics of a partition cover
+	statsWindow = 60
+	// statsBucketSamples is the latency samples kept by second and request type
+	statsBucketSamples = 256
+)
+
+// partitionStats records the requests of a partition in a sliding window of
+// one second buckets, latencies are sampled by reservoir in each bucket
+type partitionStats struct {
+	queued     atomic.Int64
+	running    atomic.Int64
+	stuckNow   atomic.Int64
+	stuckTotal atomic.Int64
+
+	mu  sync.Mutex
+	ops map[string]*[statsWindow]statsBucket
+}
+
+type statsBucket struct {
+	second  int64
+	count   int64
+	errors  int64
+	samples []float64
+}
+
+func newPartitionStats() *partitionStats {
+	return &partitionStats{ops: make(map[string]*[statsWindow]statsBucket)}
+}
+
+// partitionStats returns the stats of a partition, nil if the partition is
+// not on this server
+func (s *Server) partitionStats(pid entity.PartitionID) *partitionStats {
+	if v, ok := s.stats.Load(pid); ok {
+		return v.(*partitionStats)
+	}
+	if s.GetPartition(pid) == nil {
+		return nil
+	}
+	v, _ := s.stats.LoadOrStore(pid, newPartitionStats())
+	return v.(*partitionStats)
+}
+
+func (ps *partitionStats) enqueue() {
+	if ps != nil {
+		ps.queued.Add(1)
+	}
+}
+
+func (ps *partitionStats) dequeue() {
+	if ps != nil {
+		ps.queued.Add(-1)
+	}
+}
+
+func (ps *partitionStats) start() {
+	if ps != nil {
+		ps.queued.Add(-1)
+		ps.running.Add(1)
+	}
+}
+
+// stuck counts a request the watchdog found stuck
+func (ps *partitionStats) stuck() {
+	if ps != nil {
+		ps.stuckNow.Add(1)
+		ps.stuckTotal.Add(1)
+	}
+}
+
+func (ps *partitionStats) unstuck() {
+	if ps != nil {
+		ps.stuckNow.Add(-1)
+	}
+}
+
+// done records a request of the handler method started at begin
+func (ps *partitionStats) done(method string, begin time.Time, failed bool) {
+	if ps == nil {
+		return
+	}
+	ps.running.Add(-1)
+	if method == "" {
+		return
+	}
+	now := time.Now()
+	latency := float64(now.Sub(begin).Microseconds()) / 1000
+	op := strings.ToLower(strings.TrimSuffix(method, "Handler"))
+	second := now.Unix()
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	buckets, ok := ps.ops[op]
+	if !ok {
+		buckets = new([statsWindow]statsBucket)
+		ps.ops[op] = buckets
+	}
+	b := &buckets[second%statsWindow]
+	if b.second != second {
+		b.second, b.count, b.errors, b.samples = second, 0, 0, b.samples[:0]
+	}
+	b.count++
+	if failed {
+		b.errors++
+	}
+	if len(b.samples) < statsBucketSamples {
+		b.samples = append(b.samples, latency)
+	} else if i := rand.Int63n(b.count); i < statsBucketSamples {
+		b.samples[i] = latency
+	}
+}
+
+// snapshot returns the statistics by request type of the last window
+func (ps *partitionStats) snapshot() map[string]*entity.RequestStats {
+	result := make(map[string]*entity.RequestStats)
+	if ps == nil {
+		return result
+	}
+	now := time.Now().Unix()
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for op, buckets := range ps.ops {
+		stats := &entity.RequestStats{}
+		var samples []float64
+		for i := range buckets {
+			b := &buckets[i]
+			if b.count == 0 || now-b.second >= statsWindow {
+				continue
+			}
+			stats.Count += b.count
+			stats.Errors += b.errors
+			samples = append(samples, b.samples...)
+		}
+		if stats.Count == 0 {
+			continue
+		}
+		stats.Qps = float64(stats.Count) / statsWindow
+		sort.Float64s(samples)
+		stats.P50 = algorithm.Percentile(samples, 0.5)
+		stats.P90 = algorithm.Percentile(samples, 0.9)
+		stats.P99 = algorithm.Percentile(samples, 0.99)
+		stats.Max = samples[len(samples)-1]
+		result[op] = stats
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPartitionStats(t *testing.T) {
+	ps := newPartitionStats()
+	for i := 0; i < 3; i++ {
+		ps.enqueue()
+	}
+	ps.start()
+	ps.start()
+	ps.dequeue()
+	if ps.queued.Load() != 0 || ps.running.Load() != 2 {
+		t.Fatalf("queued %d running %d, want 0 and 2", ps.queued.Load(), ps.running.Load())
+	}
+	ps.done("SearchHandler", time.Now().Add(-10*time.Millisecond), false)
+	ps.done("SearchHandler", time.Now().Add(-30*time.Millisecond), true)
+	if ps.running.Load() != 0 {
+		t.Errorf("running %d, want 0", ps.running.Load())
+	}
+
+	stats := ps.snapshot()["search"]
+	if stats == nil {
+		t.Fatalf("search stats not found in %v", ps.snapshot())
+	}
+	if stats.Count != 2 || stats.Errors != 1 || stats.Qps != 2.0/statsWindow {
+		t.Errorf("stats = %+v, want 2 requests with 1 error", stats)
+	}
+	if stats.P50 < 10 || stats.Max < 30 || stats.P50 > stats.Max {
+		t.Errorf("latencies p50 %.2f max %.2f, want about 10 and 30 ms", stats.P50, stats.Max)
+	}
+
+	var nilStats *partitionStats
+	nilStats.enqueue()
+	nilStats.done("SearchHandler", time.Now(), false)
+	if len(nilStats.snapshot()) != 0 {
+		t.Errorf("stats of a partition not on the server should be empty")
+	}
+}
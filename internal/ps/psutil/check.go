@@ -0,0 +1,290 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package psutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft/storage/wal"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fileutil"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+// the engines keep their applied raft index in this file of the data path
+const engineSnFile = "sn"
+
+// the most bytes of raft log entries read at once by a check
+const checkEntriesSize = 4 << 20
+
+// CheckReport is the result of checking the local data of a ps without
+// joining the cluster, Ok is false if any error is found
+type CheckReport struct {
+	Cluster    string            `json:"cluster"`
+	NodeID     entity.NodeID     `json:"node_id"`
+	Time       int64             `json:"time"`
+	Ok         bool              `json:"ok"`
+	Errors     []string          `json:"errors,omitempty"`
+	Partitions []*PartitionCheck `json:"partitions"`
+}
+
+type PartitionCheck struct {
+	PartitionID entity.PartitionID `json:"partition_id"`
+	Path        string             `json:"path"`
+	SpaceID     entity.SpaceID     `json:"space_id,omitempty"`
+	SpaceName   string             `json:"space_name,omitempty"`
+	Ok          bool               `json:"ok"`
+	Quarantined string             `json:"quarantined,omitempty"`
+	// the loads which did not finish since the last finished one
+	CrashedLoads int    `json:"crashed_loads,omitempty"`
+	DataFiles    int    `json:"data_files"`
+	DataBytes    int64  `json:"data_bytes"`
+	DataSha256   string `json:"data_sha256,omitempty"`
+	// the raft index applied by the engine
+	AppliedIndex int64         `json:"applied_index"`
+	Raft         *RaftLogCheck `json:"raft,omitempty"`
+	Errors       []string      `json:"errors,omitempty"`
+	Warnings     []string      `json:"warnings,omitempty"`
+}
+
+type RaftLogCheck struct {
+	FirstIndex uint64 `json:"first_index"`
+	LastIndex  uint64 `json:"last_index"`
+	Term       uint64 `json:"term"`
+	Commit     uint64 `json:"commit"`
+	Entries    uint64 `json:"entries"`
+	// the last entries were written partially, raft drops them on start
+	TornTail bool `json:"torn_tail,omitempty"`
+}
+
+func (pc *PartitionCheck) errorf(format string, args ...interface{}) {
+	pc.Errors = append(pc.Errors, fmt.Sprintf(format, args...))
+}
+
+func (pc *PartitionCheck) warnf(format string, args ...interface{}) {
+	pc.Warnings = append(pc.Warnings, fmt.Sprintf(format, args...))
+}
+
+// CheckLocalData checks the node meta and the data, manifest and raft log of
+// every local partition, nothing is written to the data dirs
+func CheckLocalData() *CheckReport {
+	report := &CheckReport{Cluster: config.Conf().Global.Name, Time: time.Now().Unix()}
+	nodeID, err := checkNodeMeta(report.Cluster, config.Conf().GetDataDir())
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.NodeID = nodeID
+
+	pids := GetAllPartitions(config.Conf().GetDatas())
+	slices.Sort(pids)
+	report.Partitions = make([]*PartitionCheck, 0, len(pids))
+	for _, pid := range pids {
+		report.Partitions = append(report.Partitions, CheckPartition(config.Conf().GetDataDirBySlot(config.PS, pid), pid))
+	}
+
+	report.Ok = len(report.Errors) == 0
+	for _, pc := range report.Partitions {
+		report.Ok = report.Ok && pc.Ok
+	}
+	return report
+}
+
+func checkNodeMeta(cluster, dataPath string) (entity.NodeID, error) {
+	b, err := os.ReadFile(filepath.Join(dataPath, MetaFile))
+	if err != nil {
+		return 0, fmt.Errorf("read node meta: %v", err)
+	}
+	m := &meta{}
+	if err := vjson.Unmarshal(b, m); err != nil {
+		return 0, fmt.Errorf("decode node meta: %v", err)
+	}
+	if m.ClusterName != cluster {
+		return m.Id, fmt.Errorf("node meta is of cluster %s, not %s", m.ClusterName, cluster)
+	}
+	if m.Id == 0 {
+		return 0, fmt.Errorf("node meta has no node id")
+	}
+	return m.Id, nil
+}
+
+// CheckPartition checks the local data of partition id under path
+func CheckPartition(path string, id entity.PartitionID) *PartitionCheck {
+	pc := &PartitionCheck{PartitionID: id, Path: path}
+	data, raft, meta := GetPartitionPaths(path, id)
+
+	if q := LoadQuarantine(path, id); q != nil {
+		pc.Quarantined = q.Error
+		pc.warnf("partition is quarantined: %s", q.Error)
+	}
+	if b, err := os.ReadFile(filepath.Join(meta, LoadingFile)); err == nil {
+		pc.CrashedLoads, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+		pc.warnf("%d loads of the partition did not finish", pc.CrashedLoads)
+	}
+
+	if space, err := LoadPartitionMeta(path, id); err != nil {
+		pc.errorf("load partition meta: %v", err)
+	} else {
+		pc.SpaceID, pc.SpaceName = space.Id, space.Name
+		if space.GetPartition(id) == nil {
+			pc.errorf("partition is not in the meta of space %s", space.Name)
+		}
+	}
+
+	checkData(pc, data)
+	checkRaftLog(pc, raft)
+	if pc.Raft != nil && pc.AppliedIndex > 0 {
+		applied := uint64(pc.AppliedIndex)
+		if applied+1 < pc.Raft.FirstIndex {
+			pc.errorf("raft log starts at %d after the applied index %d, the entries between are lost", pc.Raft.FirstIndex, applied)
+		}
+		if applied > pc.Raft.LastIndex {
+			pc.errorf("applied index %d is beyond the last raft log index %d", applied, pc.Raft.LastIndex)
+		}
+	}
+
+	pc.Ok = len(pc.Errors) == 0
+	return pc
+}
+
+// checkData reads every file of the data path, the digest of their names
+// and contents tells whether the data changed between checks
+func checkData(pc *PartitionCheck, data string) {
+	if _, err := os.Stat(data); err != nil {
+		pc.errorf("data path: %v", err)
+		return
+	}
+	digest := sha256.New()
+	err := filepath.WalkDir(data, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		name, _ := filepath.Rel(data, file)
+		size, sum, err := fileutil.FileSha256(file)
+		if err != nil {
+			pc.errorf("read data file %s: %v", name, err)
+			return nil
+		}
+		pc.DataFiles++
+		pc.DataBytes += size
+		fmt.Fprintf(digest, "%s %s\n", name, sum)
+		return nil
+	})
+	if err != nil {
+		pc.errorf("walk data path: %v", err)
+	}
+	pc.DataSha256 = hex.EncodeToString(digest.Sum(nil))
+
+	b, err := os.ReadFile(filepath.Join(data, engineSnFile))
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		pc.errorf("read applied index: %v", err)
+		return
+	}
+	if pc.AppliedIndex, err = strconv.ParseInt(string(b), 10, 64); err != nil {
+		pc.errorf("parse applied index: %v", err)
+	}
+}
+
+// checkRaftLog opens a copy of the raft log, since opening truncates a
+// torn tail, and reads all its entries verifying their crc
+func checkRaftLog(pc *PartitionCheck, raft string) {
+	if _, err := os.Stat(raft); err != nil {
+		pc.errorf("raft path: %v", err)
+		return
+	}
+	dir, err := os.MkdirTemp("", fmt.Sprintf("vearch_check_%d_", pc.PartitionID))
+	if err != nil {
+		pc.errorf("create raft log copy: %v", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	sizes, err := copyDir(raft, dir)
+	if err != nil {
+		pc.errorf("copy raft log: %v", err)
+		return
+	}
+
+	storage, err := wal.NewStorage(dir, nil)
+	if err != nil {
+		pc.errorf("open raft log: %v", err)
+		return
+	}
+	rc := &RaftLogCheck{}
+	pc.Raft = rc
+	state, _ := storage.InitialState()
+	rc.Term, rc.Commit = state.Term, state.Commit
+	rc.FirstIndex, _ = storage.FirstIndex()
+	rc.LastIndex, _ = storage.LastIndex()
+	for lo := rc.FirstIndex; lo <= rc.LastIndex; {
+		entries, _, err := storage.Entries(lo, rc.LastIndex+1, checkEntriesSize)
+		if err != nil {
+			pc.errorf("read raft log at %d: %v", lo, err)
+			break
+		}
+		if len(entries) == 0 {
+			pc.errorf("raft log has no entry %d before its last index %d", lo, rc.LastIndex)
+			break
+		}
+		rc.Entries += uint64(len(entries))
+		lo += uint64(len(entries))
+	}
+	storage.Close()
+
+	if rc.Commit > rc.LastIndex {
+		pc.errorf("raft log commit %d is beyond its last index %d", rc.Commit, rc.LastIndex)
+	}
+	for name, size := range sizes {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.Size() < size {
+			rc.TornTail = true
+			pc.warnf("raft log file %s has a torn tail of %d bytes", name, size-info.Size())
+		}
+	}
+}
+
+// copyDir copies the regular files of src into dst, it returns their sizes
+// by the path relative to src
+func copyDir(src, dst string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	err := filepath.WalkDir(src, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(src, file)
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, name), os.ModePerm)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		size, err := fileutil.CopyFile(file, filepath.Join(dst, name))
+		sizes[name] = size
+		return err
+	})
+	return sizes, err
+}
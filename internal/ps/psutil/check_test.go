@@ -0,0 +1,102 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package psutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
+	"github.com/cubefs/cubefs/depends/tiglabs/raft/storage/wal"
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func writeCheckPartition(t *testing.T, path string, id entity.PartitionID, applied string, entries uint64) {
+	space := &entity.Space{Id: 1, Name: "ts", Partitions: []*entity.Partition{{Id: id}}}
+	data, raft, _, err := CreatePartitionPaths(path, space, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(data, "vectors"), []byte("vectors"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if applied != "" {
+		if err := os.WriteFile(filepath.Join(data, engineSnFile), []byte(applied), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	storage, err := wal.NewStorage(raft, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	for i := uint64(1); i <= entries; i++ {
+		if err := storage.StoreEntries([]*proto.Entry{{Index: i, Term: 1, Data: []byte("doc")}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.StoreHardState(proto.HardState{Term: 1, Commit: entries}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckPartition(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied string
+		entries uint64
+		ok      bool
+	}{
+		{name: "consistent", applied: "3", entries: 5, ok: true},
+		{name: "nothing applied", entries: 2, ok: true},
+		{name: "applied beyond raft log", applied: "9", entries: 5},
+		{name: "bad applied index", applied: "x", entries: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := t.TempDir()
+			writeCheckPartition(t, path, 1, tt.applied, tt.entries)
+			pc := CheckPartition(path, 1)
+			if pc.Ok != tt.ok {
+				t.Fatalf("ok %v, want %v, errors %v", pc.Ok, tt.ok, pc.Errors)
+			}
+			if pc.Raft == nil || pc.Raft.Entries != tt.entries || pc.Raft.LastIndex != tt.entries {
+				t.Errorf("unexpected raft log check %+v", pc.Raft)
+			}
+			if pc.SpaceName != "ts" || pc.DataFiles == 0 || pc.DataSha256 == "" {
+				t.Errorf("unexpected partition check %+v", pc)
+			}
+		})
+	}
+}
+
+func TestCheckPartitionMissing(t *testing.T) {
+	pc := CheckPartition(t.TempDir(), 1)
+	if pc.Ok || len(pc.Errors) != 3 {
+		t.Errorf("errors %v, want meta, data and raft errors", pc.Errors)
+	}
+}
+
+func TestCheckPartitionQuarantined(t *testing.T) {
+	path := t.TempDir()
+	writeCheckPartition(t, path, 1, "1", 1)
+	if err := SaveQuarantine(path, 1, &entity.QuarantinedPartition{PartitionID: 1, Error: "bad index"}); err != nil {
+		t.Fatal(err)
+	}
+	if pc := CheckPartition(path, 1); !pc.Ok || pc.Quarantined != "bad index" {
+		t.Errorf("quarantined %q ok %v, want a quarantined partition with sound data", pc.Quarantined, pc.Ok)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package psutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fileutil"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+// the marker files in the meta dir of a partition, loading counts the loads
+// not finished, left when a load crashes the ps
+const (
+	LoadingFile    = "loading"
+	QuarantineFile = "quarantine.json"
+)
+
+// StartPartitionLoad counts a load of the partition and returns how many
+// loads before it did not finish
+func StartPartitionLoad(path string, id entity.PartitionID) (int, error) {
+	_, _, meta := GetPartitionPaths(path, id)
+	if err := os.MkdirAll(meta, os.ModePerm); err != nil {
+		return 0, err
+	}
+	file := filepath.Join(meta, LoadingFile)
+	crashed := 0
+	if bytes, err := os.ReadFile(file); err == nil {
+		crashed, _ = strconv.Atoi(strings.TrimSpace(string(bytes)))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	return crashed, fileutil.WriteFileAtomic(file, []byte(strconv.Itoa(crashed+1)), os.ModePerm)
+}
+
+// FinishPartitionLoad removes the loading marker of the partition
+func FinishPartitionLoad(path string, id entity.PartitionID) error {
+	_, _, meta := GetPartitionPaths(path, id)
+	if err := os.Remove(filepath.Join(meta, LoadingFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SaveQuarantine keeps the partition quarantined over restarts
+func SaveQuarantine(path string, id entity.PartitionID, q *entity.QuarantinedPartition) error {
+	_, _, meta := GetPartitionPaths(path, id)
+	bytes, err := vjson.Marshal(q)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(meta, os.ModePerm); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(filepath.Join(meta, QuarantineFile), bytes, os.ModePerm)
+}
+
+// LoadQuarantine returns nil if the partition is not quarantined
+func LoadQuarantine(path string, id entity.PartitionID) *entity.QuarantinedPartition {
+	_, _, meta := GetPartitionPaths(path, id)
+	bytes, err := os.ReadFile(filepath.Join(meta, QuarantineFile))
+	if err != nil {
+		return nil
+	}
+	q := &entity.QuarantinedPartition{}
+	if err := vjson.Unmarshal(bytes, q); err != nil {
+		// a broken marker still quarantines the partition
+		q.PartitionID = id
+		q.Error = err.Error()
+	}
+	return q
+}
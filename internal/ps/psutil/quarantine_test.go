@@ -0,0 +1,62 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package psutil
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func TestPartitionLoad(t *testing.T) {
+	path := t.TempDir()
+	for want := 0; want < 3; want++ {
+		crashed, err := StartPartitionLoad(path, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crashed != want {
+			t.Fatalf("crashed loads %d, want %d", crashed, want)
+		}
+	}
+	if err := FinishPartitionLoad(path, 1); err != nil {
+		t.Fatal(err)
+	}
+	if crashed, _ := StartPartitionLoad(path, 1); crashed != 0 {
+		t.Errorf("crashed loads %d after finish, want 0", crashed)
+	}
+	if err := FinishPartitionLoad(path, 2); err != nil {
+		t.Errorf("finish a partition never loaded: %v", err)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	path := t.TempDir()
+	if q := LoadQuarantine(path, 1); q != nil {
+		t.Fatalf("partition is quarantined before saved: %+v", q)
+	}
+	want := &entity.QuarantinedPartition{PartitionID: 1, NodeID: 2, Error: "bad index"}
+	if err := SaveQuarantine(path, 1, want); err != nil {
+		t.Fatal(err)
+	}
+	q := LoadQuarantine(path, 1)
+	if q == nil || *q != *want {
+		t.Errorf("LoadQuarantine() = %+v, want %+v", q, want)
+	}
+	ClearPartition(path, 1)
+	if q := LoadQuarantine(path, 1); q != nil {
+		t.Errorf("partition is quarantined after cleared: %+v", q)
+	}
+}
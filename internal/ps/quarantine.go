@@ -0,0 +1,151 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/psutil"
+)
+
+// a partition whose loads crashed the ps this many times in a row is
+// quarantined instead of loaded again
+const maxCrashedLoads = 2
+
+// recoverPartition loads a partition of this ps at startup, a partition it
+// fails to load is quarantined so the others are still served
+func (s *Server) recoverPartition(ctx context.Context, pid entity.PartitionID, spaces []*entity.Space) {
+	path := config.Conf().GetDataDirBySlot(config.PS, pid)
+	if q := psutil.LoadQuarantine(path, pid); q != nil {
+		log.Warn("partition [%d] is quarantined: %s", pid, q.Error)
+		s.quarantine(ctx, pid, q.Error)
+		return
+	}
+	crashed, err := psutil.StartPartitionLoad(path, pid)
+	if err != nil {
+		log.Error("mark loading of partition [%d] err: %v", pid, err)
+	}
+	if crashed >= maxCrashedLoads {
+		s.quarantine(ctx, pid, fmt.Sprintf("ps exited while loading the partition %d times", crashed))
+		return
+	}
+
+	log.Debug("starting recover partition[%d]...", pid)
+	if err = s.loadPartition(ctx, pid, spaces); err != nil {
+		log.Error("init partition err :[%s]", err.Error())
+		if !errors.Is(err, vearchpb.ErrPartitionNotFound) {
+			s.quarantine(ctx, pid, err.Error())
+		}
+	} else {
+		log.Debug("partition[%d] recovered complete", pid)
+	}
+	if err := psutil.FinishPartitionLoad(path, pid); err != nil {
+		log.Error("clear loading of partition [%d] err: %v", pid, err)
+	}
+}
+
+// loadPartition turns a panic of loading into an error
+func (s *Server) loadPartition(ctx context.Context, pid entity.PartitionID, spaces []*entity.Space) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("load partition [%d] panic: %v", pid, r))
+		}
+	}()
+	_, err = s.LoadPartition(ctx, pid, spaces)
+	return err
+}
+
+// quarantine skips a partition of this ps until it is rebuilt, it is kept
+// locally over restarts and in etcd for master
+func (s *Server) quarantine(ctx context.Context, pid entity.PartitionID, cause string) {
+	q := &entity.QuarantinedPartition{
+		PartitionID: pid,
+		NodeID:      s.nodeID,
+		Ip:          s.ip,
+		Error:       cause,
+		Time:        time.Now().Unix(),
+	}
+	log.Error("quarantine partition [%d]: %s", pid, cause)
+	s.quarantined.Store(pid, q)
+	if err := psutil.SaveQuarantine(config.Conf().GetDataDirBySlot(config.PS, pid), pid, q); err != nil {
+		log.Error("save quarantine of partition [%d] err: %v", pid, err)
+	}
+	if err := s.client.Master().PutQuarantine(ctx, q); err != nil {
+		log.Error("put quarantine of partition [%d] err: %v", pid, err)
+	}
+}
+
+// dropQuarantine removes a quarantined partition with its local data
+func (s *Server) dropQuarantine(ctx context.Context, pid entity.PartitionID) bool {
+	if _, ok := s.quarantined.LoadAndDelete(pid); !ok {
+		return false
+	}
+	psutil.ClearPartition(config.Conf().GetDataDirBySlot(config.PS, pid), pid)
+	if err := s.client.Master().DeleteQuarantine(ctx, s.nodeID, pid); err != nil {
+		log.Error("delete quarantine of partition [%d] err: %v", pid, err)
+	}
+	return true
+}
+
+// RebuildReplica drops the data of a quarantined partition and creates it
+// empty, raft then recovers it from the snapshot of the leader
+func (s *Server) RebuildReplica(ctx context.Context, pid entity.PartitionID) error {
+	if _, ok := s.quarantined.Load(pid); !ok {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition [%d] is not quarantined on node [%d]", pid, s.nodeID))
+	}
+	partition, err := s.client.Master().QueryPartition(ctx, pid)
+	if err != nil {
+		return err
+	}
+	space, err := s.client.Master().QuerySpaceByID(ctx, partition.DBId, partition.SpaceId)
+	if err != nil {
+		return err
+	}
+	if p := space.GetPartition(pid); p == nil || !slices.Contains(p.Replicas, s.nodeID) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("node [%d] is not a replica of partition [%d]", s.nodeID, pid))
+	} else if len(p.Replicas) < 2 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition [%d] has no other replica to rebuild from", pid))
+	}
+
+	s.dropQuarantine(ctx, pid)
+	log.Info("rebuild partition [%d] on node [%d] from other replicas", pid, s.nodeID)
+	if err := s.CreatePartition(ctx, space, pid); err != nil {
+		s.quarantine(ctx, pid, fmt.Sprintf("rebuild: %v", err))
+		return err
+	}
+	return nil
+}
+
+// RebuildReplicaHandler rebuilds a quarantined partition of this ps
+type RebuildReplicaHandler struct {
+	server *Server
+}
+
+func (rh *RebuildReplicaHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) error {
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+	if err := rh.server.RebuildReplica(ctx, req.PartitionID); err != nil {
+		log.Error("rebuild partition [%d] err: %v", req.PartitionID, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/smallnest/rpcx/share"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// resourceGroupLimiter bounds the searches and queries of a resource group
+// running on this server
+type resourceGroupLimiter struct {
+	group *entity.ResourceGroup
+	slots chan struct{}
+}
+
+// StartResourceGroupJob keeps the limiters of resource groups in sync with
+// the groups the master stores
+func (s *Server) StartResourceGroupJob() {
+	go s.watchPrefix(entity.PrefixResourceGroup, s.putResourceGroup, s.deleteResourceGroup)
+}
+
+func (s *Server) putResourceGroup(key, value []byte) {
+	group := &entity.ResourceGroup{}
+	if err := vjson.Unmarshal(value, group); err != nil {
+		log.Error("unmarshal resource group of key [%s] err: %v", string(key), err)
+		return
+	}
+	limit := group.Limit(s.concurrentNum)
+	if limit == 0 {
+		s.resourceGroups.Delete(group.Name)
+	} else {
+		// requests running keep the slots of the old limiter they got
+		s.resourceGroups.Store(group.Name, &resourceGroupLimiter{group: group, slots: make(chan struct{}, limit)})
+	}
+	log.Info("set resource group [%s] limit [%d]", group.Name, limit)
+}
+
+func (s *Server) deleteResourceGroup(key []byte) {
+	keySplit := strings.Split(string(key), "/")
+	name := keySplit[len(keySplit)-1]
+	s.resourceGroups.Delete(name)
+	log.Info("delete resource group [%s]", name)
+}
+
+// acquireResourceGroup waits for a slot of the resource group of the space
+// of partition if the request is a search or query, the returned func
+// releases the slot and is nil if no slot is taken
+func (s *Server) acquireResourceGroup(ctx context.Context, pid entity.PartitionID) (func(), *vearchpb.VearchErr) {
+	reqMap, _ := ctx.Value(share.ReqMetaDataKey).(map[string]string)
+	if method := reqMap[client.HandlerType]; method != client.SearchHandler && method != client.QueryHandler {
+		return nil, nil
+	}
+	store := s.GetPartition(pid)
+	if store == nil {
+		return nil, nil
+	}
+	name := store.GetSpace().ResourceGroup
+	if name == "" {
+		return nil, nil
+	}
+	v, ok := s.resourceGroups.Load(name)
+	if !ok {
+		return nil, nil
+	}
+	limiter := v.(*resourceGroupLimiter)
+	select {
+	case limiter.slots <- struct{}{}:
+		return func() { <-limiter.slots }, nil
+	case <-ctx.Done():
+		err := fmt.Errorf("request for partition: %d time out, the resource group [%s] can only deal [%d] request at same time", pid, name, cap(limiter.slots))
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_RESOURCE_EXHAUSTED, err)
+	}
+}
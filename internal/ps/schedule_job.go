@@ -17,13 +17,19 @@ package ps
 import (
 	"context"
 	"os"
+	"runtime/debug"
 	"slices"
+	"strings"
 	"time"
 
+	"github.com/spf13/cast"
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
 	"github.com/vearch/vearch/v3/internal/ps/psutil"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -66,6 +72,10 @@ func (s *Server) StartHeartbeatJob() {
 				BuildTime:    config.GetBuildTime(),
 				CommitID:     config.GetCommitID(),
 			},
+			Capabilities: &entity.Capabilities{
+				IndexTypes: engine.IndexTypes(),
+				Features:   entity.PsFeatures,
+			},
 		}
 		var leaseId clientv3.LeaseID = 0
 		var lastPartitionIds []entity.PartitionID
@@ -142,7 +152,7 @@ func (s *Server) StartHeartbeatJob() {
 			case ka, ok := <-keepaliveC:
 				if !ok {
 					log.Warn("keep alive channel closed! this ps will connect to master two seconds later.")
-					time.Sleep(2 * time.Second)
+					time.Sleep(config.Conf().PS.Jitter(2 * time.Second))
 					keepaliveC, err = s.client.Master().KeepAlive(ctx, server)
 					if err != nil {
 						log.Warnf("KeepAlive err: %s", err.Error())
@@ -170,3 +180,114 @@ func (s *Server) UpdateResolver(key, value interface{}) bool {
 	}
 	return true
 }
+
+// StartEngineConfigJob applies the engine configs the master stores by space
+// to the local partitions and keeps watching them for changes
+func (s *Server) StartEngineConfigJob() {
+	go s.watchPrefix(entity.PrefixSpaceConfig, s.applySpaceEngineConfig, nil)
+}
+
+// watchPrefix calls put with every key under prefix and then with the keys
+// changed, del with the keys deleted, until the server is closed
+func (s *Server) watchPrefix(prefix string, put func(key, value []byte), del func(key []byte)) {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			log.Error("recover() err:[%v]", rErr)
+			log.Error("stack:[%s]", debug.Stack())
+		}
+	}()
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Info("watch prefix:[%s] closed", prefix)
+			return
+		default:
+		}
+
+		watcher, err := s.client.Master().WatchPrefix(s.ctx, prefix)
+		if err != nil {
+			log.Error("watch prefix:[%s] err: %v", prefix, err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		// keys changed before the watch starts are got by scan
+		keys, values, err := s.client.Master().PrefixScan(s.ctx, prefix)
+		if err != nil {
+			log.Error("scan prefix:[%s] err: %v", prefix, err)
+		}
+		for i := range keys {
+			put(keys[i], values[i])
+		}
+		for reps := range watcher {
+			if reps.Canceled {
+				log.Error("chan is closed by prefix:[%s] watcher", prefix)
+				break
+			}
+			for _, event := range reps.Events {
+				if event.Type == mvccpb.PUT {
+					put(event.Kv.Key, event.Kv.Value)
+				} else if del != nil {
+					del(event.Kv.Key)
+				}
+			}
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// applySpaceEngineConfig sets the engine config stored in key to the local
+// partitions of its space
+func (s *Server) applySpaceEngineConfig(key, value []byte) {
+	keySplit := strings.Split(string(key), "/")
+	if len(keySplit) < 2 {
+		return
+	}
+	spaceID := cast.ToInt64(keySplit[len(keySplit)-1])
+	cfg := &entity.EngineConfig{}
+	if err := vjson.Unmarshal(value, cfg); err != nil {
+		log.Error("unmarshal engine config of key [%s] err: %v", string(key), err)
+		return
+	}
+	s.RangePartition(func(pid entity.PartitionID, store PartitionStore) {
+		if store.GetSpace().Id != spaceID {
+			return
+		}
+		s.setEngineConfig(pid, store, cfg)
+	})
+}
+
+// loadEngineConfig applies the stored engine config of the space to a
+// partition just started
+func (s *Server) loadEngineConfig(ctx context.Context, pid entity.PartitionID, store PartitionStore) {
+	space := store.GetSpace()
+	value, err := s.client.Master().Get(ctx, entity.SpaceConfigKey(space.DBId, space.Id))
+	if err != nil {
+		log.Error("get engine config of space [%d] err: %v", space.Id, err)
+		return
+	}
+	if value == nil {
+		return
+	}
+	cfg := &entity.EngineConfig{}
+	if err := vjson.Unmarshal(value, cfg); err != nil {
+		log.Error("unmarshal engine config of space [%d] err: %v", space.Id, err)
+		return
+	}
+	s.setEngineConfig(pid, store, cfg)
+}
+
+func (s *Server) setEngineConfig(pid entity.PartitionID, store PartitionStore, cfg *entity.EngineConfig) {
+	engine := store.GetEngine()
+	if engine == nil {
+		return
+	}
+	data, err := vjson.Marshal(cfg)
+	if err != nil {
+		return
+	}
+	if err := engine.SetEngineCfg(data); err != nil {
+		log.Error("set engine config of partition [%d] err: %v", pid, err)
+		return
+	}
+	log.Info("set engine config of partition [%d]: %s", pid, string(data))
+}
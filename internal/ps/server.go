@@ -26,7 +26,9 @@ import (
 	"github.com/vearch/vearch/v3/internal/client"
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/distance"
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
+	"github.com/vearch/vearch/v3/internal/pkg/fairqueue"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/metrics/mserver"
 	"github.com/vearch/vearch/v3/internal/pkg/routine"
@@ -49,6 +51,8 @@ type Server struct {
 	nodeID          entity.NodeID // server id
 	ip              string
 	partitions      sync.Map
+	stats           sync.Map // partition id -> *partitionStats
+	resourceGroups  sync.Map // group name -> *resourceGroupLimiter
 	raftResolver    *raftstore.RaftResolver
 	raftServer      *raft.RaftServer
 	rpcServer       *rpc.RpcServer
@@ -63,6 +67,13 @@ type Server struct {
 	concurrentNum   int
 	rpcTimeOut      int
 	backupStatus    map[uint32]int
+	indexImports    sync.Map // partition id -> *entity.IndexImportStatus
+	snapshots       sync.Map // session -> *snapshotSession
+	spaceCopies     sync.Map // partition id -> *entity.SpaceCopyStatus
+	quarantined     sync.Map // partition id -> *entity.QuarantinedPartition
+	indexStats      sync.Map // partition id -> *entity.IndexStats
+	fairQueue       *fairqueue.Queue
+	watchdog        *watchdog
 }
 
 // NewServer creates a server instance
@@ -83,7 +94,12 @@ func NewServer(ctx context.Context) *Server {
 	if config.Conf().PS.ConcurrentNum > 0 {
 		s.concurrentNum = config.Conf().PS.ConcurrentNum
 	}
+	if err := distance.LoadPlugins(config.Conf().PS.MetricPlugins); err != nil {
+		panic(err)
+	}
 	s.concurrent = make(chan bool, s.concurrentNum)
+	s.fairQueue = s.newFairQueue(config.Conf().PS.FairQueue)
+	s.watchdog = newWatchdog(config.Conf().PS.Watchdog)
 	s.backupStatus = make(map[uint32]int)
 
 	s.rpcTimeOut = defaultRpcTimeOut
@@ -144,6 +160,21 @@ func (s *Server) Start() error {
 	// start heartbeat job
 	s.StartHeartbeatJob()
 
+	// apply engine configs distributed by master
+	s.StartEngineConfigJob()
+
+	// limit searches of spaces by their resource groups
+	s.StartResourceGroupJob()
+
+	// renew fencing tokens of the partitions led by this node
+	s.StartFencingJob()
+
+	// find requests stuck in the engine
+	s.StartWatchdogJob()
+
+	// sample the scalar fields of partitions for the filter planner
+	s.StartIndexStatsJob()
+
 	// start rpc server
 	if err = s.rpcServer.Run(); err != nil {
 		log.Panic(fmt.Sprintf("ps rpcServer run error: %v", err))
@@ -162,6 +193,7 @@ func (s *Server) Start() error {
 func (s *Server) Close() error {
 	log.Info("ps shutdown... start")
 	s.stopping = true
+	s.logDraining()
 	s.ctxCancel()
 
 	if err := routine.Stop(); err != nil {
@@ -288,6 +320,9 @@ func (s *Server) HandleRaftLeaderEvent(event *raftstore.RaftLeaderEvent) {
 // register master partition
 func (s *Server) registerMaster(leader entity.NodeID, pid entity.PartitionID) {
 	if leader != s.nodeID { // only leader registers partition
+		// the deposed leader stopped writing, release its fencing token so
+		// the new leader does not wait for it to expire
+		s.renewFencingTokens([]entity.PartitionID{pid}, true)
 		return
 	}
 
@@ -303,7 +338,9 @@ func (s *Server) registerMaster(leader entity.NodeID, pid entity.PartitionID) {
 
 	if err := s.client.Master().RegisterPartition(context.Background(), partition); err != nil {
 		log.Error("register partition error: [%s]", err.Error())
+		return
 	}
+	s.renewFencingTokens([]entity.PartitionID{pid}, false)
 }
 
 // change replicas status
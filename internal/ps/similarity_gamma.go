@@ -0,0 +1,27 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build cgo
+
+package ps
+
+import (
+	"github.com/vearch/vearch/v3/internal/engine/sdk/go/gamma"
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+// engineSimilarity computes the similarity by the gamma kernels
+func engineSimilarity(simReq *entity.SimilarityRequest, a, b []float32, d int) ([]float32, error) {
+	return gamma.Similarity(simReq.Metric(), a, b, d)
+}
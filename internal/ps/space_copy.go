@@ -0,0 +1,315 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/compress"
+	"github.com/vearch/vearch/v3/internal/pkg/errutil"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/ratelimit"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/storage/raftstore"
+)
+
+// a snapshot for copies is removed once it is not read for this long, the
+// replicas of a destination partition share it so none of them releases it
+const snapshotSessionTTL = 10 * time.Minute
+
+type snapshotSession struct {
+	pid   entity.PartitionID
+	dir   string
+	once  sync.Once
+	timer *time.Timer
+}
+
+// SnapshotHandler keeps snapshots of the engine files of partitions for
+// the ps of other clusters to copy them
+type SnapshotHandler struct {
+	server *Server
+}
+
+func (sh *SnapshotHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) (err error) {
+	defer errutil.CatchError(&err)
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+
+	snapshotReq := &entity.SnapshotRequest{}
+	if err := vjson.Unmarshal(req.Data, snapshotReq); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
+	switch snapshotReq.Command {
+	case entity.SnapshotCommandOpen:
+		store := sh.server.GetPartition(req.PartitionID)
+		if store == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_INVALID, fmt.Errorf("partition (%v), partitonStore is nil ", req.PartitionID))
+		}
+		manifest, err := sh.open(ctx, store, req.PartitionID)
+		if err != nil {
+			return err
+		}
+		reply.Data, err = vjson.Marshal(manifest)
+		return err
+	case entity.SnapshotCommandRead:
+		reply.Data, err = sh.read(req.PartitionID, snapshotReq)
+		return err
+	case entity.SnapshotCommandRelease:
+		if s, ok := sh.server.snapshots.Load(snapshotReq.Session); ok {
+			sh.release(snapshotReq.Session, s.(*snapshotSession))
+		}
+		return nil
+	default:
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unknow command %s", snapshotReq.Command))
+	}
+}
+
+func (sh *SnapshotHandler) open(ctx context.Context, store PartitionStore, pid entity.PartitionID) (*entity.SnapshotManifest, error) {
+	name := fmt.Sprintf("%d_%d", pid, time.Now().UnixNano())
+	dir := store.CopyDir("snapshot_" + name)
+	files, err := store.SnapshotFiles(ctx, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	session := &snapshotSession{pid: pid, dir: dir}
+	session.timer = time.AfterFunc(snapshotSessionTTL, func() {
+		log.Warn("snapshot %s of partition %d not read for %v, released", name, pid, snapshotSessionTTL)
+		sh.release(name, session)
+	})
+	sh.server.snapshots.Store(name, session)
+	log.Info("snapshot %s of partition %d opened with %d files", name, pid, len(files))
+	return &entity.SnapshotManifest{Session: name, Files: files}, nil
+}
+
+func (sh *SnapshotHandler) read(pid entity.PartitionID, req *entity.SnapshotRequest) ([]byte, error) {
+	s, ok := sh.server.snapshots.Load(req.Session)
+	if !ok || s.(*snapshotSession).pid != pid {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("no snapshot %s of partition %d", req.Session, pid))
+	}
+	session := s.(*snapshotSession)
+	session.timer.Reset(snapshotSessionTTL)
+	if !filepath.IsLocal(req.File) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("snapshot file %s is not in the snapshot", req.File))
+	}
+	size := req.Size
+	if size <= 0 || size > entity.MaxSnapshotChunk {
+		size = entity.MaxSnapshotChunk
+	}
+	f, err := os.Open(filepath.Join(session.dir, req.File))
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
+	defer f.Close()
+	buf := make([]byte, size)
+	n, err := f.ReadAt(buf, req.Offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data := buf[:n]
+	if req.Compression != "" {
+		if data, err = compress.Compress(req.Compression, config.Conf().PS.TransferConfig().CompressionLevel, data); err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+		}
+	}
+	if err := raftstore.TransferLimiter().Wait(sh.server.ctx, len(data)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (sh *SnapshotHandler) release(name string, session *snapshotSession) {
+	session.once.Do(func() {
+		session.timer.Stop()
+		sh.server.snapshots.Delete(name)
+		if err := os.RemoveAll(session.dir); err != nil {
+			log.Error("remove snapshot %s of partition %d err: %v", name, session.pid, err)
+		}
+	})
+}
+
+// SpaceCopyHandler pulls the snapshot of a partition of another cluster
+// into the replica in background, or replies the status of the last copy
+type SpaceCopyHandler struct {
+	server *Server
+}
+
+func (ch *SpaceCopyHandler) Execute(ctx context.Context, req *vearchpb.PartitionData, reply *vearchpb.PartitionData) (err error) {
+	defer errutil.CatchError(&err)
+	reply.Err = &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}
+
+	store := ch.server.GetPartition(req.PartitionID)
+	if store == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_INVALID, fmt.Errorf("partition (%v), partitonStore is nil ", req.PartitionID))
+	}
+	spaceCopy := &entity.SpaceCopy{}
+	if err := vjson.Unmarshal(req.Data, spaceCopy); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
+
+	var status *entity.SpaceCopyStatus
+	switch spaceCopy.Command {
+	case entity.SpaceCopyCommandStatus:
+		status = &entity.SpaceCopyStatus{PartitionID: req.PartitionID, NodeID: ch.server.nodeID}
+		if s, ok := ch.server.spaceCopies.Load(req.PartitionID); ok {
+			status = s.(*entity.SpaceCopyStatus)
+		}
+	case entity.SpaceCopyCommandStart:
+		if status, err = ch.start(ctx, store, req.PartitionID, spaceCopy); err != nil {
+			return err
+		}
+	default:
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unknow command %s", spaceCopy.Command))
+	}
+	reply.Data, err = vjson.Marshal(status)
+	return err
+}
+
+// start checks the replica has no documents, the copy replaces its engine
+// files and the writes it had would be lost
+func (ch *SpaceCopyHandler) start(ctx context.Context, store PartitionStore, pid entity.PartitionID, spaceCopy *entity.SpaceCopy) (*entity.SpaceCopyStatus, error) {
+	source := spaceCopy.Source
+	if source == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("copy of partition %d has no source", pid))
+	}
+	if err := source.Validate(); err != nil {
+		return nil, err
+	}
+	docNum, err := store.GetEngine().Reader().DocCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if docNum > 0 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition %d has %d documents, only empty partitions can be copied into", pid, docNum))
+	}
+
+	status := &entity.SpaceCopyStatus{
+		PartitionID:       pid,
+		NodeID:            ch.server.nodeID,
+		SourcePartitionID: source.PartitionID,
+		State:             entity.SpaceCopyRunning,
+		StartTime:         time.Now().Unix(),
+	}
+	for _, f := range source.Files {
+		status.TotalBytes += f.Size
+	}
+	if last, loaded := ch.server.spaceCopies.LoadOrStore(pid, status); loaded {
+		if last.(*entity.SpaceCopyStatus).State == entity.SpaceCopyRunning {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("copy of partition %d is running", pid))
+		}
+		ch.server.spaceCopies.Store(pid, status)
+	}
+
+	go func() {
+		limiter := ratelimit.New(spaceCopy.RateLimit)
+		progress := func(bytes int64) {
+			s := *status
+			s.Bytes = bytes
+			ch.server.spaceCopies.Store(pid, &s)
+		}
+		result := *status
+		result.State = entity.SpaceCopyDone
+		bytes, err := copyPartition(ch.server.ctx, store, source, limiter, progress)
+		if err != nil {
+			log.Error("copy partition %d from %s partition %d err: %v", pid, source.Addr, source.PartitionID, err)
+			result.State = entity.SpaceCopyFailed
+			result.Error = err.Error()
+		} else {
+			log.Info("copy partition %d from %s partition %d with %d bytes done", pid, source.Addr, source.PartitionID, bytes)
+		}
+		result.Bytes = bytes
+		result.EndTime = time.Now().Unix()
+		ch.server.spaceCopies.Store(pid, &result)
+	}()
+	return status, nil
+}
+
+// copyPartition downloads the files of source next to the engine path,
+// verifies their checksums and replaces the engine files by them
+func copyPartition(ctx context.Context, store PartitionStore, source *entity.PartitionCopySource, limiter *ratelimit.Limiter, progress func(int64)) (int64, error) {
+	dir := store.CopyDir("copy")
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	var bytes int64
+	for _, f := range source.Files {
+		n, err := downloadSnapshotFile(ctx, source, f, filepath.Join(dir, f.Name), limiter, func(n int64) { progress(bytes + n) })
+		bytes += n
+		if err != nil {
+			return bytes, err
+		}
+	}
+	return bytes, store.ReplaceData(ctx, dir)
+}
+
+func downloadSnapshotFile(ctx context.Context, source *entity.PartitionCopySource, f *entity.SnapshotFile, local string, limiter *ratelimit.Limiter, progress func(int64)) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(local), os.ModePerm); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(local, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	compression := config.Conf().PS.TransferConfig().Compression
+	h := sha256.New()
+	var offset int64
+	for offset < f.Size {
+		size := entity.MaxSnapshotChunk
+		if rest := f.Size - offset; rest < int64(size) {
+			size = int(rest)
+		}
+		data, err := client.ReadSnapshot(source.Addr, source.PartitionID, source.Session, f.Name, offset, size, compression)
+		if err != nil {
+			return offset, fmt.Errorf("read snapshot file %s at %d: %v", f.Name, offset, err)
+		}
+		// the limiter takes the bytes sent, it delays the next read
+		if err := limiter.Wait(ctx, len(data)); err != nil {
+			return offset, err
+		}
+		if compression != "" {
+			if data, err = compress.Decompress(data); err != nil {
+				return offset, fmt.Errorf("decompress snapshot file %s at %d: %v", f.Name, offset, err)
+			}
+		}
+		if len(data) == 0 {
+			return offset, fmt.Errorf("snapshot file %s ends at %d but has size %d", f.Name, offset, f.Size)
+		}
+		if _, err := out.Write(data); err != nil {
+			return offset, err
+		}
+		h.Write(data)
+		offset += int64(len(data))
+		progress(offset)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != f.Sha256 {
+		return offset, fmt.Errorf("checksum of snapshot file %s is %s but source has %s", f.Name, sum, f.Sha256)
+	}
+	return offset, out.Sync()
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fault"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
@@ -80,13 +81,27 @@ func (s *Store) ReplicasStatusChange() bool {
 
 // Apply implements the raft interface.
 func (s *Store) Apply(command []byte, index uint64) (resp interface{}, err error) {
-	raftCmd := &vearchpb.RaftCommand{}
+	// the error fault fails the entry on this replica as a failed apply does,
+	// the proposer gets the error
+	if err := fault.Eval(fault.RaftApply, fmt.Sprint(s.Partition.Id)); err != nil {
+		return nil, err
+	}
+
+	raftCmd := &fencedCommand{RaftCommand: &vearchpb.RaftCommand{}}
 
 	if err = vjson.Unmarshal(command, raftCmd); err != nil {
 		panic(err)
 	}
 
-	resp = s.innerApply(index, raftCmd)
+	if err := s.applyEpoch(raftCmd); err != nil {
+		// all replicas reject the write of a deposed leader alike
+		log.Warn("partition[%d] reject entry [%d]: %v", s.Partition.Id, index, err)
+		s.Sn = int64(index)
+		resp = new(RaftApplyResponse).SetErr(err)
+	} else {
+		s.keepTombstone(raftCmd)
+		resp = s.innerApply(index, raftCmd.RaftCommand)
+	}
 
 	// if follow after leader this value,means can't offer server
 	// just leader check
@@ -122,6 +137,8 @@ func (s *Store) innerApply(index uint64, raftCmd *vearchpb.RaftCommand) interfac
 		flushC, err := s.Engine.Writer().Commit(s.Ctx, int64(index))
 		resp.FlushC = flushC
 		resp.Err = err
+	case vearchpb.CmdType_CHECKSUM:
+		resp.Checksum = s.checksum(index)
 	default:
 		log.Error("unsupported command[%s]", raftCmd.Type)
 		resp.SetErr(fmt.Errorf("unsupported command[%s]", raftCmd.Type))
@@ -215,6 +232,10 @@ func (s *Store) HandleLeaderChange(leader uint64) {
 		s.EventListener.HandleRaftLeaderEvent(&RaftLeaderEvent{PartitionId: s.Partition.Id, Leader: leader})
 	} else {
 		s.Partition.SetStatus(entity.PA_READONLY)
+		if s.fencingToken.Swap(nil) != nil {
+			// notify the token is dropped so it is released on master
+			s.EventListener.HandleRaftLeaderEvent(&RaftLeaderEvent{PartitionId: s.Partition.Id, Leader: leader})
+		}
 	}
 }
 
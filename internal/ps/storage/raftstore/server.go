@@ -31,7 +31,10 @@ import (
 func StartRaftServer(nodeId entity.NodeID, ip string, resolver raft.SocketResolver) (*raft.RaftServer, error) {
 	rc := raft.DefaultConfig()
 	rc.NodeID = uint64(nodeId)
-	rc.LeaseCheck = true
+	// followers still hearing from their leader ignore the elections of
+	// other replicas, and candidates lead only after a quorum acks them, so
+	// a replica cut off for a moment does not depose a live leader
+	rc.LeaseCheck = !config.Conf().PS.RaftNoPreVote
 	rc.HeartbeatAddr = fmt.Sprintf(ip + ":" + cast.ToString(config.Conf().PS.RaftHeartbeatPort))
 	rc.ReplicateAddr = fmt.Sprintf(ip + ":" + cast.ToString(config.Conf().PS.RaftReplicatePort))
 	rc.Resolver = resolver
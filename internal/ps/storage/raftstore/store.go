@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cubefs/cubefs/depends/tiglabs/raft"
@@ -30,7 +31,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
-	"github.com/vearch/vearch/v3/internal/ps/engine/gammacb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
 	"github.com/vearch/vearch/v3/internal/ps/psutil"
 	"github.com/vearch/vearch/v3/internal/ps/storage"
 )
@@ -59,6 +60,10 @@ type Store struct {
 	raftDiffCount uint64
 	RsStatusC     chan *ReplicasStatusEntry
 	RsStatusMap   sync.Map
+	checksums     sync.Map // raft index -> *entity.ReplicaChecksum
+	fencingToken  atomic.Pointer[entity.FencingToken]
+	appliedEpoch  uint64 // highest fencing epoch of applied entries
+	tombstones    *tombstones
 }
 
 // CreateStore create an instance of Store.
@@ -81,6 +86,7 @@ func CreateStore(ctx context.Context, pID entity.PartitionID, nodeID entity.Node
 		EventListener: eventListener,
 		Client:        client,
 		RsStatusMap:   sync.Map{},
+		tombstones:    newTombstones(config.Conf().PS.Tombstone),
 	}
 	if config.Conf().PS.RaftDiffCount > 0 {
 		s.raftDiffCount = config.Conf().PS.RaftDiffCount
@@ -94,7 +100,7 @@ func CreateStore(ctx context.Context, pID entity.PartitionID, nodeID entity.Node
 func (s *Store) ReBuildEngine() (err error) {
 	log.Debug("begin re build engine")
 	// re create engine
-	s.Engine, err = gammacb.Build(gammacb.EngineConfig{
+	s.Engine, err = engine.Build(engine.Config{
 		Path:        s.DataPath,
 		Space:       s.Space,
 		PartitionID: s.Partition.Id,
@@ -118,7 +124,7 @@ func (s *Store) ReBuildEngine() (err error) {
 // Start start the store.
 func (s *Store) Start() (err error) {
 	// todo: gamma engine load need run after snapshot finish
-	s.Engine, err = gammacb.Build(gammacb.EngineConfig{
+	s.Engine, err = engine.Build(engine.Config{
 		Path:        s.DataPath,
 		Space:       s.Space,
 		PartitionID: s.Partition.Id,
@@ -160,7 +166,7 @@ func (s *Store) Start() (err error) {
 	}
 
 	for _, repl := range partition.Replicas {
-		peer := proto.Peer{Type: proto.PeerNormal, ID: uint64(repl)}
+		peer := proto.Peer{Type: proto.PeerNormal, ID: uint64(repl), Priority: s.replicaPriority(repl)}
 		raftConf.Peers = append(raftConf.Peers, peer)
 	}
 	if err = s.RaftServer.CreateRaft(raftConf); err != nil {
@@ -243,6 +249,23 @@ func (s *Store) TryToLeader() error {
 	return err
 }
 
+// replicaPriority is the leader priority of the replica on node id by the
+// leadership of the space, elections between replicas with logs as up to
+// date are won by the higher priority
+func (s *Store) replicaPriority(id entity.NodeID) uint16 {
+	if s.Space.Leadership == nil || s.Client == nil {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(s.Ctx, 5*time.Second)
+	defer cancel()
+	server, err := s.Client.Master().QueryServer(ctx, id)
+	if err != nil {
+		log.Warn("partition [%d] get server [%d] for leader priority err: %v", s.Partition.Id, id, err)
+		return 0
+	}
+	return s.Space.Leadership.Priority(server)
+}
+
 func (s *Store) GetUnreachable(id uint64) []uint64 {
 	return s.RaftServer.GetUnreachable(id)
 }
@@ -260,8 +283,9 @@ func (s *Store) ChangeMember(changeType proto.ConfChangeType, server *entity.Ser
 	id := uint64(s.Partition.Id)
 
 	peer := proto.Peer{
-		Type: proto.PeerNormal,
-		ID:   server.ID,
+		Type:     proto.PeerNormal,
+		ID:       server.ID,
+		Priority: s.Space.Leadership.Priority(server),
 	}
 
 	bytes, err := json.Marshal(server.Replica())
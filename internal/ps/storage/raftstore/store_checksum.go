@@ -0,0 +1,120 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// checksums of the latest checksum entries kept for the master to collect
+const maxKeptChecksums = 16
+
+const docIdField = "_docid"
+
+// Checksum proposes a checksum entry on the leader, every replica computes
+// its checksum when applying it, returns the checksum of the leader
+func (s *Store) Checksum(ctx context.Context) (*entity.ReplicaChecksum, error) {
+	if !s.IsLeader() {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_LEADER, nil)
+	}
+	data, err := vjson.Marshal(&vearchpb.RaftCommand{Type: vearchpb.CmdType_CHECKSUM})
+	if err != nil {
+		return nil, err
+	}
+	future := s.RaftServer.Submit(uint64(s.Partition.Id), data)
+	response, err := future.Response()
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(*RaftApplyResponse)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return resp.Checksum, nil
+}
+
+// GetChecksum waits for the checksum of the entry at index until ctx is done
+func (s *Store) GetChecksum(ctx context.Context, index uint64) (*entity.ReplicaChecksum, error) {
+	for {
+		if v, ok := s.checksums.Load(index); ok {
+			return v.(*entity.ReplicaChecksum), nil
+		}
+		// a snapshot or a restart skipped the entry
+		if s.Sn > int64(index) {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("checksum at index [%d] not found, applied index is [%d]", index, s.Sn))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, fmt.Errorf("wait checksum at index [%d] timeout, applied index is [%d]", index, s.Sn))
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// checksum sums the hash of every document by the sorted fields, so the
+// order of documents does not matter. It blocks the apply of the following
+// entries until all documents are read
+func (s *Store) checksum(index uint64) *entity.ReplicaChecksum {
+	start := time.Now()
+	result := &entity.ReplicaChecksum{PartitionID: s.Partition.Id, NodeID: s.NodeID, Index: index}
+	reader := s.Engine.Reader()
+	docID := -1
+	for {
+		doc := &vearchpb.Document{PKey: strconv.Itoa(docID)}
+		if err := reader.GetDoc(s.Ctx, doc, true, true); err != nil {
+			// no document after docID
+			break
+		}
+		fields := doc.Fields[:0]
+		for _, f := range doc.Fields {
+			if f.Name == docIdField {
+				docID = int(int32(binary.LittleEndian.Uint32(f.Value)))
+				continue
+			}
+			fields = append(fields, f)
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		h := fnv.New64a()
+		for _, f := range fields {
+			h.Write([]byte(f.Name))
+			h.Write([]byte{0})
+			h.Write(f.Value)
+			h.Write([]byte{0})
+		}
+		result.Checksum += h.Sum64()
+		result.DocNum++
+	}
+
+	s.checksums.Store(index, result)
+	s.checksums.Range(func(key, value interface{}) bool {
+		if key.(uint64)+maxKeptChecksums < index {
+			s.checksums.Delete(key)
+		}
+		return true
+	})
+	log.Info("partition [%d] checksum at index [%d]: docs [%d] checksum [%d], cost [%v]", s.Partition.Id, index, result.DocNum, result.Checksum, time.Since(start))
+	return result
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/hlc"
+	"github.com/vearch/vearch/v3/internal/pkg/vearchlog"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// fencedCommand is a raft command with the fencing epoch of the leader which
+// proposed it, 0 if write fencing is disabled. Deletes carry the time they
+// are proposed at too, replicas keep their tombstones by it
+type fencedCommand struct {
+	*vearchpb.RaftCommand
+	FencingEpoch uint64 `json:"fencing_epoch,omitempty"`
+	ProposeTime  int64  `json:"propose_time,omitempty"` // unix ms
+}
+
+// SetFencingToken sets the token permitting this leader to write
+func (s *Store) SetFencingToken(token *entity.FencingToken) {
+	s.fencingToken.Store(token)
+}
+
+// checkFencing rejects the write if write fencing is enabled and the leader
+// holds no valid fencing token, it is deposed or cut off from master. It
+// returns the epoch of the token to propose the write with
+func (s *Store) checkFencing() (uint64, error) {
+	if !config.Conf().PS.WriteFencing {
+		return 0, nil
+	}
+	token := s.fencingToken.Load()
+	if err := token.Check(s.NodeID, hlc.Now()); err != nil {
+		return 0, vearchlog.LogErrAndReturn(vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_LEADER, err))
+	}
+	return token.Epoch, nil
+}
+
+// applyEpoch rejects the entry proposed with an epoch older than an applied
+// one, a deposed leader proposed it after the new leader started writing.
+// It is called by the apply of raft, entries are applied one by one
+func (s *Store) applyEpoch(cmd *fencedCommand) error {
+	if cmd.FencingEpoch == 0 {
+		return nil
+	}
+	if cmd.FencingEpoch < s.appliedEpoch {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_LEADER, fmt.Errorf("fencing epoch [%d] of entry is older than applied epoch [%d]", cmd.FencingEpoch, s.appliedEpoch))
+	}
+	s.appliedEpoch = cmd.FencingEpoch
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestStore_applyEpoch(t *testing.T) {
+	s := &Store{}
+	steps := []struct {
+		name    string
+		epoch   uint64
+		wantErr bool
+	}{
+		{name: "Entry without fencing", epoch: 0},
+		{name: "First leader", epoch: 1},
+		{name: "Next leader", epoch: 2},
+		{name: "Stale entry of deposed leader", epoch: 1, wantErr: true},
+		{name: "Entry without fencing after epochs", epoch: 0},
+		{name: "Same leader", epoch: 2},
+	}
+	for _, step := range steps {
+		err := s.applyEpoch(&fencedCommand{RaftCommand: &vearchpb.RaftCommand{}, FencingEpoch: step.epoch})
+		if (err != nil) != step.wantErr {
+			t.Errorf("%s: applyEpoch() err = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+	}
+}
+
+func TestFencedCommandCompatible(t *testing.T) {
+	// entries of nodes before write fencing decode with epoch 0
+	old, err := vjson.Marshal(&vearchpb.RaftCommand{Type: vearchpb.CmdType_FLUSH})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := &fencedCommand{RaftCommand: &vearchpb.RaftCommand{}}
+	if err := vjson.Unmarshal(old, cmd); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Type != vearchpb.CmdType_FLUSH || cmd.FencingEpoch != 0 {
+		t.Errorf("decoded %v epoch %d, want FLUSH epoch 0", cmd.Type, cmd.FencingEpoch)
+	}
+
+	bs, err := vjson.Marshal(&fencedCommand{RaftCommand: &vearchpb.RaftCommand{Type: vearchpb.CmdType_WRITE}, FencingEpoch: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd = &fencedCommand{RaftCommand: &vearchpb.RaftCommand{}}
+	if err := vjson.Unmarshal(bs, cmd); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Type != vearchpb.CmdType_WRITE || cmd.FencingEpoch != 3 {
+		t.Errorf("decoded %v epoch %d, want WRITE epoch 3", cmd.Type, cmd.FencingEpoch)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fileutil"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
+)
+
+const (
+	// the engine loads vector indexes from the latest done dump under it
+	engineDumpDir  = "retrieval_model_index"
+	engineDumpDone = "dump.done"
+	// the version suffix of the index directory of a vector field
+	engineIndexSuffix = ".000"
+)
+
+// IndexedDocNum returns the number of docids of the engine, an imported
+// index can not cover more documents
+func (s *Store) IndexedDocNum() (int64, error) {
+	status := &entity.EngineStatus{}
+	if err := s.GetEngine().GetEngineStatus(status); err != nil {
+		return 0, err
+	}
+	return int64(status.MaxDocid) + 1, nil
+}
+
+// ImportIndex replaces the index of field by files, a name to local path
+// map, and reopens the engine to load it. The engine is dumped first so the
+// latest dump has the raw vectors, documents after the imported index are
+// indexed again by the engine. Reads and writes fail while the engine is
+// reopened
+func (s *Store) ImportIndex(ctx context.Context, field string, files map[string]string) error {
+	if err := s.GetEngine().Writer().Flush(ctx, s.Sn); err != nil {
+		return err
+	}
+
+	dumpRoot := filepath.Join(s.DataPath, engineDumpDir)
+	dumpDir, err := latestDump(dumpRoot)
+	if err != nil {
+		return err
+	}
+	indexDir := filepath.Join(dumpDir, field+engineIndexSuffix)
+
+	s.Engine.Close()
+	for !s.Engine.HasClosed() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Info("partition[%d] engine closed to import index of field %s into %s", s.Partition.Id, field, indexDir)
+
+	installErr := installIndexFiles(indexDir, files)
+	if installErr != nil {
+		log.Error("partition[%d] install index files err: %v", s.Partition.Id, installErr)
+	}
+
+	s.Engine, err = engine.Build(engine.Config{
+		Path:        s.DataPath,
+		Space:       s.Space,
+		PartitionID: s.Partition.Id,
+	})
+	if err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("reopen engine of partition %d after index import: %v", s.Partition.Id, err))
+	}
+	return installErr
+}
+
+// latestDump returns the newest dump directory which has its done file,
+// names are times so they sort in dump order
+func latestDump(dumpRoot string) (string, error) {
+	entries, err := os.ReadDir(dumpRoot)
+	if err != nil {
+		return "", vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("read engine dump dir: %v", err))
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dumpRoot, e.Name(), engineDumpDone)); err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("no engine dump in %s, the partition has no documents", dumpRoot))
+	}
+	sort.Strings(names)
+	return filepath.Join(dumpRoot, names[len(names)-1]), nil
+}
+
+// installIndexFiles copies files into dir, every file is written aside and
+// renamed so a failed copy leaves the previous index in place
+func installIndexFiles(dir string, files map[string]string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	for name, src := range files {
+		dst := filepath.Join(dir, name)
+		if _, err := fileutil.CopyFile(src, dst+".importing"); err != nil {
+			return err
+		}
+	}
+	for name := range files {
+		dst := filepath.Join(dir, name)
+		if err := os.Rename(dst+".importing", dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
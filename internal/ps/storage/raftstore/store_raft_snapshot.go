@@ -24,7 +24,11 @@ import (
 
 // Snapshot implements the raft interface.
 func (s *Store) Snapshot() (proto.Snapshot, error) {
-	return s.GetEngine().NewSnapshot()
+	snapshot, err := s.GetEngine().NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return newTransferSnapshot(s.Ctx, snapshot), nil
 }
 
 // ApplySnapshot implements the raft interface.
@@ -48,7 +52,7 @@ func (s *Store) ApplySnapshot(peers []proto.Peer, iter proto.SnapIterator) (err
 	errutil.ThrowError(err)
 	log.Debug("remove engine data path")
 	// apply snapshot
-	err = s.GetEngine().ApplySnapshot(peers, iter)
+	err = s.GetEngine().ApplySnapshot(peers, &transferIterator{SnapIterator: iter})
 	if err == nil {
 		log.Debug("store info is [%+v]", s)
 	} else {
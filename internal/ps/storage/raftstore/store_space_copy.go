@@ -0,0 +1,137 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fileutil"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
+)
+
+// the engines keep their applied raft index in this file of the engine path
+const engineSnFile = "sn"
+
+// CopyDir is the directory named name next to the engine path, snapshots
+// for copies and the files of a copy are kept there
+func (s *Store) CopyDir(name string) string {
+	return filepath.Join(filepath.Dir(s.DataPath), name)
+}
+
+// SnapshotFiles flushes the engine and copies its files into dir, the copy
+// stays the same while the engine goes on writing. The files are copied
+// live as raft snapshots stream them
+func (s *Store) SnapshotFiles(ctx context.Context, dir string) ([]*entity.SnapshotFile, error) {
+	if err := s.GetEngine().Writer().Flush(ctx, s.Sn); err != nil {
+		return nil, err
+	}
+	files := make([]*entity.SnapshotFile, 0)
+	err := filepath.WalkDir(s.DataPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(s.DataPath, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+			return err
+		}
+		size, sum, err := copyFileSha256(path, dst)
+		if err != nil {
+			return err
+		}
+		files = append(files, &entity.SnapshotFile{Name: name, Size: size, Sha256: sum})
+		return nil
+	})
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("snapshot files of partition %d: %v", s.Partition.Id, err))
+	}
+	return files, nil
+}
+
+// ReplaceData replaces the engine files by the files of a copy in dir and
+// reopens the engine on them. The applied index of the copy is set to the
+// one of this replica so raft goes on from where it is. Reads and writes
+// fail while the engine is reopened
+func (s *Store) ReplaceData(ctx context.Context, dir string) error {
+	s.Engine.Close()
+	for !s.Engine.HasClosed() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Info("partition[%d] engine closed to replace data by %s", s.Partition.Id, dir)
+
+	replaceErr := s.replaceDataPath(dir)
+	if replaceErr != nil {
+		log.Error("partition[%d] replace data err: %v", s.Partition.Id, replaceErr)
+	}
+
+	var err error
+	s.Engine, err = engine.Build(engine.Config{
+		Path:        s.DataPath,
+		Space:       s.Space,
+		PartitionID: s.Partition.Id,
+	})
+	if err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("reopen engine of partition %d after copy: %v", s.Partition.Id, err))
+	}
+	return replaceErr
+}
+
+func (s *Store) replaceDataPath(dir string) error {
+	sn := []byte(strconv.FormatInt(s.Sn, 10))
+	if err := fileutil.WriteFileAtomic(filepath.Join(dir, engineSnFile), sn, os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.DataPath); err != nil {
+		return err
+	}
+	return os.Rename(dir, s.DataPath)
+}
+
+func copyFileSha256(src, dst string) (int64, string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, "", err
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, h), in)
+	if err != nil {
+		out.Close()
+		return 0, "", err
+	}
+	if err := out.Close(); err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// tombstone is a document as it was before its delete, proposed at time
+type tombstone struct {
+	doc  *vearchpb.Document
+	time int64 // unix ms
+}
+
+// tombstones keeps the documents deleted in the last retention ms of a
+// partition, at most max of them, in the order their deletes are applied
+type tombstones struct {
+	mu        sync.Mutex
+	retention int64
+	max       int
+	list      []*tombstone
+	byKey     map[string][]*tombstone
+}
+
+// newTombstones returns nil if ps keeps no tombstones
+func newTombstones(cfg *config.TombstoneCfg) *tombstones {
+	if cfg == nil || cfg.Retention <= 0 {
+		return nil
+	}
+	max := cfg.MaxDocs
+	if max <= 0 {
+		max = config.DefaultTombstoneMaxDocs
+	}
+	return &tombstones{retention: int64(cfg.Retention) * 1000, max: max, byKey: make(map[string][]*tombstone)}
+}
+
+func (ts *tombstones) add(doc *vearchpb.Document, deleteTime, now int64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := &tombstone{doc: doc, time: deleteTime}
+	ts.list = append(ts.list, t)
+	ts.byKey[doc.PKey] = append(ts.byKey[doc.PKey], t)
+	ts.expire(now)
+}
+
+// expire drops the tombstones out of the retention and the oldest beyond max.
+// The oldest tombstone of a key is the first of it
+func (ts *tombstones) expire(now int64) {
+	n := 0
+	for ; n < len(ts.list); n++ {
+		t := ts.list[n]
+		if len(ts.list)-n <= ts.max && t.time >= now-ts.retention {
+			break
+		}
+		if rest := ts.byKey[t.doc.PKey][1:]; len(rest) > 0 {
+			ts.byKey[t.doc.PKey] = rest
+		} else {
+			delete(ts.byKey, t.doc.PKey)
+		}
+		ts.list[n] = nil
+	}
+	ts.list = ts.list[n:]
+}
+
+// check rejects the reads as of a time tombstones may be dropped since
+func (ts *tombstones) check(asOf, now int64) error {
+	if ts == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s is not supported, ps keeps no tombstones", entity.AsOfParam))
+	}
+	if asOf < now-ts.retention {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s [%d] is out of the tombstone retention of %d seconds", entity.AsOfParam, asOf, ts.retention/1000))
+	}
+	return nil
+}
+
+// get returns the document of key as of asOf, which is the one of the first
+// delete after asOf, nil if key was not deleted after asOf
+func (ts *tombstones) get(key string, asOf int64) *vearchpb.Document {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, t := range ts.byKey[key] {
+		if t.time > asOf {
+			return t.doc
+		}
+	}
+	return nil
+}
+
+// since returns the documents deleted after asOf, each as of asOf
+func (ts *tombstones) since(asOf int64) []*vearchpb.Document {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	docs := make([]*vearchpb.Document, 0)
+	seen := make(map[string]bool)
+	for _, t := range ts.list {
+		if t.time > asOf && !seen[t.doc.PKey] {
+			seen[t.doc.PKey] = true
+			docs = append(docs, t.doc)
+		}
+	}
+	return docs
+}
+
+// keepTombstone keeps the document a delete entry removes, it is called by
+// the apply before the delete. Entries of leaders stamping no propose time
+// keep none
+func (s *Store) keepTombstone(cmd *fencedCommand) {
+	if s.tombstones == nil || cmd.ProposeTime == 0 || cmd.Type != vearchpb.CmdType_WRITE ||
+		cmd.WriteCommand == nil || cmd.WriteCommand.Type != vearchpb.OpType_DELETE {
+		return
+	}
+	doc := &vearchpb.Document{PKey: string(cmd.WriteCommand.Doc)}
+	if err := s.Engine.Reader().GetDoc(s.Ctx, doc, false, false); err != nil {
+		// nothing to delete
+		return
+	}
+	s.tombstones.add(doc, cmd.ProposeTime, time.Now().UnixMilli())
+}
+
+// Tombstone returns the document of key as of asOf if it was deleted after
+// asOf, nil if not
+func (s *Store) Tombstone(key string, asOf int64) (*vearchpb.Document, error) {
+	if err := s.tombstones.check(asOf, time.Now().UnixMilli()); err != nil {
+		return nil, err
+	}
+	return s.tombstones.get(key, asOf), nil
+}
+
+// Tombstones returns the documents deleted after asOf, each as of asOf
+func (s *Store) Tombstones(asOf int64) ([]*vearchpb.Document, error) {
+	if err := s.tombstones.check(asOf, time.Now().UnixMilli()); err != nil {
+		return nil, err
+	}
+	return s.tombstones.since(asOf), nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func tombstoneDoc(key, version string) *vearchpb.Document {
+	return &vearchpb.Document{PKey: key, Fields: []*vearchpb.Field{{Name: "version", Value: []byte(version)}}}
+}
+
+func tombstoneVersion(doc *vearchpb.Document) string {
+	if doc == nil {
+		return ""
+	}
+	return string(doc.Fields[0].Value)
+}
+
+func TestTombstonesGet(t *testing.T) {
+	ts := newTombstones(&config.TombstoneCfg{Retention: 60})
+	ts.add(tombstoneDoc("a", "v1"), 1000, 1000)
+	ts.add(tombstoneDoc("b", "v1"), 2000, 2000)
+	ts.add(tombstoneDoc("a", "v2"), 3000, 3000)
+
+	tests := []struct {
+		name string
+		key  string
+		asOf int64
+		want string
+	}{
+		{name: "Before first delete", key: "a", asOf: 500, want: "v1"},
+		{name: "Between deletes", key: "a", asOf: 1500, want: "v2"},
+		{name: "After last delete", key: "a", asOf: 3000},
+		{name: "Other key", key: "b", asOf: 1500, want: "v1"},
+		{name: "Never deleted", key: "c", asOf: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tombstoneVersion(ts.get(tt.key, tt.asOf)); got != tt.want {
+				t.Errorf("get(%s, %d) = %q, want %q", tt.key, tt.asOf, got, tt.want)
+			}
+		})
+	}
+
+	docs := ts.since(500)
+	if len(docs) != 2 || docs[0].PKey != "a" || tombstoneVersion(docs[0]) != "v1" || docs[1].PKey != "b" {
+		t.Errorf("since(500) = %v, want a v1 and b", docs)
+	}
+}
+
+func TestTombstonesExpire(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *config.TombstoneCfg
+		now      int64
+		wantKeys []string
+	}{
+		{
+			name:     "All retained",
+			cfg:      &config.TombstoneCfg{Retention: 10},
+			now:      3000,
+			wantKeys: []string{"a", "b", "c"},
+		},
+		{
+			name:     "Out of retention",
+			cfg:      &config.TombstoneCfg{Retention: 10},
+			now:      11500,
+			wantKeys: []string{"b", "c"},
+		},
+		{
+			name:     "Beyond max",
+			cfg:      &config.TombstoneCfg{Retention: 10, MaxDocs: 1},
+			now:      3000,
+			wantKeys: []string{"c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTombstones(tt.cfg)
+			for i, key := range []string{"a", "b", "c"} {
+				ts.add(tombstoneDoc(key, "v1"), int64(i+1)*1000, int64(i+1)*1000)
+			}
+			ts.expire(tt.now)
+			var keys []string
+			for _, doc := range ts.since(0) {
+				keys = append(keys, doc.PKey)
+			}
+			if len(keys) != len(tt.wantKeys) {
+				t.Fatalf("kept %v, want %v", keys, tt.wantKeys)
+			}
+			for i := range keys {
+				if keys[i] != tt.wantKeys[i] {
+					t.Fatalf("kept %v, want %v", keys, tt.wantKeys)
+				}
+			}
+		})
+	}
+}
+
+func TestTombstonesCheck(t *testing.T) {
+	var disabled *tombstones
+	if err := disabled.check(1000, 1000); err == nil {
+		t.Error("check() of disabled tombstones should fail")
+	}
+	ts := newTombstones(&config.TombstoneCfg{Retention: 10})
+	if err := ts.check(5000, 10000); err != nil {
+		t.Errorf("check() in retention err = %v", err)
+	}
+	if err := ts.check(-1, 10000); err == nil {
+		t.Error("check() out of retention should fail")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/pkg/compress"
+	"github.com/vearch/vearch/v3/internal/pkg/ratelimit"
+)
+
+var (
+	transferOnce    sync.Once
+	transferLimiter *ratelimit.Limiter
+)
+
+// TransferLimiter limits the bytes all snapshots of this ps send, nil if
+// they are not limited
+func TransferLimiter() *ratelimit.Limiter {
+	transferOnce.Do(func() {
+		transferLimiter = ratelimit.New(config.Conf().PS.TransferConfig().RateLimit)
+	})
+	return transferLimiter
+}
+
+// transferSnapshot compresses and limits the messages of a raft snapshot
+type transferSnapshot struct {
+	proto.Snapshot
+	ctx context.Context
+	cfg *config.TransferCfg
+}
+
+func newTransferSnapshot(ctx context.Context, snapshot proto.Snapshot) proto.Snapshot {
+	cfg := config.Conf().PS.TransferConfig()
+	if cfg.Compression == "" && cfg.RateLimit <= 0 {
+		return snapshot
+	}
+	return &transferSnapshot{Snapshot: snapshot, ctx: ctx, cfg: cfg}
+}
+
+// Next keeps io.EOF of the last message, which has data too
+func (s *transferSnapshot) Next() ([]byte, error) {
+	data, err := s.Snapshot.Next()
+	if len(data) == 0 {
+		return data, err
+	}
+	data, cerr := compress.Compress(s.cfg.Compression, s.cfg.CompressionLevel, data)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if werr := TransferLimiter().Wait(s.ctx, len(data)); werr != nil {
+		return nil, werr
+	}
+	return data, err
+}
+
+// transferIterator decompresses the messages of a raft snapshot the leader
+// compressed, the messages of leaders not compressing are kept
+type transferIterator struct {
+	proto.SnapIterator
+}
+
+func (it *transferIterator) Next() ([]byte, error) {
+	data, err := it.SnapIterator.Next()
+	if !compress.Compressed(data) {
+		return data, err
+	}
+	data, derr := compress.Decompress(data)
+	if derr != nil {
+		return nil, derr
+	}
+	return data, err
+}
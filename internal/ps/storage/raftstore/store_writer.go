@@ -16,6 +16,7 @@ package raftstore
 
 import (
 	"context"
+	"time"
 
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
@@ -26,8 +27,9 @@ import (
 )
 
 type RaftApplyResponse struct {
-	FlushC chan error
-	Err    error
+	FlushC   chan error
+	Checksum *entity.ReplicaChecksum
+	Err      error
 }
 
 func (r *RaftApplyResponse) SetErr(err error) *RaftApplyResponse {
@@ -78,6 +80,10 @@ func (s *Store) Write(ctx context.Context, request *vearchpb.DocCmd) (err error)
 	if err = s.checkWritable(); err != nil {
 		return err
 	}
+	epoch, err := s.checkFencing()
+	if err != nil {
+		return err
+	}
 
 	if request.Type == vearchpb.OpType_BULK {
 		if s.Partition.ResourceExhausted {
@@ -94,9 +100,15 @@ func (s *Store) Write(ctx context.Context, request *vearchpb.DocCmd) (err error)
 		}
 	}
 
-	raftCmd := &vearchpb.RaftCommand{
-		Type:         vearchpb.CmdType_WRITE,
-		WriteCommand: request,
+	raftCmd := &fencedCommand{
+		RaftCommand: &vearchpb.RaftCommand{
+			Type:         vearchpb.CmdType_WRITE,
+			WriteCommand: request,
+		},
+		FencingEpoch: epoch,
+	}
+	if request.Type == vearchpb.OpType_DELETE {
+		raftCmd.ProposeTime = time.Now().UnixMilli()
 	}
 
 	data, err := vjson.Marshal(raftCmd)
@@ -0,0 +1,219 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/distance"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine/gohnsw"
+	"google.golang.org/protobuf/proto"
+)
+
+// getTombstones gets the documents not found from the tombstones of their
+// deletes after asOf
+func getTombstones(store PartitionStore, items []*vearchpb.Item, asOf int64) {
+	for _, item := range items {
+		if item.Err == nil || item.Err.Code != vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST {
+			continue
+		}
+		doc, err := store.Tombstone(item.Doc.PKey, asOf)
+		if err != nil {
+			item.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
+			continue
+		}
+		if doc != nil {
+			item.Doc.Fields, item.Err = doc.Fields, nil
+		}
+	}
+}
+
+// tombstoneQuery is a vector field of a search with the metric of its index
+type tombstoneQuery struct {
+	field              string
+	dimension          int
+	vectors            [][]float32
+	metric             distance.Metric
+	minScore, maxScore float64
+}
+
+// searchTombstones adds the documents deleted after asOf to the results of
+// a search. They are scored by brute force, summing the scores of the
+// vector fields, and filtered like the go engine does
+func searchTombstones(store PartitionStore, asOf int64, request *vearchpb.SearchRequest, response *vearchpb.SearchResponse) error {
+	docs, err := store.Tombstones(asOf)
+	if err != nil || len(docs) == 0 {
+		return err
+	}
+	space := store.GetSpace()
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		if proMap, err = entity.UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	accept, err := gohnsw.DocFilter(proMap, request.RangeFilters, request.TermFilters)
+	if err != nil {
+		return err
+	}
+	queries, reqNum, err := tombstoneQueries(&space, proMap, request)
+	if err != nil {
+		return err
+	}
+
+	searchResponse := &vearchpb.SearchResponse{}
+	if response.FlatBytes != nil {
+		if err := proto.Unmarshal(response.FlatBytes, searchResponse); err != nil {
+			return err
+		}
+	}
+	for len(searchResponse.Results) < reqNum {
+		searchResponse.Results = append(searchResponse.Results, &vearchpb.SearchResult{})
+	}
+	ascending := queries[0].metric.Ascending()
+	for i, result := range searchResponse.Results[:reqNum] {
+		if result == nil {
+			result = &vearchpb.SearchResult{}
+			searchResponse.Results[i] = result
+		}
+		found := make(map[string]bool, len(result.ResultItems))
+		for _, item := range result.ResultItems {
+			found[resultItemKey(item)] = true
+		}
+		for _, doc := range docs {
+			if found[doc.PKey] || (accept != nil && !accept(doc)) {
+				continue
+			}
+			if score, ok := scoreTombstone(queries, i, doc); ok {
+				result.ResultItems = append(result.ResultItems, tombstoneItem(doc, score, request.Fields, request.IsVectorValue))
+				result.TotalHits++
+			}
+		}
+		sortResultItems(result.ResultItems, ascending)
+		if request.TopN > 0 && int32(len(result.ResultItems)) > request.TopN {
+			result.ResultItems = result.ResultItems[:request.TopN]
+		}
+		if len(result.ResultItems) > 0 {
+			result.MaxScore = result.ResultItems[0].Score
+		}
+	}
+	flatBytes, err := proto.Marshal(searchResponse)
+	if err != nil {
+		return err
+	}
+	response.FlatBytes = flatBytes
+	return nil
+}
+
+// tombstoneQueries splits the vectors of every vector field of request, each
+// one has req_num vectors
+func tombstoneQueries(space *entity.Space, proMap map[string]*entity.SpaceProperties, request *vearchpb.SearchRequest) ([]*tombstoneQuery, int, error) {
+	if len(request.VecFields) == 0 {
+		return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("search has no vector"))
+	}
+	queries := make([]*tombstoneQuery, 0, len(request.VecFields))
+	reqNum := int(request.ReqNum)
+	for _, vf := range request.VecFields {
+		pro := proMap[vf.Name]
+		if pro == nil || pro.FieldType != vearchpb.FieldType_VECTOR || pro.Dimension <= 0 {
+			return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s is not a vector field", vf.Name))
+		}
+		values, err := cbbytes.ByteToFloat32Array(vf.Value)
+		if err != nil || len(values)%pro.Dimension != 0 {
+			return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector of field %s should have dimension %d", vf.Name, pro.Dimension))
+		}
+		n := len(values) / pro.Dimension
+		if reqNum <= 0 {
+			reqNum = n
+		}
+		if n != reqNum {
+			return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field %s has %d vectors, should be %d", vf.Name, n, reqNum))
+		}
+		metric, err := distance.New(metricType(space, pro), pro.Dimension, nil)
+		if err != nil {
+			return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+		}
+		q := &tombstoneQuery{field: vf.Name, dimension: pro.Dimension, metric: metric, minScore: vf.MinScore, maxScore: vf.MaxScore}
+		for i := 0; i < n; i++ {
+			q.vectors = append(q.vectors, values[i*pro.Dimension:(i+1)*pro.Dimension])
+		}
+		queries = append(queries, q)
+	}
+	return queries, reqNum, nil
+}
+
+// metricType is the metric of the index of a vector field, or of the space
+// index for spaces declaring it there
+func metricType(space *entity.Space, pro *entity.SpaceProperties) string {
+	params := &entity.IndexParams{}
+	if pro.Index != nil && len(pro.Index.Params) > 0 {
+		_ = json.Unmarshal(pro.Index.Params, params)
+	} else if space.Index != nil && len(space.Index.Params) > 0 {
+		_ = json.Unmarshal(space.Index.Params, params)
+	}
+	if params.MetricType == "" {
+		return entity.DefaultMetricType
+	}
+	return params.MetricType
+}
+
+// scoreTombstone scores doc by the i-th vector of every query, false if doc
+// misses a vector or a score is out of the bounds of its query
+func scoreTombstone(queries []*tombstoneQuery, i int, doc *vearchpb.Document) (float64, bool) {
+	score := 0.0
+	for _, q := range queries {
+		var vector []float32
+		for _, f := range doc.Fields {
+			if f.Name == q.field && len(f.Value) >= q.dimension*4 {
+				vector, _ = cbbytes.ByteToFloat32Array(f.Value[:q.dimension*4])
+			}
+		}
+		if vector == nil {
+			return 0, false
+		}
+		s := q.metric.Score(q.vectors[i], vector)
+		if (q.minScore != 0 || q.maxScore != 0) && (s < q.minScore || s > q.maxScore) {
+			return 0, false
+		}
+		score += s
+	}
+	return score, true
+}
+
+// tombstoneItem returns the fields of doc a search asks for like the engines
+// do, the _id is always returned
+func tombstoneItem(doc *vearchpb.Document, score float64, names []string, vectorValue bool) *vearchpb.ResultItem {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	item := &vearchpb.ResultItem{Score: score, PKey: doc.PKey}
+	for _, f := range doc.Fields {
+		if f.Name != entity.IdField {
+			if len(wanted) > 0 && !wanted[f.Name] {
+				continue
+			}
+			if f.Type == vearchpb.FieldType_VECTOR && !vectorValue {
+				continue
+			}
+		}
+		item.Fields = append(item.Fields, f)
+	}
+	return item
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/wasm"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// udfModules caches the compiled module of each udf of a space, a newer
+// version of the space compiles it again
+var udfModules sync.Map
+
+type udfKey struct {
+	space entity.SpaceID
+	name  string
+}
+
+type udfModule struct {
+	version entity.Version
+	module  *wasm.Module
+}
+
+// udfFunc is a udf instantiated for the candidates of a request
+type udfFunc struct {
+	udf      *entity.SpaceUDF
+	instance *wasm.Instance
+	fuel     int64
+}
+
+// udfRun filters and scores candidates by the udfs of a call
+type udfRun struct {
+	filter *udfFunc
+	score  *udfFunc
+	proMap map[string]*entity.SpaceProperties
+}
+
+func newUDFRun(space *entity.Space, call *entity.UDFCall, proMap map[string]*entity.SpaceProperties) (*udfRun, error) {
+	cfg := config.Conf().PS.UDF
+	if cfg == nil || !cfg.Enabled {
+		return nil, fmt.Errorf("udfs are not enabled on ps")
+	}
+	r := &udfRun{proMap: proMap}
+	var err error
+	if call.Filter != "" {
+		if r.filter, err = newUDFFunc(space, call.Filter, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if call.Score != "" {
+		if r.score, err = newUDFFunc(space, call.Score, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func newUDFFunc(space *entity.Space, name string, cfg *config.UDFCfg) (*udfFunc, error) {
+	u := space.UDF(name)
+	if u == nil {
+		return nil, fmt.Errorf("udf %s is not a udf of space %s", name, space.Name)
+	}
+	fuel := u.Fuel
+	if cfg.MaxFuel > 0 && fuel > cfg.MaxFuel {
+		fuel = cfg.MaxFuel
+	}
+	key := udfKey{space: space.Id, name: name}
+	if v, ok := udfModules.Load(key); ok && v.(*udfModule).version == space.Version {
+		return &udfFunc{udf: u, instance: v.(*udfModule).module.Instantiate(), fuel: fuel}, nil
+	}
+	limited := *u
+	if cfg.MaxMemoryPages > 0 && limited.MemoryPages > cfg.MaxMemoryPages {
+		limited.MemoryPages = cfg.MaxMemoryPages
+	}
+	m, err := limited.Compile()
+	if err != nil {
+		return nil, err
+	}
+	udfModules.Store(key, &udfModule{version: space.Version, module: m})
+	return &udfFunc{udf: u, instance: m.Instantiate(), fuel: fuel}, nil
+}
+
+func (f *udfFunc) call(score float64, fields []*vearchpb.Field, proMap map[string]*entity.SpaceProperties) (uint64, error) {
+	values := f.udf.Args(score, fields, proMap)
+	args := make([]uint64, len(values))
+	for i, v := range values {
+		args[i] = math.Float64bits(v)
+	}
+	results, err := f.instance.Call(f.udf.Export(), f.fuel, args...)
+	if err != nil {
+		return 0, fmt.Errorf("udf %s err: %v", f.udf.Name, err)
+	}
+	return results[0], nil
+}
+
+// apply drops the items the filter rejects and scores the others
+func (r *udfRun) apply(items []*vearchpb.ResultItem) ([]*vearchpb.ResultItem, error) {
+	kept := items[:0]
+	for _, item := range items {
+		if r.filter != nil {
+			keep, err := r.filter.call(item.Score, item.Fields, r.proMap)
+			if err != nil {
+				return nil, err
+			}
+			if uint32(keep) == 0 {
+				continue
+			}
+		}
+		if r.score != nil {
+			score, err := r.score.call(item.Score, item.Fields, r.proMap)
+			if err != nil {
+				return nil, err
+			}
+			item.Score = math.Float64frombits(score)
+		}
+		kept = append(kept, item)
+	}
+	return kept, nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"bytes"
+	"context"
+	"expvar"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+// the most bytes of goroutine stacks a check reads
+const maxStackDump = 64 << 20
+
+// watchdog tracks the running requests of ps, a request running
+// stuckMultiple times its timeout is stuck, mostly in a cgo call of the
+// engine which can not be interrupted
+type watchdog struct {
+	stuckMultiple float64
+	interval      time.Duration
+	cancel        bool
+
+	mu         sync.Mutex
+	seq        uint64
+	requests   map[uint64]*trackedRequest
+	stuckTotal int64
+}
+
+type trackedRequest struct {
+	pid     entity.PartitionID
+	method  string
+	gid     uint64
+	start   time.Time
+	stuckAt time.Time
+	stuck   bool
+	stats   *partitionStats
+	// cancel cancels the context of the request and frees its slot
+	cancel func()
+}
+
+// WatchdogStats is published as the ps_requests expvar, it tells how long
+// the requests left are when draining a ps
+type WatchdogStats struct {
+	Running    int                   `json:"running"`
+	OldestMs   int64                 `json:"oldest_ms"`
+	Stuck      int                   `json:"stuck"`
+	StuckTotal int64                 `json:"stuck_total"`
+	Requests   []*StuckRequestStatus `json:"stuck_requests,omitempty"`
+}
+
+type StuckRequestStatus struct {
+	PartitionID entity.PartitionID `json:"partition_id"`
+	Method      string             `json:"method"`
+	Goroutine   uint64             `json:"goroutine"`
+	RunningMs   int64              `json:"running_ms"`
+}
+
+func newWatchdog(cfg *config.WatchdogCfg) *watchdog {
+	if cfg == nil {
+		cfg = &config.WatchdogCfg{}
+	}
+	if cfg.Disabled {
+		return nil
+	}
+	w := &watchdog{
+		stuckMultiple: cfg.StuckMultiple,
+		interval:      time.Duration(cfg.Interval) * time.Millisecond,
+		cancel:        cfg.Cancel,
+		requests:      make(map[uint64]*trackedRequest),
+	}
+	if w.stuckMultiple <= 1 {
+		w.stuckMultiple = config.DefaultStuckMultiple
+	}
+	if w.interval <= 0 {
+		w.interval = config.DefaultWatchdogInterval * time.Millisecond
+	}
+	return w
+}
+
+// track adds a request of the current goroutine running for at most
+// timeout, the returned func removes it
+func (w *watchdog) track(pid entity.PartitionID, method string, timeout time.Duration, stats *partitionStats, cancel func()) func() {
+	if w == nil {
+		return func() {}
+	}
+	now := time.Now()
+	r := &trackedRequest{
+		pid:     pid,
+		method:  method,
+		gid:     goroutineID(),
+		start:   now,
+		stuckAt: now.Add(time.Duration(float64(timeout) * w.stuckMultiple)),
+		stats:   stats,
+		cancel:  cancel,
+	}
+	w.mu.Lock()
+	w.seq++
+	id := w.seq
+	w.requests[id] = r
+	w.mu.Unlock()
+	return func() {
+		w.mu.Lock()
+		delete(w.requests, id)
+		stuck := r.stuck
+		w.mu.Unlock()
+		if stuck {
+			r.stats.unstuck()
+			log.Warn("stuck %s of partition %d ends after %v", r.method, r.pid, time.Since(r.start))
+		}
+	}
+}
+
+// check marks the requests running past their stuck time and returns them
+func (w *watchdog) check(now time.Time) []*trackedRequest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var stuck []*trackedRequest
+	for _, r := range w.requests {
+		if r.stuck || now.Before(r.stuckAt) {
+			continue
+		}
+		r.stuck = true
+		w.stuckTotal++
+		r.stats.stuck()
+		stuck = append(stuck, r)
+	}
+	return stuck
+}
+
+func (w *watchdog) stats(now time.Time) *WatchdogStats {
+	stats := &WatchdogStats{}
+	if w == nil {
+		return stats
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats.Running = len(w.requests)
+	stats.StuckTotal = w.stuckTotal
+	for _, r := range w.requests {
+		running := now.Sub(r.start).Milliseconds()
+		stats.OldestMs = max(stats.OldestMs, running)
+		if r.stuck {
+			stats.Stuck++
+			stats.Requests = append(stats.Requests, &StuckRequestStatus{PartitionID: r.pid, Method: r.method, Goroutine: r.gid, RunningMs: running})
+		}
+	}
+	return stats
+}
+
+// report logs the stacks of the goroutines of stuck requests and cancels
+// them if configured
+func (w *watchdog) report(stuck []*trackedRequest) {
+	stacks := goroutineStacks()
+	for _, r := range stuck {
+		log.Error("%s of partition %d is stuck for %v, goroutine stack:\n%s", r.method, r.pid, time.Since(r.start), stacks[r.gid])
+		if w.cancel && r.cancel != nil {
+			log.Warn("cancel stuck %s of partition %d", r.method, r.pid)
+			r.cancel()
+		}
+	}
+}
+
+// StartWatchdogJob checks the running requests for stuck ones
+func (s *Server) StartWatchdogJob() {
+	if s.watchdog == nil {
+		return
+	}
+	expvar.Publish("ps_requests", expvar.Func(func() interface{} { return s.watchdog.stats(time.Now()) }))
+	go func() {
+		ticker := time.NewTicker(s.watchdog.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case now := <-ticker.C:
+				if stuck := s.watchdog.check(now); len(stuck) > 0 {
+					s.watchdog.report(stuck)
+				}
+			}
+		}
+	}()
+}
+
+// logDraining logs the requests still running when ps stops
+func (s *Server) logDraining() {
+	if s.watchdog == nil {
+		return
+	}
+	stats := s.watchdog.stats(time.Now())
+	if stats.Running == 0 {
+		return
+	}
+	log.Warn("ps stops with %d requests running, the oldest for %dms, %d stuck", stats.Running, stats.OldestMs, stats.Stuck)
+	for _, r := range stats.Requests {
+		log.Warn("stuck %s of partition %d running for %dms", r.Method, r.PartitionID, r.RunningMs)
+	}
+}
+
+// goroutineID parses the id of the current goroutine from its stack header
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// goroutineStacks returns the stacks of all goroutines by id
+func goroutineStacks() map[uint64]string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackDump {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := make(map[uint64]string)
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		var id uint64
+		if _, err := fmt.Sscanf(string(stack), "goroutine %d ", &id); err == nil {
+			stacks[id] = string(stack)
+		}
+	}
+	return stacks
+}
+
+// cancelOnce cancels ctx and frees the slot of a request at most once,
+// from the watchdog or when the request ends
+func cancelOnce(cancel context.CancelFunc, release func()) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			release()
+		})
+	}
+}
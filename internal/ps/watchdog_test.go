@@ -0,0 +1,129 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+)
+
+func TestNewWatchdog(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *config.WatchdogCfg
+		disabled bool
+		multiple float64
+		interval time.Duration
+	}{
+		{name: "default", multiple: config.DefaultStuckMultiple, interval: time.Second},
+		{name: "disabled", cfg: &config.WatchdogCfg{Disabled: true}, disabled: true},
+		{name: "configured", cfg: &config.WatchdogCfg{StuckMultiple: 5, Interval: 200}, multiple: 5, interval: 200 * time.Millisecond},
+		{name: "multiple too small", cfg: &config.WatchdogCfg{StuckMultiple: 0.5}, multiple: config.DefaultStuckMultiple, interval: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newWatchdog(tt.cfg)
+			if tt.disabled {
+				if w != nil {
+					t.Fatalf("watchdog is not disabled")
+				}
+				return
+			}
+			if w.stuckMultiple != tt.multiple || w.interval != tt.interval {
+				t.Errorf("multiple %v interval %v, want %v and %v", w.stuckMultiple, w.interval, tt.multiple, tt.interval)
+			}
+		})
+	}
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	w := newWatchdog(&config.WatchdogCfg{StuckMultiple: 2, Cancel: true})
+	stats := newPartitionStats()
+	canceled := 0
+	done := w.track(1, "SearchHandler", 100*time.Millisecond, stats, func() { canceled++ })
+	w.track(2, "GetHandler", time.Second, nil, nil)
+
+	now := time.Now()
+	tests := []struct {
+		name  string
+		now   time.Time
+		stuck int
+		total int64
+	}{
+		{name: "in time", now: now.Add(100 * time.Millisecond), stuck: 0},
+		{name: "stuck", now: now.Add(300 * time.Millisecond), stuck: 1, total: 1},
+		{name: "reported once", now: now.Add(time.Second), stuck: 0, total: 1},
+		{name: "both stuck", now: now.Add(3 * time.Second), stuck: 1, total: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stuck := w.check(tt.now)
+			if len(stuck) != tt.stuck {
+				t.Fatalf("stuck %d, want %d", len(stuck), tt.stuck)
+			}
+			w.report(stuck)
+			if s := w.stats(tt.now); s.StuckTotal != tt.total || s.Running != 2 {
+				t.Errorf("stuck total %d running %d, want %d and 2", s.StuckTotal, s.Running, tt.total)
+			}
+		})
+	}
+	if canceled != 1 {
+		t.Errorf("canceled %d times, want 1", canceled)
+	}
+	if stats.stuckNow.Load() != 1 || stats.stuckTotal.Load() != 1 {
+		t.Errorf("partition stuck %d total %d, want 1 and 1", stats.stuckNow.Load(), stats.stuckTotal.Load())
+	}
+
+	done()
+	s := w.stats(now.Add(3 * time.Second))
+	if s.Running != 1 || s.Stuck != 1 || len(s.Requests) != 1 || s.Requests[0].PartitionID != 2 {
+		t.Errorf("unexpected stats after done: %+v", s)
+	}
+	if stats.stuckNow.Load() != 0 || stats.stuckTotal.Load() != 1 {
+		t.Errorf("partition stuck %d total %d, want 0 and 1", stats.stuckNow.Load(), stats.stuckTotal.Load())
+	}
+}
+
+func TestWatchdogNil(t *testing.T) {
+	var w *watchdog
+	w.track(1, "SearchHandler", time.Second, nil, nil)()
+	if s := w.stats(time.Now()); s.Running != 0 {
+		t.Errorf("running %d, want 0", s.Running)
+	}
+}
+
+func TestCancelOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	released := 0
+	finish := cancelOnce(cancel, func() { released++ })
+	finish()
+	finish()
+	if released != 1 || ctx.Err() == nil {
+		t.Errorf("released %d, ctx err %v", released, ctx.Err())
+	}
+}
+
+func TestGoroutineStacks(t *testing.T) {
+	id := goroutineID()
+	if id == 0 {
+		t.Fatal("no goroutine id")
+	}
+	if _, ok := goroutineStacks()[id]; !ok {
+		t.Errorf("no stack of goroutine %d", id)
+	}
+}
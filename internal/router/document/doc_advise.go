@@ -0,0 +1,211 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const maxAdviseCandidates = 64
+
+// AdviseResult is the evaluation of every candidate index params and the one
+// with the highest recall within the latency budget
+type AdviseResult struct {
+	GroundTruth   string            `json:"ground_truth"`
+	LatencyBudget float64           `json:"latency_budget_ms"`
+	Recommended   *EvaluateResult   `json:"recommended"`
+	Candidates    []*EvaluateResult `json:"candidates"`
+	Applied       bool              `json:"applied"`
+}
+
+// defaultAdviseCandidates returns the search params worth sweeping for the
+// index type of the vector field
+func defaultAdviseCandidates(space *entity.Space, field string, limit int32) map[string][]int {
+	var index *entity.Index
+	if p, ok := space.SpaceProperties[field]; ok && p.Index != nil {
+		index = p.Index
+	} else {
+		index = space.Index
+	}
+	if index == nil {
+		return nil
+	}
+	k := int(limit)
+	switch index.Type {
+	case "HNSW":
+		return map[string][]int{"efSearch": {16, 32, 64, 128, 256, 512}}
+	case "IVFPQ", "IVFPQ_RELAYOUT":
+		return map[string][]int{"nprobe": {8, 16, 32, 64, 128, 256}, "recall_num": {k, 4 * k, 16 * k}}
+	case "IVFFLAT", "BINARYIVF", "GPU":
+		return map[string][]int{"nprobe": {8, 16, 32, 64, 128, 256}}
+	}
+	return nil
+}
+
+// adviseParams returns the cartesian product of candidates merged into base
+func adviseParams(base json.RawMessage, candidates map[string][]int) ([]json.RawMessage, error) {
+	keys := make([]string, 0, len(candidates))
+	total := 1
+	for key, values := range candidates {
+		if len(values) == 0 {
+			continue
+		}
+		keys = append(keys, key)
+		total *= len(values)
+	}
+	if len(keys) == 0 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("no candidates to advise, set candidates for the index type"))
+	}
+	if total > maxAdviseCandidates {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("candidates num %d exceeds the limit %d", total, maxAdviseCandidates))
+	}
+	sort.Strings(keys)
+
+	params := make([]json.RawMessage, 0, total)
+	for i := 0; i < total; i++ {
+		m := make(map[string]interface{})
+		if len(base) > 0 {
+			if err := json.Unmarshal(base, &m); err != nil {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index_params unmarshal err: %v", err))
+			}
+		}
+		n := i
+		for _, key := range keys {
+			values := candidates[key]
+			m[key] = values[n%len(values)]
+			n /= len(values)
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, b)
+	}
+	return params, nil
+}
+
+// recommend returns the candidate with the highest recall whose p99 latency
+// is within budget, ties are broken by the lower p99
+func recommend(candidates []*EvaluateResult, budget float64) *EvaluateResult {
+	var best *EvaluateResult
+	for _, c := range candidates {
+		if budget > 0 && c.Latency.P99 > budget {
+			continue
+		}
+		if best == nil || c.Recall > best.Recall || (c.Recall == best.Recall && c.Latency.P99 < best.Latency.P99) {
+			best = c
+		}
+	}
+	return best
+}
+
+func (handler *DocumentHandler) handleDocumentAdvise(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleDocumentAdvise", startTime)
+	adviseReq := &request.AdviseRequest{}
+	if err := c.ShouldBindJSON(adviseReq); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	head := &vearchpb.RequestHead{DbName: adviseReq.DbName, SpaceName: adviseReq.SpaceName, Params: make(map[string]string)}
+	space, err := handler.docService.getSpace(c.Request.Context(), head)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	adviseReq.SpaceName = head.SpaceName
+
+	result, err := handler.advise(c.Request.Context(), space, adviseReq)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if adviseReq.Apply && result.Recommended != nil {
+		url := fmt.Sprintf("/dbs/%s/spaces/%s/search_params", adviseReq.DbName, adviseReq.SpaceName)
+		if _, err := handler.client.Master().ProxyHTTPRequest(http.MethodPut, url, string(result.Recommended.IndexParams), c.GetHeader("Authorization")); err != nil {
+			response.New(c).JsonError(errors.NewErrInternal(fmt.Errorf("apply recommended params err: %v", err)))
+			return
+		}
+		result.Applied = true
+	}
+	response.New(c).JsonSuccess(result)
+}
+
+// advise evaluates every candidate index params against the same ground truth
+func (handler *DocumentHandler) advise(ctx context.Context, space *entity.Space, adviseReq *request.AdviseRequest) (*AdviseResult, error) {
+	evalReq := &adviseReq.EvaluateRequest
+	if evalReq.Limit <= 0 {
+		evalReq.Limit = 10
+	}
+	candidates := adviseReq.Candidates
+	if len(candidates) == 0 {
+		candidates = defaultAdviseCandidates(space, evalReq.Field, evalReq.Limit)
+	}
+	base := evalReq.IndexParams
+	if base == nil {
+		base = space.DefaultSearchParams
+	}
+	params, err := adviseParams(base, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AdviseResult{GroundTruth: GroundTruthSupplied, LatencyBudget: adviseReq.LatencyBudget}
+	if evalReq.GroundTruth == nil {
+		if evalReq.Field == "" || len(evalReq.Vectors) == 0 {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field and vectors should not be empty"))
+		}
+		if len(evalReq.Vectors) > maxEvaluateQueries {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vectors num %d exceeds the limit %d", len(evalReq.Vectors), maxEvaluateQueries))
+		}
+		// brute force once and reuse it for every candidate
+		result.GroundTruth = GroundTruthBruteForce
+		truth := make([][]string, len(evalReq.Vectors))
+		for i, vector := range evalReq.Vectors {
+			ids, _, err := handler.evaluateSearch(ctx, space, evalReq, vector, true)
+			if err != nil {
+				return nil, err
+			}
+			truth[i] = ids
+		}
+		evalReq.GroundTruth = truth
+	}
+
+	for _, p := range params {
+		candidateReq := *evalReq
+		candidateReq.IndexParams = p
+		r, err := handler.evaluate(ctx, space, &candidateReq)
+		if err != nil {
+			return nil, err
+		}
+		r.GroundTruth = result.GroundTruth
+		result.Candidates = append(result.Candidates, r)
+	}
+	result.Recommended = recommend(result.Candidates, adviseReq.LatencyBudget)
+	return result, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAdviseParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       string
+		candidates map[string][]int
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:       "Cartesian product merged into base",
+			base:       `{"metric_type":"L2"}`,
+			candidates: map[string][]int{"recall_num": {10, 40}, "nprobe": {8, 16}},
+			want: []string{
+				`{"metric_type":"L2","nprobe":8,"recall_num":10}`,
+				`{"metric_type":"L2","nprobe":16,"recall_num":10}`,
+				`{"metric_type":"L2","nprobe":8,"recall_num":40}`,
+				`{"metric_type":"L2","nprobe":16,"recall_num":40}`,
+			},
+		},
+		{
+			name:       "Candidates without base",
+			candidates: map[string][]int{"efSearch": {16, 32}, "nprobe": {}},
+			want:       []string{`{"efSearch":16}`, `{"efSearch":32}`},
+		},
+		{
+			name:       "No candidates",
+			candidates: map[string][]int{"nprobe": {}},
+			wantErr:    true,
+		},
+		{
+			name:       "Candidates beyond limit",
+			candidates: map[string][]int{"a": make([]int, maxAdviseCandidates), "b": {1, 2}},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := adviseParams(json.RawMessage(tt.base), tt.candidates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("adviseParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(params) != len(tt.want) {
+				t.Fatalf("adviseParams() = %d params, want %d", len(params), len(tt.want))
+			}
+			for i, p := range params {
+				if string(p) != tt.want[i] {
+					t.Errorf("params[%d] = %s, want %s", i, p, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	candidate := func(recall, p99 float64) *EvaluateResult {
+		return &EvaluateResult{Recall: recall, Latency: &LatencySummary{P99: p99}}
+	}
+	fast, accurate, tied, slow := candidate(0.9, 5), candidate(0.95, 8), candidate(0.95, 6), candidate(0.99, 20)
+	tests := []struct {
+		name   string
+		budget float64
+		want   *EvaluateResult
+	}{
+		{name: "Highest recall without budget", budget: 0, want: slow},
+		{name: "Highest recall within budget, tie broken by p99", budget: 10, want: tied},
+		{name: "Only the fast one within budget", budget: 5, want: fast},
+		{name: "None within budget", budget: 1, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recommend([]*EvaluateResult{fast, accurate, tied, slow}, tt.budget); got != tt.want {
+				t.Errorf("recommend() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// resolveFieldAliases replaces the field aliases of space in the fields,
+// filters and sort of a read request by their fields, returns the aliases
+// asked in fields by field to rename the returned documents
+func resolveFieldAliases(searchDoc *request.SearchDocumentRequest, space *entity.Space) (map[string][]string, error) {
+	if len(space.FieldAliases) == 0 {
+		return nil, nil
+	}
+	var renames map[string][]string
+	fields := make([]string, 0, len(searchDoc.Fields))
+	asked := make(map[string]bool, len(searchDoc.Fields))
+	byName := make(map[string]bool, len(searchDoc.Fields))
+	for _, name := range searchDoc.Fields {
+		field := space.ResolveField(name)
+		if field != name {
+			if renames == nil {
+				renames = make(map[string][]string)
+			}
+			renames[field] = append(renames[field], name)
+		} else {
+			byName[field] = true
+		}
+		if !asked[field] {
+			asked[field] = true
+			fields = append(fields, field)
+		}
+	}
+	if searchDoc.Fields != nil {
+		searchDoc.Fields = fields
+	}
+	// the field is still returned by its name if it is asked by name too
+	for field := range renames {
+		if byName[field] {
+			renames[field] = append(renames[field], field)
+		}
+	}
+
+	if searchDoc.Filters != nil {
+		for i := range searchDoc.Filters.Conditions {
+			c := &searchDoc.Filters.Conditions[i]
+			c.Field = space.ResolveField(c.Field)
+		}
+	}
+
+	if len(searchDoc.Sort) > 0 {
+		sorts := make([]interface{}, 0)
+		if err := vjson.Unmarshal(searchDoc.Sort, &sorts); err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("invalid sort: %v", err))
+		}
+		for i, s := range sorts {
+			switch sort := s.(type) {
+			case string:
+				sorts[i] = space.ResolveField(sort)
+			case map[string]interface{}:
+				resolved := make(map[string]interface{}, len(sort))
+				for name, order := range sort {
+					resolved[space.ResolveField(name)] = order
+				}
+				sorts[i] = resolved
+			}
+		}
+		sort, err := vjson.Marshal(sorts)
+		if err != nil {
+			return nil, err
+		}
+		searchDoc.Sort = sort
+	}
+	return renames, nil
+}
+
+// resolveDocumentAliases replaces the field aliases of space in the documents
+// to write by their fields
+func resolveDocumentAliases(docRequest *request.DocumentRequest, space *entity.Space) error {
+	if len(space.FieldAliases) == 0 {
+		return nil
+	}
+	for i, raw := range docRequest.Documents {
+		doc := make(map[string]json.RawMessage)
+		if err := vjson.Unmarshal(raw, &doc); err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+		}
+		changed := false
+		for alias, field := range space.FieldAliases {
+			value, ok := doc[alias]
+			if !ok {
+				continue
+			}
+			if _, ok := doc[field]; ok {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document should have only one of field %s and its alias %s", field, alias))
+			}
+			delete(doc, alias)
+			doc[field] = value
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		resolved, err := vjson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		docRequest.Documents[i] = resolved
+	}
+	return nil
+}
+
+// renameFields returns the fields of the documents of a read response by the
+// names they were asked by
+func renameFields(result map[string]interface{}, renames map[string][]string) {
+	if len(renames) == 0 {
+		return
+	}
+	for _, doc := range resultDocuments(result) {
+		for field, names := range renames {
+			value, ok := doc[field]
+			if !ok {
+				continue
+			}
+			delete(doc, field)
+			for _, name := range names {
+				doc[name] = value
+			}
+		}
+	}
+}
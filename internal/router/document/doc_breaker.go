@@ -0,0 +1,299 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/breaker"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// kinds of the circuit breakers of a space
+const (
+	breakerSearch = "search"
+	breakerWrite  = "write"
+)
+
+const (
+	defaultBreakerWindow      = 10 // s
+	defaultBreakerMinRequests = 20
+	defaultBreakerErrorRate   = 0.5
+	defaultBreakerTimeoutRate = 0.2
+	defaultBreakerOpenTime    = 30 // s
+	defaultBreakerProbes      = 3
+)
+
+type breakerKey struct {
+	space string // db/space
+	kind  string
+}
+
+// spaceBreakers holds the circuit breakers of the searches and of the
+// writes of each space, so a space with a pathological index fails fast
+// instead of holding the ps and router resources the other spaces need
+type spaceBreakers struct {
+	mu       sync.RWMutex
+	cfg      *config.BreakerCfg // nil if off
+	breakers map[breakerKey]*breaker.Breaker
+}
+
+// breakerStatus is a breaker as the admin api shows it
+type breakerStatus struct {
+	Space      string     `json:"space"`
+	Kind       string     `json:"kind"`
+	State      string     `json:"state"`
+	Requests   int        `json:"requests"`
+	Failures   int        `json:"failures"`
+	Timeouts   int        `json:"timeouts"`
+	OpenedAt   *time.Time `json:"opened_at,omitempty"`
+	RetryAfter string     `json:"retry_after,omitempty"`
+}
+
+func newSpaceBreakers(cfg *config.BreakerCfg) *spaceBreakers {
+	s := &spaceBreakers{}
+	if err := s.set(cfg); err != nil {
+		log.Error("circuit breakers are off, %v", err)
+	}
+	return s
+}
+
+func validateBreakerCfg(cfg *config.BreakerCfg) error {
+	if cfg.Window < 0 || cfg.MinRequests < 0 || cfg.OpenTime < 0 || cfg.Probes < 0 {
+		return fmt.Errorf("circuit breaker window, min_requests, open_time and probes should not be negative")
+	}
+	if cfg.ErrorRate < 0 || cfg.ErrorRate > 1 || cfg.TimeoutRate < 0 || cfg.TimeoutRate > 1 {
+		return fmt.Errorf("circuit breaker error_rate and timeout_rate should be in [0, 1]")
+	}
+	return nil
+}
+
+// breakerConfig is cfg with the defaults of its unset values
+func breakerConfig(cfg *config.BreakerCfg) breaker.Config {
+	bc := breaker.Config{
+		Window:      defaultBreakerWindow * time.Second,
+		MinRequests: defaultBreakerMinRequests,
+		ErrorRate:   defaultBreakerErrorRate,
+		TimeoutRate: defaultBreakerTimeoutRate,
+		OpenTime:    defaultBreakerOpenTime * time.Second,
+		Probes:      defaultBreakerProbes,
+	}
+	if cfg.Window > 0 {
+		bc.Window = time.Duration(cfg.Window) * time.Second
+	}
+	if cfg.MinRequests > 0 {
+		bc.MinRequests = cfg.MinRequests
+	}
+	if cfg.ErrorRate > 0 {
+		bc.ErrorRate = cfg.ErrorRate
+	}
+	if cfg.TimeoutRate > 0 {
+		bc.TimeoutRate = cfg.TimeoutRate
+	}
+	if cfg.OpenTime > 0 {
+		bc.OpenTime = time.Duration(cfg.OpenTime) * time.Second
+	}
+	if cfg.Probes > 0 {
+		bc.Probes = cfg.Probes
+	}
+	return bc
+}
+
+// set replaces the config, nil turns the breakers off, the breakers start
+// closed again either way
+func (s *spaceBreakers) set(cfg *config.BreakerCfg) error {
+	if cfg != nil {
+		if err := validateBreakerCfg(cfg); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.breakers {
+		monitor.BreakerState(key.space, key.kind, int(breaker.Closed), false)
+	}
+	s.cfg, s.breakers = cfg, make(map[breakerKey]*breaker.Breaker)
+	return nil
+}
+
+func (s *spaceBreakers) config() *config.BreakerCfg {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// get returns the breaker of kind of the space, nil if they are off
+func (s *spaceBreakers) get(space, kind string) *breaker.Breaker {
+	key := breakerKey{space: space, kind: kind}
+	s.mu.RLock()
+	b, cfg := s.breakers[key], s.cfg
+	s.mu.RUnlock()
+	if b != nil || cfg == nil {
+		return b
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg == nil {
+		return nil
+	}
+	if b = s.breakers[key]; b == nil {
+		b = breaker.New(breakerConfig(s.cfg), func(from, to breaker.State) {
+			log.Warn("circuit breaker of the %s requests of space %s changed from %s to %s", kind, space, from, to)
+			monitor.BreakerState(space, kind, int(to), to == breaker.Open)
+		})
+		s.breakers[key] = b
+	}
+	return b
+}
+
+// allow admits a request of kind to the space, it fails fast with the
+// Retry-After header set if the breaker is open. done records the outcome
+// of the request by the status code written, so it runs after the response
+func (s *spaceBreakers) allow(c *gin.Context, kind, dbName, spaceName string) (done func(), err error) {
+	space := shadowKey(dbName, spaceName)
+	b := s.get(space, kind)
+	if b == nil {
+		return func() {}, nil
+	}
+	record, ok := b.Allow()
+	if !ok {
+		monitor.BreakerRejected(space, kind)
+		if retry := b.RetryAfter(); retry > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		}
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_SERVICE_UNAVAILABLE,
+			fmt.Errorf("circuit breaker of the %s requests of space %s is %s, retry later", kind, space, b.Stats().State))
+	}
+	return func() { record(breakerOutcome(c.Writer.Status())) }, nil
+}
+
+// breakerOutcome maps the status of a response to the outcome of its
+// request, the rejections of the caller and the rate limits do not count
+func breakerOutcome(status int) breaker.Outcome {
+	switch {
+	case status == http.StatusGatewayTimeout:
+		return breaker.Timeout
+	case status == http.StatusTooManyRequests:
+		return breaker.Ignored
+	case status >= http.StatusInternalServerError:
+		return breaker.Failure
+	default:
+		return breaker.Success
+	}
+}
+
+// reset closes the breakers of the space, all of them if space is empty,
+// and returns how many it reset
+func (s *spaceBreakers) reset(space string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for key, b := range s.breakers {
+		if space == "" || key.space == space {
+			b.Reset()
+			n++
+		}
+	}
+	return n
+}
+
+func (s *spaceBreakers) list() []*breakerStatus {
+	s.mu.RLock()
+	statuses := make([]*breakerStatus, 0, len(s.breakers))
+	for key, b := range s.breakers {
+		stats := b.Stats()
+		status := &breakerStatus{
+			Space:    key.space,
+			Kind:     key.kind,
+			State:    stats.State.String(),
+			Requests: stats.Requests,
+			Failures: stats.Failures,
+			Timeouts: stats.Timeouts,
+		}
+		if !stats.OpenedAt.IsZero() {
+			openedAt := stats.OpenedAt
+			status.OpenedAt = &openedAt
+		}
+		if retry := b.RetryAfter(); retry > 0 {
+			status.RetryAfter = retry.Round(time.Second).String()
+		}
+		statuses = append(statuses, status)
+	}
+	s.mu.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Space != statuses[j].Space {
+			return statuses[i].Space < statuses[j].Space
+		}
+		return statuses[i].Kind < statuses[j].Kind
+	})
+	return statuses
+}
+
+func (s *spaceBreakers) status() map[string]interface{} {
+	return map[string]interface{}{"config": s.config(), "breakers": s.list()}
+}
+
+func (handler *DocumentHandler) handleConfigCircuitBreakers(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleConfigCircuitBreakers", startTime)
+	cfg := &config.BreakerCfg{}
+	if err := c.ShouldBindJSON(cfg); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := handler.breakers.set(cfg); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	log.Info("circuit breakers changed to %+v", *cfg)
+	response.New(c).JsonSuccess(handler.breakers.status())
+}
+
+func (handler *DocumentHandler) handleDeleteConfigCircuitBreakers(c *gin.Context) {
+	handler.breakers.set(nil)
+	log.Info("circuit breakers turned off")
+	response.New(c).JsonSuccess(handler.breakers.status())
+}
+
+func (handler *DocumentHandler) handleGetConfigCircuitBreakers(c *gin.Context) {
+	response.New(c).JsonSuccess(handler.breakers.status())
+}
+
+// handleResetCircuitBreakers closes the breakers of a space by hand, once
+// its index is fixed
+func (handler *DocumentHandler) handleResetCircuitBreakers(c *gin.Context) {
+	dbName, spaceName := c.Param(URLParamDbName), c.Param(URLParamSpaceName)
+	if strings.TrimSpace(dbName) == "" || strings.TrimSpace(spaceName) == "" {
+		response.New(c).JsonError(errors.NewErrBadRequest(fmt.Errorf("db and space should not be empty")))
+		return
+	}
+	n := handler.breakers.reset(shadowKey(dbName, spaceName))
+	log.Info("%d circuit breakers of space %s/%s reset", n, dbName, spaceName)
+	response.New(c).JsonSuccess(map[string]int{"reset": n})
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/pkg/breaker"
+)
+
+func TestBreakerOutcome(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   breaker.Outcome
+	}{
+		{name: "Success", status: http.StatusOK, want: breaker.Success},
+		{name: "Bad request", status: http.StatusBadRequest, want: breaker.Success},
+		{name: "Rate limited", status: http.StatusTooManyRequests, want: breaker.Ignored},
+		{name: "Internal error", status: http.StatusInternalServerError, want: breaker.Failure},
+		{name: "Unavailable", status: http.StatusServiceUnavailable, want: breaker.Failure},
+		{name: "Timeout", status: http.StatusGatewayTimeout, want: breaker.Timeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := breakerOutcome(tt.status); got != tt.want {
+				t.Errorf("breakerOutcome(%d) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpaceBreakers(t *testing.T) {
+	serve := func(s *spaceBreakers, kind, space string, status int) (*httptest.ResponseRecorder, error) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		done, err := s.allow(c, kind, "db", space)
+		if err != nil {
+			return w, err
+		}
+		c.Status(status)
+		c.Writer.WriteHeaderNow()
+		done()
+		return w, nil
+	}
+
+	s := newSpaceBreakers(&config.BreakerCfg{MinRequests: 4, ErrorRate: 0.5, OpenTime: 60})
+	for i := 0; i < 4; i++ {
+		if _, err := serve(s, breakerSearch, "bad", http.StatusInternalServerError); err != nil {
+			t.Fatalf("request %d rejected before the breaker tripped: %v", i, err)
+		}
+	}
+	w, err := serve(s, breakerSearch, "bad", http.StatusOK)
+	if err == nil {
+		t.Fatalf("search of a tripped space allowed")
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("rejection without Retry-After")
+	}
+	if _, err := serve(s, breakerWrite, "bad", http.StatusOK); err != nil {
+		t.Errorf("writes rejected by the search breaker: %v", err)
+	}
+	if _, err := serve(s, breakerSearch, "good", http.StatusOK); err != nil {
+		t.Errorf("other space rejected: %v", err)
+	}
+
+	if n := s.reset("db/bad"); n != 2 {
+		t.Errorf("reset %d breakers, want 2", n)
+	}
+	if _, err := serve(s, breakerSearch, "bad", http.StatusOK); err != nil {
+		t.Errorf("search rejected after reset: %v", err)
+	}
+
+	if err := s.set(&config.BreakerCfg{ErrorRate: 2}); err == nil {
+		t.Errorf("error rate beyond 1 accepted")
+	}
+	if err := s.set(nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := serve(s, breakerSearch, "bad", http.StatusInternalServerError); err != nil {
+			t.Fatalf("request rejected with the breakers off: %v", err)
+		}
+	}
+	if len(s.list()) != 0 {
+		t.Errorf("breakers listed while off")
+	}
+}
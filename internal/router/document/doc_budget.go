@@ -0,0 +1,123 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	// parts of the timeout of a search kept for the stages after the search
+	// of partitions, the time a stage does not use is left to the next one
+	budgetRerankShare  = 0.25
+	budgetHydrateShare = 0.15
+	// an optional stage is skipped if less than this part of its share is
+	// left when it starts
+	budgetMinShare = 0.2
+
+	// the name of hydration in the skipped stages
+	budgetStageHydrate = "hydrate"
+)
+
+// searchBudget splits the timeout of a search among the search of
+// partitions, the rerank stages of the pipeline and hydration, and records
+// the stages skipped as the timeout runs out
+type searchBudget struct {
+	deadline time.Time
+	total    time.Duration
+	rerank   bool
+	hydrate  bool
+	skipped  []string
+}
+
+// newSearchBudget returns the budget of a search with the timeout param, nil
+// if the search has no timeout of its own
+func newSearchBudget(ctx context.Context, head *vearchpb.RequestHead, rerank, hydrate bool) *searchBudget {
+	if head.Params[URLQueryTimeout] == "" {
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	return &searchBudget{deadline: deadline, total: time.Until(deadline), rerank: rerank, hydrate: hydrate}
+}
+
+func (b *searchBudget) share(share float64) time.Duration {
+	return time.Duration(share * float64(b.total))
+}
+
+// fanout returns the context of the search of partitions, its deadline
+// leaves the shares of the later stages and is passed to ps
+func (b *searchBudget) fanout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b == nil {
+		return ctx, func() {}
+	}
+	end := b.deadline
+	if b.rerank {
+		end = end.Add(-b.share(budgetRerankShare))
+	}
+	if b.hydrate {
+		end = end.Add(-b.share(budgetHydrateShare))
+	}
+	ctx = context.WithValue(ctx, entity.RPC_TIME_OUT, end)
+	return context.WithDeadline(ctx, end)
+}
+
+// rerankStage returns the context of the rerank stages of the pipeline, ok is
+// false if they should be skipped
+func (b *searchBudget) rerankStage(ctx context.Context) (stageCtx context.Context, cancel context.CancelFunc, ok bool) {
+	if b == nil {
+		return ctx, func() {}, true
+	}
+	end := b.deadline
+	if b.hydrate {
+		end = end.Add(-b.share(budgetHydrateShare))
+	}
+	return b.stage(ctx, end, budgetRerankShare)
+}
+
+// hydrateStage returns the context of hydration, ok is false if it should be
+// skipped
+func (b *searchBudget) hydrateStage(ctx context.Context) (stageCtx context.Context, cancel context.CancelFunc, ok bool) {
+	if b == nil {
+		return ctx, func() {}, true
+	}
+	return b.stage(ctx, b.deadline, budgetHydrateShare)
+}
+
+func (b *searchBudget) stage(ctx context.Context, end time.Time, share float64) (context.Context, context.CancelFunc, bool) {
+	if time.Until(end) < b.share(budgetMinShare*share) {
+		return ctx, func() {}, false
+	}
+	stageCtx, cancel := context.WithDeadline(ctx, end)
+	return stageCtx, cancel, true
+}
+
+// skip records a stage skipped or cut short by the budget
+func (b *searchBudget) skip(name string) {
+	if b != nil {
+		b.skipped = append(b.skipped, name)
+	}
+}
+
+// degraded reports whether a stage of the search was skipped
+func (b *searchBudget) degraded() bool {
+	return b != nil && len(b.skipped) > 0
+}
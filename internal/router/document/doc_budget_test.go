@@ -0,0 +1,185 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestSearchBudgetStages(t *testing.T) {
+	second := time.Second
+	tests := []struct {
+		name        string
+		left        time.Duration
+		rerank      bool
+		hydrate     bool
+		wantFanout  time.Duration
+		wantRerank  bool
+		wantHydrate bool
+	}{
+		{name: "Rerank and hydrate", left: second, rerank: true, hydrate: true, wantFanout: 600 * time.Millisecond, wantRerank: true, wantHydrate: true},
+		{name: "Rerank only", left: second, rerank: true, wantFanout: 750 * time.Millisecond, wantRerank: true, wantHydrate: true},
+		{name: "Hydrate only", left: second, hydrate: true, wantFanout: 850 * time.Millisecond, wantRerank: true, wantHydrate: true},
+		{name: "Rerank share spent", left: 100 * time.Millisecond, rerank: true, hydrate: true, wantFanout: -300 * time.Millisecond, wantHydrate: true},
+		{name: "All spent", left: 10 * time.Millisecond, rerank: true, hydrate: true, wantFanout: -390 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the search had a second, tt.left of it is left
+			b := &searchBudget{deadline: time.Now().Add(tt.left), total: second, rerank: tt.rerank, hydrate: tt.hydrate}
+			ctx, cancel := b.fanout(context.Background())
+			defer cancel()
+			deadline, _ := ctx.Deadline()
+			if got := deadline.Sub(b.deadline) + tt.left; absDuration(got-tt.wantFanout) > time.Millisecond {
+				t.Errorf("fanout() leaves %v, want %v", got, tt.wantFanout)
+			}
+			if ctx.Value(entity.RPC_TIME_OUT) != deadline {
+				t.Errorf("fanout() passes %v to ps, want %v", ctx.Value(entity.RPC_TIME_OUT), deadline)
+			}
+			_, cancel, ok := b.rerankStage(context.Background())
+			cancel()
+			if ok != tt.wantRerank {
+				t.Errorf("rerankStage() ok = %v, want %v", ok, tt.wantRerank)
+			}
+			_, cancel, ok = b.hydrateStage(context.Background())
+			cancel()
+			if ok != tt.wantHydrate {
+				t.Errorf("hydrateStage() ok = %v, want %v", ok, tt.wantHydrate)
+			}
+		})
+	}
+}
+
+func TestSearchBudgetNil(t *testing.T) {
+	var b *searchBudget
+	if _, _, ok := b.rerankStage(context.Background()); !ok {
+		t.Error("expect rerank to run without budget")
+	}
+	if _, _, ok := b.hydrateStage(context.Background()); !ok {
+		t.Error("expect hydration to run without budget")
+	}
+	b.skip(budgetStageHydrate)
+	if b.degraded() {
+		t.Error("expect a search without budget never degraded")
+	}
+
+	head := &vearchpb.RequestHead{Params: map[string]string{}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if newSearchBudget(ctx, head, true, true) != nil {
+		t.Error("expect no budget without the timeout param")
+	}
+	head.Params[URLQueryTimeout] = "1000"
+	if newSearchBudget(context.Background(), head, true, true) != nil {
+		t.Error("expect no budget without deadline")
+	}
+	if newSearchBudget(ctx, head, true, true) == nil {
+		t.Error("expect a budget of the timeout")
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// stageReranker scores texts by their length and runs out the budget of the
+// search on call cancelAt
+type stageReranker struct {
+	calls    int
+	cancelAt int
+	cancel   context.CancelFunc
+}
+
+func (r *stageReranker) Rerank(ctx context.Context, query string, texts []string) ([]float64, error) {
+	r.calls++
+	if r.calls == r.cancelAt {
+		r.cancel()
+		return nil, ctx.Err()
+	}
+	scores := make([]float64, len(texts))
+	for i, text := range texts {
+		scores[i] = float64(len(text))
+	}
+	return scores, nil
+}
+
+func TestPipelineRunAllOrNothing(t *testing.T) {
+	texts := func(rs []*vearchpb.SearchResult) [][]string {
+		got := make([][]string, len(rs))
+		for i, r := range rs {
+			for _, item := range r.ResultItems {
+				got[i] = append(got[i], string(item.Fields[0].Value))
+			}
+		}
+		return got
+	}
+	tests := []struct {
+		name        string
+		cancelAt    int
+		want        [][]string
+		wantSkipped []string
+	}{
+		{name: "Stage runs", want: [][]string{{"ccc", "bb"}, {"zzz", "yy"}}},
+		{name: "Budget out on first result", cancelAt: 1, want: [][]string{{"a", "bb"}, {"x", "yy"}}, wantSkipped: []string{"cross"}},
+		{name: "Budget out on second result", cancelAt: 2, want: [][]string{{"a", "bb"}, {"x", "yy"}}, wantSkipped: []string{"cross"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			reranker := &stageReranker{cancelAt: tt.cancelAt, cancel: cancel}
+			p := &pipeline{
+				stages: []*entity.PipelineStage{
+					{Name: "retrieve", Type: entity.PipelineRetrieve, Limit: 3},
+					{Name: "cross", Type: entity.PipelineCrossEncoder, TextField: "text", Reranker: "r", Limit: 2},
+				},
+				rerankers: map[string]Reranker{"r": reranker},
+				limit:     2,
+			}
+			var results []*vearchpb.SearchResult
+			for _, ts := range [][]string{{"a", "bb", "ccc"}, {"x", "yy", "zzz"}} {
+				result := &vearchpb.SearchResult{}
+				for _, text := range ts {
+					result.ResultItems = append(result.ResultItems, &vearchpb.ResultItem{
+						Fields: []*vearchpb.Field{{Name: "text", Value: []byte(text)}}})
+				}
+				results = append(results, result)
+			}
+			budget := &searchBudget{deadline: time.Now().Add(time.Hour), total: time.Hour, rerank: true}
+
+			if err := p.run(ctx, results, 0, budget); err != nil {
+				t.Fatal(err)
+			}
+			if got := texts(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("run() results = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(budget.skipped, tt.wantSkipped) {
+				t.Errorf("run() skipped %v, want %v", budget.skipped, tt.wantSkipped)
+			}
+			if skipped := p.explain[1].Skipped; skipped != (tt.wantSkipped != nil) {
+				t.Errorf("explain skipped = %v", skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/algorithm"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	ProjectionPCA = "pca"
+
+	defaultClusterSample     = 1000
+	maxClusterSample         = 10000
+	defaultClusterK          = 8
+	maxClusterK              = 256
+	defaultClusterIterations = 20
+	maxClusterIterations     = 100
+)
+
+// ClusterPoint is a sampled document with its cluster and projection
+type ClusterPoint struct {
+	ID    string   `json:"_id"`
+	Label int      `json:"label"`
+	X     *float32 `json:"x,omitempty"`
+	Y     *float32 `json:"y,omitempty"`
+}
+
+// ClusterResult is the result of /document/cluster
+type ClusterResult struct {
+	Scanned   int             `json:"scanned"`
+	Sampled   int             `json:"sampled"`
+	K         int             `json:"k"`
+	Inertia   float64         `json:"inertia"`
+	Centroids [][]float32     `json:"centroids"`
+	Sizes     []int           `json:"sizes"`
+	Points    []*ClusterPoint `json:"points"`
+}
+
+func (handler *DocumentHandler) handleDocumentCluster(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleDocumentCluster", startTime)
+	clusterReq := &request.ClusterRequest{}
+	if err := c.ShouldBindJSON(clusterReq); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	head, err := setRequestHeadFromGin(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	head.DbName, head.SpaceName = clusterReq.DbName, clusterReq.SpaceName
+	space, err := handler.docService.getSpace(c.Request.Context(), head)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+
+	result, err := handler.cluster(c.Request.Context(), head, space, clusterReq)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(result)
+}
+
+// cluster samples vectors uniformly from the first scan_limit documents of
+// space by reservoir sampling, then runs kmeans and the projection on them
+func (handler *DocumentHandler) cluster(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, clusterReq *request.ClusterRequest) (*ClusterResult, error) {
+	spaceProperties := space.SpaceProperties
+	if spaceProperties == nil {
+		spaceProperties, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	if field := spaceProperties[clusterReq.Field]; field == nil || field.FieldType != vearchpb.FieldType_VECTOR {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] is not vector field of space %s", clusterReq.Field, space.Name))
+	}
+	if space.Index != nil && space.Index.Type == "BINARYIVF" {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("cluster not support binary vector"))
+	}
+	if clusterReq.Projection != "" && clusterReq.Projection != ProjectionPCA {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("projection should be %s or empty", ProjectionPCA))
+	}
+	if clusterReq.Sample <= 0 {
+		clusterReq.Sample = defaultClusterSample
+	}
+	if clusterReq.K <= 0 {
+		clusterReq.K = defaultClusterK
+	}
+	if clusterReq.Iterations <= 0 {
+		clusterReq.Iterations = defaultClusterIterations
+	}
+	if clusterReq.ScanLimit <= 0 {
+		clusterReq.ScanLimit = 10 * clusterReq.Sample
+	}
+	if clusterReq.Sample > maxClusterSample || clusterReq.K > maxClusterK || clusterReq.Iterations > maxClusterIterations {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("sample, k and iterations should not exceed %d, %d and %d", maxClusterSample, maxClusterK, maxClusterIterations))
+	}
+	if clusterReq.ScanLimit < clusterReq.Sample || clusterReq.ScanLimit > maxDedupMaxDocs {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("scan_limit should be in [sample, %d]", maxDedupMaxDocs))
+	}
+
+	r := rand.New(rand.NewSource(clusterReq.Seed))
+	ids := make([]string, 0, clusterReq.Sample)
+	vectors := make([][]float32, 0, clusterReq.Sample)
+	scanned := 0
+	err := handler.scanVectors(ctx, head, space, clusterReq.Field, clusterReq.ScanLimit, func(id string, vector []float32) error {
+		scanned++
+		if len(ids) < clusterReq.Sample {
+			ids, vectors = append(ids, id), append(vectors, vector)
+		} else if i := r.Intn(scanned); i < clusterReq.Sample {
+			ids[i], vectors[i] = id, vector
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &ClusterResult{Scanned: scanned, Sampled: len(ids), Points: make([]*ClusterPoint, len(ids))}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	centroids, labels, inertia := algorithm.KMeans(vectors, clusterReq.K, clusterReq.Iterations, clusterReq.Seed)
+	result.K, result.Inertia, result.Centroids = len(centroids), inertia, centroids
+	result.Sizes = make([]int, len(centroids))
+	for i, id := range ids {
+		result.Sizes[labels[i]]++
+		result.Points[i] = &ClusterPoint{ID: id, Label: labels[i]}
+	}
+	if clusterReq.Projection == ProjectionPCA {
+		for i, p := range algorithm.PCA2D(vectors, clusterReq.Seed) {
+			x, y := p[0], p[1]
+			result.Points[i].X, result.Points[i].Y = &x, &y
+		}
+	}
+	return result, nil
+}
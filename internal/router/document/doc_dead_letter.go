@@ -0,0 +1,234 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// deadLetters collects the documents a write to space rejects, it is nil if
+// the space has no dead letter and the write fails instead
+type deadLetters struct {
+	dbName  string
+	space   *entity.Space
+	records []*entity.DeadLetterRecord
+}
+
+func newDeadLetters(dbName string, space *entity.Space) *deadLetters {
+	if space.DeadLetter == nil {
+		return nil
+	}
+	return &deadLetters{dbName: dbName, space: space}
+}
+
+// add keeps a rejected document, it returns false if there is no dead letter
+func (d *deadLetters) add(doc json.RawMessage, stage string, err error) bool {
+	if d == nil {
+		return false
+	}
+	d.records = append(d.records, &entity.DeadLetterRecord{
+		DbName:    d.dbName,
+		SpaceName: d.space.Name,
+		Stage:     stage,
+		Error:     err.Error(),
+		Document:  string(doc),
+		Time:      time.Now().Unix(),
+	})
+	return true
+}
+
+func (d *deadLetters) len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.records)
+}
+
+// flushDeadLetters sends the records kept to the dead letter of space and
+// forgets them, they are kept if it fails
+func (handler *DocumentHandler) flushDeadLetters(ctx context.Context, r *http.Request, head *vearchpb.RequestHead, d *deadLetters) error {
+	if d.len() == 0 {
+		return nil
+	}
+	if err := handler.sendDeadLetters(ctx, r, head, d.space.DeadLetter, d.records); err != nil {
+		return err
+	}
+	d.records = nil
+	return nil
+}
+
+func (handler *DocumentHandler) sendDeadLetters(ctx context.Context, r *http.Request, head *vearchpb.RequestHead, target *entity.DeadLetter, records []*entity.DeadLetterRecord) error {
+	if target.URL != "" {
+		return postDeadLetters(ctx, target, records)
+	}
+
+	args := &vearchpb.BulkRequest{Head: &vearchpb.RequestHead{DbName: head.DbName, SpaceName: target.Space, Params: head.Params}}
+	space, err := handler.docService.getSpace(ctx, args.Head)
+	if err != nil {
+		return err
+	}
+	docRequest := &request.DocumentRequest{DbName: head.DbName, SpaceName: target.Space}
+	for _, record := range records {
+		doc, err := deadLetterDocument(space, record)
+		if err != nil {
+			return err
+		}
+		docRequest.Documents = append(docRequest.Documents, doc)
+	}
+	if err := documentParse(ctx, handler, r, docRequest, space, args); err != nil {
+		return err
+	}
+	reply := handler.docService.bulk(ctx, args)
+	if _, err := documentUpsertResponse(reply); err != nil {
+		return err
+	}
+	for _, item := range reply.Items {
+		if !itemSucceeded(item) {
+			if item == nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("write dead letter to space %s err: no reply", target.Space))
+			}
+			return vearchpb.NewError(item.Err.Code, fmt.Errorf("write dead letter to space %s err: %s", target.Space, item.Err.Msg))
+		}
+	}
+	return nil
+}
+
+// deadLetterDocument is the document of a record in a dead letter space, its
+// vector fields are zero
+func deadLetterDocument(space *entity.Space, record *entity.DeadLetterRecord) (json.RawMessage, error) {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	doc := map[string]interface{}{
+		entity.DeadLetterFieldDocument: record.Document,
+		entity.DeadLetterFieldError:    record.Error,
+	}
+	if proMap[entity.DeadLetterFieldSource] != nil {
+		doc[entity.DeadLetterFieldSource] = record.DbName + "/" + record.SpaceName
+	}
+	if proMap[entity.DeadLetterFieldStage] != nil {
+		doc[entity.DeadLetterFieldStage] = record.Stage
+	}
+	if proMap[entity.DeadLetterFieldTime] != nil {
+		doc[entity.DeadLetterFieldTime] = record.Time
+	}
+	for name, pro := range proMap {
+		if pro.FieldType != vearchpb.FieldType_VECTOR {
+			continue
+		}
+		dimension := pro.Dimension
+		if space.Index != nil && space.Index.Type == "BINARYIVF" {
+			dimension /= 8
+		}
+		doc[name] = make([]float32, dimension)
+	}
+	return json.Marshal(doc)
+}
+
+func postDeadLetters(ctx context.Context, target *entity.DeadLetter, records []*entity.DeadLetterRecord) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	timeout := target.Timeout
+	if timeout <= 0 {
+		timeout = entity.DefaultDeadLetterTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post dead letters to %s err: %v", target.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("post dead letters to %s status %d: %s", target.URL, resp.StatusCode, string(msg))
+	}
+	return nil
+}
+
+func itemSucceeded(item *vearchpb.Item) bool {
+	return item != nil && (item.Err == nil || (item.Err.Msg == "success" && item.Err.Code == vearchpb.ErrorEnum_SUCCESS))
+}
+
+// parseDocuments parses the documents to write, with a dead letter the
+// documents failing alone are kept for it and the others are written
+func (handler *DocumentHandler) parseDocuments(ctx context.Context, r *http.Request, docRequest *request.DocumentRequest, space *entity.Space, args *vearchpb.BulkRequest, d *deadLetters) error {
+	err := documentParse(ctx, handler, r, docRequest, space, args)
+	if err == nil || d == nil || len(docRequest.Documents) == 0 {
+		return err
+	}
+	kept := make([]json.RawMessage, 0, len(docRequest.Documents))
+	docs := make([]*vearchpb.Document, 0, len(docRequest.Documents))
+	for _, raw := range docRequest.Documents {
+		one := &request.DocumentRequest{Documents: []json.RawMessage{raw}, DbName: docRequest.DbName, SpaceName: docRequest.SpaceName, Partitions: docRequest.Partitions}
+		oneArgs := &vearchpb.BulkRequest{Head: args.Head}
+		if err := documentParse(ctx, handler, r, one, space, oneArgs); err != nil {
+			d.add(raw, entity.DeadLetterStageParse, err)
+			continue
+		}
+		kept = append(kept, raw)
+		docs = append(docs, oneArgs.Docs...)
+		args.Partitions = oneArgs.Partitions
+	}
+	docRequest.Documents = kept
+	args.Docs = docs
+	return nil
+}
+
+// deadLetterRejected keeps the documents ps rejected, args.Docs have their
+// final keys after the bulk and are in the order of the documents
+func deadLetterRejected(docRequest *request.DocumentRequest, args *vearchpb.BulkRequest, reply *vearchpb.BulkResponse, d *deadLetters) {
+	if d == nil || len(args.Docs) != len(docRequest.Documents) {
+		return
+	}
+	byKey := make(map[string]json.RawMessage, len(args.Docs))
+	for i, doc := range args.Docs {
+		byKey[doc.PKey] = docRequest.Documents[i]
+	}
+	for _, item := range reply.Items {
+		if item == nil || item.Doc == nil || itemSucceeded(item) {
+			continue
+		}
+		if raw, ok := byKey[item.Doc.PKey]; ok {
+			d.add(raw, entity.DeadLetterStagePS, fmt.Errorf("%s", item.Err.Msg))
+		}
+	}
+}
+
+// logDeadLetters logs the records that could not be sent so they are not lost
+func logDeadLetters(d *deadLetters, err error) {
+	for _, record := range d.records {
+		log.Errorf("dead letter of %s/%s not sent, err: %v, record: %s, %s", record.DbName, record.SpaceName, err, record.Error, record.Document)
+	}
+}
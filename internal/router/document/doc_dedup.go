@@ -0,0 +1,505 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	DedupActionReport = "report"
+	DedupActionDelete = "delete"
+	DedupActionTag    = "tag"
+
+	defaultDedupMaxDocs   = 10000
+	maxDedupMaxDocs       = 1000000
+	defaultDedupNeighbors = 10
+	maxDedupNeighbors     = 100
+	maxDedupClusters      = 1000 // clusters returned in result
+
+	dedupScanBatch   = 64
+	dedupSearchBatch = 32
+	dedupWriteBatch  = 100
+)
+
+// DedupCluster is a group of near duplicate documents
+type DedupCluster struct {
+	Representative string   `json:"representative"`
+	Duplicates     []string `json:"duplicates"`
+}
+
+// DedupResult is the result of /document/dedup, clusters are the largest
+// ones if there are too many
+type DedupResult struct {
+	Scanned    int             `json:"scanned"`
+	Clusters   int             `json:"clusters"`
+	Duplicates int             `json:"duplicates"`
+	Action     string          `json:"action"`
+	Affected   int             `json:"affected"`
+	Groups     []*DedupCluster `json:"groups"`
+}
+
+// dedupSet is a union find of document ids, the root of a set is the id
+// seen first so the representative is stable for a scan
+type dedupSet struct {
+	parent map[string]string
+	order  map[string]int
+}
+
+func newDedupSet() *dedupSet {
+	return &dedupSet{parent: make(map[string]string), order: make(map[string]int)}
+}
+
+func (s *dedupSet) add(id string) {
+	if _, ok := s.parent[id]; !ok {
+		s.parent[id] = id
+		s.order[id] = len(s.order)
+	}
+}
+
+func (s *dedupSet) find(id string) string {
+	for s.parent[id] != id {
+		s.parent[id] = s.parent[s.parent[id]]
+		id = s.parent[id]
+	}
+	return id
+}
+
+func (s *dedupSet) union(a, b string) {
+	s.add(a)
+	s.add(b)
+	ra, rb := s.find(a), s.find(b)
+	if ra == rb {
+		return
+	}
+	if s.order[rb] < s.order[ra] {
+		ra, rb = rb, ra
+	}
+	s.parent[rb] = ra
+}
+
+// clusters returns the sets with more than one id, largest first
+func (s *dedupSet) clusters() []*DedupCluster {
+	groups := make(map[string]*DedupCluster)
+	for id := range s.parent {
+		root := s.find(id)
+		if root == id {
+			continue
+		}
+		g := groups[root]
+		if g == nil {
+			g = &DedupCluster{Representative: root}
+			groups[root] = g
+		}
+		g.Duplicates = append(g.Duplicates, id)
+	}
+	clusters := make([]*DedupCluster, 0, len(groups))
+	for _, g := range groups {
+		sort.Slice(g.Duplicates, func(i, j int) bool { return s.order[g.Duplicates[i]] < s.order[g.Duplicates[j]] })
+		clusters = append(clusters, g)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Duplicates) != len(clusters[j].Duplicates) {
+			return len(clusters[i].Duplicates) > len(clusters[j].Duplicates)
+		}
+		return s.order[clusters[i].Representative] < s.order[clusters[j].Representative]
+	})
+	return clusters
+}
+
+func (handler *DocumentHandler) handleDocumentDedup(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleDocumentDedup", startTime)
+	dedupReq := &request.DedupRequest{}
+	if err := c.ShouldBindJSON(dedupReq); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	head, err := setRequestHeadFromGin(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	head.DbName, head.SpaceName = dedupReq.DbName, dedupReq.SpaceName
+	space, err := handler.docService.getSpace(c.Request.Context(), head)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	dedupReq.SpaceName = head.SpaceName
+
+	result, err := handler.dedup(c.Request.Context(), head, space, dedupReq)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	log.Info("dedup %s/%s scanned %d docs, %d duplicates in %d clusters, %s %d docs", dedupReq.DbName, dedupReq.SpaceName,
+		result.Scanned, result.Duplicates, result.Clusters, result.Action, result.Affected)
+	response.New(c).JsonSuccess(result)
+}
+
+// dedup scans the vectors of space and searches the neighbors of each, the
+// documents within epsilon are near duplicates. Epsilon is the max distance
+// for L2 and the max 1 - score for InnerProduct.
+func (handler *DocumentHandler) dedup(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, dedupReq *request.DedupRequest) (*DedupResult, error) {
+	spaceProperties := space.SpaceProperties
+	if spaceProperties == nil {
+		spaceProperties, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	field := spaceProperties[dedupReq.Field]
+	if field == nil || field.FieldType != vearchpb.FieldType_VECTOR {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] is not vector field of space %s", dedupReq.Field, space.Name))
+	}
+	if space.Index != nil && space.Index.Type == "BINARYIVF" {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dedup not support binary vector"))
+	}
+	if dedupReq.Epsilon < 0 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("epsilon should not be negative"))
+	}
+	if dedupReq.Action == "" {
+		dedupReq.Action = DedupActionReport
+	}
+	switch dedupReq.Action {
+	case DedupActionReport, DedupActionDelete:
+	case DedupActionTag:
+		tag := spaceProperties[dedupReq.TagField]
+		if tag == nil || tag.FieldType != vearchpb.FieldType_STRING {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("tag_field [%s] should be string field of space %s", dedupReq.TagField, space.Name))
+		}
+	default:
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("action should be %s, %s or %s", DedupActionReport, DedupActionDelete, DedupActionTag))
+	}
+	if dedupReq.MaxDocs <= 0 {
+		dedupReq.MaxDocs = defaultDedupMaxDocs
+	}
+	if dedupReq.MaxDocs > maxDedupMaxDocs {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("max_docs should not exceed %d", maxDedupMaxDocs))
+	}
+	if dedupReq.Neighbors <= 0 {
+		dedupReq.Neighbors = defaultDedupNeighbors
+	}
+	if dedupReq.Neighbors > maxDedupNeighbors {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("neighbors should not exceed %d", maxDedupNeighbors))
+	}
+
+	metric := vectorMetricType(field)
+	near := func(score float64) bool {
+		if metric == "L2" {
+			return score <= dedupReq.Epsilon
+		}
+		return 1-score <= dedupReq.Epsilon
+	}
+
+	set := newDedupSet()
+	result := &DedupResult{Action: dedupReq.Action}
+	ids := make([]string, 0, dedupSearchBatch)
+	features := make([]float32, 0, dedupSearchBatch*field.Dimension)
+	flush := func() error {
+		if len(ids) == 0 {
+			return nil
+		}
+		neighbors, err := handler.dedupSearch(ctx, space, dedupReq, features)
+		if err != nil {
+			return err
+		}
+		for i, items := range neighbors {
+			if i >= len(ids) {
+				break
+			}
+			for _, item := range items {
+				if item.PKey != ids[i] && near(item.Score) {
+					set.union(ids[i], item.PKey)
+				}
+			}
+		}
+		ids, features = ids[:0], features[:0]
+		return nil
+	}
+
+	err := handler.scanVectors(ctx, head, space, dedupReq.Field, dedupReq.MaxDocs, func(id string, vector []float32) error {
+		result.Scanned++
+		set.add(id)
+		ids = append(ids, id)
+		features = append(features, vector...)
+		if len(ids) >= dedupSearchBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := set.clusters()
+	result.Clusters = len(clusters)
+	for _, c := range clusters {
+		result.Duplicates += len(c.Duplicates)
+	}
+	if len(clusters) > maxDedupClusters {
+		result.Groups = clusters[:maxDedupClusters]
+	} else {
+		result.Groups = clusters
+	}
+
+	if dedupReq.Action != DedupActionReport {
+		affected, err := handler.dedupApply(ctx, head, spaceProperties, dedupReq, clusters)
+		result.Affected = affected
+		if err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("%s duplicates err after %d docs: %v", dedupReq.Action, affected, err))
+		}
+	}
+	return result, nil
+}
+
+// vectorMetricType returns the metric type of the index of a vector field
+func vectorMetricType(field *entity.SpaceProperties) string {
+	if field.Index != nil && len(field.Index.Params) > 0 {
+		indexParams := &entity.IndexParams{}
+		if err := json.Unmarshal(field.Index.Params, indexParams); err == nil && indexParams.MetricType != "" {
+			return indexParams.MetricType
+		}
+	}
+	return entity.DefaultMetricType
+}
+
+// scanVectors calls fn with the id and vector of every document of space in
+// the order of partition and docid, at most maxDocs documents
+func (handler *DocumentHandler) scanVectors(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, field string, maxDocs int, fn func(id string, vector []float32) error) error {
+	scanned := 0
+	for _, partition := range space.Partitions {
+		if scanned >= maxDocs {
+			break
+		}
+		err := handler.scanPartition(ctx, head, partition.Id, func(fields []*vearchpb.Field) (bool, error) {
+			var (
+				id     string
+				vector []float32
+				err    error
+			)
+			for _, fv := range fields {
+				switch fv.Name {
+				case entity.IdField:
+					id = string(fv.Value)
+				case field:
+					if vector, err = cbbytes.ByteToVectorForFloat32(fv.Value); err != nil {
+						return false, err
+					}
+				}
+			}
+			if id == "" || vector == nil {
+				return true, nil
+			}
+			scanned++
+			if err := fn(id, vector); err != nil {
+				return false, err
+			}
+			return scanned < maxDocs, nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, fmt.Errorf("dedup is not finished after %d docs, use less max_docs or larger timeout: %v", scanned, err))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// scanPartition calls fn with the fields of every document of partition in
+// the order of docid, until fn returns false
+func (handler *DocumentHandler) scanPartition(ctx context.Context, head *vearchpb.RequestHead, partitionID entity.PartitionID, fn func(fields []*vearchpb.Field) (bool, error)) error {
+	_, err := scanDocs(ctx, -1, dedupScanBatch, handler.partitionGetter(ctx, head, partitionID), fn)
+	return err
+}
+
+// partitionGetter returns the get of scanDocs for partition
+func (handler *DocumentHandler) partitionGetter(ctx context.Context, head *vearchpb.RequestHead, partitionID entity.PartitionID) func(keys []string) *vearchpb.GetResponse {
+	next := true
+	return func(keys []string) *vearchpb.GetResponse {
+		args := &vearchpb.GetRequest{
+			Head:        &vearchpb.RequestHead{DbName: head.DbName, SpaceName: head.SpaceName, Params: head.Params},
+			PrimaryKeys: keys,
+		}
+		return handler.docService.getDocsByPartition(ctx, args, partitionID, &next)
+	}
+}
+
+// scanDocs pages through the docids of a partition after docid by get, which
+// returns the next doc of each docid key, batch keys a time, and calls fn with
+// each doc once in order. It returns the docid of the last doc passed to fn,
+// which is docid if there is none, for the next scan to go on from
+func scanDocs(ctx context.Context, docid int32, batch int, get func(keys []string) *vearchpb.GetResponse, fn func(fields []*vearchpb.Field) (bool, error)) (int32, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return docid, vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, err)
+		}
+		// the next doc of each key, keys of deleted docs return the same doc
+		keys := make([]string, batch)
+		for i := range keys {
+			keys[i] = strconv.Itoa(int(docid) + i)
+		}
+		reply := get(keys)
+		if reply.Head != nil && reply.Head.Err != nil && reply.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+			return docid, vearchpb.NewError(reply.Head.Err.Code, fmt.Errorf("%s", reply.Head.Err.Msg))
+		}
+
+		last := docid
+		seen := make(map[int32]bool, len(reply.Items))
+		for _, item := range reply.Items {
+			if item == nil || item.Doc == nil || len(item.Doc.Fields) == 0 {
+				continue
+			}
+			current := int32(-1)
+			for _, fv := range item.Doc.Fields {
+				if fv.Name == "_docid" {
+					current = cbbytes.Bytes2Int32(fv.Value)
+					break
+				}
+			}
+			if current <= docid || seen[current] {
+				continue
+			}
+			seen[current] = true
+			if current > last {
+				last = current
+			}
+			more, err := fn(item.Doc.Fields)
+			if err != nil {
+				return docid, err
+			}
+			if !more {
+				return last, nil
+			}
+		}
+		if last == docid {
+			return docid, nil
+		}
+		docid = last
+	}
+}
+
+// dedupSearch returns the neighbors of each vector of features
+func (handler *DocumentHandler) dedupSearch(ctx context.Context, space *entity.Space, dedupReq *request.DedupRequest, features []float32) ([][]*vearchpb.ResultItem, error) {
+	feature, err := json.Marshal(map[string]interface{}{"field": dedupReq.Field, "feature": features})
+	if err != nil {
+		return nil, err
+	}
+	searchDoc := &request.SearchDocumentRequest{
+		DbName:      dedupReq.DbName,
+		SpaceName:   dedupReq.SpaceName,
+		Limit:       dedupReq.Neighbors + 1,
+		Fields:      []string{entity.IdField},
+		Vectors:     []json.RawMessage{feature},
+		IndexParams: dedupReq.IndexParams,
+	}
+	searchReq := &vearchpb.SearchRequest{
+		Head: &vearchpb.RequestHead{DbName: dedupReq.DbName, SpaceName: dedupReq.SpaceName, Params: make(map[string]string)},
+	}
+	if err := requestToPb(searchDoc, space, searchReq); err != nil {
+		return nil, err
+	}
+	searchResp := handler.docService.search(ctx, searchReq)
+	if head := searchResp.Head; head != nil && head.Err != nil && head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(head.Err.Code, fmt.Errorf("%s", head.Err.Msg))
+	}
+	neighbors := make([][]*vearchpb.ResultItem, len(searchResp.Results))
+	for i, sr := range searchResp.Results {
+		neighbors[i] = sr.ResultItems
+	}
+	return neighbors, nil
+}
+
+// dedupApply deletes the duplicates, or sets the tag field of them to the id
+// of the representative, returns the number of documents changed
+func (handler *DocumentHandler) dedupApply(ctx context.Context, head *vearchpb.RequestHead, spaceProperties map[string]*entity.SpaceProperties, dedupReq *request.DedupRequest, clusters []*DedupCluster) (int, error) {
+	newHead := func() *vearchpb.RequestHead {
+		return &vearchpb.RequestHead{DbName: head.DbName, SpaceName: head.SpaceName, Params: head.Params}
+	}
+	affected := 0
+	if dedupReq.Action == DedupActionDelete {
+		keys := make([]string, 0, dedupWriteBatch)
+		deleteKeys := func() error {
+			if len(keys) == 0 {
+				return nil
+			}
+			reply := handler.docService.deleteDocs(ctx, &vearchpb.DeleteRequest{Head: newHead(), PrimaryKeys: keys})
+			if reply.Head != nil && reply.Head.Err != nil && reply.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+				return vearchpb.NewError(reply.Head.Err.Code, fmt.Errorf("%s", reply.Head.Err.Msg))
+			}
+			affected += len(keys)
+			keys = keys[:0]
+			return nil
+		}
+		for _, c := range clusters {
+			for _, id := range c.Duplicates {
+				keys = append(keys, id)
+				if len(keys) >= dedupWriteBatch {
+					if err := deleteKeys(); err != nil {
+						return affected, err
+					}
+				}
+			}
+		}
+		return affected, deleteKeys()
+	}
+
+	tag := spaceProperties[dedupReq.TagField]
+	docs := make([]*vearchpb.Document, 0, dedupWriteBatch)
+	upsertDocs := func() error {
+		if len(docs) == 0 {
+			return nil
+		}
+		reply := handler.docService.bulk(ctx, &vearchpb.BulkRequest{Head: newHead(), Docs: docs})
+		if reply.Head != nil && reply.Head.Err != nil && reply.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+			return vearchpb.NewError(reply.Head.Err.Code, fmt.Errorf("%s", reply.Head.Err.Msg))
+		}
+		affected += len(docs)
+		docs = docs[:0]
+		return nil
+	}
+	for _, c := range clusters {
+		for _, id := range c.Duplicates {
+			f, err := processString(tag, dedupReq.TagField, c.Representative)
+			if err != nil {
+				return affected, err
+			}
+			docs = append(docs, &vearchpb.Document{PKey: id, Fields: []*vearchpb.Field{f}})
+			if len(docs) >= dedupWriteBatch {
+				if err := upsertDocs(); err != nil {
+					return affected, err
+				}
+			}
+		}
+	}
+	return affected, upsertDocs()
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupSet_clusters(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs [][2]string
+		want  []*DedupCluster
+	}{
+		{
+			name:  "No duplicates",
+			pairs: nil,
+			want:  []*DedupCluster{},
+		},
+		{
+			name:  "Representative is the id seen first",
+			pairs: [][2]string{{"b", "a"}, {"c", "a"}},
+			want:  []*DedupCluster{{Representative: "b", Duplicates: []string{"a", "c"}}},
+		},
+		{
+			name:  "Transitive duplicates are merged",
+			pairs: [][2]string{{"a", "b"}, {"c", "d"}, {"b", "d"}},
+			want:  []*DedupCluster{{Representative: "a", Duplicates: []string{"b", "c", "d"}}},
+		},
+		{
+			name:  "Largest cluster first",
+			pairs: [][2]string{{"a", "b"}, {"x", "y"}, {"x", "z"}},
+			want: []*DedupCluster{
+				{Representative: "x", Duplicates: []string{"y", "z"}},
+				{Representative: "a", Duplicates: []string{"b"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newDedupSet()
+			for _, p := range tt.pairs {
+				s.union(p[0], p[1])
+			}
+			if got := s.clusters(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clusters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// degradedPaths are served while the router runs on a cache snapshot
+var degradedPaths = map[string]bool{
+	"/document/search": true,
+	"/document/query":  true,
+	"/readyz":          true,
+}
+
+// snapshotTime returns when the cache snapshot the router serves from was
+// saved, zero if the router is not degraded
+func (handler *DocumentHandler) snapshotTime() time.Time {
+	cache := handler.client.Master().Cache()
+	if cache == nil {
+		return time.Time{}
+	}
+	return cache.SnapshotTime()
+}
+
+// degradedMiddleware rejects everything but searches and queries while the
+// meta is a snapshot, writes and master requests need etcd
+func (handler *DocumentHandler) degradedMiddleware(c *gin.Context) {
+	if handler.snapshotTime().IsZero() || degradedPaths[c.Request.URL.Path] {
+		c.Next()
+		return
+	}
+	err := vearchpb.NewError(vearchpb.ErrorEnum_SERVICE_UNAVAILABLE, fmt.Errorf("router is in degraded read only mode, etcd is unreachable"))
+	response.New(c).JsonError(errors.NewErrUnavailable(err))
+	c.Abort()
+}
+
+// handleReadyz reports 503 with the snapshot time while degraded
+func (handler *DocumentHandler) handleReadyz(c *gin.Context) {
+	snapshotTime := handler.snapshotTime()
+	if snapshotTime.IsZero() {
+		response.New(c).JsonSuccess(map[string]interface{}{"status": "ready"})
+		return
+	}
+	reply := &response.HttpReply{
+		Code:      int(vearchpb.ErrorEnum_SERVICE_UNAVAILABLE),
+		RequestId: c.GetHeader("X-Request-Id"),
+		Msg:       "etcd is unreachable, serving searches and queries from the cache snapshot",
+		Data: map[string]interface{}{
+			"status":        "degraded",
+			"snapshot_time": snapshotTime.Format(time.RFC3339),
+			"snapshot_age":  time.Since(snapshotTime).Round(time.Second).String(),
+		},
+	}
+	response.New(c).SetHttpStatus(http.StatusServiceUnavailable).SendJson(reply)
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// prepareDither checks the dither of a search and fixes its seed, binary
+// vectors can not be dithered
+func prepareDither(searchDoc *request.SearchDocumentRequest, space *entity.Space) error {
+	dither := searchDoc.Dither
+	if dither == nil {
+		return nil
+	}
+	if math.IsNaN(dither.Noise) || dither.Noise <= 0 || dither.Noise > 1 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dither noise should be in (0, 1]"))
+	}
+	if space.Index != nil && space.Index.Type == "BINARYIVF" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dither does not work with binary vectors"))
+	}
+	if dither.Seed == nil {
+		seed := time.Now().UnixNano()
+		dither.Seed = &seed
+	}
+	return nil
+}
+
+// ditherVectors adds the noise of dither to every query vector of req
+func ditherVectors(dither *request.Dither, space *entity.Space, req *vearchpb.SearchRequest) error {
+	if dither == nil {
+		return nil
+	}
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	rng := rand.New(rand.NewSource(*dither.Seed))
+	for _, vq := range req.VecFields {
+		pro := proMap[vq.Name]
+		if pro == nil || pro.Dimension <= 0 || len(vq.Value)%(pro.Dimension*4) != 0 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dither does not work with the vectors of field %s", vq.Name))
+		}
+		values, err := cbbytes.ByteToFloat32Array(vq.Value)
+		if err != nil {
+			return err
+		}
+		d := pro.Dimension
+		for i := 0; i < len(values); i += d {
+			vector := values[i : i+d]
+			norm := 0.0
+			for _, x := range vector {
+				norm += float64(x) * float64(x)
+			}
+			stddev := dither.Noise * math.Sqrt(norm/float64(d))
+			for j := range vector {
+				vector[j] += float32(rng.NormFloat64() * stddev)
+			}
+		}
+		if vq.Value, err = cbbytes.FloatArrayByte(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
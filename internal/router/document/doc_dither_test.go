@@ -0,0 +1,102 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestPrepareDither(t *testing.T) {
+	space := &entity.Space{Index: &entity.Index{Type: "HNSW"}}
+	tests := []struct {
+		name    string
+		space   *entity.Space
+		dither  *request.Dither
+		wantErr bool
+	}{
+		{name: "No dither", space: space},
+		{name: "Seed set", space: space, dither: &request.Dither{Noise: 0.01}},
+		{name: "Zero noise", space: space, dither: &request.Dither{}, wantErr: true},
+		{name: "Noise above one", space: space, dither: &request.Dither{Noise: 2}, wantErr: true},
+		{name: "Binary vectors", space: &entity.Space{Index: &entity.Index{Type: "BINARYIVF"}}, dither: &request.Dither{Noise: 0.01}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searchDoc := &request.SearchDocumentRequest{Dither: tt.dither}
+			err := prepareDither(searchDoc, tt.space)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prepareDither() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.dither != nil && tt.dither.Seed == nil {
+				t.Error("prepareDither() left seed unset")
+			}
+		})
+	}
+}
+
+func TestDitherVectors(t *testing.T) {
+	space := &entity.Space{SpaceProperties: map[string]*entity.SpaceProperties{
+		"vec": {FieldType: vearchpb.FieldType_VECTOR, Dimension: 4},
+	}}
+	query := []float32{1, 2, 3, 4, -1, -2, -3, -4}
+	search := func(seed int64) []float32 {
+		value, err := cbbytes.FloatArrayByte(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := &vearchpb.SearchRequest{VecFields: []*vearchpb.VectorQuery{{Name: "vec", Value: value}}}
+		if err := ditherVectors(&request.Dither{Noise: 0.01, Seed: &seed}, space, req); err != nil {
+			t.Fatal(err)
+		}
+		values, err := cbbytes.ByteToFloat32Array(req.VecFields[0].Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+
+	a, b, c := search(1), search(1), search(2)
+	changed := false
+	for i := range query {
+		if a[i] != b[i] {
+			t.Fatalf("same seed dithered %v and %v", a, b)
+		}
+		if a[i] != query[i] {
+			changed = true
+		}
+		// 0.01 of the norm per dimension stays far below 0.5
+		if math.Abs(float64(a[i]-query[i])) > 0.5 {
+			t.Errorf("dimension %d moved from %v to %v", i, query[i], a[i])
+		}
+	}
+	if !changed {
+		t.Error("ditherVectors() left the vectors unchanged")
+	}
+	if a[0] == c[0] && a[1] == c[1] {
+		t.Errorf("seeds 1 and 2 dithered alike %v", a)
+	}
+
+	req := &vearchpb.SearchRequest{VecFields: []*vearchpb.VectorQuery{{Name: "vec", Value: []byte{1, 2, 3}}}}
+	seed := int64(1)
+	if err := ditherVectors(&request.Dither{Noise: 0.01, Seed: &seed}, space, req); err == nil {
+		t.Error("ditherVectors() of binary vectors should fail")
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// maxEchoSize caps the echo of a search, it is returned untouched so
+// clients multiplexing searches can correlate the responses
+const maxEchoSize = 4096
+
+func checkEcho(echo json.RawMessage) error {
+	if len(echo) > maxEchoSize {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("echo should have at most %d bytes, got %d", maxEchoSize, len(echo)))
+	}
+	return nil
+}
+
+// annotateResponse adds the echo of the search and the annotations of
+// space to its response, space is nil for the searches of many spaces
+func annotateResponse(result map[string]interface{}, echo json.RawMessage, space *entity.Space) {
+	if len(echo) > 0 {
+		result["echo"] = echo
+	}
+	if space != nil && len(space.Annotations) > 0 {
+		result["annotations"] = space.Annotations
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func TestAnnotateResponse(t *testing.T) {
+	annotated := &entity.Space{Annotations: map[string]string{"model_version": "v2"}}
+	tests := []struct {
+		name    string
+		echo    json.RawMessage
+		space   *entity.Space
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "Nothing", space: &entity.Space{}, want: map[string]interface{}{}},
+		{name: "Echo", echo: json.RawMessage(`{"id":7}`), space: &entity.Space{}, want: map[string]interface{}{"echo": json.RawMessage(`{"id":7}`)}},
+		{name: "Annotations", space: annotated, want: map[string]interface{}{"annotations": annotated.Annotations}},
+		{name: "Federated", echo: json.RawMessage(`"q-1"`), want: map[string]interface{}{"echo": json.RawMessage(`"q-1"`)}},
+		{name: "Echo too large", echo: json.RawMessage(`"` + strings.Repeat("x", maxEchoSize) + `"`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkEcho(tt.echo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkEcho() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			result := map[string]interface{}{}
+			annotateResponse(result, tt.echo, tt.space)
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("annotateResponse() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+)
+
+const (
+	EmbedderHTTP = "http"
+
+	defaultEmbedTimeout = 10000 // ms
+)
+
+// Embedder computes the vectors of texts, one vector for each text in order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedderFactory builds an embedder from its router config
+type EmbedderFactory func(cfg *config.EmbedderCfg) (Embedder, error)
+
+var (
+	embeddersMu sync.RWMutex
+	embedders   = map[string]EmbedderFactory{EmbedderHTTP: newHTTPEmbedder}
+)
+
+// RegisterEmbedder makes an embedder usable as plugin in router embedder
+// config, it should be called before the router starts
+func RegisterEmbedder(name string, factory EmbedderFactory) {
+	embeddersMu.Lock()
+	defer embeddersMu.Unlock()
+	embedders[name] = factory
+}
+
+// newEmbedders builds the embedders of router config by name
+func newEmbedders(cfgs []*config.EmbedderCfg) (map[string]Embedder, error) {
+	result := make(map[string]Embedder, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("embedder name should not be empty")
+		}
+		if _, ok := result[cfg.Name]; ok {
+			return nil, fmt.Errorf("embedder [%s] is duplicate", cfg.Name)
+		}
+		if cfg.Plugin == "" {
+			cfg.Plugin = EmbedderHTTP
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = defaultEmbedTimeout
+		}
+		embeddersMu.RLock()
+		factory := embedders[cfg.Plugin]
+		embeddersMu.RUnlock()
+		if factory == nil {
+			return nil, fmt.Errorf("embedder plugin [%s] not registered", cfg.Plugin)
+		}
+		embedder, err := factory(cfg)
+		if err != nil {
+			return nil, err
+		}
+		result[cfg.Name] = embedder
+	}
+	return result, nil
+}
+
+// httpEmbedder posts the texts to an openai compatible embeddings api
+type httpEmbedder struct {
+	url     string
+	model   string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPEmbedder(cfg *config.EmbedderCfg) (Embedder, error) {
+	if cfg.Url == "" {
+		return nil, fmt.Errorf("embedder [%s] url should not be empty", cfg.Name)
+	}
+	return &httpEmbedder{url: cfg.Url, model: cfg.Model, headers: cfg.Headers,
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Millisecond}}, nil
+}
+
+func (e *httpEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embed status %d: %s", resp.StatusCode, string(msg))
+	}
+	result := &struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("embed %d texts but got %d vectors", len(texts), len(result.Data))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) || vectors[d.Index] != nil {
+			return nil, fmt.Errorf("embed got invalid index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/algorithm"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	maxEvaluateQueries = 1000
+
+	GroundTruthBruteForce = "brute_force"
+	GroundTruthSupplied   = "supplied"
+)
+
+// LatencySummary is the latency distribution of searches in milliseconds
+type LatencySummary struct {
+	Avg float64 `json:"avg_ms"`
+	P50 float64 `json:"p50_ms"`
+	P90 float64 `json:"p90_ms"`
+	P99 float64 `json:"p99_ms"`
+	Max float64 `json:"max_ms"`
+}
+
+// EvaluateResult is the recall and latency of the index params of a space
+type EvaluateResult struct {
+	Queries           int             `json:"queries"`
+	Limit             int32           `json:"limit"`
+	IndexParams       json.RawMessage `json:"index_params,omitempty"`
+	GroundTruth       string          `json:"ground_truth"`
+	Recall            float64         `json:"recall"`
+	MinRecall         float64         `json:"min_recall"`
+	Latency           *LatencySummary `json:"latency"`
+	BruteForceLatency *LatencySummary `json:"brute_force_latency,omitempty"`
+}
+
+func summarizeLatency(latencies []time.Duration) *LatencySummary {
+	if len(latencies) == 0 {
+		return &LatencySummary{}
+	}
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	at := func(p float64) float64 { return ms(algorithm.Percentile(sorted, p)) }
+	var sum time.Duration
+	for _, l := range sorted {
+		sum += l
+	}
+	return &LatencySummary{
+		Avg: ms(sum / time.Duration(len(sorted))),
+		P50: at(0.5),
+		P90: at(0.9),
+		P99: at(0.99),
+		Max: ms(sorted[len(sorted)-1]),
+	}
+}
+
+func (handler *DocumentHandler) handleDocumentEvaluate(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleDocumentEvaluate", startTime)
+	evalReq := &request.EvaluateRequest{}
+	if err := c.ShouldBindJSON(evalReq); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	head := &vearchpb.RequestHead{DbName: evalReq.DbName, SpaceName: evalReq.SpaceName, Params: make(map[string]string)}
+	space, err := handler.docService.getSpace(c.Request.Context(), head)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	// update space name because maybe is alias name
+	evalReq.SpaceName = head.SpaceName
+
+	result, err := handler.evaluate(c.Request.Context(), space, evalReq)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(result)
+}
+
+// evaluate searches every query vector with the index params of evalReq and
+// measures the recall against the ground truth and the latency
+func (handler *DocumentHandler) evaluate(ctx context.Context, space *entity.Space, evalReq *request.EvaluateRequest) (*EvaluateResult, error) {
+	if evalReq.Field == "" || len(evalReq.Vectors) == 0 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field and vectors should not be empty"))
+	}
+	if len(evalReq.Vectors) > maxEvaluateQueries {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vectors num %d exceeds the limit %d", len(evalReq.Vectors), maxEvaluateQueries))
+	}
+	if evalReq.GroundTruth != nil && len(evalReq.GroundTruth) != len(evalReq.Vectors) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("ground_truth num %d not equal to vectors num %d", len(evalReq.GroundTruth), len(evalReq.Vectors)))
+	}
+	if evalReq.Limit <= 0 {
+		evalReq.Limit = 10
+	}
+
+	result := &EvaluateResult{
+		Queries:     len(evalReq.Vectors),
+		Limit:       evalReq.Limit,
+		IndexParams: evalReq.IndexParams,
+		GroundTruth: GroundTruthSupplied,
+		MinRecall:   1,
+	}
+
+	truth := evalReq.GroundTruth
+	if truth == nil {
+		result.GroundTruth = GroundTruthBruteForce
+		truth = make([][]string, len(evalReq.Vectors))
+		latencies := make([]time.Duration, len(evalReq.Vectors))
+		for i, vector := range evalReq.Vectors {
+			ids, cost, err := handler.evaluateSearch(ctx, space, evalReq, vector, true)
+			if err != nil {
+				return nil, err
+			}
+			truth[i], latencies[i] = ids, cost
+		}
+		result.BruteForceLatency = summarizeLatency(latencies)
+	}
+
+	latencies := make([]time.Duration, len(evalReq.Vectors))
+	sum := 0.0
+	for i, vector := range evalReq.Vectors {
+		ids, cost, err := handler.evaluateSearch(ctx, space, evalReq, vector, false)
+		if err != nil {
+			return nil, err
+		}
+		latencies[i] = cost
+		recall := algorithm.Recall(ids, truth[i], int(evalReq.Limit))
+		sum += recall
+		if recall < result.MinRecall {
+			result.MinRecall = recall
+		}
+	}
+	result.Recall = sum / float64(len(evalReq.Vectors))
+	result.Latency = summarizeLatency(latencies)
+	return result, nil
+}
+
+// evaluateSearch returns the ids of one search and its latency
+func (handler *DocumentHandler) evaluateSearch(ctx context.Context, space *entity.Space, evalReq *request.EvaluateRequest, vector []float32, bruteForce bool) ([]string, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, fmt.Errorf("evaluate is not finished, use less vectors or larger timeout: %v", err))
+	}
+	feature, err := json.Marshal(map[string]interface{}{"field": evalReq.Field, "feature": vector})
+	if err != nil {
+		return nil, 0, err
+	}
+	searchDoc := &request.SearchDocumentRequest{
+		DbName:      evalReq.DbName,
+		SpaceName:   evalReq.SpaceName,
+		Limit:       evalReq.Limit,
+		Fields:      []string{entity.IdField},
+		Filters:     evalReq.Filters,
+		Vectors:     []json.RawMessage{feature},
+		IndexParams: evalReq.IndexParams,
+	}
+	if bruteForce {
+		searchDoc.IsBruteSearch = 1
+	}
+	searchReq := &vearchpb.SearchRequest{
+		Head: &vearchpb.RequestHead{DbName: evalReq.DbName, SpaceName: evalReq.SpaceName, Params: make(map[string]string)},
+	}
+	if err := requestToPb(searchDoc, space, searchReq); err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	searchResp := handler.docService.search(ctx, searchReq)
+	cost := time.Since(start)
+	if head := searchResp.Head; head != nil && head.Err != nil && head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, 0, vearchpb.NewError(head.Err.Code, fmt.Errorf("%s", head.Err.Msg))
+	}
+
+	ids := make([]string, 0, evalReq.Limit)
+	if len(searchResp.Results) > 0 {
+		for _, item := range searchResp.Results[0].ResultItems {
+			ids = append(ids, item.PKey)
+		}
+	}
+	return ids, cost, nil
+}
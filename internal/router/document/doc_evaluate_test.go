@@ -0,0 +1,52 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeLatency(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		want      LatencySummary
+	}{
+		{
+			name:      "No search",
+			latencies: nil,
+			want:      LatencySummary{},
+		},
+		{
+			name:      "One search",
+			latencies: []time.Duration{3 * time.Millisecond},
+			want:      LatencySummary{Avg: 3, P50: 3, P90: 3, P99: 3, Max: 3},
+		},
+		{
+			name: "Unordered searches",
+			latencies: []time.Duration{10 * time.Millisecond, 1 * time.Millisecond, 9 * time.Millisecond, 2 * time.Millisecond, 8 * time.Millisecond,
+				3 * time.Millisecond, 7 * time.Millisecond, 4 * time.Millisecond, 6 * time.Millisecond, 5 * time.Millisecond},
+			want: LatencySummary{Avg: 5.5, P50: 5, P90: 9, P99: 10, Max: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeLatency(tt.latencies); *got != tt.want {
+				t.Errorf("summarizeLatency() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
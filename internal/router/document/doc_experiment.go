@@ -0,0 +1,230 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	// ExperimentHeader forces the variant of a search in request, and tells
+	// the variant that served it in response
+	ExperimentHeader = "X-Vearch-Variant"
+
+	ExperimentControl = "control"
+
+	ExperimentHashByUser   = "user"
+	ExperimentHashByHeader = "header"
+
+	experimentBuckets = 10000
+)
+
+// experiments holds the experiments of this router by source space
+type experiments struct {
+	mu    sync.RWMutex
+	rules map[string]*config.ExperimentCfg
+}
+
+func newExperiments(rules []*config.ExperimentCfg) (*experiments, error) {
+	e := &experiments{rules: make(map[string]*config.ExperimentCfg)}
+	if err := e.set(rules); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// set replaces all the experiments
+func (e *experiments) set(rules []*config.ExperimentCfg) error {
+	m := make(map[string]*config.ExperimentCfg, len(rules))
+	for _, rule := range rules {
+		if rule.Name == "" || rule.DbName == "" || rule.SpaceName == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("name, db_name and space_name of experiment should not be empty"))
+		}
+		switch rule.HashBy {
+		case "", ExperimentHashByUser:
+		case ExperimentHashByHeader:
+			if rule.HashHeader == "" {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("hash_header of experiment %s should not be empty", rule.Name))
+			}
+		default:
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("hash_by of experiment %s should be %s or %s", rule.Name, ExperimentHashByUser, ExperimentHashByHeader))
+		}
+		total := 0.0
+		names := map[string]bool{ExperimentControl: true}
+		for _, v := range rule.Variants {
+			if v.Name == "" || names[v.Name] {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("variant name [%s] of experiment %s is empty or duplicate", v.Name, rule.Name))
+			}
+			names[v.Name] = true
+			if v.TargetSpace == "" && len(v.IndexParams) == 0 {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("variant %s of experiment %s should have target_space or index_params", v.Name, rule.Name))
+			}
+			if v.Percent < 0 {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("percent of variant %s should not be negative", v.Name))
+			}
+			total += v.Percent
+		}
+		if total > 100 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("percent sum %.2f of experiment %s exceeds 100", total, rule.Name))
+		}
+		key := shadowKey(rule.DbName, rule.SpaceName)
+		if _, ok := m[key]; ok {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("duplicate experiment of %s", key))
+		}
+		m[key] = rule
+	}
+	e.mu.Lock()
+	e.rules = m
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *experiments) list() []*config.ExperimentCfg {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	rules := make([]*config.ExperimentCfg, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// assign returns the experiment of the space and the variant of the search,
+// the variant is nil for the control
+func (e *experiments) assign(c *gin.Context, dbName, spaceName string) (*config.ExperimentCfg, *config.ExperimentVariant) {
+	e.mu.RLock()
+	rule := e.rules[shadowKey(dbName, spaceName)]
+	e.mu.RUnlock()
+	if rule == nil {
+		return nil, nil
+	}
+
+	if name := c.GetHeader(ExperimentHeader); name != "" {
+		for _, v := range rule.Variants {
+			if v.Name == name {
+				return rule, v
+			}
+		}
+		return rule, nil
+	}
+
+	var bucket int
+	var key string
+	switch rule.HashBy {
+	case ExperimentHashByUser:
+		key, _, _ = c.Request.BasicAuth()
+	case ExperimentHashByHeader:
+		key = c.GetHeader(rule.HashHeader)
+	}
+	if key != "" {
+		// salted by the experiment so the buckets of experiments are independent
+		h := fnv.New32a()
+		h.Write([]byte(rule.Name + "/" + key))
+		bucket = int(h.Sum32() % experimentBuckets)
+	} else {
+		bucket = rand.Intn(experimentBuckets)
+	}
+
+	bound := 0.0
+	for _, v := range rule.Variants {
+		bound += v.Percent * experimentBuckets / 100
+		if float64(bucket) < bound {
+			return rule, v
+		}
+	}
+	return rule, nil
+}
+
+// applyVariant changes the space and index params of searchDoc to the variant
+func applyVariant(searchDoc *request.SearchDocumentRequest, v *config.ExperimentVariant) error {
+	if v.TargetSpace != "" {
+		if v.TargetDb != "" {
+			searchDoc.DbName = v.TargetDb
+		}
+		searchDoc.SpaceName = v.TargetSpace
+	}
+	if len(v.IndexParams) == 0 {
+		return nil
+	}
+	params := make(map[string]interface{})
+	if len(searchDoc.IndexParams) > 0 {
+		if err := json.Unmarshal(searchDoc.IndexParams, &params); err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index_params unmarshal err: %v", err))
+		}
+	}
+	for k, val := range v.IndexParams {
+		params[k] = val
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	searchDoc.IndexParams = b
+	return nil
+}
+
+// experimentSearch routes searchDoc to its variant and tags the response
+// with the variant, experiment is empty if the space has no experiment
+func (handler *DocumentHandler) experimentSearch(c *gin.Context, searchDoc *request.SearchDocumentRequest) (experiment string, variant string, err error) {
+	rule, v := handler.experiments.assign(c, searchDoc.DbName, searchDoc.SpaceName)
+	if rule == nil {
+		return "", "", nil
+	}
+	variant = ExperimentControl
+	if v != nil {
+		variant = v.Name
+		if err := applyVariant(searchDoc, v); err != nil {
+			return "", "", err
+		}
+	}
+	c.Header(ExperimentHeader, variant)
+	return rule.Name, variant, nil
+}
+
+// handleConfigExperiment replaces the experiments of this router with the
+// body, it only changes the router serving the request
+func (handler *DocumentHandler) handleConfigExperiment(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleConfigExperiment", startTime)
+	rules := make([]*config.ExperimentCfg, 0)
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := handler.experiments.set(rules); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	log.Info("experiments changed to %d experiments", len(rules))
+	response.New(c).JsonSuccess(handler.experiments.list())
+}
+
+func (handler *DocumentHandler) handleGetConfigExperiment(c *gin.Context) {
+	response.New(c).JsonSuccess(handler.experiments.list())
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+)
+
+func TestExperiments_set(t *testing.T) {
+	variant := &config.ExperimentVariant{Name: "hnsw", TargetSpace: "space_hnsw", Percent: 30}
+	tests := []struct {
+		name    string
+		rules   []*config.ExperimentCfg
+		wantErr bool
+	}{
+		{
+			name:  "Valid experiment",
+			rules: []*config.ExperimentCfg{{Name: "e", DbName: "db", SpaceName: "space", Variants: []*config.ExperimentVariant{variant}}},
+		},
+		{
+			name:    "Invalid hash by header without header",
+			rules:   []*config.ExperimentCfg{{Name: "e", DbName: "db", SpaceName: "space", HashBy: ExperimentHashByHeader}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid variant named control",
+			rules:   []*config.ExperimentCfg{{Name: "e", DbName: "db", SpaceName: "space", Variants: []*config.ExperimentVariant{{Name: ExperimentControl, TargetSpace: "b"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid variant without target",
+			rules:   []*config.ExperimentCfg{{Name: "e", DbName: "db", SpaceName: "space", Variants: []*config.ExperimentVariant{{Name: "v"}}}},
+			wantErr: true,
+		},
+		{
+			name: "Invalid percent sum beyond 100",
+			rules: []*config.ExperimentCfg{{Name: "e", DbName: "db", SpaceName: "space", Variants: []*config.ExperimentVariant{
+				{Name: "a", TargetSpace: "a", Percent: 60}, {Name: "b", TargetSpace: "b", Percent: 50}}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newExperiments(tt.rules); (err != nil) != tt.wantErr {
+				t.Errorf("newExperiments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExperiments_assign(t *testing.T) {
+	all := &config.ExperimentVariant{Name: "all", TargetSpace: "b", Percent: 100}
+	none := &config.ExperimentVariant{Name: "none", TargetSpace: "c"}
+	e, err := newExperiments([]*config.ExperimentCfg{
+		{Name: "full", DbName: "db", SpaceName: "a", HashBy: ExperimentHashByHeader, HashHeader: "X-Tenant", Variants: []*config.ExperimentVariant{all}},
+		{Name: "off", DbName: "db", SpaceName: "d", Variants: []*config.ExperimentVariant{none}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := func(headers map[string]string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/document/search", nil)
+		for k, v := range headers {
+			c.Request.Header.Set(k, v)
+		}
+		return c
+	}
+	tests := []struct {
+		name    string
+		space   string
+		headers map[string]string
+		want    *config.ExperimentVariant
+		rule    bool
+	}{
+		{name: "Space without experiment", space: "x", rule: false},
+		{name: "Bucketed into full variant", space: "a", headers: map[string]string{"X-Tenant": "t1"}, want: all, rule: true},
+		{name: "Variant of zero percent is never bucketed", space: "d", want: nil, rule: true},
+		{name: "Variant forced by header", space: "d", headers: map[string]string{ExperimentHeader: "none"}, want: none, rule: true},
+		{name: "Unknown forced variant is control", space: "a", headers: map[string]string{ExperimentHeader: "other"}, want: nil, rule: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, v := e.assign(context(tt.headers), "db", tt.space)
+			if (rule != nil) != tt.rule || v != tt.want {
+				t.Errorf("assign() = %v, %v, want rule %v variant %v", rule, v, tt.rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyVariant(t *testing.T) {
+	searchDoc := &request.SearchDocumentRequest{IndexParams: json.RawMessage(`{"metric_type":"L2","nprobe":8}`)}
+	searchDoc.DbName, searchDoc.SpaceName = "db", "a"
+	err := applyVariant(searchDoc, &config.ExperimentVariant{TargetDb: "db2", TargetSpace: "b", IndexParams: map[string]interface{}{"nprobe": 64}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if searchDoc.DbName != "db2" || searchDoc.SpaceName != "b" {
+		t.Errorf("space = %s/%s, want db2/b", searchDoc.DbName, searchDoc.SpaceName)
+	}
+	if string(searchDoc.IndexParams) != `{"metric_type":"L2","nprobe":64}` {
+		t.Errorf("index params = %s", searchDoc.IndexParams)
+	}
+}
@@ -0,0 +1,449 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/parquet-go/parquet-go"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	ExportFormatParquet = "parquet"
+	exportManifestFile  = "manifest.json"
+
+	defaultExportRowsPerFile = 100000
+	defaultExportQueryDocs   = 10000
+	maxExportQueryDocs       = 1000000
+)
+
+// ExportColumn is a column of the exported files, Type is the field type of
+// the space
+type ExportColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ExportFile is an object written by an export
+type ExportFile struct {
+	Name        string             `json:"name"`
+	PartitionID entity.PartitionID `json:"partition_id"`
+	Rows        int                `json:"rows"`
+	Size        int64              `json:"size"`
+}
+
+// ExportManifest is the result of /document/export, it is written to
+// manifest.json under the prefix after all the files
+type ExportManifest struct {
+	DbName    string          `json:"db_name"`
+	SpaceName string          `json:"space_name"`
+	Bucket    string          `json:"bucket"`
+	Prefix    string          `json:"prefix"`
+	Format    string          `json:"format"`
+	Columns   []*ExportColumn `json:"columns"`
+	Rows      int             `json:"rows"`
+	Files     []*ExportFile   `json:"files"`
+	Took      float64         `json:"took_ms"`
+}
+
+func (handler *DocumentHandler) handleDocumentExport(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleDocumentExport", startTime)
+	exportReq := &request.ExportRequest{}
+	if err := c.ShouldBindJSON(exportReq); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	head, err := setRequestHeadFromGin(c)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	head.DbName, head.SpaceName = exportReq.DbName, exportReq.SpaceName
+	space, err := handler.docService.getSpace(c.Request.Context(), head)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	exportReq.SpaceName = head.SpaceName
+
+	manifest, err := handler.export(c.Request.Context(), head, space, exportReq)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	manifest.Took = time.Since(startTime).Seconds() * 1000
+	log.Info("export %s/%s %d rows to %d files under %s/%s", exportReq.DbName, exportReq.SpaceName,
+		manifest.Rows, len(manifest.Files), manifest.Bucket, manifest.Prefix)
+	response.New(c).JsonSuccess(manifest)
+}
+
+// export writes the documents of each partition to parquet files of at most
+// RowsPerFile rows, then the manifest of the files
+func (handler *DocumentHandler) export(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, exportReq *request.ExportRequest) (*ExportManifest, error) {
+	if exportReq.S3Param.BucketName == "" || exportReq.Prefix == "" {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("s3_param.bucket_name and prefix should be set"))
+	}
+	if exportReq.RowsPerFile <= 0 {
+		exportReq.RowsPerFile = defaultExportRowsPerFile
+	}
+	if exportReq.MaxDocs < 0 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("max_docs should not be negative"))
+	}
+	if exportReq.Filters != nil {
+		if exportReq.MaxDocs == 0 {
+			exportReq.MaxDocs = defaultExportQueryDocs
+		}
+		if exportReq.MaxDocs > maxExportQueryDocs {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("max_docs with filters should not exceed %d", maxExportQueryDocs))
+		}
+	}
+
+	w, err := newExportWriter(space, exportReq)
+	if err != nil {
+		return nil, err
+	}
+	client, err := minio.New(exportReq.S3Param.EndPoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(exportReq.S3Param.AccessKey, exportReq.S3Param.SecretKey, ""),
+		Secure: exportReq.S3Param.UseSSL,
+	})
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("failed to create minio client: %v", err))
+	}
+	w.put = func(ctx context.Context, name string, reader io.Reader, size int64, contentType string) (int64, error) {
+		info, err := client.PutObject(ctx, exportReq.S3Param.BucketName, name, reader, size, minio.PutObjectOptions{ContentType: contentType})
+		return info.Size, err
+	}
+
+	for _, partition := range space.Partitions {
+		w.partition = partition.Id
+		if exportReq.Filters != nil {
+			err = handler.exportQuery(ctx, head, space, exportReq, w)
+		} else {
+			err = handler.scanPartition(ctx, head, partition.Id, func(fields []*vearchpb.Field) (bool, error) {
+				if err := w.add(ctx, fields); err != nil {
+					return false, err
+				}
+				return exportReq.MaxDocs == 0 || w.manifest.Rows < exportReq.MaxDocs, nil
+			})
+		}
+		if err == nil {
+			err = w.closeFile()
+		}
+		if err != nil {
+			w.abort(err)
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("export partition %d err after %d rows: %v", partition.Id, w.manifest.Rows, err))
+		}
+		if exportReq.MaxDocs > 0 && exportReq.Filters == nil && w.manifest.Rows >= exportReq.MaxDocs {
+			break
+		}
+	}
+
+	if err := w.writeManifest(ctx); err != nil {
+		return nil, err
+	}
+	return w.manifest, nil
+}
+
+// exportQuery writes the matches of the filters in the current partition of w
+func (handler *DocumentHandler) exportQuery(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, exportReq *request.ExportRequest, w *exportWriter) error {
+	searchDoc := &request.SearchDocumentRequest{
+		DbName:      exportReq.DbName,
+		SpaceName:   exportReq.SpaceName,
+		Filters:     exportReq.Filters,
+		Fields:      exportReq.Fields,
+		VectorValue: exportReq.VectorValue,
+		Limit:       int32(exportReq.MaxDocs),
+	}
+	args := &vearchpb.QueryRequest{
+		Head: &vearchpb.RequestHead{DbName: head.DbName, SpaceName: head.SpaceName, Params: head.Params},
+	}
+	if err := queryRequestToPb(searchDoc, space, args); err != nil {
+		return err
+	}
+	args.PartitionId = int32(w.partition)
+	searchResp := handler.docService.query(ctx, args)
+	if h := searchResp.Head; h != nil && h.Err != nil && h.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return vearchpb.NewError(h.Err.Code, fmt.Errorf("%s", h.Err.Msg))
+	}
+	for _, result := range searchResp.Results {
+		for _, item := range result.ResultItems {
+			fields := append(item.Fields, &vearchpb.Field{Name: entity.IdField, Value: []byte(item.PKey)})
+			if err := w.add(ctx, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportPut uploads an object of size to the bucket of the export, size is
+// -1 if unknown, and returns the size uploaded
+type exportPut func(ctx context.Context, name string, reader io.Reader, size int64, contentType string) (int64, error)
+
+// exportWriter streams the rows of the current file to the bucket while
+// they are encoded
+type exportWriter struct {
+	put         exportPut
+	prefix      string
+	rowsPerFile int
+	manifest    *ExportManifest
+
+	schema  *parquet.Schema
+	builder *parquet.RowBuilder
+	columns map[string]*exportColumn
+
+	partition entity.PartitionID
+	seq       int
+	file      *ExportFile
+	writer    *parquet.Writer
+	pipe      *io.PipeWriter
+	uploaded  chan error
+}
+
+type exportColumn struct {
+	index    int
+	property *entity.SpaceProperties
+	binary   bool
+}
+
+func newExportWriter(space *entity.Space, exportReq *request.ExportRequest) (*exportWriter, error) {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = entity.UnmarshalPropertyJSON(space.Fields); err != nil {
+			return nil, err
+		}
+	}
+	names := exportReq.Fields
+	if len(names) == 0 {
+		for name, pro := range proMap {
+			if pro.FieldType != vearchpb.FieldType_VECTOR || exportReq.VectorValue {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+	binary := space.Index != nil && space.Index.Type == "BINARYIVF"
+
+	group := parquet.Group{entity.IdField: parquet.String()}
+	manifest := &ExportManifest{
+		DbName:    exportReq.DbName,
+		SpaceName: exportReq.SpaceName,
+		Bucket:    exportReq.S3Param.BucketName,
+		Prefix:    exportReq.Prefix,
+		Format:    ExportFormatParquet,
+		Columns:   []*ExportColumn{{Name: entity.IdField, Type: "string"}},
+		Files:     make([]*ExportFile, 0),
+	}
+	properties := make(map[string]*entity.SpaceProperties, len(names))
+	for _, name := range names {
+		if name == entity.IdField {
+			continue
+		}
+		pro := proMap[name]
+		if pro == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] is not exist in the space", name))
+		}
+		var node parquet.Node
+		switch pro.FieldType {
+		case vearchpb.FieldType_STRING:
+			node = parquet.Optional(parquet.String())
+		case vearchpb.FieldType_STRINGARRAY:
+			node = parquet.Repeated(parquet.String())
+		case vearchpb.FieldType_INT:
+			node = parquet.Optional(parquet.Int(32))
+		case vearchpb.FieldType_LONG:
+			node = parquet.Optional(parquet.Int(64))
+		case vearchpb.FieldType_FLOAT:
+			node = parquet.Optional(parquet.Leaf(parquet.FloatType))
+		case vearchpb.FieldType_DOUBLE:
+			node = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		case vearchpb.FieldType_BOOL:
+			node = parquet.Optional(parquet.Leaf(parquet.BooleanType))
+		case vearchpb.FieldType_DATE:
+			node = parquet.Optional(parquet.Timestamp(parquet.Nanosecond))
+		case vearchpb.FieldType_VECTOR:
+			if binary {
+				node = parquet.Optional(parquet.Leaf(parquet.ByteArrayType))
+			} else {
+				node = parquet.Repeated(parquet.Leaf(parquet.FloatType))
+			}
+		default:
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] of type %v can not be exported", name, pro.FieldType))
+		}
+		group[name] = node
+		properties[name] = pro
+		manifest.Columns = append(manifest.Columns, &ExportColumn{Name: name, Type: pro.Type})
+	}
+
+	w := &exportWriter{
+		prefix:      exportReq.Prefix,
+		rowsPerFile: exportReq.RowsPerFile,
+		manifest:    manifest,
+		schema:      parquet.NewSchema(exportReq.SpaceName, group),
+		columns:     make(map[string]*exportColumn, len(group)),
+	}
+	w.builder = parquet.NewRowBuilder(w.schema)
+	for name := range group {
+		leaf, _ := w.schema.Lookup(name)
+		w.columns[name] = &exportColumn{index: leaf.ColumnIndex, property: properties[name], binary: binary}
+	}
+	return w, nil
+}
+
+// add writes a document as a row, the fields not exported are ignored
+func (w *exportWriter) add(ctx context.Context, fields []*vearchpb.Field) error {
+	w.builder.Reset()
+	for _, fv := range fields {
+		column := w.columns[fv.Name]
+		if column == nil {
+			continue
+		}
+		if err := column.add(w.builder, fv.Value); err != nil {
+			return err
+		}
+	}
+	if w.writer == nil {
+		w.openFile(ctx)
+	}
+	if _, err := w.writer.WriteRows([]parquet.Row{w.builder.Row()}); err != nil {
+		return err
+	}
+	w.file.Rows++
+	w.manifest.Rows++
+	if w.file.Rows >= w.rowsPerFile {
+		return w.closeFile()
+	}
+	return nil
+}
+
+func (column *exportColumn) add(b *parquet.RowBuilder, value []byte) error {
+	if column.property == nil {
+		b.Add(column.index, parquet.ByteArrayValue(value))
+		return nil
+	}
+	switch column.property.FieldType {
+	case vearchpb.FieldType_STRING:
+		b.Add(column.index, parquet.ByteArrayValue(value))
+	case vearchpb.FieldType_STRINGARRAY:
+		for _, s := range bytes.Split(value, []byte{'\001'}) {
+			b.Add(column.index, parquet.ByteArrayValue(s))
+		}
+	case vearchpb.FieldType_INT:
+		b.Add(column.index, parquet.Int32Value(cbbytes.Bytes2Int32(value)))
+	case vearchpb.FieldType_LONG:
+		b.Add(column.index, parquet.Int64Value(cbbytes.Bytes2Int(value)))
+	case vearchpb.FieldType_FLOAT:
+		b.Add(column.index, parquet.FloatValue(cbbytes.ByteToFloat32(value)))
+	case vearchpb.FieldType_DOUBLE:
+		b.Add(column.index, parquet.DoubleValue(cbbytes.ByteToFloat64New(value)))
+	case vearchpb.FieldType_BOOL:
+		b.Add(column.index, parquet.BooleanValue(cbbytes.Bytes2Int(value) != 0))
+	case vearchpb.FieldType_DATE:
+		b.Add(column.index, parquet.Int64Value(cbbytes.Bytes2Int(value)))
+	case vearchpb.FieldType_VECTOR:
+		if column.binary {
+			b.Add(column.index, parquet.ByteArrayValue(value))
+			return nil
+		}
+		vector, err := cbbytes.ByteToVectorForFloat32(value)
+		if err != nil {
+			return err
+		}
+		for _, x := range vector {
+			b.Add(column.index, parquet.FloatValue(x))
+		}
+	}
+	return nil
+}
+
+// openFile starts the upload of the next file of the current partition, the
+// parquet writer feeds it through a pipe
+func (w *exportWriter) openFile(ctx context.Context) {
+	name := path.Join(w.prefix, fmt.Sprintf("part-%d-%05d.parquet", w.partition, w.seq))
+	w.seq++
+	w.file = &ExportFile{Name: name, PartitionID: w.partition}
+	reader, pipe := io.Pipe()
+	w.pipe = pipe
+	w.uploaded = make(chan error, 1)
+	go func(file *ExportFile, uploaded chan error) {
+		size, err := w.put(ctx, file.Name, reader, -1, "application/vnd.apache.parquet")
+		reader.CloseWithError(err)
+		file.Size = size
+		uploaded <- err
+	}(w.file, w.uploaded)
+	w.writer = parquet.NewWriter(pipe, w.schema, parquet.Compression(&parquet.Snappy))
+}
+
+// closeFile finishes the current file and waits for its upload
+func (w *exportWriter) closeFile() error {
+	if w.writer == nil {
+		return nil
+	}
+	err := w.writer.Close()
+	w.pipe.CloseWithError(err)
+	if uploadErr := <-w.uploaded; uploadErr != nil {
+		err = uploadErr
+	}
+	w.writer = nil
+	if err != nil {
+		return fmt.Errorf("write %s: %v", w.file.Name, err)
+	}
+	w.manifest.Files = append(w.manifest.Files, w.file)
+	return nil
+}
+
+// abort stops the upload of the current file, the files uploaded are left
+// without manifest
+func (w *exportWriter) abort(err error) {
+	if w.writer == nil {
+		return
+	}
+	w.pipe.CloseWithError(err)
+	<-w.uploaded
+	w.writer = nil
+}
+
+// writeManifest writes the manifest of the files after all of them are
+// uploaded, an export without manifest is not finished
+func (w *exportWriter) writeManifest(ctx context.Context) error {
+	data, err := vjson.Marshal(w.manifest)
+	if err != nil {
+		return err
+	}
+	if _, err = w.put(ctx, path.Join(w.prefix, exportManifestFile), bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("write export manifest: %v", err))
+	}
+	return nil
+}
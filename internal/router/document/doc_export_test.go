@@ -0,0 +1,275 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// partitionDocs answers the docid keys of scanDocs by the next live doc
+// after each key, like GetNextDocsByPartition of ps
+func partitionDocs(docids []int32) func(keys []string) *vearchpb.GetResponse {
+	return func(keys []string) *vearchpb.GetResponse {
+		reply := &vearchpb.GetResponse{Head: newOkHead()}
+		for _, key := range keys {
+			k, _ := strconv.Atoi(key)
+			item := &vearchpb.Item{Doc: &vearchpb.Document{}}
+			for _, docid := range docids {
+				if int(docid) > k {
+					item.Doc.Fields = []*vearchpb.Field{
+						{Name: "_docid", Value: cbbytes.Int32ToByte(docid)},
+						{Name: entity.IdField, Value: []byte(fmt.Sprintf("doc-%d", docid))},
+					}
+					break
+				}
+			}
+			reply.Items = append(reply.Items, item)
+		}
+		return reply
+	}
+}
+
+func TestScanDocs(t *testing.T) {
+	var live []int32
+	for docid := int32(0); docid < 150; docid++ {
+		// deleted docs leave holes in the docids
+		if docid%7 != 3 {
+			live = append(live, docid)
+		}
+	}
+	ids := func(docids []int32) []string {
+		result := make([]string, 0, len(docids))
+		for _, docid := range docids {
+			result = append(result, fmt.Sprintf("doc-%d", docid))
+		}
+		return result
+	}
+	tests := []struct {
+		name     string
+		docs     []int32
+		from     int32
+		limit    int
+		want     []string
+		wantLast int32
+	}{
+		{name: "Empty partition", from: -1, want: []string{}, wantLast: -1},
+		{name: "All docs in order", docs: live, from: -1, want: ids(live), wantLast: 149},
+		{name: "Stop by fn", docs: live, from: -1, limit: 70, want: ids(live[:70]), wantLast: live[69]},
+		{name: "Go on from docid", docs: live, from: live[69], limit: 10, want: ids(live[70:80]), wantLast: live[79]},
+		{name: "Go on from last docid", docs: live, from: 149, want: []string{}, wantLast: 149},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			last, err := scanDocs(context.Background(), tt.from, dedupScanBatch, partitionDocs(tt.docs), func(fields []*vearchpb.Field) (bool, error) {
+				for _, fv := range fields {
+					if fv.Name == entity.IdField {
+						got = append(got, string(fv.Value))
+					}
+				}
+				return tt.limit == 0 || len(got) < tt.limit, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scanDocs() visited %d docs %v, want %d", len(got), got, len(tt.want))
+			}
+			if last != tt.wantLast {
+				t.Errorf("scanDocs() last docid = %d, want %d", last, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestScanDocsErr(t *testing.T) {
+	fn := func(fields []*vearchpb.Field) (bool, error) { return true, nil }
+
+	failed := func(keys []string) *vearchpb.GetResponse {
+		return &vearchpb.GetResponse{Head: &vearchpb.ResponseHead{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_PARTITION_NOT_EXIST, Msg: "gone"}}}
+	}
+	_, err := scanDocs(context.Background(), -1, dedupScanBatch, failed, fn)
+	if vErr, ok := err.(*vearchpb.VearchErr); !ok || vErr.GetError().Code != vearchpb.ErrorEnum_PARTITION_NOT_EXIST {
+		t.Errorf("expect the error of ps, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = scanDocs(ctx, -1, dedupScanBatch, partitionDocs([]int32{0, 1}), fn)
+	if vErr, ok := err.(*vearchpb.VearchErr); !ok || vErr.GetError().Code != vearchpb.ErrorEnum_TIMEOUT {
+		t.Errorf("expect a timeout, got %v", err)
+	}
+}
+
+// memBucket keeps the objects uploaded by an export
+type memBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	fail    string
+}
+
+func (b *memBucket) put(ctx context.Context, name string, reader io.Reader, size int64, contentType string) (int64, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+	if name == b.fail {
+		return 0, fmt.Errorf("put %s refused", name)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[name] = data
+	return int64(len(data)), nil
+}
+
+func exportSpace() *entity.Space {
+	return &entity.Space{
+		Name: "space",
+		SpaceProperties: map[string]*entity.SpaceProperties{
+			"title": {FieldType: vearchpb.FieldType_STRING, Type: "string"},
+			"count": {FieldType: vearchpb.FieldType_INT, Type: "integer"},
+			"vec":   {FieldType: vearchpb.FieldType_VECTOR, Type: "vector", Dimension: 2},
+		},
+	}
+}
+
+func TestNewExportWriterColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     request.ExportRequest
+		want    []string
+		wantErr bool
+	}{
+		{name: "Stored fields without vectors", want: []string{entity.IdField, "count", "title"}},
+		{name: "Stored fields with vectors", req: request.ExportRequest{VectorValue: true}, want: []string{entity.IdField, "count", "title", "vec"}},
+		{name: "Fields asked", req: request.ExportRequest{Fields: []string{"title", entity.IdField}}, want: []string{entity.IdField, "title"}},
+		{name: "Unknown field", req: request.ExportRequest{Fields: []string{"missing"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := newExportWriter(exportSpace(), &tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newExportWriter() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := make([]string, 0, len(w.manifest.Columns))
+			for _, column := range w.manifest.Columns {
+				got = append(got, column.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportWriterManifest(t *testing.T) {
+	bucket := &memBucket{objects: make(map[string][]byte)}
+	w, err := newExportWriter(exportSpace(), &request.ExportRequest{DbName: "db", SpaceName: "space", Prefix: "out", RowsPerFile: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.put = bucket.put
+	ctx := context.Background()
+	// 3 rows of partition 1 are split in 2 files, 1 row of partition 2
+	for _, part := range []struct {
+		pid  entity.PartitionID
+		rows int
+	}{{1, 3}, {2, 1}} {
+		w.partition = part.pid
+		for i := 0; i < part.rows; i++ {
+			fields := []*vearchpb.Field{
+				{Name: entity.IdField, Value: []byte(fmt.Sprintf("%d-%d", part.pid, i))},
+				{Name: "title", Value: []byte("t")},
+				{Name: "count", Value: cbbytes.Int32ToByte(int32(i))},
+				{Name: "ignored", Value: []byte("x")},
+			}
+			if err := w.add(ctx, fields); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.closeFile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.writeManifest(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	wantFiles := []*ExportFile{
+		{Name: "out/part-1-00000.parquet", PartitionID: 1, Rows: 2},
+		{Name: "out/part-1-00001.parquet", PartitionID: 1, Rows: 1},
+		{Name: "out/part-2-00002.parquet", PartitionID: 2, Rows: 1},
+	}
+	if w.manifest.Rows != 4 || len(w.manifest.Files) != len(wantFiles) {
+		t.Fatalf("manifest has %d rows in %d files, want 4 rows in %d files", w.manifest.Rows, len(w.manifest.Files), len(wantFiles))
+	}
+	for i, want := range wantFiles {
+		got := w.manifest.Files[i]
+		data := bucket.objects[want.Name]
+		if got.Name != want.Name || got.PartitionID != want.PartitionID || got.Rows != want.Rows || got.Size != int64(len(data)) {
+			t.Errorf("file %d = %+v, want %+v of size %d", i, got, want, len(data))
+			continue
+		}
+		file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Errorf("open %s: %v", want.Name, err)
+		} else if file.NumRows() != int64(want.Rows) {
+			t.Errorf("%s has %d rows, want %d", want.Name, file.NumRows(), want.Rows)
+		}
+	}
+
+	manifest := &ExportManifest{}
+	if err := vjson.Unmarshal(bucket.objects["out/"+exportManifestFile], manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Rows != 4 || len(manifest.Files) != 3 || manifest.DbName != "db" || manifest.Format != ExportFormatParquet {
+		t.Errorf("written manifest = %+v", manifest)
+	}
+}
+
+func TestExportWriterUploadErr(t *testing.T) {
+	bucket := &memBucket{objects: make(map[string][]byte), fail: "out/part-0-00000.parquet"}
+	w, err := newExportWriter(exportSpace(), &request.ExportRequest{Prefix: "out", RowsPerFile: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.put = bucket.put
+	if err := w.add(context.Background(), []*vearchpb.Field{{Name: entity.IdField, Value: []byte("1")}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.closeFile(); err == nil {
+		t.Fatal("expect the upload error")
+	}
+	if len(w.manifest.Files) != 0 {
+		t.Errorf("expect the failed file left out of the manifest, got %v", w.manifest.Files)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fairqueue"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// fairQueue queues the searches and queries of this router by tenant, so a
+// burst of one tenant does not take the slots of the others
+type fairQueue struct {
+	queue *fairqueue.Queue
+	slots int
+}
+
+func newFairQueue(cfg *config.FairQueueCfg) *fairQueue {
+	if cfg == nil || cfg.Slots <= 0 {
+		return nil
+	}
+	log.Info("queue searches and queries fairly by tenant in %d slots", cfg.Slots)
+	return &fairQueue{queue: fairqueue.New(cfg.Slots, cfg.Weight), slots: cfg.Slots}
+}
+
+// requestTenant is the user of request, or the space if it has no user
+func requestTenant(c *gin.Context, dbName, spaceName string) string {
+	if user, _, ok := c.Request.BasicAuth(); ok && user != "" {
+		return user
+	}
+	return dbName + "/" + spaceName
+}
+
+// acquire waits for a slot of the tenant of request, the returned context
+// carries the tenant to ps and the returned func releases the slot
+func (f *fairQueue) acquire(ctx context.Context, c *gin.Context, dbName, spaceName string) (context.Context, func(), error) {
+	tenant := requestTenant(c, dbName, spaceName)
+	ctx = context.WithValue(ctx, entity.TENANT, tenant)
+	if f == nil {
+		return ctx, func() {}, nil
+	}
+	release, err := f.queue.Acquire(ctx, tenant)
+	if err != nil {
+		err = fmt.Errorf("request of tenant [%s] time out in queue, the router can only deal [%d] searches and queries at same time", tenant, f.slots)
+		return ctx, nil, vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_RESOURCE_EXHAUSTED, err)
+	}
+	return ctx, release, nil
+}
+
+// acquireFairQueue acquires a slot of the fair queue unless the fair_queue
+// feature flag is off for the request
+func (handler *DocumentHandler) acquireFairQueue(ctx context.Context, c *gin.Context, dbName, spaceName string) (context.Context, func(), error) {
+	queue := handler.fairQueue
+	if !handler.featureFlags.enabled(c, entity.FeatureFairQueue, dbName, spaceName) {
+		queue = nil
+	}
+	return queue.acquire(ctx, c, dbName, spaceName)
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// featureFlags holds the feature flags stored in etcd by name, kept in sync
+// by watch so operators roll behaviors out and back without redeploying
+type featureFlags struct {
+	mu    sync.RWMutex
+	flags map[string]*entity.FeatureFlag
+}
+
+func startFeatureFlags(cli *client.Client) *featureFlags {
+	f := &featureFlags{flags: make(map[string]*entity.FeatureFlag)}
+	go f.watch(context.Background(), cli)
+	return f
+}
+
+// watch loads all the flags and then applies the changes, it retries when
+// etcd is not reachable so a degraded router picks up the flags later
+func (f *featureFlags) watch(ctx context.Context, cli *client.Client) {
+	for {
+		watcher, err := cli.Master().WatchPrefix(ctx, entity.PrefixFeatureFlag)
+		if err != nil {
+			log.Error("watch feature flags err: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		// flags changed before the watch starts are got by scan
+		_, values, err := cli.Master().PrefixScan(ctx, entity.PrefixFeatureFlag)
+		if err != nil {
+			log.Error("scan feature flags err: %v", err)
+		} else {
+			flags := make(map[string]*entity.FeatureFlag, len(values))
+			for _, value := range values {
+				if flag := decodeFeatureFlag(value); flag != nil {
+					flags[flag.Name] = flag
+				}
+			}
+			f.mu.Lock()
+			f.flags = flags
+			f.mu.Unlock()
+		}
+		for reps := range watcher {
+			if reps.Canceled {
+				log.Error("feature flags watcher is canceled")
+				break
+			}
+			for _, event := range reps.Events {
+				name := strings.TrimPrefix(string(event.Kv.Key), entity.PrefixFeatureFlag)
+				if event.Type == mvccpb.PUT {
+					if flag := decodeFeatureFlag(event.Kv.Value); flag != nil {
+						log.Info("feature flag %s changed: enabled %v, percent %.2f, spaces %v", flag.Name, flag.Enabled, flag.Share(), flag.Spaces)
+						f.mu.Lock()
+						f.flags[flag.Name] = flag
+						f.mu.Unlock()
+					}
+				} else {
+					log.Info("feature flag %s deleted", name)
+					f.mu.Lock()
+					delete(f.flags, name)
+					f.mu.Unlock()
+				}
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func decodeFeatureFlag(value []byte) *entity.FeatureFlag {
+	flag := &entity.FeatureFlag{}
+	if err := vjson.Unmarshal(value, flag); err != nil {
+		log.Error("decode feature flag err: %v", err)
+		return nil
+	}
+	return flag
+}
+
+// enabled tells whether the behavior runs for the request, it runs as
+// configured if it has no flag. The tenant of request is hashed with the
+// flag name, so a tenant stays in or out of a rollout as the percent grows
+func (f *featureFlags) enabled(c *gin.Context, name, dbName, spaceName string) bool {
+	if f == nil {
+		return true
+	}
+	f.mu.RLock()
+	flag := f.flags[name]
+	f.mu.RUnlock()
+	if flag == nil {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name + "/" + requestTenant(c, dbName, spaceName)))
+	bucket := float64(h.Sum32()%experimentBuckets) * 100 / experimentBuckets
+	return flag.On(dbName, spaceName, bucket)
+}
+
+func (f *featureFlags) list() []*entity.FeatureFlag {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	flags := make([]*entity.FeatureFlag, 0, len(f.flags))
+	for _, flag := range f.flags {
+		flags = append(flags, flag)
+	}
+	return flags
+}
+
+// handleGetConfigFeatureFlags replies the feature flags seen by this router
+func (handler *DocumentHandler) handleGetConfigFeatureFlags(c *gin.Context) {
+	response.New(c).JsonSuccess(handler.featureFlags.list())
+}
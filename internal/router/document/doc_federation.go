@@ -0,0 +1,356 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	defaultRemoteTimeout = 3000 // ms
+	maxFederatedTargets  = 16
+	// FederatedTargetField is the target of a document of a federated search
+	FederatedTargetField = "_target"
+)
+
+// federation holds the remote clusters of this router by name
+type federation struct {
+	remotes map[string]*config.RemoteClusterCfg
+	http    *http.Client
+}
+
+func newFederation(remotes []*config.RemoteClusterCfg) (*federation, error) {
+	f := &federation{
+		remotes: make(map[string]*config.RemoteClusterCfg, len(remotes)),
+		http:    &http.Client{},
+	}
+	for _, remote := range remotes {
+		if remote.Name == "" || strings.ContainsAny(remote.Name, ":/") {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("remote cluster name [%s] should not be empty or have : or /", remote.Name))
+		}
+		if _, ok := f.remotes[remote.Name]; ok {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("duplicate remote cluster %s", remote.Name))
+		}
+		if len(remote.Endpoints) == 0 {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("remote cluster %s should have endpoints", remote.Name))
+		}
+		for i, endpoint := range remote.Endpoints {
+			u, err := url.Parse(endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("endpoint %s of remote cluster %s should be an http or https url", endpoint, remote.Name))
+			}
+			remote.Endpoints[i] = strings.TrimRight(endpoint, "/")
+		}
+		if remote.Timeout <= 0 {
+			remote.Timeout = defaultRemoteTimeout
+		}
+		f.remotes[remote.Name] = remote
+	}
+	return f, nil
+}
+
+// federatedTarget is a space of this cluster if cluster is empty
+type federatedTarget struct {
+	cluster string
+	db      string
+	space   string
+}
+
+func (t federatedTarget) String() string {
+	if t.cluster == "" {
+		return t.db + "/" + t.space
+	}
+	return t.cluster + ":" + t.db + "/" + t.space
+}
+
+// parseFederatedTarget parses [cluster:]db/space
+func parseFederatedTarget(s string) (federatedTarget, error) {
+	var t federatedTarget
+	name := s
+	if i := strings.Index(name, ":"); i >= 0 {
+		t.cluster, name = name[:i], name[i+1:]
+		if t.cluster == "" {
+			return t, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("target [%s] should be [cluster:]db/space", s))
+		}
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return t, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("target [%s] should be [cluster:]db/space", s))
+	}
+	t.db, t.space = parts[0], parts[1]
+	return t, nil
+}
+
+// resolveFederatedTarget parses a target, a name without / is a global alias
+func (handler *DocumentHandler) resolveFederatedTarget(s string) (federatedTarget, error) {
+	if !strings.Contains(s, "/") {
+		alias := handler.globalAliases.get(s)
+		if alias == nil {
+			return federatedTarget{}, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("target [%s] should be [cluster:]db/space or a global alias", s))
+		}
+		s = alias.Target()
+	}
+	return parseFederatedTarget(s)
+}
+
+// federatedReply is the reply of /document/search, numbers are kept as
+// json.Number so the fields of documents are not rounded
+type federatedReply struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Documents [][]map[string]interface{} `json:"documents"`
+	} `json:"data"`
+}
+
+func decodeFederatedReply(status int, body io.Reader) ([][]map[string]interface{}, error) {
+	reply := &federatedReply{}
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(reply); err != nil {
+		return nil, fmt.Errorf("status %d: %v", status, err)
+	}
+	if reply.Code != int(vearchpb.ErrorEnum_SUCCESS) {
+		return nil, fmt.Errorf("code %d: %s", reply.Code, reply.Msg)
+	}
+	return reply.Data.Documents, nil
+}
+
+// handleFederatedSearch searches every target of searchDoc and merges the
+// results by score, the targets failing are reported and the search fails
+// only if all of them fail
+func (handler *DocumentHandler) handleFederatedSearch(c *gin.Context, searchDoc *request.SearchDocumentRequest) {
+	if len(searchDoc.Targets) > maxFederatedTargets {
+		response.New(c).JsonError(errors.NewErrBadRequest(vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("targets should be at most %d", maxFederatedTargets))))
+		return
+	}
+	targets := make([]federatedTarget, 0, len(searchDoc.Targets))
+	for _, s := range searchDoc.Targets {
+		t, err := handler.resolveFederatedTarget(s)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+		if t.cluster != "" && handler.federation.remotes[t.cluster] == nil {
+			response.New(c).JsonError(errors.NewErrBadRequest(vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("remote cluster %s of target %s is not configured on router", t.cluster, s))))
+			return
+		}
+		targets = append(targets, t)
+	}
+	asc, err := handler.federatedAscending(c.Request.Context(), searchDoc, targets)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	results := make([][][]map[string]interface{}, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		doc := *searchDoc
+		doc.DbName, doc.SpaceName, doc.Targets, doc.Echo = t.db, t.space, nil, nil
+		body, err := json.Marshal(&doc)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrInternal(err))
+			return
+		}
+		wg.Add(1)
+		go func(i int, t federatedTarget) {
+			defer wg.Done()
+			if t.cluster == "" {
+				results[i], errs[i] = handler.localSearch(c.Request, body)
+			} else {
+				results[i], errs[i] = handler.federation.search(c.Request, handler.federation.remotes[t.cluster], body)
+			}
+			for _, docs := range results[i] {
+				for _, d := range docs {
+					d[FederatedTargetField] = t.String()
+				}
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	failed := make(map[string]string)
+	var lastErr error
+	for i, err := range errs {
+		if err != nil {
+			log.Error("federated search of target %s err: %v", targets[i], err)
+			failed[targets[i].String()] = err.Error()
+			lastErr = err
+		}
+	}
+	if len(failed) == len(targets) {
+		response.New(c).JsonError(errors.NewErrInternal(vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("all targets failed, last err: %v", lastErr))))
+		return
+	}
+
+	limit := int(searchDoc.Limit)
+	if limit == 0 {
+		limit = DefaultSize
+	}
+	result := map[string]interface{}{"documents": mergeFederated(results, limit, asc)}
+	if len(failed) > 0 {
+		result["failed_targets"] = failed
+	}
+	annotateResponse(result, searchDoc.Echo, nil)
+	response.New(c).JsonSuccess(result)
+}
+
+// federatedAscending reports whether lower scores rank first, by the metric
+// of the index params of the search, or else of the space of the first
+// local target
+func (handler *DocumentHandler) federatedAscending(ctx context.Context, searchDoc *request.SearchDocumentRequest, targets []federatedTarget) (bool, error) {
+	indexParams := &entity.IndexParams{}
+	if len(searchDoc.IndexParams) > 0 {
+		if err := vjson.Unmarshal(searchDoc.IndexParams, indexParams); err != nil {
+			return false, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unmarshal index_params err: %v", err))
+		}
+		if indexParams.MetricType != "" {
+			return indexParams.MetricType == "L2", nil
+		}
+	}
+	for _, t := range targets {
+		if t.cluster != "" {
+			continue
+		}
+		space, err := handler.docService.getSpace(ctx, &vearchpb.RequestHead{DbName: t.db, SpaceName: t.space})
+		if err != nil {
+			return false, err
+		}
+		if space.Index != nil && len(space.Index.Params) > 0 {
+			if err := vjson.Unmarshal(space.Index.Params, indexParams); err != nil {
+				return false, err
+			}
+		}
+		return indexParams.MetricType == "L2", nil
+	}
+	return false, nil
+}
+
+// localSearch runs the search of a target of this cluster by the handlers
+// of the router, with the auth and params of the federated search
+func (handler *DocumentHandler) localSearch(r *http.Request, body []byte) ([][]map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, "/document/search?"+r.URL.RawQuery, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	req.Header.Del("Content-Length")
+	w := httptest.NewRecorder()
+	handler.httpServer.ServeHTTP(w, req)
+	return decodeFederatedReply(w.Code, w.Body)
+}
+
+// search runs the search on a router of remote, starting by a random one
+// and trying the next on connection errors
+func (f *federation) search(r *http.Request, remote *config.RemoteClusterCfg, body []byte) ([][]map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(remote.Timeout)*time.Millisecond)
+	defer cancel()
+	var lastErr error
+	start := rand.Intn(len(remote.Endpoints))
+	for i := range remote.Endpoints {
+		endpoint := remote.Endpoints[(start+i)%len(remote.Endpoints)]
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/document/search?"+r.URL.RawQuery, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+		if remote.User != "" {
+			req.SetBasicAuth(remote.User, remote.Password)
+		} else if auth := r.Header.Get("Authorization"); auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := f.http.Do(req)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		docs, err := decodeFederatedReply(resp.StatusCode, resp.Body)
+		resp.Body.Close()
+		return docs, err
+	}
+	return nil, fmt.Errorf("remote cluster %s unreachable, last err: %v", remote.Name, lastErr)
+}
+
+// mergeFederated merges the documents of each query over the targets by
+// score and keeps the first limit of them
+func mergeFederated(results [][][]map[string]interface{}, limit int, asc bool) [][]map[string]interface{} {
+	queries := 0
+	for _, docs := range results {
+		if len(docs) > queries {
+			queries = len(docs)
+		}
+	}
+	merged := make([][]map[string]interface{}, queries)
+	for q := range merged {
+		merged[q] = make([]map[string]interface{}, 0)
+		for _, docs := range results {
+			if q < len(docs) {
+				merged[q] = append(merged[q], docs[q]...)
+			}
+		}
+		hits := merged[q]
+		sort.SliceStable(hits, func(i, j int) bool {
+			if asc {
+				return federatedScore(hits[i]) < federatedScore(hits[j])
+			}
+			return federatedScore(hits[i]) > federatedScore(hits[j])
+		})
+		if len(hits) > limit {
+			merged[q] = hits[:limit]
+		}
+	}
+	return merged
+}
+
+func federatedScore(doc map[string]interface{}) float64 {
+	switch score := doc["_score"].(type) {
+	case json.Number:
+		f, _ := score.Float64()
+		return f
+	case float64:
+		return score
+	}
+	return 0
+}
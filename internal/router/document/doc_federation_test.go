@@ -0,0 +1,103 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/config"
+)
+
+func TestParseFederatedTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		want    federatedTarget
+		wantErr bool
+	}{
+		{name: "local", target: "db/space", want: federatedTarget{db: "db", space: "space"}},
+		{name: "remote", target: "eu:db/space", want: federatedTarget{cluster: "eu", db: "db", space: "space"}},
+		{name: "empty cluster", target: ":db/space", wantErr: true},
+		{name: "no space", target: "eu:db", wantErr: true},
+		{name: "too many parts", target: "db/space/x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFederatedTarget(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFederatedTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got != tt.want || got.String() != tt.target) {
+				t.Errorf("parseFederatedTarget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFederation(t *testing.T) {
+	tests := []struct {
+		name    string
+		remotes []*config.RemoteClusterCfg
+		wantErr bool
+	}{
+		{name: "valid", remotes: []*config.RemoteClusterCfg{{Name: "eu", Endpoints: []string{"https://router.eu:9001/"}}}},
+		{name: "no endpoints", remotes: []*config.RemoteClusterCfg{{Name: "eu"}}, wantErr: true},
+		{name: "bad endpoint", remotes: []*config.RemoteClusterCfg{{Name: "eu", Endpoints: []string{"router.eu:9001"}}}, wantErr: true},
+		{name: "name with colon", remotes: []*config.RemoteClusterCfg{{Name: "e:u", Endpoints: []string{"http://router"}}}, wantErr: true},
+		{name: "duplicate", remotes: []*config.RemoteClusterCfg{{Name: "eu", Endpoints: []string{"http://a"}}, {Name: "eu", Endpoints: []string{"http://b"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newFederation(tt.remotes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newFederation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				remote := f.remotes["eu"]
+				if remote.Endpoints[0] != "https://router.eu:9001" || remote.Timeout != defaultRemoteTimeout {
+					t.Errorf("remote = %+v, want endpoint trimmed and timeout defaulted", remote)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeFederated(t *testing.T) {
+	doc := func(id string, score string) map[string]interface{} {
+		return map[string]interface{}{"_id": id, "_score": json.Number(score)}
+	}
+	results := [][][]map[string]interface{}{
+		{{doc("a1", "0.9"), doc("a2", "0.5")}, {doc("a3", "0.1")}},
+		{{doc("b1", "0.7"), doc("b2", "0.6")}},
+	}
+	ids := func(merged [][]map[string]interface{}) [][]string {
+		out := make([][]string, len(merged))
+		for i, docs := range merged {
+			out[i] = []string{}
+			for _, d := range docs {
+				out[i] = append(out[i], d["_id"].(string))
+			}
+		}
+		return out
+	}
+	if got, want := ids(mergeFederated(results, 3, false)), [][]string{{"a1", "b1", "b2"}, {"a3"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeFederated() = %v, want %v", got, want)
+	}
+	if got, want := ids(mergeFederated(results, 2, true)), [][]string{{"a2", "b2"}, {"a3"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeFederated() ascending = %v, want %v", got, want)
+	}
+}
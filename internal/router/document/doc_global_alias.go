@@ -0,0 +1,127 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// globalAliases holds the global aliases stored in etcd by name, kept in
+// sync by watch so a migration switches the targets of searches at once
+type globalAliases struct {
+	mu      sync.RWMutex
+	aliases map[string]*entity.GlobalAlias
+}
+
+func startGlobalAliases(cli *client.Client) *globalAliases {
+	g := &globalAliases{aliases: make(map[string]*entity.GlobalAlias)}
+	go g.watch(context.Background(), cli)
+	return g
+}
+
+// watch loads all the aliases and then applies the changes, it retries when
+// etcd is not reachable
+func (g *globalAliases) watch(ctx context.Context, cli *client.Client) {
+	for {
+		watcher, err := cli.Master().WatchPrefix(ctx, entity.PrefixGlobalAlias)
+		if err != nil {
+			log.Error("watch global aliases err: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		// aliases changed before the watch starts are got by scan
+		_, values, err := cli.Master().PrefixScan(ctx, entity.PrefixGlobalAlias)
+		if err != nil {
+			log.Error("scan global aliases err: %v", err)
+		} else {
+			aliases := make(map[string]*entity.GlobalAlias, len(values))
+			for _, value := range values {
+				if alias := decodeGlobalAlias(value); alias != nil {
+					aliases[alias.Name] = alias
+				}
+			}
+			g.mu.Lock()
+			g.aliases = aliases
+			g.mu.Unlock()
+		}
+		for reps := range watcher {
+			if reps.Canceled {
+				log.Error("global aliases watcher is canceled")
+				break
+			}
+			for _, event := range reps.Events {
+				name := strings.TrimPrefix(string(event.Kv.Key), entity.PrefixGlobalAlias)
+				if event.Type == mvccpb.PUT {
+					if alias := decodeGlobalAlias(event.Kv.Value); alias != nil {
+						log.Info("global alias %s changed to %s", alias.Name, alias.Target())
+						g.mu.Lock()
+						g.aliases[alias.Name] = alias
+						g.mu.Unlock()
+					}
+				} else {
+					log.Info("global alias %s deleted", name)
+					g.mu.Lock()
+					delete(g.aliases, name)
+					g.mu.Unlock()
+				}
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func decodeGlobalAlias(value []byte) *entity.GlobalAlias {
+	alias := &entity.GlobalAlias{}
+	if err := vjson.Unmarshal(value, alias); err != nil {
+		log.Error("decode global alias err: %v", err)
+		return nil
+	}
+	return alias
+}
+
+func (g *globalAliases) get(name string) *entity.GlobalAlias {
+	if g == nil {
+		return nil
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.aliases[name]
+}
+
+func (g *globalAliases) list() []*entity.GlobalAlias {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	aliases := make([]*entity.GlobalAlias, 0, len(g.aliases))
+	for _, alias := range g.aliases {
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+// handleGetConfigGlobalAliases replies the global aliases seen by this router
+func (handler *DocumentHandler) handleGetConfigGlobalAliases(c *gin.Context) {
+	response.New(c).JsonSuccess(handler.globalAliases.list())
+}
@@ -17,9 +17,12 @@ package document
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -33,6 +36,8 @@ import (
 	"github.com/vearch/vearch/v3/internal/entity/response"
 	"github.com/vearch/vearch/v3/internal/master"
 	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/diagnose"
+	"github.com/vearch/vearch/v3/internal/pkg/distance"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/netutil"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
@@ -51,14 +56,30 @@ const (
 	URLParamAliasName   = "alias_name"
 	URLParamUserName    = "user_name"
 	URLParamRoleName    = "role_name"
+	URLParamTemplate    = "template_name"
 	URLParamMemberId    = "member_id"
 	defaultTimeout      = 10 * time.Second
 )
 
 type DocumentHandler struct {
-	httpServer *gin.Engine
-	docService docService
-	client     *client.Client
+	httpServer  *gin.Engine
+	docService  docService
+	client      *client.Client
+	shadow      *shadowMirror
+	experiments *experiments
+	queryLog    *queryLog
+	hydration   *hydration
+	usage       *usageMeter
+	readRepair  *readRepair
+	rerankers   map[string]Reranker
+	embedders   map[string]Embedder
+	fairQueue   *fairQueue
+	federation  *federation
+	breakers    *spaceBreakers
+	planner     *filterPlanner
+
+	featureFlags  *featureFlags
+	globalAliases *globalAliases
 }
 
 func BasicAuthMiddleware(docService docService) gin.HandlerFunc {
@@ -120,6 +141,7 @@ func BasicAuthMiddleware(docService docService) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		c.Set(contextRoleName, role.Name)
 
 		c.Next()
 	}
@@ -127,13 +149,64 @@ func BasicAuthMiddleware(docService docService) gin.HandlerFunc {
 
 func ExportDocumentHandler(httpServer *gin.Engine, client *client.Client) {
 	docService := newDocService(client)
+	shadow, err := newShadowMirror(config.Conf().Router.Shadow)
+	if err != nil {
+		panic(err)
+	}
+	experiments, err := newExperiments(config.Conf().Router.Experiment)
+	if err != nil {
+		panic(err)
+	}
+	queryLog, err := newQueryLog(config.Conf().Router.QueryLog)
+	if err != nil {
+		panic(err)
+	}
+	hydration, err := newHydration(config.Conf().Router.Hydration)
+	if err != nil {
+		panic(err)
+	}
+	rerankers, err := newRerankers(config.Conf().Router.Rerankers)
+	if err != nil {
+		panic(err)
+	}
+	if err := distance.LoadPlugins(config.Conf().Router.MetricPlugins); err != nil {
+		panic(err)
+	}
+	federation, err := newFederation(config.Conf().Router.Remotes)
+	if err != nil {
+		panic(err)
+	}
 
 	documentHandler := &DocumentHandler{
-		httpServer: httpServer,
-		docService: *docService,
-		client:     client,
+		httpServer:  httpServer,
+		docService:  *docService,
+		client:      client,
+		shadow:      shadow,
+		experiments: experiments,
+		queryLog:    queryLog,
+		hydration:   hydration,
+		usage:       newUsageMeter(config.Conf().Router.Usage, client),
+		readRepair:  newReadRepair(config.Conf().Router.ReadRepair, client),
+		rerankers:   rerankers,
+		fairQueue:   newFairQueue(config.Conf().Router.FairQueue),
+		federation:  federation,
+		breakers:    newSpaceBreakers(config.Conf().Router.Breaker),
+		planner:     newFilterPlanner(config.Conf().Router.Planner, client),
+
+		featureFlags:  startFeatureFlags(client),
+		globalAliases: startGlobalAliases(client),
 	}
 
+	embedders, err := newEmbedders(config.Conf().Router.Embedders)
+	if err != nil {
+		panic(err)
+	}
+	documentHandler.embedders = embedders
+	startEmbeddingMigrator(documentHandler, embedders)
+	startSimilarityJoiner(documentHandler)
+
+	httpServer.Use(documentHandler.degradedMiddleware)
+
 	var group *gin.RouterGroup
 	var groupProxy *gin.RouterGroup
 	if !config.Conf().Global.SkipAuth {
@@ -146,6 +219,7 @@ func ExportDocumentHandler(httpServer *gin.Engine, client *client.Client) {
 	}
 
 	documentHandler.proxyMaster(groupProxy)
+	groupProxy.GET("/readyz", documentHandler.handleReadyz)
 	group.Use(master.TimeoutMiddleware(defaultTimeout))
 	// open router api
 	if err := documentHandler.ExportInterfacesToServer(group); err != nil {
@@ -154,59 +228,79 @@ func ExportDocumentHandler(httpServer *gin.Engine, client *client.Client) {
 }
 
 func (handler *DocumentHandler) proxyMaster(group *gin.RouterGroup) error {
+	// metadata reads are hinted cacheable by clients as master does
+	metaCache := master.CacheControlMiddleware(config.Conf().Global.MetaCacheMaxAge)
+
 	// server handler
 	group.GET("/servers", handler.handleMasterRequest)
 
 	// partition handler
 	group.GET("/partitions", handler.handleMasterRequest)
+	group.GET("/partitions/:partition_id/_stats", handler.handleMasterRequest)
 	group.POST("/partitions/change_member", handler.handleMasterRequest)
 	group.POST("/partitions/resource_limit", handler.handleMasterRequest)
 
 	group.GET("/routers", handler.handleMasterRequest)
+	group.GET("/discovery/routers", handler.handleMasterRequest)
 
 	// db handler
 	group.POST(fmt.Sprintf("/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
-	group.GET("/dbs", handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/dbs/:%s", URLParamDbName), metaCache, handler.handleMasterRequest)
+	group.GET("/dbs", metaCache, handler.handleMasterRequest)
 	group.DELETE(fmt.Sprintf("/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
 	group.PUT(fmt.Sprintf("/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s/settings", URLParamDbName), handler.handleMasterRequest)
 	group.POST(fmt.Sprintf("/backup/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
 	group.POST(fmt.Sprintf("/backup/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
+	group.POST(fmt.Sprintf("/dbs/:%s/spaces/:%s/index/import", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s/index/import", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
 	// space handler
 	group.POST(fmt.Sprintf("/dbs/:%s/spaces", URLParamDbName), handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/dbs/:%s/spaces", URLParamDbName), handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), metaCache, handler.handleMasterSpaceRequest)
+	group.GET(fmt.Sprintf("/dbs/:%s/spaces", URLParamDbName), metaCache, handler.handleMasterRequest)
 	group.DELETE(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
-	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.handleMasterSpaceRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/search_params", URLParamDbName, URLParamSpaceName), handler.handleMasterSpaceRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/field_aliases", URLParamDbName, URLParamSpaceName), handler.handleMasterSpaceRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/annotations", URLParamDbName, URLParamSpaceName), handler.handleMasterSpaceRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/write_guard", URLParamDbName, URLParamSpaceName), handler.handleMasterSpaceRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s/pipeline", URLParamDbName, URLParamSpaceName), handler.handleMasterSpaceRequest)
 
 	// alias handler
 	group.POST(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", URLParamAliasName, URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/alias/:%s", URLParamAliasName), handler.handleMasterRequest)
-	group.GET("/alias", handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/alias/:%s", URLParamAliasName), metaCache, handler.handleMasterRequest)
+	group.GET("/alias", metaCache, handler.handleMasterRequest)
 	group.DELETE(fmt.Sprintf("/alias/:%s", URLParamAliasName), handler.handleMasterRequest)
 	group.PUT(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", URLParamAliasName, URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
 
 	// user handler
 	group.POST("/users", handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/users/:%s", URLParamUserName), handler.handleMasterRequest)
-	group.GET("/users", handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/users/:%s", URLParamUserName), metaCache, handler.handleMasterRequest)
+	group.GET("/users", metaCache, handler.handleMasterRequest)
 	group.DELETE(fmt.Sprintf("/users/:%s", URLParamUserName), handler.handleMasterRequest)
 	group.PUT("/users", handler.handleMasterRequest)
 
 	// role handler
 	group.POST("/roles", handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/roles/:%s", URLParamRoleName), handler.handleMasterRequest)
-	group.GET("/roles", handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/roles/:%s", URLParamRoleName), metaCache, handler.handleMasterRequest)
+	group.GET("/roles", metaCache, handler.handleMasterRequest)
 	group.DELETE(fmt.Sprintf("/roles/:%s", URLParamRoleName), handler.handleMasterRequest)
 	group.PUT("/roles", handler.handleMasterRequest)
 
+	// space template handler
+	group.POST("/space_templates", handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/space_templates/:%s", URLParamTemplate), metaCache, handler.handleMasterRequest)
+	group.GET("/space_templates", metaCache, handler.handleMasterRequest)
+	group.DELETE(fmt.Sprintf("/space_templates/:%s", URLParamTemplate), handler.handleMasterRequest)
+	group.PUT(fmt.Sprintf("/space_templates/:%s", URLParamTemplate), handler.handleMasterRequest)
+
 	// cluster handler
 	group.GET("/cluster/health", handler.handleMasterRequest)
 	group.GET("/cluster/stats", handler.handleMasterRequest)
 
 	// config handler
 	group.POST("/config/:"+URLParamDbName+"/:"+URLParamSpaceName, handler.handleMasterRequest)
-	group.GET("/config/:"+URLParamDbName+"/:"+URLParamSpaceName, handler.handleMasterRequest)
+	group.GET("/config/:"+URLParamDbName+"/:"+URLParamSpaceName, metaCache, handler.handleMasterRequest)
 
 	// members handler
 	group.GET("/members", handler.handleMasterRequest)
@@ -237,6 +331,38 @@ func (handler *DocumentHandler) handleMasterRequest(c *gin.Context) {
 	response.New(c).SendJsonBytes(res)
 }
 
+// handleMasterSpaceRequest proxies a space describe or update with its
+// conditional headers, and replies the status and version headers of master
+func (handler *DocumentHandler) handleMasterSpaceRequest(c *gin.Context) {
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	header := make(map[string]string)
+	for _, key := range []string{"Authorization", "If-Match", "If-None-Match"} {
+		if value := c.GetHeader(key); value != "" {
+			header[key] = value
+		}
+	}
+	res, resHeader, statusCode, err := handler.client.Master().ProxyHTTPRequestHeader(c.Request.Method, c.Request.RequestURI, string(bodyBytes), header)
+	if err != nil {
+		log.Error("handleMasterSpaceRequest %v", err)
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	for _, key := range []string{"ETag", entity.SpaceVersionHeader} {
+		if value := resHeader.Get(key); value != "" {
+			c.Header(key, value)
+		}
+	}
+	if statusCode == http.StatusNotModified {
+		c.Status(statusCode)
+		return
+	}
+	response.New(c).SetHttpStatus(int64(statusCode)).SendJsonBytes(res)
+}
+
 func (handler *DocumentHandler) ExportInterfacesToServer(group *gin.RouterGroup) error {
 	// router info
 	group.GET("/", handler.handleRouterInfo)
@@ -246,6 +372,19 @@ func (handler *DocumentHandler) ExportInterfacesToServer(group *gin.RouterGroup)
 	group.POST("/document/query", handler.handleDocumentQuery)
 	group.POST("/document/search", handler.handleDocumentSearch)
 	group.POST("/document/delete", handler.handleDocumentDelete)
+	group.GET(fmt.Sprintf("/document/dbs/:%s/spaces/:%s/documents/:%s", URLParamDbName, URLParamSpaceName, URLParamID), handler.handleDocumentGetByID)
+	// recall and latency of index params against ground truth
+	group.POST("/document/evaluate", handler.handleDocumentEvaluate)
+	// sweep index params within latency budget and recommend the defaults
+	group.POST("/document/advise", handler.handleDocumentAdvise)
+	// group near duplicate vectors, report, delete or tag the duplicates
+	group.POST("/document/dedup", handler.handleDocumentDedup)
+	// kmeans labels and 2d projection of sampled vectors for exploration
+	group.POST("/document/cluster", handler.handleDocumentCluster)
+	// write documents to object storage as parquet files with a manifest
+	group.POST("/document/export", handler.handleDocumentExport)
+	// pairwise similarity of two small sets of vectors without index
+	group.POST("/document/similarity", handler.handleDocumentSimilarity)
 
 	// index
 	group.POST("/index/flush", handler.handleIndexFlush)
@@ -255,12 +394,31 @@ func (handler *DocumentHandler) ExportInterfacesToServer(group *gin.RouterGroup)
 	// config
 	// trace: /config/trace
 	group.POST("/config/trace", handler.handleConfigTrace)
+	group.GET("/config/shadow", handler.handleGetConfigShadow)
+	group.POST("/config/shadow", handler.handleConfigShadow)
+	group.GET("/config/circuit_breakers", handler.handleGetConfigCircuitBreakers)
+	group.POST("/config/circuit_breakers", handler.handleConfigCircuitBreakers)
+	group.DELETE("/config/circuit_breakers", handler.handleDeleteConfigCircuitBreakers)
+	group.POST(fmt.Sprintf("/config/circuit_breakers/dbs/:%s/spaces/:%s/reset", URLParamDbName, URLParamSpaceName), handler.handleResetCircuitBreakers)
+	group.GET("/config/experiment", handler.handleGetConfigExperiment)
+	group.POST("/config/experiment", handler.handleConfigExperiment)
+	group.GET("/config/query_log", handler.handleGetConfigQueryLog)
+	group.POST("/config/query_log", handler.handleConfigQueryLog)
+	group.GET("/config/feature_flags", handler.handleGetConfigFeatureFlags)
+	group.GET("/config/global_aliases", handler.handleGetConfigGlobalAliases)
 
 	// cacheInfo
 	// /cache/$dbName/$spaceName
 	group.GET(fmt.Sprintf("/cache/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.cacheSpaceInfo)
 	group.GET(fmt.Sprintf("/cache/users/:%s", URLParamUserName), handler.cacheUserInfo)
 	group.GET(fmt.Sprintf("/cache/roles/:%s", URLParamRoleName), handler.cacheRoleInfo)
+	// meta cache snapshot warming the cache of a starting router, only for
+	// peers calling with the signkey of root
+	group.GET("/cache/snapshot", master.SignkeyAuthMiddleware(config.Conf().Global.Signkey), handler.cacheSnapshot)
+
+	// runtime diagnostics, /debug maps to ResourceAll so only admin can access,
+	// pass timeout param for long cpu profile
+	group.Any("/debug/*path", gin.WrapH(diagnose.NewHandler(config.Conf().GetLogDir())))
 
 	return nil
 }
@@ -297,6 +455,15 @@ func (handler *DocumentHandler) cacheUserInfo(c *gin.Context) {
 	}
 }
 
+func (handler *DocumentHandler) cacheSnapshot(c *gin.Context) {
+	bs, err := handler.client.Master().Cache().MarshalSnapshot()
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrUnavailable(err))
+		return
+	}
+	response.New(c).SendJsonBytes(bs)
+}
+
 func (handler *DocumentHandler) cacheRoleInfo(c *gin.Context) {
 	roleName := c.Param(URLParamRoleName)
 	if space, err := handler.client.Master().Cache().RoleByCache(context.Background(), roleName); err != nil {
@@ -361,8 +528,8 @@ func (handler *DocumentHandler) handleConfigTrace(c *gin.Context) {
 func (handler *DocumentHandler) handleDocumentUpsert(c *gin.Context) {
 	startTime := time.Now()
 	operateName := "handleDocumentUpsert"
-	defer monitor.Profiler(operateName, startTime)
-	span, _ := opentracing.StartSpanFromContext(c.Request.Context(), operateName)
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), operateName)
+	defer monitor.ProfilerContext(ctx, operateName, startTime)
 	defer span.Finish()
 
 	args := &vearchpb.BulkRequest{}
@@ -388,26 +555,95 @@ func (handler *DocumentHandler) handleDocumentUpsert(c *gin.Context) {
 		response.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if err := space.CheckWritable(); err != nil {
+		writesPausedError(c, err)
+		return
+	}
+	done, err := handler.breakers.allow(c, breakerWrite, args.Head.DbName, args.Head.SpaceName)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrUnavailable(err))
+		return
+	}
+	defer done()
+
+	if err := resolveDocumentAliases(docRequest, space); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
 
-	err = documentParse(c.Request.Context(), handler, c.Request, docRequest, space, args)
+	deadLetters := newDeadLetters(dbName, space)
+	dropped, err := handler.runIngestPipeline(c.Request.Context(), docRequest, space, deadLetters)
 	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	var duplicates []*writeDuplicate
+	if len(docRequest.Documents) > 0 {
+		err = handler.parseDocuments(c.Request.Context(), c.Request, docRequest, space, args, deadLetters)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrInternal(err))
+			return
+		}
+		duplicates, err = handler.guardWrites(c.Request.Context(), space, docRequest, args, deadLetters)
+		if err != nil {
+			response.New(c).JsonError(errors.NewErrInternal(err))
+			return
+		}
+	}
+	// the documents rejected before routing are kept before the others are
+	// written, so a failing dead letter fails the whole write
+	rejected := deadLetters.len()
+	if err := handler.flushDeadLetters(c.Request.Context(), c.Request, args.Head, deadLetters); err != nil {
 		response.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if len(args.Docs) == 0 {
+		result := map[string]interface{}{"total": 0, "document_ids": []interface{}{}}
+		if dropped > 0 {
+			result["dropped"] = dropped
+		}
+		if rejected > 0 {
+			result["dead_letter"] = rejected
+		}
+		if len(duplicates) > 0 {
+			result["duplicates"] = duplicates
+		}
+		response.New(c).JsonSuccess(result)
+		return
+	}
+
 	reply := handler.docService.bulk(c.Request.Context(), args)
 	result, err := documentUpsertResponse(reply)
 	if err != nil {
 		response.New(c).JsonError(errors.NewErrUnprocessable(err))
 		return
 	}
+	deadLetterRejected(docRequest, args, reply, deadLetters)
+	if n := deadLetters.len(); n > 0 {
+		// the documents ps rejected have their errors in the response anyway
+		if err := handler.flushDeadLetters(c.Request.Context(), c.Request, args.Head, deadLetters); err != nil {
+			logDeadLetters(deadLetters, err)
+		} else {
+			rejected += n
+		}
+	}
+	if dropped > 0 {
+		result["dropped"] = dropped
+	}
+	if rejected > 0 {
+		result["dead_letter"] = rejected
+	}
+	if len(duplicates) > 0 {
+		result["duplicates"] = duplicates
+	}
 	response.New(c).JsonSuccess(result)
 }
 
 func (handler *DocumentHandler) handleDocumentQuery(c *gin.Context) {
 	startTime := time.Now()
 	operateName := "handleDocumentQuery"
-	defer monitor.Profiler(operateName, startTime)
-	span, _ := opentracing.StartSpanFromContext(c.Request.Context(), operateName)
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), operateName)
+	defer monitor.ProfilerContext(ctx, operateName, startTime)
 	defer span.Finish()
 
 	args := &vearchpb.QueryRequest{}
@@ -417,6 +653,11 @@ func (handler *DocumentHandler) handleDocumentQuery(c *gin.Context) {
 		response.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	asOf, err := entity.ParseAsOf(args.Head.Params)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
 	trace := config.Trace
 	if trace_info, ok := args.Head.Params["trace"]; ok {
 		if trace_info == "true" {
@@ -440,6 +681,25 @@ func (handler *DocumentHandler) handleDocumentQuery(c *gin.Context) {
 	}
 	// update space name because maybe is alias name
 	searchDoc.SpaceName = args.Head.SpaceName
+	done, err := handler.breakers.allow(c, breakerSearch, args.Head.DbName, args.Head.SpaceName)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrUnavailable(err))
+		return
+	}
+	defer done()
+	if err := handler.hydration.prepare(searchDoc); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := prepareSample(searchDoc); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	renames, err := resolveFieldAliases(searchDoc, space)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
 
 	err = queryRequestToPb(searchDoc, space, args)
 	if err != nil {
@@ -458,8 +718,14 @@ func (handler *DocumentHandler) handleDocumentQuery(c *gin.Context) {
 			response.New(c).JsonError(errors.NewErrUnprocessable(err))
 			return
 		}
-		if searchDoc.GetByHash || searchDoc.PartitionId != nil {
-			handler.handleDocumentGet(c, searchDoc, space)
+		if asOf > 0 && searchDoc.PartitionId != nil {
+			err := vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s can not query by partition_id", entity.AsOfParam))
+			response.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+		// only gets see the documents deleted after as_of
+		if searchDoc.GetByHash || searchDoc.PartitionId != nil || asOf > 0 {
+			handler.handleDocumentGet(c, searchDoc, space, renames)
 			return
 		}
 	} else {
@@ -468,30 +734,133 @@ func (handler *DocumentHandler) handleDocumentQuery(c *gin.Context) {
 			response.New(c).JsonError(errors.NewErrBadRequest(err))
 			return
 		}
+		if asOf > 0 {
+			err := vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s needs document_ids to query", entity.AsOfParam))
+			response.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+	}
+
+	plan, err := handler.planner.admitQuery(space, args)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErr(err))
+		return
 	}
 
+	queryCtx, release, err := handler.acquireFairQueue(c.Request.Context(), c, searchDoc.DbName, searchDoc.SpaceName)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrUnavailable(err))
+		return
+	}
+	defer release()
 	serviceStart := time.Now()
-	searchResp := handler.docService.query(c.Request.Context(), args)
+	searchResp := handler.docService.query(queryCtx, args)
 	serviceCost := time.Since(serviceStart)
+	sampleResults(searchDoc.Sample, searchResp.Results)
 
 	result, err := documentQueryResponse(searchResp.Results, searchResp.Head, space)
 	if err != nil {
 		response.New(c).JsonError(errors.NewErrUnprocessable(err))
 		return
 	}
+	renameFields(result, renames)
+	handler.hydration.hydrate(c.Request.Context(), searchDoc, result)
+	filterSource(result, searchDoc.Source)
+	limitResponse(c, config.Conf().Router.ResponseLimit, searchDoc.DbName, searchDoc.SpaceName, result)
+	if plan != nil && searchDoc.Explain {
+		result["filter_plan"] = plan
+	}
 	response.New(c).JsonSuccess(result)
+	handler.usage.record(c, searchDoc.DbName, searchDoc.SpaceName, len(resultDocuments(result)), 0)
 	if trace {
 		log.Trace("handleDocumentQuery total use :[%.4f] service use :[%.4f] detail use :[%v]", time.Since(startTime).Seconds()*1000, serviceCost.Seconds()*1000, searchResp.Head.Params)
 	}
 }
 
-func (handler *DocumentHandler) handleDocumentGet(c *gin.Context, searchDoc *request.SearchDocumentRequest, space *entity.Space) {
+func (handler *DocumentHandler) handleDocumentGet(c *gin.Context, searchDoc *request.SearchDocumentRequest, space *entity.Space, renames map[string][]string) {
+	result, err := handler.documentGetResult(c, searchDoc, space, renames)
+	if err != nil {
+		response.New(c).JsonError(err)
+		return
+	}
+	response.New(c).JsonSuccess(result)
+	handler.usage.record(c, searchDoc.DbName, searchDoc.SpaceName, len(resultDocuments(result)), 0)
+}
+
+// handleDocumentGetByID gets a document by GET with fields and vector_value
+// url params, tagged by the etag of its content so http caches revalidate it
+func (handler *DocumentHandler) handleDocumentGetByID(c *gin.Context) {
+	startTime := time.Now()
+	operateName := "handleDocumentGetByID"
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), operateName)
+	defer monitor.ProfilerContext(ctx, operateName, startTime)
+	defer span.Finish()
+
+	searchDoc := &request.SearchDocumentRequest{
+		DbName:      c.Param(URLParamDbName),
+		SpaceName:   c.Param(URLParamSpaceName),
+		DocumentIds: &[]string{c.Param(URLParamID)},
+		VectorValue: c.Query("vector_value") == "true",
+		GetByHash:   true,
+	}
+	if fields := c.Query("fields"); fields != "" {
+		searchDoc.Fields = strings.Split(fields, ",")
+	}
+	head := &vearchpb.RequestHead{DbName: searchDoc.DbName, SpaceName: searchDoc.SpaceName}
+	space, err := handler.docService.getSpace(ctx, head)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	// update space name because maybe is alias name
+	searchDoc.SpaceName = head.SpaceName
+	renames, err := resolveFieldAliases(searchDoc, space)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	result, rerr := handler.documentGetResult(c, searchDoc, space, renames)
+	if rerr != nil {
+		response.New(c).JsonError(rerr)
+		return
+	}
+	docs := resultDocuments(result)
+	if len(docs) == 0 || docs[0]["code"] != nil {
+		err := vearchpb.NewError(vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST, fmt.Errorf("document %s not found in space %s", c.Param(URLParamID), space.Name))
+		response.New(c).JsonError(errors.NewErrNotFound(err))
+		return
+	}
+	etag, err := documentETag(docs[0])
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	c.Header("Cache-Control", "private, no-cache")
+	response.New(c).JsonSuccessETag(result, etag)
+	handler.usage.record(c, searchDoc.DbName, searchDoc.SpaceName, len(docs), 0)
+}
+
+// documentETag is the strong etag of the content of a document as returned,
+// it changes whenever the document is written with other values
+func documentETag(doc map[string]interface{}) (string, error) {
+	// encoding/json sorts map keys so equal documents have equal bytes
+	bs, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	h.Write(bs)
+	return fmt.Sprintf("\"%016x\"", h.Sum64()), nil
+}
+
+// documentGetResult gets the documents of searchDoc.DocumentIds
+func (handler *DocumentHandler) documentGetResult(c *gin.Context, searchDoc *request.SearchDocumentRequest, space *entity.Space, renames map[string][]string) (map[string]interface{}, *errors.ErrRequest) {
 	args := &vearchpb.GetRequest{}
 	var err error
 	args.Head, err = setRequestHeadFromGin(c)
 	if err != nil {
-		response.New(c).JsonError(errors.NewErrInternal(err))
-		return
+		return nil, errors.NewErrInternal(err)
 	}
 	args.Head.DbName = searchDoc.DbName
 	args.Head.SpaceName = searchDoc.SpaceName
@@ -512,32 +881,45 @@ func (handler *DocumentHandler) handleDocumentGet(c *gin.Context, searchDoc *req
 		}
 		if !found {
 			err := vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("partition_id %d not belong to space %s", *searchDoc.PartitionId, space.Name))
-			response.New(c).JsonError(errors.NewErrBadRequest(err))
-			return
+			return nil, errors.NewErrBadRequest(err)
 		}
 	}
 
 	var reply *vearchpb.GetResponse
+	var divergences []*entity.ReplicaDivergence
 	if searchDoc.PartitionId != nil {
 		reply = handler.docService.getDocsByPartition(c.Request.Context(), args, *searchDoc.PartitionId, searchDoc.Next)
+	} else if searchDoc.ConsistencyCheck {
+		reply, divergences = handler.docService.getDocsCheckReplicas(c.Request.Context(), args)
+		if handler.featureFlags.enabled(c, entity.FeatureReadRepair, searchDoc.DbName, searchDoc.SpaceName) {
+			handler.readRepair.trigger(searchDoc.DbName, searchDoc.SpaceName, divergences)
+		}
 	} else {
 		reply = handler.docService.getDocs(c.Request.Context(), args)
 	}
 
-	if result, err := documentGetResponse(space, reply, queryFieldsParam, searchDoc.VectorValue); err != nil {
-		response.New(c).JsonError(errors.NewErrInternal(err))
-		return
-	} else {
-		response.New(c).JsonSuccess(result)
-		return
+	result, err := documentGetResponse(space, reply, queryFieldsParam, searchDoc.VectorValue)
+	if err != nil {
+		return nil, errors.NewErrInternal(err)
 	}
+	renameFields(result, renames)
+	handler.hydration.hydrate(c.Request.Context(), searchDoc, result)
+	filterSource(result, searchDoc.Source)
+	limitResponse(c, config.Conf().Router.ResponseLimit, searchDoc.DbName, searchDoc.SpaceName, result)
+	if searchDoc.ConsistencyCheck {
+		result["consistent"] = len(divergences) == 0
+		if len(divergences) > 0 {
+			result["divergences"] = divergences
+		}
+	}
+	return result, nil
 }
 
 func (handler *DocumentHandler) handleDocumentSearch(c *gin.Context) {
 	startTime := time.Now()
 	operateName := "handleDocumentSearch"
-	defer monitor.Profiler(operateName, startTime)
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), operateName)
+	defer monitor.ProfilerContext(ctx, operateName, startTime)
 	defer span.Finish()
 	searchReq := &vearchpb.SearchRequest{}
 	var err error
@@ -546,12 +928,34 @@ func (handler *DocumentHandler) handleDocumentSearch(c *gin.Context) {
 		response.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if _, err := entity.ParseAsOf(searchReq.Head.Params); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
 	searchDoc := &request.SearchDocumentRequest{}
 	err = c.ShouldBindJSON(searchDoc)
 	if err != nil {
 		response.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
+	if err := checkEcho(searchDoc.Echo); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if len(searchDoc.Targets) > 0 {
+		handler.handleFederatedSearch(c, searchDoc)
+		return
+	}
+	captured := handler.queryLog.sample(searchDoc)
+	experiment, variant, err := handler.experimentSearch(c, searchDoc)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	success := false
+	if experiment != "" {
+		defer func() { monitor.ExperimentObserve(experiment, variant, success, time.Since(startTime)) }()
+	}
 	searchReq.Head.DbName = searchDoc.DbName
 	searchReq.Head.SpaceName = searchDoc.SpaceName
 
@@ -571,22 +975,93 @@ func (handler *DocumentHandler) handleDocumentSearch(c *gin.Context) {
 	// update space name because maybe is alias name
 	searchDoc.SpaceName = searchReq.Head.SpaceName
 	getSpaceCost := time.Since(getSpaceStart)
+	done, err := handler.breakers.allow(c, breakerSearch, searchReq.Head.DbName, searchReq.Head.SpaceName)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrUnavailable(err))
+		return
+	}
+	defer done()
+	if err := handler.hydration.prepare(searchDoc); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := prepareDither(searchDoc, space); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	limit := searchDoc.Limit
+	if limit == 0 {
+		limit = DefaultSize
+	}
+	exclude, err := handler.fetchQueryVectors(ctx, searchReq.Head, space, searchDoc)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	renames, err := resolveFieldAliases(searchDoc, space)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	pipeline, err := newPipeline(space, searchDoc, handler.rerankers)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
 
 	err = requestToPb(searchDoc, space, searchReq)
 	if err != nil {
 		response.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
+	if err := ditherVectors(searchDoc.Dither, space, searchReq); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if pipeline != nil {
+		if err := pipeline.prepare(space, searchReq); err != nil {
+			response.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+	}
 
 	if searchReq.VecFields == nil {
 		err := vearchpb.NewError(vearchpb.ErrorEnum_SEARCH_INVALID_PARAMS_SHOULD_HAVE_VECTOR_FIELD, nil)
 		response.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	plan := handler.planner.planSearch(space, searchReq)
 
+	ctx, release, err := handler.acquireFairQueue(ctx, c, searchDoc.DbName, searchDoc.SpaceName)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrUnavailable(err))
+		return
+	}
+	defer release()
+	// a search with its own timeout splits it among the stages
+	var budget *searchBudget
+	if handler.featureFlags.enabled(c, entity.FeatureSearchBudget, searchDoc.DbName, searchDoc.SpaceName) {
+		budget = newSearchBudget(ctx, searchReq.Head, pipeline != nil && len(pipeline.stages) > 1, searchDoc.Hydrate)
+	}
+	fanoutCtx, cancelFanout := budget.fanout(ctx)
 	serviceStart := time.Now()
-	searchResp := handler.docService.search(ctx, searchReq)
+	searchResp := handler.docService.search(fanoutCtx, searchReq)
 	serviceCost := time.Since(serviceStart)
+	cancelFanout()
+	if pipeline != nil && (searchResp.Head == nil || searchResp.Head.Err == nil || searchResp.Head.Err.Code == vearchpb.ErrorEnum_SUCCESS) {
+		if err := pipeline.run(ctx, searchResp.Results, serviceCost, budget); err != nil {
+			response.New(c).JsonError(errors.NewErrInternal(err))
+			return
+		}
+	}
+	excludeResults(exclude, limit, searchResp.Results)
+
+	if searchDoc.MMR != nil && (searchResp.Head == nil || searchResp.Head.Err == nil || searchResp.Head.Err.Code == vearchpb.ErrorEnum_SUCCESS) {
+		if err := diversify(searchDoc.MMR, limit, searchResp.Results); err != nil {
+			response.New(c).JsonError(errors.NewErrInternal(err))
+			return
+		}
+	}
 
 	result, err := documentSearchResponse(searchResp.Results, searchResp.Head, space)
 
@@ -594,7 +1069,37 @@ func (handler *DocumentHandler) handleDocumentSearch(c *gin.Context) {
 		response.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	renameFields(result, renames)
+	if hydrateCtx, cancelHydrate, ok := budget.hydrateStage(ctx); ok {
+		handler.hydration.hydrate(hydrateCtx, searchDoc, result)
+		cancelHydrate()
+	} else if searchDoc.Hydrate {
+		budget.skip(budgetStageHydrate)
+	}
+	filterSource(result, searchDoc.Source)
+	limitResponse(c, config.Conf().Router.ResponseLimit, searchDoc.DbName, searchDoc.SpaceName, result)
+	if variant != "" {
+		result["variant"] = variant
+	}
+	if budget.degraded() {
+		result["degraded"] = true
+		result["skipped_stages"] = budget.skipped
+	}
+	if pipeline != nil && searchDoc.Explain {
+		result["explain"] = pipeline.explain
+	}
+	if plan != nil && searchDoc.Explain {
+		result["filter_plan"] = plan
+	}
+	annotateResponse(result, searchDoc.Echo, space)
+	success = true
 	response.New(c).JsonSuccess(result)
+	// each query vector is searched in every partition of space
+	handler.usage.record(c, searchDoc.DbName, searchDoc.SpaceName, len(resultDocuments(result)), len(searchResp.Results)*len(space.Partitions))
+	handler.queryLog.capture(c.FullPath(), captured, startTime)
+	if handler.featureFlags.enabled(c, entity.FeatureShadow, searchDoc.DbName, searchDoc.SpaceName) {
+		handler.mirrorSearch(searchDoc, searchResp.Results)
+	}
 	if trace {
 		log.Trace("handleDocumentSearch %s total: [%.4f] getSpace: [%.4f] service: [%.4f] detail: [%v]",
 			searchReq.Head.Params["request_id"], time.Since(startTime).Seconds()*1000, getSpaceCost.Seconds()*1000, serviceCost.Seconds()*1000, searchResp.Head.Params)
@@ -634,8 +1139,22 @@ func (handler *DocumentHandler) handleDocumentDelete(c *gin.Context) {
 		response.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if err := space.CheckWritable(); err != nil {
+		writesPausedError(c, err)
+		return
+	}
+	done, err := handler.breakers.allow(c, breakerWrite, args.Head.DbName, args.Head.SpaceName)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrUnavailable(err))
+		return
+	}
+	defer done()
 	// update space name because maybe is alias name
 	searchDoc.SpaceName = args.Head.SpaceName
+	if _, err := resolveFieldAliases(searchDoc, space); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
 
 	err = queryRequestToPb(searchDoc, space, args)
 	if err != nil {
@@ -782,3 +1301,10 @@ func (handler *DocumentHandler) handleIndexRebuild(c *gin.Context) {
 
 	response.New(c).JsonSuccess(result)
 }
+
+// writesPausedError replies 503 with Retry-After to the writes of a paused
+// space, clients retry them once the space is resumed
+func writesPausedError(c *gin.Context, err error) {
+	c.Header("Retry-After", strconv.Itoa(entity.WritePauseRetryAfter))
+	response.New(c).JsonError(errors.NewErr(err))
+}
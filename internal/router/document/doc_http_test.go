@@ -0,0 +1,53 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import "testing"
+
+func TestDocumentETag(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  map[string]interface{}
+		equal bool
+	}{
+		{
+			name:  "Same content in any order",
+			a:     map[string]interface{}{"_id": "1", "a": 1, "b": "x"},
+			b:     map[string]interface{}{"b": "x", "a": 1, "_id": "1"},
+			equal: true,
+		},
+		{
+			name:  "Changed field",
+			a:     map[string]interface{}{"_id": "1", "a": 1},
+			b:     map[string]interface{}{"_id": "1", "a": 2},
+			equal: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := documentETag(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := documentETag(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (a == b) != tt.equal {
+				t.Errorf("documentETag() = %s and %s, want equal %v", a, b, tt.equal)
+			}
+		})
+	}
+}
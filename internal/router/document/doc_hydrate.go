@@ -0,0 +1,296 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	HydratorHTTP = "http"
+
+	// HydrateErrorField is set on documents whose payload fetch failed
+	HydrateErrorField = "_hydrate_error"
+
+	defaultHydrateTimeout     = 1000 // ms
+	defaultHydrateConcurrency = 16
+)
+
+// Hydrator fetches the payload of a document from an external store, a nil
+// payload without error means the reference is not found
+type Hydrator interface {
+	Fetch(ctx context.Context, ref string) (map[string]interface{}, error)
+}
+
+// HydratorFactory builds a hydrator from its router config
+type HydratorFactory func(cfg *config.HydrationCfg) (Hydrator, error)
+
+var (
+	hydratorsMu sync.RWMutex
+	hydrators   = map[string]HydratorFactory{HydratorHTTP: newHTTPHydrator}
+)
+
+// RegisterHydrator makes a hydrator usable as plugin in router hydration
+// config, it should be called before the router starts
+func RegisterHydrator(name string, factory HydratorFactory) {
+	hydratorsMu.Lock()
+	defer hydratorsMu.Unlock()
+	hydrators[name] = factory
+}
+
+// hydration holds the hydration rules of this router by space
+type hydration struct {
+	rules map[string]*hydrationRule
+}
+
+type hydrationRule struct {
+	cfg      *config.HydrationCfg
+	hydrator Hydrator
+	cache    *hydrateCache
+	inflight chan struct{}
+}
+
+func newHydration(cfgs []*config.HydrationCfg) (*hydration, error) {
+	h := &hydration{rules: make(map[string]*hydrationRule)}
+	for _, cfg := range cfgs {
+		if cfg.DbName == "" || cfg.SpaceName == "" || cfg.RefField == "" {
+			return nil, fmt.Errorf("hydration db_name, space_name and ref_field should not be empty")
+		}
+		if cfg.Plugin == "" {
+			cfg.Plugin = HydratorHTTP
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = defaultHydrateTimeout
+		}
+		if cfg.Concurrency <= 0 {
+			cfg.Concurrency = defaultHydrateConcurrency
+		}
+		hydratorsMu.RLock()
+		factory := hydrators[cfg.Plugin]
+		hydratorsMu.RUnlock()
+		if factory == nil {
+			return nil, fmt.Errorf("hydration plugin [%s] not registered", cfg.Plugin)
+		}
+		hydrator, err := factory(cfg)
+		if err != nil {
+			return nil, err
+		}
+		h.rules[shadowKey(cfg.DbName, cfg.SpaceName)] = &hydrationRule{
+			cfg:      cfg,
+			hydrator: hydrator,
+			cache:    newHydrateCache(cfg.CacheSize, time.Duration(cfg.CacheTTL)*time.Second),
+			inflight: make(chan struct{}, cfg.Concurrency),
+		}
+	}
+	return h, nil
+}
+
+// prepare checks the space can be hydrated and fetches its reference field
+func (h *hydration) prepare(searchDoc *request.SearchDocumentRequest) error {
+	if !searchDoc.Hydrate {
+		return nil
+	}
+	if searchDoc.HydrateTimeout < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("hydrate_timeout should not be negative"))
+	}
+	rule := h.rules[shadowKey(searchDoc.DbName, searchDoc.SpaceName)]
+	if rule == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space %s/%s has no hydration", searchDoc.DbName, searchDoc.SpaceName))
+	}
+	if len(searchDoc.Fields) == 0 {
+		return nil
+	}
+	for _, field := range searchDoc.Fields {
+		if field == rule.cfg.RefField {
+			return nil
+		}
+	}
+	searchDoc.Fields = append(searchDoc.Fields, rule.cfg.RefField)
+	return nil
+}
+
+// hydrate merges the payloads into the documents of a read response, the
+// fields of the space win over the payload fields of the same name
+func (h *hydration) hydrate(ctx context.Context, searchDoc *request.SearchDocumentRequest, result map[string]interface{}) {
+	if !searchDoc.Hydrate {
+		return
+	}
+	rule := h.rules[shadowKey(searchDoc.DbName, searchDoc.SpaceName)]
+	if rule == nil {
+		return
+	}
+	docs := resultDocuments(result)
+
+	timeout := rule.cfg.Timeout
+	if searchDoc.HydrateTimeout > 0 && int(searchDoc.HydrateTimeout) < timeout {
+		timeout = int(searchDoc.HydrateTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, doc := range docs {
+		ref, ok := doc[rule.cfg.RefField]
+		if !ok || ref == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(doc map[string]interface{}, ref string) {
+			defer wg.Done()
+			payload, err := rule.fetch(ctx, ref)
+			if err != nil {
+				doc[HydrateErrorField] = err.Error()
+				return
+			}
+			for key, value := range payload {
+				if _, ok := doc[key]; !ok {
+					doc[key] = value
+				}
+			}
+		}(doc, fmt.Sprint(ref))
+	}
+	wg.Wait()
+}
+
+func (rule *hydrationRule) fetch(ctx context.Context, ref string) (map[string]interface{}, error) {
+	if payload, ok := rule.cache.get(ref); ok {
+		return payload, nil
+	}
+	select {
+	case rule.inflight <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	payload, err := rule.hydrator.Fetch(ctx, ref)
+	<-rule.inflight
+	if err != nil {
+		return nil, err
+	}
+	rule.cache.put(ref, payload)
+	return payload, nil
+}
+
+// hydrateCache is a lru cache of payloads expiring after ttl, payloads are
+// shared by the documents and must not be modified
+type hydrateCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type hydrateEntry struct {
+	ref     string
+	payload map[string]interface{}
+	expire  time.Time
+}
+
+func newHydrateCache(size int, ttl time.Duration) *hydrateCache {
+	return &hydrateCache{size: size, ttl: ttl, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+func (c *hydrateCache) get(ref string) (map[string]interface{}, bool) {
+	if c.size <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[ref]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*hydrateEntry)
+	if c.ttl > 0 && time.Now().After(entry.expire) {
+		c.lru.Remove(e)
+		delete(c.entries, ref)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.payload, true
+}
+
+func (c *hydrateCache) put(ref string, payload map[string]interface{}) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &hydrateEntry{ref: ref, payload: payload, expire: time.Now().Add(c.ttl)}
+	if e, ok := c.entries[ref]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[ref] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		last := c.lru.Back()
+		c.lru.Remove(last)
+		delete(c.entries, last.Value.(*hydrateEntry).ref)
+	}
+}
+
+// httpHydrator gets the payload as a json object from the url of the reference
+type httpHydrator struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPHydrator(cfg *config.HydrationCfg) (Hydrator, error) {
+	if !strings.Contains(cfg.Url, "{ref}") {
+		return nil, fmt.Errorf("hydration url should contain {ref}")
+	}
+	return &httpHydrator{url: cfg.Url, headers: cfg.Headers, client: &http.Client{}}, nil
+}
+
+func (h *httpHydrator) Fetch(ctx context.Context, ref string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(h.url, "{ref}", url.PathEscape(ref)), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range h.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("hydrate [%s] status %d: %s", ref, resp.StatusCode, string(body))
+	}
+	payload := make(map[string]interface{})
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
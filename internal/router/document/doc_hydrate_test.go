@@ -0,0 +1,124 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+)
+
+// mapHydrator serves payloads from a map and counts the fetches
+type mapHydrator struct {
+	payloads map[string]map[string]interface{}
+	fetches  int32
+}
+
+func (h *mapHydrator) Fetch(ctx context.Context, ref string) (map[string]interface{}, error) {
+	atomic.AddInt32(&h.fetches, 1)
+	if ref == "broken" {
+		return nil, fmt.Errorf("store unavailable")
+	}
+	return h.payloads[ref], nil
+}
+
+func TestHydration_hydrate(t *testing.T) {
+	hydrator := &mapHydrator{payloads: map[string]map[string]interface{}{
+		"r1": {"body": "long text", "title": "payload title"},
+	}}
+	RegisterHydrator("map", func(cfg *config.HydrationCfg) (Hydrator, error) { return hydrator, nil })
+	h, err := newHydration([]*config.HydrationCfg{{DbName: "db", SpaceName: "space", RefField: "ref", Plugin: "map", CacheSize: 8}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searchDoc := &request.SearchDocumentRequest{Hydrate: true, Fields: []string{"title"}}
+	searchDoc.DbName, searchDoc.SpaceName = "db", "space"
+	if err := h.prepare(searchDoc); err != nil {
+		t.Fatal(err)
+	}
+	if len(searchDoc.Fields) != 2 || searchDoc.Fields[1] != "ref" {
+		t.Errorf("prepare() fields = %v, want the ref field fetched", searchDoc.Fields)
+	}
+
+	docs := []map[string]interface{}{
+		{"title": "space title", "ref": "r1"},
+		{"title": "b", "ref": "broken"},
+		{"title": "c"},
+		{"title": "d", "ref": "r1"},
+	}
+	h.hydrate(context.Background(), searchDoc, map[string]interface{}{"documents": docs})
+	if docs[0]["body"] != "long text" || docs[0]["title"] != "space title" {
+		t.Errorf("doc 0 = %v, want payload merged and space fields kept", docs[0])
+	}
+	if docs[1][HydrateErrorField] == nil {
+		t.Errorf("doc 1 = %v, want hydrate error", docs[1])
+	}
+	if len(docs[2]) != 1 {
+		t.Errorf("doc 2 = %v, want no hydration without ref", docs[2])
+	}
+	if docs[3]["body"] != "long text" {
+		t.Errorf("doc 3 = %v, want payload merged", docs[3])
+	}
+
+	before := atomic.LoadInt32(&hydrator.fetches)
+	h.hydrate(context.Background(), searchDoc, map[string]interface{}{"documents": []map[string]interface{}{{"ref": "r1"}}})
+	if atomic.LoadInt32(&hydrator.fetches) != before {
+		t.Errorf("cached payload should not be fetched again")
+	}
+
+	other := &request.SearchDocumentRequest{Hydrate: true}
+	other.DbName, other.SpaceName = "db", "other"
+	if err := h.prepare(other); err == nil {
+		t.Errorf("space without hydration should fail")
+	}
+
+	negative := &request.SearchDocumentRequest{Hydrate: true, HydrateTimeout: -1}
+	negative.DbName, negative.SpaceName = "db", "space"
+	if err := h.prepare(negative); err == nil {
+		t.Errorf("negative hydrate_timeout should fail")
+	}
+}
+
+func TestHydrateCache(t *testing.T) {
+	c := newHydrateCache(2, time.Hour)
+	c.put("a", map[string]interface{}{"v": 1})
+	c.put("b", map[string]interface{}{"v": 2})
+	c.get("a")
+	c.put("c", map[string]interface{}{"v": 3})
+	if _, ok := c.get("b"); ok {
+		t.Errorf("least recently used b should be evicted")
+	}
+	for _, ref := range []string{"a", "c"} {
+		if _, ok := c.get(ref); !ok {
+			t.Errorf("%s should be cached", ref)
+		}
+	}
+
+	expired := newHydrateCache(2, time.Nanosecond)
+	expired.put("a", map[string]interface{}{"v": 1})
+	time.Sleep(time.Millisecond)
+	if _, ok := expired.get("a"); ok {
+		t.Errorf("expired payload should not be got")
+	}
+	if _, ok := newHydrateCache(0, time.Hour).get("a"); ok {
+		t.Errorf("cache of size 0 is disabled")
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// runIngestPipeline runs the ingest processors of space on the documents to
+// write before they are routed to partitions, it removes the documents the
+// pipeline drops and returns their number. The pipeline is the one of the
+// cached space, so an update by master applies to the next writes. With a
+// dead letter the documents a processor fails on are kept for it and removed.
+func (handler *DocumentHandler) runIngestPipeline(ctx context.Context, docRequest *request.DocumentRequest, space *entity.Space, d *deadLetters) (int, error) {
+	if len(space.IngestPipeline) == 0 {
+		return 0, nil
+	}
+	docs := make([]map[string]json.RawMessage, len(docRequest.Documents))
+	rejected := make([]bool, len(docs))
+	reject := func(i int, err error) error {
+		if !d.add(docRequest.Documents[i], entity.DeadLetterStageIngest, err) {
+			return err
+		}
+		docs[i] = nil
+		rejected[i] = true
+		return nil
+	}
+	for i, raw := range docRequest.Documents {
+		var err error
+		if err = vjson.Unmarshal(raw, &docs[i]); err != nil {
+			err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+		} else if docs[i] == nil {
+			err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document %d should be an object", i))
+		}
+		if err != nil {
+			if err := reject(i, err); err != nil {
+				return 0, err
+			}
+		}
+	}
+	for _, p := range space.IngestPipeline {
+		if p.Type == entity.IngestEmbed {
+			if err := handler.ingestEmbed(ctx, p, docs, reject); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		for i, doc := range docs {
+			if doc == nil {
+				continue
+			}
+			drop, err := p.Apply(doc)
+			if err != nil {
+				if err := reject(i, err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			if drop {
+				docs[i] = nil
+			}
+		}
+	}
+
+	dropped := 0
+	kept := docRequest.Documents[:0]
+	for i, doc := range docs {
+		if doc == nil {
+			if !rejected[i] {
+				dropped++
+			}
+			continue
+		}
+		raw, err := vjson.Marshal(doc)
+		if err != nil {
+			return 0, err
+		}
+		kept = append(kept, raw)
+	}
+	docRequest.Documents = kept
+	return dropped, nil
+}
+
+// ingestEmbed sets the target vector of the documents by one call of the
+// embedder for all their texts, reject fails the write or keeps a document
+// for the dead letter
+func (handler *DocumentHandler) ingestEmbed(ctx context.Context, p *entity.IngestProcessor, docs []map[string]json.RawMessage, reject func(i int, err error) error) error {
+	embedder := handler.embedders[p.Embedder]
+	if embedder == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder [%s] of ingest pipeline is not configured on router", p.Embedder))
+	}
+	texts := make([]string, 0, len(docs))
+	targets := make([]int, 0, len(docs))
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		value, ok := doc[p.Field]
+		if !ok {
+			if p.IgnoreMissing {
+				continue
+			}
+			if err := reject(i, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embed ingest processor: document has no field [%s]", p.Field))); err != nil {
+				return err
+			}
+			continue
+		}
+		var text string
+		if err := vjson.Unmarshal(value, &text); err != nil {
+			if err := reject(i, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embed field [%s] should be string", p.Field))); err != nil {
+				return err
+			}
+			continue
+		}
+		texts = append(texts, text)
+		targets = append(targets, i)
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+	vectors, err := embedder.Embed(ctx, texts)
+	if err == nil && len(vectors) != len(texts) {
+		err = fmt.Errorf("returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	if err != nil {
+		err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("embedder [%s] err: %v", p.Embedder, err))
+		for _, i := range targets {
+			if err := reject(i, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for j, i := range targets {
+		raw, err := vjson.Marshal(vectors[j])
+		if err != nil {
+			return err
+		}
+		docs[i][p.TargetField] = raw
+	}
+	return nil
+}
@@ -0,0 +1,467 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/parquet-go/parquet-go"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// errJoinStopped is returned when the join is canceled by others while
+// running
+var errJoinStopped = errors.New("similarity join stopped")
+
+// joinSource is a document of the source space to join
+type joinSource struct {
+	id     string
+	vector []float32
+}
+
+// JoinMatch is a row of the parquet objects written by a join
+type JoinMatch struct {
+	SourceID string  `parquet:"source_id"`
+	TargetID string  `parquet:"target_id"`
+	Score    float64 `parquet:"score"`
+	Rank     int32   `parquet:"rank"`
+}
+
+// similarityJoiner runs the similarity joins of master one at a time, a join
+// is run by one router holding its lock and resumes from its saved progress
+// on another one
+type similarityJoiner struct {
+	handler *DocumentHandler
+	runner  string
+}
+
+func startSimilarityJoiner(handler *DocumentHandler) {
+	runner, _ := os.Hostname()
+	j := &similarityJoiner{handler: handler, runner: runner}
+	go func() {
+		for range time.Tick(migrationCheckInterval) {
+			j.runPending(context.Background())
+		}
+	}()
+}
+
+// runPending runs the running joins not locked by other routers
+func (j *similarityJoiner) runPending(ctx context.Context) {
+	_, values, err := j.handler.client.Master().PrefixScan(ctx, entity.PrefixSimilarityJoin)
+	if err != nil {
+		log.Error("scan similarity joins err: %v", err)
+		return
+	}
+	for _, value := range values {
+		join := &entity.SimilarityJoin{}
+		if err := vjson.Unmarshal(value, join); err != nil {
+			log.Error("unmarshal similarity join err: %v", err)
+			continue
+		}
+		if join.Finished() {
+			continue
+		}
+		lock := j.handler.client.Master().NewLock(ctx, entity.SimilarityJoinLockKey(join.Name), migrationLockTTL)
+		if ok, _ := lock.TryLock(); !ok {
+			continue
+		}
+		err := j.run(ctx, join, lock.KeepAliveOnce)
+		if err != nil && !errors.Is(err, errJoinStopped) {
+			log.Error("similarity join %s failed: %v", join.Name, err)
+			if _, e := j.save(ctx, join.Name, func(saved *entity.SimilarityJoin) {
+				saved.Status = entity.MigrationFailed
+				saved.Error = err.Error()
+				saved.FinishTime = time.Now().Unix()
+			}); e != nil {
+				log.Error("save similarity join %s err: %v", join.Name, e)
+			}
+		}
+		if err := lock.Unlock(); err != nil {
+			log.Error("unlock similarity join %s err: %v", join.Name, err)
+		}
+	}
+}
+
+// run joins the documents batch by batch from the saved progress at the rate
+// of the join, and marks it done after the last partition
+func (j *similarityJoiner) run(ctx context.Context, join *entity.SimilarityJoin, keepAlive func()) error {
+	log.Info("run similarity join %s of space %s/%s from partition %d cursor %d", join.Name,
+		join.DbName, join.SpaceName, join.Partition, join.Cursor)
+	head := &vearchpb.RequestHead{DbName: join.DbName, SpaceName: join.SpaceName, Params: make(map[string]string)}
+	targetHead := &vearchpb.RequestHead{DbName: join.TargetDbName, SpaceName: join.TargetSpaceName, Params: make(map[string]string)}
+
+	var s3 *minio.Client
+	if o := join.Output; o.S3Param != nil {
+		var err error
+		s3, err = minio.New(o.S3Param.EndPoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(o.S3Param.AccessKey, o.S3Param.SecretKey, ""),
+			Secure: o.S3Param.UseSSL,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create minio client: %v", err)
+		}
+	}
+
+	// matches of the filters in the current partition
+	var (
+		filtered          []joinSource
+		filteredPartition = -1
+	)
+	for {
+		keepAlive()
+		space, err := j.handler.docService.getSpace(ctx, head)
+		if err != nil {
+			return err
+		}
+		if join.Partition >= len(space.Partitions) {
+			join, err = j.save(ctx, join.Name, func(saved *entity.SimilarityJoin) {
+				saved.Status = entity.MigrationDone
+				saved.UpdateTime = time.Now().Unix()
+				saved.FinishTime = saved.UpdateTime
+			})
+			if err != nil {
+				return err
+			}
+			log.Info("similarity join %s done, %d documents joined with %d matches", join.Name, join.Joined, join.Matches)
+			return nil
+		}
+		targetSpace, err := j.handler.docService.getSpace(ctx, targetHead)
+		if err != nil {
+			return err
+		}
+		for _, s := range []*entity.Space{space, targetSpace} {
+			if s.Index != nil && s.Index.Type == "BINARYIVF" {
+				return fmt.Errorf("similarity join not support binary vector of space %s", s.Name)
+			}
+		}
+
+		start := time.Now()
+		var (
+			sources []joinSource
+			first   = join.Cursor + 1
+			n       int
+		)
+		if len(join.Filters) > 0 {
+			if filteredPartition != join.Partition {
+				if filtered, err = j.queryFiltered(ctx, head, space, join); err != nil {
+					return err
+				}
+				filteredPartition = join.Partition
+			}
+			sources, n = j.nextFiltered(join, filtered)
+		} else if sources, n, err = j.nextBatch(ctx, head, space, join); err != nil {
+			return err
+		}
+
+		if len(sources) > 0 {
+			matches, err := j.search(ctx, targetHead, targetSpace, join, sources)
+			if err != nil {
+				return err
+			}
+			if s3 != nil {
+				err = j.writeObject(ctx, s3, join, space.Partitions[join.Partition].Id, first, matches)
+			} else {
+				err = j.writeSpace(ctx, join, matches)
+			}
+			if err != nil {
+				return err
+			}
+			join.Joined += int64(len(sources))
+			join.Matches += int64(len(matches))
+		}
+		if join, err = j.save(ctx, join.Name, func(saved *entity.SimilarityJoin) {
+			saved.Partition, saved.Cursor = join.Partition, join.Cursor
+			saved.Joined, saved.Matches, saved.Files = join.Joined, join.Matches, join.Files
+			saved.Runner = j.runner
+			saved.UpdateTime = time.Now().Unix()
+		}); err != nil {
+			return err
+		}
+
+		// documents of a batch take batch / rate seconds at least
+		if wait := time.Duration(n)*time.Second/time.Duration(join.Rate) - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+}
+
+// nextBatch reads the next batch of the current partition, or moves to the
+// next partition if it has no more documents. It returns the documents with
+// the vector and the documents read
+func (j *similarityJoiner) nextBatch(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, join *entity.SimilarityJoin) ([]joinSource, int, error) {
+	n := 0
+	sources := make([]joinSource, 0, join.BatchSize)
+	get := j.handler.partitionGetter(ctx, head, space.Partitions[join.Partition].Id)
+	last, err := scanDocs(ctx, join.Cursor, join.BatchSize, get, func(fields []*vearchpb.Field) (bool, error) {
+		n++
+		source := joinSource{}
+		for _, fv := range fields {
+			switch fv.Name {
+			case entity.IdField:
+				source.id = string(fv.Value)
+			case join.Field:
+				vector, err := cbbytes.ByteToVectorForFloat32(fv.Value)
+				if err != nil {
+					return false, err
+				}
+				source.vector = vector
+			}
+		}
+		if source.id != "" && len(source.vector) > 0 {
+			sources = append(sources, source)
+		}
+		return n < join.BatchSize, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if last == join.Cursor {
+		join.Partition++
+		join.Cursor = -1
+		return nil, 0, nil
+	}
+	join.Cursor = last
+	return sources, n, nil
+}
+
+// queryFiltered returns the documents of the current partition matching the
+// filters of the join, at most MaxDocs of them
+func (j *similarityJoiner) queryFiltered(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, join *entity.SimilarityJoin) ([]joinSource, error) {
+	filters := &request.Filter{}
+	if err := json.Unmarshal(join.Filters, filters); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unmarshal filters err: %v", err))
+	}
+	searchDoc := &request.SearchDocumentRequest{
+		DbName:      join.DbName,
+		SpaceName:   join.SpaceName,
+		Filters:     filters,
+		Fields:      []string{entity.IdField, join.Field},
+		VectorValue: true,
+		Limit:       int32(join.MaxDocs),
+	}
+	args := &vearchpb.QueryRequest{
+		Head: &vearchpb.RequestHead{DbName: head.DbName, SpaceName: head.SpaceName, Params: head.Params},
+	}
+	if err := queryRequestToPb(searchDoc, space, args); err != nil {
+		return nil, err
+	}
+	args.PartitionId = int32(space.Partitions[join.Partition].Id)
+	searchResp := j.handler.docService.query(ctx, args)
+	if h := searchResp.Head; h != nil && h.Err != nil && h.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(h.Err.Code, fmt.Errorf("%s", h.Err.Msg))
+	}
+	var sources []joinSource
+	for _, result := range searchResp.Results {
+		for _, item := range result.ResultItems {
+			for _, fv := range item.Fields {
+				if fv.Name != join.Field {
+					continue
+				}
+				vector, err := cbbytes.ByteToVectorForFloat32(fv.Value)
+				if err != nil {
+					return nil, err
+				}
+				sources = append(sources, joinSource{id: item.PKey, vector: vector})
+			}
+		}
+	}
+	return sources, nil
+}
+
+// nextFiltered returns the next batch of the matches of the filters, Cursor
+// is the index of the last match joined
+func (j *similarityJoiner) nextFiltered(join *entity.SimilarityJoin, filtered []joinSource) ([]joinSource, int) {
+	from := int(join.Cursor) + 1
+	if from >= len(filtered) {
+		join.Partition++
+		join.Cursor = -1
+		return nil, 0
+	}
+	to := from + join.BatchSize
+	if to > len(filtered) {
+		to = len(filtered)
+	}
+	join.Cursor = int32(to - 1)
+	return filtered[from:to], to - from
+}
+
+// search returns the TopK neighbors in the target space of each source
+func (j *similarityJoiner) search(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, join *entity.SimilarityJoin, sources []joinSource) ([]*JoinMatch, error) {
+	features := make([]float32, 0, len(sources)*len(sources[0].vector))
+	for _, s := range sources {
+		features = append(features, s.vector...)
+	}
+	feature, err := json.Marshal(map[string]interface{}{"field": join.TargetField, "feature": features})
+	if err != nil {
+		return nil, err
+	}
+	limit := join.TopK
+	if join.SelfJoin() {
+		limit++
+	}
+	searchDoc := &request.SearchDocumentRequest{
+		DbName:      join.TargetDbName,
+		SpaceName:   join.TargetSpaceName,
+		Limit:       int32(limit),
+		Fields:      []string{entity.IdField},
+		Vectors:     []json.RawMessage{feature},
+		IndexParams: join.IndexParams,
+	}
+	searchReq := &vearchpb.SearchRequest{
+		Head: &vearchpb.RequestHead{DbName: head.DbName, SpaceName: head.SpaceName, Params: make(map[string]string)},
+	}
+	if err := requestToPb(searchDoc, space, searchReq); err != nil {
+		return nil, err
+	}
+	searchResp := j.handler.docService.search(ctx, searchReq)
+	if h := searchResp.Head; h != nil && h.Err != nil && h.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(h.Err.Code, fmt.Errorf("%s", h.Err.Msg))
+	}
+	if len(searchResp.Results) != len(sources) {
+		return nil, fmt.Errorf("search %d vectors of space %s got %d results", len(sources), join.TargetSpaceName, len(searchResp.Results))
+	}
+	matches := make([]*JoinMatch, 0, len(sources)*join.TopK)
+	for i, sr := range searchResp.Results {
+		rank := int32(0)
+		for _, item := range sr.ResultItems {
+			if int(rank) >= join.TopK {
+				break
+			}
+			if join.SelfJoin() && item.PKey == sources[i].id {
+				continue
+			}
+			rank++
+			matches = append(matches, &JoinMatch{SourceID: sources[i].id, TargetID: item.PKey, Score: item.Score, Rank: rank})
+		}
+	}
+	return matches, nil
+}
+
+// writeSpace upserts the matches to the output space, the id of a match is
+// the source id and its rank so a batch joined again replaces its matches
+func (j *similarityJoiner) writeSpace(ctx context.Context, join *entity.SimilarityJoin, matches []*JoinMatch) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	head := &vearchpb.RequestHead{DbName: join.Output.DbName, SpaceName: join.Output.SpaceName, Params: make(map[string]string)}
+	space, err := j.handler.docService.getSpace(ctx, head)
+	if err != nil {
+		return err
+	}
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	source, target, score := proMap[entity.SimilarityJoinSourceField], proMap[entity.SimilarityJoinTargetField], proMap[entity.SimilarityJoinScoreField]
+	if source == nil || target == nil || score == nil {
+		return fmt.Errorf("output space %s should have fields %s, %s and %s", space.Name,
+			entity.SimilarityJoinSourceField, entity.SimilarityJoinTargetField, entity.SimilarityJoinScoreField)
+	}
+
+	docs := make([]*vearchpb.Document, 0, len(matches))
+	for _, m := range matches {
+		fields := make([]*vearchpb.Field, 0, 3)
+		for _, kv := range []struct {
+			pro   *entity.SpaceProperties
+			name  string
+			value string
+		}{
+			{source, entity.SimilarityJoinSourceField, m.SourceID},
+			{target, entity.SimilarityJoinTargetField, m.TargetID},
+			{score, entity.SimilarityJoinScoreField, strconv.FormatFloat(m.Score, 'g', -1, 64)},
+		} {
+			f, err := processString(kv.pro, kv.name, kv.value)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, f)
+		}
+		docs = append(docs, &vearchpb.Document{PKey: fmt.Sprintf("%s_%d", m.SourceID, m.Rank), Fields: fields})
+	}
+	reply := j.handler.docService.bulk(ctx, &vearchpb.BulkRequest{Head: head, Docs: docs})
+	if reply.Head != nil && reply.Head.Err != nil && reply.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return vearchpb.NewError(reply.Head.Err.Code, fmt.Errorf("%s", reply.Head.Err.Msg))
+	}
+	for _, item := range reply.Items {
+		if item != nil && item.Err != nil && item.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+			return vearchpb.NewError(item.Err.Code, fmt.Errorf("%s", item.Err.Msg))
+		}
+	}
+	return nil
+}
+
+// writeObject writes the matches of a batch to a parquet object named by the
+// partition and the cursor of the batch, so a batch joined again replaces it
+func (j *similarityJoiner) writeObject(ctx context.Context, client *minio.Client, join *entity.SimilarityJoin, partitionID entity.PartitionID, first int32, matches []*JoinMatch) error {
+	rows := make([]JoinMatch, len(matches))
+	for i, m := range matches {
+		rows[i] = *m
+	}
+	buf := &bytes.Buffer{}
+	if err := parquet.Write(buf, rows, parquet.Compression(&parquet.Snappy)); err != nil {
+		return err
+	}
+	name := path.Join(join.Output.Prefix, fmt.Sprintf("part-%d-%d.parquet", partitionID, first))
+	_, err := client.PutObject(ctx, join.Output.S3Param.BucketName, name, bytes.NewReader(buf.Bytes()), int64(buf.Len()), minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("write %s err: %v", name, err)
+	}
+	join.Files++
+	return nil
+}
+
+// save applies fn to the join in etcd if it is still running
+func (j *similarityJoiner) save(ctx context.Context, name string, fn func(saved *entity.SimilarityJoin)) (*entity.SimilarityJoin, error) {
+	saved := &entity.SimilarityJoin{}
+	err := j.handler.client.Master().STM(ctx, func(stm store.STM) error {
+		value := stm.Get(entity.SimilarityJoinKey(name))
+		if value == "" {
+			return errJoinStopped
+		}
+		if err := vjson.Unmarshal([]byte(value), saved); err != nil {
+			return err
+		}
+		if saved.Finished() {
+			return errJoinStopped
+		}
+		fn(saved)
+		bs, err := vjson.Marshal(saved)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.SimilarityJoinKey(name), string(bs))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
@@ -0,0 +1,262 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	migrationCheckInterval = 10 * time.Second
+	migrationLockTTL       = 60 * time.Second
+)
+
+// errMigrationStopped is returned when the migration is canceled or finished
+// by others while running
+var errMigrationStopped = errors.New("embedding migration stopped")
+
+// embeddingMigrator runs the embedding migrations of master whose embedder is
+// configured in this router, one at a time, a migration is run by one router
+// holding its lock and resumes from its saved progress on another one
+type embeddingMigrator struct {
+	handler   *DocumentHandler
+	embedders map[string]Embedder
+	runner    string
+}
+
+func startEmbeddingMigrator(handler *DocumentHandler, embedders map[string]Embedder) {
+	if len(embedders) == 0 {
+		return
+	}
+	runner, _ := os.Hostname()
+	m := &embeddingMigrator{handler: handler, embedders: embedders, runner: runner}
+	go func() {
+		for range time.Tick(migrationCheckInterval) {
+			m.runPending(context.Background())
+		}
+	}()
+	log.Info("run embedding migrations with %d embedders", len(embedders))
+}
+
+// runPending runs the running migrations not locked by other routers
+func (m *embeddingMigrator) runPending(ctx context.Context) {
+	_, values, err := m.handler.client.Master().PrefixScan(ctx, entity.PrefixEmbeddingMigration)
+	if err != nil {
+		log.Error("scan embedding migrations err: %v", err)
+		return
+	}
+	for _, value := range values {
+		migration := &entity.EmbeddingMigration{}
+		if err := vjson.Unmarshal(value, migration); err != nil {
+			log.Error("unmarshal embedding migration err: %v", err)
+			continue
+		}
+		embedder := m.embedders[migration.Embedder]
+		if migration.Finished() || embedder == nil {
+			continue
+		}
+		lock := m.handler.client.Master().NewLock(ctx, entity.EmbeddingMigrationLockKey(migration.Name), migrationLockTTL)
+		if ok, _ := lock.TryLock(); !ok {
+			continue
+		}
+		err := m.run(ctx, migration, embedder, lock.KeepAliveOnce)
+		if err != nil && !errors.Is(err, errMigrationStopped) {
+			log.Error("embedding migration %s failed: %v", migration.Name, err)
+			if _, e := m.save(ctx, migration.Name, func(saved *entity.EmbeddingMigration) {
+				saved.Status = entity.MigrationFailed
+				saved.Error = err.Error()
+				saved.FinishTime = time.Now().Unix()
+			}); e != nil {
+				log.Error("save embedding migration %s err: %v", migration.Name, e)
+			}
+		}
+		if err := lock.Unlock(); err != nil {
+			log.Error("unlock embedding migration %s err: %v", migration.Name, err)
+		}
+	}
+}
+
+// run embeds the documents batch by batch from the saved progress at the
+// rate of the migration, and asks master to complete it at the end
+func (m *embeddingMigrator) run(ctx context.Context, migration *entity.EmbeddingMigration, embedder Embedder, keepAlive func()) error {
+	log.Info("run embedding migration %s of space %s/%s from partition %d docid %d", migration.Name,
+		migration.DbName, migration.SpaceName, migration.Partition, migration.Cursor)
+	head := &vearchpb.RequestHead{DbName: migration.DbName, SpaceName: migration.SpaceName, Params: make(map[string]string)}
+	for {
+		keepAlive()
+		space, err := m.handler.docService.getSpace(ctx, head)
+		if err != nil {
+			return err
+		}
+		if migration.Partition >= len(space.Partitions) {
+			return m.complete(ctx, migration.Name)
+		}
+
+		start := time.Now()
+		n, err := m.runBatch(ctx, head, space, migration, embedder)
+		if err != nil {
+			return err
+		}
+		if migration, err = m.save(ctx, migration.Name, func(saved *entity.EmbeddingMigration) {
+			saved.Partition, saved.Cursor = migration.Partition, migration.Cursor
+			saved.Embedded, saved.Skipped = migration.Embedded, migration.Skipped
+			saved.Runner = m.runner
+			saved.UpdateTime = time.Now().Unix()
+		}); err != nil {
+			return err
+		}
+
+		// documents of a batch take batch / rate seconds at least
+		if wait := time.Duration(n)*time.Second/time.Duration(migration.Rate) - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+}
+
+// runBatch embeds the next batch of the current partition, or moves to the
+// next partition if it has no more documents. It returns the documents read
+func (m *embeddingMigrator) runBatch(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, migration *entity.EmbeddingMigration, embedder Embedder) (int, error) {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	target := proMap[migration.Target.Name]
+	if target == nil || target.FieldType != vearchpb.FieldType_VECTOR {
+		return 0, fmt.Errorf("target field %s is not vector field of space %s", migration.Target.Name, space.Name)
+	}
+
+	n := 0
+	ids := make([]string, 0, migration.BatchSize)
+	texts := make([]string, 0, migration.BatchSize)
+	get := m.handler.partitionGetter(ctx, head, space.Partitions[migration.Partition].Id)
+	last, err := scanDocs(ctx, migration.Cursor, migration.BatchSize, get, func(fields []*vearchpb.Field) (bool, error) {
+		n++
+		var id, text string
+		for _, fv := range fields {
+			switch fv.Name {
+			case entity.IdField:
+				id = string(fv.Value)
+			case migration.SourceField:
+				text = string(fv.Value)
+			}
+		}
+		switch {
+		case id == "":
+		case text == "":
+			migration.Skipped++
+		default:
+			ids = append(ids, id)
+			texts = append(texts, text)
+		}
+		return n < migration.BatchSize, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if last == migration.Cursor {
+		migration.Partition++
+		migration.Cursor = -1
+		return 0, nil
+	}
+
+	if len(texts) > 0 {
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return 0, err
+		}
+		docs := make([]*vearchpb.Document, 0, len(ids))
+		for i, id := range ids {
+			f, err := processVector(target, migration.Target.Name, vectors[i])
+			if err != nil {
+				return 0, err
+			}
+			docs = append(docs, &vearchpb.Document{PKey: id, Fields: []*vearchpb.Field{f}})
+		}
+		bulkReply := m.handler.docService.bulk(ctx, &vearchpb.BulkRequest{
+			Head: &vearchpb.RequestHead{DbName: head.DbName, SpaceName: head.SpaceName, Params: head.Params},
+			Docs: docs,
+		})
+		if bulkReply.Head != nil && bulkReply.Head.Err != nil && bulkReply.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+			return 0, vearchpb.NewError(bulkReply.Head.Err.Code, fmt.Errorf("%s", bulkReply.Head.Err.Msg))
+		}
+		for _, item := range bulkReply.Items {
+			if item != nil && item.Err != nil && item.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+				return 0, vearchpb.NewError(item.Err.Code, fmt.Errorf("%s", item.Err.Msg))
+			}
+		}
+		migration.Embedded += int64(len(docs))
+	}
+	migration.Cursor = last
+	return n, nil
+}
+
+// save applies fn to the migration in etcd if it is still running
+func (m *embeddingMigrator) save(ctx context.Context, name string, fn func(saved *entity.EmbeddingMigration)) (*entity.EmbeddingMigration, error) {
+	saved := &entity.EmbeddingMigration{}
+	err := m.handler.client.Master().STM(ctx, func(stm store.STM) error {
+		value := stm.Get(entity.EmbeddingMigrationKey(name))
+		if value == "" {
+			return errMigrationStopped
+		}
+		if err := vjson.Unmarshal([]byte(value), saved); err != nil {
+			return err
+		}
+		if saved.Finished() {
+			return errMigrationStopped
+		}
+		fn(saved)
+		bs, err := vjson.Marshal(saved)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.EmbeddingMigrationKey(name), string(bs))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// complete asks master to switch the default vector field of the space
+func (m *embeddingMigrator) complete(ctx context.Context, name string) error {
+	response, err := m.handler.client.Master().HTTPRequest(ctx, http.MethodPost, "/cluster/embedding_migrations/"+name+"/complete", "")
+	if err != nil {
+		return err
+	}
+	js := &struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}{}
+	if err := vjson.Unmarshal(response, js); err != nil {
+		return err
+	}
+	if js.Code != int(vearchpb.ErrorEnum_SUCCESS) {
+		return fmt.Errorf("complete embedding migration %s err: %s", name, js.Msg)
+	}
+	log.Info("embedding migration %s completed", name)
+	return nil
+}
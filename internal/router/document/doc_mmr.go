@@ -0,0 +1,162 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	defaultMMRLambda  = 0.5
+	maxMMRCandidates  = 1000
+	mmrCandidatesRate = 4
+)
+
+// prepareMMR fetches the candidates and their vectors for diversify
+func prepareMMR(searchDoc *request.SearchDocumentRequest, space *entity.Space, spaceProMap map[string]*entity.SpaceProperties, metricType string, queryFieldMap map[string]string, searchReq *vearchpb.SearchRequest) error {
+	mmr := searchDoc.MMR
+	if mmr.Lambda == nil {
+		lambda := defaultMMRLambda
+		mmr.Lambda = &lambda
+	}
+	if *mmr.Lambda < 0 || *mmr.Lambda > 1 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("mmr lambda should be in [0, 1]"))
+	}
+	if mmr.Field == "" {
+		for _, vq := range searchReq.VecFields {
+			if mmr.Field != "" && mmr.Field != vq.Name {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("mmr field should be set when searching several vector fields"))
+			}
+			mmr.Field = vq.Name
+		}
+	}
+	if field := spaceProMap[mmr.Field]; field == nil || field.FieldType != vearchpb.FieldType_VECTOR {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("mmr field [%s] is not vector field", mmr.Field))
+	}
+	if space.Index != nil && space.Index.Type == "BINARYIVF" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("mmr not support binary vector"))
+	}
+	if mmr.Candidates <= 0 {
+		mmr.Candidates = mmrCandidatesRate * searchReq.TopN
+		if mmr.Candidates > maxMMRCandidates {
+			mmr.Candidates = maxMMRCandidates
+		}
+	}
+	if mmr.Candidates < searchReq.TopN || mmr.Candidates > maxMMRCandidates {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("mmr candidates should be in [limit, %d]", maxMMRCandidates))
+	}
+	mmr.MetricType = metricType
+
+	if queryFieldMap[mmr.Field] == "" || !searchReq.IsVectorValue {
+		mmr.Strip = true
+		if queryFieldMap[mmr.Field] == "" {
+			searchReq.Fields = append(searchReq.Fields, mmr.Field)
+			queryFieldMap[mmr.Field] = mmr.Field
+		}
+		searchReq.IsVectorValue = true
+	}
+	searchReq.TopN = mmr.Candidates
+	return nil
+}
+
+// diversify greedily picks limit items of each result maximizing
+// lambda * relevance - (1 - lambda) * max cosine similarity to the picked,
+// relevance is the score min-max normalized over the candidates
+func diversify(mmr *request.MMR, limit int32, results []*vearchpb.SearchResult) error {
+	lambda := *mmr.Lambda
+	for _, result := range results {
+		if result == nil || len(result.ResultItems) == 0 {
+			continue
+		}
+		items := result.ResultItems
+		vectors := make([][]float32, len(items))
+		norms := make([]float64, len(items))
+		minScore, maxScore := math.MaxFloat64, -math.MaxFloat64
+		for i, item := range items {
+			minScore, maxScore = math.Min(minScore, item.Score), math.Max(maxScore, item.Score)
+			for j, fv := range item.Fields {
+				if fv.Name != mmr.Field {
+					continue
+				}
+				vector, err := cbbytes.ByteToVectorForFloat32(fv.Value)
+				if err != nil {
+					return err
+				}
+				vectors[i] = vector
+				for _, x := range vector {
+					norms[i] += float64(x) * float64(x)
+				}
+				norms[i] = math.Sqrt(norms[i])
+				if mmr.Strip {
+					item.Fields = append(item.Fields[:j:j], item.Fields[j+1:]...)
+				}
+				break
+			}
+		}
+		relevance := make([]float64, len(items))
+		for i, item := range items {
+			switch {
+			case maxScore == minScore:
+				relevance[i] = 1
+			case mmr.MetricType == "L2":
+				relevance[i] = (maxScore - item.Score) / (maxScore - minScore)
+			default:
+				relevance[i] = (item.Score - minScore) / (maxScore - minScore)
+			}
+		}
+
+		n := int(limit)
+		if n > len(items) {
+			n = len(items)
+		}
+		picked := make([]bool, len(items))
+		maxSim := make([]float64, len(items))
+		for i := range maxSim {
+			maxSim[i] = -1
+		}
+		selected := make([]*vearchpb.ResultItem, 0, n)
+		for len(selected) < n {
+			best, bestValue := -1, -math.MaxFloat64
+			for i := range items {
+				if picked[i] {
+					continue
+				}
+				if v := lambda*relevance[i] - (1-lambda)*maxSim[i]; v > bestValue {
+					best, bestValue = i, v
+				}
+			}
+			picked[best] = true
+			selected = append(selected, items[best])
+			for i := range items {
+				if picked[i] || vectors[i] == nil || vectors[best] == nil || norms[i] == 0 || norms[best] == 0 {
+					continue
+				}
+				var dot float64
+				for d := range vectors[i] {
+					dot += float64(vectors[i][d]) * float64(vectors[best][d])
+				}
+				maxSim[i] = math.Max(maxSim[i], dot/(norms[i]*norms[best]))
+			}
+		}
+		result.ResultItems = selected
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestDiversify(t *testing.T) {
+	item := func(t *testing.T, id string, score float64, vector []float32) *vearchpb.ResultItem {
+		value, err := cbbytes.VectorToByte(vector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &vearchpb.ResultItem{PKey: id, Score: score, Fields: []*vearchpb.Field{{Name: "vec", Value: value}}}
+	}
+	tests := []struct {
+		name   string
+		lambda float64
+		metric string
+		scores []float64
+		strip  bool
+		want   []string
+	}{
+		{name: "Near duplicate is replaced by a diverse item", lambda: 0.5, metric: "InnerProduct", scores: []float64{0.9, 0.89, 0.5}, want: []string{"a", "c"}},
+		{name: "Lambda one keeps the relevance order", lambda: 1, metric: "InnerProduct", scores: []float64{0.9, 0.89, 0.5}, want: []string{"a", "b"}},
+		{name: "Lower L2 distance is more relevant", lambda: 0.5, metric: "L2", scores: []float64{0.1, 0.11, 0.5}, want: []string{"a", "c"}},
+		{name: "Vector field fetched for mmr is stripped", lambda: 0.5, metric: "L2", scores: []float64{0.1, 0.11, 0.5}, strip: true, want: []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &vearchpb.SearchResult{ResultItems: []*vearchpb.ResultItem{
+				item(t, "a", tt.scores[0], []float32{1, 0}),
+				item(t, "b", tt.scores[1], []float32{1, 0.01}),
+				item(t, "c", tt.scores[2], []float32{0, 1}),
+			}}
+			lambda := tt.lambda
+			mmr := &request.MMR{Lambda: &lambda, Field: "vec", MetricType: tt.metric, Strip: tt.strip}
+			if err := diversify(mmr, 2, []*vearchpb.SearchResult{result, nil}); err != nil {
+				t.Fatal(err)
+			}
+			if len(result.ResultItems) != len(tt.want) {
+				t.Fatalf("diversify() = %d items, want %d", len(result.ResultItems), len(tt.want))
+			}
+			for i, it := range result.ResultItems {
+				if it.PKey != tt.want[i] {
+					t.Errorf("item %d = %s, want %s", i, it.PKey, tt.want[i])
+				}
+				if stripped := len(it.Fields) == 0; stripped != tt.strip {
+					t.Errorf("item %s fields stripped %v, want %v", it.PKey, stripped, tt.strip)
+				}
+			}
+		})
+	}
+}
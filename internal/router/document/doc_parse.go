@@ -32,6 +32,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/netutil"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 )
@@ -184,8 +185,8 @@ func processPropertyObjectVectorBinary(feature []*fastjson.Value, pathString str
 	}
 	field, err := processVectorBinary(pro, pathString, vector)
 	if err != nil {
-		log.Error("process vector binary err:[%s] vector value:[%v]", err.Error(), vector)
-		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("process binary vector embedding err:[%s] vector value:[%v]", err.Error(), vector))
+		log.Error("process vector binary err:[%s] vector value:[%s]", err.Error(), redact.Value(vector))
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("process binary vector embedding err:[%s] vector value:[%s]", err.Error(), redact.Value(vector)))
 	}
 	return field, nil
 }
@@ -205,8 +206,8 @@ func processPropertyObjectVectorOther(feature []*fastjson.Value, pathString stri
 	}
 	field, err := processVector(pro, pathString, vector)
 	if err != nil {
-		log.Error("%s vector value:[%v]", err.Error(), vector)
-		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s vector embedding value:[%v]", err.Error(), vector))
+		log.Error("%s vector value:[%s]", err.Error(), redact.Value(vector))
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%s vector embedding value:[%s]", err.Error(), redact.Value(vector)))
 	}
 	return field, nil
 }
@@ -529,7 +530,7 @@ func configTraceParse(r *http.Request) (printSwitch bool, err error) {
 	}{}
 	err = json.Unmarshal(reqBody, &temp)
 	if err != nil {
-		err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("config trace param convert json %s err: %v", string(reqBody), err))
+		err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("config trace param convert json %s err: %v", redact.Payload(reqBody), err))
 		return false, err
 	}
 	return temp.Trace, nil
@@ -0,0 +1,405 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/distance"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// pipeline runs the retrieval pipeline of a space for a search, the search
+// itself is the retrieve stage and the later stages rescore its results
+type pipeline struct {
+	stages     []*entity.PipelineStage
+	rerankers  map[string]Reranker
+	queries    map[string][]float32 // query vectors of rerank stages by field
+	dimensions map[string]int
+	metrics    map[string]distance.Metric // space metrics of rerank stages by stage
+	queryText  string
+	limit      int32
+	metricType string
+	strip      map[string]bool // fields returned only for the stages
+	explain    []*stageExplain
+}
+
+// stageExplain is the explain output of a stage of the pipeline
+type stageExplain struct {
+	Stage      string  `json:"stage"`
+	Type       string  `json:"type"`
+	Candidates int     `json:"candidates"`
+	Took       float64 `json:"took_ms"`
+	Skipped    bool    `json:"skipped,omitempty"`
+}
+
+// newPipeline prepares the search to run the pipeline of space, it keeps
+// only the vector of the retrieve stage in searchDoc and searches the limit
+// of the stage. It returns nil if space has no pipeline or the search skips
+func newPipeline(space *entity.Space, searchDoc *request.SearchDocumentRequest, rerankers map[string]Reranker) (*pipeline, error) {
+	if len(space.Pipeline) == 0 || searchDoc.SkipPipeline {
+		return nil, nil
+	}
+	if searchDoc.MMR != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("mmr is not supported by the pipeline of space %s", space.Name))
+	}
+	if space.Index != nil && space.Index.Type == "BINARYIVF" {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("pipeline not support binary vector"))
+	}
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+
+	p := &pipeline{
+		stages:     space.Pipeline,
+		rerankers:  rerankers,
+		queries:    make(map[string][]float32),
+		dimensions: make(map[string]int),
+		metrics:    make(map[string]distance.Metric),
+		queryText:  searchDoc.QueryText,
+		limit:      searchDoc.Limit,
+	}
+	if p.limit == 0 {
+		p.limit = DefaultSize
+	}
+	retrieve := p.stages[0]
+	features := make(map[string]json.RawMessage, len(searchDoc.Vectors))
+	var retrieveVector json.RawMessage
+	for _, raw := range searchDoc.Vectors {
+		vq := &VectorQuery{}
+		if err := vjson.Unmarshal(raw, vq); err != nil {
+			return nil, err
+		}
+		// vectors without field are searched by the retrieve stage
+		if vq.Field == "" || vq.Field == retrieve.Field {
+			fields := make(map[string]json.RawMessage)
+			if err := vjson.Unmarshal(raw, &fields); err != nil {
+				return nil, err
+			}
+			fields["field"], _ = vjson.Marshal(retrieve.Field)
+			var err error
+			if retrieveVector, err = vjson.Marshal(fields); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		features[vq.Field] = vq.FeatureData
+	}
+	if retrieveVector == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("pipeline of space %s should have vector of field [%s]", space.Name, retrieve.Field))
+	}
+
+	for _, stage := range p.stages[1:] {
+		switch stage.Type {
+		case entity.PipelineRerank:
+			feature := features[stage.Field]
+			if stage.Field == retrieve.Field {
+				vq := &VectorQuery{}
+				if err := vjson.Unmarshal(retrieveVector, vq); err != nil {
+					return nil, err
+				}
+				feature = vq.FeatureData
+			}
+			if len(feature) == 0 {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("pipeline stage %s should have vector of field [%s]", stage.Name, stage.Field))
+			}
+			vector := make([]float32, 0)
+			if err := vjson.Unmarshal(feature, &vector); err != nil {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector of field [%s] of pipeline stage %s err: %v", stage.Field, stage.Name, err))
+			}
+			dimension := 0
+			if pro := proMap[stage.Field]; pro != nil {
+				dimension = pro.Dimension
+			}
+			if dimension <= 0 || len(vector) == 0 || len(vector)%dimension != 0 {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector of field [%s] of pipeline stage %s should be multiple of dimension %d", stage.Field, stage.Name, dimension))
+			}
+			p.queries[stage.Field] = vector
+			p.dimensions[stage.Field] = dimension
+			if stage.Metric != "" {
+				m := space.Metric(stage.Metric)
+				if m == nil {
+					return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("metric %s of pipeline stage %s is not a metric of space %s", stage.Metric, stage.Name, space.Name))
+				}
+				metric, err := m.New(dimension)
+				if err != nil {
+					return nil, err
+				}
+				p.metrics[stage.Name] = metric
+			}
+		case entity.PipelineCrossEncoder:
+			if p.queryText == "" {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("pipeline stage %s should have query_text", stage.Name))
+			}
+			if p.rerankers[stage.Reranker] == nil {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("reranker [%s] of pipeline stage %s is not configured in router", stage.Reranker, stage.Name))
+			}
+		}
+	}
+
+	searchDoc.Vectors = []json.RawMessage{retrieveVector}
+	searchDoc.Limit = retrieve.Limit
+	return p, nil
+}
+
+// prepare returns the fields of the stages by the search
+func (p *pipeline) prepare(space *entity.Space, searchReq *vearchpb.SearchRequest) error {
+	metricType, err := searchMetricType(space, searchReq.IndexParams)
+	if err != nil {
+		return err
+	}
+	p.metricType = metricType
+
+	requested := make(map[string]bool, len(searchReq.Fields))
+	for _, field := range searchReq.Fields {
+		requested[field] = true
+	}
+	p.strip = make(map[string]bool)
+	for _, stage := range p.stages[1:] {
+		field := stage.TextField
+		if stage.Type == entity.PipelineRerank {
+			field = stage.Field
+			if !searchReq.IsVectorValue {
+				p.strip[field] = true
+			}
+		}
+		if !requested[field] {
+			requested[field] = true
+			p.strip[field] = true
+			searchReq.Fields = append(searchReq.Fields, field)
+		}
+	}
+	if len(p.queries) > 0 {
+		searchReq.IsVectorValue = true
+	}
+	return nil
+}
+
+// run rescores the results of the retrieve stage by the later stages and
+// trims them to the limit of the search. A stage rescores all the results or
+// none of them, if the budget of the search runs out the stage and the later
+// ones are skipped and the results kept in the order of the previous stage
+func (p *pipeline) run(ctx context.Context, results []*vearchpb.SearchResult, retrieveCost time.Duration, budget *searchBudget) error {
+	p.explain = append(p.explain, &stageExplain{Stage: p.stages[0].Name, Type: p.stages[0].Type,
+		Candidates: countCandidates(results), Took: retrieveCost.Seconds() * 1000})
+
+	ctx, cancel, ok := budget.rerankStage(ctx)
+	defer cancel()
+	for _, stage := range p.stages[1:] {
+		start := time.Now()
+		if ok {
+			scores, err := p.score(ctx, stage, results)
+			if err != nil && budget != nil && ctx.Err() != nil {
+				ok = false
+			} else if err != nil {
+				return err
+			} else {
+				for i, result := range results {
+					if scores[i] != nil {
+						p.applyScores(stage, result, scores[i])
+					}
+				}
+			}
+		}
+		for _, result := range results {
+			if result != nil && int32(len(result.ResultItems)) > stage.Limit {
+				result.ResultItems = result.ResultItems[:stage.Limit]
+			}
+		}
+		if !ok {
+			budget.skip(stage.Name)
+		}
+		p.explain = append(p.explain, &stageExplain{Stage: stage.Name, Type: stage.Type,
+			Candidates: countCandidates(results), Took: time.Since(start).Seconds() * 1000, Skipped: !ok})
+	}
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if int32(len(result.ResultItems)) > p.limit {
+			result.ResultItems = result.ResultItems[:p.limit]
+		}
+		for _, item := range result.ResultItems {
+			fields := item.Fields[:0]
+			for _, fv := range item.Fields {
+				if !p.strip[fv.Name] {
+					fields = append(fields, fv)
+				}
+			}
+			item.Fields = fields
+		}
+	}
+	return nil
+}
+
+// score returns the scores of the items of every result by stage, nil for
+// the results without items. It changes none of the results, so a stage cut
+// short leaves them all in the order of the previous stage
+func (p *pipeline) score(ctx context.Context, stage *entity.PipelineStage, results []*vearchpb.SearchResult) ([][]float64, error) {
+	scores := make([][]float64, len(results))
+	for i, result := range results {
+		if result == nil || len(result.ResultItems) == 0 {
+			continue
+		}
+		var err error
+		switch stage.Type {
+		case entity.PipelineRerank:
+			scores[i], err = p.rerank(stage, i, result)
+		case entity.PipelineCrossEncoder:
+			scores[i], err = p.crossEncode(ctx, stage, result)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return scores, nil
+}
+
+// applyScores sets the scores of stage to the items and sorts them, by
+// distance for rerank stages of L2 or of an ascending space metric and by
+// descending score otherwise
+func (p *pipeline) applyScores(stage *entity.PipelineStage, result *vearchpb.SearchResult, scores []float64) {
+	for i, item := range result.ResultItems {
+		item.Score = scores[i]
+	}
+	asc := stage.Type == entity.PipelineRerank && p.metricType == "L2"
+	if m := p.metrics[stage.Name]; m != nil {
+		asc = m.Ascending()
+	}
+	items := result.ResultItems
+	sort.SliceStable(items, func(a, b int) bool {
+		if asc {
+			return items[a].Score < items[b].Score
+		}
+		return items[a].Score > items[b].Score
+	})
+}
+
+// rerank scores the items by the exact distance of the vector of the stage
+// field to the query vector of the i-th query, by the space metric of the
+// stage if it has one
+func (p *pipeline) rerank(stage *entity.PipelineStage, i int, result *vearchpb.SearchResult) ([]float64, error) {
+	dimension := p.dimensions[stage.Field]
+	query := p.queries[stage.Field]
+	if n := len(query) / dimension; n > 1 {
+		if i >= n {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("pipeline stage %s has %d query vectors but search has more", stage.Name, n))
+		}
+		query = query[i*dimension : (i+1)*dimension]
+	}
+	scores := make([]float64, len(result.ResultItems))
+	metric := p.metrics[stage.Name]
+	var vectors []float32
+	if metric != nil {
+		vectors = make([]float32, 0, len(result.ResultItems)*dimension)
+	}
+	for k, item := range result.ResultItems {
+		var vector []float32
+		for _, fv := range item.Fields {
+			if fv.Name == stage.Field {
+				var err error
+				if vector, err = cbbytes.ByteToVectorForFloat32(fv.Value); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if len(vector) != len(query) {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("document %s has no vector of field [%s] for pipeline stage %s", item.PKey, stage.Field, stage.Name))
+		}
+		if metric != nil {
+			vectors = append(vectors, vector...)
+			continue
+		}
+		score := 0.0
+		for j, x := range vector {
+			if p.metricType == "L2" {
+				d := float64(x) - float64(query[j])
+				score += d * d
+			} else {
+				score += float64(x) * float64(query[j])
+			}
+		}
+		scores[k] = score
+	}
+	if metric != nil {
+		distance.ScoreBatch(metric, query, vectors, scores)
+	}
+	return scores, nil
+}
+
+// crossEncode scores the items by the reranker of the stage
+func (p *pipeline) crossEncode(ctx context.Context, stage *entity.PipelineStage, result *vearchpb.SearchResult) ([]float64, error) {
+	texts := make([]string, len(result.ResultItems))
+	for i, item := range result.ResultItems {
+		for _, fv := range item.Fields {
+			if fv.Name == stage.TextField {
+				texts[i] = string(fv.Value)
+				break
+			}
+		}
+	}
+	scores, err := p.rerankers[stage.Reranker].Rerank(ctx, p.queryText, texts)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("pipeline stage %s rerank err: %v", stage.Name, err))
+	}
+	if len(scores) != len(texts) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("pipeline stage %s rerank %d texts but got %d scores", stage.Name, len(texts), len(scores)))
+	}
+	return scores, nil
+}
+
+func countCandidates(results []*vearchpb.SearchResult) int {
+	n := 0
+	for _, result := range results {
+		if result != nil {
+			n += len(result.ResultItems)
+		}
+	}
+	return n
+}
+
+// searchMetricType returns the metric type of the index params of a search,
+// or of the space index if the search has none
+func searchMetricType(space *entity.Space, params string) (string, error) {
+	indexParams := &entity.IndexParams{}
+	if params != "" {
+		if err := vjson.Unmarshal([]byte(params), indexParams); err != nil {
+			return "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unmarshal err:[%s] , index params:[%s]", err.Error(), params))
+		}
+		if indexParams.MetricType != "" {
+			return indexParams.MetricType, nil
+		}
+	}
+	if space.Index != nil && len(space.Index.Params) > 0 {
+		if err := vjson.Unmarshal(space.Index.Params, indexParams); err != nil {
+			return "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unmarshal err:[%s] , space.Index.IndexParams:[%s]", err.Error(), string(space.Index.Params)))
+		}
+	}
+	return indexParams.MetricType, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestPipelineRerankMetric(t *testing.T) {
+	space := &entity.Space{
+		Name: "ts_space",
+		SpaceProperties: map[string]*entity.SpaceProperties{
+			"sparse": {FieldType: vearchpb.FieldType_VECTOR, Dimension: 2},
+			"dense":  {FieldType: vearchpb.FieldType_VECTOR, Dimension: 2},
+		},
+		Pipeline: []*entity.PipelineStage{
+			{Name: "retrieve", Type: entity.PipelineRetrieve, Field: "sparse", Limit: 3},
+			{Name: "rerank", Type: entity.PipelineRerank, Field: "dense", Metric: "weighted", Limit: 3},
+		},
+		// only the first dimension counts
+		Metrics: []*entity.SpaceMetric{{Name: "weighted", Type: "WeightedL2", Field: "dense", Params: json.RawMessage(`{"weights":[1,0]}`)}},
+	}
+	vectors := []json.RawMessage{
+		json.RawMessage(`{"field":"sparse","feature":[1,1]}`),
+		json.RawMessage(`{"field":"dense","feature":[0,0]}`),
+	}
+	p, err := newPipeline(space, &request.SearchDocumentRequest{Vectors: vectors}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &vearchpb.SearchResult{}
+	for _, doc := range []struct {
+		key    string
+		vector []float32
+	}{{"far", []float32{3, 0}}, {"near", []float32{1, 9}}, {"mid", []float32{2, 0}}} {
+		value, err := cbbytes.VectorToByte(doc.vector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.ResultItems = append(result.ResultItems, &vearchpb.ResultItem{PKey: doc.key,
+			Fields: []*vearchpb.Field{{Name: "dense", Value: value}}})
+	}
+	if err := p.run(context.Background(), []*vearchpb.SearchResult{result}, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(result.ResultItems))
+	for _, item := range result.ResultItems {
+		got = append(got, item.PKey)
+	}
+	if want := []string{"near", "mid", "far"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("run() = %v, want %v by ascending weighted distance", got, want)
+	}
+
+	space.Pipeline[1].Metric = "unknown"
+	if _, err := newPipeline(space, &request.SearchDocumentRequest{Vectors: vectors}, nil); err == nil {
+		t.Error("newPipeline() with an undeclared metric should fail")
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	defaultPlannerRefreshTime = 60 // s
+	plannerFetchTimeout       = 5 * time.Second
+
+	// strategies of a filtered search
+	planPreFilter  = "pre_filter"  // filter first and scan the matches exactly
+	planPostFilter = "post_filter" // search the index and filter its candidates
+)
+
+// filterPlan is how the filters of a request are expected to run, returned
+// with explain
+type filterPlan struct {
+	Strategy      string  `json:"strategy,omitempty"`
+	EstimatedRows int64   `json:"estimated_rows"`
+	Selectivity   float64 `json:"selectivity"`
+}
+
+// filterPlanner estimates the documents the filters of a request match by
+// the index stats the ps sample of every partition
+type filterPlanner struct {
+	cfg     *config.PlannerCfg
+	client  *client.Client
+	refresh time.Duration
+
+	mu     sync.Mutex
+	spaces map[entity.SpaceID]*spaceIndexStats
+}
+
+// spaceIndexStats are the index stats of the partitions of a space
+type spaceIndexStats struct {
+	partitions []*entity.IndexStats
+	fetched    time.Time
+	used       time.Time
+	fetching   bool
+}
+
+func newFilterPlanner(cfg *config.PlannerCfg, client *client.Client) *filterPlanner {
+	if cfg == nil {
+		return nil
+	}
+	refresh := defaultPlannerRefreshTime * time.Second
+	if cfg.RefreshTime > 0 {
+		refresh = time.Duration(cfg.RefreshTime) * time.Second
+	}
+	log.Info("plan filters by index stats, pre filter up to %d rows, admit queries up to %d rows", cfg.BruteForceRows, cfg.MaxQueryRows)
+	return &filterPlanner{cfg: cfg, client: client, refresh: refresh, spaces: make(map[entity.SpaceID]*spaceIndexStats)}
+}
+
+// stats returns the index stats cached of space, stale ones are refreshed in
+// the background so requests never wait for them
+func (p *filterPlanner) stats(space *entity.Space) []*entity.IndexStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	s := p.spaces[space.Id]
+	if s == nil {
+		// forget the spaces not requested for a while, deleted ones too
+		for id, old := range p.spaces {
+			if now.Sub(old.used) > 10*p.refresh {
+				delete(p.spaces, id)
+			}
+		}
+		s = &spaceIndexStats{}
+		p.spaces[space.Id] = s
+	}
+	s.used = now
+	if !s.fetching && now.Sub(s.fetched) > p.refresh {
+		s.fetching = true
+		go p.fetch(space, s)
+	}
+	return s.partitions
+}
+
+// fetch gets the index stats of every partition of space from its leader
+func (p *filterPlanner) fetch(space *entity.Space, s *spaceIndexStats) {
+	ctx, cancel := context.WithTimeout(context.Background(), plannerFetchTimeout)
+	defer cancel()
+	partitions := make([]*entity.IndexStats, 0, len(space.Partitions))
+	for _, partition := range space.Partitions {
+		stats, err := p.fetchPartition(ctx, space, partition.Id)
+		if err != nil {
+			log.Warn("get index stats of partition [%d] of space [%s] failed, err: [%v]", partition.Id, space.Name, err)
+			continue
+		}
+		if stats != nil {
+			partitions = append(partitions, stats)
+		}
+	}
+	p.mu.Lock()
+	s.partitions, s.fetched, s.fetching = partitions, time.Now(), false
+	p.mu.Unlock()
+}
+
+func (p *filterPlanner) fetchPartition(ctx context.Context, space *entity.Space, pid entity.PartitionID) (*entity.IndexStats, error) {
+	partition, err := p.client.Master().Cache().PartitionByCache(ctx, space.Name, pid)
+	if err != nil {
+		return nil, err
+	}
+	server, err := p.client.Master().Cache().ServerByCache(ctx, partition.LeaderID)
+	if err != nil {
+		return nil, err
+	}
+	return client.IndexStats(server.RpcAddr(), pid)
+}
+
+// rangeBounds decodes the bounds of a range filter on a field of fieldType
+func rangeBounds(rf *vearchpb.RangeFilter, fieldType vearchpb.FieldType) (lower, upper float64, ok bool) {
+	switch fieldType {
+	case vearchpb.FieldType_INT:
+		return float64(cbbytes.Bytes2Int32(rf.LowerValue)), float64(cbbytes.Bytes2Int32(rf.UpperValue)), true
+	case vearchpb.FieldType_LONG, vearchpb.FieldType_DATE:
+		return float64(cbbytes.Bytes2Int(rf.LowerValue)), float64(cbbytes.Bytes2Int(rf.UpperValue)), true
+	case vearchpb.FieldType_FLOAT:
+		return float64(cbbytes.ByteToFloat32(rf.LowerValue)), float64(cbbytes.ByteToFloat32(rf.UpperValue)), true
+	case vearchpb.FieldType_DOUBLE:
+		return cbbytes.ByteToFloat64New(rf.LowerValue), cbbytes.ByteToFloat64New(rf.UpperValue), true
+	}
+	return 0, 0, false
+}
+
+// estimateFilters returns the documents the filters are expected to match
+// in partitions and their share of all documents, the filters are taken as
+// independent
+func estimateFilters(partitions []*entity.IndexStats, proMap map[string]*entity.SpaceProperties, rangeFilters []*vearchpb.RangeFilter, termFilters []*vearchpb.TermFilter) (rows int64, selectivity float64) {
+	var matches, docs float64
+	for _, stats := range partitions {
+		share := 1.0
+		for _, rf := range rangeFilters {
+			pro := proMap[rf.Field]
+			if pro == nil {
+				continue
+			}
+			if lower, upper, ok := rangeBounds(rf, pro.FieldType); ok {
+				share *= stats.RangeSelectivity(rf.Field, lower, upper)
+			}
+		}
+		for _, tf := range termFilters {
+			s := stats.TermSelectivity(tf.Field, strings.Split(string(tf.Value), "\001"))
+			if tf.IsUnion == TermOperatorNOTIN {
+				s = 1 - s
+			}
+			share *= s
+		}
+		matches += share * float64(stats.DocNum)
+		docs += float64(stats.DocNum)
+	}
+	if docs > 0 {
+		selectivity = matches / docs
+	}
+	return int64(matches + 0.5), selectivity
+}
+
+// estimate estimates the filters on space, false without the stats of
+// every partition
+func (p *filterPlanner) estimate(space *entity.Space, rangeFilters []*vearchpb.RangeFilter, termFilters []*vearchpb.TermFilter) (*filterPlan, bool) {
+	if len(rangeFilters) == 0 && len(termFilters) == 0 {
+		return nil, false
+	}
+	partitions := p.stats(space)
+	if len(partitions) == 0 || len(partitions) != len(space.Partitions) {
+		return nil, false
+	}
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		var err error
+		if proMap, err = entity.UnmarshalPropertyJSON(space.Fields); err != nil {
+			return nil, false
+		}
+	}
+	rows, selectivity := estimateFilters(partitions, proMap, rangeFilters, termFilters)
+	return &filterPlan{EstimatedRows: rows, Selectivity: selectivity}, true
+}
+
+// planSearch chooses how the filters of a search run, the searches expected
+// to match at most brute_force_rows documents filter first and scan them by
+// brute force, which is exact and cheaper than an index search missing most
+// of its candidates. nil if the search is not planned
+func (p *filterPlanner) planSearch(space *entity.Space, req *vearchpb.SearchRequest) *filterPlan {
+	if p == nil {
+		return nil
+	}
+	plan, ok := p.estimate(space, req.RangeFilters, req.TermFilters)
+	if !ok {
+		return nil
+	}
+	plan.Strategy = planPostFilter
+	if req.IsBruteSearch == 0 && p.cfg.BruteForceRows > 0 && plan.EstimatedRows <= int64(p.cfg.BruteForceRows) {
+		req.IsBruteSearch = 1
+	}
+	if req.IsBruteSearch != 0 {
+		plan.Strategy = planPreFilter
+	}
+	return plan
+}
+
+// admitQuery rejects the queries expected to return more than
+// max_query_rows documents, sorted queries read every match. nil if the
+// query is not planned
+func (p *filterPlanner) admitQuery(space *entity.Space, req *vearchpb.QueryRequest) (*filterPlan, error) {
+	if p == nil {
+		return nil, nil
+	}
+	plan, ok := p.estimate(space, req.RangeFilters, req.TermFilters)
+	if !ok {
+		return nil, nil
+	}
+	rows := plan.EstimatedRows
+	if len(req.SortFields) == 0 && int64(req.Limit) < rows {
+		rows = int64(req.Limit)
+	}
+	if p.cfg.MaxQueryRows > 0 && rows > int64(p.cfg.MaxQueryRows) {
+		err := fmt.Errorf("query of space [%s] is expected to read %d documents, more than the %d admitted, narrow its filters or limit", space.Name, rows, p.cfg.MaxQueryRows)
+		return plan, vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_RESOURCE_EXHAUSTED, err)
+	}
+	return plan, nil
+}
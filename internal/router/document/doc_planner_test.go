@@ -0,0 +1,105 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestFilterPlanner(t *testing.T) {
+	space := &entity.Space{
+		Id:         1,
+		Name:       "space",
+		Partitions: []*entity.Partition{{Id: 1}, {Id: 2}},
+		SpaceProperties: map[string]*entity.SpaceProperties{
+			"age":  {FieldType: vearchpb.FieldType_INT},
+			"city": {FieldType: vearchpb.FieldType_STRING},
+		},
+	}
+	// each partition holds 10000 documents, age uniform in [0, 100) and
+	// one of ten cities
+	partitions := make([]*entity.IndexStats, 0, len(space.Partitions))
+	for _, partition := range space.Partitions {
+		sampler := entity.NewStatsSampler()
+		for i := 0; i < 1000; i++ {
+			sampler.AddDoc()
+			sampler.AddNumber("age", float64(i%100))
+			sampler.AddTerm("city", string(rune('a'+i%10)))
+		}
+		partitions = append(partitions, sampler.Stats(partition.Id, 10000, 0))
+	}
+	ageRange := func(lower, upper int32) []*vearchpb.RangeFilter {
+		lowerValue, _ := cbbytes.ValueToByte(lower)
+		upperValue, _ := cbbytes.ValueToByte(upper)
+		return []*vearchpb.RangeFilter{{Field: "age", LowerValue: lowerValue, UpperValue: upperValue, IncludeLower: true, IncludeUpper: true}}
+	}
+	city := []*vearchpb.TermFilter{{Field: "city", Value: []byte("a\001b"), IsUnion: TermOperatorIN}}
+
+	tests := []struct {
+		name         string
+		rangeFilters []*vearchpb.RangeFilter
+		termFilters  []*vearchpb.TermFilter
+		sorted       bool
+		minRows      int64
+		maxRows      int64
+		strategy     string
+		rejected     bool
+	}{
+		{name: "Broad range", rangeFilters: ageRange(0, 49), minRows: 9000, maxRows: 11000, strategy: planPostFilter},
+		{name: "Range and terms", rangeFilters: ageRange(0, 49), termFilters: city, minRows: 1500, maxRows: 2500, strategy: planPostFilter},
+		{name: "Selective range", rangeFilters: ageRange(99, 99), termFilters: city, minRows: 0, maxRows: 100, strategy: planPreFilter},
+		{name: "Sorted query", rangeFilters: ageRange(0, 49), sorted: true, minRows: 9000, maxRows: 11000, strategy: planPostFilter, rejected: true},
+	}
+	p := &filterPlanner{
+		cfg:     &config.PlannerCfg{BruteForceRows: 500, MaxQueryRows: 5000},
+		refresh: time.Hour,
+		spaces:  map[entity.SpaceID]*spaceIndexStats{space.Id: {partitions: partitions, fetched: time.Now()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searchReq := &vearchpb.SearchRequest{RangeFilters: tt.rangeFilters, TermFilters: tt.termFilters}
+			plan := p.planSearch(space, searchReq)
+			if plan == nil {
+				t.Fatal("search not planned")
+			}
+			if plan.EstimatedRows < tt.minRows || plan.EstimatedRows > tt.maxRows {
+				t.Errorf("estimated rows = %d, want in [%d, %d]", plan.EstimatedRows, tt.minRows, tt.maxRows)
+			}
+			if plan.Strategy != tt.strategy || (searchReq.IsBruteSearch != 0) != (tt.strategy == planPreFilter) {
+				t.Errorf("strategy = %s brute search %d, want %s", plan.Strategy, searchReq.IsBruteSearch, tt.strategy)
+			}
+
+			queryReq := &vearchpb.QueryRequest{RangeFilters: tt.rangeFilters, TermFilters: tt.termFilters, Limit: 100}
+			if tt.sorted {
+				queryReq.SortFields = []*vearchpb.SortField{{Field: "age"}}
+			}
+			if _, err := p.admitQuery(space, queryReq); (err != nil) != tt.rejected {
+				t.Errorf("admitQuery() error = %v, want rejected %v", err, tt.rejected)
+			}
+		})
+	}
+
+	// without the stats of every partition nothing is planned
+	p.spaces[space.Id].partitions = partitions[:1]
+	if plan := p.planSearch(space, &vearchpb.SearchRequest{RangeFilters: ageRange(0, 1)}); plan != nil {
+		t.Errorf("search planned with partial stats: %+v", plan)
+	}
+}
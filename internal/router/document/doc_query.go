@@ -20,12 +20,14 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"github.com/spf13/cast"
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/entity/request"
 	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 	"github.com/vearch/vearch/v3/internal/ps/engine/sortorder"
@@ -58,6 +60,8 @@ type VectorQuery struct {
 	MinScore     *float64        `json:"min_score,omitempty"`
 	MaxScore     *float64        `json:"max_score,omitempty"`
 	IndexType    string          `json:"index_type"`
+	DocumentId   string          `json:"document_id,omitempty"` // search by the stored vector of the document
+	Exclude      bool            `json:"exclude_document,omitempty"`
 }
 
 type Range struct {
@@ -229,7 +233,7 @@ func parseRanker(data json.RawMessage, req *vearchpb.SearchRequest) error {
 	ranker := &request.Ranker{}
 	err := vjson.Unmarshal(data, ranker)
 	if err != nil {
-		err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("ranker param convert json %s err: %v", string(data), err))
+		err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("ranker param convert json %s err: %v", redact.Payload(data), err))
 		return err
 	}
 	if ranker.Type != WeightedRanker {
@@ -277,6 +281,9 @@ func parseVectors(reqNum int, vqs []*vearchpb.VectorQuery, tmpArr []json.RawMess
 		if vqTemp.IndexType != "" {
 			indexType = vqTemp.IndexType
 		}
+		if vqTemp.Field == "" {
+			vqTemp.Field = space.DefaultVectorField
+		}
 		docField := proMap[vqTemp.Field]
 
 		if docField == nil {
@@ -680,6 +687,11 @@ func queryRequestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Sp
 		if searchDoc.VectorValue {
 			queryReq.Fields = append(queryReq.Fields, vectorFieldArr...)
 		}
+		fields, err := sourceFields(searchDoc, spaceProKeyMap, queryReq.Fields)
+		if err != nil {
+			return err
+		}
+		queryReq.Fields = fields
 	}
 
 	hasID := false
@@ -774,6 +786,8 @@ func requestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Space,
 
 	if searchDoc.IndexParams != nil {
 		searchReq.IndexParams = string(searchDoc.IndexParams)
+	} else if space != nil && space.DefaultSearchParams != nil {
+		searchReq.IndexParams = string(space.DefaultSearchParams)
 	}
 
 	searchReq.TopN = searchDoc.Limit
@@ -812,6 +826,11 @@ func requestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Space,
 		if searchDoc.VectorValue {
 			searchReq.Fields = append(searchReq.Fields, vectorFieldArr...)
 		}
+		fields, err := sourceFields(searchDoc, spaceProKeyMap, searchReq.Fields)
+		if err != nil {
+			return err
+		}
+		searchReq.Fields = fields
 	}
 
 	hasID := false
@@ -889,11 +908,95 @@ func requestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Space,
 	searchReq.SortFields = sortFieldArr
 	searchReq.SortFieldMap = sortFieldMap
 
+	if searchDoc.Boost != nil && searchDoc.ScoreScript != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("boost and score_script should not be set together"))
+	}
+	if searchDoc.Boost != nil {
+		if err := searchDoc.Boost.Validate(spaceProMap); err != nil {
+			return err
+		}
+		// every partition decays from the same time
+		searchDoc.Boost.MetricType = metricType
+		searchDoc.Boost.Now = time.Now().Unix()
+		for _, f := range searchDoc.Boost.Functions {
+			if queryFieldMap[f.Field] == "" && sortFieldMap[f.Field] == "" {
+				searchReq.Fields = append(searchReq.Fields, f.Field)
+				queryFieldMap[f.Field] = f.Field
+			}
+		}
+		boost, err := vjson.Marshal(searchDoc.Boost)
+		if err != nil {
+			return err
+		}
+		if searchReq.Head.Params == nil {
+			searchReq.Head.Params = make(map[string]string)
+		}
+		searchReq.Head.Params[entity.BoostParam] = string(boost)
+	}
+
 	err = parseSearch(searchDoc.Vectors, searchDoc.Filters, searchReq, space)
 	if err != nil {
 		return err
 	}
 
+	if searchDoc.ScoreScript != nil {
+		script := searchDoc.ScoreScript
+		if err := script.Compile(spaceProMap); err != nil {
+			return err
+		}
+		// partitions check again with the candidates they really have
+		if err := script.CheckCost(int(searchReq.TopN * script.Oversample * searchReq.ReqNum)); err != nil {
+			return err
+		}
+		script.MetricType = metricType
+		script.Now = time.Now().Unix()
+		for _, field := range script.Fields() {
+			if queryFieldMap[field] == "" && sortFieldMap[field] == "" {
+				searchReq.Fields = append(searchReq.Fields, field)
+				queryFieldMap[field] = field
+			}
+		}
+		scriptBytes, err := vjson.Marshal(script)
+		if err != nil {
+			return err
+		}
+		if searchReq.Head.Params == nil {
+			searchReq.Head.Params = make(map[string]string)
+		}
+		searchReq.Head.Params[entity.ScoreScriptParam] = string(scriptBytes)
+	}
+
+	if searchDoc.UDF != nil {
+		if searchDoc.Boost != nil || searchDoc.ScoreScript != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("udf should not be set with boost or score_script"))
+		}
+		fields, err := searchDoc.UDF.Validate(space)
+		if err != nil {
+			return err
+		}
+		searchDoc.UDF.MetricType = metricType
+		for _, field := range fields {
+			if queryFieldMap[field] == "" && sortFieldMap[field] == "" {
+				searchReq.Fields = append(searchReq.Fields, field)
+				queryFieldMap[field] = field
+			}
+		}
+		udf, err := vjson.Marshal(searchDoc.UDF)
+		if err != nil {
+			return err
+		}
+		if searchReq.Head.Params == nil {
+			searchReq.Head.Params = make(map[string]string)
+		}
+		searchReq.Head.Params[entity.UDFParam] = string(udf)
+	}
+
+	if searchDoc.MMR != nil {
+		if err := prepareMMR(searchDoc, space, spaceProMap, metricType, queryFieldMap, searchReq); err != nil {
+			return err
+		}
+	}
+
 	if searchDoc.Ranker != nil && string(searchDoc.Ranker) != "" && len(searchDoc.Vectors) > 1 {
 		err = parseRanker(searchDoc.Ranker, searchReq)
 		if err != nil {
@@ -901,6 +1004,13 @@ func requestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Space,
 		}
 	}
 
+	if searchDoc.Provenance {
+		if searchReq.Head.Params == nil {
+			searchReq.Head.Params = make(map[string]string)
+		}
+		searchReq.Head.Params[entity.ProvenanceParam] = "true"
+	}
+
 	searchReq.Head.ClientType = searchDoc.LoadBalance
 	return nil
 }
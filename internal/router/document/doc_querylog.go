@@ -0,0 +1,131 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/querylog"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// queryLog captures sampled searches of this router
+type queryLog struct {
+	writer  *querylog.Writer
+	percent uint64 // float64 bits
+}
+
+func newQueryLog(cfg *config.QueryLogCfg) (*queryLog, error) {
+	q := &queryLog{}
+	if cfg == nil || cfg.Path == "" {
+		return q, nil
+	}
+	writer, err := querylog.NewWriter(cfg.Path, cfg.MaxSizeMB)
+	if err != nil {
+		return nil, err
+	}
+	q.writer = writer
+	if err := q.setPercent(cfg.SamplePercent); err != nil {
+		return nil, err
+	}
+	log.Info("capture %.2f%% searches to %s", cfg.SamplePercent, cfg.Path)
+	return q, nil
+}
+
+func (q *queryLog) setPercent(percent float64) error {
+	if percent < 0 || percent > 100 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("sample_percent should be in [0, 100]"))
+	}
+	if percent > 0 && q.writer == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("query log path of router is not configured"))
+	}
+	atomic.StoreUint64(&q.percent, math.Float64bits(percent))
+	return nil
+}
+
+func (q *queryLog) getPercent() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&q.percent))
+}
+
+// sample returns the body to capture if the search is sampled, it is
+// marshaled before the search is changed by experiments
+func (q *queryLog) sample(searchDoc *request.SearchDocumentRequest) json.RawMessage {
+	if q.writer == nil || rand.Float64()*100 >= q.getPercent() {
+		return nil
+	}
+	body, err := json.Marshal(searchDoc)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+func (q *queryLog) capture(path string, body json.RawMessage, startTime time.Time) {
+	if body == nil {
+		return
+	}
+	q.writer.Write(&querylog.Record{
+		Time: startTime.UnixNano(),
+		Path: path,
+		Cost: float64(time.Since(startTime).Microseconds()) / 1000,
+		Body: body,
+	})
+}
+
+type queryLogStatus struct {
+	SamplePercent float64 `json:"sample_percent"`
+	Dropped       uint64  `json:"dropped"`
+}
+
+func (q *queryLog) status() *queryLogStatus {
+	s := &queryLogStatus{SamplePercent: q.getPercent()}
+	if q.writer != nil {
+		s.Dropped = q.writer.Dropped()
+	}
+	return s
+}
+
+// handleConfigQueryLog changes the sample percent of query capture of this
+// router, 0 stops capturing
+func (handler *DocumentHandler) handleConfigQueryLog(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleConfigQueryLog", startTime)
+	status := &queryLogStatus{}
+	if err := c.ShouldBindJSON(status); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := handler.queryLog.setPercent(status.SamplePercent); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	response.New(c).JsonSuccess(handler.queryLog.status())
+}
+
+func (handler *DocumentHandler) handleGetConfigQueryLog(c *gin.Context) {
+	response.New(c).JsonSuccess(handler.queryLog.status())
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+const defaultReadRepairInterval = 600 // seconds
+
+// readRepair asks master to check and repair the partitions found divergent
+// by gets, in background so the get is not blocked
+type readRepair struct {
+	client   *client.Client
+	interval time.Duration
+	mu       sync.Mutex
+	last     map[entity.PartitionID]time.Time // partition -> last repair requested
+}
+
+func newReadRepair(cfg *config.ReadRepairCfg, cli *client.Client) *readRepair {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = defaultReadRepairInterval
+	}
+	log.Info("read repair divergent partitions, at most once every %ds", interval)
+	return &readRepair{client: cli, interval: time.Duration(interval) * time.Second, last: make(map[entity.PartitionID]time.Time)}
+}
+
+// trigger requests the repair of the partitions of divergences not repaired
+// in the interval
+func (rr *readRepair) trigger(dbName, spaceName string, divergences []*entity.ReplicaDivergence) {
+	if rr == nil || len(divergences) == 0 {
+		return
+	}
+	req := &entity.ConsistencyCheckRequest{DbName: dbName, SpaceName: spaceName, Repair: true}
+	req.PartitionIDs = rr.due(divergences, time.Now())
+	if len(req.PartitionIDs) == 0 {
+		return
+	}
+
+	go func() {
+		body, err := vjson.Marshal(req)
+		if err != nil {
+			log.Error("marshal read repair request err: %v", err)
+			return
+		}
+		log.Warn("replicas of partitions %v of space [%s/%s] diverge, request read repair", req.PartitionIDs, dbName, spaceName)
+		response, err := rr.client.Master().HTTPRequest(context.Background(), http.MethodPost, "/cluster/consistency_check", string(body))
+		if err != nil {
+			log.Error("read repair of partitions %v err: %v", req.PartitionIDs, err)
+			return
+		}
+		log.Info("read repair of partitions %v: %s", req.PartitionIDs, string(response))
+	}()
+}
+
+// due returns the partitions of divergences not repaired in the interval
+// before now, and marks them repaired at now
+func (rr *readRepair) due(divergences []*entity.ReplicaDivergence, now time.Time) []entity.PartitionID {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	var pids []entity.PartitionID
+	for _, d := range divergences {
+		if t, ok := rr.last[d.PartitionID]; ok && now.Sub(t) < rr.interval {
+			continue
+		}
+		rr.last[d.PartitionID] = now
+		pids = append(pids, d.PartitionID)
+	}
+	return pids
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func TestReadRepair_due(t *testing.T) {
+	start := time.Now()
+	divergences := func(pids ...entity.PartitionID) []*entity.ReplicaDivergence {
+		ds := make([]*entity.ReplicaDivergence, 0, len(pids))
+		for _, pid := range pids {
+			ds = append(ds, &entity.ReplicaDivergence{PartitionID: pid})
+		}
+		return ds
+	}
+	rr := &readRepair{interval: time.Minute, last: make(map[entity.PartitionID]time.Time)}
+	steps := []struct {
+		name        string
+		divergences []*entity.ReplicaDivergence
+		now         time.Time
+		want        []entity.PartitionID
+	}{
+		{
+			name:        "First divergence repaired once per partition",
+			divergences: divergences(1, 1, 2),
+			now:         start,
+			want:        []entity.PartitionID{1, 2},
+		},
+		{
+			name:        "Repaired in interval",
+			divergences: divergences(1, 3),
+			now:         start.Add(30 * time.Second),
+			want:        []entity.PartitionID{3},
+		},
+		{
+			name:        "Repaired again after interval",
+			divergences: divergences(1, 3),
+			now:         start.Add(time.Minute),
+			want:        []entity.PartitionID{1},
+		},
+	}
+	for _, step := range steps {
+		if got := rr.due(step.divergences, step.now); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: due() = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
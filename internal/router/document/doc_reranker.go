@@ -0,0 +1,149 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+)
+
+const (
+	RerankerHTTP = "http"
+
+	defaultRerankTimeout = 1000 // ms
+)
+
+// Reranker scores the relevance of texts to query, one score for each text
+// in order, higher is more relevant
+type Reranker interface {
+	Rerank(ctx context.Context, query string, texts []string) ([]float64, error)
+}
+
+// RerankerFactory builds a reranker from its router config
+type RerankerFactory func(cfg *config.RerankerCfg) (Reranker, error)
+
+var (
+	rerankersMu sync.RWMutex
+	rerankers   = map[string]RerankerFactory{RerankerHTTP: newHTTPReranker}
+)
+
+// RegisterReranker makes a reranker usable as plugin in router reranker
+// config, it should be called before the router starts
+func RegisterReranker(name string, factory RerankerFactory) {
+	rerankersMu.Lock()
+	defer rerankersMu.Unlock()
+	rerankers[name] = factory
+}
+
+// newRerankers builds the rerankers of router config by name
+func newRerankers(cfgs []*config.RerankerCfg) (map[string]Reranker, error) {
+	result := make(map[string]Reranker, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("reranker name should not be empty")
+		}
+		if _, ok := result[cfg.Name]; ok {
+			return nil, fmt.Errorf("reranker [%s] is duplicate", cfg.Name)
+		}
+		if cfg.Plugin == "" {
+			cfg.Plugin = RerankerHTTP
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = defaultRerankTimeout
+		}
+		rerankersMu.RLock()
+		factory := rerankers[cfg.Plugin]
+		rerankersMu.RUnlock()
+		if factory == nil {
+			return nil, fmt.Errorf("reranker plugin [%s] not registered", cfg.Plugin)
+		}
+		reranker, err := factory(cfg)
+		if err != nil {
+			return nil, err
+		}
+		result[cfg.Name] = reranker
+	}
+	return result, nil
+}
+
+// httpReranker posts the query and texts to a cohere compatible rerank api
+type httpReranker struct {
+	url     string
+	model   string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPReranker(cfg *config.RerankerCfg) (Reranker, error) {
+	if cfg.Url == "" {
+		return nil, fmt.Errorf("reranker [%s] url should not be empty", cfg.Name)
+	}
+	return &httpReranker{url: cfg.Url, model: cfg.Model, headers: cfg.Headers,
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Millisecond}}, nil
+}
+
+func (r *httpReranker) Rerank(ctx context.Context, query string, texts []string) ([]float64, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": r.model, "query": query, "documents": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range r.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("rerank status %d: %s", resp.StatusCode, string(msg))
+	}
+	result := &struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) != len(texts) {
+		return nil, fmt.Errorf("rerank %d texts but got %d scores", len(texts), len(result.Results))
+	}
+	scores := make([]float64, len(texts))
+	seen := make([]bool, len(texts))
+	for _, res := range result.Results {
+		if res.Index < 0 || res.Index >= len(texts) || seen[res.Index] {
+			return nil, fmt.Errorf("rerank got invalid index %d", res.Index)
+		}
+		seen[res.Index] = true
+		scores[res.Index] = res.RelevanceScore
+	}
+	return scores, nil
+}
@@ -85,6 +85,7 @@ func documentResultSerialize(item *vearchpb.Item) map[string]interface{} {
 	if item.Err != nil {
 		if item.Err.Msg != "success" {
 			result["code"] = http.StatusNotFound
+			result["error_code"] = item.Err.Code
 			result["msg"] = item.Err.Msg
 		}
 	}
@@ -241,6 +242,9 @@ func DocFieldSerialize(doc *vearchpb.Document, space *entity.Space, returnFields
 				log.Error("can not found mappping by field:[%s]", name)
 				continue
 			}
+			if !field.Stored() {
+				continue
+			}
 			switch field.FieldType {
 			case vearchpb.FieldType_STRING:
 				tempValue := string(fv.Value)
@@ -308,12 +312,17 @@ func GetDocSource(doc *vearchpb.ResultItem, space *entity.Space, from string) (m
 		switch name {
 		case entity.IdField:
 			pKey = string(fv.Value)
+		case entity.ProvenanceField:
+			source[name] = json.RawMessage(fv.Value)
 		default:
 			field := spaceProperties[name]
 			if field == nil {
 				log.Error("can not found mappping by field:[%s]", name)
 				continue
 			}
+			if !field.Stored() {
+				continue
+			}
 			switch field.FieldType {
 			case vearchpb.FieldType_STRING:
 				tempValue := string(fv.Value)
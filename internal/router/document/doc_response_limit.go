@@ -0,0 +1,95 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+const (
+	// OmittedField is set on the documents whose fields are dropped as the
+	// response is beyond its byte budget
+	OmittedField = "_omitted"
+
+	// contextRoleName is the role of the user of a request in its gin context
+	contextRoleName = "role_name"
+)
+
+// keys of a document kept when its fields are omitted
+var omitKeepKeys = map[string]bool{entity.IdField: true, "_score": true, "code": true, "msg": true}
+
+// limitResponse keeps the documents of a read response in the byte budget
+// of the request, the documents are taken by rank so the best hits of every
+// query are the last ones omitted
+func limitResponse(c *gin.Context, cfg *config.ResponseLimitCfg, dbName, spaceName string, result map[string]interface{}) {
+	role := c.GetString(contextRoleName)
+	budget := cfg.Budget(role, dbName, spaceName)
+	if budget <= 0 {
+		return
+	}
+	var used int64
+	truncated := false
+	for _, doc := range rankedDocuments(result) {
+		size := documentSize(doc)
+		if used+size > budget {
+			for key := range doc {
+				if !omitKeepKeys[key] {
+					delete(doc, key)
+				}
+			}
+			doc[OmittedField] = true
+			size = documentSize(doc)
+			truncated = true
+		}
+		used += size
+	}
+	if truncated {
+		result["truncated"] = true
+		result["budget_bytes"] = budget
+	}
+}
+
+// rankedDocuments returns the documents of a read response by rank, the
+// hits of the queries of a search are interleaved
+func rankedDocuments(result map[string]interface{}) []map[string]interface{} {
+	documents, ok := result["documents"].([][]map[string]interface{})
+	if !ok {
+		return resultDocuments(result)
+	}
+	var docs []map[string]interface{}
+	for rank := 0; ; rank++ {
+		n := len(docs)
+		for _, hits := range documents {
+			if rank < len(hits) {
+				docs = append(docs, hits[rank])
+			}
+		}
+		if len(docs) == n {
+			return docs
+		}
+	}
+}
+
+func documentSize(doc map[string]interface{}) int64 {
+	bs, err := json.Marshal(doc)
+	if err != nil {
+		return 0
+	}
+	return int64(len(bs))
+}
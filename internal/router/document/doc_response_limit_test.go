@@ -0,0 +1,75 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+)
+
+func TestLimitResponse(t *testing.T) {
+	cfg := &config.ResponseLimitCfg{
+		DefaultBytes: 150,
+		Spaces:       map[string]int64{"db/large": 10000},
+		Roles:        map[string]int64{"export": 0},
+	}
+	payload := strings.Repeat("x", 40)
+	searchResult := func() map[string]interface{} {
+		return map[string]interface{}{"documents": [][]map[string]interface{}{
+			{{"_id": "1", "_score": 1.0, "text": payload}, {"_id": "2", "_score": 0.5, "text": payload}},
+			{{"_id": "3", "_score": 0.9, "text": payload}},
+		}}
+	}
+	tests := []struct {
+		name      string
+		role      string
+		space     string
+		omitted   []string
+		truncated bool
+	}{
+		{name: "Default budget", space: "small", omitted: []string{"2"}, truncated: true},
+		{name: "Space budget", space: "large"},
+		{name: "Unlimited role", role: "export", space: "small"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.role != "" {
+				c.Set(contextRoleName, tt.role)
+			}
+			result := searchResult()
+			limitResponse(c, cfg, "db", tt.space, result)
+			var omitted []string
+			for _, doc := range resultDocuments(result) {
+				if doc[OmittedField] == true {
+					if _, ok := doc["text"]; ok {
+						t.Errorf("document %v omitted with its fields", doc["_id"])
+					}
+					omitted = append(omitted, doc["_id"].(string))
+				}
+			}
+			if strings.Join(omitted, ",") != strings.Join(tt.omitted, ",") {
+				t.Errorf("omitted = %v, want %v", omitted, tt.omitted)
+			}
+			if (result["truncated"] == true) != tt.truncated {
+				t.Errorf("truncated = %v, want %v", result["truncated"], tt.truncated)
+			}
+		})
+	}
+}
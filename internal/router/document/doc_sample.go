@@ -0,0 +1,80 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// prepareSample checks the sample of a query and fixes its seed, the matches
+// to pick from are the first limit ones in the merged order
+func prepareSample(searchDoc *request.SearchDocumentRequest) error {
+	sample := searchDoc.Sample
+	if sample == nil {
+		return nil
+	}
+	if searchDoc.DocumentIds != nil && len(*searchDoc.DocumentIds) > 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("sample only works with filters"))
+	}
+	limit := searchDoc.Limit
+	if limit == 0 {
+		limit = DefaultSize
+	}
+	if sample.Size <= 0 || sample.Size > limit {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("sample size should be in [1, limit %d]", limit))
+	}
+	if sample.Seed == nil {
+		seed := time.Now().UnixNano()
+		sample.Seed = &seed
+	}
+	return nil
+}
+
+// sampleResults keeps size items of each result picked by reservoir with the
+// seed, the kept items stay in the merged order
+func sampleResults(sample *request.Sample, results []*vearchpb.SearchResult) {
+	if sample == nil {
+		return
+	}
+	size := int(sample.Size)
+	for _, result := range results {
+		items := result.ResultItems
+		if len(items) <= size {
+			continue
+		}
+		r := rand.New(rand.NewSource(*sample.Seed))
+		picked := make([]int, size)
+		for i := range picked {
+			picked[i] = i
+		}
+		for i := size; i < len(items); i++ {
+			if j := r.Intn(i + 1); j < size {
+				picked[j] = i
+			}
+		}
+		sort.Ints(picked)
+		kept := make([]*vearchpb.ResultItem, size)
+		for i, idx := range picked {
+			kept[i] = items[idx]
+		}
+		result.ResultItems = kept
+	}
+}
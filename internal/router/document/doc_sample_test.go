@@ -0,0 +1,107 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestPrepareSample(t *testing.T) {
+	seed := int64(7)
+	tests := []struct {
+		name    string
+		req     request.SearchDocumentRequest
+		wantErr bool
+	}{
+		{name: "No sample", req: request.SearchDocumentRequest{}},
+		{name: "Seed kept", req: request.SearchDocumentRequest{Limit: 10, Sample: &request.Sample{Size: 5, Seed: &seed}}},
+		{name: "Seed set", req: request.SearchDocumentRequest{Sample: &request.Sample{Size: 1}}},
+		{name: "Size above limit", req: request.SearchDocumentRequest{Limit: 2, Sample: &request.Sample{Size: 3}}, wantErr: true},
+		{name: "Zero size", req: request.SearchDocumentRequest{Limit: 2, Sample: &request.Sample{}}, wantErr: true},
+		{name: "With document ids", req: request.SearchDocumentRequest{DocumentIds: &[]string{"1"}, Sample: &request.Sample{Size: 1}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := prepareSample(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prepareSample() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.req.Sample != nil && tt.req.Sample.Seed == nil {
+				t.Error("prepareSample() left seed unset")
+			}
+		})
+	}
+}
+
+func TestSampleResults(t *testing.T) {
+	results := func(n int) []*vearchpb.SearchResult {
+		result := &vearchpb.SearchResult{}
+		for i := 0; i < n; i++ {
+			result.ResultItems = append(result.ResultItems, &vearchpb.ResultItem{Score: float64(n - i)})
+		}
+		return []*vearchpb.SearchResult{result}
+	}
+	scores := func(rs []*vearchpb.SearchResult) []float64 {
+		s := make([]float64, 0)
+		for _, item := range rs[0].ResultItems {
+			s = append(s, item.Score)
+		}
+		return s
+	}
+	seed, other := int64(1), int64(2)
+	tests := []struct {
+		name string
+		size int32
+		n    int
+	}{
+		{name: "Sample of more matches", size: 3, n: 20},
+		{name: "Fewer matches than size", size: 5, n: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := results(tt.n), results(tt.n)
+			sampleResults(&request.Sample{Size: tt.size, Seed: &seed}, a)
+			sampleResults(&request.Sample{Size: tt.size, Seed: &seed}, b)
+			if !reflect.DeepEqual(scores(a), scores(b)) {
+				t.Errorf("same seed sampled %v and %v", scores(a), scores(b))
+			}
+			want := int(tt.size)
+			if tt.n < want {
+				want = tt.n
+			}
+			got := scores(a)
+			if len(got) != want {
+				t.Fatalf("sampled %d items, want %d", len(got), want)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i] >= got[i-1] {
+					t.Errorf("sample %v is not in merged order", got)
+				}
+			}
+		})
+	}
+
+	// a different seed picks another sample of the same matches
+	a, b := results(100), results(100)
+	sampleResults(&request.Sample{Size: 10, Seed: &seed}, a)
+	sampleResults(&request.Sample{Size: 10, Seed: &other}, b)
+	if reflect.DeepEqual(scores(a), scores(b)) {
+		t.Errorf("seeds %d and %d sampled the same %v", seed, other, scores(a))
+	}
+}
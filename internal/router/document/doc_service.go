@@ -22,6 +22,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/redact"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 	"github.com/vearch/vearch/v3/internal/ps/engine/sortorder"
 )
@@ -59,7 +60,7 @@ func (docService *docService) getDocs(ctx context.Context, args *vearchpb.GetReq
 	request := client.NewRouterRequest(ctx, docService.client)
 	request.SetMsgID(args.Head.Params["request_id"]).SetMethod(client.GetDocsHandler).SetHead(args.Head).SetSpace().SetDocsByKey(args.PrimaryKeys).PartitionDocs()
 	if request.Err != nil {
-		log.Errorf("getDoc args:[%v] error: [%s]", args, request.Err)
+		log.Errorf("getDoc args:[%s] error: [%s]", redact.Value(args), request.Err)
 		return &vearchpb.GetResponse{Head: setErrHead(request.Err)}
 	}
 	items := request.Execute()
@@ -68,6 +69,25 @@ func (docService *docService) getDocs(ctx context.Context, args *vearchpb.GetReq
 	return reply
 }
 
+// getDocsCheckReplicas gets docs from the leaders like getDocs and compares
+// them with the followers, the result of the leaders is returned
+func (docService *docService) getDocsCheckReplicas(ctx context.Context, args *vearchpb.GetRequest) (*vearchpb.GetResponse, []*entity.ReplicaDivergence) {
+	ctx, cancel := setTimeout(ctx, args.Head)
+	defer cancel()
+	reply := &vearchpb.GetResponse{Head: newOkHead()}
+	request := client.NewRouterRequest(ctx, docService.client)
+	request.SetMsgID(args.Head.Params["request_id"]).SetMethod(client.GetDocsHandler).SetHead(args.Head).SetSpace().SetDocsByKey(args.PrimaryKeys).PartitionDocs()
+	if request.Err != nil {
+		log.Errorf("getDoc args:[%s] error: [%s]", redact.Value(args), request.Err)
+		return &vearchpb.GetResponse{Head: setErrHead(request.Err)}, nil
+	}
+	items := request.Execute()
+	divergences := request.CompareReplicas(items)
+	reply.Head.Params = request.GetMD()
+	reply.Items = items
+	return reply, divergences
+}
+
 func (docService *docService) getDocsByPartition(ctx context.Context, args *vearchpb.GetRequest, partitionId uint32, next *bool) *vearchpb.GetResponse {
 	reply := &vearchpb.GetResponse{Head: newOkHead()}
 	request := client.NewRouterRequest(ctx, docService.client)
@@ -77,7 +97,7 @@ func (docService *docService) getDocsByPartition(ctx context.Context, args *vear
 		request.SetMsgID(args.Head.Params["request_id"]).SetMethod(client.GetDocsByPartitionHandler).SetHead(args.Head).SetSpace().SetDocsBySpecifyKey(args.PrimaryKeys).SetSendMap(partitionId)
 	}
 	if request.Err != nil {
-		log.Errorf("getDoc args:[%v] error: [%s]", args, request.Err)
+		log.Errorf("getDoc args:[%s] error: [%s]", redact.Value(args), request.Err)
 		return &vearchpb.GetResponse{Head: setErrHead(request.Err)}
 	}
 	items := request.Execute()
@@ -92,7 +112,7 @@ func (docService *docService) deleteDocs(ctx context.Context, args *vearchpb.Del
 	request := client.NewRouterRequest(ctx, docService.client)
 	request.SetMsgID(args.Head.Params["request_id"]).SetMethod(client.DeleteDocsHandler).SetHead(args.Head).SetSpace().SetDocsByKey(args.PrimaryKeys).SetDocsField().PartitionDocs()
 	if request.Err != nil {
-		log.Errorf("delete args:[%v] error: [%s]", args, request.Err)
+		log.Errorf("delete args:[%s] error: [%s]", redact.Value(args), request.Err)
 		return &vearchpb.DeleteResponse{Head: setErrHead(request.Err)}
 	}
 	items := request.Execute()
@@ -106,7 +126,7 @@ func (docService *docService) bulk(ctx context.Context, args *vearchpb.BulkReque
 	request := client.NewRouterRequest(ctx, docService.client)
 	request.SetMsgID(args.Head.Params["request_id"]).SetMethod(client.BatchHandler).SetHead(args.Head).SetSpace().SetDocs(args.Docs).SetDocsField().UpsertByPartitions(args.Partitions)
 	if request.Err != nil {
-		log.Errorf("bulk args:[%v] error: [%s]", args, request.Err)
+		log.Errorf("bulk args:[%s] error: [%s]", redact.Value(args), request.Err)
 		return &vearchpb.BulkResponse{Head: setErrHead(request.Err)}
 	}
 	reply.Items = request.Execute()
@@ -116,11 +136,7 @@ func (docService *docService) bulk(ctx context.Context, args *vearchpb.BulkReque
 
 // utils
 func setErrHead(err error) *vearchpb.ResponseHead {
-	vErr, ok := err.(*vearchpb.VearchErr)
-	if !ok {
-		vErr = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err)
-	}
-	return &vearchpb.ResponseHead{Err: vErr.GetError()}
+	return &vearchpb.ResponseHead{Err: vearchpb.ToError(err)}
 }
 
 func newOkHead() *vearchpb.ResponseHead {
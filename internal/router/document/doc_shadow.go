@@ -0,0 +1,286 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/algorithm"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	// mirrored searches beyond it are dropped so the shadow never slows down
+	// the primary traffic
+	maxShadowInflight    = 64
+	defaultShadowTimeout = 1000 // ms
+)
+
+// shadowMirror holds the shadow rules of this router by source space
+type shadowMirror struct {
+	mu       sync.RWMutex
+	rules    map[string]*config.ShadowCfg
+	inflight chan struct{}
+	http     *http.Client
+}
+
+func newShadowMirror(rules []*config.ShadowCfg) (*shadowMirror, error) {
+	s := &shadowMirror{
+		rules:    make(map[string]*config.ShadowCfg),
+		inflight: make(chan struct{}, maxShadowInflight),
+		http:     &http.Client{},
+	}
+	if err := s.set(rules); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func shadowKey(dbName, spaceName string) string {
+	return dbName + "/" + spaceName
+}
+
+func shadowTarget(rule *config.ShadowCfg) string {
+	if rule.TargetUrl != "" {
+		return strings.TrimRight(rule.TargetUrl, "/") + "/" + shadowKey(rule.TargetDb, rule.TargetSpace)
+	}
+	return shadowKey(rule.TargetDb, rule.TargetSpace)
+}
+
+// set replaces all the rules
+func (s *shadowMirror) set(rules []*config.ShadowCfg) error {
+	m := make(map[string]*config.ShadowCfg, len(rules))
+	for _, rule := range rules {
+		if rule.DbName == "" || rule.SpaceName == "" || rule.TargetSpace == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("db_name, space_name and target_space of shadow should not be empty"))
+		}
+		if rule.TargetDb == "" {
+			rule.TargetDb = rule.DbName
+		}
+		if rule.TargetUrl == "" && rule.TargetDb == rule.DbName && rule.TargetSpace == rule.SpaceName {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("shadow target of %s is itself", shadowKey(rule.DbName, rule.SpaceName)))
+		}
+		if rule.Percent < 0 || rule.Percent > 100 || rule.DiffPercent < 0 || rule.DiffPercent > 100 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("percent and diff_percent of shadow should be in [0, 100]"))
+		}
+		if rule.Timeout <= 0 {
+			rule.Timeout = defaultShadowTimeout
+		}
+		key := shadowKey(rule.DbName, rule.SpaceName)
+		if _, ok := m[key]; ok {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("duplicate shadow of %s", key))
+		}
+		m[key] = rule
+	}
+	s.mu.Lock()
+	s.rules = m
+	s.mu.Unlock()
+	return nil
+}
+
+// list returns the rules without password
+func (s *shadowMirror) list() []*config.ShadowCfg {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rules := make([]*config.ShadowCfg, 0, len(s.rules))
+	for _, rule := range s.rules {
+		r := *rule
+		if r.Password != "" {
+			r.Password = "******"
+		}
+		rules = append(rules, &r)
+	}
+	return rules
+}
+
+func (s *shadowMirror) rule(dbName, spaceName string) *config.ShadowCfg {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rules[shadowKey(dbName, spaceName)]
+}
+
+// handleConfigShadow replaces the shadow rules of this router with the body,
+// it only changes the router serving the request
+func (handler *DocumentHandler) handleConfigShadow(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleConfigShadow", startTime)
+	rules := make([]*config.ShadowCfg, 0)
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if err := handler.shadow.set(rules); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	log.Info("shadow rules changed to %d rules", len(rules))
+	response.New(c).JsonSuccess(handler.shadow.list())
+}
+
+func (handler *DocumentHandler) handleGetConfigShadow(c *gin.Context) {
+	response.New(c).JsonSuccess(handler.shadow.list())
+}
+
+// mirrorSearch sends the search to the shadow target of the space in the
+// background, sampled ones are diffed with the primary results
+func (handler *DocumentHandler) mirrorSearch(searchDoc *request.SearchDocumentRequest, primary []*vearchpb.SearchResult) {
+	rule := handler.shadow.rule(searchDoc.DbName, searchDoc.SpaceName)
+	if rule == nil || rand.Float64()*100 >= rule.Percent {
+		return
+	}
+	space, target := shadowKey(searchDoc.DbName, searchDoc.SpaceName), shadowTarget(rule)
+
+	var expect [][]string
+	if rand.Float64()*100 < rule.DiffPercent {
+		expect = make([][]string, len(primary))
+		for i, sr := range primary {
+			for _, item := range sr.ResultItems {
+				expect[i] = append(expect[i], item.PKey)
+			}
+		}
+	}
+
+	select {
+	case handler.shadow.inflight <- struct{}{}:
+	default:
+		monitor.ShadowObserve(space, target, monitor.ShadowDropped, 0)
+		return
+	}
+
+	doc := *searchDoc
+	doc.DbName, doc.SpaceName = rule.TargetDb, rule.TargetSpace
+	go func() {
+		defer func() { <-handler.shadow.inflight }()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rule.Timeout)*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		var ids [][]string
+		var err error
+		if rule.TargetUrl != "" {
+			ids, err = handler.shadowRemoteSearch(ctx, rule, &doc)
+		} else {
+			ids, err = handler.shadowLocalSearch(ctx, &doc)
+		}
+		if err != nil {
+			log.Debug("shadow search of %s to %s err: %v", space, target, err)
+			monitor.ShadowObserve(space, target, monitor.ShadowError, 0)
+			return
+		}
+		monitor.ShadowObserve(space, target, monitor.ShadowOK, time.Since(start))
+
+		if len(expect) > 0 {
+			sum := 0.0
+			for i := range expect {
+				var got []string
+				if i < len(ids) {
+					got = ids[i]
+				}
+				sum += algorithm.Recall(got, expect[i], len(expect[i]))
+			}
+			monitor.ShadowDiff(space, target, sum/float64(len(expect)))
+		}
+	}()
+}
+
+// shadowLocalSearch searches another space of this cluster
+func (handler *DocumentHandler) shadowLocalSearch(ctx context.Context, doc *request.SearchDocumentRequest) ([][]string, error) {
+	searchReq := &vearchpb.SearchRequest{
+		Head: &vearchpb.RequestHead{DbName: doc.DbName, SpaceName: doc.SpaceName, Params: make(map[string]string)},
+	}
+	space, err := handler.docService.getSpace(ctx, searchReq.Head)
+	if err != nil {
+		return nil, err
+	}
+	doc.SpaceName = searchReq.Head.SpaceName
+	if err := requestToPb(doc, space, searchReq); err != nil {
+		return nil, err
+	}
+	searchResp := handler.docService.search(ctx, searchReq)
+	if head := searchResp.Head; head != nil && head.Err != nil && head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(head.Err.Code, fmt.Errorf("%s", head.Err.Msg))
+	}
+	ids := make([][]string, len(searchResp.Results))
+	for i, sr := range searchResp.Results {
+		for _, item := range sr.ResultItems {
+			ids[i] = append(ids[i], item.PKey)
+		}
+	}
+	return ids, nil
+}
+
+// shadowRemoteSearch searches the router of another cluster
+func (handler *DocumentHandler) shadowRemoteSearch(ctx context.Context, rule *config.ShadowCfg, doc *request.SearchDocumentRequest) ([][]string, error) {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(rule.TargetUrl, "/")+"/document/search", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if rule.User != "" {
+		req.SetBasicAuth(rule.User, rule.Password)
+	}
+	resp, err := handler.shadow.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			Documents [][]map[string]interface{} `json:"documents"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(b, reply); err != nil {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if reply.Code != 0 {
+		return nil, fmt.Errorf("code %d: %s", reply.Code, reply.Msg)
+	}
+	ids := make([][]string, len(reply.Data.Documents))
+	for i, docs := range reply.Data.Documents {
+		for _, d := range docs {
+			id, _ := d[entity.IdField].(string)
+			ids[i] = append(ids[i], id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/config"
+)
+
+func TestShadowMirror_set(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []*config.ShadowCfg
+		wantErr bool
+	}{
+		{
+			name:  "Valid local and remote rules",
+			rules: []*config.ShadowCfg{{DbName: "db", SpaceName: "a", TargetSpace: "a_new", Percent: 10}, {DbName: "db", SpaceName: "b", TargetSpace: "b", TargetUrl: "http://router:9001/", Percent: 100, DiffPercent: 50}},
+		},
+		{
+			name:    "Invalid rule without target space",
+			rules:   []*config.ShadowCfg{{DbName: "db", SpaceName: "a"}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid rule mirroring to itself",
+			rules:   []*config.ShadowCfg{{DbName: "db", SpaceName: "a", TargetSpace: "a"}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid rule with percent beyond 100",
+			rules:   []*config.ShadowCfg{{DbName: "db", SpaceName: "a", TargetSpace: "b", Percent: 120}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid duplicate rules of a space",
+			rules:   []*config.ShadowCfg{{DbName: "db", SpaceName: "a", TargetSpace: "b"}, {DbName: "db", SpaceName: "a", TargetSpace: "c"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newShadowMirror(tt.rules); (err != nil) != tt.wantErr {
+				t.Errorf("newShadowMirror() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShadowMirror_rule(t *testing.T) {
+	s, err := newShadowMirror([]*config.ShadowCfg{
+		{DbName: "db", SpaceName: "a", TargetSpace: "a_new"},
+		{DbName: "db", SpaceName: "b", TargetDb: "other", TargetSpace: "b", TargetUrl: "http://router:9001/", Password: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := s.rule("db", "a")
+	if local == nil || local.TargetDb != "db" || local.Timeout != defaultShadowTimeout {
+		t.Fatalf("rule of db/a = %+v, want target db and timeout defaulted", local)
+	}
+	if got := shadowTarget(local); got != "db/a_new" {
+		t.Errorf("shadowTarget() = %s, want db/a_new", got)
+	}
+	if got := shadowTarget(s.rule("db", "b")); got != "http://router:9001/other/b" {
+		t.Errorf("shadowTarget() = %s, want http://router:9001/other/b", got)
+	}
+	if s.rule("db", "c") != nil {
+		t.Errorf("space without rule should not be mirrored")
+	}
+	for _, rule := range s.list() {
+		if rule.Password == "secret" {
+			t.Errorf("list should mask the password")
+		}
+	}
+	if s.rule("db", "b").Password != "secret" {
+		t.Errorf("list should not change the rule")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// fetchQueryVectors replaces the document_id of query vectors by the vectors
+// stored in the documents, the limit is raised by the documents excluded from
+// results so they do not shorten the results, returns the ids to exclude
+func (handler *DocumentHandler) fetchQueryVectors(ctx context.Context, head *vearchpb.RequestHead, space *entity.Space, searchDoc *request.SearchDocumentRequest) (map[string]bool, error) {
+	queries := make([]*VectorQuery, len(searchDoc.Vectors))
+	keys := make([]string, 0)
+	for i, raw := range searchDoc.Vectors {
+		vq := &VectorQuery{}
+		if err := vjson.Unmarshal(raw, vq); err != nil {
+			return nil, err
+		}
+		if vq.DocumentId == "" {
+			continue
+		}
+		if len(vq.FeatureData) > 0 {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field:[%s] should have only one of feature and document_id", vq.Field))
+		}
+		queries[i] = vq
+		keys = append(keys, vq.DocumentId)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	args := &vearchpb.GetRequest{
+		Head:        &vearchpb.RequestHead{DbName: head.DbName, SpaceName: head.SpaceName, Params: head.Params},
+		PrimaryKeys: keys,
+	}
+	reply := handler.docService.getDocs(ctx, args)
+	if reply.Head != nil && reply.Head.Err != nil && reply.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return nil, vearchpb.NewError(reply.Head.Err.Code, fmt.Errorf("%s", reply.Head.Err.Msg))
+	}
+	docs := make(map[string]*vearchpb.Document, len(reply.Items))
+	for _, item := range reply.Items {
+		if item != nil && item.Err == nil && item.Doc != nil {
+			docs[item.Doc.PKey] = item.Doc
+		}
+	}
+
+	var exclude map[string]bool
+	for i, vq := range queries {
+		if vq == nil {
+			continue
+		}
+		doc := docs[vq.DocumentId]
+		if doc == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document_id:[%s] of vector field:[%s] not found", vq.DocumentId, vq.Field))
+		}
+		feature, err := storedVector(space, doc, vq.Field)
+		if err != nil {
+			return nil, err
+		}
+		fields := make(map[string]json.RawMessage)
+		if err := vjson.Unmarshal(searchDoc.Vectors[i], &fields); err != nil {
+			return nil, err
+		}
+		delete(fields, "document_id")
+		delete(fields, "exclude_document")
+		fields["feature"] = feature
+		if searchDoc.Vectors[i], err = vjson.Marshal(fields); err != nil {
+			return nil, err
+		}
+		if vq.Exclude {
+			if exclude == nil {
+				exclude = make(map[string]bool)
+			}
+			exclude[vq.DocumentId] = true
+		}
+	}
+	if exclude != nil {
+		if searchDoc.Limit == 0 {
+			searchDoc.Limit = DefaultSize
+		}
+		searchDoc.Limit += int32(len(exclude))
+	}
+	return exclude, nil
+}
+
+// storedVector returns the vector of field in doc as the feature of a query
+func storedVector(space *entity.Space, doc *vearchpb.Document, field string) (json.RawMessage, error) {
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	pro := proMap[field]
+	if pro == nil || pro.FieldType != vearchpb.FieldType_VECTOR {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] is not vector type", field))
+	}
+	for _, fv := range doc.Fields {
+		if fv.Name != field {
+			continue
+		}
+		if space.Index.Type == "BINARYIVF" {
+			vector, err := cbbytes.ByteToVectorBinary(fv.Value, pro.Dimension)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(vector)
+		}
+		vector, err := cbbytes.ByteToVectorForFloat32(fv.Value)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(vector)
+	}
+	return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document:[%s] has no vector field:[%s]", doc.PKey, field))
+}
+
+// excludeResults drops the excluded documents and trims each result to limit
+func excludeResults(exclude map[string]bool, limit int32, results []*vearchpb.SearchResult) {
+	if len(exclude) == 0 {
+		return
+	}
+	for _, result := range results {
+		items := result.ResultItems[:0]
+		for _, item := range result.ResultItems {
+			if !exclude[item.PKey] {
+				items = append(items, item)
+			}
+		}
+		if int32(len(items)) > limit {
+			items = items[:limit]
+		}
+		result.ResultItems = items
+	}
+}
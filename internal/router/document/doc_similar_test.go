@@ -0,0 +1,107 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestStoredVector(t *testing.T) {
+	vector, err := cbbytes.VectorToByte([]float32{1, 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	space := &entity.Space{
+		Index: &entity.Index{Type: "FLAT"},
+		SpaceProperties: map[string]*entity.SpaceProperties{
+			"vec":  {FieldType: vearchpb.FieldType_VECTOR, Dimension: 2},
+			"name": {FieldType: vearchpb.FieldType_STRING},
+		},
+	}
+	doc := &vearchpb.Document{PKey: "1", Fields: []*vearchpb.Field{{Name: "vec", Value: vector}}}
+	tests := []struct {
+		name    string
+		field   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Stored vector", field: "vec", want: "[1,0.5]"},
+		{name: "Not vector field", field: "name", wantErr: true},
+		{name: "Unknown field", field: "other", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := storedVector(space, doc, tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("storedVector() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("storedVector() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude map[string]bool
+		limit   int32
+		items   []string
+		want    []string
+	}{
+		{
+			name:    "Source document dropped",
+			exclude: map[string]bool{"1": true},
+			limit:   2,
+			items:   []string{"1", "2", "3"},
+			want:    []string{"2", "3"},
+		},
+		{
+			name:    "Trimmed to limit when source not found",
+			exclude: map[string]bool{"9": true},
+			limit:   2,
+			items:   []string{"1", "2", "3"},
+			want:    []string{"1", "2"},
+		},
+		{
+			name:  "Nothing excluded",
+			limit: 2,
+			items: []string{"1", "2", "3"},
+			want:  []string{"1", "2", "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &vearchpb.SearchResult{}
+			for _, key := range tt.items {
+				result.ResultItems = append(result.ResultItems, &vearchpb.ResultItem{PKey: key})
+			}
+			excludeResults(tt.exclude, tt.limit, []*vearchpb.SearchResult{result})
+			if len(result.ResultItems) != len(tt.want) {
+				t.Fatalf("excludeResults() = %d items, want %d", len(result.ResultItems), len(tt.want))
+			}
+			for i, item := range result.ResultItems {
+				if item.PKey != tt.want[i] {
+					t.Errorf("item %d = %s, want %s", i, item.PKey, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func (handler *DocumentHandler) handleDocumentSimilarity(c *gin.Context) {
+	startTime := time.Now()
+	defer monitor.Profiler("handleDocumentSimilarity", startTime)
+	simReq := &entity.SimilarityRequest{}
+	if err := c.ShouldBindJSON(simReq); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	head := &vearchpb.RequestHead{DbName: simReq.DbName, SpaceName: simReq.SpaceName, Params: make(map[string]string)}
+	space, err := handler.docService.getSpace(c.Request.Context(), head)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	if m := space.Metric(simReq.MetricType); m != nil {
+		simReq.SpaceMetric = m
+	}
+	if err := simReq.Validate(); err != nil {
+		response.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	addr, err := handler.partitionLeaderAddr(c.Request.Context(), space)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	result, err := client.Similarity(addr, simReq)
+	if err != nil {
+		response.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	response.New(c).JsonSuccess(result)
+}
+
+// partitionLeaderAddr returns the rpc address of the leader of the first
+// partition of space
+func (handler *DocumentHandler) partitionLeaderAddr(ctx context.Context, space *entity.Space) (string, error) {
+	if len(space.Partitions) == 0 {
+		return "", vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_EXIST, fmt.Errorf("space %s has no partition", space.Name))
+	}
+	partition, err := handler.client.Master().Cache().PartitionByCache(ctx, space.Name, space.Partitions[0].Id)
+	if err != nil {
+		return "", err
+	}
+	server, err := handler.client.Master().Cache().ServerByCache(ctx, partition.LeaderID)
+	if err != nil {
+		return "", err
+	}
+	return server.RpcAddr(), nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// keys of a returned document not subject to _source
+var sourceKeepKeys = map[string]bool{entity.IdField: true, "_score": true, "_docid": true, "code": true, "msg": true, HydrateErrorField: true, entity.ProvenanceField: true}
+
+// sourceFields drops the fields not stored or not matching _source from the
+// fields to fetch, asking for a field not stored is an error
+func sourceFields(searchDoc *request.SearchDocumentRequest, spaceProMap map[string]*entity.SpaceProperties, fields []string) ([]string, error) {
+	if searchDoc.Source != nil {
+		if err := searchDoc.Source.Validate(); err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+		}
+	}
+	result := fields[:0:0]
+	for _, field := range fields {
+		if field != entity.IdField {
+			if pro := spaceProMap[field]; pro != nil && !pro.Stored() {
+				if len(searchDoc.Fields) > 0 {
+					return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field [%s] is not stored", field))
+				}
+				continue
+			}
+			if searchDoc.Source != nil && !searchDoc.Source.Match(field) {
+				continue
+			}
+		}
+		result = append(result, field)
+	}
+	return result, nil
+}
+
+// filterSource drops the fields not matching _source from the documents of a
+// read response, such as the sort or boost fields fetched for ranking only
+func filterSource(result map[string]interface{}, filter *request.SourceFilter) {
+	if filter == nil {
+		return
+	}
+	filterDoc := func(doc map[string]interface{}) {
+		for key := range doc {
+			if !sourceKeepKeys[key] && !filter.Match(key) {
+				delete(doc, key)
+			}
+		}
+	}
+	switch documents := result["documents"].(type) {
+	case []map[string]interface{}:
+		for _, doc := range documents {
+			filterDoc(doc)
+		}
+	case [][]map[string]interface{}:
+		for _, docs := range documents {
+			for _, doc := range docs {
+				filterDoc(doc)
+			}
+		}
+	}
+}
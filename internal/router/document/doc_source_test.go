@@ -0,0 +1,94 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+)
+
+func TestSourceFields(t *testing.T) {
+	notStored := false
+	spaceProMap := map[string]*entity.SpaceProperties{
+		"title":  {},
+		"tag":    {Store: &notStored},
+		"vec":    {},
+		"vec_ts": {},
+	}
+	tests := []struct {
+		name    string
+		fields  []string
+		asked   []string
+		source  *request.SourceFilter
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "Fields not stored are dropped",
+			fields: []string{entity.IdField, "title", "tag", "vec"},
+			want:   []string{entity.IdField, "title", "vec"},
+		},
+		{
+			name:    "Asking for a field not stored fails",
+			fields:  []string{"title", "tag"},
+			asked:   []string{"title", "tag"},
+			wantErr: true,
+		},
+		{
+			name:   "Excludes win over includes and the id is kept",
+			fields: []string{entity.IdField, "title", "vec", "vec_ts"},
+			source: &request.SourceFilter{Includes: []string{"vec*", "title"}, Excludes: []string{"vec_*"}},
+			want:   []string{entity.IdField, "title", "vec"},
+		},
+		{
+			name:    "Invalid pattern fails",
+			fields:  []string{"title"},
+			source:  &request.SourceFilter{Includes: []string{"[title"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searchDoc := &request.SearchDocumentRequest{Fields: tt.asked, Source: tt.source}
+			got, err := sourceFields(searchDoc, spaceProMap, tt.fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sourceFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sourceFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterSource(t *testing.T) {
+	result := map[string]interface{}{"documents": [][]map[string]interface{}{{
+		{entity.IdField: "1", "_score": 0.9, "title": "a", "price": 3},
+	}}}
+	filterSource(result, &request.SourceFilter{Excludes: []string{"price"}})
+	want := map[string]interface{}{entity.IdField: "1", "_score": 0.9, "title": "a"}
+	if got := result["documents"].([][]map[string]interface{})[0][0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("filterSource() = %v, want %v", got, want)
+	}
+
+	docs := []map[string]interface{}{{entity.IdField: "1", "title": "a", "price": 3}}
+	filterSource(map[string]interface{}{"documents": docs}, &request.SourceFilter{Includes: []string{"title"}})
+	if !reflect.DeepEqual(docs[0], map[string]interface{}{entity.IdField: "1", "title": "a"}) {
+		t.Errorf("filterSource() = %v", docs[0])
+	}
+}
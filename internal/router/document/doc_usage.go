@@ -0,0 +1,135 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/master/store"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+const defaultUsageFlushInterval = 60 // seconds
+
+// usageMeter accumulates the cost of the reads of this router by user and
+// space, and adds it to the daily rollups in etcd periodically
+type usageMeter struct {
+	client  *client.Client
+	mu      sync.Mutex
+	pending map[string]*entity.Usage // usage key -> usage not flushed
+}
+
+func newUsageMeter(cfg *config.UsageCfg, cli *client.Client) *usageMeter {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	interval := cfg.FlushInterval
+	if interval <= 0 {
+		interval = defaultUsageFlushInterval
+	}
+	m := &usageMeter{client: cli, pending: make(map[string]*entity.Usage)}
+	go func() {
+		for range time.Tick(time.Duration(interval) * time.Second) {
+			m.flush(context.Background())
+		}
+	}()
+	log.Info("account usage of requests, flush every %ds", interval)
+	return m
+}
+
+// record adds a read of the user of request to the usage of the space, it
+// should be called after the response is written
+func (m *usageMeter) record(c *gin.Context, dbName, spaceName string, documents, vectors int) {
+	if m == nil {
+		return
+	}
+	user, _, ok := c.Request.BasicAuth()
+	if !ok || user == "" {
+		user = entity.UsageAnonymous
+	}
+	bytes := c.Writer.Size()
+	if bytes < 0 {
+		bytes = 0
+	}
+	date := entity.UsageDate(time.Now())
+	key := entity.UsageKey(date, user, dbName, spaceName)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.pending[key]
+	if !ok {
+		u = &entity.Usage{Date: date, User: user, DbName: dbName, SpaceName: spaceName}
+		m.pending[key] = u
+	}
+	u.Add(&entity.Usage{Requests: 1, Documents: int64(documents), Vectors: int64(vectors), Bytes: int64(bytes)})
+}
+
+// flush adds the pending usage to etcd, usage failed to flush is kept for
+// the next flush
+func (m *usageMeter) flush(ctx context.Context) {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[string]*entity.Usage)
+	m.mu.Unlock()
+
+	for key, u := range pending {
+		err := m.client.Master().STM(ctx, func(stm store.STM) error {
+			total := &entity.Usage{Date: u.Date, User: u.User, DbName: u.DbName, SpaceName: u.SpaceName}
+			if value := stm.Get(key); value != "" {
+				if err := vjson.Unmarshal([]byte(value), total); err != nil {
+					return err
+				}
+			}
+			total.Add(u)
+			bs, err := vjson.Marshal(total)
+			if err != nil {
+				return err
+			}
+			stm.Put(key, string(bs))
+			return nil
+		})
+		if err != nil {
+			log.Error("flush usage of key [%s] err: %v", key, err)
+			m.mu.Lock()
+			if p, ok := m.pending[key]; ok {
+				p.Add(u)
+			} else {
+				m.pending[key] = u
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+// resultDocuments returns the documents of a read response
+func resultDocuments(result map[string]interface{}) []map[string]interface{} {
+	var docs []map[string]interface{}
+	switch documents := result["documents"].(type) {
+	case []map[string]interface{}:
+		docs = documents
+	case [][]map[string]interface{}:
+		for _, d := range documents {
+			docs = append(docs, d...)
+		}
+	}
+	return docs
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import "testing"
+
+func TestResultDocuments(t *testing.T) {
+	tests := []struct {
+		name   string
+		result map[string]interface{}
+		want   int
+	}{
+		{
+			name:   "Query documents",
+			result: map[string]interface{}{"documents": []map[string]interface{}{{"_id": "1"}, {"_id": "2"}}},
+			want:   2,
+		},
+		{
+			name: "Search documents by vector",
+			result: map[string]interface{}{"documents": [][]map[string]interface{}{
+				{{"_id": "1"}, {"_id": "2"}},
+				{{"_id": "3"}},
+			}},
+			want: 3,
+		},
+		{
+			name:   "No documents",
+			result: map[string]interface{}{"total": 0},
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resultDocuments(tt.result); len(got) != tt.want {
+				t.Errorf("resultDocuments() = %d documents, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
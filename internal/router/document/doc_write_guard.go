@@ -0,0 +1,212 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// neighbors searched for a document written, its own id may be one of them
+const writeGuardNeighbors = 3
+
+// writeDuplicate is a document of a write within the epsilon of the write
+// guard of another document, Index is its position in the write since a
+// document without _id has no id before it is written, and a duplicate of
+// such a document of the write is of documents[n]
+type writeDuplicate struct {
+	Index       int     `json:"index"`
+	ID          string  `json:"_id,omitempty"`
+	DuplicateOf string  `json:"duplicate_of"`
+	Score       float64 `json:"score"`
+	Rejected    bool    `json:"rejected"`
+}
+
+// guardWrites checks the parsed documents of a write by the write guard of
+// space. The guard searches the whole space rather than the partitions the
+// documents are routed to, since a duplicate with another id may be in any
+// partition. Rejected duplicates are removed from the write and kept by the
+// dead letter if any, flagged ones are written with the id they duplicate.
+func (handler *DocumentHandler) guardWrites(ctx context.Context, space *entity.Space, docRequest *request.DocumentRequest, args *vearchpb.BulkRequest, d *deadLetters) ([]*writeDuplicate, error) {
+	guard := space.WriteGuard
+	if guard == nil || len(args.Docs) == 0 {
+		return nil, nil
+	}
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	field := proMap[guard.Field]
+	if field == nil || field.FieldType != vearchpb.FieldType_VECTOR {
+		return nil, nil
+	}
+	metric := vectorMetricType(field)
+
+	vectors := make([][]float32, len(args.Docs))
+	for i, doc := range args.Docs {
+		for _, fv := range doc.Fields {
+			if fv.Name != guard.Field || len(fv.Value) == 0 {
+				continue
+			}
+			vector, err := cbbytes.ByteToVectorForFloat32(fv.Value)
+			if err != nil {
+				return nil, err
+			}
+			vectors[i] = vector
+		}
+	}
+
+	found := findWriteDuplicates(guard, metric, args.Docs, vectors)
+
+	ids := make([]int, 0, dedupSearchBatch)
+	features := make([]float32, 0, dedupSearchBatch*field.Dimension)
+	flush := func() error {
+		if len(ids) == 0 {
+			return nil
+		}
+		neighbors, err := handler.dedupSearch(ctx, space, &request.DedupRequest{
+			DbName:      docRequest.DbName,
+			SpaceName:   docRequest.SpaceName,
+			Field:       guard.Field,
+			Neighbors:   writeGuardNeighbors,
+			IndexParams: guard.IndexParams,
+		}, features)
+		if err != nil {
+			return err
+		}
+		for n, items := range neighbors {
+			if n >= len(ids) {
+				break
+			}
+			i := ids[n]
+			for _, item := range items {
+				if key := args.Docs[i].PKey; key != "" && item.PKey == key {
+					continue
+				}
+				if guard.Near(metric, item.Score) {
+					found[i] = &writeDuplicate{Index: i, ID: args.Docs[i].PKey, DuplicateOf: item.PKey, Score: item.Score}
+				}
+				break
+			}
+		}
+		ids, features = ids[:0], features[:0]
+		return nil
+	}
+	for i, vector := range vectors {
+		if vector == nil || found[i] != nil {
+			continue
+		}
+		ids = append(ids, i)
+		features = append(features, vector...)
+		if len(ids) >= dedupSearchBatch {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	duplicates := make([]*writeDuplicate, 0)
+	for _, dup := range found {
+		if dup != nil {
+			duplicates = append(duplicates, dup)
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil, nil
+	}
+	if guard.Action == entity.WriteGuardFlag {
+		for _, dup := range duplicates {
+			flagDuplicate(args.Docs[dup.Index], guard.FlagField, dup.DuplicateOf)
+		}
+		return duplicates, nil
+	}
+
+	aligned := len(args.Docs) == len(docRequest.Documents)
+	docs := make([]*vearchpb.Document, 0, len(args.Docs)-len(duplicates))
+	raws := docRequest.Documents[:0]
+	for i, doc := range args.Docs {
+		if dup := found[i]; dup != nil {
+			dup.Rejected = true
+			if aligned {
+				d.add(docRequest.Documents[i], entity.DeadLetterStageWriteGuard, fmt.Errorf("duplicate of document %s, score %v", dup.DuplicateOf, dup.Score))
+			}
+			continue
+		}
+		docs = append(docs, doc)
+		if aligned {
+			raws = append(raws, docRequest.Documents[i])
+		}
+	}
+	args.Docs = docs
+	if aligned {
+		docRequest.Documents = raws
+	}
+	return duplicates, nil
+}
+
+// findWriteDuplicates returns the documents within the epsilon of guard of a
+// document earlier in the same write, scored as the engine does: the squared
+// distance for L2 and the inner product else
+func findWriteDuplicates(guard *entity.WriteGuard, metric string, docs []*vearchpb.Document, vectors [][]float32) []*writeDuplicate {
+	found := make([]*writeDuplicate, len(docs))
+	for i, a := range vectors {
+		if a == nil {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			b := vectors[j]
+			if b == nil || len(b) != len(a) || (docs[i].PKey != "" && docs[i].PKey == docs[j].PKey) {
+				continue
+			}
+			score := float64(0)
+			for k := range a {
+				if metric == "L2" {
+					diff := float64(a[k] - b[k])
+					score += diff * diff
+				} else {
+					score += float64(a[k]) * float64(b[k])
+				}
+			}
+			if guard.Near(metric, score) {
+				of := docs[j].PKey
+				if of == "" {
+					of = fmt.Sprintf("documents[%d]", j)
+				}
+				found[i] = &writeDuplicate{Index: i, ID: docs[i].PKey, DuplicateOf: of, Score: score}
+				break
+			}
+		}
+	}
+	return found
+}
+
+// flagDuplicate sets the flag field of doc to the id it duplicates
+func flagDuplicate(doc *vearchpb.Document, flagField, duplicateOf string) {
+	for _, fv := range doc.Fields {
+		if fv.Name == flagField {
+			fv.Value = []byte(duplicateOf)
+			return
+		}
+	}
+	doc.Fields = append(doc.Fields, &vearchpb.Field{Name: flagField, Type: vearchpb.FieldType_STRING, Value: []byte(duplicateOf)})
+}
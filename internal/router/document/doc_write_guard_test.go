@@ -0,0 +1,99 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestFindWriteDuplicates(t *testing.T) {
+	docs := func(keys ...string) []*vearchpb.Document {
+		ds := make([]*vearchpb.Document, len(keys))
+		for i, key := range keys {
+			ds[i] = &vearchpb.Document{PKey: key}
+		}
+		return ds
+	}
+	tests := []struct {
+		name    string
+		guard   *entity.WriteGuard
+		metric  string
+		docs    []*vearchpb.Document
+		vectors [][]float32
+		want    []string // duplicate of, "" if not a duplicate
+	}{
+		{
+			name:    "Inner product within epsilon",
+			guard:   &entity.WriteGuard{Epsilon: 0.05},
+			metric:  "InnerProduct",
+			docs:    docs("a", "b", "c"),
+			vectors: [][]float32{{1, 0}, {0.99, 0.1}, {0, 1}},
+			want:    []string{"", "a", ""},
+		},
+		{
+			name:    "L2 by squared distance",
+			guard:   &entity.WriteGuard{Epsilon: 0.02},
+			metric:  "L2",
+			docs:    docs("a", "b", "c"),
+			vectors: [][]float32{{1, 1}, {1.1, 1}, {1.2, 1}},
+			want:    []string{"", "a", "b"},
+		},
+		{
+			name:    "Same id is not a duplicate",
+			guard:   &entity.WriteGuard{Epsilon: 0},
+			metric:  "L2",
+			docs:    docs("a", "a"),
+			vectors: [][]float32{{1, 1}, {1, 1}},
+			want:    []string{"", ""},
+		},
+		{
+			name:    "Documents without id or vector",
+			guard:   &entity.WriteGuard{Epsilon: 0},
+			metric:  "L2",
+			docs:    docs("", "", "c"),
+			vectors: [][]float32{{1, 1}, {1, 1}, nil},
+			want:    []string{"", "documents[0]", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := findWriteDuplicates(tt.guard, tt.metric, tt.docs, tt.vectors)
+			for i, want := range tt.want {
+				got := ""
+				if found[i] != nil {
+					got = found[i].DuplicateOf
+				}
+				if got != want {
+					t.Errorf("document %d duplicate of %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFlagDuplicate(t *testing.T) {
+	doc := &vearchpb.Document{PKey: "b", Fields: []*vearchpb.Field{{Name: "dup", Type: vearchpb.FieldType_STRING, Value: []byte("x")}}}
+	flagDuplicate(doc, "dup", "a")
+	if len(doc.Fields) != 1 || string(doc.Fields[0].Value) != "a" {
+		t.Errorf("flag field should be replaced, got %v", doc.Fields)
+	}
+	flagDuplicate(doc, "dup_of", "a")
+	if len(doc.Fields) != 2 || doc.Fields[1].Name != "dup_of" || string(doc.Fields[1].Value) != "a" {
+		t.Errorf("flag field should be added, got %v", doc.Fields)
+	}
+}
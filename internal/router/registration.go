@@ -0,0 +1,168 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package router
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+)
+
+// RegistrationTTL is the seconds the registration of a router outlives its
+// last keepalive
+const RegistrationTTL = 10
+
+// registration keeps the info of this router under the routers prefix while
+// the router is healthy, clients and load balancers discover routers by it
+type registration struct {
+	cli   *client.Client
+	key   string
+	value []byte
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // of the lease keepalive, nil if not registered
+	lost   chan struct{}      // closed when the keepalive ends
+	closed bool
+}
+
+func newRegistration(cli *client.Client, info *entity.RouterInfo) (*registration, error) {
+	value, err := vjson.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return &registration{cli: cli, key: entity.RouterRegistrationKey(config.Conf().Global.Name, info.Address), value: value}, nil
+}
+
+// registered is false once the lease of the registration is lost
+func (r *registration) registered() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel == nil {
+		return false
+	}
+	select {
+	case <-r.lost:
+		r.cancel()
+		r.cancel = nil
+		return false
+	default:
+		return true
+	}
+}
+
+func (r *registration) register(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.cancel != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	keepaliveC, err := r.cli.Master().Store.KeepAlive(ctx, r.key, r.value, time.Second*RegistrationTTL)
+	if err != nil {
+		cancel()
+		return err
+	}
+	lost := make(chan struct{})
+	go func() {
+		defer close(lost)
+		for range keepaliveC {
+		}
+	}()
+	r.cancel, r.lost = cancel, lost
+	return nil
+}
+
+// deregister removes the registration at once instead of waiting for its
+// lease to expire
+func (r *registration) deregister() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.cancel = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := r.cli.Master().Store.Delete(ctx, r.key); err != nil {
+		log.Warn("delete registration %s err: %v, it expires in %d seconds", r.key, err, RegistrationTTL)
+	}
+}
+
+// close deregisters for good, the router is shutting down
+func (r *registration) close() {
+	r.deregister()
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+}
+
+// healthy is whether the router serves every request, a degraded router
+// only serves reads from its cache snapshot
+func (s *Server) healthy() bool {
+	return s.cli.Master().Cache().SnapshotTime().IsZero()
+}
+
+// StartRegistrationJob registers this router under the routers prefix while
+// it is healthy and removes the registration while it is not
+func (s *Server) StartRegistrationJob(routerIP string) {
+	cfg := config.Conf().Router
+	info := &entity.RouterInfo{
+		Address:   cfg.AdvertiseAddr,
+		Version:   config.GetBuildVersion(),
+		StartTime: time.Now().Unix(),
+	}
+	if info.Address == "" {
+		info.Address = fmt.Sprintf("%s:%d", routerIP, cfg.Port)
+	}
+	if cfg.RpcPort > 0 {
+		info.RpcAddress = fmt.Sprintf("%s:%d", routerIP, cfg.RpcPort)
+	}
+	r, err := newRegistration(s.cli, info)
+	if err != nil {
+		log.Error("new registration of router %s err: %v", info.Address, err)
+		return
+	}
+	s.registration = r
+	go func() {
+		ticker := time.NewTicker(time.Second * RegistrationTTL / 2)
+		defer ticker.Stop()
+		for {
+			healthy := s.healthy()
+			if healthy && !r.registered() {
+				if err := r.register(s.ctx); err != nil {
+					log.Warn("register router %s err: %v", info.Address, err)
+				} else {
+					log.Info("router %s registered for discovery", info.Address)
+				}
+			} else if !healthy && r.registered() {
+				r.deregister()
+				log.Warn("router %s is unhealthy, deregistered from discovery", info.Address)
+			}
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
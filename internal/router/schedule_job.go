@@ -15,6 +15,7 @@
 package router
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/vearch/vearch/v3/internal/config"
@@ -56,3 +57,57 @@ func (s *Server) StartHeartbeatJob(addr string) {
 		}
 	}()
 }
+
+// recover a router started from the cache snapshot once etcd is reachable
+func (s *Server) StartRecoverJob(routerIP string) {
+	go func() {
+		ticker := time.NewTicker(time.Second * KeepAliveTime)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := s.cli.Master().RegisterRouter(s.ctx, config.Conf().Global.Name, time.Duration(10*time.Second)); err != nil {
+				log.Warn("router is degraded, register router failed, err: %v", err)
+				continue
+			}
+			if err := flushCacheJob(s.ctx, s.cli); err != nil {
+				log.Warn("router is degraded, start cache job failed, err: %v", err)
+				continue
+			}
+			log.Info("router recovered from degraded read only mode")
+			if config.Conf().Router.RpcPort > 0 {
+				s.StartHeartbeatJob(fmt.Sprintf("%s:%d", routerIP, config.Conf().Router.RpcPort))
+			}
+			return
+		}
+	}()
+}
+
+// this job persists the meta cache for a router starting without etcd
+func (s *Server) StartCacheSnapshotJob() {
+	snapshot := config.Conf().Router.CacheSnapshot
+	if snapshot == nil || snapshot.Path == "" {
+		return
+	}
+	interval := snapshot.Interval
+	if interval <= 0 {
+		interval = 60
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.cli.Master().Cache().SaveSnapshot(snapshot.Path); err != nil {
+					log.Error("save cache snapshot to %s failed, err: %v", snapshot.Path, err)
+				}
+			}
+		}
+	}()
+}
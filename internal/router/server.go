@@ -41,6 +41,8 @@ type Server struct {
 	httpServer *gin.Engine
 	rpcServer  *grpc.Server
 	cancelFunc context.CancelFunc
+	// registration is removed before shutting down
+	registration *registration
 }
 
 func NewServer(ctx context.Context) (*Server, error) {
@@ -49,13 +51,24 @@ func NewServer(ctx context.Context) (*Server, error) {
 		return nil, err
 	}
 
+	degraded := false
 	res, err := cli.Master().RegisterRouter(ctx, config.Conf().Global.Name, time.Duration(10*time.Second))
 	if err != nil {
-		return nil, err
+		snapshot := config.Conf().Router.CacheSnapshot
+		if snapshot == nil || snapshot.Path == "" {
+			return nil, err
+		}
+		snapshotTime, loadErr := cli.Master().LoadCacheSnapshot(ctx, snapshot.Path)
+		if loadErr != nil {
+			log.Error("register router failed and load cache snapshot failed, err: %v", loadErr)
+			return nil, err
+		}
+		log.Warn("register router failed, err: %v, start in degraded read only mode from cache snapshot of %s", err, snapshotTime.Format(time.RFC3339))
+		degraded = true
+	} else {
+		log.Info("register router success, res: %s", res)
 	}
 
-	log.Info("register router success, res: %s", res)
-
 	addr := config.LocalCastAddr
 
 	gin.SetMode(gin.ReleaseMode)
@@ -104,10 +117,12 @@ func NewServer(ctx context.Context) (*Server, error) {
 	}
 
 	routerCtx, routerCancel := context.WithCancel(ctx)
-	// start router cache
-	if err := cli.Master().FlushCacheJob(routerCtx); err != nil {
-		log.Error("Error in Start cache Job,Err:%v", err)
-		panic(err)
+	// start router cache, a degraded router starts it once etcd is back
+	if !degraded {
+		if err := flushCacheJob(routerCtx, cli); err != nil {
+			log.Error("Error in Start cache Job,Err:%v", err)
+			panic(err)
+		}
 	}
 
 	return &Server{
@@ -129,9 +144,15 @@ func (server *Server) Start() error {
 	}
 	log.Debugf("Get router ip: [%s]", routerIP)
 	mserver.SetIp(routerIP, false)
-	if config.Conf().Router.RpcPort > 0 {
-		server.StartHeartbeatJob(fmt.Sprintf("%s:%d", routerIP, config.Conf().Router.RpcPort))
+	if server.cli.Master().Cache().SnapshotTime().IsZero() {
+		if config.Conf().Router.RpcPort > 0 {
+			server.StartHeartbeatJob(fmt.Sprintf("%s:%d", routerIP, config.Conf().Router.RpcPort))
+		}
+	} else {
+		server.StartRecoverJob(routerIP)
 	}
+	server.StartCacheSnapshotJob()
+	server.StartRegistrationJob(routerIP)
 
 	if port := config.Conf().Router.MonitorPort; port > 0 {
 		monitor.Register(nil, nil, config.Conf().Router.MonitorPort)
@@ -146,6 +167,10 @@ func (server *Server) Start() error {
 }
 
 func (server *Server) Shutdown() {
+	// clients stop discovering the router before it stops serving
+	if server.registration != nil {
+		server.registration.close()
+	}
 	server.cancelFunc()
 	log.Info("router shutdown... start")
 	if server.httpServer != nil {
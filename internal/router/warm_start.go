@@ -0,0 +1,89 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package router
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/netutil"
+)
+
+const defaultWarmStartTimeout = 10 // seconds
+
+// flushCacheJob starts the meta cache of the router, filled from the cache of
+// a running router if warm start is enabled, or by scanning etcd
+func flushCacheJob(ctx context.Context, cli *client.Client) error {
+	cfg := config.Conf().Router.WarmStart
+	if cfg != nil && cfg.Enabled {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = defaultWarmStartTimeout
+		}
+		for _, peer := range warmStartPeers(ctx, cli, cfg) {
+			err := cli.Master().FlushCacheJobFromPeer(ctx, func() ([]byte, error) {
+				return fetchPeerCache(peer, int64(timeout))
+			})
+			if err == nil {
+				log.Info("meta cache warmed from router %s", peer)
+				return nil
+			}
+			log.Warn("warm meta cache from router %s failed, err: %v", peer, err)
+		}
+		log.Warn("no router to warm the meta cache from, scan etcd")
+	}
+	return cli.Master().FlushCacheJob(ctx)
+}
+
+// warmStartPeers returns the configured peers, or the registered routers
+// other than this one on the router port
+func warmStartPeers(ctx context.Context, cli *client.Client, cfg *config.WarmStartCfg) []string {
+	if len(cfg.Peers) > 0 {
+		return cfg.Peers
+	}
+	_, values, err := cli.Master().PrefixScan(ctx, entity.PrefixRouter)
+	if err != nil {
+		log.Error("scan routers to warm the meta cache err: %v", err)
+		return nil
+	}
+	localIP, _ := netutil.GetLocalIP()
+	peers := make([]string, 0, len(values))
+	seen := make(map[string]bool)
+	for _, value := range values {
+		host, _, err := net.SplitHostPort(string(value))
+		if err != nil || host == localIP || seen[host] {
+			continue
+		}
+		seen[host] = true
+		peers = append(peers, fmt.Sprintf("%s:%d", host, config.Conf().Router.Port))
+	}
+	return peers
+}
+
+// fetchPeerCache gets the meta cache snapshot of the router at peer
+func fetchPeerCache(peer string, timeout int64) ([]byte, error) {
+	query := netutil.NewQuery().SetHeader(client.Authorization, netutil.AuthEncrypt(client.Root, config.Conf().Global.Signkey))
+	query.SetAddress("http://" + peer)
+	query.SetMethod(http.MethodGet)
+	query.SetUrlPath("/cache/snapshot")
+	query.SetTimeout(timeout)
+	return query.Do()
+}
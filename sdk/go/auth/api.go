@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+	"github.com/vearch/vearch/v3/sdk/go/except"
+)
+
+// API manages the users and the roles of the cluster, it needs a client
+// authenticated as root or as a user with the ResourceUser and
+// ResourceRole privileges
+type API struct {
+	connection *connection.Connection
+}
+
+func New(con *connection.Connection) *API {
+	return &API{connection: con}
+}
+
+func (auth *API) CreateUser(name string) *UserCreator {
+	return &UserCreator{connection: auth.connection, name: name}
+}
+
+func (auth *API) GetUser(name string) *UserGetter {
+	return &UserGetter{connection: auth.connection, name: name}
+}
+
+func (auth *API) ListUsers() *UserLister {
+	return &UserLister{connection: auth.connection}
+}
+
+func (auth *API) DeleteUser(name string) *UserDeleter {
+	return &UserDeleter{connection: auth.connection, name: name}
+}
+
+// ChangePassword changes the password of a user, root may leave out the old
+// password of the other users
+func (auth *API) ChangePassword(name string) *PasswordChanger {
+	return &PasswordChanger{connection: auth.connection, name: name}
+}
+
+// SetRole gives the user another role
+func (auth *API) SetRole(name, roleName string) *RoleSetter {
+	return &RoleSetter{connection: auth.connection, name: name, roleName: roleName}
+}
+
+func (auth *API) CreateRole(name string) *RoleCreator {
+	return &RoleCreator{connection: auth.connection, name: name, privileges: map[models.Resource]models.Privilege{}}
+}
+
+func (auth *API) GetRole(name string) *RoleGetter {
+	return &RoleGetter{connection: auth.connection, name: name}
+}
+
+func (auth *API) ListRoles() *RoleLister {
+	return &RoleLister{connection: auth.connection}
+}
+
+func (auth *API) DeleteRole(name string) *RoleDeleter {
+	return &RoleDeleter{connection: auth.connection, name: name}
+}
+
+// Grant adds privileges to a role, replacing the ones it has on the same
+// resources
+func (auth *API) Grant(roleName string) *PrivilegeChanger {
+	return &PrivilegeChanger{connection: auth.connection, name: roleName, operator: operatorGrant, privileges: map[models.Resource]models.Privilege{}}
+}
+
+// Revoke removes the privileges of a role on the resources, whatever they
+// are
+func (auth *API) Revoke(roleName string, resources ...models.Resource) *PrivilegeChanger {
+	privileges := make(map[models.Resource]models.Privilege, len(resources))
+	for _, resource := range resources {
+		privileges[resource] = models.PrivilegeNone
+	}
+	return &PrivilegeChanger{connection: auth.connection, name: roleName, operator: operatorRevoke, privileges: privileges}
+}
+
+// decode checks the status and the code of a response and decodes its data
+// into target, target may be nil
+func decode(responseData *connection.ResponseData, err error, target interface{}, format string, args ...interface{}) error {
+	if err := except.CheckResponseDataErrorAndStatusCode(responseData, err, 200); err != nil {
+		return err
+	}
+	result := &struct {
+		Code int         `json:"code"`
+		Msg  string      `json:"msg,omitempty"`
+		Data interface{} `json:"data"`
+	}{Data: target}
+	if err := responseData.DecodeBodyIntoTarget(result); err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return except.NewClientError(responseData.StatusCode, "%s: %s", fmt.Sprintf(format, args...), result.Msg)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+	"github.com/vearch/vearch/v3/sdk/go/fault"
+)
+
+func TestAPI(t *testing.T) {
+	type request struct {
+		method, path string
+		body         map[string]interface{}
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, path: r.URL.Path}
+		if r.ContentLength > 0 {
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&req.body))
+		}
+		requests = append(requests, req)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/users/alice":
+			fmt.Fprint(w, `{"code":0,"data":{"name":"alice","role":{"name":"reader","privileges":{"ResourceDocument":"ReadOnly"}}}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/users":
+			fmt.Fprint(w, `{"code":0,"data":[{"name":"root","role":{"name":"root"}},{"name":"alice","role":{"name":"reader"}}]}`)
+		case r.Method == http.MethodPut && r.URL.Path == "/roles":
+			fmt.Fprint(w, `{"code":0,"data":{"name":"reader","privileges":{"ResourceDocument":"WriteRead"}}}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/roles/missing":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"code":401,"msg":"role not exist"}`)
+		default:
+			fmt.Fprint(w, `{"code":0}`)
+		}
+	}))
+	defer server.Close()
+
+	api := New(connection.NewConnection(server.URL, nil, nil))
+	ctx := context.Background()
+
+	require.Nil(t, api.CreateRole("reader").WithPrivilege(models.ResourceDocument, models.PrivilegeReadOnly).Do(ctx))
+	require.Nil(t, api.CreateUser("alice").WithPassword("secret").WithRoleName("reader").Do(ctx))
+	user, err := api.GetUser("alice").Do(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, &models.User{Name: "alice", Role: &models.Role{Name: "reader", Privileges: map[models.Resource]models.Privilege{models.ResourceDocument: models.PrivilegeReadOnly}}}, user)
+	users, err := api.ListUsers().Do(ctx)
+	require.Nil(t, err)
+	assert.Len(t, users, 2)
+
+	require.Nil(t, api.ChangePassword("alice").WithPassword("new").WithOldPassword("secret").Do(ctx))
+	require.Nil(t, api.SetRole("alice", "writer").Do(ctx))
+	role, err := api.Grant("reader").WithPrivilege(models.ResourceDocument, models.PrivilegeWriteRead).Do(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, models.PrivilegeWriteRead, role.Privileges[models.ResourceDocument])
+	_, err = api.Revoke("reader", models.ResourceSpace).Do(ctx)
+	require.Nil(t, err)
+
+	err = api.DeleteRole("missing").Do(ctx)
+	var clientErr *fault.ClientError
+	require.ErrorAs(t, err, &clientErr)
+	assert.Equal(t, http.StatusNotFound, clientErr.StatusCode)
+
+	// rejected before sending
+	assert.NotNil(t, api.CreateUser("bob").Do(ctx))
+	assert.NotNil(t, api.ChangePassword("bob").Do(ctx))
+	assert.NotNil(t, api.CreateRole("empty").Do(ctx))
+
+	assert.Equal(t, []request{
+		{method: http.MethodPost, path: "/roles", body: map[string]interface{}{"name": "reader", "privileges": map[string]interface{}{"ResourceDocument": "ReadOnly"}}},
+		{method: http.MethodPost, path: "/users", body: map[string]interface{}{"name": "alice", "password": "secret", "role_name": "reader"}},
+		{method: http.MethodGet, path: "/users/alice"},
+		{method: http.MethodGet, path: "/users"},
+		{method: http.MethodPut, path: "/users", body: map[string]interface{}{"name": "alice", "password": "new", "old_password": "secret"}},
+		{method: http.MethodPut, path: "/users", body: map[string]interface{}{"name": "alice", "role_name": "writer"}},
+		{method: http.MethodPut, path: "/roles", body: map[string]interface{}{"name": "reader", "operator": "Grant", "privileges": map[string]interface{}{"ResourceDocument": "WriteRead"}}},
+		{method: http.MethodPut, path: "/roles", body: map[string]interface{}{"name": "reader", "operator": "Revoke", "privileges": map[string]interface{}{"ResourceSpace": "None"}}},
+		{method: http.MethodDelete, path: "/roles/missing"},
+	}, requests)
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+	"github.com/vearch/vearch/v3/sdk/go/except"
+)
+
+const (
+	operatorGrant  = "Grant"
+	operatorRevoke = "Revoke"
+)
+
+type roleRequest struct {
+	Name       string                               `json:"name"`
+	Operator   string                               `json:"operator,omitempty"`
+	Privileges map[models.Resource]models.Privilege `json:"privileges"`
+}
+
+type RoleCreator struct {
+	connection *connection.Connection
+	name       string
+	privileges map[models.Resource]models.Privilege
+}
+
+func (rc *RoleCreator) WithPrivilege(resource models.Resource, privilege models.Privilege) *RoleCreator {
+	rc.privileges[resource] = privilege
+	return rc
+}
+
+func (rc *RoleCreator) WithPrivileges(privileges map[models.Resource]models.Privilege) *RoleCreator {
+	for resource, privilege := range privileges {
+		rc.privileges[resource] = privilege
+	}
+	return rc
+}
+
+func (rc *RoleCreator) Do(ctx context.Context) error {
+	if len(rc.privileges) == 0 {
+		return except.NewValidationError(errors.New("role needs privileges"))
+	}
+	req := &roleRequest{Name: rc.name, Privileges: rc.privileges}
+	responseData, err := rc.connection.RunREST(ctx, "/roles", http.MethodPost, req)
+	return decode(responseData, err, nil, "create role %s", rc.name)
+}
+
+type RoleGetter struct {
+	connection *connection.Connection
+	name       string
+}
+
+func (rg *RoleGetter) Do(ctx context.Context) (*models.Role, error) {
+	responseData, err := rg.connection.RunREST(ctx, fmt.Sprintf("/roles/%s", rg.name), http.MethodGet, nil)
+	role := &models.Role{}
+	if err := decode(responseData, err, role, "get role %s", rg.name); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+type RoleLister struct {
+	connection *connection.Connection
+}
+
+func (rl *RoleLister) Do(ctx context.Context) ([]*models.Role, error) {
+	responseData, err := rl.connection.RunREST(ctx, "/roles", http.MethodGet, nil)
+	roles := make([]*models.Role, 0)
+	if err := decode(responseData, err, &roles, "list roles"); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+type RoleDeleter struct {
+	connection *connection.Connection
+	name       string
+}
+
+func (rd *RoleDeleter) Do(ctx context.Context) error {
+	responseData, err := rd.connection.RunREST(ctx, fmt.Sprintf("/roles/%s", rd.name), http.MethodDelete, nil)
+	return decode(responseData, err, nil, "delete role %s", rd.name)
+}
+
+// PrivilegeChanger grants or revokes privileges of a role
+type PrivilegeChanger struct {
+	connection *connection.Connection
+	name       string
+	operator   string
+	privileges map[models.Resource]models.Privilege
+}
+
+func (pc *PrivilegeChanger) WithPrivilege(resource models.Resource, privilege models.Privilege) *PrivilegeChanger {
+	pc.privileges[resource] = privilege
+	return pc
+}
+
+func (pc *PrivilegeChanger) WithPrivileges(privileges map[models.Resource]models.Privilege) *PrivilegeChanger {
+	for resource, privilege := range privileges {
+		pc.privileges[resource] = privilege
+	}
+	return pc
+}
+
+// Do returns the role with the privileges it has after the change
+func (pc *PrivilegeChanger) Do(ctx context.Context) (*models.Role, error) {
+	if len(pc.privileges) == 0 {
+		return nil, except.NewValidationError(errors.New("no privileges to change"))
+	}
+	req := &roleRequest{Name: pc.name, Operator: pc.operator, Privileges: pc.privileges}
+	responseData, err := pc.connection.RunREST(ctx, "/roles", http.MethodPut, req)
+	role := &models.Role{}
+	if err := decode(responseData, err, role, "%s privileges of role %s", pc.operator, pc.name); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
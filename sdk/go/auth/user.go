@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+	"github.com/vearch/vearch/v3/sdk/go/except"
+)
+
+// userRequest is the body of the user apis, a role or a password change
+// per update
+type userRequest struct {
+	Name        string  `json:"name"`
+	Password    *string `json:"password,omitempty"`
+	OldPassword *string `json:"old_password,omitempty"`
+	RoleName    *string `json:"role_name,omitempty"`
+}
+
+type UserCreator struct {
+	connection *connection.Connection
+	name       string
+	password   string
+	roleName   string
+}
+
+func (uc *UserCreator) WithPassword(password string) *UserCreator {
+	uc.password = password
+	return uc
+}
+
+func (uc *UserCreator) WithRoleName(roleName string) *UserCreator {
+	uc.roleName = roleName
+	return uc
+}
+
+func (uc *UserCreator) Do(ctx context.Context) error {
+	if uc.password == "" || uc.roleName == "" {
+		return except.NewValidationError(errors.New("user needs a password and a role"))
+	}
+	req := &userRequest{Name: uc.name, Password: &uc.password, RoleName: &uc.roleName}
+	responseData, err := uc.connection.RunREST(ctx, "/users", http.MethodPost, req)
+	return decode(responseData, err, nil, "create user %s", uc.name)
+}
+
+type UserGetter struct {
+	connection *connection.Connection
+	name       string
+}
+
+// Do returns the user with its role
+func (ug *UserGetter) Do(ctx context.Context) (*models.User, error) {
+	responseData, err := ug.connection.RunREST(ctx, fmt.Sprintf("/users/%s", ug.name), http.MethodGet, nil)
+	user := &models.User{}
+	if err := decode(responseData, err, user, "get user %s", ug.name); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+type UserLister struct {
+	connection *connection.Connection
+}
+
+// Do returns the users of the cluster with their roles
+func (ul *UserLister) Do(ctx context.Context) ([]*models.User, error) {
+	responseData, err := ul.connection.RunREST(ctx, "/users", http.MethodGet, nil)
+	users := make([]*models.User, 0)
+	if err := decode(responseData, err, &users, "list users"); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+type UserDeleter struct {
+	connection *connection.Connection
+	name       string
+}
+
+func (ud *UserDeleter) Do(ctx context.Context) error {
+	responseData, err := ud.connection.RunREST(ctx, fmt.Sprintf("/users/%s", ud.name), http.MethodDelete, nil)
+	return decode(responseData, err, nil, "delete user %s", ud.name)
+}
+
+type PasswordChanger struct {
+	connection  *connection.Connection
+	name        string
+	password    string
+	oldPassword string
+}
+
+func (pc *PasswordChanger) WithPassword(password string) *PasswordChanger {
+	pc.password = password
+	return pc
+}
+
+func (pc *PasswordChanger) WithOldPassword(oldPassword string) *PasswordChanger {
+	pc.oldPassword = oldPassword
+	return pc
+}
+
+func (pc *PasswordChanger) Do(ctx context.Context) error {
+	if pc.password == "" {
+		return except.NewValidationError(errors.New("new password is empty"))
+	}
+	req := &userRequest{Name: pc.name, Password: &pc.password}
+	if pc.oldPassword != "" {
+		req.OldPassword = &pc.oldPassword
+	}
+	responseData, err := pc.connection.RunREST(ctx, "/users", http.MethodPut, req)
+	return decode(responseData, err, nil, "change password of user %s", pc.name)
+}
+
+type RoleSetter struct {
+	connection *connection.Connection
+	name       string
+	roleName   string
+}
+
+func (rs *RoleSetter) Do(ctx context.Context) error {
+	req := &userRequest{Name: rs.name, RoleName: &rs.roleName}
+	responseData, err := rs.connection.RunREST(ctx, "/users", http.MethodPut, req)
+	return decode(responseData, err, nil, "set role %s of user %s", rs.roleName, rs.name)
+}
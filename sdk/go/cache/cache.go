@@ -0,0 +1,263 @@
+// Package cache is an opt-in in-process cache of document gets by id and
+// space schema lookups of the client
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+)
+
+const (
+	DefaultTTL        = 10 * time.Second
+	DefaultMaxEntries = 10000
+)
+
+// Cache keeps the replies of document gets by id and space schema lookups.
+// A reply is served from memory for TTL, then revalidated by its etag, the
+// document version, so an unchanged document is not downloaded again.
+// Writes through the client drop the cached replies of their space, writes
+// by others are seen within TTL
+type Cache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	hits, misses, revalidated uint64
+}
+
+type entry struct {
+	key       string
+	dbName    string
+	spaceName string
+	etag      string
+	header    http.Header
+	body      []byte
+	expire    time.Time
+}
+
+// Stats counts the gets served from memory, sent to the server and
+// revalidated by etag without download
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Revalidated uint64
+	Entries     int
+}
+
+// New returns a cache of at most maxEntries replies reused for ttl, zero
+// values use the defaults
+func New(ttl time.Duration, maxEntries int) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Middleware caches the requests of a connection, it should be the last
+// middleware so the others see every request
+func (c *Cache) Middleware() connection.Middleware {
+	return func(request *http.Request, next connection.Handler) (*http.Response, error) {
+		dbName, spaceName, cacheable := parsePath(request.URL.Path)
+		if request.Method != http.MethodGet {
+			c.invalidateWrite(request, dbName, spaceName)
+			return next(request)
+		}
+		if !cacheable {
+			return next(request)
+		}
+
+		// keyed without the host so every router of a discovered cluster
+		// shares the entries
+		key := request.URL.RequestURI()
+		cached := c.get(key)
+		if cached != nil && time.Now().Before(cached.expire) {
+			c.count(&c.hits)
+			return cached.response(request), nil
+		}
+		if cached != nil && cached.etag != "" {
+			request.Header.Set("If-None-Match", cached.etag)
+		}
+
+		response, err := next(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode == http.StatusNotModified && cached != nil {
+			response.Body.Close()
+			c.count(&c.revalidated)
+			c.put(&entry{key: key, dbName: dbName, spaceName: spaceName, etag: cached.etag,
+				header: cached.header, body: cached.body, expire: time.Now().Add(c.ttl)})
+			return cached.response(request), nil
+		}
+		c.count(&c.misses)
+		if response.StatusCode != http.StatusOK {
+			c.remove(key)
+			return response, nil
+		}
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		response.Body = io.NopCloser(bytes.NewReader(body))
+		// replies of errors are 200 with a code
+		reply := &struct {
+			Code int `json:"code"`
+		}{}
+		if json.Unmarshal(body, reply) == nil && reply.Code == 0 {
+			c.put(&entry{key: key, dbName: dbName, spaceName: spaceName, etag: response.Header.Get("ETag"),
+				header: response.Header.Clone(), body: body, expire: time.Now().Add(c.ttl)})
+		}
+		return response, nil
+	}
+}
+
+// Invalidate drops the cached replies of a space, or of every space of the
+// db if spaceName is empty
+func (c *Cache) Invalidate(dbName, spaceName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, element := range c.entries {
+		e := element.Value.(*entry)
+		if e.dbName == dbName && (spaceName == "" || e.spaceName == spaceName) {
+			c.lru.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Clear drops every cached reply
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{Hits: c.hits, Misses: c.misses, Revalidated: c.revalidated, Entries: len(c.entries)}
+}
+
+// invalidateWrite drops the replies of the space a write changes, document
+// writes name their space in the body
+func (c *Cache) invalidateWrite(request *http.Request, dbName, spaceName string) {
+	if dbName != "" {
+		c.Invalidate(dbName, spaceName)
+		return
+	}
+	path := strings.TrimSuffix(request.URL.Path, "/")
+	switch {
+	case strings.HasPrefix(path, "/dbs/"):
+		// db writes
+		c.Invalidate(strings.SplitN(strings.TrimPrefix(path, "/dbs/"), "/", 2)[0], "")
+	case strings.HasPrefix(path, "/document/") && request.GetBody != nil:
+		body, err := request.GetBody()
+		if err != nil {
+			c.Clear()
+			return
+		}
+		defer body.Close()
+		target := &struct {
+			DBName    string `json:"db_name"`
+			SpaceName string `json:"space_name"`
+		}{}
+		if err := json.NewDecoder(body).Decode(target); err != nil || target.DBName == "" {
+			c.Clear()
+			return
+		}
+		c.Invalidate(target.DBName, target.SpaceName)
+	}
+}
+
+func (c *Cache) get(key string) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return element.Value.(*entry)
+}
+
+func (c *Cache) put(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[e.key]; ok {
+		element.Value = e
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+func (c *Cache) count(counter *uint64) {
+	c.mu.Lock()
+	*counter++
+	c.mu.Unlock()
+}
+
+func (e *entry) response(request *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       request,
+	}
+}
+
+// parsePath returns the space of a document get by id or space schema path,
+// and whether its replies are cached
+func parsePath(path string) (dbName, spaceName string, cacheable bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 7 && parts[0] == "document" && parts[1] == "dbs" && parts[3] == "spaces" && parts[5] == "documents":
+		return parts[2], parts[4], true
+	case len(parts) == 4 && parts[0] == "dbs" && parts[2] == "spaces":
+		return parts[1], parts[3], true
+	case len(parts) > 4 && parts[0] == "dbs" && parts[2] == "spaces":
+		// writes of space settings
+		return parts[1], parts[3], false
+	}
+	return "", "", false
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+)
+
+func TestCache_Middleware(t *testing.T) {
+	var version, downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := fmt.Sprintf(`"%d"`, atomic.LoadInt32(&version))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&downloads, 1)
+		}
+		fmt.Fprintf(w, `{"code":0,"data":{"version":%s}}`, etag)
+	}))
+	defer server.Close()
+
+	c := New(20*time.Millisecond, 10)
+	con := connection.NewConnection(server.URL, nil, nil)
+	con.Use(c.Middleware())
+	ctx := context.Background()
+	get := func() string {
+		responseData, err := con.RunREST(ctx, "/document/dbs/db/spaces/space/documents/1", http.MethodGet, nil)
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, responseData.StatusCode)
+		return string(responseData.Body)
+	}
+
+	first := get()
+	assert.Equal(t, first, get(), "served from memory within ttl")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, first, get(), "revalidated by etag after ttl")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+	// a write through the client drops the replies of its space
+	atomic.StoreInt32(&version, 1)
+	_, err := con.RunREST(ctx, "/document/upsert", http.MethodPost, map[string]string{"db_name": "db", "space_name": "space"})
+	require.Nil(t, err)
+	assert.NotEqual(t, first, get())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&downloads))
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Revalidated)
+	assert.Equal(t, 1, stats.Entries)
+}
+
+func TestParsePath(t *testing.T) {
+	db, space, cacheable := parsePath("/document/dbs/db/spaces/space/documents/1")
+	assert.Equal(t, []interface{}{"db", "space", true}, []interface{}{db, space, cacheable})
+	db, space, cacheable = parsePath("/dbs/db/spaces/space")
+	assert.Equal(t, []interface{}{"db", "space", true}, []interface{}{db, space, cacheable})
+	db, space, cacheable = parsePath("/dbs/db/spaces/space/pipeline")
+	assert.Equal(t, []interface{}{"db", "space", false}, []interface{}{db, space, cacheable})
+	_, _, cacheable = parsePath("/cluster/stats")
+	assert.False(t, cacheable)
+}
@@ -0,0 +1,19 @@
+package cluster
+
+import "github.com/vearch/vearch/v3/sdk/go/connection"
+
+type API struct {
+	connection *connection.Connection
+}
+
+func New(con *connection.Connection) *API {
+	return &API{connection: con}
+}
+
+// RouterLister lists the healthy routers of the cluster, routers register
+// themselves while healthy
+func (cluster *API) RouterLister() *RouterLister {
+	return &RouterLister{
+		connection: cluster.connection,
+	}
+}
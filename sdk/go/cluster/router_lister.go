@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+	"github.com/vearch/vearch/v3/sdk/go/except"
+)
+
+type RouterLister struct {
+	connection *connection.Connection
+}
+
+// Do returns the healthy routers registered for discovery
+func (rl *RouterLister) Do(ctx context.Context) ([]*models.Router, error) {
+	responseData, err := rl.connection.RunREST(ctx, "/discovery/routers", http.MethodGet, nil)
+	if err := except.CheckResponseDataErrorAndStatusCode(responseData, err, 200); err != nil {
+		return nil, err
+	}
+
+	result := &struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg,omitempty"`
+		Data struct {
+			Routers []*models.Router `json:"routers"`
+		} `json:"data"`
+	}{}
+	if err := responseData.DecodeBodyIntoTarget(result); err != nil {
+		return nil, err
+	}
+	if result.Code != 0 {
+		return nil, except.NewClientError(responseData.StatusCode, "list routers: %s", result.Msg)
+	}
+	return result.Data.Routers, nil
+}
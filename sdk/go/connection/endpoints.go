@@ -0,0 +1,130 @@
+package connection
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Endpoints is the set of routers a connection spreads its requests over,
+// it starts with the seed and is replaced by the routers discovered from
+// the cluster. An endpoint failing a request is dropped until the next
+// refresh
+type Endpoints struct {
+	seed   string
+	scheme string
+	next   atomic.Uint64
+
+	mu    sync.RWMutex
+	hosts []string
+	down  map[string]bool
+}
+
+// NewEndpoints returns the endpoints of the seed host
+func NewEndpoints(seed string) *Endpoints {
+	seed = strings.TrimSuffix(seed, "/")
+	scheme := "http"
+	if u, err := url.Parse(seed); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	return &Endpoints{
+		seed:   seed,
+		scheme: scheme,
+		hosts:  []string{seed},
+		down:   make(map[string]bool),
+	}
+}
+
+// Hosts returns the endpoints that are not dropped
+func (e *Endpoints) Hosts() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	hosts := make([]string, 0, len(e.hosts))
+	for _, host := range e.hosts {
+		if !e.down[host] {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Pick returns the next endpoint round robin, the seed when every endpoint
+// is dropped
+func (e *Endpoints) Pick() string {
+	hosts := e.Hosts()
+	if len(hosts) == 0 {
+		return e.seed
+	}
+	return hosts[e.next.Add(1)%uint64(len(hosts))]
+}
+
+// Drop marks the endpoint unhealthy until the next refresh
+func (e *Endpoints) Drop(host string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.down[host] = true
+}
+
+// Set replaces the endpoints by the addresses, an address without a scheme
+// takes the one of the seed. No addresses keeps the current endpoints so a
+// cluster without discovery keeps working through the seed
+func (e *Endpoints) Set(addrs []string) {
+	hosts := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr == "" {
+			continue
+		}
+		if !strings.Contains(addr, "://") {
+			addr = e.scheme + "://" + addr
+		}
+		hosts = append(hosts, strings.TrimSuffix(addr, "/"))
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.down = make(map[string]bool)
+	if len(hosts) > 0 {
+		e.hosts = hosts
+	}
+}
+
+// Refresh sets the endpoints to the addresses returned by discover every
+// interval until ctx is done, it refreshes once before returning. A failed
+// discovery keeps the endpoints but forgets the dropped ones so they are
+// tried again
+func (e *Endpoints) Refresh(ctx context.Context, interval time.Duration, discover func(ctx context.Context) ([]string, error)) {
+	refresh := func() {
+		addrs, err := discover(ctx)
+		if err != nil {
+			addrs = nil
+		}
+		e.Set(addrs)
+	}
+	refresh()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
+
+// UseEndpoints makes the connection send its requests to the endpoints
+// instead of its host
+func (con *Connection) UseEndpoints(endpoints *Endpoints) {
+	con.endpoints = endpoints
+}
+
+// Endpoints returns the endpoints of the connection, nil when it sends to
+// its host only
+func (con *Connection) Endpoints() *Endpoints {
+	return con.endpoints
+}
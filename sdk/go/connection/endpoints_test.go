@@ -0,0 +1,81 @@
+package connection
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpoints_Set(t *testing.T) {
+	tests := []struct {
+		name  string
+		seed  string
+		addrs []string
+		want  []string
+	}{
+		{"seed only", "http://seed:9001/", nil, []string{"http://seed:9001"}},
+		{"scheme of seed", "https://seed:9001", []string{"r1:9001", "", "http://r2:9001/"}, []string{"https://r1:9001", "http://r2:9001"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints := NewEndpoints(tt.seed)
+			endpoints.Set(tt.addrs)
+			assert.Equal(t, tt.want, endpoints.Hosts())
+		})
+	}
+}
+
+func TestEndpoints_Drop(t *testing.T) {
+	endpoints := NewEndpoints("http://seed:9001")
+	endpoints.Set([]string{"r1:9001", "r2:9001"})
+	endpoints.Drop("http://r1:9001")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "http://r2:9001", endpoints.Pick())
+	}
+	endpoints.Drop("http://r2:9001")
+	assert.Equal(t, "http://seed:9001", endpoints.Pick())
+
+	// a refresh tries the dropped endpoints again
+	endpoints.Set(nil)
+	assert.Equal(t, []string{"http://r1:9001", "http://r2:9001"}, endpoints.Hosts())
+}
+
+func TestConnection_Endpoints(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	endpoints := NewEndpoints(server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var discovered atomic.Int32
+	endpoints.Refresh(ctx, time.Hour, func(ctx context.Context) ([]string, error) {
+		discovered.Add(1)
+		return []string{closed.URL, server.URL}, nil
+	})
+	con := NewConnection(server.URL, nil, nil)
+	con.UseEndpoints(endpoints)
+
+	var failed int
+	for i := 0; i < 4; i++ {
+		if _, err := con.RunREST(ctx, "/", http.MethodGet, nil); err != nil {
+			failed++
+		}
+	}
+	// the closed router fails once then is dropped
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, int32(3), hits.Load())
+	assert.Equal(t, []string{server.URL}, endpoints.Hosts())
+
+	assert.Equal(t, int32(1), discovered.Load())
+}
@@ -0,0 +1,61 @@
+package connection
+
+import (
+	"net/http"
+	"time"
+)
+
+// Handler sends a request and returns its response
+type Handler func(request *http.Request) (*http.Response, error)
+
+// Middleware intercepts the requests of a connection, it may change the
+// request before calling next and inspect the response or error after, or
+// return without calling next to fail the request. The body of the response
+// is read by the connection so middlewares should not consume it
+type Middleware func(request *http.Request, next Handler) (*http.Response, error)
+
+// OnRequest returns a middleware calling hook before each request is sent,
+// an error of hook fails the request without sending it
+func OnRequest(hook func(request *http.Request) error) Middleware {
+	return func(request *http.Request, next Handler) (*http.Response, error) {
+		if err := hook(request); err != nil {
+			return nil, err
+		}
+		return next(request)
+	}
+}
+
+// OnResponse returns a middleware calling hook after each request with its
+// response or error and how long it took
+func OnResponse(hook func(request *http.Request, response *http.Response, err error, took time.Duration)) Middleware {
+	return func(request *http.Request, next Handler) (*http.Response, error) {
+		start := time.Now()
+		response, err := next(request)
+		hook(request, response, err, time.Since(start))
+		return response, err
+	}
+}
+
+// Use appends middlewares to the connection, the first added is the
+// outermost and sees the request first
+func (con *Connection) Use(middlewares ...Middleware) {
+	con.middlewares = append(con.middlewares, middlewares...)
+}
+
+func (con *Connection) do(request *http.Request, host string) (*http.Response, error) {
+	handler := func(request *http.Request) (*http.Response, error) {
+		response, err := con.httpClient.Do(request)
+		// the router could not be reached, not a middleware failing the request
+		if err != nil && con.endpoints != nil && request.Context().Err() == nil {
+			con.endpoints.Drop(host)
+		}
+		return response, err
+	}
+	for i := len(con.middlewares) - 1; i >= 0; i-- {
+		middleware, next := con.middlewares[i], handler
+		handler = func(request *http.Request) (*http.Response, error) {
+			return middleware(request, next)
+		}
+	}
+	return handler(request)
+}
@@ -0,0 +1,50 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnection_Middlewares(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace-Id")))
+	}))
+	defer server.Close()
+
+	var calls []string
+	con := NewConnection(server.URL, nil, nil)
+	con.Use(
+		func(request *http.Request, next Handler) (*http.Response, error) {
+			calls = append(calls, "outer")
+			return next(request)
+		},
+		OnRequest(func(request *http.Request) error {
+			calls = append(calls, "request")
+			request.Header.Set("X-Trace-Id", "trace-1")
+			return nil
+		}),
+		OnResponse(func(request *http.Request, response *http.Response, err error, took time.Duration) {
+			calls = append(calls, "response")
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+		}),
+	)
+
+	responseData, err := con.RunREST(context.Background(), "/", http.MethodGet, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "trace-1", string(responseData.Body))
+	assert.Equal(t, []string{"outer", "request", "response"}, calls)
+
+	denied := errors.New("denied")
+	con = NewConnection(server.URL, nil, nil)
+	con.Use(OnRequest(func(request *http.Request) error { return denied }))
+	_, err = con.RunREST(context.Background(), "/", http.MethodGet, nil)
+	assert.Equal(t, denied, err)
+}
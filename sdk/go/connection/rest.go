@@ -12,10 +12,12 @@ import (
 )
 
 type Connection struct {
-	basePath   string
-	httpClient *http.Client
-	headers    map[string]string
-	doneCh     chan bool
+	basePath    string
+	httpClient  *http.Client
+	headers     map[string]string
+	middlewares []Middleware
+	endpoints   *Endpoints
+	doneCh      chan bool
 }
 
 func finalizer(c *Connection) {
@@ -39,6 +41,16 @@ func NewConnection(host string, httpClient *http.Client, headers map[string]stri
 	return connection
 }
 
+// HTTPClient returns the http client of the connection
+func (con *Connection) HTTPClient() *http.Client {
+	return con.httpClient
+}
+
+// Headers returns the headers the connection adds to every request
+func (con *Connection) Headers() map[string]string {
+	return con.headers
+}
+
 func (con *Connection) addHeaderToRequest(request *http.Request) {
 	for k, v := range con.headers {
 		request.Header.Add(k, v)
@@ -58,8 +70,8 @@ func (con *Connection) marshalBody(body interface{}) (io.Reader, error) {
 	return bytes.NewBuffer(jsonBody), nil
 }
 
-func (con *Connection) createRequest(ctx context.Context, path string, restMethod string, body interface{}) (*http.Request, error) {
-	url := con.basePath + path
+func (con *Connection) createRequest(ctx context.Context, host string, path string, restMethod string, body interface{}) (*http.Request, error) {
+	url := host + path
 
 	jsonBody, err := con.marshalBody(body)
 	if err != nil {
@@ -76,11 +88,15 @@ func (con *Connection) createRequest(ctx context.Context, path string, restMetho
 }
 
 func (con *Connection) RunREST(ctx context.Context, path string, restMethod string, requestBody interface{}) (*ResponseData, error) {
-	request, requestErr := con.createRequest(ctx, path, restMethod, requestBody)
+	host := con.basePath
+	if con.endpoints != nil {
+		host = con.endpoints.Pick()
+	}
+	request, requestErr := con.createRequest(ctx, host, path, restMethod, requestBody)
 	if requestErr != nil {
 		return nil, requestErr
 	}
-	response, responseErr := con.httpClient.Do(request)
+	response, responseErr := con.do(request, host)
 	if responseErr != nil {
 		return nil, responseErr
 	}
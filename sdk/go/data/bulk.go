@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vearch/vearch/v3/sdk/go/except"
+)
+
+// BulkItem is the outcome of a single document of a bulk upsert or delete
+type BulkItem struct {
+	ID string `json:"_id"`
+	// Code is zero on success
+	Code int `json:"code,omitempty"`
+	// ErrorCode is the server error enum of a failed item
+	ErrorCode int    `json:"error_code,omitempty"`
+	Msg       string `json:"msg,omitempty"`
+}
+
+func (item *BulkItem) Failed() bool {
+	return item.Code != 0
+}
+
+func failedItems(items []BulkItem) []BulkItem {
+	failed := make([]BulkItem, 0)
+	for _, item := range items {
+		if item.Failed() {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+// Items returns the per document results of the upsert
+func (wrapper *DocWrapper) Items() []BulkItem {
+	if wrapper == nil || wrapper.Docs == nil {
+		return nil
+	}
+	return wrapper.Docs.Data.DocumentIds
+}
+
+// FailedItems returns the documents which were not written
+func (wrapper *DocWrapper) FailedItems() []BulkItem {
+	return failedItems(wrapper.Items())
+}
+
+// PartialSuccess reports whether some but not all documents were written
+func (wrapper *DocWrapper) PartialSuccess() bool {
+	failed := len(wrapper.FailedItems())
+	return failed > 0 && failed < len(wrapper.Items())
+}
+
+// RetryFailed upserts again the documents reported as failed, only
+// documents with an explicit _id can be matched with their result
+func (wrapper *DocWrapper) RetryFailed(ctx context.Context) (*DocWrapper, error) {
+	failed := wrapper.FailedItems()
+	if len(failed) == 0 {
+		return wrapper, nil
+	}
+	if wrapper.creator == nil {
+		return nil, except.NewValidationError(fmt.Errorf("no upsert request to retry"))
+	}
+
+	byID := make(map[string]interface{}, len(wrapper.creator.documents))
+	for _, document := range wrapper.creator.documents {
+		if id, ok := documentID(document); ok {
+			byID[id] = document
+		}
+	}
+	documents := make([]interface{}, 0, len(failed))
+	for _, item := range failed {
+		document, ok := byID[item.ID]
+		if !ok {
+			return nil, except.NewValidationError(fmt.Errorf("failed document %s has no _id in the request", item.ID))
+		}
+		documents = append(documents, document)
+	}
+
+	retry := *wrapper.creator
+	retry.documents = documents
+	return retry.Do(ctx)
+}
+
+func documentID(document interface{}) (string, bool) {
+	fields, ok := document.(map[string]interface{})
+	if !ok {
+		data, err := json.Marshal(document)
+		if err != nil {
+			return "", false
+		}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return "", false
+		}
+	}
+	id, ok := fields["_id"].(string)
+	return id, ok && id != ""
+}
+
+// Items returns the per id results of a delete by ids, ids which were
+// not deleted are reported as failed
+func (wrapper *DeleteWrapper) Items() []BulkItem {
+	if wrapper == nil || wrapper.Docs == nil {
+		return nil
+	}
+	deleted := make(map[string]struct{}, len(wrapper.Docs.Data.DocumentsIDs))
+	for _, id := range wrapper.Docs.Data.DocumentsIDs {
+		deleted[id] = struct{}{}
+	}
+	if wrapper.deleter == nil || len(wrapper.deleter.ids) == 0 {
+		items := make([]BulkItem, 0, len(wrapper.Docs.Data.DocumentsIDs))
+		for _, id := range wrapper.Docs.Data.DocumentsIDs {
+			items = append(items, BulkItem{ID: id})
+		}
+		return items
+	}
+	items := make([]BulkItem, 0, len(wrapper.deleter.ids))
+	for _, id := range wrapper.deleter.ids {
+		item := BulkItem{ID: id}
+		if _, ok := deleted[id]; !ok {
+			item.Code = 404
+			item.Msg = "document not deleted"
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// FailedItems returns the ids which were not deleted
+func (wrapper *DeleteWrapper) FailedItems() []BulkItem {
+	return failedItems(wrapper.Items())
+}
+
+// RetryFailed deletes again the ids which were not deleted
+func (wrapper *DeleteWrapper) RetryFailed(ctx context.Context) (*DeleteWrapper, error) {
+	failed := wrapper.FailedItems()
+	if len(failed) == 0 {
+		return wrapper, nil
+	}
+	ids := make([]string, 0, len(failed))
+	for _, item := range failed {
+		ids = append(ids, item.ID)
+	}
+	retry := *wrapper.deleter
+	retry.ids = ids
+	retry.filters = nil
+	return retry.Do(ctx)
+}
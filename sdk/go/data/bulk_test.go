@@ -0,0 +1,72 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+)
+
+func TestBulk_RetryFailed(t *testing.T) {
+	var upserts [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Documents []map[string]interface{} `json:"documents"`
+			IDs       []string                 `json:"document_ids"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		if r.URL.Path == "/document/delete" {
+			// only "a" exists
+			fmt.Fprint(w, `{"code":0,"data":{"total":1,"document_ids":["a"]}}`)
+			return
+		}
+		ids := make([]string, 0, len(req.Documents))
+		items := make([]string, 0, len(req.Documents))
+		for _, doc := range req.Documents {
+			id := doc["_id"].(string)
+			ids = append(ids, id)
+			// "b" fails on the first attempt only
+			if id == "b" && len(upserts) == 0 {
+				items = append(items, `{"_id":"b","code":404,"error_code":2,"msg":"bad field"}`)
+			} else {
+				items = append(items, fmt.Sprintf(`{"_id":%q}`, id))
+			}
+		}
+		upserts = append(upserts, ids)
+		fmt.Fprintf(w, `{"code":0,"data":{"total":%d,"document_ids":[%s]}}`, len(ids), strings.Join(items, ","))
+	}))
+	defer server.Close()
+
+	api := New(connection.NewConnection(server.URL, nil, nil))
+	ctx := context.Background()
+
+	docs := []interface{}{
+		map[string]interface{}{"_id": "a"},
+		struct {
+			ID string `json:"_id"`
+		}{ID: "b"},
+		map[string]interface{}{"_id": "c"},
+	}
+	resp, err := api.Creator().WithDBName("db").WithSpaceName("space").WithDocs(docs).Do(ctx)
+	require.Nil(t, err)
+	assert.True(t, resp.PartialSuccess())
+	failed := resp.FailedItems()
+	require.Len(t, failed, 1)
+	assert.Equal(t, BulkItem{ID: "b", Code: 404, ErrorCode: 2, Msg: "bad field"}, failed[0])
+
+	retried, err := resp.RetryFailed(ctx)
+	require.Nil(t, err)
+	assert.Empty(t, retried.FailedItems())
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"b"}}, upserts)
+
+	deleted, err := api.Deleter().WithDBName("db").WithSpaceName("space").WithIDs([]string{"a", "x"}).Do(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, []BulkItem{{ID: "x", Code: 404, Msg: "document not deleted"}}, deleted.FailedItems())
+}
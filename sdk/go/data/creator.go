@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/vearch/vearch/v3/sdk/go/connection"
@@ -13,15 +14,14 @@ type ResultDocs struct {
 	Code int     `json:"code"`
 	Msg  *string `json:"msg,omitempty"`
 	Data struct {
-		Total       int `json:"total"`
-		DocumentIds []struct {
-			ID string `json:"_id"`
-		} `json:"document_ids"`
+		Total       int        `json:"total"`
+		DocumentIds []BulkItem `json:"document_ids"`
 	} `json:"data"`
 }
 
 type DocWrapper struct {
-	Docs *ResultDocs
+	Docs    *ResultDocs
+	creator *Creator
 }
 
 type Creator struct {
@@ -29,6 +29,7 @@ type Creator struct {
 	dbName     string
 	spaceName  string
 	documents  []interface{}
+	schema     *models.SpaceSchema
 }
 
 func (creator *Creator) WithDBName(name string) *Creator {
@@ -46,9 +47,22 @@ func (creator *Creator) WithDocs(documents []interface{}) *Creator {
 	return creator
 }
 
+// WithSchema validates the documents by the schema before they are sent
+func (creator *Creator) WithSchema(schema *models.SpaceSchema) *Creator {
+	creator.schema = schema
+	return creator
+}
+
 func (creator *Creator) Do(ctx context.Context) (*DocWrapper, error) {
 	var err error
 	var responseData *connection.ResponseData
+	if creator.schema != nil {
+		for i, document := range creator.documents {
+			if err := creator.schema.ValidateDocument(document); err != nil {
+				return nil, except.NewValidationError(fmt.Errorf("document %d: %w", i, err))
+			}
+		}
+	}
 	doc, _ := creator.PayloadDoc()
 
 	path := creator.buildPath()
@@ -61,7 +75,8 @@ func (creator *Creator) Do(ctx context.Context) (*DocWrapper, error) {
 	var resultDoc ResultDocs
 	parseErr := responseData.DecodeBodyIntoTarget(&resultDoc)
 	return &DocWrapper{
-		Docs: &resultDoc,
+		Docs:    &resultDoc,
+		creator: creator,
 	}, parseErr
 }
 
@@ -28,6 +28,12 @@ func (data *API) Query() *Query {
 	}
 }
 
+func (data *API) Getter() *Getter {
+	return &Getter{
+		connection: data.connection,
+	}
+}
+
 func (data *API) Deleter() *Deleter {
 	return &Deleter{
 		connection: data.connection,
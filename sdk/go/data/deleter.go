@@ -19,7 +19,8 @@ type DeleteResultDocs struct {
 }
 
 type DeleteWrapper struct {
-	Docs *DeleteResultDocs
+	Docs    *DeleteResultDocs
+	deleter *Deleter
 }
 
 type Deleter struct {
@@ -65,7 +66,8 @@ func (query *Deleter) Do(ctx context.Context) (*DeleteWrapper, error) {
 	var resultDoc DeleteResultDocs
 	parseErr := responseData.DecodeBodyIntoTarget(&resultDoc)
 	return &DeleteWrapper{
-		Docs: &resultDoc,
+		Docs:    &resultDoc,
+		deleter: query,
 	}, parseErr
 }
 
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+	"github.com/vearch/vearch/v3/sdk/go/except"
+)
+
+type GetResultDocs struct {
+	Code int     `json:"code"`
+	Msg  *string `json:"msg,omitempty"`
+	Data struct {
+		Total     int                      `json:"total"`
+		Documents []map[string]interface{} `json:"documents"`
+	} `json:"data"`
+}
+
+type GetWrapper struct {
+	Docs *GetResultDocs
+}
+
+// Getter gets a document by id with GET, so its reply can be revalidated by
+// etag and cached by the client cache
+type Getter struct {
+	connection  *connection.Connection
+	dbName      string
+	spaceName   string
+	id          string
+	fields      []string
+	vectorValue bool
+}
+
+func (getter *Getter) WithDBName(name string) *Getter {
+	getter.dbName = name
+	return getter
+}
+
+func (getter *Getter) WithSpaceName(name string) *Getter {
+	getter.spaceName = name
+	return getter
+}
+
+func (getter *Getter) WithID(id string) *Getter {
+	getter.id = id
+	return getter
+}
+
+func (getter *Getter) WithFields(fields []string) *Getter {
+	getter.fields = fields
+	return getter
+}
+
+func (getter *Getter) WithVectorValue(vectorValue bool) *Getter {
+	getter.vectorValue = vectorValue
+	return getter
+}
+
+func (getter *Getter) Do(ctx context.Context) (*GetWrapper, error) {
+	responseData, err := getter.connection.RunREST(ctx, getter.buildPath(), http.MethodGet, nil)
+	respErr := except.CheckResponseDataErrorAndStatusCode(responseData, err, 200)
+	if respErr != nil {
+		return nil, respErr
+	}
+
+	var resultDoc GetResultDocs
+	parseErr := responseData.DecodeBodyIntoTarget(&resultDoc)
+	return &GetWrapper{
+		Docs: &resultDoc,
+	}, parseErr
+}
+
+func (getter *Getter) buildPath() string {
+	path := fmt.Sprintf("/document/dbs/%s/spaces/%s/documents/%s",
+		url.PathEscape(getter.dbName), url.PathEscape(getter.spaceName), url.PathEscape(getter.id))
+	params := url.Values{}
+	if len(getter.fields) > 0 {
+		params.Set("fields", strings.Join(getter.fields, ","))
+	}
+	if getter.vectorValue {
+		params.Set("vector_value", "true")
+	}
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	return path
+}
@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 
 	"github.com/vearch/vearch/v3/sdk/go/connection"
@@ -14,6 +15,10 @@ type SearchResultDocs struct {
 	Msg  *string `json:"msg,omitempty"`
 	Data struct {
 		Documents []interface{} `json:"documents"`
+		// Echo is the echo of the request
+		Echo json.RawMessage `json:"echo,omitempty"`
+		// Annotations are the static annotations of the space
+		Annotations map[string]string `json:"annotations,omitempty"`
 	} `json:"data"`
 }
 
@@ -28,6 +33,8 @@ type Searcher struct {
 	limit      int
 	vectors    []models.Vector
 	filters    *models.Filters
+	schema     *models.SpaceSchema
+	echo       json.RawMessage
 }
 
 func (searcher *Searcher) WithDBName(name string) *Searcher {
@@ -55,9 +62,29 @@ func (searcher *Searcher) WithFilters(filters *models.Filters) *Searcher {
 	return searcher
 }
 
+// WithSchema validates the vectors by the schema before they are sent
+func (searcher *Searcher) WithSchema(schema *models.SpaceSchema) *Searcher {
+	searcher.schema = schema
+	return searcher
+}
+
+// WithEcho sets a json value returned untouched in the response, to
+// correlate the responses of searches in flight
+func (searcher *Searcher) WithEcho(echo json.RawMessage) *Searcher {
+	searcher.echo = echo
+	return searcher
+}
+
 func (searcher *Searcher) Do(ctx context.Context) (*SearchWrapper, error) {
 	var err error
 	var responseData *connection.ResponseData
+	if searcher.schema != nil {
+		for _, vector := range searcher.vectors {
+			if err := searcher.schema.ValidateVector(vector); err != nil {
+				return nil, except.NewValidationError(err)
+			}
+		}
+	}
 	req, _ := searcher.PayloadDoc()
 
 	path := searcher.buildPath()
@@ -86,6 +113,7 @@ func (searcher *Searcher) PayloadDoc() (*models.SearchRequest, error) {
 		Limit:     searcher.limit,
 		Vectors:   searcher.vectors,
 		Filters:   searcher.filters,
+		Echo:      searcher.echo,
 	}
 	return &doc, nil
 }
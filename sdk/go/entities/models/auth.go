@@ -0,0 +1,42 @@
+package models
+
+// Privilege is the access a role has to a resource
+type Privilege string
+
+const (
+	PrivilegeNone      Privilege = "None"
+	PrivilegeWriteOnly Privilege = "WriteOnly"
+	PrivilegeReadOnly  Privilege = "ReadOnly"
+	PrivilegeWriteRead Privilege = "WriteRead"
+)
+
+// Resource is what a privilege applies to, ResourceAll covers every one
+type Resource string
+
+const (
+	ResourceAll       Resource = "ResourceAll"
+	ResourceCluster   Resource = "ResourceCluster"
+	ResourceServer    Resource = "ResourceServer"
+	ResourcePartition Resource = "ResourcePartition"
+	ResourceDB        Resource = "ResourceDB"
+	ResourceSpace     Resource = "ResourceSpace"
+	ResourceDocument  Resource = "ResourceDocument"
+	ResourceIndex     Resource = "ResourceIndex"
+	ResourceAlias     Resource = "ResourceAlias"
+	ResourceUser      Resource = "ResourceUser"
+	ResourceRole      Resource = "ResourceRole"
+	ResourceConfig    Resource = "ResourceConfig"
+	ResourceCache     Resource = "ResourceCache"
+)
+
+// Role is a named set of privileges
+type Role struct {
+	Name       string                 `json:"name"`
+	Privileges map[Resource]Privilege `json:"privileges,omitempty"`
+}
+
+// User is a user of the cluster, the server never returns its password
+type User struct {
+	Name string `json:"name"`
+	Role *Role  `json:"role,omitempty"`
+}
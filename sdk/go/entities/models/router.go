@@ -0,0 +1,9 @@
+package models
+
+// Router is a healthy router of the cluster registered for discovery
+type Router struct {
+	Address    string `json:"address"` // host:port of the http api
+	RpcAddress string `json:"rpc_address,omitempty"`
+	Version    string `json:"version,omitempty"`
+	StartTime  int64  `json:"start_time"` // unix seconds
+}
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type Vector struct {
 	Field   string    `json:"field"`
 	Feature []float32 `json:"feature"`
@@ -22,4 +24,6 @@ type SearchRequest struct {
 	Limit     int      `json:"limit"`
 	Vectors   []Vector `json:"vectors"`
 	Filters   *Filters `json:"filters,omitempty"`
+	// Echo is returned untouched in the response
+	Echo json.RawMessage `json:"echo,omitempty"`
 }
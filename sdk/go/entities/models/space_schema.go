@@ -0,0 +1,205 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// SpaceSchema is the schema of a space as described by the server, Version
+// changes whenever the space is updated
+type SpaceSchema struct {
+	DBName             string            `json:"db_name"`
+	SpaceName          string            `json:"space_name"`
+	Version            int64             `json:"version"`
+	PartitionNum       int               `json:"partition_num"`
+	ReplicaNum         int               `json:"replica_num"`
+	Fields             []*Field          `json:"fields"`
+	FieldAliases       map[string]string `json:"field_aliases,omitempty"`
+	DefaultVectorField string            `json:"default_vector_field,omitempty"`
+}
+
+// Field returns the field of name, nil if the space has no such field
+func (s *SpaceSchema) Field(name string) *Field {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// vectorLength is the length of the features of a vector field, binary
+// vectors pack eight dimensions in a byte
+func (f *Field) vectorLength() int {
+	if f.Index != nil && f.Index.Type == "BINARYIVF" {
+		return f.Dimension / 8
+	}
+	return f.Dimension
+}
+
+// ValidateDocument checks the fields of doc are fields of the space with
+// values of their types, doc is a map or a struct encoded as json object
+func (s *SpaceSchema) ValidateDocument(doc interface{}) error {
+	values, ok := doc.(map[string]interface{})
+	if !ok {
+		bs, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		values = make(map[string]interface{})
+		if err := json.Unmarshal(bs, &values); err != nil {
+			return fmt.Errorf("document should be a json object: %v", err)
+		}
+	}
+	for name, value := range values {
+		if name == "_id" || value == nil {
+			continue
+		}
+		field := s.Field(name)
+		if field == nil {
+			return fmt.Errorf("field %q is not in space %s", name, s.SpaceName)
+		}
+		if err := field.validateValue(value); err != nil {
+			return fmt.Errorf("field %q of space %s: %v", name, s.SpaceName, err)
+		}
+	}
+	return nil
+}
+
+// ValidateVector checks the vector searches a vector field of the space by
+// features of its dimension, several features may be searched at once
+func (s *SpaceSchema) ValidateVector(vector Vector) error {
+	name := vector.Field
+	if name == "" {
+		name = s.DefaultVectorField
+	}
+	field := s.Field(name)
+	if field == nil || field.Type != "vector" {
+		return fmt.Errorf("field %q is not a vector field of space %s", vector.Field, s.SpaceName)
+	}
+	length := field.vectorLength()
+	if len(vector.Feature) == 0 || length <= 0 || len(vector.Feature)%length != 0 {
+		return fmt.Errorf("feature of field %q has %d values, should be a multiple of %d", name, len(vector.Feature), length)
+	}
+	return nil
+}
+
+func (f *Field) validateValue(value interface{}) error {
+	switch f.Type {
+	case "string", "text", "keyword":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("should be string but got %T", value)
+		}
+	case "stringArray", "StringArray", "string_array":
+		array, ok := toArray(value)
+		if !ok {
+			return fmt.Errorf("should be string array but got %T", value)
+		}
+		for _, v := range array {
+			if _, ok := v.(string); !ok {
+				return fmt.Errorf("should be string array but has %T", v)
+			}
+		}
+	case "integer", "short", "byte", "long":
+		n, ok := toNumber(value)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("should be %s but got %v", f.Type, value)
+		}
+	case "float", "double":
+		if _, ok := toNumber(value); !ok {
+			return fmt.Errorf("should be %s but got %T", f.Type, value)
+		}
+	case "bool", "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("should be bool but got %T", value)
+		}
+	case "date":
+		if _, ok := value.(string); ok {
+			return nil
+		}
+		if _, ok := toNumber(value); !ok {
+			return fmt.Errorf("should be date string or timestamp but got %T", value)
+		}
+	case "vector":
+		array, ok := toArray(value)
+		if !ok {
+			return fmt.Errorf("should be vector but got %T", value)
+		}
+		if len(array) != f.vectorLength() {
+			return fmt.Errorf("vector has %d values but dimension is %d", len(array), f.vectorLength())
+		}
+		for _, v := range array {
+			if _, ok := toNumber(v); !ok {
+				return fmt.Errorf("vector should have numbers but has %T", v)
+			}
+		}
+	}
+	return nil
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// toArray returns the values of the slices documents are built with
+func toArray(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		array := make([]interface{}, len(v))
+		for i, s := range v {
+			array[i] = s
+		}
+		return array, true
+	case []float32:
+		array := make([]interface{}, len(v))
+		for i, x := range v {
+			array[i] = x
+		}
+		return array, true
+	case []float64:
+		array := make([]interface{}, len(v))
+		for i, x := range v {
+			array[i] = x
+		}
+		return array, true
+	case []uint8:
+		array := make([]interface{}, len(v))
+		for i, x := range v {
+			array[i] = x
+		}
+		return array, true
+	}
+	return nil, false
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpaceSchema_Validate(t *testing.T) {
+	schema := &SpaceSchema{
+		SpaceName: "ts_space",
+		Fields: []*Field{
+			{Name: "field_int", Type: "integer"},
+			{Name: "field_string", Type: "string"},
+			{Name: "field_vector", Type: "vector", Dimension: 4},
+		},
+		DefaultVectorField: "field_vector",
+	}
+
+	docs := []struct {
+		name    string
+		doc     interface{}
+		wantErr bool
+	}{
+		{"valid", map[string]interface{}{"_id": "1", "field_int": 1, "field_string": "a", "field_vector": []float32{1, 2, 3, 4}}, false},
+		{"struct", struct {
+			Int int `json:"field_int"`
+		}{1}, false},
+		{"unknown field", map[string]interface{}{"field_missing": 1}, true},
+		{"fractional integer", map[string]interface{}{"field_int": 1.5}, true},
+		{"number as string", map[string]interface{}{"field_string": 1}, true},
+		{"wrong dimension", map[string]interface{}{"field_vector": []float32{1, 2, 3}}, true},
+	}
+	for _, tt := range docs {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.ValidateDocument(tt.doc)
+			assert.Equal(t, tt.wantErr, err != nil, "error %v", err)
+		})
+	}
+
+	assert.Nil(t, schema.ValidateVector(Vector{Feature: []float32{1, 2, 3, 4, 5, 6, 7, 8}}))
+	assert.NotNil(t, schema.ValidateVector(Vector{Field: "field_vector", Feature: []float32{1, 2, 3}}))
+	assert.NotNil(t, schema.ValidateVector(Vector{Field: "field_int", Feature: []float32{1, 2, 3, 4}}))
+}
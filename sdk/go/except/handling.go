@@ -24,6 +24,16 @@ func NewDerivedClientError(err error) *fault.ClientError {
 	}
 }
 
+// NewValidationError reports a request rejected by the client before sending
+func NewValidationError(err error) *fault.ClientError {
+	return &fault.ClientError{
+		IsUnexpectedStatusCode: false,
+		StatusCode:             -1,
+		Msg:                    "invalid request",
+		DerivedFromError:       err,
+	}
+}
+
 func NewUnexpectedStatusCodeErrorFromRESTResponse(responseData *connection.ResponseData) *fault.ClientError {
 	return NewClientError(responseData.StatusCode, string(responseData.Body))
 }
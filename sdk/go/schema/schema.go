@@ -33,3 +33,13 @@ func (schema *API) SpaceDeleter() *SpaceDeleter {
 		connection: schema.connection,
 	}
 }
+
+// GetSpace returns the getter of the typed schema of a space, the schema can
+// validate documents and vectors before they are sent
+func (schema *API) GetSpace(dbName, spaceName string) *SpaceGetter {
+	return &SpaceGetter{
+		connection: schema.connection,
+		dbName:     dbName,
+		spaceName:  spaceName,
+	}
+}
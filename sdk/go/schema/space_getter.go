@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/vearch/vearch/v3/sdk/go/connection"
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+	"github.com/vearch/vearch/v3/sdk/go/except"
+)
+
+type SpaceGetter struct {
+	connection *connection.Connection
+	dbName     string
+	spaceName  string
+}
+
+func (sg *SpaceGetter) WithDBName(dbName string) *SpaceGetter {
+	sg.dbName = dbName
+	return sg
+}
+
+func (sg *SpaceGetter) WithSpaceName(spaceName string) *SpaceGetter {
+	sg.spaceName = spaceName
+	return sg
+}
+
+// Do describes the space and returns its schema
+func (sg *SpaceGetter) Do(ctx context.Context) (*models.SpaceSchema, error) {
+	responseData, err := sg.connection.RunREST(ctx, fmt.Sprintf("/dbs/%s/spaces/%s", sg.dbName, sg.spaceName), http.MethodGet, nil)
+	if err := except.CheckResponseDataErrorAndStatusCode(responseData, err, 200); err != nil {
+		return nil, err
+	}
+
+	result := &struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg,omitempty"`
+		Data struct {
+			models.SpaceSchema
+			Schema struct {
+				Fields []*models.Field `json:"fields"`
+			} `json:"schema"`
+		} `json:"data"`
+	}{}
+	if err := responseData.DecodeBodyIntoTarget(result); err != nil {
+		return nil, err
+	}
+	if result.Code != 0 {
+		return nil, except.NewClientError(responseData.StatusCode, "describe space %s/%s: %s", sg.dbName, sg.spaceName, result.Msg)
+	}
+	schema := result.Data.SpaceSchema
+	schema.Fields = result.Data.Schema.Fields
+	return &schema, nil
+}
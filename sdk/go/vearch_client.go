@@ -1,9 +1,13 @@
 package vearch
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/vearch/vearch/v3/sdk/go/auth"
+	"github.com/vearch/vearch/v3/sdk/go/cache"
+	"github.com/vearch/vearch/v3/sdk/go/cluster"
 	"github.com/vearch/vearch/v3/sdk/go/connection"
 	"github.com/vearch/vearch/v3/sdk/go/data"
 	"github.com/vearch/vearch/v3/sdk/go/schema"
@@ -14,12 +18,25 @@ type Config struct {
 	ConnectionClient *http.Client
 	AuthConfig       auth.Config
 	Headers          map[string]string
+	// Middlewares intercept every request of the client, for tracing,
+	// metrics, auth injection or logging
+	Middlewares []connection.Middleware
+	// Cache keeps document gets by id and space schema lookups in memory,
+	// nil disables it
+	Cache *cache.Cache
+	// DiscoveryInterval makes Host a seed, the client sends its requests to
+	// the healthy routers listed by the cluster and refreshes them every
+	// interval, 0 sends every request to Host
+	DiscoveryInterval time.Duration
 }
 
 type Client struct {
 	connection *connection.Connection
 	schema     *schema.API
 	data       *data.API
+	cluster    *cluster.API
+	auth       *auth.API
+	cancel     context.CancelFunc
 }
 
 func NewClient(config Config) (*Client, error) {
@@ -38,14 +55,54 @@ func NewClient(config Config) (*Client, error) {
 		}
 	}
 	con := connection.NewConnection(config.Host, config.ConnectionClient, config.Headers)
+	con.Use(config.Middlewares...)
+	if config.Cache != nil {
+		con.Use(config.Cache.Middleware())
+	}
 	client := &Client{
 		connection: con,
 		schema:     schema.New(con),
 		data:       data.New(con),
+		cluster:    cluster.New(con),
+		auth:       auth.New(con),
+	}
+	if config.DiscoveryInterval > 0 {
+		client.discover(config.Host, config.DiscoveryInterval)
 	}
 	return client, nil
 }
 
+// discover lists the routers through the routers already known, without
+// the middlewares of the client, so the seed going away does not stop the
+// refresh
+func (c *Client) discover(seed string, interval time.Duration) {
+	endpoints := connection.NewEndpoints(seed)
+	con := connection.NewConnection(seed, c.connection.HTTPClient(), c.connection.Headers())
+	con.UseEndpoints(endpoints)
+	lister := cluster.New(con).RouterLister()
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	endpoints.Refresh(ctx, interval, func(ctx context.Context) ([]string, error) {
+		routers, err := lister.Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]string, 0, len(routers))
+		for _, router := range routers {
+			addrs = append(addrs, router.Address)
+		}
+		return addrs, nil
+	})
+	c.connection.UseEndpoints(endpoints)
+}
+
+// Close stops refreshing the routers of the cluster
+func (c *Client) Close() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
 func (c *Client) Schema() *schema.API {
 	return c.schema
 }
@@ -53,3 +110,12 @@ func (c *Client) Schema() *schema.API {
 func (c *Client) Data() *data.API {
 	return c.data
 }
+
+func (c *Client) Cluster() *cluster.API {
+	return c.cluster
+}
+
+// Auth manages the users and the roles of the cluster
+func (c *Client) Auth() *auth.API {
+	return c.auth
+}
@@ -0,0 +1,243 @@
+package vearchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+)
+
+const (
+	metricL2           = "L2"
+	metricInnerProduct = "InnerProduct"
+)
+
+type hit struct {
+	id    string
+	score float64
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		selectRequest
+		Vectors []models.Vector `json:"vectors"`
+		Echo    json.RawMessage `json:"echo"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.lookup(w, req.DBName, req.SpaceName)
+	if sp == nil {
+		return
+	}
+	if len(req.Vectors) == 0 {
+		writeError(w, http.StatusBadRequest, codeParamError, "vectors is empty")
+		return
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	// a feature may hold several queries of the field dimension, every
+	// vector field should have the same number of them
+	metric := ""
+	queries := -1
+	for _, vector := range req.Vectors {
+		field := sp.field(vector.Field)
+		if field == nil || field.Type != "vector" {
+			writeError(w, http.StatusBadRequest, codeParamError, "%s is not a vector field", vector.Field)
+			return
+		}
+		if len(vector.Feature) == 0 || len(vector.Feature)%field.Dimension != 0 {
+			writeError(w, http.StatusBadRequest, codeParamError, "feature of %s should be a multiple of dimension %d", vector.Field, field.Dimension)
+			return
+		}
+		n := len(vector.Feature) / field.Dimension
+		if queries >= 0 && n != queries {
+			writeError(w, http.StatusBadRequest, codeParamError, "vectors should have the same number of queries")
+			return
+		}
+		queries = n
+		fieldMetric := metricL2
+		if field.Index != nil && field.Index.Params != nil && field.Index.Params.MetricType != "" {
+			fieldMetric = field.Index.Params.MetricType
+		}
+		if metric != "" && metric != fieldMetric {
+			writeError(w, http.StatusBadRequest, codeParamError, "vector fields should have the same metric type")
+			return
+		}
+		metric = fieldMetric
+	}
+	if metric != metricL2 && metric != metricInnerProduct {
+		writeError(w, http.StatusBadRequest, codeParamError, "unsupported metric type %s", metric)
+		return
+	}
+
+	candidates := make([]string, 0, len(sp.ids))
+	for _, id := range sp.ids {
+		if req.Filters != nil {
+			ok, code, err := match(req.Filters, sp.docs[id])
+			if err != nil {
+				writeError(w, http.StatusBadRequest, code, "%v", err)
+				return
+			}
+			if !ok {
+				continue
+			}
+		}
+		candidates = append(candidates, id)
+	}
+
+	documents := make([][]map[string]interface{}, 0, queries)
+	for q := 0; q < queries; q++ {
+		hits := make([]hit, 0, len(candidates))
+		for _, id := range candidates {
+			doc := sp.docs[id]
+			score, ok := 0.0, true
+			for _, vector := range req.Vectors {
+				value, has := doc[vector.Field].([]float32)
+				if !has {
+					ok = false
+					break
+				}
+				dim := len(value)
+				score += distance(metric, vector.Feature[q*dim:(q+1)*dim], value)
+			}
+			if ok {
+				hits = append(hits, hit{id: id, score: score})
+			}
+		}
+		sort.SliceStable(hits, func(i, j int) bool {
+			if metric == metricL2 {
+				return hits[i].score < hits[j].score
+			}
+			return hits[i].score > hits[j].score
+		})
+		if len(hits) > limit {
+			hits = hits[:limit]
+		}
+		docs := make([]map[string]interface{}, 0, len(hits))
+		for _, h := range hits {
+			doc := sp.source(sp.docs[h.id], req.VectorValue)
+			doc["_score"] = h.score
+			docs = append(docs, doc)
+		}
+		documents = append(documents, docs)
+	}
+	result := map[string]interface{}{"documents": documents}
+	if len(req.Echo) > 0 {
+		result["echo"] = req.Echo
+	}
+	writeData(w, result)
+}
+
+// distance is the squared euclidean distance for L2 and the dot product
+// for InnerProduct, like the engine
+func distance(metric string, a, b []float32) float64 {
+	var score float64
+	for i := range a {
+		if metric == metricL2 {
+			d := float64(a[i]) - float64(b[i])
+			score += d * d
+		} else {
+			score += float64(a[i]) * float64(b[i])
+		}
+	}
+	return score
+}
+
+// match reports whether the document satisfies all the conditions
+func match(filters *models.Filters, doc map[string]interface{}) (bool, int, error) {
+	if filters.Operator != "AND" {
+		return false, codeFilterOperator, fmt.Errorf("filters operator should be AND")
+	}
+	for _, condition := range filters.Conditions {
+		value, ok := doc[condition.Field]
+		if !ok {
+			return false, codeSuccess, nil
+		}
+		switch condition.Operator {
+		case "<", "<=", ">", ">=":
+			c, ok := compare(value, condition.Value)
+			if !ok {
+				return false, codeParamError, fmt.Errorf("can not compare field %s with %v", condition.Field, condition.Value)
+			}
+			if (condition.Operator == "<" && c >= 0) || (condition.Operator == "<=" && c > 0) ||
+				(condition.Operator == ">" && c <= 0) || (condition.Operator == ">=" && c < 0) {
+				return false, codeSuccess, nil
+			}
+		case "IN", "NOT IN":
+			terms, ok := condition.Value.([]interface{})
+			if !ok {
+				return false, codeParamError, fmt.Errorf("value of %s should be an array", condition.Operator)
+			}
+			in := false
+			for _, term := range terms {
+				if fmt.Sprint(term) == fmt.Sprint(value) {
+					in = true
+					break
+				}
+			}
+			if in != (condition.Operator == "IN") {
+				return false, codeSuccess, nil
+			}
+		default:
+			return false, codeFilterCondition, fmt.Errorf("unsupported condition operator %s", condition.Operator)
+		}
+	}
+	return true, codeSuccess, nil
+}
+
+// compare returns the order of a and b, numbers and strings are comparable
+func compare(a, b interface{}) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	y, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,520 @@
+// Package vearchtest provides an in-memory fake of the router REST API, so
+// code built on the Go SDK can be unit tested without a running cluster.
+//
+//	server := vearchtest.NewServer()
+//	defer server.Close()
+//	client, _ := vearch.NewClient(vearch.Config{Host: server.URL})
+//
+// The fake keeps databases, spaces and documents in memory and searches by
+// brute force, it supports the L2 and InnerProduct metrics and filters
+// joined by AND. Indexes, partitions and replicas are accepted but ignored.
+package vearchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+)
+
+// error codes of the server
+const (
+	codeSuccess          = 0
+	codeParamError       = 6
+	codeDBNotExist       = 200
+	codeDBExist          = 201
+	codeDBNotEmpty       = 202
+	codeSpaceExist       = 220
+	codeSpaceNotExist    = 221
+	codeDocumentNotExist = 260
+	codeFilterOperator   = 300
+	codeFilterCondition  = 301
+)
+
+const defaultLimit = 50
+
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	dbs     map[string]*database
+	routers []*models.Router
+}
+
+type database struct {
+	id     int64
+	spaces map[string]*space
+}
+
+type space struct {
+	id           int64
+	version      int64
+	partitionNum int
+	replicaNum   int
+	fields       []*models.Field
+	docs         map[string]map[string]interface{}
+	// ids in insertion order, so queries are stable
+	ids   []string
+	idSeq int64
+}
+
+// NewServer starts a fake server, Close it when the test is done
+func NewServer() *Server {
+	s := &Server{dbs: make(map[string]*database)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dbs/{db}", s.createDB)
+	mux.HandleFunc("GET /dbs/{db}", s.getDB)
+	mux.HandleFunc("DELETE /dbs/{db}", s.deleteDB)
+	mux.HandleFunc("POST /dbs/{db}/spaces", s.createSpace)
+	mux.HandleFunc("GET /dbs/{db}/spaces", s.listSpaces)
+	mux.HandleFunc("GET /dbs/{db}/spaces/{space}", s.getSpace)
+	mux.HandleFunc("DELETE /dbs/{db}/spaces/{space}", s.deleteSpace)
+	mux.HandleFunc("POST /document/upsert", s.upsert)
+	mux.HandleFunc("POST /document/query", s.query)
+	mux.HandleFunc("POST /document/search", s.search)
+	mux.HandleFunc("POST /document/delete", s.delete)
+	mux.HandleFunc("GET /document/dbs/{db}/spaces/{space}/documents/{id}", s.getDocument)
+	mux.HandleFunc("GET /discovery/routers", s.discoverRouters)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Reset drops all databases
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dbs = make(map[string]*database)
+}
+
+// SetRouters sets the routers the server lists for discovery, none by
+// default
+func (s *Server) SetRouters(addrs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routers = make([]*models.Router, 0, len(addrs))
+	for _, addr := range addrs {
+		s.routers = append(s.routers, &models.Router{Address: addr})
+	}
+}
+
+func (s *Server) discoverRouters(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	routers := s.routers
+	if routers == nil {
+		routers = []*models.Router{}
+	}
+	writeData(w, map[string]interface{}{"routers": routers, "count": len(routers)})
+}
+
+type reply struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg,omitempty"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&reply{Code: codeSuccess, Data: data})
+}
+
+func writeError(w http.ResponseWriter, status int, code int, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&reply{Code: code, Msg: fmt.Sprintf(format, args...)})
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, codeParamError, "invalid request body: %v", err)
+		return false
+	}
+	return true
+}
+
+func (s *Server) createDB(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := r.PathValue("db")
+	if _, ok := s.dbs[name]; ok {
+		writeError(w, http.StatusBadRequest, codeDBExist, "db %s already exists", name)
+		return
+	}
+	db := &database{id: int64(len(s.dbs) + 1), spaces: make(map[string]*space)}
+	s.dbs[name] = db
+	writeData(w, map[string]interface{}{"id": db.id, "name": name})
+}
+
+func (s *Server) getDB(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := r.PathValue("db")
+	db, ok := s.dbs[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeDBNotExist, "db %s not exists", name)
+		return
+	}
+	writeData(w, map[string]interface{}{"id": db.id, "name": name})
+}
+
+func (s *Server) deleteDB(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := r.PathValue("db")
+	db, ok := s.dbs[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeDBNotExist, "db %s not exists", name)
+		return
+	}
+	if len(db.spaces) > 0 {
+		writeError(w, http.StatusBadRequest, codeDBNotEmpty, "db %s is not empty", name)
+		return
+	}
+	delete(s.dbs, name)
+	writeData(w, nil)
+}
+
+func (s *Server) createSpace(w http.ResponseWriter, r *http.Request) {
+	var req models.Space
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dbName := r.PathValue("db")
+	db, ok := s.dbs[dbName]
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeDBNotExist, "db %s not exists", dbName)
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, codeParamError, "space name is empty")
+		return
+	}
+	if _, ok := db.spaces[req.Name]; ok {
+		writeError(w, http.StatusBadRequest, codeSpaceExist, "space %s already exists", req.Name)
+		return
+	}
+	names := make(map[string]bool, len(req.Fields))
+	for _, field := range req.Fields {
+		if field.Name == "" || names[field.Name] {
+			writeError(w, http.StatusBadRequest, codeParamError, "field name %q is empty or duplicated", field.Name)
+			return
+		}
+		names[field.Name] = true
+		if field.Type == "vector" && field.Dimension <= 0 {
+			writeError(w, http.StatusBadRequest, codeParamError, "vector field %s has no dimension", field.Name)
+			return
+		}
+	}
+	sp := &space{
+		id:           int64(len(db.spaces) + 1),
+		version:      1,
+		partitionNum: req.PartitionNum,
+		replicaNum:   req.ReplicaNum,
+		fields:       req.Fields,
+		docs:         make(map[string]map[string]interface{}),
+	}
+	db.spaces[req.Name] = sp
+	writeData(w, sp.describe(dbName, req.Name))
+}
+
+func (sp *space) describe(dbName, spaceName string) map[string]interface{} {
+	return map[string]interface{}{
+		"space_name":    spaceName,
+		"db_name":       dbName,
+		"version":       sp.version,
+		"doc_num":       len(sp.docs),
+		"partition_num": sp.partitionNum,
+		"replica_num":   sp.replicaNum,
+		"schema":        map[string]interface{}{"fields": sp.fields},
+	}
+}
+
+func (s *Server) listSpaces(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dbName := r.PathValue("db")
+	db, ok := s.dbs[dbName]
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeDBNotExist, "db %s not exists", dbName)
+		return
+	}
+	spaces := make([]map[string]interface{}, 0, len(db.spaces))
+	for name, sp := range db.spaces {
+		spaces = append(spaces, sp.describe(dbName, name))
+	}
+	writeData(w, spaces)
+}
+
+// lookup returns the space or writes the error, the caller holds the lock
+func (s *Server) lookup(w http.ResponseWriter, dbName, spaceName string) *space {
+	db, ok := s.dbs[dbName]
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeDBNotExist, "db %s not exists", dbName)
+		return nil
+	}
+	sp, ok := db.spaces[spaceName]
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeSpaceNotExist, "space %s not exists in db %s", spaceName, dbName)
+		return nil
+	}
+	return sp
+}
+
+func (s *Server) getSpace(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.lookup(w, r.PathValue("db"), r.PathValue("space"))
+	if sp == nil {
+		return
+	}
+	writeData(w, sp.describe(r.PathValue("db"), r.PathValue("space")))
+}
+
+func (s *Server) deleteSpace(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lookup(w, r.PathValue("db"), r.PathValue("space")) == nil {
+		return
+	}
+	delete(s.dbs[r.PathValue("db")].spaces, r.PathValue("space"))
+	writeData(w, nil)
+}
+
+func (sp *space) field(name string) *models.Field {
+	for _, field := range sp.fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func (s *Server) upsert(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DBName    string                   `json:"db_name"`
+		SpaceName string                   `json:"space_name"`
+		Documents []map[string]interface{} `json:"documents"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.lookup(w, req.DBName, req.SpaceName)
+	if sp == nil {
+		return
+	}
+	if len(req.Documents) == 0 {
+		writeError(w, http.StatusBadRequest, codeParamError, "documents is empty")
+		return
+	}
+
+	total := 0
+	items := make([]map[string]interface{}, 0, len(req.Documents))
+	for _, doc := range req.Documents {
+		id, _ := doc["_id"].(string)
+		if id == "" {
+			sp.idSeq++
+			id = fmt.Sprintf("%d", sp.idSeq)
+		}
+		item := map[string]interface{}{"_id": id}
+		if err := sp.upsert(id, doc); err != nil {
+			// same shape as the router, a failed item has code and msg
+			item["code"] = http.StatusNotFound
+			item["error_code"] = codeParamError
+			item["msg"] = err.Error()
+		} else {
+			total++
+		}
+		items = append(items, item)
+	}
+	writeData(w, map[string]interface{}{"total": total, "document_ids": items})
+}
+
+// upsert merges the fields into the document after checking them against
+// the schema
+func (sp *space) upsert(id string, doc map[string]interface{}) error {
+	values := make(map[string]interface{}, len(doc))
+	for name, value := range doc {
+		if name == "_id" {
+			continue
+		}
+		field := sp.field(name)
+		if field == nil {
+			return fmt.Errorf("unknown field %s", name)
+		}
+		if field.Type == "vector" {
+			vector, err := toVector(value)
+			if err != nil {
+				return fmt.Errorf("field %s: %v", name, err)
+			}
+			if len(vector) != field.Dimension {
+				return fmt.Errorf("field %s dimension should be %d but got %d", name, field.Dimension, len(vector))
+			}
+			value = vector
+		}
+		values[name] = value
+	}
+
+	old, ok := sp.docs[id]
+	if !ok {
+		old = map[string]interface{}{"_id": id}
+		sp.ids = append(sp.ids, id)
+		sp.docs[id] = old
+	}
+	for name, value := range values {
+		old[name] = value
+	}
+	return nil
+}
+
+func toVector(value interface{}) ([]float32, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("vector should be an array of numbers")
+	}
+	vector := make([]float32, 0, len(values))
+	for _, v := range values {
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("vector should be an array of numbers")
+		}
+		vector = append(vector, float32(f))
+	}
+	return vector, nil
+}
+
+// source returns the document without vectors, as returned by queries
+func (sp *space) source(doc map[string]interface{}, vectorValue bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(doc))
+	for name, value := range doc {
+		if field := sp.field(name); field != nil && field.Type == "vector" && !vectorValue {
+			continue
+		}
+		out[name] = value
+	}
+	return out
+}
+
+func (s *Server) getDocument(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.lookup(w, r.PathValue("db"), r.PathValue("space"))
+	if sp == nil {
+		return
+	}
+	doc, ok := sp.docs[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeDocumentNotExist, "document %s not found in space %s", r.PathValue("id"), r.PathValue("space"))
+		return
+	}
+	docs := []map[string]interface{}{sp.source(doc, r.URL.Query().Get("vector_value") == "true")}
+	writeData(w, map[string]interface{}{"total": 1, "documents": docs})
+}
+
+type selectRequest struct {
+	DBName      string          `json:"db_name"`
+	SpaceName   string          `json:"space_name"`
+	IDs         []string        `json:"document_ids"`
+	Filters     *models.Filters `json:"filters"`
+	Limit       int             `json:"limit"`
+	VectorValue bool            `json:"vector_value"`
+}
+
+// selectIDs returns the ids matching the ids or the filters of the request
+func (sp *space) selectIDs(req *selectRequest) ([]string, int, error) {
+	if len(req.IDs) > 0 && req.Filters != nil {
+		return nil, codeParamError, fmt.Errorf("document_ids and filters can not both be set")
+	}
+	if len(req.IDs) > 0 {
+		ids := make([]string, 0, len(req.IDs))
+		for _, id := range req.IDs {
+			if _, ok := sp.docs[id]; ok {
+				ids = append(ids, id)
+			}
+		}
+		return ids, codeSuccess, nil
+	}
+	if req.Filters == nil {
+		return nil, codeParamError, fmt.Errorf("one of document_ids or filters should be set")
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	ids := make([]string, 0)
+	for _, id := range sp.ids {
+		ok, code, err := match(req.Filters, sp.docs[id])
+		if err != nil {
+			return nil, code, err
+		}
+		if ok {
+			ids = append(ids, id)
+			if len(ids) == limit {
+				break
+			}
+		}
+	}
+	return ids, codeSuccess, nil
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request) {
+	var req selectRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.lookup(w, req.DBName, req.SpaceName)
+	if sp == nil {
+		return
+	}
+	ids, code, err := sp.selectIDs(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, code, "%v", err)
+		return
+	}
+	docs := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		docs = append(docs, sp.source(sp.docs[id], req.VectorValue))
+	}
+	writeData(w, map[string]interface{}{"total": len(docs), "documents": docs})
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	var req selectRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.lookup(w, req.DBName, req.SpaceName)
+	if sp == nil {
+		return
+	}
+	ids, code, err := sp.selectIDs(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, code, "%v", err)
+		return
+	}
+	deleted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		delete(sp.docs, id)
+		deleted[id] = true
+	}
+	remain := sp.ids[:0]
+	for _, id := range sp.ids {
+		if !deleted[id] {
+			remain = append(remain, id)
+		}
+	}
+	sp.ids = remain
+	writeData(w, map[string]interface{}{"total": len(ids), "document_ids": ids})
+}
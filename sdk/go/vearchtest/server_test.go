@@ -0,0 +1,111 @@
+package vearchtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vearch "github.com/vearch/vearch/v3/sdk/go"
+	"github.com/vearch/vearch/v3/sdk/go/entities/models"
+	"github.com/vearch/vearch/v3/sdk/go/vearchtest"
+)
+
+func TestServer(t *testing.T) {
+	server := vearchtest.NewServer()
+	defer server.Close()
+	client, err := vearch.NewClient(vearch.Config{Host: server.URL})
+	require.Nil(t, err)
+	ctx := context.Background()
+
+	require.Nil(t, client.Schema().DBCreator().WithDB(&models.DB{Name: "db"}).Do(ctx))
+	space := &models.Space{
+		Name:         "space",
+		PartitionNum: 1,
+		ReplicaNum:   1,
+		Fields: []*models.Field{
+			{Name: "name", Type: "string"},
+			{Name: "age", Type: "integer"},
+			{Name: "vec", Type: "vector", Dimension: 2, Index: &models.Index{Name: "idx", Type: "FLAT", Params: &models.IndexParams{MetricType: "L2"}}},
+		},
+	}
+	require.Nil(t, client.Schema().SpaceCreator().WithDBName("db").WithSpace(space).Do(ctx))
+	schema, err := client.Schema().GetSpace("db", "space").Do(ctx)
+	require.Nil(t, err)
+	assert.Len(t, schema.Fields, 3)
+
+	docs := []interface{}{
+		map[string]interface{}{"_id": "1", "name": "a", "age": 10, "vec": []float32{0, 0}},
+		map[string]interface{}{"_id": "2", "name": "b", "age": 20, "vec": []float32{1, 1}},
+		map[string]interface{}{"_id": "3", "name": "c", "age": 30, "vec": []float32{5, 5}},
+		map[string]interface{}{"_id": "4", "unknown": 1},
+	}
+	upserted, err := client.Data().Creator().WithDBName("db").WithSpaceName("space").WithDocs(docs).Do(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 3, upserted.Docs.Data.Total)
+	require.Len(t, upserted.FailedItems(), 1)
+	assert.Equal(t, "4", upserted.FailedItems()[0].ID)
+
+	filters := &models.Filters{Operator: "AND", Conditions: []models.Condition{{Operator: ">=", Field: "age", Value: 20}}}
+	searched, err := client.Data().Searcher().WithDBName("db").WithSpaceName("space").WithLimit(1).
+		WithVectors([]models.Vector{{Field: "vec", Feature: []float32{0.1, 0.1}}}).WithFilters(filters).
+		WithEcho(json.RawMessage(`{"request":7}`)).Do(ctx)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"request":7}`, string(searched.Docs.Data.Echo))
+	require.Len(t, searched.Docs.Data.Documents, 1)
+	hits := searched.Docs.Data.Documents[0].([]interface{})
+	require.Len(t, hits, 1)
+	assert.Equal(t, "2", hits[0].(map[string]interface{})["_id"])
+
+	got, err := client.Data().Getter().WithDBName("db").WithSpaceName("space").WithID("1").Do(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, "a", got.Docs.Data.Documents[0]["name"])
+	_, err = client.Data().Getter().WithDBName("db").WithSpaceName("space").WithID("4").Do(ctx)
+	assert.NotNil(t, err)
+
+	deleted, err := client.Data().Deleter().WithDBName("db").WithSpaceName("space").WithIDs([]string{"1", "4"}).Do(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1"}, deleted.Docs.Data.DocumentsIDs)
+
+	queried, err := client.Data().Query().WithDBName("db").WithSpaceName("space").WithFilters(filters).Do(ctx)
+	require.Nil(t, err)
+	assert.Len(t, queried.Docs.Data.Documents, 2)
+
+	assert.NotNil(t, client.Schema().DBDeleter().WithDBName("db").Do(ctx), "db with spaces")
+	require.Nil(t, client.Schema().SpaceDeleter().WithDBName("db").WithSpaceName("space").Do(ctx))
+	require.Nil(t, client.Schema().DBDeleter().WithDBName("db").Do(ctx))
+}
+
+func TestServerRouters(t *testing.T) {
+	server := vearchtest.NewServer()
+	defer server.Close()
+	client, err := vearch.NewClient(vearch.Config{Host: server.URL})
+	require.Nil(t, err)
+	ctx := context.Background()
+
+	routers, err := client.Cluster().RouterLister().Do(ctx)
+	require.Nil(t, err)
+	assert.Empty(t, routers)
+
+	server.SetRouters("10.0.0.1:9001", "10.0.0.2:9001")
+	routers, err = client.Cluster().RouterLister().Do(ctx)
+	require.Nil(t, err)
+	require.Len(t, routers, 2)
+	assert.Equal(t, "10.0.0.2:9001", routers[1].Address)
+}
+
+func TestServerDiscovery(t *testing.T) {
+	server := vearchtest.NewServer()
+	defer server.Close()
+	server.SetRouters(strings.TrimPrefix(server.URL, "http://"))
+	client, err := vearch.NewClient(vearch.Config{Host: server.URL, DiscoveryInterval: time.Minute})
+	require.Nil(t, err)
+	defer client.Close()
+
+	routers, err := client.Cluster().RouterLister().Do(context.Background())
+	require.Nil(t, err)
+	require.Len(t, routers, 1)
+}